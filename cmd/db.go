@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server"
+	"github.com/runatlantis/atlantis/server/events/db"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Flags of the db migrate command that the server doesn't have.
+const (
+	DBMigrateFromFlag = "from"
+	DBMigrateToFlag   = "to"
+	DBTenantFlag      = "tenant"
+)
+
+var dbMigrateFlags = map[string]stringFlag{
+	DBMigrateFromFlag: {
+		description: "Type of the database to copy from: " + strings.Join(dbTypes, ", ") + ".",
+	},
+	DBMigrateToFlag: {
+		description: "Type of the database to copy to: " + strings.Join(dbTypes, ", ") + ".",
+	},
+	DBTenantFlag: {
+		description: "Name of the tenant whose data is migrated. If not set, the data of the top-level organization is migrated.",
+	},
+}
+
+// dbConnectionFlags are the server flags that configure how to connect to
+// each type of database. They're read from the server's config file and
+// environment variables too.
+var dbConnectionFlags = []string{
+	ConfigFlag,
+	DataDirFlag,
+	DynamoDBEndpointFlag,
+	DynamoDBPullTTLFlag,
+	DynamoDBRegionFlag,
+	DynamoDBTableFlag,
+	PostgresURLFlag,
+	RedisAddrsFlag,
+	RedisClusterFlag,
+	RedisDBFlag,
+	RedisInsecureSkipVerify,
+	RedisPasswordFlag,
+	RedisPoolSizeFlag,
+	RedisSentinelMasterFlag,
+	RedisTLSEnabledFlag,
+}
+
+var dbTypes = []string{db.BoltDBType, db.RedisType, db.DynamoDBType, db.PostgresType}
+
+// DBCmd manages the database that stores the locks and statuses of pull
+// requests.
+type DBCmd struct {
+	Viper *viper.Viper
+	// Out is where results are printed. Defaults to stdout.
+	Out io.Writer
+}
+
+// Init returns the runnable cobra command.
+func (d *DBCmd) Init() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "db",
+		Short: "Manage the database of locks and pull request statuses",
+	}
+	c.AddCommand(d.migrateCmd())
+	return c
+}
+
+func (d *DBCmd) migrateCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "migrate",
+		Short: "Copy locks, pull request statuses and job history between databases",
+		Long: `Copy the locks, pull request statuses and job history from one type of database to another
+and verify they were copied, ex. atlantis db migrate --from boltdb --to postgres.
+The databases are configured with the same flags, environment variables or config file
+as the server. Stop Atlantis before migrating and start it with --locking-db-type
+set to the new database afterwards.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return d.migrate()
+		},
+	}
+
+	d.Viper.SetEnvPrefix("ATLANTIS")
+	d.Viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	d.Viper.AutomaticEnv()
+	d.Viper.SetTypeByDefaultValue(true)
+
+	for name, f := range dbMigrateFlags {
+		c.Flags().String(name, "", f.description)
+		d.Viper.BindPFlag(name, c.Flags().Lookup(name)) // nolint: errcheck
+	}
+	for _, name := range dbConnectionFlags {
+		if f, ok := stringFlags[name]; ok {
+			usage := f.description
+			if f.defaultValue != "" {
+				usage = fmt.Sprintf("%s (default %q)", usage, f.defaultValue)
+			}
+			c.Flags().String(name, "", usage)
+		} else if f, ok := intFlags[name]; ok {
+			c.Flags().Int(name, 0, f.description)
+		} else {
+			c.Flags().Bool(name, boolFlags[name].defaultValue, boolFlags[name].description)
+		}
+		d.Viper.BindPFlag(name, c.Flags().Lookup(name)) // nolint: errcheck
+	}
+	return c
+}
+
+func (d *DBCmd) migrate() error {
+	if configFile := d.Viper.GetString(ConfigFlag); configFile != "" {
+		d.Viper.SetConfigFile(configFile)
+		if err := d.Viper.ReadInConfig(); err != nil {
+			return errors.Wrapf(err, "invalid config: reading %s", configFile)
+		}
+	}
+	var userConfig server.UserConfig
+	if err := d.Viper.Unmarshal(&userConfig); err != nil {
+		return err
+	}
+	fromType := d.Viper.GetString(DBMigrateFromFlag)
+	toType := d.Viper.GetString(DBMigrateToFlag)
+	tenant := d.Viper.GetString(DBTenantFlag)
+	for _, f := range []struct{ name, value string }{{DBMigrateFromFlag, fromType}, {DBMigrateToFlag, toType}} {
+		if !isValidDBType(f.value) {
+			return fmt.Errorf("--%s must be one of %s", f.name, strings.Join(dbTypes, ", "))
+		}
+	}
+	if fromType == toType {
+		return fmt.Errorf("--%s and --%s must be different", DBMigrateFromFlag, DBMigrateToFlag)
+	}
+	if tenant != "" && !tenantNameRegex.MatchString(tenant) {
+		return fmt.Errorf("invalid tenant name %q: must only contain lowercase letters, numbers and dashes", tenant)
+	}
+
+	s := ServerCmd{}
+	s.setDefaults(&userConfig)
+	if err := s.setDataDir(&userConfig); err != nil {
+		return err
+	}
+	if tenant != "" {
+		userConfig.DataDir = filepath.Join(userConfig.DataDir, server.TenantsDirName, tenant)
+	}
+
+	from, err := d.newDatabase(userConfig, fromType, tenant)
+	if err != nil {
+		return err
+	}
+	defer closeDatabase(from)
+	to, err := d.newDatabase(userConfig, toType, tenant)
+	if err != nil {
+		return err
+	}
+	defer closeDatabase(to)
+	res, err := db.Migrate(from, to)
+	if err != nil {
+		return errors.Wrapf(err, "migrating from %s to %s", fromType, toType)
+	}
+
+	out := d.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintf(out, "Migrated %d locks, %d command locks, %d pull request statuses and %d jobs from %s to %s.\n",
+		res.Locks, res.CommandLocks, res.PullStatuses, res.Jobs, fromType, toType)
+	if res.SkippedJobs > 0 {
+		fmt.Fprintf(out, "Skipped %d jobs since only %s keeps the history of jobs.\n", res.SkippedJobs, db.PostgresType)
+	}
+	return nil
+}
+
+// newDatabase connects to the database of dbType.
+func (d *DBCmd) newDatabase(userConfig server.UserConfig, dbType string, tenant string) (db.Database, error) {
+	userConfig.LockingDBType = dbType
+	database, err := server.NewDatabase(userConfig, tenant)
+	return database, errors.Wrapf(err, "connecting to %s", dbType)
+}
+
+// closeDatabase closes database if it holds connections or files open.
+func closeDatabase(database db.Database) {
+	if closer, ok := database.(io.Closer); ok {
+		closer.Close() // nolint: errcheck
+	}
+}
+
+func isValidDBType(dbType string) bool {
+	for _, t := range dbTypes {
+		if t == dbType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/runatlantis/atlantis/server/events/db"
+	"github.com/runatlantis/atlantis/server/events/models"
+	. "github.com/runatlantis/atlantis/testing"
+	"github.com/spf13/viper"
+)
+
+func setupDBMigrate(flags map[string]interface{}) (*DBCmd, *bytes.Buffer) {
+	vipr := viper.New()
+	for k, v := range flags {
+		vipr.Set(k, v)
+	}
+	out := new(bytes.Buffer)
+	return &DBCmd{Viper: vipr, Out: out}, out
+}
+
+func TestDBMigrate(t *testing.T) {
+	dataDir, cleanup := TempDir(t)
+	defer cleanup()
+	boltDB, err := db.New(dataDir)
+	Ok(t, err)
+	_, _, err = boltDB.TryLock(models.ProjectLock{
+		Project:   models.NewProject("owner/repo", "."),
+		Workspace: "default",
+		Pull:      models.PullRequest{Num: 1},
+	})
+	Ok(t, err)
+	// BoltDB can only be opened by one process at a time.
+	Ok(t, boltDB.Close())
+	s, err := miniredis.Run()
+	Ok(t, err)
+	defer s.Close()
+
+	c, out := setupDBMigrate(map[string]interface{}{
+		DBMigrateFromFlag: db.BoltDBType,
+		DBMigrateToFlag:   db.RedisType,
+		DataDirFlag:       dataDir,
+		RedisAddrsFlag:    s.Addr(),
+	})
+	Ok(t, c.Init().Commands()[0].RunE(nil, nil))
+	Equals(t, "Migrated 1 locks, 0 command locks, 0 pull request statuses and 0 jobs from boltdb to redis.\n", out.String())
+	Assert(t, s.Exists(db.DefaultKeyPrefix+"lock:owner/repo/./default"), "exp lock in redis, got keys %v", s.Keys())
+}
+
+func TestDBMigrate_Invalid(t *testing.T) {
+	cases := []struct {
+		flags  map[string]interface{}
+		expErr string
+	}{
+		{
+			map[string]interface{}{DBMigrateToFlag: db.PostgresType},
+			"--from must be one of boltdb, redis, dynamodb, postgres",
+		},
+		{
+			map[string]interface{}{DBMigrateFromFlag: db.BoltDBType, DBMigrateToFlag: "mysql"},
+			"--to must be one of boltdb, redis, dynamodb, postgres",
+		},
+		{
+			map[string]interface{}{DBMigrateFromFlag: db.RedisType, DBMigrateToFlag: db.RedisType},
+			"--from and --to must be different",
+		},
+		{
+			map[string]interface{}{DBMigrateFromFlag: db.BoltDBType, DBMigrateToFlag: db.RedisType, DBTenantFlag: "Team A"},
+			`invalid tenant name "Team A": must only contain lowercase letters, numbers and dashes`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.expErr, func(t *testing.T) {
+			cmd, _ := setupDBMigrate(c.flags)
+			ErrEquals(t, c.expErr, cmd.Init().Commands()[0].RunE(nil, nil))
+		})
+	}
+}
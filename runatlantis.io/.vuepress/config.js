@@ -72,14 +72,20 @@ module.exports = {
                         'apply-requirements',
                         'checkout-strategy',
                         'terraform-versions',
-                        'terraform-cloud'
+                        'terraform-cloud',
+                        'tracing',
+                        'notifications',
+                        'customizing-comments',
+                        'drift-detection',
+                        'multi-tenancy'
                     ]
                 },
                 {
                     title: 'Using Atlantis',
                     collapsable: true,
                     children: [
-                        ['using-atlantis', 'Overview']
+                        ['using-atlantis', 'Overview'],
+                        'api'
                     ]
                 },
                 {
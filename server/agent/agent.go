@@ -0,0 +1,111 @@
+// Package agent runs project commands on remote execution agents. The
+// Atlantis server acting as the control plane handles webhooks, comments and
+// locking while agents, which are Atlantis servers started with
+// --agent-port, clone pull requests and run the projects' workflows. This
+// lets the terraform runs be scaled separately from the server and keeps
+// the cloud credentials they need on the agents only.
+//
+// Agents are called over gRPC. The messages are JSON encoded so the service
+// doesn't need generated code.
+package agent
+
+import (
+	"encoding/json"
+
+	version "github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"google.golang.org/grpc/encoding"
+)
+
+const (
+	// serviceName is the name of the agent's gRPC service.
+	serviceName = "atlantis.agent.Agent"
+	// runMethod runs a project command.
+	runMethod = "/" + serviceName + "/RunProjectCommand"
+	// codecName is the content subtype of the agent's messages.
+	codecName = "json"
+	// tokenHeader is the metadata key of the token shared by the server and
+	// its agents.
+	tokenHeader = "authorization"
+)
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// jsonCodec encodes gRPC messages as JSON.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return codecName
+}
+
+// runRequest asks an agent to run a project command.
+type runRequest struct {
+	Command models.CommandName
+	// Context is the project's context without its logger and tracing span,
+	// which the agent replaces with its own.
+	Context models.ProjectCommandContext
+	// TerraformVersion is the project's terraform version, if it's set.
+	TerraformVersion string
+}
+
+// runResponse is the result of a project command run by an agent.
+type runResponse struct {
+	Result models.ProjectResult
+	// Error is the message of the result's error, if it has one.
+	Error string
+}
+
+func newRunRequest(cmd models.CommandName, ctx models.ProjectCommandContext) *runRequest {
+	req := &runRequest{Command: cmd}
+	if ctx.TerraformVersion != nil {
+		req.TerraformVersion = ctx.TerraformVersion.String()
+	}
+	ctx.Log = nil
+	ctx.Span = nil
+	ctx.TerraformVersion = nil
+	req.Context = ctx
+	return req
+}
+
+// projectContext returns the context of the command to run.
+func (r *runRequest) projectContext() (models.ProjectCommandContext, error) {
+	ctx := r.Context
+	if r.TerraformVersion != "" {
+		v, err := version.NewVersion(r.TerraformVersion)
+		if err != nil {
+			return ctx, err
+		}
+		ctx.TerraformVersion = v
+	}
+	return ctx, nil
+}
+
+func newRunResponse(result models.ProjectResult) *runResponse {
+	resp := &runResponse{}
+	if result.Error != nil {
+		resp.Error = result.Error.Error()
+	}
+	result.Error = nil
+	resp.Result = result
+	return resp
+}
+
+// projectResult returns the result of the command.
+func (r *runResponse) projectResult() models.ProjectResult {
+	result := r.Result
+	if r.Error != "" {
+		result.Error = errors.New(r.Error)
+	}
+	return result
+}
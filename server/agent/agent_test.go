@@ -0,0 +1,162 @@
+package agent_test
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	version "github.com/hashicorp/go-version"
+	"github.com/runatlantis/atlantis/server/agent"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/webhooks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestProjectCommandRunner_Plan(t *testing.T) {
+	runner := &fakeRunner{result: models.ProjectResult{
+		Command:     models.PlanCommand,
+		RepoRelDir:  "dir",
+		Workspace:   "default",
+		PlanSuccess: &models.PlanSuccess{TerraformOutput: "Plan: 1 to add"},
+	}}
+	locker := &fakeLocker{acquire: true}
+	p := newProjectCommandRunner(t, runner, locker, "token", "token")
+
+	ctx := projectContext(t)
+	ctx.TerraformVersion, _ = version.NewVersion("0.14.7")
+	result := p.Plan(ctx)
+	Ok(t, result.Error)
+	Equals(t, "Plan: 1 to add", result.PlanSuccess.TerraformOutput)
+	// The lock is the control plane's.
+	Equals(t, "lock-url/lock-key", result.PlanSuccess.LockURL)
+	Equals(t, false, locker.unlocked)
+
+	// The agent gets the context with its own logger.
+	Equals(t, models.PlanCommand, runner.cmd)
+	Equals(t, "owner/repo", runner.ctx.Pull.BaseRepo.FullName)
+	Equals(t, "dir", runner.ctx.RepoRelDir)
+	Equals(t, "0.14.7", runner.ctx.TerraformVersion.String())
+	Assert(t, runner.ctx.Log != nil, "expected logger")
+}
+
+func TestProjectCommandRunner_PlanError(t *testing.T) {
+	runner := &fakeRunner{result: models.ProjectResult{
+		Command: models.PlanCommand,
+		Error:   errors.New("terraform init failed"),
+	}}
+	locker := &fakeLocker{acquire: true}
+	p := newProjectCommandRunner(t, runner, locker, "token", "token")
+
+	result := p.Plan(projectContext(t))
+	ErrEquals(t, "terraform init failed", result.Error)
+	Equals(t, true, locker.unlocked)
+}
+
+func TestProjectCommandRunner_PlanLocked(t *testing.T) {
+	runner := &fakeRunner{}
+	locker := &fakeLocker{acquire: false}
+	p := newProjectCommandRunner(t, runner, locker, "token", "token")
+
+	result := p.Plan(projectContext(t))
+	Ok(t, result.Error)
+	Equals(t, "locked by pull #2", result.Failure)
+	Equals(t, models.CommandName(0), runner.cmd)
+}
+
+func TestProjectCommandRunner_InvalidToken(t *testing.T) {
+	runner := &fakeRunner{}
+	p := newProjectCommandRunner(t, runner, &fakeLocker{acquire: true}, "token", "other")
+
+	result := p.Apply(projectContext(t))
+	Assert(t, result.Error != nil, "expected error")
+	Assert(t, strings.Contains(result.Error.Error(), "invalid agent token"), "unexpected error %q", result.Error)
+	Equals(t, models.CommandName(0), runner.cmd)
+}
+
+func newProjectCommandRunner(t *testing.T, runner events.ProjectCommandRunner, locker events.ProjectLocker, serverToken string, clientToken string) *agent.ProjectCommandRunner {
+	t.Helper()
+	server, err := agent.NewServer(runner, logging.NewNoopLogger(t), serverToken, "", "")
+	Ok(t, err)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	Ok(t, err)
+	go server.Serve(lis) // nolint: errcheck
+	t.Cleanup(server.Stop)
+
+	client, err := agent.Dial([]string{lis.Addr().String()}, clientToken, true)
+	Ok(t, err)
+	t.Cleanup(func() { client.Close() }) // nolint: errcheck
+	return &agent.ProjectCommandRunner{
+		Client:           client,
+		Locker:           locker,
+		LockURLGenerator: fakeLockURLGenerator{},
+		Webhooks:         &webhooks.MultiWebhookSender{},
+	}
+}
+
+func projectContext(t *testing.T) models.ProjectCommandContext {
+	repo := models.Repo{FullName: "owner/repo", Owner: "owner", Name: "repo"}
+	return models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(t),
+		BaseRepo:   repo,
+		HeadRepo:   repo,
+		Pull:       models.PullRequest{Num: 1, BaseRepo: repo},
+		RepoRelDir: "dir",
+		Workspace:  "default",
+	}
+}
+
+type fakeRunner struct {
+	result models.ProjectResult
+	cmd    models.CommandName
+	ctx    models.ProjectCommandContext
+}
+
+func (f *fakeRunner) run(cmd models.CommandName, ctx models.ProjectCommandContext) models.ProjectResult {
+	f.cmd = cmd
+	f.ctx = ctx
+	return f.result
+}
+
+func (f *fakeRunner) Plan(ctx models.ProjectCommandContext) models.ProjectResult {
+	return f.run(models.PlanCommand, ctx)
+}
+
+func (f *fakeRunner) PolicyCheck(ctx models.ProjectCommandContext) models.ProjectResult {
+	return f.run(models.PolicyCheckCommand, ctx)
+}
+
+func (f *fakeRunner) Apply(ctx models.ProjectCommandContext) models.ProjectResult {
+	return f.run(models.ApplyCommand, ctx)
+}
+
+func (f *fakeRunner) ApprovePolicies(ctx models.ProjectCommandContext) models.ProjectResult {
+	return f.run(models.ApprovePoliciesCommand, ctx)
+}
+
+type fakeLocker struct {
+	acquire  bool
+	unlocked bool
+}
+
+func (f *fakeLocker) TryLock(log logging.SimpleLogging, pull models.PullRequest, user models.User, workspace string, project models.Project) (*events.TryLockResponse, error) {
+	if !f.acquire {
+		return &events.TryLockResponse{LockFailureReason: "locked by pull #2"}, nil
+	}
+	return &events.TryLockResponse{
+		LockAcquired: true,
+		LockKey:      "lock-key",
+		UnlockFn: func() error {
+			f.unlocked = true
+			return nil
+		},
+	}, nil
+}
+
+type fakeLockURLGenerator struct{}
+
+func (fakeLockURLGenerator) GenerateLockURL(lockID string) string {
+	return "lock-url/" + lockID
+}
@@ -0,0 +1,212 @@
+package agent
+
+import (
+	"context"
+	"crypto/tls"
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/webhooks"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Client sends project commands to agents.
+type Client struct {
+	conns []*grpc.ClientConn
+}
+
+// Dial returns a client of the agents at addrs, authenticated with token.
+// Agents are called over TLS unless insecure is true. Connections are made
+// in the background so agents don't need to be up yet.
+func Dial(addrs []string, token string, insecure bool) (*Client, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("no agent addresses")
+	}
+	opts := []grpc.DialOption{
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(codecName)),
+		grpc.WithPerRPCCredentials(tokenCredentials{token: token, secure: !insecure}),
+	}
+	if insecure {
+		opts = append(opts, grpc.WithInsecure())
+	} else {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})))
+	}
+	c := &Client{}
+	for _, addr := range addrs {
+		conn, err := grpc.Dial(addr, opts...)
+		if err != nil {
+			c.Close() // nolint: errcheck
+			return nil, errors.Wrapf(err, "connecting to agent %s", addr)
+		}
+		c.conns = append(c.conns, conn)
+	}
+	return c, nil
+}
+
+// Run runs cmd for the project described by ctx on an agent. The commands of
+// a pull request are always sent to the same agent so its plans can be
+// applied without a plan store.
+func (c *Client) Run(cmd models.CommandName, ctx models.ProjectCommandContext) (models.ProjectResult, error) {
+	conn := c.conns[c.agentIndex(ctx.Pull)]
+	resp := &runResponse{}
+	// Commands can wait for locks and take hours so the call doesn't time
+	// out, but waits for the agent to be up.
+	err := conn.Invoke(context.Background(), runMethod, newRunRequest(cmd, ctx), resp, grpc.WaitForReady(true))
+	if err != nil {
+		return models.ProjectResult{}, errors.Wrapf(err, "running %s on agent %s", cmd, conn.Target())
+	}
+	return resp.projectResult(), nil
+}
+
+// Close closes the connections to the agents.
+func (c *Client) Close() error {
+	var closeErr error
+	for _, conn := range c.conns {
+		if err := conn.Close(); err != nil {
+			closeErr = err
+		}
+	}
+	return closeErr
+}
+
+func (c *Client) agentIndex(pull models.PullRequest) int {
+	h := fnv.New32a()
+	h.Write([]byte(pull.BaseRepo.FullName + "#" + strconv.Itoa(pull.Num))) // nolint: errcheck
+	return int(h.Sum32() % uint32(len(c.conns)))
+}
+
+// tokenCredentials adds the agent token to each call.
+type tokenCredentials struct {
+	token  string
+	secure bool
+}
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{tokenHeader: t.token}, nil
+}
+
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return t.secure
+}
+
+// ProjectCommandRunner runs project commands on agents. Projects are locked
+// before their commands are sent, and webhooks are sent once they complete,
+// so agents don't need access to the locking DB.
+type ProjectCommandRunner struct {
+	Client           *Client
+	Locker           events.ProjectLocker
+	LockURLGenerator events.LockURLGenerator
+	Webhooks         events.WebhooksSender
+	// ConcurrencyLimiter limits how many commands are sent to agents at the
+	// same time for each repo and project. If nil, they aren't limited.
+	ConcurrencyLimiter *events.ConcurrencyLimiter
+}
+
+// Plan runs terraform plan for the project described by ctx on an agent.
+func (p *ProjectCommandRunner) Plan(ctx models.ProjectCommandContext) (result models.ProjectResult) {
+	release := p.ConcurrencyLimiter.Acquire(ctx.Log, ctx.Pull.BaseRepo, ctx.RepoRelDir)
+	defer release()
+	start := time.Now()
+	p.Webhooks.Send(ctx.Log, webhooks.NewStartedResult(ctx, models.PlanCommand)) // nolint: errcheck
+	defer p.sendFinished(ctx, &result)
+	lockAttempt, result, ok := p.lock(ctx, models.PlanCommand, start)
+	if !ok {
+		return result
+	}
+	result = p.run(models.PlanCommand, ctx, start)
+	if result.Error != nil || result.Failure != "" {
+		if unlockErr := lockAttempt.UnlockFn(); unlockErr != nil {
+			ctx.Log.Err("error unlocking state after plan error: %v", unlockErr)
+		}
+	}
+	if result.PlanSuccess != nil {
+		result.PlanSuccess.LockURL = p.LockURLGenerator.GenerateLockURL(lockAttempt.LockKey)
+	}
+	return result
+}
+
+// PolicyCheck evaluates the policies of the project described by ctx on an
+// agent.
+func (p *ProjectCommandRunner) PolicyCheck(ctx models.ProjectCommandContext) (result models.ProjectResult) {
+	release := p.ConcurrencyLimiter.Acquire(ctx.Log, ctx.Pull.BaseRepo, ctx.RepoRelDir)
+	defer release()
+	start := time.Now()
+	defer p.sendFinished(ctx, &result)
+	lockAttempt, result, ok := p.lock(ctx, models.PolicyCheckCommand, start)
+	if !ok {
+		return result
+	}
+	// A failing policy check doesn't unlock the project since it requires
+	// approval.
+	result = p.run(models.PolicyCheckCommand, ctx, start)
+	if result.PolicyCheckSuccess != nil {
+		result.PolicyCheckSuccess.LockURL = p.LockURLGenerator.GenerateLockURL(lockAttempt.LockKey)
+	}
+	return result
+}
+
+// Apply runs terraform apply for the project described by ctx on an agent.
+func (p *ProjectCommandRunner) Apply(ctx models.ProjectCommandContext) (result models.ProjectResult) {
+	release := p.ConcurrencyLimiter.Acquire(ctx.Log, ctx.Pull.BaseRepo, ctx.RepoRelDir)
+	defer release()
+	p.Webhooks.Send(ctx.Log, webhooks.NewStartedResult(ctx, models.ApplyCommand)) // nolint: errcheck
+	defer p.sendFinished(ctx, &result)
+	return p.run(models.ApplyCommand, ctx, time.Now())
+}
+
+// ApprovePolicies approves the failing policies of the project described by
+// ctx.
+func (p *ProjectCommandRunner) ApprovePolicies(ctx models.ProjectCommandContext) models.ProjectResult {
+	return p.run(models.ApprovePoliciesCommand, ctx, time.Now())
+}
+
+// lock locks the project described by ctx. If it isn't locked, ok is false
+// and result is the result of cmd.
+func (p *ProjectCommandRunner) lock(ctx models.ProjectCommandContext, cmd models.CommandName, start time.Time) (lockAttempt *events.TryLockResponse, result models.ProjectResult, ok bool) {
+	lockAttempt, err := p.Locker.TryLock(ctx.Log, ctx.Pull, ctx.User, ctx.Workspace, models.NewProject(ctx.Pull.BaseRepo.FullName, ctx.RepoRelDir))
+	if err != nil {
+		return nil, failedResult(cmd, ctx, start, errors.Wrap(err, "acquiring lock"), ""), false
+	}
+	if !lockAttempt.LockAcquired {
+		return nil, failedResult(cmd, ctx, start, nil, lockAttempt.LockFailureReason), false
+	}
+	ctx.Log.Debug("acquired lock for project")
+	return lockAttempt, models.ProjectResult{}, true
+}
+
+// sendFinished sends the webhooks for result finishing.
+func (p *ProjectCommandRunner) sendFinished(ctx models.ProjectCommandContext, result *models.ProjectResult) {
+	p.Webhooks.Send(ctx.Log, webhooks.NewApplyResult(ctx, result.Command, result.Failure, result.Error)) // nolint: errcheck
+}
+
+// run runs cmd on an agent. If the agent can't be called, the result has
+// the error.
+func (p *ProjectCommandRunner) run(cmd models.CommandName, ctx models.ProjectCommandContext, start time.Time) models.ProjectResult {
+	result, err := p.Client.Run(cmd, ctx)
+	if err != nil {
+		return failedResult(cmd, ctx, start, err, "")
+	}
+	return result
+}
+
+func failedResult(cmd models.CommandName, ctx models.ProjectCommandContext, start time.Time, err error, failure string) models.ProjectResult {
+	if cmd == models.ApprovePoliciesCommand {
+		cmd = models.PolicyCheckCommand
+	}
+	return models.ProjectResult{
+		Command:     cmd,
+		Error:       err,
+		Failure:     failure,
+		RepoRelDir:  ctx.RepoRelDir,
+		Workspace:   ctx.Workspace,
+		ProjectName: ctx.ProjectName,
+		User:        ctx.User.Username,
+		StartedAt:   start,
+		Duration:    time.Since(start),
+	}
+}
@@ -0,0 +1,154 @@
+package agent
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Server runs the project commands sent by the control plane.
+type Server struct {
+	// Runner runs the commands. It shouldn't lock projects since they're
+	// locked by the control plane, see UnlockedProjectLocker.
+	Runner events.ProjectCommandRunner
+	Logger logging.SimpleLogging
+
+	token []byte
+	grpc  *grpc.Server
+}
+
+// NewServer returns an agent server running commands with runner for the
+// control planes that know token. If certFile and keyFile are set, it's
+// served over TLS.
+func NewServer(runner events.ProjectCommandRunner, logger logging.SimpleLogging, token string, certFile string, keyFile string) (*Server, error) {
+	s := &Server{
+		Runner: runner,
+		Logger: logger,
+		token:  []byte(token),
+	}
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(s.authenticate)}
+	if certFile != "" && keyFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(certFile, keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading TLS certificate")
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	s.grpc = grpc.NewServer(opts...)
+	s.grpc.RegisterService(&serviceDesc, s)
+	return s, nil
+}
+
+// ListenAndServe serves commands on port until Stop is called.
+func (s *Server) ListenAndServe(port int) error {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	return s.Serve(lis)
+}
+
+// Serve serves commands on lis until Stop is called.
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpc.Serve(lis)
+}
+
+// Stop stops serving once the commands running have completed.
+func (s *Server) Stop() {
+	s.grpc.GracefulStop()
+}
+
+// run runs the command in req.
+func (s *Server) run(req *runRequest) (*runResponse, error) {
+	ctx, err := req.projectContext()
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "parsing terraform version: %s", err)
+	}
+	ctx.Log = s.Logger.WithHistory(
+		"repo", ctx.Pull.BaseRepo.FullName,
+		"pull", strconv.Itoa(ctx.Pull.Num),
+		logging.CorrelationIDKey, ctx.CorrelationID,
+	)
+	ctx.Log.Info("running %s for dir %q workspace %q", req.Command, ctx.RepoRelDir, ctx.Workspace)
+
+	var result models.ProjectResult
+	switch req.Command {
+	case models.PlanCommand:
+		result = s.Runner.Plan(ctx)
+	case models.PolicyCheckCommand:
+		result = s.Runner.PolicyCheck(ctx)
+	case models.ApplyCommand:
+		result = s.Runner.Apply(ctx)
+	case models.ApprovePoliciesCommand:
+		result = s.Runner.ApprovePolicies(ctx)
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "agents can't run %s", req.Command)
+	}
+	return newRunResponse(result), nil
+}
+
+// authenticate rejects the calls that don't have the server's token.
+func (s *Server) authenticate(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if tokens := md.Get(tokenHeader); len(tokens) != 1 || subtle.ConstantTimeCompare([]byte(tokens[0]), s.token) != 1 {
+		return nil, status.Error(codes.Unauthenticated, "invalid agent token")
+	}
+	return handler(ctx, req)
+}
+
+// agentService is implemented by Server. It's the handler type of the gRPC
+// service.
+type agentService interface {
+	run(req *runRequest) (*runResponse, error)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*agentService)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RunProjectCommand",
+			Handler:    runHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+func runHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := &runRequest{}
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(agentService).run(req)
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(agentService).run(req.(*runRequest))
+	}
+	return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: runMethod}, handler)
+}
+
+// UnlockedProjectLocker is the ProjectLocker of agents. It always acquires
+// the lock since projects are locked by the control plane before the
+// commands are sent to agents.
+type UnlockedProjectLocker struct{}
+
+// TryLock implements events.ProjectLocker.
+func (UnlockedProjectLocker) TryLock(log logging.SimpleLogging, pull models.PullRequest, user models.User, workspace string, project models.Project) (*events.TryLockResponse, error) {
+	return &events.TryLockResponse{
+		LockAcquired: true,
+		UnlockFn:     func() error { return nil },
+	}, nil
+}
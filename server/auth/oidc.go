@@ -0,0 +1,550 @@
+// Package auth authenticates users of the web UI through an OpenID Connect
+// provider, ex. Okta or Google, and authorizes them based on their groups.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+const (
+	// LoginPath starts the login flow. CallbackPath is where the provider
+	// redirects back to and must be allowed as a redirect URI for the client.
+	LoginPath    = "/auth/login"
+	CallbackPath = "/auth/callback"
+	LogoutPath   = "/auth/logout"
+
+	// DefaultGroupsClaim is the ID token claim that holds the user's groups.
+	DefaultGroupsClaim = "groups"
+	// DefaultSessionTTL is how long users stay logged in.
+	DefaultSessionTTL = 12 * time.Hour
+
+	sessionCookie = "atlantis_session"
+	stateCookie   = "atlantis_oidc_state"
+	stateTTL      = 10 * time.Minute
+
+	// The types of the tokens signed with the session key. They're in their
+	// typ claim so that a state cookie can't be used as a session.
+	stateType   = "state"
+	sessionType = "session"
+)
+
+// Config configures OIDC login.
+type Config struct {
+	// IssuerURL is the OIDC provider, ex. https://example.okta.com. Its
+	// discovery document must be at /.well-known/openid-configuration.
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	// AtlantisURL is the externally accessible URL of Atlantis. The callback
+	// is served under it.
+	AtlantisURL *url.URL
+	// GroupsClaim is the ID token claim that holds the user's groups.
+	GroupsClaim string
+	// AllowedGroups are the groups allowed to use the web UI. If empty, any
+	// user that can log in is allowed.
+	AllowedGroups []string
+	// AdminGroups are the groups allowed to make admin requests, ex. discard
+	// locks. If empty, any allowed user can make them.
+	AdminGroups []string
+	// Public returns true if r doesn't require logging in, ex. webhooks.
+	Public func(r *http.Request) bool
+	// Admin returns true if r requires being in AdminGroups.
+	Admin func(r *http.Request) bool
+}
+
+// User is a logged in user.
+type User struct {
+	Subject string   `json:"sub"`
+	Name    string   `json:"name"`
+	Groups  []string `json:"groups"`
+}
+
+type userContextKey struct{}
+
+// WithUser returns a copy of ctx with user as the user making the request.
+func WithUser(ctx context.Context, user User) context.Context {
+	return context.WithValue(ctx, userContextKey{}, user)
+}
+
+// UserFromContext returns the user that made the request with ctx, if any.
+func UserFromContext(ctx context.Context) (User, bool) {
+	u, ok := ctx.Value(userContextKey{}).(User)
+	return u, ok
+}
+
+// OIDC authenticates web UI requests using OpenID Connect.
+type OIDC struct {
+	cfg        Config
+	logger     logging.SimpleLogging
+	client     *http.Client
+	sessionKey []byte
+	now        func() time.Time
+
+	issuer        string
+	authEndpoint  string
+	tokenEndpoint string
+	jwksURI       string
+
+	keysMu sync.Mutex
+	keys   map[string]*rsa.PublicKey
+}
+
+// NewOIDC returns an authenticator configured from the provider's discovery
+// document.
+func NewOIDC(cfg Config, logger logging.SimpleLogging, client *http.Client) (*OIDC, error) {
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = DefaultGroupsClaim
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	// Sessions are signed with a key derived from the client secret so they
+	// survive restarts and are valid across replicas.
+	sum := sha256.Sum256([]byte("atlantis-web-session:" + cfg.ClientSecret))
+	o := &OIDC{
+		cfg:        cfg,
+		logger:     logger,
+		client:     client,
+		sessionKey: sum[:],
+		now:        time.Now,
+		keys:       make(map[string]*rsa.PublicKey),
+	}
+
+	var discovery struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	discoveryURL := strings.TrimSuffix(cfg.IssuerURL, "/") + "/.well-known/openid-configuration"
+	if err := o.getJSON(discoveryURL, &discovery); err != nil {
+		return nil, errors.Wrap(err, "fetching OIDC discovery document")
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("OIDC discovery document at %s is missing authorization_endpoint, token_endpoint or jwks_uri", discoveryURL)
+	}
+	o.issuer = discovery.Issuer
+	o.authEndpoint = discovery.AuthorizationEndpoint
+	o.tokenEndpoint = discovery.TokenEndpoint
+	o.jwksURI = discovery.JWKSURI
+	return o, nil
+}
+
+// ServeHTTP is a negroni middleware that requires requests to be from a
+// logged in user unless they're public.
+func (o *OIDC) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if o.isAuthPath(r.URL.Path) || (o.cfg.Public != nil && o.cfg.Public(r)) {
+		next(w, r)
+		return
+	}
+	user, err := o.session(r)
+	if err != nil {
+		if r.Method == http.MethodGet {
+			http.Redirect(w, r, o.loginURL(r.URL.RequestURI()), http.StatusFound)
+			return
+		}
+		http.Error(w, "Not logged in", http.StatusUnauthorized)
+		return
+	}
+	if !inGroups(user.Groups, o.cfg.AllowedGroups) {
+		o.logger.Info("denying %s %s for %q: not in an allowed group", r.Method, r.URL.Path, user.Name)
+		http.Error(w, fmt.Sprintf("User %q isn't in any of the groups allowed to use Atlantis", user.Name), http.StatusForbidden)
+		return
+	}
+	if o.cfg.Admin != nil && o.cfg.Admin(r) && !inGroups(user.Groups, o.cfg.AdminGroups) {
+		o.logger.Info("denying %s %s for %q: not in an admin group", r.Method, r.URL.Path, user.Name)
+		http.Error(w, fmt.Sprintf("User %q isn't in any of the groups allowed to do this", user.Name), http.StatusForbidden)
+		return
+	}
+	next(w, r.WithContext(WithUser(r.Context(), user)))
+}
+
+// Login is the GET /auth/login route. It redirects to the provider. The
+// redirect query parameter is where to return to after logging in.
+func (o *OIDC) Login(w http.ResponseWriter, r *http.Request) {
+	redirect := r.URL.Query().Get("redirect")
+	if !isLocalPath(redirect) {
+		redirect = "/"
+	}
+	state, err := randomString()
+	if err != nil {
+		o.respond(w, http.StatusInternalServerError, "Failed generating state: %s", err)
+		return
+	}
+	nonce, err := randomString()
+	if err != nil {
+		o.respond(w, http.StatusInternalServerError, "Failed generating nonce: %s", err)
+		return
+	}
+	signed, err := o.sign(stateType, jwt.MapClaims{
+		"state":    state,
+		"nonce":    nonce,
+		"redirect": redirect,
+		"exp":      o.now().Add(stateTTL).Unix(),
+	})
+	if err != nil {
+		o.respond(w, http.StatusInternalServerError, "Failed signing state: %s", err)
+		return
+	}
+	o.setCookie(w, stateCookie, signed, stateTTL)
+
+	params := url.Values{
+		"response_type": {"code"},
+		"client_id":     {o.cfg.ClientID},
+		"redirect_uri":  {o.redirectURI()},
+		"scope":         {"openid profile email"},
+		"state":         {state},
+		"nonce":         {nonce},
+	}
+	sep := "?"
+	if strings.Contains(o.authEndpoint, "?") {
+		sep = "&"
+	}
+	http.Redirect(w, r, o.authEndpoint+sep+params.Encode(), http.StatusFound)
+}
+
+// Callback is the GET /auth/callback route. It exchanges the authorization
+// code for an ID token and starts a session for its user.
+func (o *OIDC) Callback(w http.ResponseWriter, r *http.Request) {
+	if errParam := r.URL.Query().Get("error"); errParam != "" {
+		o.respond(w, http.StatusUnauthorized, "Login failed: %s %s", errParam, r.URL.Query().Get("error_description"))
+		return
+	}
+	cookie, err := r.Cookie(stateCookie)
+	if err != nil {
+		o.respond(w, http.StatusBadRequest, "Login failed: missing state cookie")
+		return
+	}
+	stateClaims, err := o.verify(cookie.Value, stateType)
+	if err != nil {
+		o.respond(w, http.StatusBadRequest, "Login failed: invalid state cookie: %s", err)
+		return
+	}
+	if state, _ := stateClaims["state"].(string); state == "" || state != r.URL.Query().Get("state") {
+		o.respond(w, http.StatusBadRequest, "Login failed: state doesn't match")
+		return
+	}
+	o.setCookie(w, stateCookie, "", -1)
+
+	idToken, err := o.exchange(r.URL.Query().Get("code"))
+	if err != nil {
+		o.respond(w, http.StatusUnauthorized, "Login failed: %s", err)
+		return
+	}
+	nonce, _ := stateClaims["nonce"].(string)
+	user, err := o.verifyIDToken(idToken, nonce)
+	if err != nil {
+		o.respond(w, http.StatusUnauthorized, "Login failed: %s", err)
+		return
+	}
+	if user.Subject == "" {
+		o.respond(w, http.StatusUnauthorized, "Login failed: ID token has no subject")
+		return
+	}
+	session, err := o.sign(sessionType, jwt.MapClaims{
+		"sub":    user.Subject,
+		"name":   user.Name,
+		"groups": user.Groups,
+		"exp":    o.now().Add(DefaultSessionTTL).Unix(),
+	})
+	if err != nil {
+		o.respond(w, http.StatusInternalServerError, "Failed signing session: %s", err)
+		return
+	}
+	o.setCookie(w, sessionCookie, session, DefaultSessionTTL)
+	o.logger.Info("%q logged in", user.Name)
+
+	redirect, _ := stateClaims["redirect"].(string)
+	if !isLocalPath(redirect) {
+		redirect = "/"
+	}
+	http.Redirect(w, r, o.cfg.AtlantisURL.Path+redirect, http.StatusFound)
+}
+
+// Logout is the GET /auth/logout route. It ends the session.
+func (o *OIDC) Logout(w http.ResponseWriter, r *http.Request) {
+	o.setCookie(w, sessionCookie, "", -1)
+	http.Redirect(w, r, o.cfg.AtlantisURL.Path+"/", http.StatusFound)
+}
+
+// session returns the user of r's session.
+func (o *OIDC) session(r *http.Request) (User, error) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return User{}, err
+	}
+	claims, err := o.verify(cookie.Value, sessionType)
+	if err != nil {
+		return User{}, err
+	}
+	user := o.userFromClaims(claims, "groups")
+	if user.Subject == "" {
+		return User{}, errors.New("session has no subject")
+	}
+	return user, nil
+}
+
+// exchange exchanges code for an ID token at the token endpoint.
+func (o *OIDC) exchange(code string) (string, error) {
+	if code == "" {
+		return "", errors.New("missing code")
+	}
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {o.redirectURI()},
+	}
+	req, err := http.NewRequest("POST", o.tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(o.cfg.ClientID), url.QueryEscape(o.cfg.ClientSecret))
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "exchanging code")
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.Wrap(err, "reading token response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, body)
+	}
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", errors.Wrap(err, "parsing token response")
+	}
+	if token.IDToken == "" {
+		return "", errors.New("token response has no id_token")
+	}
+	return token.IDToken, nil
+}
+
+// verifyIDToken verifies the ID token's signature and claims and returns its
+// user.
+func (o *OIDC) verifyIDToken(idToken string, nonce string) (User, error) {
+	parsed, err := jwt.Parse(idToken, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unsupported signing method %v", t.Header["alg"])
+		}
+		kid, _ := t.Header["kid"].(string)
+		return o.key(kid)
+	})
+	if err != nil {
+		return User{}, errors.Wrap(err, "verifying ID token")
+	}
+	claims := parsed.Claims.(jwt.MapClaims)
+	if o.issuer != "" && !claims.VerifyIssuer(o.issuer, true) {
+		return User{}, errors.New("ID token has the wrong issuer")
+	}
+	if !verifyAudience(claims["aud"], o.cfg.ClientID) {
+		return User{}, errors.New("ID token has the wrong audience")
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return User{}, errors.New("ID token has the wrong nonce")
+	}
+	return o.userFromClaims(claims, o.cfg.GroupsClaim), nil
+}
+
+func (o *OIDC) userFromClaims(claims jwt.MapClaims, groupsClaim string) User {
+	user := User{}
+	user.Subject, _ = claims["sub"].(string)
+	for _, c := range []string{"name", "email", "preferred_username", "sub"} {
+		if v, ok := claims[c].(string); ok && v != "" {
+			user.Name = v
+			break
+		}
+	}
+	switch groups := claims[groupsClaim].(type) {
+	case string:
+		user.Groups = []string{groups}
+	case []interface{}:
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				user.Groups = append(user.Groups, s)
+			}
+		}
+	}
+	return user
+}
+
+// key returns the provider's public key with kid. The keys are fetched again
+// if kid is unknown since the provider may have rotated them.
+func (o *OIDC) key(kid string) (*rsa.PublicKey, error) {
+	o.keysMu.Lock()
+	defer o.keysMu.Unlock()
+	if k, ok := o.keys[kid]; ok {
+		return k, nil
+	}
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := o.getJSON(o.jwksURI, &jwks); err != nil {
+		return nil, errors.Wrap(err, "fetching JWKS")
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	o.keys = keys
+	if k, ok := keys[kid]; ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("no key with kid %q", kid)
+}
+
+// sign signs claims as a token of type typ.
+func (o *OIDC) sign(typ string, claims jwt.MapClaims) (string, error) {
+	claims["typ"] = typ
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(o.sessionKey)
+}
+
+// verify returns the claims of signed if it's a valid token of type typ.
+func (o *OIDC) verify(signed string, typ string) (jwt.MapClaims, error) {
+	parsed, err := jwt.Parse(signed, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		return o.sessionKey, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	claims := parsed.Claims.(jwt.MapClaims)
+	if t, _ := claims["typ"].(string); t != typ {
+		return nil, fmt.Errorf("token is a %q token, not a %q one", t, typ)
+	}
+	return claims, nil
+}
+
+func (o *OIDC) setCookie(w http.ResponseWriter, name string, value string, ttl time.Duration) {
+	path := o.cfg.AtlantisURL.Path
+	if path == "" {
+		path = "/"
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   o.cfg.AtlantisURL.Scheme == "https",
+		// Lax so the cookies are sent when the provider redirects back.
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (o *OIDC) getJSON(u string, v interface{}) error {
+	resp, err := o.client.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", u, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (o *OIDC) redirectURI() string {
+	return strings.TrimSuffix(o.cfg.AtlantisURL.String(), "/") + CallbackPath
+}
+
+func (o *OIDC) loginURL(redirect string) string {
+	return o.cfg.AtlantisURL.Path + LoginPath + "?" + url.Values{"redirect": {redirect}}.Encode()
+}
+
+func (o *OIDC) isAuthPath(path string) bool {
+	return path == LoginPath || path == CallbackPath || path == LogoutPath
+}
+
+func (o *OIDC) respond(w http.ResponseWriter, code int, format string, args ...interface{}) {
+	response := fmt.Sprintf(format, args...)
+	o.logger.Warn(response)
+	http.Error(w, response, code)
+}
+
+// inGroups returns true if any of groups is in allowed or allowed is empty.
+func inGroups(groups []string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, g := range groups {
+		for _, a := range allowed {
+			if g == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func verifyAudience(aud interface{}, clientID string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == clientID
+	case []interface{}:
+		for _, v := range a {
+			if s, ok := v.(string); ok && s == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isLocalPath returns true if p is a path on this server so that redirecting
+// to it after login can't send users to another site.
+func isLocalPath(p string) bool {
+	return strings.HasPrefix(p, "/") && !strings.HasPrefix(p, "//") && !strings.Contains(p, "\\")
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
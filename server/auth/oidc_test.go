@@ -0,0 +1,309 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/runatlantis/atlantis/server/auth"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// fakeProvider is an OIDC provider that issues ID tokens for claims.
+type fakeProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	claims jwt.MapClaims
+	// nonce is the nonce from the last authorization request.
+	nonce string
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Ok(t, err)
+	p := &fakeProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{ // nolint: errcheck
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"jwks_uri":               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{ // nolint: errcheck
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "key1",
+				"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "client-id" || pass != "client-secret" || r.FormValue("code") != "the-code" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		claims := jwt.MapClaims{
+			"iss":   p.server.URL,
+			"aud":   "client-id",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"nonce": p.nonce,
+		}
+		for k, v := range p.claims {
+			claims[k] = v
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "key1"
+		signed, err := token.SignedString(key)
+		Ok(t, err)
+		json.NewEncoder(w).Encode(map[string]string{"id_token": signed}) // nolint: errcheck
+	})
+	p.server = httptest.NewServer(mux)
+	return p
+}
+
+func newOIDC(t *testing.T, p *fakeProvider) *auth.OIDC {
+	atlantisURL, err := url.Parse("https://atlantis.example.com/basepath")
+	Ok(t, err)
+	o, err := auth.NewOIDC(auth.Config{
+		IssuerURL:    p.server.URL,
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		AtlantisURL:  atlantisURL,
+		AdminGroups:  []string{"admins"},
+		Public: func(r *http.Request) bool {
+			return r.URL.Path == "/events"
+		},
+		Admin: func(r *http.Request) bool {
+			return r.Method == http.MethodDelete
+		},
+	}, logging.NewNoopLogger(t), nil)
+	Ok(t, err)
+	return o
+}
+
+// login runs the login flow and returns the session cookie.
+func login(t *testing.T, o *auth.OIDC, p *fakeProvider) *http.Cookie {
+	w := httptest.NewRecorder()
+	o.Login(w, httptest.NewRequest("GET", "/auth/login?redirect=/pulls", nil))
+	Equals(t, http.StatusFound, w.Code)
+	authURL, err := url.Parse(w.Header().Get("Location"))
+	Ok(t, err)
+	Equals(t, "https://atlantis.example.com/basepath/auth/callback", authURL.Query().Get("redirect_uri"))
+	p.nonce = authURL.Query().Get("nonce")
+
+	req := httptest.NewRequest("GET", "/auth/callback?code=the-code&state="+authURL.Query().Get("state"), nil)
+	for _, c := range w.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	w = httptest.NewRecorder()
+	o.Callback(w, req)
+	Equals(t, http.StatusFound, w.Code)
+	Equals(t, "/basepath/pulls", w.Header().Get("Location"))
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "atlantis_session" && c.Value != "" {
+			return c
+		}
+	}
+	t.Fatal("no session cookie")
+	return nil
+}
+
+func serve(o *auth.OIDC, req *http.Request) (*httptest.ResponseRecorder, *auth.User) {
+	w := httptest.NewRecorder()
+	var user *auth.User
+	o.ServeHTTP(w, req, func(w http.ResponseWriter, r *http.Request) {
+		if u, ok := auth.UserFromContext(r.Context()); ok {
+			user = &u
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	return w, user
+}
+
+func TestOIDC_NotLoggedIn(t *testing.T) {
+	p := newFakeProvider(t)
+	defer p.server.Close()
+	o := newOIDC(t, p)
+
+	w, _ := serve(o, httptest.NewRequest("GET", "/pulls?repo=owner/repo", nil))
+	Equals(t, http.StatusFound, w.Code)
+	Equals(t, "/basepath/auth/login?redirect=%2Fpulls%3Frepo%3Downer%2Frepo", w.Header().Get("Location"))
+
+	w, _ = serve(o, httptest.NewRequest("DELETE", "/locks?id=1", nil))
+	Equals(t, http.StatusUnauthorized, w.Code)
+
+	w, _ = serve(o, httptest.NewRequest("POST", "/events", nil))
+	Equals(t, http.StatusOK, w.Code)
+}
+
+func TestOIDC_Login(t *testing.T) {
+	p := newFakeProvider(t)
+	defer p.server.Close()
+	p.claims = jwt.MapClaims{"sub": "123", "email": "user@example.com", "groups": []string{"devs"}}
+	o := newOIDC(t, p)
+	session := login(t, o, p)
+
+	req := httptest.NewRequest("GET", "/pulls", nil)
+	req.AddCookie(session)
+	w, user := serve(o, req)
+	Equals(t, http.StatusOK, w.Code)
+	Equals(t, &auth.User{Subject: "123", Name: "user@example.com", Groups: []string{"devs"}}, user)
+
+	// Users not in an admin group can't make admin requests.
+	req = httptest.NewRequest("DELETE", "/locks?id=1", nil)
+	req.AddCookie(session)
+	w, _ = serve(o, req)
+	Equals(t, http.StatusForbidden, w.Code)
+}
+
+func TestOIDC_LoginAdmin(t *testing.T) {
+	p := newFakeProvider(t)
+	defer p.server.Close()
+	p.claims = jwt.MapClaims{"sub": "123", "groups": []string{"devs", "admins"}}
+	o := newOIDC(t, p)
+	session := login(t, o, p)
+
+	req := httptest.NewRequest("DELETE", "/locks?id=1", nil)
+	req.AddCookie(session)
+	w, _ := serve(o, req)
+	Equals(t, http.StatusOK, w.Code)
+}
+
+func TestOIDC_InvalidSession(t *testing.T) {
+	p := newFakeProvider(t)
+	defer p.server.Close()
+	o := newOIDC(t, p)
+
+	// A session signed with a different client secret isn't valid.
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":    "123",
+		"groups": []string{"admins"},
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte("wrong"))
+	Ok(t, err)
+	req := httptest.NewRequest("DELETE", "/locks?id=1", nil)
+	req.AddCookie(&http.Cookie{Name: "atlantis_session", Value: forged})
+	w, _ := serve(o, req)
+	Equals(t, http.StatusUnauthorized, w.Code)
+}
+
+// Test that the state cookie, which is signed with the same key, can't be
+// used as a session.
+func TestOIDC_StateCookieAsSession(t *testing.T) {
+	p := newFakeProvider(t)
+	defer p.server.Close()
+	o := newOIDC(t, p)
+
+	w := httptest.NewRecorder()
+	o.Login(w, httptest.NewRequest("GET", "/auth/login", nil))
+	var state *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "atlantis_oidc_state" {
+			state = c
+		}
+	}
+	Assert(t, state != nil, "no state cookie")
+
+	req := httptest.NewRequest("GET", "/pulls", nil)
+	req.AddCookie(&http.Cookie{Name: "atlantis_session", Value: state.Value})
+	w, user := serve(o, req)
+	Equals(t, http.StatusFound, w.Code)
+	Assert(t, user == nil, "exp no user")
+
+	req = httptest.NewRequest("DELETE", "/locks?id=1", nil)
+	req.AddCookie(&http.Cookie{Name: "atlantis_session", Value: state.Value})
+	w, _ = serve(o, req)
+	Equals(t, http.StatusUnauthorized, w.Code)
+}
+
+// Test that a session without a subject isn't valid.
+func TestOIDC_CallbackNoSubject(t *testing.T) {
+	p := newFakeProvider(t)
+	defer p.server.Close()
+	p.claims = jwt.MapClaims{"email": "user@example.com"}
+	o := newOIDC(t, p)
+
+	w := httptest.NewRecorder()
+	o.Login(w, httptest.NewRequest("GET", "/auth/login", nil))
+	authURL, err := url.Parse(w.Header().Get("Location"))
+	Ok(t, err)
+	p.nonce = authURL.Query().Get("nonce")
+	req := httptest.NewRequest("GET", "/auth/callback?code=the-code&state="+authURL.Query().Get("state"), nil)
+	for _, c := range w.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	w = httptest.NewRecorder()
+	o.Callback(w, req)
+	ResponseContains(t, w, http.StatusUnauthorized, "ID token has no subject")
+}
+
+func TestOIDC_CallbackWrongState(t *testing.T) {
+	p := newFakeProvider(t)
+	defer p.server.Close()
+	o := newOIDC(t, p)
+
+	w := httptest.NewRecorder()
+	o.Login(w, httptest.NewRequest("GET", "/auth/login", nil))
+	req := httptest.NewRequest("GET", "/auth/callback?code=the-code&state=wrong", nil)
+	for _, c := range w.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	w = httptest.NewRecorder()
+	o.Callback(w, req)
+	ResponseContains(t, w, http.StatusBadRequest, "state doesn't match")
+}
+
+func TestOIDC_CallbackWrongNonce(t *testing.T) {
+	p := newFakeProvider(t)
+	defer p.server.Close()
+	o := newOIDC(t, p)
+
+	w := httptest.NewRecorder()
+	o.Login(w, httptest.NewRequest("GET", "/auth/login", nil))
+	authURL, err := url.Parse(w.Header().Get("Location"))
+	Ok(t, err)
+	p.nonce = "wrong"
+	req := httptest.NewRequest("GET", "/auth/callback?code=the-code&state="+authURL.Query().Get("state"), nil)
+	for _, c := range w.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	w = httptest.NewRecorder()
+	o.Callback(w, req)
+	ResponseContains(t, w, http.StatusUnauthorized, "wrong nonce")
+}
+
+func TestOIDC_LoginRedirectMustBeLocal(t *testing.T) {
+	p := newFakeProvider(t)
+	defer p.server.Close()
+	p.claims = jwt.MapClaims{"sub": "123"}
+	o := newOIDC(t, p)
+
+	w := httptest.NewRecorder()
+	o.Login(w, httptest.NewRequest("GET", "/auth/login?redirect=//evil.com", nil))
+	authURL, err := url.Parse(w.Header().Get("Location"))
+	Ok(t, err)
+	Assert(t, strings.HasPrefix(authURL.String(), p.server.URL+"/authorize?"), "expected redirect to provider, got %s", authURL)
+	p.nonce = authURL.Query().Get("nonce")
+	req := httptest.NewRequest("GET", "/auth/callback?code=the-code&state="+authURL.Query().Get("state"), nil)
+	for _, c := range w.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	w = httptest.NewRecorder()
+	o.Callback(w, req)
+	Equals(t, http.StatusFound, w.Code)
+	Equals(t, "/basepath/", w.Header().Get("Location"))
+}
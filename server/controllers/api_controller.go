@@ -0,0 +1,1078 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/deliveries"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/locking"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/yaml/raw"
+	"github.com/runatlantis/atlantis/server/jobs"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// APIPrefix is the prefix of all versioned API routes.
+const APIPrefix = "/api/v1"
+
+// Scopes grant API tokens access to routes.
+const (
+	// PlanScope allows triggering plans.
+	PlanScope = "plan"
+	// ApplyScope allows triggering applies.
+	ApplyScope = "apply"
+	// ReadScope allows reading any job. Tokens can always read the jobs
+	// they created.
+	ReadScope = "read"
+	// AdminScope allows draining Atlantis and reloading the server-side repo
+	// config.
+	AdminScope = "admin"
+)
+
+// ValidScopes are all the scopes an API token can have.
+var ValidScopes = []string{PlanScope, ApplyScope, ReadScope, AdminScope}
+
+// APIToken authenticates requests to the API.
+type APIToken struct {
+	// Name identifies the token in logs and jobs. API requests run as the
+	// user "api:{name}".
+	Name   string
+	Token  string
+	Scopes []string
+}
+
+// HasScope returns true if the token was granted scope.
+func (t APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIController handles the versioned API used to trigger plans and applies
+// without a pull request, ex. from CI pipelines.
+type APIController struct {
+	Logger logging.SimpleLogging
+	// Tokens authenticate the requests. Once requests are handled, their
+	// secrets must only be replaced with SetToken.
+	Tokens                        []APIToken
+	Parser                        events.EventParsing
+	SupportedVCSHosts             []models.VCSHostType
+	RepoAllowlistChecker          *events.RepoAllowlistChecker
+	PreWorkflowHooksCommandRunner events.PreWorkflowHooksCommandRunner
+	ProjectCommandBuilder         events.ProjectCommandBuilder
+	ProjectCommandRunner          events.ProjectCommandRunner
+	// DeleteLockCommand deletes the locks and working directories held by
+	// API jobs once they finish.
+	DeleteLockCommand events.DeleteLockCommand
+	Drainer           *events.Drainer
+	Jobs              *jobs.Store
+	// PlanArtifacts reads the plans of pull requests for the /api/v1/plans
+	// routes.
+	PlanArtifacts *events.PlanArtifactReader
+	// ReloadRepoConfig reloads the server-side repo config file. If nil,
+	// there's no file to reload.
+	ReloadRepoConfig func() error
+	// Deliveries are the webhooks received recently. If nil, webhooks aren't
+	// stored and can't be replayed.
+	Deliveries *deliveries.Store
+	// ReplayWebhook handles the stored webhook with id again. It returns what
+	// Atlantis responded with and false if there's no webhook with id.
+	ReplayWebhook func(id string) (int, string, bool)
+	// Maintenance rejects applies during its maintenance windows. If nil,
+	// there are none.
+	Maintenance *locking.MaintenanceSchedule
+	// BoltDB maintains the BoltDB file. If nil, BoltDB isn't used.
+	BoltDB *events.BoltDBMaintainer
+	// RepoMutexes serializes jobs for the same repo since they share locks
+	// and working directories.
+	RepoMutexes *events.RepoMutexes
+	// Drift runs the drift checks. If nil, there are none.
+	Drift *events.DriftDetector
+	// Audit queries the audit log for GET /api/v1/audit.
+	Audit *AuditController
+	// WorkingDir resolves the refs of API requests to commits.
+	WorkingDir events.WorkingDir
+
+	// lastPullNum is the magnitude of the pull request number of the latest
+	// job. See nextPullNum.
+	lastPullNum int64
+	// tokensMutex guards Tokens, which can be rotated while requests are
+	// handled.
+	tokensMutex sync.RWMutex
+}
+
+// SetToken replaces the secret of the token named name, ex. when it's rotated
+// in its secret store. Requests are authenticated with it immediately.
+func (a *APIController) SetToken(name string, secret string) error {
+	a.tokensMutex.Lock()
+	defer a.tokensMutex.Unlock()
+	for i := range a.Tokens {
+		if a.Tokens[i].Name == name {
+			a.Tokens[i].Token = secret
+			return nil
+		}
+	}
+	return fmt.Errorf("no API token named %q", name)
+}
+
+// APIRequest is the body of POST /api/v1/plan and POST /api/v1/apply.
+type APIRequest struct {
+	// VCS is the VCS host of the repo: github, gitlab, bitbucket-cloud or
+	// azuredevops. Can be omitted if only one VCS host is configured.
+	VCS string `json:"vcs"`
+	// Repository is the repo's full name, ex. owner/repo.
+	Repository string `json:"repository"`
+	// Ref is the branch to run on. It's resolved to its latest commit when
+	// the job is created.
+	Ref      string       `json:"ref"`
+	Projects []APIProject `json:"projects"`
+}
+
+// APIProject identifies a project either by name or by dir and workspace.
+type APIProject struct {
+	Name      string `json:"name"`
+	Dir       string `json:"dir"`
+	Workspace string `json:"workspace"`
+}
+
+// APIJobResponse is the response to POST /api/v1/plan and POST
+// /api/v1/apply.
+type APIJobResponse struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+// vcsHostTypes maps the vcs values of API requests to VCS host types.
+var vcsHostTypes = map[string]models.VCSHostType{
+	"github":          models.Github,
+	"gitlab":          models.Gitlab,
+	"bitbucket-cloud": models.BitbucketCloud,
+	"azuredevops":     models.AzureDevops,
+}
+
+// Plan is the POST /api/v1/plan route. It starts a job that plans the
+// requested projects and then discards the plans and their locks.
+func (a *APIController) Plan(w http.ResponseWriter, r *http.Request) {
+	a.startJob(w, r, models.PlanCommand, PlanScope)
+}
+
+// Apply is the POST /api/v1/apply route. It starts a job that plans and then
+// applies the requested projects.
+func (a *APIController) Apply(w http.ResponseWriter, r *http.Request) {
+	a.startJob(w, r, models.ApplyCommand, ApplyScope)
+}
+
+// GetJob is the GET /api/v1/jobs/{id} route.
+func (a *APIController) GetJob(w http.ResponseWriter, r *http.Request) {
+	token, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+	job, ok := a.Jobs.Get(mux.Vars(r)["id"])
+	// Respond with not found for other token's jobs so job IDs can't be
+	// probed.
+	if !ok || (job.CreatedBy != token.Name && !token.HasScope(ReadScope)) {
+		a.respond(w, logging.Info, http.StatusNotFound, "No job found with id %q", mux.Vars(r)["id"])
+		return
+	}
+	a.writeJSON(w, http.StatusOK, job)
+}
+
+// APIPlan is a plan stored for a pull request's project.
+type APIPlan struct {
+	Project   string `json:"project,omitempty"`
+	Dir       string `json:"dir"`
+	Workspace string `json:"workspace"`
+	// URL downloads the binary plan.
+	URL string `json:"url"`
+	// JSONURL downloads the plan rendered by terraform show -json.
+	JSONURL string `json:"json_url"`
+}
+
+// ListPlans is the GET /api/v1/plans route. It lists the plans stored for the
+// pull request in the vcs, repository and pull query parameters.
+func (a *APIController) ListPlans(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.authenticateScope(w, r, ReadScope); !ok {
+		return
+	}
+	repo, pullNum, err := a.parsePlanQuery(r)
+	if err != nil {
+		a.respond(w, logging.Info, http.StatusBadRequest, "Invalid request: %s", err)
+		return
+	}
+	plans, err := a.PlanArtifacts.List(repo, pullNum)
+	if err != nil {
+		a.respond(w, logging.Error, http.StatusInternalServerError, "Listing plans: %s", err)
+		return
+	}
+	resp := []APIPlan{}
+	for _, p := range plans {
+		if !a.RepoAllowlistChecker.IsDirAllowlisted(repo.FullName, repo.VCSHost.Hostname, p.RepoRelDir) {
+			continue
+		}
+		query := url.Values{}
+		if vcs := r.URL.Query().Get("vcs"); vcs != "" {
+			query.Set("vcs", vcs)
+		}
+		query.Set("repository", repo.FullName)
+		query.Set("pull", strconv.Itoa(pullNum))
+		query.Set("dir", p.RepoRelDir)
+		query.Set("workspace", p.Workspace)
+		if p.ProjectName != "" {
+			query.Set("project", p.ProjectName)
+		}
+		planURL := APIPrefix + "/plans/download?" + query.Encode()
+		resp = append(resp, APIPlan{
+			Project:   p.ProjectName,
+			Dir:       p.RepoRelDir,
+			Workspace: p.Workspace,
+			URL:       planURL,
+			JSONURL:   planURL + "&format=json",
+		})
+	}
+	a.writeJSON(w, http.StatusOK, resp)
+}
+
+// DownloadPlan is the GET /api/v1/plans/download route. It returns the plan
+// identified by the vcs, repository, pull, and either the project or the dir
+// and workspace query parameters. If format is json, the plan is rendered by
+// terraform show -json, otherwise the binary plan is returned.
+func (a *APIController) DownloadPlan(w http.ResponseWriter, r *http.Request) {
+	token, ok := a.authenticateScope(w, r, ReadScope)
+	if !ok {
+		return
+	}
+	repo, pullNum, err := a.parsePlanQuery(r)
+	if err != nil {
+		a.respond(w, logging.Info, http.StatusBadRequest, "Invalid request: %s", err)
+		return
+	}
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format != "" && format != "binary" && format != "json" {
+		a.respond(w, logging.Info, http.StatusBadRequest, "Invalid request: format must be binary or json, got %q", format)
+		return
+	}
+	if query.Get("project") == "" && query.Get("dir") == "" {
+		a.respond(w, logging.Info, http.StatusBadRequest, "Invalid request: project or dir is required")
+		return
+	}
+	plan, err := a.PlanArtifacts.Find(repo, pullNum, query.Get("project"), query.Get("dir"), query.Get("workspace"))
+	if err == events.ErrPlanNotFound {
+		a.respond(w, logging.Info, http.StatusNotFound, "No plan found for %s#%d", repo.FullName, pullNum)
+		return
+	}
+	if err != nil {
+		a.respond(w, logging.Error, http.StatusInternalServerError, "Finding plan: %s", err)
+		return
+	}
+	if !a.RepoAllowlistChecker.IsDirAllowlisted(repo.FullName, repo.VCSHost.Hostname, plan.RepoRelDir) {
+		a.respond(w, logging.Info, http.StatusNotFound, "No plan found for %s#%d", repo.FullName, pullNum)
+		return
+	}
+
+	var contents []byte
+	if format == "json" {
+		contents, err = a.PlanArtifacts.ReadJSON(a.Logger, repo, pullNum, plan)
+	} else {
+		contents, err = a.PlanArtifacts.Read(repo, pullNum, plan)
+	}
+	if err == events.ErrPlanNotFound {
+		a.respond(w, logging.Info, http.StatusNotFound, "No plan found for %s#%d", repo.FullName, pullNum)
+		return
+	}
+	if err == events.ErrPlanInUse {
+		a.respond(w, logging.Info, http.StatusConflict, "%s", err)
+		return
+	}
+	if err != nil {
+		a.respond(w, logging.Error, http.StatusInternalServerError, "Reading plan: %s", err)
+		return
+	}
+	a.Logger.Info("API token %q downloaded the plan for %s#%d in dir %q and workspace %q", token.Name, repo.FullName, pullNum, plan.RepoRelDir, plan.Workspace)
+	filename := runtime.GetPlanFilename(plan.Workspace, plan.ProjectName)
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		filename = models.ProjectCommandContext{ProjectName: plan.ProjectName, Workspace: plan.Workspace}.GetShowResultFileName()
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	w.Write(contents) // nolint: errcheck
+}
+
+// parsePlanQuery returns the repo and pull request number of the
+// /api/v1/plans routes.
+func (a *APIController) parsePlanQuery(r *http.Request) (models.Repo, int, error) {
+	query := r.URL.Query()
+	if query.Get("repository") == "" {
+		return models.Repo{}, 0, errors.New("repository is required")
+	}
+	pullNum, err := strconv.Atoi(query.Get("pull"))
+	if err != nil || pullNum <= 0 {
+		return models.Repo{}, 0, errors.New("pull must be a pull request number")
+	}
+	hostType, err := a.vcsHostType(query.Get("vcs"))
+	if err != nil {
+		return models.Repo{}, 0, err
+	}
+	repo, err := a.Parser.ParseAPIRepo(hostType, query.Get("repository"))
+	if err != nil {
+		return models.Repo{}, 0, err
+	}
+	if !a.RepoAllowlistChecker.IsAllowlisted(repo.FullName, repo.VCSHost.Hostname) {
+		return models.Repo{}, 0, fmt.Errorf("repo %s is not in the allowlist", repo.FullName)
+	}
+	return repo, pullNum, nil
+}
+
+// APIDrainResponse is the response of the /api/v1/drain routes.
+type APIDrainResponse struct {
+	// Draining is true if new commands are rejected.
+	Draining bool `json:"draining"`
+	// InProgressOps is the number of commands still running.
+	InProgressOps int `json:"in_progress_operations"`
+	// Complete is true once Atlantis is draining and no commands are
+	// running so it's safe to stop.
+	Complete bool `json:"complete"`
+}
+
+// StartDrain is the POST /api/v1/drain route. New commands are rejected with
+// a comment on the pull request while those in progress finish.
+func (a *APIController) StartDrain(w http.ResponseWriter, r *http.Request) {
+	token, ok := a.authenticateScope(w, r, AdminScope)
+	if !ok {
+		return
+	}
+	a.Drainer.Drain()
+	a.Logger.Warn("API token %q started draining", token.Name)
+	a.writeJSON(w, http.StatusAccepted, a.drainStatus())
+}
+
+// StopDrain is the DELETE /api/v1/drain route. It allows new commands to run
+// again.
+func (a *APIController) StopDrain(w http.ResponseWriter, r *http.Request) {
+	token, ok := a.authenticateScope(w, r, AdminScope)
+	if !ok {
+		return
+	}
+	a.Drainer.Resume()
+	a.Logger.Info("API token %q stopped draining", token.Name)
+	a.writeJSON(w, http.StatusOK, a.drainStatus())
+}
+
+// GetDrain is the GET /api/v1/drain route.
+func (a *APIController) GetDrain(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.authenticateScope(w, r, AdminScope); !ok {
+		return
+	}
+	a.writeJSON(w, http.StatusOK, a.drainStatus())
+}
+
+// ReloadRepoConfigHandler is the POST /api/v1/repo-config/reload route. It
+// reloads the server-side repo config file. If the file is invalid, the
+// current config is kept and the error is returned.
+func (a *APIController) ReloadRepoConfigHandler(w http.ResponseWriter, r *http.Request) {
+	token, ok := a.authenticateScope(w, r, AdminScope)
+	if !ok {
+		return
+	}
+	if a.ReloadRepoConfig == nil {
+		a.respond(w, logging.Warn, http.StatusNotFound, "There's no server-side repo config file to reload")
+		return
+	}
+	if err := a.ReloadRepoConfig(); err != nil {
+		a.respond(w, logging.Warn, http.StatusBadRequest, "Not reloading the server-side repo config since it's invalid: %s", err)
+		return
+	}
+	a.Logger.Info("API token %q reloaded the server-side repo config", token.Name)
+	a.respond(w, logging.Info, http.StatusOK, "Reloaded the server-side repo config")
+}
+
+// MaintainBoltDB is the POST /api/v1/boltdb/maintenance route. It checks the
+// integrity of the BoltDB file and compacts it unless problems are found.
+// Other operations wait while it's compacted.
+func (a *APIController) MaintainBoltDB(w http.ResponseWriter, r *http.Request) {
+	token, ok := a.authenticateScope(w, r, AdminScope)
+	if !ok {
+		return
+	}
+	if a.BoltDB == nil {
+		a.respond(w, logging.Info, http.StatusNotFound, "There's no BoltDB file to maintain since --locking-db-type isn't boltdb")
+		return
+	}
+	a.Logger.Info("API token %q started BoltDB maintenance", token.Name)
+	report, err := a.BoltDB.Maintain(true)
+	if err != nil {
+		a.respond(w, logging.Error, http.StatusInternalServerError, "Failed maintaining BoltDB: %s", err)
+		return
+	}
+	a.writeJSON(w, http.StatusOK, report)
+}
+
+// APIWebhook is a webhook received by Atlantis.
+type APIWebhook struct {
+	ID         string    `json:"id"`
+	ReceivedAt time.Time `json:"received_at"`
+	VCS        string    `json:"vcs"`
+	Event      string    `json:"event,omitempty"`
+	DeliveryID string    `json:"delivery_id,omitempty"`
+	// StatusCode and Response are what Atlantis last responded with.
+	StatusCode int    `json:"status_code"`
+	Response   string `json:"response"`
+	Replays    int    `json:"replays"`
+}
+
+// APIReplayResponse is the response to POST /api/v1/webhooks/{id}/replay.
+type APIReplayResponse struct {
+	// StatusCode and Response are what Atlantis responded with when handling
+	// the webhook again.
+	StatusCode int    `json:"status_code"`
+	Response   string `json:"response"`
+}
+
+// ListWebhooks is the GET /api/v1/webhooks route. It lists the webhooks
+// received within --webhook-replay-retention, newest first.
+func (a *APIController) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.authenticateScope(w, r, AdminScope); !ok {
+		return
+	}
+	if a.Deliveries == nil {
+		a.respond(w, logging.Info, http.StatusNotFound, "Webhooks aren't stored since --webhook-replay-retention isn't set")
+		return
+	}
+	resp := []APIWebhook{}
+	for _, d := range a.Deliveries.List() {
+		resp = append(resp, APIWebhook{
+			ID:         d.ID,
+			ReceivedAt: d.ReceivedAt,
+			VCS:        d.VCS,
+			Event:      d.Event,
+			DeliveryID: d.DeliveryID,
+			StatusCode: d.StatusCode,
+			Response:   d.Response,
+			Replays:    d.Replays,
+		})
+	}
+	a.writeJSON(w, http.StatusOK, resp)
+}
+
+// ReplayWebhookHandler is the POST /api/v1/webhooks/{id}/replay route. It
+// handles the stored webhook again as if it was just received, ex. to
+// re-run a command that failed because the VCS host was down.
+func (a *APIController) ReplayWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	token, ok := a.authenticateScope(w, r, AdminScope)
+	if !ok {
+		return
+	}
+	if a.Deliveries == nil || a.ReplayWebhook == nil {
+		a.respond(w, logging.Info, http.StatusNotFound, "Webhooks aren't stored since --webhook-replay-retention isn't set")
+		return
+	}
+	id := mux.Vars(r)["id"]
+	a.Logger.Info("API token %q is replaying webhook %s", token.Name, id)
+	code, response, ok := a.ReplayWebhook(id)
+	if !ok {
+		a.respond(w, logging.Info, http.StatusNotFound, "No webhook found with id %q", id)
+		return
+	}
+	a.writeJSON(w, http.StatusOK, APIReplayResponse{StatusCode: code, Response: response})
+}
+
+// APIMaintenanceWindow is a recurring period during which applies are
+// rejected.
+type APIMaintenanceWindow struct {
+	Name     string `json:"name"`
+	Schedule string `json:"schedule"`
+	Duration string `json:"duration"`
+	Message  string `json:"message,omitempty"`
+	// Global is true if the window applies to every repo.
+	Global bool `json:"global"`
+	// Active is true if the window is in effect now, even if it's
+	// overridden.
+	Active     bool `json:"active"`
+	Overridden bool `json:"overridden"`
+	// Start and End are the times of the current occurrence if the window is
+	// active, otherwise of the next one.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// ListMaintenanceWindows is the GET /api/v1/maintenance-windows route.
+func (a *APIController) ListMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.authenticateScope(w, r, AdminScope); !ok {
+		return
+	}
+	resp := []APIMaintenanceWindow{}
+	if a.Maintenance != nil {
+		for _, s := range a.Maintenance.Status() {
+			resp = append(resp, APIMaintenanceWindow{
+				Name:       s.Name,
+				Schedule:   s.Spec,
+				Duration:   s.Duration.String(),
+				Message:    s.Message,
+				Global:     s.Global,
+				Active:     s.Active,
+				Overridden: s.Overridden,
+				Start:      s.Start,
+				End:        s.End,
+			})
+		}
+	}
+	a.writeJSON(w, http.StatusOK, resp)
+}
+
+// OverrideMaintenanceWindow is the POST
+// /api/v1/maintenance-windows/{name}/override route. It allows applies until
+// the current occurrence of the window ends, ex. to apply a hotfix.
+func (a *APIController) OverrideMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	token, ok := a.authenticateScope(w, r, AdminScope)
+	if !ok {
+		return
+	}
+	name := mux.Vars(r)["name"]
+	if a.Maintenance == nil {
+		a.respond(w, logging.Info, http.StatusNotFound, "No maintenance window named %q", name)
+		return
+	}
+	until, err := a.Maintenance.Override(name)
+	if err != nil {
+		a.respond(w, logging.Info, http.StatusNotFound, "Not overriding maintenance window: %s", err)
+		return
+	}
+	a.Logger.Warn("API token %q overrode maintenance window %q until %s", token.Name, name, until.Format(time.RFC3339))
+	a.respond(w, logging.Info, http.StatusOK, "Applies are allowed until %s", until.Format(time.RFC3339))
+}
+
+// CancelMaintenanceWindowOverride is the DELETE
+// /api/v1/maintenance-windows/{name}/override route. It rejects applies
+// again for the rest of the window.
+func (a *APIController) CancelMaintenanceWindowOverride(w http.ResponseWriter, r *http.Request) {
+	token, ok := a.authenticateScope(w, r, AdminScope)
+	if !ok {
+		return
+	}
+	name := mux.Vars(r)["name"]
+	if a.Maintenance == nil {
+		a.respond(w, logging.Info, http.StatusNotFound, "No maintenance window named %q", name)
+		return
+	}
+	if err := a.Maintenance.CancelOverride(name); err != nil {
+		a.respond(w, logging.Info, http.StatusNotFound, "Not cancelling override: %s", err)
+		return
+	}
+	a.Logger.Info("API token %q cancelled the override of maintenance window %q", token.Name, name)
+	a.respond(w, logging.Info, http.StatusOK, "Cancelled the override of maintenance window %q", name)
+}
+
+// APIDriftCheck is a drift check and the results of its last run.
+type APIDriftCheck struct {
+	Name       string `json:"name"`
+	Repository string `json:"repository"`
+	Branch     string `json:"branch"`
+	Schedule   string `json:"schedule"`
+	Running    bool   `json:"running"`
+	// LastRun is when the check last finished. It's omitted if it hasn't run
+	// since Atlantis started.
+	LastRun  *time.Time              `json:"last_run,omitempty"`
+	NextRun  time.Time               `json:"next_run"`
+	Projects []APIDriftProjectResult `json:"projects"`
+}
+
+// APIDriftProjectResult is the result of the last drift check of a project.
+type APIDriftProjectResult struct {
+	Project   string `json:"project,omitempty"`
+	Dir       string `json:"dir"`
+	Workspace string `json:"workspace"`
+	// Status is drifted, in-sync or errored.
+	Status    string `json:"status"`
+	Summary   string `json:"summary,omitempty"`
+	Error     string `json:"error,omitempty"`
+	OutputURL string `json:"output_url,omitempty"`
+	// IssueURL is the URL of the open issue about the drift, if any.
+	IssueURL  string    `json:"issue_url,omitempty"`
+	ToAdd     int       `json:"to_add"`
+	ToChange  int       `json:"to_change"`
+	ToDestroy int       `json:"to_destroy"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// APIDriftSummary summarizes the last run of every drift check.
+type APIDriftSummary struct {
+	Checks    int `json:"checks"`
+	Projects  int `json:"projects"`
+	Drifted   int `json:"drifted"`
+	InSync    int `json:"in_sync"`
+	Errored   int `json:"errored"`
+	ToAdd     int `json:"to_add"`
+	ToChange  int `json:"to_change"`
+	ToDestroy int `json:"to_destroy"`
+	// LastRun is when a check last finished. It's omitted if none has run
+	// since Atlantis started.
+	LastRun *time.Time `json:"last_run,omitempty"`
+}
+
+// ListDrift is the GET /api/v1/drift route. It lists the drift checks and
+// the results of their last run.
+func (a *APIController) ListDrift(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.authenticateScope(w, r, ReadScope); !ok {
+		return
+	}
+	resp := []APIDriftCheck{}
+	if a.Drift != nil {
+		for _, s := range a.Drift.Status() {
+			check := APIDriftCheck{
+				Name:       s.Name,
+				Repository: s.Repo.FullName,
+				Branch:     s.Branch,
+				Schedule:   s.Spec,
+				Running:    s.Running,
+				NextRun:    s.NextRun,
+				Projects:   []APIDriftProjectResult{},
+			}
+			if !s.LastRun.IsZero() {
+				lastRun := s.LastRun
+				check.LastRun = &lastRun
+			}
+			for _, r := range s.Results {
+				check.Projects = append(check.Projects, APIDriftProjectResult{
+					Project:   r.ProjectName,
+					Dir:       r.Dir,
+					Workspace: r.Workspace,
+					Status:    r.Status,
+					Summary:   r.Summary,
+					Error:     r.Error,
+					OutputURL: r.OutputURL,
+					IssueURL:  r.IssueURL,
+					ToAdd:     r.ToAdd,
+					ToChange:  r.ToChange,
+					ToDestroy: r.ToDestroy,
+					CheckedAt: r.CheckedAt,
+				})
+			}
+			resp = append(resp, check)
+		}
+	}
+	a.writeJSON(w, http.StatusOK, resp)
+}
+
+// GetAudit is the GET /api/v1/audit route. It requires the admin scope since
+// the audit log includes who ran which commands and their errors.
+func (a *APIController) GetAudit(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.authenticateScope(w, r, AdminScope); !ok {
+		return
+	}
+	a.Audit.Get(w, r)
+}
+
+// DriftSummary is the GET /api/v1/drift/summary route. It summarizes the last
+// run of every drift check.
+func (a *APIController) DriftSummary(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.authenticateScope(w, r, ReadScope); !ok {
+		return
+	}
+	var resp APIDriftSummary
+	if a.Drift != nil {
+		summary := a.Drift.Summary()
+		resp = APIDriftSummary{
+			Checks:    summary.Checks,
+			Projects:  summary.Projects,
+			Drifted:   summary.Drifted,
+			InSync:    summary.InSync,
+			Errored:   summary.Errored,
+			ToAdd:     summary.ToAdd,
+			ToChange:  summary.ToChange,
+			ToDestroy: summary.ToDestroy,
+		}
+		if !summary.LastRun.IsZero() {
+			resp.LastRun = &summary.LastRun
+		}
+	}
+	a.writeJSON(w, http.StatusOK, resp)
+}
+
+// RunDriftCheck is the POST /api/v1/drift/{name}/run route. It runs the drift
+// check now in the background.
+func (a *APIController) RunDriftCheck(w http.ResponseWriter, r *http.Request) {
+	token, ok := a.authenticateScope(w, r, PlanScope)
+	if !ok {
+		return
+	}
+	name := mux.Vars(r)["name"]
+	if a.Drift == nil {
+		a.respond(w, logging.Info, http.StatusNotFound, "No drift check named %q", name)
+		return
+	}
+	switch err := a.Drift.Start(name); err {
+	case nil:
+		a.Logger.Info("API token %q started drift check %q", token.Name, name)
+		a.respond(w, logging.Info, http.StatusAccepted, "Started drift check %q", name)
+	case events.ErrDriftCheckNotFound:
+		a.respond(w, logging.Info, http.StatusNotFound, "No drift check named %q", name)
+	case events.ErrDriftCheckRunning:
+		a.respond(w, logging.Info, http.StatusConflict, "Drift check %q is already running", name)
+	default:
+		a.respond(w, logging.Error, http.StatusInternalServerError, "Starting drift check %q: %s", name, err)
+	}
+}
+
+func (a *APIController) drainStatus() APIDrainResponse {
+	status := a.Drainer.GetStatus()
+	return APIDrainResponse{
+		Draining:      status.Draining,
+		InProgressOps: status.InProgressOps,
+		Complete:      status.Draining && status.InProgressOps == 0,
+	}
+}
+
+func (a *APIController) startJob(w http.ResponseWriter, r *http.Request, cmdName models.CommandName, scope string) {
+	token, ok := a.authenticateScope(w, r, scope)
+	if !ok {
+		return
+	}
+
+	var req APIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.respond(w, logging.Warn, http.StatusBadRequest, "Failed parsing request: %s", err)
+		return
+	}
+	ctx, err := a.buildContext(req, token)
+	if err != nil {
+		a.respond(w, logging.Warn, http.StatusBadRequest, "Invalid request: %s", err)
+		return
+	}
+
+	if cmdName == models.ApplyCommand && a.Maintenance != nil {
+		repo := ctx.Pull.BaseRepo
+		if window := a.Maintenance.Active(repo.FullName, repo.VCSHost.Hostname); window != nil {
+			a.respond(w, logging.Info, http.StatusConflict, "Applies are disabled during the scheduled maintenance window %q until %s",
+				window.Name, window.End.Format(time.RFC3339))
+			return
+		}
+	}
+
+	if !a.Drainer.StartOp() {
+		if a.Drainer.GetStatus().Draining {
+			a.respond(w, logging.Warn, http.StatusServiceUnavailable, "Atlantis is draining")
+			return
+		}
+		a.respond(w, logging.Warn, http.StatusServiceUnavailable, "Atlantis is shutting down")
+		return
+	}
+	job := a.Jobs.Create(jobs.Job{
+		Command:   cmdName.String(),
+		Repo:      ctx.Pull.BaseRepo.FullName,
+		Ref:       req.Ref,
+		Commit:    ctx.Pull.HeadCommit,
+		CreatedBy: token.Name,
+	})
+	ctx.Log = a.Logger.WithHistory("repository", ctx.Pull.BaseRepo.FullName, "job", job.ID)
+	ctx.Log.Info("API token %q started %s job", token.Name, cmdName.String())
+	go a.run(job.ID, cmdName, ctx, req.Projects)
+
+	a.writeJSON(w, http.StatusAccepted, APIJobResponse{
+		ID:  job.ID,
+		URL: fmt.Sprintf("%s/jobs/%s", APIPrefix, job.ID),
+	})
+}
+
+// buildContext returns the context for req. API requests don't have a pull
+// request so they run as a pull request of the ref's latest commit with a
+// number of their own. See nextPullNum.
+func (a *APIController) buildContext(req APIRequest, token APIToken) (*events.CommandContext, error) {
+	if req.Repository == "" {
+		return nil, errors.New("repository is required")
+	}
+	if req.Ref == "" {
+		return nil, errors.New("ref is required")
+	}
+	if len(req.Projects) == 0 {
+		return nil, errors.New("at least one project is required")
+	}
+	for _, p := range req.Projects {
+		if p.Name == "" && p.Dir == "" {
+			return nil, errors.New("projects must have a name or dir")
+		}
+	}
+
+	hostType, err := a.vcsHostType(req.VCS)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := a.Parser.ParseAPIRepo(hostType, req.Repository)
+	if err != nil {
+		return nil, err
+	}
+	if !a.RepoAllowlistChecker.IsAllowlisted(repo.FullName, repo.VCSHost.Hostname) {
+		return nil, fmt.Errorf("repo %s is not in the allowlist", repo.FullName)
+	}
+	for _, p := range req.Projects {
+		if p.Dir != "" && !a.RepoAllowlistChecker.IsDirAllowlisted(repo.FullName, repo.VCSHost.Hostname, p.Dir) {
+			return nil, fmt.Errorf("dir %q of repo %s is not in the allowlist", p.Dir, repo.FullName)
+		}
+	}
+	sha, err := a.WorkingDir.RemoteBranchSHA(a.Logger, repo, req.Ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "resolving ref %q", req.Ref)
+	}
+	return &events.CommandContext{
+		HeadRepo: repo,
+		Pull: models.PullRequest{
+			Num:        a.nextPullNum(),
+			HeadBranch: req.Ref,
+			HeadCommit: sha,
+			BaseBranch: req.Ref,
+			Author:     "api:" + token.Name,
+			State:      models.OpenPullState,
+			BaseRepo:   repo,
+		},
+		User:    models.User{Username: "api:" + token.Name},
+		Trigger: events.Comment,
+	}, nil
+}
+
+// nextPullNum returns the pull request number of a new job. Locks and working
+// directories are owned by pull requests so each job gets its own, negative,
+// number to not share them with pull requests, drift checks or other jobs.
+// The numbers are based on the time so they aren't reused after a restart
+// either.
+func (a *APIController) nextPullNum() int {
+	for {
+		last := atomic.LoadInt64(&a.lastPullNum)
+		next := time.Now().Unix()
+		if next <= last {
+			next = last + 1
+		}
+		if atomic.CompareAndSwapInt64(&a.lastPullNum, last, next) {
+			return -int(next)
+		}
+	}
+}
+
+func (a *APIController) vcsHostType(name string) (models.VCSHostType, error) {
+	return VCSHostType(name, a.SupportedVCSHosts)
+}
+
+// VCSHostType returns the VCS host type of name, ex. github, which must be
+// one of the supported hosts. If name is empty, it's the only supported
+// host.
+func VCSHostType(name string, supported []models.VCSHostType) (models.VCSHostType, error) {
+	if name == "" {
+		if len(supported) != 1 {
+			return 0, errors.New("vcs is required when more than one VCS host is configured")
+		}
+		return supported[0], nil
+	}
+	hostType, ok := vcsHostTypes[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("vcs %q isn't supported", name)
+	}
+	for _, h := range supported {
+		if h == hostType {
+			return hostType, nil
+		}
+	}
+	return 0, fmt.Errorf("vcs %q isn't configured", name)
+}
+
+// run runs the job with id.
+func (a *APIController) run(id string, cmdName models.CommandName, ctx *events.CommandContext, projects []APIProject) {
+	defer a.Drainer.OpDone()
+	defer a.RepoMutexes.Lock(ctx.Pull.BaseRepo.FullName)()
+
+	a.Jobs.Start(id)
+	if err := a.PreWorkflowHooksCommandRunner.RunPreHooks(ctx); err != nil {
+		ctx.Log.Err("Error running pre-workflow hooks %s. Proceeding with %s command.", err, cmdName.String())
+	}
+	err := a.runCommands(id, cmdName, ctx, projects)
+	// API jobs don't keep plans or locks between jobs since the plans were
+	// made for a ref, not a pull request. Each job has its own pull request
+	// number so only its own locks are deleted.
+	if _, unlockErr := a.DeleteLockCommand.DeleteLocksByPull(ctx.Pull.BaseRepo.FullName, ctx.Pull.Num); unlockErr != nil {
+		ctx.Log.Err("failed to delete locks: %s", unlockErr)
+	}
+	a.Jobs.Finish(id, err)
+}
+
+// runCommands plans the projects and, if cmdName is apply and all the plans
+// succeeded, applies them.
+func (a *APIController) runCommands(id string, cmdName models.CommandName, ctx *events.CommandContext, projects []APIProject) error {
+	plansSucceeded := true
+	for _, p := range projects {
+		planCmds, err := a.ProjectCommandBuilder.BuildPlanCommands(ctx, p.comment(models.PlanCommand))
+		if err != nil {
+			return errors.Wrapf(err, "building plan command for %s", p)
+		}
+		if err := a.checkDirsAllowlisted(ctx, planCmds); err != nil {
+			return errors.Wrapf(err, "building plan command for %s", p)
+		}
+		for _, planCmd := range planCmds {
+			result := a.ProjectCommandRunner.Plan(planCmd)
+			plansSucceeded = plansSucceeded && result.IsSuccessful()
+			// Only record successful plans if that's the command since
+			// otherwise the apply results are what matter.
+			if cmdName == models.PlanCommand || !result.IsSuccessful() {
+				a.Jobs.AddResult(id, toJobResult(result))
+			}
+		}
+	}
+	if cmdName != models.ApplyCommand {
+		return nil
+	}
+	if !plansSucceeded {
+		return errors.New("not applying since some plans failed")
+	}
+	for _, p := range projects {
+		applyCmds, err := a.ProjectCommandBuilder.BuildApplyCommands(ctx, p.comment(models.ApplyCommand))
+		if err != nil {
+			return errors.Wrapf(err, "building apply command for %s", p)
+		}
+		if err := a.checkDirsAllowlisted(ctx, applyCmds); err != nil {
+			return errors.Wrapf(err, "building apply command for %s", p)
+		}
+		for _, applyCmd := range applyCmds {
+			if reqs := pullRequestApplyRequirements(applyCmd.ApplyRequirements); len(reqs) > 0 {
+				a.Jobs.AddResult(id, toJobResult(models.ProjectResult{
+					Command:     models.ApplyCommand,
+					RepoRelDir:  applyCmd.RepoRelDir,
+					Workspace:   applyCmd.Workspace,
+					ProjectName: applyCmd.ProjectName,
+					Failure:     fmt.Sprintf("The project can't be applied through the API since its apply requirements need a pull request: %s.", strings.Join(reqs, ", ")),
+				}))
+				continue
+			}
+			a.Jobs.AddResult(id, toJobResult(a.ProjectCommandRunner.Apply(applyCmd)))
+		}
+	}
+	return nil
+}
+
+// checkDirsAllowlisted returns an error if the repo isn't allowlisted for the
+// dir of one of cmds. Projects named in requests are only resolved to their
+// dirs once their commands are built.
+func (a *APIController) checkDirsAllowlisted(ctx *events.CommandContext, cmds []models.ProjectCommandContext) error {
+	repo := ctx.Pull.BaseRepo
+	for _, cmd := range cmds {
+		if !a.RepoAllowlistChecker.IsDirAllowlisted(repo.FullName, repo.VCSHost.Hostname, cmd.RepoRelDir) {
+			return fmt.Errorf("dir %q of repo %s is not in the allowlist", cmd.RepoRelDir, repo.FullName)
+		}
+	}
+	return nil
+}
+
+// pullRequestApplyRequirements returns the requirements of reqs that can only
+// be checked against a pull request.
+func pullRequestApplyRequirements(reqs []string) []string {
+	var pullReqs []string
+	for _, req := range reqs {
+		switch req {
+		case raw.ApprovedApplyRequirement, raw.ApprovedCountRequirement, raw.CodeOwnersApplyRequirement,
+			raw.MergeableApplyRequirement, raw.UnDivergedApplyRequirement, raw.BaseUnchangedRequirement,
+			raw.PipelineSucceededRequirement:
+			pullReqs = append(pullReqs, req)
+		}
+	}
+	return pullReqs
+}
+
+// comment returns the comment command that would run name on p.
+func (p APIProject) comment(name models.CommandName) *events.CommentCommand {
+	return events.NewCommentCommand(p.Dir, nil, name, false, p.Workspace, p.Name)
+}
+
+func (p APIProject) String() string {
+	if p.Name != "" {
+		return fmt.Sprintf("project %q", p.Name)
+	}
+	return fmt.Sprintf("dir %q", p.Dir)
+}
+
+func toJobResult(r models.ProjectResult) jobs.ProjectResult {
+	result := jobs.ProjectResult{
+		Project:   r.ProjectName,
+		Dir:       r.RepoRelDir,
+		Workspace: r.Workspace,
+		Success:   r.IsSuccessful(),
+		Failure:   r.Failure,
+		Output:    r.ApplySuccess,
+		OutputURL: r.OutputURL,
+	}
+	if r.Error != nil {
+		result.Error = r.Error.Error()
+	}
+	if r.PlanSuccess != nil {
+		result.Output = r.PlanSuccess.TerraformOutput
+		result.Summary = r.PlanSuccess.Summary()
+	}
+	return result
+}
+
+// authenticate returns the token the request was made with. If the request
+// isn't authenticated, it responds and returns false.
+func (a *APIController) authenticate(w http.ResponseWriter, r *http.Request) (APIToken, bool) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		a.respond(w, logging.Warn, http.StatusUnauthorized, "Missing bearer token in Authorization header")
+		return APIToken{}, false
+	}
+	given := []byte(strings.TrimPrefix(header, "Bearer "))
+	a.tokensMutex.RLock()
+	defer a.tokensMutex.RUnlock()
+	for _, token := range a.Tokens {
+		if subtle.ConstantTimeCompare(given, []byte(token.Token)) == 1 {
+			return token, true
+		}
+	}
+	a.respond(w, logging.Warn, http.StatusUnauthorized, "Invalid API token")
+	return APIToken{}, false
+}
+
+// authenticateScope is like authenticate but also responds and returns false
+// if the token doesn't have scope.
+func (a *APIController) authenticateScope(w http.ResponseWriter, r *http.Request, scope string) (APIToken, bool) {
+	token, ok := a.authenticate(w, r)
+	if !ok {
+		return APIToken{}, false
+	}
+	if !token.HasScope(scope) {
+		a.respond(w, logging.Warn, http.StatusForbidden, "API token %q doesn't have the %s scope", token.Name, scope)
+		return APIToken{}, false
+	}
+	return token, true
+}
+
+func (a *APIController) writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		a.respond(w, logging.Error, http.StatusInternalServerError, "Error creating json response: %s", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data) // nolint: errcheck
+}
+
+// respond is a helper function to respond and log the response. lvl is the log
+// level to log at, code is the HTTP response code.
+func (a *APIController) respond(w http.ResponseWriter, lvl logging.LogLevel, responseCode int, format string, args ...interface{}) {
+	response := fmt.Sprintf(format, args...)
+	a.Logger.Log(lvl, response)
+	w.WriteHeader(responseCode)
+	fmt.Fprintln(w, response)
+}
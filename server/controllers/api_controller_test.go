@@ -0,0 +1,779 @@
+package controllers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/controllers"
+	"github.com/runatlantis/atlantis/server/deliveries"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/audit"
+	"github.com/runatlantis/atlantis/server/events/db"
+	"github.com/runatlantis/atlantis/server/events/locking"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/jobs"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+const (
+	planToken  = "plan-token-0123456789"
+	applyToken = "apply-token-0123456789"
+	readToken  = "read-token-0123456789"
+	adminToken = "admin-token-0123456789"
+)
+
+func anyCommandContext() *events.CommandContext {
+	RegisterMatcher(NewAnyMatcher(reflect.TypeOf(&events.CommandContext{})))
+	return nil
+}
+
+func setupAPIController(t *testing.T) (*controllers.APIController, *mocks.MockProjectCommandBuilder, *mocks.MockProjectCommandRunner, *mocks.MockDeleteLockCommand) {
+	RegisterMockTestingT(t)
+	parser := mocks.NewMockEventParsing()
+	builder := mocks.NewMockProjectCommandBuilder()
+	runner := mocks.NewMockProjectCommandRunner()
+	deleteLockCommand := mocks.NewMockDeleteLockCommand()
+	workingDir := mocks.NewMockWorkingDir()
+	allowlist, err := events.NewRepoAllowlistChecker("github.com/owner/*")
+	Ok(t, err)
+	When(workingDir.RemoteBranchSHA(matchers.AnyLoggingSimpleLogging(), matchers.AnyModelsRepo(), AnyString())).ThenReturn("sha", nil)
+	When(workingDir.RemoteBranchSHA(matchers.AnyLoggingSimpleLogging(), matchers.AnyModelsRepo(), EqString("missing"))).
+		ThenReturn("", errors.New(`branch "missing" not found`))
+
+	repo := models.Repo{
+		FullName: "owner/repo",
+		VCSHost:  models.VCSHost{Hostname: "github.com", Type: models.Github},
+	}
+	When(parser.ParseAPIRepo(models.Github, "owner/repo")).ThenReturn(repo, nil)
+	When(parser.ParseAPIRepo(models.Github, "other/repo")).ThenReturn(models.Repo{
+		FullName: "other/repo",
+		VCSHost:  models.VCSHost{Hostname: "github.com", Type: models.Github},
+	}, nil)
+
+	ac := &controllers.APIController{
+		Logger: logging.NewNoopLogger(t),
+		Tokens: []controllers.APIToken{
+			{Name: "ci", Token: planToken, Scopes: []string{controllers.PlanScope}},
+			{Name: "deployer", Token: applyToken, Scopes: []string{controllers.ApplyScope}},
+			{Name: "reader", Token: readToken, Scopes: []string{controllers.ReadScope}},
+			{Name: "admin", Token: adminToken, Scopes: []string{controllers.AdminScope}},
+		},
+		Parser:                        parser,
+		SupportedVCSHosts:             []models.VCSHostType{models.Github},
+		RepoAllowlistChecker:          allowlist,
+		PreWorkflowHooksCommandRunner: mocks.NewMockPreWorkflowHooksCommandRunner(),
+		ProjectCommandBuilder:         builder,
+		ProjectCommandRunner:          runner,
+		DeleteLockCommand:             deleteLockCommand,
+		Drainer:                       &events.Drainer{},
+		Jobs:                          jobs.NewStore(jobs.DefaultMaxJobs),
+		RepoMutexes:                   &events.RepoMutexes{},
+		WorkingDir:                    workingDir,
+	}
+	return ac, builder, runner, deleteLockCommand
+}
+
+func apiRequest(t *testing.T, token string, body interface{}) *http.Request {
+	data, err := json.Marshal(body)
+	Ok(t, err)
+	req, _ := http.NewRequest("POST", "/api/v1/plan", bytes.NewReader(data))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+// waitForJob polls the job until it finishes.
+func waitForJob(t *testing.T, ac *controllers.APIController, id string, token string) jobs.Job {
+	for i := 0; i < 100; i++ {
+		req, _ := http.NewRequest("GET", "/api/v1/jobs/"+id, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		w := httptest.NewRecorder()
+		ac.GetJob(w, req)
+		Equals(t, http.StatusOK, w.Result().StatusCode)
+		var job jobs.Job
+		Ok(t, json.NewDecoder(w.Body).Decode(&job))
+		if job.Finished() {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("job didn't finish")
+	return jobs.Job{}
+}
+
+func TestAPIController_Unauthenticated(t *testing.T) {
+	ac, _, _, _ := setupAPIController(t)
+	body := controllers.APIRequest{Repository: "owner/repo", Ref: "main", Projects: []controllers.APIProject{{Dir: "."}}}
+
+	w := httptest.NewRecorder()
+	ac.Plan(w, apiRequest(t, "", body))
+	ResponseContains(t, w, http.StatusUnauthorized, "Missing bearer token")
+
+	w = httptest.NewRecorder()
+	ac.Plan(w, apiRequest(t, "wrong", body))
+	ResponseContains(t, w, http.StatusUnauthorized, "Invalid API token")
+}
+
+func TestAPIController_SetToken(t *testing.T) {
+	ac, _, _, _ := setupAPIController(t)
+	body := controllers.APIRequest{Repository: "owner/repo", Ref: "main", Projects: []controllers.APIProject{{Dir: "."}}}
+	rotated := "rotated-deployer-token"
+	Ok(t, ac.SetToken("deployer", rotated))
+	ErrEquals(t, `no API token named "unknown"`, ac.SetToken("unknown", rotated))
+
+	w := httptest.NewRecorder()
+	ac.Plan(w, apiRequest(t, applyToken, body))
+	ResponseContains(t, w, http.StatusUnauthorized, "Invalid API token")
+
+	// The token is authenticated and only lacks the plan scope.
+	w = httptest.NewRecorder()
+	ac.Plan(w, apiRequest(t, rotated, body))
+	ResponseContains(t, w, http.StatusForbidden, `API token "deployer" doesn't have the plan scope`)
+}
+
+func TestAPIController_MissingScope(t *testing.T) {
+	ac, _, _, _ := setupAPIController(t)
+	body := controllers.APIRequest{Repository: "owner/repo", Ref: "main", Projects: []controllers.APIProject{{Dir: "."}}}
+
+	w := httptest.NewRecorder()
+	ac.Apply(w, apiRequest(t, planToken, body))
+	ResponseContains(t, w, http.StatusForbidden, `API token "ci" doesn't have the apply scope`)
+
+	w = httptest.NewRecorder()
+	ac.Plan(w, apiRequest(t, readToken, body))
+	ResponseContains(t, w, http.StatusForbidden, `API token "reader" doesn't have the plan scope`)
+}
+
+func TestAPIController_InvalidRequest(t *testing.T) {
+	ac, _, _, _ := setupAPIController(t)
+	cases := map[string]struct {
+		body   controllers.APIRequest
+		expErr string
+	}{
+		"no repository": {
+			controllers.APIRequest{Ref: "main", Projects: []controllers.APIProject{{Dir: "."}}},
+			"repository is required",
+		},
+		"no ref": {
+			controllers.APIRequest{Repository: "owner/repo", Projects: []controllers.APIProject{{Dir: "."}}},
+			"ref is required",
+		},
+		"no projects": {
+			controllers.APIRequest{Repository: "owner/repo", Ref: "main"},
+			"at least one project is required",
+		},
+		"empty project": {
+			controllers.APIRequest{Repository: "owner/repo", Ref: "main", Projects: []controllers.APIProject{{Workspace: "staging"}}},
+			"projects must have a name or dir",
+		},
+		"unconfigured vcs": {
+			controllers.APIRequest{VCS: "gitlab", Repository: "owner/repo", Ref: "main", Projects: []controllers.APIProject{{Dir: "."}}},
+			`vcs "gitlab" isn't configured`,
+		},
+		"not allowlisted": {
+			controllers.APIRequest{Repository: "other/repo", Ref: "main", Projects: []controllers.APIProject{{Dir: "."}}},
+			"repo other/repo is not in the allowlist",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ac.Plan(w, apiRequest(t, planToken, c.body))
+			ResponseContains(t, w, http.StatusBadRequest, c.expErr)
+		})
+	}
+}
+
+// Test that repos allowlisted for some paths can only run the projects in
+// them.
+func TestAPIController_PathAllowlist(t *testing.T) {
+	ac, builder, runner, _ := setupAPIController(t)
+	allowlist, err := events.NewRepoAllowlistChecker("github.com/owner/repo:infra/*")
+	Ok(t, err)
+	ac.RepoAllowlistChecker = allowlist
+
+	w := httptest.NewRecorder()
+	ac.Plan(w, apiRequest(t, planToken, controllers.APIRequest{
+		Repository: "owner/repo",
+		Ref:        "main",
+		Projects:   []controllers.APIProject{{Dir: "app"}},
+	}))
+	ResponseContains(t, w, http.StatusBadRequest, `dir "app" of repo owner/repo is not in the allowlist`)
+
+	// Named projects are checked once they're resolved to their dirs.
+	projCtx := models.ProjectCommandContext{RepoRelDir: "app", Workspace: "default", ProjectName: "app"}
+	When(builder.BuildPlanCommands(anyCommandContext(), matchers.AnyPtrToEventsCommentCommand())).
+		ThenReturn([]models.ProjectCommandContext{projCtx}, nil)
+	w = httptest.NewRecorder()
+	ac.Plan(w, apiRequest(t, planToken, controllers.APIRequest{
+		Repository: "owner/repo",
+		Ref:        "main",
+		Projects:   []controllers.APIProject{{Name: "app"}},
+	}))
+	Equals(t, http.StatusAccepted, w.Result().StatusCode)
+	var resp controllers.APIJobResponse
+	Ok(t, json.NewDecoder(w.Body).Decode(&resp))
+	job := waitForJob(t, ac, resp.ID, planToken)
+	Equals(t, jobs.FailedStatus, job.Status)
+	Assert(t, strings.Contains(job.Error, `dir "app" of repo owner/repo is not in the allowlist`), "unexpected error %q", job.Error)
+	runner.VerifyWasCalled(Never()).Plan(matchers.AnyModelsProjectCommandContext())
+}
+
+func TestAPIController_Plan(t *testing.T) {
+	ac, builder, runner, deleteLockCommand := setupAPIController(t)
+	projCtx := models.ProjectCommandContext{RepoRelDir: ".", Workspace: "default"}
+	When(builder.BuildPlanCommands(anyCommandContext(), matchers.AnyPtrToEventsCommentCommand())).
+		ThenReturn([]models.ProjectCommandContext{projCtx}, nil)
+	When(runner.Plan(projCtx)).ThenReturn(models.ProjectResult{
+		RepoRelDir:  ".",
+		Workspace:   "default",
+		PlanSuccess: &models.PlanSuccess{TerraformOutput: "Plan: 1 to add, 0 to change, 0 to destroy."},
+	})
+
+	w := httptest.NewRecorder()
+	ac.Plan(w, apiRequest(t, planToken, controllers.APIRequest{
+		Repository: "owner/repo",
+		Ref:        "main",
+		Projects:   []controllers.APIProject{{Dir: "."}},
+	}))
+	Equals(t, http.StatusAccepted, w.Result().StatusCode)
+	var resp controllers.APIJobResponse
+	Ok(t, json.NewDecoder(w.Body).Decode(&resp))
+	Equals(t, "/api/v1/jobs/"+resp.ID, resp.URL)
+
+	job := waitForJob(t, ac, resp.ID, planToken)
+	Equals(t, jobs.SucceededStatus, job.Status)
+	Equals(t, "plan", job.Command)
+	Equals(t, "owner/repo", job.Repo)
+	Equals(t, "main", job.Ref)
+	Equals(t, "ci", job.CreatedBy)
+	Equals(t, []jobs.ProjectResult{{
+		Dir:       ".",
+		Workspace: "default",
+		Success:   true,
+		Output:    "Plan: 1 to add, 0 to change, 0 to destroy.",
+		Summary:   "Plan: 1 to add, 0 to change, 0 to destroy.",
+	}}, job.Results)
+
+	// The plan runs as a pull request of its own of the ref's commit and its
+	// locks are deleted afterwards.
+	Equals(t, "sha", job.Commit)
+	ctx, _ := builder.VerifyWasCalledOnce().BuildPlanCommands(anyCommandContext(), matchers.AnyPtrToEventsCommentCommand()).GetCapturedArguments()
+	Assert(t, ctx.Pull.Num < 0, "exp a negative pull request number, got %d", ctx.Pull.Num)
+	Equals(t, "main", ctx.Pull.HeadBranch)
+	Equals(t, "sha", ctx.Pull.HeadCommit)
+	Equals(t, "api:ci", ctx.User.Username)
+	deleteLockCommand.VerifyWasCalledOnce().DeleteLocksByPull("owner/repo", ctx.Pull.Num)
+
+	// Other tokens can only read the job with the read scope.
+	req, _ := http.NewRequest("GET", resp.URL, nil)
+	req.Header.Set("Authorization", "Bearer "+applyToken)
+	req = mux.SetURLVars(req, map[string]string{"id": resp.ID})
+	w = httptest.NewRecorder()
+	ac.GetJob(w, req)
+	ResponseContains(t, w, http.StatusNotFound, "No job found")
+	waitForJob(t, ac, resp.ID, readToken)
+}
+
+func TestAPIController_Apply(t *testing.T) {
+	ac, builder, runner, _ := setupAPIController(t)
+	projCtx := models.ProjectCommandContext{ProjectName: "staging", RepoRelDir: "staging", Workspace: "default"}
+	When(builder.BuildPlanCommands(anyCommandContext(), matchers.AnyPtrToEventsCommentCommand())).
+		ThenReturn([]models.ProjectCommandContext{projCtx}, nil)
+	When(builder.BuildApplyCommands(anyCommandContext(), matchers.AnyPtrToEventsCommentCommand())).
+		ThenReturn([]models.ProjectCommandContext{projCtx}, nil)
+	When(runner.Plan(projCtx)).ThenReturn(models.ProjectResult{
+		ProjectName: "staging",
+		PlanSuccess: &models.PlanSuccess{TerraformOutput: "plan"},
+	})
+	When(runner.Apply(projCtx)).ThenReturn(models.ProjectResult{
+		ProjectName: "staging",
+		Error:       errors.New("apply failed"),
+	})
+
+	w := httptest.NewRecorder()
+	ac.Apply(w, apiRequest(t, applyToken, controllers.APIRequest{
+		VCS:        "github",
+		Repository: "owner/repo",
+		Ref:        "main",
+		Projects:   []controllers.APIProject{{Name: "staging"}},
+	}))
+	Equals(t, http.StatusAccepted, w.Result().StatusCode)
+	var resp controllers.APIJobResponse
+	Ok(t, json.NewDecoder(w.Body).Decode(&resp))
+
+	// Only the apply result is recorded since the plan succeeded.
+	job := waitForJob(t, ac, resp.ID, applyToken)
+	Equals(t, jobs.FailedStatus, job.Status)
+	Equals(t, []jobs.ProjectResult{{Project: "staging", Error: "apply failed"}}, job.Results)
+	_, cmd := builder.VerifyWasCalledOnce().BuildApplyCommands(anyCommandContext(), matchers.AnyPtrToEventsCommentCommand()).GetCapturedArguments()
+	Equals(t, "staging", cmd.ProjectName)
+	Equals(t, models.ApplyCommand, cmd.Name)
+}
+
+// Test that each job gets its own pull request number.
+func TestAPIController_UniquePullNums(t *testing.T) {
+	ac, builder, _, _ := setupAPIController(t)
+	When(builder.BuildPlanCommands(anyCommandContext(), matchers.AnyPtrToEventsCommentCommand())).
+		ThenReturn([]models.ProjectCommandContext{}, nil)
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		ac.Plan(w, apiRequest(t, planToken, controllers.APIRequest{
+			Repository: "owner/repo",
+			Ref:        "main",
+			Projects:   []controllers.APIProject{{Dir: "."}},
+		}))
+		var resp controllers.APIJobResponse
+		Ok(t, json.NewDecoder(w.Body).Decode(&resp))
+		waitForJob(t, ac, resp.ID, planToken)
+	}
+	ctxs, _ := builder.VerifyWasCalled(Times(2)).BuildPlanCommands(anyCommandContext(), matchers.AnyPtrToEventsCommentCommand()).GetAllCapturedArguments()
+	Assert(t, ctxs[0].Pull.Num != ctxs[1].Pull.Num, "exp different pull request numbers, got %d twice", ctxs[0].Pull.Num)
+}
+
+// Test that refs that don't exist are rejected.
+func TestAPIController_RefNotFound(t *testing.T) {
+	ac, _, _, _ := setupAPIController(t)
+	w := httptest.NewRecorder()
+	ac.Plan(w, apiRequest(t, planToken, controllers.APIRequest{
+		Repository: "owner/repo",
+		Ref:        "missing",
+		Projects:   []controllers.APIProject{{Dir: "."}},
+	}))
+	ResponseContains(t, w, http.StatusBadRequest, `Invalid request: resolving ref "missing": branch "missing" not found`)
+}
+
+// Test that projects with apply requirements that need a pull request aren't
+// applied.
+func TestAPIController_ApplyPullRequestRequirements(t *testing.T) {
+	ac, builder, runner, _ := setupAPIController(t)
+	projCtx := models.ProjectCommandContext{
+		ProjectName:       "staging",
+		RepoRelDir:        "staging",
+		Workspace:         "default",
+		ApplyRequirements: []string{"all_plans_succeeded", "approved", "mergeable"},
+	}
+	When(builder.BuildPlanCommands(anyCommandContext(), matchers.AnyPtrToEventsCommentCommand())).
+		ThenReturn([]models.ProjectCommandContext{projCtx}, nil)
+	When(builder.BuildApplyCommands(anyCommandContext(), matchers.AnyPtrToEventsCommentCommand())).
+		ThenReturn([]models.ProjectCommandContext{projCtx}, nil)
+	When(runner.Plan(projCtx)).ThenReturn(models.ProjectResult{
+		ProjectName: "staging",
+		PlanSuccess: &models.PlanSuccess{TerraformOutput: "plan"},
+	})
+
+	w := httptest.NewRecorder()
+	ac.Apply(w, apiRequest(t, applyToken, controllers.APIRequest{
+		Repository: "owner/repo",
+		Ref:        "main",
+		Projects:   []controllers.APIProject{{Name: "staging"}},
+	}))
+	var resp controllers.APIJobResponse
+	Ok(t, json.NewDecoder(w.Body).Decode(&resp))
+
+	job := waitForJob(t, ac, resp.ID, applyToken)
+	Equals(t, jobs.FailedStatus, job.Status)
+	Equals(t, []jobs.ProjectResult{{
+		Project:   "staging",
+		Dir:       "staging",
+		Workspace: "default",
+		Failure:   "The project can't be applied through the API since its apply requirements need a pull request: approved, mergeable.",
+	}}, job.Results)
+	runner.VerifyWasCalled(Never()).Apply(matchers.AnyModelsProjectCommandContext())
+}
+
+func TestAPIController_ShuttingDown(t *testing.T) {
+	ac, _, _, _ := setupAPIController(t)
+	ac.Drainer.ShutdownBlocking()
+	w := httptest.NewRecorder()
+	ac.Plan(w, apiRequest(t, planToken, controllers.APIRequest{
+		Repository: "owner/repo",
+		Ref:        "main",
+		Projects:   []controllers.APIProject{{Dir: "."}},
+	}))
+	ResponseContains(t, w, http.StatusServiceUnavailable, "Atlantis is shutting down")
+}
+
+func drainRequest(method string, token string) *http.Request {
+	req, _ := http.NewRequest(method, "/api/v1/drain", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestAPIController_Drain(t *testing.T) {
+	ac, _, _, _ := setupAPIController(t)
+
+	w := httptest.NewRecorder()
+	ac.StartDrain(w, drainRequest("POST", planToken))
+	ResponseContains(t, w, http.StatusForbidden, `API token "ci" doesn't have the admin scope`)
+
+	// An op in progress stops the drain from completing.
+	Equals(t, true, ac.Drainer.StartOp())
+	w = httptest.NewRecorder()
+	ac.StartDrain(w, drainRequest("POST", adminToken))
+	Equals(t, http.StatusAccepted, w.Result().StatusCode)
+	var resp controllers.APIDrainResponse
+	Ok(t, json.NewDecoder(w.Body).Decode(&resp))
+	Equals(t, controllers.APIDrainResponse{Draining: true, InProgressOps: 1}, resp)
+
+	// New jobs are rejected.
+	w = httptest.NewRecorder()
+	ac.Plan(w, apiRequest(t, planToken, controllers.APIRequest{
+		Repository: "owner/repo",
+		Ref:        "main",
+		Projects:   []controllers.APIProject{{Dir: "."}},
+	}))
+	ResponseContains(t, w, http.StatusServiceUnavailable, "Atlantis is draining")
+
+	ac.Drainer.OpDone()
+	w = httptest.NewRecorder()
+	ac.GetDrain(w, drainRequest("GET", adminToken))
+	Equals(t, http.StatusOK, w.Result().StatusCode)
+	Ok(t, json.NewDecoder(w.Body).Decode(&resp))
+	Equals(t, controllers.APIDrainResponse{Draining: true, Complete: true}, resp)
+
+	w = httptest.NewRecorder()
+	ac.StopDrain(w, drainRequest("DELETE", adminToken))
+	Equals(t, http.StatusOK, w.Result().StatusCode)
+	Ok(t, json.NewDecoder(w.Body).Decode(&resp))
+	Equals(t, controllers.APIDrainResponse{}, resp)
+	Equals(t, true, ac.Drainer.StartOp())
+}
+
+func TestAPIController_ReloadRepoConfig(t *testing.T) {
+	ac, _, _, _ := setupAPIController(t)
+	req := func(token string) *http.Request {
+		req, _ := http.NewRequest("POST", "/api/v1/repo-config/reload", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req
+	}
+
+	w := httptest.NewRecorder()
+	ac.ReloadRepoConfigHandler(w, req(adminToken))
+	ResponseContains(t, w, http.StatusNotFound, "There's no server-side repo config file to reload")
+
+	w = httptest.NewRecorder()
+	ac.ReloadRepoConfigHandler(w, req(readToken))
+	ResponseContains(t, w, http.StatusForbidden, `API token "reader" doesn't have the admin scope`)
+
+	ac.ReloadRepoConfig = func() error { return errors.New("invalid apply_requirements") }
+	w = httptest.NewRecorder()
+	ac.ReloadRepoConfigHandler(w, req(adminToken))
+	ResponseContains(t, w, http.StatusBadRequest, "Not reloading the server-side repo config since it's invalid: invalid apply_requirements")
+
+	reloaded := false
+	ac.ReloadRepoConfig = func() error { reloaded = true; return nil }
+	w = httptest.NewRecorder()
+	ac.ReloadRepoConfigHandler(w, req(adminToken))
+	ResponseContains(t, w, http.StatusOK, "Reloaded the server-side repo config")
+	Assert(t, reloaded, "expected reload")
+}
+
+func TestAPIController_Audit(t *testing.T) {
+	ac, _, _, _ := setupAPIController(t)
+	querier := &fakeAuditQuerier{entries: []audit.Entry{{Actor: "alice", Command: "apply", Result: audit.SuccessResult}}}
+	ac.Audit = &controllers.AuditController{Logger: logging.NewNoopLogger(t), Querier: querier}
+	req := func(token string) *http.Request {
+		req, _ := http.NewRequest("GET", "/api/v1/audit?user=alice", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return req
+	}
+
+	w := httptest.NewRecorder()
+	ac.GetAudit(w, req(""))
+	ResponseContains(t, w, http.StatusUnauthorized, "Missing bearer token in Authorization header")
+
+	w = httptest.NewRecorder()
+	ac.GetAudit(w, req(readToken))
+	ResponseContains(t, w, http.StatusForbidden, `API token "reader" doesn't have the admin scope`)
+
+	w = httptest.NewRecorder()
+	ac.GetAudit(w, req(adminToken))
+	Equals(t, http.StatusOK, w.Result().StatusCode)
+	Equals(t, "alice", querier.query.Actor)
+}
+
+func TestAPIController_MaintainBoltDB(t *testing.T) {
+	ac, _, _, _ := setupAPIController(t)
+	req := func(token string) *http.Request {
+		req, _ := http.NewRequest("POST", "/api/v1/boltdb/maintenance", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req
+	}
+
+	w := httptest.NewRecorder()
+	ac.MaintainBoltDB(w, req(adminToken))
+	ResponseContains(t, w, http.StatusNotFound, "There's no BoltDB file to maintain since --locking-db-type isn't boltdb")
+
+	dataDir, cleanup := TempDir(t)
+	defer cleanup()
+	boltDB, err := db.New(dataDir)
+	Ok(t, err)
+	defer boltDB.Close() // nolint: errcheck
+	ac.BoltDB = &events.BoltDBMaintainer{DB: boltDB, Logger: logging.NewNoopLogger(t), Now: time.Now}
+
+	w = httptest.NewRecorder()
+	ac.MaintainBoltDB(w, req(planToken))
+	ResponseContains(t, w, http.StatusForbidden, `API token "ci" doesn't have the admin scope`)
+
+	w = httptest.NewRecorder()
+	ac.MaintainBoltDB(w, req(adminToken))
+	Equals(t, http.StatusOK, w.Result().StatusCode)
+	var report events.BoltDBReport
+	Ok(t, json.NewDecoder(w.Body).Decode(&report))
+	Assert(t, report.Compacted, "exp BoltDB to be compacted")
+	Equals(t, 0, len(report.Problems))
+}
+
+func TestAPIController_MaintenanceWindows(t *testing.T) {
+	ac, _, _, _ := setupAPIController(t)
+	schedule, err := locking.ParseMaintenanceSpec("@daily")
+	Ok(t, err)
+	ac.Maintenance = locking.NewMaintenanceSchedule([]locking.MaintenanceWindow{
+		{Name: "freeze", Spec: "@daily", Schedule: schedule, Duration: 24 * time.Hour, Message: "Release freeze"},
+	})
+	req := func(method string, token string, name string) *http.Request {
+		req, _ := http.NewRequest(method, "/api/v1/maintenance-windows/"+name+"/override", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return mux.SetURLVars(req, map[string]string{"name": name})
+	}
+
+	w := httptest.NewRecorder()
+	ac.ListMaintenanceWindows(w, req("GET", adminToken, ""))
+	Equals(t, http.StatusOK, w.Result().StatusCode)
+	var windows []controllers.APIMaintenanceWindow
+	Ok(t, json.NewDecoder(w.Body).Decode(&windows))
+	Equals(t, 1, len(windows))
+	Equals(t, "freeze", windows[0].Name)
+	Equals(t, "24h0m0s", windows[0].Duration)
+	Equals(t, true, windows[0].Active)
+
+	// Applies are rejected during the window.
+	w = httptest.NewRecorder()
+	ac.Apply(w, apiRequest(t, applyToken, controllers.APIRequest{
+		Repository: "owner/repo",
+		Ref:        "main",
+		Projects:   []controllers.APIProject{{Dir: "."}},
+	}))
+	ResponseContains(t, w, http.StatusConflict, `Applies are disabled during the scheduled maintenance window "freeze"`)
+
+	w = httptest.NewRecorder()
+	ac.OverrideMaintenanceWindow(w, req("POST", applyToken, "freeze"))
+	ResponseContains(t, w, http.StatusForbidden, `API token "deployer" doesn't have the admin scope`)
+
+	w = httptest.NewRecorder()
+	ac.OverrideMaintenanceWindow(w, req("POST", adminToken, "missing"))
+	ResponseContains(t, w, http.StatusNotFound, `no maintenance window named "missing"`)
+
+	w = httptest.NewRecorder()
+	ac.OverrideMaintenanceWindow(w, req("POST", adminToken, "freeze"))
+	ResponseContains(t, w, http.StatusOK, "Applies are allowed until")
+	Equals(t, true, ac.Maintenance.Status()[0].Overridden)
+
+	w = httptest.NewRecorder()
+	ac.CancelMaintenanceWindowOverride(w, req("DELETE", adminToken, "freeze"))
+	ResponseContains(t, w, http.StatusOK, `Cancelled the override of maintenance window "freeze"`)
+	Equals(t, false, ac.Maintenance.Status()[0].Overridden)
+}
+
+func TestAPIController_Plans(t *testing.T) {
+	ac, _, _, _ := setupAPIController(t)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	projectDir := filepath.Join(tmp, "default", "staging")
+	Ok(t, os.MkdirAll(projectDir, 0700))
+	Ok(t, ioutil.WriteFile(filepath.Join(projectDir, "staging-default.tfplan"), []byte("plan"), 0600))
+	workingDir := mocks.NewMockWorkingDir()
+	When(workingDir.GetPullDir(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest())).ThenReturn(tmp, nil)
+	finder := mocks.NewMockPendingPlanFinder()
+	When(finder.Find(tmp)).ThenReturn([]events.PendingPlan{{
+		RepoDir:     filepath.Join(tmp, "default"),
+		RepoRelDir:  "staging",
+		Workspace:   "default",
+		ProjectName: "staging",
+	}}, nil)
+	ac.PlanArtifacts = &events.PlanArtifactReader{
+		WorkingDir:        workingDir,
+		WorkingDirLocker:  events.NewDefaultWorkingDirLocker(),
+		PendingPlanFinder: finder,
+	}
+	get := func(token string, url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		if strings.HasPrefix(url, "/api/v1/plans/download") {
+			ac.DownloadPlan(w, req)
+		} else {
+			ac.ListPlans(w, req)
+		}
+		return w
+	}
+
+	w := get(planToken, "/api/v1/plans?repository=owner/repo&pull=1")
+	ResponseContains(t, w, http.StatusForbidden, `API token "ci" doesn't have the read scope`)
+	w = get(readToken, "/api/v1/plans?repository=owner/repo")
+	ResponseContains(t, w, http.StatusBadRequest, "pull must be a pull request number")
+	w = get(readToken, "/api/v1/plans?repository=other/repo&pull=1")
+	ResponseContains(t, w, http.StatusBadRequest, "repo other/repo is not in the allowlist")
+
+	w = get(readToken, "/api/v1/plans?repository=owner/repo&pull=1")
+	Equals(t, http.StatusOK, w.Result().StatusCode)
+	var plans []controllers.APIPlan
+	Ok(t, json.NewDecoder(w.Body).Decode(&plans))
+	expURL := "/api/v1/plans/download?dir=staging&project=staging&pull=1&repository=owner%2Frepo&workspace=default"
+	Equals(t, []controllers.APIPlan{{
+		Project:   "staging",
+		Dir:       "staging",
+		Workspace: "default",
+		URL:       expURL,
+		JSONURL:   expURL + "&format=json",
+	}}, plans)
+
+	w = get(readToken, expURL)
+	Equals(t, http.StatusOK, w.Result().StatusCode)
+	Equals(t, "application/octet-stream", w.Result().Header.Get("Content-Type"))
+	Equals(t, `attachment; filename="staging-default.tfplan"`, w.Result().Header.Get("Content-Disposition"))
+	Equals(t, "plan", w.Body.String())
+
+	w = get(readToken, "/api/v1/plans/download?repository=owner/repo&pull=1&dir=prod")
+	ResponseContains(t, w, http.StatusNotFound, "No plan found for owner/repo#1")
+	w = get(readToken, expURL+"&format=yaml")
+	ResponseContains(t, w, http.StatusBadRequest, `format must be binary or json, got "yaml"`)
+}
+
+func TestAPIController_Webhooks(t *testing.T) {
+	ac, _, _, _ := setupAPIController(t)
+	req := func(token string, method string, url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		if method == "POST" {
+			req = mux.SetURLVars(req, map[string]string{"id": strings.Split(url, "/")[4]})
+			ac.ReplayWebhookHandler(w, req)
+		} else {
+			ac.ListWebhooks(w, req)
+		}
+		return w
+	}
+
+	w := req(adminToken, "GET", "/api/v1/webhooks")
+	ResponseContains(t, w, http.StatusNotFound, "Webhooks aren't stored since --webhook-replay-retention isn't set")
+
+	ac.Deliveries = deliveries.NewStore(time.Hour, 10)
+	id := ac.Deliveries.Add(deliveries.Delivery{VCS: "github", Event: "issue_comment", DeliveryID: "guid", Body: []byte("{}"), StatusCode: http.StatusInternalServerError, Response: "GitHub is down"})
+	ac.ReplayWebhook = func(replayID string) (int, string, bool) {
+		if replayID != id {
+			return 0, "", false
+		}
+		ac.Deliveries.Replayed(id, http.StatusOK, "Processing...")
+		return http.StatusOK, "Processing...", true
+	}
+
+	w = req(readToken, "GET", "/api/v1/webhooks")
+	ResponseContains(t, w, http.StatusForbidden, `API token "reader" doesn't have the admin scope`)
+	w = req(adminToken, "GET", "/api/v1/webhooks")
+	Equals(t, http.StatusOK, w.Result().StatusCode)
+	var webhooks []controllers.APIWebhook
+	Ok(t, json.NewDecoder(w.Body).Decode(&webhooks))
+	Equals(t, 1, len(webhooks))
+	Equals(t, "guid", webhooks[0].DeliveryID)
+	Equals(t, "GitHub is down", webhooks[0].Response)
+
+	w = req(adminToken, "POST", "/api/v1/webhooks/missing/replay")
+	ResponseContains(t, w, http.StatusNotFound, `No webhook found with id "missing"`)
+	w = req(adminToken, "POST", "/api/v1/webhooks/"+id+"/replay")
+	Equals(t, http.StatusOK, w.Result().StatusCode)
+	var replay controllers.APIReplayResponse
+	Ok(t, json.NewDecoder(w.Body).Decode(&replay))
+	Equals(t, controllers.APIReplayResponse{StatusCode: http.StatusOK, Response: "Processing..."}, replay)
+
+	w = req(adminToken, "GET", "/api/v1/webhooks")
+	webhooks = nil
+	Ok(t, json.NewDecoder(w.Body).Decode(&webhooks))
+	Equals(t, 1, webhooks[0].Replays)
+	Equals(t, http.StatusOK, webhooks[0].StatusCode)
+}
+
+func TestAPIController_Drift(t *testing.T) {
+	ac, _, _, _ := setupAPIController(t)
+	ac.Drift = newDriftDetector(t)
+	req := func(method string, token string, name string) *http.Request {
+		req, _ := http.NewRequest(method, "/api/v1/drift/"+name+"/run", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return mux.SetURLVars(req, map[string]string{"name": name})
+	}
+
+	w := httptest.NewRecorder()
+	ac.RunDriftCheck(w, req("POST", readToken, "prod"))
+	ResponseContains(t, w, http.StatusForbidden, `API token "reader" doesn't have the plan scope`)
+
+	w = httptest.NewRecorder()
+	ac.RunDriftCheck(w, req("POST", planToken, "missing"))
+	ResponseContains(t, w, http.StatusNotFound, `No drift check named "missing"`)
+
+	w = httptest.NewRecorder()
+	ac.RunDriftCheck(w, req("POST", planToken, "prod"))
+	ResponseContains(t, w, http.StatusAccepted, `Started drift check "prod"`)
+
+	var checks []controllers.APIDriftCheck
+	for i := 0; i < 100; i++ {
+		w = httptest.NewRecorder()
+		ac.ListDrift(w, req("GET", readToken, ""))
+		Equals(t, http.StatusOK, w.Result().StatusCode)
+		Ok(t, json.NewDecoder(w.Body).Decode(&checks))
+		if checks[0].LastRun != nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	Equals(t, 1, len(checks))
+	Equals(t, "prod", checks[0].Name)
+	Equals(t, "owner/repo", checks[0].Repository)
+	Equals(t, "main", checks[0].Branch)
+	Equals(t, "@daily", checks[0].Schedule)
+	Assert(t, checks[0].LastRun != nil, "exp drift check to have run")
+	Equals(t, []controllers.APIDriftProjectResult{{
+		Project:   "prod",
+		Dir:       "prod",
+		Workspace: "default",
+		Status:    events.DriftedStatus,
+		Summary:   "Plan: 0 to add, 1 to change, 0 to destroy.",
+		OutputURL: "/jobs/1234",
+		ToChange:  1,
+		CheckedAt: checks[0].Projects[0].CheckedAt,
+	}}, checks[0].Projects)
+	Assert(t, !checks[0].Projects[0].CheckedAt.IsZero(), "exp checked_at to be set")
+
+	w = httptest.NewRecorder()
+	ac.DriftSummary(w, req("GET", readToken, ""))
+	Equals(t, http.StatusOK, w.Result().StatusCode)
+	var summary controllers.APIDriftSummary
+	Ok(t, json.NewDecoder(w.Body).Decode(&summary))
+	Equals(t, controllers.APIDriftSummary{
+		Checks:   1,
+		Projects: 1,
+		Drifted:  1,
+		ToChange: 1,
+		LastRun:  checks[0].LastRun,
+	}, summary)
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/audit"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// AuditController handles requests for the audit log.
+type AuditController struct {
+	Logger logging.SimpleLogging
+	// Querier is used to search the audit log. If nil, the audit log is
+	// either disabled or its store isn't queryable.
+	Querier audit.Querier
+}
+
+// AuditResponse is the response to GET /api/v1/audit.
+type AuditResponse struct {
+	Entries []audit.Entry `json:"entries"`
+}
+
+// Get serves GET /api/v1/audit once the API controller has authenticated
+// the request. It supports the query parameters repo,
+// pull, user, command, project, result, since, until (RFC3339) and limit.
+func (a *AuditController) Get(w http.ResponseWriter, r *http.Request) {
+	if a.Querier == nil {
+		a.respond(w, logging.Warn, http.StatusNotFound, "Audit log is not enabled or its store does not support querying")
+		return
+	}
+
+	q, err := a.parseQuery(r)
+	if err != nil {
+		a.respond(w, logging.Warn, http.StatusBadRequest, "Invalid query: %s", err)
+		return
+	}
+	entries, err := a.Querier.Query(q)
+	if err != nil {
+		a.respond(w, logging.Error, http.StatusInternalServerError, "Querying audit log: %s", err)
+		return
+	}
+	if entries == nil {
+		entries = []audit.Entry{}
+	}
+
+	data, err := json.MarshalIndent(AuditResponse{Entries: entries}, "", "  ")
+	if err != nil {
+		a.respond(w, logging.Error, http.StatusInternalServerError, "Error creating audit json response: %s", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data) // nolint: errcheck
+}
+
+func (a *AuditController) parseQuery(r *http.Request) (audit.Query, error) {
+	params := r.URL.Query()
+	q := audit.Query{
+		Repo:    params.Get("repo"),
+		Actor:   params.Get("user"),
+		Command: params.Get("command"),
+		Project: params.Get("project"),
+		Result:  audit.Result(params.Get("result")),
+	}
+
+	var err error
+	if v := params.Get("pull"); v != "" {
+		if q.PullNum, err = strconv.Atoi(v); err != nil {
+			return q, fmt.Errorf("pull must be a number: %q", v)
+		}
+	}
+	if v := params.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 0 {
+			return q, fmt.Errorf("limit must be a positive number: %q", v)
+		}
+	}
+	if v := params.Get("since"); v != "" {
+		if q.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return q, fmt.Errorf("since must be an RFC3339 timestamp: %q", v)
+		}
+	}
+	if v := params.Get("until"); v != "" {
+		if q.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return q, fmt.Errorf("until must be an RFC3339 timestamp: %q", v)
+		}
+	}
+	return q, nil
+}
+
+// respond is a helper function to respond and log the response. lvl is the log
+// level to log at, code is the HTTP response code.
+func (a *AuditController) respond(w http.ResponseWriter, lvl logging.LogLevel, responseCode int, format string, args ...interface{}) {
+	response := fmt.Sprintf(format, args...)
+	a.Logger.Log(lvl, response)
+	w.WriteHeader(responseCode)
+	fmt.Fprintln(w, response)
+}
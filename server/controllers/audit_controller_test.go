@@ -0,0 +1,79 @@
+package controllers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/controllers"
+	"github.com/runatlantis/atlantis/server/events/audit"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+type fakeAuditQuerier struct {
+	query   audit.Query
+	entries []audit.Entry
+	err     error
+}
+
+func (f *fakeAuditQuerier) Query(q audit.Query) ([]audit.Entry, error) {
+	f.query = q
+	return f.entries, f.err
+}
+
+func TestAuditController_Get_NotEnabled(t *testing.T) {
+	ac := controllers.AuditController{Logger: logging.NewNoopLogger(t)}
+	req, _ := http.NewRequest("GET", "/api/v1/audit", nil)
+	w := httptest.NewRecorder()
+	ac.Get(w, req)
+	ResponseContains(t, w, http.StatusNotFound, "Audit log is not enabled")
+}
+
+func TestAuditController_Get_InvalidQuery(t *testing.T) {
+	ac := controllers.AuditController{Logger: logging.NewNoopLogger(t), Querier: &fakeAuditQuerier{}}
+	for _, q := range []string{"pull=abc", "limit=-1", "since=yesterday"} {
+		t.Run(q, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/api/v1/audit?"+q, nil)
+			w := httptest.NewRecorder()
+			ac.Get(w, req)
+			ResponseContains(t, w, http.StatusBadRequest, "Invalid query")
+		})
+	}
+}
+
+func TestAuditController_Get_QueryErr(t *testing.T) {
+	ac := controllers.AuditController{Logger: logging.NewNoopLogger(t), Querier: &fakeAuditQuerier{err: errors.New("err")}}
+	req, _ := http.NewRequest("GET", "/api/v1/audit", nil)
+	w := httptest.NewRecorder()
+	ac.Get(w, req)
+	ResponseContains(t, w, http.StatusInternalServerError, "Querying audit log: err")
+}
+
+func TestAuditController_Get(t *testing.T) {
+	entry := audit.Entry{Actor: "alice", Repo: "owner/repo", PullNum: 1, Command: "apply", Result: audit.SuccessResult}
+	querier := &fakeAuditQuerier{entries: []audit.Entry{entry}}
+	ac := controllers.AuditController{Logger: logging.NewNoopLogger(t), Querier: querier}
+	req, _ := http.NewRequest("GET", "/api/v1/audit?repo=owner/repo&pull=1&user=alice&command=apply&result=success&since=2021-01-01T00:00:00Z&limit=10", nil)
+	w := httptest.NewRecorder()
+	ac.Get(w, req)
+
+	Equals(t, http.StatusOK, w.Result().StatusCode)
+	Equals(t, "application/json", w.Result().Header.Get("Content-Type"))
+	Equals(t, audit.Query{
+		Repo:    "owner/repo",
+		PullNum: 1,
+		Actor:   "alice",
+		Command: "apply",
+		Result:  audit.SuccessResult,
+		Since:   time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		Limit:   10,
+	}, querier.query)
+
+	var resp controllers.AuditResponse
+	Ok(t, json.NewDecoder(w.Body).Decode(&resp))
+	Equals(t, []audit.Entry{entry}, resp.Entries)
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/runatlantis/atlantis/server/controllers/templates"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// DriftController renders the dashboard of drift checks.
+type DriftController struct {
+	AtlantisVersion string
+	AtlantisURL     *url.URL
+	Logger          logging.SimpleLogging
+	// Drift runs the drift checks. If nil, there are none.
+	Drift         *events.DriftDetector
+	DriftTemplate templates.TemplateWriter
+}
+
+// Index is the GET /drift route. It lists the drift checks along with the
+// results of their last run.
+func (d *DriftController) Index(w http.ResponseWriter, r *http.Request) {
+	data := templates.DriftIndexData{
+		AtlantisVersion: d.AtlantisVersion,
+		CleanedBasePath: d.AtlantisURL.Path,
+	}
+	if d.Drift != nil {
+		summary := d.Drift.Summary()
+		data.Summary = templates.DriftSummaryData{
+			Projects:  summary.Projects,
+			Drifted:   summary.Drifted,
+			Errored:   summary.Errored,
+			ToAdd:     summary.ToAdd,
+			ToChange:  summary.ToChange,
+			ToDestroy: summary.ToDestroy,
+		}
+		if !summary.LastRun.IsZero() {
+			data.Summary.LastRunFormatted = summary.LastRun.Format("02-01-2006 15:04:05")
+		}
+		for _, s := range d.Drift.Status() {
+			check := templates.DriftCheckData{
+				Name:             s.Name,
+				RepoFullName:     s.Repo.FullName,
+				Branch:           s.Branch,
+				Schedule:         s.Spec,
+				Running:          s.Running,
+				NextRunFormatted: s.NextRun.Format("02-01-2006 15:04:05"),
+			}
+			if !s.LastRun.IsZero() {
+				check.LastRunFormatted = s.LastRun.Format("02-01-2006 15:04:05")
+			}
+			for _, r := range s.Results {
+				if r.Status == events.DriftedStatus {
+					check.Drifted++
+				}
+				check.Projects = append(check.Projects, templates.DriftProjectData{
+					ProjectName:      r.ProjectName,
+					Path:             r.Dir,
+					Workspace:        r.Workspace,
+					Status:           r.Status,
+					Summary:          r.Summary,
+					Error:            r.Error,
+					LogURL:           r.OutputURL,
+					IssueURL:         r.IssueURL,
+					CheckedFormatted: r.CheckedAt.Format("02-01-2006 15:04:05"),
+				})
+			}
+			data.Checks = append(data.Checks, check)
+		}
+	}
+	if err := d.DriftTemplate.Execute(w, data); err != nil {
+		d.Logger.Err(err.Error())
+	}
+}
@@ -0,0 +1,108 @@
+package controllers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/controllers"
+	"github.com/runatlantis/atlantis/server/controllers/templates"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/locking"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// newDriftDetector returns a detector with a "prod" check whose project has
+// drifted once it's run.
+func newDriftDetector(t *testing.T) *events.DriftDetector {
+	RegisterMockTestingT(t)
+	builder := mocks.NewMockProjectCommandBuilder()
+	runner := mocks.NewMockProjectCommandRunner()
+	projCtx := models.ProjectCommandContext{ProjectName: "prod", RepoRelDir: "prod", Workspace: "default"}
+	When(builder.BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())).
+		ThenReturn([]models.ProjectCommandContext{projCtx}, nil)
+	When(runner.Plan(projCtx)).ThenReturn(models.ProjectResult{
+		ProjectName: "prod",
+		RepoRelDir:  "prod",
+		Workspace:   "default",
+		PlanSuccess: &models.PlanSuccess{TerraformOutput: "Plan: 0 to add, 1 to change, 0 to destroy."},
+		OutputURL:   "/jobs/1234",
+	})
+	schedule, err := locking.ParseMaintenanceSpec("@daily")
+	Ok(t, err)
+	return &events.DriftDetector{
+		Checks: []events.DriftCheck{{
+			Name:     "prod",
+			Spec:     "@daily",
+			Schedule: schedule,
+			Repo:     models.Repo{FullName: "owner/repo"},
+			Branch:   "main",
+			Projects: []events.DriftProject{{Name: "prod"}},
+		}},
+		PreWorkflowHooksCommandRunner: mocks.NewMockPreWorkflowHooksCommandRunner(),
+		ProjectCommandBuilder:         builder,
+		ProjectCommandRunner:          runner,
+		DeleteLockCommand:             mocks.NewMockDeleteLockCommand(),
+		Drainer:                       &events.Drainer{},
+		RepoMutexes:                   &events.RepoMutexes{},
+		Logger:                        logging.NewNoopLogger(t),
+		Now:                           time.Now,
+	}
+}
+
+func setupDriftController(t *testing.T, drift *events.DriftDetector) controllers.DriftController {
+	atlantisURL, err := url.Parse("https://example.com/basepath")
+	Ok(t, err)
+	return controllers.DriftController{
+		AtlantisVersion: "1.0.0",
+		AtlantisURL:     atlantisURL,
+		Logger:          logging.NewNoopLogger(t),
+		Drift:           drift,
+		DriftTemplate:   templates.DriftTemplate,
+	}
+}
+
+func TestDriftIndex_NoChecks(t *testing.T) {
+	dc := setupDriftController(t, nil)
+	req, _ := http.NewRequest("GET", "/drift", nil)
+	w := httptest.NewRecorder()
+	dc.Index(w, req)
+	ResponseContains(t, w, http.StatusOK, "No drift checks are configured.")
+}
+
+func TestDriftIndex_NotRun(t *testing.T) {
+	dc := setupDriftController(t, newDriftDetector(t))
+	req, _ := http.NewRequest("GET", "/drift", nil)
+	w := httptest.NewRecorder()
+	dc.Index(w, req)
+	ResponseContains(t, w, http.StatusOK, "No drift check has run yet.")
+}
+
+func TestDriftIndex_Results(t *testing.T) {
+	drift := newDriftDetector(t)
+	Ok(t, drift.Detect("prod"))
+	dc := setupDriftController(t, drift)
+	req, _ := http.NewRequest("GET", "/drift", nil)
+	w := httptest.NewRecorder()
+	dc.Index(w, req)
+	Equals(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	for _, exp := range []string{
+		"<strong>1 of 1 projects drifted</strong>:",
+		"0 resources to add, 1 to change and 0 to destroy.",
+		"1 of 1 projects drifted.",
+		"<code>drifted</code>",
+		"Plan: 0 to add, 1 to change, 0 to destroy.",
+		`<a href="/jobs/1234">view</a>`,
+	} {
+		Assert(t, strings.Contains(body, exp), "expected %q in %q", exp, body)
+	}
+}
@@ -14,25 +14,38 @@
 package events
 
 import (
+	"bytes"
+	"context"
+	"crypto/subtle"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/go-github/v31/github"
 	"github.com/mcdafydd/go-azuredevops/azuredevops"
 	"github.com/microcosm-cc/bluemonday"
 	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/deliveries"
 	"github.com/runatlantis/atlantis/server/events"
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/vcs"
 	"github.com/runatlantis/atlantis/server/events/vcs/bitbucketcloud"
 	"github.com/runatlantis/atlantis/server/events/vcs/bitbucketserver"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/metrics"
+	"github.com/runatlantis/atlantis/server/ratelimit"
+	"github.com/runatlantis/atlantis/server/tracing"
+	"github.com/runatlantis/atlantis/server/workerpool"
 	gitlab "github.com/xanzy/go-gitlab"
 )
 
 const githubHeader = "X-Github-Event"
+const githubDeliveryHeader = "X-Github-Delivery"
 const gitlabHeader = "X-Gitlab-Event"
 const azuredevopsHeader = "Request-Id"
 
@@ -41,6 +54,13 @@ const bitbucketEventTypeHeader = "X-Event-Key"
 const bitbucketCloudRequestIDHeader = "X-Request-UUID"
 const bitbucketServerRequestIDHeader = "X-Request-ID"
 const bitbucketServerSignatureHeader = "X-Hub-Signature"
+const bitbucketCloudSignatureHeader = "X-Hub-Signature"
+const azuredevopsWebhookSecretHeader = "X-Atlantis-Webhook-Secret"
+
+// webhookQueueRetryAfterSecs is how long VCS hosts are asked to wait before
+// retrying webhooks that were rejected because the worker pool's queue was
+// full.
+const webhookQueueRetryAfterSecs = 60
 
 // VCSEventsController handles all webhook requests which signify 'events' in the
 // VCS host, ex. GitHub.
@@ -50,7 +70,10 @@ type VCSEventsController struct {
 	Logger        logging.SimpleLogging
 	Parser        events.EventParsing
 	CommentParser events.CommentParsing
-	ApplyDisabled bool
+	// HelpCommentRenderer renders the response to atlantis help for the
+	// commenting user and repo. If nil, the default help is commented.
+	HelpCommentRenderer *events.HelpCommentRenderer
+	ApplyDisabled       bool
 	// GithubWebhookSecret is the secret added to this webhook via the GitHub
 	// UI that identifies this call as coming from GitHub. If empty, no
 	// request validation is done.
@@ -71,8 +94,9 @@ type VCSEventsController struct {
 	VCSClient         vcs.Client
 	TestingMode       bool
 	// BitbucketWebhookSecret is the secret added to this webhook via the Bitbucket
-	// UI that identifies this call as coming from Bitbucket. If empty, no
-	// request validation is done.
+	// UI that identifies this call as coming from Bitbucket. Both Bitbucket
+	// Cloud and Server use it to sign requests. If empty, no request
+	// validation is done.
 	BitbucketWebhookSecret []byte
 	// AzureDevopsWebhookUser is the Basic authentication username added to this
 	// webhook via the Azure DevOps UI that identifies this call as coming from your
@@ -83,11 +107,141 @@ type VCSEventsController struct {
 	// webhook via the Azure DevOps UI that identifies this call as coming from your
 	// Azure DevOps Team Project. If empty, no request validation is done.
 	AzureDevopsWebhookBasicPassword []byte
-	AzureDevopsRequestValidator     AzureDevopsRequestValidator
+	// AzureDevopsWebhookSecret is a shared secret that's sent in the
+	// X-Atlantis-Webhook-Secret header, configured as a custom HTTP header on
+	// the Azure DevOps service hook subscription. Unlike the other hosts'
+	// secrets it doesn't sign the payload, the header is just compared to it.
+	// If empty, the header isn't checked.
+	AzureDevopsWebhookSecret    []byte
+	AzureDevopsRequestValidator AzureDevopsRequestValidator
+	// AzureDevopsOrgWebhooks maps lowercase Azure DevOps organizations to the
+	// credentials of their webhooks, which replace the ones above for
+	// requests about their repos.
+	AzureDevopsOrgWebhooks map[string]AzureDevopsWebhookAuth
+	// AzureDevopsStatuses re-posts the Atlantis statuses of Azure DevOps pull
+	// requests on the iterations created by new pushes. If nil, they aren't.
+	AzureDevopsStatuses *events.AzureDevopsStatuses
+	// RejectedWebhooks counts the requests that failed validation, by VCS
+	// host type. If nil, rejections aren't counted.
+	RejectedWebhooks *metrics.Counters
+	// GlobalRateLimiter limits the webhooks that run commands across all
+	// repos. If nil, they aren't limited.
+	GlobalRateLimiter *ratelimit.Limiter
+	// RepoRateLimiter limits the webhooks that run commands for each repo. If
+	// nil, they aren't limited.
+	RepoRateLimiter *ratelimit.Limiter
+	// RateLimitedWebhooks counts the requests that were rate limited, by repo
+	// or "global" for the global limit. If nil, they aren't counted.
+	RateLimitedWebhooks *metrics.Counters
+	// Tracer records a span for each pull request and comment event. The
+	// commands they trigger are recorded under it. If nil, events aren't
+	// traced.
+	Tracer *tracing.Tracer
+	// Deliveries stores the webhooks received so they can be replayed. If
+	// nil, they aren't stored.
+	Deliveries *deliveries.Store
+	// WorkerPool runs the commands triggered by webhooks. If nil, each runs
+	// in its own goroutine.
+	WorkerPool *workerpool.Pool
+
+	// secretsMutex guards the webhook secrets, which can be rotated while
+	// requests are handled.
+	secretsMutex sync.RWMutex
+}
+
+// UpdateWebhookSecrets runs update, which replaces some of the webhook
+// secrets or organization webhooks of e, ex. when they're rotated in their
+// secret store. It's safe to call while requests are handled.
+func (e *VCSEventsController) UpdateWebhookSecrets(update func()) {
+	e.secretsMutex.Lock()
+	defer e.secretsMutex.Unlock()
+	update()
+}
+
+// webhookSecret returns the current value of secret, one of e's webhook
+// secrets.
+func (e *VCSEventsController) webhookSecret(secret *[]byte) []byte {
+	e.secretsMutex.RLock()
+	defer e.secretsMutex.RUnlock()
+	return *secret
+}
+
+// AzureDevopsWebhookAuth is the Basic authentication username and password
+// and the shared secret of the webhooks of an Azure DevOps organization. If
+// they're empty, the requests aren't checked.
+type AzureDevopsWebhookAuth struct {
+	BasicUser     []byte
+	BasicPassword []byte
+	Secret        []byte
 }
 
 // Post handles POST webhook requests.
 func (e *VCSEventsController) Post(w http.ResponseWriter, r *http.Request) {
+	if e.Deliveries == nil {
+		e.handlePost(w, r)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		e.respond(w, logging.Warn, http.StatusBadRequest, "Unable to read body: %s", err)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	rec := &responseRecorder{w: w}
+	e.handlePost(rec, r)
+
+	vcsHost, event, deliveryID := deliveryInfo(r.Header)
+	e.Deliveries.Add(deliveries.Delivery{
+		VCS:        vcsHost,
+		Event:      event,
+		DeliveryID: deliveryID,
+		Header:     r.Header.Clone(),
+		Body:       body,
+		StatusCode: rec.statusCode(),
+		Response:   rec.body.String(),
+	})
+}
+
+// Replay handles the webhook delivery with id again as if it was just
+// received. Its signature or secret is validated again. It returns what
+// Atlantis responded with and false if there's no delivery with id.
+func (e *VCSEventsController) Replay(id string) (int, string, bool) {
+	d, ok := e.Deliveries.Get(id)
+	if !ok {
+		return 0, "", false
+	}
+	r, err := http.NewRequest(http.MethodPost, "/events", bytes.NewReader(d.Body))
+	if err != nil {
+		return http.StatusInternalServerError, err.Error(), true
+	}
+	r.Header = d.Header.Clone()
+	e.Logger.Info("replaying webhook %s received at %s", id, d.ReceivedAt.Format(time.RFC3339))
+	rec := &responseRecorder{}
+	e.handlePost(rec, r)
+	e.Deliveries.Replayed(id, rec.statusCode(), rec.body.String())
+	return rec.statusCode(), rec.body.String(), true
+}
+
+// deliveryInfo returns the VCS host, event type and delivery id of a webhook
+// from its headers.
+func deliveryInfo(h http.Header) (string, string, string) {
+	switch {
+	case h.Get(githubHeader) != "":
+		return "github", h.Get(githubHeader), h.Get(githubDeliveryHeader)
+	case h.Get(gitlabHeader) != "":
+		return "gitlab", h.Get(gitlabHeader), ""
+	case h.Get(bitbucketEventTypeHeader) != "" && h.Get(bitbucketCloudRequestIDHeader) != "":
+		return "bitbucket-cloud", h.Get(bitbucketEventTypeHeader), h.Get(bitbucketCloudRequestIDHeader)
+	case h.Get(bitbucketEventTypeHeader) != "":
+		return "bitbucket-server", h.Get(bitbucketEventTypeHeader), h.Get(bitbucketServerRequestIDHeader)
+	case h.Get(azuredevopsHeader) != "":
+		return "azuredevops", "", h.Get(azuredevopsHeader)
+	}
+	return "", "", ""
+}
+
+// handlePost handles a webhook request. It's used by both Post and Replay.
+func (e *VCSEventsController) handlePost(w http.ResponseWriter, r *http.Request) {
 	if r.Header.Get(githubHeader) != "" {
 		if !e.supportsHost(models.Github) {
 			e.respond(w, logging.Debug, http.StatusBadRequest, "Ignoring request since not configured to support GitHub")
@@ -138,8 +292,9 @@ func (e *VCSEventsController) Post(w http.ResponseWriter, r *http.Request) {
 
 func (e *VCSEventsController) handleGithubPost(w http.ResponseWriter, r *http.Request) {
 	// Validate the request against the optional webhook secret.
-	payload, err := e.GithubRequestValidator.Validate(r, e.GithubWebhookSecret)
+	payload, err := e.GithubRequestValidator.Validate(r, e.webhookSecret(&e.GithubWebhookSecret))
 	if err != nil {
+		e.reject(models.Github)
 		e.respond(w, logging.Warn, http.StatusBadRequest, err.Error())
 		return
 	}
@@ -168,6 +323,14 @@ func (e *VCSEventsController) handleBitbucketCloudPost(w http.ResponseWriter, r
 		e.respond(w, logging.Error, http.StatusBadRequest, "Unable to read body: %s %s=%s", err, bitbucketCloudRequestIDHeader, reqID)
 		return
 	}
+	if secret := e.webhookSecret(&e.BitbucketWebhookSecret); len(secret) > 0 {
+		// Bitbucket Cloud signs requests the same way as Bitbucket Server.
+		if err := bitbucketserver.ValidateSignature(body, r.Header.Get(bitbucketCloudSignatureHeader), secret); err != nil {
+			e.reject(models.BitbucketCloud)
+			e.respond(w, logging.Warn, http.StatusBadRequest, "%s %s=%s", errors.Wrap(err, "request did not pass validation"), bitbucketCloudRequestIDHeader, reqID)
+			return
+		}
+	}
 	switch eventType {
 	case bitbucketcloud.PullCreatedHeader, bitbucketcloud.PullUpdatedHeader, bitbucketcloud.PullFulfilledHeader, bitbucketcloud.PullRejectedHeader:
 		e.Logger.Debug("handling as pull request state changed event")
@@ -198,8 +361,9 @@ func (e *VCSEventsController) handleBitbucketServerPost(w http.ResponseWriter, r
 		e.respond(w, logging.Info, http.StatusOK, "Successfully received %s event %s=%s", eventType, bitbucketServerRequestIDHeader, reqID)
 		return
 	}
-	if len(e.BitbucketWebhookSecret) > 0 {
-		if err := bitbucketserver.ValidateSignature(body, sig, e.BitbucketWebhookSecret); err != nil {
+	if secret := e.webhookSecret(&e.BitbucketWebhookSecret); len(secret) > 0 {
+		if err := bitbucketserver.ValidateSignature(body, sig, secret); err != nil {
+			e.reject(models.BitbucketServer)
 			e.respond(w, logging.Warn, http.StatusBadRequest, errors.Wrap(err, "request did not pass validation").Error())
 			return
 		}
@@ -219,10 +383,24 @@ func (e *VCSEventsController) handleBitbucketServerPost(w http.ResponseWriter, r
 }
 
 func (e *VCSEventsController) handleAzureDevopsPost(w http.ResponseWriter, r *http.Request) {
+	auth, err := e.azureDevopsWebhookAuth(r)
+	if err != nil {
+		e.respond(w, logging.Warn, http.StatusBadRequest, "Unable to read body: %s", err)
+		return
+	}
+	// Validate the request against the optional shared secret header.
+	if len(auth.Secret) > 0 {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(azuredevopsWebhookSecretHeader)), auth.Secret) != 1 {
+			e.reject(models.AzureDevops)
+			e.respond(w, logging.Warn, http.StatusBadRequest, "request did not pass validation: %s header is missing or incorrect", azuredevopsWebhookSecretHeader)
+			return
+		}
+	}
 	// Validate the request against the optional basic auth username and password.
-	payload, err := e.AzureDevopsRequestValidator.Validate(r, e.AzureDevopsWebhookBasicUser, e.AzureDevopsWebhookBasicPassword)
+	payload, err := e.AzureDevopsRequestValidator.Validate(r, auth.BasicUser, auth.BasicPassword)
 	if err != nil {
-		e.respond(w, logging.Warn, http.StatusUnauthorized, err.Error())
+		e.reject(models.AzureDevops)
+		e.respond(w, logging.Warn, http.StatusBadRequest, err.Error())
 		return
 	}
 	e.Logger.Debug("request valid")
@@ -245,6 +423,55 @@ func (e *VCSEventsController) handleAzureDevopsPost(w http.ResponseWriter, r *ht
 	}
 }
 
+// azureDevopsWebhookAuth returns the webhook credentials of the organization
+// of the repo of the request r. Since they're needed to validate r, the
+// organization is read from r's body before it's validated. If it can't be,
+// or it has no credentials of its own, the top-level ones are returned.
+func (e *VCSEventsController) azureDevopsWebhookAuth(r *http.Request) (AzureDevopsWebhookAuth, error) {
+	e.secretsMutex.RLock()
+	auth := AzureDevopsWebhookAuth{
+		BasicUser:     e.AzureDevopsWebhookBasicUser,
+		BasicPassword: e.AzureDevopsWebhookBasicPassword,
+		Secret:        e.AzureDevopsWebhookSecret,
+	}
+	hasOrgs := len(e.AzureDevopsOrgWebhooks) > 0
+	e.secretsMutex.RUnlock()
+	if !hasOrgs {
+		return auth, nil
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return auth, err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	event, err := azuredevops.ParseWebHook(body)
+	if err != nil {
+		return auth, nil
+	}
+	var adRepo *azuredevops.GitRepository
+	switch resource := event.Resource.(type) {
+	case *azuredevops.GitPullRequestWithComment:
+		adRepo = resource.PullRequest.GetRepository()
+	case *azuredevops.GitPullRequest:
+		adRepo = resource.GetRepository()
+	}
+	if adRepo == nil {
+		return auth, nil
+	}
+	repo, err := e.Parser.ParseAzureDevopsRepo(adRepo)
+	if err != nil {
+		return auth, nil
+	}
+	org, _, _ := vcs.SplitAzureDevopsRepoFullName(repo.FullName)
+	e.secretsMutex.RLock()
+	defer e.secretsMutex.RUnlock()
+	if orgAuth, ok := e.AzureDevopsOrgWebhooks[strings.ToLower(org)]; ok {
+		return orgAuth, nil
+	}
+	return auth, nil
+}
+
 // HandleGithubCommentEvent handles comment events from GitHub where Atlantis
 // commands can come from. It's exported to make testing easier.
 func (e *VCSEventsController) HandleGithubCommentEvent(w http.ResponseWriter, event *github.IssueCommentEvent, githubReqID string) {
@@ -320,6 +547,8 @@ func (e *VCSEventsController) HandleGithubPullRequestEvent(w http.ResponseWriter
 }
 
 func (e *VCSEventsController) handlePullRequestEvent(w http.ResponseWriter, baseRepo models.Repo, headRepo models.Repo, pull models.PullRequest, user models.User, eventType models.PullRequestEventType) {
+	reqCtx, span, log := e.startEvent("webhook pull request", baseRepo, pull.Num, user)
+	defer span.End()
 	if !e.RepoAllowlistChecker.IsAllowlisted(baseRepo.FullName, baseRepo.VCSHost.Hostname) {
 		// If the repo isn't allowlisted and we receive an opened pull request
 		// event we comment back on the pull request that the repo isn't
@@ -336,20 +565,31 @@ func (e *VCSEventsController) handlePullRequestEvent(w http.ResponseWriter, base
 
 	switch eventType {
 	case models.OpenedPullEvent, models.UpdatedPullEvent:
+		if eventType == models.UpdatedPullEvent {
+			// Statuses are propagated even if autoplan doesn't run so branch
+			// policies don't show the previous commits' results.
+			e.AzureDevopsStatuses.Propagate(log, baseRepo, pull)
+		}
 		// If the pull request was opened or updated, we will try to autoplan.
-
-		// Respond with success and then actually execute the command asynchronously.
-		// We use a goroutine so that this function returns and the connection is
-		// closed.
-		fmt.Fprintln(w, "Processing...")
-
-		e.Logger.Info("executing autoplan")
-		if !e.TestingMode {
-			go e.CommandRunner.RunAutoplanCommand(baseRepo, headRepo, pull, user)
-		} else {
-			// When testing we want to wait for everything to complete.
-			e.CommandRunner.RunAutoplanCommand(baseRepo, headRepo, pull, user)
+		allowlisted, err := e.modifiesAllowlistedPaths(baseRepo, pull)
+		if err != nil {
+			e.respond(w, logging.Error, http.StatusInternalServerError, "Error getting modified files: %s", err)
+			return
 		}
+		if !allowlisted {
+			e.respond(w, logging.Debug, http.StatusOK,
+				"Ignoring pull request event that doesn't modify allowlisted paths of repo \"%s/%s\"",
+				baseRepo.VCSHost.Hostname, baseRepo.FullName)
+			return
+		}
+		if e.rateLimited(w, baseRepo) {
+			return
+		}
+
+		log.Info("executing autoplan")
+		e.runAsync(w, func() {
+			e.CommandRunner.RunAutoplanCommand(reqCtx, baseRepo, headRepo, pull, user)
+		})
 		return
 	case models.ClosedPullEvent:
 		// If the pull request was closed, we delete locks.
@@ -357,7 +597,7 @@ func (e *VCSEventsController) handlePullRequestEvent(w http.ResponseWriter, base
 			e.respond(w, logging.Error, http.StatusInternalServerError, "Error cleaning pull request: %s", err)
 			return
 		}
-		e.Logger.Info("deleted locks and workspace for repo %s, pull %d", baseRepo.FullName, pull.Num)
+		log.Info("deleted locks and workspace for repo %s, pull %d", baseRepo.FullName, pull.Num)
 		fmt.Fprintln(w, "Pull request cleaned successfully")
 		return
 	case models.OtherPullEvent:
@@ -368,8 +608,9 @@ func (e *VCSEventsController) handlePullRequestEvent(w http.ResponseWriter, base
 }
 
 func (e *VCSEventsController) handleGitlabPost(w http.ResponseWriter, r *http.Request) {
-	event, err := e.GitlabRequestParserValidator.ParseAndValidate(r, e.GitlabWebhookSecret)
+	event, err := e.GitlabRequestParserValidator.ParseAndValidate(r, e.webhookSecret(&e.GitlabWebhookSecret))
 	if err != nil {
+		e.reject(models.Gitlab)
 		e.respond(w, logging.Warn, http.StatusBadRequest, err.Error())
 		return
 	}
@@ -414,7 +655,9 @@ func (e *VCSEventsController) handleCommentEvent(w http.ResponseWriter, baseRepo
 		e.respond(w, logging.Debug, http.StatusOK, "Ignoring non-command comment: %q", truncated)
 		return
 	}
-	e.Logger.Info("parsed comment as %s", parseResult.Command)
+	reqCtx, span, log := e.startEvent("webhook comment", baseRepo, pullNum, user)
+	defer span.End()
+	log.Info("parsed comment as %s", parseResult.Command)
 
 	// At this point we know it's a command we're not supposed to ignore, so now
 	// we check if this repo is allowed to run commands in the first place.
@@ -423,30 +666,82 @@ func (e *VCSEventsController) handleCommentEvent(w http.ResponseWriter, baseRepo
 		e.respond(w, logging.Warn, http.StatusForbidden, "Repo not allowlisted")
 		return
 	}
+	pull := models.PullRequest{Num: pullNum, BaseRepo: baseRepo}
+	if maybePull != nil {
+		pull = *maybePull
+	}
+	allowlisted, err := e.modifiesAllowlistedPaths(baseRepo, pull)
+	if err != nil {
+		e.respond(w, logging.Error, http.StatusInternalServerError, "Error getting modified files: %s", err)
+		return
+	}
+	if !allowlisted {
+		e.commentPathsNotAllowlisted(baseRepo, pullNum)
+		e.respond(w, logging.Warn, http.StatusForbidden, "Pull request doesn't modify allowlisted paths")
+		return
+	}
+	if e.rateLimited(w, baseRepo) {
+		return
+	}
 
 	// If the command isn't valid or doesn't require processing, ex.
 	// "atlantis help" then we just comment back immediately.
 	// We do this here rather than earlier because we need access to the pull
 	// variable to comment back on the pull request.
 	if parseResult.CommentResponse != "" {
-		if err := e.VCSClient.CreateComment(baseRepo, pullNum, parseResult.CommentResponse, ""); err != nil {
-			e.Logger.Err("unable to comment on pull request: %s", err)
+		response := parseResult.CommentResponse
+		if parseResult.Help && e.HelpCommentRenderer != nil {
+			response = e.HelpCommentRenderer.Render(log, baseRepo, user)
+		}
+		if err := e.VCSClient.CreateComment(baseRepo, pullNum, response, ""); err != nil {
+			log.Err("unable to comment on pull request: %s", err)
 		}
 		e.respond(w, logging.Info, http.StatusOK, "Commenting back on pull request")
 		return
 	}
 
-	e.Logger.Debug("executing command")
-	fmt.Fprintln(w, "Processing...")
-	if !e.TestingMode {
-		// Respond with success and then actually execute the command asynchronously.
-		// We use a goroutine so that this function returns and the connection is
-		// closed.
-		go e.CommandRunner.RunCommentCommand(baseRepo, maybeHeadRepo, maybePull, user, pullNum, parseResult.Command)
-	} else {
+	log.Debug("executing command")
+	e.runAsync(w, func() {
+		e.CommandRunner.RunCommentCommand(reqCtx, baseRepo, maybeHeadRepo, maybePull, user, pullNum, parseResult.Command)
+	})
+}
+
+// runAsync responds with success and then actually executes the command run
+// by fn asynchronously on the worker pool so that the connection is closed.
+// If the pool's queue is full, it responds with a 503 instead so the webhook
+// is retried once the commands already queued have run.
+func (e *VCSEventsController) runAsync(w http.ResponseWriter, fn func()) {
+	if e.TestingMode {
 		// When testing we want to wait for everything to complete.
-		e.CommandRunner.RunCommentCommand(baseRepo, maybeHeadRepo, maybePull, user, pullNum, parseResult.Command)
+		fmt.Fprintln(w, "Processing...")
+		fn()
+		return
+	}
+	if !e.WorkerPool.Submit(fn) {
+		w.Header().Set("Retry-After", strconv.Itoa(webhookQueueRetryAfterSecs))
+		e.respond(w, logging.Warn, http.StatusServiceUnavailable, "Ignoring webhook since too many commands are queued, retry in %ds", webhookQueueRetryAfterSecs)
+		return
 	}
+	fmt.Fprintln(w, "Processing...")
+}
+
+// startEvent starts a span for handling an event for repo's pull request
+// pullNum. The returned context contains the span and the event's correlation
+// ID, which is the ID of its trace if it's traced. The returned logger
+// includes the correlation ID.
+func (e *VCSEventsController) startEvent(name string, repo models.Repo, pullNum int, user models.User) (context.Context, *tracing.Span, logging.SimpleLogging) {
+	ctx, span := e.Tracer.Start(context.Background(), name,
+		tracing.String("vcs.host", repo.VCSHost.Type.String()),
+		tracing.String("atlantis.repo", repo.FullName),
+		tracing.Int("atlantis.pull", pullNum),
+		tracing.String("atlantis.user", user.Username))
+	span.SetKind(tracing.ServerKind)
+	correlationID := span.TraceID()
+	if correlationID == "" {
+		correlationID = logging.NewCorrelationID()
+	}
+	ctx = logging.WithCorrelationID(ctx, correlationID)
+	return ctx, span, e.Logger.With(logging.CorrelationIDKey, correlationID)
 }
 
 // HandleGitlabMergeRequestEvent will delete any locks associated with the pull
@@ -534,6 +829,33 @@ func (e *VCSEventsController) supportsHost(h models.VCSHostType) bool {
 	return false
 }
 
+// rateLimited responds with a 429 and returns true if baseRepo or all repos
+// have sent too many webhooks that run commands. Only these webhooks are
+// limited so that ex. closed pull requests are always cleaned up.
+func (e *VCSEventsController) rateLimited(w http.ResponseWriter, baseRepo models.Repo) bool {
+	// The repo's limit is checked first so a noisy repo doesn't use up the
+	// global limit.
+	key := baseRepo.VCSHost.Hostname + "/" + baseRepo.FullName
+	allowed, wait := e.RepoRateLimiter.Allow(key)
+	if allowed {
+		key = "global"
+		allowed, wait = e.GlobalRateLimiter.Allow(key)
+	}
+	if allowed {
+		return false
+	}
+	if e.RateLimitedWebhooks != nil {
+		e.RateLimitedWebhooks.Inc(key)
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	if key == "global" {
+		e.respond(w, logging.Warn, http.StatusTooManyRequests, "Ignoring webhook since too many webhooks were received, retry in %s", wait.Round(time.Second))
+	} else {
+		e.respond(w, logging.Warn, http.StatusTooManyRequests, "Ignoring webhook since too many webhooks were received for %s, retry in %s", key, wait.Round(time.Second))
+	}
+	return true
+}
+
 func (e *VCSEventsController) respond(w http.ResponseWriter, lvl logging.LogLevel, code int, format string, args ...interface{}) {
 	response := fmt.Sprintf(format, args...)
 	e.Logger.Log(lvl, response)
@@ -541,6 +863,13 @@ func (e *VCSEventsController) respond(w http.ResponseWriter, lvl logging.LogLeve
 	fmt.Fprintln(w, response)
 }
 
+// reject counts a request from vcsHost that failed validation.
+func (e *VCSEventsController) reject(vcsHost models.VCSHostType) {
+	if e.RejectedWebhooks != nil {
+		e.RejectedWebhooks.Inc(vcsHost.String())
+	}
+}
+
 // commentNotAllowlisted comments on the pull request that the repo is not
 // allowlisted unless allowlist error comments are disabled.
 func (e *VCSEventsController) commentNotAllowlisted(baseRepo models.Repo, pullNum int) {
@@ -553,3 +882,34 @@ func (e *VCSEventsController) commentNotAllowlisted(baseRepo models.Repo, pullNu
 		e.Logger.Err("unable to comment on pull request: %s", err)
 	}
 }
+
+// modifiesAllowlistedPaths returns false if the repo is only allowlisted for
+// some paths and pull doesn't modify any of them.
+func (e *VCSEventsController) modifiesAllowlistedPaths(baseRepo models.Repo, pull models.PullRequest) (bool, error) {
+	if !e.RepoAllowlistChecker.IsPathRestricted(baseRepo.FullName, baseRepo.VCSHost.Hostname) {
+		return true, nil
+	}
+	modifiedFiles, err := e.VCSClient.GetModifiedFiles(baseRepo, pull)
+	if err != nil {
+		return false, err
+	}
+	for _, file := range modifiedFiles {
+		if e.RepoAllowlistChecker.IsPathAllowlisted(baseRepo.FullName, baseRepo.VCSHost.Hostname, file) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// commentPathsNotAllowlisted comments on pull requests that run commands but
+// don't modify the paths their repo is allowlisted for.
+func (e *VCSEventsController) commentPathsNotAllowlisted(baseRepo models.Repo, pullNum int) {
+	if e.SilenceAllowlistErrors {
+		return
+	}
+
+	errMsg := "```\nError: This pull request doesn't modify any paths allowlisted for Atlantis.\n```"
+	if err := e.VCSClient.CreateComment(baseRepo, pullNum, errMsg, ""); err != nil {
+		e.Logger.Err("unable to comment on pull request: %s", err)
+	}
+}
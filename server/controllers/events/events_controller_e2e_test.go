@@ -643,7 +643,7 @@ func TestGitHubWorkflowWithPolicyCheck(t *testing.T) {
 	}
 }
 
-func setupE2E(t *testing.T, repoDir string) (events_controllers.VCSEventsController, *vcsmocks.MockClient, *mocks.MockGithubPullGetter, *events.FileWorkspace) {
+func setupE2E(t *testing.T, repoDir string) (*events_controllers.VCSEventsController, *vcsmocks.MockClient, *mocks.MockGithubPullGetter, *events.FileWorkspace) {
 	allowForkPRs := false
 	dataDir, binDir, cacheDir, cleanup := mkSubDirs(t)
 	defer cleanup()
@@ -674,7 +674,7 @@ func setupE2E(t *testing.T, repoDir string) (events_controllers.VCSEventsControl
 		GithubUser: "github-user",
 		GitlabUser: "gitlab-user",
 	}
-	terraformClient, err := terraform.NewClient(logger, binDir, cacheDir, "", "", "", "default-tf-version", "https://releases.hashicorp.com", &NoopTFDownloader{}, false)
+	terraformClient, err := terraform.NewClient(logger, binDir, cacheDir, "", "", "", "default-tf-version", "https://releases.hashicorp.com", &NoopTFDownloader{}, false, nil, nil, nil)
 	Ok(t, err)
 	boltdb, err := db.New(dataDir)
 	Ok(t, err)
@@ -719,7 +719,7 @@ func setupE2E(t *testing.T, repoDir string) (events_controllers.VCSEventsControl
 	mockPreWorkflowHookRunner = runtimemocks.NewMockPreWorkflowHookRunner()
 	preWorkflowHooksCommandRunner := &events.DefaultPreWorkflowHooksCommandRunner{
 		VCSClient:             e2eVCSClient,
-		GlobalCfg:             globalCfg,
+		GlobalCfg:             valid.NewGlobalCfgStore(globalCfg),
 		WorkingDirLocker:      locker,
 		WorkingDir:            workingDir,
 		PreWorkflowHookRunner: mockPreWorkflowHookRunner,
@@ -731,7 +731,7 @@ func setupE2E(t *testing.T, repoDir string) (events_controllers.VCSEventsControl
 		e2eVCSClient,
 		workingDir,
 		locker,
-		globalCfg,
+		valid.NewGlobalCfgStore(globalCfg),
 		&events.DefaultPendingPlanFinder{},
 		commentParser,
 		false,
@@ -882,7 +882,7 @@ func setupE2E(t *testing.T, repoDir string) (events_controllers.VCSEventsControl
 	repoAllowlistChecker, err := events.NewRepoAllowlistChecker("*")
 	Ok(t, err)
 
-	ctrl := events_controllers.VCSEventsController{
+	ctrl := &events_controllers.VCSEventsController{
 		TestingMode:   true,
 		CommandRunner: commandRunner,
 		PullCleaner: &events.PullClosedExecutor{
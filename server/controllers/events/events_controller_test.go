@@ -15,6 +15,9 @@ package events_test
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -24,16 +27,22 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	. "github.com/petergtz/pegomock"
 	events_controllers "github.com/runatlantis/atlantis/server/controllers/events"
 	"github.com/runatlantis/atlantis/server/controllers/events/mocks"
+	cmatchers "github.com/runatlantis/atlantis/server/controllers/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/deliveries"
 	"github.com/runatlantis/atlantis/server/events"
 	emocks "github.com/runatlantis/atlantis/server/events/mocks"
 	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
 	"github.com/runatlantis/atlantis/server/events/models"
 	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/metrics"
+	"github.com/runatlantis/atlantis/server/ratelimit"
+	"github.com/runatlantis/atlantis/server/workerpool"
 	. "github.com/runatlantis/atlantis/testing"
 	gitlab "github.com/xanzy/go-gitlab"
 )
@@ -316,6 +325,26 @@ func TestPost_GitlabCommentResponse(t *testing.T) {
 	ResponseContains(t, w, http.StatusOK, "Commenting back on pull request")
 }
 
+func TestPost_GithubHelpCommentResponse(t *testing.T) {
+	t.Log("when the comment is atlantis help the help is rendered for the user and repo")
+	e, v, _, p, _, _, vcsClient, cp := setup(t)
+	e.HelpCommentRenderer = &events.HelpCommentRenderer{ApplyDisabled: true}
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req.Header.Set(githubHeader, "issue_comment")
+	event := `{"action": "created"}`
+	When(v.Validate(req, secret)).ThenReturn([]byte(event), nil)
+	baseRepo := models.Repo{}
+	user := models.User{}
+	When(p.ParseGithubIssueCommentEvent(matchers.AnyPtrToGithubIssueCommentEvent())).ThenReturn(baseRepo, user, 1, nil)
+	When(cp.Parse("", models.Github)).ThenReturn(events.CommentParseResult{CommentResponse: "default help", Help: true})
+	w := httptest.NewRecorder()
+
+	e.Post(w, req)
+	comment := (&events.CommentParser{}).HelpComment(true)
+	vcsClient.VerifyWasCalledOnce().CreateComment(baseRepo, 1, comment, "")
+	ResponseContains(t, w, http.StatusOK, "Commenting back on pull request")
+}
+
 func TestPost_GithubCommentResponse(t *testing.T) {
 	t.Log("when the event is a github comment that warrants a comment response we comment back")
 	e, v, _, p, _, _, vcsClient, cp := setup(t)
@@ -344,7 +373,104 @@ func TestPost_GitlabCommentSuccess(t *testing.T) {
 	e.Post(w, req)
 	ResponseContains(t, w, http.StatusOK, "Processing...")
 
-	cr.VerifyWasCalledOnce().RunCommentCommand(models.Repo{}, &models.Repo{}, nil, models.User{}, 0, nil)
+	cr.VerifyWasCalledOnce().RunCommentCommand(matchers.AnyContextContext(), matchers.EqModelsRepo(models.Repo{}), matchers.EqPtrToModelsRepo(&models.Repo{}), matchers.EqPtrToModelsPullRequest(nil), matchers.EqModelsUser(models.User{}), EqInt(0), matchers.EqPtrToEventsCommentCommand(nil))
+}
+
+func TestPost_CommentRateLimited(t *testing.T) {
+	t.Log("when there are too many comments we respond with a 429")
+	e, _, gl, p, cr, _, _, _ := setup(t)
+	rateLimited := metrics.NewCounters()
+	e.RepoRateLimiter = ratelimit.NewLimiter(1, 1)
+	e.GlobalRateLimiter = ratelimit.NewLimiter(60, 2)
+	e.RateLimitedWebhooks = rateLimited
+	post := func(repo models.Repo) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+		req.Header.Set(gitlabHeader, "value")
+		event := gitlab.MergeCommentEvent{}
+		event.Project.PathWithNamespace = repo.FullName
+		When(gl.ParseAndValidate(req, secret)).ThenReturn(event, nil)
+		When(p.ParseGitlabMergeRequestCommentEvent(event)).ThenReturn(repo, repo, models.User{}, nil)
+		w := httptest.NewRecorder()
+		e.Post(w, req)
+		return w
+	}
+	repo := models.Repo{FullName: "owner/repo", VCSHost: models.VCSHost{Hostname: "gitlab.com"}}
+	other := models.Repo{FullName: "owner/other", VCSHost: models.VCSHost{Hostname: "gitlab.com"}}
+	third := models.Repo{FullName: "owner/third", VCSHost: models.VCSHost{Hostname: "gitlab.com"}}
+
+	ResponseContains(t, post(repo), http.StatusOK, "Processing...")
+	w := post(repo)
+	ResponseContains(t, w, http.StatusTooManyRequests, "Ignoring webhook since too many webhooks were received for gitlab.com/owner/repo, retry in 1m0s")
+	Equals(t, "60", w.Result().Header.Get("Retry-After"))
+
+	// Other repos have their own limit until the global limit is reached.
+	ResponseContains(t, post(other), http.StatusOK, "Processing...")
+	ResponseContains(t, post(third), http.StatusTooManyRequests, "Ignoring webhook since too many webhooks were received, retry in 1s")
+
+	Equals(t, map[string]int64{"gitlab.com/owner/repo": 1, "global": 1}, rateLimited.Snapshot())
+	cr.VerifyWasCalled(Times(2)).RunCommentCommand(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyPtrToModelsRepo(), matchers.AnyPtrToModelsPullRequest(), matchers.AnyModelsUser(), AnyInt(), matchers.AnyPtrToEventsCommentCommand())
+}
+
+func TestPost_CommentQueueFull(t *testing.T) {
+	t.Log("when the worker pool's queue is full we respond with a 503")
+	e, _, gl, p, cr, _, _, _ := setup(t)
+	e.TestingMode = false
+	e.WorkerPool = workerpool.New(1, 1)
+	// Fill the worker and the queue with commands that run until released.
+	release := make(chan struct{})
+	defer close(release)
+	wait := func() { <-release }
+	Equals(t, true, e.WorkerPool.Submit(wait))
+	for start := time.Now(); e.WorkerPool.Stats().Running == 0; time.Sleep(time.Millisecond) {
+		Assert(t, time.Since(start) < 5*time.Second, "command never ran")
+	}
+	Equals(t, true, e.WorkerPool.Submit(wait))
+
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req.Header.Set(gitlabHeader, "value")
+	When(gl.ParseAndValidate(req, secret)).ThenReturn(gitlab.MergeCommentEvent{}, nil)
+	When(p.ParseGitlabMergeRequestCommentEvent(gitlab.MergeCommentEvent{})).ThenReturn(models.Repo{}, models.Repo{}, models.User{}, nil)
+	w := httptest.NewRecorder()
+	e.Post(w, req)
+	ResponseContains(t, w, http.StatusServiceUnavailable, "Ignoring webhook since too many commands are queued, retry in 60s")
+	Equals(t, "60", w.Result().Header.Get("Retry-After"))
+	Equals(t, int64(1), e.WorkerPool.Stats().Rejected)
+	cr.VerifyWasCalled(Never()).RunCommentCommand(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyPtrToModelsRepo(), matchers.AnyPtrToModelsPullRequest(), matchers.AnyModelsUser(), AnyInt(), matchers.AnyPtrToEventsCommentCommand())
+}
+
+func TestPost_ReplayDelivery(t *testing.T) {
+	t.Log("webhooks are stored and can be replayed")
+	e, _, gl, _, cr, _, _, _ := setup(t)
+	e.Deliveries = deliveries.NewStore(time.Hour, 10)
+	When(gl.ParseAndValidate(cmatchers.AnyPtrToHttpRequest(), cmatchers.AnySliceOfByte())).ThenReturn(gitlab.MergeCommentEvent{}, nil)
+	req, _ := http.NewRequest("POST", "/events", bytes.NewBufferString(`{"object_kind": "note"}`))
+	req.Header.Set(gitlabHeader, "Note Hook")
+	w := httptest.NewRecorder()
+	e.Post(w, req)
+	ResponseContains(t, w, http.StatusOK, "Processing...")
+
+	list := e.Deliveries.List()
+	Equals(t, 1, len(list))
+	Equals(t, "gitlab", list[0].VCS)
+	Equals(t, "Note Hook", list[0].Event)
+	Equals(t, http.StatusOK, list[0].StatusCode)
+	Assert(t, strings.Contains(list[0].Response, "Processing..."), "expected the response to be stored, got %q", list[0].Response)
+	d, ok := e.Deliveries.Get(list[0].ID)
+	Equals(t, true, ok)
+	Equals(t, `{"object_kind": "note"}`, string(d.Body))
+
+	code, resp, ok := e.Replay(list[0].ID)
+	Equals(t, true, ok)
+	Equals(t, http.StatusOK, code)
+	Assert(t, strings.Contains(resp, "Processing..."), "expected the replay's response, got %q", resp)
+	cr.VerifyWasCalled(Times(2)).RunCommentCommand(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyPtrToModelsRepo(), matchers.AnyPtrToModelsPullRequest(), matchers.AnyModelsUser(), AnyInt(), matchers.AnyPtrToEventsCommentCommand())
+	// Replays aren't stored as new deliveries.
+	list = e.Deliveries.List()
+	Equals(t, 1, len(list))
+	Equals(t, 1, list[0].Replays)
+
+	_, _, ok = e.Replay("missing")
+	Equals(t, false, ok)
 }
 
 func TestPost_GithubCommentSuccess(t *testing.T) {
@@ -363,7 +489,8 @@ func TestPost_GithubCommentSuccess(t *testing.T) {
 	e.Post(w, req)
 	ResponseContains(t, w, http.StatusOK, "Processing...")
 
-	cr.VerifyWasCalledOnce().RunCommentCommand(baseRepo, nil, nil, user, 1, &cmd)
+	reqCtx, _, _, _, _, _, _ := cr.VerifyWasCalledOnce().RunCommentCommand(matchers.AnyContextContext(), matchers.EqModelsRepo(baseRepo), matchers.EqPtrToModelsRepo(nil), matchers.EqPtrToModelsPullRequest(nil), matchers.EqModelsUser(user), EqInt(1), matchers.EqPtrToEventsCommentCommand(&cmd)).GetCapturedArguments()
+	Assert(t, logging.CorrelationIDFromContext(reqCtx) != "", "expected the command to have a correlation ID")
 }
 
 func TestPost_GithubPullRequestInvalid(t *testing.T) {
@@ -429,6 +556,32 @@ func TestPost_GitlabMergeRequestNotAllowlisted(t *testing.T) {
 	ResponseContains(t, w, http.StatusForbidden, "Ignoring pull request event from non-allowlisted repo")
 }
 
+func TestPost_GitlabMergeRequestPathsNotAllowlisted(t *testing.T) {
+	t.Log("when the event is a gitlab merge request that doesn't modify the allowlisted paths of its repo we ignore it")
+	e, _, gl, p, cr, _, vcsClient, _ := setup(t)
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req.Header.Set(gitlabHeader, "value")
+
+	var err error
+	e.RepoAllowlistChecker, err = events.NewRepoAllowlistChecker("gitlab.com/owner/monorepo:infrastructure/*")
+	Ok(t, err)
+	When(gl.ParseAndValidate(req, secret)).ThenReturn(gitlab.MergeEvent{}, nil)
+	repo := models.Repo{FullName: "owner/monorepo", VCSHost: models.VCSHost{Hostname: "gitlab.com", Type: models.Gitlab}}
+	pullRequest := models.PullRequest{Num: 1, BaseRepo: repo}
+	When(p.ParseGitlabMergeRequestEvent(gitlab.MergeEvent{})).ThenReturn(pullRequest, models.OpenedPullEvent, repo, repo, models.User{}, nil)
+	When(vcsClient.GetModifiedFiles(repo, pullRequest)).ThenReturn([]string{"app/main.go", "infrastructure.md"}, nil)
+
+	w := httptest.NewRecorder()
+	e.Post(w, req)
+	ResponseContains(t, w, http.StatusOK, "Ignoring pull request event that doesn't modify allowlisted paths")
+	cr.VerifyWasCalled(Never()).RunAutoplanCommand(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyModelsUser())
+
+	When(vcsClient.GetModifiedFiles(repo, pullRequest)).ThenReturn([]string{"app/main.go", "infrastructure/main.tf"}, nil)
+	w = httptest.NewRecorder()
+	e.Post(w, req)
+	cr.VerifyWasCalledOnce().RunAutoplanCommand(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyModelsUser())
+}
+
 func TestPost_GithubPullRequestUnsupportedAction(t *testing.T) {
 	t.Skip("relies too much on mocks, should use real event parser")
 	e, v, _, _, _, _, _, _ := setup(t)
@@ -680,6 +833,7 @@ func TestPost_BBServerPullClosed(t *testing.T) {
 				HeadBranch: "decline-me",
 				BaseBranch: "master",
 				Author:     "admin",
+				Title:      "Commit message",
 				State:      models.OpenPullState,
 				BaseRepo:   expRepo,
 			})
@@ -687,6 +841,229 @@ func TestPost_BBServerPullClosed(t *testing.T) {
 	}
 }
 
+// Test that Bitbucket Cloud requests are rejected if their signature doesn't
+// match the webhook secret.
+func TestPost_BBCloudSignature(t *testing.T) {
+	body := []byte(`{}`)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body) // nolint: errcheck
+	validSig := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	cases := []struct {
+		description string
+		sig         string
+		expCode     int
+		expResp     string
+		expRejected int64
+	}{
+		{
+			description: "missing signature",
+			sig:         "",
+			expCode:     http.StatusBadRequest,
+			expResp:     "request did not pass validation",
+			expRejected: 1,
+		},
+		{
+			description: "invalid signature",
+			sig:         "sha256=" + hex.EncodeToString([]byte("invalid")),
+			expCode:     http.StatusBadRequest,
+			expResp:     "request did not pass validation: payload signature check failed",
+			expRejected: 1,
+		},
+		{
+			description: "valid signature",
+			sig:         validSig,
+			expCode:     http.StatusOK,
+			expResp:     "Ignoring unsupported event type repo:push",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			rejected := metrics.NewCounters()
+			e := events_controllers.VCSEventsController{
+				Logger:                 logging.NewNoopLogger(t),
+				SupportedVCSHosts:      []models.VCSHostType{models.BitbucketCloud},
+				BitbucketWebhookSecret: secret,
+				RejectedWebhooks:       rejected,
+			}
+			req, _ := http.NewRequest("POST", "/events", bytes.NewBuffer(body))
+			req.Header.Set("X-Event-Key", "repo:push")
+			req.Header.Set("X-Request-UUID", "request-id")
+			if c.sig != "" {
+				req.Header.Set("X-Hub-Signature", c.sig)
+			}
+			w := httptest.NewRecorder()
+			e.Post(w, req)
+			ResponseContains(t, w, c.expCode, c.expResp)
+			Equals(t, c.expRejected, rejected.Get("BitbucketCloud"))
+		})
+	}
+}
+
+// Test that Azure DevOps requests are rejected if they don't have the webhook
+// secret header.
+func TestPost_AzureDevopsWebhookSecret(t *testing.T) {
+	cases := []struct {
+		description string
+		header      string
+		expCode     int
+		expResp     string
+		expRejected int64
+	}{
+		{
+			description: "missing header",
+			expCode:     http.StatusBadRequest,
+			expResp:     "request did not pass validation: X-Atlantis-Webhook-Secret header is missing or incorrect",
+			expRejected: 1,
+		},
+		{
+			description: "incorrect header",
+			header:      "wrong",
+			expCode:     http.StatusBadRequest,
+			expResp:     "request did not pass validation: X-Atlantis-Webhook-Secret header is missing or incorrect",
+			expRejected: 1,
+		},
+		{
+			// The payload is invalid but it's passed validation.
+			description: "correct header",
+			header:      string(secret),
+			expCode:     http.StatusBadRequest,
+			expResp:     "Failed parsing webhook",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			v := mocks.NewMockAzureDevopsRequestValidator()
+			rejected := metrics.NewCounters()
+			e := events_controllers.VCSEventsController{
+				Logger:                      logging.NewNoopLogger(t),
+				SupportedVCSHosts:           []models.VCSHostType{models.AzureDevops},
+				AzureDevopsWebhookSecret:    secret,
+				AzureDevopsRequestValidator: v,
+				RejectedWebhooks:            rejected,
+			}
+			req, _ := http.NewRequest("POST", "/events", bytes.NewBuffer(nil))
+			req.Header.Set(azuredevopsHeader, "reqID")
+			if c.header != "" {
+				req.Header.Set("X-Atlantis-Webhook-Secret", c.header)
+			}
+			When(v.Validate(req, nil, nil)).ThenReturn([]byte(`{}`), nil)
+			w := httptest.NewRecorder()
+			e.Post(w, req)
+			ResponseContains(t, w, c.expCode, c.expResp)
+			Equals(t, c.expRejected, rejected.Get("AzureDevops"))
+		})
+	}
+}
+
+// Test that Azure DevOps requests about the repos of an organization with its
+// own webhook credentials are validated with them.
+func TestPost_AzureDevopsOrgWebhookSecret(t *testing.T) {
+	event := `{
+		"eventType": "git.pullrequest.updated",
+		"publisherId": "tfs",
+		"resource": {
+			"repository": {
+				"name": "repo",
+				"project": {"name": "project"},
+				"webUrl": "https://dev.azure.com/%s/project/_git/repo"
+			}
+		}}`
+	cases := []struct {
+		description string
+		org         string
+		header      string
+		expRejected int64
+	}{
+		{
+			description: "org secret",
+			org:         "Org",
+			header:      "org-secret",
+		},
+		{
+			description: "top-level secret for org",
+			org:         "org",
+			header:      string(secret),
+			expRejected: 1,
+		},
+		{
+			description: "top-level secret for other org",
+			org:         "other",
+			header:      string(secret),
+		},
+		{
+			description: "org secret for other org",
+			org:         "other",
+			header:      "org-secret",
+			expRejected: 1,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			v := mocks.NewMockAzureDevopsRequestValidator()
+			rejected := metrics.NewCounters()
+			e := events_controllers.VCSEventsController{
+				Logger:                      logging.NewNoopLogger(t),
+				Parser:                      &events.EventParser{},
+				SupportedVCSHosts:           []models.VCSHostType{models.AzureDevops},
+				AzureDevopsWebhookSecret:    secret,
+				AzureDevopsRequestValidator: v,
+				AzureDevopsOrgWebhooks: map[string]events_controllers.AzureDevopsWebhookAuth{
+					"org": {Secret: []byte("org-secret")},
+				},
+				RejectedWebhooks: rejected,
+			}
+			req, _ := http.NewRequest("POST", "/events", bytes.NewBuffer([]byte(fmt.Sprintf(event, c.org))))
+			req.Header.Set(azuredevopsHeader, "reqID")
+			req.Header.Set("X-Atlantis-Webhook-Secret", c.header)
+			When(v.Validate(req, nil, nil)).ThenReturn([]byte(`{}`), nil)
+			w := httptest.NewRecorder()
+			e.Post(w, req)
+			Equals(t, c.expRejected, rejected.Get("AzureDevops"))
+		})
+	}
+}
+
+// Test that Azure DevOps requests are validated with the webhook secrets once
+// they're rotated.
+func TestPost_AzureDevopsWebhookSecretRotated(t *testing.T) {
+	RegisterMockTestingT(t)
+	v := mocks.NewMockAzureDevopsRequestValidator()
+	rejected := metrics.NewCounters()
+	e := &events_controllers.VCSEventsController{
+		Logger:                      logging.NewNoopLogger(t),
+		Parser:                      &events.EventParser{},
+		SupportedVCSHosts:           []models.VCSHostType{models.AzureDevops},
+		AzureDevopsWebhookSecret:    secret,
+		AzureDevopsRequestValidator: v,
+		AzureDevopsOrgWebhooks: map[string]events_controllers.AzureDevopsWebhookAuth{
+			"org": {Secret: []byte("org-secret")},
+		},
+		RejectedWebhooks: rejected,
+	}
+	e.UpdateWebhookSecrets(func() {
+		e.AzureDevopsWebhookSecret = []byte("rotated")
+		e.AzureDevopsOrgWebhooks["org"] = events_controllers.AzureDevopsWebhookAuth{Secret: []byte("org-rotated")}
+	})
+
+	post := func(org string, header string) {
+		body := fmt.Sprintf(`{"eventType": "git.pullrequest.updated", "resource": {"repository": {"name": "repo", "project": {"name": "project"}, "webUrl": "https://dev.azure.com/%s/project/_git/repo"}}}`, org)
+		req, _ := http.NewRequest("POST", "/events", bytes.NewBuffer([]byte(body)))
+		req.Header.Set(azuredevopsHeader, "reqID")
+		req.Header.Set("X-Atlantis-Webhook-Secret", header)
+		When(v.Validate(req, nil, nil)).ThenReturn([]byte(`{}`), nil)
+		e.Post(httptest.NewRecorder(), req)
+	}
+	post("other", "rotated")
+	post("org", "org-rotated")
+	Equals(t, int64(0), rejected.Get("AzureDevops"))
+	post("other", string(secret))
+	post("org", "org-secret")
+	Equals(t, int64(2), rejected.Get("AzureDevops"))
+}
+
 func TestPost_PullOpenedOrUpdated(t *testing.T) {
 	cases := []struct {
 		Description string
@@ -743,12 +1120,12 @@ func TestPost_PullOpenedOrUpdated(t *testing.T) {
 			w := httptest.NewRecorder()
 			e.Post(w, req)
 			ResponseContains(t, w, http.StatusOK, "Processing...")
-			cr.VerifyWasCalledOnce().RunAutoplanCommand(models.Repo{}, models.Repo{}, models.PullRequest{State: models.ClosedPullState}, models.User{})
+			cr.VerifyWasCalledOnce().RunAutoplanCommand(matchers.AnyContextContext(), matchers.EqModelsRepo(models.Repo{}), matchers.EqModelsRepo(models.Repo{}), matchers.EqModelsPullRequest(models.PullRequest{State: models.ClosedPullState}), matchers.EqModelsUser(models.User{}))
 		})
 	}
 }
 
-func setup(t *testing.T) (events_controllers.VCSEventsController, *mocks.MockGithubRequestValidator, *mocks.MockGitlabRequestParserValidator, *emocks.MockEventParsing, *emocks.MockCommandRunner, *emocks.MockPullCleaner, *vcsmocks.MockClient, *emocks.MockCommentParsing) {
+func setup(t *testing.T) (*events_controllers.VCSEventsController, *mocks.MockGithubRequestValidator, *mocks.MockGitlabRequestParserValidator, *emocks.MockEventParsing, *emocks.MockCommandRunner, *emocks.MockPullCleaner, *vcsmocks.MockClient, *emocks.MockCommentParsing) {
 	RegisterMockTestingT(t)
 	v := mocks.NewMockGithubRequestValidator()
 	gl := mocks.NewMockGitlabRequestParserValidator()
@@ -759,7 +1136,7 @@ func setup(t *testing.T) (events_controllers.VCSEventsController, *mocks.MockGit
 	vcsmock := vcsmocks.NewMockClient()
 	repoAllowlistChecker, err := events.NewRepoAllowlistChecker("*")
 	Ok(t, err)
-	e := events_controllers.VCSEventsController{
+	e := &events_controllers.VCSEventsController{
 		TestingMode:                  true,
 		Logger:                       logging.NewNoopLogger(t),
 		GithubRequestValidator:       v,
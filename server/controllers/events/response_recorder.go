@@ -0,0 +1,63 @@
+package events
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// maxRecordedResponse is how much of a response body responseRecorder keeps.
+const maxRecordedResponse = 4096
+
+// responseRecorder records the status code and body of a response so they
+// can be stored with the delivery. If w is set, the response is also written
+// to it.
+type responseRecorder struct {
+	w      http.ResponseWriter
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header {
+	if r.w != nil {
+		return r.w.Header()
+	}
+	if r.header == nil {
+		r.header = make(http.Header)
+	}
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+	if r.w != nil {
+		r.w.WriteHeader(code)
+	}
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	if remaining := maxRecordedResponse - r.body.Len(); remaining > 0 {
+		if len(p) < remaining {
+			remaining = len(p)
+		}
+		r.body.Write(p[:remaining])
+	}
+	if r.w != nil {
+		return r.w.Write(p)
+	}
+	return len(p), nil
+}
+
+// statusCode returns the status code written, which is 200 if none was
+// written explicitly.
+func (r *responseRecorder) statusCode() int {
+	if r.code == 0 {
+		return http.StatusOK
+	}
+	return r.code
+}
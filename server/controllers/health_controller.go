@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// DefaultHealthCheckTimeout is how long a health check can take before it's
+// considered failed.
+const DefaultHealthCheckTimeout = 5 * time.Second
+
+// HealthCheck checks that a dependency of Atlantis is usable.
+type HealthCheck struct {
+	// Name identifies the check in responses, ex. vcs/github.com.
+	Name string
+	// Check returns an error if the dependency isn't usable.
+	Check func() error
+}
+
+// HealthController serves the liveness and readiness of Atlantis.
+type HealthController struct {
+	Logger logging.SimpleLogging
+	// Drainer is used to report Atlantis as not ready while it's shutting
+	// down. If nil, it isn't checked.
+	Drainer *events.Drainer
+	// LivenessChecks are run by both /healthz and /readyz. They should only
+	// fail if restarting Atlantis could fix them, ex. the data dir isn't
+	// writable.
+	LivenessChecks []HealthCheck
+	// ReadinessChecks are only run by /readyz, ex. checking the VCS hosts are
+	// reachable, since restarting Atlantis can't fix them.
+	ReadinessChecks []HealthCheck
+	// Timeout is how long each check can take. Defaults to
+	// DefaultHealthCheckTimeout.
+	Timeout time.Duration
+}
+
+// HealthResponse is the response of /healthz and /readyz.
+type HealthResponse struct {
+	// Status is ok if all checks passed and failed otherwise.
+	Status string              `json:"status"`
+	Checks []HealthCheckResult `json:"checks,omitempty"`
+}
+
+// HealthCheckResult is the result of one check.
+type HealthCheckResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+const (
+	healthOK     = "ok"
+	healthFailed = "failed"
+)
+
+// Healthz is the GET /healthz route. It returns a 503 if any liveness check
+// fails.
+func (h *HealthController) Healthz(w http.ResponseWriter, _ *http.Request) {
+	h.respond(w, h.run(h.LivenessChecks))
+}
+
+// Readyz is the GET /readyz route. It returns a 503 if any liveness or
+// readiness check fails or if Atlantis is shutting down or draining.
+func (h *HealthController) Readyz(w http.ResponseWriter, _ *http.Request) {
+	checks := append(append([]HealthCheck{}, h.LivenessChecks...), h.ReadinessChecks...)
+	resp := h.run(checks)
+	if h.Drainer != nil {
+		status := h.Drainer.GetStatus()
+		if status.ShuttingDown {
+			resp.Status = healthFailed
+			resp.Checks = append(resp.Checks, HealthCheckResult{Name: "shutdown", Status: healthFailed, Error: "atlantis is shutting down"})
+		} else if status.Draining {
+			resp.Status = healthFailed
+			resp.Checks = append(resp.Checks, HealthCheckResult{Name: "drain", Status: healthFailed, Error: "atlantis is draining"})
+		}
+	}
+	h.respond(w, resp)
+}
+
+// run runs checks concurrently.
+func (h *HealthController) run(checks []HealthCheck) HealthResponse {
+	timeout := h.Timeout
+	if timeout == 0 {
+		timeout = DefaultHealthCheckTimeout
+	}
+	resp := HealthResponse{Status: healthOK}
+	results := make([]HealthCheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c HealthCheck) {
+			defer wg.Done()
+			results[i] = HealthCheckResult{Name: c.Name, Status: healthOK}
+			if err := runWithTimeout(c.Check, timeout); err != nil {
+				results[i].Status = healthFailed
+				results[i].Error = err.Error()
+			}
+		}(i, c)
+	}
+	wg.Wait()
+	for _, r := range results {
+		if r.Status != healthOK {
+			resp.Status = healthFailed
+			if h.Logger != nil {
+				h.Logger.Warn("health check %s failed: %s", r.Name, r.Error)
+			}
+		}
+	}
+	resp.Checks = results
+	return resp
+}
+
+func (h *HealthController) respond(w http.ResponseWriter, resp HealthResponse) {
+	data, err := json.MarshalIndent(&resp, "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Error creating status json response: %s", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Status != healthOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(data) // nolint: errcheck
+}
+
+// runWithTimeout runs check, returning an error if it doesn't finish within
+// timeout. The check keeps running in the background if it times out.
+func runWithTimeout(check func() error, timeout time.Duration) error {
+	errCh := make(chan error, 1)
+	go func() { errCh <- check() }()
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+}
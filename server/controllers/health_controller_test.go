@@ -0,0 +1,130 @@
+package controllers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/controllers"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestHealthController(t *testing.T) {
+	ok := func() error { return nil }
+	failed := func() error { return errors.New("unauthorized") }
+	cases := []struct {
+		description string
+		liveness    []controllers.HealthCheck
+		readiness   []controllers.HealthCheck
+		shutdown    bool
+		drain       bool
+		expHealthz  int
+		expReadyz   int
+		expChecks   []controllers.HealthCheckResult
+	}{
+		{
+			description: "all ok",
+			liveness:    []controllers.HealthCheck{{Name: "data-dir", Check: ok}},
+			readiness:   []controllers.HealthCheck{{Name: "vcs/github.com", Check: ok}},
+			expHealthz:  http.StatusOK,
+			expReadyz:   http.StatusOK,
+			expChecks: []controllers.HealthCheckResult{
+				{Name: "data-dir", Status: "ok"},
+				{Name: "vcs/github.com", Status: "ok"},
+			},
+		},
+		{
+			description: "readiness check failed",
+			liveness:    []controllers.HealthCheck{{Name: "data-dir", Check: ok}},
+			readiness:   []controllers.HealthCheck{{Name: "vcs/github.com", Check: failed}},
+			expHealthz:  http.StatusOK,
+			expReadyz:   http.StatusServiceUnavailable,
+			expChecks: []controllers.HealthCheckResult{
+				{Name: "data-dir", Status: "ok"},
+				{Name: "vcs/github.com", Status: "failed", Error: "unauthorized"},
+			},
+		},
+		{
+			description: "liveness check failed",
+			liveness:    []controllers.HealthCheck{{Name: "data-dir", Check: failed}},
+			expHealthz:  http.StatusServiceUnavailable,
+			expReadyz:   http.StatusServiceUnavailable,
+			expChecks: []controllers.HealthCheckResult{
+				{Name: "data-dir", Status: "failed", Error: "unauthorized"},
+			},
+		},
+		{
+			description: "check timed out",
+			liveness:    []controllers.HealthCheck{{Name: "terraform", Check: func() error { time.Sleep(time.Second); return nil }}},
+			expHealthz:  http.StatusServiceUnavailable,
+			expReadyz:   http.StatusServiceUnavailable,
+			expChecks: []controllers.HealthCheckResult{
+				{Name: "terraform", Status: "failed", Error: "timed out after 10ms"},
+			},
+		},
+		{
+			description: "shutting down",
+			shutdown:    true,
+			expHealthz:  http.StatusOK,
+			expReadyz:   http.StatusServiceUnavailable,
+			expChecks: []controllers.HealthCheckResult{
+				{Name: "shutdown", Status: "failed", Error: "atlantis is shutting down"},
+			},
+		},
+		{
+			description: "draining",
+			drain:       true,
+			expHealthz:  http.StatusOK,
+			expReadyz:   http.StatusServiceUnavailable,
+			expChecks: []controllers.HealthCheckResult{
+				{Name: "drain", Status: "failed", Error: "atlantis is draining"},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			drainer := &events.Drainer{}
+			if c.shutdown {
+				drainer.ShutdownBlocking()
+			}
+			if c.drain {
+				drainer.Drain()
+			}
+			h := &controllers.HealthController{
+				Logger:          logging.NewNoopLogger(t),
+				Drainer:         drainer,
+				LivenessChecks:  c.liveness,
+				ReadinessChecks: c.readiness,
+				Timeout:         10 * time.Millisecond,
+			}
+
+			r, _ := http.NewRequest("GET", "/healthz", bytes.NewBuffer(nil))
+			w := httptest.NewRecorder()
+			h.Healthz(w, r)
+			Equals(t, c.expHealthz, w.Result().StatusCode)
+
+			r, _ = http.NewRequest("GET", "/readyz", bytes.NewBuffer(nil))
+			w = httptest.NewRecorder()
+			h.Readyz(w, r)
+			Equals(t, c.expReadyz, w.Result().StatusCode)
+			Equals(t, "application/json", w.Result().Header.Get("Content-Type"))
+			body, err := ioutil.ReadAll(w.Result().Body)
+			Ok(t, err)
+			var resp controllers.HealthResponse
+			Ok(t, json.Unmarshal(body, &resp))
+			expStatus := "ok"
+			if c.expReadyz != http.StatusOK {
+				expStatus = "failed"
+			}
+			Equals(t, expStatus, resp.Status)
+			Equals(t, c.expChecks, resp.Checks)
+		})
+	}
+}
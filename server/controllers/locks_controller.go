@@ -1,10 +1,13 @@
 package controllers
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 
+	"github.com/runatlantis/atlantis/server/auth"
 	"github.com/runatlantis/atlantis/server/controllers/templates"
 	"github.com/runatlantis/atlantis/server/events/db"
 
@@ -27,8 +30,11 @@ type LocksController struct {
 	LockDetailTemplate templates.TemplateWriter
 	WorkingDir         events.WorkingDir
 	WorkingDirLocker   events.WorkingDirLocker
-	DB                 *db.BoltDB
+	DB                 db.Database
 	DeleteLockCommand  events.DeleteLockCommand
+	// Maintenance rejects applies during its maintenance windows. If nil,
+	// there are none.
+	Maintenance *locking.MaintenanceSchedule
 }
 
 // LockApply handles creating a global apply lock.
@@ -55,6 +61,43 @@ func (l *LocksController) UnlockApply(w http.ResponseWriter, r *http.Request) {
 	l.respond(w, logging.Info, http.StatusOK, "Deleted apply lock")
 }
 
+// OverrideMaintenanceWindow is the POST /maintenance-windows/{name}/override
+// route. It allows applies until the current occurrence of the window ends.
+func (l *LocksController) OverrideMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if l.Maintenance == nil {
+		l.respond(w, logging.Info, http.StatusNotFound, "No maintenance window named %q", name)
+		return
+	}
+	until, err := l.Maintenance.Override(name)
+	if err != nil {
+		l.respond(w, logging.Info, http.StatusNotFound, "Not overriding maintenance window: %s", err)
+		return
+	}
+	overriddenBy := "the Atlantis UI"
+	if user, ok := auth.UserFromContext(r.Context()); ok {
+		overriddenBy = user.Name
+	}
+	l.Logger.Warn("%s overrode maintenance window %q until %s", overriddenBy, name, until.Format("2006-01-02 15:04:05"))
+	l.respond(w, logging.Info, http.StatusOK, "Applies are allowed until %s", until.Format("2006-01-02 15:04:05"))
+}
+
+// CancelMaintenanceWindowOverride is the DELETE
+// /maintenance-windows/{name}/override route. It rejects applies again for
+// the rest of the window.
+func (l *LocksController) CancelMaintenanceWindowOverride(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if l.Maintenance == nil {
+		l.respond(w, logging.Info, http.StatusNotFound, "No maintenance window named %q", name)
+		return
+	}
+	if err := l.Maintenance.CancelOverride(name); err != nil {
+		l.respond(w, logging.Info, http.StatusNotFound, "Not cancelling override: %s", err)
+		return
+	}
+	l.respond(w, logging.Info, http.StatusOK, "Cancelled the override of maintenance window %q", name)
+}
+
 // GetLock is the GET /locks/{id} route. It renders the lock detail view.
 func (l *LocksController) GetLock(w http.ResponseWriter, r *http.Request) {
 	id, ok := mux.Vars(r)["id"]
@@ -112,7 +155,7 @@ func (l *LocksController) DeleteLock(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	lock, err := l.DeleteLockCommand.DeleteLock(idUnencoded)
+	lock, err := l.discardLock(r, idUnencoded)
 	if err != nil {
 		l.respond(w, logging.Error, http.StatusInternalServerError, "deleting lock failed with: %s", err)
 		return
@@ -122,6 +165,62 @@ func (l *LocksController) DeleteLock(w http.ResponseWriter, r *http.Request) {
 		l.respond(w, logging.Info, http.StatusNotFound, "No lock found at id %q", idUnencoded)
 		return
 	}
+	l.respond(w, logging.Info, http.StatusOK, "Deleted lock id %q", id)
+}
+
+// DeleteLocksRequest is the body of the POST /locks/discard route.
+type DeleteLocksRequest struct {
+	// IDs are the ids of the locks to delete. They aren't encoded.
+	IDs []string `json:"ids"`
+}
+
+// DeleteLocks is the POST /locks/discard route. It deletes each lock in the
+// request like DeleteLock does. Locks that don't exist anymore, ex. because
+// they were deleted by someone else, are skipped.
+func (l *LocksController) DeleteLocks(w http.ResponseWriter, r *http.Request) {
+	// Requiring JSON means browsers won't send the request cross-site without
+	// a preflight.
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		l.respond(w, logging.Warn, http.StatusUnsupportedMediaType, "Request must be JSON")
+		return
+	}
+	var req DeleteLocksRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		l.respond(w, logging.Warn, http.StatusBadRequest, "Failed parsing request: %s", err)
+		return
+	}
+	if len(req.IDs) == 0 {
+		l.respond(w, logging.Warn, http.StatusBadRequest, "No lock ids in request")
+		return
+	}
+
+	deleted := 0
+	var failed []string
+	for _, id := range req.IDs {
+		lock, err := l.discardLock(r, id)
+		if err != nil {
+			l.Logger.Err("deleting lock %q failed with: %s", id, err)
+			failed = append(failed, id)
+			continue
+		}
+		if lock != nil {
+			deleted++
+		}
+	}
+	if len(failed) > 0 {
+		l.respond(w, logging.Error, http.StatusInternalServerError, "Deleted %d locks, deleting %q failed", deleted, failed)
+		return
+	}
+	l.respond(w, logging.Info, http.StatusOK, "Deleted %d locks", deleted)
+}
+
+// discardLock deletes the lock at id, deletes the plan in its workspace and
+// comments back on the pull request. It returns nil if there's no lock at id.
+func (l *LocksController) discardLock(r *http.Request, id string) (*models.ProjectLock, error) {
+	lock, err := l.DeleteLockCommand.DeleteLock(id)
+	if err != nil || lock == nil {
+		return nil, err
+	}
 
 	// NOTE: Because BaseRepo was added to the PullRequest model later, previous
 	// installations of Atlantis will have locks in their DB that do not have
@@ -142,15 +241,19 @@ func (l *LocksController) DeleteLock(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Once the lock has been deleted, comment back on the pull request.
-		comment := fmt.Sprintf("**Warning**: The plan for dir: `%s` workspace: `%s` was **discarded** via the Atlantis UI.\n\n"+
-			"To `apply` this plan you must run `plan` again.", lock.Project.Path, lock.Workspace)
+		discardedBy := "the Atlantis UI"
+		if user, ok := auth.UserFromContext(r.Context()); ok {
+			discardedBy = fmt.Sprintf("the Atlantis UI by %s", user.Name)
+		}
+		comment := fmt.Sprintf("**Warning**: The plan for dir: `%s` workspace: `%s` was **discarded** via %s.\n\n"+
+			"To `apply` this plan you must run `plan` again.", lock.Project.Path, lock.Workspace, discardedBy)
 		if err = l.VCSClient.CreateComment(lock.Pull.BaseRepo, lock.Pull.Num, comment, ""); err != nil {
 			l.Logger.Warn("failed commenting on pull request: %s", err)
 		}
 	} else {
 		l.Logger.Debug("skipping commenting on pull request and deleting workspace because BaseRepo field is empty")
 	}
-	l.respond(w, logging.Info, http.StatusOK, "Deleted lock id %q", id)
+	return lock, nil
 }
 
 // respond is a helper function to respond and log the response. lvl is the log
@@ -11,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/runatlantis/atlantis/server/auth"
 	"github.com/runatlantis/atlantis/server/controllers"
 	"github.com/runatlantis/atlantis/server/controllers/templates"
 	tMocks "github.com/runatlantis/atlantis/server/controllers/templates/mocks"
@@ -410,3 +411,125 @@ func TestDeleteLock_CommentSuccess(t *testing.T) {
 		"**Warning**: The plan for dir: `path` workspace: `workspace` was **discarded** via the Atlantis UI.\n\n"+
 			"To `apply` this plan you must run `plan` again.", "")
 }
+
+func TestDeleteLock_CommentIncludesUser(t *testing.T) {
+	t.Log("If the user is logged in, the comment should say who deleted the lock")
+	RegisterMockTestingT(t)
+	cp := vcsmocks.NewMockClient()
+	dlc := mocks2.NewMockDeleteLockCommand()
+	pull := models.PullRequest{
+		BaseRepo: models.Repo{FullName: "owner/repo"},
+	}
+	When(dlc.DeleteLock("id")).ThenReturn(&models.ProjectLock{
+		Pull:      pull,
+		Workspace: "workspace",
+		Project: models.Project{
+			Path:         "path",
+			RepoFullName: "owner/repo",
+		},
+	}, nil)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	db, err := db.New(tmp)
+	Ok(t, err)
+	lc := controllers.LocksController{
+		DeleteLockCommand: dlc,
+		Logger:            logging.NewNoopLogger(t),
+		VCSClient:         cp,
+		DB:                db,
+		WorkingDir:        mocks2.NewMockWorkingDir(),
+		WorkingDirLocker:  events.NewDefaultWorkingDirLocker(),
+	}
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req = mux.SetURLVars(req, map[string]string{"id": "id"})
+	req = req.WithContext(auth.WithUser(req.Context(), auth.User{Name: "user@example.com"}))
+	w := httptest.NewRecorder()
+	lc.DeleteLock(w, req)
+	ResponseContains(t, w, http.StatusOK, "Deleted lock id \"id\"")
+	cp.VerifyWasCalled(Once()).CreateComment(pull.BaseRepo, pull.Num,
+		"**Warning**: The plan for dir: `path` workspace: `workspace` was **discarded** via the Atlantis UI by user@example.com.\n\n"+
+			"To `apply` this plan you must run `plan` again.", "")
+}
+
+func TestDeleteLocks(t *testing.T) {
+	cases := []struct {
+		description string
+		contentType string
+		body        string
+		expCode     int
+		expBody     string
+	}{
+		{
+			description: "not json",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "ids=id1",
+			expCode:     http.StatusUnsupportedMediaType,
+			expBody:     "Request must be JSON",
+		},
+		{
+			description: "invalid json",
+			contentType: "application/json",
+			body:        "{",
+			expCode:     http.StatusBadRequest,
+			expBody:     "Failed parsing request",
+		},
+		{
+			description: "no ids",
+			contentType: "application/json",
+			body:        `{"ids": []}`,
+			expCode:     http.StatusBadRequest,
+			expBody:     "No lock ids in request",
+		},
+		{
+			description: "deletes the locks and skips missing ones",
+			contentType: "application/json",
+			body:        `{"ids": ["owner/repo/path/default", "owner/repo/other/default", "missing"]}`,
+			expCode:     http.StatusOK,
+			expBody:     "Deleted 2 locks",
+		},
+		{
+			description: "deleting a lock failed",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"ids": ["owner/repo/path/default", "failed"]}`,
+			expCode:     http.StatusInternalServerError,
+			expBody:     "Deleted 1 locks, deleting [\"failed\"] failed",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			cp := vcsmocks.NewMockClient()
+			dlc := mocks2.NewMockDeleteLockCommand()
+			pull := models.PullRequest{BaseRepo: models.Repo{FullName: "owner/repo"}, Num: 1}
+			for _, path := range []string{"path", "other"} {
+				When(dlc.DeleteLock("owner/repo/" + path + "/default")).ThenReturn(&models.ProjectLock{
+					Pull:      pull,
+					Workspace: "default",
+					Project:   models.Project{Path: path, RepoFullName: "owner/repo"},
+				}, nil)
+			}
+			When(dlc.DeleteLock("missing")).ThenReturn(nil, nil)
+			When(dlc.DeleteLock("failed")).ThenReturn(nil, errors.New("err"))
+			tmp, cleanup := TempDir(t)
+			defer cleanup()
+			db, err := db.New(tmp)
+			Ok(t, err)
+			lc := controllers.LocksController{
+				DeleteLockCommand: dlc,
+				Logger:            logging.NewNoopLogger(t),
+				VCSClient:         cp,
+				DB:                db,
+				WorkingDir:        mocks2.NewMockWorkingDir(),
+				WorkingDirLocker:  events.NewDefaultWorkingDirLocker(),
+			}
+			req, _ := http.NewRequest("POST", "/locks/discard", bytes.NewBufferString(c.body))
+			req.Header.Set("Content-Type", c.contentType)
+			w := httptest.NewRecorder()
+			lc.DeleteLocks(w, req)
+			ResponseContains(t, w, c.expCode, c.expBody)
+			if c.expCode == http.StatusOK {
+				cp.VerifyWasCalled(Twice()).CreateComment(AnyRepo(), AnyInt(), AnyString(), AnyString())
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/runatlantis/atlantis/server/controllers/templates"
+	"github.com/runatlantis/atlantis/server/jobs"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// LogsController handles requests to view the output of project commands.
+type LogsController struct {
+	AtlantisVersion string
+	AtlantisURL     *url.URL
+	Logger          logging.SimpleLogging
+	Outputs         *jobs.OutputStore
+	LogTemplate     templates.TemplateWriter
+}
+
+// GetLog is the GET /logs/{id} route. It renders the log view which streams
+// the output from GetLogStream.
+func (l *LogsController) GetLog(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	output, ok := l.Outputs.Get(id)
+	if !ok {
+		l.respond(w, logging.Info, http.StatusNotFound, "No log found with id %q", id)
+		return
+	}
+	info := output.Info()
+	err := l.LogTemplate.Execute(w, templates.LogDetailData{
+		StreamPath:      fmt.Sprintf("/logs/%s/stream", url.PathEscape(id)),
+		Command:         info.Command,
+		RepoFullName:    info.Repo,
+		PullNum:         info.Pull,
+		Path:            info.RepoRelDir,
+		Workspace:       info.Workspace,
+		ProjectName:     info.ProjectName,
+		AtlantisVersion: l.AtlantisVersion,
+		CleanedBasePath: l.AtlantisURL.Path,
+	})
+	if err != nil {
+		l.Logger.Err(err.Error())
+	}
+}
+
+// GetLogStream is the GET /logs/{id}/stream route. It streams the output as
+// server-sent events: each line is a message and a "done" event is sent once
+// the command finishes.
+func (l *LogsController) GetLogStream(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	output, ok := l.Outputs.Get(id)
+	if !ok {
+		l.respond(w, logging.Info, http.StatusNotFound, "No log found with id %q", id)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		l.respond(w, logging.Error, http.StatusInternalServerError, "Streaming isn't supported")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Stop proxies like nginx from buffering the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	lines, ch, unsubscribe := output.Subscribe()
+	defer unsubscribe()
+	for _, line := range lines {
+		writeEvent(w, line)
+	}
+	flusher.Flush()
+	for ch != nil {
+		select {
+		case line, ok := <-ch:
+			if !ok {
+				ch = nil
+				continue
+			}
+			writeEvent(w, line)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+	fmt.Fprint(w, "event: done\ndata: \n\n") // nolint: errcheck
+	flusher.Flush()
+}
+
+// writeEvent writes line as a server-sent event message.
+func writeEvent(w http.ResponseWriter, line string) {
+	// Lines never contain newlines but can contain carriage returns which
+	// would otherwise end the message.
+	fmt.Fprintf(w, "data: %s\n\n", strings.Replace(line, "\r", "", -1)) // nolint: errcheck
+}
+
+func (l *LogsController) respond(w http.ResponseWriter, lvl logging.LogLevel, responseCode int, format string, args ...interface{}) {
+	response := fmt.Sprintf(format, args...)
+	l.Logger.Log(lvl, response)
+	w.WriteHeader(responseCode)
+	fmt.Fprintln(w, response)
+}
@@ -0,0 +1,98 @@
+package controllers_test
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/runatlantis/atlantis/server/controllers"
+	"github.com/runatlantis/atlantis/server/controllers/templates"
+	"github.com/runatlantis/atlantis/server/jobs"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func setupLogsController(t *testing.T) (controllers.LogsController, *jobs.OutputStore) {
+	atlantisURL, err := url.Parse("https://example.com/basepath")
+	Ok(t, err)
+	outputs := jobs.NewOutputStore(jobs.DefaultMaxOutputs)
+	return controllers.LogsController{
+		AtlantisVersion: "1.0.0",
+		AtlantisURL:     atlantisURL,
+		Logger:          logging.NewNoopLogger(t),
+		Outputs:         outputs,
+		LogTemplate:     templates.LogTemplate,
+	}, outputs
+}
+
+func logRequest(id string) *http.Request {
+	req, _ := http.NewRequest("GET", "", nil)
+	return mux.SetURLVars(req, map[string]string{"id": id})
+}
+
+func TestGetLog_NotFound(t *testing.T) {
+	lc, _ := setupLogsController(t)
+	w := httptest.NewRecorder()
+	lc.GetLog(w, logRequest("missing"))
+	ResponseContains(t, w, http.StatusNotFound, `No log found with id "missing"`)
+
+	w = httptest.NewRecorder()
+	lc.GetLogStream(w, logRequest("missing"))
+	ResponseContains(t, w, http.StatusNotFound, `No log found with id "missing"`)
+}
+
+func TestGetLog(t *testing.T) {
+	lc, outputs := setupLogsController(t)
+	o := outputs.Start(jobs.OutputInfo{Command: "plan", Repo: "owner/repo", Pull: 1, RepoRelDir: "dir", Workspace: "default"})
+	w := httptest.NewRecorder()
+	lc.GetLog(w, logRequest(o.Info().ID))
+	Equals(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	Assert(t, strings.Contains(body, "owner/repo #1"), "expected repo in %q", body)
+	// The stream path is escaped since it's in javascript.
+	Assert(t, strings.Contains(body, `\/basepath\/logs\/`+o.Info().ID+`\/stream`), "expected stream path in %q", body)
+}
+
+func TestGetLogStream_Finished(t *testing.T) {
+	lc, outputs := setupLogsController(t)
+	o := outputs.Start(jobs.OutputInfo{})
+	o.Write([]byte("line 1\nline 2\n")) // nolint: errcheck
+	o.Close()
+
+	w := httptest.NewRecorder()
+	lc.GetLogStream(w, logRequest(o.Info().ID))
+	Equals(t, http.StatusOK, w.Code)
+	Equals(t, "text/event-stream", w.Header().Get("Content-Type"))
+	Equals(t, "data: line 1\n\ndata: line 2\n\nevent: done\ndata: \n\n", w.Body.String())
+}
+
+func TestGetLogStream_Live(t *testing.T) {
+	lc, outputs := setupLogsController(t)
+	o := outputs.Start(jobs.OutputInfo{})
+	o.Write([]byte("before\n")) // nolint: errcheck
+
+	router := mux.NewRouter()
+	router.HandleFunc("/logs/{id}/stream", lc.GetLogStream)
+	server := httptest.NewServer(router)
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/logs/" + o.Info().ID + "/stream")
+	Ok(t, err)
+	defer resp.Body.Close() // nolint: errcheck
+	r := bufio.NewReader(resp.Body)
+
+	// Once we've read the existing output, the request has subscribed so
+	// new lines are streamed.
+	line, err := r.ReadString('\n')
+	Ok(t, err)
+	Equals(t, "data: before\n", line)
+	o.Write([]byte("during\n")) // nolint: errcheck
+	o.Close()
+	rest, err := ioutil.ReadAll(r)
+	Ok(t, err)
+	Equals(t, "\ndata: during\n\nevent: done\ndata: \n\n", string(rest))
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// PlansController serves the plans of pull requests rendered as JSON so
+// reviewers and tools can inspect them, ex. from the link in plan comments.
+type PlansController struct {
+	Logger        logging.SimpleLogging
+	PlanArtifacts *events.PlanArtifactReader
+}
+
+// GetPlanJSON is the GET /plans/json route. It returns the plan rendered by
+// terraform show -json for the project selected by the repo, pull, dir,
+// workspace and project query parameters, with its sensitive values redacted.
+// It's only served with web auth since plans still contain the values of
+// resources, outputs and variables that aren't marked sensitive.
+func (p *PlansController) GetPlanJSON(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	repoFullName := query.Get("repo")
+	// The repo's name is part of the path of its working directory.
+	if repoFullName == "" || strings.Contains(repoFullName, "..") {
+		p.respond(w, logging.Warn, http.StatusBadRequest, "repo must be a repo's full name")
+		return
+	}
+	pullNum, err := strconv.Atoi(query.Get("pull"))
+	if err != nil || pullNum <= 0 {
+		p.respond(w, logging.Warn, http.StatusBadRequest, "pull must be a pull request number")
+		return
+	}
+	repo := models.Repo{FullName: repoFullName}
+	plan, err := p.PlanArtifacts.Find(repo, pullNum, query.Get("project"), query.Get("dir"), query.Get("workspace"))
+	if err == nil {
+		var contents []byte
+		if contents, err = p.PlanArtifacts.ReadJSON(p.Logger, repo, pullNum, plan); err == nil {
+			if contents, err = events.RedactPlanJSON(contents); err != nil {
+				p.respond(w, logging.Error, http.StatusInternalServerError, "Redacting plan: %s", err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write(contents) // nolint: errcheck
+			return
+		}
+	}
+	p.respondErr(w, err, repoFullName, pullNum)
+}
+
+// respondErr responds with the error of finding or reading a plan.
+func (p *PlansController) respondErr(w http.ResponseWriter, err error, repoFullName string, pullNum int) {
+	switch err {
+	case events.ErrPlanNotFound:
+		p.respond(w, logging.Info, http.StatusNotFound, "No plan found for %s#%d, it may have been applied or deleted", repoFullName, pullNum)
+	case events.ErrPlanInUse:
+		p.respond(w, logging.Info, http.StatusConflict, "%s", err)
+	default:
+		p.respond(w, logging.Error, http.StatusInternalServerError, "Reading plan: %s", err)
+	}
+}
+
+func (p *PlansController) respond(w http.ResponseWriter, lvl logging.LogLevel, responseCode int, format string, args ...interface{}) {
+	response := fmt.Sprintf(format, args...)
+	p.Logger.Log(lvl, response)
+	w.WriteHeader(responseCode)
+	fmt.Fprintln(w, response)
+}
@@ -0,0 +1,63 @@
+package controllers_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/controllers"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestPlansController_GetPlanJSON(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	projectDir := filepath.Join(tmp, "default", "staging")
+	Ok(t, os.MkdirAll(projectDir, 0700))
+	Ok(t, ioutil.WriteFile(filepath.Join(projectDir, "staging-default.tfplan"), []byte("plan"), 0600))
+	Ok(t, ioutil.WriteFile(filepath.Join(projectDir, "staging-default.json"), []byte(`{"format_version":"0.1","output_changes":{"token":{"after":"secret","after_sensitive":true}}}`), 0600))
+	workingDir := mocks.NewMockWorkingDir()
+	When(workingDir.GetPullDir(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest())).ThenReturn(tmp, nil)
+	finder := mocks.NewMockPendingPlanFinder()
+	When(finder.Find(tmp)).ThenReturn([]events.PendingPlan{{
+		RepoDir:     filepath.Join(tmp, "default"),
+		RepoRelDir:  "staging",
+		Workspace:   "default",
+		ProjectName: "staging",
+	}}, nil)
+	pc := &controllers.PlansController{
+		Logger: logging.NewNoopLogger(t),
+		PlanArtifacts: &events.PlanArtifactReader{
+			WorkingDir:        workingDir,
+			WorkingDirLocker:  events.NewDefaultWorkingDirLocker(),
+			PendingPlanFinder: finder,
+		},
+	}
+	get := func(url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		pc.GetPlanJSON(w, req)
+		return w
+	}
+
+	w := get("/plans/json?repo=owner/repo&pull=1&dir=staging&workspace=default&project=staging")
+	Equals(t, http.StatusOK, w.Result().StatusCode)
+	Equals(t, "application/json", w.Result().Header.Get("Content-Type"))
+	Equals(t, `{"format_version":"0.1","output_changes":{"token":{"after":"(sensitive value)","after_sensitive":true}}}`, w.Body.String())
+
+	w = get("/plans/json?repo=owner/repo&pull=1&dir=prod")
+	ResponseContains(t, w, http.StatusNotFound, "No plan found for owner/repo#1")
+	w = get("/plans/json?repo=owner/repo")
+	ResponseContains(t, w, http.StatusBadRequest, "pull must be a pull request number")
+	w = get("/plans/json?repo=../../etc&pull=1")
+	ResponseContains(t, w, http.StatusBadRequest, "repo must be a repo's full name")
+}
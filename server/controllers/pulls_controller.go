@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/controllers/templates"
+	"github.com/runatlantis/atlantis/server/events/db"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/jobs"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// projectStatuses are all the statuses a project can be filtered by.
+var projectStatuses = []models.ProjectPlanStatus{
+	models.PlannedPlanStatus,
+	models.ErroredPlanStatus,
+	models.PassedPolicyCheckStatus,
+	models.ErroredPolicyCheckStatus,
+	models.AppliedPlanStatus,
+	models.ErroredApplyStatus,
+	models.DiscardedPlanStatus,
+}
+
+// runningStatus is the status used to filter for projects that have a
+// command running right now.
+const runningStatus = "running"
+
+// PullsController renders the dashboard of open pull requests.
+type PullsController struct {
+	AtlantisVersion string
+	AtlantisURL     *url.URL
+	Logger          logging.SimpleLogging
+	DB              db.Database
+	Outputs         *jobs.OutputStore
+	PullsTemplate   templates.TemplateWriter
+}
+
+// Index is the GET /pulls route. It lists the open pull requests Atlantis has
+// run commands for along with the last status of each of their projects.
+// Pulls can be filtered with the repo and status query parameters.
+func (p *PullsController) Index(w http.ResponseWriter, r *http.Request) {
+	statuses, err := p.DB.GetPullStatuses()
+	if err != nil {
+		p.respond(w, logging.Error, http.StatusInternalServerError, "Failed getting pull statuses: %s", err)
+		return
+	}
+	repoFilter := r.URL.Query().Get("repo")
+	statusFilter := r.URL.Query().Get("status")
+
+	running := p.runningOutputs()
+	repoSet := make(map[string]bool)
+	var pulls []pullWithActivity
+	for _, s := range statuses {
+		repoSet[s.Pull.BaseRepo.FullName] = true
+		if repoFilter != "" && s.Pull.BaseRepo.FullName != repoFilter {
+			continue
+		}
+
+		pull := pullWithActivity{
+			PullIndexData: templates.PullIndexData{
+				RepoFullName: s.Pull.BaseRepo.FullName,
+				PullNum:      s.Pull.Num,
+				URL:          s.Pull.URL,
+				Author:       s.Pull.Author,
+				HeadCommit:   shortCommit(s.Pull.HeadCommit),
+			},
+		}
+		matches := statusFilter == ""
+		for _, proj := range s.Projects {
+			data := templates.ProjectIndexData{
+				ProjectName: proj.ProjectName,
+				Path:        proj.RepoRelDir,
+				Workspace:   proj.Workspace,
+				Status:      proj.Status.String(),
+				User:        proj.User,
+				LogURL:      proj.OutputURL,
+			}
+			if !proj.StartedAt.IsZero() {
+				data.TimeFormatted = proj.StartedAt.Format("02-01-2006 15:04:05")
+				data.Duration = proj.Duration.Round(time.Second).String()
+				if proj.StartedAt.After(pull.lastActivity) {
+					pull.lastActivity = proj.StartedAt
+				}
+			}
+			key := runningKey(s.Pull.BaseRepo.FullName, s.Pull.Num, proj.RepoRelDir, proj.Workspace)
+			if info, ok := running[key]; ok {
+				data.Running = true
+				data.LogURL = fmt.Sprintf("%s/logs/%s", p.AtlantisURL.Path, url.PathEscape(info.ID))
+				if info.StartedAt.After(pull.lastActivity) {
+					pull.lastActivity = info.StartedAt
+				}
+			}
+			if data.Status == statusFilter || (data.Running && statusFilter == runningStatus) {
+				matches = true
+			}
+			pull.Projects = append(pull.Projects, data)
+		}
+		if matches {
+			pulls = append(pulls, pull)
+		}
+	}
+
+	// Show the pulls with the most recent activity first.
+	sort.SliceStable(pulls, func(i, j int) bool {
+		if !pulls[i].lastActivity.Equal(pulls[j].lastActivity) {
+			return pulls[i].lastActivity.After(pulls[j].lastActivity)
+		}
+		if pulls[i].RepoFullName != pulls[j].RepoFullName {
+			return pulls[i].RepoFullName < pulls[j].RepoFullName
+		}
+		return pulls[i].PullNum > pulls[j].PullNum
+	})
+
+	var repos []string
+	for repo := range repoSet {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+	statusNames := []string{runningStatus}
+	for _, s := range projectStatuses {
+		statusNames = append(statusNames, s.String())
+	}
+	data := templates.PullsIndexData{
+		Repos:           repos,
+		Statuses:        statusNames,
+		RepoFilter:      repoFilter,
+		StatusFilter:    statusFilter,
+		AtlantisVersion: p.AtlantisVersion,
+		CleanedBasePath: p.AtlantisURL.Path,
+	}
+	for _, pull := range pulls {
+		data.Pulls = append(data.Pulls, pull.PullIndexData)
+	}
+	if err := p.PullsTemplate.Execute(w, data); err != nil {
+		p.Logger.Err(err.Error())
+	}
+}
+
+// pullWithActivity is a pull request in the dashboard along with
+// when a command was last run for it, used for sorting.
+type pullWithActivity struct {
+	templates.PullIndexData
+	lastActivity time.Time
+}
+
+// runningOutputs returns the outputs of commands that are running right now,
+// keyed by runningKey.
+func (p *PullsController) runningOutputs() map[string]jobs.OutputInfo {
+	running := make(map[string]jobs.OutputInfo)
+	if p.Outputs == nil {
+		return running
+	}
+	// List returns the most recent outputs first so if the same project has
+	// multiple running outputs, the most recent one is kept.
+	for _, info := range p.Outputs.List() {
+		if info.Finished {
+			continue
+		}
+		key := runningKey(info.Repo, info.Pull, info.RepoRelDir, info.Workspace)
+		if _, ok := running[key]; !ok {
+			running[key] = info
+		}
+	}
+	return running
+}
+
+func runningKey(repo string, pull int, dir string, workspace string) string {
+	return fmt.Sprintf("%s/%d/%s/%s", repo, pull, dir, workspace)
+}
+
+func shortCommit(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+func (p *PullsController) respond(w http.ResponseWriter, lvl logging.LogLevel, responseCode int, format string, args ...interface{}) {
+	response := fmt.Sprintf(format, args...)
+	p.Logger.Log(lvl, response)
+	w.WriteHeader(responseCode)
+	fmt.Fprintln(w, response)
+}
@@ -0,0 +1,154 @@
+package controllers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/controllers"
+	"github.com/runatlantis/atlantis/server/controllers/templates"
+	"github.com/runatlantis/atlantis/server/events/db"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/jobs"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func setupPullsController(t *testing.T) (controllers.PullsController, *db.BoltDB, *jobs.OutputStore, func()) {
+	tmp, cleanup := TempDir(t)
+	boltdb, err := db.New(tmp)
+	Ok(t, err)
+	atlantisURL, err := url.Parse("https://example.com/basepath")
+	Ok(t, err)
+	outputs := jobs.NewOutputStore(jobs.DefaultMaxOutputs)
+	return controllers.PullsController{
+		AtlantisVersion: "1.0.0",
+		AtlantisURL:     atlantisURL,
+		Logger:          logging.NewNoopLogger(t),
+		DB:              boltdb,
+		Outputs:         outputs,
+		PullsTemplate:   templates.PullsTemplate,
+	}, boltdb, outputs, cleanup
+}
+
+func addPull(t *testing.T, boltdb *db.BoltDB, repo string, num int, results ...models.ProjectResult) {
+	pull := models.PullRequest{
+		Num:        num,
+		HeadCommit: "abcdef123456",
+		URL:        "https://github.com/" + repo + "/pull/1",
+		Author:     "lkysow",
+		BaseRepo:   models.Repo{FullName: repo},
+	}
+	_, err := boltdb.UpdatePullWithResults(pull, results)
+	Ok(t, err)
+}
+
+func pullsRequest(query string) *http.Request {
+	req, _ := http.NewRequest("GET", "/pulls?"+query, nil)
+	return req
+}
+
+func TestPullsIndex_Empty(t *testing.T) {
+	pc, _, _, cleanup := setupPullsController(t)
+	defer cleanup()
+	w := httptest.NewRecorder()
+	pc.Index(w, pullsRequest(""))
+	ResponseContains(t, w, http.StatusOK, "No pull requests found.")
+}
+
+func TestPullsIndex(t *testing.T) {
+	pc, boltdb, _, cleanup := setupPullsController(t)
+	defer cleanup()
+	addPull(t, boltdb, "owner/repo", 1, models.ProjectResult{
+		Command:     models.PlanCommand,
+		RepoRelDir:  "dir",
+		Workspace:   "default",
+		PlanSuccess: &models.PlanSuccess{},
+		User:        "bob",
+		StartedAt:   time.Now(),
+		Duration:    90 * time.Second,
+		OutputURL:   "https://example.com/basepath/logs/id",
+		ProjectName: "proj",
+	})
+
+	w := httptest.NewRecorder()
+	pc.Index(w, pullsRequest(""))
+	Equals(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	for _, exp := range []string{"owner/repo #1", "abcdef1", "proj", "planned", "bob", "1m30s", "https://example.com/basepath/logs/id"} {
+		Assert(t, strings.Contains(body, exp), "expected %q in %q", exp, body)
+	}
+}
+
+func TestPullsIndex_Filters(t *testing.T) {
+	pc, boltdb, outputs, cleanup := setupPullsController(t)
+	defer cleanup()
+	addPull(t, boltdb, "owner/planned", 1, models.ProjectResult{
+		Command:     models.PlanCommand,
+		RepoRelDir:  "dir",
+		Workspace:   "default",
+		PlanSuccess: &models.PlanSuccess{},
+	})
+	addPull(t, boltdb, "owner/applied", 2, models.ProjectResult{
+		Command:      models.ApplyCommand,
+		RepoRelDir:   "dir",
+		Workspace:    "default",
+		ApplySuccess: "success",
+	})
+	addPull(t, boltdb, "owner/running", 3, models.ProjectResult{
+		Command:     models.PlanCommand,
+		RepoRelDir:  "dir",
+		Workspace:   "default",
+		PlanSuccess: &models.PlanSuccess{},
+	})
+	o := outputs.Start(jobs.OutputInfo{Command: "apply", Repo: "owner/running", Pull: 3, RepoRelDir: "dir", Workspace: "default"})
+
+	cases := []struct {
+		query  string
+		exp    []string
+		notExp []string
+	}{
+		{
+			"",
+			[]string{"owner/planned #1", "owner/applied #2", "owner/running #3"},
+			nil,
+		},
+		{
+			"repo=owner/applied",
+			[]string{"owner/applied #2"},
+			[]string{"owner/planned #1", "owner/running #3"},
+		},
+		{
+			"status=applied",
+			[]string{"owner/applied #2"},
+			[]string{"owner/planned #1", "owner/running #3"},
+		},
+		{
+			"status=running",
+			[]string{"owner/running #3", "/basepath/logs/" + o.Info().ID},
+			[]string{"owner/planned #1", "owner/applied #2"},
+		},
+		{
+			"repo=owner/planned&status=applied",
+			[]string{"No pull requests found."},
+			[]string{"owner/planned #1", "owner/applied #2", "owner/running #3"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			pc.Index(w, pullsRequest(c.query))
+			Equals(t, http.StatusOK, w.Code)
+			body := w.Body.String()
+			for _, exp := range c.exp {
+				Assert(t, strings.Contains(body, exp), "expected %q in %q", exp, body)
+			}
+			for _, notExp := range c.notExp {
+				Assert(t, !strings.Contains(body, notExp), "did not expect %q in %q", notExp, body)
+			}
+		})
+	}
+}
@@ -6,27 +6,101 @@ import (
 	"net/http"
 
 	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/vcs"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/metrics"
+	"github.com/runatlantis/atlantis/server/workerpool"
 )
 
 // StatusController handles the status of Atlantis.
 type StatusController struct {
 	Logger  logging.SimpleLogging
 	Drainer *events.Drainer
+	// RejectedWebhooks counts webhook requests that failed validation. If nil,
+	// they aren't included in the response.
+	RejectedWebhooks *metrics.Counters
+	// RateLimitedWebhooks counts webhook requests that were rate limited. If
+	// nil, they aren't included in the response.
+	RateLimitedWebhooks *metrics.Counters
+	// WebhookWorkers runs the commands triggered by webhooks. If nil, its
+	// stats aren't included in the response.
+	WebhookWorkers *workerpool.Pool
+	// CleanedOrphans counts the pull requests closed without Atlantis
+	// receiving their webhook that were cleaned up, and their locks and
+	// working dirs. If nil, they aren't included in the response.
+	CleanedOrphans *metrics.Counters
+	// BoltDB reports the size of the BoltDB file. If nil, BoltDB isn't used.
+	BoltDB *events.BoltDBMaintainer
+	// DiskQuota reports the disk usage of the data dir. If nil, the data dir
+	// has no quota.
+	DiskQuota *events.DiskQuota
+	// VCSRateLimits tracks the rate limits of the VCS hosts' APIs. If nil,
+	// they aren't included in the response.
+	VCSRateLimits *vcs.RateLimits
+	// GitlabToken checks the GitLab access token. If nil, it isn't included
+	// in the response.
+	GitlabToken *events.GitlabTokenWatcher
 }
 
 type StatusResponse struct {
 	ShuttingDown  bool `json:"shutting_down"`
 	InProgressOps int  `json:"in_progress_operations"`
+	// RejectedWebhooks is the number of webhook requests that failed
+	// validation, by VCS host type.
+	RejectedWebhooks map[string]int64 `json:"rejected_webhooks,omitempty"`
+	// RateLimitedWebhooks is the number of webhook requests that were rate
+	// limited, by repo or "global" for the global limit.
+	RateLimitedWebhooks map[string]int64 `json:"rate_limited_webhooks,omitempty"`
+	// WebhookWorkers is how many commands triggered by webhooks are running
+	// and queued, and how many were rejected because the queue was full.
+	WebhookWorkers *workerpool.Stats `json:"webhook_workers,omitempty"`
+	// CleanedOrphans is the number of pull requests closed without Atlantis
+	// receiving their webhook that were cleaned up, and of their locks and
+	// working dirs.
+	CleanedOrphans map[string]int64 `json:"cleaned_orphans,omitempty"`
+	// BoltDB is the size of the BoltDB file and the outcome of its last
+	// maintenance.
+	BoltDB *events.BoltDBStatus `json:"boltdb,omitempty"`
+	// DiskUsage is the disk usage of the data dir and of each repo and pull
+	// request when its quota was last enforced.
+	DiskUsage *events.DiskUsage `json:"disk_usage,omitempty"`
+	// VCSRateLimits is the rate limit of each VCS host's API, and of each
+	// resource for hosts with a limit per resource, as of its latest
+	// response.
+	VCSRateLimits map[string]vcs.RateLimit `json:"vcs_rate_limits,omitempty"`
+	// GitlabToken is when the GitLab access token expires and how many
+	// times it was rotated, as of its last check.
+	GitlabToken *events.GitlabTokenStatus `json:"gitlab_token,omitempty"`
 }
 
 // Get is the GET /status route.
 func (d *StatusController) Get(w http.ResponseWriter, r *http.Request) {
 	status := d.Drainer.GetStatus()
-	data, err := json.MarshalIndent(&StatusResponse{
+	resp := StatusResponse{
 		ShuttingDown:  status.ShuttingDown,
 		InProgressOps: status.InProgressOps,
-	}, "", "  ")
+	}
+	if d.RejectedWebhooks != nil {
+		resp.RejectedWebhooks = d.RejectedWebhooks.Snapshot()
+	}
+	if d.RateLimitedWebhooks != nil {
+		resp.RateLimitedWebhooks = d.RateLimitedWebhooks.Snapshot()
+	}
+	resp.WebhookWorkers = d.WebhookWorkers.Stats()
+	if d.CleanedOrphans != nil {
+		resp.CleanedOrphans = d.CleanedOrphans.Snapshot()
+	}
+	if d.BoltDB != nil {
+		if boltStatus, err := d.BoltDB.Status(); err != nil {
+			d.Logger.Warn("failed getting BoltDB status: %s", err)
+		} else {
+			resp.BoltDB = &boltStatus
+		}
+	}
+	resp.DiskUsage = d.DiskQuota.Usage()
+	resp.VCSRateLimits = d.VCSRateLimits.Snapshot()
+	resp.GitlabToken = d.GitlabToken.Status()
+	data, err := json.MarshalIndent(&resp, "", "  ")
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprintf(w, "Error creating status json response: %s", err)
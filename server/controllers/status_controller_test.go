@@ -3,14 +3,20 @@ package controllers_test
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/runatlantis/atlantis/server/controllers"
 	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/metrics"
+	"github.com/runatlantis/atlantis/server/workerpool"
 	. "github.com/runatlantis/atlantis/testing"
 )
 
@@ -80,3 +86,81 @@ func TestStatusController_Shutdown(t *testing.T) {
 	Equals(t, true, result.ShuttingDown)
 	Equals(t, 0, result.InProgressOps)
 }
+
+func TestStatusController_RejectedWebhooks(t *testing.T) {
+	logger := logging.NewNoopLogger(t)
+	r, _ := http.NewRequest("GET", "/status", bytes.NewBuffer(nil))
+	w := httptest.NewRecorder()
+	rejected := metrics.NewCounters()
+	rejected.Inc("BitbucketCloud")
+	rejected.Inc("BitbucketCloud")
+	rejected.Inc("AzureDevops")
+
+	d := &controllers.StatusController{
+		Logger:           logger,
+		Drainer:          &events.Drainer{},
+		RejectedWebhooks: rejected,
+	}
+	d.Get(w, r)
+
+	var result controllers.StatusResponse
+	body, err := ioutil.ReadAll(w.Result().Body)
+	Ok(t, err)
+	Equals(t, 200, w.Result().StatusCode)
+	err = json.Unmarshal(body, &result)
+	Ok(t, err)
+	Equals(t, map[string]int64{"BitbucketCloud": 2, "AzureDevops": 1}, result.RejectedWebhooks)
+}
+
+func TestStatusController_WebhookWorkers(t *testing.T) {
+	logger := logging.NewNoopLogger(t)
+	r, _ := http.NewRequest("GET", "/status", bytes.NewBuffer(nil))
+	w := httptest.NewRecorder()
+	d := &controllers.StatusController{
+		Logger:         logger,
+		Drainer:        &events.Drainer{},
+		WebhookWorkers: workerpool.New(5, 100),
+	}
+	d.Get(w, r)
+
+	var result controllers.StatusResponse
+	body, err := ioutil.ReadAll(w.Result().Body)
+	Ok(t, err)
+	Equals(t, 200, w.Result().StatusCode)
+	err = json.Unmarshal(body, &result)
+	Ok(t, err)
+	Equals(t, &workerpool.Stats{Workers: 5, QueueSize: 100}, result.WebhookWorkers)
+}
+
+func TestStatusController_VCSRateLimits(t *testing.T) {
+	reset := time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)
+	testServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("RateLimit-Limit", "600")
+			w.Header().Set("RateLimit-Remaining", "599")
+			w.Header().Set("RateLimit-Reset", fmt.Sprint(reset.Unix()))
+		}))
+	defer testServer.Close()
+	limits := vcs.NewRateLimits()
+	client := &http.Client{Transport: limits.Transport(models.Gitlab, nil)}
+	resp, err := client.Get(testServer.URL)
+	Ok(t, err)
+	resp.Body.Close() // nolint: errcheck
+
+	r, _ := http.NewRequest("GET", "/status", bytes.NewBuffer(nil))
+	w := httptest.NewRecorder()
+	d := &controllers.StatusController{
+		Logger:        logging.NewNoopLogger(t),
+		Drainer:       &events.Drainer{},
+		VCSRateLimits: limits,
+	}
+	d.Get(w, r)
+
+	var result controllers.StatusResponse
+	body, err := ioutil.ReadAll(w.Result().Body)
+	Ok(t, err)
+	Equals(t, 200, w.Result().StatusCode)
+	err = json.Unmarshal(body, &result)
+	Ok(t, err)
+	Equals(t, map[string]vcs.RateLimit{"Gitlab": {Limit: 600, Remaining: 599, Reset: reset}}, result.VCSRateLimits)
+}
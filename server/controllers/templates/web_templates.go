@@ -31,15 +31,36 @@ type TemplateWriter interface {
 
 // LockIndexData holds the fields needed to display the index view for locks.
 type LockIndexData struct {
-	LockPath      string
+	LockPath string
+	// LockID is the unencoded id, used to discard the selected locks.
+	LockID        string
 	RepoFullName  string
 	PullNum       int
+	Author        string
 	Path          string
 	Workspace     string
 	Time          time.Time
 	TimeFormatted string
 }
 
+// LockPageData holds the search, sorting and pagination of the locks in the
+// index view.
+type LockPageData struct {
+	// Query is the free-text search.
+	Query string
+	// Sort is newest or oldest.
+	Sort string
+	// Page is the current page, starting at 1.
+	Page  int
+	Pages int
+	// Total is the number of locks that match Query.
+	Total int
+	// PrevQuery and NextQuery are the query strings of the previous and next
+	// pages. They're empty if there's no previous or next page.
+	PrevQuery string
+	NextQuery string
+}
+
 // ApplyLockData holds the fields to display in the index view
 type ApplyLockData struct {
 	Locked        bool
@@ -47,15 +68,52 @@ type ApplyLockData struct {
 	TimeFormatted string
 }
 
+// MaintenanceWindowData holds the fields needed to display a maintenance
+// window in the index view.
+type MaintenanceWindowData struct {
+	Name    string
+	Message string
+	// Global is true if the window applies to every repo.
+	Global     bool
+	Active     bool
+	Overridden bool
+	// StartFormatted and EndFormatted are the times of the current occurrence
+	// if the window is active, otherwise of the next one.
+	StartFormatted string
+	EndFormatted   string
+}
+
+// LogIndexData holds the fields needed to display a project command's log in
+// the index view.
+type LogIndexData struct {
+	LogPath       string
+	Command       string
+	RepoFullName  string
+	PullNum       int
+	Path          string
+	Workspace     string
+	Running       bool
+	TimeFormatted string
+}
+
 // IndexData holds the data for rendering the index page
 type IndexData struct {
-	Locks           []LockIndexData
-	ApplyLock       ApplyLockData
+	// Locks are the locks on the current page.
+	Locks     []LockIndexData
+	LockPage  LockPageData
+	ApplyLock ApplyLockData
+	// MaintenanceWindows are the windows during which applies are rejected.
+	MaintenanceWindows []MaintenanceWindowData
+	// CanOverride is true if maintenance windows can be overridden from the
+	// UI, which requires web auth.
+	CanOverride     bool
 	AtlantisVersion string
 	// CleanedBasePath is the path Atlantis is accessible at externally. If
 	// not using a path-based proxy, this will be an empty string. Never ends
 	// in a '/' (hence "cleaned").
 	CleanedBasePath string
+	// Logs are the most recent project command logs.
+	Logs []LogIndexData
 }
 
 var IndexTemplate = template.Must(template.New("index.html.tmpl").Parse(`
@@ -80,6 +138,18 @@ var IndexTemplate = template.Must(template.New("index.html.tmpl").Parse(`
   <link rel="stylesheet" href="{{ .CleanedBasePath }}/static/css/skeleton.css">
   <link rel="stylesheet" href="{{ .CleanedBasePath }}/static/css/custom.css">
   <link rel="icon" type="image/png" href="{{ .CleanedBasePath }}/static/images/atlantis-icon.png">
+  <style>
+    .lock-search input[type="search"] {
+      width: 50%;
+    }
+    .lock-select {
+      float: left;
+      margin: 1.5rem 1rem 0 0;
+    }
+    .lock-pagination {
+      text-align: center;
+    }
+  </style>
 </head>
 <body>
 <div class="container">
@@ -87,6 +157,8 @@ var IndexTemplate = template.Must(template.New("index.html.tmpl").Parse(`
     <a title="atlantis" href="{{ .CleanedBasePath }}/"><img class="hero" src="{{ .CleanedBasePath }}/static/images/atlantis-icon_512.png"/></a>
     <p class="title-heading">atlantis</p>
     <p class="js-discard-success"><strong>Plan discarded and unlocked!</strong></p>
+    <a href="{{ .CleanedBasePath }}/pulls">View open pull requests</a> ·
+    <a href="{{ .CleanedBasePath }}/drift">View drift</a>
   </section>
   <section>
     {{ if .ApplyLock.Locked }}
@@ -103,14 +175,51 @@ var IndexTemplate = template.Must(template.New("index.html.tmpl").Parse(`
     </div>
     {{ end }}
   </section>
+  {{ if .MaintenanceWindows }}
+  <br>
+  <section>
+    <p class="title-heading small"><strong>Maintenance Windows</strong></p>
+    {{ range .MaintenanceWindows }}
+      <div class="twelve columns content lock-row">
+      <div class="list-title">{{ .Name }}{{ if not .Global }} <span class="heading-font-size">(some repos)</span>{{ end }}{{ if .Message }} <span class="heading-font-size">{{ .Message }}</span>{{ end }}</div>
+      {{ if .Active }}
+      <div class="list-status"><code>{{ if .Overridden }}Overridden{{ else }}Applies disabled{{ end }}</code></div>
+      <div class="list-timestamp"><span class="heading-font-size">until {{ .EndFormatted }}</span></div>
+      {{ if not $.CanOverride }}
+      {{ else if .Overridden }}
+      <a class="button js-maintenance-override" data-name="{{ .Name }}" data-method="DELETE">Cancel Override</a>
+      {{ else }}
+      <a class="button button-primary js-maintenance-override" data-name="{{ .Name }}" data-method="POST">Override</a>
+      {{ end }}
+      {{ else }}
+      <div class="list-status"><code>Scheduled</code></div>
+      <div class="list-timestamp"><span class="heading-font-size">{{ .StartFormatted }} to {{ .EndFormatted }}</span></div>
+      {{ end }}
+      </div>
+    {{ end }}
+  </section>
+  {{ end }}
   <br>
   <br>
   <br>
   <section>
     <p class="title-heading small"><strong>Locks</strong></p>
+    <form class="lock-search" method="GET" action="{{ .CleanedBasePath }}/">
+      <input type="search" name="q" value="{{ .LockPage.Query }}" placeholder="Search by repo, #pull, path, workspace or author">
+      <select name="sort">
+        <option value="newest"{{ if eq .LockPage.Sort "newest" }} selected{{ end }}>Newest first</option>
+        <option value="oldest"{{ if eq .LockPage.Sort "oldest" }} selected{{ end }}>Oldest first</option>
+      </select>
+      <input class="button" type="submit" value="Search">
+    </form>
     {{ if .Locks }}
     {{ $basePath := .CleanedBasePath }}
+    <div class="lock-actions">
+      <label><input type="checkbox" id="selectAllLocks"> Select all on this page</label>
+      <a class="button button-default" id="discardLocksPrompt">Discard Selected Plans & Unlock</a>
+    </div>
     {{ range .Locks }}
+      <div class="lock-select"><input type="checkbox" class="js-lock-select" value="{{.LockID}}"></div>
       <a href="{{ $basePath }}{{.LockPath}}">
         <div class="twelve columns button content lock-row">
         <div class="list-title">{{.RepoFullName}} <span class="heading-font-size">#{{.PullNum}}</span> <code>{{.Path}}</code> <code>{{.Workspace}}</code></div>
@@ -119,10 +228,48 @@ var IndexTemplate = template.Must(template.New("index.html.tmpl").Parse(`
         </div>
       </a>
     {{ end }}
+    <p class="lock-pagination">
+      {{ if .LockPage.PrevQuery }}<a href="{{ $basePath }}/{{ .LockPage.PrevQuery }}">&larr; Previous</a>{{ end }}
+      Page {{ .LockPage.Page }} of {{ .LockPage.Pages }} ({{ .LockPage.Total }} locks)
+      {{ if .LockPage.NextQuery }}<a href="{{ $basePath }}/{{ .LockPage.NextQuery }}">Next &rarr;</a>{{ end }}
+    </p>
     {{ else }}
     <p class="placeholder">No locks found.</p>
     {{ end }}
   </section>
+  <br>
+  <br>
+  <br>
+  <section>
+    <p class="title-heading small"><strong>Logs</strong></p>
+    {{ if .Logs }}
+    {{ $basePath := .CleanedBasePath }}
+    {{ range .Logs }}
+      <a href="{{ $basePath }}{{.LogPath}}">
+        <div class="twelve columns button content lock-row">
+        <div class="list-title">{{.RepoFullName}} <span class="heading-font-size">#{{.PullNum}}</span> <code>{{.Path}}</code> <code>{{.Workspace}}</code></div>
+        <div class="list-status"><code>{{.Command}}{{ if .Running }} running{{ end }}</code></div>
+        <div class="list-timestamp"><span class="heading-font-size">{{.TimeFormatted}}</span></div>
+        </div>
+      </a>
+    {{ end }}
+    {{ else }}
+    <p class="placeholder">No logs found.</p>
+    {{ end }}
+  </section>
+  <div id="discardLocksModal" class="modal">
+    <!-- Modal content -->
+    <div class="modal-content">
+      <div class="modal-header">
+        <span class="close">&times;</span>
+      </div>
+      <div class="modal-body">
+        <p><strong>Are you sure you want to discard the plans and release the <span id="discardLocksCount"></span> selected locks?</strong></p>
+        <input class="button-primary" id="discardLocksYes" type="submit" value="Yes">
+        <input type="button" class="cancel" value="Cancel">
+      </div>
+    </div>
+  </div>
   <div id="applyLockMessageModal" class="modal">
     <!-- Modal content -->
     <div class="modal-content">
@@ -228,6 +375,57 @@ v{{ .AtlantisVersion }}
           modal.css("display", "none");
       }
   }
+
+  function selectedLockIDs() {
+    return $("input.js-lock-select:checked").map(function() { return this.value; }).get();
+  }
+  $("#selectAllLocks").change(function() {
+    $("input.js-lock-select").prop("checked", this.checked);
+  });
+  $("#discardLocksPrompt").click(function() {
+    var ids = selectedLockIDs();
+    if (ids.length === 0) {
+      return;
+    }
+    $("#discardLocksCount").text(ids.length);
+    $("#discardLocksModal").css("display", "block");
+  });
+  $("#discardLocksModal .close, #discardLocksModal .cancel").click(function() {
+    $("#discardLocksModal").css("display", "none");
+  });
+  $(".js-maintenance-override").click(function() {
+    var name = $(this).data("name");
+    var method = $(this).data("method");
+    if (method === "POST" && !confirm("Allow applies until the end of the maintenance window " + name + "?")) {
+      return;
+    }
+    $.ajax({
+        url: '{{ .CleanedBasePath }}/maintenance-windows/' + encodeURIComponent(name) + '/override',
+        type: method,
+        success: function(result) {
+          window.location.reload();
+        },
+        error: function(request, textStatus, errorThrown) {
+          alert("Failed to update the maintenance window: " + request.responseText);
+          window.location.reload();
+        }
+    });
+  });
+  $("#discardLocksYes").click(function() {
+    $.ajax({
+        url: '{{ .CleanedBasePath }}/locks/discard',
+        type: 'POST',
+        contentType: 'application/json',
+        data: JSON.stringify({ids: selectedLockIDs()}),
+        success: function(result) {
+          window.location.replace("{{ .CleanedBasePath }}/?discard=true");
+        },
+        error: function(request, textStatus, errorThrown) {
+          alert("Failed to discard the selected plans: " + request.responseText);
+          window.location.reload();
+        }
+    });
+  });
 </script>
 </body>
 </html>
@@ -445,3 +643,347 @@ var GithubAppSetupTemplate = template.Must(template.New("github-app.html.tmpl").
 </body>
 </html>
 `))
+
+// LogDetailData holds the fields needed to display the log view.
+type LogDetailData struct {
+	// StreamPath is the path to stream the log from, relative to
+	// CleanedBasePath.
+	StreamPath      string
+	Command         string
+	RepoFullName    string
+	PullNum         int
+	Path            string
+	Workspace       string
+	ProjectName     string
+	AtlantisVersion string
+	// CleanedBasePath is the path Atlantis is accessible at externally. If
+	// not using a path-based proxy, this will be an empty string. Never ends
+	// in a '/' (hence "cleaned").
+	CleanedBasePath string
+}
+
+var LogTemplate = template.Must(template.New("log.html.tmpl").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>atlantis</title>
+  <meta name="description" content="">
+  <meta name="author" content="">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <link rel="stylesheet" href="{{ .CleanedBasePath }}/static/css/normalize.css">
+  <link rel="stylesheet" href="{{ .CleanedBasePath }}/static/css/skeleton.css">
+  <link rel="stylesheet" href="{{ .CleanedBasePath }}/static/css/custom.css">
+  <link rel="icon" type="image/png" href="{{ .CleanedBasePath }}/static/images/atlantis-icon.png">
+</head>
+<body>
+  <div class="container">
+    <section class="header">
+    <a title="atlantis" href="{{ .CleanedBasePath }}/"><img class="hero" src="{{ .CleanedBasePath }}/static/images/atlantis-icon_512.png"/></a>
+    <p class="title-heading">atlantis</p>
+    <p class="title-heading"><strong>{{.RepoFullName}} #{{.PullNum}}</strong> <code>{{.Command}}</code> <code id="log-status">running</code></p>
+    </section>
+    <div class="navbar-spacer"></div>
+    <br>
+    <section>
+      <div class="twelve columns">
+        {{ if .ProjectName }}<h6><code>Project</code>: <strong>{{.ProjectName}}</strong></h6>{{ end }}
+        <h6><code>Dir</code>: <strong>{{.Path}}</strong></h6>
+        <h6><code>Workspace</code>: <strong>{{.Workspace}}</strong></h6>
+        <pre><code id="log"></code></pre>
+      </div>
+    </section>
+  </div>
+<footer>
+v{{ .AtlantisVersion }}
+</footer>
+<script>
+  var log = document.getElementById("log");
+  var status = document.getElementById("log-status");
+  var source = new EventSource("{{ .CleanedBasePath }}{{ .StreamPath }}");
+  source.onmessage = function(event) {
+    var atBottom = window.innerHeight + window.scrollY >= document.body.offsetHeight - 10;
+    log.appendChild(document.createTextNode(event.data + "\n"));
+    if (atBottom) {
+      window.scrollTo(0, document.body.scrollHeight);
+    }
+  };
+  source.addEventListener("done", function() {
+    status.textContent = "finished";
+    source.close();
+  });
+  source.onerror = function() {
+    status.textContent = "disconnected";
+    source.close();
+  };
+</script>
+</body>
+</html>
+`))
+
+// PullsIndexData holds the data for rendering the pull requests dashboard.
+type PullsIndexData struct {
+	Pulls []PullIndexData
+	// Repos are all the repos with open pull requests, for filtering.
+	Repos []string
+	// Statuses are all the project statuses, for filtering.
+	Statuses []string
+	// RepoFilter and StatusFilter are the filters that are applied. Empty if
+	// not filtering.
+	RepoFilter      string
+	StatusFilter    string
+	AtlantisVersion string
+	// CleanedBasePath is the path Atlantis is accessible at externally. If
+	// not using a path-based proxy, this will be an empty string. Never ends
+	// in a '/' (hence "cleaned").
+	CleanedBasePath string
+}
+
+// PullIndexData holds the fields needed to display a pull request in the
+// dashboard.
+type PullIndexData struct {
+	RepoFullName string
+	PullNum      int
+	URL          string
+	Author       string
+	HeadCommit   string
+	Projects     []ProjectIndexData
+}
+
+// ProjectIndexData holds the fields needed to display a project of a pull
+// request in the dashboard.
+type ProjectIndexData struct {
+	ProjectName string
+	Path        string
+	Workspace   string
+	Status      string
+	// Running is true if a command is running for the project right now.
+	Running       bool
+	User          string
+	TimeFormatted string
+	Duration      string
+	LogURL        string
+}
+
+var PullsTemplate = template.Must(template.New("pulls.html.tmpl").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>atlantis</title>
+  <meta name="description" content="">
+  <meta name="author" content="">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <link rel="stylesheet" href="{{ .CleanedBasePath }}/static/css/normalize.css">
+  <link rel="stylesheet" href="{{ .CleanedBasePath }}/static/css/skeleton.css">
+  <link rel="stylesheet" href="{{ .CleanedBasePath }}/static/css/custom.css">
+  <link rel="icon" type="image/png" href="{{ .CleanedBasePath }}/static/images/atlantis-icon.png">
+</head>
+<body>
+<div class="container">
+  <section class="header">
+    <a title="atlantis" href="{{ .CleanedBasePath }}/"><img class="hero" src="{{ .CleanedBasePath }}/static/images/atlantis-icon_512.png"/></a>
+    <p class="title-heading">atlantis</p>
+  </section>
+  <section>
+    <p class="title-heading small"><strong>Pull Requests</strong></p>
+    <form method="GET" action="{{ .CleanedBasePath }}/pulls">
+      <div class="row">
+        <div class="five columns">
+          <select class="u-full-width" name="repo">
+            <option value="">All repos</option>
+            {{ $repoFilter := .RepoFilter }}
+            {{ range .Repos }}<option value="{{ . }}"{{ if eq . $repoFilter }} selected{{ end }}>{{ . }}</option>{{ end }}
+          </select>
+        </div>
+        <div class="five columns">
+          <select class="u-full-width" name="status">
+            <option value="">All statuses</option>
+            {{ $statusFilter := .StatusFilter }}
+            {{ range .Statuses }}<option value="{{ . }}"{{ if eq . $statusFilter }} selected{{ end }}>{{ . }}</option>{{ end }}
+          </select>
+        </div>
+        <div class="two columns">
+          <input class="button-primary u-full-width" type="submit" value="Filter">
+        </div>
+      </div>
+    </form>
+    {{ if .Pulls }}
+    {{ range .Pulls }}
+    <h6><a href="{{ .URL }}" target="_blank"><strong>{{ .RepoFullName }} #{{ .PullNum }}</strong></a> <span class="heading-font-size">by {{ .Author }} at <code>{{ .HeadCommit }}</code></span></h6>
+    <table class="u-full-width">
+      <thead>
+        <tr>
+          <th class="content-table-heading">Project</th>
+          <th class="content-table-heading">Dir</th>
+          <th class="content-table-heading">Workspace</th>
+          <th class="content-table-heading">Status</th>
+          <th class="content-table-heading">Triggered By</th>
+          <th class="content-table-heading">Started</th>
+          <th class="content-table-heading">Duration</th>
+          <th class="content-table-heading">Log</th>
+        </tr>
+      </thead>
+      <tbody>
+      {{ range .Projects }}
+        <tr>
+          <td>{{ .ProjectName }}</td>
+          <td><code>{{ .Path }}</code></td>
+          <td><code>{{ .Workspace }}</code></td>
+          <td><code>{{ if .Running }}running{{ else }}{{ .Status }}{{ end }}</code></td>
+          <td>{{ .User }}</td>
+          <td>{{ .TimeFormatted }}</td>
+          <td>{{ .Duration }}</td>
+          <td>{{ if .LogURL }}<a href="{{ .LogURL }}">view</a>{{ end }}</td>
+        </tr>
+      {{ end }}
+      </tbody>
+    </table>
+    {{ end }}
+    {{ else }}
+    <p class="placeholder">No pull requests found.</p>
+    {{ end }}
+  </section>
+</div>
+<footer>
+v{{ .AtlantisVersion }}
+</footer>
+</body>
+</html>
+`))
+
+// DriftIndexData holds the data for rendering the drift dashboard.
+type DriftIndexData struct {
+	Summary         DriftSummaryData
+	Checks          []DriftCheckData
+	AtlantisVersion string
+	// CleanedBasePath is the path Atlantis is accessible at externally. If
+	// not using a path-based proxy, this will be an empty string. Never ends
+	// in a '/' (hence "cleaned").
+	CleanedBasePath string
+}
+
+// DriftSummaryData holds the totals of the last runs of all drift checks.
+type DriftSummaryData struct {
+	Projects  int
+	Drifted   int
+	Errored   int
+	ToAdd     int
+	ToChange  int
+	ToDestroy int
+	// LastRunFormatted is empty if no check has run since Atlantis started.
+	LastRunFormatted string
+}
+
+// DriftCheckData holds the fields needed to display a drift check in the
+// dashboard.
+type DriftCheckData struct {
+	Name         string
+	RepoFullName string
+	Branch       string
+	Schedule     string
+	Running      bool
+	// LastRunFormatted is empty if the check hasn't run since Atlantis
+	// started.
+	LastRunFormatted string
+	NextRunFormatted string
+	// Drifted is how many projects drifted.
+	Drifted  int
+	Projects []DriftProjectData
+}
+
+// DriftProjectData holds the fields needed to display the result of a
+// project of a drift check.
+type DriftProjectData struct {
+	ProjectName string
+	Path        string
+	Workspace   string
+	Status      string
+	Summary     string
+	Error       string
+	LogURL      string
+	IssueURL    string
+	// CheckedFormatted is when the project was planned.
+	CheckedFormatted string
+}
+
+var DriftTemplate = template.Must(template.New("drift.html.tmpl").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>atlantis</title>
+  <meta name="description" content="">
+  <meta name="author" content="">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <link rel="stylesheet" href="{{ .CleanedBasePath }}/static/css/normalize.css">
+  <link rel="stylesheet" href="{{ .CleanedBasePath }}/static/css/skeleton.css">
+  <link rel="stylesheet" href="{{ .CleanedBasePath }}/static/css/custom.css">
+  <link rel="icon" type="image/png" href="{{ .CleanedBasePath }}/static/images/atlantis-icon.png">
+</head>
+<body>
+<div class="container">
+  <section class="header">
+    <a title="atlantis" href="{{ .CleanedBasePath }}/"><img class="hero" src="{{ .CleanedBasePath }}/static/images/atlantis-icon_512.png"/></a>
+    <p class="title-heading">atlantis</p>
+  </section>
+  <section>
+    <p class="title-heading small"><strong>Drift</strong></p>
+    {{ if .Checks }}
+    {{ with .Summary }}
+    <p class="heading-font-size">
+      {{ if .LastRunFormatted }}
+      <strong>{{ .Drifted }} of {{ .Projects }} projects drifted</strong>{{ if .Errored }} and {{ .Errored }} errored{{ end }}:
+      {{ .ToAdd }} resources to add, {{ .ToChange }} to change and {{ .ToDestroy }} to destroy.
+      The last check finished {{ .LastRunFormatted }}.
+      {{ else }}
+      No drift check has run yet.
+      {{ end }}
+    </p>
+    {{ end }}
+    {{ range .Checks }}
+    <h6><strong>{{ .Name }}</strong> <span class="heading-font-size">{{ .RepoFullName }} <code>{{ .Branch }}</code> on <code>{{ .Schedule }}</code></span></h6>
+    <p class="heading-font-size">
+      {{ if .Running }}Running now.{{ else if .LastRunFormatted }}Last run {{ .LastRunFormatted }}: {{ .Drifted }} of {{ len .Projects }} projects drifted.{{ else }}Not run yet.{{ end }}
+      Next run {{ .NextRunFormatted }}.
+    </p>
+    {{ if .Projects }}
+    <table class="u-full-width">
+      <thead>
+        <tr>
+          <th class="content-table-heading">Project</th>
+          <th class="content-table-heading">Dir</th>
+          <th class="content-table-heading">Workspace</th>
+          <th class="content-table-heading">Status</th>
+          <th class="content-table-heading">Summary</th>
+          <th class="content-table-heading">Checked</th>
+          <th class="content-table-heading">Log</th>
+        </tr>
+      </thead>
+      <tbody>
+      {{ range .Projects }}
+        <tr>
+          <td>{{ .ProjectName }}</td>
+          <td><code>{{ .Path }}</code></td>
+          <td><code>{{ .Workspace }}</code></td>
+          <td><code>{{ .Status }}</code></td>
+          <td>{{ if .Error }}{{ .Error }}{{ else }}{{ .Summary }}{{ end }}</td>
+          <td>{{ .CheckedFormatted }}</td>
+          <td>{{ if .LogURL }}<a href="{{ .LogURL }}">view</a>{{ end }}{{ if .IssueURL }} <a href="{{ .IssueURL }}">issue</a>{{ end }}</td>
+        </tr>
+      {{ end }}
+      </tbody>
+    </table>
+    {{ end }}
+    {{ end }}
+    {{ else }}
+    <p class="placeholder">No drift checks are configured.</p>
+    {{ end }}
+  </section>
+</div>
+<footer>
+v{{ .AtlantisVersion }}
+</footer>
+</body>
+</html>
+`))
@@ -0,0 +1,143 @@
+// Package deliveries stores the webhooks received recently so that they can be
+// replayed, ex. if handling them failed because the VCS host was down.
+package deliveries
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultMaxDeliveries is how many deliveries are stored by default. Once
+// reached, the oldest deliveries are forgotten even if they're within the
+// retention.
+const DefaultMaxDeliveries = 1000
+
+// Delivery is a webhook request received by Atlantis.
+type Delivery struct {
+	ID         string
+	ReceivedAt time.Time
+	// VCS is the VCS host that sent the webhook, ex. github.
+	VCS string
+	// Event is the event type header, ex. issue_comment. It's empty if the
+	// VCS host doesn't send one.
+	Event string
+	// DeliveryID is the VCS host's id for the request, if any.
+	DeliveryID string
+	Header     http.Header
+	Body       []byte
+	// StatusCode and Response are what Atlantis last responded with.
+	StatusCode int
+	Response   string
+	// Replays is how many times the delivery was replayed.
+	Replays int
+}
+
+// Store holds the deliveries received within its retention. It's safe for
+// concurrent use. A nil Store doesn't store anything.
+type Store struct {
+	// Now returns the current time. It's only overridden in tests.
+	Now func() time.Time
+
+	retention time.Duration
+	max       int
+	mutex     sync.Mutex
+	// deliveries are ordered from oldest to newest.
+	deliveries []*Delivery
+}
+
+// NewStore returns a store that keeps deliveries for retention and at most
+// max of them. It returns nil if retention isn't positive.
+func NewStore(retention time.Duration, max int) *Store {
+	if retention <= 0 {
+		return nil
+	}
+	return &Store{
+		Now:       time.Now,
+		retention: retention,
+		max:       max,
+	}
+}
+
+// Add stores d and returns its id.
+func (s *Store) Add(d Delivery) string {
+	if s == nil {
+		return ""
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	d.ID = uuid.New().String()
+	d.ReceivedAt = s.Now()
+	s.deliveries = append(s.deliveries, &d)
+	s.forgetExpired()
+	return d.ID
+}
+
+// List returns the stored deliveries from newest to oldest. Their headers and
+// bodies aren't included since they can contain credentials.
+func (s *Store) List() []Delivery {
+	if s == nil {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.forgetExpired()
+	var list []Delivery
+	for i := len(s.deliveries) - 1; i >= 0; i-- {
+		d := *s.deliveries[i]
+		d.Header = nil
+		d.Body = nil
+		list = append(list, d)
+	}
+	return list
+}
+
+// Get returns the delivery with id. It returns false if there's none, ex.
+// because it's past the retention.
+func (s *Store) Get(id string) (Delivery, bool) {
+	if s == nil {
+		return Delivery{}, false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.forgetExpired()
+	for _, d := range s.deliveries {
+		if d.ID == id {
+			return *d, true
+		}
+	}
+	return Delivery{}, false
+}
+
+// Replayed records that the delivery with id was replayed and what Atlantis
+// responded with.
+func (s *Store) Replayed(id string, statusCode int, response string) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, d := range s.deliveries {
+		if d.ID == id {
+			d.Replays++
+			d.StatusCode = statusCode
+			d.Response = response
+			return
+		}
+	}
+}
+
+// forgetExpired forgets the deliveries past the retention or over the max.
+// s.mutex must be held.
+func (s *Store) forgetExpired() {
+	cutoff := s.Now().Add(-s.retention)
+	i := 0
+	for i < len(s.deliveries) && (s.deliveries[i].ReceivedAt.Before(cutoff) || len(s.deliveries)-i > s.max) {
+		i++
+	}
+	if i > 0 {
+		s.deliveries = append([]*Delivery{}, s.deliveries[i:]...)
+	}
+}
@@ -0,0 +1,63 @@
+package deliveries_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/deliveries"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestNewStore_Disabled(t *testing.T) {
+	s := deliveries.NewStore(0, 10)
+	Assert(t, s == nil, "expected nil store")
+	Equals(t, "", s.Add(deliveries.Delivery{Body: []byte("body")}))
+	Equals(t, 0, len(s.List()))
+	_, ok := s.Get("id")
+	Equals(t, false, ok)
+	s.Replayed("id", http.StatusOK, "")
+}
+
+func TestStore(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := deliveries.NewStore(time.Hour, 2)
+	s.Now = func() time.Time { return now }
+
+	first := s.Add(deliveries.Delivery{VCS: "github", Event: "issue_comment", Header: http.Header{"X-Github-Event": {"issue_comment"}}, Body: []byte("first")})
+	now = now.Add(time.Minute)
+	second := s.Add(deliveries.Delivery{VCS: "github", Event: "pull_request", Body: []byte("second"), StatusCode: http.StatusOK})
+
+	// The newest delivery is listed first and the headers and bodies aren't
+	// listed.
+	list := s.List()
+	Equals(t, []deliveries.Delivery{
+		{ID: second, ReceivedAt: now, VCS: "github", Event: "pull_request", StatusCode: http.StatusOK},
+		{ID: first, ReceivedAt: now.Add(-time.Minute), VCS: "github", Event: "issue_comment"},
+	}, list)
+
+	d, ok := s.Get(first)
+	Equals(t, true, ok)
+	Equals(t, []byte("first"), d.Body)
+	Equals(t, "issue_comment", d.Header.Get("X-Github-Event"))
+
+	s.Replayed(first, http.StatusAccepted, "Processing...")
+	d, _ = s.Get(first)
+	Equals(t, 1, d.Replays)
+	Equals(t, http.StatusAccepted, d.StatusCode)
+	Equals(t, "Processing...", d.Response)
+
+	// Once over the max, the oldest delivery is forgotten.
+	now = now.Add(30 * time.Minute)
+	third := s.Add(deliveries.Delivery{Body: []byte("third")})
+	_, ok = s.Get(first)
+	Equals(t, false, ok)
+	Equals(t, 2, len(s.List()))
+
+	// Deliveries past the retention are forgotten.
+	now = now.Add(30*time.Minute + time.Second)
+	_, ok = s.Get(second)
+	Equals(t, false, ok)
+	_, ok = s.Get(third)
+	Equals(t, true, ok)
+}
@@ -1,6 +1,8 @@
 package events
 
 import (
+	"fmt"
+
 	"github.com/runatlantis/atlantis/server/events/db"
 	"github.com/runatlantis/atlantis/server/events/locking"
 	"github.com/runatlantis/atlantis/server/events/models"
@@ -17,7 +19,7 @@ func NewApplyCommandRunner(
 	autoMerger *AutoMerger,
 	pullUpdater *PullUpdater,
 	dbUpdater *DBUpdater,
-	db *db.BoltDB,
+	db db.Database,
 	parallelPoolSize int,
 	SilenceNoProjects bool,
 	silenceVCSStatusNoProjects bool,
@@ -40,8 +42,11 @@ func NewApplyCommandRunner(
 }
 
 type ApplyCommandRunner struct {
-	DisableApplyAll     bool
-	DB                  *db.BoltDB
+	DisableApplyAll bool
+	DB              db.Database
+	// Maintenance rejects applies during its maintenance windows. If nil,
+	// there are none.
+	Maintenance         *locking.MaintenanceSchedule
 	locker              locking.ApplyLockChecker
 	vcsClient           vcs.Client
 	commitStatusUpdater CommitStatusUpdater
@@ -57,6 +62,9 @@ type ApplyCommandRunner struct {
 	// SilenceVCSStatusNoPlans is whether any plan should set commit status if no projects
 	// are found
 	silenceVCSStatusNoProjects bool
+	// PlanThreads closes the plan comment threads of Azure DevOps pull
+	// requests once their plans are all applied. If nil, they aren't.
+	PlanThreads *AzureDevopsPlanThreads
 }
 
 func (a *ApplyCommandRunner) Run(ctx *CommandContext, cmd *CommentCommand) {
@@ -81,6 +89,16 @@ func (a *ApplyCommandRunner) Run(ctx *CommandContext, cmd *CommentCommand) {
 		return
 	}
 
+	if a.Maintenance != nil {
+		if window := a.Maintenance.Active(baseRepo.FullName, baseRepo.VCSHost.Hostname); window != nil {
+			ctx.Log.Info("ignoring apply command during maintenance window %q", window.Name)
+			if err := a.vcsClient.CreateComment(baseRepo, pull.Num, maintenanceWindowComment(*window), models.ApplyCommand.String()); err != nil {
+				ctx.Log.Err("unable to comment on pull request: %s", err)
+			}
+			return
+		}
+	}
+
 	if a.DisableApplyAll && !cmd.IsForSpecificProject() {
 		ctx.Log.Info("ignoring apply command without flags since apply all is disabled")
 		if err := a.vcsClient.CreateComment(baseRepo, pull.Num, applyAllDisabledComment, models.ApplyCommand.String()); err != nil {
@@ -108,6 +126,7 @@ func (a *ApplyCommandRunner) Run(ctx *CommandContext, cmd *CommentCommand) {
 	}
 
 	ctx.Log.Info("pull request mergeable status: %t", ctx.PullMergeable)
+	ctx.AllowDestroy = cmd.AllowDestroy
 
 	var projectCmds []models.ProjectCommandContext
 	projectCmds, err = a.prjCmdBuilder.BuildApplyCommands(ctx, cmd)
@@ -135,6 +154,7 @@ func (a *ApplyCommandRunner) Run(ctx *CommandContext, cmd *CommentCommand) {
 		return
 	}
 
+	progress := a.pullUpdater.startProgress(ctx, models.ApplyCommand, projectCmds)
 	// Only run commands in parallel if enabled
 	var result CommandResult
 	if a.isParallelEnabled(projectCmds) {
@@ -143,6 +163,7 @@ func (a *ApplyCommandRunner) Run(ctx *CommandContext, cmd *CommentCommand) {
 	} else {
 		result = runProjectCmds(projectCmds, a.prjCmdRunner.Apply)
 	}
+	progress.Stop()
 
 	a.pullUpdater.updatePull(
 		ctx,
@@ -156,6 +177,7 @@ func (a *ApplyCommandRunner) Run(ctx *CommandContext, cmd *CommentCommand) {
 	}
 
 	a.updateCommitStatus(ctx, pullStatus)
+	a.PlanThreads.Close(ctx, pullStatus)
 
 	if a.autoMerger.automergeEnabled(projectCmds) {
 		a.autoMerger.automerge(ctx, pullStatus, a.autoMerger.deleteSourceBranchOnMergeEnabled(projectCmds))
@@ -207,3 +229,14 @@ var applyAllDisabledComment = "**Error:** Running `atlantis apply` without flags
 
 // applyDisabledComment is posted when apply commands are disabled globally and an apply command is issued.
 var applyDisabledComment = "**Error:** Running `atlantis apply` is disabled."
+
+// maintenanceWindowComment is posted when an apply command is issued during
+// a scheduled maintenance window.
+func maintenanceWindowComment(window locking.MaintenanceWindowStatus) string {
+	comment := fmt.Sprintf("**Error:** Running `atlantis apply` is disabled during the scheduled maintenance window `%s` until %s.",
+		window.Name, window.End.Format("2006-01-02 15:04 MST"))
+	if window.Message != "" {
+		comment += "\n\n" + window.Message
+	}
+	return comment
+}
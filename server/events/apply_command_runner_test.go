@@ -3,6 +3,7 @@ package events_test
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/google/go-github/v31/github"
 	. "github.com/petergtz/pegomock"
@@ -11,6 +12,7 @@ import (
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/models/fixtures"
 	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
 )
 
 func TestApplyCommandRunner_IsLocked(t *testing.T) {
@@ -68,3 +70,30 @@ func TestApplyCommandRunner_IsLocked(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyCommandRunner_MaintenanceWindow(t *testing.T) {
+	RegisterMockTestingT(t)
+	vcsClient := setup(t)
+
+	schedule, err := locking.ParseMaintenanceSpec("0 22 * * 5")
+	Ok(t, err)
+	maintenance := locking.NewMaintenanceSchedule([]locking.MaintenanceWindow{
+		{Name: "weekend", Schedule: schedule, Duration: 56 * time.Hour, Message: "Release freeze"},
+	})
+	maintenance.Now = func() time.Time { return time.Date(2021, 6, 5, 12, 0, 0, 0, time.UTC) }
+	applyCommandRunner.Maintenance = maintenance
+
+	modelPull := models.PullRequest{BaseRepo: fixtures.GithubRepo, State: models.OpenPullState, Num: fixtures.Pull.Num}
+	ctx := &events.CommandContext{
+		User:     fixtures.User,
+		Log:      logging.NewNoopLogger(t),
+		Pull:     modelPull,
+		HeadRepo: fixtures.GithubRepo,
+		Trigger:  events.Comment,
+	}
+	When(applyLockChecker.CheckApplyLock()).ThenReturn(locking.ApplyCommandLock{}, nil)
+	applyCommandRunner.Run(ctx, &events.CommentCommand{Name: models.ApplyCommand})
+
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, modelPull.Num,
+		"**Error:** Running `atlantis apply` is disabled during the scheduled maintenance window `weekend` until 2021-06-07 06:00 UTC.\n\nRelease freeze", "apply")
+}
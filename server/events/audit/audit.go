@@ -0,0 +1,152 @@
+// Package audit records every command Atlantis runs so operators can answer
+// "who ran what, where and when".
+package audit
+
+import (
+	"time"
+
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// Result is the outcome of an audited command.
+type Result string
+
+const (
+	// SuccessResult means the command completed successfully.
+	SuccessResult Result = "success"
+	// FailureResult means the command didn't run because a requirement wasn't
+	// met, ex. the pull request wasn't approved.
+	FailureResult Result = "failure"
+	// ErrorResult means the command ran but errored.
+	ErrorResult Result = "error"
+)
+
+// DefaultQueryLimit is the max number of entries returned by a query that
+// doesn't set a limit.
+const DefaultQueryLimit = 100
+
+// Entry is a single record in the audit log.
+type Entry struct {
+	// Time is when the command started.
+	Time time.Time `json:"time"`
+	// Actor is the username of the user that ran the command. For autoplans
+	// this is the user that triggered the pull request event.
+	Actor string `json:"actor"`
+	// Repo is the full name of the base repo, ex. runatlantis/atlantis.
+	Repo string `json:"repo"`
+	// PullNum is the pull request number.
+	PullNum int `json:"pull_num"`
+	// CommitSHA is the head commit of the pull request at the time the
+	// command was run.
+	CommitSHA string `json:"commit_sha"`
+	// Command is the name of the command, ex. plan or apply.
+	Command string `json:"command"`
+	// Project is the name of the project from atlantis.yaml, if any.
+	Project string `json:"project,omitempty"`
+	// Dir is the project's directory relative to the repo root.
+	Dir string `json:"dir,omitempty"`
+	// Workspace is the Terraform workspace.
+	Workspace string `json:"workspace,omitempty"`
+	// Flags are the extra arguments passed to the command after --.
+	Flags []string `json:"flags,omitempty"`
+	// Result is the outcome of the command.
+	Result Result `json:"result"`
+	// Message is the failure reason or error, if any.
+	Message string `json:"message,omitempty"`
+	// DurationMS is how long the command took in milliseconds.
+	DurationMS int64 `json:"duration_ms"`
+}
+
+// Query filters entries in the audit log. Zero values match everything.
+type Query struct {
+	Repo    string
+	PullNum int
+	Actor   string
+	Command string
+	Project string
+	Result  Result
+	Since   time.Time
+	Until   time.Time
+	// Limit is the max number of entries to return. Entries are returned
+	// newest first.
+	Limit int
+}
+
+// Matches returns true if e satisfies the query.
+func (q Query) Matches(e Entry) bool {
+	if q.Repo != "" && q.Repo != e.Repo {
+		return false
+	}
+	if q.PullNum != 0 && q.PullNum != e.PullNum {
+		return false
+	}
+	if q.Actor != "" && q.Actor != e.Actor {
+		return false
+	}
+	if q.Command != "" && q.Command != e.Command {
+		return false
+	}
+	if q.Project != "" && q.Project != e.Project {
+		return false
+	}
+	if q.Result != "" && q.Result != e.Result {
+		return false
+	}
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && e.Time.After(q.Until) {
+		return false
+	}
+	return true
+}
+
+// limit returns the effective limit for the query.
+func (q Query) limit() int {
+	if q.Limit <= 0 {
+		return DefaultQueryLimit
+	}
+	return q.Limit
+}
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_store.go Store
+
+// Store persists audit entries.
+type Store interface {
+	// Record persists entry.
+	Record(entry Entry) error
+}
+
+// Querier is implemented by stores that can be searched.
+type Querier interface {
+	// Query returns the entries matching q, newest first.
+	Query(q Query) ([]Entry, error)
+}
+
+// MultiStore records entries to each of its stores and queries the first one
+// that supports querying.
+type MultiStore struct {
+	Stores []Store
+	Logger logging.SimpleLogging
+}
+
+// Record records entry to every store. Errors are logged rather than returned
+// so that one misbehaving store doesn't prevent the others from recording.
+func (m *MultiStore) Record(entry Entry) error {
+	for _, s := range m.Stores {
+		if err := s.Record(entry); err != nil {
+			m.Logger.Err("recording audit entry: %s", err)
+		}
+	}
+	return nil
+}
+
+// Querier returns the first store that supports querying or nil if none do.
+func (m *MultiStore) Querier() Querier {
+	for _, s := range m.Stores {
+		if q, ok := s.(Querier); ok {
+			return q
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// FileStore appends entries as JSON lines to a file on disk.
+type FileStore struct {
+	path  string
+	mutex sync.Mutex
+}
+
+// NewFileStore returns a FileStore that writes to path. The file is created
+// if it doesn't exist.
+func NewFileStore(path string) (*FileStore, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening audit log %q", path)
+	}
+	if err := f.Close(); err != nil {
+		return nil, errors.Wrapf(err, "closing audit log %q", path)
+	}
+	return &FileStore{path: path}, nil
+}
+
+// Record appends entry to the file.
+func (f *FileStore) Record(entry Entry) error {
+	serialized, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "serializing audit entry")
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "opening audit log %q", f.path)
+	}
+	if _, err := file.Write(append(serialized, '\n')); err != nil {
+		file.Close() // nolint: errcheck
+		return errors.Wrapf(err, "writing to audit log %q", f.path)
+	}
+	return file.Close()
+}
+
+// Query scans the file for entries matching q.
+func (f *FileStore) Query(q Query) ([]Entry, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening audit log %q", f.path)
+	}
+	defer file.Close() // nolint: errcheck
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, errors.Wrapf(err, "deserializing audit log %q line %d", f.path, line)
+		}
+		if q.Matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "reading audit log %q", f.path)
+	}
+
+	// Return the newest entries first.
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.After(entries[j].Time) })
+	if len(entries) > q.limit() {
+		entries = entries[:q.limit()]
+	}
+	return entries, nil
+}
@@ -0,0 +1,68 @@
+package audit_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/audit"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestFileStore_RecordAndQuery(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	store, err := audit.NewFileStore(filepath.Join(tmp, "audit.log"))
+	Ok(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	first := audit.Entry{Time: now.Add(-2 * time.Minute), Actor: "alice", Repo: "owner/repo", PullNum: 1, Command: "plan", Result: audit.SuccessResult}
+	second := audit.Entry{Time: now.Add(-1 * time.Minute), Actor: "bob", Repo: "owner/repo", PullNum: 1, Command: "apply", Result: audit.ErrorResult, Message: "err"}
+	third := audit.Entry{Time: now, Actor: "alice", Repo: "owner/other", PullNum: 2, Command: "apply", Result: audit.SuccessResult}
+	for _, e := range []audit.Entry{first, second, third} {
+		Ok(t, store.Record(e))
+	}
+
+	t.Run("newest first", func(t *testing.T) {
+		entries, err := store.Query(audit.Query{})
+		Ok(t, err)
+		Equals(t, []audit.Entry{third, second, first}, entries)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		entries, err := store.Query(audit.Query{Repo: "owner/repo", Command: "apply"})
+		Ok(t, err)
+		Equals(t, []audit.Entry{second}, entries)
+
+		entries, err = store.Query(audit.Query{Actor: "alice", Since: now.Add(-90 * time.Second)})
+		Ok(t, err)
+		Equals(t, []audit.Entry{third}, entries)
+
+		entries, err = store.Query(audit.Query{Result: audit.FailureResult})
+		Ok(t, err)
+		Equals(t, 0, len(entries))
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		entries, err := store.Query(audit.Query{Limit: 2})
+		Ok(t, err)
+		Equals(t, []audit.Entry{third, second}, entries)
+	})
+}
+
+// Test that entries survive reopening the file, ex. after a restart.
+func TestFileStore_Reopen(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	path := filepath.Join(tmp, "audit.log")
+	store, err := audit.NewFileStore(path)
+	Ok(t, err)
+	entry := audit.Entry{Time: time.Now().UTC().Truncate(time.Second), Actor: "alice", Command: "plan"}
+	Ok(t, store.Record(entry))
+
+	store, err = audit.NewFileStore(path)
+	Ok(t, err)
+	entries, err := store.Query(audit.Query{})
+	Ok(t, err)
+	Equals(t, []audit.Entry{entry}, entries)
+}
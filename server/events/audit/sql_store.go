@@ -0,0 +1,173 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	// Registers the postgres driver with database/sql.
+	_ "github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const sqlTableName = "atlantis_audit_log"
+
+// SQLStore persists entries to a SQL database.
+type SQLStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore connects to the database at dbURL and creates the audit log
+// table if it doesn't exist. Only postgres:// and postgresql:// URLs are
+// currently supported.
+func NewSQLStore(dbURL string) (*SQLStore, error) {
+	parsed, err := url.Parse(dbURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing audit log sql url")
+	}
+	if parsed.Scheme != "postgres" && parsed.Scheme != "postgresql" {
+		return nil, fmt.Errorf("unsupported audit log sql url scheme %q: only postgres is supported", parsed.Scheme)
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "connecting to audit log database")
+	}
+	return NewSQLStoreWithDB(db)
+}
+
+// NewSQLStoreWithDB returns a SQLStore using an existing connection, ex. the
+// one to the database storing the locks. It is also used for testing.
+func NewSQLStoreWithDB(db *sql.DB) (*SQLStore, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS ` + sqlTableName + ` (
+		id          SERIAL PRIMARY KEY,
+		time        TIMESTAMP WITH TIME ZONE NOT NULL,
+		actor       TEXT NOT NULL,
+		repo        TEXT NOT NULL,
+		pull_num    INTEGER NOT NULL,
+		commit_sha  TEXT NOT NULL,
+		command     TEXT NOT NULL,
+		project     TEXT NOT NULL,
+		dir         TEXT NOT NULL,
+		workspace   TEXT NOT NULL,
+		flags       TEXT NOT NULL,
+		result      TEXT NOT NULL,
+		message     TEXT NOT NULL,
+		duration_ms BIGINT NOT NULL
+	)`)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating table %q", sqlTableName)
+	}
+	return &SQLStore{db: db}, nil
+}
+
+// Record inserts entry into the audit log table.
+func (s *SQLStore) Record(e Entry) error {
+	flags, err := encodeFlags(e.Flags)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO `+sqlTableName+
+		` (time, actor, repo, pull_num, commit_sha, command, project, dir, workspace, flags, result, message, duration_ms)`+
+		` VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		e.Time, e.Actor, e.Repo, e.PullNum, e.CommitSHA, e.Command, e.Project, e.Dir, e.Workspace,
+		flags, string(e.Result), e.Message, e.DurationMS)
+	return errors.Wrap(err, "inserting audit entry")
+}
+
+// Query returns the entries matching q.
+func (s *SQLStore) Query(q Query) ([]Entry, error) {
+	query, args := buildSQLQuery(q)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying audit log")
+	}
+	defer rows.Close() // nolint: errcheck
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		var flags, result string
+		if err := rows.Scan(&e.Time, &e.Actor, &e.Repo, &e.PullNum, &e.CommitSHA, &e.Command, &e.Project, &e.Dir, &e.Workspace, &flags, &result, &e.Message, &e.DurationMS); err != nil {
+			return nil, errors.Wrap(err, "scanning audit entry")
+		}
+		if e.Flags, err = decodeFlags(flags); err != nil {
+			return nil, err
+		}
+		e.Result = Result(result)
+		entries = append(entries, e)
+	}
+	return entries, errors.Wrap(rows.Err(), "reading audit entries")
+}
+
+// encodeFlags serializes flags as a JSON array so flags containing spaces,
+// ex. -var 'name=a b', survive the round trip. No flags are stored as "".
+func encodeFlags(flags []string) (string, error) {
+	if len(flags) == 0 {
+		return "", nil
+	}
+	serialized, err := json.Marshal(flags)
+	if err != nil {
+		return "", errors.Wrap(err, "serializing audit entry flags")
+	}
+	return string(serialized), nil
+}
+
+// decodeFlags is the inverse of encodeFlags. Rows written before flags were
+// stored as JSON hold them space separated, so those are split on spaces.
+func decodeFlags(flags string) ([]string, error) {
+	if flags == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(flags, "[") {
+		return strings.Split(flags, " "), nil
+	}
+	var decoded []string
+	if err := json.Unmarshal([]byte(flags), &decoded); err != nil {
+		return nil, errors.Wrap(err, "parsing audit entry flags")
+	}
+	return decoded, nil
+}
+
+// buildSQLQuery returns the select statement and its arguments for q.
+func buildSQLQuery(q Query) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	addCond := func(column string, op string, val interface{}) {
+		args = append(args, val)
+		conds = append(conds, fmt.Sprintf("%s %s $%d", column, op, len(args)))
+	}
+	if q.Repo != "" {
+		addCond("repo", "=", q.Repo)
+	}
+	if q.PullNum != 0 {
+		addCond("pull_num", "=", q.PullNum)
+	}
+	if q.Actor != "" {
+		addCond("actor", "=", q.Actor)
+	}
+	if q.Command != "" {
+		addCond("command", "=", q.Command)
+	}
+	if q.Project != "" {
+		addCond("project", "=", q.Project)
+	}
+	if q.Result != "" {
+		addCond("result", "=", string(q.Result))
+	}
+	if !q.Since.IsZero() {
+		addCond("time", ">=", q.Since)
+	}
+	if !q.Until.IsZero() {
+		addCond("time", "<=", q.Until)
+	}
+
+	query := "SELECT time, actor, repo, pull_num, commit_sha, command, project, dir, workspace, flags, result, message, duration_ms FROM " + sqlTableName
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, q.limit())
+	query += fmt.Sprintf(" ORDER BY time DESC LIMIT $%d", len(args))
+	return query, args
+}
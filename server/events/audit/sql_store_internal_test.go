@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestBuildSQLQuery(t *testing.T) {
+	since := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		description string
+		q           Query
+		expQuery    string
+		expArgs     []interface{}
+	}{
+		{
+			"no filters",
+			Query{},
+			"SELECT time, actor, repo, pull_num, commit_sha, command, project, dir, workspace, flags, result, message, duration_ms FROM atlantis_audit_log ORDER BY time DESC LIMIT $1",
+			[]interface{}{DefaultQueryLimit},
+		},
+		{
+			"filters",
+			Query{Repo: "owner/repo", PullNum: 2, Result: FailureResult, Since: since, Limit: 5},
+			"SELECT time, actor, repo, pull_num, commit_sha, command, project, dir, workspace, flags, result, message, duration_ms FROM atlantis_audit_log WHERE repo = $1 AND pull_num = $2 AND result = $3 AND time >= $4 ORDER BY time DESC LIMIT $5",
+			[]interface{}{"owner/repo", 2, "failure", since, 5},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			query, args := buildSQLQuery(c.q)
+			Equals(t, c.expQuery, query)
+			Equals(t, c.expArgs, args)
+		})
+	}
+}
+
+func TestEncodeDecodeFlags(t *testing.T) {
+	cases := []struct {
+		description string
+		flags       []string
+		expEncoded  string
+	}{
+		{
+			"no flags",
+			nil,
+			"",
+		},
+		{
+			"flags",
+			[]string{"-target=a", "-no-color"},
+			`["-target=a","-no-color"]`,
+		},
+		{
+			"flag with a space",
+			[]string{"-var", "name=a b"},
+			`["-var","name=a b"]`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			encoded, err := encodeFlags(c.flags)
+			Ok(t, err)
+			Equals(t, c.expEncoded, encoded)
+			decoded, err := decodeFlags(encoded)
+			Ok(t, err)
+			Equals(t, c.flags, decoded)
+		})
+	}
+}
+
+// Rows written before flags were stored as JSON are space separated.
+func TestDecodeFlags_Legacy(t *testing.T) {
+	decoded, err := decodeFlags("-target=a -no-color")
+	Ok(t, err)
+	Equals(t, []string{"-target=a", "-no-color"}, decoded)
+}
@@ -0,0 +1,43 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WebhookStore POSTs each entry as JSON to a URL. It doesn't support
+// querying.
+type WebhookStore struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookStore returns a WebhookStore that posts to url.
+func NewWebhookStore(url string) *WebhookStore {
+	return &WebhookStore{
+		URL:    url,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Record POSTs entry to the webhook URL.
+func (w *WebhookStore) Record(entry Entry) error {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "serializing audit entry")
+	}
+	resp, err := w.Client.Post(w.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "posting audit entry")
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("posting audit entry: %s responded with %d", w.URL, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package audit_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/audit"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestWebhookStore_Record(t *testing.T) {
+	var received audit.Entry
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "application/json", r.Header.Get("Content-Type"))
+		Ok(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer ts.Close()
+
+	entry := audit.Entry{Actor: "alice", Repo: "owner/repo", Command: "apply", Result: audit.SuccessResult}
+	Ok(t, audit.NewWebhookStore(ts.URL).Record(entry))
+	Equals(t, entry.Actor, received.Actor)
+	Equals(t, entry.Command, received.Command)
+}
+
+func TestWebhookStore_RecordErrOnNon2xx(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	err := audit.NewWebhookStore(ts.URL).Record(audit.Entry{})
+	ErrContains(t, "responded with 500", err)
+}
@@ -0,0 +1,115 @@
+package events
+
+import (
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/audit"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// AuditProjectCommandRunner records every project command to an audit store
+// before returning the result from the wrapped runner.
+type AuditProjectCommandRunner struct {
+	ProjectCommandRunner
+	Store audit.Store
+}
+
+// Plan runs and records the plan for the project described by ctx.
+func (a *AuditProjectCommandRunner) Plan(ctx models.ProjectCommandContext) models.ProjectResult {
+	return a.record(ctx, a.ProjectCommandRunner.Plan)
+}
+
+// PolicyCheck runs and records the policy check for the project described by ctx.
+func (a *AuditProjectCommandRunner) PolicyCheck(ctx models.ProjectCommandContext) models.ProjectResult {
+	return a.record(ctx, a.ProjectCommandRunner.PolicyCheck)
+}
+
+// Apply runs and records the apply for the project described by ctx.
+func (a *AuditProjectCommandRunner) Apply(ctx models.ProjectCommandContext) models.ProjectResult {
+	return a.record(ctx, a.ProjectCommandRunner.Apply)
+}
+
+// ApprovePolicies runs and records the policy approval for the project
+// described by ctx.
+func (a *AuditProjectCommandRunner) ApprovePolicies(ctx models.ProjectCommandContext) models.ProjectResult {
+	return a.record(ctx, a.ProjectCommandRunner.ApprovePolicies)
+}
+
+func (a *AuditProjectCommandRunner) record(ctx models.ProjectCommandContext, runnerFunc prjCmdRunnerFunc) models.ProjectResult {
+	start := time.Now()
+	res := runnerFunc(ctx)
+
+	entry := audit.Entry{
+		Time:       start,
+		Actor:      ctx.User.Username,
+		Repo:       ctx.Pull.BaseRepo.FullName,
+		PullNum:    ctx.Pull.Num,
+		CommitSHA:  ctx.Pull.HeadCommit,
+		Command:    ctx.CommandName.String(),
+		Project:    ctx.ProjectName,
+		Dir:        ctx.RepoRelDir,
+		Workspace:  ctx.Workspace,
+		Flags:      unescapeArgs(ctx.EscapedCommentArgs),
+		Result:     audit.SuccessResult,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if res.Error != nil {
+		entry.Result = audit.ErrorResult
+		entry.Message = res.Error.Error()
+	} else if res.Failure != "" {
+		entry.Result = audit.FailureResult
+		entry.Message = res.Failure
+	}
+	if err := a.Store.Record(entry); err != nil {
+		ctx.Log.Err("recording audit entry: %s", err)
+	}
+	return res
+}
+
+// AuditCommentCommandRunner records comment commands that don't run on
+// specific projects, ex. unlock. Their outcome is read from the
+// CommandError and CommandFailure of the command's context.
+type AuditCommentCommandRunner struct {
+	CommentCommandRunner
+	Store audit.Store
+}
+
+// Run runs and records cmd.
+func (a *AuditCommentCommandRunner) Run(ctx *CommandContext, cmd *CommentCommand) {
+	start := time.Now()
+	a.CommentCommandRunner.Run(ctx, cmd)
+
+	entry := audit.Entry{
+		Time:       start,
+		Actor:      ctx.User.Username,
+		Repo:       ctx.Pull.BaseRepo.FullName,
+		PullNum:    ctx.Pull.Num,
+		CommitSHA:  ctx.Pull.HeadCommit,
+		Command:    cmd.CommandName().String(),
+		Result:     audit.SuccessResult,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if ctx.CommandError != nil {
+		entry.Result = audit.ErrorResult
+		entry.Message = ctx.CommandError.Error()
+	} else if ctx.CommandFailure != "" {
+		entry.Result = audit.FailureResult
+		entry.Message = ctx.CommandFailure
+	}
+	if err := a.Store.Record(entry); err != nil {
+		ctx.Log.Err("recording audit entry: %s", err)
+	}
+}
+
+// unescapeArgs reverses escapeArgs.
+func unescapeArgs(args []string) []string {
+	var unescaped []string
+	for _, arg := range args {
+		var unescapedArg []byte
+		for i := 1; i < len(arg); i += 2 {
+			unescapedArg = append(unescapedArg, arg[i])
+		}
+		unescaped = append(unescaped, string(unescapedArg))
+	}
+	return unescaped
+}
@@ -0,0 +1,149 @@
+package events_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/audit"
+	amocks "github.com/runatlantis/atlantis/server/events/audit/mocks"
+	amatchers "github.com/runatlantis/atlantis/server/events/audit/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models"
+	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestAuditProjectCommandRunner_Records(t *testing.T) {
+	cases := []struct {
+		description string
+		result      models.ProjectResult
+		expResult   audit.Result
+		expMessage  string
+	}{
+		{
+			"success",
+			models.ProjectResult{ApplySuccess: "applied"},
+			audit.SuccessResult,
+			"",
+		},
+		{
+			"failure",
+			models.ProjectResult{Failure: "pull request must be approved"},
+			audit.FailureResult,
+			"pull request must be approved",
+		},
+		{
+			"error",
+			models.ProjectResult{Error: errors.New("exit status 1")},
+			audit.ErrorResult,
+			"exit status 1",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			wrapped := mocks.NewMockProjectCommandRunner()
+			store := amocks.NewMockStore()
+			runner := events.AuditProjectCommandRunner{
+				ProjectCommandRunner: wrapped,
+				Store:                store,
+			}
+			ctx := models.ProjectCommandContext{
+				Log:                logging.NewNoopLogger(t),
+				CommandName:        models.ApplyCommand,
+				User:               models.User{Username: "alice"},
+				Pull:               models.PullRequest{Num: 2, HeadCommit: "abc123", BaseRepo: models.Repo{FullName: "owner/repo"}},
+				ProjectName:        "prod",
+				RepoRelDir:         "dir",
+				Workspace:          "default",
+				EscapedCommentArgs: []string{`\-\-\v\a\r`, `\a\=\b`},
+			}
+			When(wrapped.Apply(matchers.AnyModelsProjectCommandContext())).ThenReturn(c.result)
+
+			res := runner.Apply(ctx)
+			Equals(t, c.result, res)
+
+			entry := store.VerifyWasCalledOnce().Record(amatchers.AnyAuditEntry()).GetCapturedArguments()
+			Equals(t, "alice", entry.Actor)
+			Equals(t, "owner/repo", entry.Repo)
+			Equals(t, 2, entry.PullNum)
+			Equals(t, "abc123", entry.CommitSHA)
+			Equals(t, "apply", entry.Command)
+			Equals(t, "prod", entry.Project)
+			Equals(t, "dir", entry.Dir)
+			Equals(t, "default", entry.Workspace)
+			Equals(t, []string{"--var", "a=b"}, entry.Flags)
+			Equals(t, c.expResult, entry.Result)
+			Equals(t, c.expMessage, entry.Message)
+		})
+	}
+}
+
+// Test that an error recording the entry doesn't affect the result.
+func TestAuditProjectCommandRunner_RecordErr(t *testing.T) {
+	RegisterMockTestingT(t)
+	wrapped := mocks.NewMockProjectCommandRunner()
+	store := amocks.NewMockStore()
+	runner := events.AuditProjectCommandRunner{
+		ProjectCommandRunner: wrapped,
+		Store:                store,
+	}
+	exp := models.ProjectResult{PlanSuccess: &models.PlanSuccess{TerraformOutput: "output"}}
+	When(wrapped.Plan(matchers.AnyModelsProjectCommandContext())).ThenReturn(exp)
+	When(store.Record(amatchers.AnyAuditEntry())).ThenReturn(errors.New("disk full"))
+
+	res := runner.Plan(models.ProjectCommandContext{Log: logging.NewNoopLogger(t), CommandName: models.PlanCommand})
+	Equals(t, exp, res)
+}
+
+func TestAuditCommentCommandRunner_Records(t *testing.T) {
+	cases := []struct {
+		description string
+		deleteErr   error
+		expResult   audit.Result
+		expMessage  string
+	}{
+		{
+			"success",
+			nil,
+			audit.SuccessResult,
+			"",
+		},
+		{
+			"error",
+			errors.New("db locked"),
+			audit.ErrorResult,
+			"db locked",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			deleteLockCommand := mocks.NewMockDeleteLockCommand()
+			store := amocks.NewMockStore()
+			runner := events.AuditCommentCommandRunner{
+				CommentCommandRunner: events.NewUnlockCommandRunner(deleteLockCommand, vcsmocks.NewMockClient(), false),
+				Store:                store,
+			}
+			ctx := &events.CommandContext{
+				Log:  logging.NewNoopLogger(t),
+				User: models.User{Username: "alice"},
+				Pull: models.PullRequest{Num: 2, HeadCommit: "abc123", BaseRepo: models.Repo{FullName: "owner/repo"}},
+			}
+			When(deleteLockCommand.DeleteLocksByPull("owner/repo", 2)).ThenReturn(1, c.deleteErr)
+
+			runner.Run(ctx, &events.CommentCommand{Name: models.UnlockCommand})
+
+			entry := store.VerifyWasCalledOnce().Record(amatchers.AnyAuditEntry()).GetCapturedArguments()
+			Equals(t, "alice", entry.Actor)
+			Equals(t, "owner/repo", entry.Repo)
+			Equals(t, "unlock", entry.Command)
+			Equals(t, c.expResult, entry.Result)
+			Equals(t, c.expMessage, entry.Message)
+		})
+	}
+}
@@ -0,0 +1,151 @@
+// Package authz delegates command authorization decisions to an external
+// policy endpoint such as Open Policy Agent (OPA).
+package authz
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_authorizer.go Authorizer
+
+// Authorizer decides whether a command can be run.
+type Authorizer interface {
+	// Authorize returns the decision for input.
+	Authorize(input Input) (Decision, error)
+}
+
+// Input is the command context sent to the policy endpoint.
+type Input struct {
+	// Command is the command being run, ex. plan or apply.
+	Command     string   `json:"command"`
+	User        string   `json:"user"`
+	Repo        Repo     `json:"repo"`
+	Pull        Pull     `json:"pull"`
+	Project     Project  `json:"project"`
+	CommentArgs []string `json:"comment_args"`
+	Verbose     bool     `json:"verbose"`
+}
+
+// Repo is the repo in Input.
+type Repo struct {
+	FullName string `json:"full_name"`
+	Owner    string `json:"owner"`
+	Name     string `json:"name"`
+	// VCSHost is the hostname of the VCS provider, ex. github.com.
+	VCSHost string `json:"vcs_host"`
+	// VCSType is the type of VCS provider, ex. Github.
+	VCSType string `json:"vcs_type"`
+}
+
+// Pull is the pull request in Input.
+type Pull struct {
+	Num        int    `json:"num"`
+	URL        string `json:"url"`
+	Author     string `json:"author"`
+	HeadCommit string `json:"head_commit"`
+	HeadBranch string `json:"head_branch"`
+	BaseBranch string `json:"base_branch"`
+	Mergeable  bool   `json:"mergeable"`
+}
+
+// Project is the project the command is run on in Input.
+type Project struct {
+	Name      string `json:"name"`
+	Dir       string `json:"dir"`
+	Workspace string `json:"workspace"`
+	// ApplyRequirements are the apply requirements configured for the project.
+	ApplyRequirements []string `json:"apply_requirements"`
+}
+
+// Decision is the response from the policy endpoint.
+type Decision struct {
+	Allow bool
+	// Reason is an optional explanation for a denial.
+	Reason string
+}
+
+// OPAAuthorizer queries an OPA data API endpoint, ex.
+// http://localhost:8181/v1/data/atlantis/authz. The policy's result can
+// either be a boolean or an object with an "allow" boolean and an optional
+// "reason" string.
+type OPAAuthorizer struct {
+	URL string
+	// Token, if set, is sent as a bearer token.
+	Token  string
+	Client *http.Client
+}
+
+// NewOPAAuthorizer returns an OPAAuthorizer for url.
+func NewOPAAuthorizer(url string, token string) *OPAAuthorizer {
+	return &OPAAuthorizer{
+		URL:    url,
+		Token:  token,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type opaRequest struct {
+	Input Input `json:"input"`
+}
+
+type opaResponse struct {
+	Result *json.RawMessage `json:"result"`
+}
+
+type opaResultObject struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason"`
+}
+
+// Authorize POSTs input to the endpoint and parses the decision. A policy with
+// no result, ex. because it's undefined, is treated as a denial.
+func (o *OPAAuthorizer) Authorize(input Input) (Decision, error) {
+	body, err := json.Marshal(opaRequest{Input: input})
+	if err != nil {
+		return Decision{}, errors.Wrap(err, "serializing authorization input")
+	}
+	req, err := http.NewRequest("POST", o.URL, bytes.NewReader(body))
+	if err != nil {
+		return Decision{}, errors.Wrap(err, "creating authorization request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+o.Token)
+	}
+	resp, err := o.Client.Do(req)
+	if err != nil {
+		return Decision{}, errors.Wrap(err, "querying authorization endpoint")
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Decision{}, errors.Wrap(err, "reading authorization response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Decision{}, fmt.Errorf("authorization endpoint responded with %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var parsed opaResponse
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return Decision{}, errors.Wrap(err, "parsing authorization response")
+	}
+	if parsed.Result == nil {
+		return Decision{Reason: "policy is undefined"}, nil
+	}
+	var allow bool
+	if err := json.Unmarshal(*parsed.Result, &allow); err == nil {
+		return Decision{Allow: allow}, nil
+	}
+	var obj opaResultObject
+	if err := json.Unmarshal(*parsed.Result, &obj); err != nil {
+		return Decision{}, fmt.Errorf("parsing authorization result: expected a boolean or an object with an allow key, got %s", string(*parsed.Result))
+	}
+	return Decision{Allow: obj.Allow, Reason: obj.Reason}, nil
+}
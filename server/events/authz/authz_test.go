@@ -0,0 +1,93 @@
+package authz_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/authz"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestOPAAuthorizer_Authorize(t *testing.T) {
+	cases := []struct {
+		description string
+		response    string
+		exp         authz.Decision
+	}{
+		{
+			"boolean allow",
+			`{"result": true}`,
+			authz.Decision{Allow: true},
+		},
+		{
+			"boolean deny",
+			`{"result": false}`,
+			authz.Decision{Allow: false},
+		},
+		{
+			"object with reason",
+			`{"result": {"allow": false, "reason": "applies are frozen"}}`,
+			authz.Decision{Allow: false, Reason: "applies are frozen"},
+		},
+		{
+			"undefined",
+			`{}`,
+			authz.Decision{Allow: false, Reason: "policy is undefined"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			var received struct {
+				Input authz.Input `json:"input"`
+			}
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Equals(t, "Bearer token", r.Header.Get("Authorization"))
+				Ok(t, json.NewDecoder(r.Body).Decode(&received))
+				w.Write([]byte(c.response)) // nolint: errcheck
+			}))
+			defer ts.Close()
+
+			input := authz.Input{Command: "apply", User: "alice", Project: authz.Project{Dir: "."}}
+			decision, err := authz.NewOPAAuthorizer(ts.URL, "token").Authorize(input)
+			Ok(t, err)
+			Equals(t, c.exp, decision)
+			Equals(t, input, received.Input)
+		})
+	}
+}
+
+func TestOPAAuthorizer_AuthorizeErrs(t *testing.T) {
+	cases := []struct {
+		description string
+		status      int
+		response    string
+		expErr      string
+	}{
+		{
+			"non-200",
+			http.StatusInternalServerError,
+			"oops",
+			"authorization endpoint responded with 500: oops",
+		},
+		{
+			"invalid result",
+			http.StatusOK,
+			`{"result": "yes"}`,
+			`parsing authorization result: expected a boolean or an object with an allow key, got "yes"`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(c.status)
+				w.Write([]byte(c.response)) // nolint: errcheck
+			}))
+			defer ts.Close()
+
+			_, err := authz.NewOPAAuthorizer(ts.URL, "").Authorize(authz.Input{})
+			ErrEquals(t, c.expErr, err)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package events
+
+import (
+	"fmt"
+
+	"github.com/runatlantis/atlantis/server/events/authz"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// AuthzProjectCommandRunner asks an external authorizer whether each project
+// command can be run before running it with the wrapped runner.
+type AuthzProjectCommandRunner struct {
+	ProjectCommandRunner
+	Authorizer authz.Authorizer
+}
+
+// Plan authorizes and runs the plan for the project described by ctx.
+func (a *AuthzProjectCommandRunner) Plan(ctx models.ProjectCommandContext) models.ProjectResult {
+	return a.authorize(ctx, models.PlanCommand, a.ProjectCommandRunner.Plan)
+}
+
+// PolicyCheck authorizes and runs the policy check for the project described
+// by ctx.
+func (a *AuthzProjectCommandRunner) PolicyCheck(ctx models.ProjectCommandContext) models.ProjectResult {
+	return a.authorize(ctx, models.PolicyCheckCommand, a.ProjectCommandRunner.PolicyCheck)
+}
+
+// Apply authorizes and runs the apply for the project described by ctx.
+func (a *AuthzProjectCommandRunner) Apply(ctx models.ProjectCommandContext) models.ProjectResult {
+	return a.authorize(ctx, models.ApplyCommand, a.ProjectCommandRunner.Apply)
+}
+
+// ApprovePolicies authorizes and runs the policy approval for the project
+// described by ctx.
+func (a *AuthzProjectCommandRunner) ApprovePolicies(ctx models.ProjectCommandContext) models.ProjectResult {
+	return a.authorize(ctx, models.PolicyCheckCommand, a.ProjectCommandRunner.ApprovePolicies)
+}
+
+// authorize runs runnerFunc if the authorizer allows it. resultCmd is the
+// command set on the result if it doesn't run, to match runnerFunc's results.
+func (a *AuthzProjectCommandRunner) authorize(ctx models.ProjectCommandContext, resultCmd models.CommandName, runnerFunc prjCmdRunnerFunc) models.ProjectResult {
+	decision, err := a.Authorizer.Authorize(authzInput(ctx))
+	if err == nil && decision.Allow {
+		return runnerFunc(ctx)
+	}
+
+	res := models.ProjectResult{
+		Command:     resultCmd,
+		RepoRelDir:  ctx.RepoRelDir,
+		Workspace:   ctx.Workspace,
+		ProjectName: ctx.ProjectName,
+	}
+	if err != nil {
+		ctx.Log.Err("checking authorization: %s", err)
+		res.Error = fmt.Errorf("checking authorization: %s", err)
+		return res
+	}
+	ctx.Log.Info("%s is not authorized to run %s", ctx.User.Username, ctx.CommandName.String())
+	res.Failure = fmt.Sprintf("User @%s is not authorized to run %s on this project.", ctx.User.Username, ctx.CommandName.String())
+	if decision.Reason != "" {
+		res.Failure += " Reason: " + decision.Reason
+	}
+	return res
+}
+
+func authzInput(ctx models.ProjectCommandContext) authz.Input {
+	return authz.Input{
+		Command: ctx.CommandName.String(),
+		User:    ctx.User.Username,
+		Repo: authz.Repo{
+			FullName: ctx.BaseRepo.FullName,
+			Owner:    ctx.BaseRepo.Owner,
+			Name:     ctx.BaseRepo.Name,
+			VCSHost:  ctx.BaseRepo.VCSHost.Hostname,
+			VCSType:  ctx.BaseRepo.VCSHost.Type.String(),
+		},
+		Pull: authz.Pull{
+			Num:        ctx.Pull.Num,
+			URL:        ctx.Pull.URL,
+			Author:     ctx.Pull.Author,
+			HeadCommit: ctx.Pull.HeadCommit,
+			HeadBranch: ctx.Pull.HeadBranch,
+			BaseBranch: ctx.Pull.BaseBranch,
+			Mergeable:  ctx.PullMergeable,
+		},
+		Project: authz.Project{
+			Name:              ctx.ProjectName,
+			Dir:               ctx.RepoRelDir,
+			Workspace:         ctx.Workspace,
+			ApplyRequirements: ctx.ApplyRequirements,
+		},
+		CommentArgs: unescapeArgs(ctx.EscapedCommentArgs),
+		Verbose:     ctx.Verbose,
+	}
+}
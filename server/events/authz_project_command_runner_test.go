@@ -0,0 +1,119 @@
+package events_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/authz"
+	authzmocks "github.com/runatlantis/atlantis/server/events/authz/mocks"
+	authzmatchers "github.com/runatlantis/atlantis/server/events/authz/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestAuthzProjectCommandRunner_Allowed(t *testing.T) {
+	RegisterMockTestingT(t)
+	wrapped := mocks.NewMockProjectCommandRunner()
+	authorizer := authzmocks.NewMockAuthorizer()
+	runner := events.AuthzProjectCommandRunner{
+		ProjectCommandRunner: wrapped,
+		Authorizer:           authorizer,
+	}
+	ctx := models.ProjectCommandContext{
+		Log:                logging.NewNoopLogger(t),
+		CommandName:        models.ApplyCommand,
+		User:               models.User{Username: "alice"},
+		BaseRepo:           models.Repo{FullName: "owner/repo", Owner: "owner", Name: "repo", VCSHost: models.VCSHost{Hostname: "github.com", Type: models.Github}},
+		Pull:               models.PullRequest{Num: 2, Author: "bob", HeadCommit: "abc123", BaseBranch: "main", HeadBranch: "feature"},
+		ProjectName:        "prod",
+		RepoRelDir:         "dir",
+		Workspace:          "default",
+		ApplyRequirements:  []string{"approved"},
+		EscapedCommentArgs: []string{`\-\-\v\a\r`},
+	}
+	exp := models.ProjectResult{ApplySuccess: "applied"}
+	When(authorizer.Authorize(authzmatchers.AnyAuthzInput())).ThenReturn(authz.Decision{Allow: true}, nil)
+	When(wrapped.Apply(matchers.AnyModelsProjectCommandContext())).ThenReturn(exp)
+
+	Equals(t, exp, runner.Apply(ctx))
+	input := authorizer.VerifyWasCalledOnce().Authorize(authzmatchers.AnyAuthzInput()).GetCapturedArguments()
+	Equals(t, authz.Input{
+		Command: "apply",
+		User:    "alice",
+		Repo:    authz.Repo{FullName: "owner/repo", Owner: "owner", Name: "repo", VCSHost: "github.com", VCSType: "Github"},
+		Pull:    authz.Pull{Num: 2, Author: "bob", HeadCommit: "abc123", BaseBranch: "main", HeadBranch: "feature"},
+		Project: authz.Project{
+			Name:              "prod",
+			Dir:               "dir",
+			Workspace:         "default",
+			ApplyRequirements: []string{"approved"},
+		},
+		CommentArgs: []string{"--var"},
+	}, input)
+}
+
+func TestAuthzProjectCommandRunner_Denied(t *testing.T) {
+	cases := []struct {
+		description string
+		decision    authz.Decision
+		err         error
+		expFailure  string
+		expErr      string
+	}{
+		{
+			"denied",
+			authz.Decision{Allow: false},
+			nil,
+			"User @alice is not authorized to run plan on this project.",
+			"",
+		},
+		{
+			"denied with reason",
+			authz.Decision{Allow: false, Reason: "not an owner"},
+			nil,
+			"User @alice is not authorized to run plan on this project. Reason: not an owner",
+			"",
+		},
+		{
+			"error",
+			authz.Decision{},
+			errors.New("timeout"),
+			"",
+			"checking authorization: timeout",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			wrapped := mocks.NewMockProjectCommandRunner()
+			authorizer := authzmocks.NewMockAuthorizer()
+			runner := events.AuthzProjectCommandRunner{
+				ProjectCommandRunner: wrapped,
+				Authorizer:           authorizer,
+			}
+			When(authorizer.Authorize(authzmatchers.AnyAuthzInput())).ThenReturn(c.decision, c.err)
+
+			res := runner.Plan(models.ProjectCommandContext{
+				Log:         logging.NewNoopLogger(t),
+				CommandName: models.PlanCommand,
+				User:        models.User{Username: "alice"},
+				RepoRelDir:  "dir",
+				Workspace:   "default",
+			})
+			Equals(t, models.PlanCommand, res.Command)
+			Equals(t, "dir", res.RepoRelDir)
+			Equals(t, c.expFailure, res.Failure)
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, res.Error)
+			} else {
+				Ok(t, res.Error)
+			}
+			wrapped.VerifyWasCalled(Never()).Plan(matchers.AnyModelsProjectCommandContext())
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package events
 
 import (
+	"bytes"
 	"fmt"
+	"strings"
 
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/vcs"
@@ -10,6 +12,17 @@ import (
 type AutoMerger struct {
 	VCSClient       vcs.Client
 	GlobalAutomerge bool
+	// Templates overrides the automergeCommitMessage template. If nil, the
+	// default template is used.
+	Templates *CommentTemplates
+}
+
+// automergeCommitMessageData is the data of the automergeCommitMessage
+// template.
+type automergeCommitMessageData struct {
+	Pull models.PullRequest
+	// Projects are the applied projects of the pull request.
+	Projects []models.ProjectStatus
 }
 
 func (c *AutoMerger) automerge(ctx *CommandContext, pullStatus models.PullStatus, deleteSourceBranchOnMerge bool) {
@@ -31,6 +44,14 @@ func (c *AutoMerger) automerge(ctx *CommandContext, pullStatus models.PullStatus
 	ctx.Log.Info("automerging pull request")
 	var pullOptions models.PullRequestOptions
 	pullOptions.DeleteSourceBranchOnMerge = deleteSourceBranchOnMerge
+	buf := &bytes.Buffer{}
+	data := automergeCommitMessageData{Pull: ctx.Pull, Projects: pullStatus.Projects}
+	if err := c.Templates.For(ctx.Pull.BaseRepo).ExecuteTemplate(buf, automergeCommitMessageTmpl, data); err != nil {
+		// The VCS host's default message is used instead.
+		ctx.Log.Err("unable to render automerge commit message: %s", err)
+		buf.Reset()
+	}
+	pullOptions.CommitMessage = strings.TrimSpace(buf.String())
 	err := c.VCSClient.MergePull(ctx.Pull, pullOptions)
 
 	if err != nil {
@@ -56,3 +77,8 @@ func (c *AutoMerger) deleteSourceBranchOnMergeEnabled(projectCmds []models.Proje
 	//check if this repo is configured for automerging.
 	return (len(projectCmds) > 0 && projectCmds[0].DeleteSourceBranchOnMerge)
 }
+
+// automergeCommitMessageTmpl is the message of the merge commit of
+// automerged pull requests. It's empty unless it's overridden, in which case
+// the VCS host's default message is used.
+var automergeCommitMessageTmpl = commentTemplate("automergeCommitMessage", "")
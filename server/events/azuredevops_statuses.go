@@ -0,0 +1,37 @@
+package events
+
+import (
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// AzureDevopsStatusClient re-posts statuses on a pull request's latest
+// iteration. It's implemented by vcs.AzureDevopsClient.
+type AzureDevopsStatusClient interface {
+	PropagateStatuses(repo models.Repo, pull models.PullRequest) (int, error)
+}
+
+// AzureDevopsStatuses carries the Atlantis statuses of Azure DevOps pull
+// requests over to the iterations created by new pushes, so branch policies
+// keep requiring a plan until Atlantis posts statuses for the new commits.
+// Its methods do nothing if it's nil or the repo isn't an Azure DevOps repo.
+type AzureDevopsStatuses struct {
+	Client AzureDevopsStatusClient
+}
+
+// Propagate re-posts the latest Atlantis statuses of pull on its latest
+// iteration. Errors are only logged so the pull request event is still
+// handled.
+func (a *AzureDevopsStatuses) Propagate(log logging.SimpleLogging, repo models.Repo, pull models.PullRequest) {
+	if a == nil || repo.VCSHost.Type != models.AzureDevops {
+		return
+	}
+	propagated, err := a.Client.PropagateStatuses(repo, pull)
+	if err != nil {
+		log.Warn("unable to propagate statuses to the latest iteration: %s", err)
+		return
+	}
+	if propagated > 0 {
+		log.Info("propagated %d statuses to the latest iteration", propagated)
+	}
+}
@@ -0,0 +1,48 @@
+package events_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+type fakeStatusClient struct {
+	pulls []int
+	err   error
+}
+
+func (f *fakeStatusClient) PropagateStatuses(repo models.Repo, pull models.PullRequest) (int, error) {
+	f.pulls = append(f.pulls, pull.Num)
+	return 1, f.err
+}
+
+func TestAzureDevopsStatuses_Propagate(t *testing.T) {
+	client := &fakeStatusClient{}
+	s := &events.AzureDevopsStatuses{Client: client}
+	repo := models.Repo{FullName: "owner/project/repo", VCSHost: models.VCSHost{Type: models.AzureDevops}}
+
+	s.Propagate(logging.NewNoopLogger(t), repo, models.PullRequest{Num: 1})
+	Equals(t, []int{1}, client.pulls)
+
+	// Errors are only logged.
+	client.err = errors.New("err")
+	s.Propagate(logging.NewNoopLogger(t), repo, models.PullRequest{Num: 2})
+	Equals(t, []int{1, 2}, client.pulls)
+}
+
+func TestAzureDevopsStatuses_Propagate_OtherHosts(t *testing.T) {
+	client := &fakeStatusClient{}
+	s := &events.AzureDevopsStatuses{Client: client}
+	repo := models.Repo{FullName: "owner/repo", VCSHost: models.VCSHost{Type: models.Github}}
+
+	s.Propagate(logging.NewNoopLogger(t), repo, models.PullRequest{Num: 1})
+	Equals(t, 0, len(client.pulls))
+
+	// A nil AzureDevopsStatuses does nothing.
+	var nilStatuses *events.AzureDevopsStatuses
+	nilStatuses.Propagate(logging.NewNoopLogger(t), repo, models.PullRequest{Num: 1})
+}
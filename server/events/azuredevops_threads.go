@@ -0,0 +1,39 @@
+package events
+
+import (
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// AzureDevopsThreadClient closes the comment threads of plans. It's
+// implemented by vcs.AzureDevopsClient.
+type AzureDevopsThreadClient interface {
+	ClosePlanThreads(repo models.Repo, pullNum int) (int, error)
+}
+
+// AzureDevopsPlanThreads closes the comment threads of the plans of Azure
+// DevOps pull requests as fixed once they're all applied, so the pull
+// request's active comments are only the ones reviewers need to act on. Its
+// methods do nothing if it's nil or the repo isn't an Azure DevOps repo.
+type AzureDevopsPlanThreads struct {
+	Client AzureDevopsThreadClient
+}
+
+// Close closes the plan threads of ctx's pull request if every project in
+// pullStatus has been applied. Errors are only logged since the applies
+// already ran.
+func (a *AzureDevopsPlanThreads) Close(ctx *CommandContext, pullStatus models.PullStatus) {
+	if a == nil || ctx.Pull.BaseRepo.VCSHost.Type != models.AzureDevops {
+		return
+	}
+	if len(pullStatus.Projects) == 0 || pullStatus.StatusCount(models.AppliedPlanStatus) < len(pullStatus.Projects) {
+		return
+	}
+	closed, err := a.Client.ClosePlanThreads(ctx.Pull.BaseRepo, ctx.Pull.Num)
+	if err != nil {
+		ctx.Log.Warn("unable to close plan threads: %s", err)
+		return
+	}
+	if closed > 0 {
+		ctx.Log.Info("closed %d plan threads", closed)
+	}
+}
@@ -0,0 +1,82 @@
+package events_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+type fakeThreadClient struct {
+	pulls []int
+}
+
+func (f *fakeThreadClient) ClosePlanThreads(repo models.Repo, pullNum int) (int, error) {
+	f.pulls = append(f.pulls, pullNum)
+	return 1, nil
+}
+
+func TestAzureDevopsPlanThreads_Close(t *testing.T) {
+	repo := models.Repo{FullName: "owner/project/repo", VCSHost: models.VCSHost{Type: models.AzureDevops}}
+	ctx := &events.CommandContext{
+		Log:  logging.NewNoopLogger(t),
+		Pull: models.PullRequest{Num: 1, BaseRepo: repo},
+	}
+	cases := []struct {
+		description string
+		statuses    []models.ProjectPlanStatus
+		expClosed   bool
+	}{
+		{
+			"all applied",
+			[]models.ProjectPlanStatus{models.AppliedPlanStatus, models.AppliedPlanStatus},
+			true,
+		},
+		{
+			"unapplied plan",
+			[]models.ProjectPlanStatus{models.AppliedPlanStatus, models.PlannedPlanStatus},
+			false,
+		},
+		{
+			"errored apply",
+			[]models.ProjectPlanStatus{models.ErroredApplyStatus},
+			false,
+		},
+		{
+			"no projects",
+			nil,
+			false,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			client := &fakeThreadClient{}
+			threads := &events.AzureDevopsPlanThreads{Client: client}
+			var pullStatus models.PullStatus
+			for _, s := range c.statuses {
+				pullStatus.Projects = append(pullStatus.Projects, models.ProjectStatus{Status: s})
+			}
+			threads.Close(ctx, pullStatus)
+			Equals(t, c.expClosed, len(client.pulls) == 1)
+		})
+	}
+}
+
+func TestAzureDevopsPlanThreads_Close_OtherHosts(t *testing.T) {
+	client := &fakeThreadClient{}
+	threads := &events.AzureDevopsPlanThreads{Client: client}
+	ctx := &events.CommandContext{
+		Log:  logging.NewNoopLogger(t),
+		Pull: models.PullRequest{Num: 1, BaseRepo: models.Repo{FullName: "owner/repo", VCSHost: models.VCSHost{Type: models.Github}}},
+	}
+	pullStatus := models.PullStatus{Projects: []models.ProjectStatus{{Status: models.AppliedPlanStatus}}}
+
+	threads.Close(ctx, pullStatus)
+	Equals(t, 0, len(client.pulls))
+
+	// A nil AzureDevopsPlanThreads does nothing.
+	var nilThreads *events.AzureDevopsPlanThreads
+	nilThreads.Close(ctx, pullStatus)
+}
@@ -0,0 +1,141 @@
+package events
+
+import (
+	"crypto/sha1" // nolint: gosec
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs/bitbucketserver"
+)
+
+// Bitbucket rejects reports and annotations with longer details and
+// messages, and reports with more annotations.
+const (
+	maxInsightDetailsLength = 2000
+	maxInsightAnnotations   = 1000
+)
+
+// BitbucketInsightsClient creates Bitbucket Server Code Insights reports. It's
+// implemented by bitbucketserver.Client.
+type BitbucketInsightsClient interface {
+	CreateInsightReport(repo models.Repo, commit string, key string, report bitbucketserver.InsightReport) error
+	CreateInsightAnnotations(repo models.Repo, commit string, key string, annotations []bitbucketserver.InsightAnnotation) error
+}
+
+// BitbucketInsights publishes the plans of projects in Bitbucket Server repos
+// as Code Insights reports on the pull request's head commit, with the
+// numbers of resources to add, change and destroy and annotations on the
+// lines of Terraform's errors. Its methods do nothing if it's nil or the repo
+// isn't a Bitbucket Server repo.
+type BitbucketInsights struct {
+	Client BitbucketInsightsClient
+}
+
+// PublishPlan publishes the report of the plan of ctx, which had result.
+// Errors are only logged since the plan already ran.
+func (b *BitbucketInsights) PublishPlan(ctx models.ProjectCommandContext, result models.ProjectResult) {
+	if b == nil || ctx.Pull.BaseRepo.VCSHost.Type != models.BitbucketServer {
+		return
+	}
+	projectID := ctx.ProjectName
+	if projectID == "" {
+		projectID = fmt.Sprintf("%s/%s", ctx.RepoRelDir, ctx.Workspace)
+	}
+	report := bitbucketserver.InsightReport{
+		Title:    "Atlantis plan: " + projectID,
+		Reporter: "Atlantis",
+		Link:     result.OutputURL,
+		Result:   bitbucketserver.InsightPass,
+	}
+	var annotations []bitbucketserver.InsightAnnotation
+	switch {
+	case result.Error != nil:
+		report.Result = bitbucketserver.InsightFail
+		report.Details = "Plan failed."
+		annotations = PlanErrorAnnotations(ctx.RepoRelDir, result.Error.Error())
+	case result.Failure != "":
+		report.Result = bitbucketserver.InsightFail
+		report.Details = truncate(result.Failure, maxInsightDetailsLength)
+	case result.PlanSuccess != nil:
+		report.Details = result.PlanSuccess.Summary()
+		if counts := result.PlanSuccess.Counts(); counts != nil {
+			report.Data = []bitbucketserver.InsightData{
+				{Title: "To add", Type: "NUMBER", Value: counts.Add},
+				{Title: "To change", Type: "NUMBER", Value: counts.Change},
+				{Title: "To destroy", Type: "NUMBER", Value: counts.Destroy},
+			}
+		}
+	}
+
+	key := insightReportKey(projectID)
+	if err := b.Client.CreateInsightReport(ctx.Pull.BaseRepo, ctx.Pull.HeadCommit, key, report); err != nil {
+		ctx.Log.Warn("unable to publish Code Insights report: %s", err)
+		return
+	}
+	// The annotations of the previous plan of the commit are replaced, even
+	// if there are none now.
+	if err := b.Client.CreateInsightAnnotations(ctx.Pull.BaseRepo, ctx.Pull.HeadCommit, key, annotations); err != nil {
+		ctx.Log.Warn("unable to publish Code Insights annotations: %s", err)
+	}
+}
+
+// insightReportKey returns the key of the report of the project projectID.
+// Keys are part of the report's URL so they're hashed instead of escaped.
+func insightReportKey(projectID string) string {
+	return fmt.Sprintf("atlantis-plan-%x", sha1.Sum([]byte(projectID)))[:30] // nolint: gosec
+}
+
+// diagnosticRangeRegex matches the line of a Terraform diagnostic that says
+// where it is. Its groups are the file and line.
+var diagnosticRangeRegex = regexp.MustCompile(`^on (\S+) line (\d+)`)
+
+// PlanErrorAnnotations returns annotations of the files and lines of the
+// errors in output, the output of a failed plan of the project in
+// repoRelDir. Errors in files outside of the repo, ex. downloaded modules,
+// aren't annotated.
+func PlanErrorAnnotations(repoRelDir string, output string) []bitbucketserver.InsightAnnotation {
+	var annotations []bitbucketserver.InsightAnnotation
+	var summary string
+	for _, line := range strings.Split(output, "\n") {
+		// Terraform >= 0.15 draws a box around diagnostics.
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "│╷╵"))
+		if strings.HasPrefix(line, "Error: ") {
+			summary = strings.TrimPrefix(line, "Error: ")
+			continue
+		}
+		match := diagnosticRangeRegex.FindStringSubmatch(line)
+		if summary == "" || match == nil {
+			continue
+		}
+		file := path.Join(repoRelDir, match[1])
+		lineNum, _ := strconv.Atoi(match[2])
+		if strings.HasPrefix(file, "../") || strings.HasPrefix(file, ".terraform/") || strings.Contains(file, "/.terraform/") {
+			summary = ""
+			continue
+		}
+		annotations = append(annotations, bitbucketserver.InsightAnnotation{
+			Path:     file,
+			Line:     lineNum,
+			Message:  truncate(summary, maxInsightDetailsLength),
+			Severity: "HIGH",
+			Type:     "BUG",
+		})
+		summary = ""
+		if len(annotations) == maxInsightAnnotations {
+			break
+		}
+	}
+	return annotations
+}
+
+// truncate returns s cut to at most max bytes.
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max-3] + "..."
+}
@@ -0,0 +1,128 @@
+package events_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs/bitbucketserver"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+type fakeInsightsClient struct {
+	commit      string
+	keys        []string
+	report      *bitbucketserver.InsightReport
+	annotations []bitbucketserver.InsightAnnotation
+}
+
+func (f *fakeInsightsClient) CreateInsightReport(repo models.Repo, commit string, key string, report bitbucketserver.InsightReport) error {
+	f.commit = commit
+	f.keys = append(f.keys, key)
+	f.report = &report
+	return nil
+}
+
+func (f *fakeInsightsClient) CreateInsightAnnotations(repo models.Repo, commit string, key string, annotations []bitbucketserver.InsightAnnotation) error {
+	f.keys = append(f.keys, key)
+	f.annotations = annotations
+	return nil
+}
+
+func insightsCtx(t *testing.T) models.ProjectCommandContext {
+	repo := models.Repo{FullName: "owner/repo", VCSHost: models.VCSHost{Type: models.BitbucketServer}}
+	return models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(t),
+		Pull:       models.PullRequest{Num: 1, HeadCommit: "abc123", BaseRepo: repo},
+		HeadRepo:   repo,
+		RepoRelDir: "dir",
+		Workspace:  "default",
+	}
+}
+
+func TestBitbucketInsights_PublishPlan(t *testing.T) {
+	client := &fakeInsightsClient{}
+	i := &events.BitbucketInsights{Client: client}
+
+	i.PublishPlan(insightsCtx(t), models.ProjectResult{
+		OutputURL: "url",
+		PlanSuccess: &models.PlanSuccess{
+			TerraformOutput: "Plan: 1 to add, 2 to change, 3 to destroy.",
+		},
+	})
+	Equals(t, "abc123", client.commit)
+	Equals(t, 2, len(client.keys))
+	Equals(t, client.keys[0], client.keys[1])
+	Equals(t, &bitbucketserver.InsightReport{
+		Title:    "Atlantis plan: dir/default",
+		Details:  "Plan: 1 to add, 2 to change, 3 to destroy.",
+		Result:   bitbucketserver.InsightPass,
+		Reporter: "Atlantis",
+		Link:     "url",
+		Data: []bitbucketserver.InsightData{
+			{Title: "To add", Type: "NUMBER", Value: 1},
+			{Title: "To change", Type: "NUMBER", Value: 2},
+			{Title: "To destroy", Type: "NUMBER", Value: 3},
+		},
+	}, client.report)
+	Equals(t, 0, len(client.annotations))
+}
+
+func TestBitbucketInsights_PublishPlanError(t *testing.T) {
+	client := &fakeInsightsClient{}
+	i := &events.BitbucketInsights{Client: client}
+
+	i.PublishPlan(insightsCtx(t), models.ProjectResult{
+		Error: errors.New(`exit status 1
+╷
+│ Error: Unsupported argument
+│ 
+│   on main.tf line 3, in resource "null_resource" "this":
+│    3:   foo = "bar"
+╵
+`),
+	})
+	Equals(t, bitbucketserver.InsightFail, client.report.Result)
+	Equals(t, []bitbucketserver.InsightAnnotation{
+		{Path: "dir/main.tf", Line: 3, Message: "Unsupported argument", Severity: "HIGH", Type: "BUG"},
+	}, client.annotations)
+}
+
+func TestBitbucketInsights_PublishPlanSkipped(t *testing.T) {
+	var i *events.BitbucketInsights
+	i.PublishPlan(insightsCtx(t), models.ProjectResult{})
+
+	client := &fakeInsightsClient{}
+	i = &events.BitbucketInsights{Client: client}
+	ctx := insightsCtx(t)
+	ctx.Pull.BaseRepo.VCSHost.Type = models.BitbucketCloud
+	i.PublishPlan(ctx, models.ProjectResult{})
+	Equals(t, 0, len(client.keys))
+}
+
+func TestPlanErrorAnnotations(t *testing.T) {
+	output := `Error: Missing required argument
+
+  on main.tf line 10, in resource "aws_instance" "web":
+  10: resource "aws_instance" "web" {
+
+Error: Invalid reference
+
+  on .terraform/modules/vpc/main.tf line 2:
+
+Error: Unsupported block type
+
+  on ../shared/vars.tf line 5:
+
+Error: No configuration files
+`
+	Equals(t, []bitbucketserver.InsightAnnotation{
+		{Path: "modules/a/main.tf", Line: 10, Message: "Missing required argument", Severity: "HIGH", Type: "BUG"},
+		{Path: "modules/shared/vars.tf", Line: 5, Message: "Unsupported block type", Severity: "HIGH", Type: "BUG"},
+	}, events.PlanErrorAnnotations("modules/a", output))
+	Equals(t, []bitbucketserver.InsightAnnotation{
+		{Path: "main.tf", Line: 10, Message: "Missing required argument", Severity: "HIGH", Type: "BUG"},
+	}, events.PlanErrorAnnotations(".", output))
+}
@@ -0,0 +1,37 @@
+package events
+
+import (
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs/bitbucketcloud"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// BitbucketOAuthWorkingDir implements WorkingDir.
+// It acts as a proxy to an instance of WorkingDir that writes the current
+// OAuth access token to ~/.git-credentials before every clone, given Bitbucket
+// Cloud OAuth access tokens expire after two hours. Clone URLs already have
+// the token but modules and other repos are fetched with ~/.git-credentials.
+type BitbucketOAuthWorkingDir struct {
+	WorkingDir
+	Credentials *bitbucketcloud.Credentials
+}
+
+// Clone writes a fresh token for Bitbucket Cloud OAuth authentication.
+func (b *BitbucketOAuthWorkingDir) Clone(log logging.SimpleLogging, headRepo models.Repo, p models.PullRequest, workspace string) (string, bool, error) {
+	if p.BaseRepo.VCSHost.Type == models.BitbucketCloud {
+		user, token, err := b.Credentials.GitCredentials()
+		if err != nil {
+			return "", false, errors.Wrap(err, "getting Bitbucket Cloud token")
+		}
+		home, err := homedir.Dir()
+		if err != nil {
+			return "", false, errors.Wrap(err, "getting home dir to write ~/.git-credentials file")
+		}
+		if err := WriteGitCreds(user, token, "bitbucket.org", home, log, true); err != nil {
+			return "", false, err
+		}
+	}
+	return b.WorkingDir.Clone(log, headRepo, p, workspace)
+}
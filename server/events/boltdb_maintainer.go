@@ -0,0 +1,125 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/db"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// Scheduled maintenance compacts the BoltDB file once at least
+// BoltDBCompactFreeRatio of it and BoltDBCompactMinFreeBytes are free pages.
+// Reclaiming less isn't worth blocking other operations.
+const (
+	BoltDBCompactFreeRatio    = 0.25
+	BoltDBCompactMinFreeBytes = 1024 * 1024
+)
+
+// BoltDBReport is the outcome of maintaining the BoltDB file.
+type BoltDBReport struct {
+	Time time.Time `json:"time"`
+	// Compacted is true if the file was compacted and ReclaimedBytes is by
+	// how much it shrank.
+	Compacted      bool  `json:"compacted"`
+	ReclaimedBytes int64 `json:"reclaimed_bytes"`
+	// Problems are the integrity problems found. The file isn't compacted if
+	// there are any.
+	Problems []string `json:"problems,omitempty"`
+}
+
+// BoltDBStatus is the current size of the BoltDB file and the outcome of
+// its last maintenance.
+type BoltDBStatus struct {
+	FileSizeBytes int64 `json:"file_size_bytes"`
+	// FreeBytes is the size of the free pages that compacting would reclaim.
+	FreeBytes int64 `json:"free_bytes"`
+	// Keys is the number of keys in each bucket.
+	Keys            map[string]int `json:"keys"`
+	LastMaintenance *BoltDBReport  `json:"last_maintenance,omitempty"`
+}
+
+// BoltDBMaintainer checks the integrity of the BoltDB file and compacts it.
+// BoltDB never shrinks its file so long-running servers accumulate free
+// pages left behind by deleted locks and pull statuses.
+type BoltDBMaintainer struct {
+	DB     *db.BoltDB
+	Logger logging.SimpleLogging
+	// Interval is how often the file is maintained by Run.
+	Interval time.Duration
+	// Now returns the current time. It's only overridden in tests.
+	Now func() time.Time
+
+	// mutex ensures the file is only maintained by one goroutine at a time
+	// and guards last.
+	mutex sync.Mutex
+	last  *BoltDBReport
+}
+
+// Run maintains the file every Interval until ctx is done.
+func (m *BoltDBMaintainer) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := m.Maintain(false); err != nil {
+				m.Logger.Err("failed maintaining BoltDB: %s", err)
+			}
+		}
+	}
+}
+
+// Maintain checks the integrity of the file and compacts it if enough of it
+// is free pages or if force is true.
+func (m *BoltDBMaintainer) Maintain(force bool) (BoltDBReport, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	report := BoltDBReport{Time: m.Now()}
+	problems, err := m.DB.Check()
+	if err != nil {
+		return report, err
+	}
+	report.Problems = problems
+	for _, p := range problems {
+		m.Logger.Err("BoltDB integrity problem: %s", p)
+	}
+	stats, err := m.DB.Stats()
+	if err != nil {
+		return report, err
+	}
+	compact := force || (stats.FreeSize >= BoltDBCompactMinFreeBytes &&
+		float64(stats.FreeSize) >= BoltDBCompactFreeRatio*float64(stats.FileSize))
+	if compact && len(problems) == 0 {
+		before, after, err := m.DB.Compact()
+		if err != nil {
+			return report, err
+		}
+		report.Compacted = true
+		report.ReclaimedBytes = before - after
+		m.Logger.Info("compacted BoltDB from %d to %d bytes", before, after)
+	}
+	m.last = &report
+	return report, nil
+}
+
+// Status returns the current size of the file and the outcome of its last
+// maintenance.
+func (m *BoltDBMaintainer) Status() (BoltDBStatus, error) {
+	stats, err := m.DB.Stats()
+	if err != nil {
+		return BoltDBStatus{}, err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return BoltDBStatus{
+		FileSizeBytes:   stats.FileSize,
+		FreeBytes:       stats.FreeSize,
+		Keys:            stats.Keys,
+		LastMaintenance: m.last,
+	}, nil
+}
@@ -0,0 +1,61 @@
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/db"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/models/fixtures"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestBoltDBMaintainer_Maintain(t *testing.T) {
+	dataDir, cleanup := TempDir(t)
+	defer cleanup()
+	boltDB, err := db.New(dataDir)
+	Ok(t, err)
+	defer boltDB.Close() // nolint: errcheck
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	m := &events.BoltDBMaintainer{
+		DB:       boltDB,
+		Logger:   logging.NewNoopLogger(t),
+		Interval: time.Hour,
+		Now:      func() time.Time { return now },
+	}
+
+	status, err := m.Status()
+	Ok(t, err)
+	Assert(t, status.LastMaintenance == nil, "exp no maintenance yet")
+
+	// A new file has no free pages to reclaim.
+	report, err := m.Maintain(false)
+	Ok(t, err)
+	Equals(t, events.BoltDBReport{Time: now}, report)
+
+	var pulls []models.PullRequest
+	for i := 1; i <= 100; i++ {
+		pull := models.PullRequest{Num: i, BaseRepo: fixtures.GithubRepo}
+		_, err := boltDB.UpdatePullWithResults(pull, []models.ProjectResult{
+			{RepoRelDir: string(make([]byte, 4096)), Workspace: "default", PlanSuccess: &models.PlanSuccess{}},
+		})
+		Ok(t, err)
+		pulls = append(pulls, pull)
+	}
+	for _, pull := range pulls[1:] {
+		Ok(t, boltDB.DeletePullStatus(pull))
+	}
+	report, err = m.Maintain(false)
+	Ok(t, err)
+	Assert(t, report.Compacted, "exp file that's mostly free pages to be compacted")
+	Assert(t, report.ReclaimedBytes > 0, "exp bytes to be reclaimed")
+
+	status, err = m.Status()
+	Ok(t, err)
+	Equals(t, &report, status.LastMaintenance)
+	Equals(t, 1, status.Keys["pulls"])
+	_, err = boltDB.GetPullStatus(pulls[0])
+	Ok(t, err)
+}
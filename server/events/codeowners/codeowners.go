@@ -0,0 +1,163 @@
+// Package codeowners parses CODEOWNERS files.
+package codeowners
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Locations are the paths, relative to the repo root, that are searched for a
+// CODEOWNERS file, in order.
+var Locations = []string{
+	".github/CODEOWNERS",
+	".gitlab/CODEOWNERS",
+	"docs/CODEOWNERS",
+	"CODEOWNERS",
+}
+
+// File is a parsed CODEOWNERS file.
+type File struct {
+	rules []rule
+}
+
+type rule struct {
+	pattern string
+	regex   *regexp.Regexp
+	owners  []string
+}
+
+// ErrNotFound is returned by FindInRef when there's no CODEOWNERS file.
+var ErrNotFound = errors.New("no CODEOWNERS file found")
+
+// FindInRef parses the first CODEOWNERS file found in ref, ex.
+// "refs/remotes/origin/main", of the git repo in repoDir. Reading it from a
+// ref rather than the working tree means a pull request can't change its own
+// code owners. It returns ErrNotFound if ref doesn't have one.
+func FindInRef(repoDir string, ref string) (*File, error) {
+	if _, err := git(repoDir, "rev-parse", "--verify", ref+"^{commit}"); err != nil {
+		return nil, err
+	}
+	for _, loc := range Locations {
+		out, err := git(repoDir, "ls-tree", "--name-only", ref, "--", loc)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(string(out)) == "" {
+			continue
+		}
+		out, err = git(repoDir, "show", ref+":"+loc)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := Parse(bytes.NewReader(out))
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing %s", loc)
+		}
+		return parsed, nil
+	}
+	return nil, ErrNotFound
+}
+
+func git(repoDir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...) // nolint: gosec
+	cmd.Dir = repoDir
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, errors.Wrapf(err, "running git %s: %s", strings.Join(args, " "), strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, errors.Wrapf(err, "running git %s", strings.Join(args, " "))
+	}
+	return out, nil
+}
+
+// Parse parses a CODEOWNERS file. Each non-empty line that isn't a comment is
+// a gitignore-style pattern followed by owners, ex. "/infra/ @org/infra-team".
+// GitLab section headers, ex. "[Section]", are ignored.
+func Parse(r io.Reader) (*File, error) {
+	var f File
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") || strings.HasPrefix(line, "^[") {
+			continue
+		}
+		// Strip trailing comments.
+		if idx := strings.Index(line, " #"); idx != -1 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		regex, err := patternToRegex(fields[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d: invalid pattern %q", lineNum, fields[0])
+		}
+		f.rules = append(f.rules, rule{
+			pattern: fields[0],
+			regex:   regex,
+			owners:  fields[1:],
+		})
+	}
+	return &f, scanner.Err()
+}
+
+// OwnersFor returns the owners of path, which is relative to the repo root.
+// As with GitHub and GitLab, the last matching pattern wins. It returns nil
+// if path has no owners.
+func (f *File) OwnersFor(path string) []string {
+	path = strings.TrimPrefix(filepath.ToSlash(path), "/")
+	for i := len(f.rules) - 1; i >= 0; i-- {
+		if f.rules[i].regex.MatchString(path) {
+			return f.rules[i].owners
+		}
+	}
+	return nil
+}
+
+// patternToRegex converts a gitignore-style pattern into a regex that matches
+// file paths relative to the repo root.
+func patternToRegex(pattern string) (*regexp.Regexp, error) {
+	// Patterns with a slash anywhere but the end are relative to the repo
+	// root, otherwise they match at any depth.
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	var re strings.Builder
+	re.WriteString("^")
+	if !anchored {
+		re.WriteString("(.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '*' && strings.HasPrefix(pattern[i:], "**/"):
+			re.WriteString("(.*/)?")
+			i += 2
+		case c == '*' && strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if dirOnly {
+		// Directories only match their contents.
+		re.WriteString("/.*$")
+	} else {
+		// A pattern matching a directory also matches everything in it.
+		re.WriteString("(/.*)?$")
+	}
+	return regexp.Compile(re.String())
+}
@@ -0,0 +1,92 @@
+package codeowners_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/codeowners"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestParse_OwnersFor(t *testing.T) {
+	f, err := codeowners.Parse(strings.NewReader(`
+# Default owners.
+*               @org/everyone
+
+[Infra]
+/infra/         @org/infra   # the infra team
+*.tf            @tf-owner
+/docs/**/*.md   docs@example.com
+build/          @builder
+/apps/?/main.go @apps
+`))
+	Ok(t, err)
+
+	cases := []struct {
+		path string
+		exp  []string
+	}{
+		{"README.md", []string{"@org/everyone"}},
+		{"infra/main.go", []string{"@org/infra"}},
+		{"infra/main.tf", []string{"@tf-owner"}},
+		{"modules/vpc/main.tf", []string{"@tf-owner"}},
+		{"docs/a/b/c.md", []string{"docs@example.com"}},
+		{"docs/c.md", []string{"docs@example.com"}},
+		{"nested/build/out", []string{"@builder"}},
+		{"build", []string{"@org/everyone"}},
+		{"apps/a/main.go", []string{"@apps"}},
+		{"apps/ab/main.go", []string{"@org/everyone"}},
+	}
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			Equals(t, c.exp, f.OwnersFor(c.path))
+		})
+	}
+}
+
+func TestParse_NoOwners(t *testing.T) {
+	f, err := codeowners.Parse(strings.NewReader("/infra/ @org/infra\n/infra/unowned.tf\n"))
+	Ok(t, err)
+	Equals(t, []string(nil), f.OwnersFor("README.md"))
+	Equals(t, []string{}, f.OwnersFor("infra/unowned.tf"))
+}
+
+func TestFindInRef(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	runGit(t, tmp, "init")
+	runGit(t, tmp, "config", "--local", "user.email", "atlantisbot@runatlantis.io")
+	runGit(t, tmp, "config", "--local", "user.name", "atlantisbot")
+	runGit(t, tmp, "commit", "--allow-empty", "-m", "initial commit")
+
+	_, err := codeowners.FindInRef(tmp, "HEAD")
+	Equals(t, codeowners.ErrNotFound, err)
+
+	Ok(t, os.MkdirAll(filepath.Join(tmp, ".github"), 0700))
+	Ok(t, ioutil.WriteFile(filepath.Join(tmp, "CODEOWNERS"), []byte("* @root\n"), 0600))
+	Ok(t, ioutil.WriteFile(filepath.Join(tmp, ".github", "CODEOWNERS"), []byte("* @github\n"), 0600))
+	runGit(t, tmp, "add", ".")
+	runGit(t, tmp, "commit", "-m", "add codeowners")
+	runGit(t, tmp, "update-ref", "refs/remotes/origin/main", "HEAD")
+
+	// Changes that aren't in the ref are ignored.
+	Ok(t, ioutil.WriteFile(filepath.Join(tmp, ".github", "CODEOWNERS"), []byte("* @changed\n"), 0600))
+	f, err := codeowners.FindInRef(tmp, "refs/remotes/origin/main")
+	Ok(t, err)
+	Equals(t, []string{"@github"}, f.OwnersFor("main.tf"))
+
+	_, err = codeowners.FindInRef(tmp, "refs/remotes/origin/missing")
+	Assert(t, err != nil && err != codeowners.ErrNotFound, "exp error for a missing ref")
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	Assert(t, err == nil, "err running git %s: %s", strings.Join(args, " "), out)
+}
@@ -0,0 +1,168 @@
+package events
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/codeowners"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+)
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_codeowners_checker.go CodeOwnersChecker
+
+// CodeOwnersChecker checks whether the code owners of a project's modified
+// files have approved a pull request.
+type CodeOwnersChecker interface {
+	// MissingApprovals returns the owner sets that haven't approved the pull
+	// request for the project described by ctx. Each owner set contains the
+	// owners of one or more modified files, any one of whom can approve.
+	// repoDir is the root of the cloned repo.
+	MissingApprovals(ctx models.ProjectCommandContext, repoDir string) ([][]string, error)
+}
+
+// DefaultCodeOwnersChecker implements CodeOwnersChecker using the repo's
+// CODEOWNERS file and the approvals from the VCS host.
+type DefaultCodeOwnersChecker struct {
+	VCSClient vcs.Client
+}
+
+// MissingApprovals returns the owner sets that haven't approved the pull
+// request. The CODEOWNERS file is read from the base branch so the pull request
+// can't change its own owners. If none of the modified files in the project
+// have owners, nothing is missing. If the base branch has no CODEOWNERS file it
+// returns codeowners.ErrNotFound.
+func (d *DefaultCodeOwnersChecker) MissingApprovals(ctx models.ProjectCommandContext, repoDir string) ([][]string, error) {
+	owners, err := codeowners.FindInRef(repoDir, "refs/remotes/origin/"+ctx.Pull.BaseBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	modifiedFiles, err := d.VCSClient.GetModifiedFiles(ctx.Pull.BaseRepo, ctx.Pull)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting modified files")
+	}
+	ownerSets := d.ownerSets(owners, modifiedFiles, ctx.RepoRelDir)
+	if len(ownerSets) == 0 {
+		return nil, nil
+	}
+
+	approvals, err := d.VCSClient.GetApprovals(ctx.Pull.BaseRepo, ctx.Pull)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting approvals")
+	}
+	var approvers []models.User
+	for _, a := range approvals {
+		if strings.EqualFold(a.Username, ctx.Pull.Author) {
+			continue
+		}
+		approvers = append(approvers, models.User{Username: a.Username})
+	}
+
+	// Only the memberships of the owner teams are looked up.
+	var ownerTeams []string
+	seen := make(map[string]bool)
+	for _, set := range ownerSets {
+		for _, owner := range set {
+			name := strings.TrimPrefix(owner, "@")
+			if strings.HasPrefix(owner, "@") && strings.Contains(name, "/") && !seen[name] {
+				seen[name] = true
+				ownerTeams = append(ownerTeams, name)
+			}
+		}
+	}
+
+	var missing [][]string
+	teams := make(map[string][]string)
+	for _, set := range ownerSets {
+		approved, err := d.setApproved(ctx.Pull.BaseRepo, set, ownerTeams, approvers, teams)
+		if err != nil {
+			return nil, err
+		}
+		if !approved {
+			missing = append(missing, set)
+		}
+	}
+	return missing, nil
+}
+
+// ownerSets returns the unique owner sets of the files in modifiedFiles that
+// are under repoRelDir.
+func (d *DefaultCodeOwnersChecker) ownerSets(owners *codeowners.File, modifiedFiles []string, repoRelDir string) [][]string {
+	dir := filepath.ToSlash(filepath.Clean(repoRelDir))
+	seen := make(map[string]bool)
+	var sets [][]string
+	for _, file := range modifiedFiles {
+		if dir != "." && file != dir && !strings.HasPrefix(file, dir+"/") {
+			continue
+		}
+		fileOwners := owners.OwnersFor(file)
+		if len(fileOwners) == 0 {
+			continue
+		}
+		sorted := append([]string(nil), fileOwners...)
+		sort.Strings(sorted)
+		key := strings.Join(sorted, " ")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		sets = append(sets, sorted)
+	}
+	return sets
+}
+
+// setApproved returns true if any of approvers is one of owners. teams caches
+// which of ownerTeams approvers are members of.
+func (d *DefaultCodeOwnersChecker) setApproved(repo models.Repo, owners []string, ownerTeams []string, approvers []models.User, teams map[string][]string) (bool, error) {
+	for _, owner := range owners {
+		for _, approver := range approvers {
+			if !strings.HasPrefix(owner, "@") {
+				// Owners can be emails. We can only compare them to usernames.
+				if strings.EqualFold(owner, approver.Username) {
+					return true, nil
+				}
+				continue
+			}
+			name := strings.TrimPrefix(owner, "@")
+			if !strings.Contains(name, "/") {
+				if strings.EqualFold(name, approver.Username) {
+					return true, nil
+				}
+				continue
+			}
+
+			approverTeams, ok := teams[approver.Username]
+			if !ok {
+				var err error
+				approverTeams, err = d.VCSClient.GetTeamNamesForUser(repo, approver, ownerTeams)
+				if err != nil {
+					return false, errors.Wrapf(err, "getting teams for %s", approver.Username)
+				}
+				teams[approver.Username] = approverTeams
+			}
+			if teamMatches(repo, name, approverTeams) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// teamMatches returns true if owner, ex. "org/team", is one of teams. Teams
+// can be either full paths or, for teams in the repo's owner, their slugs.
+func teamMatches(repo models.Repo, owner string, teams []string) bool {
+	org := owner[:strings.Index(owner, "/")]
+	slug := owner[len(org)+1:]
+	for _, team := range teams {
+		if strings.EqualFold(team, owner) {
+			return true
+		}
+		if strings.EqualFold(org, repo.Owner) && strings.EqualFold(team, slug) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package events_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/codeowners"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/models/fixtures"
+	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	vcsmatchers "github.com/runatlantis/atlantis/server/events/vcs/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func codeOwnersCtx(t *testing.T, repoRelDir string) models.ProjectCommandContext {
+	pull := fixtures.Pull
+	pull.Author = "author"
+	pull.BaseRepo = fixtures.GithubRepo
+	pull.BaseBranch = "main"
+	return models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(t),
+		BaseRepo:   fixtures.GithubRepo,
+		Pull:       pull,
+		RepoRelDir: repoRelDir,
+	}
+}
+
+// codeOwnersRepo returns a git repo whose origin/main branch has a CODEOWNERS
+// file with contents, or none if contents is empty.
+func codeOwnersRepo(t *testing.T, contents string) (string, func()) {
+	repoDir, cleanup := TempDir(t)
+	runCmd(t, repoDir, "git", "init")
+	runCmd(t, repoDir, "git", "config", "--local", "user.email", "atlantisbot@runatlantis.io")
+	runCmd(t, repoDir, "git", "config", "--local", "user.name", "atlantisbot")
+	if contents != "" {
+		Ok(t, ioutil.WriteFile(filepath.Join(repoDir, "CODEOWNERS"), []byte(contents), 0600))
+		runCmd(t, repoDir, "git", "add", "CODEOWNERS")
+	}
+	runCmd(t, repoDir, "git", "commit", "--allow-empty", "-m", "initial commit")
+	runCmd(t, repoDir, "git", "update-ref", "refs/remotes/origin/main", "HEAD")
+	return repoDir, cleanup
+}
+
+func TestDefaultCodeOwnersChecker_NoCodeOwnersFile(t *testing.T) {
+	RegisterMockTestingT(t)
+	vcsClient := vcsmocks.NewMockClient()
+	checker := &events.DefaultCodeOwnersChecker{VCSClient: vcsClient}
+	tmp, cleanup := codeOwnersRepo(t, "")
+	defer cleanup()
+
+	_, err := checker.MissingApprovals(codeOwnersCtx(t, "."), tmp)
+	Equals(t, codeowners.ErrNotFound, err)
+	vcsClient.VerifyWasCalled(Never()).GetModifiedFiles(vcsmatchers.AnyModelsRepo(), vcsmatchers.AnyModelsPullRequest())
+}
+
+func TestDefaultCodeOwnersChecker_MissingApprovals(t *testing.T) {
+	tmp, cleanup := codeOwnersRepo(t, `
+/infra/ @runatlantis/infra
+/app/   @alice @bob
+/docs/  @author
+`)
+	defer cleanup()
+	// The pull request's own changes to CODEOWNERS are ignored.
+	Ok(t, ioutil.WriteFile(filepath.Join(tmp, "CODEOWNERS"), []byte("* @author\n"), 0600))
+
+	cases := []struct {
+		description string
+		repoRelDir  string
+		approvers   []string
+		teams       []string
+		expMissing  [][]string
+	}{
+		{
+			description: "no approvals",
+			repoRelDir:  ".",
+			expMissing:  [][]string{{"@runatlantis/infra"}, {"@alice", "@bob"}, {"@author"}},
+		},
+		{
+			description: "author approvals are ignored",
+			repoRelDir:  "docs",
+			approvers:   []string{"author"},
+			expMissing:  [][]string{{"@author"}},
+		},
+		{
+			description: "user approval",
+			repoRelDir:  "app",
+			approvers:   []string{"Bob"},
+		},
+		{
+			description: "team approval by slug",
+			repoRelDir:  "infra",
+			approvers:   []string{"carol"},
+			teams:       []string{"infra"},
+		},
+		{
+			description: "non-member approval",
+			repoRelDir:  "infra",
+			approvers:   []string{"carol"},
+			teams:       []string{"devs"},
+			expMissing:  [][]string{{"@runatlantis/infra"}},
+		},
+		{
+			description: "only project files are checked",
+			repoRelDir:  "app",
+			approvers:   []string{"alice"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			vcsClient := vcsmocks.NewMockClient()
+			checker := &events.DefaultCodeOwnersChecker{VCSClient: vcsClient}
+			ctx := codeOwnersCtx(t, c.repoRelDir)
+
+			var approvals []models.Approval
+			for _, a := range c.approvers {
+				approvals = append(approvals, models.Approval{Username: a})
+			}
+			When(vcsClient.GetModifiedFiles(ctx.BaseRepo, ctx.Pull)).ThenReturn([]string{"infra/main.tf", "app/main.tf", "docs/readme.md", "unowned.tf"}, nil)
+			When(vcsClient.GetApprovals(ctx.BaseRepo, ctx.Pull)).ThenReturn(approvals, nil)
+			When(vcsClient.GetTeamNamesForUser(vcsmatchers.AnyModelsRepo(), vcsmatchers.AnyModelsUser(), vcsmatchers.AnySliceOfString())).ThenReturn(c.teams, nil)
+
+			missing, err := checker.MissingApprovals(ctx, tmp)
+			Ok(t, err)
+			Equals(t, c.expMissing, missing)
+		})
+	}
+}
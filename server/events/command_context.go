@@ -15,6 +15,7 @@ package events
 import (
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/tracing"
 )
 
 // CommandTrigger represents the how the command was triggered
@@ -45,8 +46,29 @@ type CommandContext struct {
 	// set our own build statuses which can affect mergeability if users have
 	// required the Atlantis status to be successful prior to merging.
 	PullMergeable bool
+	// AllowDestroy is true if apply was run with --allow-destroy to confirm
+	// applying plans that destroy or replace resources.
+	AllowDestroy bool
+	// UpgradeProviders is true if plan was run with --upgrade to upgrade
+	// providers.
+	UpgradeProviders bool
 
 	PullStatus *models.PullStatus
 
 	Trigger CommandTrigger
+
+	// Span is the tracing span of the command. It's nil if tracing is
+	// disabled.
+	Span *tracing.Span
+
+	// CorrelationID identifies the logs of the command and the webhook that
+	// triggered it. Log already includes it.
+	CorrelationID string
+
+	// CommandError is set by comment commands that don't run on specific
+	// projects, ex. unlock, if they errored so that it's audited.
+	CommandError error
+	// CommandFailure is set by those commands if they failed without an
+	// error, ex. because a project was locked by another pull request.
+	CommandFailure string
 }
@@ -0,0 +1,156 @@
+package events
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// journalEntryExt is the extension of the files holding journal entries.
+const journalEntryExt = ".json"
+
+// JournalEntry is a project command that was in progress.
+type JournalEntry struct {
+	ID string
+	// Command is the stage the project reached, ex. apply.
+	Command     models.CommandName
+	StartedAt   time.Time
+	Pull        models.PullRequest
+	HeadRepo    models.Repo
+	User        models.User
+	RepoRelDir  string
+	Workspace   string
+	ProjectName string
+	// Flags are the extra arguments of the comment that ran the command.
+	Flags []string
+}
+
+// CommandJournal records the project commands in progress as files in Dir
+// so the commands interrupted by a crash or restart can be found afterwards.
+// Each entry is written when its command starts and deleted once it
+// finishes. It's safe for concurrent use.
+type CommandJournal struct {
+	Dir string
+	// Now returns the current time. It's only overridden in tests.
+	Now func() time.Time
+}
+
+// NewCommandJournal returns a journal stored in dir, creating it if needed.
+func NewCommandJournal(dir string) (*CommandJournal, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "creating journal dir")
+	}
+	return &CommandJournal{Dir: dir, Now: time.Now}, nil
+}
+
+// Start records that cmdName started for the project of ctx. It returns the
+// id of the entry to pass to Finish.
+func (j *CommandJournal) Start(cmdName models.CommandName, ctx models.ProjectCommandContext) (string, error) {
+	entry := JournalEntry{
+		ID:          uuid.New().String(),
+		Command:     cmdName,
+		StartedAt:   j.Now(),
+		Pull:        ctx.Pull,
+		HeadRepo:    ctx.HeadRepo,
+		User:        ctx.User,
+		RepoRelDir:  ctx.RepoRelDir,
+		Workspace:   ctx.Workspace,
+		ProjectName: ctx.ProjectName,
+		Flags:       unescapeArgs(ctx.EscapedCommentArgs),
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+	// Write to a temporary file first so a crash never leaves a partial
+	// entry.
+	tmp := filepath.Join(j.Dir, "."+entry.ID)
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return "", errors.Wrap(err, "writing journal entry")
+	}
+	return entry.ID, errors.Wrap(os.Rename(tmp, j.path(entry.ID)), "writing journal entry")
+}
+
+// Finish deletes the entry with id once its command finished.
+func (j *CommandJournal) Finish(id string) error {
+	if err := os.Remove(j.path(id)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "deleting journal entry")
+	}
+	return nil
+}
+
+// List returns the entries of the commands in progress, or that were
+// interrupted if called on startup, from oldest to newest.
+func (j *CommandJournal) List() ([]JournalEntry, error) {
+	files, err := ioutil.ReadDir(j.Dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading journal dir")
+	}
+	var entries []JournalEntry
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), journalEntryExt) {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(j.Dir, f.Name()))
+		if err != nil {
+			return nil, errors.Wrap(err, "reading journal entry")
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, errors.Wrapf(err, "parsing journal entry %s", f.Name())
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, k int) bool { return entries[i].StartedAt.Before(entries[k].StartedAt) })
+	return entries, nil
+}
+
+func (j *CommandJournal) path(id string) string {
+	return filepath.Join(j.Dir, id+journalEntryExt)
+}
+
+// JournalProjectCommandRunner records the project commands in progress in a
+// journal while the wrapped runner runs them.
+type JournalProjectCommandRunner struct {
+	ProjectCommandRunner
+	Journal *CommandJournal
+}
+
+// Plan runs the plan for the project described by ctx.
+func (j *JournalProjectCommandRunner) Plan(ctx models.ProjectCommandContext) models.ProjectResult {
+	return j.record(models.PlanCommand, ctx, j.ProjectCommandRunner.Plan)
+}
+
+// PolicyCheck runs the policy check for the project described by ctx.
+func (j *JournalProjectCommandRunner) PolicyCheck(ctx models.ProjectCommandContext) models.ProjectResult {
+	return j.record(models.PolicyCheckCommand, ctx, j.ProjectCommandRunner.PolicyCheck)
+}
+
+// Apply runs the apply for the project described by ctx.
+func (j *JournalProjectCommandRunner) Apply(ctx models.ProjectCommandContext) models.ProjectResult {
+	return j.record(models.ApplyCommand, ctx, j.ProjectCommandRunner.Apply)
+}
+
+func (j *JournalProjectCommandRunner) record(cmdName models.CommandName, ctx models.ProjectCommandContext, runnerFunc prjCmdRunnerFunc) models.ProjectResult {
+	// The command still runs if it can't be journaled, it just won't be
+	// recovered.
+	id, err := j.Journal.Start(cmdName, ctx)
+	if err != nil {
+		ctx.Log.Err("recording command in journal: %s", err)
+	}
+	res := runnerFunc(ctx)
+	if id != "" {
+		if err := j.Journal.Finish(id); err != nil {
+			ctx.Log.Err("%s", err)
+		}
+	}
+	return res
+}
@@ -0,0 +1,91 @@
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/models/fixtures"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func newCommandJournal(t *testing.T) *events.CommandJournal {
+	dir, cleanup := TempDir(t)
+	t.Cleanup(cleanup)
+	journal, err := events.NewCommandJournal(dir)
+	Ok(t, err)
+	return journal
+}
+
+func TestCommandJournal(t *testing.T) {
+	journal := newCommandJournal(t)
+	start := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	journal.Now = func() time.Time { return start }
+	ctx := models.ProjectCommandContext{
+		Pull:               fixtures.Pull,
+		HeadRepo:           fixtures.GithubRepo,
+		User:               fixtures.User,
+		RepoRelDir:         "dir",
+		Workspace:          "default",
+		EscapedCommentArgs: []string{`\-\-\t\a\r\g\e\t\=\w\e\b`},
+	}
+	applyID, err := journal.Start(models.ApplyCommand, ctx)
+	Ok(t, err)
+	journal.Now = func() time.Time { return start.Add(time.Minute) }
+	planID, err := journal.Start(models.PlanCommand, ctx)
+	Ok(t, err)
+
+	entries, err := journal.List()
+	Ok(t, err)
+	Equals(t, 2, len(entries))
+	Equals(t, events.JournalEntry{
+		ID:         applyID,
+		Command:    models.ApplyCommand,
+		StartedAt:  start,
+		Pull:       fixtures.Pull,
+		HeadRepo:   fixtures.GithubRepo,
+		User:       fixtures.User,
+		RepoRelDir: "dir",
+		Workspace:  "default",
+		Flags:      []string{"--target=web"},
+	}, entries[0])
+	Equals(t, planID, entries[1].ID)
+
+	Ok(t, journal.Finish(applyID))
+	// Finishing twice is a no-op.
+	Ok(t, journal.Finish(applyID))
+	entries, err = journal.List()
+	Ok(t, err)
+	Equals(t, 1, len(entries))
+	Equals(t, planID, entries[0].ID)
+}
+
+func TestJournalProjectCommandRunner(t *testing.T) {
+	RegisterMockTestingT(t)
+	wrapped := mocks.NewMockProjectCommandRunner()
+	journal := newCommandJournal(t)
+	runner := events.JournalProjectCommandRunner{
+		ProjectCommandRunner: wrapped,
+		Journal:              journal,
+	}
+	exp := models.ProjectResult{ApplySuccess: "applied"}
+	When(wrapped.Apply(matchers.AnyModelsProjectCommandContext())).Then(func(params []Param) ReturnValues {
+		// The command is in the journal while it runs.
+		entries, err := journal.List()
+		Ok(t, err)
+		Equals(t, 1, len(entries))
+		Equals(t, models.ApplyCommand, entries[0].Command)
+		return ReturnValues{exp}
+	})
+
+	res := runner.Apply(models.ProjectCommandContext{Log: logging.NewNoopLogger(t), RepoRelDir: "dir", Workspace: "default"})
+	Equals(t, exp, res)
+	entries, err := journal.List()
+	Ok(t, err)
+	Equals(t, 0, len(entries))
+}
@@ -24,6 +24,9 @@ type CommandResult struct {
 	// deleted. This happens if automerging is enabled and one project has an
 	// error since automerging requires all plans to succeed.
 	PlansDeleted bool
+	// KeptPlans are the results of the projects whose plans were kept since
+	// the pull request's new commits didn't modify them.
+	KeptPlans []models.ProjectResult
 }
 
 // HasErrors returns true if there were any errors during the execution,
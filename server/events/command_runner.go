@@ -14,6 +14,7 @@
 package events
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 
@@ -24,11 +25,17 @@ import (
 	"github.com/runatlantis/atlantis/server/events/vcs"
 	"github.com/runatlantis/atlantis/server/logging"
 	"github.com/runatlantis/atlantis/server/recovery"
+	"github.com/runatlantis/atlantis/server/tracing"
 	gitlab "github.com/xanzy/go-gitlab"
 )
 
 const (
-	ShutdownComment = "Atlantis server is shutting down, please try again later."
+	// ShutdownComment is posted when a command isn't run because Atlantis is
+	// shutting down, ex. to restart for a deploy.
+	ShutdownComment = "Atlantis is restarting so this command wasn't run. Please try again in a few minutes."
+	// DrainComment is posted instead of ShutdownComment when an admin is
+	// draining Atlantis, ex. for an upgrade.
+	DrainComment = "Atlantis is down for maintenance so this command wasn't run. Please try again once the maintenance is over."
 )
 
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_command_runner.go CommandRunner
@@ -38,8 +45,10 @@ type CommandRunner interface {
 	// RunCommentCommand is the first step after a command request has been parsed.
 	// It handles gathering additional information needed to execute the command
 	// and then calling the appropriate services to finish executing the command.
-	RunCommentCommand(baseRepo models.Repo, maybeHeadRepo *models.Repo, maybePull *models.PullRequest, user models.User, pullNum int, cmd *CommentCommand)
-	RunAutoplanCommand(baseRepo models.Repo, headRepo models.Repo, pull models.PullRequest, user models.User)
+	// reqCtx is the context of the webhook request that triggered the command,
+	// used for tracing.
+	RunCommentCommand(reqCtx context.Context, baseRepo models.Repo, maybeHeadRepo *models.Repo, maybePull *models.PullRequest, user models.User, pullNum int, cmd *CommentCommand)
+	RunAutoplanCommand(reqCtx context.Context, baseRepo models.Repo, headRepo models.Repo, pull models.PullRequest, user models.User)
 }
 
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_github_pull_getter.go GithubPullGetter
@@ -114,20 +123,46 @@ type DefaultCommandRunner struct {
 	Drainer                       *Drainer
 	PreWorkflowHooksCommandRunner PreWorkflowHooksCommandRunner
 	PullStatusFetcher             PullStatusFetcher
+	// CommandAuthorizer restricts which users can run comment commands. If
+	// nil, all users can run all commands.
+	CommandAuthorizer CommandAuthorizer
+	// Tracer records a span for each command. If nil, commands aren't traced.
+	Tracer *tracing.Tracer
+	// PullCoordinator stops Atlantis servers sharing a database from running
+	// commands for the same pull request at the same time. If nil, commands
+	// aren't coordinated.
+	PullCoordinator *PullCoordinator
+	// DiskQuota deletes the working dirs of pull requests to free disk space.
+	// If nil, the data dir has no quota.
+	DiskQuota *DiskQuota
+	// InvalidatedPlansCommenter comments the plans that new commits
+	// invalidated. If nil, they aren't commented.
+	InvalidatedPlansCommenter *InvalidatedPlansCommenter
 }
 
 // RunAutoplanCommand runs plan and policy_checks when a pull request is opened or updated.
-func (c *DefaultCommandRunner) RunAutoplanCommand(baseRepo models.Repo, headRepo models.Repo, pull models.PullRequest, user models.User) {
+func (c *DefaultCommandRunner) RunAutoplanCommand(reqCtx context.Context, baseRepo models.Repo, headRepo models.Repo, pull models.PullRequest, user models.User) {
 	if opStarted := c.Drainer.StartOp(); !opStarted {
-		if commentErr := c.VCSClient.CreateComment(baseRepo, pull.Num, ShutdownComment, models.PlanCommand.String()); commentErr != nil {
+		if commentErr := c.VCSClient.CreateComment(baseRepo, pull.Num, c.notStartedComment(), models.PlanCommand.String()); commentErr != nil {
 			c.Logger.Log(logging.Error, "unable to comment that Atlantis is shutting down: %s", commentErr)
 		}
 		return
 	}
 	defer c.Drainer.OpDone()
 
-	log := c.buildLogger(baseRepo.FullName, pull.Num)
+	span := c.startSpan(reqCtx, "autoplan", baseRepo, pull.Num, user)
+	defer span.End()
+	// VCS calls for the pull request are recorded under the command's span.
+	defer c.Tracer.Activate(span, baseRepo.FullName, pull.Num)()
+	correlationID := commandCorrelationID(reqCtx, span)
+	log := c.buildLogger(baseRepo.FullName, pull.Num, correlationID)
 	defer c.logPanics(baseRepo, pull.Num, log)
+	lease, ok := c.acquirePullLease(baseRepo, pull.Num, models.PlanCommand.String(), log)
+	if !ok {
+		return
+	}
+	defer lease.Release()
+
 	status, err := c.PullStatusFetcher.GetPullStatus(pull)
 
 	if err != nil {
@@ -141,13 +176,19 @@ func (c *DefaultCommandRunner) RunAutoplanCommand(baseRepo models.Repo, headRepo
 		HeadRepo:   headRepo,
 		PullStatus: status,
 		Trigger:    Auto,
+		Span:       span,
+
+		CorrelationID: correlationID,
 	}
-	if !c.validateCtxAndComment(ctx) {
+	if !c.validateCtxAndComment(ctx, nil) {
 		return
 	}
+	// Deferred so that the plans autoplan keeps or makes aren't commented.
+	defer c.InvalidatedPlansCommenter.Comment(ctx, status)
 	if c.DisableAutoplan {
 		return
 	}
+	c.DiskQuota.Revisit(ctx, models.PlanCommand)
 
 	err = c.PreWorkflowHooksCommandRunner.RunPreHooks(ctx)
 
@@ -165,17 +206,30 @@ func (c *DefaultCommandRunner) RunAutoplanCommand(baseRepo models.Repo, headRepo
 // enough data to construct the Repo model and callers might want to wait until
 // the event is further validated before making an additional (potentially
 // wasteful) call to get the necessary data.
-func (c *DefaultCommandRunner) RunCommentCommand(baseRepo models.Repo, maybeHeadRepo *models.Repo, maybePull *models.PullRequest, user models.User, pullNum int, cmd *CommentCommand) {
+func (c *DefaultCommandRunner) RunCommentCommand(reqCtx context.Context, baseRepo models.Repo, maybeHeadRepo *models.Repo, maybePull *models.PullRequest, user models.User, pullNum int, cmd *CommentCommand) {
 	if opStarted := c.Drainer.StartOp(); !opStarted {
-		if commentErr := c.VCSClient.CreateComment(baseRepo, pullNum, ShutdownComment, ""); commentErr != nil {
+		if commentErr := c.VCSClient.CreateComment(baseRepo, pullNum, c.notStartedComment(), ""); commentErr != nil {
 			c.Logger.Log(logging.Error, "unable to comment that Atlantis is shutting down: %s", commentErr)
 		}
 		return
 	}
 	defer c.Drainer.OpDone()
 
-	log := c.buildLogger(baseRepo.FullName, pullNum)
+	var command string
+	if cmd != nil {
+		command = cmd.Name.String()
+	}
+	span := c.startSpan(reqCtx, command, baseRepo, pullNum, user)
+	defer span.End()
+	defer c.Tracer.Activate(span, baseRepo.FullName, pullNum)()
+	correlationID := commandCorrelationID(reqCtx, span)
+	log := c.buildLogger(baseRepo.FullName, pullNum, correlationID)
 	defer c.logPanics(baseRepo, pullNum, log)
+	lease, ok := c.acquirePullLease(baseRepo, pullNum, command, log)
+	if !ok {
+		return
+	}
+	defer lease.Release()
 
 	headRepo, pull, err := c.ensureValidRepoMetadata(baseRepo, maybeHeadRepo, maybePull, user, pullNum, log)
 	if err != nil {
@@ -195,16 +249,28 @@ func (c *DefaultCommandRunner) RunCommentCommand(baseRepo models.Repo, maybeHead
 		PullStatus: status,
 		HeadRepo:   headRepo,
 		Trigger:    Comment,
+		Span:       span,
+
+		CorrelationID: correlationID,
 	}
 
-	if !c.validateCtxAndComment(ctx) {
+	if !c.validateCtxAndComment(ctx, cmd) {
 		return
 	}
 
-	err = c.PreWorkflowHooksCommandRunner.RunPreHooks(ctx)
+	if !c.authorizeAndComment(ctx, cmd) {
+		return
+	}
+	c.DiskQuota.Revisit(ctx, cmd.CommandName())
 
-	if err != nil {
-		ctx.Log.Err("Error running pre-workflow hooks %s. Proceeding with %s command.", err, cmd.Name.String())
+	// Revert runs the hooks itself on the reverted base branch since the
+	// pull request was already merged.
+	if cmd.CommandName() != models.RevertCommand {
+		err = c.PreWorkflowHooksCommandRunner.RunPreHooks(ctx)
+
+		if err != nil {
+			ctx.Log.Err("Error running pre-workflow hooks %s. Proceeding with %s command.", err, cmd.Name.String())
+		}
 	}
 
 	cmdRunner := buildCommentCommandRunner(c, cmd.CommandName())
@@ -212,6 +278,64 @@ func (c *DefaultCommandRunner) RunCommentCommand(baseRepo models.Repo, maybeHead
 	cmdRunner.Run(ctx, cmd)
 }
 
+// acquirePullLease leases the pull request pullNum of repo to this server
+// while it runs command. If it can't, it comments why and returns false. If
+// the lease was taken over from a server that stopped while running a
+// command, it comments that the command was interrupted.
+func (c *DefaultCommandRunner) acquirePullLease(repo models.Repo, pullNum int, command string, log logging.SimpleLogging) (*PullLease, bool) {
+	if c.PullCoordinator == nil {
+		return &PullLease{release: func() {}}, true
+	}
+	lease, err := c.PullCoordinator.Acquire(repo, pullNum, command)
+	if busyErr, ok := err.(*PullBusyError); ok {
+		log.Info("not running %s: %s", command, busyErr)
+		comment := fmt.Sprintf("Another Atlantis server is running `%s` for this pull request. Wait until it's complete and try again.", busyErr.Lease.Command)
+		if commentErr := c.VCSClient.CreateComment(repo, pullNum, comment, command); commentErr != nil {
+			log.Warn("unable to comment that the pull request is busy: %s", commentErr)
+		}
+		return nil, false
+	}
+	if err != nil {
+		// Running the command is safer than dropping it since the other
+		// locks still apply.
+		log.Err("unable to lease pull request, running %s anyway: %s", command, err)
+		return &PullLease{release: func() {}}, true
+	}
+	if prev := lease.TakenOver; prev != nil {
+		log.Warn("took over pull request from Atlantis server %q, which stopped while running %s", prev.Holder, prev.Command)
+		comment := fmt.Sprintf("**Warning**: Atlantis server `%s` stopped while running `%s` for this pull request so its output was lost.", prev.Holder, prev.Command)
+		if prev.Command == models.ApplyCommand.String() {
+			comment += " Its apply **may have been partially applied**. Review the state of its resources manually before running `atlantis apply` again."
+		} else {
+			comment += " Run it again if its results are missing."
+		}
+		if commentErr := c.VCSClient.CreateComment(repo, pullNum, comment, ""); commentErr != nil {
+			log.Warn("unable to comment that a command was interrupted: %s", commentErr)
+		}
+	}
+	return lease, true
+}
+
+// notStartedComment returns the comment explaining that a command wasn't
+// started because Atlantis is shutting down or draining.
+func (c *DefaultCommandRunner) notStartedComment() string {
+	if status := c.Drainer.GetStatus(); status.Draining && !status.ShuttingDown {
+		return DrainComment
+	}
+	return ShutdownComment
+}
+
+// startSpan starts the span for running command for repo's pull request
+// pullNum.
+func (c *DefaultCommandRunner) startSpan(reqCtx context.Context, command string, repo models.Repo, pullNum int, user models.User) *tracing.Span {
+	_, span := c.Tracer.Start(reqCtx, "atlantis "+command,
+		tracing.String("atlantis.command", command),
+		tracing.String("atlantis.repo", repo.FullName),
+		tracing.Int("atlantis.pull", pullNum),
+		tracing.String("atlantis.user", user.Username))
+	return span
+}
+
 func (c *DefaultCommandRunner) getGithubData(baseRepo models.Repo, pullNum int) (models.PullRequest, models.Repo, error) {
 	if c.GithubPullGetter == nil {
 		return models.PullRequest{}, models.Repo{}, errors.New("Atlantis not configured to support GitHub")
@@ -254,14 +378,28 @@ func (c *DefaultCommandRunner) getAzureDevopsData(baseRepo models.Repo, pullNum
 	return pull, headRepo, nil
 }
 
-func (c *DefaultCommandRunner) buildLogger(repoFullName string, pullNum int) logging.SimpleLogging {
+func (c *DefaultCommandRunner) buildLogger(repoFullName string, pullNum int, correlationID string) logging.SimpleLogging {
 
 	return c.Logger.WithHistory(
 		"repo", repoFullName,
 		"pull", strconv.Itoa(pullNum),
+		logging.CorrelationIDKey, correlationID,
 	)
 }
 
+// commandCorrelationID returns the correlation ID of the webhook in reqCtx.
+// Commands that weren't triggered by a webhook use the ID of their trace or,
+// if they aren't traced, a new ID.
+func commandCorrelationID(reqCtx context.Context, span *tracing.Span) string {
+	if id := logging.CorrelationIDFromContext(reqCtx); id != "" {
+		return id
+	}
+	if id := span.TraceID(); id != "" {
+		return id
+	}
+	return logging.NewCorrelationID()
+}
+
 func (c *DefaultCommandRunner) ensureValidRepoMetadata(
 	baseRepo models.Repo,
 	maybeHeadRepo *models.Repo,
@@ -301,7 +439,9 @@ func (c *DefaultCommandRunner) ensureValidRepoMetadata(
 	return
 }
 
-func (c *DefaultCommandRunner) validateCtxAndComment(ctx *CommandContext) bool {
+// validateCtxAndComment returns true if cmd can be run for ctx. If not, it
+// comments on the pull request with the reason. cmd is nil for autoplan.
+func (c *DefaultCommandRunner) validateCtxAndComment(ctx *CommandContext, cmd *CommentCommand) bool {
 	if !c.AllowForkPRs && ctx.HeadRepo.Owner != ctx.Pull.BaseRepo.Owner {
 		if c.SilenceForkPRErrors {
 			return false
@@ -313,7 +453,8 @@ func (c *DefaultCommandRunner) validateCtxAndComment(ctx *CommandContext) bool {
 		return false
 	}
 
-	if ctx.Pull.State != models.OpenPullState {
+	// Revert is run on merged pull requests so it checks the state itself.
+	if ctx.Pull.State != models.OpenPullState && (cmd == nil || cmd.Name != models.RevertCommand) {
 		ctx.Log.Info("command was run on closed pull request")
 		if err := c.VCSClient.CreateComment(ctx.Pull.BaseRepo, ctx.Pull.Num, "Atlantis commands can't be run on closed pull requests", ""); err != nil {
 			ctx.Log.Err("unable to comment: %s", err)
@@ -323,6 +464,31 @@ func (c *DefaultCommandRunner) validateCtxAndComment(ctx *CommandContext) bool {
 	return true
 }
 
+// authorizeAndComment returns true if ctx.User is allowed to run cmd. If not,
+// it comments on the pull request with the reason.
+func (c *DefaultCommandRunner) authorizeAndComment(ctx *CommandContext, cmd *CommentCommand) bool {
+	if c.CommandAuthorizer == nil {
+		return true
+	}
+	authorized, err := c.CommandAuthorizer.IsAuthorized(ctx.Pull.BaseRepo, ctx.User, cmd.CommandName())
+	if authorized {
+		return true
+	}
+
+	var comment string
+	if err != nil {
+		ctx.Log.Err("unable to check if %s can run %s: %s", ctx.User.Username, cmd.Name.String(), err)
+		comment = fmt.Sprintf("**Error:** unable to check if @%s is allowed to run `%s`:\n```\n%s\n```", ctx.User.Username, cmd.Name.String(), err)
+	} else {
+		ctx.Log.Info("%s is not a member of a team allowed to run %s", ctx.User.Username, cmd.Name.String())
+		comment = fmt.Sprintf("@%s is not a member of a team that is allowed to run `%s` on this repo.", ctx.User.Username, cmd.Name.String())
+	}
+	if err := c.VCSClient.CreateComment(ctx.Pull.BaseRepo, ctx.Pull.Num, comment, cmd.Name.String()); err != nil {
+		ctx.Log.Err("unable to comment: %s", err)
+	}
+	return false
+}
+
 // logPanics logs and creates a comment on the pull request for panics.
 func (c *DefaultCommandRunner) logPanics(baseRepo models.Repo, pullNum int, logger logging.SimpleLogging) {
 	if err := recover(); err != nil {
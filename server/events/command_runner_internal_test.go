@@ -92,6 +92,7 @@ func TestPlanUpdateCommitStatus(t *testing.T) {
 		expStatus     models.CommitStatus
 		expNumSuccess int
 		expNumTotal   int
+		expCounts     *models.PlanCounts
 	}{
 		"single plan success": {
 			cmd: models.PlanCommand,
@@ -128,6 +129,28 @@ func TestPlanUpdateCommitStatus(t *testing.T) {
 			expNumSuccess: 3,
 			expNumTotal:   4,
 		},
+		"plan counts summed": {
+			cmd: models.PlanCommand,
+			pullStatus: models.PullStatus{
+				Projects: []models.ProjectStatus{
+					{
+						Status:     models.PlannedPlanStatus,
+						PlanCounts: &models.PlanCounts{Add: 3, Change: 1},
+					},
+					{
+						Status:     models.PlannedPlanStatus,
+						PlanCounts: &models.PlanCounts{Change: 1, Destroy: 2},
+					},
+					{
+						Status: models.ErroredPlanStatus,
+					},
+				},
+			},
+			expStatus:     models.FailedCommitStatus,
+			expNumSuccess: 2,
+			expNumTotal:   3,
+			expCounts:     &models.PlanCounts{Add: 3, Change: 2, Destroy: 2},
+		},
 	}
 
 	for name, c := range cases {
@@ -143,6 +166,7 @@ func TestPlanUpdateCommitStatus(t *testing.T) {
 			Equals(t, c.cmd, csu.CalledCommand)
 			Equals(t, c.expNumSuccess, csu.CalledNumSuccess)
 			Equals(t, c.expNumTotal, csu.CalledNumTotal)
+			Equals(t, c.expCounts, csu.CalledCounts)
 		})
 	}
 }
@@ -154,6 +178,7 @@ type MockCSU struct {
 	CalledCommand    models.CommandName
 	CalledNumSuccess int
 	CalledNumTotal   int
+	CalledCounts     *models.PlanCounts
 }
 
 func (m *MockCSU) UpdateCombinedCount(repo models.Repo, pull models.PullRequest, status models.CommitStatus, command models.CommandName, numSuccess int, numTotal int) error {
@@ -171,3 +196,10 @@ func (m *MockCSU) UpdateCombined(repo models.Repo, pull models.PullRequest, stat
 func (m *MockCSU) UpdateProject(ctx models.ProjectCommandContext, cmdName models.CommandName, status models.CommitStatus, url string) error {
 	return nil
 }
+func (m *MockCSU) UpdatePlanCount(repo models.Repo, pull models.PullRequest, status models.CommitStatus, numSuccess int, numTotal int, counts *models.PlanCounts) error {
+	m.CalledCounts = counts
+	return m.UpdateCombinedCount(repo, pull, status, models.PlanCommand, numSuccess, numTotal)
+}
+func (m *MockCSU) UpdatePlanProject(ctx models.ProjectCommandContext, status models.CommitStatus, url string, counts *models.PlanCounts) error {
+	return nil
+}
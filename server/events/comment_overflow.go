@@ -0,0 +1,131 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/jobs"
+)
+
+// CommentOverflow is what to do with comments longer than the VCS host's
+// maximum comment length.
+type CommentOverflow string
+
+const (
+	// SplitCommentOverflow splits comments into multiple comments.
+	SplitCommentOverflow CommentOverflow = "split"
+	// TruncateCommentOverflow truncates comments and links to the full log of
+	// each project.
+	TruncateCommentOverflow CommentOverflow = "truncate"
+	// UploadCommentOverflow uploads comments to the web UI and comments a
+	// link to them instead.
+	UploadCommentOverflow CommentOverflow = "upload"
+)
+
+// CommentOverflows are the valid CommentOverflows.
+var CommentOverflows = []CommentOverflow{SplitCommentOverflow, TruncateCommentOverflow, UploadCommentOverflow}
+
+// overflow returns comment, or what to comment instead if it's longer than
+// the maximum comment length of the pull request's VCS host. Comments that
+// are still too long are split by the VCS client.
+func (c *PullUpdater) overflow(ctx *CommandContext, command models.CommandName, res CommandResult, comment string) string {
+	maxLength := c.MaxCommentLengths[ctx.Pull.BaseRepo.VCSHost.Type]
+	if maxLength <= 0 || len(comment) <= maxLength {
+		return comment
+	}
+	switch c.CommentOverflow {
+	case TruncateCommentOverflow:
+		return truncateComment(comment, maxLength, res.ProjectResults)
+	case UploadCommentOverflow:
+		if c.Outputs == nil || c.OutputURLGenerator == nil {
+			ctx.Log.Warn("not uploading comment since there's no web UI to upload it to")
+			return comment
+		}
+		output := c.Outputs.Start(jobs.OutputInfo{
+			Command: command.String(),
+			Repo:    ctx.Pull.BaseRepo.FullName,
+			Pull:    ctx.Pull.Num,
+		})
+		output.Write([]byte(comment + "\n")) // nolint: errcheck
+		output.Close()
+		return uploadedComment(command, c.OutputURLGenerator.GenerateOutputURL(output.Info().ID), res.ProjectResults)
+	}
+	return comment
+}
+
+// truncateComment truncates comment to maxLength at the end of a line,
+// closing the code blocks and <details> it cuts, and links to the full logs
+// of results.
+func truncateComment(comment string, maxLength int, results []models.ProjectResult) string {
+	notice := "\n\n**Warning**: Output truncated since it's longer than the max comment size."
+	for _, result := range results {
+		if result.OutputURL != "" {
+			notice += fmt.Sprintf("\n* :scroll: To view the full log of %s click [here](%s)", resultDescription(result), result.OutputURL)
+		}
+	}
+	// Leave room to close everything that could be open.
+	reserved := len(notice) + len("\n```") + strings.Count(comment, "<details>")*len("\n</details>")
+	cut := maxLength - reserved
+	if cut < 0 {
+		cut = 0
+	}
+	for cut > 0 && !utf8.RuneStart(comment[cut]) {
+		cut--
+	}
+	truncated := comment[:cut]
+	// Don't cut lines in half.
+	if i := strings.LastIndex(truncated, "\n"); i > 0 {
+		truncated = truncated[:i]
+	}
+	if strings.Count(truncated, "```")%2 == 1 {
+		truncated += "\n```"
+	}
+	for i := strings.Count(truncated, "</details>"); i < strings.Count(truncated, "<details>"); i++ {
+		truncated += "\n</details>"
+	}
+	return truncated + notice
+}
+
+// uploadedComment is the comment instead of one that was uploaded to url.
+func uploadedComment(command models.CommandName, url string, results []models.ProjectResult) string {
+	var comment string
+	switch len(results) {
+	case 0:
+		comment = fmt.Sprintf("Ran %s but the output is longer than the max comment size.\n\n", command.TitleString())
+	case 1:
+		comment = fmt.Sprintf("Ran %s for %s but the output is longer than the max comment size.\n\n", command.TitleString(), resultDescription(results[0]))
+	default:
+		comment = fmt.Sprintf("Ran %s for %d projects but the output is longer than the max comment size.\n\n", command.TitleString(), len(results))
+		for _, result := range results {
+			comment += fmt.Sprintf("1. %s: %s\n", resultDescription(result), resultStatus(result))
+		}
+		comment += "\n"
+	}
+	return comment + fmt.Sprintf("* :scroll: To view the full output click [here](%s)", url)
+}
+
+// resultDescription describes the project of result like the comments of its
+// results do.
+func resultDescription(result models.ProjectResult) string {
+	description := fmt.Sprintf("dir: `%s` workspace: `%s`", result.RepoRelDir, result.Workspace)
+	if result.ProjectName != "" {
+		description = fmt.Sprintf("project: `%s` %s", result.ProjectName, description)
+	}
+	return description
+}
+
+// resultStatus is a short description of whether result succeeded.
+func resultStatus(result models.ProjectResult) string {
+	switch {
+	case result.Error != nil:
+		return ":x: errored"
+	case result.Failure != "":
+		return ":warning: failed"
+	case result.PlanSuccess != nil && result.PlanSuccess.Summary() != "":
+		return ":white_check_mark: " + result.PlanSuccess.Summary()
+	default:
+		return ":white_check_mark: succeeded"
+	}
+}
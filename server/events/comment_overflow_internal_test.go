@@ -0,0 +1,94 @@
+package events
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/jobs"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+type outputURLGenerator struct{}
+
+func (outputURLGenerator) GenerateOutputURL(id string) string {
+	return "https://atlantis/outputs/" + id
+}
+
+func TestPullUpdater_Overflow(t *testing.T) {
+	results := []models.ProjectResult{
+		{
+			RepoRelDir: "staging",
+			Workspace:  "default",
+			PlanSuccess: &models.PlanSuccess{
+				TerraformOutput: "Plan: 1 to add, 0 to change, 0 to destroy.",
+			},
+			OutputURL: "https://atlantis/outputs/staging",
+		},
+		{
+			RepoRelDir: "production",
+			Workspace:  "default",
+			Error:      errors.New("err"),
+		},
+	}
+	comment := "Ran Plan\n<details><summary>Show Output</summary>\n\n```diff\n" + strings.Repeat("+ line\n", 200) + "```\n</details>"
+	ctx := &CommandContext{
+		Pull: models.PullRequest{
+			Num:      1,
+			BaseRepo: models.Repo{FullName: "owner/repo", VCSHost: models.VCSHost{Type: models.Github}},
+		},
+		Log: logging.NewNoopLogger(t),
+	}
+	res := CommandResult{ProjectResults: results}
+
+	t.Run("under max", func(t *testing.T) {
+		u := &PullUpdater{MaxCommentLengths: map[models.VCSHostType]int{models.Github: len(comment)}, CommentOverflow: TruncateCommentOverflow}
+		Equals(t, comment, u.overflow(ctx, models.PlanCommand, res, comment))
+	})
+
+	t.Run("no max", func(t *testing.T) {
+		u := &PullUpdater{MaxCommentLengths: map[models.VCSHostType]int{models.Gitlab: 10}, CommentOverflow: TruncateCommentOverflow}
+		Equals(t, comment, u.overflow(ctx, models.PlanCommand, res, comment))
+	})
+
+	t.Run("split", func(t *testing.T) {
+		u := &PullUpdater{MaxCommentLengths: map[models.VCSHostType]int{models.Github: 500}, CommentOverflow: SplitCommentOverflow}
+		Equals(t, comment, u.overflow(ctx, models.PlanCommand, res, comment))
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		u := &PullUpdater{MaxCommentLengths: map[models.VCSHostType]int{models.Github: 500}, CommentOverflow: TruncateCommentOverflow}
+		truncated := u.overflow(ctx, models.PlanCommand, res, comment)
+		Assert(t, len(truncated) <= 500, "truncated comment of length %d is longer than 500", len(truncated))
+		Assert(t, strings.HasPrefix(truncated, "Ran Plan\n<details>"), "exp the start of the comment to be kept but was %q", truncated)
+		Assert(t, strings.HasSuffix(truncated, "+ line\n```\n</details>\n\n**Warning**: Output truncated since it's longer than the max comment size.\n"+
+			"* :scroll: To view the full log of dir: `staging` workspace: `default` click [here](https://atlantis/outputs/staging)"),
+			"exp the code block and details to be closed and a link to the full log but was %q", truncated)
+	})
+
+	t.Run("upload", func(t *testing.T) {
+		outputs := jobs.NewOutputStore(10)
+		u := &PullUpdater{
+			MaxCommentLengths:  map[models.VCSHostType]int{models.Github: 500},
+			CommentOverflow:    UploadCommentOverflow,
+			Outputs:            outputs,
+			OutputURLGenerator: outputURLGenerator{},
+		}
+		uploaded := u.overflow(ctx, models.PlanCommand, res, comment)
+
+		infos := outputs.List()
+		Equals(t, 1, len(infos))
+		Equals(t, "owner/repo", infos[0].Repo)
+		Equals(t, 1, infos[0].Pull)
+		output, _ := outputs.Get(infos[0].ID)
+		lines, _, unsubscribe := output.Subscribe()
+		unsubscribe()
+		Equals(t, comment, strings.Join(lines, "\n"))
+		Equals(t, "Ran Plan for 2 projects but the output is longer than the max comment size.\n\n"+
+			"1. dir: `staging` workspace: `default`: :white_check_mark: Plan: 1 to add, 0 to change, 0 to destroy.\n"+
+			"1. dir: `production` workspace: `default`: :x: errored\n\n"+
+			"* :scroll: To view the full output click [here](https://atlantis/outputs/"+infos[0].ID+")", uploaded)
+	})
+}
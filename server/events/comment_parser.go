@@ -14,14 +14,12 @@
 package events
 
 import (
-	"bytes"
 	"fmt"
 	"io/ioutil"
 	"net/url"
 	"path/filepath"
 	"regexp"
 	"strings"
-	"text/template"
 
 	"github.com/flynn-archive/go-shlex"
 	"github.com/runatlantis/atlantis/server/events/models"
@@ -30,17 +28,28 @@ import (
 )
 
 const (
-	workspaceFlagLong  = "workspace"
-	workspaceFlagShort = "w"
-	dirFlagLong        = "dir"
-	dirFlagShort       = "d"
-	projectFlagLong    = "project"
-	projectFlagShort   = "p"
-	verboseFlagLong    = "verbose"
-	verboseFlagShort   = ""
-	atlantisExecutable = "atlantis"
+	workspaceFlagLong    = "workspace"
+	workspaceFlagShort   = "w"
+	dirFlagLong          = "dir"
+	dirFlagShort         = "d"
+	projectFlagLong      = "project"
+	projectFlagShort     = "p"
+	verboseFlagLong      = "verbose"
+	verboseFlagShort     = ""
+	openPRFlagLong       = "open-pr"
+	allowDestroyFlagLong = "allow-destroy"
+	upgradeFlagLong      = "upgrade"
+	fixFlagLong          = "fix"
+	atlantisExecutable   = "atlantis"
+
+	workspaceNewAction    = "new"
+	workspaceDeleteAction = "delete"
+	workspaceListAction   = "list"
 )
 
+// workspaceActions are the actions of atlantis workspace.
+var workspaceActions = []string{workspaceNewAction, workspaceDeleteAction, workspaceListAction}
+
 // multiLineRegex is used to ignore multi-line comments since those aren't valid
 // Atlantis commands. If the second line just has newlines then we let it pass
 // through because when you double click on a comment in GitHub and then you
@@ -84,6 +93,9 @@ type CommentParseResult struct {
 	// CommentResponse is set when we should respond immediately to the command
 	// for example for atlantis help.
 	CommentResponse string
+	// Help is set when the command is atlantis help so the response can be
+	// rendered for the user and repo.
+	Help bool
 	// Ignore is set to true when we should just ignore this comment.
 	Ignore bool
 }
@@ -93,7 +105,8 @@ type CommentParseResult struct {
 // Valid commands contain:
 // - The initial "executable" name, 'run' or 'atlantis' or '@GithubUser'
 //   where GithubUser is the API user Atlantis is running as.
-// - Then a command, either 'plan', 'apply', 'approve_policies', or 'help'.
+// - Then a command, either 'plan', 'apply', 'approve_policies', 'unlock',
+//   'lock', 'revert', 'workspace', 'fmt', 'validate' or 'help'.
 // - Then optional flags, then an optional separator '--' followed by optional
 //   extra flags to be appended to the terraform plan/apply command.
 //
@@ -104,6 +117,9 @@ type CommentParseResult struct {
 // - atlantis plan -w staging -d dir --verbose
 // - atlantis plan --verbose -- -key=value -key2 value2
 // - atlantis approve_policies
+// - atlantis workspace new pr-123 -d dir
+// - atlantis fmt --fix
+// - atlantis validate -d dir
 //
 func (e *CommentParser) Parse(comment string, vcsHost models.VCSHostType) CommentParseResult {
 	if multiLineRegex.MatchString(comment) {
@@ -153,17 +169,18 @@ func (e *CommentParser) Parse(comment string, vcsHost models.VCSHostType) Commen
 	// If they've just typed the name of the executable then give them the help
 	// output.
 	if len(args) == 1 {
-		return CommentParseResult{CommentResponse: e.HelpComment(e.ApplyDisabled)}
+		return CommentParseResult{CommentResponse: e.HelpComment(e.ApplyDisabled), Help: true}
 	}
 	command := args[1]
 
 	// Help output.
 	if e.stringInSlice(command, []string{"help", "-h", "--help"}) {
-		return CommentParseResult{CommentResponse: e.HelpComment(e.ApplyDisabled)}
+		return CommentParseResult{CommentResponse: e.HelpComment(e.ApplyDisabled), Help: true}
 	}
 
-	// Need to have a plan, apply, approve_policy or unlock at this point.
-	if !e.stringInSlice(command, []string{models.PlanCommand.String(), models.ApplyCommand.String(), models.UnlockCommand.String(), models.ApprovePoliciesCommand.String()}) {
+	// Need to have a plan, apply, approve_policy, unlock, lock, revert,
+	// workspace, fmt or validate at this point.
+	if !e.stringInSlice(command, []string{models.PlanCommand.String(), models.ApplyCommand.String(), models.UnlockCommand.String(), models.ApprovePoliciesCommand.String(), models.LockCommand.String(), models.RevertCommand.String(), models.WorkspaceCommand.String(), models.FmtCommand.String(), models.ValidateCommand.String()}) {
 		return CommentParseResult{CommentResponse: fmt.Sprintf("```\nError: unknown command %q.\nRun 'atlantis --help' for usage.\n```", command)}
 	}
 
@@ -171,6 +188,10 @@ func (e *CommentParser) Parse(comment string, vcsHost models.VCSHostType) Commen
 	var dir string
 	var project string
 	var verbose bool
+	var openPR bool
+	var allowDestroy bool
+	var upgrade bool
+	var fix bool
 	var flagSet *pflag.FlagSet
 	var name models.CommandName
 
@@ -184,6 +205,7 @@ func (e *CommentParser) Parse(comment string, vcsHost models.VCSHostType) Commen
 		flagSet.StringVarP(&dir, dirFlagLong, dirFlagShort, "", "Which directory to run plan in relative to root of repo, ex. 'child/dir'.")
 		flagSet.StringVarP(&project, projectFlagLong, projectFlagShort, "", fmt.Sprintf("Which project to run plan for. Refers to the name of the project configured in %s. Cannot be used at same time as workspace or dir flags.", yaml.AtlantisYAMLFilename))
 		flagSet.BoolVarP(&verbose, verboseFlagLong, verboseFlagShort, false, "Append Atlantis log to comment.")
+		flagSet.BoolVar(&upgrade, upgradeFlagLong, false, "Upgrade providers with terraform init -upgrade and list the versions that changed.")
 	case models.ApplyCommand.String():
 		name = models.ApplyCommand
 		flagSet = pflag.NewFlagSet(models.ApplyCommand.String(), pflag.ContinueOnError)
@@ -192,6 +214,7 @@ func (e *CommentParser) Parse(comment string, vcsHost models.VCSHostType) Commen
 		flagSet.StringVarP(&dir, dirFlagLong, dirFlagShort, "", "Apply the plan for this directory, relative to root of repo, ex. 'child/dir'.")
 		flagSet.StringVarP(&project, projectFlagLong, projectFlagShort, "", fmt.Sprintf("Apply the plan for this project. Refers to the name of the project configured in %s. Cannot be used at same time as workspace or dir flags.", yaml.AtlantisYAMLFilename))
 		flagSet.BoolVarP(&verbose, verboseFlagLong, verboseFlagShort, false, "Append Atlantis log to comment.")
+		flagSet.BoolVar(&allowDestroy, allowDestroyFlagLong, false, "Confirm applying plans that destroy or replace resources.")
 	case models.ApprovePoliciesCommand.String():
 		name = models.ApprovePoliciesCommand
 		flagSet = pflag.NewFlagSet(models.ApprovePoliciesCommand.String(), pflag.ContinueOnError)
@@ -201,6 +224,42 @@ func (e *CommentParser) Parse(comment string, vcsHost models.VCSHostType) Commen
 		name = models.UnlockCommand
 		flagSet = pflag.NewFlagSet(models.UnlockCommand.String(), pflag.ContinueOnError)
 		flagSet.SetOutput(ioutil.Discard)
+	case models.LockCommand.String():
+		name = models.LockCommand
+		flagSet = pflag.NewFlagSet(models.LockCommand.String(), pflag.ContinueOnError)
+		flagSet.SetOutput(ioutil.Discard)
+		flagSet.StringVarP(&workspace, workspaceFlagLong, workspaceFlagShort, "", "Lock the project in this Terraform workspace.")
+		flagSet.StringVarP(&dir, dirFlagLong, dirFlagShort, "", "Lock the project in this directory, relative to root of repo, ex. 'child/dir'.")
+		flagSet.StringVarP(&project, projectFlagLong, projectFlagShort, "", fmt.Sprintf("Lock this project. Refers to the name of the project configured in %s. Cannot be used at same time as workspace or dir flags.", yaml.AtlantisYAMLFilename))
+	case models.RevertCommand.String():
+		name = models.RevertCommand
+		flagSet = pflag.NewFlagSet(models.RevertCommand.String(), pflag.ContinueOnError)
+		flagSet.SetOutput(ioutil.Discard)
+		flagSet.StringVarP(&workspace, workspaceFlagLong, workspaceFlagShort, "", "Revert the project in this Terraform workspace.")
+		flagSet.StringVarP(&dir, dirFlagLong, dirFlagShort, "", "Revert the project in this directory, relative to root of repo, ex. 'child/dir'.")
+		flagSet.StringVarP(&project, projectFlagLong, projectFlagShort, "", fmt.Sprintf("Revert this project. Refers to the name of the project configured in %s. Cannot be used at same time as workspace or dir flags.", yaml.AtlantisYAMLFilename))
+		flagSet.BoolVar(&openPR, openPRFlagLong, false, "Open a pull request with the revert and its plan.")
+		flagSet.BoolVarP(&verbose, verboseFlagLong, verboseFlagShort, false, "Append Atlantis log to comment.")
+	case models.WorkspaceCommand.String():
+		name = models.WorkspaceCommand
+		flagSet = pflag.NewFlagSet(models.WorkspaceCommand.String(), pflag.ContinueOnError)
+		flagSet.SetOutput(ioutil.Discard)
+		flagSet.StringVarP(&dir, dirFlagLong, dirFlagShort, "", "Manage the workspaces of the project in this directory, relative to root of repo, ex. 'child/dir'.")
+		flagSet.StringVarP(&project, projectFlagLong, projectFlagShort, "", fmt.Sprintf("Manage the workspaces of this project. Refers to the name of the project configured in %s. Cannot be used at same time as the dir flag.", yaml.AtlantisYAMLFilename))
+	case models.FmtCommand.String():
+		name = models.FmtCommand
+		flagSet = pflag.NewFlagSet(models.FmtCommand.String(), pflag.ContinueOnError)
+		flagSet.SetOutput(ioutil.Discard)
+		flagSet.StringVarP(&dir, dirFlagLong, dirFlagShort, "", "Check the formatting of this directory, relative to root of repo, ex. 'child/dir'.")
+		flagSet.StringVarP(&project, projectFlagLong, projectFlagShort, "", fmt.Sprintf("Check the formatting of this project. Refers to the name of the project configured in %s. Cannot be used at same time as the dir flag.", yaml.AtlantisYAMLFilename))
+		flagSet.BoolVar(&fix, fixFlagLong, false, "Push a commit that formats the files to the pull request's branch.")
+	case models.ValidateCommand.String():
+		name = models.ValidateCommand
+		flagSet = pflag.NewFlagSet(models.ValidateCommand.String(), pflag.ContinueOnError)
+		flagSet.SetOutput(ioutil.Discard)
+		flagSet.StringVarP(&workspace, workspaceFlagLong, workspaceFlagShort, "", "Validate the project in this Terraform workspace.")
+		flagSet.StringVarP(&dir, dirFlagLong, dirFlagShort, "", "Validate the project in this directory, relative to root of repo, ex. 'child/dir'.")
+		flagSet.StringVarP(&project, projectFlagLong, projectFlagShort, "", fmt.Sprintf("Validate this project. Refers to the name of the project configured in %s. Cannot be used at same time as workspace or dir flags.", yaml.AtlantisYAMLFilename))
 	default:
 		return CommentParseResult{CommentResponse: fmt.Sprintf("Error: unknown command %q – this is a bug", command)}
 	}
@@ -224,6 +283,17 @@ func (e *CommentParser) Parse(comment string, vcsHost models.VCSHostType) Commen
 	} else {
 		unusedArgs = flagSet.Args()[0:flagSet.ArgsLenAtDash()]
 	}
+	// atlantis workspace takes its action and the workspace's name as
+	// arguments, ex. atlantis workspace new pr-123.
+	var workspaceAction, workspaceName string
+	if name == models.WorkspaceCommand {
+		var errMsg string
+		workspaceAction, workspaceName, errMsg = e.parseWorkspaceArgs(unusedArgs)
+		if errMsg != "" {
+			return CommentParseResult{CommentResponse: e.errMarkdown(errMsg, command, flagSet)}
+		}
+		unusedArgs = nil
+	}
 	if len(unusedArgs) > 0 {
 		return CommentParseResult{CommentResponse: e.errMarkdown(fmt.Sprintf("unknown argument(s) – %s", strings.Join(unusedArgs, " ")), command, flagSet)}
 	}
@@ -232,6 +302,19 @@ func (e *CommentParser) Parse(comment string, vcsHost models.VCSHostType) Commen
 	if flagSet.ArgsLenAtDash() != -1 {
 		extraArgs = flagSet.Args()[flagSet.ArgsLenAtDash():]
 	}
+	// terraform plan doesn't have -upgrade so atlantis plan -- -upgrade is
+	// the same as atlantis plan --upgrade.
+	if name == models.PlanCommand {
+		var planArgs []string
+		for _, arg := range extraArgs {
+			if arg == "-upgrade" || arg == "--upgrade" || arg == "-upgrade=true" {
+				upgrade = true
+				continue
+			}
+			planArgs = append(planArgs, arg)
+		}
+		extraArgs = planArgs
+	}
 
 	dir, err = e.validateDir(dir)
 	if err != nil {
@@ -255,9 +338,44 @@ func (e *CommentParser) Parse(comment string, vcsHost models.VCSHostType) Commen
 		return CommentParseResult{CommentResponse: e.errMarkdown(err, command, flagSet)}
 	}
 
-	return CommentParseResult{
-		Command: NewCommentCommand(dir, extraArgs, name, verbose, workspace, project),
+	cmd := NewCommentCommand(dir, extraArgs, name, verbose, workspace, project)
+	cmd.OpenPR = openPR
+	cmd.AllowDestroy = allowDestroy
+	cmd.UpgradeProviders = upgrade
+	cmd.Fix = fix
+	cmd.WorkspaceAction = workspaceAction
+	cmd.WorkspaceName = workspaceName
+	return CommentParseResult{Command: cmd}
+}
+
+// parseWorkspaceArgs parses the arguments of atlantis workspace into its
+// action and the workspace's name. If they're invalid it returns why.
+func (e *CommentParser) parseWorkspaceArgs(args []string) (string, string, string) {
+	if len(args) == 0 {
+		return "", "", fmt.Sprintf("missing action – one of %s", strings.Join(workspaceActions, ", "))
+	}
+	action := args[0]
+	if !e.stringInSlice(action, workspaceActions) {
+		return "", "", fmt.Sprintf("unknown action %q – one of %s", action, strings.Join(workspaceActions, ", "))
+	}
+	if action == workspaceListAction {
+		if len(args) > 1 {
+			return "", "", fmt.Sprintf("unknown argument(s) – %s", strings.Join(args[1:], " "))
+		}
+		return action, "", ""
+	}
+	if len(args) != 2 {
+		return "", "", fmt.Sprintf("%s takes the name of one workspace", action)
+	}
+	name := args[1]
+	// Same validation as the workspace flag.
+	if name != url.PathEscape(name) || strings.Contains(name, "..") {
+		return "", "", fmt.Sprintf("invalid workspace: %q", name)
+	}
+	if name == DefaultWorkspace {
+		return "", "", fmt.Sprintf("can't %s the %s workspace", action, DefaultWorkspace)
 	}
+	return action, name, ""
 }
 
 // BuildPlanComment builds a plan comment for the specified args.
@@ -340,56 +458,12 @@ func (e *CommentParser) errMarkdown(errMsg string, command string, flagSet *pfla
 	return fmt.Sprintf("```\nError: %s.\nUsage of %s:\n%s```", errMsg, command, flagSet.FlagUsagesWrapped(usagesCols))
 }
 
+// HelpComment renders the default help comment, which lists every command.
 func (e *CommentParser) HelpComment(applyDisabled bool) string {
-	buf := &bytes.Buffer{}
-	var tmpl = template.Must(template.New("").Parse(helpCommentTemplate))
-	if err := tmpl.Execute(buf, struct {
-		ApplyDisabled bool
-	}{
-		ApplyDisabled: applyDisabled,
-	}); err != nil {
-		return fmt.Sprintf("Failed to render template, this is a bug: %v", err)
-	}
-	return buf.String()
-
+	h := &HelpCommentRenderer{ApplyDisabled: applyDisabled}
+	return h.Render(nil, models.Repo{}, models.User{})
 }
 
-var helpCommentTemplate = "```cmake\n" +
-	`atlantis
-Terraform Pull Request Automation
-
-Usage:
-  atlantis <command> [options] -- [terraform options]
-
-Examples:
-  # run plan in the root directory passing the -target flag to terraform
-  atlantis plan -d . -- -target=resource
-  {{- if not .ApplyDisabled }}
-
-  # apply all unapplied plans from this pull request
-  atlantis apply
-
-  # apply the plan for the root directory and staging workspace
-  atlantis apply -d . -w staging
-{{- end }}
-
-Commands:
-  plan     Runs 'terraform plan' for the changes in this pull request.
-           To plan a specific project, use the -d, -w and -p flags.
-{{- if not .ApplyDisabled }}
-  apply    Runs 'terraform apply' on all unapplied plans from this pull request.
-           To only apply a specific plan, use the -d, -w and -p flags.
-{{- end }}
-  unlock   Removes all atlantis locks and discards all plans for this PR.
-           To unlock a specific plan you can use the Atlantis UI.
-  help     View help.
-
-Flags:
-  -h, --help   help for atlantis
-
-Use "atlantis [command] --help" for more information about a command.` +
-	"\n```"
-
 // DidYouMeanAtlantisComment is the comment we add to the pull request when
 // someone runs a command with terraform instead of atlantis.
 var DidYouMeanAtlantisComment = "Did you mean to use `atlantis` instead of `terraform`?"
@@ -57,6 +57,7 @@ func TestParse_HelpResponse(t *testing.T) {
 	for _, c := range helpComments {
 		r := commentParser.Parse(c, models.Github)
 		Equals(t, commentParser.HelpComment(false), r.CommentResponse)
+		Assert(t, r.Help, "exp %q to be parsed as help", c)
 	}
 }
 
@@ -206,6 +207,10 @@ func TestParse_SubcommandUsage(t *testing.T) {
 		"atlantis apply --help",
 		"atlantis approve_policies -h",
 		"atlantis approve_policies --help",
+		"atlantis lock -h",
+		"atlantis lock --help",
+		"atlantis revert -h",
+		"atlantis revert --help",
 	}
 	for _, c := range comments {
 		r := commentParser.Parse(c, models.Github)
@@ -576,6 +581,145 @@ func TestParse_Parsing(t *testing.T) {
 	}
 }
 
+func TestParse_Lock(t *testing.T) {
+	r := commentParser.Parse("atlantis lock -d dir -w staging", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, models.LockCommand, r.Command.Name)
+	Equals(t, "dir", r.Command.RepoRelDir)
+	Equals(t, "staging", r.Command.Workspace)
+
+	r = commentParser.Parse("atlantis lock -p project", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, "project", r.Command.ProjectName)
+
+	r = commentParser.Parse("atlantis lock --verbose", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "Error: unknown flag: --verbose"), "exp unknown flag error but got %q", r.CommentResponse)
+}
+
+func TestParse_AllowDestroy(t *testing.T) {
+	r := commentParser.Parse("atlantis apply -p project", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, false, r.Command.AllowDestroy)
+
+	r = commentParser.Parse("atlantis apply -p project --allow-destroy", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, "project", r.Command.ProjectName)
+	Equals(t, true, r.Command.AllowDestroy)
+
+	r = commentParser.Parse("atlantis plan --allow-destroy", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "Error: unknown flag: --allow-destroy"), "exp unknown flag error but got %q", r.CommentResponse)
+}
+
+func TestParse_Upgrade(t *testing.T) {
+	r := commentParser.Parse("atlantis plan -d dir --upgrade", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, true, r.Command.UpgradeProviders)
+	Equals(t, []string(nil), r.Command.Flags)
+
+	// terraform plan doesn't have -upgrade so it's the same as --upgrade.
+	r = commentParser.Parse("atlantis plan -d dir -- -upgrade -target=aws_instance.web", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, true, r.Command.UpgradeProviders)
+	Equals(t, []string{"-target=aws_instance.web"}, r.Command.Flags)
+
+	r = commentParser.Parse("atlantis plan -d dir", models.Github)
+	Equals(t, false, r.Command.UpgradeProviders)
+
+	r = commentParser.Parse("atlantis apply --upgrade", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "Error: unknown flag: --upgrade"), "exp unknown flag error but got %q", r.CommentResponse)
+}
+
+func TestParse_Revert(t *testing.T) {
+	r := commentParser.Parse("atlantis revert -d dir -w staging", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, models.RevertCommand, r.Command.Name)
+	Equals(t, "dir", r.Command.RepoRelDir)
+	Equals(t, "staging", r.Command.Workspace)
+	Equals(t, false, r.Command.OpenPR)
+
+	r = commentParser.Parse("atlantis revert -p project --open-pr --verbose", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, "project", r.Command.ProjectName)
+	Equals(t, true, r.Command.OpenPR)
+	Equals(t, true, r.Command.Verbose)
+
+	r = commentParser.Parse("atlantis plan --open-pr", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "Error: unknown flag: --open-pr"), "exp unknown flag error but got %q", r.CommentResponse)
+}
+
+func TestParse_Workspace(t *testing.T) {
+	r := commentParser.Parse("atlantis workspace new pr-123 -d dir", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, models.WorkspaceCommand, r.Command.Name)
+	Equals(t, "new", r.Command.WorkspaceAction)
+	Equals(t, "pr-123", r.Command.WorkspaceName)
+	Equals(t, "dir", r.Command.RepoRelDir)
+	Equals(t, "", r.Command.Workspace)
+
+	r = commentParser.Parse("atlantis workspace delete pr-123 -p project", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, "delete", r.Command.WorkspaceAction)
+	Equals(t, "pr-123", r.Command.WorkspaceName)
+	Equals(t, "project", r.Command.ProjectName)
+
+	r = commentParser.Parse("atlantis workspace list", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, "list", r.Command.WorkspaceAction)
+	Equals(t, "", r.Command.WorkspaceName)
+
+	errCases := map[string]string{
+		"atlantis workspace":                     "Error: missing action – one of new, delete, list.",
+		"atlantis workspace select pr-123":       `Error: unknown action "select" – one of new, delete, list.`,
+		"atlantis workspace new":                 "Error: new takes the name of one workspace.",
+		"atlantis workspace new a b":             "Error: new takes the name of one workspace.",
+		"atlantis workspace list a":              "Error: unknown argument(s) – a.",
+		"atlantis workspace delete default":      "Error: can't delete the default workspace.",
+		"atlantis workspace new ../pr-123":       `Error: invalid workspace: "../pr-123".`,
+		"atlantis workspace new pr-123 -w other": "Error: unknown shorthand flag: 'w' in -w.",
+	}
+	for comment, expErr := range errCases {
+		t.Run(comment, func(t *testing.T) {
+			r := commentParser.Parse(comment, models.Github)
+			Assert(t, strings.Contains(r.CommentResponse, expErr), "exp %q but got %q", expErr, r.CommentResponse)
+		})
+	}
+}
+
+func TestParse_Fmt(t *testing.T) {
+	r := commentParser.Parse("atlantis fmt", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, models.FmtCommand, r.Command.Name)
+	Equals(t, false, r.Command.Fix)
+
+	r = commentParser.Parse("atlantis fmt -d dir --fix -- -recursive", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, "dir", r.Command.RepoRelDir)
+	Equals(t, true, r.Command.Fix)
+	Equals(t, []string{"-recursive"}, r.Command.Flags)
+
+	r = commentParser.Parse("atlantis fmt -p project -d dir", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "cannot use -p/--project at same time as -d/--dir"), "got %q", r.CommentResponse)
+}
+
+func TestParse_Validate(t *testing.T) {
+	r := commentParser.Parse("atlantis validate", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, models.ValidateCommand, r.Command.Name)
+
+	r = commentParser.Parse("atlantis validate -d dir -w staging", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, "dir", r.Command.RepoRelDir)
+	Equals(t, "staging", r.Command.Workspace)
+
+	r = commentParser.Parse("atlantis validate -p project -- -json", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, "project", r.Command.ProjectName)
+	Equals(t, []string{"-json"}, r.Command.Flags)
+
+	r = commentParser.Parse("atlantis validate --fix", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "unknown flag: --fix"), "got %q", r.CommentResponse)
+}
+
 func TestBuildPlanApplyComment(t *testing.T) {
 	cases := []struct {
 		repoRelDir    string
@@ -703,8 +847,18 @@ Commands:
            To plan a specific project, use the -d, -w and -p flags.
   apply    Runs 'terraform apply' on all unapplied plans from this pull request.
            To only apply a specific plan, use the -d, -w and -p flags.
+  lock     Locks the projects without planning them so other PRs can't plan them.
+           To lock a specific project, use the -d, -w and -p flags.
   unlock   Removes all atlantis locks and discards all plans for this PR.
            To unlock a specific plan you can use the Atlantis UI.
+  revert   Plans the revert of this merged pull request. To open a pull
+           request with the revert, use the --open-pr flag.
+  workspace Creates, deletes or lists the Terraform workspaces of a project,
+            ex. atlantis workspace new pr-123 -d dir.
+  fmt      Checks that the Terraform files changed in this pull request are
+           formatted. To push a commit that formats them, use the --fix flag.
+  validate Runs 'terraform validate' for the changes in this pull request.
+           To validate a specific project, use the -d, -w and -p flags.
   help     View help.
 
 Flags:
@@ -729,8 +883,18 @@ Examples:
 Commands:
   plan     Runs 'terraform plan' for the changes in this pull request.
            To plan a specific project, use the -d, -w and -p flags.
+  lock     Locks the projects without planning them so other PRs can't plan them.
+           To lock a specific project, use the -d, -w and -p flags.
   unlock   Removes all atlantis locks and discards all plans for this PR.
            To unlock a specific plan you can use the Atlantis UI.
+  revert   Plans the revert of this merged pull request. To open a pull
+           request with the revert, use the --open-pr flag.
+  workspace Creates, deletes or lists the Terraform workspaces of a project,
+            ex. atlantis workspace new pr-123 -d dir.
+  fmt      Checks that the Terraform files changed in this pull request are
+           formatted. To push a commit that formats them, use the --fix flag.
+  validate Runs 'terraform validate' for the changes in this pull request.
+           To validate a specific project, use the -d, -w and -p flags.
   help     View help.
 
 Flags:
@@ -795,11 +959,14 @@ var PlanUsage = `Usage of plan:
   -p, --project string     Which project to run plan for. Refers to the name of the
                            project configured in atlantis.yaml. Cannot be used at
                            same time as workspace or dir flags.
+      --upgrade            Upgrade providers with terraform init -upgrade and list
+                           the versions that changed.
       --verbose            Append Atlantis log to comment.
   -w, --workspace string   Switch to this Terraform workspace before planning.
 `
 
 var ApplyUsage = `Usage of apply:
+      --allow-destroy      Confirm applying plans that destroy or replace resources.
   -d, --dir string         Apply the plan for this directory, relative to root of
                            repo, ex. 'child/dir'.
   -p, --project string     Apply the plan for this project. Refers to the name of
@@ -0,0 +1,111 @@
+package events
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// CommentTemplateExt is the extension of the files of comment templates.
+const CommentTemplateExt = ".tmpl"
+
+// CommentTemplates are the templates of comments after applying the
+// overrides of an operator. Overrides are files in a directory that redefine
+// the default templates with {{ define "name" }} blocks. Files directly in the
+// directory override the templates of all repos and files in the
+// subdirectory {hostname}/{owner}/{repo}, ex. github.com/runatlantis/atlantis,
+// override them for that repo.
+type CommentTemplates struct {
+	all   *template.Template
+	repos map[string]*template.Template
+}
+
+// LoadCommentTemplates loads the overrides in dir.
+func LoadCommentTemplates(dir string) (*CommentTemplates, error) {
+	all, err := overrideCommentTemplates(defaultCommentTemplates, dir)
+	if err != nil {
+		return nil, err
+	}
+	c := &CommentTemplates{all: all, repos: make(map[string]*template.Template)}
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() || path == dir {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		repo, err := overrideCommentTemplates(all, path)
+		if err != nil {
+			return err
+		}
+		if repo != all {
+			c.repos[filepath.ToSlash(rel)] = repo
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "loading comment templates from %s", dir)
+	}
+	return c, nil
+}
+
+// For returns the templates of repo. If c is nil, it returns the default
+// templates.
+func (c *CommentTemplates) For(repo models.Repo) *template.Template {
+	if c == nil {
+		return defaultCommentTemplates
+	}
+	if t, ok := c.repos[repo.VCSHost.Hostname+"/"+repo.FullName]; ok {
+		return t
+	}
+	return c.all
+}
+
+// overrideCommentTemplates returns a copy of base with the overrides of the
+// files directly in dir, or base itself if there are none.
+func overrideCommentTemplates(base *template.Template, dir string) (*template.Template, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+CommentTemplateExt))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return base, nil
+	}
+	sort.Strings(files)
+	t := template.Must(base.Clone())
+	for _, f := range files {
+		text, err := ioutil.ReadFile(f) // nolint: gosec
+		if err != nil {
+			return nil, err
+		}
+		name := filepath.Base(f)
+		parsed, err := t.New(name).Parse(string(text))
+		if err != nil {
+			return nil, err
+		}
+		// Text outside of define blocks would be silently ignored.
+		if parsed.Tree != nil && strings.TrimSpace(parsed.Tree.Root.String()) != "" {
+			return nil, fmt.Errorf("%s: templates must be in {{ define }} blocks", f)
+		}
+	}
+	for _, tmpl := range t.Templates() {
+		if strings.HasSuffix(tmpl.Name(), CommentTemplateExt) {
+			continue
+		}
+		if defaultCommentTemplates.Lookup(tmpl.Name()) == nil {
+			return nil, fmt.Errorf("%s: unknown template %q", dir, tmpl.Name())
+		}
+	}
+	return t, nil
+}
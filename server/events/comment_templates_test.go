@@ -0,0 +1,86 @@
+package events_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/models"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestLoadCommentTemplates_Overrides(t *testing.T) {
+	dir, cleanup := TempDir(t)
+	defer cleanup()
+	writeCommentTemplate(t, dir, "header.tmpl", `{{ define "header" }}**{{ .Command }}** on {{ .Repo.FullName }}
+
+{{ end }}`)
+	writeCommentTemplate(t, dir, "footer.tmpl", `{{ define "footer" }}
+---
+Owned by the platform team.{{ end }}`)
+	writeCommentTemplate(t, filepath.Join(dir, "github.com", "owner", "special"), "footer.tmpl", `{{ define "footer" }}
+---
+Owned by the special team.{{ end }}`)
+
+	tmpls, err := events.LoadCommentTemplates(dir)
+	Ok(t, err)
+	r := events.MarkdownRenderer{Templates: tmpls}
+	res := events.CommandResult{Error: errors.New("err")}
+
+	s := r.Render(res, models.PlanCommand, "", false, repoOn(models.Github))
+	Equals(t, "**Plan** on owner/repo\n\n**Plan Error**\n```\nerr\n```\n\n---\nOwned by the platform team.", s)
+
+	special := repoOn(models.Github)
+	special.FullName = "owner/special"
+	s = r.Render(res, models.ApplyCommand, "", false, special)
+	Equals(t, "**Apply** on owner/special\n\n**Apply Error**\n```\nerr\n```\n\n---\nOwned by the special team.", s)
+}
+
+func TestLoadCommentTemplates_OverridesBody(t *testing.T) {
+	dir, cleanup := TempDir(t)
+	defer cleanup()
+	writeCommentTemplate(t, dir, "errors.tmpl", `{{ define "unwrappedErr" }}:x: {{ .Command }} failed: {{ .Error }}{{ end }}`)
+
+	tmpls, err := events.LoadCommentTemplates(dir)
+	Ok(t, err)
+	r := events.MarkdownRenderer{Templates: tmpls}
+	s := r.Render(events.CommandResult{Error: errors.New("err")}, models.PlanCommand, "", false, repoOn(models.Github))
+	Equals(t, ":x: Plan failed: err\n", s)
+}
+
+func TestLoadCommentTemplates_Empty(t *testing.T) {
+	dir, cleanup := TempDir(t)
+	defer cleanup()
+
+	tmpls, err := events.LoadCommentTemplates(dir)
+	Ok(t, err)
+	res := events.CommandResult{Error: errors.New("err")}
+	exp := (&events.MarkdownRenderer{}).Render(res, models.PlanCommand, "log", true, repoOn(models.Github))
+	Equals(t, exp, (&events.MarkdownRenderer{Templates: tmpls}).Render(res, models.PlanCommand, "log", true, repoOn(models.Github)))
+}
+
+func TestLoadCommentTemplates_Errors(t *testing.T) {
+	cases := map[string]string{
+		"unknown template":   `{{ define "heading" }}hi{{ end }}`,
+		"outside define":     `hi`,
+		"invalid template":   `{{ define "header" }}{{ .Command }{{ end }}`,
+		"undefined function": `{{ define "header" }}{{ shout .Command }}{{ end }}`,
+	}
+	for name, text := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir, cleanup := TempDir(t)
+			defer cleanup()
+			writeCommentTemplate(t, dir, "header.tmpl", text)
+			_, err := events.LoadCommentTemplates(dir)
+			Assert(t, err != nil, "exp err")
+		})
+	}
+}
+
+func writeCommentTemplate(t *testing.T, dir string, name string, text string) {
+	Ok(t, os.MkdirAll(dir, 0700))
+	Ok(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(text), 0600))
+}
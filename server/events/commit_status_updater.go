@@ -32,9 +32,17 @@ type CommitStatusUpdater interface {
 	// UpdateCombinedCount updates the combined status to reflect the
 	// numSuccess out of numTotal.
 	UpdateCombinedCount(repo models.Repo, pull models.PullRequest, status models.CommitStatus, command models.CommandName, numSuccess int, numTotal int) error
+	// UpdatePlanCount updates the combined plan status like
+	// UpdateCombinedCount and adds how many resources the plans change, if
+	// counts isn't nil.
+	UpdatePlanCount(repo models.Repo, pull models.PullRequest, status models.CommitStatus, numSuccess int, numTotal int, counts *models.PlanCounts) error
 	// UpdateProject sets the commit status for the project represented by
 	// ctx.
 	UpdateProject(ctx models.ProjectCommandContext, cmdName models.CommandName, status models.CommitStatus, url string) error
+	// UpdatePlanProject sets the plan commit status for the project
+	// represented by ctx like UpdateProject and adds how many resources the
+	// plan changes, if counts isn't nil.
+	UpdatePlanProject(ctx models.ProjectCommandContext, status models.CommitStatus, url string, counts *models.PlanCounts) error
 }
 
 // DefaultCommitStatusUpdater implements CommitStatusUpdater.
@@ -75,12 +83,16 @@ func (d *DefaultCommitStatusUpdater) UpdateCombinedCount(repo models.Repo, pull
 	return d.Client.UpdateStatus(repo, pull, status, src, fmt.Sprintf("%d/%d projects %s successfully.", numSuccess, numTotal, cmdVerb), "")
 }
 
-func (d *DefaultCommitStatusUpdater) UpdateProject(ctx models.ProjectCommandContext, cmdName models.CommandName, status models.CommitStatus, url string) error {
-	projectID := ctx.ProjectName
-	if projectID == "" {
-		projectID = fmt.Sprintf("%s/%s", ctx.RepoRelDir, ctx.Workspace)
+func (d *DefaultCommitStatusUpdater) UpdatePlanCount(repo models.Repo, pull models.PullRequest, status models.CommitStatus, numSuccess int, numTotal int, counts *models.PlanCounts) error {
+	if counts == nil {
+		return d.UpdateCombinedCount(repo, pull, status, models.PlanCommand, numSuccess, numTotal)
 	}
-	src := fmt.Sprintf("%s/%s: %s", d.StatusName, cmdName.String(), projectID)
+	src := fmt.Sprintf("%s/%s", d.StatusName, models.PlanCommand.String())
+	descrip := fmt.Sprintf("%d/%d projects planned successfully: %s", numSuccess, numTotal, counts)
+	return d.Client.UpdateStatus(repo, pull, status, src, descrip, "")
+}
+
+func (d *DefaultCommitStatusUpdater) UpdateProject(ctx models.ProjectCommandContext, cmdName models.CommandName, status models.CommitStatus, url string) error {
 	var descripWords string
 	switch status {
 	case models.PendingCommitStatus:
@@ -91,5 +103,23 @@ func (d *DefaultCommitStatusUpdater) UpdateProject(ctx models.ProjectCommandCont
 		descripWords = "succeeded."
 	}
 	descrip := fmt.Sprintf("%s %s", strings.Title(cmdName.String()), descripWords)
-	return d.Client.UpdateStatus(ctx.BaseRepo, ctx.Pull, status, src, descrip, url)
+	return d.Client.UpdateStatus(ctx.BaseRepo, ctx.Pull, status, d.projectSrc(ctx, cmdName), descrip, url)
+}
+
+func (d *DefaultCommitStatusUpdater) UpdatePlanProject(ctx models.ProjectCommandContext, status models.CommitStatus, url string, counts *models.PlanCounts) error {
+	if counts == nil || status != models.SuccessCommitStatus {
+		return d.UpdateProject(ctx, models.PlanCommand, status, url)
+	}
+	descrip := fmt.Sprintf("Plan succeeded: %s", counts)
+	return d.Client.UpdateStatus(ctx.BaseRepo, ctx.Pull, status, d.projectSrc(ctx, models.PlanCommand), descrip, url)
+}
+
+// projectSrc is the source of the commit status of cmdName for the project
+// represented by ctx.
+func (d *DefaultCommitStatusUpdater) projectSrc(ctx models.ProjectCommandContext, cmdName models.CommandName) string {
+	projectID := ctx.ProjectName
+	if projectID == "" {
+		projectID = fmt.Sprintf("%s/%s", ctx.RepoRelDir, ctx.Workspace)
+	}
+	return fmt.Sprintf("%s/%s: %s", d.StatusName, cmdName.String(), projectID)
 }
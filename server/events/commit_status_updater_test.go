@@ -144,6 +144,44 @@ func TestUpdateCombinedCount(t *testing.T) {
 
 // Test that it sets the "source" properly depending on if the project is
 // named or not.
+func TestUpdatePlanCount(t *testing.T) {
+	cases := []struct {
+		status     models.CommitStatus
+		numSuccess int
+		counts     *models.PlanCounts
+		expDescrip string
+	}{
+		{
+			status:     models.SuccessCommitStatus,
+			numSuccess: 2,
+			expDescrip: "2/2 projects planned successfully.",
+		},
+		{
+			status:     models.SuccessCommitStatus,
+			numSuccess: 2,
+			counts:     &models.PlanCounts{Add: 3, Change: 1},
+			expDescrip: "2/2 projects planned successfully: +3 ~1 -0",
+		},
+		{
+			status:     models.FailedCommitStatus,
+			numSuccess: 1,
+			counts:     &models.PlanCounts{},
+			expDescrip: "1/2 projects planned successfully: no changes",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.expDescrip, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			client := mocks.NewMockClient()
+			s := events.DefaultCommitStatusUpdater{Client: client, StatusName: "atlantis-test"}
+			err := s.UpdatePlanCount(models.Repo{}, models.PullRequest{}, c.status, c.numSuccess, 2, c.counts)
+			Ok(t, err)
+			client.VerifyWasCalledOnce().UpdateStatus(models.Repo{}, models.PullRequest{}, c.status, "atlantis-test/plan", c.expDescrip, "")
+		})
+	}
+}
+
 func TestDefaultCommitStatusUpdater_UpdateProjectSrc(t *testing.T) {
 	RegisterMockTestingT(t)
 	cases := []struct {
@@ -242,6 +280,52 @@ func TestDefaultCommitStatusUpdater_UpdateProject(t *testing.T) {
 }
 
 // Test that we can set the status name.
+func TestDefaultCommitStatusUpdater_UpdatePlanProject(t *testing.T) {
+	cases := []struct {
+		status     models.CommitStatus
+		counts     *models.PlanCounts
+		expDescrip string
+	}{
+		{
+			models.SuccessCommitStatus,
+			&models.PlanCounts{Add: 1, Destroy: 2},
+			"Plan succeeded: +1 ~0 -2",
+		},
+		{
+			models.SuccessCommitStatus,
+			&models.PlanCounts{},
+			"Plan succeeded: no changes",
+		},
+		{
+			models.SuccessCommitStatus,
+			nil,
+			"Plan succeeded.",
+		},
+		{
+			models.FailedCommitStatus,
+			&models.PlanCounts{Add: 1},
+			"Plan failed.",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.expDescrip, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			client := mocks.NewMockClient()
+			s := events.DefaultCommitStatusUpdater{Client: client, StatusName: "atlantis"}
+			err := s.UpdatePlanProject(models.ProjectCommandContext{
+				RepoRelDir: ".",
+				Workspace:  "default",
+			},
+				c.status,
+				"url",
+				c.counts)
+			Ok(t, err)
+			client.VerifyWasCalledOnce().UpdateStatus(models.Repo{}, models.PullRequest{}, c.status, "atlantis/plan: ./default", c.expDescrip, "url")
+		})
+	}
+}
+
 func TestDefaultCommitStatusUpdater_UpdateProjectCustomStatusName(t *testing.T) {
 	RegisterMockTestingT(t)
 	client := mocks.NewMockClient()
@@ -0,0 +1,86 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// ConcurrencyLimiter limits how many project commands run at the same time
+// for each repo and for each project dir, across all pull requests, so that
+// a heavyweight repo can't keep the server busy while the others wait.
+// Commands over a limit wait until one of the commands running for the same
+// repo or project completes. A nil ConcurrencyLimiter doesn't limit
+// anything. It's safe for concurrent use.
+type ConcurrencyLimiter struct {
+	// RepoLimit and ProjectLimit are how many commands can run at the same
+	// time for each repo and project dir. 0 means no limit.
+	RepoLimit    int
+	ProjectLimit int
+
+	// mutex guards running and is cond's lock.
+	mutex sync.Mutex
+	cond  *sync.Cond
+	// running is the number of commands running by repo and project key.
+	running map[string]int
+}
+
+// NewConcurrencyLimiter returns a limiter allowing repoLimit commands for
+// each repo and projectLimit commands for each project dir to run at the
+// same time. If both are 0, it returns nil so nothing is limited.
+func NewConcurrencyLimiter(repoLimit int, projectLimit int) *ConcurrencyLimiter {
+	if repoLimit <= 0 && projectLimit <= 0 {
+		return nil
+	}
+	l := &ConcurrencyLimiter{
+		RepoLimit:    repoLimit,
+		ProjectLimit: projectLimit,
+		running:      make(map[string]int),
+	}
+	l.cond = sync.NewCond(&l.mutex)
+	return l
+}
+
+// Acquire blocks until a command can run for repoRelDir of repo and returns
+// the function to call once it completes.
+func (l *ConcurrencyLimiter) Acquire(log logging.SimpleLogging, repo models.Repo, repoRelDir string) func() {
+	if l == nil {
+		return func() {}
+	}
+	repoKey := fmt.Sprintf("repo:%s/%s", repo.VCSHost.Hostname, repo.FullName)
+	projectKey := fmt.Sprintf("project:%s/%s/%s", repo.VCSHost.Hostname, repo.FullName, repoRelDir)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for i := 0; !l.allowed(repoKey, projectKey); i++ {
+		if i == 0 {
+			log.Info("waiting for other commands running for repo %s or dir %q to complete", repo.FullName, repoRelDir)
+		}
+		l.cond.Wait()
+	}
+	l.running[repoKey]++
+	l.running[projectKey]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mutex.Lock()
+			defer l.mutex.Unlock()
+			for _, key := range []string{repoKey, projectKey} {
+				if l.running[key]--; l.running[key] <= 0 {
+					delete(l.running, key)
+				}
+			}
+			l.cond.Broadcast()
+		})
+	}
+}
+
+func (l *ConcurrencyLimiter) allowed(repoKey string, projectKey string) bool {
+	if l.RepoLimit > 0 && l.running[repoKey] >= l.RepoLimit {
+		return false
+	}
+	return l.ProjectLimit <= 0 || l.running[projectKey] < l.ProjectLimit
+}
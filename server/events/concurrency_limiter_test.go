@@ -0,0 +1,84 @@
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestNewConcurrencyLimiter_Disabled(t *testing.T) {
+	l := events.NewConcurrencyLimiter(0, 0)
+	Assert(t, l == nil, "expected nil limiter")
+	// A nil limiter never blocks.
+	log := logging.NewNoopLogger(t)
+	repo := models.Repo{FullName: "owner/repo"}
+	release1 := l.Acquire(log, repo, "dir")
+	release2 := l.Acquire(log, repo, "dir")
+	release1()
+	release2()
+}
+
+func TestConcurrencyLimiter_RepoLimit(t *testing.T) {
+	l := events.NewConcurrencyLimiter(1, 0)
+	log := logging.NewNoopLogger(t)
+	repo := models.Repo{FullName: "owner/repo"}
+	other := models.Repo{FullName: "owner/other"}
+
+	release := l.Acquire(log, repo, "dir1")
+	// Other repos aren't limited by the commands running for repo.
+	l.Acquire(log, other, "dir1")()
+	acquired := acquireAsync(l, log, repo, "dir2")
+	assertBlocked(t, acquired)
+
+	release()
+	// Calling release again doesn't free another slot.
+	release()
+	assertAcquired(t, acquired)()
+}
+
+func TestConcurrencyLimiter_ProjectLimit(t *testing.T) {
+	l := events.NewConcurrencyLimiter(0, 1)
+	log := logging.NewNoopLogger(t)
+	repo := models.Repo{FullName: "owner/repo"}
+
+	release := l.Acquire(log, repo, "dir1")
+	// Other dirs of the same repo aren't limited.
+	l.Acquire(log, repo, "dir2")()
+	acquired := acquireAsync(l, log, repo, "dir1")
+	assertBlocked(t, acquired)
+
+	release()
+	assertAcquired(t, acquired)()
+}
+
+func acquireAsync(l *events.ConcurrencyLimiter, log logging.SimpleLogging, repo models.Repo, dir string) chan func() {
+	acquired := make(chan func(), 1)
+	go func() {
+		acquired <- l.Acquire(log, repo, dir)
+	}()
+	return acquired
+}
+
+func assertBlocked(t *testing.T, acquired chan func()) {
+	t.Helper()
+	select {
+	case <-acquired:
+		t.Fatal("command ran over the limit")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func assertAcquired(t *testing.T, acquired chan func()) func() {
+	t.Helper()
+	select {
+	case release := <-acquired:
+		return release
+	case <-time.After(5 * time.Second):
+		t.Fatal("command didn't run after the others completed")
+		return nil
+	}
+}
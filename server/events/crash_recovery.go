@@ -0,0 +1,111 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/runatlantis/atlantis/server/events/db"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// CrashRecovery handles the commands that were interrupted because Atlantis
+// crashed or was restarted while they ran. It comments on their pull
+// requests, runs interrupted plans again and flags interrupted applies for
+// manual review since they may have been partially applied.
+type CrashRecovery struct {
+	Journal       *CommandJournal
+	VCSClient     vcs.Client
+	CommandRunner CommandRunner
+	DB            db.Database
+	Logger        logging.SimpleLogging
+}
+
+// Recover handles the commands left in the journal by the previous run. It
+// must be called before any command is run. The entries are removed from the
+// journal before returning, and are handled in the background.
+func (c *CrashRecovery) Recover() error {
+	entries, err := c.Journal.List()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := c.Journal.Finish(entry.ID); err != nil {
+			return err
+		}
+	}
+	if len(entries) > 0 {
+		c.Logger.Warn("found %d command(s) interrupted by the last shutdown", len(entries))
+		go c.HandleInterrupted(entries)
+	}
+	return nil
+}
+
+// HandleInterrupted comments on the pull requests of entries and recovers
+// their commands. Errors are logged.
+func (c *CrashRecovery) HandleInterrupted(entries []JournalEntry) {
+	// Group by pull request, keeping the order the commands started in, so
+	// each pull request gets a single comment.
+	var pulls []models.PullRequest
+	byPull := make(map[string][]JournalEntry)
+	for _, entry := range entries {
+		key := fmt.Sprintf("%s/%d", entry.Pull.BaseRepo.FullName, entry.Pull.Num)
+		if _, ok := byPull[key]; !ok {
+			pulls = append(pulls, entry.Pull)
+		}
+		byPull[key] = append(byPull[key], entry)
+	}
+	for _, pull := range pulls {
+		c.recoverPull(pull, byPull[fmt.Sprintf("%s/%d", pull.BaseRepo.FullName, pull.Num)])
+	}
+}
+
+func (c *CrashRecovery) recoverPull(pull models.PullRequest, entries []JournalEntry) {
+	var replans []JournalEntry
+	seen := make(map[string]bool)
+	comment := new(bytes.Buffer)
+	fmt.Fprint(comment, "**Warning**: Atlantis was restarted while running commands on this pull request:\n\n")
+	for _, entry := range entries {
+		project := fmt.Sprintf("dir: `%s` workspace: `%s`", entry.RepoRelDir, entry.Workspace)
+		if entry.ProjectName != "" {
+			project = fmt.Sprintf("project: `%s` %s", entry.ProjectName, project)
+		}
+		switch entry.Command {
+		case models.ApplyCommand:
+			fmt.Fprintf(comment, "- `apply` for %s was interrupted and **may have been partially applied**. Review the state of its resources manually before running `atlantis apply` again.\n", project)
+			if err := c.DB.UpdateProjectStatus(pull, entry.Workspace, entry.RepoRelDir, models.ErroredApplyStatus); err != nil {
+				c.Logger.Err("failed marking interrupted apply as errored for pull request #%d: %s", pull.Num, err)
+			}
+		default:
+			// Policy checks only run after a plan, so both are recovered
+			// by planning again.
+			fmt.Fprintf(comment, "- `%s` for %s was interrupted and is being run again.\n", entry.Command.String(), project)
+			key := entry.ProjectName + "/" + entry.RepoRelDir + "/" + entry.Workspace
+			if !seen[key] {
+				seen[key] = true
+				replans = append(replans, entry)
+			}
+		}
+	}
+	if err := c.VCSClient.CreateComment(pull.BaseRepo, pull.Num, comment.String(), ""); err != nil {
+		c.Logger.Warn("failed commenting on pull request #%d about interrupted commands: %s", pull.Num, err)
+	}
+
+	for _, entry := range replans {
+		cmd := &CommentCommand{
+			Name:  models.PlanCommand,
+			Flags: entry.Flags,
+		}
+		if entry.ProjectName != "" {
+			cmd.ProjectName = entry.ProjectName
+		} else {
+			cmd.RepoRelDir = entry.RepoRelDir
+			cmd.Workspace = entry.Workspace
+		}
+		headRepo := entry.HeadRepo
+		maybePull := pull
+		c.CommandRunner.RunCommentCommand(context.Background(), pull.BaseRepo, &headRepo, &maybePull, entry.User, pull.Num, cmd)
+	}
+}
@@ -0,0 +1,88 @@
+package events_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/db"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/models/fixtures"
+	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestCrashRecovery_Recover(t *testing.T) {
+	RegisterMockTestingT(t)
+	journal := newCommandJournal(t)
+	dataDir, cleanup := TempDir(t)
+	defer cleanup()
+	database, err := db.New(dataDir)
+	Ok(t, err)
+	vcsClient := vcsmocks.NewMockClient()
+	commandRunner := mocks.NewMockCommandRunner()
+	recovery := events.CrashRecovery{
+		Journal:       journal,
+		VCSClient:     vcsClient,
+		CommandRunner: commandRunner,
+		DB:            database,
+		Logger:        logging.NewNoopLogger(t),
+	}
+
+	pull := fixtures.Pull
+	pull.BaseRepo = fixtures.GithubRepo
+	_, err = database.UpdatePullWithResults(pull, []models.ProjectResult{
+		{Command: models.PlanCommand, RepoRelDir: "network", Workspace: "default", PlanSuccess: &models.PlanSuccess{}},
+	})
+	Ok(t, err)
+	ctx := func(dir string, projectName string) models.ProjectCommandContext {
+		return models.ProjectCommandContext{
+			Pull:               pull,
+			HeadRepo:           fixtures.GithubRepo,
+			User:               fixtures.User,
+			RepoRelDir:         dir,
+			Workspace:          "default",
+			ProjectName:        projectName,
+			EscapedCommentArgs: []string{`\-\l\o\c\k\=\f\a\l\s\e`},
+		}
+	}
+	_, err = journal.Start(models.ApplyCommand, ctx("network", ""))
+	Ok(t, err)
+	_, err = journal.Start(models.PlanCommand, ctx("app", "web"))
+	Ok(t, err)
+	// The policy check of the same project is recovered by the same plan.
+	_, err = journal.Start(models.PolicyCheckCommand, ctx("app", "web"))
+	Ok(t, err)
+
+	entries, err := journal.List()
+	Ok(t, err)
+	recovery.HandleInterrupted(entries)
+
+	_, pullNum, comment, _ := vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString(), AnyString()).GetCapturedArguments()
+	Equals(t, pull.Num, pullNum)
+	Assert(t, strings.Contains(comment, "- `apply` for dir: `network` workspace: `default` was interrupted and **may have been partially applied**"), "unexpected comment %q", comment)
+	Assert(t, strings.Contains(comment, "- `plan` for project: `web` dir: `app` workspace: `default` was interrupted and is being run again."), "unexpected comment %q", comment)
+	Assert(t, strings.Contains(comment, "- `policy_check` for project: `web` dir: `app` workspace: `default` was interrupted and is being run again."), "unexpected comment %q", comment)
+
+	_, _, _, _, user, _, cmd := commandRunner.VerifyWasCalledOnce().RunCommentCommand(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyPtrToModelsRepo(), matchers.AnyPtrToModelsPullRequest(), matchers.AnyModelsUser(), AnyInt(), matchers.AnyPtrToEventsCommentCommand()).GetCapturedArguments()
+	Equals(t, fixtures.User, user)
+	Equals(t, &events.CommentCommand{
+		Name:        models.PlanCommand,
+		ProjectName: "web",
+		Flags:       []string{"-lock=false"},
+	}, cmd)
+
+	status, err := database.GetPullStatus(pull)
+	Ok(t, err)
+	Equals(t, models.ErroredApplyStatus, status.Projects[0].Status)
+
+	// Recover removes the entries from the journal.
+	Ok(t, recovery.Recover())
+	entries, err = journal.List()
+	Ok(t, err)
+	Equals(t, 0, len(entries))
+}
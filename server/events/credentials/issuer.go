@@ -0,0 +1,129 @@
+// Package credentials provides short-lived cloud credentials to project
+// workflows by exchanging OIDC tokens with cloud providers.
+package credentials
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// DefaultTokenTTL is how long tokens issued by Atlantis are valid for. They
+// only need to last until they're exchanged.
+const DefaultTokenTTL = 10 * time.Minute
+
+const (
+	// DiscoveryPath is the path of the OpenID Connect discovery document.
+	DiscoveryPath = "/.well-known/openid-configuration"
+	// JWKSPath is the path of the issuer's public keys.
+	JWKSPath = "/.well-known/jwks"
+)
+
+// Issuer issues OIDC tokens signed with an RSA key. Cloud providers verify
+// the tokens using the public keys served at JWKSPath.
+type Issuer struct {
+	// URL is the issuer, ex. https://atlantis.example.com. Discovery and
+	// JWKS documents must be served under it.
+	URL   string
+	key   *rsa.PrivateKey
+	keyID string
+	now   func() time.Time
+}
+
+// NewIssuer returns an issuer for url that signs tokens with the PEM-encoded
+// RSA private key in keyFile.
+func NewIssuer(url string, keyFile string) (*Issuer, error) {
+	contents, err := ioutil.ReadFile(keyFile) // nolint: gosec
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", keyFile)
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(contents)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", keyFile)
+	}
+	return newIssuer(url, key)
+}
+
+func newIssuer(url string, key *rsa.PrivateKey) (*Issuer, error) {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	// The key ID is derived from the public key so it changes when the key
+	// is rotated.
+	sum := sha256.Sum256(der)
+	return &Issuer{
+		URL:   strings.TrimSuffix(url, "/"),
+		key:   key,
+		keyID: base64.RawURLEncoding.EncodeToString(sum[:16]),
+		now:   time.Now,
+	}, nil
+}
+
+// Token returns a signed token for subject and audience. claims are added
+// to the standard claims.
+func (i *Issuer) Token(subject string, audience string, claims map[string]interface{}) (string, error) {
+	now := i.now()
+	mapClaims := jwt.MapClaims{
+		"iss": i.URL,
+		"sub": subject,
+		"aud": audience,
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(DefaultTokenTTL).Unix(),
+	}
+	for k, v := range claims {
+		mapClaims[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, mapClaims)
+	token.Header["kid"] = i.keyID
+	return token.SignedString(i.key)
+}
+
+// ServeDiscovery serves the OpenID Connect discovery document.
+func (i *Issuer) ServeDiscovery(w http.ResponseWriter, _ *http.Request) {
+	i.writeJSON(w, map[string]interface{}{
+		"issuer":                                i.URL,
+		"jwks_uri":                              i.URL + JWKSPath,
+		"response_types_supported":              []string{"id_token"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"claims_supported":                      []string{"iss", "sub", "aud", "iat", "nbf", "exp", "repository", "pull_number", "project", "dir", "workspace", "command", "user"},
+	})
+}
+
+// ServeJWKS serves the public key used to verify tokens.
+func (i *Issuer) ServeJWKS(w http.ResponseWriter, _ *http.Request) {
+	i.writeJSON(w, map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"use": "sig",
+				"alg": "RS256",
+				"kid": i.keyID,
+				"n":   base64.RawURLEncoding.EncodeToString(i.key.PublicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(i.key.PublicKey.E)).Bytes()),
+			},
+		},
+	})
+}
+
+func (i *Issuer) writeJSON(w http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data) // nolint: errcheck
+}
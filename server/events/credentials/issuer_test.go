@@ -0,0 +1,88 @@
+package credentials_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/runatlantis/atlantis/server/events/credentials"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// newTestIssuer returns an issuer for https://atlantis.example.com/ using a
+// new key.
+func newTestIssuer(t *testing.T) *credentials.Issuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Ok(t, err)
+	tmp, cleanup := TempDir(t)
+	t.Cleanup(cleanup)
+	keyFile := filepath.Join(tmp, "key.pem")
+	Ok(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600))
+	issuer, err := credentials.NewIssuer("https://atlantis.example.com/", keyFile)
+	Ok(t, err)
+	return issuer
+}
+
+func TestNewIssuer_InvalidKey(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	keyFile := filepath.Join(tmp, "key.pem")
+	Ok(t, ioutil.WriteFile(keyFile, []byte("not a key"), 0600))
+	_, err := credentials.NewIssuer("https://atlantis.example.com", keyFile)
+	ErrContains(t, "parsing "+keyFile, err)
+}
+
+func TestIssuer_TokenVerifiesWithJWKS(t *testing.T) {
+	issuer := newTestIssuer(t)
+	token, err := issuer.Token("repo:owner/repo:dir:.:workspace:default", "sts.amazonaws.com", map[string]interface{}{"pull_number": 1})
+	Ok(t, err)
+
+	w := httptest.NewRecorder()
+	issuer.ServeJWKS(w, httptest.NewRequest("GET", credentials.JWKSPath, nil))
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	Ok(t, json.Unmarshal(w.Body.Bytes(), &jwks))
+	Equals(t, 1, len(jwks.Keys))
+	n, err := base64.RawURLEncoding.DecodeString(jwks.Keys[0].N)
+	Ok(t, err)
+	e, err := base64.RawURLEncoding.DecodeString(jwks.Keys[0].E)
+	Ok(t, err)
+	pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+
+	parsed, err := jwt.Parse(token, func(tok *jwt.Token) (interface{}, error) {
+		Equals(t, jwks.Keys[0].Kid, tok.Header["kid"])
+		return pub, nil
+	})
+	Ok(t, err)
+	claims := parsed.Claims.(jwt.MapClaims)
+	Equals(t, "https://atlantis.example.com", claims["iss"])
+	Equals(t, "repo:owner/repo:dir:.:workspace:default", claims["sub"])
+	Equals(t, "sts.amazonaws.com", claims["aud"])
+	Equals(t, float64(1), claims["pull_number"])
+	Assert(t, claims.VerifyExpiresAt(int64(claims["iat"].(float64))+1, true), "exp token not to be expired")
+}
+
+func TestIssuer_ServeDiscovery(t *testing.T) {
+	issuer := newTestIssuer(t)
+	w := httptest.NewRecorder()
+	issuer.ServeDiscovery(w, httptest.NewRequest("GET", credentials.DiscoveryPath, nil))
+	var doc map[string]interface{}
+	Ok(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	Equals(t, "https://atlantis.example.com", doc["issuer"])
+	Equals(t, "https://atlantis.example.com/.well-known/jwks", doc["jwks_uri"])
+	Equals(t, "application/json", w.Header().Get("Content-Type"))
+}
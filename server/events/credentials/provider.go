@@ -0,0 +1,272 @@
+package credentials
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awscredentials "github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+)
+
+const (
+	// DefaultGCPSTSURL is Google's Security Token Service endpoint.
+	DefaultGCPSTSURL = "https://sts.googleapis.com/v1/token"
+	// DefaultGCPIAMCredentialsURL is Google's IAM Credentials API.
+	DefaultGCPIAMCredentialsURL = "https://iamcredentials.googleapis.com/v1"
+
+	defaultAWSAudience   = "sts.amazonaws.com"
+	defaultAzureAudience = "api://AzureADTokenExchange"
+	gcpScope             = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_provider.go Provider
+
+// Provider provides short-lived cloud credentials to project workflows.
+type Provider interface {
+	// Env returns environment variables with credentials for the project
+	// described by ctx. It returns nil if the project doesn't have any
+	// cloud credentials configured.
+	Env(ctx models.ProjectCommandContext) (map[string]string, error)
+}
+
+// DefaultProvider exchanges OIDC tokens, either issued by Atlantis or read
+// from an ambient workload identity token file, for cloud credentials.
+type DefaultProvider struct {
+	// Issuer issues tokens. If nil, projects must configure a token_file.
+	Issuer *Issuer
+	// STS is used to assume AWS roles.
+	STS                  stsiface.STSAPI
+	HTTPClient           *http.Client
+	GCPSTSURL            string
+	GCPIAMCredentialsURL string
+}
+
+// NewDefaultProvider returns a provider that issues tokens with issuer, which
+// can be nil.
+func NewDefaultProvider(issuer *Issuer) (*DefaultProvider, error) {
+	// AssumeRoleWithWebIdentity doesn't need credentials. The token is the
+	// only proof of identity.
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config: aws.Config{Credentials: awscredentials.AnonymousCredentials},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating AWS session")
+	}
+	return &DefaultProvider{
+		Issuer:               issuer,
+		STS:                  sts.New(sess),
+		HTTPClient:           &http.Client{Timeout: 30 * time.Second},
+		GCPSTSURL:            DefaultGCPSTSURL,
+		GCPIAMCredentialsURL: DefaultGCPIAMCredentialsURL,
+	}, nil
+}
+
+// Env returns the credentials for each configured cloud.
+func (d *DefaultProvider) Env(ctx models.ProjectCommandContext) (map[string]string, error) {
+	creds := ctx.CloudCredentials
+	if creds.Empty() {
+		return nil, nil
+	}
+	env := make(map[string]string)
+	if creds.AWS != nil {
+		if err := d.awsEnv(ctx, *creds.AWS, env); err != nil {
+			return nil, errors.Wrap(err, "getting AWS credentials")
+		}
+	}
+	if creds.GCP != nil {
+		if err := d.gcpEnv(ctx, *creds.GCP, env); err != nil {
+			return nil, errors.Wrap(err, "getting GCP credentials")
+		}
+	}
+	if creds.Azure != nil {
+		if err := d.azureEnv(ctx, *creds.Azure, env); err != nil {
+			return nil, errors.Wrap(err, "getting Azure credentials")
+		}
+	}
+	return env, nil
+}
+
+func (d *DefaultProvider) awsEnv(ctx models.ProjectCommandContext, cfg valid.AWSCloudCredentials, env map[string]string) error {
+	token, err := d.token(ctx, cfg.Audience, defaultAWSAudience)
+	if err != nil {
+		return err
+	}
+	input := &sts.AssumeRoleWithWebIdentityInput{
+		RoleArn:          aws.String(cfg.RoleARN),
+		RoleSessionName:  aws.String(SessionName(ctx)),
+		WebIdentityToken: aws.String(token),
+	}
+	if cfg.SessionDuration > 0 {
+		input.DurationSeconds = aws.Int64(int64(cfg.SessionDuration.Seconds()))
+	}
+	out, err := d.STS.AssumeRoleWithWebIdentity(input)
+	if err != nil {
+		return errors.Wrapf(err, "assuming role %s", cfg.RoleARN)
+	}
+	env["AWS_ACCESS_KEY_ID"] = aws.StringValue(out.Credentials.AccessKeyId)
+	env["AWS_SECRET_ACCESS_KEY"] = aws.StringValue(out.Credentials.SecretAccessKey)
+	env["AWS_SESSION_TOKEN"] = aws.StringValue(out.Credentials.SessionToken)
+	if cfg.Region != "" {
+		env["AWS_REGION"] = cfg.Region
+		env["AWS_DEFAULT_REGION"] = cfg.Region
+	}
+	return nil
+}
+
+func (d *DefaultProvider) gcpEnv(ctx models.ProjectCommandContext, cfg valid.GCPCloudCredentials, env map[string]string) error {
+	audience := "//iam.googleapis.com/" + cfg.WorkloadIdentityProvider
+	token, err := d.token(ctx, cfg.Audience, audience)
+	if err != nil {
+		return err
+	}
+	var stsResp struct {
+		AccessToken string `json:"access_token"`
+	}
+	err = d.postJSON(d.GCPSTSURL, "", map[string]string{
+		"audience":           audience,
+		"grantType":          "urn:ietf:params:oauth:grant-type:token-exchange",
+		"requestedTokenType": "urn:ietf:params:oauth:token-type:access_token",
+		"scope":              gcpScope,
+		"subjectTokenType":   "urn:ietf:params:oauth:token-type:jwt",
+		"subjectToken":       token,
+	}, &stsResp)
+	if err != nil {
+		return errors.Wrap(err, "exchanging token")
+	}
+	accessToken := stsResp.AccessToken
+
+	if cfg.ServiceAccount != "" {
+		var iamResp struct {
+			AccessToken string `json:"accessToken"`
+		}
+		url := fmt.Sprintf("%s/projects/-/serviceAccounts/%s:generateAccessToken", d.GCPIAMCredentialsURL, cfg.ServiceAccount)
+		err = d.postJSON(url, accessToken, map[string]interface{}{
+			"scope": []string{gcpScope},
+		}, &iamResp)
+		if err != nil {
+			return errors.Wrapf(err, "impersonating %s", cfg.ServiceAccount)
+		}
+		accessToken = iamResp.AccessToken
+	}
+	env["GOOGLE_OAUTH_ACCESS_TOKEN"] = accessToken
+	return nil
+}
+
+// azureEnv configures the azurerm provider to exchange the token itself
+// since Azure AD access tokens can't be passed to it directly.
+func (d *DefaultProvider) azureEnv(ctx models.ProjectCommandContext, cfg valid.AzureCloudCredentials, env map[string]string) error {
+	token, err := d.token(ctx, cfg.Audience, defaultAzureAudience)
+	if err != nil {
+		return err
+	}
+	env["ARM_USE_OIDC"] = "true"
+	env["ARM_OIDC_TOKEN"] = token
+	env["ARM_CLIENT_ID"] = cfg.ClientID
+	env["ARM_TENANT_ID"] = cfg.TenantID
+	if cfg.SubscriptionID != "" {
+		env["ARM_SUBSCRIPTION_ID"] = cfg.SubscriptionID
+	}
+	return nil
+}
+
+// token returns the token file's contents if configured, otherwise a token
+// issued for audience, or defaultAudience if audience is empty.
+func (d *DefaultProvider) token(ctx models.ProjectCommandContext, audience string, defaultAudience string) (string, error) {
+	if ctx.CloudCredentials.TokenFile != "" {
+		contents, err := ioutil.ReadFile(ctx.CloudCredentials.TokenFile) // nolint: gosec
+		if err != nil {
+			return "", errors.Wrap(err, "reading token file")
+		}
+		return strings.TrimSpace(string(contents)), nil
+	}
+	if d.Issuer == nil {
+		return "", errors.New("cloud_credentials requires either token_file or the --oidc-signing-key-file flag")
+	}
+	if audience == "" {
+		audience = defaultAudience
+	}
+	return d.Issuer.Token(Subject(ctx), audience, Claims(ctx))
+}
+
+func (d *DefaultProvider) postJSON(url string, bearer string, body interface{}, resp interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	r, err := d.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close() // nolint: errcheck
+	respBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if r.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d: %s", url, r.StatusCode, respBody)
+	}
+	return json.Unmarshal(respBody, resp)
+}
+
+// Subject returns the subject of tokens issued for the project described by
+// ctx, ex. "repo:owner/repo:project:name:workspace:default". Projects
+// without names use "dir:{dir}" instead of "project:{name}". Cloud trust
+// policies can match on it to restrict roles to projects.
+func Subject(ctx models.ProjectCommandContext) string {
+	project := "dir:" + ctx.RepoRelDir
+	if ctx.ProjectName != "" {
+		project = "project:" + ctx.ProjectName
+	}
+	return fmt.Sprintf("repo:%s:%s:workspace:%s", ctx.BaseRepo.FullName, project, ctx.Workspace)
+}
+
+// Claims returns the extra claims of tokens issued for the project
+// described by ctx.
+func Claims(ctx models.ProjectCommandContext) map[string]interface{} {
+	return map[string]interface{}{
+		"repository":  ctx.BaseRepo.FullName,
+		"vcs_host":    ctx.BaseRepo.VCSHost.Hostname,
+		"pull_number": ctx.Pull.Num,
+		"project":     ctx.ProjectName,
+		"dir":         ctx.RepoRelDir,
+		"workspace":   ctx.Workspace,
+		"command":     ctx.CommandName.String(),
+		"user":        ctx.User.Username,
+	}
+}
+
+var invalidSessionNameChars = regexp.MustCompile(`[^\w+=,.@-]`)
+
+// SessionName returns the AWS role session name for the project described
+// by ctx. Session names can be at most 64 characters.
+func SessionName(ctx models.ProjectCommandContext) string {
+	project := ctx.ProjectName
+	if project == "" {
+		project = ctx.RepoRelDir
+	}
+	name := invalidSessionNameChars.ReplaceAllString(fmt.Sprintf("atlantis-%d-%s-%s", ctx.Pull.Num, project, ctx.Workspace), "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
@@ -0,0 +1,157 @@
+package credentials_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/runatlantis/atlantis/server/events/credentials"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+type fakeSTS struct {
+	stsiface.STSAPI
+	input *sts.AssumeRoleWithWebIdentityInput
+}
+
+func (f *fakeSTS) AssumeRoleWithWebIdentity(input *sts.AssumeRoleWithWebIdentityInput) (*sts.AssumeRoleWithWebIdentityOutput, error) {
+	f.input = input
+	return &sts.AssumeRoleWithWebIdentityOutput{
+		Credentials: &sts.Credentials{
+			AccessKeyId:     aws.String("key-id"),
+			SecretAccessKey: aws.String("secret"),
+			SessionToken:    aws.String("session"),
+		},
+	}, nil
+}
+
+func testCtx(creds valid.CloudCredentials) models.ProjectCommandContext {
+	return models.ProjectCommandContext{
+		BaseRepo:         models.Repo{FullName: "owner/repo"},
+		Pull:             models.PullRequest{Num: 2},
+		ProjectName:      "my/project",
+		RepoRelDir:       "infra",
+		Workspace:        "default",
+		CommandName:      models.PlanCommand,
+		CloudCredentials: creds,
+	}
+}
+
+func TestDefaultProvider_Empty(t *testing.T) {
+	p := &credentials.DefaultProvider{}
+	env, err := p.Env(testCtx(valid.CloudCredentials{}))
+	Ok(t, err)
+	Assert(t, env == nil, "exp no env")
+}
+
+func TestDefaultProvider_RequiresTokenSource(t *testing.T) {
+	p := &credentials.DefaultProvider{STS: &fakeSTS{}}
+	_, err := p.Env(testCtx(valid.CloudCredentials{AWS: &valid.AWSCloudCredentials{RoleARN: "arn"}}))
+	ErrEquals(t, "getting AWS credentials: cloud_credentials requires either token_file or the --oidc-signing-key-file flag", err)
+}
+
+func TestDefaultProvider_AWS(t *testing.T) {
+	fake := &fakeSTS{}
+	p := &credentials.DefaultProvider{Issuer: newTestIssuer(t), STS: fake}
+	env, err := p.Env(testCtx(valid.CloudCredentials{AWS: &valid.AWSCloudCredentials{
+		RoleARN:         "arn:aws:iam::123456789012:role/atlantis",
+		Region:          "us-east-1",
+		SessionDuration: 30 * time.Minute,
+	}}))
+	Ok(t, err)
+	Equals(t, map[string]string{
+		"AWS_ACCESS_KEY_ID":     "key-id",
+		"AWS_SECRET_ACCESS_KEY": "secret",
+		"AWS_SESSION_TOKEN":     "session",
+		"AWS_REGION":            "us-east-1",
+		"AWS_DEFAULT_REGION":    "us-east-1",
+	}, env)
+	Equals(t, "arn:aws:iam::123456789012:role/atlantis", *fake.input.RoleArn)
+	Equals(t, "atlantis-2-my_project-default", *fake.input.RoleSessionName)
+	Equals(t, int64(1800), *fake.input.DurationSeconds)
+	Assert(t, *fake.input.WebIdentityToken != "", "exp token")
+}
+
+func TestDefaultProvider_TokenFile(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	tokenFile := filepath.Join(tmp, "token")
+	Ok(t, ioutil.WriteFile(tokenFile, []byte("ambient-token\n"), 0600))
+
+	p := &credentials.DefaultProvider{}
+	env, err := p.Env(testCtx(valid.CloudCredentials{
+		TokenFile: tokenFile,
+		Azure: &valid.AzureCloudCredentials{
+			ClientID:       "client",
+			TenantID:       "tenant",
+			SubscriptionID: "sub",
+		},
+	}))
+	Ok(t, err)
+	Equals(t, map[string]string{
+		"ARM_USE_OIDC":        "true",
+		"ARM_OIDC_TOKEN":      "ambient-token",
+		"ARM_CLIENT_ID":       "client",
+		"ARM_TENANT_ID":       "tenant",
+		"ARM_SUBSCRIPTION_ID": "sub",
+	}, env)
+}
+
+func TestDefaultProvider_GCP(t *testing.T) {
+	var stsReq map[string]string
+	var iamAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sts":
+			Ok(t, json.NewDecoder(r.Body).Decode(&stsReq))
+			w.Write([]byte(`{"access_token": "federated"}`)) // nolint: errcheck
+		case "/iam/projects/-/serviceAccounts/tf@proj.iam.gserviceaccount.com:generateAccessToken":
+			iamAuth = r.Header.Get("Authorization")
+			w.Write([]byte(`{"accessToken": "impersonated"}`)) // nolint: errcheck
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	p := &credentials.DefaultProvider{
+		Issuer:               newTestIssuer(t),
+		HTTPClient:           server.Client(),
+		GCPSTSURL:            server.URL + "/sts",
+		GCPIAMCredentialsURL: server.URL + "/iam",
+	}
+	creds := &valid.GCPCloudCredentials{
+		WorkloadIdentityProvider: "projects/123/locations/global/workloadIdentityPools/pool/providers/atlantis",
+	}
+	env, err := p.Env(testCtx(valid.CloudCredentials{GCP: creds}))
+	Ok(t, err)
+	Equals(t, map[string]string{"GOOGLE_OAUTH_ACCESS_TOKEN": "federated"}, env)
+	Equals(t, "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/atlantis", stsReq["audience"])
+	Equals(t, "urn:ietf:params:oauth:token-type:jwt", stsReq["subjectTokenType"])
+
+	creds.ServiceAccount = "tf@proj.iam.gserviceaccount.com"
+	env, err = p.Env(testCtx(valid.CloudCredentials{GCP: creds}))
+	Ok(t, err)
+	Equals(t, map[string]string{"GOOGLE_OAUTH_ACCESS_TOKEN": "impersonated"}, env)
+	Equals(t, "Bearer federated", iamAuth)
+
+	p.GCPSTSURL = server.URL + "/missing"
+	_, err = p.Env(testCtx(valid.CloudCredentials{GCP: creds}))
+	ErrContains(t, "getting GCP credentials: exchanging token: "+server.URL+"/missing returned 404", err)
+}
+
+func TestSubject(t *testing.T) {
+	ctx := testCtx(valid.CloudCredentials{})
+	Equals(t, "repo:owner/repo:project:my/project:workspace:default", credentials.Subject(ctx))
+	ctx.ProjectName = ""
+	Equals(t, "repo:owner/repo:dir:infra:workspace:default", credentials.Subject(ctx))
+}
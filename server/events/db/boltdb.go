@@ -7,7 +7,7 @@ import (
 	"fmt"
 	"os"
 	"path"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -17,6 +17,9 @@ import (
 
 // BoltDB is a database using BoltDB
 type BoltDB struct {
+	// mutex is held for writing while the file is swapped by Compact and
+	// for reading by every transaction.
+	mutex                 sync.RWMutex
 	db                    *bolt.DB
 	locksBucketName       []byte
 	pullsBucketName       []byte
@@ -36,12 +39,9 @@ func New(dataDir string) (*BoltDB, error) {
 	if err := os.MkdirAll(dataDir, 0700); err != nil {
 		return nil, errors.Wrap(err, "creating data dir")
 	}
-	db, err := bolt.Open(path.Join(dataDir, "atlantis.db"), 0600, &bolt.Options{Timeout: 1 * time.Second})
+	db, err := openBoltDB(path.Join(dataDir, "atlantis.db"))
 	if err != nil {
-		if err.Error() == "timeout" {
-			return nil, errors.New("starting BoltDB: timeout (a possible cause is another Atlantis instance already running)")
-		}
-		return nil, errors.Wrap(err, "starting BoltDB")
+		return nil, err
 	}
 
 	// Create the buckets.
@@ -79,6 +79,38 @@ func NewWithDB(db *bolt.DB, bucket string, globalBucket string) (*BoltDB, error)
 	}, nil
 }
 
+func openBoltDB(path string) (*bolt.DB, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		if err.Error() == "timeout" {
+			return nil, errors.New("starting BoltDB: timeout (a possible cause is another Atlantis instance already running)")
+		}
+		return nil, errors.Wrap(err, "starting BoltDB")
+	}
+	return db, nil
+}
+
+// Close closes the database file so another process can open it.
+func (b *BoltDB) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.db.Close()
+}
+
+// update runs fn in a read-write transaction.
+func (b *BoltDB) update(fn func(*bolt.Tx) error) error {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.db.Update(fn)
+}
+
+// view runs fn in a read-only transaction.
+func (b *BoltDB) view(fn func(*bolt.Tx) error) error {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.db.View(fn)
+}
+
 // TryLock attempts to create a new lock. If the lock is
 // acquired, it will return true and the lock returned will be newLock.
 // If the lock is not acquired, it will return false and the current
@@ -86,9 +118,9 @@ func NewWithDB(db *bolt.DB, bucket string, globalBucket string) (*BoltDB, error)
 func (b *BoltDB) TryLock(newLock models.ProjectLock) (bool, models.ProjectLock, error) {
 	var lockAcquired bool
 	var currLock models.ProjectLock
-	key := b.lockKey(newLock.Project, newLock.Workspace)
+	key := lockKey(newLock.Project, newLock.Workspace)
 	newLockSerialized, _ := json.Marshal(newLock)
-	transactionErr := b.db.Update(func(tx *bolt.Tx) error {
+	transactionErr := b.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(b.locksBucketName)
 
 		// if there is no run at that key then we're free to create the lock
@@ -123,8 +155,8 @@ func (b *BoltDB) TryLock(newLock models.ProjectLock) (bool, models.ProjectLock,
 func (b *BoltDB) Unlock(p models.Project, workspace string) (*models.ProjectLock, error) {
 	var lock models.ProjectLock
 	foundLock := false
-	key := b.lockKey(p, workspace)
-	err := b.db.Update(func(tx *bolt.Tx) error {
+	key := lockKey(p, workspace)
+	err := b.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(b.locksBucketName)
 		serialized := bucket.Get([]byte(key))
 		if serialized != nil {
@@ -146,7 +178,7 @@ func (b *BoltDB) Unlock(p models.Project, workspace string) (*models.ProjectLock
 func (b *BoltDB) List() ([]models.ProjectLock, error) {
 	var locks []models.ProjectLock
 	var locksBytes [][]byte
-	err := b.db.View(func(tx *bolt.Tx) error {
+	err := b.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(b.locksBucketName)
 		c := bucket.Cursor()
 		for k, v := c.First(); k != nil; k, v = c.Next() {
@@ -182,16 +214,16 @@ func (b *BoltDB) LockCommand(cmdName models.CommandName, lockTime time.Time) (*m
 	}
 
 	newLockSerialized, _ := json.Marshal(lock)
-	transactionErr := b.db.Update(func(tx *bolt.Tx) error {
+	transactionErr := b.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(b.globalLocksBucketName)
 
-		currLockSerialized := bucket.Get([]byte(b.commandLockKey(cmdName)))
+		currLockSerialized := bucket.Get([]byte(commandLockKey(cmdName)))
 		if currLockSerialized != nil {
 			return errors.New("lock already exists")
 		}
 
 		// This will only error on readonly buckets, it's okay to ignore.
-		bucket.Put([]byte(b.commandLockKey(cmdName)), newLockSerialized) // nolint: errcheck
+		bucket.Put([]byte(commandLockKey(cmdName)), newLockSerialized) // nolint: errcheck
 		return nil
 	})
 
@@ -205,14 +237,14 @@ func (b *BoltDB) LockCommand(cmdName models.CommandName, lockTime time.Time) (*m
 // UnlockCommand removes CommandName lock if present.
 // If there are no lock it returns an error.
 func (b *BoltDB) UnlockCommand(cmdName models.CommandName) error {
-	transactionErr := b.db.Update(func(tx *bolt.Tx) error {
+	transactionErr := b.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(b.globalLocksBucketName)
 
-		if l := bucket.Get([]byte(b.commandLockKey(cmdName))); l == nil {
+		if l := bucket.Get([]byte(commandLockKey(cmdName))); l == nil {
 			return errors.New("no lock exists")
 		}
 
-		return bucket.Delete([]byte(b.commandLockKey(cmdName)))
+		return bucket.Delete([]byte(commandLockKey(cmdName)))
 	})
 
 	if transactionErr != nil {
@@ -229,10 +261,10 @@ func (b *BoltDB) CheckCommandLock(cmdName models.CommandName) (*models.CommandLo
 
 	found := false
 
-	err := b.db.View(func(tx *bolt.Tx) error {
+	err := b.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(b.globalLocksBucketName)
 
-		serializedLock := bucket.Get([]byte(b.commandLockKey(cmdName)))
+		serializedLock := bucket.Get([]byte(commandLockKey(cmdName)))
 
 		if serializedLock != nil {
 			if err := json.Unmarshal(serializedLock, &cmdLock); err != nil {
@@ -254,7 +286,7 @@ func (b *BoltDB) CheckCommandLock(cmdName models.CommandName) (*models.CommandLo
 // UnlockByPull deletes all locks associated with that pull request and returns them.
 func (b *BoltDB) UnlockByPull(repoFullName string, pullNum int) ([]models.ProjectLock, error) {
 	var locks []models.ProjectLock
-	err := b.db.View(func(tx *bolt.Tx) error {
+	err := b.view(func(tx *bolt.Tx) error {
 		c := tx.Bucket(b.locksBucketName).Cursor()
 
 		// we can use the repoFullName as a prefix search since that's the first part of the key
@@ -285,9 +317,9 @@ func (b *BoltDB) UnlockByPull(repoFullName string, pullNum int) ([]models.Projec
 // GetLock returns a pointer to the lock for that project and workspace.
 // If there is no lock, it returns a nil pointer.
 func (b *BoltDB) GetLock(p models.Project, workspace string) (*models.ProjectLock, error) {
-	key := b.lockKey(p, workspace)
+	key := lockKey(p, workspace)
 	var lockBytes []byte
-	err := b.db.View(func(tx *bolt.Tx) error {
+	err := b.view(func(tx *bolt.Tx) error {
 		b := tx.Bucket(b.locksBucketName)
 		lockBytes = b.Get([]byte(key))
 		return nil
@@ -313,61 +345,21 @@ func (b *BoltDB) GetLock(p models.Project, workspace string) (*models.ProjectLoc
 // UpdatePullWithResults updates pull's status with the latest project results.
 // It returns the new PullStatus object.
 func (b *BoltDB) UpdatePullWithResults(pull models.PullRequest, newResults []models.ProjectResult) (models.PullStatus, error) {
-	key, err := b.pullKey(pull)
+	k, err := pullKey(pull)
 	if err != nil {
 		return models.PullStatus{}, err
 	}
+	key := []byte(k)
 
 	var newStatus models.PullStatus
-	err = b.db.Update(func(tx *bolt.Tx) error {
+	err = b.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(b.pullsBucketName)
 		currStatus, err := b.getPullFromBucket(bucket, key)
 		if err != nil {
 			return err
 		}
 
-		// If there is no pull OR if the pull we have is out of date, we
-		// just write a new pull.
-		if currStatus == nil || currStatus.Pull.HeadCommit != pull.HeadCommit {
-			var statuses []models.ProjectStatus
-			for _, r := range newResults {
-				statuses = append(statuses, b.projectResultToProject(r))
-			}
-			newStatus = models.PullStatus{
-				Pull:     pull,
-				Projects: statuses,
-			}
-		} else {
-			// If there's an existing pull at the right commit then we have to
-			// merge our project results with the existing ones. We do a merge
-			// because it's possible a user is just applying a single project
-			// in this command and so we don't want to delete our data about
-			// other projects that aren't affected by this command.
-			newStatus = *currStatus
-			for _, res := range newResults {
-				// First, check if we should update any existing projects.
-				updatedExisting := false
-				for i := range newStatus.Projects {
-					// NOTE: We're using a reference here because we are
-					// in-place updating its Status field.
-					proj := &newStatus.Projects[i]
-					if res.Workspace == proj.Workspace &&
-						res.RepoRelDir == proj.RepoRelDir &&
-						res.ProjectName == proj.ProjectName {
-
-						proj.Status = res.PlanStatus()
-						updatedExisting = true
-						break
-					}
-				}
-
-				if !updatedExisting {
-					// If we didn't update an existing project, then we need to
-					// add this because it's a new one.
-					newStatus.Projects = append(newStatus.Projects, b.projectResultToProject(res))
-				}
-			}
-		}
+		newStatus = mergePullStatus(currStatus, pull, newResults)
 
 		// Now, we overwrite the key with our new status.
 		return b.writePullToBucket(bucket, key, newStatus)
@@ -378,12 +370,13 @@ func (b *BoltDB) UpdatePullWithResults(pull models.PullRequest, newResults []mod
 // GetPullStatus returns the status for pull.
 // If there is no status, returns a nil pointer.
 func (b *BoltDB) GetPullStatus(pull models.PullRequest) (*models.PullStatus, error) {
-	key, err := b.pullKey(pull)
+	k, err := pullKey(pull)
 	if err != nil {
 		return nil, err
 	}
+	key := []byte(k)
 	var s *models.PullStatus
-	err = b.db.View(func(tx *bolt.Tx) error {
+	err = b.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(b.pullsBucketName)
 		var txErr error
 		s, txErr = b.getPullFromBucket(bucket, key)
@@ -392,13 +385,33 @@ func (b *BoltDB) GetPullStatus(pull models.PullRequest) (*models.PullStatus, err
 	return s, errors.Wrap(err, "DB transaction failed")
 }
 
+// GetPullStatuses returns the statuses of all the pull requests we know
+// about. Statuses are deleted when pull requests are closed so these are all
+// open.
+func (b *BoltDB) GetPullStatuses() ([]models.PullStatus, error) {
+	var statuses []models.PullStatus
+	err := b.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.pullsBucketName)
+		return bucket.ForEach(func(k, v []byte) error {
+			var s models.PullStatus
+			if err := json.Unmarshal(v, &s); err != nil {
+				return errors.Wrapf(err, "deserializing pull at %q with contents %q", k, v)
+			}
+			statuses = append(statuses, s)
+			return nil
+		})
+	})
+	return statuses, errors.Wrap(err, "DB transaction failed")
+}
+
 // DeletePullStatus deletes the status for pull.
 func (b *BoltDB) DeletePullStatus(pull models.PullRequest) error {
-	key, err := b.pullKey(pull)
+	k, err := pullKey(pull)
 	if err != nil {
 		return err
 	}
-	err = b.db.Update(func(tx *bolt.Tx) error {
+	key := []byte(k)
+	err = b.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(b.pullsBucketName)
 		return bucket.Delete(key)
 	})
@@ -407,11 +420,12 @@ func (b *BoltDB) DeletePullStatus(pull models.PullRequest) error {
 
 // UpdateProjectStatus updates project status.
 func (b *BoltDB) UpdateProjectStatus(pull models.PullRequest, workspace string, repoRelDir string, newStatus models.ProjectPlanStatus) error {
-	key, err := b.pullKey(pull)
+	k, err := pullKey(pull)
 	if err != nil {
 		return err
 	}
-	err = b.db.Update(func(tx *bolt.Tx) error {
+	key := []byte(k)
+	err = b.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(b.pullsBucketName)
 		currStatusPtr, err := b.getPullFromBucket(bucket, key)
 		if err != nil {
@@ -422,41 +436,23 @@ func (b *BoltDB) UpdateProjectStatus(pull models.PullRequest, workspace string,
 		}
 		currStatus := *currStatusPtr
 
-		// Update the status.
-		for i := range currStatus.Projects {
-			// NOTE: We're using a reference here because we are
-			// in-place updating its Status field.
-			proj := &currStatus.Projects[i]
-			if proj.Workspace == workspace && proj.RepoRelDir == repoRelDir {
-				proj.Status = newStatus
-				break
-			}
-		}
+		setProjectStatus(&currStatus, workspace, repoRelDir, newStatus)
 		return b.writePullToBucket(bucket, key, currStatus)
 	})
 	return errors.Wrap(err, "DB transaction failed")
 }
 
-func (b *BoltDB) pullKey(pull models.PullRequest) ([]byte, error) {
-	hostname := pull.BaseRepo.VCSHost.Hostname
-	if strings.Contains(hostname, pullKeySeparator) {
-		return nil, fmt.Errorf("vcs hostname %q contains illegal string %q", hostname, pullKeySeparator)
-	}
-	repo := pull.BaseRepo.FullName
-	if strings.Contains(repo, pullKeySeparator) {
-		return nil, fmt.Errorf("repo name %q contains illegal string %q", hostname, pullKeySeparator)
+// putPullStatus replaces the status of status.Pull, ex. to migrate it from
+// another database.
+func (b *BoltDB) putPullStatus(status models.PullStatus) error {
+	key, err := pullKey(status.Pull)
+	if err != nil {
+		return err
 	}
-
-	return []byte(fmt.Sprintf("%s::%s::%d", hostname, repo, pull.Num)),
-		nil
-}
-
-func (b *BoltDB) commandLockKey(cmdName models.CommandName) string {
-	return fmt.Sprintf("%s/lock", cmdName)
-}
-
-func (b *BoltDB) lockKey(p models.Project, workspace string) string {
-	return fmt.Sprintf("%s/%s/%s", p.RepoFullName, p.Path, workspace)
+	err = b.update(func(tx *bolt.Tx) error {
+		return b.writePullToBucket(tx.Bucket(b.pullsBucketName), []byte(key), status)
+	})
+	return errors.Wrap(err, "DB transaction failed")
 }
 
 func (b *BoltDB) getPullFromBucket(bucket *bolt.Bucket, key []byte) (*models.PullStatus, error) {
@@ -479,12 +475,3 @@ func (b *BoltDB) writePullToBucket(bucket *bolt.Bucket, key []byte, pull models.
 	}
 	return bucket.Put(key, serialized)
 }
-
-func (b *BoltDB) projectResultToProject(p models.ProjectResult) models.ProjectStatus {
-	return models.ProjectStatus{
-		Workspace:   p.Workspace,
-		RepoRelDir:  p.RepoRelDir,
-		ProjectName: p.ProjectName,
-		Status:      p.PlanStatus(),
-	}
-}
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltCompactTxMaxSize is how many bytes Compact copies per transaction.
+const boltCompactTxMaxSize = 64 * 1024
+
+// BoltDBStats describes the size of the BoltDB file.
+type BoltDBStats struct {
+	// FileSize is the size of the file in bytes.
+	FileSize int64
+	// FreeSize is the size of the file's free pages in bytes. BoltDB reuses
+	// them but never shrinks the file, so they're only reclaimed by Compact.
+	FreeSize int64
+	// Keys is the number of keys in each bucket.
+	Keys map[string]int
+}
+
+// Stats returns the size of the file and the number of keys in each bucket.
+func (b *BoltDB) Stats() (BoltDBStats, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	info, err := os.Stat(b.db.Path())
+	if err != nil {
+		return BoltDBStats{}, errors.Wrap(err, "getting size of BoltDB file")
+	}
+	stats := BoltDBStats{
+		FileSize: info.Size(),
+		Keys:     make(map[string]int),
+	}
+	dbStats := b.db.Stats()
+	stats.FreeSize = int64(dbStats.FreePageN+dbStats.PendingPageN) * int64(b.db.Info().PageSize)
+	err = b.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+			stats.Keys[string(name)] = bucket.Stats().KeyN
+			return nil
+		})
+	})
+	return stats, errors.Wrap(err, "DB transaction failed")
+}
+
+// Check verifies the consistency of the file's pages and that each lock
+// and pull status can be deserialized. It returns the problems it found.
+func (b *BoltDB) Check() ([]string, error) {
+	var problems []string
+	err := b.view(func(tx *bolt.Tx) error {
+		for err := range tx.Check() {
+			problems = append(problems, err.Error())
+		}
+		buckets := []struct {
+			name  []byte
+			value func() interface{}
+		}{
+			{b.locksBucketName, func() interface{} { return new(models.ProjectLock) }},
+			{b.pullsBucketName, func() interface{} { return new(models.PullStatus) }},
+			{b.globalLocksBucketName, func() interface{} { return new(models.CommandLock) }},
+		}
+		for _, bucket := range buckets {
+			bkt := tx.Bucket(bucket.name)
+			if bkt == nil {
+				problems = append(problems, fmt.Sprintf("bucket %q is missing", bucket.name))
+				continue
+			}
+			bkt.ForEach(func(k, v []byte) error { // nolint: errcheck
+				if err := json.Unmarshal(v, bucket.value()); err != nil {
+					problems = append(problems, fmt.Sprintf("deserializing %q in bucket %q: %s", k, bucket.name, err))
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	return problems, errors.Wrap(err, "DB transaction failed")
+}
+
+// Compact rewrites the file without its free pages and returns its size
+// before and after. Other operations wait until it's done.
+func (b *BoltDB) Compact() (int64, int64, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	path := b.db.Path()
+	before, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "getting size of BoltDB file")
+	}
+	tmpPath := path + ".compact"
+	os.Remove(tmpPath) // nolint: errcheck
+	dst, err := bolt.Open(tmpPath, 0600, nil)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "creating compacted BoltDB file")
+	}
+	err = bolt.Compact(dst, b.db, boltCompactTxMaxSize)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath) // nolint: errcheck
+		return 0, 0, errors.Wrap(err, "compacting BoltDB")
+	}
+
+	if err := b.db.Close(); err != nil {
+		os.Remove(tmpPath) // nolint: errcheck
+		return 0, 0, errors.Wrap(err, "closing BoltDB")
+	}
+	renameErr := os.Rename(tmpPath, path)
+	// The original file is reopened if it couldn't be replaced.
+	db, err := openBoltDB(path)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "reopening BoltDB after compacting it")
+	}
+	b.db = db
+	if renameErr != nil {
+		os.Remove(tmpPath) // nolint: errcheck
+		return 0, 0, errors.Wrap(renameErr, "replacing BoltDB file with compacted file")
+	}
+	after, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "getting size of BoltDB file")
+	}
+	return before.Size(), after.Size(), nil
+}
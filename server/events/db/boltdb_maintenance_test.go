@@ -0,0 +1,64 @@
+package db_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	. "github.com/runatlantis/atlantis/testing"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBoltDB_Compact(t *testing.T) {
+	b, cleanup := newTestDB2(t)
+	defer cleanup()
+	_, _, err := b.TryLock(lock)
+	Ok(t, err)
+	// Deleted pull statuses leave free pages behind.
+	var pulls []models.PullRequest
+	for i := 1; i <= 100; i++ {
+		pull := models.PullRequest{Num: i, BaseRepo: models.Repo{FullName: "owner/repo"}}
+		_, err := b.UpdatePullWithResults(pull, []models.ProjectResult{
+			{RepoRelDir: strings.Repeat("dir/", 1000), Workspace: "default", PlanSuccess: &models.PlanSuccess{}},
+		})
+		Ok(t, err)
+		pulls = append(pulls, pull)
+	}
+	for _, pull := range pulls {
+		Ok(t, b.DeletePullStatus(pull))
+	}
+	stats, err := b.Stats()
+	Ok(t, err)
+	Assert(t, stats.FreeSize > 0, "exp free pages")
+	Equals(t, map[string]int{"runLocks": 1, "pulls": 0, "globalLocks": 0}, stats.Keys)
+
+	before, after, err := b.Compact()
+	Ok(t, err)
+	Equals(t, stats.FileSize, before)
+	Assert(t, after < before, "exp compacted file to be smaller, got %d bytes before and %d after", before, after)
+	stats, err = b.Stats()
+	Ok(t, err)
+	Equals(t, after, stats.FileSize)
+	locks, err := b.List()
+	Ok(t, err)
+	Equals(t, 1, len(locks))
+	problems, err := b.Check()
+	Ok(t, err)
+	Equals(t, 0, len(problems))
+}
+
+func TestBoltDB_Check(t *testing.T) {
+	boltDB, b := newTestDB()
+	defer cleanupDB(boltDB)
+	Ok(t, boltDB.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(lockBucket)).Put([]byte("owner/repo/./default"), []byte("{"))
+	}))
+
+	problems, err := b.Check()
+	Ok(t, err)
+	Equals(t, []string{
+		fmt.Sprintf(`deserializing "owner/repo/./default" in bucket %q: unexpected end of JSON input`, lockBucket),
+		`bucket "pulls" is missing`,
+	}, problems)
+}
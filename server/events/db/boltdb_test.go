@@ -16,6 +16,7 @@ package db_test
 import (
 	"io/ioutil"
 	"os"
+	"sort"
 	"testing"
 	"time"
 
@@ -766,7 +767,138 @@ func TestPullStatus_UpdateMerge(t *testing.T) {
 	}
 }
 
+// Test that the plan counts of projects are kept when their policies are
+// checked since the checks are of the same plan.
+func TestPullStatus_UpdatePlanCounts(t *testing.T) {
+	b, cleanup := newTestDB2(t)
+	defer cleanup()
+
+	pull := models.PullRequest{
+		Num:        1,
+		HeadCommit: "sha",
+		BaseRepo: models.Repo{
+			FullName: "runatlantis/atlantis",
+		},
+	}
+	_, err := b.UpdatePullWithResults(
+		pull,
+		[]models.ProjectResult{
+			{
+				Command:    models.PlanCommand,
+				RepoRelDir: ".",
+				Workspace:  "default",
+				PlanSuccess: &models.PlanSuccess{
+					TerraformOutput: "Plan: 1 to add, 0 to change, 2 to destroy.",
+				},
+			},
+		})
+	Ok(t, err)
+
+	status, err := b.UpdatePullWithResults(
+		pull,
+		[]models.ProjectResult{
+			{
+				Command:            models.PolicyCheckCommand,
+				RepoRelDir:         ".",
+				Workspace:          "default",
+				PolicyCheckSuccess: &models.PolicyCheckSuccess{},
+			},
+		})
+	Ok(t, err)
+	Equals(t, &models.PlanCounts{Add: 1, Destroy: 2}, status.Projects[0].PlanCounts)
+
+	status, err = b.UpdatePullWithResults(
+		pull,
+		[]models.ProjectResult{
+			{
+				Command:      models.ApplyCommand,
+				RepoRelDir:   ".",
+				Workspace:    "default",
+				ApplySuccess: "success!",
+			},
+		})
+	Ok(t, err)
+	Equals(t, (*models.PlanCounts)(nil), status.Projects[0].PlanCounts)
+}
+
 // newTestDB returns a TestDB using a temporary path.
+// Test that the command details are recorded and that all pulls are listed.
+func TestPullStatus_GetPullStatuses(t *testing.T) {
+	b, cleanup := newTestDB2(t)
+	defer cleanup()
+
+	statuses, err := b.GetPullStatuses()
+	Ok(t, err)
+	Equals(t, 0, len(statuses))
+
+	repo := models.Repo{
+		FullName: "runatlantis/atlantis",
+		VCSHost: models.VCSHost{
+			Hostname: "github.com",
+			Type:     models.Github,
+		},
+	}
+	start := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, num := range []int{1, 2} {
+		_, err = b.UpdatePullWithResults(
+			models.PullRequest{Num: num, HeadCommit: "sha", BaseRepo: repo},
+			[]models.ProjectResult{
+				{
+					Command:     models.PlanCommand,
+					RepoRelDir:  ".",
+					Workspace:   "default",
+					PlanSuccess: &models.PlanSuccess{},
+					User:        "lkysow",
+					StartedAt:   start,
+					Duration:    time.Minute,
+					OutputURL:   "output-url",
+				},
+			})
+		Ok(t, err)
+	}
+	// Updating an existing project replaces its details.
+	_, err = b.UpdatePullWithResults(
+		models.PullRequest{Num: 2, HeadCommit: "sha", BaseRepo: repo},
+		[]models.ProjectResult{
+			{
+				Command:      models.ApplyCommand,
+				RepoRelDir:   ".",
+				Workspace:    "default",
+				ApplySuccess: "success",
+				User:         "acme",
+				StartedAt:    start.Add(time.Hour),
+				Duration:     time.Second,
+			},
+		})
+	Ok(t, err)
+
+	statuses, err = b.GetPullStatuses()
+	Ok(t, err)
+	Equals(t, 2, len(statuses))
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Pull.Num < statuses[j].Pull.Num })
+	Equals(t, []models.ProjectStatus{
+		{
+			Workspace:  "default",
+			RepoRelDir: ".",
+			Status:     models.PlannedPlanStatus,
+			User:       "lkysow",
+			StartedAt:  start,
+			Duration:   time.Minute,
+			OutputURL:  "output-url",
+		},
+	}, statuses[0].Projects)
+	Equals(t, []models.ProjectStatus{
+		{
+			Workspace:  "default",
+			RepoRelDir: ".",
+			Status:     models.AppliedPlanStatus,
+			User:       "acme",
+			StartedAt:  start.Add(time.Hour),
+			Duration:   time.Second,
+		},
+	}, statuses[1].Projects)
+}
+
 func newTestDB() (*bolt.DB, *db.BoltDB) {
 	// Retrieve a temporary path.
 	f, err := ioutil.TempFile("", "")
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+const (
+	// BoltDBType stores the data in BoltDB.
+	BoltDBType = "boltdb"
+	// RedisType stores the data in Redis.
+	RedisType = "redis"
+	// DynamoDBType stores the data in DynamoDB.
+	DynamoDBType = "dynamodb"
+	// PostgresType stores the data in PostgreSQL.
+	PostgresType = "postgres"
+
+	// DefaultKeyPrefix is prepended to the keys Atlantis stores in Redis and
+	// DynamoDB by default.
+	DefaultKeyPrefix = "atlantis:"
+)
+
+// Database stores the locks and the statuses of pull requests. It's
+// implemented by BoltDB, which stores them in a file in the data dir, and by
+// Redis, DynamoDB and Postgres, which let multiple Atlantis servers share
+// them.
+type Database interface {
+	TryLock(lock models.ProjectLock) (bool, models.ProjectLock, error)
+	Unlock(p models.Project, workspace string) (*models.ProjectLock, error)
+	List() ([]models.ProjectLock, error)
+	GetLock(p models.Project, workspace string) (*models.ProjectLock, error)
+	UnlockByPull(repoFullName string, pullNum int) ([]models.ProjectLock, error)
+
+	LockCommand(cmdName models.CommandName, lockTime time.Time) (*models.CommandLock, error)
+	UnlockCommand(cmdName models.CommandName) error
+	CheckCommandLock(cmdName models.CommandName) (*models.CommandLock, error)
+
+	UpdatePullWithResults(pull models.PullRequest, newResults []models.ProjectResult) (models.PullStatus, error)
+	GetPullStatus(pull models.PullRequest) (*models.PullStatus, error)
+	GetPullStatuses() ([]models.PullStatus, error)
+	DeletePullStatus(pull models.PullRequest) error
+	UpdateProjectStatus(pull models.PullRequest, workspace string, repoRelDir string, newStatus models.ProjectPlanStatus) error
+}
+
+func pullKey(pull models.PullRequest) (string, error) {
+	hostname := pull.BaseRepo.VCSHost.Hostname
+	if strings.Contains(hostname, pullKeySeparator) {
+		return "", fmt.Errorf("vcs hostname %q contains illegal string %q", hostname, pullKeySeparator)
+	}
+	repo := pull.BaseRepo.FullName
+	if strings.Contains(repo, pullKeySeparator) {
+		return "", fmt.Errorf("repo name %q contains illegal string %q", hostname, pullKeySeparator)
+	}
+
+	return fmt.Sprintf("%s::%s::%d", hostname, repo, pull.Num), nil
+}
+
+func commandLockKey(cmdName models.CommandName) string {
+	return fmt.Sprintf("%s/lock", cmdName)
+}
+
+func lockKey(p models.Project, workspace string) string {
+	return fmt.Sprintf("%s/%s/%s", p.RepoFullName, p.Path, workspace)
+}
+
+// mergePullStatus returns the status of pull once newResults are recorded.
+// currStatus is its current status, which is nil if there's none.
+func mergePullStatus(currStatus *models.PullStatus, pull models.PullRequest, newResults []models.ProjectResult) models.PullStatus {
+	// If there is no pull OR if the pull we have is out of date, we
+	// just write a new pull.
+	if currStatus == nil || currStatus.Pull.HeadCommit != pull.HeadCommit {
+		var statuses []models.ProjectStatus
+		for _, r := range newResults {
+			statuses = append(statuses, projectResultToProject(r))
+		}
+		return models.PullStatus{
+			Pull:     pull,
+			Projects: statuses,
+		}
+	}
+
+	// If there's an existing pull at the right commit then we have to
+	// merge our project results with the existing ones. We do a merge
+	// because it's possible a user is just applying a single project
+	// in this command and so we don't want to delete our data about
+	// other projects that aren't affected by this command.
+	newStatus := *currStatus
+	for _, res := range newResults {
+		// First, check if we should update any existing projects.
+		updatedExisting := false
+		for i := range newStatus.Projects {
+			// NOTE: We're using a reference here because we are
+			// in-place updating its Status field.
+			proj := &newStatus.Projects[i]
+			if res.Workspace == proj.Workspace &&
+				res.RepoRelDir == proj.RepoRelDir &&
+				res.ProjectName == proj.ProjectName {
+
+				planCounts := proj.PlanCounts
+				*proj = projectResultToProject(res)
+				// Policy checks are of the last plan so its counts still
+				// apply.
+				if res.Command == models.PolicyCheckCommand || res.Command == models.ApprovePoliciesCommand {
+					proj.PlanCounts = planCounts
+				}
+				updatedExisting = true
+				break
+			}
+		}
+
+		if !updatedExisting {
+			// If we didn't update an existing project, then we need to
+			// add this because it's a new one.
+			newStatus.Projects = append(newStatus.Projects, projectResultToProject(res))
+		}
+	}
+	return newStatus
+}
+
+// setProjectStatus sets the status of the project in workspace and
+// repoRelDir.
+func setProjectStatus(status *models.PullStatus, workspace string, repoRelDir string, newStatus models.ProjectPlanStatus) {
+	for i := range status.Projects {
+		// NOTE: We're using a reference here because we are
+		// in-place updating its Status field.
+		proj := &status.Projects[i]
+		if proj.Workspace == workspace && proj.RepoRelDir == repoRelDir {
+			proj.Status = newStatus
+			return
+		}
+	}
+}
+
+func projectResultToProject(p models.ProjectResult) models.ProjectStatus {
+	status := models.ProjectStatus{
+		Workspace:   p.Workspace,
+		RepoRelDir:  p.RepoRelDir,
+		ProjectName: p.ProjectName,
+		Status:      p.PlanStatus(),
+		User:        p.User,
+		StartedAt:   p.StartedAt,
+		Duration:    p.Duration,
+		OutputURL:   p.OutputURL,
+	}
+	if p.PlanSuccess != nil {
+		status.PlanCounts = p.PlanSuccess.Counts()
+	}
+	return status
+}
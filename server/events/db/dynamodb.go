@@ -0,0 +1,531 @@
+package db
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+const (
+	// DynamoDBKeyAttribute is the table's partition key. It's a string.
+	DynamoDBKeyAttribute = "id"
+	// DynamoDBTTLAttribute is the attribute to enable the table's TTL on. It
+	// holds when pull request statuses expire, in seconds since the epoch.
+	DynamoDBTTLAttribute = "expires_at"
+
+	dynamoDBValueAttribute   = "value"
+	dynamoDBVersionAttribute = "version"
+
+	dynamoDBLocksPrefix       = "lock:"
+	dynamoDBGlobalLocksPrefix = "command-lock:"
+	dynamoDBPullsPrefix       = "pull:"
+	// dynamoDBMaxRetries is how many times a conditional write is retried
+	// when the item it's updating is modified by another Atlantis server.
+	dynamoDBMaxRetries = 10
+)
+
+// DynamoDBConfig configures the DynamoDB table.
+type DynamoDBConfig struct {
+	// Table is the name of the table. Its partition key must be the string
+	// DynamoDBKeyAttribute.
+	Table string
+	// Region is the table's AWS region. If empty, it's read from the
+	// environment like the AWS CLI does.
+	Region string
+	// Endpoint overrides the DynamoDB endpoint, ex. for DynamoDB Local.
+	Endpoint string
+	// PullTTL is how long the status of a pull request is kept after it was
+	// last updated, in case Atlantis never finds out the pull request was
+	// closed. If 0, statuses don't expire.
+	PullTTL time.Duration
+	// KeyPrefix is prepended to every key, ex. so that multiple tenants can
+	// share a table.
+	KeyPrefix string
+}
+
+// DynamoDB is a database using a DynamoDB table. Writes are conditional so
+// multiple Atlantis servers can share the table.
+type DynamoDB struct {
+	// Now returns the current time. It's only overridden in tests.
+	Now func() time.Time
+
+	client  dynamodbiface.DynamoDBAPI
+	table   string
+	prefix  string
+	pullTTL time.Duration
+}
+
+// NewDynamoDB returns a database using the table in cfg. It checks that the
+// table exists.
+func NewDynamoDB(cfg DynamoDBConfig) (*DynamoDB, error) {
+	awsCfg := aws.Config{}
+	if cfg.Region != "" {
+		awsCfg.Region = aws.String(cfg.Region)
+	}
+	if cfg.Endpoint != "" {
+		awsCfg.Endpoint = aws.String(cfg.Endpoint)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            awsCfg,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating AWS session")
+	}
+	client := dynamodb.New(sess)
+	if _, err := client.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String(cfg.Table)}); err != nil {
+		return nil, errors.Wrapf(err, "describing DynamoDB table %q", cfg.Table)
+	}
+	return NewDynamoDBWithClient(client, cfg), nil
+}
+
+// NewDynamoDBWithClient is used for testing.
+func NewDynamoDBWithClient(client dynamodbiface.DynamoDBAPI, cfg DynamoDBConfig) *DynamoDB {
+	return &DynamoDB{
+		client:  client,
+		table:   cfg.Table,
+		prefix:  cfg.KeyPrefix,
+		pullTTL: cfg.PullTTL,
+		Now:     time.Now,
+	}
+}
+
+// TryLock attempts to create a new lock. If the lock is
+// acquired, it will return true and the lock returned will be newLock.
+// If the lock is not acquired, it will return false and the current
+// lock that is preventing this lock from being acquired.
+func (d *DynamoDB) TryLock(newLock models.ProjectLock) (bool, models.ProjectLock, error) {
+	key := d.lockKey(newLock.Project, newLock.Workspace)
+	newLockSerialized, _ := json.Marshal(newLock)
+	for i := 0; i < dynamoDBMaxRetries; i++ {
+		err := d.putIfNotExists(key, newLockSerialized)
+		if err == nil {
+			return true, newLock, nil
+		}
+		if !isConditionalCheckFailed(err) {
+			return false, models.ProjectLock{}, errors.Wrap(err, "DynamoDB PutItem failed")
+		}
+		currLock, err := d.getLock(key)
+		if err != nil {
+			return false, models.ProjectLock{}, err
+		}
+		// If the lock was released since we tried to acquire it, try again.
+		if currLock != nil {
+			return false, *currLock, nil
+		}
+	}
+	return false, models.ProjectLock{}, errors.Errorf("lock %q kept changing while acquiring it", key)
+}
+
+// Unlock attempts to unlock the project and workspace.
+// If there is no lock, then it will return a nil pointer.
+// If there is a lock, then it will delete it, and then return a pointer
+// to the deleted lock.
+func (d *DynamoDB) Unlock(p models.Project, workspace string) (*models.ProjectLock, error) {
+	key := d.lockKey(p, workspace)
+	out, err := d.client.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName:    aws.String(d.table),
+		Key:          d.key(key),
+		ReturnValues: aws.String(dynamodb.ReturnValueAllOld),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "DynamoDB DeleteItem failed")
+	}
+	value, ok := itemValue(out.Attributes)
+	if !ok {
+		return nil, nil
+	}
+	var lock models.ProjectLock
+	if err := json.Unmarshal(value, &lock); err != nil {
+		return nil, errors.Wrapf(err, "deserializing lock at key %q", key)
+	}
+	return &lock, nil
+}
+
+// List lists all current locks.
+func (d *DynamoDB) List() ([]models.ProjectLock, error) {
+	var locks []models.ProjectLock
+	err := d.scan(d.prefix+dynamoDBLocksPrefix, func(key string, value []byte) error {
+		var lock models.ProjectLock
+		if err := json.Unmarshal(value, &lock); err != nil {
+			return errors.Wrapf(err, "deserializing lock at key %q", key)
+		}
+		locks = append(locks, lock)
+		return nil
+	})
+	return locks, err
+}
+
+// GetLock returns a pointer to the lock for that project and workspace.
+// If there is no lock, it returns a nil pointer.
+func (d *DynamoDB) GetLock(p models.Project, workspace string) (*models.ProjectLock, error) {
+	lock, err := d.getLock(d.lockKey(p, workspace))
+	if err != nil || lock == nil {
+		return nil, err
+	}
+	// need to set it to Local after deserialization due to https://github.com/golang/go/issues/19486
+	lock.Time = lock.Time.Local()
+	return lock, nil
+}
+
+// UnlockByPull deletes all locks associated with that pull request and returns them.
+func (d *DynamoDB) UnlockByPull(repoFullName string, pullNum int) ([]models.ProjectLock, error) {
+	var locks []models.ProjectLock
+	err := d.scan(d.prefix+dynamoDBLocksPrefix+repoFullName+"/", func(key string, value []byte) error {
+		var lock models.ProjectLock
+		if err := json.Unmarshal(value, &lock); err != nil {
+			return errors.Wrapf(err, "deserializing lock at key %q", key)
+		}
+		if lock.Pull.Num == pullNum {
+			locks = append(locks, lock)
+		}
+		return nil
+	})
+	if err != nil {
+		return locks, err
+	}
+
+	// delete the locks
+	for _, lock := range locks {
+		if _, err = d.Unlock(lock.Project, lock.Workspace); err != nil {
+			return locks, errors.Wrapf(err, "unlocking repo %s, path %s, workspace %s", lock.Project.RepoFullName, lock.Project.Path, lock.Workspace)
+		}
+	}
+	return locks, nil
+}
+
+// LockCommand attempts to create a new lock for a CommandName.
+// If the lock doesn't exists, it will create a lock and return a pointer to it.
+// If the lock already exists, it will return an "lock already exists" error
+func (d *DynamoDB) LockCommand(cmdName models.CommandName, lockTime time.Time) (*models.CommandLock, error) {
+	lock := models.CommandLock{
+		CommandName: cmdName,
+		LockMetadata: models.LockMetadata{
+			UnixTime: lockTime.Unix(),
+		},
+	}
+	newLockSerialized, _ := json.Marshal(lock)
+	err := d.putIfNotExists(d.commandLockKey(cmdName), newLockSerialized)
+	if isConditionalCheckFailed(err) {
+		return nil, errors.New("lock already exists")
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "DynamoDB PutItem failed")
+	}
+	return &lock, nil
+}
+
+// UnlockCommand removes CommandName lock if present.
+// If there are no lock it returns an error.
+func (d *DynamoDB) UnlockCommand(cmdName models.CommandName) error {
+	_, err := d.client.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName:                aws.String(d.table),
+		Key:                      d.key(d.commandLockKey(cmdName)),
+		ConditionExpression:      aws.String("attribute_exists(#k)"),
+		ExpressionAttributeNames: map[string]*string{"#k": aws.String(DynamoDBKeyAttribute)},
+	})
+	if isConditionalCheckFailed(err) {
+		return errors.New("no lock exists")
+	}
+	return errors.Wrap(err, "DynamoDB DeleteItem failed")
+}
+
+// CheckCommandLock checks if CommandName lock was set.
+// If the lock exists return the pointer to the lock object, otherwise return nil
+func (d *DynamoDB) CheckCommandLock(cmdName models.CommandName) (*models.CommandLock, error) {
+	item, err := d.get(d.commandLockKey(cmdName))
+	if err != nil {
+		return nil, err
+	}
+	value, ok := itemValue(item)
+	if !ok {
+		return nil, nil
+	}
+	var cmdLock models.CommandLock
+	if err := json.Unmarshal(value, &cmdLock); err != nil {
+		return nil, errors.Wrap(err, "failed to deserialize command lock")
+	}
+	return &cmdLock, nil
+}
+
+// UpdatePullWithResults updates pull's status with the latest project results.
+// It returns the new PullStatus object.
+func (d *DynamoDB) UpdatePullWithResults(pull models.PullRequest, newResults []models.ProjectResult) (models.PullStatus, error) {
+	key, err := d.pullKey(pull)
+	if err != nil {
+		return models.PullStatus{}, err
+	}
+	var newStatus models.PullStatus
+	err = d.updatePull(key, func(currStatus *models.PullStatus) *models.PullStatus {
+		newStatus = mergePullStatus(currStatus, pull, newResults)
+		return &newStatus
+	})
+	return newStatus, err
+}
+
+// GetPullStatus returns the status for pull.
+// If there is no status, returns a nil pointer.
+func (d *DynamoDB) GetPullStatus(pull models.PullRequest) (*models.PullStatus, error) {
+	key, err := d.pullKey(pull)
+	if err != nil {
+		return nil, err
+	}
+	status, _, err := d.getPull(key)
+	return status, err
+}
+
+// GetPullStatuses returns the statuses of all the pull requests we know
+// about. Statuses are deleted when pull requests are closed so these are all
+// open.
+func (d *DynamoDB) GetPullStatuses() ([]models.PullStatus, error) {
+	var statuses []models.PullStatus
+	err := d.scan(d.prefix+dynamoDBPullsPrefix, func(key string, value []byte) error {
+		var s models.PullStatus
+		if err := json.Unmarshal(value, &s); err != nil {
+			return errors.Wrapf(err, "deserializing pull at %q with contents %q", key, value)
+		}
+		statuses = append(statuses, s)
+		return nil
+	})
+	return statuses, err
+}
+
+// DeletePullStatus deletes the status for pull.
+func (d *DynamoDB) DeletePullStatus(pull models.PullRequest) error {
+	key, err := d.pullKey(pull)
+	if err != nil {
+		return err
+	}
+	_, err = d.client.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(d.table),
+		Key:       d.key(key),
+	})
+	return errors.Wrap(err, "DynamoDB DeleteItem failed")
+}
+
+// UpdateProjectStatus updates project status.
+func (d *DynamoDB) UpdateProjectStatus(pull models.PullRequest, workspace string, repoRelDir string, newStatus models.ProjectPlanStatus) error {
+	key, err := d.pullKey(pull)
+	if err != nil {
+		return err
+	}
+	return d.updatePull(key, func(currStatus *models.PullStatus) *models.PullStatus {
+		if currStatus == nil {
+			return nil
+		}
+		setProjectStatus(currStatus, workspace, repoRelDir, newStatus)
+		return currStatus
+	})
+}
+
+// putPullStatus replaces the status of status.Pull, ex. to migrate it from
+// another database.
+func (d *DynamoDB) putPullStatus(status models.PullStatus) error {
+	key, err := d.pullKey(status.Pull)
+	if err != nil {
+		return err
+	}
+	return d.updatePull(key, func(*models.PullStatus) *models.PullStatus {
+		return &status
+	})
+}
+
+// updatePull sets the pull status at key to what update returns for its
+// current status. If update returns nil, it's left as is. Each status has a
+// version that's checked when it's written so the update is retried if
+// another Atlantis server modifies the status concurrently.
+func (d *DynamoDB) updatePull(key string, update func(*models.PullStatus) *models.PullStatus) error {
+	for i := 0; i < dynamoDBMaxRetries; i++ {
+		currStatus, version, err := d.getPull(key)
+		if err != nil {
+			return err
+		}
+		newStatus := update(currStatus)
+		if newStatus == nil {
+			return nil
+		}
+		serialized, err := json.Marshal(newStatus)
+		if err != nil {
+			return errors.Wrap(err, "serializing")
+		}
+		item := d.key(key)
+		item[dynamoDBValueAttribute] = &dynamodb.AttributeValue{S: aws.String(string(serialized))}
+		item[dynamoDBVersionAttribute] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(version+1, 10))}
+		if d.pullTTL > 0 {
+			item[DynamoDBTTLAttribute] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(d.Now().Add(d.pullTTL).Unix(), 10))}
+		}
+		input := &dynamodb.PutItemInput{
+			TableName:                aws.String(d.table),
+			Item:                     item,
+			ConditionExpression:      aws.String("attribute_not_exists(#k)"),
+			ExpressionAttributeNames: map[string]*string{"#k": aws.String(DynamoDBKeyAttribute)},
+		}
+		if version > 0 {
+			input.ConditionExpression = aws.String("#v = :v")
+			input.ExpressionAttributeNames = map[string]*string{"#v": aws.String(dynamoDBVersionAttribute)}
+			input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+				":v": {N: aws.String(strconv.FormatInt(version, 10))},
+			}
+		}
+		_, err = d.client.PutItem(input)
+		if !isConditionalCheckFailed(err) {
+			return errors.Wrap(err, "DynamoDB PutItem failed")
+		}
+	}
+	return errors.Errorf("pull status %q kept changing while updating it", key)
+}
+
+// getPull returns the pull status at key and its version. If there's none,
+// it returns nil and version 0.
+func (d *DynamoDB) getPull(key string) (*models.PullStatus, int64, error) {
+	item, err := d.get(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	value, ok := itemValue(item)
+	if !ok {
+		return nil, 0, nil
+	}
+	var version int64
+	if v, ok := item[dynamoDBVersionAttribute]; ok && v.N != nil {
+		version, err = strconv.ParseInt(*v.N, 10, 64)
+		if err != nil {
+			return nil, 0, errors.Wrapf(err, "parsing version of pull at %q", key)
+		}
+	}
+	// DynamoDB can take a while to delete expired items so they're ignored
+	// until then. Their version is kept so they can be overwritten.
+	if d.expired(item) {
+		return nil, version, nil
+	}
+	var p models.PullStatus
+	if err := json.Unmarshal(value, &p); err != nil {
+		return nil, 0, errors.Wrapf(err, "deserializing pull at %q with contents %q", key, value)
+	}
+	return &p, version, nil
+}
+
+func (d *DynamoDB) getLock(key string) (*models.ProjectLock, error) {
+	item, err := d.get(key)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := itemValue(item)
+	if !ok {
+		return nil, nil
+	}
+	var lock models.ProjectLock
+	if err := json.Unmarshal(value, &lock); err != nil {
+		return nil, errors.Wrapf(err, "deserializing lock at key %q", key)
+	}
+	return &lock, nil
+}
+
+// get returns the item at key, which is nil if there's none. It's read
+// consistently so writes from other Atlantis servers are seen.
+func (d *DynamoDB) get(key string) (map[string]*dynamodb.AttributeValue, error) {
+	out, err := d.client.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(d.table),
+		Key:            d.key(key),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "DynamoDB GetItem failed")
+	}
+	return out.Item, nil
+}
+
+// putIfNotExists stores value at key unless there's already an item there,
+// in which case it returns a ConditionalCheckFailedException.
+func (d *DynamoDB) putIfNotExists(key string, value []byte) error {
+	item := d.key(key)
+	item[dynamoDBValueAttribute] = &dynamodb.AttributeValue{S: aws.String(string(value))}
+	_, err := d.client.PutItem(&dynamodb.PutItemInput{
+		TableName:                aws.String(d.table),
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#k)"),
+		ExpressionAttributeNames: map[string]*string{"#k": aws.String(DynamoDBKeyAttribute)},
+	})
+	return err
+}
+
+// scan calls fn with each key starting with prefix and its value.
+func (d *DynamoDB) scan(prefix string, fn func(key string, value []byte) error) error {
+	var fnErr error
+	err := d.client.ScanPages(&dynamodb.ScanInput{
+		TableName:                aws.String(d.table),
+		ConsistentRead:           aws.Bool(true),
+		FilterExpression:         aws.String("begins_with(#k, :prefix)"),
+		ExpressionAttributeNames: map[string]*string{"#k": aws.String(DynamoDBKeyAttribute)},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":prefix": {S: aws.String(prefix)},
+		},
+	}, func(out *dynamodb.ScanOutput, lastPage bool) bool {
+		for _, item := range out.Items {
+			value, ok := itemValue(item)
+			if !ok || d.expired(item) {
+				continue
+			}
+			if fnErr = fn(aws.StringValue(item[DynamoDBKeyAttribute].S), value); fnErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return errors.Wrap(err, "DynamoDB Scan failed")
+	}
+	return fnErr
+}
+
+// expired returns true if item is past its TTL.
+func (d *DynamoDB) expired(item map[string]*dynamodb.AttributeValue) bool {
+	v, ok := item[DynamoDBTTLAttribute]
+	if !ok || v.N == nil {
+		return false
+	}
+	expiresAt, err := strconv.ParseInt(*v.N, 10, 64)
+	return err == nil && expiresAt <= d.Now().Unix()
+}
+
+func (d *DynamoDB) key(key string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		DynamoDBKeyAttribute: {S: aws.String(key)},
+	}
+}
+
+func (d *DynamoDB) lockKey(p models.Project, workspace string) string {
+	return d.prefix + dynamoDBLocksPrefix + lockKey(p, workspace)
+}
+
+func (d *DynamoDB) commandLockKey(cmdName models.CommandName) string {
+	return d.prefix + dynamoDBGlobalLocksPrefix + commandLockKey(cmdName)
+}
+
+func (d *DynamoDB) pullKey(pull models.PullRequest) (string, error) {
+	key, err := pullKey(pull)
+	return d.prefix + dynamoDBPullsPrefix + key, err
+}
+
+// itemValue returns the serialized value of item. It returns false if item
+// is nil.
+func itemValue(item map[string]*dynamodb.AttributeValue) ([]byte, bool) {
+	v, ok := item[dynamoDBValueAttribute]
+	if !ok || v.S == nil {
+		return nil, false
+	}
+	return []byte(*v.S), true
+}
+
+func isConditionalCheckFailed(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
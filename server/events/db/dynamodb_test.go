@@ -0,0 +1,256 @@
+package db_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/runatlantis/atlantis/server/events/db"
+	"github.com/runatlantis/atlantis/server/events/models"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// fakeDynamoDB is an in-memory table that understands the condition and
+// filter expressions used by db.DynamoDB.
+type fakeDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	mutex sync.Mutex
+	items map[string]map[string]*dynamodb.AttributeValue
+	puts  int
+}
+
+func (f *fakeDynamoDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return &dynamodb.GetItemOutput{Item: f.items[f.id(input.Key)]}, nil
+}
+
+func (f *fakeDynamoDB) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	id := f.id(input.Item)
+	if err := f.check(f.items[id], input.ConditionExpression, input.ExpressionAttributeValues); err != nil {
+		return nil, err
+	}
+	f.items[id] = input.Item
+	f.puts++
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeDynamoDB) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	id := f.id(input.Key)
+	old := f.items[id]
+	if err := f.check(old, input.ConditionExpression, input.ExpressionAttributeValues); err != nil {
+		return nil, err
+	}
+	delete(f.items, id)
+	return &dynamodb.DeleteItemOutput{Attributes: old}, nil
+}
+
+func (f *fakeDynamoDB) ScanPages(input *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput, bool) bool) error {
+	f.mutex.Lock()
+	prefix := aws.StringValue(input.ExpressionAttributeValues[":prefix"].S)
+	out := &dynamodb.ScanOutput{}
+	for id, item := range f.items {
+		if strings.HasPrefix(id, prefix) {
+			out.Items = append(out.Items, item)
+		}
+	}
+	f.mutex.Unlock()
+	fn(out, true)
+	return nil
+}
+
+func (f *fakeDynamoDB) check(item map[string]*dynamodb.AttributeValue, condition *string, values map[string]*dynamodb.AttributeValue) error {
+	ok := true
+	switch aws.StringValue(condition) {
+	case "":
+	case "attribute_not_exists(#k)":
+		ok = item == nil
+	case "attribute_exists(#k)":
+		ok = item != nil
+	case "#v = :v":
+		ok = item != nil && aws.StringValue(item["version"].N) == aws.StringValue(values[":v"].N)
+	default:
+		panic("unexpected condition " + aws.StringValue(condition))
+	}
+	if !ok {
+		return awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	}
+	return nil
+}
+
+func (f *fakeDynamoDB) id(item map[string]*dynamodb.AttributeValue) string {
+	return aws.StringValue(item[db.DynamoDBKeyAttribute].S)
+}
+
+func newTestDynamoDB(ttl time.Duration) (*db.DynamoDB, *fakeDynamoDB) {
+	fake := &fakeDynamoDB{items: make(map[string]map[string]*dynamodb.AttributeValue)}
+	return db.NewDynamoDBWithClient(fake, db.DynamoDBConfig{Table: "atlantis", PullTTL: ttl, KeyPrefix: "atlantis:"}), fake
+}
+
+func TestDynamoDB_Locks(t *testing.T) {
+	d, fake := newTestDynamoDB(0)
+
+	acquired, currLock, err := d.TryLock(lock)
+	Ok(t, err)
+	Equals(t, true, acquired)
+	Equals(t, lock, currLock)
+	_, ok := fake.items["atlantis:lock:owner/repo/parent/child/default"]
+	Assert(t, ok, "exp lock to be stored under its key")
+
+	newLock := lock
+	newLock.Pull.Num = 2
+	acquired, currLock, err = d.TryLock(newLock)
+	Ok(t, err)
+	Equals(t, false, acquired)
+	Equals(t, pullNum, currLock.Pull.Num)
+
+	otherLock := lock
+	otherLock.Workspace = "staging"
+	_, _, err = d.TryLock(otherLock)
+	Ok(t, err)
+	locks, err := d.List()
+	Ok(t, err)
+	Equals(t, 2, len(locks))
+
+	got, err := d.GetLock(project, workspace)
+	Ok(t, err)
+	Equals(t, lock.User, got.User)
+	unlocked, err := d.Unlock(project, workspace)
+	Ok(t, err)
+	Equals(t, workspace, unlocked.Workspace)
+	unlocked, err = d.Unlock(project, workspace)
+	Ok(t, err)
+	Assert(t, unlocked == nil, "exp nil when there's no lock")
+
+	deleted, err := d.UnlockByPull("owner/repo", pullNum)
+	Ok(t, err)
+	Equals(t, 1, len(deleted))
+	locks, err = d.List()
+	Ok(t, err)
+	Equals(t, 0, len(locks))
+}
+
+func TestDynamoDB_CommandLocks(t *testing.T) {
+	d, _ := newTestDynamoDB(0)
+
+	cmdLock, err := d.CheckCommandLock(models.ApplyCommand)
+	Ok(t, err)
+	Assert(t, cmdLock == nil, "exp nil")
+	ErrEquals(t, "no lock exists", d.UnlockCommand(models.ApplyCommand))
+
+	lockTime := time.Now()
+	_, err = d.LockCommand(models.ApplyCommand, lockTime)
+	Ok(t, err)
+	_, err = d.LockCommand(models.ApplyCommand, lockTime)
+	ErrEquals(t, "lock already exists", err)
+	cmdLock, err = d.CheckCommandLock(models.ApplyCommand)
+	Ok(t, err)
+	Equals(t, lockTime.Unix(), cmdLock.LockMetadata.UnixTime)
+	Ok(t, d.UnlockCommand(models.ApplyCommand))
+}
+
+func TestDynamoDB_PullStatus(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	d, fake := newTestDynamoDB(time.Hour)
+	d.Now = func() time.Time { return now }
+	repo, err := models.NewRepo(models.Github, "runatlantis/atlantis", "https://github.com/runatlantis/atlantis.git", "", "")
+	Ok(t, err)
+	pull := models.PullRequest{
+		Num:        1,
+		HeadCommit: "sha",
+		BaseRepo:   repo,
+		State:      models.OpenPullState,
+	}
+	key := "atlantis:pull:github.com::runatlantis/atlantis::1"
+
+	Ok(t, d.UpdateProjectStatus(pull, "default", ".", models.DiscardedPlanStatus))
+	Equals(t, 0, fake.puts)
+
+	_, err = d.UpdatePullWithResults(pull, []models.ProjectResult{
+		{Command: models.PlanCommand, RepoRelDir: ".", Workspace: "default", PlanSuccess: &models.PlanSuccess{TerraformOutput: "tf"}},
+	})
+	Ok(t, err)
+	Equals(t, "1", aws.StringValue(fake.items[key]["version"].N))
+	Equals(t, "1600003600", aws.StringValue(fake.items[key][db.DynamoDBTTLAttribute].N))
+
+	// Each write bumps the version and the expiry.
+	now = now.Add(time.Minute)
+	Ok(t, d.UpdateProjectStatus(pull, "default", ".", models.DiscardedPlanStatus))
+	Equals(t, "2", aws.StringValue(fake.items[key]["version"].N))
+	Equals(t, "1600003660", aws.StringValue(fake.items[key][db.DynamoDBTTLAttribute].N))
+	status, err := d.GetPullStatus(pull)
+	Ok(t, err)
+	Equals(t, models.DiscardedPlanStatus, status.Projects[0].Status)
+	statuses, err := d.GetPullStatuses()
+	Ok(t, err)
+	Equals(t, 1, len(statuses))
+
+	// Expired statuses are ignored until DynamoDB deletes them.
+	now = now.Add(time.Hour)
+	status, err = d.GetPullStatus(pull)
+	Ok(t, err)
+	Assert(t, status == nil, "exp expired status to be ignored")
+	statuses, err = d.GetPullStatuses()
+	Ok(t, err)
+	Equals(t, 0, len(statuses))
+	_, err = d.UpdatePullWithResults(pull, nil)
+	Ok(t, err)
+	Equals(t, "3", aws.StringValue(fake.items[key]["version"].N))
+
+	Ok(t, d.DeletePullStatus(pull))
+	status, err = d.GetPullStatus(pull)
+	Ok(t, err)
+	Assert(t, status == nil, "exp nil")
+}
+
+func TestDynamoDB_PullStatusConflict(t *testing.T) {
+	d, fake := newTestDynamoDB(0)
+	repo, err := models.NewRepo(models.Github, "runatlantis/atlantis", "https://github.com/runatlantis/atlantis.git", "", "")
+	Ok(t, err)
+	pull := models.PullRequest{Num: 1, HeadCommit: "sha", BaseRepo: repo}
+	_, err = d.UpdatePullWithResults(pull, []models.ProjectResult{
+		{Command: models.PlanCommand, RepoRelDir: ".", Workspace: "default", PlanSuccess: &models.PlanSuccess{}},
+	})
+	Ok(t, err)
+
+	// Simulate another server writing the status concurrently: results
+	// written with a stale version are retried so both are kept.
+	conflicting := &conflictingDynamoDB{fakeDynamoDB: fake}
+	d = db.NewDynamoDBWithClient(conflicting, db.DynamoDBConfig{Table: "atlantis", KeyPrefix: "atlantis:"})
+	other := db.NewDynamoDBWithClient(fake, db.DynamoDBConfig{Table: "atlantis", KeyPrefix: "atlantis:"})
+	conflicting.beforePut = func() {
+		_, err := other.UpdatePullWithResults(pull, []models.ProjectResult{
+			{Command: models.PlanCommand, RepoRelDir: "other", Workspace: "default", PlanSuccess: &models.PlanSuccess{}},
+		})
+		Ok(t, err)
+	}
+	status, err := d.UpdatePullWithResults(pull, []models.ProjectResult{
+		{Command: models.PlanCommand, RepoRelDir: "staging", Workspace: "default", PlanSuccess: &models.PlanSuccess{}},
+	})
+	Ok(t, err)
+	Equals(t, 3, len(status.Projects))
+}
+
+// conflictingDynamoDB calls beforePut before its first put.
+type conflictingDynamoDB struct {
+	*fakeDynamoDB
+	beforePut func()
+}
+
+func (c *conflictingDynamoDB) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	if c.beforePut != nil {
+		before := c.beforePut
+		c.beforePut = nil
+		before()
+	}
+	return c.fakeDynamoDB.PutItem(input)
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"time"
+)
+
+// Lease is held by an Atlantis server while it runs commands for a pull
+// request so that the other servers sharing the database don't run commands
+// for it at the same time. It expires unless it's renewed so that another
+// server can take over if its holder dies.
+type Lease struct {
+	// Key identifies what the lease is for, ex. a pull request.
+	Key string
+	// Holder identifies the server holding the lease.
+	Holder string
+	// Command is the command the holder is running.
+	Command   string
+	ExpiresAt time.Time
+}
+
+// LeaseStore stores leases. It's implemented by the databases that multiple
+// Atlantis servers can share, except DynamoDB.
+type LeaseStore interface {
+	// TryAcquireLease acquires lease if its key has no lease, if its lease
+	// expired at now or if its lease is held by lease.Holder. If the lease
+	// is acquired, it returns true and the lease it replaced, if any. If it
+	// isn't, it returns false and the lease held by the other server.
+	TryAcquireLease(lease Lease, now time.Time) (bool, *Lease, error)
+	// RenewLease extends the lease of key until expiresAt. It returns false
+	// if holder doesn't hold it anymore, ex. because it expired and was
+	// acquired by another server.
+	RenewLease(key string, holder string, expiresAt time.Time) (bool, error)
+	// ReleaseLease deletes the lease of key if it's held by holder.
+	ReleaseLease(key string, holder string) error
+}
+
+// leaseUpdater is implemented by each LeaseStore. updateLease replaces the
+// lease of key with what update returns for its current lease, which is nil
+// if there's none. If update returns false, the lease is left as is. If it
+// returns a nil lease, the lease is deleted. Concurrent updates of the same
+// lease are serialized.
+type leaseUpdater interface {
+	updateLease(key string, update func(curr *Lease) (*Lease, bool)) error
+}
+
+func tryAcquireLease(u leaseUpdater, lease Lease, now time.Time) (bool, *Lease, error) {
+	var acquired bool
+	var prev *Lease
+	err := u.updateLease(lease.Key, func(curr *Lease) (*Lease, bool) {
+		prev = curr
+		acquired = curr == nil || curr.Holder == lease.Holder || !now.Before(curr.ExpiresAt)
+		return &lease, acquired
+	})
+	if err != nil {
+		return false, nil, err
+	}
+	return acquired, prev, nil
+}
+
+func renewLease(u leaseUpdater, key string, holder string, expiresAt time.Time) (bool, error) {
+	var renewed bool
+	err := u.updateLease(key, func(curr *Lease) (*Lease, bool) {
+		if curr == nil || curr.Holder != holder {
+			return nil, false
+		}
+		renewed = true
+		next := *curr
+		next.ExpiresAt = expiresAt
+		return &next, true
+	})
+	return renewed, err
+}
+
+func releaseLease(u leaseUpdater, key string, holder string) error {
+	return u.updateLease(key, func(curr *Lease) (*Lease, bool) {
+		return nil, curr != nil && curr.Holder == holder
+	})
+}
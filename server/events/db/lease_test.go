@@ -0,0 +1,65 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/db"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestRedis_Lease(t *testing.T) {
+	r, _ := newTestRedis(t, db.DefaultKeyPrefix)
+	testLeaseStore(t, r)
+}
+
+func TestPostgres_Lease(t *testing.T) {
+	testLeaseStore(t, newTestPostgres(t))
+}
+
+func testLeaseStore(t *testing.T, store db.LeaseStore) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	lease := db.Lease{Key: "github.com/owner/repo#1", Holder: "atlantis-0", Command: "plan", ExpiresAt: now.Add(time.Minute)}
+
+	acquired, prev, err := store.TryAcquireLease(lease, now)
+	Ok(t, err)
+	Assert(t, acquired, "exp lease to be acquired")
+	Assert(t, prev == nil, "exp no previous lease, got %v", prev)
+
+	// Another server can't acquire it until it expires.
+	other := lease
+	other.Holder = "atlantis-1"
+	other.Command = "apply"
+	other.ExpiresAt = now.Add(3 * time.Minute)
+	acquired, prev, err = store.TryAcquireLease(other, now.Add(30*time.Second))
+	Ok(t, err)
+	Assert(t, !acquired, "exp lease to be held by atlantis-0")
+	Equals(t, "atlantis-0", prev.Holder)
+
+	renewed, err := store.RenewLease(lease.Key, "atlantis-0", now.Add(2*time.Minute))
+	Ok(t, err)
+	Assert(t, renewed, "exp lease to be renewed")
+	acquired, _, err = store.TryAcquireLease(other, now.Add(90*time.Second))
+	Ok(t, err)
+	Assert(t, !acquired, "exp renewed lease to be held by atlantis-0")
+
+	// Once it expires, it's taken over.
+	acquired, prev, err = store.TryAcquireLease(other, now.Add(2*time.Minute))
+	Ok(t, err)
+	Assert(t, acquired, "exp expired lease to be taken over")
+	Equals(t, "atlantis-0", prev.Holder)
+	Equals(t, "plan", prev.Command)
+	renewed, err = store.RenewLease(lease.Key, "atlantis-0", now.Add(3*time.Minute))
+	Ok(t, err)
+	Assert(t, !renewed, "exp lease taken over not to be renewed")
+
+	// Only its holder can release it.
+	Ok(t, store.ReleaseLease(lease.Key, "atlantis-0"))
+	acquired, _, err = store.TryAcquireLease(lease, now.Add(2*time.Minute))
+	Ok(t, err)
+	Assert(t, !acquired, "exp lease to still be held by atlantis-1")
+	Ok(t, store.ReleaseLease(lease.Key, "atlantis-1"))
+	acquired, prev, err = store.TryAcquireLease(lease, now.Add(2*time.Minute))
+	Ok(t, err)
+	Assert(t, acquired && prev == nil, "exp released lease to be acquired")
+}
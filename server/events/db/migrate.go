@@ -0,0 +1,154 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/jobs"
+)
+
+// pullStatusPutter is implemented by the databases whose pull statuses can
+// be migrated.
+type pullStatusPutter interface {
+	putPullStatus(status models.PullStatus) error
+}
+
+// jobHistory is implemented by the databases that keep the history of jobs.
+type jobHistory interface {
+	SaveJob(job jobs.Job) error
+	GetJob(id string) (*jobs.Job, error)
+	ListJobs() ([]jobs.Job, error)
+}
+
+// MigrationResult is what Migrate copied.
+type MigrationResult struct {
+	Locks        int
+	CommandLocks int
+	PullStatuses int
+	Jobs         int
+	// SkippedJobs is the number of jobs that weren't copied because the
+	// destination doesn't keep the history of jobs.
+	SkippedJobs int
+}
+
+// Migrate copies the locks, pull statuses and history of jobs from one
+// database to another, and reads each back from the destination to verify
+// it was copied. Data already in the destination is kept unless it's for
+// the same pull request, and a lock held by a different pull request in the
+// destination is an error. Neither database should be used by a running
+// Atlantis during the migration.
+func Migrate(from Database, to Database) (MigrationResult, error) {
+	var res MigrationResult
+	locks, err := from.List()
+	if err != nil {
+		return res, errors.Wrap(err, "listing locks")
+	}
+	for _, lock := range locks {
+		key := lockKey(lock.Project, lock.Workspace)
+		acquired, currLock, err := to.TryLock(lock)
+		if err != nil {
+			return res, errors.Wrapf(err, "copying lock %q", key)
+		}
+		if !acquired && currLock.Pull.Num != lock.Pull.Num {
+			return res, fmt.Errorf("lock %q is held by pull request #%d in the destination but by #%d in the source", key, currLock.Pull.Num, lock.Pull.Num)
+		}
+		copied, err := to.GetLock(lock.Project, lock.Workspace)
+		if err != nil {
+			return res, errors.Wrapf(err, "verifying lock %q", key)
+		}
+		if copied == nil || !sameJSON(lock, *copied) {
+			return res, fmt.Errorf("verifying lock %q: it differs in the destination", key)
+		}
+		res.Locks++
+	}
+
+	for _, cmdName := range []models.CommandName{models.ApplyCommand} {
+		cmdLock, err := from.CheckCommandLock(cmdName)
+		if err != nil {
+			return res, errors.Wrapf(err, "checking %s command lock", cmdName.String())
+		}
+		if cmdLock == nil || !cmdLock.IsLocked() {
+			continue
+		}
+		copied, err := to.CheckCommandLock(cmdName)
+		if err != nil {
+			return res, errors.Wrapf(err, "checking %s command lock in the destination", cmdName.String())
+		}
+		// It's already locked if the migration is run again.
+		if copied == nil || !copied.IsLocked() {
+			if _, err := to.LockCommand(cmdName, cmdLock.LockTime()); err != nil {
+				return res, errors.Wrapf(err, "copying %s command lock", cmdName.String())
+			}
+			copied, err = to.CheckCommandLock(cmdName)
+			if err != nil {
+				return res, errors.Wrapf(err, "verifying %s command lock", cmdName.String())
+			}
+			if copied == nil || !copied.IsLocked() {
+				return res, fmt.Errorf("verifying %s command lock: it's missing in the destination", cmdName.String())
+			}
+		}
+		res.CommandLocks++
+	}
+
+	putter, ok := to.(pullStatusPutter)
+	if !ok {
+		return res, fmt.Errorf("pull statuses can't be migrated to %T", to)
+	}
+	statuses, err := from.GetPullStatuses()
+	if err != nil {
+		return res, errors.Wrap(err, "listing pull statuses")
+	}
+	for _, status := range statuses {
+		if err := putter.putPullStatus(status); err != nil {
+			return res, errors.Wrapf(err, "copying status of pull request #%d", status.Pull.Num)
+		}
+		copied, err := to.GetPullStatus(status.Pull)
+		if err != nil {
+			return res, errors.Wrapf(err, "verifying status of pull request #%d", status.Pull.Num)
+		}
+		if copied == nil || !sameJSON(status, *copied) {
+			return res, fmt.Errorf("verifying status of %s pull request #%d: it differs in the destination", status.Pull.BaseRepo.FullName, status.Pull.Num)
+		}
+		res.PullStatuses++
+	}
+
+	fromJobs, ok := from.(jobHistory)
+	if !ok {
+		return res, nil
+	}
+	history, err := fromJobs.ListJobs()
+	if err != nil {
+		return res, errors.Wrap(err, "listing jobs")
+	}
+	toJobs, ok := to.(jobHistory)
+	if !ok {
+		res.SkippedJobs = len(history)
+		return res, nil
+	}
+	for _, job := range history {
+		if err := toJobs.SaveJob(job); err != nil {
+			return res, errors.Wrapf(err, "copying job %q", job.ID)
+		}
+		copied, err := toJobs.GetJob(job.ID)
+		if err != nil {
+			return res, errors.Wrapf(err, "verifying job %q", job.ID)
+		}
+		if copied == nil || !sameJSON(job, *copied) {
+			return res, fmt.Errorf("verifying job %q: it differs in the destination", job.ID)
+		}
+		res.Jobs++
+	}
+	return res, nil
+}
+
+// sameJSON returns true if a and b serialize to the same JSON. Databases
+// store JSON, so comparing it ignores differences that don't survive being
+// stored, ex. the monotonic clock of times.
+func sameJSON(a interface{}, b interface{}) bool {
+	aJSON, aErr := json.Marshal(a)
+	bJSON, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && bytes.Equal(aJSON, bJSON)
+}
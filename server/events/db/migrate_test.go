@@ -0,0 +1,72 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/db"
+	"github.com/runatlantis/atlantis/server/events/models"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestMigrate(t *testing.T) {
+	from, cleanup := newTestDB2(t)
+	defer cleanup()
+	to, _ := newTestRedis(t, db.DefaultKeyPrefix)
+
+	_, _, err := from.TryLock(lock)
+	Ok(t, err)
+	otherLock := lock
+	otherLock.Workspace = "staging"
+	_, _, err = from.TryLock(otherLock)
+	Ok(t, err)
+	lockTime := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	_, err = from.LockCommand(models.ApplyCommand, lockTime)
+	Ok(t, err)
+	pull := models.PullRequest{
+		Num:        pullNum,
+		HeadCommit: "sha",
+		BaseRepo:   models.Repo{FullName: "owner/repo", VCSHost: models.VCSHost{Hostname: "github.com"}},
+	}
+	_, err = from.UpdatePullWithResults(pull, []models.ProjectResult{
+		{Command: models.PlanCommand, RepoRelDir: "parent/child", Workspace: workspace, PlanSuccess: &models.PlanSuccess{}, User: "lkysow"},
+	})
+	Ok(t, err)
+	Ok(t, from.UpdateProjectStatus(pull, workspace, "parent/child", models.DiscardedPlanStatus))
+
+	res, err := db.Migrate(from, to)
+	Ok(t, err)
+	Equals(t, db.MigrationResult{Locks: 2, CommandLocks: 1, PullStatuses: 1}, res)
+
+	locks, err := to.List()
+	Ok(t, err)
+	Equals(t, 2, len(locks))
+	cmdLock, err := to.CheckCommandLock(models.ApplyCommand)
+	Ok(t, err)
+	Equals(t, lockTime.Unix(), cmdLock.LockTime().Unix())
+	status, err := to.GetPullStatus(pull)
+	Ok(t, err)
+	Equals(t, models.DiscardedPlanStatus, status.Projects[0].Status)
+	Equals(t, "lkysow", status.Projects[0].User)
+
+	// Migrating again is a no-op.
+	res, err = db.Migrate(from, to)
+	Ok(t, err)
+	Equals(t, 2, res.Locks)
+}
+
+func TestMigrate_LockConflict(t *testing.T) {
+	from, cleanup := newTestDB2(t)
+	defer cleanup()
+	to, _ := newTestRedis(t, db.DefaultKeyPrefix)
+
+	_, _, err := from.TryLock(lock)
+	Ok(t, err)
+	conflicting := lock
+	conflicting.Pull.Num = 2
+	_, _, err = to.TryLock(conflicting)
+	Ok(t, err)
+
+	_, err = db.Migrate(from, to)
+	ErrEquals(t, `lock "owner/repo/parent/child/default" is held by pull request #2 in the destination but by #1 in the source`, err)
+}
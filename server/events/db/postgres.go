@@ -0,0 +1,574 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	// Registers the postgres driver with database/sql.
+	_ "github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/jobs"
+)
+
+// postgresMigrationsTable records which migrations were applied.
+const postgresMigrationsTable = "atlantis_schema_migrations"
+
+// postgresMaxLockRetries is how many times acquiring a lock is retried when
+// it's released while being acquired.
+const postgresMaxLockRetries = 10
+
+// postgresMigrationLockID is the key of the advisory lock held while
+// migrating so servers starting at the same time don't migrate concurrently.
+const postgresMigrationLockID = 0x61746c61 // "atla"
+
+// postgresMigrations are applied in order. The schema's version is the
+// number of migrations applied so existing migrations must never be
+// modified, only new ones appended.
+var postgresMigrations = []string{
+	`CREATE TABLE atlantis_locks (
+		tenant     TEXT NOT NULL,
+		key        TEXT NOT NULL,
+		repo       TEXT NOT NULL,
+		pull_num   INTEGER NOT NULL,
+		data       JSONB NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		PRIMARY KEY (tenant, key)
+	);
+	CREATE INDEX atlantis_locks_pull_idx ON atlantis_locks (tenant, repo, pull_num);
+	CREATE TABLE atlantis_command_locks (
+		tenant  TEXT NOT NULL,
+		command TEXT NOT NULL,
+		data    JSONB NOT NULL,
+		PRIMARY KEY (tenant, command)
+	);
+	CREATE TABLE atlantis_pull_statuses (
+		tenant     TEXT NOT NULL,
+		key        TEXT NOT NULL,
+		data       JSONB NOT NULL,
+		updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		PRIMARY KEY (tenant, key)
+	)`,
+	`CREATE TABLE atlantis_jobs (
+		tenant     TEXT NOT NULL,
+		id         TEXT NOT NULL,
+		status     TEXT NOT NULL,
+		repo       TEXT NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		data       JSONB NOT NULL,
+		PRIMARY KEY (tenant, id)
+	);
+	CREATE INDEX atlantis_jobs_created_at_idx ON atlantis_jobs (tenant, created_at)`,
+	`CREATE TABLE atlantis_leases (
+		tenant TEXT NOT NULL,
+		key    TEXT NOT NULL,
+		data   JSONB NOT NULL,
+		PRIMARY KEY (tenant, key)
+	)`,
+}
+
+// PostgresConfig configures the connection to PostgreSQL.
+type PostgresConfig struct {
+	// URL is the postgres:// or postgresql:// URL of the database.
+	URL string
+	// Tenant separates the rows of multiple tenants sharing a database. It's
+	// empty for the default tenant.
+	Tenant string
+}
+
+// Postgres is a database using PostgreSQL. It lets multiple Atlantis servers
+// share their locks and the statuses of pull requests, and keeps the
+// history of jobs.
+type Postgres struct {
+	db     *sql.DB
+	tenant string
+	// Now returns the current time. It's only overridden in tests.
+	Now func() time.Time
+}
+
+// NewPostgres connects to PostgreSQL and migrates its schema to the latest
+// version.
+func NewPostgres(cfg PostgresConfig) (*Postgres, error) {
+	parsed, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing postgres url")
+	}
+	if parsed.Scheme != "postgres" && parsed.Scheme != "postgresql" {
+		return nil, fmt.Errorf("unsupported postgres url scheme %q", parsed.Scheme)
+	}
+	sqlDB, err := sql.Open("postgres", cfg.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "connecting to postgres")
+	}
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close() // nolint: errcheck
+		return nil, errors.Wrap(err, "connecting to postgres")
+	}
+	return NewPostgresWithDB(sqlDB, cfg.Tenant)
+}
+
+// NewPostgresWithDB returns a Postgres using an existing connection and
+// migrates its schema to the latest version.
+func NewPostgresWithDB(sqlDB *sql.DB, tenant string) (*Postgres, error) {
+	p := &Postgres{
+		db:     sqlDB,
+		tenant: tenant,
+		Now:    time.Now,
+	}
+	if err := p.migrate(); err != nil {
+		return nil, errors.Wrap(err, "migrating postgres schema")
+	}
+	return p, nil
+}
+
+// DB returns the connection to the database, ex. so the audit log can be
+// stored in it.
+func (p *Postgres) DB() *sql.DB {
+	return p.db
+}
+
+// TryLock attempts to create a new lock. If the lock is
+// acquired, it will return true and the lock returned will be newLock.
+// If the lock is not acquired, it will return false and the current
+// lock that is preventing this lock from being acquired.
+func (p *Postgres) TryLock(newLock models.ProjectLock) (bool, models.ProjectLock, error) {
+	key := lockKey(newLock.Project, newLock.Workspace)
+	newLockSerialized, _ := json.Marshal(newLock)
+	for i := 0; i < postgresMaxLockRetries; i++ {
+		res, err := p.db.Exec(`INSERT INTO atlantis_locks (tenant, key, repo, pull_num, data, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (tenant, key) DO NOTHING`,
+			p.tenant, key, newLock.Project.RepoFullName, newLock.Pull.Num, string(newLockSerialized), p.Now())
+		if err != nil {
+			return false, models.ProjectLock{}, errors.Wrap(err, "inserting lock")
+		}
+		if inserted, _ := res.RowsAffected(); inserted == 1 {
+			return true, newLock, nil
+		}
+		currLock, err := p.getLock(key)
+		if err != nil {
+			return false, models.ProjectLock{}, err
+		}
+		// If the lock was released since we tried to acquire it, try again.
+		if currLock != nil {
+			return false, *currLock, nil
+		}
+	}
+	return false, models.ProjectLock{}, errors.Errorf("lock %q kept changing while acquiring it", key)
+}
+
+// Unlock attempts to unlock the project and workspace.
+// If there is no lock, then it will return a nil pointer.
+// If there is a lock, then it will delete it, and then return a pointer
+// to the deleted lock.
+func (p *Postgres) Unlock(project models.Project, workspace string) (*models.ProjectLock, error) {
+	locks, err := p.queryLocks(`DELETE FROM atlantis_locks WHERE tenant = $1 AND key = $2 RETURNING data`,
+		p.tenant, lockKey(project, workspace))
+	if err != nil || len(locks) == 0 {
+		return nil, err
+	}
+	return &locks[0], nil
+}
+
+// List lists all current locks.
+func (p *Postgres) List() ([]models.ProjectLock, error) {
+	return p.queryLocks(`SELECT data FROM atlantis_locks WHERE tenant = $1 ORDER BY key`, p.tenant)
+}
+
+// GetLock returns a pointer to the lock for that project and workspace.
+// If there is no lock, it returns a nil pointer.
+func (p *Postgres) GetLock(project models.Project, workspace string) (*models.ProjectLock, error) {
+	lock, err := p.getLock(lockKey(project, workspace))
+	if err != nil || lock == nil {
+		return nil, err
+	}
+	// need to set it to Local after deserialization due to https://github.com/golang/go/issues/19486
+	lock.Time = lock.Time.Local()
+	return lock, nil
+}
+
+// UnlockByPull deletes all locks associated with that pull request and returns them.
+func (p *Postgres) UnlockByPull(repoFullName string, pullNum int) ([]models.ProjectLock, error) {
+	return p.queryLocks(`DELETE FROM atlantis_locks WHERE tenant = $1 AND repo = $2 AND pull_num = $3 RETURNING data`,
+		p.tenant, repoFullName, pullNum)
+}
+
+// LockCommand attempts to create a new lock for a CommandName.
+// If the lock doesn't exists, it will create a lock and return a pointer to it.
+// If the lock already exists, it will return an "lock already exists" error
+func (p *Postgres) LockCommand(cmdName models.CommandName, lockTime time.Time) (*models.CommandLock, error) {
+	lock := models.CommandLock{
+		CommandName: cmdName,
+		LockMetadata: models.LockMetadata{
+			UnixTime: lockTime.Unix(),
+		},
+	}
+	newLockSerialized, _ := json.Marshal(lock)
+	res, err := p.db.Exec(`INSERT INTO atlantis_command_locks (tenant, command, data)
+		VALUES ($1, $2, $3) ON CONFLICT (tenant, command) DO NOTHING`,
+		p.tenant, cmdName.String(), string(newLockSerialized))
+	if err != nil {
+		return nil, errors.Wrap(err, "inserting command lock")
+	}
+	if inserted, _ := res.RowsAffected(); inserted == 0 {
+		return nil, errors.New("lock already exists")
+	}
+	return &lock, nil
+}
+
+// UnlockCommand removes CommandName lock if present.
+// If there are no lock it returns an error.
+func (p *Postgres) UnlockCommand(cmdName models.CommandName) error {
+	res, err := p.db.Exec(`DELETE FROM atlantis_command_locks WHERE tenant = $1 AND command = $2`,
+		p.tenant, cmdName.String())
+	if err != nil {
+		return errors.Wrap(err, "deleting command lock")
+	}
+	if deleted, _ := res.RowsAffected(); deleted == 0 {
+		return errors.New("no lock exists")
+	}
+	return nil
+}
+
+// CheckCommandLock checks if CommandName lock was set.
+// If the lock exists return the pointer to the lock object, otherwise return nil
+func (p *Postgres) CheckCommandLock(cmdName models.CommandName) (*models.CommandLock, error) {
+	var serialized []byte
+	err := p.db.QueryRow(`SELECT data FROM atlantis_command_locks WHERE tenant = $1 AND command = $2`,
+		p.tenant, cmdName.String()).Scan(&serialized)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "querying command lock")
+	}
+	var cmdLock models.CommandLock
+	if err := json.Unmarshal(serialized, &cmdLock); err != nil {
+		return nil, errors.Wrap(err, "failed to deserialize command lock")
+	}
+	return &cmdLock, nil
+}
+
+// UpdatePullWithResults updates pull's status with the latest project results.
+// It returns the new PullStatus object.
+func (p *Postgres) UpdatePullWithResults(pull models.PullRequest, newResults []models.ProjectResult) (models.PullStatus, error) {
+	key, err := pullKey(pull)
+	if err != nil {
+		return models.PullStatus{}, err
+	}
+	var newStatus models.PullStatus
+	err = p.updatePull(key, func(currStatus *models.PullStatus) *models.PullStatus {
+		newStatus = mergePullStatus(currStatus, pull, newResults)
+		return &newStatus
+	})
+	return newStatus, err
+}
+
+// GetPullStatus returns the status for pull.
+// If there is no status, returns a nil pointer.
+func (p *Postgres) GetPullStatus(pull models.PullRequest) (*models.PullStatus, error) {
+	key, err := pullKey(pull)
+	if err != nil {
+		return nil, err
+	}
+	return p.getPull(p.db, key)
+}
+
+// GetPullStatuses returns the statuses of all the pull requests we know
+// about. Statuses are deleted when pull requests are closed so these are all
+// open.
+func (p *Postgres) GetPullStatuses() ([]models.PullStatus, error) {
+	rows, err := p.db.Query(`SELECT key, data FROM atlantis_pull_statuses WHERE tenant = $1 ORDER BY key`, p.tenant)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying pull statuses")
+	}
+	defer rows.Close() // nolint: errcheck
+	var statuses []models.PullStatus
+	for rows.Next() {
+		var key string
+		var serialized []byte
+		if err := rows.Scan(&key, &serialized); err != nil {
+			return nil, errors.Wrap(err, "scanning pull status")
+		}
+		var s models.PullStatus
+		if err := json.Unmarshal(serialized, &s); err != nil {
+			return nil, errors.Wrapf(err, "deserializing pull at %q with contents %q", key, serialized)
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, errors.Wrap(rows.Err(), "querying pull statuses")
+}
+
+// DeletePullStatus deletes the status for pull.
+func (p *Postgres) DeletePullStatus(pull models.PullRequest) error {
+	key, err := pullKey(pull)
+	if err != nil {
+		return err
+	}
+	_, err = p.db.Exec(`DELETE FROM atlantis_pull_statuses WHERE tenant = $1 AND key = $2`, p.tenant, key)
+	return errors.Wrap(err, "deleting pull status")
+}
+
+// UpdateProjectStatus updates project status.
+func (p *Postgres) UpdateProjectStatus(pull models.PullRequest, workspace string, repoRelDir string, newStatus models.ProjectPlanStatus) error {
+	key, err := pullKey(pull)
+	if err != nil {
+		return err
+	}
+	return p.updatePull(key, func(currStatus *models.PullStatus) *models.PullStatus {
+		if currStatus == nil {
+			return nil
+		}
+		setProjectStatus(currStatus, workspace, repoRelDir, newStatus)
+		return currStatus
+	})
+}
+
+// putPullStatus replaces the status of status.Pull, ex. to migrate it from
+// another database.
+func (p *Postgres) putPullStatus(status models.PullStatus) error {
+	key, err := pullKey(status.Pull)
+	if err != nil {
+		return err
+	}
+	return p.updatePull(key, func(*models.PullStatus) *models.PullStatus {
+		return &status
+	})
+}
+
+// SaveJob creates or updates job so it's kept after it's evicted from
+// memory or the server restarts.
+func (p *Postgres) SaveJob(job jobs.Job) error {
+	serialized, err := json.Marshal(job)
+	if err != nil {
+		return errors.Wrap(err, "serializing job")
+	}
+	_, err = p.db.Exec(`INSERT INTO atlantis_jobs (tenant, id, status, repo, created_at, data)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (tenant, id) DO UPDATE SET status = EXCLUDED.status, data = EXCLUDED.data`,
+		p.tenant, job.ID, string(job.Status), job.Repo, job.CreatedAt, string(serialized))
+	return errors.Wrapf(err, "saving job %q", job.ID)
+}
+
+// GetJob returns the job with id or nil if there's none.
+func (p *Postgres) GetJob(id string) (*jobs.Job, error) {
+	var serialized []byte
+	err := p.db.QueryRow(`SELECT data FROM atlantis_jobs WHERE tenant = $1 AND id = $2`, p.tenant, id).Scan(&serialized)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "querying job %q", id)
+	}
+	var job jobs.Job
+	if err := json.Unmarshal(serialized, &job); err != nil {
+		return nil, errors.Wrapf(err, "deserializing job %q", id)
+	}
+	return &job, nil
+}
+
+// ListJobs returns every job, oldest first.
+func (p *Postgres) ListJobs() ([]jobs.Job, error) {
+	rows, err := p.db.Query(`SELECT data FROM atlantis_jobs WHERE tenant = $1 ORDER BY created_at`, p.tenant)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying jobs")
+	}
+	defer rows.Close() // nolint: errcheck
+	var list []jobs.Job
+	for rows.Next() {
+		var serialized []byte
+		if err := rows.Scan(&serialized); err != nil {
+			return nil, errors.Wrap(err, "scanning job")
+		}
+		var job jobs.Job
+		if err := json.Unmarshal(serialized, &job); err != nil {
+			return nil, errors.Wrap(err, "deserializing job")
+		}
+		list = append(list, job)
+	}
+	return list, errors.Wrap(rows.Err(), "querying jobs")
+}
+
+// TryAcquireLease acquires lease if it's free, expired at now or already
+// held by lease.Holder. See LeaseStore.
+func (p *Postgres) TryAcquireLease(lease Lease, now time.Time) (bool, *Lease, error) {
+	return tryAcquireLease(p, lease, now)
+}
+
+// RenewLease extends the lease of key held by holder until expiresAt.
+func (p *Postgres) RenewLease(key string, holder string, expiresAt time.Time) (bool, error) {
+	return renewLease(p, key, holder, expiresAt)
+}
+
+// ReleaseLease deletes the lease of key if it's held by holder.
+func (p *Postgres) ReleaseLease(key string, holder string) error {
+	return releaseLease(p, key, holder)
+}
+
+// Close closes the connections to PostgreSQL.
+func (p *Postgres) Close() error {
+	return p.db.Close()
+}
+
+// migrate applies the migrations that haven't been applied yet in a single
+// transaction.
+func (p *Postgres) migrate() error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // nolint: errcheck
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, postgresMigrationLockID); err != nil {
+		return errors.Wrap(err, "locking migrations")
+	}
+	if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS ` + postgresMigrationsTable + ` (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP WITH TIME ZONE NOT NULL
+	)`); err != nil {
+		return errors.Wrapf(err, "creating table %q", postgresMigrationsTable)
+	}
+	var version int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM ` + postgresMigrationsTable).Scan(&version); err != nil {
+		return errors.Wrap(err, "querying schema version")
+	}
+	if version > len(postgresMigrations) {
+		return fmt.Errorf("schema version %d is newer than the latest version supported by this version of Atlantis, %d", version, len(postgresMigrations))
+	}
+	for i := version; i < len(postgresMigrations); i++ {
+		if _, err := tx.Exec(postgresMigrations[i]); err != nil {
+			return errors.Wrapf(err, "applying migration %d", i+1)
+		}
+		if _, err := tx.Exec(`INSERT INTO `+postgresMigrationsTable+` (version, applied_at) VALUES ($1, $2)`, i+1, p.Now()); err != nil {
+			return errors.Wrapf(err, "recording migration %d", i+1)
+		}
+	}
+	return tx.Commit()
+}
+
+// updatePull sets the pull status at key to what update returns for its
+// current status. If update returns nil, it's left as is. Concurrent updates
+// of the same status are serialized with an advisory lock since there may be
+// no row to lock yet.
+func (p *Postgres) updatePull(key string, update func(*models.PullStatus) *models.PullStatus) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback() // nolint: errcheck
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, p.tenant+"/"+key); err != nil {
+		return errors.Wrapf(err, "locking pull status %q", key)
+	}
+	currStatus, err := p.getPull(tx, key)
+	if err != nil {
+		return err
+	}
+	newStatus := update(currStatus)
+	if newStatus == nil {
+		return nil
+	}
+	serialized, err := json.Marshal(newStatus)
+	if err != nil {
+		return errors.Wrap(err, "serializing")
+	}
+	if _, err := tx.Exec(`INSERT INTO atlantis_pull_statuses (tenant, key, data, updated_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (tenant, key) DO UPDATE SET data = EXCLUDED.data, updated_at = EXCLUDED.updated_at`,
+		p.tenant, key, string(serialized), p.Now()); err != nil {
+		return errors.Wrapf(err, "writing pull status %q", key)
+	}
+	return errors.Wrap(tx.Commit(), "committing transaction")
+}
+
+// updateLease implements leaseUpdater. Like updatePull, concurrent updates
+// are serialized with an advisory lock.
+func (p *Postgres) updateLease(key string, update func(*Lease) (*Lease, bool)) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback() // nolint: errcheck
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, p.tenant+"/lease/"+key); err != nil {
+		return errors.Wrapf(err, "locking lease %q", key)
+	}
+	var curr *Lease
+	var serialized []byte
+	err = tx.QueryRow(`SELECT data FROM atlantis_leases WHERE tenant = $1 AND key = $2`, p.tenant, key).Scan(&serialized)
+	if err != nil && err != sql.ErrNoRows {
+		return errors.Wrapf(err, "querying lease %q", key)
+	}
+	if err == nil {
+		curr = new(Lease)
+		if err := json.Unmarshal(serialized, curr); err != nil {
+			return errors.Wrapf(err, "deserializing lease %q", key)
+		}
+	}
+	next, ok := update(curr)
+	if !ok {
+		return nil
+	}
+	if next == nil {
+		_, err = tx.Exec(`DELETE FROM atlantis_leases WHERE tenant = $1 AND key = $2`, p.tenant, key)
+	} else {
+		serialized, _ = json.Marshal(next)
+		_, err = tx.Exec(`INSERT INTO atlantis_leases (tenant, key, data) VALUES ($1, $2, $3)
+			ON CONFLICT (tenant, key) DO UPDATE SET data = EXCLUDED.data`,
+			p.tenant, key, string(serialized))
+	}
+	if err != nil {
+		return errors.Wrapf(err, "writing lease %q", key)
+	}
+	return errors.Wrap(tx.Commit(), "committing transaction")
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func (p *Postgres) getPull(q queryer, key string) (*models.PullStatus, error) {
+	var serialized []byte
+	err := q.QueryRow(`SELECT data FROM atlantis_pull_statuses WHERE tenant = $1 AND key = $2`, p.tenant, key).Scan(&serialized)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "querying pull status %q", key)
+	}
+	var s models.PullStatus
+	if err := json.Unmarshal(serialized, &s); err != nil {
+		return nil, errors.Wrapf(err, "deserializing pull at %q with contents %q", key, serialized)
+	}
+	return &s, nil
+}
+
+func (p *Postgres) getLock(key string) (*models.ProjectLock, error) {
+	locks, err := p.queryLocks(`SELECT data FROM atlantis_locks WHERE tenant = $1 AND key = $2`, p.tenant, key)
+	if err != nil || len(locks) == 0 {
+		return nil, err
+	}
+	return &locks[0], nil
+}
+
+// queryLocks runs query, which must return the data column of the locks
+// table, and returns the deserialized locks.
+func (p *Postgres) queryLocks(query string, args ...interface{}) ([]models.ProjectLock, error) {
+	rows, err := p.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying locks")
+	}
+	defer rows.Close() // nolint: errcheck
+	var locks []models.ProjectLock
+	for rows.Next() {
+		var serialized []byte
+		if err := rows.Scan(&serialized); err != nil {
+			return nil, errors.Wrap(err, "scanning lock")
+		}
+		var lock models.ProjectLock
+		if err := json.Unmarshal(serialized, &lock); err != nil {
+			return nil, errors.Wrapf(err, "deserializing lock %q", serialized)
+		}
+		locks = append(locks, lock)
+	}
+	return locks, errors.Wrap(rows.Err(), "querying locks")
+}
@@ -0,0 +1,509 @@
+package db
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+const (
+	redisLocksPrefix       = "lock:"
+	redisGlobalLocksPrefix = "command-lock:"
+	redisPullsPrefix       = "pull:"
+	redisLeasesPrefix      = "lease:"
+	// redisMaxTxRetries is how many times a transaction is retried when the
+	// key it's updating is modified by another Atlantis server.
+	redisMaxTxRetries = 10
+)
+
+// RedisConfig configures the connection to Redis.
+type RedisConfig struct {
+	// Addrs are the host:port addresses of the Redis server, of the sentinels
+	// if SentinelMaster is set, or of the cluster nodes if Cluster is set.
+	Addrs    []string
+	Password string
+	// DB is the database to select. It's ignored for clusters.
+	DB int
+	// SentinelMaster is the name of the master to get from the sentinels.
+	SentinelMaster string
+	// Cluster is true if Addrs are the nodes of a Redis Cluster.
+	Cluster bool
+	// PoolSize is the maximum number of connections to each node. If 0, it
+	// defaults to 10 per CPU.
+	PoolSize              int
+	TLSEnabled            bool
+	TLSInsecureSkipVerify bool
+	// KeyPrefix is prepended to every key, ex. so that multiple tenants can
+	// share a database.
+	KeyPrefix string
+}
+
+// Redis is a database using Redis. It lets multiple Atlantis servers share
+// their locks and the statuses of pull requests.
+type Redis struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedis connects to Redis and checks that it's reachable.
+func NewRedis(cfg RedisConfig) (*Redis, error) {
+	if len(cfg.Addrs) == 0 {
+		return nil, errors.New("no Redis addresses configured")
+	}
+	opts := &redis.UniversalOptions{
+		Addrs:      cfg.Addrs,
+		Password:   cfg.Password,
+		DB:         cfg.DB,
+		MasterName: cfg.SentinelMaster,
+		PoolSize:   cfg.PoolSize,
+	}
+	if cfg.TLSEnabled {
+		opts.TLSConfig = &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: cfg.TLSInsecureSkipVerify, // nolint: gosec
+		}
+	}
+	var client redis.UniversalClient
+	if cfg.Cluster {
+		// NewUniversalClient only returns a cluster client for multiple
+		// addresses but a single node is enough to discover the others.
+		client = redis.NewClusterClient(opts.Cluster())
+	} else {
+		client = redis.NewUniversalClient(opts)
+	}
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close() // nolint: errcheck
+		return nil, errors.Wrap(err, "connecting to Redis")
+	}
+	return NewRedisWithClient(client, cfg.KeyPrefix), nil
+}
+
+// NewRedisWithClient is used for testing.
+func NewRedisWithClient(client redis.UniversalClient, prefix string) *Redis {
+	return &Redis{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// TryLock attempts to create a new lock. If the lock is
+// acquired, it will return true and the lock returned will be newLock.
+// If the lock is not acquired, it will return false and the current
+// lock that is preventing this lock from being acquired.
+func (r *Redis) TryLock(newLock models.ProjectLock) (bool, models.ProjectLock, error) {
+	ctx := context.Background()
+	key := r.lockKey(newLock.Project, newLock.Workspace)
+	newLockSerialized, _ := json.Marshal(newLock)
+	for i := 0; i < redisMaxTxRetries; i++ {
+		acquired, err := r.client.SetNX(ctx, key, newLockSerialized, 0).Result()
+		if err != nil {
+			return false, models.ProjectLock{}, errors.Wrap(err, "Redis SETNX failed")
+		}
+		if acquired {
+			return true, newLock, nil
+		}
+		currLock, err := r.getLock(ctx, key)
+		if err != nil {
+			return false, models.ProjectLock{}, err
+		}
+		// If the lock was released since we tried to acquire it, try again.
+		if currLock != nil {
+			return false, *currLock, nil
+		}
+	}
+	return false, models.ProjectLock{}, errors.Errorf("lock %q kept changing while acquiring it", key)
+}
+
+// Unlock attempts to unlock the project and workspace.
+// If there is no lock, then it will return a nil pointer.
+// If there is a lock, then it will delete it, and then return a pointer
+// to the deleted lock.
+func (r *Redis) Unlock(p models.Project, workspace string) (*models.ProjectLock, error) {
+	ctx := context.Background()
+	key := r.lockKey(p, workspace)
+	var get *redis.StringCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Redis transaction failed")
+	}
+	var lock models.ProjectLock
+	if err := json.Unmarshal([]byte(get.Val()), &lock); err != nil {
+		return nil, errors.Wrapf(err, "deserializing lock at key %q", key)
+	}
+	return &lock, nil
+}
+
+// List lists all current locks.
+func (r *Redis) List() ([]models.ProjectLock, error) {
+	var locks []models.ProjectLock
+	err := r.scan(r.prefix+redisLocksPrefix, func(key string, value []byte) error {
+		var lock models.ProjectLock
+		if err := json.Unmarshal(value, &lock); err != nil {
+			return errors.Wrapf(err, "deserializing lock at key %q", key)
+		}
+		locks = append(locks, lock)
+		return nil
+	})
+	return locks, err
+}
+
+// GetLock returns a pointer to the lock for that project and workspace.
+// If there is no lock, it returns a nil pointer.
+func (r *Redis) GetLock(p models.Project, workspace string) (*models.ProjectLock, error) {
+	lock, err := r.getLock(context.Background(), r.lockKey(p, workspace))
+	if err != nil || lock == nil {
+		return nil, err
+	}
+	// need to set it to Local after deserialization due to https://github.com/golang/go/issues/19486
+	lock.Time = lock.Time.Local()
+	return lock, nil
+}
+
+// UnlockByPull deletes all locks associated with that pull request and returns them.
+func (r *Redis) UnlockByPull(repoFullName string, pullNum int) ([]models.ProjectLock, error) {
+	var locks []models.ProjectLock
+	err := r.scan(r.prefix+redisLocksPrefix+repoFullName+"/", func(key string, value []byte) error {
+		var lock models.ProjectLock
+		if err := json.Unmarshal(value, &lock); err != nil {
+			return errors.Wrapf(err, "deserializing lock at key %q", key)
+		}
+		if lock.Pull.Num == pullNum {
+			locks = append(locks, lock)
+		}
+		return nil
+	})
+	if err != nil {
+		return locks, err
+	}
+
+	// delete the locks
+	for _, lock := range locks {
+		if _, err = r.Unlock(lock.Project, lock.Workspace); err != nil {
+			return locks, errors.Wrapf(err, "unlocking repo %s, path %s, workspace %s", lock.Project.RepoFullName, lock.Project.Path, lock.Workspace)
+		}
+	}
+	return locks, nil
+}
+
+// LockCommand attempts to create a new lock for a CommandName.
+// If the lock doesn't exists, it will create a lock and return a pointer to it.
+// If the lock already exists, it will return an "lock already exists" error
+func (r *Redis) LockCommand(cmdName models.CommandName, lockTime time.Time) (*models.CommandLock, error) {
+	lock := models.CommandLock{
+		CommandName: cmdName,
+		LockMetadata: models.LockMetadata{
+			UnixTime: lockTime.Unix(),
+		},
+	}
+	newLockSerialized, _ := json.Marshal(lock)
+	acquired, err := r.client.SetNX(context.Background(), r.commandLockKey(cmdName), newLockSerialized, 0).Result()
+	if err != nil {
+		return nil, errors.Wrap(err, "Redis SETNX failed")
+	}
+	if !acquired {
+		return nil, errors.New("lock already exists")
+	}
+	return &lock, nil
+}
+
+// UnlockCommand removes CommandName lock if present.
+// If there are no lock it returns an error.
+func (r *Redis) UnlockCommand(cmdName models.CommandName) error {
+	deleted, err := r.client.Del(context.Background(), r.commandLockKey(cmdName)).Result()
+	if err != nil {
+		return errors.Wrap(err, "Redis DEL failed")
+	}
+	if deleted == 0 {
+		return errors.New("no lock exists")
+	}
+	return nil
+}
+
+// CheckCommandLock checks if CommandName lock was set.
+// If the lock exists return the pointer to the lock object, otherwise return nil
+func (r *Redis) CheckCommandLock(cmdName models.CommandName) (*models.CommandLock, error) {
+	serialized, err := r.client.Get(context.Background(), r.commandLockKey(cmdName)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Redis GET failed")
+	}
+	var cmdLock models.CommandLock
+	if err := json.Unmarshal(serialized, &cmdLock); err != nil {
+		return nil, errors.Wrap(err, "failed to deserialize command lock")
+	}
+	return &cmdLock, nil
+}
+
+// UpdatePullWithResults updates pull's status with the latest project results.
+// It returns the new PullStatus object.
+func (r *Redis) UpdatePullWithResults(pull models.PullRequest, newResults []models.ProjectResult) (models.PullStatus, error) {
+	key, err := r.pullKey(pull)
+	if err != nil {
+		return models.PullStatus{}, err
+	}
+	var newStatus models.PullStatus
+	err = r.updatePull(key, func(currStatus *models.PullStatus) *models.PullStatus {
+		newStatus = mergePullStatus(currStatus, pull, newResults)
+		return &newStatus
+	})
+	return newStatus, err
+}
+
+// GetPullStatus returns the status for pull.
+// If there is no status, returns a nil pointer.
+func (r *Redis) GetPullStatus(pull models.PullRequest) (*models.PullStatus, error) {
+	key, err := r.pullKey(pull)
+	if err != nil {
+		return nil, err
+	}
+	return r.getPull(context.Background(), r.client, key)
+}
+
+// GetPullStatuses returns the statuses of all the pull requests we know
+// about. Statuses are deleted when pull requests are closed so these are all
+// open.
+func (r *Redis) GetPullStatuses() ([]models.PullStatus, error) {
+	var statuses []models.PullStatus
+	err := r.scan(r.prefix+redisPullsPrefix, func(key string, value []byte) error {
+		var s models.PullStatus
+		if err := json.Unmarshal(value, &s); err != nil {
+			return errors.Wrapf(err, "deserializing pull at %q with contents %q", key, value)
+		}
+		statuses = append(statuses, s)
+		return nil
+	})
+	return statuses, err
+}
+
+// DeletePullStatus deletes the status for pull.
+func (r *Redis) DeletePullStatus(pull models.PullRequest) error {
+	key, err := r.pullKey(pull)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(r.client.Del(context.Background(), key).Err(), "Redis DEL failed")
+}
+
+// UpdateProjectStatus updates project status.
+func (r *Redis) UpdateProjectStatus(pull models.PullRequest, workspace string, repoRelDir string, newStatus models.ProjectPlanStatus) error {
+	key, err := r.pullKey(pull)
+	if err != nil {
+		return err
+	}
+	return r.updatePull(key, func(currStatus *models.PullStatus) *models.PullStatus {
+		if currStatus == nil {
+			return nil
+		}
+		setProjectStatus(currStatus, workspace, repoRelDir, newStatus)
+		return currStatus
+	})
+}
+
+// putPullStatus replaces the status of status.Pull, ex. to migrate it from
+// another database.
+func (r *Redis) putPullStatus(status models.PullStatus) error {
+	key, err := r.pullKey(status.Pull)
+	if err != nil {
+		return err
+	}
+	return r.updatePull(key, func(*models.PullStatus) *models.PullStatus {
+		return &status
+	})
+}
+
+// TryAcquireLease acquires lease if it's free, expired at now or already
+// held by lease.Holder. See LeaseStore.
+func (r *Redis) TryAcquireLease(lease Lease, now time.Time) (bool, *Lease, error) {
+	return tryAcquireLease(r, lease, now)
+}
+
+// RenewLease extends the lease of key held by holder until expiresAt.
+func (r *Redis) RenewLease(key string, holder string, expiresAt time.Time) (bool, error) {
+	return renewLease(r, key, holder, expiresAt)
+}
+
+// ReleaseLease deletes the lease of key if it's held by holder.
+func (r *Redis) ReleaseLease(key string, holder string) error {
+	return releaseLease(r, key, holder)
+}
+
+// Close closes the connections to Redis.
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
+
+// updatePull sets the pull status at key to what update returns for its
+// current status. If update returns nil, it's left as is. The update is
+// retried if another Atlantis server modifies the status concurrently.
+func (r *Redis) updatePull(key string, update func(*models.PullStatus) *models.PullStatus) error {
+	ctx := context.Background()
+	txf := func(tx *redis.Tx) error {
+		currStatus, err := r.getPull(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+		newStatus := update(currStatus)
+		if newStatus == nil {
+			return nil
+		}
+		serialized, err := json.Marshal(newStatus)
+		if err != nil {
+			return errors.Wrap(err, "serializing")
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, serialized, 0)
+			return nil
+		})
+		return err
+	}
+	for i := 0; i < redisMaxTxRetries; i++ {
+		err := r.client.Watch(ctx, txf, key)
+		if err != redis.TxFailedErr {
+			return errors.Wrap(err, "Redis transaction failed")
+		}
+	}
+	return errors.Errorf("pull status %q kept changing while updating it", key)
+}
+
+// updateLease implements leaseUpdater. Like updatePull, the update is
+// retried if another Atlantis server modifies the lease concurrently.
+func (r *Redis) updateLease(key string, update func(*Lease) (*Lease, bool)) error {
+	ctx := context.Background()
+	redisKey := r.prefix + redisLeasesPrefix + key
+	txf := func(tx *redis.Tx) error {
+		var curr *Lease
+		serialized, err := tx.Get(ctx, redisKey).Bytes()
+		if err != nil && err != redis.Nil {
+			return errors.Wrap(err, "Redis GET failed")
+		}
+		if err == nil {
+			curr = new(Lease)
+			if err := json.Unmarshal(serialized, curr); err != nil {
+				return errors.Wrapf(err, "deserializing lease at %q", redisKey)
+			}
+		}
+		next, ok := update(curr)
+		if !ok {
+			return nil
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if next == nil {
+				pipe.Del(ctx, redisKey)
+				return nil
+			}
+			serialized, _ := json.Marshal(next)
+			pipe.Set(ctx, redisKey, serialized, 0)
+			return nil
+		})
+		return err
+	}
+	for i := 0; i < redisMaxTxRetries; i++ {
+		err := r.client.Watch(ctx, txf, redisKey)
+		if err != redis.TxFailedErr {
+			return errors.Wrap(err, "Redis transaction failed")
+		}
+	}
+	return errors.Errorf("lease %q kept changing while updating it", key)
+}
+
+func (r *Redis) getPull(ctx context.Context, c redis.Cmdable, key string) (*models.PullStatus, error) {
+	serialized, err := c.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Redis GET failed")
+	}
+	var p models.PullStatus
+	if err := json.Unmarshal(serialized, &p); err != nil {
+		return nil, errors.Wrapf(err, "deserializing pull at %q with contents %q", key, serialized)
+	}
+	return &p, nil
+}
+
+func (r *Redis) getLock(ctx context.Context, key string) (*models.ProjectLock, error) {
+	serialized, err := r.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Redis GET failed")
+	}
+	var lock models.ProjectLock
+	if err := json.Unmarshal(serialized, &lock); err != nil {
+		return nil, errors.Wrapf(err, "deserializing lock at key %q", key)
+	}
+	return &lock, nil
+}
+
+// scan calls fn with each key starting with prefix and its value. Keys
+// deleted during the scan are skipped.
+func (r *Redis) scan(prefix string, fn func(key string, value []byte) error) error {
+	ctx := context.Background()
+	scanNode := func(ctx context.Context, c redis.Cmdable) error {
+		iter := c.Scan(ctx, 0, escapeRedisPattern(prefix)+"*", 100).Iterator()
+		for iter.Next(ctx) {
+			value, err := c.Get(ctx, iter.Val()).Bytes()
+			if err == redis.Nil {
+				continue
+			}
+			if err != nil {
+				return errors.Wrap(err, "Redis GET failed")
+			}
+			if err := fn(iter.Val(), value); err != nil {
+				return err
+			}
+		}
+		return errors.Wrap(iter.Err(), "Redis SCAN failed")
+	}
+	// A cluster's keys are spread across its masters so each has to be
+	// scanned. They're scanned concurrently so fn is serialized.
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		var mutex sync.Mutex
+		unsafeFn := fn
+		fn = func(key string, value []byte) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return unsafeFn(key, value)
+		}
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, c *redis.Client) error {
+			return scanNode(ctx, c)
+		})
+	}
+	return scanNode(ctx, r.client)
+}
+
+func (r *Redis) lockKey(p models.Project, workspace string) string {
+	return r.prefix + redisLocksPrefix + lockKey(p, workspace)
+}
+
+func (r *Redis) commandLockKey(cmdName models.CommandName) string {
+	return r.prefix + redisGlobalLocksPrefix + commandLockKey(cmdName)
+}
+
+func (r *Redis) pullKey(pull models.PullRequest) (string, error) {
+	key, err := pullKey(pull)
+	return r.prefix + redisPullsPrefix + key, err
+}
+
+// escapeRedisPattern escapes the characters that have a special meaning in
+// the patterns of SCAN's MATCH.
+func escapeRedisPattern(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`).Replace(s)
+}
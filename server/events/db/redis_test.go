@@ -0,0 +1,177 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/db"
+	"github.com/runatlantis/atlantis/server/events/models"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestRedis_Locks(t *testing.T) {
+	r, _ := newTestRedis(t, db.DefaultKeyPrefix)
+
+	acquired, currLock, err := r.TryLock(lock)
+	Ok(t, err)
+	Equals(t, true, acquired)
+	Equals(t, lock, currLock)
+
+	// A lock for the same project and workspace isn't acquired.
+	newLock := lock
+	newLock.Pull.Num = 2
+	acquired, currLock, err = r.TryLock(newLock)
+	Ok(t, err)
+	Equals(t, false, acquired)
+	Equals(t, pullNum, currLock.Pull.Num)
+
+	// Another workspace can be locked.
+	otherLock := lock
+	otherLock.Workspace = "staging"
+	acquired, _, err = r.TryLock(otherLock)
+	Ok(t, err)
+	Equals(t, true, acquired)
+
+	got, err := r.GetLock(project, workspace)
+	Ok(t, err)
+	Equals(t, lock.User, got.User)
+	locks, err := r.List()
+	Ok(t, err)
+	Equals(t, 2, len(locks))
+
+	unlocked, err := r.Unlock(project, workspace)
+	Ok(t, err)
+	Equals(t, workspace, unlocked.Workspace)
+	unlocked, err = r.Unlock(project, workspace)
+	Ok(t, err)
+	Assert(t, unlocked == nil, "exp nil when there's no lock")
+	got, err = r.GetLock(project, workspace)
+	Ok(t, err)
+	Assert(t, got == nil, "exp nil when there's no lock")
+
+	// Only the locks of the pull request are deleted.
+	otherPull := lock
+	otherPull.Pull.Num = 2
+	otherPull.Project = models.NewProject("owner/repo", "other")
+	_, _, err = r.TryLock(otherPull)
+	Ok(t, err)
+	deleted, err := r.UnlockByPull("owner/repo", pullNum)
+	Ok(t, err)
+	Equals(t, 1, len(deleted))
+	Equals(t, "staging", deleted[0].Workspace)
+	locks, err = r.List()
+	Ok(t, err)
+	Equals(t, 1, len(locks))
+	Equals(t, 2, locks[0].Pull.Num)
+}
+
+func TestRedis_CommandLocks(t *testing.T) {
+	r, _ := newTestRedis(t, db.DefaultKeyPrefix)
+
+	cmdLock, err := r.CheckCommandLock(models.ApplyCommand)
+	Ok(t, err)
+	Assert(t, cmdLock == nil, "exp nil")
+	ErrEquals(t, "no lock exists", r.UnlockCommand(models.ApplyCommand))
+
+	lockTime := time.Now()
+	_, err = r.LockCommand(models.ApplyCommand, lockTime)
+	Ok(t, err)
+	_, err = r.LockCommand(models.ApplyCommand, lockTime)
+	ErrEquals(t, "lock already exists", err)
+	cmdLock, err = r.CheckCommandLock(models.ApplyCommand)
+	Ok(t, err)
+	Equals(t, lockTime.Unix(), cmdLock.LockMetadata.UnixTime)
+
+	Ok(t, r.UnlockCommand(models.ApplyCommand))
+	cmdLock, err = r.CheckCommandLock(models.ApplyCommand)
+	Ok(t, err)
+	Assert(t, cmdLock == nil, "exp nil")
+}
+
+func TestRedis_PullStatus(t *testing.T) {
+	r, _ := newTestRedis(t, db.DefaultKeyPrefix)
+	repo, err := models.NewRepo(models.Github, "runatlantis/atlantis", "https://github.com/runatlantis/atlantis.git", "", "")
+	Ok(t, err)
+	pull := models.PullRequest{
+		Num:        1,
+		HeadCommit: "sha",
+		BaseRepo:   repo,
+		State:      models.OpenPullState,
+	}
+
+	status, err := r.GetPullStatus(pull)
+	Ok(t, err)
+	Assert(t, status == nil, "exp nil")
+	// Updating the project of a pull without a status is a no-op.
+	Ok(t, r.UpdateProjectStatus(pull, "default", ".", models.DiscardedPlanStatus))
+
+	_, err = r.UpdatePullWithResults(pull, []models.ProjectResult{
+		{Command: models.PlanCommand, RepoRelDir: ".", Workspace: "default", PlanSuccess: &models.PlanSuccess{TerraformOutput: "tf"}},
+		{Command: models.PlanCommand, RepoRelDir: "staging", Workspace: "default", Error: errors.New("err")},
+	})
+	Ok(t, err)
+	// Results for the same commit are merged.
+	newStatus, err := r.UpdatePullWithResults(pull, []models.ProjectResult{
+		{Command: models.PlanCommand, RepoRelDir: "staging", Workspace: "default", PlanSuccess: &models.PlanSuccess{TerraformOutput: "tf"}},
+	})
+	Ok(t, err)
+	Equals(t, 2, len(newStatus.Projects))
+	Equals(t, models.PlannedPlanStatus, newStatus.Projects[1].Status)
+
+	Ok(t, r.UpdateProjectStatus(pull, "default", ".", models.DiscardedPlanStatus))
+	status, err = r.GetPullStatus(pull)
+	Ok(t, err)
+	Equals(t, models.DiscardedPlanStatus, status.Projects[0].Status)
+	Equals(t, models.PlannedPlanStatus, status.Projects[1].Status)
+
+	statuses, err := r.GetPullStatuses()
+	Ok(t, err)
+	Equals(t, 1, len(statuses))
+	Equals(t, pull.Num, statuses[0].Pull.Num)
+
+	Ok(t, r.DeletePullStatus(pull))
+	status, err = r.GetPullStatus(pull)
+	Ok(t, err)
+	Assert(t, status == nil, "exp nil")
+}
+
+func TestRedis_KeyPrefix(t *testing.T) {
+	r, s := newTestRedis(t, "atlantis:tenants:acme:")
+	_, _, err := r.TryLock(lock)
+	Ok(t, err)
+	Equals(t, []string{"atlantis:tenants:acme:lock:owner/repo/parent/child/default"}, s.Keys())
+
+	// Locks with another prefix aren't listed.
+	other := db.NewRedisWithClient(redisClient(s), "atlantis:")
+	locks, err := other.List()
+	Ok(t, err)
+	Equals(t, 0, len(locks))
+}
+
+func TestNewRedis(t *testing.T) {
+	s, err := miniredis.Run()
+	Ok(t, err)
+	defer s.Close()
+	addr := s.Addr()
+	r, err := db.NewRedis(db.RedisConfig{Addrs: []string{addr}, KeyPrefix: db.DefaultKeyPrefix})
+	Ok(t, err)
+	defer r.Close() // nolint: errcheck
+
+	s.Close()
+	_, err = db.NewRedis(db.RedisConfig{Addrs: []string{addr}})
+	Assert(t, err != nil, "exp error connecting to a closed server")
+}
+
+func newTestRedis(t *testing.T, prefix string) (*db.Redis, *miniredis.Miniredis) {
+	s, err := miniredis.Run()
+	Ok(t, err)
+	t.Cleanup(s.Close)
+	return db.NewRedisWithClient(redisClient(s), prefix), s
+}
+
+func redisClient(s *miniredis.Miniredis) redis.UniversalClient {
+	return redis.NewClient(&redis.Options{Addr: s.Addr()})
+}
@@ -6,7 +6,7 @@ import (
 )
 
 type DBUpdater struct {
-	DB *db.BoltDB
+	DB db.Database
 }
 
 func (c *DBUpdater) updateDB(ctx *CommandContext, pull models.PullRequest, results []models.ProjectResult) (models.PullStatus, error) {
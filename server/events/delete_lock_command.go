@@ -1,6 +1,7 @@
 package events
 
 import (
+	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/events/db"
 	"github.com/runatlantis/atlantis/server/events/locking"
 	"github.com/runatlantis/atlantis/server/events/models"
@@ -13,6 +14,11 @@ import (
 type DeleteLockCommand interface {
 	DeleteLock(id string) (*models.ProjectLock, error)
 	DeleteLocksByPull(repoFullName string, pullNum int) (int, error)
+	// DeleteIdleLock deletes the lock at id and its plan like DeleteLock
+	// unless a command is running in its working dir, in which case the
+	// lock is kept and an error is returned. If the lock was released but
+	// its plan couldn't be deleted, both the lock and an error are returned.
+	DeleteIdleLock(id string) (*models.ProjectLock, error)
 }
 
 // DefaultDeleteLockCommand deletes a specific lock after a request from the LocksController.
@@ -21,7 +27,7 @@ type DefaultDeleteLockCommand struct {
 	Logger           logging.SimpleLogging
 	WorkingDir       WorkingDir
 	WorkingDirLocker WorkingDirLocker
-	DB               *db.BoltDB
+	DB               db.Database
 }
 
 // DeleteLock handles deleting the lock at id
@@ -38,6 +44,43 @@ func (l *DefaultDeleteLockCommand) DeleteLock(id string) (*models.ProjectLock, e
 	return lock, nil
 }
 
+// See DeleteLockCommand.DeleteIdleLock.
+func (l *DefaultDeleteLockCommand) DeleteIdleLock(id string) (*models.ProjectLock, error) {
+	lock, err := l.Locker.GetLock(id)
+	if err != nil {
+		return nil, err
+	}
+	if lock == nil {
+		return nil, nil
+	}
+	// Locks without a BaseRepo have no working dir to lock or delete.
+	if lock.Pull.BaseRepo == (models.Repo{}) {
+		return l.DeleteLock(id)
+	}
+	// Commands hold the working dir lock while they run so holding it until
+	// the plan is deleted ensures none is interrupted.
+	unlock, err := l.WorkingDirLocker.TryLock(lock.Pull.BaseRepo.FullName, lock.Pull.Num, lock.Workspace)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	lock, err = l.Locker.Unlock(id)
+	if err != nil {
+		return nil, err
+	}
+	if lock == nil {
+		return nil, nil
+	}
+	if err := l.WorkingDir.DeleteForWorkspace(lock.Pull.BaseRepo, lock.Pull, lock.Workspace); err != nil {
+		return lock, errors.Wrap(err, "deleting plan")
+	}
+	if err := l.DB.UpdateProjectStatus(lock.Pull, lock.Workspace, lock.Project.Path, models.DiscardedPlanStatus); err != nil {
+		l.Logger.Err("unable to delete project status: %s", err)
+	}
+	return lock, nil
+}
+
 // DeleteLocksByPull handles deleting all locks for the pull request
 func (l *DefaultDeleteLockCommand) DeleteLocksByPull(repoFullName string, pullNum int) (int, error) {
 	locks, err := l.Locker.UnlockByPull(repoFullName, pullNum)
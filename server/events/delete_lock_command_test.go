@@ -89,6 +89,51 @@ func TestDeleteLock_Success(t *testing.T) {
 	workingDir.VerifyWasCalledOnce().DeleteForWorkspace(pull.BaseRepo, pull, "workspace")
 }
 
+func TestDeleteIdleLock_CommandRunning(t *testing.T) {
+	t.Log("If a command is running in the lock's working dir, the lock is kept")
+	RegisterMockTestingT(t)
+	l := lockmocks.NewMockLocker()
+	pull := models.PullRequest{Num: 1, BaseRepo: models.Repo{FullName: "owner/repo"}}
+	When(l.GetLock("id")).ThenReturn(&models.ProjectLock{Pull: pull, Workspace: "workspace"}, nil)
+	workingDir := events.NewMockWorkingDir()
+	workingDirLocker := events.NewDefaultWorkingDirLocker()
+	unlock, err := workingDirLocker.TryLock("owner/repo", 1, "workspace")
+	Ok(t, err)
+	defer unlock()
+	dlc := events.DefaultDeleteLockCommand{
+		Locker:           l,
+		Logger:           logging.NewNoopLogger(t),
+		WorkingDirLocker: workingDirLocker,
+		WorkingDir:       workingDir,
+	}
+	lock, err := dlc.DeleteIdleLock("id")
+	ErrContains(t, "currently locked", err)
+	Assert(t, lock == nil, "lock was not nil")
+	l.VerifyWasCalled(Never()).Unlock(AnyString())
+	workingDir.VerifyWasCalled(Never()).DeleteForWorkspace(pull.BaseRepo, pull, "workspace")
+}
+
+func TestDeleteIdleLock_DeleteErr(t *testing.T) {
+	t.Log("If the plan can't be deleted, the released lock and the error are returned")
+	RegisterMockTestingT(t)
+	l := lockmocks.NewMockLocker()
+	pull := models.PullRequest{Num: 1, BaseRepo: models.Repo{FullName: "owner/repo"}}
+	projectLock := &models.ProjectLock{Pull: pull, Workspace: "workspace"}
+	When(l.GetLock("id")).ThenReturn(projectLock, nil)
+	When(l.Unlock("id")).ThenReturn(projectLock, nil)
+	workingDir := events.NewMockWorkingDir()
+	When(workingDir.DeleteForWorkspace(pull.BaseRepo, pull, "workspace")).ThenReturn(errors.New("permission denied"))
+	dlc := events.DefaultDeleteLockCommand{
+		Locker:           l,
+		Logger:           logging.NewNoopLogger(t),
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+		WorkingDir:       workingDir,
+	}
+	lock, err := dlc.DeleteIdleLock("id")
+	ErrEquals(t, "deleting plan: permission denied", err)
+	Assert(t, lock != nil, "lock was nil")
+}
+
 func TestDeleteLocksByPull_LockerErr(t *testing.T) {
 	t.Log("If there is an error retrieving the lock, returned a failed status")
 	repoName := "reponame"
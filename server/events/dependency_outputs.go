@@ -0,0 +1,108 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	version "github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+)
+
+// DependencyOutputs reads the outputs of the projects that a project depends
+// on through depends_on so they can be used as its input variables.
+type DependencyOutputs struct {
+	TerraformExecutor runtime.TerraformExec
+	DefaultTFVersion  *version.Version
+	WorkingDir        WorkingDir
+}
+
+// tfOutput is an output in the output of terraform output -json.
+type tfOutput struct {
+	Sensitive bool            `json:"sensitive"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// Env returns the outputs of the dependencies of ctx as TF_VAR_ environment
+// variables, ex. TF_VAR_vpc_id for the output vpc_id, along with the values
+// of sensitive outputs so they can be masked. If two dependencies have an
+// output with the same name, the one listed last wins. envs are the
+// environment variables used to run terraform, ex. cloud credentials.
+func (d *DependencyOutputs) Env(ctx models.ProjectCommandContext, envs map[string]string) (map[string]string, []string, error) {
+	vars := make(map[string]string)
+	var sensitive []string
+	for _, dep := range ctx.DependsOn {
+		outputs, err := d.outputs(ctx, dep, envs)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "reading outputs of project %q", dep.Name)
+		}
+		for name, output := range outputs {
+			value, err := outputValue(output.Value)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "parsing output %q of project %q", name, dep.Name)
+			}
+			vars["TF_VAR_"+name] = value
+			if output.Sensitive && value != "" {
+				sensitive = append(sensitive, value)
+			}
+		}
+	}
+	return vars, sensitive, nil
+}
+
+// outputs runs terraform output for the dependency dep of ctx, initializing
+// it first if it hasn't been planned in this pull request.
+func (d *DependencyOutputs) outputs(ctx models.ProjectCommandContext, dep valid.ProjectDependency, envs map[string]string) (map[string]tfOutput, error) {
+	// The dependency is in the clone for its workspace if it was planned
+	// but the pull request's files are the same in every clone.
+	repoDir, err := d.WorkingDir.GetWorkingDir(ctx.Pull.BaseRepo, ctx.Pull, dep.Workspace)
+	if os.IsNotExist(err) {
+		repoDir, err = d.WorkingDir.GetWorkingDir(ctx.Pull.BaseRepo, ctx.Pull, ctx.Workspace)
+	}
+	if err != nil {
+		return nil, err
+	}
+	absPath := filepath.Join(repoDir, dep.RepoRelDir)
+	if _, err := os.Stat(absPath); os.IsNotExist(err) {
+		return nil, DirNotExistErr{RepoRelDir: dep.RepoRelDir}
+	}
+
+	tfVersion := d.DefaultTFVersion
+	if dep.TerraformVersion != nil {
+		tfVersion = dep.TerraformVersion
+	}
+	if _, err := os.Stat(filepath.Join(absPath, ".terraform")); os.IsNotExist(err) {
+		if out, err := d.TerraformExecutor.RunCommandWithVersion(ctx.Log, absPath, []string{"init", "-input=false", "-no-color"}, envs, tfVersion, dep.Workspace); err != nil {
+			return nil, fmt.Errorf("%s: %s", err, out)
+		}
+	}
+	out, err := d.TerraformExecutor.RunCommandWithVersion(ctx.Log, absPath, []string{"output", "-json"}, envs, tfVersion, dep.Workspace)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", err, out)
+	}
+	var outputs map[string]tfOutput
+	if err := json.Unmarshal([]byte(out), &outputs); err != nil {
+		return nil, errors.Wrap(err, "parsing terraform output")
+	}
+	return outputs, nil
+}
+
+// outputValue returns the value of an output as Terraform expects it in a
+// TF_VAR_ environment variable: strings as is and other values as JSON,
+// which Terraform parses for lists, maps and objects.
+func outputValue(value json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return s, nil
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, value); err != nil {
+		return "", err
+	}
+	return compact.String(), nil
+}
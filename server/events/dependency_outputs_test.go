@@ -0,0 +1,113 @@
+package events_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	version "github.com/hashicorp/go-version"
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/models/fixtures"
+	tfmocks "github.com/runatlantis/atlantis/server/events/terraform/mocks"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestDependencyOutputs_Env(t *testing.T) {
+	RegisterMockTestingT(t)
+	repoDir, cleanup := TempDir(t)
+	defer cleanup()
+	// The network project was already initialized.
+	Ok(t, os.MkdirAll(filepath.Join(repoDir, "network", ".terraform"), 0700))
+
+	tf := tfmocks.NewMockClient()
+	workingDir := mocks.NewMockWorkingDir()
+	tfVersion := version.Must(version.NewVersion("1.0.0"))
+	dependencyOutputs := &events.DependencyOutputs{
+		TerraformExecutor: tf,
+		DefaultTFVersion:  tfVersion,
+		WorkingDir:        workingDir,
+	}
+
+	logger := logging.NewNoopLogger(t)
+	ctx := models.ProjectCommandContext{
+		Log:        logger,
+		Pull:       fixtures.Pull,
+		RepoRelDir: "app",
+		Workspace:  "default",
+		DependsOn: []valid.ProjectDependency{
+			{Name: "network", RepoRelDir: "network", Workspace: "default"},
+		},
+	}
+	envs := map[string]string{"AWS_ACCESS_KEY_ID": "key-id"}
+	When(workingDir.GetWorkingDir(fixtures.Pull.BaseRepo, fixtures.Pull, "default")).ThenReturn(repoDir, nil)
+	When(tf.RunCommandWithVersion(logger, filepath.Join(repoDir, "network"), []string{"output", "-json"}, envs, tfVersion, "default")).
+		ThenReturn(`{
+  "vpc_id": {"sensitive": false, "type": "string", "value": "vpc-123"},
+  "subnet_ids": {"sensitive": false, "type": ["list", "string"], "value": ["subnet-1", "subnet-2"]},
+  "db_password": {"sensitive": true, "type": "string", "value": "hunter2"}
+}`, nil)
+
+	vars, sensitive, err := dependencyOutputs.Env(ctx, envs)
+	Ok(t, err)
+	Equals(t, map[string]string{
+		"TF_VAR_vpc_id":      "vpc-123",
+		"TF_VAR_subnet_ids":  `["subnet-1","subnet-2"]`,
+		"TF_VAR_db_password": "hunter2",
+	}, vars)
+	Equals(t, []string{"hunter2"}, sensitive)
+	tf.VerifyWasCalled(Never()).RunCommandWithVersion(logger, filepath.Join(repoDir, "network"), []string{"init", "-input=false", "-no-color"}, envs, tfVersion, "default")
+}
+
+func TestDependencyOutputs_EnvInitsDependency(t *testing.T) {
+	RegisterMockTestingT(t)
+	repoDir, cleanup := TempDir(t)
+	defer cleanup()
+	Ok(t, os.Mkdir(filepath.Join(repoDir, "network"), 0700))
+	absPath := filepath.Join(repoDir, "network")
+
+	tf := tfmocks.NewMockClient()
+	workingDir := mocks.NewMockWorkingDir()
+	defaultVersion := version.Must(version.NewVersion("1.0.0"))
+	networkVersion := version.Must(version.NewVersion("0.14.0"))
+	dependencyOutputs := &events.DependencyOutputs{
+		TerraformExecutor: tf,
+		DefaultTFVersion:  defaultVersion,
+		WorkingDir:        workingDir,
+	}
+
+	logger := logging.NewNoopLogger(t)
+	ctx := models.ProjectCommandContext{
+		Log:        logger,
+		Pull:       fixtures.Pull,
+		RepoRelDir: "app",
+		Workspace:  "default",
+		DependsOn: []valid.ProjectDependency{
+			{Name: "network", RepoRelDir: "network", Workspace: "staging", TerraformVersion: networkVersion},
+		},
+	}
+	// The staging workspace wasn't cloned since network wasn't planned so
+	// the clone of app is used.
+	When(workingDir.GetWorkingDir(fixtures.Pull.BaseRepo, fixtures.Pull, "staging")).ThenReturn("", os.ErrNotExist)
+	When(workingDir.GetWorkingDir(fixtures.Pull.BaseRepo, fixtures.Pull, "default")).ThenReturn(repoDir, nil)
+	When(tf.RunCommandWithVersion(logger, absPath, []string{"init", "-input=false", "-no-color"}, map[string]string(nil), networkVersion, "staging")).
+		ThenReturn("", nil)
+	When(tf.RunCommandWithVersion(logger, absPath, []string{"output", "-json"}, map[string]string(nil), networkVersion, "staging")).
+		ThenReturn(`{"cluster":{"sensitive":false,"value":{"name":"main","nodes":3}}}`, nil)
+
+	vars, sensitive, err := dependencyOutputs.Env(ctx, nil)
+	Ok(t, err)
+	Equals(t, map[string]string{"TF_VAR_cluster": `{"name":"main","nodes":3}`}, vars)
+	Equals(t, 0, len(sensitive))
+
+	// Errors reading the outputs are returned.
+	When(tf.RunCommandWithVersion(logger, absPath, []string{"output", "-json"}, map[string]string(nil), networkVersion, "staging")).
+		ThenReturn("No state.", errors.New("exit status 1"))
+	_, _, err = dependencyOutputs.Env(ctx, nil)
+	ErrEquals(t, "reading outputs of project \"network\": exit status 1: No state.", err)
+}
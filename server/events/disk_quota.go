@@ -0,0 +1,280 @@
+package events
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/db"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// cleanedPullsPrefix is the dir, relative to the data dir, where the pull
+// requests whose working dirs were deleted to free disk space are recorded.
+const cleanedPullsPrefix = "cleaned-pulls"
+
+// CleanedPullComment is posted when a command is run for a pull request whose
+// working dir was deleted to free disk space.
+var CleanedPullComment = "The working directory of this pull request was deleted to free disk space because it hadn't been used recently, so its plans were discarded." +
+	" Run `atlantis plan` to plan it again."
+
+// DiskUsage is the disk usage of the data dir when the quota was last
+// enforced.
+type DiskUsage struct {
+	Time       time.Time `json:"time"`
+	QuotaBytes int64     `json:"quota_bytes"`
+	UsedBytes  int64     `json:"used_bytes"`
+	// Repos is the disk usage of the working dirs of each repo's pull
+	// requests and Pulls of each pull request, ex. owner/repo#1.
+	Repos map[string]int64 `json:"repos"`
+	Pulls map[string]int64 `json:"pulls"`
+	// CleanedPulls is the number of pull requests whose working dirs were
+	// deleted to free disk space since Atlantis started.
+	CleanedPulls int64 `json:"cleaned_pulls"`
+}
+
+// DiskQuota keeps the data dir under a quota by deleting the working dirs of
+// the least recently used pull requests once it's exceeded. The working dirs
+// of pull requests that are running a command are never deleted. Pull
+// requests are found through their statuses, which they have for as long as
+// they have a working dir, and were last used when their last command
+// started. When a command is run for a pull request whose working dir was
+// deleted, the pull request gets a comment that it has to be planned again.
+// A nil *DiskQuota does nothing.
+type DiskQuota struct {
+	DataDir          string
+	QuotaBytes       int64
+	DB               db.Database
+	WorkingDir       WorkingDir
+	WorkingDirLocker WorkingDirLocker
+	VCSClient        vcs.Client
+	Logger           logging.SimpleLogging
+	// Interval is how often the quota is enforced by Run.
+	Interval time.Duration
+	// Now returns the current time. It's only overridden in tests.
+	Now func() time.Time
+
+	// mutex ensures the quota is only enforced by one goroutine at a time
+	// and guards last and cleaned.
+	mutex   sync.Mutex
+	last    *DiskUsage
+	cleaned int64
+}
+
+// pullDiskUsage is the disk usage of the working dir of a pull request.
+type pullDiskUsage struct {
+	pull     models.PullRequest
+	bytes    int64
+	lastUsed time.Time
+}
+
+// Run enforces the quota every Interval until ctx is done.
+func (q *DiskQuota) Run(ctx context.Context) {
+	ticker := time.NewTicker(q.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := q.Enforce(); err != nil {
+				q.Logger.Err("failed enforcing data dir quota: %s", err)
+			}
+		}
+	}
+}
+
+// Enforce measures the disk usage of the data dir and, if it's over the
+// quota, deletes the working dirs of the least recently used pull requests
+// until it isn't. It returns the usage after they were deleted.
+func (q *DiskQuota) Enforce() (DiskUsage, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	usage := DiskUsage{
+		Time:       q.Now(),
+		QuotaBytes: q.QuotaBytes,
+		Repos:      make(map[string]int64),
+		Pulls:      make(map[string]int64),
+	}
+	used, err := dirSize(q.DataDir)
+	if err != nil {
+		return usage, err
+	}
+	usage.UsedBytes = used
+	statuses, err := q.DB.GetPullStatuses()
+	if err != nil {
+		return usage, err
+	}
+	q.pruneCleanedMarkers(statuses)
+	var pulls []pullDiskUsage
+	for _, status := range statuses {
+		p, ok := q.pullUsage(status)
+		if !ok {
+			continue
+		}
+		pulls = append(pulls, p)
+		usage.Repos[p.pull.BaseRepo.FullName] += p.bytes
+		usage.Pulls[orphanPullKey(p.pull)] = p.bytes
+	}
+
+	sort.Slice(pulls, func(i, j int) bool { return pulls[i].lastUsed.Before(pulls[j].lastUsed) })
+	for _, p := range pulls {
+		if usage.UsedBytes <= q.QuotaBytes {
+			break
+		}
+		unlock, err := q.WorkingDirLocker.TryLockPull(p.pull.BaseRepo.FullName, p.pull.Num)
+		if err != nil {
+			q.Logger.Debug("not deleting working dir of pull request %s since a command is running for it", orphanPullKey(p.pull))
+			continue
+		}
+		err = q.clean(p.pull)
+		unlock()
+		if err != nil {
+			q.Logger.Warn("failed deleting working dir of pull request %s to free disk space: %s", orphanPullKey(p.pull), err)
+			continue
+		}
+		q.Logger.Info("deleted working dir of pull request %s, last used at %s, to free %d bytes of disk space", orphanPullKey(p.pull), p.lastUsed.Format(time.RFC3339), p.bytes)
+		q.cleaned++
+		usage.UsedBytes -= p.bytes
+		usage.Repos[p.pull.BaseRepo.FullName] -= p.bytes
+		delete(usage.Pulls, orphanPullKey(p.pull))
+	}
+	if usage.UsedBytes > q.QuotaBytes {
+		q.Logger.Warn("data dir uses %d bytes, which is over its quota of %d bytes, after deleting the working dirs of all inactive pull requests", usage.UsedBytes, q.QuotaBytes)
+	}
+	usage.CleanedPulls = q.cleaned
+	q.last = &usage
+	return usage, nil
+}
+
+// Usage returns the disk usage of the data dir when the quota was last
+// enforced, or nil if it hasn't been yet.
+func (q *DiskQuota) Usage() *DiskUsage {
+	if q == nil {
+		return nil
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.last
+}
+
+// Revisit is called before command is run for the pull request of ctx. If
+// the pull request's working dir was deleted to free disk space, it comments
+// that it has to be planned again, unless command is a plan.
+func (q *DiskQuota) Revisit(ctx *CommandContext, command models.CommandName) {
+	if q == nil {
+		return
+	}
+	marker := q.cleanedMarker(ctx.Pull)
+	if _, err := os.Stat(marker); err != nil {
+		return
+	}
+	if err := os.Remove(marker); err != nil {
+		ctx.Log.Warn("unable to remove record that working dir was deleted: %s", err)
+	}
+	if command == models.PlanCommand {
+		return
+	}
+	if err := q.VCSClient.CreateComment(ctx.Pull.BaseRepo, ctx.Pull.Num, CleanedPullComment, command.String()); err != nil {
+		ctx.Log.Err("unable to comment: %s", err)
+	}
+}
+
+// pullUsage returns the disk usage of the working dir of the pull request of
+// status. It returns false if it has none.
+func (q *DiskQuota) pullUsage(status models.PullStatus) (pullDiskUsage, bool) {
+	// Old versions of Atlantis didn't store the repo of pull requests.
+	if status.Pull.BaseRepo == (models.Repo{}) {
+		return pullDiskUsage{}, false
+	}
+	dir, err := q.WorkingDir.GetPullDir(status.Pull.BaseRepo, status.Pull)
+	if err != nil {
+		return pullDiskUsage{}, false
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return pullDiskUsage{}, false
+	}
+	bytes, err := dirSize(dir)
+	if err != nil {
+		q.Logger.Warn("failed measuring disk usage of %s: %s", dir, err)
+		return pullDiskUsage{}, false
+	}
+	lastUsed := info.ModTime()
+	for _, project := range status.Projects {
+		if project.StartedAt.After(lastUsed) {
+			lastUsed = project.StartedAt
+		}
+	}
+	return pullDiskUsage{pull: status.Pull, bytes: bytes, lastUsed: lastUsed}, true
+}
+
+// clean deletes the working dir of pull and records that it was deleted.
+func (q *DiskQuota) clean(pull models.PullRequest) error {
+	marker := q.cleanedMarker(pull)
+	if err := os.MkdirAll(filepath.Dir(marker), 0700); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(marker, nil, 0600); err != nil {
+		return err
+	}
+	return q.WorkingDir.Delete(pull.BaseRepo, pull)
+}
+
+// pruneCleanedMarkers deletes the records of the pull requests whose working
+// dirs were deleted that no longer have a status, ex. because they were
+// closed, since they won't be revisited.
+func (q *DiskQuota) pruneCleanedMarkers(statuses []models.PullStatus) {
+	keep := make(map[string]bool)
+	for _, status := range statuses {
+		keep[q.cleanedMarker(status.Pull)] = true
+	}
+	root := filepath.Join(q.DataDir, cleanedPullsPrefix)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() && !keep[path] {
+			return os.Remove(path)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		q.Logger.Warn("failed deleting records of deleted working dirs: %s", err)
+	}
+}
+
+func (q *DiskQuota) cleanedMarker(pull models.PullRequest) string {
+	return filepath.Join(q.DataDir, cleanedPullsPrefix, pull.BaseRepo.FullName, strconv.Itoa(pull.Num))
+}
+
+// dirSize returns the size of the files in dir. Files deleted while it's
+// measured are ignored.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrapf(err, "measuring disk usage of %s", dir)
+	}
+	return size, nil
+}
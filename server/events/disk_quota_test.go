@@ -0,0 +1,95 @@
+package events_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/db"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/models/fixtures"
+	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestDiskQuota_Enforce(t *testing.T) {
+	RegisterMockTestingT(t)
+	vcsClient := vcsmocks.NewMockClient()
+	dataDir, cleanup := TempDir(t)
+	defer cleanup()
+	dbDir, cleanupDB := TempDir(t)
+	defer cleanupDB()
+	database, err := db.New(dbDir)
+	Ok(t, err)
+	locker := events.NewDefaultWorkingDirLocker()
+	now := time.Now()
+	quota := &events.DiskQuota{
+		DataDir:          dataDir,
+		QuotaBytes:       2000,
+		DB:               database,
+		WorkingDir:       &events.FileWorkspace{DataDir: dataDir},
+		WorkingDirLocker: locker,
+		VCSClient:        vcsClient,
+		Logger:           logging.NewNoopLogger(t),
+		Interval:         time.Hour,
+		Now:              func() time.Time { return now },
+	}
+	Assert(t, quota.Usage() == nil, "expected no usage before the quota is enforced")
+
+	// Each pull's working dir uses 1000 bytes and was last modified num
+	// hours ago, except pull 4 which last ran a command an hour ago.
+	pulls := make(map[int]models.PullRequest)
+	for num := 1; num <= 4; num++ {
+		pull := models.PullRequest{Num: num, BaseRepo: fixtures.GithubRepo}
+		pulls[num] = pull
+		dir := filepath.Join(dataDir, "repos", fixtures.GithubRepo.FullName, strconv.Itoa(num))
+		Ok(t, os.MkdirAll(filepath.Join(dir, "default"), 0700))
+		Ok(t, ioutil.WriteFile(filepath.Join(dir, "default", "main.tf"), make([]byte, 1000), 0600))
+		modified := now.Add(-time.Duration(6-num) * time.Hour)
+		Ok(t, os.Chtimes(dir, modified, modified))
+		var results []models.ProjectResult
+		if num == 4 {
+			results = []models.ProjectResult{{Command: models.PlanCommand, RepoRelDir: ".", Workspace: "default", StartedAt: now.Add(-time.Hour)}}
+		}
+		_, err = database.UpdatePullWithResults(pull, results)
+		Ok(t, err)
+	}
+	// Pull 1 was used least recently but is running a command.
+	unlock, err := locker.TryLockPull(fixtures.GithubRepo.FullName, 1)
+	Ok(t, err)
+	defer unlock()
+
+	usage, err := quota.Enforce()
+	Ok(t, err)
+	Equals(t, events.DiskUsage{
+		Time:         now,
+		QuotaBytes:   2000,
+		UsedBytes:    2000,
+		Repos:        map[string]int64{fixtures.GithubRepo.FullName: 2000},
+		Pulls:        map[string]int64{fixtures.GithubRepo.FullName + "#1": 1000, fixtures.GithubRepo.FullName + "#4": 1000},
+		CleanedPulls: 2,
+	}, usage)
+	Equals(t, &usage, quota.Usage())
+	for num, exists := range map[int]bool{1: true, 2: false, 3: false, 4: true} {
+		_, err := os.Stat(filepath.Join(dataDir, "repos", fixtures.GithubRepo.FullName, strconv.Itoa(num)))
+		Equals(t, exists, err == nil)
+	}
+
+	// Plans don't get a comment since they plan the pull request again.
+	ctx := func(num int) *events.CommandContext {
+		return &events.CommandContext{Pull: pulls[num], Log: logging.NewNoopLogger(t)}
+	}
+	quota.Revisit(ctx(2), models.PlanCommand)
+	quota.Revisit(ctx(3), models.ApplyCommand)
+	quota.Revisit(ctx(3), models.ApplyCommand)
+	quota.Revisit(ctx(4), models.ApplyCommand)
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, 3, events.CleanedPullComment, "apply")
+	vcsClient.VerifyWasCalled(Never()).CreateComment(fixtures.GithubRepo, 2, events.CleanedPullComment, "plan")
+	vcsClient.VerifyWasCalled(Never()).CreateComment(fixtures.GithubRepo, 4, events.CleanedPullComment, "apply")
+}
@@ -15,17 +15,20 @@ type Drainer struct {
 type DrainStatus struct {
 	// ShuttingDown is whether we are in the progress of shutting down.
 	ShuttingDown bool
+	// Draining is whether an admin has stopped new operations from starting,
+	// ex. before upgrading Atlantis.
+	Draining bool
 	// InProgressOps is the number of operations currently in progress.
 	InProgressOps int
 }
 
 // StartOp tries to start a new operation. It returns false if Atlantis is
-// shutting down.
+// shutting down or draining.
 func (d *Drainer) StartOp() bool {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
 
-	if d.status.ShuttingDown {
+	if d.status.ShuttingDown || d.status.Draining {
 		return false
 	}
 	d.status.InProgressOps++
@@ -58,6 +61,23 @@ func (d *Drainer) ShutdownBlocking() {
 	d.wg.Wait()
 }
 
+// Drain stops new operations from starting without waiting for the ones in
+// progress. It's undone by Resume.
+func (d *Drainer) Drain() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.status.Draining = true
+}
+
+// Resume allows new operations to start again after Drain.
+func (d *Drainer) Resume() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.status.Draining = false
+}
+
 func (d *Drainer) GetStatus() DrainStatus {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
 	return d.status
 }
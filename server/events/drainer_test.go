@@ -67,3 +67,23 @@ func TestDrainer_Shutdown(t *testing.T) {
 
 	}
 }
+
+func TestDrainer_Drain(t *testing.T) {
+	d := events.Drainer{}
+	Equals(t, true, d.StartOp())
+
+	// Ops in progress can finish but new ones can't start.
+	d.Drain()
+	Equals(t, false, d.StartOp())
+	Equals(t, events.DrainStatus{
+		Draining:      true,
+		InProgressOps: 1,
+	}, d.GetStatus())
+	d.OpDone()
+	Equals(t, 0, d.GetStatus().InProgressOps)
+
+	// Resuming allows ops to start again.
+	d.Resume()
+	Equals(t, true, d.StartOp())
+	Equals(t, events.DrainStatus{InProgressOps: 1}, d.GetStatus())
+}
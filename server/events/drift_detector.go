@@ -0,0 +1,463 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// Statuses of the projects of drift checks.
+const (
+	// DriftedStatus means the plan has changes, so the infrastructure
+	// doesn't match the code.
+	DriftedStatus = "drifted"
+	// InSyncStatus means the plan has no changes.
+	InSyncStatus = "in-sync"
+	// DriftErroredStatus means the plan failed or didn't run, ex. because
+	// a pull request has locked the project.
+	DriftErroredStatus = "errored"
+)
+
+// DriftUser is the user drift checks run as.
+const DriftUser = "atlantis-drift-detection"
+
+// ErrDriftCheckNotFound is returned when running a drift check that isn't
+// configured.
+var ErrDriftCheckNotFound = errors.New("drift check not found")
+
+// ErrDriftCheckRunning is returned when running a drift check that's already
+// running.
+var ErrDriftCheckRunning = errors.New("drift check is already running")
+
+// planChangesRegex matches the summary of plans with changes. Its groups are
+// the numbers of resources to add, change and destroy.
+var planChangesRegex = regexp.MustCompile(`Plan: (\d+) to add, (\d+) to change, (\d+) to destroy.`)
+
+// DriftCheck periodically plans projects of a branch, ex. the default branch,
+// to detect if the infrastructure has drifted from the code.
+type DriftCheck struct {
+	// Name identifies the check, ex. when running it through the API.
+	Name string
+	// Spec is the cron expression of when the check runs. It's only used for
+	// display.
+	Spec     string
+	Schedule cron.Schedule
+	Repo     models.Repo
+	Branch   string
+	Projects []DriftProject
+	// Issues, if set, configures the issues opened for projects that
+	// drifted. If nil, no issues are opened.
+	Issues *DriftIssues
+}
+
+// DriftProject identifies a project of a drift check either by name or by dir
+// and workspace.
+type DriftProject struct {
+	Name      string
+	Dir       string
+	Workspace string
+	// Assignees, if set, replace the assignees of the check's issues for this
+	// project.
+	Assignees []string
+}
+
+// DriftIssues configures the issues opened for projects that drifted.
+type DriftIssues struct {
+	Labels    []string
+	Assignees []string
+}
+
+func (p DriftProject) String() string {
+	if p.Name != "" {
+		return fmt.Sprintf("project %q", p.Name)
+	}
+	return fmt.Sprintf("dir %q", p.Dir)
+}
+
+// DriftResult is the result of the last drift check of a project.
+type DriftResult struct {
+	ProjectName string
+	Dir         string
+	Workspace   string
+	// Status is DriftedStatus, InSyncStatus or DriftErroredStatus.
+	Status string
+	// Summary is a one line summary of the plan's changes.
+	Summary string
+	// Error is why the plan failed or didn't run.
+	Error string
+	// OutputURL is where the plan's full log can be viewed.
+	OutputURL string
+	// IssueURL is the URL of the open issue about the drift, if any.
+	IssueURL string
+	// ToAdd, ToChange and ToDestroy are the numbers of resources the plan
+	// would add, change and destroy.
+	ToAdd     int
+	ToChange  int
+	ToDestroy int
+	// CheckedAt is when the project was planned.
+	CheckedAt time.Time
+}
+
+// DriftSummary summarizes the last run of every drift check.
+type DriftSummary struct {
+	Checks int
+	// Projects is the number of projects planned by the checks' last runs.
+	Projects int
+	Drifted  int
+	InSync   int
+	Errored  int
+	// ToAdd, ToChange and ToDestroy are the total numbers of resources that
+	// drifted.
+	ToAdd     int
+	ToChange  int
+	ToDestroy int
+	// LastRun is when a check last finished. It's zero if none has run since
+	// Atlantis started.
+	LastRun time.Time
+}
+
+// DriftCheckStatus is the state of a drift check.
+type DriftCheckStatus struct {
+	DriftCheck
+	Running bool
+	// LastRun is when the check last finished. It's zero if it hasn't run
+	// since Atlantis started.
+	LastRun time.Time
+	NextRun time.Time
+	// Results are the results of each project of the last run.
+	Results []DriftResult
+}
+
+// DriftDetector runs drift checks on their schedules. Results are kept in
+// memory. It's safe for concurrent use.
+type DriftDetector struct {
+	Checks                        []DriftCheck
+	PreWorkflowHooksCommandRunner PreWorkflowHooksCommandRunner
+	ProjectCommandBuilder         ProjectCommandBuilder
+	ProjectCommandRunner          ProjectCommandRunner
+	// DeleteLockCommand deletes the locks and working directories of checks
+	// once they finish.
+	DeleteLockCommand DeleteLockCommand
+	// VCSClient opens the issues of checks with issues configured.
+	VCSClient vcs.Client
+	Drainer   *Drainer
+	// RepoMutexes serializes checks with API jobs for the same repo.
+	RepoMutexes *RepoMutexes
+	Logger      logging.SimpleLogging
+	// Now returns the current time. It's only overridden in tests.
+	Now func() time.Time
+
+	mutex    sync.Mutex
+	running  map[string]bool
+	lastRuns map[string]time.Time
+	nextRuns map[string]time.Time
+	results  map[string][]DriftResult
+}
+
+// Run runs the checks when they're due until ctx is done.
+func (d *DriftDetector) Run(ctx context.Context) {
+	// Cron schedules are at most once a minute.
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	d.RunDue()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.RunDue()
+		}
+	}
+}
+
+// RunDue starts the checks that are due in the background. Checks are first
+// due at their first scheduled time after RunDue is first called.
+func (d *DriftDetector) RunDue() {
+	now := d.Now()
+	var due []string
+	d.mutex.Lock()
+	if d.nextRuns == nil {
+		d.nextRuns = make(map[string]time.Time)
+	}
+	for _, c := range d.Checks {
+		next, ok := d.nextRuns[c.Name]
+		if ok && !next.After(now) {
+			due = append(due, c.Name)
+		}
+		if !ok || !next.After(now) {
+			d.nextRuns[c.Name] = c.Schedule.Next(now)
+		}
+	}
+	d.mutex.Unlock()
+
+	for _, name := range due {
+		if err := d.Start(name); err != nil {
+			d.Logger.Warn("not running drift check %q: %s", name, err)
+		}
+	}
+}
+
+// Start runs the check name in the background.
+func (d *DriftDetector) Start(name string) error {
+	check, err := d.begin(name)
+	if err != nil {
+		return err
+	}
+	go d.run(check)
+	return nil
+}
+
+// Detect runs the check name and returns once it's finished.
+func (d *DriftDetector) Detect(name string) error {
+	check, err := d.begin(name)
+	if err != nil {
+		return err
+	}
+	d.run(check)
+	return nil
+}
+
+// Status returns the state of each check, in the order they're configured.
+func (d *DriftDetector) Status() []DriftCheckStatus {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	var statuses []DriftCheckStatus
+	for _, c := range d.Checks {
+		statuses = append(statuses, DriftCheckStatus{
+			DriftCheck: c,
+			Running:    d.running[c.Name],
+			LastRun:    d.lastRuns[c.Name],
+			NextRun:    d.nextRun(c),
+			Results:    d.results[c.Name],
+		})
+	}
+	return statuses
+}
+
+// Summary summarizes the last run of every check.
+func (d *DriftDetector) Summary() DriftSummary {
+	var summary DriftSummary
+	for _, s := range d.Status() {
+		summary.Checks++
+		if s.LastRun.After(summary.LastRun) {
+			summary.LastRun = s.LastRun
+		}
+		for _, r := range s.Results {
+			summary.Projects++
+			switch r.Status {
+			case DriftedStatus:
+				summary.Drifted++
+			case InSyncStatus:
+				summary.InSync++
+			default:
+				summary.Errored++
+			}
+			summary.ToAdd += r.ToAdd
+			summary.ToChange += r.ToChange
+			summary.ToDestroy += r.ToDestroy
+		}
+	}
+	return summary
+}
+
+// nextRun returns when c runs next. d.mutex must be held.
+func (d *DriftDetector) nextRun(c DriftCheck) time.Time {
+	if next, ok := d.nextRuns[c.Name]; ok {
+		return next
+	}
+	return c.Schedule.Next(d.Now())
+}
+
+// begin marks the check name as running.
+func (d *DriftDetector) begin(name string) (DriftCheck, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for _, c := range d.Checks {
+		if c.Name != name {
+			continue
+		}
+		if d.running[name] {
+			return DriftCheck{}, ErrDriftCheckRunning
+		}
+		if d.running == nil {
+			d.running = make(map[string]bool)
+		}
+		d.running[name] = true
+		return c, nil
+	}
+	return DriftCheck{}, ErrDriftCheckNotFound
+}
+
+// run plans the projects of check and records their results.
+func (d *DriftDetector) run(check DriftCheck) {
+	log := d.Logger.WithHistory("repository", check.Repo.FullName, "drift-check", check.Name)
+	var results []DriftResult
+	defer func() {
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		delete(d.running, check.Name)
+		if results == nil {
+			return
+		}
+		if d.lastRuns == nil {
+			d.lastRuns = make(map[string]time.Time)
+			d.results = make(map[string][]DriftResult)
+		}
+		d.lastRuns[check.Name] = d.Now()
+		d.results[check.Name] = results
+	}()
+
+	if !d.Drainer.StartOp() {
+		log.Warn("not running drift check since Atlantis is draining or shutting down")
+		return
+	}
+	defer d.Drainer.OpDone()
+	defer d.RepoMutexes.Lock(check.Repo.FullName)()
+
+	log.Info("running drift check of branch %q", check.Branch)
+	ctx := &CommandContext{
+		HeadRepo: check.Repo,
+		Pull: models.PullRequest{
+			Num:        0,
+			HeadBranch: check.Branch,
+			HeadCommit: check.Branch,
+			BaseBranch: check.Branch,
+			Author:     DriftUser,
+			State:      models.OpenPullState,
+			BaseRepo:   check.Repo,
+		},
+		User:    models.User{Username: DriftUser},
+		Log:     log,
+		Trigger: Comment,
+	}
+	if err := d.PreWorkflowHooksCommandRunner.RunPreHooks(ctx); err != nil {
+		log.Err("Error running pre-workflow hooks %s. Proceeding with drift check.", err)
+	}
+	results = []DriftResult{}
+	for _, p := range check.Projects {
+		cmds, err := d.ProjectCommandBuilder.BuildPlanCommands(ctx, NewCommentCommand(p.Dir, nil, models.PlanCommand, false, p.Workspace, p.Name))
+		if err != nil {
+			results = append(results, DriftResult{
+				ProjectName: p.Name,
+				Dir:         p.Dir,
+				Workspace:   p.Workspace,
+				Status:      DriftErroredStatus,
+				Error:       errors.Wrapf(err, "building plan command for %s", p).Error(),
+				CheckedAt:   d.Now(),
+			})
+			continue
+		}
+		for _, cmd := range cmds {
+			result := toDriftResult(d.ProjectCommandRunner.Plan(cmd))
+			result.CheckedAt = d.Now()
+			if result.Status == DriftedStatus && check.Issues != nil {
+				result.IssueURL = d.openIssue(log, check, p, result)
+			}
+			results = append(results, result)
+		}
+	}
+	// Checks don't keep plans or locks since the plans are never applied.
+	if _, err := d.DeleteLockCommand.DeleteLocksByPull(check.Repo.FullName, 0); err != nil {
+		log.Err("failed to delete locks: %s", err)
+	}
+
+	drifted := 0
+	for _, r := range results {
+		if r.Status == DriftedStatus {
+			drifted++
+		}
+	}
+	log.Info("drift check found %d of %d projects drifted", drifted, len(results))
+}
+
+// openIssue opens an issue about the drift of result unless one with the same
+// title is already open. It returns the issue's URL, or "" if it failed.
+func (d *DriftDetector) openIssue(log logging.SimpleLogging, check DriftCheck, p DriftProject, result DriftResult) string {
+	title := DriftIssueTitle(check.Branch, result)
+	existing, err := d.VCSClient.FindOpenIssue(check.Repo, title)
+	if err != nil {
+		log.Err("failed to find drift issue: %s", err)
+		return ""
+	}
+	if existing != nil {
+		log.Debug("drift issue #%d is already open", existing.Number)
+		return existing.URL
+	}
+
+	assignees := check.Issues.Assignees
+	if len(p.Assignees) > 0 {
+		assignees = p.Assignees
+	}
+	issue, err := d.VCSClient.CreateIssue(check.Repo, models.Issue{
+		Title:     title,
+		Body:      driftIssueBody(check, result),
+		Labels:    check.Issues.Labels,
+		Assignees: assignees,
+	})
+	if err != nil {
+		log.Err("failed to open drift issue: %s", err)
+		return ""
+	}
+	log.Info("opened drift issue #%d", issue.Number)
+	return issue.URL
+}
+
+// DriftIssueTitle returns the title of the issue about the drift of result.
+// Since it's used to find open issues, it's the same for each run.
+func DriftIssueTitle(branch string, result DriftResult) string {
+	name := result.ProjectName
+	if name == "" {
+		name = result.Dir
+	}
+	return fmt.Sprintf("Drift detected in %s (%s) on %s", name, result.Workspace, branch)
+}
+
+func driftIssueBody(check DriftCheck, result DriftResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Drift check `%s` found that the infrastructure of dir `%s` in workspace `%s` doesn't match branch `%s`:\n\n",
+		check.Name, result.Dir, result.Workspace, check.Branch)
+	fmt.Fprintf(&b, "> %s\n\n", result.Summary)
+	if result.OutputURL != "" {
+		fmt.Fprintf(&b, "[View the plan](%s)\n\n", result.OutputURL)
+	}
+	b.WriteString("Atlantis doesn't update or close this issue. Close it once the drift is resolved so that the next drift is reported again.\n")
+	return b.String()
+}
+
+func toDriftResult(r models.ProjectResult) DriftResult {
+	result := DriftResult{
+		ProjectName: r.ProjectName,
+		Dir:         r.RepoRelDir,
+		Workspace:   r.Workspace,
+		OutputURL:   r.OutputURL,
+	}
+	switch {
+	case r.PlanSuccess != nil:
+		result.Summary = r.PlanSuccess.Summary()
+		result.Status = InSyncStatus
+		if match := planChangesRegex.FindStringSubmatch(r.PlanSuccess.TerraformOutput); match != nil {
+			result.Status = DriftedStatus
+			// The groups only match digits so they can't fail to parse.
+			result.ToAdd, _ = strconv.Atoi(match[1])
+			result.ToChange, _ = strconv.Atoi(match[2])
+			result.ToDestroy, _ = strconv.Atoi(match[3])
+		}
+	case r.Error != nil:
+		result.Status = DriftErroredStatus
+		result.Error = r.Error.Error()
+	default:
+		result.Status = DriftErroredStatus
+		result.Error = r.Failure
+	}
+	return result
+}
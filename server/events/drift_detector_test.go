@@ -0,0 +1,210 @@
+package events_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/locking"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models"
+	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	vcsmatchers "github.com/runatlantis/atlantis/server/events/vcs/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func setupDriftDetector(t *testing.T) (*events.DriftDetector, *mocks.MockProjectCommandBuilder, *mocks.MockProjectCommandRunner, *mocks.MockDeleteLockCommand) {
+	RegisterMockTestingT(t)
+	builder := mocks.NewMockProjectCommandBuilder()
+	runner := mocks.NewMockProjectCommandRunner()
+	deleteLockCommand := mocks.NewMockDeleteLockCommand()
+	schedule, err := locking.ParseMaintenanceSpec("0 6 * * *")
+	Ok(t, err)
+	now := time.Date(2021, 6, 1, 5, 0, 0, 0, time.UTC)
+	d := &events.DriftDetector{
+		Checks: []events.DriftCheck{{
+			Name:     "prod",
+			Spec:     "0 6 * * *",
+			Schedule: schedule,
+			Repo:     models.Repo{FullName: "owner/repo"},
+			Branch:   "main",
+			Projects: []events.DriftProject{{Name: "prod"}, {Dir: "staging"}},
+		}},
+		PreWorkflowHooksCommandRunner: mocks.NewMockPreWorkflowHooksCommandRunner(),
+		ProjectCommandBuilder:         builder,
+		ProjectCommandRunner:          runner,
+		DeleteLockCommand:             deleteLockCommand,
+		Drainer:                       &events.Drainer{},
+		RepoMutexes:                   &events.RepoMutexes{},
+		Logger:                        logging.NewNoopLogger(t),
+		Now:                           func() time.Time { return now },
+	}
+	return d, builder, runner, deleteLockCommand
+}
+
+func TestDriftDetector_Detect(t *testing.T) {
+	d, builder, runner, deleteLockCommand := setupDriftDetector(t)
+	prodCtx := models.ProjectCommandContext{ProjectName: "prod", RepoRelDir: "prod", Workspace: "default"}
+	stagingCtx := models.ProjectCommandContext{RepoRelDir: "staging", Workspace: "default"}
+	When(builder.BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())).
+		ThenReturn([]models.ProjectCommandContext{prodCtx}, nil).
+		ThenReturn([]models.ProjectCommandContext{stagingCtx}, nil)
+	When(runner.Plan(prodCtx)).ThenReturn(models.ProjectResult{
+		ProjectName: "prod",
+		RepoRelDir:  "prod",
+		Workspace:   "default",
+		PlanSuccess: &models.PlanSuccess{TerraformOutput: "Plan: 1 to add, 0 to change, 0 to destroy."},
+	})
+	When(runner.Plan(stagingCtx)).ThenReturn(models.ProjectResult{
+		RepoRelDir:  "staging",
+		Workspace:   "default",
+		PlanSuccess: &models.PlanSuccess{TerraformOutput: "No changes. Infrastructure is up-to-date."},
+	})
+
+	Ok(t, d.Detect("prod"))
+	status := d.Status()
+	Equals(t, 1, len(status))
+	Equals(t, false, status[0].Running)
+	Equals(t, d.Now(), status[0].LastRun)
+	Equals(t, []events.DriftResult{
+		{
+			ProjectName: "prod",
+			Dir:         "prod",
+			Workspace:   "default",
+			Status:      events.DriftedStatus,
+			Summary:     "Plan: 1 to add, 0 to change, 0 to destroy.",
+			ToAdd:       1,
+			CheckedAt:   d.Now(),
+		},
+		{
+			Dir:       "staging",
+			Workspace: "default",
+			Status:    events.InSyncStatus,
+			Summary:   "No changes. Infrastructure is up-to-date.",
+			CheckedAt: d.Now(),
+		},
+	}, status[0].Results)
+	Equals(t, events.DriftSummary{
+		Checks:   1,
+		Projects: 2,
+		Drifted:  1,
+		InSync:   1,
+		ToAdd:    1,
+		LastRun:  d.Now(),
+	}, d.Summary())
+
+	// Checks plan the branch as pull request 0 and delete its locks
+	// afterwards.
+	ctx, _ := builder.VerifyWasCalled(Times(2)).BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand()).GetCapturedArguments()
+	Equals(t, 0, ctx.Pull.Num)
+	Equals(t, "main", ctx.Pull.HeadBranch)
+	Equals(t, events.DriftUser, ctx.User.Username)
+	deleteLockCommand.VerifyWasCalledOnce().DeleteLocksByPull("owner/repo", 0)
+}
+
+func TestDriftDetector_DetectErrored(t *testing.T) {
+	d, builder, runner, _ := setupDriftDetector(t)
+	prodCtx := models.ProjectCommandContext{ProjectName: "prod", RepoRelDir: "prod", Workspace: "default"}
+	When(builder.BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())).
+		ThenReturn([]models.ProjectCommandContext{prodCtx}, nil).
+		ThenReturn(nil, errors.New("no project at staging"))
+	When(runner.Plan(prodCtx)).ThenReturn(models.ProjectResult{
+		ProjectName: "prod",
+		RepoRelDir:  "prod",
+		Workspace:   "default",
+		Failure:     "This project is currently locked by an unapplied plan from pull #1.",
+	})
+
+	Ok(t, d.Detect("prod"))
+	Equals(t, []events.DriftResult{
+		{
+			ProjectName: "prod",
+			Dir:         "prod",
+			Workspace:   "default",
+			Status:      events.DriftErroredStatus,
+			Error:       "This project is currently locked by an unapplied plan from pull #1.",
+			CheckedAt:   d.Now(),
+		},
+		{
+			Dir:       "staging",
+			Status:    events.DriftErroredStatus,
+			Error:     `building plan command for dir "staging": no project at staging`,
+			CheckedAt: d.Now(),
+		},
+	}, d.Status()[0].Results)
+}
+
+func TestDriftDetector_Issues(t *testing.T) {
+	d, builder, runner, _ := setupDriftDetector(t)
+	vcsClient := vcsmocks.NewMockClient()
+	d.VCSClient = vcsClient
+	d.Checks[0].Issues = &events.DriftIssues{Labels: []string{"drift"}, Assignees: []string{"infra-oncall"}}
+	d.Checks[0].Projects[1].Assignees = []string{"alice"}
+	prodCtx := models.ProjectCommandContext{ProjectName: "prod", RepoRelDir: "prod", Workspace: "default"}
+	stagingCtx := models.ProjectCommandContext{RepoRelDir: "staging", Workspace: "default"}
+	When(builder.BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())).
+		ThenReturn([]models.ProjectCommandContext{prodCtx}, nil).
+		ThenReturn([]models.ProjectCommandContext{stagingCtx}, nil)
+	When(runner.Plan(prodCtx)).ThenReturn(models.ProjectResult{
+		ProjectName: "prod",
+		RepoRelDir:  "prod",
+		Workspace:   "default",
+		PlanSuccess: &models.PlanSuccess{TerraformOutput: "Plan: 1 to add, 0 to change, 0 to destroy."},
+	})
+	When(runner.Plan(stagingCtx)).ThenReturn(models.ProjectResult{
+		RepoRelDir:  "staging",
+		Workspace:   "default",
+		PlanSuccess: &models.PlanSuccess{TerraformOutput: "Plan: 0 to add, 0 to change, 1 to destroy."},
+		OutputURL:   "https://atlantis.example.com/jobs/1",
+	})
+	// The drift of prod was already reported.
+	repo := d.Checks[0].Repo
+	When(vcsClient.FindOpenIssue(repo, "Drift detected in prod (default) on main")).
+		ThenReturn(&models.Issue{Number: 1, URL: "https://github.com/owner/repo/issues/1"}, nil)
+	When(vcsClient.CreateIssue(vcsmatchers.AnyModelsRepo(), vcsmatchers.AnyModelsIssue())).
+		ThenReturn(models.Issue{Number: 2, URL: "https://github.com/owner/repo/issues/2"}, nil)
+
+	Ok(t, d.Detect("prod"))
+	results := d.Status()[0].Results
+	Equals(t, "https://github.com/owner/repo/issues/1", results[0].IssueURL)
+	Equals(t, "https://github.com/owner/repo/issues/2", results[1].IssueURL)
+
+	_, issue := vcsClient.VerifyWasCalledOnce().CreateIssue(vcsmatchers.AnyModelsRepo(), vcsmatchers.AnyModelsIssue()).GetCapturedArguments()
+	Equals(t, "Drift detected in staging (default) on main", issue.Title)
+	Equals(t, []string{"drift"}, issue.Labels)
+	Equals(t, []string{"alice"}, issue.Assignees)
+	Assert(t, strings.Contains(issue.Body, "Plan: 0 to add, 0 to change, 1 to destroy."), "exp summary in %q", issue.Body)
+	Assert(t, strings.Contains(issue.Body, "[View the plan](https://atlantis.example.com/jobs/1)"), "exp plan link in %q", issue.Body)
+}
+
+func TestDriftDetector_NotFound(t *testing.T) {
+	d, _, _, _ := setupDriftDetector(t)
+	ErrEquals(t, events.ErrDriftCheckNotFound.Error(), d.Detect("missing"))
+	ErrEquals(t, events.ErrDriftCheckNotFound.Error(), d.Start("missing"))
+}
+
+func TestDriftDetector_RunDue(t *testing.T) {
+	d, builder, _, _ := setupDriftDetector(t)
+	now := d.Now()
+	d.Now = func() time.Time { return now }
+
+	// Checks aren't run when Atlantis starts but at their next scheduled
+	// time.
+	d.RunDue()
+	Equals(t, time.Date(2021, 6, 1, 6, 0, 0, 0, time.UTC), d.Status()[0].NextRun)
+	builder.VerifyWasCalled(Never()).BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())
+
+	now = time.Date(2021, 6, 1, 6, 0, 30, 0, time.UTC)
+	d.RunDue()
+	Equals(t, time.Date(2021, 6, 2, 6, 0, 0, 0, time.UTC), d.Status()[0].NextRun)
+	for i := 0; i < 100 && d.Status()[0].LastRun.IsZero(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	Equals(t, now, d.Status()[0].LastRun)
+	builder.VerifyWasCalled(Times(2)).BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())
+}
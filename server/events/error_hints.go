@@ -0,0 +1,93 @@
+package events
+
+import (
+	"regexp"
+)
+
+// ErrorHint is a remediation hint added to the comments of errors matching
+// Pattern.
+type ErrorHint struct {
+	// Name identifies the hint, ex. so the config can replace a default one.
+	Name    string
+	Pattern *regexp.Regexp
+	Hint    string
+}
+
+// DefaultErrorHints are the hints for common failures.
+var DefaultErrorHints = ErrorHints{
+	{
+		Name:    "state-lock",
+		Pattern: regexp.MustCompile(`Error acquiring the state lock|Error locking state`),
+		Hint: "The state is locked by another run. Wait for it to finish and try again. If no run is in progress," +
+			" unlock the state with `terraform force-unlock` and the lock ID in the error.",
+	},
+	{
+		Name: "expired-credentials",
+		Pattern: regexp.MustCompile(`(?i)ExpiredToken|InvalidClientTokenId|security token included in the request is (expired|invalid)` +
+			`|token (has|is) expired|credentials have expired|oauth2: cannot fetch token|AADSTS700024`),
+		Hint: "The cloud credentials have expired or are invalid. Check the credentials Atlantis runs with, ex. renew the" +
+			" token or role session, and try again.",
+	},
+	{
+		Name: "provider-version-conflict",
+		Pattern: regexp.MustCompile(`Failed to query available provider packages|no available releases match the given constraints` +
+			`|locked provider .* does not match configured version constraint|Incompatible provider version` +
+			`|doesn't match any of the checksums previously recorded`),
+		Hint: "A provider version doesn't satisfy the version constraints or the dependency lock file. Update the" +
+			" `required_providers` constraints, or run `terraform init -upgrade` and commit `.terraform.lock.hcl`.",
+	},
+	{
+		Name:    "backend-changed",
+		Pattern: regexp.MustCompile(`Backend configuration changed|Backend initialization required|Backend configuration block has changed`),
+		Hint: "The backend configuration changed since the project was initialized. Add `-reconfigure` to the `init` step's" +
+			" `extra_args`, or migrate the state with `terraform init -migrate-state` first.",
+	},
+}
+
+// ErrorHints classify errors to add remediation hints to their comments.
+type ErrorHints []ErrorHint
+
+// NewErrorHints returns DefaultErrorHints with custom hints added. A custom
+// hint with the name of a default one replaces it, or removes it if its Hint
+// is empty. The other custom hints are checked before the defaults since
+// they're usually more specific.
+func NewErrorHints(custom []ErrorHint) ErrorHints {
+	replaced := make(map[string]ErrorHint)
+	var hints ErrorHints
+	for _, h := range custom {
+		if DefaultErrorHints.has(h.Name) {
+			replaced[h.Name] = h
+		} else {
+			hints = append(hints, h)
+		}
+	}
+	for _, h := range DefaultErrorHints {
+		if r, ok := replaced[h.Name]; ok {
+			h = r
+		}
+		if h.Hint != "" {
+			hints = append(hints, h)
+		}
+	}
+	return hints
+}
+
+// For returns the hints of the errors in output.
+func (e ErrorHints) For(output string) []string {
+	var hints []string
+	for _, h := range e {
+		if h.Pattern != nil && h.Pattern.MatchString(output) {
+			hints = append(hints, h.Hint)
+		}
+	}
+	return hints
+}
+
+func (e ErrorHints) has(name string) bool {
+	for _, h := range e {
+		if h.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package events_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestDefaultErrorHints(t *testing.T) {
+	cases := map[string]string{
+		"state-lock": `Error: Error acquiring the state lock
+
+Error message: ConditionalCheckFailedException: The conditional request failed
+Lock Info:
+  ID:        9db590f1-b6fe-c5f2-2678-8804f089deba`,
+		"expired-credentials": `Error: error configuring Terraform AWS Provider: error validating provider credentials: ` +
+			`error calling sts:GetCallerIdentity: ExpiredToken: The security token included in the request is expired`,
+		"provider-version-conflict": `Error: Failed to query available provider packages
+
+Could not retrieve the list of available versions for provider hashicorp/aws: locked provider
+registry.terraform.io/hashicorp/aws 3.0.0 does not match configured version constraint ~> 4.0`,
+		"backend-changed": `Error: Backend configuration changed
+
+A change in the backend configuration has been detected, which may require migrating existing state.`,
+	}
+	for name, output := range cases {
+		t.Run(name, func(t *testing.T) {
+			var exp []string
+			for _, h := range events.DefaultErrorHints {
+				if h.Name == name {
+					exp = append(exp, h.Hint)
+				}
+			}
+			Equals(t, exp, events.DefaultErrorHints.For(output))
+		})
+	}
+	Equals(t, []string(nil), events.DefaultErrorHints.For("Error: Unsupported argument"))
+}
+
+func TestNewErrorHints(t *testing.T) {
+	hints := events.NewErrorHints([]events.ErrorHint{
+		{Name: "quota", Pattern: regexp.MustCompile(`QuotaExceeded`), Hint: "Request a quota increase."},
+		{Name: "state-lock", Pattern: regexp.MustCompile(`Error acquiring the state lock`), Hint: "Ask #infra to unlock it."},
+		{Name: "backend-changed"},
+	})
+	var names []string
+	for _, h := range hints {
+		names = append(names, h.Name)
+	}
+	Equals(t, []string{"quota", "state-lock", "expired-credentials", "provider-version-conflict"}, names)
+
+	Equals(t, []string{"Request a quota increase.", "Ask #infra to unlock it."}, hints.For("QuotaExceeded\nError acquiring the state lock"))
+	Equals(t, []string(nil), hints.For("Error: Backend configuration changed"))
+}
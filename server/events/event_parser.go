@@ -24,6 +24,7 @@ import (
 	"github.com/mcdafydd/go-azuredevops/azuredevops"
 	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
 	"github.com/runatlantis/atlantis/server/events/vcs/bitbucketcloud"
 	"github.com/runatlantis/atlantis/server/events/vcs/bitbucketserver"
 	"github.com/xanzy/go-gitlab"
@@ -100,6 +101,25 @@ type CommentCommand struct {
 	// project specified in an atlantis.yaml file.
 	// If empty then the comment specified no project.
 	ProjectName string
+	// OpenPR is true if atlantis revert should open a pull request with the
+	// revert.
+	OpenPR bool
+	// WorkspaceAction is what atlantis workspace does, ie. "new", "delete" or
+	// "list".
+	WorkspaceAction string
+	// WorkspaceName is the name of the workspace that atlantis workspace
+	// creates or deletes.
+	WorkspaceName string
+	// AllowDestroy is true if atlantis apply should apply plans that destroy
+	// or replace resources when the destroy_confirmed apply requirement is
+	// set.
+	AllowDestroy bool
+	// UpgradeProviders is true if atlantis plan should upgrade providers and
+	// list the versions that changed.
+	UpgradeProviders bool
+	// Fix is true if atlantis fmt should push a commit that formats the
+	// files.
+	Fix bool
 }
 
 // IsForSpecificProject returns true if the command is for a specific dir, workspace
@@ -277,20 +297,47 @@ type EventParsing interface {
 	// ParseAzureDevopsRepo parses the response from the Azure DevOps API endpoint that
 	// returns a repo into the Atlantis model.
 	ParseAzureDevopsRepo(adRepo *azuredevops.GitRepository) (models.Repo, error)
+
+	// ParseAPIRepo returns the repo named repoFullName on the VCS host of type
+	// vcsHostType. It's used by API requests which only name the repo.
+	ParseAPIRepo(vcsHostType models.VCSHostType, repoFullName string) (models.Repo, error)
 }
 
 // EventParser parses VCS events.
 type EventParser struct {
 	GithubUser         string
 	GithubToken        string
+	GithubHostname     string
 	GitlabUser         string
 	GitlabToken        string
+	GitlabHostname     string
 	AllowDraftPRs      bool
 	BitbucketUser      string
 	BitbucketToken     string
 	BitbucketServerURL string
 	AzureDevopsToken   string
 	AzureDevopsUser    string
+	// GithubCredentials, if set, is used instead of GithubToken so that
+	// clone URLs use the token after it's rotated.
+	GithubCredentials *vcs.GithubUserCredentials
+	// GitlabCredentials, if set, is used instead of GitlabToken so that
+	// clone URLs use the token after it's rotated.
+	GitlabCredentials *vcs.GitlabCredentials
+	// BitbucketCloudCredentials, if set, is used instead of BitbucketUser and
+	// BitbucketToken for Bitbucket Cloud clone URLs so that they use access
+	// tokens.
+	BitbucketCloudCredentials *bitbucketcloud.Credentials
+	// AzureDevopsOrgs maps lowercase Azure DevOps organizations to the
+	// credentials of their clone URLs. Other organizations use
+	// AzureDevopsUser and AzureDevopsToken.
+	AzureDevopsOrgs map[string]AzureDevopsCredentials
+}
+
+// AzureDevopsCredentials are the user and token of an Azure DevOps
+// organization's clone URLs.
+type AzureDevopsCredentials struct {
+	User  string
+	Token string
 }
 
 // GetBitbucketCloudPullEventType returns the type of the pull request
@@ -341,12 +388,16 @@ func (e *EventParser) parseCommonBitbucketCloudEventData(event bitbucketcloud.Co
 		return
 	}
 
+	gitUser, gitToken, err := e.bitbucketCloudGitCredentials()
+	if err != nil {
+		return
+	}
 	headRepo, err = models.NewRepo(
 		models.BitbucketCloud,
 		*event.PullRequest.Source.Repository.FullName,
 		*event.PullRequest.Source.Repository.Links.HTML.HREF,
-		e.BitbucketUser,
-		e.BitbucketToken)
+		gitUser,
+		gitToken)
 	if err != nil {
 		return
 	}
@@ -354,8 +405,8 @@ func (e *EventParser) parseCommonBitbucketCloudEventData(event bitbucketcloud.Co
 		models.BitbucketCloud,
 		*event.Repository.FullName,
 		*event.Repository.Links.HTML.HREF,
-		e.BitbucketUser,
-		e.BitbucketToken)
+		gitUser,
+		gitToken)
 	if err != nil {
 		return
 	}
@@ -367,6 +418,7 @@ func (e *EventParser) parseCommonBitbucketCloudEventData(event bitbucketcloud.Co
 		HeadBranch: *event.PullRequest.Source.Branch.Name,
 		BaseBranch: *event.PullRequest.Destination.Branch.Name,
 		Author:     *event.Actor.Nickname,
+		Title:      event.PullRequest.GetTitle(),
 		State:      prState,
 		BaseRepo:   baseRepo,
 	}
@@ -513,16 +565,24 @@ func (e *EventParser) ParseGithubPull(pull *github.PullRequest) (pullModel model
 	if pull.GetState() == "open" {
 		pullState = models.OpenPullState
 	}
+	// merge_commit_sha is also set to a test merge commit while the pull
+	// request is open.
+	var mergeCommit string
+	if pull.GetMerged() {
+		mergeCommit = pull.GetMergeCommitSHA()
+	}
 
 	pullModel = models.PullRequest{
-		Author:     authorUsername,
-		HeadBranch: headBranch,
-		HeadCommit: commit,
-		URL:        url,
-		Num:        num,
-		State:      pullState,
-		BaseRepo:   baseRepo,
-		BaseBranch: baseBranch,
+		Author:      authorUsername,
+		Title:       pull.GetTitle(),
+		HeadBranch:  headBranch,
+		HeadCommit:  commit,
+		URL:         url,
+		Num:         num,
+		State:       pullState,
+		MergeCommit: mergeCommit,
+		BaseRepo:    baseRepo,
+		BaseBranch:  baseBranch,
 	}
 	return
 }
@@ -531,7 +591,7 @@ func (e *EventParser) ParseGithubPull(pull *github.PullRequest) (pullModel model
 // returns a repo into the Atlantis model.
 // See EventParsing for return value docs.
 func (e *EventParser) ParseGithubRepo(ghRepo *github.Repository) (models.Repo, error) {
-	return models.NewRepo(models.Github, ghRepo.GetFullName(), ghRepo.GetCloneURL(), e.GithubUser, e.GithubToken)
+	return models.NewRepo(models.Github, ghRepo.GetFullName(), ghRepo.GetCloneURL(), e.GithubUser, e.githubToken())
 }
 
 // ParseGitlabMergeRequestEvent parses GitLab merge request events.
@@ -545,11 +605,11 @@ func (e *EventParser) ParseGitlabMergeRequestEvent(event gitlab.MergeEvent) (pul
 	// GitLab also has a "merged" state, but we map that to Closed so we don't
 	// need to check for it.
 
-	baseRepo, err = models.NewRepo(models.Gitlab, event.Project.PathWithNamespace, event.Project.GitHTTPURL, e.GitlabUser, e.GitlabToken)
+	baseRepo, err = models.NewRepo(models.Gitlab, event.Project.PathWithNamespace, event.Project.GitHTTPURL, e.GitlabUser, e.gitlabToken())
 	if err != nil {
 		return
 	}
-	headRepo, err = models.NewRepo(models.Gitlab, event.ObjectAttributes.Source.PathWithNamespace, event.ObjectAttributes.Source.GitHTTPURL, e.GitlabUser, e.GitlabToken)
+	headRepo, err = models.NewRepo(models.Gitlab, event.ObjectAttributes.Source.PathWithNamespace, event.ObjectAttributes.Source.GitHTTPURL, e.GitlabUser, e.gitlabToken())
 	if err != nil {
 		return
 	}
@@ -557,6 +617,7 @@ func (e *EventParser) ParseGitlabMergeRequestEvent(event gitlab.MergeEvent) (pul
 	pull = models.PullRequest{
 		URL:        event.ObjectAttributes.URL,
 		Author:     event.User.Username,
+		Title:      event.ObjectAttributes.Title,
 		Num:        event.ObjectAttributes.IID,
 		HeadCommit: event.ObjectAttributes.LastCommit.ID,
 		HeadBranch: event.ObjectAttributes.SourceBranch,
@@ -590,7 +651,7 @@ func (e *EventParser) ParseGitlabMergeRequestCommentEvent(event gitlab.MergeComm
 	// Parse the base repo first.
 	repoFullName := event.Project.PathWithNamespace
 	cloneURL := event.Project.GitHTTPURL
-	baseRepo, err = models.NewRepo(models.Gitlab, repoFullName, cloneURL, e.GitlabUser, e.GitlabToken)
+	baseRepo, err = models.NewRepo(models.Gitlab, repoFullName, cloneURL, e.GitlabUser, e.gitlabToken())
 	if err != nil {
 		return
 	}
@@ -601,7 +662,7 @@ func (e *EventParser) ParseGitlabMergeRequestCommentEvent(event gitlab.MergeComm
 	// Now parse the head repo.
 	headRepoFullName := event.MergeRequest.Source.PathWithNamespace
 	headCloneURL := event.MergeRequest.Source.GitHTTPURL
-	headRepo, err = models.NewRepo(models.Gitlab, headRepoFullName, headCloneURL, e.GitlabUser, e.GitlabToken)
+	headRepo, err = models.NewRepo(models.Gitlab, headRepoFullName, headCloneURL, e.GitlabUser, e.gitlabToken())
 	return
 }
 
@@ -616,16 +677,22 @@ func (e *EventParser) ParseGitlabMergeRequest(mr *gitlab.MergeRequest, baseRepo
 	}
 	// GitLab also has a "merged" state, but we map that to Closed so we don't
 	// need to check for it.
+	mergeCommit := mr.MergeCommitSHA
+	if mergeCommit == "" {
+		mergeCommit = mr.SquashCommitSHA
+	}
 
 	return models.PullRequest{
-		URL:        mr.WebURL,
-		Author:     mr.Author.Username,
-		Num:        mr.IID,
-		HeadCommit: mr.SHA,
-		HeadBranch: mr.SourceBranch,
-		BaseBranch: mr.TargetBranch,
-		State:      pullState,
-		BaseRepo:   baseRepo,
+		URL:         mr.WebURL,
+		Author:      mr.Author.Username,
+		Title:       mr.Title,
+		Num:         mr.IID,
+		HeadCommit:  mr.SHA,
+		HeadBranch:  mr.SourceBranch,
+		BaseBranch:  mr.TargetBranch,
+		State:       pullState,
+		MergeCommit: mergeCommit,
+		BaseRepo:    baseRepo,
 	}
 }
 
@@ -706,6 +773,7 @@ func (e *EventParser) parseCommonBitbucketServerEventData(event bitbucketserver.
 		HeadBranch: *event.PullRequest.FromRef.DisplayID,
 		BaseBranch: *event.PullRequest.ToRef.DisplayID,
 		Author:     *event.Actor.Username,
+		Title:      event.PullRequest.GetTitle(),
 		State:      prState,
 		BaseRepo:   baseRepo,
 	}
@@ -823,6 +891,7 @@ func (e *EventParser) ParseAzureDevopsPull(pull *azuredevops.GitPullRequest) (pu
 
 	pullModel = models.PullRequest{
 		Author: authorUsername,
+		Title:  pull.GetTitle(),
 		// Change webhook refs from "refs/heads/<branch>" to "<branch>"
 		HeadBranch: strings.Replace(headBranch, "refs/heads/", "", 1),
 		HeadCommit: commit,
@@ -871,5 +940,74 @@ func (e *EventParser) ParseAzureDevopsRepo(adRepo *azuredevops.GitRepository) (m
 	repo := adRepo.GetName()
 	cloneURL := fmt.Sprintf("https://dev.azure.com/%s/%s/_git/%s", owner, project, repo)
 	fullName := fmt.Sprintf("%s/%s/%s", owner, project, repo)
-	return models.NewRepo(models.AzureDevops, fullName, cloneURL, e.AzureDevopsUser, e.AzureDevopsToken)
+	creds := e.azureDevopsCredentials(owner)
+	return models.NewRepo(models.AzureDevops, fullName, cloneURL, creds.User, creds.Token)
+}
+
+// ParseAPIRepo constructs the clone URL of repoFullName from the configured
+// hostnames since API requests only name the repo.
+func (e *EventParser) ParseAPIRepo(vcsHostType models.VCSHostType, repoFullName string) (models.Repo, error) {
+	switch vcsHostType {
+	case models.Github:
+		cloneURL := fmt.Sprintf("https://%s/%s.git", e.GithubHostname, repoFullName)
+		return models.NewRepo(vcsHostType, repoFullName, cloneURL, e.GithubUser, e.githubToken())
+	case models.Gitlab:
+		cloneURL := fmt.Sprintf("https://%s/%s.git", e.GitlabHostname, repoFullName)
+		return models.NewRepo(vcsHostType, repoFullName, cloneURL, e.GitlabUser, e.gitlabToken())
+	case models.BitbucketCloud:
+		cloneURL := fmt.Sprintf("https://bitbucket.org/%s.git", repoFullName)
+		gitUser, gitToken, err := e.bitbucketCloudGitCredentials()
+		if err != nil {
+			return models.Repo{}, err
+		}
+		return models.NewRepo(vcsHostType, repoFullName, cloneURL, gitUser, gitToken)
+	case models.AzureDevops:
+		// Azure DevOps repos are named owner/project/repo.
+		parts := strings.Split(repoFullName, "/")
+		if len(parts) != 3 {
+			return models.Repo{}, fmt.Errorf("Azure DevOps repos must be of the form owner/project/repo, got %q", repoFullName)
+		}
+		cloneURL := fmt.Sprintf("https://dev.azure.com/%s/%s/_git/%s", parts[0], parts[1], parts[2])
+		creds := e.azureDevopsCredentials(parts[0])
+		return models.NewRepo(vcsHostType, repoFullName, cloneURL, creds.User, creds.Token)
+	}
+	// Bitbucket Server repos are named after the project's name but cloned
+	// using its key so we can't construct the clone URL.
+	return models.Repo{}, fmt.Errorf("%s repos aren't supported", vcsHostType)
+}
+
+// githubToken returns the current GitHub token.
+func (e *EventParser) githubToken() string {
+	if e.GithubCredentials != nil {
+		token, _ := e.GithubCredentials.GetToken()
+		return token
+	}
+	return e.GithubToken
+}
+
+// gitlabToken returns the current GitLab token.
+func (e *EventParser) gitlabToken() string {
+	if e.GitlabCredentials != nil {
+		return e.GitlabCredentials.Token()
+	}
+	return e.GitlabToken
+}
+
+// bitbucketCloudGitCredentials returns the current user and password of
+// Bitbucket Cloud clone URLs.
+func (e *EventParser) bitbucketCloudGitCredentials() (string, string, error) {
+	if e.BitbucketCloudCredentials != nil {
+		user, token, err := e.BitbucketCloudCredentials.GitCredentials()
+		return user, token, errors.Wrap(err, "getting Bitbucket Cloud credentials")
+	}
+	return e.BitbucketUser, e.BitbucketToken, nil
+}
+
+// azureDevopsCredentials returns the credentials of the clone URLs of the
+// Azure DevOps organization org.
+func (e *EventParser) azureDevopsCredentials(org string) AzureDevopsCredentials {
+	if creds, ok := e.AzureDevopsOrgs[strings.ToLower(org)]; ok {
+		return creds
+	}
+	return AzureDevopsCredentials{User: e.AzureDevopsUser, Token: e.AzureDevopsToken}
 }
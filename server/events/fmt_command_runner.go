@@ -0,0 +1,205 @@
+package events
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+)
+
+// fmtCommitMessage is the message of the commits pushed by atlantis fmt --fix.
+const fmtCommitMessage = "Format Terraform files\n\nPushed by atlantis fmt --fix."
+
+func NewFmtCommandRunner(
+	prjCmdBuilder ProjectPlanCommandBuilder,
+	terraformExec runtime.TerraformExec,
+	workingDir WorkingDir,
+	workingDirLocker WorkingDirLocker,
+	vcsClient vcs.Client,
+	SilenceNoProjects bool,
+) *FmtCommandRunner {
+	return &FmtCommandRunner{
+		prjCmdBuilder:     prjCmdBuilder,
+		terraformExec:     terraformExec,
+		workingDir:        workingDir,
+		workingDirLocker:  workingDirLocker,
+		vcsClient:         vcsClient,
+		SilenceNoProjects: SilenceNoProjects,
+	}
+}
+
+// FmtCommandRunner checks that the Terraform files of projects are formatted
+// with terraform fmt and comments the changes needed to format them. With
+// --fix, it pushes a commit with the changes to the pull request's branch so
+// formatting is enforced without leaving the pull request.
+type FmtCommandRunner struct {
+	prjCmdBuilder    ProjectPlanCommandBuilder
+	terraformExec    runtime.TerraformExec
+	workingDir       WorkingDir
+	workingDirLocker WorkingDirLocker
+	vcsClient        vcs.Client
+	// SilenceNoProjects is whether Atlantis should respond to PRs if no projects
+	// are found
+	SilenceNoProjects bool
+}
+
+func (f *FmtCommandRunner) Run(
+	ctx *CommandContext,
+	cmd *CommentCommand,
+) {
+	// The projects are found like for plan so that the same flags select
+	// them, which also clones the pull request.
+	projectCmds, err := f.prjCmdBuilder.BuildPlanCommands(ctx, cmd)
+	if err != nil {
+		ctx.Log.Err("failed to build fmt commands: %s", err)
+		ctx.CommandError = err
+		f.commentErr(ctx, err.Error())
+		return
+	}
+	// Projects in the same dir, ex. in different workspaces, have the same
+	// files so they're only checked once.
+	var dirCmds []models.ProjectCommandContext
+	seen := make(map[string]bool)
+	for _, projectCmd := range projectCmds {
+		if !seen[projectCmd.RepoRelDir] {
+			seen[projectCmd.RepoRelDir] = true
+			dirCmds = append(dirCmds, projectCmd)
+		}
+	}
+	if len(dirCmds) == 0 {
+		if !f.SilenceNoProjects {
+			f.comment(ctx, "Ran fmt for 0 directories.")
+		}
+		return
+	}
+
+	// Every dir is checked in the clone of the first project's workspace so
+	// that a single commit formats all of them.
+	workspace := dirCmds[0].Workspace
+	unlockFn, err := f.workingDirLocker.TryLock(ctx.Pull.BaseRepo.FullName, ctx.Pull.Num, workspace)
+	if err != nil {
+		ctx.Log.Err("failed to lock working dir: %s", err)
+		ctx.CommandError = err
+		f.commentErr(ctx, err.Error())
+		return
+	}
+	defer unlockFn()
+	repoDir, err := f.workingDir.GetWorkingDir(ctx.Pull.BaseRepo, ctx.Pull, workspace)
+	if err != nil {
+		ctx.Log.Err("failed to get working dir: %s", err)
+		ctx.CommandError = err
+		f.commentErr(ctx, err.Error())
+		return
+	}
+
+	var sections []string
+	var unformatted []string
+	for _, dirCmd := range dirCmds {
+		description := fmt.Sprintf("dir: `%s`", dirCmd.RepoRelDir)
+		diff, err := f.check(ctx, dirCmd, repoDir, cmd.Flags)
+		if err != nil {
+			ctx.Log.Err("failed to run fmt for %s: %s", description, err)
+			ctx.CommandError = err
+			sections = append(sections, fmt.Sprintf("### %s\n**Fmt Error**\n```\n%s\n```", description, err))
+			continue
+		}
+		if diff == "" {
+			sections = append(sections, fmt.Sprintf("### %s\n:white_check_mark: Formatted.", description))
+			continue
+		}
+		sections = append(sections, fmt.Sprintf("### %s\n:x: Not formatted:\n```diff\n%s\n```", description, strings.TrimSpace(diff)))
+		unformatted = append(unformatted, dirCmd.RepoRelDir)
+	}
+
+	dirs := "directories"
+	if len(dirCmds) == 1 {
+		dirs = "directory"
+	}
+	comment := fmt.Sprintf("Ran fmt for %d %s:\n\n%s", len(dirCmds), dirs, strings.Join(sections, "\n\n"))
+	if len(unformatted) > 0 {
+		if cmd.Fix {
+			comment += "\n\n---\n" + f.fix(ctx, dirCmds, repoDir, unformatted, workspace, cmd.Flags)
+		} else {
+			ctx.CommandFailure = "files aren't formatted"
+			comment += fmt.Sprintf("\n\n---\n* :wrench: To push a commit that formats these files, comment:\n    * `%s`", fixCommentCommand(cmd))
+		}
+	}
+	f.comment(ctx, comment)
+}
+
+// check runs terraform fmt without writing the files of the dir of dirCmd
+// and returns the diff of the changes needed to format them, which is empty
+// if they're formatted.
+func (f *FmtCommandRunner) check(ctx *CommandContext, dirCmd models.ProjectCommandContext, repoDir string, extraArgs []string) (string, error) {
+	absPath := filepath.Join(repoDir, dirCmd.RepoRelDir)
+	if _, err := os.Stat(absPath); os.IsNotExist(err) {
+		return "", DirNotExistErr{RepoRelDir: dirCmd.RepoRelDir}
+	}
+	args := append([]string{"fmt", "-write=false", "-list=false", "-diff", "-no-color"}, extraArgs...)
+	out, err := f.terraformExec.RunCommandWithVersion(ctx.Log, absPath, args, nil, dirCmd.TerraformVersion, dirCmd.Workspace)
+	if err != nil {
+		return "", fmt.Errorf("%s\n%s", err, out)
+	}
+	return out, nil
+}
+
+// fix formats the files of the unformatted dirs and pushes a commit with the
+// changes. It returns what happened for the comment.
+func (f *FmtCommandRunner) fix(ctx *CommandContext, dirCmds []models.ProjectCommandContext, repoDir string, unformatted []string, workspace string, extraArgs []string) string {
+	toFormat := make(map[string]bool)
+	for _, dir := range unformatted {
+		toFormat[dir] = true
+	}
+	for _, dirCmd := range dirCmds {
+		if !toFormat[dirCmd.RepoRelDir] {
+			continue
+		}
+		args := append([]string{"fmt", "-list=false", "-no-color"}, extraArgs...)
+		if out, err := f.terraformExec.RunCommandWithVersion(ctx.Log, filepath.Join(repoDir, dirCmd.RepoRelDir), args, nil, dirCmd.TerraformVersion, workspace); err != nil {
+			ctx.Log.Err("failed to format dir %q: %s: %s", dirCmd.RepoRelDir, err, out)
+			ctx.CommandError = err
+			return fmt.Sprintf("**Fmt Error**: unable to format dir `%s`:\n```\n%s\n%s\n```", dirCmd.RepoRelDir, err, out)
+		}
+	}
+	commit, err := f.workingDir.PushCommit(ctx.Log, ctx.HeadRepo, ctx.Pull, workspace, unformatted, fmtCommitMessage)
+	if err != nil {
+		ctx.Log.Err("failed to push formatting commit: %s", err)
+		ctx.CommandError = err
+		return fmt.Sprintf("**Fmt Error**: unable to push a commit that formats these files:\n```\n%s\n```", err)
+	}
+	ctx.Log.Info("pushed formatting commit %s", commit)
+	if len(commit) > 7 {
+		commit = commit[:7]
+	}
+	return fmt.Sprintf(":wrench: Pushed `%s` that formats these files to `%s`.", commit, ctx.Pull.HeadBranch)
+}
+
+// fixCommentCommand returns the comment that runs cmd with --fix.
+func fixCommentCommand(cmd *CommentCommand) string {
+	args := []string{atlantisExecutable, models.FmtCommand.String()}
+	if cmd.RepoRelDir != "" {
+		args = append(args, "-"+dirFlagShort, cmd.RepoRelDir)
+	}
+	if cmd.ProjectName != "" {
+		args = append(args, "-"+projectFlagShort, cmd.ProjectName)
+	}
+	args = append(args, "--"+fixFlagLong)
+	if len(cmd.Flags) > 0 {
+		args = append(append(args, "--"), cmd.Flags...)
+	}
+	return strings.Join(args, " ")
+}
+
+func (f *FmtCommandRunner) commentErr(ctx *CommandContext, err string) {
+	f.comment(ctx, fmt.Sprintf("**Fmt Error**\n```\n%s\n```", err))
+}
+
+func (f *FmtCommandRunner) comment(ctx *CommandContext, comment string) {
+	if commentErr := f.vcsClient.CreateComment(ctx.Pull.BaseRepo, ctx.Pull.Num, comment, models.FmtCommand.String()); commentErr != nil {
+		ctx.Log.Err("unable to comment: %s", commentErr)
+	}
+}
@@ -0,0 +1,115 @@
+package events_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/models/fixtures"
+	tfmocks "github.com/runatlantis/atlantis/server/events/terraform/mocks"
+	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+var fmtCheckArgs = []string{"fmt", "-write=false", "-list=false", "-diff", "-no-color"}
+var fmtWriteArgs = []string{"fmt", "-list=false", "-no-color"}
+
+const fmtDiff = `app/main.tf
+--- old/app/main.tf
++++ new/app/main.tf
+@@ -1,3 +1,3 @@
+ resource "null_resource" "app" {
+-  triggers = { a="b" }
++  triggers = { a = "b" }
+ }
+`
+
+func TestFmtCommandRunner_Check(t *testing.T) {
+	RegisterMockTestingT(t)
+	repoDir, cleanup := TempDir(t)
+	defer cleanup()
+	Ok(t, os.Mkdir(filepath.Join(repoDir, "app"), 0700))
+	Ok(t, os.Mkdir(filepath.Join(repoDir, "network"), 0700))
+
+	builder := mocks.NewMockProjectCommandBuilder()
+	tf := tfmocks.NewMockClient()
+	workingDir := mocks.NewMockWorkingDir()
+	vcsClient := vcsmocks.NewMockClient()
+	runner := events.NewFmtCommandRunner(builder, tf, workingDir, events.NewDefaultWorkingDirLocker(), vcsClient, false)
+
+	logger := logging.NewNoopLogger(t)
+	pull := fixtures.Pull
+	pull.BaseRepo = fixtures.GithubRepo
+	ctx := &events.CommandContext{Pull: pull, HeadRepo: fixtures.GithubRepo, User: fixtures.User, Log: logger}
+	cmd := &events.CommentCommand{Name: models.FmtCommand}
+	When(builder.BuildPlanCommands(ctx, cmd)).ThenReturn([]models.ProjectCommandContext{
+		{Log: logger, Pull: pull, RepoRelDir: "app", Workspace: "default"},
+		{Log: logger, Pull: pull, RepoRelDir: "app", Workspace: "staging"},
+		{Log: logger, Pull: pull, RepoRelDir: "network", Workspace: "default"},
+	}, nil)
+	When(workingDir.GetWorkingDir(fixtures.GithubRepo, pull, "default")).ThenReturn(repoDir, nil)
+	When(tf.RunCommandWithVersion(logger, filepath.Join(repoDir, "app"), fmtCheckArgs, map[string]string(nil), nil, "default")).
+		ThenReturn(fmtDiff, nil)
+	When(tf.RunCommandWithVersion(logger, filepath.Join(repoDir, "network"), fmtCheckArgs, map[string]string(nil), nil, "default")).
+		ThenReturn("", nil)
+
+	runner.Run(ctx, cmd)
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, pull.Num, "Ran fmt for 2 directories:\n\n"+
+		"### dir: `app`\n:x: Not formatted:\n```diff\n"+fmtDiff+"```\n\n"+
+		"### dir: `network`\n:white_check_mark: Formatted.\n\n"+
+		"---\n* :wrench: To push a commit that formats these files, comment:\n    * `atlantis fmt --fix`",
+		"fmt")
+	tf.VerifyWasCalled(Never()).RunCommandWithVersion(logger, filepath.Join(repoDir, "app"), fmtWriteArgs, map[string]string(nil), nil, "default")
+	workingDir.VerifyWasCalled(Never()).PushCommit(logger, fixtures.GithubRepo, pull, "default", []string{"app"}, "Format Terraform files\n\nPushed by atlantis fmt --fix.")
+}
+
+func TestFmtCommandRunner_Fix(t *testing.T) {
+	RegisterMockTestingT(t)
+	repoDir, cleanup := TempDir(t)
+	defer cleanup()
+	Ok(t, os.Mkdir(filepath.Join(repoDir, "app"), 0700))
+
+	builder := mocks.NewMockProjectCommandBuilder()
+	tf := tfmocks.NewMockClient()
+	workingDir := mocks.NewMockWorkingDir()
+	vcsClient := vcsmocks.NewMockClient()
+	runner := events.NewFmtCommandRunner(builder, tf, workingDir, events.NewDefaultWorkingDirLocker(), vcsClient, false)
+
+	logger := logging.NewNoopLogger(t)
+	pull := fixtures.Pull
+	pull.BaseRepo = fixtures.GithubRepo
+	pull.HeadBranch = "feature"
+	ctx := &events.CommandContext{Pull: pull, HeadRepo: fixtures.GithubRepo, User: fixtures.User, Log: logger}
+	cmd := &events.CommentCommand{Name: models.FmtCommand, RepoRelDir: "app", Fix: true}
+	When(builder.BuildPlanCommands(ctx, cmd)).ThenReturn([]models.ProjectCommandContext{
+		{Log: logger, Pull: pull, RepoRelDir: "app", Workspace: "default"},
+	}, nil)
+	When(workingDir.GetWorkingDir(fixtures.GithubRepo, pull, "default")).ThenReturn(repoDir, nil)
+	When(tf.RunCommandWithVersion(logger, filepath.Join(repoDir, "app"), fmtCheckArgs, map[string]string(nil), nil, "default")).
+		ThenReturn(fmtDiff, nil)
+	When(tf.RunCommandWithVersion(logger, filepath.Join(repoDir, "app"), fmtWriteArgs, map[string]string(nil), nil, "default")).
+		ThenReturn("", nil)
+	When(workingDir.PushCommit(logger, fixtures.GithubRepo, pull, "default", []string{"app"}, "Format Terraform files\n\nPushed by atlantis fmt --fix.")).
+		ThenReturn("0123456789abcdef", nil)
+
+	runner.Run(ctx, cmd)
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, pull.Num, "Ran fmt for 1 directory:\n\n"+
+		"### dir: `app`\n:x: Not formatted:\n```diff\n"+fmtDiff+"```\n\n"+
+		"---\n:wrench: Pushed `0123456` that formats these files to `feature`.",
+		"fmt")
+
+	// Errors pushing, ex. without access to the head branch, are commented.
+	When(workingDir.PushCommit(logger, fixtures.GithubRepo, pull, "default", []string{"app"}, "Format Terraform files\n\nPushed by atlantis fmt --fix.")).
+		ThenReturn("", errors.New("permission denied"))
+	runner.Run(ctx, cmd)
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, pull.Num, "Ran fmt for 1 directory:\n\n"+
+		"### dir: `app`\n:x: Not formatted:\n```diff\n"+fmtDiff+"```\n\n"+
+		"---\n**Fmt Error**: unable to push a commit that formats these files:\n```\npermission denied\n```",
+		"fmt")
+}
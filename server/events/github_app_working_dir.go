@@ -22,21 +22,8 @@ type GithubAppWorkingDir struct {
 
 // Clone writes a fresh token for Github App authentication
 func (g *GithubAppWorkingDir) Clone(log logging.SimpleLogging, headRepo models.Repo, p models.PullRequest, workspace string) (string, bool, error) {
-
-	log.Info("Refreshing git tokens for Github App")
-
-	token, err := g.Credentials.GetToken()
-	if err != nil {
-		return "", false, errors.Wrap(err, "getting github token")
-	}
-
-	home, err := homedir.Dir()
+	token, err := g.refreshToken(log)
 	if err != nil {
-		return "", false, errors.Wrap(err, "getting home dir to write ~/.git-credentials file")
-	}
-
-	// https://developer.github.com/apps/building-github-apps/authenticating-with-github-apps/#http-based-git-access-by-an-installation
-	if err := WriteGitCreds("x-access-token", token, g.GithubHostname, home, log, true); err != nil {
 		return "", false, err
 	}
 
@@ -53,3 +40,36 @@ func (g *GithubAppWorkingDir) Clone(log logging.SimpleLogging, headRepo models.R
 
 	return g.WorkingDir.Clone(log, headRepo, p, workspace)
 }
+
+// RemoteBranchSHA writes a fresh token for Github App authentication
+func (g *GithubAppWorkingDir) RemoteBranchSHA(log logging.SimpleLogging, repo models.Repo, branch string) (string, error) {
+	token, err := g.refreshToken(log)
+	if err != nil {
+		return "", err
+	}
+	repo.CloneURL = strings.Replace(repo.CloneURL, "://:", fmt.Sprintf("://x-access-token:%s", token), 1)
+	repo.SanitizedCloneURL = strings.Replace(repo.SanitizedCloneURL, "://:", "://x-access-token:", 1)
+	return g.WorkingDir.RemoteBranchSHA(log, repo, branch)
+}
+
+// refreshToken gets a fresh token for the Github App, writes it to the
+// ~/.git-credentials file and returns it.
+func (g *GithubAppWorkingDir) refreshToken(log logging.SimpleLogging) (string, error) {
+	log.Info("Refreshing git tokens for Github App")
+
+	token, err := g.Credentials.GetToken()
+	if err != nil {
+		return "", errors.Wrap(err, "getting github token")
+	}
+
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", errors.Wrap(err, "getting home dir to write ~/.git-credentials file")
+	}
+
+	// https://developer.github.com/apps/building-github-apps/authenticating-with-github-apps/#http-based-git-access-by-an-installation
+	if err := WriteGitCreds("x-access-token", token, g.GithubHostname, home, log, true); err != nil {
+		return "", err
+	}
+	return token, nil
+}
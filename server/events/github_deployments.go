@@ -0,0 +1,86 @@
+package events
+
+import (
+	"fmt"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// GithubDeploymentsClient creates GitHub deployments. It's implemented by
+// vcs.GithubClient.
+type GithubDeploymentsClient interface {
+	CreateDeployment(repo models.Repo, ref string, environment string, description string, payload map[string]interface{}) (int64, error)
+	CreateDeploymentStatus(repo models.Repo, deploymentID int64, state string, description string, logURL string) error
+}
+
+// GithubDeployments records the applies of projects in GitHub repos as GitHub
+// deployments so they're shown in the Environments tab of the repo and are
+// subject to the deployment branch rules of its environments. Its methods do
+// nothing if it's nil or the repo isn't a GitHub repo.
+type GithubDeployments struct {
+	Client GithubDeploymentsClient
+}
+
+// Start creates the deployment of the apply of ctx in the in_progress state
+// and returns its ID. logURL links to the apply's output, if it's not empty.
+// If GitHub refuses to create the deployment, ex. because the environment
+// doesn't allow deployments from the pull request's branch, the apply
+// shouldn't run.
+func (d *GithubDeployments) Start(ctx models.ProjectCommandContext, logURL string) (int64, error) {
+	if d == nil || ctx.Pull.BaseRepo.VCSHost.Type != models.Github {
+		return 0, nil
+	}
+	// Deployments of branches are checked against the deployment branch
+	// rules, but the branches of forks aren't in the base repo.
+	ref := ctx.Pull.HeadBranch
+	if ctx.HeadRepo.FullName != ctx.Pull.BaseRepo.FullName {
+		ref = ctx.Pull.HeadCommit
+	}
+	payload := map[string]interface{}{
+		"dir":       ctx.RepoRelDir,
+		"workspace": ctx.Workspace,
+		"pull":      ctx.Pull.Num,
+	}
+	if ctx.ProjectName != "" {
+		payload["project"] = ctx.ProjectName
+	}
+	description := fmt.Sprintf("Atlantis apply of %s from #%d", DeploymentEnvironment(ctx), ctx.Pull.Num)
+	id, err := d.Client.CreateDeployment(ctx.Pull.BaseRepo, ref, DeploymentEnvironment(ctx), description, payload)
+	if err != nil {
+		return 0, err
+	}
+	if err := d.Client.CreateDeploymentStatus(ctx.Pull.BaseRepo, id, "in_progress", "Applying.", logURL); err != nil {
+		ctx.Log.Warn("unable to set deployment %d in progress: %s", id, err)
+	}
+	return id, nil
+}
+
+// Finish sets the state of the deployment deploymentID, created by Start, to
+// whether the apply succeeded. Errors are only logged since the apply already
+// ran.
+func (d *GithubDeployments) Finish(ctx models.ProjectCommandContext, deploymentID int64, applyErr error, logURL string) {
+	if d == nil || deploymentID == 0 {
+		return
+	}
+	state, description := "success", "Apply succeeded."
+	if applyErr != nil {
+		state, description = "failure", "Apply failed."
+	}
+	if err := d.Client.CreateDeploymentStatus(ctx.Pull.BaseRepo, deploymentID, state, description, logURL); err != nil {
+		ctx.Log.Err("unable to set state of deployment %d to %s: %s", deploymentID, state, err)
+	}
+}
+
+// DeploymentEnvironment returns the name of the GitHub environment that the
+// apply of ctx deploys to: the project's name, or else its workspace unless
+// it's the default workspace, or else its dir.
+func DeploymentEnvironment(ctx models.ProjectCommandContext) string {
+	switch {
+	case ctx.ProjectName != "":
+		return ctx.ProjectName
+	case ctx.Workspace != DefaultWorkspace:
+		return ctx.Workspace
+	default:
+		return ctx.RepoRelDir
+	}
+}
@@ -0,0 +1,121 @@
+package events_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+type fakeDeploymentsClient struct {
+	deployErr   error
+	ref         string
+	environment string
+	description string
+	payload     map[string]interface{}
+	states      []string
+}
+
+func (f *fakeDeploymentsClient) CreateDeployment(repo models.Repo, ref string, environment string, description string, payload map[string]interface{}) (int64, error) {
+	if f.deployErr != nil {
+		return 0, f.deployErr
+	}
+	f.ref, f.environment, f.description, f.payload = ref, environment, description, payload
+	return 42, nil
+}
+
+func (f *fakeDeploymentsClient) CreateDeploymentStatus(repo models.Repo, deploymentID int64, state string, description string, logURL string) error {
+	f.states = append(f.states, state+": "+description+" "+logURL)
+	return nil
+}
+
+func deploymentCtx(t *testing.T) models.ProjectCommandContext {
+	repo := models.Repo{FullName: "owner/repo", VCSHost: models.VCSHost{Type: models.Github}}
+	return models.ProjectCommandContext{
+		Log:         logging.NewNoopLogger(t),
+		Pull:        models.PullRequest{Num: 1, HeadBranch: "branch", HeadCommit: "abc123", BaseRepo: repo},
+		HeadRepo:    repo,
+		RepoRelDir:  "dir",
+		Workspace:   "default",
+		ProjectName: "project",
+	}
+}
+
+func TestGithubDeployments(t *testing.T) {
+	client := &fakeDeploymentsClient{}
+	d := &events.GithubDeployments{Client: client}
+	ctx := deploymentCtx(t)
+
+	id, err := d.Start(ctx, "url")
+	Ok(t, err)
+	Equals(t, int64(42), id)
+	Equals(t, "branch", client.ref)
+	Equals(t, "project", client.environment)
+	Equals(t, "Atlantis apply of project from #1", client.description)
+	Equals(t, map[string]interface{}{"dir": "dir", "workspace": "default", "pull": 1, "project": "project"}, client.payload)
+
+	d.Finish(ctx, id, errors.New("err"), "url")
+	Equals(t, []string{"in_progress: Applying. url", "failure: Apply failed. url"}, client.states)
+}
+
+func TestGithubDeployments_Fork(t *testing.T) {
+	client := &fakeDeploymentsClient{}
+	d := &events.GithubDeployments{Client: client}
+	ctx := deploymentCtx(t)
+	ctx.HeadRepo.FullName = "fork/repo"
+
+	id, err := d.Start(ctx, "")
+	Ok(t, err)
+	Equals(t, "abc123", client.ref)
+	d.Finish(ctx, id, nil, "")
+	Equals(t, []string{"in_progress: Applying. ", "success: Apply succeeded. "}, client.states)
+}
+
+func TestGithubDeployments_Refused(t *testing.T) {
+	client := &fakeDeploymentsClient{deployErr: errors.New("branch not allowed")}
+	d := &events.GithubDeployments{Client: client}
+
+	_, err := d.Start(deploymentCtx(t), "")
+	ErrEquals(t, "branch not allowed", err)
+	Equals(t, 0, len(client.states))
+}
+
+// Test that nothing is recorded if deployments are disabled or the repo isn't
+// a GitHub repo.
+func TestGithubDeployments_Skipped(t *testing.T) {
+	var disabled *events.GithubDeployments
+	id, err := disabled.Start(deploymentCtx(t), "")
+	Ok(t, err)
+	Equals(t, int64(0), id)
+	disabled.Finish(deploymentCtx(t), id, nil, "")
+
+	client := &fakeDeploymentsClient{}
+	d := &events.GithubDeployments{Client: client}
+	ctx := deploymentCtx(t)
+	ctx.Pull.BaseRepo.VCSHost.Type = models.Gitlab
+	id, err = d.Start(ctx, "")
+	Ok(t, err)
+	d.Finish(ctx, id, nil, "")
+	Equals(t, 0, len(client.states))
+}
+
+func TestDeploymentEnvironment(t *testing.T) {
+	cases := []struct {
+		projectName string
+		workspace   string
+		exp         string
+	}{
+		{"project", "staging", "project"},
+		{"", "staging", "staging"},
+		{"", "default", "dir"},
+	}
+	for _, c := range cases {
+		t.Run(c.exp, func(t *testing.T) {
+			ctx := models.ProjectCommandContext{ProjectName: c.projectName, Workspace: c.workspace, RepoRelDir: "dir"}
+			Equals(t, c.exp, events.DeploymentEnvironment(ctx))
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// GitlabTokenInfoGetter gets the access token that Atlantis authenticates to
+// GitLab with.
+type GitlabTokenInfoGetter interface {
+	GetTokenInfo() (vcs.GitlabTokenInfo, error)
+}
+
+// GitlabTokenStatus is the state of the GitLab access token as of its last
+// check.
+type GitlabTokenStatus struct {
+	// Type is the type of the token: personal, group or project.
+	Type      string     `json:"type"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// ExpiresSoon is true if the token expires within the warning period,
+	// or has expired or been revoked.
+	ExpiresSoon bool `json:"expires_soon"`
+	// Rotations is the number of times the token was reloaded from its file
+	// with a new token.
+	Rotations int64     `json:"rotations"`
+	LastCheck time.Time `json:"last_check"`
+	// Error is why the last check failed, if it did.
+	Error string `json:"error,omitempty"`
+}
+
+// GitlabTokenWatcher reloads the GitLab access token from its file, so that
+// it can be rotated without restarting Atlantis, and warns when it's about to
+// expire. Group and project access tokens always expire.
+type GitlabTokenWatcher struct {
+	Credentials *vcs.GitlabCredentials
+	Client      GitlabTokenInfoGetter
+	Logger      logging.SimpleLogging
+	// Interval is how often the token is checked by Run.
+	Interval time.Duration
+	// WarnBefore is how long before the token expires that warnings are
+	// logged.
+	WarnBefore time.Duration
+	// GitUser and GitHostname are the user and hostname of the token's line
+	// in ~/.git-credentials. If GitUser is empty, the file isn't updated
+	// when the token is rotated.
+	GitUser     string
+	GitHostname string
+	// Now returns the current time. It's only overridden in tests.
+	Now func() time.Time
+
+	mutex  sync.Mutex
+	status GitlabTokenStatus
+}
+
+// Run checks the token now and then every Interval until ctx is done.
+func (w *GitlabTokenWatcher) Run(ctx context.Context) {
+	w.check()
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+// check runs Check and logs its error.
+func (w *GitlabTokenWatcher) check() {
+	if err := w.Check(); err != nil {
+		w.Logger.Err("failed checking GitLab access token: %s", err)
+	}
+}
+
+// Check reloads the token from its file and checks when it expires, logging
+// a warning if it's within WarnBefore. It's also run on SIGHUP so a rotated
+// token can be picked up immediately.
+func (w *GitlabTokenWatcher) Check() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.status.Type = w.Credentials.Type
+	w.status.LastCheck = w.now()
+	err := w.checkLocked()
+	w.status.Error = ""
+	if err != nil {
+		w.status.Error = err.Error()
+	}
+	return err
+}
+
+func (w *GitlabTokenWatcher) checkLocked() error {
+	rotated, err := w.Credentials.Reload()
+	if err != nil {
+		return err
+	}
+	if rotated {
+		w.status.Rotations++
+		w.Logger.Info("reloaded rotated GitLab access token from %s", w.Credentials.File)
+		if err := w.writeGitCreds(); err != nil {
+			return err
+		}
+	}
+
+	info, err := w.Client.GetTokenInfo()
+	if err != nil {
+		return err
+	}
+	w.status.ExpiresAt = info.ExpiresAt
+	w.status.ExpiresSoon = false
+	if info.Revoked || !info.Active {
+		w.status.ExpiresSoon = true
+		return fmt.Errorf("GitLab %s access token %q was revoked or has expired, %s", w.Credentials.Type, info.Name, w.rotateHint())
+	}
+	if info.ExpiresAt != nil && info.ExpiresAt.Sub(w.now()) < w.WarnBefore {
+		w.status.ExpiresSoon = true
+		w.Logger.Warn("GitLab %s access token %q expires on %s, %s", w.Credentials.Type, info.Name, info.ExpiresAt.Format("2006-01-02"), w.rotateHint())
+	}
+	return nil
+}
+
+// rotateHint describes how to rotate the token.
+func (w *GitlabTokenWatcher) rotateHint() string {
+	hint := fmt.Sprintf("create a new one in the %s's access token settings", map[string]string{
+		vcs.GitlabPersonalToken: "user",
+		vcs.GitlabGroupToken:    "group",
+		vcs.GitlabProjectToken:  "project",
+	}[w.Credentials.Type])
+	if w.Credentials.File != "" {
+		return hint + " and write it to " + w.Credentials.File
+	}
+	return hint + " and restart Atlantis with it"
+}
+
+// writeGitCreds replaces the rotated token in ~/.git-credentials.
+func (w *GitlabTokenWatcher) writeGitCreds() error {
+	if w.GitUser == "" {
+		return nil
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return errors.Wrap(err, "getting home dir to write ~/.git-credentials file")
+	}
+	// The line of the previous token is replaced, like for GitHub app
+	// tokens, instead of appending one that git wouldn't use.
+	return WriteGitCreds(w.GitUser, w.Credentials.Token(), w.GitHostname, home, w.Logger, true)
+}
+
+// Status returns the status as of the last check. It returns nil if w is nil
+// or the token hasn't been checked yet.
+func (w *GitlabTokenWatcher) Status() *GitlabTokenStatus {
+	if w == nil {
+		return nil
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.status.LastCheck.IsZero() {
+		return nil
+	}
+	status := w.status
+	return &status
+}
+
+func (w *GitlabTokenWatcher) now() time.Time {
+	if w.Now != nil {
+		return w.Now()
+	}
+	return time.Now()
+}
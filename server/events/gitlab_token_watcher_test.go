@@ -0,0 +1,124 @@
+package events_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+type fakeTokenInfoGetter struct {
+	info vcs.GitlabTokenInfo
+	err  error
+}
+
+func (f *fakeTokenInfoGetter) GetTokenInfo() (vcs.GitlabTokenInfo, error) {
+	return f.info, f.err
+}
+
+func TestGitlabTokenWatcher_Check(t *testing.T) {
+	now := time.Date(2021, 5, 28, 12, 0, 0, 0, time.UTC)
+	in := func(d time.Duration) *time.Time {
+		expiresAt := now.Add(d)
+		return &expiresAt
+	}
+	cases := []struct {
+		description    string
+		info           vcs.GitlabTokenInfo
+		getErr         error
+		expExpiresSoon bool
+		expErr         string
+	}{
+		{
+			description: "never expires",
+			info:        vcs.GitlabTokenInfo{Name: "atlantis", Active: true},
+		},
+		{
+			description: "expires later",
+			info:        vcs.GitlabTokenInfo{Name: "atlantis", Active: true, ExpiresAt: in(30 * 24 * time.Hour)},
+		},
+		{
+			description:    "expires soon",
+			info:           vcs.GitlabTokenInfo{Name: "atlantis", Active: true, ExpiresAt: in(36 * time.Hour)},
+			expExpiresSoon: true,
+		},
+		{
+			description:    "expired",
+			info:           vcs.GitlabTokenInfo{Name: "atlantis", ExpiresAt: in(-time.Hour)},
+			expExpiresSoon: true,
+			expErr:         `GitLab group access token "atlantis" was revoked or has expired, create a new one in the group's access token settings and restart Atlantis with it`,
+		},
+		{
+			description: "unsupported",
+			getErr:      errors.New("404 Not Found"),
+			expErr:      "404 Not Found",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			creds, err := vcs.NewGitlabCredentials(vcs.GitlabGroupToken, "token", "")
+			Ok(t, err)
+			w := &events.GitlabTokenWatcher{
+				Credentials: creds,
+				Client:      &fakeTokenInfoGetter{info: c.info, err: c.getErr},
+				Logger:      logging.NewNoopLogger(t),
+				WarnBefore:  7 * 24 * time.Hour,
+				Now:         func() time.Time { return now },
+			}
+			Assert(t, w.Status() == nil, "exp no status before the first check")
+
+			err = w.Check()
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, err)
+			} else {
+				Ok(t, err)
+			}
+			Equals(t, &events.GitlabTokenStatus{
+				Type:        vcs.GitlabGroupToken,
+				ExpiresAt:   c.info.ExpiresAt,
+				ExpiresSoon: c.expExpiresSoon,
+				LastCheck:   now,
+				Error:       c.expErr,
+			}, w.Status())
+		})
+	}
+}
+
+func TestGitlabTokenWatcher_Rotation(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	file := filepath.Join(tmp, "token")
+	Ok(t, ioutil.WriteFile(file, []byte("token1"), 0600))
+	creds, err := vcs.NewGitlabCredentials(vcs.GitlabProjectToken, "", file)
+	Ok(t, err)
+	w := &events.GitlabTokenWatcher{
+		Credentials: creds,
+		Client:      &fakeTokenInfoGetter{info: vcs.GitlabTokenInfo{Active: true}},
+		Logger:      logging.NewNoopLogger(t),
+	}
+
+	Ok(t, w.Check())
+	Equals(t, int64(0), w.Status().Rotations)
+
+	Ok(t, ioutil.WriteFile(file, []byte("token2"), 0600))
+	Ok(t, w.Check())
+	Equals(t, "token2", creds.Token())
+	Equals(t, int64(1), w.Status().Rotations)
+
+	// An empty file fails the check but keeps the current token.
+	Ok(t, ioutil.WriteFile(file, nil, 0600))
+	Assert(t, w.Check() != nil, "exp err")
+	Equals(t, "token2", creds.Token())
+}
+
+// Test that a nil watcher has no status.
+func TestGitlabTokenWatcher_Nil(t *testing.T) {
+	var w *events.GitlabTokenWatcher
+	Assert(t, w.Status() == nil, "exp nil status")
+}
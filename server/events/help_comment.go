@@ -0,0 +1,131 @@
+package events
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// helpCommands are the commands that the help templates are told whether the
+// user can run.
+var helpCommands = []models.CommandName{models.PlanCommand, models.ApplyCommand, models.UnlockCommand, models.ApprovePoliciesCommand, models.LockCommand, models.RevertCommand, models.WorkspaceCommand, models.FmtCommand, models.ValidateCommand}
+
+// HelpCommentRenderer renders the response to atlantis help with the comment
+// templates of the repo, listing only the commands the commenting user is
+// allowed to run.
+type HelpCommentRenderer struct {
+	// Templates overrides the help templates. If nil, the default templates
+	// are used.
+	Templates *CommentTemplates
+	// CommandAuthorizer hides the commands the user isn't allowed to run. If
+	// nil, every command is listed.
+	CommandAuthorizer CommandAuthorizer
+	ApplyDisabled     bool
+}
+
+// helpData is the data of the help templates.
+type helpData struct {
+	Repo models.Repo
+	User models.User
+	// Commands are whether each command is listed, by name, ex.
+	// {{ if .Commands.apply }}.
+	Commands map[string]bool
+}
+
+// Render renders the help comment for user on repo.
+func (h *HelpCommentRenderer) Render(log logging.SimpleLogging, repo models.Repo, user models.User) string {
+	commands := make(map[string]bool)
+	for _, cmd := range helpCommands {
+		if cmd == models.ApplyCommand && h.ApplyDisabled {
+			continue
+		}
+		if h.CommandAuthorizer == nil {
+			commands[cmd.String()] = true
+			continue
+		}
+		authorized, err := h.CommandAuthorizer.IsAuthorized(repo, user, cmd)
+		if err != nil {
+			// Running the command checks again so listing it is harmless.
+			log.Warn("unable to check if %s can run %s, listing it in help: %s", user.Username, cmd.String(), err)
+			authorized = true
+		}
+		commands[cmd.String()] = authorized
+	}
+	return renderHelp(h.Templates.For(repo), helpData{Repo: repo, User: user, Commands: commands})
+}
+
+// renderHelp renders the help template of tmpls.
+func renderHelp(tmpls *template.Template, data helpData) string {
+	buf := &bytes.Buffer{}
+	if err := tmpls.ExecuteTemplate(buf, helpTmpl, data); err != nil {
+		return fmt.Sprintf("Failed to render template, this is a bug: %v", err)
+	}
+	return buf.String()
+}
+
+// helpNotesTmpl is rendered after the help, ex. for organization-specific
+// usage notes. It's empty unless it's overridden.
+var helpNotesTmpl = commentTemplate("helpNotes", "")
+
+var helpTmpl = commentTemplate("help", "```cmake\n"+
+	`atlantis
+Terraform Pull Request Automation
+
+Usage:
+  atlantis <command> [options] -- [terraform options]
+{{ if or .Commands.plan .Commands.apply }}
+Examples:
+{{- if .Commands.plan }}
+  # run plan in the root directory passing the -target flag to terraform
+  atlantis plan -d . -- -target=resource
+{{ end }}
+{{- if .Commands.apply }}
+  # apply all unapplied plans from this pull request
+  atlantis apply
+
+  # apply the plan for the root directory and staging workspace
+  atlantis apply -d . -w staging
+{{ end }}{{ end }}
+Commands:
+{{- if .Commands.plan }}
+  plan     Runs 'terraform plan' for the changes in this pull request.
+           To plan a specific project, use the -d, -w and -p flags.
+{{- end }}
+{{- if .Commands.apply }}
+  apply    Runs 'terraform apply' on all unapplied plans from this pull request.
+           To only apply a specific plan, use the -d, -w and -p flags.
+{{- end }}
+{{- if .Commands.lock }}
+  lock     Locks the projects without planning them so other PRs can't plan them.
+           To lock a specific project, use the -d, -w and -p flags.
+{{- end }}
+{{- if .Commands.unlock }}
+  unlock   Removes all atlantis locks and discards all plans for this PR.
+           To unlock a specific plan you can use the Atlantis UI.
+{{- end }}
+{{- if .Commands.revert }}
+  revert   Plans the revert of this merged pull request. To open a pull
+           request with the revert, use the --open-pr flag.
+{{- end }}
+{{- if .Commands.workspace }}
+  workspace Creates, deletes or lists the Terraform workspaces of a project,
+            ex. atlantis workspace new pr-123 -d dir.
+{{- end }}
+{{- if .Commands.fmt }}
+  fmt      Checks that the Terraform files changed in this pull request are
+           formatted. To push a commit that formats them, use the --fix flag.
+{{- end }}
+{{- if .Commands.validate }}
+  validate Runs 'terraform validate' for the changes in this pull request.
+           To validate a specific project, use the -d, -w and -p flags.
+{{- end }}
+  help     View help.
+
+Flags:
+  -h, --help   help for atlantis
+
+Use "atlantis [command] --help" for more information about a command.`+
+	"\n```{{ template \"helpNotes\" . }}")
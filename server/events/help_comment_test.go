@@ -0,0 +1,59 @@
+package events_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestHelpCommentRenderer_Render(t *testing.T) {
+	RegisterMockTestingT(t)
+	repo := models.Repo{FullName: "owner/repo", VCSHost: models.VCSHost{Hostname: "github.com"}}
+	user := models.User{Username: "user"}
+	logger := logging.NewNoopLogger(t)
+
+	h := &events.HelpCommentRenderer{}
+	Equals(t, (&events.CommentParser{}).HelpComment(false), h.Render(logger, repo, user))
+
+	authorizer := mocks.NewMockCommandAuthorizer()
+	When(authorizer.IsAuthorized(repo, user, models.PlanCommand)).ThenReturn(true, nil)
+	When(authorizer.IsAuthorized(repo, user, models.ApplyCommand)).ThenReturn(false, nil)
+	When(authorizer.IsAuthorized(repo, user, models.UnlockCommand)).ThenReturn(false, errors.New("error"))
+	When(authorizer.IsAuthorized(repo, user, models.ApprovePoliciesCommand)).ThenReturn(false, nil)
+	h = &events.HelpCommentRenderer{CommandAuthorizer: authorizer}
+	help := h.Render(logger, repo, user)
+	Assert(t, strings.Contains(help, "atlantis plan -d ."), "exp plan in %q", help)
+	Assert(t, !strings.Contains(help, "atlantis apply"), "exp no apply in %q", help)
+	// Unlock is listed since its authorization couldn't be checked.
+	Assert(t, strings.Contains(help, "unlock   Removes"), "exp unlock in %q", help)
+}
+
+func TestHelpCommentRenderer_RenderTemplates(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	repoDir := filepath.Join(tmp, "github.com", "owner", "repo")
+	Ok(t, os.MkdirAll(repoDir, 0700))
+	Ok(t, ioutil.WriteFile(filepath.Join(tmp, "help.tmpl"),
+		[]byte(`{{ define "help" }}Commands:{{ range $name, $ok := .Commands }}{{ if $ok }} {{ $name }}{{ end }}{{ end }}{{ template "helpNotes" . }}{{ end }}`), 0600))
+	Ok(t, ioutil.WriteFile(filepath.Join(repoDir, "helpNotes.tmpl"),
+		[]byte(`{{ define "helpNotes" }} (see the runbook, @{{ .User.Username }}){{ end }}`), 0600))
+	tmpls, err := events.LoadCommentTemplates(tmp)
+	Ok(t, err)
+
+	h := &events.HelpCommentRenderer{Templates: tmpls, ApplyDisabled: true}
+	logger := logging.NewNoopLogger(t)
+	user := models.User{Username: "user"}
+	Equals(t, "Commands: approve_policies fmt lock plan revert unlock validate workspace", h.Render(logger, models.Repo{FullName: "owner/other"}, user))
+	Equals(t, "Commands: approve_policies fmt lock plan revert unlock validate workspace (see the runbook, @user)",
+		h.Render(logger, models.Repo{FullName: "owner/repo", VCSHost: models.VCSHost{Hostname: "github.com"}}, user))
+}
@@ -0,0 +1,24 @@
+package events
+
+import (
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/tracing"
+)
+
+// InstrumentedWorkingDir implements WorkingDir.
+// It records a tracing span for each clone under the span of the command
+// running for the pull request.
+type InstrumentedWorkingDir struct {
+	WorkingDir
+	Tracer *tracing.Tracer
+}
+
+// Clone clones the repo and records how long it took.
+func (i *InstrumentedWorkingDir) Clone(log logging.SimpleLogging, headRepo models.Repo, p models.PullRequest, workspace string) (string, bool, error) {
+	span := i.Tracer.Active(p.BaseRepo.FullName, p.Num).Start("git clone", tracing.String("atlantis.workspace", workspace))
+	dir, hasDiverged, err := i.WorkingDir.Clone(log, headRepo, p, workspace)
+	span.RecordError(err)
+	span.End()
+	return dir, hasDiverged, err
+}
@@ -0,0 +1,88 @@
+package events
+
+import (
+	"bytes"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+)
+
+// InvalidatedPlansCommenter comments which plans new commits to a pull
+// request invalidated so users know to plan them again instead of finding
+// out when apply fails.
+type InvalidatedPlansCommenter struct {
+	VCSClient         vcs.Client
+	PullStatusFetcher PullStatusFetcher
+	// Templates overrides the invalidatedPlans template. If nil, the default
+	// template is used.
+	Templates *CommentTemplates
+}
+
+// invalidatedPlansData is the data of the invalidatedPlans template.
+type invalidatedPlansData struct {
+	Projects []models.ProjectStatus
+}
+
+// Comment comments the projects that had unapplied plans in prev, the status
+// of the pull request before its new commits, and that have no plan at the
+// new head commit, ex. because autoplan is disabled or didn't plan them. It
+// must be called after autoplan so the plans it kept or made aren't listed.
+func (i *InvalidatedPlansCommenter) Comment(ctx *CommandContext, prev *models.PullStatus) {
+	if i == nil || prev == nil || prev.Pull.HeadCommit == ctx.Pull.HeadCommit {
+		return
+	}
+	curr, err := i.PullStatusFetcher.GetPullStatus(ctx.Pull)
+	if err != nil {
+		ctx.Log.Err("unable to fetch pull status to comment invalidated plans: %s", err)
+		return
+	}
+	var invalidated []models.ProjectStatus
+	for _, project := range prev.Projects {
+		if !hasUnappliedPlan(project.Status) {
+			continue
+		}
+		if curr != nil && curr.Pull.HeadCommit == ctx.Pull.HeadCommit && hasUnappliedPlan(statusOf(curr, project)) {
+			continue
+		}
+		invalidated = append(invalidated, project)
+	}
+	if len(invalidated) == 0 {
+		return
+	}
+	buf := &bytes.Buffer{}
+	if err := i.Templates.For(ctx.Pull.BaseRepo).ExecuteTemplate(buf, invalidatedPlansTmpl, invalidatedPlansData{Projects: invalidated}); err != nil {
+		ctx.Log.Err("unable to render invalidated plans comment: %s", err)
+		return
+	}
+	// The comment is for the plan command so that it's hidden with the
+	// previous plan comments when the projects are planned again.
+	if err := i.VCSClient.CreateComment(ctx.Pull.BaseRepo, ctx.Pull.Num, buf.String(), models.PlanCommand.String()); err != nil {
+		ctx.Log.Err("unable to comment invalidated plans: %s", err)
+	}
+}
+
+// hasUnappliedPlan returns whether a project with status has a plan that
+// can be applied.
+func hasUnappliedPlan(status models.ProjectPlanStatus) bool {
+	switch status {
+	case models.PlannedPlanStatus, models.ErroredApplyStatus, models.PassedPolicyCheckStatus, models.ErroredPolicyCheckStatus:
+		return true
+	}
+	return false
+}
+
+// statusOf returns the status of project in pullStatus, or ErroredPlanStatus
+// if it isn't in it.
+func statusOf(pullStatus *models.PullStatus, project models.ProjectStatus) models.ProjectPlanStatus {
+	for _, p := range pullStatus.Projects {
+		if p.RepoRelDir == project.RepoRelDir && p.Workspace == project.Workspace && p.ProjectName == project.ProjectName {
+			return p.Status
+		}
+	}
+	return models.ErroredPlanStatus
+}
+
+var invalidatedPlansTmpl = commentTemplate("invalidatedPlans",
+	"New commits invalidated the plans of {{ len .Projects }} project{{ if ne (len .Projects) 1 }}s{{ end }}."+
+		" Run `atlantis plan` to plan {{ if ne (len .Projects) 1 }}them{{ else }}it{{ end }} again before applying:\n\n"+
+		"{{ range .Projects }}* {{ if .ProjectName }}project: `{{.ProjectName}}` {{ end }}dir: `{{.RepoRelDir}}` workspace: `{{.Workspace}}`\n{{ end }}")
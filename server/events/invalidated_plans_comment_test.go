@@ -0,0 +1,62 @@
+package events_test
+
+import (
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/db"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/models/fixtures"
+	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	"github.com/runatlantis/atlantis/server/events/vcs/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestInvalidatedPlansCommenter_Comment(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	boltDB, err := db.New(tmp)
+	Ok(t, err)
+	vcsClient := vcsmocks.NewMockClient()
+	commenter := &events.InvalidatedPlansCommenter{
+		VCSClient:         vcsClient,
+		PullStatusFetcher: boltDB,
+	}
+
+	oldPull := fixtures.Pull
+	oldPull.HeadCommit = "old"
+	prev, err := boltDB.UpdatePullWithResults(oldPull, []models.ProjectResult{
+		{Command: models.PlanCommand, RepoRelDir: "staging", Workspace: "default", PlanSuccess: &models.PlanSuccess{}},
+		{Command: models.PlanCommand, RepoRelDir: "prod", Workspace: "default", ProjectName: "prod", PlanSuccess: &models.PlanSuccess{}},
+		{Command: models.ApplyCommand, RepoRelDir: "dev", Workspace: "default", ApplySuccess: "applied"},
+		{Command: models.PlanCommand, RepoRelDir: "kept", Workspace: "default", PlanSuccess: &models.PlanSuccess{}},
+	})
+	Ok(t, err)
+
+	pull := fixtures.Pull
+	pull.HeadCommit = "new"
+	ctx := &events.CommandContext{Pull: pull, Log: logging.NewNoopLogger(t)}
+
+	t.Run("same commit", func(t *testing.T) {
+		commenter.Comment(&events.CommandContext{Pull: oldPull, Log: ctx.Log}, &prev)
+		commenter.Comment(ctx, nil)
+		vcsClient.VerifyWasCalled(Never()).CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString(), AnyString())
+	})
+
+	t.Run("new commit", func(t *testing.T) {
+		// Autoplan planned kept again at the new commit.
+		_, err := boltDB.UpdatePullWithResults(pull, []models.ProjectResult{
+			{Command: models.PlanCommand, RepoRelDir: "kept", Workspace: "default", PlanSuccess: &models.PlanSuccess{}},
+		})
+		Ok(t, err)
+		commenter.Comment(ctx, &prev)
+		vcsClient.VerifyWasCalledOnce().CreateComment(pull.BaseRepo, pull.Num,
+			"New commits invalidated the plans of 2 projects. Run `atlantis plan` to plan them again before applying:\n\n"+
+				"* dir: `staging` workspace: `default`\n"+
+				"* project: `prod` dir: `prod` workspace: `default`\n",
+			"plan")
+	})
+}
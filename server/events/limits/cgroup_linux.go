@@ -0,0 +1,71 @@
+package limits
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// cpuPeriod is the period, in microseconds, the CPU time of cgroups is
+// limited over.
+const cpuPeriod = 100000
+
+var cgroupCount uint64
+
+// newCgroup creates a cgroup in the cgroup v2 dir parent limited to
+// memoryBytes of memory and cpus CPUs and returns its dir.
+func newCgroup(parent string, memoryBytes int64, cpus float64) (string, error) {
+	if parent == "" {
+		return "", fmt.Errorf("no cgroup dir to create cgroups in")
+	}
+	dir := filepath.Join(parent, fmt.Sprintf("atlantis-%d-%d", os.Getpid(), atomic.AddUint64(&cgroupCount, 1)))
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return "", err
+	}
+	limits := make(map[string]string)
+	if memoryBytes > 0 {
+		limits["memory.max"] = strconv.FormatInt(memoryBytes, 10)
+		// Swapping would only slow commands down before they're killed.
+		limits["memory.swap.max"] = "0"
+	}
+	if cpus > 0 {
+		limits["cpu.max"] = fmt.Sprintf("%d %d", int64(cpus*cpuPeriod), cpuPeriod)
+	}
+	for file, limit := range limits {
+		err := ioutil.WriteFile(filepath.Join(dir, file), []byte(limit), 0644) // nolint: gosec
+		// Swap isn't accounted for on every system.
+		if os.IsNotExist(err) && file == "memory.swap.max" {
+			continue
+		}
+		if err != nil {
+			os.Remove(dir) // nolint: errcheck
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// oomKilled returns true if a process in the cgroup in dir was killed for
+// using more than its memory limit.
+func oomKilled(dir string) bool {
+	events, err := ioutil.ReadFile(filepath.Join(dir, "memory.events")) // nolint: gosec
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(events), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			return fields[1] != "0"
+		}
+	}
+	return false
+}
+
+// removeCgroup removes the cgroup in dir once its processes have exited.
+func removeCgroup(dir string) error {
+	return os.Remove(dir)
+}
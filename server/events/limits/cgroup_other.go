@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package limits
+
+import "errors"
+
+func newCgroup(parent string, memoryBytes int64, cpus float64) (string, error) {
+	return "", errors.New("memory and CPU limits require cgroups, which are only supported on Linux")
+}
+
+func oomKilled(dir string) bool {
+	return false
+}
+
+func removeCgroup(dir string) error {
+	return nil
+}
@@ -0,0 +1,419 @@
+// Package limits limits the time, output and resources of the commands run
+// for workflow steps.
+package limits
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// KillGracePeriod is how long commands have to exit after they're
+// interrupted for timing out before they're killed. Terraform releases the
+// state lock when it's interrupted.
+var KillGracePeriod = time.Minute
+
+// ErrAborted is the error of commands that were aborted by AbortAll.
+var ErrAborted = errors.New("aborted because Atlantis is shutting down. Terraform was interrupted so its changes may be incomplete")
+
+// running is the commands that have been started and not waited for.
+var running = struct {
+	sync.Mutex
+	cmds    map[*Cmd]struct{}
+	aborted bool
+}{cmds: make(map[*Cmd]struct{})}
+
+// Config is the limits of commands. A nil *Config doesn't limit them.
+type Config struct {
+	// Timeout is how long commands can run before they're killed. If 0, they
+	// can run forever.
+	Timeout time.Duration
+	// IdleTimeout is how long commands can run without producing output
+	// before they're considered hung and killed. If 0, they can be silent
+	// forever.
+	IdleTimeout time.Duration
+	// MaxOutputBytes is how much of the output of commands is kept. The rest
+	// is discarded. If 0, all of it is kept.
+	MaxOutputBytes int
+	// MemoryBytes is how much memory commands can use before they're killed.
+	// If 0, it isn't limited. Requires CgroupDir.
+	MemoryBytes int64
+	// CPUs is how many CPUs commands can use. If 0, it isn't limited.
+	// Requires CgroupDir.
+	CPUs float64
+	// CgroupDir is the cgroup v2 dir the cgroups of commands are created in
+	// when their memory or CPUs are limited. Only supported on Linux.
+	CgroupDir string
+}
+
+// TimeoutError is the error of commands that were killed for timing out.
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (t *TimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s and was killed", t.Timeout)
+}
+
+// HungError is the error of commands that were killed for producing no
+// output for IdleTimeout, which usually means they're stuck, ex. waiting on a
+// provider plugin or a network connection.
+type HungError struct {
+	IdleTimeout time.Duration
+	// Processes is the process group of the command when it was killed, as
+	// listed by ps.
+	Processes string
+	// Goroutines is the goroutines of Atlantis when the command was killed.
+	Goroutines string
+}
+
+func (h *HungError) Error() string {
+	return fmt.Sprintf("produced no output for %s so it was considered hung and killed with its child processes."+
+		" Terraform was interrupted first so it could release the state lock: if it didn't, run terraform force-unlock."+
+		" The processes were:\n%s", h.IdleTimeout, h.Processes)
+}
+
+// killReason is why a command was killed.
+type killReason int
+
+const (
+	notKilled killReason = iota
+	killedTimeout
+	killedAbort
+	killedIdle
+)
+
+// Cmd is a shell command run within the limits. It's started and waited for
+// like an exec.Cmd.
+type Cmd struct {
+	*exec.Cmd
+	config *Config
+	cgroup string
+	// container is the name of the Docker container the command runs or ""
+	// if it doesn't run one.
+	container string
+
+	mutex     sync.Mutex
+	timers    []*time.Timer
+	idle      *time.Timer
+	killed    killReason
+	hung      *HungError
+	completed bool
+}
+
+// Command returns the command running the shell command command. It runs
+// in its own process group so it can be killed with its children, and in its
+// own cgroup if memory or CPUs are limited.
+func (c *Config) Command(command string) (*Cmd, error) {
+	cmd := &Cmd{config: c}
+	if c != nil && (c.MemoryBytes > 0 || c.CPUs > 0) {
+		cgroup, err := newCgroup(c.CgroupDir, c.MemoryBytes, c.CPUs)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating cgroup")
+		}
+		cmd.cgroup = cgroup
+		// The shell moves itself into the cgroup before running the command
+		// so none of its children escape it.
+		command = fmt.Sprintf("echo $$ > '%s' && %s", filepath.Join(cgroup, "cgroup.procs"), command)
+	}
+	cmd.Cmd = exec.Command("sh", "-c", command) // #nosec
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return cmd, nil
+}
+
+// Wrap returns cmd limited to the timeout and MaxOutputBytes. Its memory
+// and CPUs aren't limited, ex. because they're limited by the container
+// it runs.
+func (c *Config) Wrap(cmd *exec.Cmd) *Cmd {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return &Cmd{Cmd: cmd, config: c}
+}
+
+// WrapContainer is like Wrap for cmd running the Docker container named
+// container. Killing the docker CLI doesn't stop the container so it's killed
+// through Docker too.
+func (c *Config) WrapContainer(cmd *exec.Cmd, container string) *Cmd {
+	wrapped := c.Wrap(cmd)
+	wrapped.container = container
+	return wrapped
+}
+
+// LimitOutput returns a writer that writes at most MaxOutputBytes to w. What
+// is written over the limit is discarded and a note is written instead.
+func (c *Config) LimitOutput(w io.Writer) io.Writer {
+	if c == nil || c.MaxOutputBytes <= 0 {
+		return w
+	}
+	return &limitWriter{w: w, max: c.MaxOutputBytes, remaining: c.MaxOutputBytes}
+}
+
+// StdoutPipe is like exec.Cmd.StdoutPipe but reading from the pipe counts as
+// output for IdleTimeout.
+func (c *Cmd) StdoutPipe() (io.ReadCloser, error) {
+	r, err := c.Cmd.StdoutPipe()
+	if err != nil || !c.watchesIdle() {
+		return r, err
+	}
+	return &activityReader{ReadCloser: r, cmd: c}, nil
+}
+
+// StderrPipe is like exec.Cmd.StderrPipe but reading from the pipe counts as
+// output for IdleTimeout.
+func (c *Cmd) StderrPipe() (io.ReadCloser, error) {
+	r, err := c.Cmd.StderrPipe()
+	if err != nil || !c.watchesIdle() {
+		return r, err
+	}
+	return &activityReader{ReadCloser: r, cmd: c}, nil
+}
+
+// Start starts the command. If it runs for longer than the timeout, or
+// without output for longer than the idle timeout, it's interrupted and then
+// killed with its children once KillGracePeriod has passed. It returns
+// ErrAborted if AbortAll was called.
+func (c *Cmd) Start() error {
+	running.Lock()
+	defer running.Unlock()
+	if running.aborted {
+		c.removeCgroup()
+		return ErrAborted
+	}
+	if c.watchesIdle() {
+		// Files, ex. the pipes of StdoutPipe, are read by the caller so
+		// their output is tracked when it's read.
+		if _, ok := c.Stdout.(*os.File); !ok {
+			c.Stdout = &activityWriter{w: c.Stdout, cmd: c}
+		}
+		if _, ok := c.Stderr.(*os.File); !ok {
+			c.Stderr = &activityWriter{w: c.Stderr, cmd: c}
+		}
+	}
+	if err := c.Cmd.Start(); err != nil {
+		c.removeCgroup()
+		return err
+	}
+	running.cmds[c] = struct{}{}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.config != nil && c.config.Timeout > 0 {
+		c.timers = append(c.timers, time.AfterFunc(c.config.Timeout, func() { c.kill(killedTimeout) }))
+	}
+	if c.watchesIdle() {
+		c.idle = time.AfterFunc(c.config.IdleTimeout, func() { c.kill(killedIdle) })
+		c.timers = append(c.timers, c.idle)
+	}
+	return nil
+}
+
+// Wait waits for the command to exit. If it was killed for timing out, the
+// error is a *TimeoutError, if it was killed for being idle, a *HungError,
+// and if it was aborted, ErrAborted.
+func (c *Cmd) Wait() error {
+	err := c.Cmd.Wait()
+	running.Lock()
+	delete(running.cmds, c)
+	running.Unlock()
+	c.mutex.Lock()
+	c.completed = true
+	for _, t := range c.timers {
+		t.Stop()
+	}
+	killed := c.killed
+	hung := c.hung
+	c.mutex.Unlock()
+	if c.cgroup != "" && err != nil && oomKilled(c.cgroup) {
+		err = fmt.Errorf("killed for using more than %d MiB of memory", c.config.MemoryBytes/(1024*1024))
+	}
+	c.removeCgroup()
+	switch killed {
+	case killedAbort:
+		return ErrAborted
+	case killedTimeout:
+		return &TimeoutError{Timeout: c.config.Timeout}
+	case killedIdle:
+		return hung
+	}
+	return err
+}
+
+// AbortAll interrupts the running commands, so Terraform can release the
+// state lock, and kills them with their children once KillGracePeriod has
+// passed. Commands started afterwards fail with ErrAborted. It returns the
+// number of commands that were running.
+func AbortAll() int {
+	running.Lock()
+	defer running.Unlock()
+	running.aborted = true
+	for c := range running.cmds {
+		c.kill(killedAbort)
+	}
+	return len(running.cmds)
+}
+
+// Run starts the command and waits for it to exit.
+func (c *Cmd) Run() error {
+	if err := c.Start(); err != nil {
+		return err
+	}
+	return c.Wait()
+}
+
+// CombinedOutput runs the command and returns its standard output and error,
+// limited to MaxOutputBytes.
+func (c *Cmd) CombinedOutput() ([]byte, error) {
+	var out bytes.Buffer
+	w := c.config.LimitOutput(&out)
+	c.Stdout = w
+	c.Stderr = w
+	err := c.Run()
+	return out.Bytes(), err
+}
+
+// kill interrupts the command and kills it once KillGracePeriod has passed
+// for reason. If it's hung, the processes of the command and the goroutines
+// of Atlantis are captured first.
+func (c *Cmd) kill(reason killReason) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.completed || c.killed != notKilled {
+		return
+	}
+	c.killed = reason
+	pgid := c.Process.Pid
+	if reason == killedIdle {
+		c.hung = &HungError{
+			IdleTimeout: c.config.IdleTimeout,
+			Processes:   processGroup(pgid),
+			Goroutines:  goroutines(),
+		}
+	}
+	// The negative pid signals the whole process group. The docker CLI
+	// forwards the interrupt to the container.
+	syscall.Kill(-pgid, syscall.SIGINT) // nolint: errcheck
+	container := c.container
+	c.timers = append(c.timers, time.AfterFunc(KillGracePeriod, func() {
+		syscall.Kill(-pgid, syscall.SIGKILL) // nolint: errcheck
+		if container != "" {
+			exec.Command("docker", "kill", container).Run() // nolint: errcheck, gosec
+		}
+	}))
+}
+
+func (c *Cmd) watchesIdle() bool {
+	return c.config != nil && c.config.IdleTimeout > 0
+}
+
+// active postpones killing the command for being idle since it produced
+// output.
+func (c *Cmd) active() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.idle != nil && c.killed == notKilled && !c.completed {
+		c.idle.Reset(c.config.IdleTimeout)
+	}
+}
+
+func (c *Cmd) removeCgroup() {
+	if c.cgroup != "" {
+		removeCgroup(c.cgroup) // nolint: errcheck
+	}
+}
+
+// limitWriter writes at most max bytes to w.
+type limitWriter struct {
+	w         io.Writer
+	max       int
+	remaining int
+	mutex     sync.Mutex
+}
+
+func (l *limitWriter) Write(p []byte) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	// The whole of p is always reported as written so commands don't fail
+	// when their output is discarded.
+	n := len(p)
+	if l.remaining < 0 {
+		return n, nil
+	}
+	truncated := len(p) > l.remaining
+	if truncated {
+		p = p[:l.remaining]
+	}
+	l.remaining -= len(p)
+	if _, err := l.w.Write(p); err != nil {
+		return 0, err
+	}
+	if truncated {
+		l.remaining = -1
+		if _, err := fmt.Fprintf(l.w, "\n[output truncated: it was longer than %d bytes]\n", l.max); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// activityWriter writes to w, or discards if it's nil, and marks cmd as
+// active.
+type activityWriter struct {
+	w   io.Writer
+	cmd *Cmd
+}
+
+func (a *activityWriter) Write(p []byte) (int, error) {
+	a.cmd.active()
+	if a.w == nil {
+		return ioutil.Discard.Write(p)
+	}
+	return a.w.Write(p)
+}
+
+// activityReader marks cmd as active when output is read from it.
+type activityReader struct {
+	io.ReadCloser
+	cmd *Cmd
+}
+
+func (a *activityReader) Read(p []byte) (int, error) {
+	n, err := a.ReadCloser.Read(p)
+	if n > 0 {
+		a.cmd.active()
+	}
+	return n, err
+}
+
+// processGroup lists the processes in process group pgid with ps.
+func processGroup(pgid int) string {
+	out, err := exec.Command("ps", "-A", "-o", "pid,ppid,pgid,stat,etime,args").Output() // #nosec
+	if err != nil {
+		return fmt.Sprintf("unable to list processes: %s", err)
+	}
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	group := lines[:1]
+	for _, line := range lines[1:] {
+		if fields := strings.Fields(line); len(fields) > 2 && fields[2] == strconv.Itoa(pgid) {
+			group = append(group, line)
+		}
+	}
+	return strings.Join(group, "\n")
+}
+
+// goroutines returns the stacks of the goroutines of Atlantis, with identical
+// goroutines grouped together.
+func goroutines() string {
+	var buf bytes.Buffer
+	pprof.Lookup("goroutine").WriteTo(&buf, 1) // nolint: errcheck
+	return buf.String()
+}
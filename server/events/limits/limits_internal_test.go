@@ -0,0 +1,35 @@
+package limits
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestAbortAll(t *testing.T) {
+	orig := KillGracePeriod
+	defer func() { KillGracePeriod = orig }()
+	KillGracePeriod = 100 * time.Millisecond
+	defer func() {
+		running.Lock()
+		running.aborted = false
+		running.Unlock()
+	}()
+
+	var config *Config
+	// The child ignores the interrupt so it's killed with the shell.
+	cmd, err := config.Command("trap '' INT; sleep 30 & wait")
+	Ok(t, err)
+	Ok(t, cmd.Start())
+	Equals(t, 1, AbortAll())
+	start := time.Now()
+	Equals(t, ErrAborted, cmd.Wait())
+	Assert(t, time.Since(start) < 10*time.Second, "command wasn't killed")
+
+	// Commands can't be started once aborted.
+	cmd, err = config.Command("true")
+	Ok(t, err)
+	Equals(t, ErrAborted, cmd.Run())
+	Equals(t, 0, AbortAll())
+}
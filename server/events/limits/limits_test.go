@@ -0,0 +1,129 @@
+package limits_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/limits"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestCmd_Unlimited(t *testing.T) {
+	var config *limits.Config
+	cmd, err := config.Command("echo hello")
+	Ok(t, err)
+	out, err := cmd.CombinedOutput()
+	Ok(t, err)
+	Equals(t, "hello\n", string(out))
+}
+
+func TestCmd_Timeout(t *testing.T) {
+	orig := limits.KillGracePeriod
+	defer func() { limits.KillGracePeriod = orig }()
+	limits.KillGracePeriod = 100 * time.Millisecond
+
+	config := &limits.Config{Timeout: 100 * time.Millisecond}
+	// The child ignores the interrupt so it's killed with the shell.
+	cmd, err := config.Command("trap '' INT; sleep 30 & wait")
+	Ok(t, err)
+	start := time.Now()
+	_, err = cmd.CombinedOutput()
+	ErrEquals(t, "timed out after 100ms and was killed", err)
+	_, ok := err.(*limits.TimeoutError)
+	Assert(t, ok, "expected *TimeoutError, got %T", err)
+	Assert(t, time.Since(start) < 10*time.Second, "command wasn't killed")
+
+	// Commands that complete in time aren't killed.
+	cmd, err = config.Command("true")
+	Ok(t, err)
+	Ok(t, cmd.Run())
+}
+
+// Test that the containers of commands that time out are killed through
+// Docker.
+func TestCmd_TimeoutContainer(t *testing.T) {
+	orig := limits.KillGracePeriod
+	defer func() { limits.KillGracePeriod = orig }()
+	limits.KillGracePeriod = 100 * time.Millisecond
+
+	// The fake docker records how it's run.
+	binDir, cleanup := TempDir(t)
+	defer cleanup()
+	logFile := filepath.Join(binDir, "docker.log")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" >> '%s'\n", logFile)
+	Ok(t, ioutil.WriteFile(filepath.Join(binDir, "docker"), []byte(script), 0700)) // #nosec G306
+	origPath := os.Getenv("PATH")
+	Ok(t, os.Setenv("PATH", binDir+":"+origPath))
+	defer os.Setenv("PATH", origPath) // nolint: errcheck
+
+	config := &limits.Config{Timeout: 100 * time.Millisecond}
+	cmd := config.WrapContainer(exec.Command("sh", "-c", "trap '' INT; sleep 30 & wait"), "atlantis-test")
+	_, err := cmd.CombinedOutput()
+	ErrEquals(t, "timed out after 100ms and was killed", err)
+
+	// docker kill runs in the background after the command is killed.
+	var log []byte
+	for i := 0; i < 50 && !strings.Contains(string(log), "kill"); i++ {
+		time.Sleep(100 * time.Millisecond)
+		log, _ = ioutil.ReadFile(logFile) // nolint: errcheck
+	}
+	Equals(t, "kill atlantis-test\n", string(log))
+}
+
+func TestCmd_IdleTimeout(t *testing.T) {
+	orig := limits.KillGracePeriod
+	defer func() { limits.KillGracePeriod = orig }()
+	limits.KillGracePeriod = 100 * time.Millisecond
+
+	config := &limits.Config{IdleTimeout: 300 * time.Millisecond}
+	// The child ignores the interrupt so it's killed with the shell.
+	cmd, err := config.Command("echo started; trap '' INT; sleep 30 & wait")
+	Ok(t, err)
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	Equals(t, "started\n", string(out))
+	hung, ok := err.(*limits.HungError)
+	Assert(t, ok, "expected *HungError, got %T: %v", err, err)
+	Equals(t, 300*time.Millisecond, hung.IdleTimeout)
+	Assert(t, strings.Contains(hung.Processes, "sleep 30"), "expected the processes to include sleep, got %q", hung.Processes)
+	Assert(t, strings.Contains(hung.Goroutines, "goroutine profile"), "expected goroutines, got %q", hung.Goroutines)
+	ErrContains(t, "produced no output for 300ms so it was considered hung", err)
+	Assert(t, time.Since(start) < 10*time.Second, "command wasn't killed")
+
+	// Commands that keep producing output aren't killed, including when it's
+	// read from a pipe.
+	cmd, err = config.Command("for i in 1 2 3 4 5; do echo $i; sleep 0.1; done")
+	Ok(t, err)
+	stdout, err := cmd.StdoutPipe()
+	Ok(t, err)
+	Ok(t, cmd.Start())
+	_, err = ioutil.ReadAll(stdout)
+	Ok(t, err)
+	Ok(t, cmd.Wait())
+}
+
+func TestConfig_LimitOutput(t *testing.T) {
+	config := &limits.Config{MaxOutputBytes: 10}
+	cmd, err := config.Command("echo 123456; echo 7890123")
+	Ok(t, err)
+	out, err := cmd.CombinedOutput()
+	Ok(t, err)
+	Equals(t, "123456\n789\n[output truncated: it was longer than 10 bytes]\n", string(out))
+
+	var buf bytes.Buffer
+	w := config.LimitOutput(&buf)
+	n, err := w.Write([]byte("0123456789abc"))
+	Ok(t, err)
+	Equals(t, 13, n)
+	n, err = w.Write([]byte("def"))
+	Ok(t, err)
+	Equals(t, 3, n)
+	Equals(t, "0123456789\n[output truncated: it was longer than 10 bytes]\n", buf.String())
+}
@@ -0,0 +1,94 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/events/locking"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+)
+
+func NewLockCommandRunner(
+	prjCmdBuilder ProjectPlanCommandBuilder,
+	locker locking.Locker,
+	vcsClient vcs.Client,
+	SilenceNoProjects bool,
+) *LockCommandRunner {
+	return &LockCommandRunner{
+		prjCmdBuilder:     prjCmdBuilder,
+		locker:            locker,
+		vcsClient:         vcsClient,
+		SilenceNoProjects: SilenceNoProjects,
+	}
+}
+
+// LockCommandRunner locks projects for a pull request without planning them,
+// ex. to reserve an environment during an incident. The locks are released
+// like the locks of plans, ex. with atlantis unlock or from the locks UI.
+type LockCommandRunner struct {
+	prjCmdBuilder ProjectPlanCommandBuilder
+	locker        locking.Locker
+	vcsClient     vcs.Client
+	// SilenceNoProjects is whether Atlantis should respond to PRs if no projects
+	// are found
+	SilenceNoProjects bool
+}
+
+func (l *LockCommandRunner) Run(
+	ctx *CommandContext,
+	cmd *CommentCommand,
+) {
+	baseRepo := ctx.Pull.BaseRepo
+	pullNum := ctx.Pull.Num
+
+	// The projects are found like for plan so that the same flags select
+	// them.
+	projectCmds, err := l.prjCmdBuilder.BuildPlanCommands(ctx, cmd)
+	if err != nil {
+		ctx.Log.Err("failed to build lock commands: %s", err)
+		ctx.CommandError = err
+		l.comment(ctx, fmt.Sprintf("**Lock Error**\n```\n%s\n```", err))
+		return
+	}
+	if len(projectCmds) == 0 {
+		if !l.SilenceNoProjects {
+			l.comment(ctx, "Ran lock for 0 projects.")
+		}
+		return
+	}
+
+	var lines []string
+	for _, projectCmd := range projectCmds {
+		project := models.NewProject(baseRepo.FullName, projectCmd.RepoRelDir)
+		resp, err := l.locker.TryLock(project, projectCmd.Workspace, ctx.Pull, ctx.User)
+		switch {
+		case err != nil:
+			ctx.Log.Err("failed to lock %s: %s", projectDescription(projectCmd), err)
+			ctx.CommandError = err
+			lines = append(lines, fmt.Sprintf("* :x: %s: failed to lock: %s", projectDescription(projectCmd), err))
+		case resp.LockAcquired || resp.CurrLock.Pull.Num == pullNum:
+			ctx.Log.Info("acquired lock with id %q", resp.LockKey)
+			lines = append(lines, fmt.Sprintf("* :lock: %s", projectDescription(projectCmd)))
+		default:
+			link, err := l.vcsClient.MarkdownPullLink(resp.CurrLock.Pull)
+			if err != nil {
+				link = fmt.Sprintf("#%d", resp.CurrLock.Pull.Num)
+			}
+			lines = append(lines, fmt.Sprintf("* :x: %s: already locked by pull %s", projectDescription(projectCmd), link))
+			ctx.CommandFailure = fmt.Sprintf("%s is already locked by pull #%d", projectDescription(projectCmd), resp.CurrLock.Pull.Num)
+		}
+	}
+	projects := "projects"
+	if len(projectCmds) == 1 {
+		projects = "project"
+	}
+	l.comment(ctx, fmt.Sprintf("Ran lock for %d %s:\n\n%s\n\nOther pull requests can't plan the locked projects until `atlantis unlock` is run here, the locks are deleted from the Atlantis UI or this pull request is closed.",
+		len(projectCmds), projects, strings.Join(lines, "\n")))
+}
+
+func (l *LockCommandRunner) comment(ctx *CommandContext, comment string) {
+	if commentErr := l.vcsClient.CreateComment(ctx.Pull.BaseRepo, ctx.Pull.Num, comment, models.LockCommand.String()); commentErr != nil {
+		ctx.Log.Err("unable to comment: %s", commentErr)
+	}
+}
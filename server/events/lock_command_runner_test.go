@@ -0,0 +1,69 @@
+package events_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/locking"
+	lockingmocks "github.com/runatlantis/atlantis/server/events/locking/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/models/fixtures"
+	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+func TestLockCommandRunner_Run(t *testing.T) {
+	RegisterMockTestingT(t)
+	builder := mocks.NewMockProjectCommandBuilder()
+	locker := lockingmocks.NewMockLocker()
+	vcsClient := vcsmocks.NewMockClient()
+	runner := events.NewLockCommandRunner(builder, locker, vcsClient, false)
+
+	pull := fixtures.Pull
+	pull.BaseRepo = fixtures.GithubRepo
+	otherPull := models.PullRequest{Num: 2, BaseRepo: fixtures.GithubRepo}
+	ctx := &events.CommandContext{Pull: pull, User: fixtures.User, Log: logging.NewNoopLogger(t)}
+	cmd := &events.CommentCommand{Name: models.LockCommand, Workspace: "staging"}
+	When(builder.BuildPlanCommands(ctx, cmd)).ThenReturn([]models.ProjectCommandContext{
+		{RepoRelDir: "staging", Workspace: "staging"},
+		{RepoRelDir: "prod", Workspace: "staging", ProjectName: "prod"},
+		{RepoRelDir: "dev", Workspace: "staging"},
+		{RepoRelDir: "shared", Workspace: "staging"},
+	}, nil)
+	project := func(dir string) models.Project { return models.NewProject(fixtures.GithubRepo.FullName, dir) }
+	When(locker.TryLock(project("staging"), "staging", pull, fixtures.User)).
+		ThenReturn(locking.TryLockResponse{LockAcquired: true, LockKey: "staging"}, nil)
+	When(locker.TryLock(project("prod"), "staging", pull, fixtures.User)).
+		ThenReturn(locking.TryLockResponse{CurrLock: models.ProjectLock{Pull: otherPull}}, nil)
+	When(locker.TryLock(project("dev"), "staging", pull, fixtures.User)).
+		ThenReturn(locking.TryLockResponse{}, errors.New("err"))
+	// Locks already held by the pull request are kept.
+	When(locker.TryLock(project("shared"), "staging", pull, fixtures.User)).
+		ThenReturn(locking.TryLockResponse{CurrLock: models.ProjectLock{Pull: pull}}, nil)
+	When(vcsClient.MarkdownPullLink(otherPull)).ThenReturn("#2", nil)
+
+	runner.Run(ctx, cmd)
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, pull.Num, "Ran lock for 4 projects:\n\n"+
+		"* :lock: dir: `staging` workspace: `staging`\n"+
+		"* :x: project: `prod` dir: `prod` workspace: `staging`: already locked by pull #2\n"+
+		"* :x: dir: `dev` workspace: `staging`: failed to lock: err\n"+
+		"* :lock: dir: `shared` workspace: `staging`\n\n"+
+		"Other pull requests can't plan the locked projects until `atlantis unlock` is run here, the locks are deleted from the Atlantis UI or this pull request is closed.",
+		"lock")
+}
+
+func TestLockCommandRunner_RunNoProjects(t *testing.T) {
+	RegisterMockTestingT(t)
+	builder := mocks.NewMockProjectCommandBuilder()
+	vcsClient := vcsmocks.NewMockClient()
+	runner := events.NewLockCommandRunner(builder, lockingmocks.NewMockLocker(), vcsClient, true)
+	ctx := &events.CommandContext{Pull: fixtures.Pull, Log: logging.NewNoopLogger(t)}
+	When(builder.BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())).ThenReturn(nil, nil)
+
+	runner.Run(ctx, &events.CommentCommand{Name: models.LockCommand})
+	vcsClient.VerifyWasCalled(Never()).CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString(), AnyString())
+}
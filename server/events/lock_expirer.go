@@ -0,0 +1,131 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/locking"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// LockExpiryCheckInterval is how often the locks are checked for expiry.
+const LockExpiryCheckInterval = time.Minute
+
+// LockExpirer reminds pull requests that have held a project lock for longer
+// than TTL and, if AutoRelease is set, releases their locks and discards
+// their plans so abandoned pull requests don't block everyone else.
+type LockExpirer struct {
+	Locker            locking.Locker
+	DeleteLockCommand DeleteLockCommand
+	VCSClient         vcs.Client
+	Logger            logging.SimpleLogging
+	// TTL is the maximum age of a lock.
+	TTL time.Duration
+	// AutoRelease is true if expired locks are released instead of only
+	// reminding their pull requests.
+	AutoRelease bool
+	// Now returns the current time. It's only overridden in tests.
+	Now func() time.Time
+
+	mutex sync.Mutex
+	// reminded maps the keys of the locks whose pull requests were reminded
+	// to when the locks were acquired, so each lock is only reminded once.
+	reminded map[string]time.Time
+}
+
+// Run checks the locks every LockExpiryCheckInterval until ctx is done.
+func (e *LockExpirer) Run(ctx context.Context) {
+	ticker := time.NewTicker(LockExpiryCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.CheckLocks()
+		}
+	}
+}
+
+// CheckLocks reminds or releases every lock older than TTL. Errors are
+// logged.
+func (e *LockExpirer) CheckLocks() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.reminded == nil {
+		e.reminded = make(map[string]time.Time)
+	}
+
+	locks, err := e.Locker.List()
+	if err != nil {
+		e.Logger.Err("failed listing locks to check for expiry: %s", err)
+		return
+	}
+	// Forget the locks that don't exist anymore.
+	for key := range e.reminded {
+		if _, ok := locks[key]; !ok {
+			delete(e.reminded, key)
+		}
+	}
+
+	now := e.Now()
+	for key, lock := range locks {
+		age := now.Sub(lock.Time)
+		if age < e.TTL {
+			continue
+		}
+		if e.AutoRelease {
+			e.release(key, lock, age)
+			continue
+		}
+		if remindedAt, ok := e.reminded[key]; ok && remindedAt.Equal(lock.Time) {
+			continue
+		}
+		e.reminded[key] = lock.Time
+		comment := fmt.Sprintf("**Reminder**: This pull request has held the lock for dir: `%s` workspace: `%s` for %s, which is longer than the maximum of %s.\n\n"+
+			"Other pull requests can't `plan` this project until it's released. If it's not needed anymore, comment `atlantis unlock` or discard it from the Atlantis UI.",
+			lock.Project.Path, lock.Workspace, age.Round(time.Minute), e.TTL)
+		e.comment(lock, comment)
+	}
+}
+
+// release deletes the lock at key and its plan, and comments on its pull
+// request. Locks whose pull request is running a command are released by a
+// later check once it's done.
+func (e *LockExpirer) release(key string, lock models.ProjectLock, age time.Duration) {
+	deleted, err := e.DeleteLockCommand.DeleteIdleLock(key)
+	if deleted == nil {
+		if err != nil {
+			e.Logger.Warn("not releasing expired lock %q yet: %s", key, err)
+		}
+		// Otherwise the lock was released since it was listed.
+		return
+	}
+	e.Logger.Info("released lock %q held by pull request #%d for %s", key, lock.Pull.Num, age.Round(time.Minute))
+	comment := fmt.Sprintf("**Warning**: The lock for dir: `%s` workspace: `%s` was held for %s, which is longer than the maximum of %s, so it was **released** and its plan was **discarded**.\n\n"+
+		"To `apply` this project you must run `plan` again.",
+		lock.Project.Path, lock.Workspace, age.Round(time.Minute), e.TTL)
+	if err != nil {
+		e.Logger.Err("failed discarding the plan of expired lock %q: %s", key, err)
+		comment = fmt.Sprintf("**Warning**: The lock for dir: `%s` workspace: `%s` was held for %s, which is longer than the maximum of %s, so it was **released**, but its plan couldn't be discarded.\n\n"+
+			"Run `plan` again before you `apply` this project.",
+			lock.Project.Path, lock.Workspace, age.Round(time.Minute), e.TTL)
+	}
+	e.comment(lock, comment)
+}
+
+func (e *LockExpirer) comment(lock models.ProjectLock, comment string) {
+	// NOTE: Because BaseRepo was added to the PullRequest model later, previous
+	// installations of Atlantis will have locks in their DB that do not have
+	// this field on PullRequest. We skip commenting in this case.
+	if lock.Pull.BaseRepo == (models.Repo{}) {
+		return
+	}
+	if err := e.VCSClient.CreateComment(lock.Pull.BaseRepo, lock.Pull.Num, comment, ""); err != nil {
+		e.Logger.Warn("failed commenting on pull request: %s", err)
+	}
+}
@@ -0,0 +1,133 @@
+package events_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	lockmocks "github.com/runatlantis/atlantis/server/events/locking/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/models/fixtures"
+	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+var lockExpirerNow = time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newLockExpirer(t *testing.T, autoRelease bool) (*events.LockExpirer, *lockmocks.MockLocker, *mocks.MockDeleteLockCommand, *vcsmocks.MockClient) {
+	RegisterMockTestingT(t)
+	locker := lockmocks.NewMockLocker()
+	deleteLockCommand := mocks.NewMockDeleteLockCommand()
+	vcsClient := vcsmocks.NewMockClient()
+	return &events.LockExpirer{
+		Locker:            locker,
+		DeleteLockCommand: deleteLockCommand,
+		VCSClient:         vcsClient,
+		Logger:            logging.NewNoopLogger(t),
+		TTL:               24 * time.Hour,
+		AutoRelease:       autoRelease,
+		Now:               func() time.Time { return lockExpirerNow },
+	}, locker, deleteLockCommand, vcsClient
+}
+
+func TestLockExpirer_Remind(t *testing.T) {
+	e, locker, deleteLockCommand, vcsClient := newLockExpirer(t, false)
+	expired := models.ProjectLock{
+		Project:   models.NewProject("owner/repo", "dir"),
+		Workspace: "default",
+		Pull:      models.PullRequest{Num: 1, BaseRepo: fixtures.GithubRepo},
+		Time:      lockExpirerNow.Add(-25 * time.Hour),
+	}
+	recent := expired
+	recent.Project.Path = "recent"
+	recent.Time = lockExpirerNow.Add(-time.Hour)
+	When(locker.List()).ThenReturn(map[string]models.ProjectLock{
+		"owner/repo/dir/default":    expired,
+		"owner/repo/recent/default": recent,
+	}, nil)
+
+	e.CheckLocks()
+	_, pullNum, comment, _ := vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString(), AnyString()).GetCapturedArguments()
+	Equals(t, 1, pullNum)
+	Assert(t, strings.HasPrefix(comment, "**Reminder**: This pull request has held the lock for dir: `dir` workspace: `default` for 25h0m0s"), "unexpected comment %q", comment)
+	deleteLockCommand.VerifyWasCalled(Never()).DeleteLock(AnyString())
+
+	// Each lock is only reminded once.
+	e.CheckLocks()
+	vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString(), AnyString())
+
+	// Unless it's acquired again.
+	expired.Time = expired.Time.Add(time.Minute)
+	When(locker.List()).ThenReturn(map[string]models.ProjectLock{"owner/repo/dir/default": expired}, nil)
+	e.CheckLocks()
+	vcsClient.VerifyWasCalled(Times(2)).CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString(), AnyString())
+}
+
+func TestLockExpirer_AutoRelease(t *testing.T) {
+	e, locker, deleteLockCommand, vcsClient := newLockExpirer(t, true)
+	expired := models.ProjectLock{
+		Project:   models.NewProject("owner/repo", "dir"),
+		Workspace: "default",
+		Pull:      models.PullRequest{Num: 1, BaseRepo: fixtures.GithubRepo},
+		Time:      lockExpirerNow.Add(-48 * time.Hour),
+	}
+	When(locker.List()).ThenReturn(map[string]models.ProjectLock{"owner/repo/dir/default": expired}, nil)
+	When(deleteLockCommand.DeleteIdleLock("owner/repo/dir/default")).ThenReturn(&expired, nil)
+
+	e.CheckLocks()
+	deleteLockCommand.VerifyWasCalledOnce().DeleteIdleLock("owner/repo/dir/default")
+	_, _, comment, _ := vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString(), AnyString()).GetCapturedArguments()
+	Assert(t, strings.Contains(comment, "so it was **released** and its plan was **discarded**"), "unexpected comment %q", comment)
+}
+
+func TestLockExpirer_AutoReleaseAlreadyReleased(t *testing.T) {
+	e, locker, deleteLockCommand, vcsClient := newLockExpirer(t, true)
+	expired := models.ProjectLock{
+		Project:   models.NewProject("owner/repo", "dir"),
+		Workspace: "default",
+		Pull:      models.PullRequest{Num: 1, BaseRepo: fixtures.GithubRepo},
+		Time:      lockExpirerNow.Add(-48 * time.Hour),
+	}
+	When(locker.List()).ThenReturn(map[string]models.ProjectLock{"owner/repo/dir/default": expired}, nil)
+	When(deleteLockCommand.DeleteIdleLock("owner/repo/dir/default")).ThenReturn(nil, nil)
+
+	e.CheckLocks()
+	vcsClient.VerifyWasCalled(Never()).CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString(), AnyString())
+}
+
+func TestLockExpirer_AutoReleaseCommandRunning(t *testing.T) {
+	e, locker, deleteLockCommand, vcsClient := newLockExpirer(t, true)
+	expired := models.ProjectLock{
+		Project:   models.NewProject("owner/repo", "dir"),
+		Workspace: "default",
+		Pull:      models.PullRequest{Num: 1, BaseRepo: fixtures.GithubRepo},
+		Time:      lockExpirerNow.Add(-48 * time.Hour),
+	}
+	When(locker.List()).ThenReturn(map[string]models.ProjectLock{"owner/repo/dir/default": expired}, nil)
+	When(deleteLockCommand.DeleteIdleLock("owner/repo/dir/default")).ThenReturn(nil, errors.New("the default workspace is currently locked"))
+
+	e.CheckLocks()
+	vcsClient.VerifyWasCalled(Never()).CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString(), AnyString())
+}
+
+func TestLockExpirer_AutoReleasePlanNotDeleted(t *testing.T) {
+	e, locker, deleteLockCommand, vcsClient := newLockExpirer(t, true)
+	expired := models.ProjectLock{
+		Project:   models.NewProject("owner/repo", "dir"),
+		Workspace: "default",
+		Pull:      models.PullRequest{Num: 1, BaseRepo: fixtures.GithubRepo},
+		Time:      lockExpirerNow.Add(-48 * time.Hour),
+	}
+	When(locker.List()).ThenReturn(map[string]models.ProjectLock{"owner/repo/dir/default": expired}, nil)
+	When(deleteLockCommand.DeleteIdleLock("owner/repo/dir/default")).ThenReturn(&expired, errors.New("deleting plan: permission denied"))
+
+	e.CheckLocks()
+	_, _, comment, _ := vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString(), AnyString()).GetCapturedArguments()
+	Assert(t, strings.Contains(comment, "so it was **released**, but its plan couldn't be discarded"), "unexpected comment %q", comment)
+}
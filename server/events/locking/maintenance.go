@@ -0,0 +1,177 @@
+package locking
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// RepoMatcher matches the repos a maintenance window applies to. It's
+// implemented by events.RepoAllowlistChecker.
+type RepoMatcher interface {
+	IsAllowlisted(repoFullName string, vcsHostname string) bool
+}
+
+// MaintenanceWindow is a recurring period during which applies are
+// rejected, ex. during a release freeze.
+type MaintenanceWindow struct {
+	// Name identifies the window, ex. when overriding it.
+	Name string
+	// Spec is the cron expression of when the window starts. It's only used
+	// for display.
+	Spec     string
+	Schedule cron.Schedule
+	Duration time.Duration
+	// Repos matches the repos the window applies to. If nil, it applies to
+	// every repo.
+	Repos RepoMatcher
+	// Message is added to the comment rejecting applies.
+	Message string
+}
+
+// MaintenanceWindowStatus is the state of a maintenance window.
+type MaintenanceWindowStatus struct {
+	Name     string
+	Spec     string
+	Duration time.Duration
+	Message  string
+	// Global is true if the window applies to every repo.
+	Global bool
+	// Active is true if the window is in effect now, even if it's
+	// overridden.
+	Active bool
+	// Overridden is true if applies are allowed during the current
+	// occurrence of the window.
+	Overridden bool
+	// Start and End are the times of the current occurrence if the window is
+	// active, otherwise of the next one.
+	Start time.Time
+	End   time.Time
+}
+
+// MaintenanceSchedule rejects applies during maintenance windows. Windows
+// can be overridden until their current occurrence ends, ex. to apply a
+// hotfix. Overrides are kept in memory. It's safe for concurrent use.
+type MaintenanceSchedule struct {
+	windows []MaintenanceWindow
+	// Now returns the current time. It's only overridden in tests.
+	Now func() time.Time
+
+	mutex sync.Mutex
+	// overrides maps the names of overridden windows to the end of the
+	// occurrence they were overridden for.
+	overrides map[string]time.Time
+}
+
+// NewMaintenanceSchedule returns a schedule of windows.
+func NewMaintenanceSchedule(windows []MaintenanceWindow) *MaintenanceSchedule {
+	return &MaintenanceSchedule{
+		windows:   windows,
+		Now:       time.Now,
+		overrides: make(map[string]time.Time),
+	}
+}
+
+// ParseMaintenanceSpec parses spec, a standard cron expression with five
+// fields, ex. "0 22 * * 5", or a descriptor, ex. "@daily". A time zone can be
+// set with a CRON_TZ= prefix, ex. "CRON_TZ=Europe/Paris 0 22 * * 5".
+func ParseMaintenanceSpec(spec string) (cron.Schedule, error) {
+	return cron.ParseStandard(spec)
+}
+
+// Active returns the status of the window in effect for the repo, or nil if
+// applies are allowed. Overridden windows aren't in effect.
+func (m *MaintenanceSchedule) Active(repoFullName string, vcsHostname string) *MaintenanceWindowStatus {
+	for _, s := range m.Status() {
+		if !s.Active || s.Overridden {
+			continue
+		}
+		w, _ := m.window(s.Name)
+		if w.Repos == nil || w.Repos.IsAllowlisted(repoFullName, vcsHostname) {
+			return &s
+		}
+	}
+	return nil
+}
+
+// Status returns the status of every window.
+func (m *MaintenanceSchedule) Status() []MaintenanceWindowStatus {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := m.Now()
+	var statuses []MaintenanceWindowStatus
+	for _, w := range m.windows {
+		s := MaintenanceWindowStatus{
+			Name:     w.Name,
+			Spec:     w.Spec,
+			Duration: w.Duration,
+			Message:  w.Message,
+			Global:   w.Repos == nil,
+		}
+		s.Start, s.Active = occurrence(w, now)
+		s.End = s.Start.Add(w.Duration)
+		if until, ok := m.overrides[w.Name]; ok {
+			if s.Active && until.Equal(s.End) {
+				s.Overridden = true
+			} else {
+				// The occurrence it was overridden for is over.
+				delete(m.overrides, w.Name)
+			}
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
+
+// Override allows applies until the current occurrence of the window ends.
+// It returns when it ends.
+func (m *MaintenanceSchedule) Override(name string) (time.Time, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	w, ok := m.window(name)
+	if !ok {
+		return time.Time{}, fmt.Errorf("no maintenance window named %q", name)
+	}
+	start, active := occurrence(w, m.Now())
+	if !active {
+		return time.Time{}, fmt.Errorf("maintenance window %q isn't active", name)
+	}
+	end := start.Add(w.Duration)
+	m.overrides[name] = end
+	return end, nil
+}
+
+// CancelOverride rejects applies again during the current occurrence of the
+// window. It's a no-op if the window isn't overridden.
+func (m *MaintenanceSchedule) CancelOverride(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.window(name); !ok {
+		return fmt.Errorf("no maintenance window named %q", name)
+	}
+	delete(m.overrides, name)
+	return nil
+}
+
+func (m *MaintenanceSchedule) window(name string) (MaintenanceWindow, bool) {
+	for _, w := range m.windows {
+		if w.Name == name {
+			return w, true
+		}
+	}
+	return MaintenanceWindow{}, false
+}
+
+// occurrence returns the start of the occurrence of w that's in effect at
+// now and true, or the start of the next occurrence and false if none is.
+func occurrence(w MaintenanceWindow, now time.Time) (time.Time, bool) {
+	// The only occurrence that can be in effect is the first one that starts
+	// within the window's duration before now.
+	start := w.Schedule.Next(now.Add(-w.Duration))
+	if !start.After(now) {
+		return start, true
+	}
+	return start, false
+}
@@ -0,0 +1,89 @@
+package locking_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/locking"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// infraRepos matches the owner/infra repo.
+type infraRepos struct{}
+
+func (infraRepos) IsAllowlisted(repoFullName string, vcsHostname string) bool {
+	return repoFullName == "owner/infra"
+}
+
+func newMaintenanceSchedule(t *testing.T, now *time.Time) *locking.MaintenanceSchedule {
+	// Fridays from 22:00 to Monday 06:00.
+	weekend, err := locking.ParseMaintenanceSpec("0 22 * * 5")
+	Ok(t, err)
+	// Every day from 02:00 to 03:00.
+	nightly, err := locking.ParseMaintenanceSpec("0 2 * * *")
+	Ok(t, err)
+	m := locking.NewMaintenanceSchedule([]locking.MaintenanceWindow{
+		{Name: "weekend", Spec: "0 22 * * 5", Schedule: weekend, Duration: 56 * time.Hour, Message: "Release freeze"},
+		{Name: "nightly", Spec: "0 2 * * *", Schedule: nightly, Duration: time.Hour, Repos: infraRepos{}},
+	})
+	m.Now = func() time.Time { return *now }
+	return m
+}
+
+func TestMaintenanceSchedule_Active(t *testing.T) {
+	// A Wednesday.
+	now := time.Date(2021, 6, 2, 12, 0, 0, 0, time.Local)
+	m := newMaintenanceSchedule(t, &now)
+	Assert(t, m.Active("owner/repo", "github.com") == nil, "exp no active window")
+
+	statuses := m.Status()
+	Equals(t, 2, len(statuses))
+	Equals(t, false, statuses[0].Active)
+	Equals(t, time.Date(2021, 6, 4, 22, 0, 0, 0, time.Local), statuses[0].Start)
+	Equals(t, time.Date(2021, 6, 3, 3, 0, 0, 0, time.Local), statuses[1].End)
+
+	// Saturday.
+	now = time.Date(2021, 6, 5, 12, 0, 0, 0, time.Local)
+	active := m.Active("owner/repo", "github.com")
+	Assert(t, active != nil, "exp active window")
+	Equals(t, "weekend", active.Name)
+	Equals(t, "Release freeze", active.Message)
+	Equals(t, time.Date(2021, 6, 7, 6, 0, 0, 0, time.Local), active.End)
+
+	// Windows only apply to the repos they match.
+	now = time.Date(2021, 6, 3, 2, 30, 0, 0, time.Local)
+	Assert(t, m.Active("owner/repo", "github.com") == nil, "exp no active window")
+	active = m.Active("owner/infra", "github.com")
+	Assert(t, active != nil, "exp active window")
+	Equals(t, "nightly", active.Name)
+
+	// The end of a window isn't part of it.
+	now = time.Date(2021, 6, 3, 3, 0, 0, 0, time.Local)
+	Assert(t, m.Active("owner/infra", "github.com") == nil, "exp no active window")
+}
+
+func TestMaintenanceSchedule_Override(t *testing.T) {
+	now := time.Date(2021, 6, 5, 12, 0, 0, 0, time.Local)
+	m := newMaintenanceSchedule(t, &now)
+
+	_, err := m.Override("missing")
+	ErrEquals(t, `no maintenance window named "missing"`, err)
+	_, err = m.Override("nightly")
+	ErrEquals(t, `maintenance window "nightly" isn't active`, err)
+
+	end, err := m.Override("weekend")
+	Ok(t, err)
+	Equals(t, time.Date(2021, 6, 7, 6, 0, 0, 0, time.Local), end)
+	Assert(t, m.Active("owner/repo", "github.com") == nil, "exp overridden window to not be active")
+	Equals(t, true, m.Status()[0].Overridden)
+
+	Ok(t, m.CancelOverride("weekend"))
+	Assert(t, m.Active("owner/repo", "github.com") != nil, "exp window to be active again")
+
+	// Overrides only last for the current occurrence.
+	_, err = m.Override("weekend")
+	Ok(t, err)
+	now = now.Add(7 * 24 * time.Hour)
+	Assert(t, m.Active("owner/repo", "github.com") != nil, "exp next occurrence to be active")
+	Equals(t, false, m.Status()[0].Overridden)
+}
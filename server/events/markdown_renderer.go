@@ -16,6 +16,7 @@ package events
 import (
 	"bytes"
 	"fmt"
+	"regexp"
 	"strings"
 	"text/template"
 
@@ -43,6 +44,18 @@ type MarkdownRenderer struct {
 	DisableApply             bool
 	DisableMarkdownFolding   bool
 	DisableRepoLocking       bool
+	// CompactPlanOutput hides the noise of plans, see CompactPlanOutput.
+	CompactPlanOutput bool
+	// AggregatedComments renders the results of commands that ran for
+	// multiple projects as a table of their statuses followed by a
+	// collapsed section per project.
+	AggregatedComments bool
+	// Templates overrides the templates of comments. If nil, the default
+	// templates are used.
+	Templates *CommentTemplates
+	// ErrorHints add remediation hints to the comments of errors. If nil,
+	// there are no hints.
+	ErrorHints ErrorHints
 }
 
 // commonData is data that all responses have.
@@ -59,6 +72,8 @@ type commonData struct {
 // errData is data about an error response.
 type errData struct {
 	Error string
+	// Hints are how to fix the error, if it's a common one.
+	Hints []string
 	commonData
 }
 
@@ -76,7 +91,10 @@ type resultData struct {
 
 type planSuccessData struct {
 	models.PlanSuccess
-	PlanSummary        string
+	PlanSummary string
+	// Changes are the resources the plan changes. They're only set if the
+	// output is wrapped.
+	Changes            []models.ResourceChanges
 	PlanWasDeleted     bool
 	DisableApply       bool
 	DisableRepoLocking bool
@@ -86,16 +104,30 @@ type policyCheckSuccessData struct {
 	models.PolicyCheckSuccess
 }
 
+// headerData is the data of the header and footer templates.
+type headerData struct {
+	Command string
+	Repo    models.Repo
+	// Failed is true if the command or any of its projects failed.
+	Failed bool
+}
+
 type projectResultTmplData struct {
 	Workspace   string
 	RepoRelDir  string
 	ProjectName string
 	Rendered    string
+	// Status is whether the project succeeded, ex. ":x: Errored".
+	Status string
+	// Summary is the one line summary of the project's output, ex. "Plan: 1
+	// to add, 0 to change, 0 to destroy.", if there is one.
+	Summary string
 }
 
-// Render formats the data into a markdown string.
+// Render formats the data into a markdown string using the templates of
+// repo.
 // nolint: interfacer
-func (m *MarkdownRenderer) Render(res CommandResult, cmdName models.CommandName, log string, verbose bool, vcsHost models.VCSHostType) string {
+func (m *MarkdownRenderer) Render(res CommandResult, cmdName models.CommandName, log string, verbose bool, repo models.Repo) string {
 	commandStr := strings.Title(strings.Replace(cmdName.String(), "_", " ", -1))
 	common := commonData{
 		Command:            commandStr,
@@ -106,19 +138,40 @@ func (m *MarkdownRenderer) Render(res CommandResult, cmdName models.CommandName,
 		DisableApply:       m.DisableApply,
 		DisableRepoLocking: m.DisableRepoLocking,
 	}
+	tmpls := m.Templates.For(repo)
+	header := headerData{Command: commandStr, Repo: repo, Failed: res.HasErrors()}
+	return m.renderTemplate(tmpls, headerTmpl, header) +
+		m.renderBody(tmpls, res, common, repo.VCSHost.Type) +
+		m.renderTemplate(tmpls, footerTmpl, header)
+}
+
+func (m *MarkdownRenderer) renderBody(tmpls *template.Template, res CommandResult, common commonData, vcsHost models.VCSHostType) string {
 	if res.Error != nil {
-		return m.renderTemplate(unwrappedErrWithLogTmpl, errData{res.Error.Error(), common})
+		return m.renderTemplate(tmpls, unwrappedErrWithLogTmpl, errData{res.Error.Error(), m.ErrorHints.For(res.Error.Error()), common})
 	}
 	if res.Failure != "" {
-		return m.renderTemplate(failureWithLogTmpl, failureData{res.Failure, common})
+		return m.renderTemplate(tmpls, failureWithLogTmpl, failureData{res.Failure, common})
 	}
-	return m.renderProjectResults(res.ProjectResults, common, vcsHost)
+	if len(res.KeptPlans) == 0 {
+		return m.renderProjectResults(tmpls, res.ProjectResults, common, vcsHost)
+	}
+	keptPlans := m.renderTemplate(tmpls, keptPlansTmpl, res.KeptPlans)
+	if len(res.ProjectResults) == 0 {
+		return keptPlans
+	}
+	return m.renderProjectResults(tmpls, res.ProjectResults, common, vcsHost) + "\n" + keptPlans
 }
 
-func (m *MarkdownRenderer) renderProjectResults(results []models.ProjectResult, common commonData, vcsHost models.VCSHostType) string {
+func (m *MarkdownRenderer) renderProjectResults(tmpls *template.Template, results []models.ProjectResult, common commonData, vcsHost models.VCSHostType) string {
 	var resultsTmplData []projectResultTmplData
 	numPlanSuccesses := 0
 	numPolicyCheckSuccesses := 0
+	// Aggregated comments collapse each project so their output isn't
+	// collapsed again.
+	aggregate := m.AggregatedComments && len(results) > 1 && m.supportsFolding(vcsHost)
+	wrap := func(output string) bool {
+		return !aggregate && m.shouldUseWrappedTmpl(vcsHost, output)
+	}
 
 	for _, result := range results {
 		resultData := projectResultTmplData{
@@ -127,19 +180,23 @@ func (m *MarkdownRenderer) renderProjectResults(results []models.ProjectResult,
 			ProjectName: result.ProjectName,
 		}
 		if result.Error != nil {
+			resultData.Status = ":x: Errored"
 			tmpl := unwrappedErrTmpl
-			if m.shouldUseWrappedTmpl(vcsHost, result.Error.Error()) {
+			if wrap(result.Error.Error()) {
 				tmpl = wrappedErrTmpl
 			}
-			resultData.Rendered = m.renderTemplate(tmpl, struct {
+			resultData.Rendered = m.renderTemplate(tmpls, tmpl, struct {
 				Command string
 				Error   string
+				Hints   []string
 			}{
 				Command: common.Command,
 				Error:   result.Error.Error(),
+				Hints:   m.ErrorHints.For(result.Error.Error()),
 			})
 		} else if result.Failure != "" {
-			resultData.Rendered = m.renderTemplate(failureTmpl, struct {
+			resultData.Status = ":warning: Failed"
+			resultData.Rendered = m.renderTemplate(tmpls, failureTmpl, struct {
 				Command string
 				Failure string
 			}{
@@ -147,32 +204,45 @@ func (m *MarkdownRenderer) renderProjectResults(results []models.ProjectResult,
 				Failure: result.Failure,
 			})
 		} else if result.PlanSuccess != nil {
-			if m.shouldUseWrappedTmpl(vcsHost, result.PlanSuccess.TerraformOutput) {
-				resultData.Rendered = m.renderTemplate(planSuccessWrappedTmpl, planSuccessData{PlanSuccess: *result.PlanSuccess, PlanSummary: result.PlanSuccess.Summary(), PlanWasDeleted: common.PlansDeleted, DisableApply: common.DisableApply, DisableRepoLocking: common.DisableRepoLocking})
+			if m.CompactPlanOutput {
+				compacted := *result.PlanSuccess
+				compacted.TerraformOutput = CompactPlanOutput(compacted.TerraformOutput)
+				result.PlanSuccess = &compacted
+			}
+			resultData.Status = ":white_check_mark: Planned"
+			resultData.Summary = result.PlanSuccess.Summary()
+			if wrap(result.PlanSuccess.TerraformOutput) {
+				resultData.Rendered = m.renderTemplate(tmpls, planSuccessWrappedTmpl, planSuccessData{PlanSuccess: *result.PlanSuccess, PlanSummary: result.PlanSuccess.Summary(), Changes: result.PlanSuccess.Changes(), PlanWasDeleted: common.PlansDeleted, DisableApply: common.DisableApply, DisableRepoLocking: common.DisableRepoLocking})
 			} else {
-				resultData.Rendered = m.renderTemplate(planSuccessUnwrappedTmpl, planSuccessData{PlanSuccess: *result.PlanSuccess, PlanWasDeleted: common.PlansDeleted, DisableApply: common.DisableApply, DisableRepoLocking: common.DisableRepoLocking})
+				resultData.Rendered = m.renderTemplate(tmpls, planSuccessUnwrappedTmpl, planSuccessData{PlanSuccess: *result.PlanSuccess, PlanWasDeleted: common.PlansDeleted, DisableApply: common.DisableApply, DisableRepoLocking: common.DisableRepoLocking})
 			}
 			numPlanSuccesses++
 		} else if result.PolicyCheckSuccess != nil {
-			if m.shouldUseWrappedTmpl(vcsHost, result.PolicyCheckSuccess.PolicyCheckOutput) {
-				resultData.Rendered = m.renderTemplate(policyCheckSuccessWrappedTmpl, policyCheckSuccessData{PolicyCheckSuccess: *result.PolicyCheckSuccess})
+			resultData.Status = ":white_check_mark: Passed"
+			if wrap(result.PolicyCheckSuccess.PolicyCheckOutput) {
+				resultData.Rendered = m.renderTemplate(tmpls, policyCheckSuccessWrappedTmpl, policyCheckSuccessData{PolicyCheckSuccess: *result.PolicyCheckSuccess})
 			} else {
-				resultData.Rendered = m.renderTemplate(policyCheckSuccessUnwrappedTmpl, policyCheckSuccessData{PolicyCheckSuccess: *result.PolicyCheckSuccess})
+				resultData.Rendered = m.renderTemplate(tmpls, policyCheckSuccessUnwrappedTmpl, policyCheckSuccessData{PolicyCheckSuccess: *result.PolicyCheckSuccess})
 			}
 			numPolicyCheckSuccesses++
 		} else if result.ApplySuccess != "" {
-			if m.shouldUseWrappedTmpl(vcsHost, result.ApplySuccess) {
-				resultData.Rendered = m.renderTemplate(applyWrappedSuccessTmpl, struct{ Output string }{result.ApplySuccess})
+			resultData.Status = ":white_check_mark: Applied"
+			resultData.Summary = applySummaryRegex.FindString(result.ApplySuccess)
+			if wrap(result.ApplySuccess) {
+				resultData.Rendered = m.renderTemplate(tmpls, applyWrappedSuccessTmpl, struct{ Output string }{result.ApplySuccess})
 			} else {
-				resultData.Rendered = m.renderTemplate(applyUnwrappedSuccessTmpl, struct{ Output string }{result.ApplySuccess})
+				resultData.Rendered = m.renderTemplate(tmpls, applyUnwrappedSuccessTmpl, struct{ Output string }{result.ApplySuccess})
 			}
 		} else {
 			resultData.Rendered = "Found no template. This is a bug!"
 		}
+		if result.OutputURL != "" {
+			resultData.Rendered += m.renderTemplate(tmpls, outputURLTmpl, struct{ OutputURL string }{result.OutputURL})
+		}
 		resultsTmplData = append(resultsTmplData, resultData)
 	}
 
-	var tmpl *template.Template
+	var tmpl string
 	switch {
 	case len(resultsTmplData) == 1 && common.Command == planCommandTitle && numPlanSuccesses > 0:
 		tmpl = singleProjectPlanSuccessTmpl
@@ -184,17 +254,21 @@ func (m *MarkdownRenderer) renderProjectResults(results []models.ProjectResult,
 		tmpl = singleProjectPlanUnsuccessfulTmpl
 	case len(resultsTmplData) == 1 && common.Command == applyCommandTitle:
 		tmpl = singleProjectApplyTmpl
+	case aggregate && (common.Command == planCommandTitle || common.Command == policyCheckCommandTitle):
+		tmpl = multiProjectPlanAggregatedTmpl
 	case common.Command == planCommandTitle,
 		common.Command == policyCheckCommandTitle:
 		tmpl = multiProjectPlanTmpl
 	case common.Command == approvePoliciesCommandTitle:
 		tmpl = approveAllProjectsTmpl
+	case aggregate && common.Command == applyCommandTitle:
+		tmpl = multiProjectApplyAggregatedTmpl
 	case common.Command == applyCommandTitle:
 		tmpl = multiProjectApplyTmpl
 	default:
 		return "no template matched–this is a bug"
 	}
-	return m.renderTemplate(tmpl, resultData{resultsTmplData, common})
+	return m.renderTemplate(tmpls, tmpl, resultData{resultsTmplData, common})
 }
 
 // shouldUseWrappedTmpl returns true if we should use the wrapped markdown
@@ -202,6 +276,12 @@ func (m *MarkdownRenderer) renderProjectResults(results []models.ProjectResult,
 // load. Some VCS providers or versions of VCS providers don't support this
 // syntax.
 func (m *MarkdownRenderer) shouldUseWrappedTmpl(vcsHost models.VCSHostType, output string) bool {
+	return m.supportsFolding(vcsHost) && strings.Count(output, "\n") > maxUnwrappedLines
+}
+
+// supportsFolding returns true if comments on vcsHost can use the folding
+// markdown syntax.
+func (m *MarkdownRenderer) supportsFolding(vcsHost models.VCSHostType) bool {
 	if m.DisableMarkdownFolding {
 		return false
 	}
@@ -215,121 +295,223 @@ func (m *MarkdownRenderer) shouldUseWrappedTmpl(vcsHost models.VCSHostType, outp
 		return false
 	}
 
-	return strings.Count(output, "\n") > maxUnwrappedLines
+	return true
 }
 
-func (m *MarkdownRenderer) renderTemplate(tmpl *template.Template, data interface{}) string {
+// renderTemplate renders the template named name of tmpls.
+func (m *MarkdownRenderer) renderTemplate(tmpls *template.Template, name string, data interface{}) string {
 	buf := &bytes.Buffer{}
-	if err := tmpl.Execute(buf, data); err != nil {
+	if err := tmpls.ExecuteTemplate(buf, name, data); err != nil {
 		return fmt.Sprintf("Failed to render template, this is a bug: %v", err)
 	}
 	return buf.String()
 }
 
+// defaultCommentTemplates are the templates of comments. Each template is
+// named so that operators can override it, see CommentTemplates.
+var defaultCommentTemplates = template.New("").Funcs(sprig.TxtFuncMap())
+
+// commentTemplate adds the template name to defaultCommentTemplates and
+// returns its name.
+func commentTemplate(name string, text string) string {
+	template.Must(defaultCommentTemplates.New(name).Parse(text))
+	return name
+}
+
+// The header and footer are rendered before and after every comment. They're
+// empty unless they're overridden.
+var headerTmpl = commentTemplate("header", "")
+var footerTmpl = commentTemplate("footer", "")
+
 // todo: refactor to remove duplication #refactor
-var singleProjectApplyTmpl = template.Must(template.New("").Parse(
-	"{{$result := index .Results 0}}Ran {{.Command}} for {{ if $result.ProjectName }}project: `{{$result.ProjectName}}` {{ end }}dir: `{{$result.RepoRelDir}}` workspace: `{{$result.Workspace}}`\n\n{{$result.Rendered}}\n" + logTmpl))
-var singleProjectPlanSuccessTmpl = template.Must(template.New("").Parse(
-	"{{$result := index .Results 0}}Ran {{.Command}} for {{ if $result.ProjectName }}project: `{{$result.ProjectName}}` {{ end }}dir: `{{$result.RepoRelDir}}` workspace: `{{$result.Workspace}}`\n\n{{$result.Rendered}}\n" +
-		"\n" +
-		"{{ if ne .DisableApplyAll true  }}---\n" +
-		"* :fast_forward: To **apply** all unapplied plans from this pull request, comment:\n" +
-		"    * `atlantis apply`\n" +
-		"* :put_litter_in_its_place: To delete all plans and locks for the PR, comment:\n" +
-		"    * `atlantis unlock`{{ end }}" + logTmpl))
-var singleProjectPlanUnsuccessfulTmpl = template.Must(template.New("").Parse(
-	"{{$result := index .Results 0}}Ran {{.Command}} for dir: `{{$result.RepoRelDir}}` workspace: `{{$result.Workspace}}`\n\n" +
-		"{{$result.Rendered}}\n" + logTmpl))
-var approveAllProjectsTmpl = template.Must(template.New("").Funcs(sprig.TxtFuncMap()).Parse(
-	"Approved Policies for {{ len .Results }} projects:\n\n" +
-		"{{ range $result := .Results }}" +
-		"1. {{ if $result.ProjectName }}project: `{{$result.ProjectName}}` {{ end }}dir: `{{$result.RepoRelDir}}` workspace: `{{$result.Workspace}}`\n" +
-		"{{end}}\n" + logTmpl))
-var multiProjectPlanTmpl = template.Must(template.New("").Funcs(sprig.TxtFuncMap()).Parse(
-	"Ran {{.Command}} for {{ len .Results }} projects:\n\n" +
-		"{{ range $result := .Results }}" +
-		"1. {{ if $result.ProjectName }}project: `{{$result.ProjectName}}` {{ end }}dir: `{{$result.RepoRelDir}}` workspace: `{{$result.Workspace}}`\n" +
-		"{{end}}\n" +
-		"{{ $disableApplyAll := .DisableApplyAll }}{{ range $i, $result := .Results }}" +
-		"### {{add $i 1}}. {{ if $result.ProjectName }}project: `{{$result.ProjectName}}` {{ end }}dir: `{{$result.RepoRelDir}}` workspace: `{{$result.Workspace}}`\n" +
-		"{{$result.Rendered}}\n\n" +
-		"{{ if ne $disableApplyAll true }}---\n{{end}}{{end}}{{ if ne .DisableApplyAll true }}{{ if and (gt (len .Results) 0) (not .PlansDeleted) }}* :fast_forward: To **apply** all unapplied plans from this pull request, comment:\n" +
-		"    * `atlantis apply`\n" +
-		"* :put_litter_in_its_place: To delete all plans and locks for the PR, comment:\n" +
-		"    * `atlantis unlock`" +
-		"{{end}}{{end}}" +
-		logTmpl))
-var multiProjectApplyTmpl = template.Must(template.New("").Funcs(sprig.TxtFuncMap()).Parse(
-	"Ran {{.Command}} for {{ len .Results }} projects:\n\n" +
-		"{{ range $result := .Results }}" +
-		"1. {{ if $result.ProjectName }}project: `{{$result.ProjectName}}` {{ end }}dir: `{{$result.RepoRelDir}}` workspace: `{{$result.Workspace}}`\n" +
-		"{{end}}\n" +
-		"{{ range $i, $result := .Results }}" +
-		"### {{add $i 1}}. {{ if $result.ProjectName }}project: `{{$result.ProjectName}}` {{ end }}dir: `{{$result.RepoRelDir}}` workspace: `{{$result.Workspace}}`\n" +
-		"{{$result.Rendered}}\n\n" +
-		"---\n{{end}}" +
-		logTmpl))
-var planSuccessUnwrappedTmpl = template.Must(template.New("").Parse(
-	"```diff\n" +
-		"{{.TerraformOutput}}\n" +
-		"```\n\n" + planNextSteps +
-		"{{ if .HasDiverged }}\n\n:warning: The branch we're merging into is ahead, it is recommended to pull new commits first.{{end}}"))
-
-var planSuccessWrappedTmpl = template.Must(template.New("").Parse(
-	"<details><summary>Show Output</summary>\n\n" +
-		"```diff\n" +
-		"{{.TerraformOutput}}\n" +
-		"```\n\n" +
-		planNextSteps + "\n" +
-		"</details>" + "\n" +
-		"{{.PlanSummary}}" +
-		"{{ if .HasDiverged }}\n\n:warning: The branch we're merging into is ahead, it is recommended to pull new commits first.{{end}}"))
-
-var policyCheckSuccessUnwrappedTmpl = template.Must(template.New("").Parse(
-	"```diff\n" +
-		"{{.PolicyCheckOutput}}\n" +
-		"```\n\n" + policyCheckNextSteps +
-		"{{ if .HasDiverged }}\n\n:warning: The branch we're merging into is ahead, it is recommended to pull new commits first.{{end}}"))
-
-var policyCheckSuccessWrappedTmpl = template.Must(template.New("").Parse(
-	"<details><summary>Show Output</summary>\n\n" +
-		"```diff\n" +
-		"{{.PolicyCheckOutput}}\n" +
-		"```\n\n" +
-		policyCheckNextSteps + "\n" +
-		"</details>" +
-		"{{ if .HasDiverged }}\n\n:warning: The branch we're merging into is ahead, it is recommended to pull new commits first.{{end}}"))
+var singleProjectApplyTmpl = commentTemplate("singleProjectApply",
+	"{{$result := index .Results 0}}Ran {{.Command}} for {{ if $result.ProjectName }}project: `{{$result.ProjectName}}` {{ end }}dir: `{{$result.RepoRelDir}}` workspace: `{{$result.Workspace}}`\n\n{{$result.Rendered}}\n"+logTmpl)
+var singleProjectPlanSuccessTmpl = commentTemplate("singleProjectPlanSuccess",
+	"{{$result := index .Results 0}}Ran {{.Command}} for {{ if $result.ProjectName }}project: `{{$result.ProjectName}}` {{ end }}dir: `{{$result.RepoRelDir}}` workspace: `{{$result.Workspace}}`\n\n{{$result.Rendered}}\n"+
+		"\n"+
+		"{{ if ne .DisableApplyAll true  }}---\n"+
+		"{{ template \"applyAllNextSteps\" . }}{{ end }}"+logTmpl)
+var singleProjectPlanUnsuccessfulTmpl = commentTemplate("singleProjectPlanUnsuccessful",
+	"{{$result := index .Results 0}}Ran {{.Command}} for dir: `{{$result.RepoRelDir}}` workspace: `{{$result.Workspace}}`\n\n"+
+		"{{$result.Rendered}}\n"+logTmpl)
+var approveAllProjectsTmpl = commentTemplate("approveAllProjects",
+	"Approved Policies for {{ len .Results }} projects:\n\n"+
+		"{{ range $result := .Results }}"+
+		"1. {{ if $result.ProjectName }}project: `{{$result.ProjectName}}` {{ end }}dir: `{{$result.RepoRelDir}}` workspace: `{{$result.Workspace}}`\n"+
+		"{{end}}\n"+logTmpl)
+var multiProjectPlanTmpl = commentTemplate("multiProjectPlan",
+	"Ran {{.Command}} for {{ len .Results }} projects:\n\n"+
+		"{{ range $result := .Results }}"+
+		"1. {{ if $result.ProjectName }}project: `{{$result.ProjectName}}` {{ end }}dir: `{{$result.RepoRelDir}}` workspace: `{{$result.Workspace}}`\n"+
+		"{{end}}\n"+
+		"{{ $disableApplyAll := .DisableApplyAll }}{{ range $i, $result := .Results }}"+
+		"### {{add $i 1}}. {{ if $result.ProjectName }}project: `{{$result.ProjectName}}` {{ end }}dir: `{{$result.RepoRelDir}}` workspace: `{{$result.Workspace}}`\n"+
+		"{{$result.Rendered}}\n\n"+
+		"{{ if ne $disableApplyAll true }}---\n{{end}}{{end}}{{ if ne .DisableApplyAll true }}{{ if and (gt (len .Results) 0) (not .PlansDeleted) }}"+
+		"{{ template \"applyAllNextSteps\" . }}"+
+		"{{end}}{{end}}"+
+		logTmpl)
+var multiProjectApplyTmpl = commentTemplate("multiProjectApply",
+	"Ran {{.Command}} for {{ len .Results }} projects:\n\n"+
+		"{{ range $result := .Results }}"+
+		"1. {{ if $result.ProjectName }}project: `{{$result.ProjectName}}` {{ end }}dir: `{{$result.RepoRelDir}}` workspace: `{{$result.Workspace}}`\n"+
+		"{{end}}\n"+
+		"{{ range $i, $result := .Results }}"+
+		"### {{add $i 1}}. {{ if $result.ProjectName }}project: `{{$result.ProjectName}}` {{ end }}dir: `{{$result.RepoRelDir}}` workspace: `{{$result.Workspace}}`\n"+
+		"{{$result.Rendered}}\n\n"+
+		"---\n{{end}}"+
+		logTmpl)
+
+var multiProjectPlanAggregatedTmpl = commentTemplate("multiProjectPlanAggregated",
+	aggregatedProjectsTmpl+
+		"{{ if ne .DisableApplyAll true }}{{ if and (gt (len .Results) 0) (not .PlansDeleted) }}\n---\n"+
+		"{{ template \"applyAllNextSteps\" . }}"+
+		"{{end}}{{end}}"+
+		logTmpl)
+var multiProjectApplyAggregatedTmpl = commentTemplate("multiProjectApplyAggregated",
+	aggregatedProjectsTmpl+
+		logTmpl)
+
+// aggregatedProjectsTmpl is a table of the statuses of projects followed by
+// a collapsed section of each project's output.
+var aggregatedProjectsTmpl = `{{ template "aggregatedProjects" . }}`
+var _ = commentTemplate("aggregatedProjects",
+	"Ran {{.Command}} for {{ len .Results }} projects:\n\n"+
+		"| # | Project | Status | Summary |\n"+
+		"|---|---------|--------|---------|\n"+
+		"{{ range $i, $result := .Results }}"+
+		"| {{add $i 1}} | {{ if $result.ProjectName }}project: `{{$result.ProjectName}}` {{ end }}dir: `{{$result.RepoRelDir}}` workspace: `{{$result.Workspace}}` | {{$result.Status}} | {{$result.Summary}} |\n"+
+		"{{end}}"+
+		"{{ range $i, $result := .Results }}"+
+		"\n<details><summary>{{add $i 1}}. {{ if $result.ProjectName }}project: <code>{{$result.ProjectName}}</code> {{ end }}dir: <code>{{$result.RepoRelDir}}</code> workspace: <code>{{$result.Workspace}}</code> {{$result.Status}}</summary>\n\n"+
+		"{{$result.Rendered}}\n"+
+		"</details>\n"+
+		"{{end}}")
+
+// applySummaryRegex matches the summary of the output of applies.
+var applySummaryRegex = regexp.MustCompile(`Apply complete! Resources: \d+ added, \d+ changed, \d+ destroyed.`)
+
+// applyAllNextSteps are instructions appended after successful plans as to
+// how to apply or delete all of them.
+var _ = commentTemplate("applyAllNextSteps",
+	"* :fast_forward: To **apply** all unapplied plans from this pull request, comment:\n"+
+		"    * `atlantis apply`\n"+
+		"* :put_litter_in_its_place: To delete all plans and locks for the PR, comment:\n"+
+		"    * `atlantis unlock`")
+var planSuccessUnwrappedTmpl = commentTemplate("planSuccessUnwrapped",
+	lintWarningTmpl+
+		riskWarningTmpl+
+		providerUpgradesTmpl+
+		"```diff\n"+
+		"{{.TerraformOutput}}\n"+
+		"```\n\n"+planNextSteps+
+		divergedTmpl)
+
+var planSuccessWrappedTmpl = commentTemplate("planSuccessWrapped",
+	lintWarningTmpl+
+		riskWarningTmpl+
+		providerUpgradesTmpl+
+		planChangesTmpl+
+		"<details><summary>Show Output</summary>\n\n"+
+		"```diff\n"+
+		"{{.TerraformOutput}}\n"+
+		"```\n\n"+
+		planNextSteps+"\n"+
+		"</details>"+"\n"+
+		"{{.PlanSummary}}"+
+		divergedTmpl)
+
+var policyCheckSuccessUnwrappedTmpl = commentTemplate("policyCheckSuccessUnwrapped",
+	"```diff\n"+
+		"{{.PolicyCheckOutput}}\n"+
+		"```\n\n"+policyCheckNextSteps+
+		divergedTmpl)
+
+var policyCheckSuccessWrappedTmpl = commentTemplate("policyCheckSuccessWrapped",
+	"<details><summary>Show Output</summary>\n\n"+
+		"```diff\n"+
+		"{{.PolicyCheckOutput}}\n"+
+		"```\n\n"+
+		policyCheckNextSteps+"\n"+
+		"</details>"+
+		divergedTmpl)
+
+// planChangesTmpl is a table of the resources a plan changes so they can be
+// reviewed without expanding the output.
+var planChangesTmpl = `{{ template "planChanges" . }}`
+var _ = commentTemplate("planChanges",
+	"{{ if .Changes }}| Action | Count | Resources |\n"+
+		"|--------|-------|-----------|\n"+
+		"{{ range .Changes }}| {{ .Action.Title }} | {{ len .Addresses }} | {{ range $i, $a := .Addresses }}{{ if $i }}, {{ end }}`{{ $a }}`{{ end }} |\n{{ end }}"+
+		"\n{{ end }}")
+
+// lintWarningTmpl lists the issues tflint found in projects whose lint config
+// isn't blocking.
+var lintWarningTmpl = `{{ template "lintWarning" . }}`
+var _ = commentTemplate("lintWarning",
+	"{{ if .LintFindings }}:warning: **tflint found issues:**\n\n{{ .LintFindings.Markdown }}\n{{ end }}")
+
+// riskWarningTmpl warns about the resources a plan destroys or replaces since
+// those changes are easy to miss in long plans.
+var riskWarningTmpl = `{{ template "riskWarning" . }}`
+var _ = commentTemplate("riskWarning",
+	"{{ if .RiskyChanges }}:warning: **This plan destroys or replaces resources:**\n\n"+
+		"{{ range .RiskyChanges }}* **{{ .Action.Title }}**: {{ range $i, $a := .Addresses }}{{ if $i }}, {{ end }}`{{ $a }}`{{ end }}\n{{ end }}"+
+		"{{ if .RequiresAllowDestroy }}\nApplying it must be confirmed, comment:\n"+
+		"    * `{{.ApplyCmd}} --allow-destroy`\n{{ end }}"+
+		"\n{{ end }}")
+
+// providerUpgradesTmpl lists the provider versions that changed for plans run
+// with --upgrade so dependency bumps can be reviewed.
+var providerUpgradesTmpl = `{{ template "providerUpgrades" . }}`
+var _ = commentTemplate("providerUpgrades",
+	"{{ if .UpgradeProviders }}{{ if .ProviderUpgrades }}**Provider upgrades:**\n\n"+
+		"| Provider | From | To |\n"+
+		"|----------|------|----|\n"+
+		"{{ range .ProviderUpgrades }}| `{{ .Provider }}` | {{ or .From \"none\" }} | {{ or .To \"removed\" }} |\n{{ end }}"+
+		"{{ else }}**Provider upgrades:** all providers are already up to date.\n{{ end }}\n{{ end }}")
+
+// divergedTmpl warns that the base branch is ahead of the pull request.
+var divergedTmpl = `{{ template "diverged" . }}`
+var _ = commentTemplate("diverged",
+	"{{ if .HasDiverged }}\n\n:warning: The branch we're merging into is ahead, it is recommended to pull new commits first.{{end}}")
 
 // policyCheckNextSteps are instructions appended after successful plans as to what
 // to do next.
-var policyCheckNextSteps = "* :arrow_forward: To **apply** this plan, comment:\n" +
-	"    * `{{.ApplyCmd}}`\n" +
-	"* :put_litter_in_its_place: To **delete** this plan click [here]({{.LockURL}})\n" +
-	"* :repeat: To re-run policies **plan** this project again by commenting:\n" +
-	"    * `{{.RePlanCmd}}`"
+var policyCheckNextSteps = `{{ template "policyCheckNextSteps" . }}`
+var _ = commentTemplate("policyCheckNextSteps",
+	"* :arrow_forward: To **apply** this plan, comment:\n"+
+		"    * `{{.ApplyCmd}}`\n"+
+		"* :put_litter_in_its_place: To **delete** this plan click [here]({{.LockURL}})\n"+
+		"* :repeat: To re-run policies **plan** this project again by commenting:\n"+
+		"    * `{{.RePlanCmd}}`")
 
 // planNextSteps are instructions appended after successful plans as to what
 // to do next.
-var planNextSteps = "{{ if .PlanWasDeleted }}This plan was not saved because one or more projects failed and automerge requires all plans pass.{{ else }}" +
-	"{{ if not .DisableApply }}* :arrow_forward: To **apply** this plan, comment:\n" +
-	"    * `{{.ApplyCmd}}`\n{{end}}" +
-	"{{ if not .DisableRepoLocking }}* :put_litter_in_its_place: To **delete** this plan click [here]({{.LockURL}})\n{{end}}" +
-	"* :repeat: To **plan** this project again, comment:\n" +
-	"    * `{{.RePlanCmd}}`{{end}}"
-var applyUnwrappedSuccessTmpl = template.Must(template.New("").Parse(
-	"```diff\n" +
-		"{{.Output}}\n" +
-		"```"))
-var applyWrappedSuccessTmpl = template.Must(template.New("").Parse(
-	"<details><summary>Show Output</summary>\n\n" +
-		"```diff\n" +
-		"{{.Output}}\n" +
-		"```\n" +
-		"</details>"))
+var planNextSteps = `{{ template "planNextSteps" . }}`
+var _ = commentTemplate("planNextSteps",
+	"{{ if .PlanWasDeleted }}This plan was not saved because one or more projects failed and automerge requires all plans pass.{{ else }}"+
+		"{{ if not .DisableApply }}* :arrow_forward: To **apply** this plan, comment:\n"+
+		"    * `{{.ApplyCmd}}`\n{{end}}"+
+		"{{ if not .DisableRepoLocking }}* :put_litter_in_its_place: To **delete** this plan click [here]({{.LockURL}})\n{{end}}"+
+		"{{ if .PlanJSONURL }}* :page_facing_up: To view this plan as JSON click [here]({{.PlanJSONURL}})\n{{end}}"+
+		"* :repeat: To **plan** this project again, comment:\n"+
+		"    * `{{.RePlanCmd}}`{{end}}")
+var applyUnwrappedSuccessTmpl = commentTemplate("applyUnwrappedSuccess",
+	"```diff\n"+
+		"{{.Output}}\n"+
+		"```")
+var applyWrappedSuccessTmpl = commentTemplate("applyWrappedSuccess",
+	"<details><summary>Show Output</summary>\n\n"+
+		"```diff\n"+
+		"{{.Output}}\n"+
+		"```\n"+
+		"</details>")
 var unwrappedErrTmplText = "**{{.Command}} Error**\n" +
 	"```\n" +
 	"{{.Error}}\n" +
 	"```" +
+	errorHints +
 	"{{ if eq .Command \"Policy Check\" }}" +
 	"\n* :heavy_check_mark: To **approve** failing policies either request an approval from approvers or address the failure by modifying the codebase.\n" +
 	"{{ end }}"
@@ -337,11 +519,26 @@ var wrappedErrTmplText = "**{{.Command}} Error**\n" +
 	"<details><summary>Show Output</summary>\n\n" +
 	"```\n" +
 	"{{.Error}}\n" +
-	"```\n</details>"
-var unwrappedErrTmpl = template.Must(template.New("").Parse(unwrappedErrTmplText))
-var unwrappedErrWithLogTmpl = template.Must(template.New("").Parse(unwrappedErrTmplText + logTmpl))
-var wrappedErrTmpl = template.Must(template.New("").Parse(wrappedErrTmplText))
+	"```\n</details>" +
+	errorHints
+
+// errorHints are the remediation hints of an error, see ErrorHints.
+var errorHints = `{{ template "errorHints" . }}`
+var _ = commentTemplate("errorHints",
+	"{{ range .Hints }}\n* :bulb: {{ . }}{{ end }}")
+var unwrappedErrTmpl = commentTemplate("unwrappedErr", unwrappedErrTmplText)
+var unwrappedErrWithLogTmpl = commentTemplate("unwrappedErrWithLog", `{{ template "unwrappedErr" . }}`+logTmpl)
+var wrappedErrTmpl = commentTemplate("wrappedErr", wrappedErrTmplText)
 var failureTmplText = "**{{.Command}} Failed**: {{.Failure}}"
-var failureTmpl = template.Must(template.New("").Parse(failureTmplText))
-var failureWithLogTmpl = template.Must(template.New("").Parse(failureTmplText + logTmpl))
-var logTmpl = "{{if .Verbose}}\n<details><summary>Log</summary>\n  <p>\n\n```\n{{.Log}}```\n</p></details>{{end}}\n"
+var failureTmpl = commentTemplate("failure", failureTmplText)
+var failureWithLogTmpl = commentTemplate("failureWithLog", `{{ template "failure" . }}`+logTmpl)
+var keptPlansTmpl = commentTemplate("keptPlans",
+	"Kept the plans of {{ len . }} project{{ if ne (len .) 1 }}s{{ end }} not modified by the new commits:\n\n"+
+		"{{ range . }}* {{ if .ProjectName }}project: `{{.ProjectName}}` {{ end }}dir: `{{.RepoRelDir}}` workspace: `{{.Workspace}}`\n{{ end }}")
+
+// outputURLTmpl links to the full log of a project's command.
+var outputURLTmpl = commentTemplate("outputURL",
+	"\n\n* :scroll: To view the full log click [here]({{.OutputURL}})")
+var logTmpl = `{{ template "log" . }}`
+var _ = commentTemplate("log",
+	"{{if .Verbose}}\n<details><summary>Log</summary>\n  <p>\n\n```\n{{.Log}}```\n</p></details>{{end}}\n")
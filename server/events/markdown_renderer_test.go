@@ -16,6 +16,7 @@ package events_test
 import (
 	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 	"testing"
 
@@ -60,7 +61,7 @@ func TestRenderErr(t *testing.T) {
 		}
 		for _, verbose := range []bool{true, false} {
 			t.Run(fmt.Sprintf("%s_%t", c.Description, verbose), func(t *testing.T) {
-				s := r.Render(res, c.Command, "log", verbose, models.Github)
+				s := r.Render(res, c.Command, "log", verbose, repoOn(models.Github))
 				if !verbose {
 					Equals(t, c.Expected, s)
 				} else {
@@ -105,7 +106,7 @@ func TestRenderFailure(t *testing.T) {
 		}
 		for _, verbose := range []bool{true, false} {
 			t.Run(fmt.Sprintf("%s_%t", c.Description, verbose), func(t *testing.T) {
-				s := r.Render(res, c.Command, "log", verbose, models.Github)
+				s := r.Render(res, c.Command, "log", verbose, repoOn(models.Github))
 				if !verbose {
 					Equals(t, c.Expected, s)
 				} else {
@@ -122,7 +123,7 @@ func TestRenderErrAndFailure(t *testing.T) {
 		Error:   errors.New("error"),
 		Failure: "failure",
 	}
-	s := r.Render(res, models.PlanCommand, "", false, models.Github)
+	s := r.Render(res, models.PlanCommand, "", false, repoOn(models.Github))
 	Equals(t, "**Plan Error**\n```\nerror\n```\n", s)
 }
 
@@ -753,7 +754,7 @@ $$$
 			}
 			for _, verbose := range []bool{true, false} {
 				t.Run(c.Description, func(t *testing.T) {
-					s := r.Render(res, c.Command, "log", verbose, c.VCSHost)
+					s := r.Render(res, c.Command, "log", verbose, repoOn(c.VCSHost))
 					expWithBackticks := strings.Replace(c.Expected, "$", "`", -1)
 					if !verbose {
 						Equals(t, expWithBackticks, s)
@@ -906,7 +907,7 @@ $$$
 			}
 			for _, verbose := range []bool{true, false} {
 				t.Run(c.Description, func(t *testing.T) {
-					s := r.Render(res, c.Command, "log", verbose, c.VCSHost)
+					s := r.Render(res, c.Command, "log", verbose, repoOn(c.VCSHost))
 					expWithBackticks := strings.Replace(c.Expected, "$", "`", -1)
 					if !verbose {
 						Equals(t, expWithBackticks, s)
@@ -1052,7 +1053,7 @@ $$$
 			}
 			for _, verbose := range []bool{true, false} {
 				t.Run(c.Description, func(t *testing.T) {
-					s := r.Render(res, c.Command, "log", verbose, c.VCSHost)
+					s := r.Render(res, c.Command, "log", verbose, repoOn(c.VCSHost))
 					expWithBackticks := strings.Replace(c.Expected, "$", "`", -1)
 					if !verbose {
 						Equals(t, expWithBackticks, s)
@@ -1079,12 +1080,62 @@ func TestRenderProjectResults_DisableFolding(t *testing.T) {
 				Error:      errors.New(strings.Repeat("line\n", 13)),
 			},
 		},
-	}, models.PlanCommand, "log", false, models.Github)
+	}, models.PlanCommand, "log", false, repoOn(models.Github))
 	Equals(t, false, strings.Contains(rendered, "<details>"))
 }
 
+// Test that results link to their full log.
+func TestRenderProjectResults_OutputURL(t *testing.T) {
+	mr := events.MarkdownRenderer{}
+	rendered := mr.Render(events.CommandResult{
+		ProjectResults: []models.ProjectResult{
+			{
+				RepoRelDir:   ".",
+				Workspace:    "default",
+				ApplySuccess: "success",
+				OutputURL:    "https://atlantis/logs/id",
+			},
+		},
+	}, models.ApplyCommand, "log", false, repoOn(models.Github))
+	Equals(t, "Ran Apply for dir: `.` workspace: `default`\n\n```diff\nsuccess\n```\n\n* :scroll: To view the full log click [here](https://atlantis/logs/id)\n\n", rendered)
+}
+
 // Test that if the output is longer than 12 lines, it gets wrapped on the right
 // VCS hosts during an error.
+func TestRenderProjectResults_KeptPlans(t *testing.T) {
+	mr := events.MarkdownRenderer{}
+	keptPlans := []models.ProjectResult{
+		{
+			RepoRelDir:  "dir1",
+			Workspace:   "default",
+			PlanSuccess: &models.PlanSuccess{},
+		},
+		{
+			RepoRelDir:  "dir2",
+			Workspace:   "staging",
+			ProjectName: "proj",
+			PlanSuccess: &models.PlanSuccess{},
+		},
+	}
+	rendered := mr.Render(events.CommandResult{
+		KeptPlans: keptPlans,
+	}, models.PlanCommand, "log", false, repoOn(models.Github))
+	Equals(t, "Kept the plans of 2 projects not modified by the new commits:\n\n* dir: `dir1` workspace: `default`\n* project: `proj` dir: `dir2` workspace: `staging`\n", rendered)
+
+	// The kept plans are listed after the plans that ran.
+	rendered = mr.Render(events.CommandResult{
+		ProjectResults: []models.ProjectResult{
+			{
+				RepoRelDir:   ".",
+				Workspace:    "default",
+				ApplySuccess: "success",
+			},
+		},
+		KeptPlans: keptPlans[:1],
+	}, models.ApplyCommand, "log", false, repoOn(models.Github))
+	Equals(t, "Ran Apply for dir: `.` workspace: `default`\n\n```diff\nsuccess\n```\n\n\nKept the plans of 1 project not modified by the new commits:\n\n* dir: `dir1` workspace: `default`\n", rendered)
+}
+
 func TestRenderProjectResults_WrappedErr(t *testing.T) {
 	cases := []struct {
 		VCSHost                 models.VCSHostType
@@ -1163,7 +1214,7 @@ func TestRenderProjectResults_WrappedErr(t *testing.T) {
 							Error:      errors.New(c.Output),
 						},
 					},
-				}, models.PlanCommand, "log", false, c.VCSHost)
+				}, models.PlanCommand, "log", false, repoOn(c.VCSHost))
 				var exp string
 				if c.ShouldWrap {
 					exp = `Ran Plan for dir: $.$ workspace: $default$
@@ -1194,6 +1245,43 @@ $$$
 	}
 }
 
+// Test that remediation hints are added after errors, even if they're
+// wrapped.
+func TestRenderProjectResults_ErrorHints(t *testing.T) {
+	mr := events.MarkdownRenderer{
+		ErrorHints: events.NewErrorHints([]events.ErrorHint{
+			{Name: "quota", Pattern: regexp.MustCompile(`QuotaExceeded`), Hint: "Request a quota increase."},
+		}),
+	}
+	output := "Error: Error acquiring the state lock\nQuotaExceeded" + strings.Repeat("\nline", 12)
+	rendered := mr.Render(events.CommandResult{
+		ProjectResults: []models.ProjectResult{
+			{
+				RepoRelDir: ".",
+				Workspace:  "default",
+				Error:      errors.New(output),
+			},
+		},
+	}, models.PlanCommand, "log", false, repoOn(models.Github))
+	exp := `Ran Plan for dir: $.$ workspace: $default$
+
+**Plan Error**
+<details><summary>Show Output</summary>
+
+$$$
+` + output + `
+$$$
+</details>
+* :bulb: Request a quota increase.
+* :bulb: ` + events.DefaultErrorHints[0].Hint + `
+
+`
+	Equals(t, strings.Replace(exp, "$", "`", -1), rendered)
+
+	rendered = mr.Render(events.CommandResult{Error: errors.New("QuotaExceeded")}, models.PlanCommand, "log", false, repoOn(models.Github))
+	Equals(t, "**Plan Error**\n```\nQuotaExceeded\n```\n* :bulb: Request a quota increase.\n", rendered)
+}
+
 // Test that if the output is longer than 12 lines, it gets wrapped on the right
 // VCS hosts for a single project.
 func TestRenderProjectResults_WrapSingleProject(t *testing.T) {
@@ -1288,7 +1376,7 @@ func TestRenderProjectResults_WrapSingleProject(t *testing.T) {
 					}
 					rendered := mr.Render(events.CommandResult{
 						ProjectResults: []models.ProjectResult{pr},
-					}, cmd, "log", false, c.VCSHost)
+					}, cmd, "log", false, repoOn(c.VCSHost))
 
 					// Check result.
 					var exp string
@@ -1383,7 +1471,7 @@ func TestRenderProjectResults_MultiProjectApplyWrapped(t *testing.T) {
 				ApplySuccess: tfOut,
 			},
 		},
-	}, models.ApplyCommand, "log", false, models.Github)
+	}, models.ApplyCommand, "log", false, repoOn(models.Github))
 	exp := `Ran Apply for 2 projects:
 
 1. dir: $.$ workspace: $staging$
@@ -1439,7 +1527,7 @@ func TestRenderProjectResults_MultiProjectPlanWrapped(t *testing.T) {
 				},
 			},
 		},
-	}, models.PlanCommand, "log", false, models.Github)
+	}, models.PlanCommand, "log", false, repoOn(models.Github))
 	exp := `Ran Plan for 2 projects:
 
 1. dir: $.$ workspace: $staging$
@@ -1486,6 +1574,410 @@ Plan: 1 to add, 0 to change, 0 to destroy.
 	Equals(t, expWithBackticks, rendered)
 }
 
+func TestRenderProjectResults_PlanChangesTable(t *testing.T) {
+	mr := events.MarkdownRenderer{}
+	tfOut := `Terraform will perform the following actions:
+
+  # aws_instance.web will be created
++ resource "aws_instance" "web" {}
+
+  # aws_instance.db must be replaced
+-/+ resource "aws_instance" "db" {}
+
+  # module.dns.aws_route53_record.a["www"] will be updated in-place
+~ resource "aws_route53_record" "a" {}
+
+  # aws_instance.old will be destroyed
+- resource "aws_instance" "old" {}
+
+  # aws_instance.cache will be created
++ resource "aws_instance" "cache" {}
+
+Plan: 3 to add, 1 to change, 2 to destroy.`
+	rendered := mr.Render(events.CommandResult{
+		ProjectResults: []models.ProjectResult{
+			{
+				RepoRelDir: ".",
+				Workspace:  "default",
+				PlanSuccess: &models.PlanSuccess{
+					TerraformOutput: tfOut,
+					LockURL:         "lock-url",
+					ApplyCmd:        "apply-cmd",
+					RePlanCmd:       "replan-cmd",
+				},
+			},
+		},
+	}, models.PlanCommand, "log", false, repoOn(models.Github))
+	exp := `Ran Plan for dir: $.$ workspace: $default$
+
+| Action | Count | Resources |
+|--------|-------|-----------|
+| Add | 2 | $aws_instance.web$, $aws_instance.cache$ |
+| Change | 1 | $module.dns.aws_route53_record.a["www"]$ |
+| Replace | 1 | $aws_instance.db$ |
+| Destroy | 1 | $aws_instance.old$ |
+
+<details><summary>Show Output</summary>
+
+$$$diff
+` + tfOut + `
+$$$
+
+* :arrow_forward: To **apply** this plan, comment:
+    * $apply-cmd$
+* :put_litter_in_its_place: To **delete** this plan click [here](lock-url)
+* :repeat: To **plan** this project again, comment:
+    * $replan-cmd$
+</details>
+Plan: 3 to add, 1 to change, 2 to destroy.
+
+---
+* :fast_forward: To **apply** all unapplied plans from this pull request, comment:
+    * $atlantis apply$
+* :put_litter_in_its_place: To delete all plans and locks for the PR, comment:
+    * $atlantis unlock$
+`
+	expWithBackticks := strings.Replace(exp, "$", "`", -1)
+	Equals(t, expWithBackticks, rendered)
+}
+
+// Test that plans link to their JSON rendering.
+func TestRenderProjectResults_PlanJSONURL(t *testing.T) {
+	mr := events.MarkdownRenderer{}
+	rendered := mr.Render(events.CommandResult{
+		ProjectResults: []models.ProjectResult{
+			{
+				RepoRelDir: ".",
+				Workspace:  "default",
+				PlanSuccess: &models.PlanSuccess{
+					TerraformOutput: "terraform-output",
+					LockURL:         "lock-url",
+					ApplyCmd:        "apply-cmd",
+					RePlanCmd:       "replan-cmd",
+					PlanJSONURL:     "plan-json-url",
+				},
+			},
+		},
+	}, models.PlanCommand, "log", false, repoOn(models.Github))
+	exp := `Ran Plan for dir: $.$ workspace: $default$
+
+$$$diff
+terraform-output
+$$$
+
+* :arrow_forward: To **apply** this plan, comment:
+    * $apply-cmd$
+* :put_litter_in_its_place: To **delete** this plan click [here](lock-url)
+* :page_facing_up: To view this plan as JSON click [here](plan-json-url)
+* :repeat: To **plan** this project again, comment:
+    * $replan-cmd$
+
+---
+* :fast_forward: To **apply** all unapplied plans from this pull request, comment:
+    * $atlantis apply$
+* :put_litter_in_its_place: To delete all plans and locks for the PR, comment:
+    * $atlantis unlock$
+`
+	expWithBackticks := strings.Replace(exp, "$", "`", -1)
+	Equals(t, expWithBackticks, rendered)
+}
+
+// Test that the resources a plan destroys or replaces are highlighted above
+// its output.
+func TestRenderProjectResults_RiskyChanges(t *testing.T) {
+	mr := events.MarkdownRenderer{}
+	rendered := mr.Render(events.CommandResult{
+		ProjectResults: []models.ProjectResult{
+			{
+				RepoRelDir: ".",
+				Workspace:  "default",
+				PlanSuccess: &models.PlanSuccess{
+					TerraformOutput: "terraform-output",
+					LockURL:         "lock-url",
+					ApplyCmd:        "apply-cmd",
+					RePlanCmd:       "replan-cmd",
+					RiskyChanges: []models.ResourceChanges{
+						{Action: models.ReplaceResourceAction, Addresses: []string{"aws_db_instance.db", "random_id.id"}},
+						{Action: models.DeleteResourceAction, Addresses: []string{"aws_s3_bucket.logs"}},
+					},
+					RequiresAllowDestroy: true,
+				},
+			},
+		},
+	}, models.PlanCommand, "log", false, repoOn(models.Github))
+	exp := `Ran Plan for dir: $.$ workspace: $default$
+
+:warning: **This plan destroys or replaces resources:**
+
+* **Replace**: $aws_db_instance.db$, $random_id.id$
+* **Destroy**: $aws_s3_bucket.logs$
+
+Applying it must be confirmed, comment:
+    * $apply-cmd --allow-destroy$
+
+$$$diff
+terraform-output
+$$$
+
+* :arrow_forward: To **apply** this plan, comment:
+    * $apply-cmd$
+* :put_litter_in_its_place: To **delete** this plan click [here](lock-url)
+* :repeat: To **plan** this project again, comment:
+    * $replan-cmd$
+
+---
+* :fast_forward: To **apply** all unapplied plans from this pull request, comment:
+    * $atlantis apply$
+* :put_litter_in_its_place: To delete all plans and locks for the PR, comment:
+    * $atlantis unlock$
+`
+	expWithBackticks := strings.Replace(exp, "$", "`", -1)
+	Equals(t, expWithBackticks, rendered)
+}
+
+func TestRenderProjectResults_LintFindings(t *testing.T) {
+	mr := events.MarkdownRenderer{}
+	rendered := mr.Render(events.CommandResult{
+		ProjectResults: []models.ProjectResult{
+			{
+				RepoRelDir: ".",
+				Workspace:  "default",
+				PlanSuccess: &models.PlanSuccess{
+					TerraformOutput: "terraform-output",
+					LockURL:         "lock-url",
+					ApplyCmd:        "apply-cmd",
+					RePlanCmd:       "replan-cmd",
+					LintFindings: models.LintFindings{
+						{Rule: "aws_instance_invalid_type", Severity: "error", Message: "invalid instance type", Filename: "main.tf", Line: 12},
+						{Rule: "terraform_unused_declarations", Severity: "warning", Message: "variable \"a\" is declared but not used", Filename: "variables.tf", Line: 3},
+						{Rule: "terraform_deprecated_index", Severity: "warning", Message: "List items should be accessed using square brackets", Filename: "variables.tf", Line: 9},
+					},
+				},
+			},
+		},
+	}, models.PlanCommand, "log", false, repoOn(models.Github))
+	exp := `Ran Plan for dir: $.$ workspace: $default$
+
+:warning: **tflint found issues:**
+
+**Error**
+
+* $main.tf:12$ invalid instance type ($aws_instance_invalid_type$)
+
+**Warning**
+
+* $variables.tf:3$ variable "a" is declared but not used ($terraform_unused_declarations$)
+* $variables.tf:9$ List items should be accessed using square brackets ($terraform_deprecated_index$)
+
+$$$diff
+terraform-output
+$$$
+`
+	expWithBackticks := strings.Replace(exp, "$", "`", -1)
+	Assert(t, strings.HasPrefix(rendered, expWithBackticks), "exp prefix\n%s\ngot\n%s", expWithBackticks, rendered)
+}
+
+func TestRenderProjectResults_ProviderUpgrades(t *testing.T) {
+	cases := []struct {
+		upgrades []models.ProviderUpgrade
+		exp      string
+	}{
+		{
+			upgrades: []models.ProviderUpgrade{
+				{Provider: "registry.terraform.io/hashicorp/aws", From: "3.37.0", To: "3.38.0"},
+				{Provider: "registry.terraform.io/hashicorp/google", To: "4.0.0"},
+				{Provider: "registry.terraform.io/hashicorp/null", From: "3.1.0"},
+			},
+			exp: `**Provider upgrades:**
+
+| Provider | From | To |
+|----------|------|----|
+| $registry.terraform.io/hashicorp/aws$ | 3.37.0 | 3.38.0 |
+| $registry.terraform.io/hashicorp/google$ | none | 4.0.0 |
+| $registry.terraform.io/hashicorp/null$ | 3.1.0 | removed |
+`,
+		},
+		{
+			exp: "**Provider upgrades:** all providers are already up to date.\n",
+		},
+	}
+	for _, c := range cases {
+		mr := events.MarkdownRenderer{}
+		rendered := mr.Render(events.CommandResult{
+			ProjectResults: []models.ProjectResult{
+				{
+					RepoRelDir: ".",
+					Workspace:  "default",
+					PlanSuccess: &models.PlanSuccess{
+						TerraformOutput:  "terraform-output",
+						LockURL:          "lock-url",
+						ApplyCmd:         "apply-cmd",
+						RePlanCmd:        "replan-cmd",
+						UpgradeProviders: true,
+						ProviderUpgrades: c.upgrades,
+					},
+				},
+			},
+		}, models.PlanCommand, "log", false, repoOn(models.Github))
+		exp := `Ran Plan for dir: $.$ workspace: $default$
+
+` + c.exp + `
+$$$diff
+terraform-output
+$$$
+`
+		expWithBackticks := strings.Replace(exp, "$", "`", -1)
+		Assert(t, strings.HasPrefix(rendered, expWithBackticks), "exp prefix\n%s\ngot\n%s", expWithBackticks, rendered)
+	}
+}
+
+// Test that compacted plans are short enough not to be wrapped.
+func TestRenderProjectResults_CompactPlanOutput(t *testing.T) {
+	mr := events.MarkdownRenderer{CompactPlanOutput: true}
+	tfOut := "~ resource \"aws_instance\" \"web\" {\n" +
+		strings.Repeat("        tag = \"x\"\n", 13) +
+		"      ~ instance_type = \"t2.micro\" -> \"t2.small\"\n" +
+		"    }"
+	rendered := mr.Render(events.CommandResult{
+		ProjectResults: []models.ProjectResult{
+			{
+				RepoRelDir: ".",
+				Workspace:  "default",
+				PlanSuccess: &models.PlanSuccess{
+					TerraformOutput: tfOut,
+					LockURL:         "lock-url",
+					ApplyCmd:        "apply-cmd",
+					RePlanCmd:       "replan-cmd",
+				},
+			},
+		},
+	}, models.PlanCommand, "log", false, repoOn(models.Github))
+	exp := `Ran Plan for dir: $.$ workspace: $default$
+
+$$$diff
+~ resource "aws_instance" "web" {
+        # (13 unchanged attributes hidden)
+      ~ instance_type = "t2.micro" -> "t2.small"
+    }
+$$$
+
+* :arrow_forward: To **apply** this plan, comment:
+    * $apply-cmd$
+* :put_litter_in_its_place: To **delete** this plan click [here](lock-url)
+* :repeat: To **plan** this project again, comment:
+    * $replan-cmd$
+
+---
+* :fast_forward: To **apply** all unapplied plans from this pull request, comment:
+    * $atlantis apply$
+* :put_litter_in_its_place: To delete all plans and locks for the PR, comment:
+    * $atlantis unlock$
+`
+	expWithBackticks := strings.Replace(exp, "$", "`", -1)
+	Equals(t, expWithBackticks, rendered)
+}
+
+func TestRenderProjectResults_AggregatedComments(t *testing.T) {
+	mr := events.MarkdownRenderer{AggregatedComments: true}
+	tfOut := strings.Repeat("line\n", 13) + "Plan: 1 to add, 0 to change, 0 to destroy."
+	res := events.CommandResult{
+		ProjectResults: []models.ProjectResult{
+			{
+				RepoRelDir:  ".",
+				Workspace:   "staging",
+				ProjectName: "app",
+				PlanSuccess: &models.PlanSuccess{
+					TerraformOutput: tfOut,
+					LockURL:         "staging-lock-url",
+					ApplyCmd:        "staging-apply-cmd",
+					RePlanCmd:       "staging-replan-cmd",
+				},
+			},
+			{
+				RepoRelDir: ".",
+				Workspace:  "production",
+				Error:      errors.New("error"),
+			},
+		},
+	}
+	rendered := mr.Render(res, models.PlanCommand, "log", false, repoOn(models.Github))
+	exp := `Ran Plan for 2 projects:
+
+| # | Project | Status | Summary |
+|---|---------|--------|---------|
+| 1 | project: $app$ dir: $.$ workspace: $staging$ | :white_check_mark: Planned | Plan: 1 to add, 0 to change, 0 to destroy. |
+| 2 | dir: $.$ workspace: $production$ | :x: Errored |  |
+
+<details><summary>1. project: <code>app</code> dir: <code>.</code> workspace: <code>staging</code> :white_check_mark: Planned</summary>
+
+$$$diff
+` + tfOut + `
+$$$
+
+* :arrow_forward: To **apply** this plan, comment:
+    * $staging-apply-cmd$
+* :put_litter_in_its_place: To **delete** this plan click [here](staging-lock-url)
+* :repeat: To **plan** this project again, comment:
+    * $staging-replan-cmd$
+</details>
+
+<details><summary>2. dir: <code>.</code> workspace: <code>production</code> :x: Errored</summary>
+
+**Plan Error**
+$$$
+error
+$$$
+</details>
+
+---
+* :fast_forward: To **apply** all unapplied plans from this pull request, comment:
+    * $atlantis apply$
+* :put_litter_in_its_place: To delete all plans and locks for the PR, comment:
+    * $atlantis unlock$
+`
+	Equals(t, strings.Replace(exp, "$", "`", -1), rendered)
+
+	rendered = mr.Render(events.CommandResult{
+		ProjectResults: []models.ProjectResult{
+			{
+				RepoRelDir:   ".",
+				Workspace:    "staging",
+				ApplySuccess: "Apply complete! Resources: 1 added, 0 changed, 0 destroyed.",
+			},
+			{
+				RepoRelDir: ".",
+				Workspace:  "production",
+				Failure:    "failure",
+			},
+		},
+	}, models.ApplyCommand, "log", false, repoOn(models.Github))
+	exp = `Ran Apply for 2 projects:
+
+| # | Project | Status | Summary |
+|---|---------|--------|---------|
+| 1 | dir: $.$ workspace: $staging$ | :white_check_mark: Applied | Apply complete! Resources: 1 added, 0 changed, 0 destroyed. |
+| 2 | dir: $.$ workspace: $production$ | :warning: Failed |  |
+
+<details><summary>1. dir: <code>.</code> workspace: <code>staging</code> :white_check_mark: Applied</summary>
+
+$$$diff
+Apply complete! Resources: 1 added, 0 changed, 0 destroyed.
+$$$
+</details>
+
+<details><summary>2. dir: <code>.</code> workspace: <code>production</code> :warning: Failed</summary>
+
+**Apply Failed**: failure
+</details>
+
+`
+	Equals(t, strings.Replace(exp, "$", "`", -1), rendered)
+
+	// Hosts that can't fold comments get the default comment.
+	exp = (&events.MarkdownRenderer{}).Render(res, models.PlanCommand, "log", false, repoOn(models.BitbucketCloud))
+	Equals(t, exp, mr.Render(res, models.PlanCommand, "log", false, repoOn(models.BitbucketCloud)))
+}
+
 // Test rendering when there was an error in one of the plans and we deleted
 // all the plans as a result.
 func TestRenderProjectResults_PlansDeleted(t *testing.T) {
@@ -1588,7 +2080,7 @@ This plan was not saved because one or more projects failed and automerge requir
 	for name, c := range cases {
 		t.Run(name, func(t *testing.T) {
 			mr := events.MarkdownRenderer{}
-			rendered := mr.Render(c.cr, models.PlanCommand, "log", false, models.Github)
+			rendered := mr.Render(c.cr, models.PlanCommand, "log", false, repoOn(models.Github))
 			expWithBackticks := strings.Replace(c.exp, "$", "`", -1)
 			Equals(t, expWithBackticks, rendered)
 		})
@@ -2056,7 +2548,7 @@ $$$
 			}
 			for _, verbose := range []bool{true, false} {
 				t.Run(c.Description, func(t *testing.T) {
-					s := r.Render(res, c.Command, "log", verbose, c.VCSHost)
+					s := r.Render(res, c.Command, "log", verbose, repoOn(c.VCSHost))
 					expWithBackticks := strings.Replace(c.Expected, "$", "`", -1)
 					if !verbose {
 						Equals(t, expWithBackticks, s)
@@ -2068,3 +2560,11 @@ $$$
 		})
 	}
 }
+
+// repoOn returns a repo hosted on host.
+func repoOn(host models.VCSHostType) models.Repo {
+	return models.Repo{
+		FullName: "owner/repo",
+		VCSHost:  models.VCSHost{Hostname: "github.com", Type: host},
+	}
+}
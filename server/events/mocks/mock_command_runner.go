@@ -4,6 +4,7 @@
 package mocks
 
 import (
+	context "context"
 	pegomock "github.com/petergtz/pegomock"
 	events "github.com/runatlantis/atlantis/server/events"
 	models "github.com/runatlantis/atlantis/server/events/models"
@@ -26,19 +27,19 @@ func NewMockCommandRunner(options ...pegomock.Option) *MockCommandRunner {
 func (mock *MockCommandRunner) SetFailHandler(fh pegomock.FailHandler) { mock.fail = fh }
 func (mock *MockCommandRunner) FailHandler() pegomock.FailHandler      { return mock.fail }
 
-func (mock *MockCommandRunner) RunCommentCommand(baseRepo models.Repo, maybeHeadRepo *models.Repo, maybePull *models.PullRequest, user models.User, pullNum int, cmd *events.CommentCommand) {
+func (mock *MockCommandRunner) RunCommentCommand(reqCtx context.Context, baseRepo models.Repo, maybeHeadRepo *models.Repo, maybePull *models.PullRequest, user models.User, pullNum int, cmd *events.CommentCommand) {
 	if mock == nil {
 		panic("mock must not be nil. Use myMock := NewMockCommandRunner().")
 	}
-	params := []pegomock.Param{baseRepo, maybeHeadRepo, maybePull, user, pullNum, cmd}
+	params := []pegomock.Param{reqCtx, baseRepo, maybeHeadRepo, maybePull, user, pullNum, cmd}
 	pegomock.GetGenericMockFrom(mock).Invoke("RunCommentCommand", params, []reflect.Type{})
 }
 
-func (mock *MockCommandRunner) RunAutoplanCommand(baseRepo models.Repo, headRepo models.Repo, pull models.PullRequest, user models.User) {
+func (mock *MockCommandRunner) RunAutoplanCommand(reqCtx context.Context, baseRepo models.Repo, headRepo models.Repo, pull models.PullRequest, user models.User) {
 	if mock == nil {
 		panic("mock must not be nil. Use myMock := NewMockCommandRunner().")
 	}
-	params := []pegomock.Param{baseRepo, headRepo, pull, user}
+	params := []pegomock.Param{reqCtx, baseRepo, headRepo, pull, user}
 	pegomock.GetGenericMockFrom(mock).Invoke("RunAutoplanCommand", params, []reflect.Type{})
 }
 
@@ -79,8 +80,8 @@ type VerifierMockCommandRunner struct {
 	timeout                time.Duration
 }
 
-func (verifier *VerifierMockCommandRunner) RunCommentCommand(baseRepo models.Repo, maybeHeadRepo *models.Repo, maybePull *models.PullRequest, user models.User, pullNum int, cmd *events.CommentCommand) *MockCommandRunner_RunCommentCommand_OngoingVerification {
-	params := []pegomock.Param{baseRepo, maybeHeadRepo, maybePull, user, pullNum, cmd}
+func (verifier *VerifierMockCommandRunner) RunCommentCommand(reqCtx context.Context, baseRepo models.Repo, maybeHeadRepo *models.Repo, maybePull *models.PullRequest, user models.User, pullNum int, cmd *events.CommentCommand) *MockCommandRunner_RunCommentCommand_OngoingVerification {
+	params := []pegomock.Param{reqCtx, baseRepo, maybeHeadRepo, maybePull, user, pullNum, cmd}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "RunCommentCommand", params, verifier.timeout)
 	return &MockCommandRunner_RunCommentCommand_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -90,44 +91,48 @@ type MockCommandRunner_RunCommentCommand_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *MockCommandRunner_RunCommentCommand_OngoingVerification) GetCapturedArguments() (models.Repo, *models.Repo, *models.PullRequest, models.User, int, *events.CommentCommand) {
-	baseRepo, maybeHeadRepo, maybePull, user, pullNum, cmd := c.GetAllCapturedArguments()
-	return baseRepo[len(baseRepo)-1], maybeHeadRepo[len(maybeHeadRepo)-1], maybePull[len(maybePull)-1], user[len(user)-1], pullNum[len(pullNum)-1], cmd[len(cmd)-1]
+func (c *MockCommandRunner_RunCommentCommand_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, *models.Repo, *models.PullRequest, models.User, int, *events.CommentCommand) {
+	reqCtx, baseRepo, maybeHeadRepo, maybePull, user, pullNum, cmd := c.GetAllCapturedArguments()
+	return reqCtx[len(reqCtx)-1], baseRepo[len(baseRepo)-1], maybeHeadRepo[len(maybeHeadRepo)-1], maybePull[len(maybePull)-1], user[len(user)-1], pullNum[len(pullNum)-1], cmd[len(cmd)-1]
 }
 
-func (c *MockCommandRunner_RunCommentCommand_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []*models.Repo, _param2 []*models.PullRequest, _param3 []models.User, _param4 []int, _param5 []*events.CommentCommand) {
+func (c *MockCommandRunner_RunCommentCommand_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []*models.Repo, _param3 []*models.PullRequest, _param4 []models.User, _param5 []int, _param6 []*events.CommentCommand) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
-		_param0 = make([]models.Repo, len(c.methodInvocations))
+		_param0 = make([]context.Context, len(c.methodInvocations))
 		for u, param := range params[0] {
-			_param0[u] = param.(models.Repo)
+			_param0[u] = param.(context.Context)
 		}
-		_param1 = make([]*models.Repo, len(c.methodInvocations))
+		_param1 = make([]models.Repo, len(c.methodInvocations))
 		for u, param := range params[1] {
-			_param1[u] = param.(*models.Repo)
+			_param1[u] = param.(models.Repo)
 		}
-		_param2 = make([]*models.PullRequest, len(c.methodInvocations))
+		_param2 = make([]*models.Repo, len(c.methodInvocations))
 		for u, param := range params[2] {
-			_param2[u] = param.(*models.PullRequest)
+			_param2[u] = param.(*models.Repo)
 		}
-		_param3 = make([]models.User, len(c.methodInvocations))
+		_param3 = make([]*models.PullRequest, len(c.methodInvocations))
 		for u, param := range params[3] {
-			_param3[u] = param.(models.User)
+			_param3[u] = param.(*models.PullRequest)
 		}
-		_param4 = make([]int, len(c.methodInvocations))
+		_param4 = make([]models.User, len(c.methodInvocations))
 		for u, param := range params[4] {
-			_param4[u] = param.(int)
+			_param4[u] = param.(models.User)
 		}
-		_param5 = make([]*events.CommentCommand, len(c.methodInvocations))
+		_param5 = make([]int, len(c.methodInvocations))
 		for u, param := range params[5] {
-			_param5[u] = param.(*events.CommentCommand)
+			_param5[u] = param.(int)
+		}
+		_param6 = make([]*events.CommentCommand, len(c.methodInvocations))
+		for u, param := range params[6] {
+			_param6[u] = param.(*events.CommentCommand)
 		}
 	}
 	return
 }
 
-func (verifier *VerifierMockCommandRunner) RunAutoplanCommand(baseRepo models.Repo, headRepo models.Repo, pull models.PullRequest, user models.User) *MockCommandRunner_RunAutoplanCommand_OngoingVerification {
-	params := []pegomock.Param{baseRepo, headRepo, pull, user}
+func (verifier *VerifierMockCommandRunner) RunAutoplanCommand(reqCtx context.Context, baseRepo models.Repo, headRepo models.Repo, pull models.PullRequest, user models.User) *MockCommandRunner_RunAutoplanCommand_OngoingVerification {
+	params := []pegomock.Param{reqCtx, baseRepo, headRepo, pull, user}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "RunAutoplanCommand", params, verifier.timeout)
 	return &MockCommandRunner_RunAutoplanCommand_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -137,29 +142,33 @@ type MockCommandRunner_RunAutoplanCommand_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *MockCommandRunner_RunAutoplanCommand_OngoingVerification) GetCapturedArguments() (models.Repo, models.Repo, models.PullRequest, models.User) {
-	baseRepo, headRepo, pull, user := c.GetAllCapturedArguments()
-	return baseRepo[len(baseRepo)-1], headRepo[len(headRepo)-1], pull[len(pull)-1], user[len(user)-1]
+func (c *MockCommandRunner_RunAutoplanCommand_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, models.Repo, models.PullRequest, models.User) {
+	reqCtx, baseRepo, headRepo, pull, user := c.GetAllCapturedArguments()
+	return reqCtx[len(reqCtx)-1], baseRepo[len(baseRepo)-1], headRepo[len(headRepo)-1], pull[len(pull)-1], user[len(user)-1]
 }
 
-func (c *MockCommandRunner_RunAutoplanCommand_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []models.Repo, _param2 []models.PullRequest, _param3 []models.User) {
+func (c *MockCommandRunner_RunAutoplanCommand_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []models.Repo, _param3 []models.PullRequest, _param4 []models.User) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
-		_param0 = make([]models.Repo, len(c.methodInvocations))
+		_param0 = make([]context.Context, len(c.methodInvocations))
 		for u, param := range params[0] {
-			_param0[u] = param.(models.Repo)
+			_param0[u] = param.(context.Context)
 		}
 		_param1 = make([]models.Repo, len(c.methodInvocations))
 		for u, param := range params[1] {
 			_param1[u] = param.(models.Repo)
 		}
-		_param2 = make([]models.PullRequest, len(c.methodInvocations))
+		_param2 = make([]models.Repo, len(c.methodInvocations))
 		for u, param := range params[2] {
-			_param2[u] = param.(models.PullRequest)
+			_param2[u] = param.(models.Repo)
 		}
-		_param3 = make([]models.User, len(c.methodInvocations))
+		_param3 = make([]models.PullRequest, len(c.methodInvocations))
 		for u, param := range params[3] {
-			_param3[u] = param.(models.User)
+			_param3[u] = param.(models.PullRequest)
+		}
+		_param4 = make([]models.User, len(c.methodInvocations))
+		for u, param := range params[4] {
+			_param4[u] = param.(models.User)
 		}
 	}
 	return
@@ -70,6 +70,36 @@ func (mock *MockCommitStatusUpdater) UpdateProject(ctx models.ProjectCommandCont
 	return ret0
 }
 
+func (mock *MockCommitStatusUpdater) UpdatePlanCount(repo models.Repo, pull models.PullRequest, status models.CommitStatus, numSuccess int, numTotal int, counts *models.PlanCounts) error {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockCommitStatusUpdater().")
+	}
+	params := []pegomock.Param{repo, pull, status, numSuccess, numTotal, counts}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("UpdatePlanCount", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(error)
+		}
+	}
+	return ret0
+}
+
+func (mock *MockCommitStatusUpdater) UpdatePlanProject(ctx models.ProjectCommandContext, status models.CommitStatus, url string, counts *models.PlanCounts) error {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockCommitStatusUpdater().")
+	}
+	params := []pegomock.Param{ctx, status, url, counts}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("UpdatePlanProject", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(error)
+		}
+	}
+	return ret0
+}
+
 func (mock *MockCommitStatusUpdater) VerifyWasCalledOnce() *VerifierMockCommitStatusUpdater {
 	return &VerifierMockCommitStatusUpdater{
 		mock:                   mock,
@@ -231,3 +261,89 @@ func (c *MockCommitStatusUpdater_UpdateProject_OngoingVerification) GetAllCaptur
 	}
 	return
 }
+
+func (verifier *VerifierMockCommitStatusUpdater) UpdatePlanCount(repo models.Repo, pull models.PullRequest, status models.CommitStatus, numSuccess int, numTotal int, counts *models.PlanCounts) *MockCommitStatusUpdater_UpdatePlanCount_OngoingVerification {
+	params := []pegomock.Param{repo, pull, status, numSuccess, numTotal, counts}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "UpdatePlanCount", params, verifier.timeout)
+	return &MockCommitStatusUpdater_UpdatePlanCount_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type MockCommitStatusUpdater_UpdatePlanCount_OngoingVerification struct {
+	mock              *MockCommitStatusUpdater
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *MockCommitStatusUpdater_UpdatePlanCount_OngoingVerification) GetCapturedArguments() (models.Repo, models.PullRequest, models.CommitStatus, int, int, *models.PlanCounts) {
+	repo, pull, status, numSuccess, numTotal, counts := c.GetAllCapturedArguments()
+	return repo[len(repo)-1], pull[len(pull)-1], status[len(status)-1], numSuccess[len(numSuccess)-1], numTotal[len(numTotal)-1], counts[len(counts)-1]
+}
+
+func (c *MockCommitStatusUpdater_UpdatePlanCount_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []models.PullRequest, _param2 []models.CommitStatus, _param3 []int, _param4 []int, _param5 []*models.PlanCounts) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]models.Repo, len(c.methodInvocations))
+		for u, param := range params[0] {
+			_param0[u] = param.(models.Repo)
+		}
+		_param1 = make([]models.PullRequest, len(c.methodInvocations))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.PullRequest)
+		}
+		_param2 = make([]models.CommitStatus, len(c.methodInvocations))
+		for u, param := range params[2] {
+			_param2[u] = param.(models.CommitStatus)
+		}
+		_param3 = make([]int, len(c.methodInvocations))
+		for u, param := range params[3] {
+			_param3[u] = param.(int)
+		}
+		_param4 = make([]int, len(c.methodInvocations))
+		for u, param := range params[4] {
+			_param4[u] = param.(int)
+		}
+		_param5 = make([]*models.PlanCounts, len(c.methodInvocations))
+		for u, param := range params[5] {
+			_param5[u] = param.(*models.PlanCounts)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierMockCommitStatusUpdater) UpdatePlanProject(ctx models.ProjectCommandContext, status models.CommitStatus, url string, counts *models.PlanCounts) *MockCommitStatusUpdater_UpdatePlanProject_OngoingVerification {
+	params := []pegomock.Param{ctx, status, url, counts}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "UpdatePlanProject", params, verifier.timeout)
+	return &MockCommitStatusUpdater_UpdatePlanProject_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type MockCommitStatusUpdater_UpdatePlanProject_OngoingVerification struct {
+	mock              *MockCommitStatusUpdater
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *MockCommitStatusUpdater_UpdatePlanProject_OngoingVerification) GetCapturedArguments() (models.ProjectCommandContext, models.CommitStatus, string, *models.PlanCounts) {
+	ctx, status, url, counts := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], status[len(status)-1], url[len(url)-1], counts[len(counts)-1]
+}
+
+func (c *MockCommitStatusUpdater_UpdatePlanProject_OngoingVerification) GetAllCapturedArguments() (_param0 []models.ProjectCommandContext, _param1 []models.CommitStatus, _param2 []string, _param3 []*models.PlanCounts) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]models.ProjectCommandContext, len(c.methodInvocations))
+		for u, param := range params[0] {
+			_param0[u] = param.(models.ProjectCommandContext)
+		}
+		_param1 = make([]models.CommitStatus, len(c.methodInvocations))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.CommitStatus)
+		}
+		_param2 = make([]string, len(c.methodInvocations))
+		for u, param := range params[2] {
+			_param2[u] = param.(string)
+		}
+		_param3 = make([]*models.PlanCounts, len(c.methodInvocations))
+		for u, param := range params[3] {
+			_param3[u] = param.(*models.PlanCounts)
+		}
+	}
+	return
+}
@@ -44,6 +44,25 @@ func (mock *MockDeleteLockCommand) DeleteLock(id string) (*models.ProjectLock, e
 	return ret0, ret1
 }
 
+func (mock *MockDeleteLockCommand) DeleteIdleLock(id string) (*models.ProjectLock, error) {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockDeleteLockCommand().")
+	}
+	params := []pegomock.Param{id}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("DeleteIdleLock", params, []reflect.Type{reflect.TypeOf((**models.ProjectLock)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 *models.ProjectLock
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(*models.ProjectLock)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
 func (mock *MockDeleteLockCommand) DeleteLocksByPull(repoFullName string, pullNum int) (int, error) {
 	if mock == nil {
 		panic("mock must not be nil. Use myMock := NewMockDeleteLockCommand().")
@@ -157,3 +176,30 @@ func (c *MockDeleteLockCommand_DeleteLocksByPull_OngoingVerification) GetAllCapt
 	}
 	return
 }
+
+func (verifier *VerifierMockDeleteLockCommand) DeleteIdleLock(id string) *MockDeleteLockCommand_DeleteIdleLock_OngoingVerification {
+	params := []pegomock.Param{id}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "DeleteIdleLock", params, verifier.timeout)
+	return &MockDeleteLockCommand_DeleteIdleLock_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type MockDeleteLockCommand_DeleteIdleLock_OngoingVerification struct {
+	mock              *MockDeleteLockCommand
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *MockDeleteLockCommand_DeleteIdleLock_OngoingVerification) GetCapturedArguments() string {
+	id := c.GetAllCapturedArguments()
+	return id[len(id)-1]
+}
+
+func (c *MockDeleteLockCommand_DeleteIdleLock_OngoingVerification) GetAllCapturedArguments() (_param0 []string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]string, len(c.methodInvocations))
+		for u, param := range params[0] {
+			_param0[u] = param.(string)
+		}
+	}
+	return
+}
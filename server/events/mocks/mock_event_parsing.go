@@ -456,6 +456,25 @@ func (mock *MockEventParsing) ParseAzureDevopsRepo(adRepo *azuredevops.GitReposi
 	return ret0, ret1
 }
 
+func (mock *MockEventParsing) ParseAPIRepo(vcsHostType models.VCSHostType, repoFullName string) (models.Repo, error) {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockEventParsing().")
+	}
+	params := []pegomock.Param{vcsHostType, repoFullName}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("ParseAPIRepo", params, []reflect.Type{reflect.TypeOf((*models.Repo)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 models.Repo
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(models.Repo)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
 func (mock *MockEventParsing) VerifyWasCalledOnce() *VerifierMockEventParsing {
 	return &VerifierMockEventParsing{
 		mock:                   mock,
@@ -928,3 +947,34 @@ func (c *MockEventParsing_ParseAzureDevopsRepo_OngoingVerification) GetAllCaptur
 	}
 	return
 }
+
+func (verifier *VerifierMockEventParsing) ParseAPIRepo(vcsHostType models.VCSHostType, repoFullName string) *MockEventParsing_ParseAPIRepo_OngoingVerification {
+	params := []pegomock.Param{vcsHostType, repoFullName}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "ParseAPIRepo", params, verifier.timeout)
+	return &MockEventParsing_ParseAPIRepo_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type MockEventParsing_ParseAPIRepo_OngoingVerification struct {
+	mock              *MockEventParsing
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *MockEventParsing_ParseAPIRepo_OngoingVerification) GetCapturedArguments() (models.VCSHostType, string) {
+	vcsHostType, repoFullName := c.GetAllCapturedArguments()
+	return vcsHostType[len(vcsHostType)-1], repoFullName[len(repoFullName)-1]
+}
+
+func (c *MockEventParsing_ParseAPIRepo_OngoingVerification) GetAllCapturedArguments() (_param0 []models.VCSHostType, _param1 []string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]models.VCSHostType, len(c.methodInvocations))
+		for u, param := range params[0] {
+			_param0[u] = param.(models.VCSHostType)
+		}
+		_param1 = make([]string, len(c.methodInvocations))
+		for u, param := range params[1] {
+			_param1[u] = param.(string)
+		}
+	}
+	return
+}
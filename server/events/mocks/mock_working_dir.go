@@ -5,6 +5,7 @@ package mocks
 
 import (
 	pegomock "github.com/petergtz/pegomock"
+	events "github.com/runatlantis/atlantis/server/events"
 	models "github.com/runatlantis/atlantis/server/events/models"
 	logging "github.com/runatlantis/atlantis/server/logging"
 	"reflect"
@@ -120,6 +121,146 @@ func (mock *MockWorkingDir) DeleteForWorkspace(r models.Repo, p models.PullReque
 	return ret0
 }
 
+func (mock *MockWorkingDir) CheckoutDirs(log logging.SimpleLogging, p models.PullRequest, workspace string, dirs []string) error {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockWorkingDir().")
+	}
+	params := []pegomock.Param{log, p, workspace, dirs}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("CheckoutDirs", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(error)
+		}
+	}
+	return ret0
+}
+
+func (mock *MockWorkingDir) GetPreviousClone(log logging.SimpleLogging, p models.PullRequest, workspace string) (*events.PreviousClone, error) {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockWorkingDir().")
+	}
+	params := []pegomock.Param{log, p, workspace}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("GetPreviousClone", params, []reflect.Type{reflect.TypeOf((**events.PreviousClone)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 *events.PreviousClone
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(*events.PreviousClone)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
+func (mock *MockWorkingDir) DeletePreviousClone(p models.PullRequest, workspace string) error {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockWorkingDir().")
+	}
+	params := []pegomock.Param{p, workspace}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("DeletePreviousClone", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(error)
+		}
+	}
+	return ret0
+}
+
+func (mock *MockWorkingDir) Revert(log logging.SimpleLogging, p models.PullRequest, workspace string, commit string, dirs []string, message string) (string, error) {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockWorkingDir().")
+	}
+	params := []pegomock.Param{log, p, workspace, commit, dirs, message}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("Revert", params, []reflect.Type{reflect.TypeOf((*string)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 string
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(string)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
+func (mock *MockWorkingDir) PushBranch(log logging.SimpleLogging, p models.PullRequest, workspace string, commit string, branch string) error {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockWorkingDir().")
+	}
+	params := []pegomock.Param{log, p, workspace, commit, branch}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("PushBranch", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(error)
+		}
+	}
+	return ret0
+}
+
+func (mock *MockWorkingDir) PushCommit(log logging.SimpleLogging, headRepo models.Repo, p models.PullRequest, workspace string, dirs []string, message string) (string, error) {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockWorkingDir().")
+	}
+	params := []pegomock.Param{log, headRepo, p, workspace, dirs, message}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("PushCommit", params, []reflect.Type{reflect.TypeOf((*string)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 string
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(string)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
+func (mock *MockWorkingDir) BaseAdvanced(log logging.SimpleLogging, headRepo models.Repo, p models.PullRequest, cloneDir string) (bool, error) {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockWorkingDir().")
+	}
+	params := []pegomock.Param{log, headRepo, p, cloneDir}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("BaseAdvanced", params, []reflect.Type{reflect.TypeOf((*bool)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 bool
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(bool)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
+func (mock *MockWorkingDir) RemoteBranchSHA(log logging.SimpleLogging, repo models.Repo, branch string) (string, error) {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockWorkingDir().")
+	}
+	params := []pegomock.Param{log, repo, branch}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("RemoteBranchSHA", params, []reflect.Type{reflect.TypeOf((*string)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 string
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(string)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
 func (mock *MockWorkingDir) VerifyWasCalledOnce() *VerifierMockWorkingDir {
 	return &VerifierMockWorkingDir{
 		mock:                   mock,
@@ -327,3 +468,319 @@ func (c *MockWorkingDir_DeleteForWorkspace_OngoingVerification) GetAllCapturedAr
 	}
 	return
 }
+
+func (verifier *VerifierMockWorkingDir) CheckoutDirs(log logging.SimpleLogging, p models.PullRequest, workspace string, dirs []string) *MockWorkingDir_CheckoutDirs_OngoingVerification {
+	params := []pegomock.Param{log, p, workspace, dirs}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "CheckoutDirs", params, verifier.timeout)
+	return &MockWorkingDir_CheckoutDirs_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type MockWorkingDir_CheckoutDirs_OngoingVerification struct {
+	mock              *MockWorkingDir
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *MockWorkingDir_CheckoutDirs_OngoingVerification) GetCapturedArguments() (logging.SimpleLogging, models.PullRequest, string, []string) {
+	log, p, workspace, dirs := c.GetAllCapturedArguments()
+	return log[len(log)-1], p[len(p)-1], workspace[len(workspace)-1], dirs[len(dirs)-1]
+}
+
+func (c *MockWorkingDir_CheckoutDirs_OngoingVerification) GetAllCapturedArguments() (_param0 []logging.SimpleLogging, _param1 []models.PullRequest, _param2 []string, _param3 [][]string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]logging.SimpleLogging, len(c.methodInvocations))
+		for u, param := range params[0] {
+			_param0[u] = param.(logging.SimpleLogging)
+		}
+		_param1 = make([]models.PullRequest, len(c.methodInvocations))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.PullRequest)
+		}
+		_param2 = make([]string, len(c.methodInvocations))
+		for u, param := range params[2] {
+			_param2[u] = param.(string)
+		}
+		_param3 = make([][]string, len(c.methodInvocations))
+		for u, param := range params[3] {
+			_param3[u] = param.([]string)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierMockWorkingDir) GetPreviousClone(log logging.SimpleLogging, p models.PullRequest, workspace string) *MockWorkingDir_GetPreviousClone_OngoingVerification {
+	params := []pegomock.Param{log, p, workspace}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "GetPreviousClone", params, verifier.timeout)
+	return &MockWorkingDir_GetPreviousClone_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type MockWorkingDir_GetPreviousClone_OngoingVerification struct {
+	mock              *MockWorkingDir
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *MockWorkingDir_GetPreviousClone_OngoingVerification) GetCapturedArguments() (logging.SimpleLogging, models.PullRequest, string) {
+	log, p, workspace := c.GetAllCapturedArguments()
+	return log[len(log)-1], p[len(p)-1], workspace[len(workspace)-1]
+}
+
+func (c *MockWorkingDir_GetPreviousClone_OngoingVerification) GetAllCapturedArguments() (_param0 []logging.SimpleLogging, _param1 []models.PullRequest, _param2 []string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]logging.SimpleLogging, len(c.methodInvocations))
+		for u, param := range params[0] {
+			_param0[u] = param.(logging.SimpleLogging)
+		}
+		_param1 = make([]models.PullRequest, len(c.methodInvocations))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.PullRequest)
+		}
+		_param2 = make([]string, len(c.methodInvocations))
+		for u, param := range params[2] {
+			_param2[u] = param.(string)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierMockWorkingDir) DeletePreviousClone(p models.PullRequest, workspace string) *MockWorkingDir_DeletePreviousClone_OngoingVerification {
+	params := []pegomock.Param{p, workspace}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "DeletePreviousClone", params, verifier.timeout)
+	return &MockWorkingDir_DeletePreviousClone_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type MockWorkingDir_DeletePreviousClone_OngoingVerification struct {
+	mock              *MockWorkingDir
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *MockWorkingDir_DeletePreviousClone_OngoingVerification) GetCapturedArguments() (models.PullRequest, string) {
+	p, workspace := c.GetAllCapturedArguments()
+	return p[len(p)-1], workspace[len(workspace)-1]
+}
+
+func (c *MockWorkingDir_DeletePreviousClone_OngoingVerification) GetAllCapturedArguments() (_param0 []models.PullRequest, _param1 []string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]models.PullRequest, len(c.methodInvocations))
+		for u, param := range params[0] {
+			_param0[u] = param.(models.PullRequest)
+		}
+		_param1 = make([]string, len(c.methodInvocations))
+		for u, param := range params[1] {
+			_param1[u] = param.(string)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierMockWorkingDir) PushCommit(log logging.SimpleLogging, headRepo models.Repo, p models.PullRequest, workspace string, dirs []string, message string) *MockWorkingDir_PushCommit_OngoingVerification {
+	params := []pegomock.Param{log, headRepo, p, workspace, dirs, message}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "PushCommit", params, verifier.timeout)
+	return &MockWorkingDir_PushCommit_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type MockWorkingDir_PushCommit_OngoingVerification struct {
+	mock              *MockWorkingDir
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *MockWorkingDir_PushCommit_OngoingVerification) GetCapturedArguments() (logging.SimpleLogging, models.Repo, models.PullRequest, string, []string, string) {
+	log, headRepo, p, workspace, dirs, message := c.GetAllCapturedArguments()
+	return log[len(log)-1], headRepo[len(headRepo)-1], p[len(p)-1], workspace[len(workspace)-1], dirs[len(dirs)-1], message[len(message)-1]
+}
+
+func (c *MockWorkingDir_PushCommit_OngoingVerification) GetAllCapturedArguments() (_param0 []logging.SimpleLogging, _param1 []models.Repo, _param2 []models.PullRequest, _param3 []string, _param4 [][]string, _param5 []string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]logging.SimpleLogging, len(c.methodInvocations))
+		for u, param := range params[0] {
+			_param0[u] = param.(logging.SimpleLogging)
+		}
+		_param1 = make([]models.Repo, len(c.methodInvocations))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.Repo)
+		}
+		_param2 = make([]models.PullRequest, len(c.methodInvocations))
+		for u, param := range params[2] {
+			_param2[u] = param.(models.PullRequest)
+		}
+		_param3 = make([]string, len(c.methodInvocations))
+		for u, param := range params[3] {
+			_param3[u] = param.(string)
+		}
+		_param4 = make([][]string, len(c.methodInvocations))
+		for u, param := range params[4] {
+			_param4[u] = param.([]string)
+		}
+		_param5 = make([]string, len(c.methodInvocations))
+		for u, param := range params[5] {
+			_param5[u] = param.(string)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierMockWorkingDir) BaseAdvanced(log logging.SimpleLogging, headRepo models.Repo, p models.PullRequest, cloneDir string) *MockWorkingDir_BaseAdvanced_OngoingVerification {
+	params := []pegomock.Param{log, headRepo, p, cloneDir}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "BaseAdvanced", params, verifier.timeout)
+	return &MockWorkingDir_BaseAdvanced_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type MockWorkingDir_BaseAdvanced_OngoingVerification struct {
+	mock              *MockWorkingDir
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *MockWorkingDir_BaseAdvanced_OngoingVerification) GetCapturedArguments() (logging.SimpleLogging, models.Repo, models.PullRequest, string) {
+	log, headRepo, p, cloneDir := c.GetAllCapturedArguments()
+	return log[len(log)-1], headRepo[len(headRepo)-1], p[len(p)-1], cloneDir[len(cloneDir)-1]
+}
+
+func (c *MockWorkingDir_BaseAdvanced_OngoingVerification) GetAllCapturedArguments() (_param0 []logging.SimpleLogging, _param1 []models.Repo, _param2 []models.PullRequest, _param3 []string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]logging.SimpleLogging, len(c.methodInvocations))
+		for u, param := range params[0] {
+			_param0[u] = param.(logging.SimpleLogging)
+		}
+		_param1 = make([]models.Repo, len(c.methodInvocations))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.Repo)
+		}
+		_param2 = make([]models.PullRequest, len(c.methodInvocations))
+		for u, param := range params[2] {
+			_param2[u] = param.(models.PullRequest)
+		}
+		_param3 = make([]string, len(c.methodInvocations))
+		for u, param := range params[3] {
+			_param3[u] = param.(string)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierMockWorkingDir) Revert(log logging.SimpleLogging, p models.PullRequest, workspace string, commit string, dirs []string, message string) *MockWorkingDir_Revert_OngoingVerification {
+	params := []pegomock.Param{log, p, workspace, commit, dirs, message}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "Revert", params, verifier.timeout)
+	return &MockWorkingDir_Revert_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type MockWorkingDir_Revert_OngoingVerification struct {
+	mock              *MockWorkingDir
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *MockWorkingDir_Revert_OngoingVerification) GetCapturedArguments() (logging.SimpleLogging, models.PullRequest, string, string, []string, string) {
+	log, p, workspace, commit, dirs, message := c.GetAllCapturedArguments()
+	return log[len(log)-1], p[len(p)-1], workspace[len(workspace)-1], commit[len(commit)-1], dirs[len(dirs)-1], message[len(message)-1]
+}
+
+func (c *MockWorkingDir_Revert_OngoingVerification) GetAllCapturedArguments() (_param0 []logging.SimpleLogging, _param1 []models.PullRequest, _param2 []string, _param3 []string, _param4 [][]string, _param5 []string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]logging.SimpleLogging, len(c.methodInvocations))
+		for u, param := range params[0] {
+			_param0[u] = param.(logging.SimpleLogging)
+		}
+		_param1 = make([]models.PullRequest, len(c.methodInvocations))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.PullRequest)
+		}
+		_param2 = make([]string, len(c.methodInvocations))
+		for u, param := range params[2] {
+			_param2[u] = param.(string)
+		}
+		_param3 = make([]string, len(c.methodInvocations))
+		for u, param := range params[3] {
+			_param3[u] = param.(string)
+		}
+		_param4 = make([][]string, len(c.methodInvocations))
+		for u, param := range params[4] {
+			_param4[u] = param.([]string)
+		}
+		_param5 = make([]string, len(c.methodInvocations))
+		for u, param := range params[5] {
+			_param5[u] = param.(string)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierMockWorkingDir) PushBranch(log logging.SimpleLogging, p models.PullRequest, workspace string, commit string, branch string) *MockWorkingDir_PushBranch_OngoingVerification {
+	params := []pegomock.Param{log, p, workspace, commit, branch}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "PushBranch", params, verifier.timeout)
+	return &MockWorkingDir_PushBranch_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type MockWorkingDir_PushBranch_OngoingVerification struct {
+	mock              *MockWorkingDir
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *MockWorkingDir_PushBranch_OngoingVerification) GetCapturedArguments() (logging.SimpleLogging, models.PullRequest, string, string, string) {
+	log, p, workspace, commit, branch := c.GetAllCapturedArguments()
+	return log[len(log)-1], p[len(p)-1], workspace[len(workspace)-1], commit[len(commit)-1], branch[len(branch)-1]
+}
+
+func (c *MockWorkingDir_PushBranch_OngoingVerification) GetAllCapturedArguments() (_param0 []logging.SimpleLogging, _param1 []models.PullRequest, _param2 []string, _param3 []string, _param4 []string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]logging.SimpleLogging, len(c.methodInvocations))
+		for u, param := range params[0] {
+			_param0[u] = param.(logging.SimpleLogging)
+		}
+		_param1 = make([]models.PullRequest, len(c.methodInvocations))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.PullRequest)
+		}
+		_param2 = make([]string, len(c.methodInvocations))
+		for u, param := range params[2] {
+			_param2[u] = param.(string)
+		}
+		_param3 = make([]string, len(c.methodInvocations))
+		for u, param := range params[3] {
+			_param3[u] = param.(string)
+		}
+		_param4 = make([]string, len(c.methodInvocations))
+		for u, param := range params[4] {
+			_param4[u] = param.(string)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierMockWorkingDir) RemoteBranchSHA(log logging.SimpleLogging, repo models.Repo, branch string) *MockWorkingDir_RemoteBranchSHA_OngoingVerification {
+	params := []pegomock.Param{log, repo, branch}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "RemoteBranchSHA", params, verifier.timeout)
+	return &MockWorkingDir_RemoteBranchSHA_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type MockWorkingDir_RemoteBranchSHA_OngoingVerification struct {
+	mock              *MockWorkingDir
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *MockWorkingDir_RemoteBranchSHA_OngoingVerification) GetCapturedArguments() (logging.SimpleLogging, models.Repo, string) {
+	log, repo, branch := c.GetAllCapturedArguments()
+	return log[len(log)-1], repo[len(repo)-1], branch[len(branch)-1]
+}
+
+func (c *MockWorkingDir_RemoteBranchSHA_OngoingVerification) GetAllCapturedArguments() (_param0 []logging.SimpleLogging, _param1 []models.Repo, _param2 []string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]logging.SimpleLogging, len(c.methodInvocations))
+		for u, param := range params[0] {
+			_param0[u] = param.(logging.SimpleLogging)
+		}
+		_param1 = make([]models.Repo, len(c.methodInvocations))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.Repo)
+		}
+		_param2 = make([]string, len(c.methodInvocations))
+		for u, param := range params[2] {
+			_param2[u] = param.(string)
+		}
+	}
+	return
+}
@@ -21,13 +21,16 @@ import (
 	"net/url"
 	paths "path"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/hashicorp/go-version"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/tracing"
 
 	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/progress"
 	"github.com/runatlantis/atlantis/server/events/yaml/valid"
 )
 
@@ -163,10 +166,16 @@ type PullRequest struct {
 	BaseBranch string
 	// Author is the username of the pull request author.
 	Author string
+	// Title is the title of the pull request.
+	Title string
 	// State will be one of Open or Closed.
 	// Gitlab supports an additional "merged" state but Github doesn't so we map
 	// merged to Closed.
 	State PullRequestState
+	// MergeCommit is the sha of the commit that merged the pull request into
+	// the base branch. It's empty unless the pull request was merged and the
+	// VCS host reports it (GitHub and GitLab).
+	MergeCommit string
 	// BaseRepo is the repository that the pull request will be merged into.
 	BaseRepo Repo
 }
@@ -176,6 +185,10 @@ type PullRequestOptions struct {
 	// When DeleteSourceBranchOnMerge flag is set to true VCS deletes the source branch after the PR is merged
 	// Applied by GitLab & AzureDevops
 	DeleteSourceBranchOnMerge bool
+	// CommitMessage is the message of the merge commit. If empty, the VCS
+	// host's default is used.
+	// Applied by GitHub, GitLab, AzureDevops & Bitbucket Cloud
+	CommitMessage string
 }
 
 type PullRequestState int
@@ -214,6 +227,55 @@ type User struct {
 	Username string
 }
 
+// Approval is an approval of a pull request.
+type Approval struct {
+	// Username is the username of the approver.
+	Username string
+	// CommitSHA is the commit that was approved. It is empty if the VCS host
+	// doesn't track which commit was approved.
+	CommitSHA string
+	// Time is when the pull request was approved. It is the zero time if the
+	// VCS host doesn't track when approvals were given.
+	Time time.Time
+}
+
+// Pipeline is the latest CI pipeline of a pull request.
+type Pipeline struct {
+	// ID is the pipeline's ID. It is 0 if the pull request has no pipeline.
+	ID int
+	// SHA is the commit that the pipeline ran for.
+	SHA string
+	// Status is the pipeline's status, ex. success. It includes the commit
+	// statuses set by Atlantis.
+	Status string
+	// Jobs are the pipeline's CI jobs. Retried jobs only appear with the
+	// status of their latest run.
+	Jobs []PipelineJob
+}
+
+// PipelineJob is a CI job of a pipeline.
+type PipelineJob struct {
+	Name   string
+	Status string
+	// AllowFailure is true if the job failing doesn't fail the pipeline.
+	AllowFailure bool
+}
+
+// Issue is an issue of a repo.
+type Issue struct {
+	// Number is the issue's number. It's set by the VCS host.
+	Number int
+	// URL is the issue's URL. It's set by the VCS host.
+	URL   string
+	Title string
+	Body  string
+	// Labels are added to the issue when it's created.
+	Labels []string
+	// Assignees are the usernames of the users the issue is assigned to when
+	// it's created.
+	Assignees []string
+}
+
 // LockMetadata contains additional data provided to the lock
 type LockMetadata struct {
 	UnixTime int64
@@ -340,6 +402,25 @@ type ProjectCommandContext struct {
 	// ApplyRequirements is the list of requirements that must be satisfied
 	// before we will run the apply stage.
 	ApplyRequirements []string
+	// Approvals configures the approved_count apply requirement.
+	Approvals valid.Approvals
+	// Pipeline configures the pipeline_succeeded apply requirement.
+	Pipeline valid.Pipeline
+	// Denylist is the providers, resource types and provisioners that this
+	// project's plans can't contain.
+	Denylist valid.Denylist
+	// VerifyLockfile is true if init must not change the project's committed
+	// dependency lock file.
+	VerifyLockfile bool
+	// CloudCredentials configures the cloud credentials provided to this
+	// project's workflow.
+	CloudCredentials valid.CloudCredentials
+	// DependsOn are the projects whose outputs are provided to this project's
+	// workflow.
+	DependsOn []valid.ProjectDependency
+	// Lint configures running tflint before this project is planned. If nil,
+	// it isn't linted.
+	Lint *valid.Lint
 	// AutomergeEnabled is true if automerge is enabled for the repo that this
 	// project is in.
 	AutomergeEnabled bool
@@ -366,6 +447,12 @@ type ProjectCommandContext struct {
 	Log logging.SimpleLogging
 	// PullMergeable is true if the pull request for this project is able to be merged.
 	PullMergeable bool
+	// AllowDestroy is true if applying plans that destroy or replace
+	// resources was confirmed with --allow-destroy.
+	AllowDestroy bool
+	// UpgradeProviders is true if init must upgrade providers, ignoring the
+	// versions in the dependency lock file.
+	UpgradeProviders bool
 	// CurrentProjectPlanStatus is the status of the current project prior to this command.
 	ProjectPlanStatus ProjectPlanStatus
 	// Pull is the pull request we're responding to.
@@ -400,6 +487,26 @@ type ProjectCommandContext struct {
 	PolicySets valid.PolicySets
 	// DeleteSourceBranchOnMerge will attempt to allow a branch to be deleted when merged (AzureDevOps & GitLab Support Only)
 	DeleteSourceBranchOnMerge bool
+	// Span is the tracing span of the command. It's nil if tracing is
+	// disabled.
+	Span *tracing.Span
+	// CorrelationID identifies the logs of the command and the webhook that
+	// triggered it.
+	CorrelationID string
+	// ContainerImage is the image of the containers custom run steps are run
+	// in. If empty, the server's default is used.
+	ContainerImage string
+	// UnplannedProjects describe the projects modified in the pull request
+	// that don't have a successful plan at its head commit. It's only set for
+	// applies of projects with the all_plans_succeeded apply requirement.
+	UnplannedProjects []string
+	// KeptPlan is the status of the project at the pull request's previous
+	// head commit if its plan was kept because the new commits didn't modify
+	// the project. If it's set, the project isn't planned again.
+	KeptPlan *ProjectStatus
+	// Progress is the progress comment of the command, which shows the
+	// stage the project is at. It's nil if progress isn't shown.
+	Progress *progress.Comment
 }
 
 // GetShowResultFileName returns the filename (not the path) to store the tf show result
@@ -437,6 +544,14 @@ type ProjectResult struct {
 	PolicyCheckSuccess *PolicyCheckSuccess
 	ApplySuccess       string
 	ProjectName        string
+	// OutputURL is where the command's full output can be viewed. It's empty
+	// if output isn't streamed.
+	OutputURL string
+	// User is the username of who ran the command.
+	User string
+	// StartedAt is when the command started and Duration is how long it ran.
+	StartedAt time.Time
+	Duration  time.Duration
 }
 
 // CommitStatus returns the vcs commit status of this project result.
@@ -499,6 +614,70 @@ type PlanSuccess struct {
 	// branch we're merging into has been updated since we cloned and merged
 	// it.
 	HasDiverged bool
+	// PlanJSONURL is the full URL to the plan rendered by terraform show
+	// -json. It's empty if plans aren't rendered as JSON.
+	PlanJSONURL string
+	// RiskyChanges are the resources the plan replaces and destroys, which
+	// are highlighted as warnings.
+	RiskyChanges []ResourceChanges
+	// RequiresAllowDestroy is true if the plan has RiskyChanges and the
+	// destroy_confirmed apply requirement is set so it must be applied with
+	// --allow-destroy.
+	RequiresAllowDestroy bool
+	// UpgradeProviders is true if the plan was run with --upgrade.
+	UpgradeProviders bool
+	// ProviderUpgrades are the providers whose versions in the dependency
+	// lock file changed when they were upgraded.
+	ProviderUpgrades []ProviderUpgrade
+	// LintFindings are the issues tflint found in the project, which are
+	// shown as warnings.
+	LintFindings LintFindings
+}
+
+// ProviderUpgrade is a provider whose version changed when upgrading
+// providers.
+type ProviderUpgrade struct {
+	// Provider is the provider's source address, ex.
+	// "registry.terraform.io/hashicorp/aws".
+	Provider string
+	// From is the version before upgrading. It's empty if the provider was
+	// added.
+	From string
+	// To is the version after upgrading. It's empty if the provider was
+	// removed.
+	To string
+}
+
+// LintIssue is an issue that tflint found.
+type LintIssue struct {
+	// Rule is the name of the rule, ex. "terraform_unused_declarations".
+	Rule string
+	// Severity is the rule's severity, ex. "error", "warning" or "info".
+	Severity string
+	Message  string
+	// Filename is the file with the issue relative to the project's
+	// directory.
+	Filename string
+	Line     int
+}
+
+// LintFindings are the issues that tflint found in a project, ordered by
+// severity from most to least severe.
+type LintFindings []LintIssue
+
+// Markdown renders the issues as a list for each severity.
+func (f LintFindings) Markdown() string {
+	var b strings.Builder
+	for i, issue := range f {
+		if i == 0 || issue.Severity != f[i-1].Severity {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "**%s**\n\n", strings.Title(issue.Severity))
+		}
+		fmt.Fprintf(&b, "* `%s:%d` %s (`%s`)\n", issue.Filename, issue.Line, issue.Message, issue.Rule)
+	}
+	return b.String()
 }
 
 // Summary extracts one line summary of plan changes from TerraformOutput.
@@ -511,6 +690,117 @@ func (p *PlanSuccess) Summary() string {
 	return r.FindString(p.TerraformOutput)
 }
 
+// ResourceAction is what a plan does to a resource.
+type ResourceAction string
+
+const (
+	CreateResourceAction  ResourceAction = "create"
+	UpdateResourceAction  ResourceAction = "update"
+	ReplaceResourceAction ResourceAction = "replace"
+	DeleteResourceAction  ResourceAction = "delete"
+)
+
+// Title returns the action as it's shown in comments.
+func (a ResourceAction) Title() string {
+	switch a {
+	case CreateResourceAction:
+		return "Add"
+	case UpdateResourceAction:
+		return "Change"
+	case ReplaceResourceAction:
+		return "Replace"
+	case DeleteResourceAction:
+		return "Destroy"
+	}
+	return string(a)
+}
+
+// ResourceChanges are the addresses of the resources a plan does Action to.
+type ResourceChanges struct {
+	Action    ResourceAction
+	Addresses []string
+}
+
+// resourceChangeRegex matches the comment Terraform >= 0.12 writes above
+// each resource in a plan. Its groups are the address and what happens to it.
+var resourceChangeRegex = regexp.MustCompile(`(?m)^\s*# (.+?) (will be created|will be updated in-place|will be destroyed|must be replaced|will be replaced, as requested)\s*$`)
+
+var resourceChangeActions = map[string]ResourceAction{
+	"will be created":                CreateResourceAction,
+	"will be updated in-place":       UpdateResourceAction,
+	"will be destroyed":              DeleteResourceAction,
+	"must be replaced":               ReplaceResourceAction,
+	"will be replaced, as requested": ReplaceResourceAction,
+}
+
+// Changes extracts the resources the plan changes from TerraformOutput,
+// grouped by action in the order create, update, replace and delete.
+// Actions without resources are left out, so it's empty if there are no
+// changes or the output is from Terraform < 0.12.
+func (p *PlanSuccess) Changes() []ResourceChanges {
+	byAction := make(map[ResourceAction][]string)
+	for _, match := range resourceChangeRegex.FindAllStringSubmatch(p.TerraformOutput, -1) {
+		action := resourceChangeActions[match[2]]
+		byAction[action] = append(byAction[action], match[1])
+	}
+	var changes []ResourceChanges
+	for _, action := range []ResourceAction{CreateResourceAction, UpdateResourceAction, ReplaceResourceAction, DeleteResourceAction} {
+		if addrs := byAction[action]; len(addrs) > 0 {
+			changes = append(changes, ResourceChanges{Action: action, Addresses: addrs})
+		}
+	}
+	return changes
+}
+
+// planCountsRegex matches the summary of a plan's changes. Its groups are the
+// numbers of resources to add, change and destroy.
+var planCountsRegex = regexp.MustCompile(`Plan: (\d+) to add, (\d+) to change, (\d+) to destroy\.`)
+
+// noChangesRegex matches the output of plans without changes of every
+// Terraform version.
+var noChangesRegex = regexp.MustCompile(`No changes\. (Infrastructure is up-to-date|Your infrastructure matches the configuration)\.`)
+
+// Counts returns how many resources the plan adds, changes and destroys, or
+// nil if the output doesn't say.
+func (p *PlanSuccess) Counts() *PlanCounts {
+	return ParsePlanCounts(p.TerraformOutput)
+}
+
+// ParsePlanCounts returns how many resources the plan with output adds,
+// changes and destroys, or nil if the output doesn't say.
+func ParsePlanCounts(output string) *PlanCounts {
+	if match := planCountsRegex.FindStringSubmatch(output); match != nil {
+		add, _ := strconv.Atoi(match[1])
+		change, _ := strconv.Atoi(match[2])
+		destroy, _ := strconv.Atoi(match[3])
+		return &PlanCounts{Add: add, Change: change, Destroy: destroy}
+	}
+	if noChangesRegex.MatchString(output) {
+		return &PlanCounts{}
+	}
+	return nil
+}
+
+// PlanCounts are how many resources a plan adds, changes and destroys.
+type PlanCounts struct {
+	Add     int
+	Change  int
+	Destroy int
+}
+
+// Plus returns the sum of c and o.
+func (c PlanCounts) Plus(o PlanCounts) PlanCounts {
+	return PlanCounts{Add: c.Add + o.Add, Change: c.Change + o.Change, Destroy: c.Destroy + o.Destroy}
+}
+
+// String returns the counts like "+3 ~1 -0", or "no changes".
+func (c PlanCounts) String() string {
+	if c == (PlanCounts{}) {
+		return "no changes"
+	}
+	return fmt.Sprintf("+%d ~%d -%d", c.Add, c.Change, c.Destroy)
+}
+
 // PolicyCheckSuccess is the result of a successful policy check run.
 type PolicyCheckSuccess struct {
 	// PolicyCheckOutput is the output from policy check binary(conftest|opa)
@@ -553,6 +843,18 @@ type ProjectStatus struct {
 	ProjectName string
 	// Status is the status of where this project is at in the planning cycle.
 	Status ProjectPlanStatus
+	// User is the username of who ran the last command for this project.
+	User string
+	// StartedAt is when the last command started and Duration is how long it
+	// ran.
+	StartedAt time.Time
+	Duration  time.Duration
+	// OutputURL is where the last command's full output can be viewed.
+	OutputURL string
+	// PlanCounts are how many resources the project's plan changes. It's
+	// nil if the last command wasn't a successful plan or its output didn't
+	// say.
+	PlanCounts *PlanCounts
 }
 
 // ProjectPlanStatus is the status of where this project is at in the planning
@@ -621,6 +923,19 @@ const (
 	ApprovePoliciesCommand
 	// AutoplanCommand is a command to run terrafor plan on PR open/update if autoplan is enabled
 	AutoplanCommand
+	// LockCommand is a command to lock projects before planning them.
+	LockCommand
+	// RevertCommand is a command to plan the revert of a merged pull request.
+	RevertCommand
+	// WorkspaceCommand is a command to create, delete or list the Terraform
+	// workspaces of projects.
+	WorkspaceCommand
+	// FmtCommand is a command to check that the Terraform files of projects
+	// are formatted and optionally format them.
+	FmtCommand
+	// ValidateCommand is a command to validate the configuration of projects
+	// with terraform validate.
+	ValidateCommand
 	// Adding more? Don't forget to update String() below
 )
 
@@ -643,6 +958,16 @@ func (c CommandName) String() string {
 		return "policy_check"
 	case ApprovePoliciesCommand:
 		return "approve_policies"
+	case LockCommand:
+		return "lock"
+	case RevertCommand:
+		return "revert"
+	case WorkspaceCommand:
+		return "workspace"
+	case FmtCommand:
+		return "fmt"
+	case ValidateCommand:
+		return "validate"
 	}
 	return ""
 }
@@ -536,6 +536,73 @@ func TestPlanSuccess_Summary(t *testing.T) {
 	}
 }
 
+func TestPlanSuccess_Changes(t *testing.T) {
+	p := models.PlanSuccess{
+		TerraformOutput: `Terraform will perform the following actions:
+
+  # aws_instance.a will be created
+  + resource "aws_instance" "a" {}
+
+  # aws_instance.b will be updated in-place
+  ~ resource "aws_instance" "b" {}
+
+  # aws_instance.c must be replaced
+-/+ resource "aws_instance" "c" {}
+
+  # aws_instance.d will be replaced, as requested
+-/+ resource "aws_instance" "d" {}
+
+  # module.m.aws_instance.e["a b"] will be destroyed
+  - resource "aws_instance" "e" {}
+
+  # data.aws_ami.f will be read during apply
+ <= data "aws_ami" "f" {}
+
+Plan: 3 to add, 1 to change, 3 to destroy.`,
+	}
+	Equals(t, []models.ResourceChanges{
+		{Action: models.CreateResourceAction, Addresses: []string{"aws_instance.a"}},
+		{Action: models.UpdateResourceAction, Addresses: []string{"aws_instance.b"}},
+		{Action: models.ReplaceResourceAction, Addresses: []string{"aws_instance.c", "aws_instance.d"}},
+		{Action: models.DeleteResourceAction, Addresses: []string{`module.m.aws_instance.e["a b"]`}},
+	}, p.Changes())
+
+	p = models.PlanSuccess{TerraformOutput: "No changes. Infrastructure is up-to-date."}
+	Equals(t, 0, len(p.Changes()))
+}
+
+func TestPlanSuccess_Counts(t *testing.T) {
+	cases := map[string]struct {
+		output    string
+		expCounts *models.PlanCounts
+	}{
+		"changes": {
+			output:    "Terraform will perform the following actions:\n\nPlan: 3 to add, 1 to change, 0 to destroy.",
+			expCounts: &models.PlanCounts{Add: 3, Change: 1},
+		},
+		"no changes": {
+			output:    "No changes. Infrastructure is up-to-date.",
+			expCounts: &models.PlanCounts{},
+		},
+		"no changes since 0.15.4": {
+			output:    "No changes. Your infrastructure matches the configuration.",
+			expCounts: &models.PlanCounts{},
+		},
+		"no summary": {
+			output: "Error: Unsupported argument",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := models.PlanSuccess{TerraformOutput: c.output}
+			Equals(t, c.expCounts, p.Counts())
+		})
+	}
+	Equals(t, "+3 ~1 -0", models.PlanCounts{Add: 3, Change: 1}.String())
+	Equals(t, "no changes", models.PlanCounts{}.String())
+	Equals(t, models.PlanCounts{Add: 3, Change: 1, Destroy: 2}, models.PlanCounts{Add: 1, Change: 1}.Plus(models.PlanCounts{Add: 2, Destroy: 2}))
+}
+
 func TestPullStatus_StatusCount(t *testing.T) {
 	ps := models.PullStatus{
 		Projects: []models.ProjectStatus{
@@ -0,0 +1,126 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/db"
+	"github.com/runatlantis/atlantis/server/events/locking"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/metrics"
+)
+
+// Names of the counters of OrphanCollector.Cleaned.
+const (
+	OrphanedPullsCounter       = "pulls"
+	OrphanedLocksCounter       = "locks"
+	OrphanedWorkingDirsCounter = "working_dirs"
+)
+
+// OrphanCollector cleans up after pull requests that were closed or merged
+// without Atlantis receiving their webhook, ex. because it was down. Their
+// locks would block other pull requests forever and their working dirs would
+// never be deleted. It checks every pull request that holds a lock or has a
+// status, which it has for as long as it has a working dir.
+type OrphanCollector struct {
+	Locker      locking.Locker
+	DB          db.Database
+	VCSClient   vcs.Client
+	WorkingDir  WorkingDir
+	PullCleaner PullCleaner
+	Logger      logging.SimpleLogging
+	// Interval is how often pull requests are checked.
+	Interval time.Duration
+	// Cleaned counts the pull requests that were cleaned up, and their locks
+	// and working dirs.
+	Cleaned *metrics.Counters
+
+	// mutex ensures pull requests are only checked by one goroutine at a time.
+	mutex sync.Mutex
+}
+
+// Run checks the pull requests every Interval until ctx is done.
+func (o *OrphanCollector) Run(ctx context.Context) {
+	ticker := time.NewTicker(o.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			o.Collect()
+		}
+	}
+}
+
+// Collect cleans up after each pull request that holds a lock or has a
+// status but was closed. Errors are logged.
+func (o *OrphanCollector) Collect() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	pulls := make(map[string]models.PullRequest)
+	lockCounts := make(map[string]int)
+	locks, err := o.Locker.List()
+	if err != nil {
+		o.Logger.Err("failed listing locks to check for closed pull requests: %s", err)
+		return
+	}
+	for _, lock := range locks {
+		// NOTE: Because BaseRepo was added to the PullRequest model later,
+		// previous installations of Atlantis will have locks in their DB
+		// that do not have this field on PullRequest. We can't query their
+		// pull requests.
+		if lock.Pull.BaseRepo == (models.Repo{}) {
+			continue
+		}
+		key := orphanPullKey(lock.Pull)
+		pulls[key] = lock.Pull
+		lockCounts[key]++
+	}
+	statuses, err := o.DB.GetPullStatuses()
+	if err != nil {
+		o.Logger.Err("failed listing pull statuses to check for closed pull requests: %s", err)
+		return
+	}
+	for _, status := range statuses {
+		if status.Pull.BaseRepo == (models.Repo{}) {
+			continue
+		}
+		if _, ok := pulls[orphanPullKey(status.Pull)]; !ok {
+			pulls[orphanPullKey(status.Pull)] = status.Pull
+		}
+	}
+
+	for key, pull := range pulls {
+		closed, err := o.VCSClient.PullIsClosed(pull.BaseRepo, pull)
+		if err != nil {
+			o.Logger.Warn("failed checking if pull request %s is closed: %s", key, err)
+			continue
+		}
+		if !closed {
+			continue
+		}
+		// The working dir is checked before it's deleted so it's only
+		// counted if it existed.
+		_, dirErr := o.WorkingDir.GetPullDir(pull.BaseRepo, pull)
+		if err := o.PullCleaner.CleanUpPull(pull.BaseRepo, pull); err != nil {
+			o.Logger.Err("failed cleaning up closed pull request %s: %s", key, err)
+			continue
+		}
+		o.Logger.Info("cleaned up pull request %s, which was closed without Atlantis being notified", key)
+		o.Cleaned.Inc(OrphanedPullsCounter)
+		o.Cleaned.Add(OrphanedLocksCounter, int64(lockCounts[key]))
+		if dirErr == nil {
+			o.Cleaned.Inc(OrphanedWorkingDirsCounter)
+		}
+	}
+}
+
+func orphanPullKey(pull models.PullRequest) string {
+	return fmt.Sprintf("%s#%d", pull.BaseRepo.FullName, pull.Num)
+}
@@ -0,0 +1,78 @@
+package events_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/db"
+	lockmocks "github.com/runatlantis/atlantis/server/events/locking/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/models/fixtures"
+	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/metrics"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestOrphanCollector_Collect(t *testing.T) {
+	RegisterMockTestingT(t)
+	locker := lockmocks.NewMockLocker()
+	vcsClient := vcsmocks.NewMockClient()
+	workingDir := mocks.NewMockWorkingDir()
+	pullCleaner := mocks.NewMockPullCleaner()
+	dataDir, cleanup := TempDir(t)
+	defer cleanup()
+	database, err := db.New(dataDir)
+	Ok(t, err)
+	collector := &events.OrphanCollector{
+		Locker:      locker,
+		DB:          database,
+		VCSClient:   vcsClient,
+		WorkingDir:  workingDir,
+		PullCleaner: pullCleaner,
+		Logger:      logging.NewNoopLogger(t),
+		Interval:    time.Hour,
+		Cleaned:     metrics.NewCounters(),
+	}
+
+	closedPull := models.PullRequest{Num: 1, BaseRepo: fixtures.GithubRepo}
+	openPull := models.PullRequest{Num: 2, BaseRepo: fixtures.GithubRepo}
+	// Pull 3 only has a status, ex. because its plans were discarded.
+	closedUnlockedPull := models.PullRequest{Num: 3, BaseRepo: fixtures.GithubRepo}
+	erroredPull := models.PullRequest{Num: 4, BaseRepo: fixtures.GithubRepo}
+	lock := func(pull models.PullRequest, path string) models.ProjectLock {
+		return models.ProjectLock{Project: models.NewProject(fixtures.GithubRepo.FullName, path), Workspace: "default", Pull: pull}
+	}
+	When(locker.List()).ThenReturn(map[string]models.ProjectLock{
+		"1/a": lock(closedPull, "a"),
+		"1/b": lock(closedPull, "b"),
+		"2/a": lock(openPull, "c"),
+		"4/d": lock(erroredPull, "d"),
+		// Locks created by old versions of Atlantis have no repo.
+		"5/e": lock(models.PullRequest{Num: 5}, "e"),
+	}, nil)
+	_, err = database.UpdatePullWithResults(closedUnlockedPull, nil)
+	Ok(t, err)
+	When(vcsClient.PullIsClosed(fixtures.GithubRepo, closedPull)).ThenReturn(true, nil)
+	When(vcsClient.PullIsClosed(fixtures.GithubRepo, openPull)).ThenReturn(false, nil)
+	When(vcsClient.PullIsClosed(fixtures.GithubRepo, closedUnlockedPull)).ThenReturn(true, nil)
+	When(vcsClient.PullIsClosed(fixtures.GithubRepo, erroredPull)).ThenReturn(false, errors.New("rate limited"))
+	When(workingDir.GetPullDir(fixtures.GithubRepo, closedPull)).ThenReturn("/data/repos/owner/repo/1", nil)
+	When(workingDir.GetPullDir(fixtures.GithubRepo, closedUnlockedPull)).ThenReturn("", errors.New("not found"))
+
+	collector.Collect()
+	pullCleaner.VerifyWasCalledOnce().CleanUpPull(fixtures.GithubRepo, closedPull)
+	pullCleaner.VerifyWasCalledOnce().CleanUpPull(fixtures.GithubRepo, closedUnlockedPull)
+	pullCleaner.VerifyWasCalled(Never()).CleanUpPull(matchers.AnyModelsRepo(), matchers.EqModelsPullRequest(openPull))
+	pullCleaner.VerifyWasCalled(Never()).CleanUpPull(matchers.AnyModelsRepo(), matchers.EqModelsPullRequest(erroredPull))
+	Equals(t, map[string]int64{
+		events.OrphanedPullsCounter:       2,
+		events.OrphanedLocksCounter:       2,
+		events.OrphanedWorkingDirsCounter: 1,
+	}, collector.Cleaned.Snapshot())
+}
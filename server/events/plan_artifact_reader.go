@@ -0,0 +1,153 @@
+package events
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	version "github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// ErrPlanNotFound is returned by PlanArtifactReader when a pull request has no
+// plan for the project.
+var ErrPlanNotFound = errors.New("plan not found")
+
+// ErrPlanInUse is returned by PlanArtifactReader when a command is running in
+// the plan's workspace.
+var ErrPlanInUse = errors.New("a command is running in the plan's workspace, try again once it finishes")
+
+// PlanArtifactReader reads the plans stored in the working directories of
+// pull requests so they can be used by other tools, ex. through the API.
+type PlanArtifactReader struct {
+	WorkingDir        WorkingDir
+	WorkingDirLocker  WorkingDirLocker
+	PendingPlanFinder PendingPlanFinder
+	// PlanEncryptor decrypts the plans if they're encrypted at rest. If nil,
+	// plans aren't encrypted.
+	PlanEncryptor     runtime.PlanEncryptor
+	TerraformExecutor runtime.TerraformExec
+	// DefaultTFVersion is the version used to render plans as JSON if
+	// they weren't already rendered by a show step.
+	DefaultTFVersion *version.Version
+}
+
+// List returns the plans stored for repo's pull request pullNum.
+func (r *PlanArtifactReader) List(repo models.Repo, pullNum int) ([]PendingPlan, error) {
+	pullDir, err := r.WorkingDir.GetPullDir(repo, models.PullRequest{BaseRepo: repo, Num: pullNum})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "getting pull request's working dir")
+	}
+	plans, err := r.PendingPlanFinder.Find(pullDir)
+	return plans, errors.Wrap(err, "finding plans")
+}
+
+// Find returns the plan stored for the project in dir and workspace or, if
+// projectName is set, for the project with that name. It returns
+// ErrPlanNotFound if there's no plan.
+func (r *PlanArtifactReader) Find(repo models.Repo, pullNum int, projectName string, dir string, workspace string) (PendingPlan, error) {
+	plans, err := r.List(repo, pullNum)
+	if err != nil {
+		return PendingPlan{}, err
+	}
+	if workspace == "" {
+		workspace = DefaultWorkspace
+	}
+	for _, p := range plans {
+		if projectName != "" && p.ProjectName == projectName {
+			return p, nil
+		}
+		if projectName == "" && filepath.Clean(p.RepoRelDir) == filepath.Clean(dir) && p.Workspace == workspace {
+			return p, nil
+		}
+	}
+	return PendingPlan{}, ErrPlanNotFound
+}
+
+// Read returns the contents of the binary plan file, decrypted and
+// decompressed if needed.
+func (r *PlanArtifactReader) Read(repo models.Repo, pullNum int, plan PendingPlan) ([]byte, error) {
+	unlock, err := r.WorkingDirLocker.TryLock(repo.FullName, pullNum, plan.Workspace)
+	if err != nil {
+		return nil, ErrPlanInUse
+	}
+	defer unlock()
+
+	path, cleanup, err := r.decryptedCopy(plan)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	return ioutil.ReadFile(path) // nolint: gosec
+}
+
+// ReadJSON returns the plan rendered by terraform show -json. If a show step
+// already rendered it, that's returned, otherwise terraform is run.
+func (r *PlanArtifactReader) ReadJSON(log logging.SimpleLogging, repo models.Repo, pullNum int, plan PendingPlan) ([]byte, error) {
+	unlock, err := r.WorkingDirLocker.TryLock(repo.FullName, pullNum, plan.Workspace)
+	if err != nil {
+		return nil, ErrPlanInUse
+	}
+	defer unlock()
+
+	projectDir := filepath.Join(plan.RepoDir, plan.RepoRelDir)
+	showFile := filepath.Join(projectDir, models.ProjectCommandContext{ProjectName: plan.ProjectName, Workspace: plan.Workspace}.GetShowResultFileName())
+	if contents, err := runtime.ReadDecompressed(showFile); err == nil {
+		return contents, nil
+	}
+
+	path, cleanup, err := r.decryptedCopy(plan)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	output, err := r.TerraformExecutor.RunCommandWithVersion(log, projectDir, []string{"show", "-no-color", "-json", path}, map[string]string{}, r.DefaultTFVersion, plan.Workspace)
+	if err != nil {
+		return nil, errors.Wrap(err, "running terraform show")
+	}
+	return []byte(output), nil
+}
+
+// decryptedCopy copies the plan file next to it and decrypts and
+// decompresses the copy so the original stays encrypted and compressed. The copy doesn't end in .tfplan so it isn't found
+// as a pending plan. The returned func deletes it.
+func (r *PlanArtifactReader) decryptedCopy(plan PendingPlan) (string, func(), error) {
+	projectDir := filepath.Join(plan.RepoDir, plan.RepoRelDir)
+	contents, err := ioutil.ReadFile(filepath.Join(projectDir, runtime.GetPlanFilename(plan.Workspace, plan.ProjectName))) // nolint: gosec
+	if os.IsNotExist(err) {
+		return "", nil, ErrPlanNotFound
+	}
+	if err != nil {
+		return "", nil, errors.Wrap(err, "reading plan")
+	}
+	f, err := ioutil.TempFile(projectDir, ".atlantis-plan-")
+	if err != nil {
+		return "", nil, errors.Wrap(err, "copying plan")
+	}
+	cleanup := func() { os.Remove(f.Name()) } // nolint: errcheck
+	_, err = f.Write(contents)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, errors.Wrap(err, "copying plan")
+	}
+	if r.PlanEncryptor != nil {
+		if err := r.PlanEncryptor.Decrypt(f.Name()); err != nil {
+			cleanup()
+			return "", nil, errors.Wrap(err, "decrypting plan")
+		}
+	}
+	if err := runtime.DecompressPlan(f.Name()); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return f.Name(), cleanup, nil
+}
@@ -0,0 +1,105 @@
+package events_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	tmocks "github.com/runatlantis/atlantis/server/events/terraform/mocks"
+	tmatchers "github.com/runatlantis/atlantis/server/events/terraform/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func setupPlanArtifactReader(t *testing.T) (*events.PlanArtifactReader, *tmocks.MockClient, events.PendingPlan, string) {
+	RegisterMockTestingT(t)
+	tmp, cleanup := TempDir(t)
+	t.Cleanup(cleanup)
+	projectDir := filepath.Join(tmp, "default", "staging")
+	Ok(t, os.MkdirAll(projectDir, 0700))
+	planPath := filepath.Join(projectDir, "default.tfplan")
+	Ok(t, ioutil.WriteFile(planPath, []byte("plan"), 0600))
+
+	plan := events.PendingPlan{RepoDir: filepath.Join(tmp, "default"), RepoRelDir: "staging", Workspace: "default"}
+	workingDir := mocks.NewMockWorkingDir()
+	When(workingDir.GetPullDir(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest())).ThenReturn(tmp, nil)
+	finder := mocks.NewMockPendingPlanFinder()
+	When(finder.Find(tmp)).ThenReturn([]events.PendingPlan{plan}, nil)
+	tfClient := tmocks.NewMockClient()
+
+	encryptor, err := runtime.NewAESGCMPlanEncryptor([]byte("0123456789abcdef"))
+	Ok(t, err)
+	Ok(t, encryptor.Encrypt(planPath))
+	return &events.PlanArtifactReader{
+		WorkingDir:        workingDir,
+		WorkingDirLocker:  events.NewDefaultWorkingDirLocker(),
+		PendingPlanFinder: finder,
+		PlanEncryptor:     encryptor,
+		TerraformExecutor: tfClient,
+	}, tfClient, plan, projectDir
+}
+
+func TestPlanArtifactReader_Find(t *testing.T) {
+	reader, _, plan, _ := setupPlanArtifactReader(t)
+	found, err := reader.Find(models.Repo{FullName: "owner/repo"}, 1, "", "staging/", "")
+	Ok(t, err)
+	Equals(t, plan, found)
+
+	_, err = reader.Find(models.Repo{FullName: "owner/repo"}, 1, "", "staging", "other")
+	Equals(t, events.ErrPlanNotFound, err)
+	_, err = reader.Find(models.Repo{FullName: "owner/repo"}, 1, "staging", "", "")
+	Equals(t, events.ErrPlanNotFound, err)
+}
+
+func TestPlanArtifactReader_Read(t *testing.T) {
+	reader, _, plan, projectDir := setupPlanArtifactReader(t)
+	contents, err := reader.Read(models.Repo{FullName: "owner/repo"}, 1, plan)
+	Ok(t, err)
+	Equals(t, "plan", string(contents))
+
+	// The stored plan stays encrypted and the decrypted copy is deleted.
+	files, err := ioutil.ReadDir(projectDir)
+	Ok(t, err)
+	Equals(t, 1, len(files))
+	stored, err := ioutil.ReadFile(filepath.Join(projectDir, "default.tfplan"))
+	Ok(t, err)
+	Assert(t, string(stored) != "plan", "expected plan to stay encrypted")
+}
+
+func TestPlanArtifactReader_ReadInUse(t *testing.T) {
+	reader, _, plan, _ := setupPlanArtifactReader(t)
+	unlock, err := reader.WorkingDirLocker.TryLock("owner/repo", 1, "default")
+	Ok(t, err)
+	defer unlock()
+	_, err = reader.Read(models.Repo{FullName: "owner/repo"}, 1, plan)
+	Equals(t, events.ErrPlanInUse, err)
+}
+
+func TestPlanArtifactReader_ReadJSON(t *testing.T) {
+	t.Run("runs terraform show", func(t *testing.T) {
+		reader, tfClient, plan, projectDir := setupPlanArtifactReader(t)
+		When(tfClient.RunCommandWithVersion(tmatchers.AnyLoggingSimpleLogging(), EqString(projectDir), tmatchers.AnySliceOfString(), tmatchers.AnyMapOfStringToString(), tmatchers.AnyPtrToGoVersionVersion(), EqString("default"))).
+			ThenReturn(`{"format_version":"0.1"}`, nil)
+		contents, err := reader.ReadJSON(logging.NewNoopLogger(t), models.Repo{FullName: "owner/repo"}, 1, plan)
+		Ok(t, err)
+		Equals(t, `{"format_version":"0.1"}`, string(contents))
+		_, _, args, _, _, _ := tfClient.VerifyWasCalledOnce().RunCommandWithVersion(tmatchers.AnyLoggingSimpleLogging(), AnyString(), tmatchers.AnySliceOfString(), tmatchers.AnyMapOfStringToString(), tmatchers.AnyPtrToGoVersionVersion(), AnyString()).GetCapturedArguments()
+		Equals(t, []string{"show", "-no-color", "-json"}, args[:3])
+	})
+
+	t.Run("uses the show step's output", func(t *testing.T) {
+		reader, tfClient, plan, projectDir := setupPlanArtifactReader(t)
+		Ok(t, ioutil.WriteFile(filepath.Join(projectDir, "default.json"), []byte(`{"from":"show"}`), 0600))
+		contents, err := reader.ReadJSON(logging.NewNoopLogger(t), models.Repo{FullName: "owner/repo"}, 1, plan)
+		Ok(t, err)
+		Equals(t, `{"from":"show"}`, string(contents))
+		tfClient.VerifyWasCalled(Never()).RunCommandWithVersion(tmatchers.AnyLoggingSimpleLogging(), AnyString(), tmatchers.AnySliceOfString(), tmatchers.AnyMapOfStringToString(), tmatchers.AnyPtrToGoVersionVersion(), AnyString())
+	})
+}
@@ -2,6 +2,7 @@ package events
 
 import (
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/planstore"
 	"github.com/runatlantis/atlantis/server/events/vcs"
 )
 
@@ -63,6 +64,12 @@ type PlanCommandRunner struct {
 	autoMerger                 *AutoMerger
 	parallelPoolSize           int
 	pullStatusFetcher          PullStatusFetcher
+	// PlanStore stores plan files. If set, deleted plans are deleted from it
+	// too.
+	PlanStore *planstore.Store
+	// Validator validates the projects before they're autoplanned. If set,
+	// autoplan is skipped when any of them is invalid.
+	Validator *ValidateCommandRunner
 }
 
 func (p *PlanCommandRunner) runAutoplan(ctx *CommandContext) {
@@ -78,9 +85,10 @@ func (p *PlanCommandRunner) runAutoplan(ctx *CommandContext) {
 		return
 	}
 
+	projectCmds, keptPlans := p.partitionKeptPlans(projectCmds)
 	projectCmds, policyCheckCmds := p.partitionProjectCmds(ctx, projectCmds)
 
-	if len(projectCmds) == 0 {
+	if len(projectCmds) == 0 && len(keptPlans) == 0 {
 		ctx.Log.Info("determined there was no project to run plan in")
 		if !(p.silenceVCSStatusNoPlans || p.silenceVCSStatusNoProjects) {
 			// If there were no projects modified, we set successful commit statuses
@@ -105,6 +113,15 @@ func (p *PlanCommandRunner) runAutoplan(ctx *CommandContext) {
 		ctx.Log.Warn("unable to update commit status: %s", err)
 	}
 
+	if p.Validator != nil && len(projectCmds) > 0 && !p.Validator.ValidateAutoplan(ctx, projectCmds) {
+		ctx.Log.Info("skipping autoplan because there were validation errors")
+		if err := p.commitStatusUpdater.UpdateCombined(baseRepo, pull, models.FailedCommitStatus, models.PlanCommand); err != nil {
+			ctx.Log.Warn("unable to update commit status: %s", err)
+		}
+		return
+	}
+
+	progress := p.pullUpdater.startProgress(ctx, models.PlanCommand, projectCmds)
 	// Only run commands in parallel if enabled
 	var result CommandResult
 	if p.isParallelEnabled(projectCmds) {
@@ -113,16 +130,19 @@ func (p *PlanCommandRunner) runAutoplan(ctx *CommandContext) {
 	} else {
 		result = runProjectCmds(projectCmds, p.prjCmdRunner.Plan)
 	}
+	progress.Stop()
 
 	if p.autoMerger.automergeEnabled(projectCmds) && result.HasErrors() {
 		ctx.Log.Info("deleting plans because there were errors and automerge requires all plans succeed")
 		p.deletePlans(ctx)
 		result.PlansDeleted = true
+	} else {
+		result.KeptPlans = keptPlans
 	}
 
 	p.pullUpdater.updatePull(ctx, AutoplanCommand{}, result)
 
-	pullStatus, err := p.dbUpdater.updateDB(ctx, ctx.Pull, result.ProjectResults)
+	pullStatus, err := p.dbUpdater.updateDB(ctx, ctx.Pull, append(result.ProjectResults, result.KeptPlans...))
 	if err != nil {
 		ctx.Log.Err("writing results: %s", err)
 	}
@@ -149,6 +169,7 @@ func (p *PlanCommandRunner) run(ctx *CommandContext, cmd *CommentCommand) {
 	var err error
 	baseRepo := ctx.Pull.BaseRepo
 	pull := ctx.Pull
+	ctx.UpgradeProviders = cmd.UpgradeProviders
 
 	if err = p.commitStatusUpdater.UpdateCombined(baseRepo, pull, models.PendingCommitStatus, models.PlanCommand); err != nil {
 		ctx.Log.Warn("unable to update commit status: %s", err)
@@ -179,6 +200,7 @@ func (p *PlanCommandRunner) run(ctx *CommandContext, cmd *CommentCommand) {
 
 	projectCmds, policyCheckCmds := p.partitionProjectCmds(ctx, projectCmds)
 
+	progress := p.pullUpdater.startProgress(ctx, models.PlanCommand, projectCmds)
 	// Only run commands in parallel if enabled
 	var result CommandResult
 	if p.isParallelEnabled(projectCmds) {
@@ -187,6 +209,7 @@ func (p *PlanCommandRunner) run(ctx *CommandContext, cmd *CommentCommand) {
 	} else {
 		result = runProjectCmds(projectCmds, p.prjCmdRunner.Plan)
 	}
+	progress.Stop()
 
 	if p.autoMerger.automergeEnabled(projectCmds) && result.HasErrors() {
 		ctx.Log.Info("deleting plans because there were errors and automerge requires all plans succeed")
@@ -239,13 +262,31 @@ func (p *PlanCommandRunner) updateCommitStatus(ctx *CommandContext, pullStatus m
 		status = models.FailedCommitStatus
 	}
 
-	if err := p.commitStatusUpdater.UpdateCombinedCount(
+	// Only summarize the plans if every successful one has counts, otherwise
+	// the summary would leave out changes.
+	var sum models.PlanCounts
+	counts := &sum
+	for _, project := range pullStatus.Projects {
+		if project.Status == models.ErroredPlanStatus {
+			continue
+		}
+		if project.PlanCounts == nil {
+			counts = nil
+			break
+		}
+		sum = sum.Plus(*project.PlanCounts)
+	}
+	if numSuccess == 0 {
+		counts = nil
+	}
+
+	if err := p.commitStatusUpdater.UpdatePlanCount(
 		ctx.Pull.BaseRepo,
 		ctx.Pull,
 		status,
-		models.PlanCommand,
 		numSuccess,
 		len(pullStatus.Projects),
+		counts,
 	); err != nil {
 		ctx.Log.Warn("unable to update commit status: %s", err)
 	}
@@ -260,6 +301,47 @@ func (p *PlanCommandRunner) deletePlans(ctx *CommandContext) {
 	if err := p.pendingPlanFinder.DeletePlans(pullDir); err != nil {
 		ctx.Log.Err("deleting pending plans: %s", err)
 	}
+	if p.PlanStore != nil {
+		if err := p.PlanStore.DeleteForPull(ctx.Pull.BaseRepo.FullName, ctx.Pull.Num); err != nil {
+			ctx.Log.Err("deleting stored plans: %s", err)
+		}
+	}
+}
+
+// partitionKeptPlans splits cmds into the commands to run and the results of
+// the projects whose plans were kept, which have their previous status.
+func (p *PlanCommandRunner) partitionKeptPlans(cmds []models.ProjectCommandContext) (
+	projectCmds []models.ProjectCommandContext,
+	keptPlans []models.ProjectResult,
+) {
+	for _, cmd := range cmds {
+		if cmd.KeptPlan == nil {
+			projectCmds = append(projectCmds, cmd)
+			continue
+		}
+		// With policy checks, the project also has a policy check command.
+		if cmd.CommandName != models.PlanCommand {
+			continue
+		}
+		result := models.ProjectResult{
+			Command:     models.PlanCommand,
+			RepoRelDir:  cmd.RepoRelDir,
+			Workspace:   cmd.Workspace,
+			ProjectName: cmd.ProjectName,
+			PlanSuccess: &models.PlanSuccess{},
+			OutputURL:   cmd.KeptPlan.OutputURL,
+			User:        cmd.KeptPlan.User,
+			StartedAt:   cmd.KeptPlan.StartedAt,
+			Duration:    cmd.KeptPlan.Duration,
+		}
+		if cmd.KeptPlan.Status == models.PassedPolicyCheckStatus {
+			result.Command = models.PolicyCheckCommand
+			result.PlanSuccess = nil
+			result.PolicyCheckSuccess = &models.PolicyCheckSuccess{}
+		}
+		keptPlans = append(keptPlans, result)
+	}
+	return
 }
 
 func (p *PlanCommandRunner) partitionProjectCmds(
@@ -0,0 +1,125 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// RedactedValue replaces sensitive values in plans rendered as JSON. It's what
+// terraform shows instead of them.
+const RedactedValue = "(sensitive value)"
+
+// RedactPlanJSON replaces the sensitive values in plan, rendered by terraform
+// show -json, with RedactedValue. These are the values terraform marks as
+// sensitive in the changes, planned values and prior state of resources and
+// outputs, and the values of variables declared as sensitive.
+func RedactPlanJSON(plan []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(plan))
+	// Numbers are kept as they were rendered.
+	dec.UseNumber()
+	var root map[string]interface{}
+	if err := dec.Decode(&root); err != nil {
+		return nil, errors.Wrap(err, "parsing plan")
+	}
+
+	for _, rc := range asSlice(root["resource_changes"]) {
+		redactChange(asMap(asMap(rc)["change"]))
+	}
+	for _, oc := range asMap(root["output_changes"]) {
+		redactChange(asMap(oc))
+	}
+	redactValues(asMap(root["planned_values"]))
+	redactValues(asMap(asMap(root["prior_state"])["values"]))
+
+	configVars := asMap(asMap(asMap(root["configuration"])["root_module"])["variables"])
+	for name, v := range asMap(root["variables"]) {
+		if sensitive, _ := asMap(configVars[name])["sensitive"].(bool); sensitive {
+			asMap(v)["value"] = RedactedValue
+		}
+	}
+
+	return json.Marshal(root)
+}
+
+// redactChange redacts the before and after values of change.
+func redactChange(change map[string]interface{}) {
+	if change == nil {
+		return
+	}
+	for _, key := range []string{"before", "after"} {
+		if value, ok := change[key]; ok {
+			change[key] = redact(value, change[key+"_sensitive"])
+		}
+	}
+}
+
+// redactValues redacts the outputs and resources of values, which is either
+// the planned values or the prior state, and of its child modules.
+func redactValues(values map[string]interface{}) {
+	if values == nil {
+		return
+	}
+	for _, o := range asMap(values["outputs"]) {
+		output := asMap(o)
+		if sensitive, _ := output["sensitive"].(bool); sensitive {
+			output["value"] = RedactedValue
+		}
+	}
+	redactModule(asMap(values["root_module"]))
+}
+
+func redactModule(module map[string]interface{}) {
+	if module == nil {
+		return
+	}
+	for _, r := range asSlice(module["resources"]) {
+		resource := asMap(r)
+		if values, ok := resource["values"]; ok {
+			resource["values"] = redact(values, resource["sensitive_values"])
+		}
+	}
+	for _, child := range asSlice(module["child_modules"]) {
+		redactModule(asMap(child))
+	}
+}
+
+// redact returns value with the parts that sensitive marks as sensitive
+// replaced. sensitive is either true, if all of value is sensitive, or has
+// the same structure as value with true for its sensitive parts.
+func redact(value interface{}, sensitive interface{}) interface{} {
+	switch s := sensitive.(type) {
+	case bool:
+		if s && value != nil {
+			return RedactedValue
+		}
+	case map[string]interface{}:
+		if v, ok := value.(map[string]interface{}); ok {
+			for key, keySensitive := range s {
+				if _, ok := v[key]; ok {
+					v[key] = redact(v[key], keySensitive)
+				}
+			}
+		}
+	case []interface{}:
+		if v, ok := value.([]interface{}); ok {
+			for i := range v {
+				if i < len(s) {
+					v[i] = redact(v[i], s[i])
+				}
+			}
+		}
+	}
+	return value
+}
+
+func asMap(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func asSlice(v interface{}) []interface{} {
+	s, _ := v.([]interface{})
+	return s
+}
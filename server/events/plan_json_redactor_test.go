@@ -0,0 +1,116 @@
+package events_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestRedactPlanJSON(t *testing.T) {
+	plan := `{
+  "format_version": "0.2",
+  "variables": {
+    "password": {"value": "hunter2"},
+    "region": {"value": "us-east-1"}
+  },
+  "planned_values": {
+    "outputs": {
+      "token": {"sensitive": true, "value": "secret-token"},
+      "id": {"sensitive": false, "value": "i-123"}
+    },
+    "root_module": {
+      "resources": [
+        {"address": "aws_db_instance.db", "values": {"password": "hunter2", "port": 5432}, "sensitive_values": {"password": true}}
+      ],
+      "child_modules": [
+        {"resources": [
+          {"address": "module.m.aws_secret.s", "values": {"tags": ["a", "secret"]}, "sensitive_values": {"tags": [false, true]}}
+        ]}
+      ]
+    }
+  },
+  "resource_changes": [
+    {
+      "address": "aws_db_instance.db",
+      "change": {
+        "actions": ["update"],
+        "before": {"password": "old", "port": 5432},
+        "after": {"password": "hunter2", "port": 5433},
+        "before_sensitive": {"password": true},
+        "after_sensitive": {"password": true}
+      }
+    },
+    {
+      "address": "random_password.p",
+      "change": {
+        "actions": ["create"],
+        "before": null,
+        "after": {"result": "abc"},
+        "before_sensitive": false,
+        "after_sensitive": true
+      }
+    }
+  ],
+  "output_changes": {
+    "token": {"before": null, "after": "secret-token", "before_sensitive": false, "after_sensitive": true}
+  },
+  "prior_state": {
+    "values": {
+      "outputs": {"token": {"sensitive": true, "value": "old-token"}},
+      "root_module": {
+        "resources": [
+          {"address": "aws_db_instance.db", "values": {"password": "old", "port": 5432}, "sensitive_values": {"password": true}}
+        ]
+      }
+    }
+  },
+  "configuration": {
+    "root_module": {
+      "variables": {
+        "password": {"sensitive": true},
+        "region": {}
+      }
+    }
+  }
+}`
+	redacted, err := events.RedactPlanJSON([]byte(plan))
+	Ok(t, err)
+
+	var got map[string]interface{}
+	Ok(t, json.Unmarshal(redacted, &got))
+	get := func(path ...interface{}) interface{} {
+		var v interface{} = got
+		for _, p := range path {
+			switch p := p.(type) {
+			case string:
+				v = v.(map[string]interface{})[p]
+			case int:
+				v = v.([]interface{})[p]
+			}
+		}
+		return v
+	}
+
+	Equals(t, "(sensitive value)", get("variables", "password", "value"))
+	Equals(t, "us-east-1", get("variables", "region", "value"))
+	Equals(t, "(sensitive value)", get("planned_values", "outputs", "token", "value"))
+	Equals(t, "i-123", get("planned_values", "outputs", "id", "value"))
+	Equals(t, "(sensitive value)", get("planned_values", "root_module", "resources", 0, "values", "password"))
+	Equals(t, 5432.0, get("planned_values", "root_module", "resources", 0, "values", "port"))
+	Equals(t, []interface{}{"a", "(sensitive value)"}, get("planned_values", "root_module", "child_modules", 0, "resources", 0, "values", "tags"))
+	Equals(t, "(sensitive value)", get("resource_changes", 0, "change", "before", "password"))
+	Equals(t, "(sensitive value)", get("resource_changes", 0, "change", "after", "password"))
+	Equals(t, 5433.0, get("resource_changes", 0, "change", "after", "port"))
+	Equals(t, nil, get("resource_changes", 1, "change", "before"))
+	Equals(t, "(sensitive value)", get("resource_changes", 1, "change", "after"))
+	Equals(t, "(sensitive value)", get("output_changes", "token", "after"))
+	Equals(t, "(sensitive value)", get("prior_state", "values", "outputs", "token", "value"))
+	Equals(t, "(sensitive value)", get("prior_state", "values", "root_module", "resources", 0, "values", "password"))
+}
+
+func TestRedactPlanJSON_Invalid(t *testing.T) {
+	_, err := events.RedactPlanJSON([]byte("not json"))
+	ErrContains(t, "parsing plan", err)
+}
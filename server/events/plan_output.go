@@ -0,0 +1,201 @@
+package events
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// heredocRegex matches lines that start a heredoc. Its group is the
+// terminator.
+var heredocRegex = regexp.MustCompile(`<<[-~]?([A-Za-z_]+)$`)
+
+// planOutputMarkers are the prefixes of lines of a plan that are changes.
+var planOutputMarkers = []string{"-/+ ", "+/- ", "<= ", "+ ", "- ", "~ "}
+
+// CompactPlanOutput hides the noise of a plan's output: the unchanged
+// attributes, blocks and list elements of resources, which Terraform < 0.14
+// prints, and the attributes of resources to create that are only known
+// after apply. They're replaced with a comment of how many were hidden,
+// like Terraform >= 0.14 does for unchanged attributes.
+func CompactPlanOutput(output string) string {
+	c := &planOutputCompactor{}
+	lines := strings.Split(output, "\n")
+	for i := 0; i < len(lines); i++ {
+		i = c.line(lines, i)
+	}
+	c.flush()
+	return strings.Join(c.out, "\n")
+}
+
+type planOutputCompactor struct {
+	out []string
+	// lists is whether each of the open blocks is a list, so hidden lines
+	// are counted as elements instead of attributes.
+	lists []bool
+
+	indent   string
+	attrs    int
+	blocks   int
+	elements int
+	computed int
+}
+
+// line handles lines[i] and returns the index of the last line it consumed.
+func (c *planOutputCompactor) line(lines []string, i int) int {
+	line := lines[i]
+	trimmed := strings.TrimSpace(line)
+	if len(c.lists) == 0 {
+		// Outside of resources, only changes can open blocks, the rest is
+		// text.
+		c.keep(line)
+		if hasPlanOutputMarker(trimmed) {
+			c.open(trimmed)
+		}
+		return i
+	}
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		c.keep(line)
+		return i
+	}
+	if closers := countClosers(trimmed); closers > 0 {
+		c.keep(line)
+		c.close(closers)
+		return i
+	}
+
+	changed := hasPlanOutputMarker(trimmed)
+	if !changed || (strings.HasPrefix(trimmed, "+ ") && strings.HasSuffix(trimmed, "= (known after apply)")) {
+		if c.indent == "" {
+			c.indent = line[:len(line)-len(strings.TrimLeft(line, " "))]
+			if changed {
+				// Align with the attribute, not its marker.
+				c.indent += "  "
+			}
+		}
+		end, block := skipPlanOutputValue(lines, i)
+		switch {
+		case changed:
+			c.computed++
+		case block:
+			c.blocks++
+		case c.lists[len(c.lists)-1]:
+			c.elements++
+		default:
+			c.attrs++
+		}
+		return end
+	}
+
+	c.keep(line)
+	if match := heredocRegex.FindStringSubmatch(trimmed); match != nil {
+		// Changed heredocs are kept whole since their lines are a diff.
+		for i+1 < len(lines) {
+			i++
+			c.keep(lines[i])
+			if strings.TrimSpace(lines[i]) == match[1] {
+				break
+			}
+		}
+		return i
+	}
+	c.open(trimmed)
+	return i
+}
+
+// keep keeps line, after a comment of the lines hidden before it.
+func (c *planOutputCompactor) keep(line string) {
+	c.flush()
+	c.out = append(c.out, line)
+}
+
+func (c *planOutputCompactor) open(trimmed string) {
+	switch {
+	case strings.HasSuffix(trimmed, "["):
+		c.lists = append(c.lists, true)
+	case strings.HasSuffix(trimmed, "{"), strings.HasSuffix(trimmed, "("):
+		c.lists = append(c.lists, false)
+	}
+}
+
+func (c *planOutputCompactor) close(n int) {
+	if n > len(c.lists) {
+		n = len(c.lists)
+	}
+	c.lists = c.lists[:len(c.lists)-n]
+}
+
+// flush adds comments of the lines hidden since the last line kept.
+func (c *planOutputCompactor) flush() {
+	for _, hidden := range []struct {
+		n                int
+		singular, plural string
+	}{
+		{c.attrs, "unchanged attribute", "unchanged attributes"},
+		{c.blocks, "unchanged block", "unchanged blocks"},
+		{c.elements, "unchanged element", "unchanged elements"},
+		{c.computed, "attribute known after apply", "attributes known after apply"},
+	} {
+		if hidden.n == 1 {
+			c.out = append(c.out, fmt.Sprintf("%s# (1 %s hidden)", c.indent, hidden.singular))
+		} else if hidden.n > 1 {
+			c.out = append(c.out, fmt.Sprintf("%s# (%d %s hidden)", c.indent, hidden.n, hidden.plural))
+		}
+	}
+	c.indent = ""
+	c.attrs, c.blocks, c.elements, c.computed = 0, 0, 0, 0
+}
+
+// skipPlanOutputValue returns the index of the last line of the attribute,
+// block or element starting at lines[i], and whether it's a block.
+func skipPlanOutputValue(lines []string, i int) (int, bool) {
+	trimmed := strings.TrimSpace(lines[i])
+	if match := heredocRegex.FindStringSubmatch(trimmed); match != nil {
+		for i+1 < len(lines) {
+			i++
+			if strings.TrimSpace(lines[i]) == match[1] {
+				break
+			}
+		}
+		return i, false
+	}
+	if !opensPlanOutputBlock(trimmed) {
+		return i, false
+	}
+	block := strings.HasSuffix(trimmed, "{") && !strings.Contains(trimmed, "=")
+	depth := 1
+	for depth > 0 && i+1 < len(lines) {
+		i++
+		t := strings.TrimSpace(lines[i])
+		depth -= countClosers(t)
+		if opensPlanOutputBlock(t) {
+			depth++
+		}
+	}
+	return i, block
+}
+
+func hasPlanOutputMarker(trimmed string) bool {
+	for _, m := range planOutputMarkers {
+		if strings.HasPrefix(trimmed, m) {
+			return true
+		}
+	}
+	return false
+}
+
+func opensPlanOutputBlock(trimmed string) bool {
+	return strings.HasSuffix(trimmed, "{") || strings.HasSuffix(trimmed, "[") || strings.HasSuffix(trimmed, "(")
+}
+
+// countClosers returns how many blocks the line closes.
+func countClosers(trimmed string) int {
+	n := 0
+	for _, r := range trimmed {
+		if r != '}' && r != ']' && r != ')' {
+			break
+		}
+		n++
+	}
+	return n
+}
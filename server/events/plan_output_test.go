@@ -0,0 +1,108 @@
+package events_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestCompactPlanOutput(t *testing.T) {
+	cases := map[string]struct {
+		output string
+		exp    string
+	}{
+		"unchanged attributes and blocks": {
+			output: `  # aws_instance.web will be updated in-place
+~ resource "aws_instance" "web" {
+        ami           = "ami-123"
+        id            = "i-123"
+      ~ instance_type = "t2.micro" -> "t2.small"
+      ~ tags          = {
+            "Env"  = "prod"
+          ~ "Name" = "web" -> "web-1"
+        }
+        user_data     = <<~EOT
+            #!/bin/bash
+        EOT
+
+        root_block_device {
+            volume_size = 8
+        }
+    }
+
+Plan: 0 to add, 1 to change, 0 to destroy.`,
+			exp: `  # aws_instance.web will be updated in-place
+~ resource "aws_instance" "web" {
+        # (2 unchanged attributes hidden)
+      ~ instance_type = "t2.micro" -> "t2.small"
+      ~ tags          = {
+            # (1 unchanged attribute hidden)
+          ~ "Name" = "web" -> "web-1"
+        }
+        # (1 unchanged attribute hidden)
+
+        # (1 unchanged block hidden)
+    }
+
+Plan: 0 to add, 1 to change, 0 to destroy.`,
+		},
+		"unchanged elements": {
+			output: `~ resource "aws_security_group" "sg" {
+      ~ cidr_blocks = [
+            "10.0.0.0/8",
+            "10.1.0.0/16",
+          + "10.2.0.0/16",
+        ]
+    }`,
+			exp: `~ resource "aws_security_group" "sg" {
+      ~ cidr_blocks = [
+            # (2 unchanged elements hidden)
+          + "10.2.0.0/16",
+        ]
+    }`,
+		},
+		"known after apply": {
+			output: `+ resource "aws_instance" "new" {
+      + ami           = "ami-123"
+      + arn           = (known after apply)
+      + id            = (known after apply)
+      + ebs_block_device {
+          + volume_id = (known after apply)
+        }
+    }`,
+			exp: `+ resource "aws_instance" "new" {
+      + ami           = "ami-123"
+        # (2 attributes known after apply hidden)
+      + ebs_block_device {
+            # (1 attribute known after apply hidden)
+        }
+    }`,
+		},
+		"changed heredoc": {
+			output: `~ resource "aws_instance" "web" {
+      ~ user_data = <<~EOT
+            #!/bin/bash
+          - echo hi
+          + echo bye
+        EOT
+    }`,
+			exp: `~ resource "aws_instance" "web" {
+      ~ user_data = <<~EOT
+            #!/bin/bash
+          - echo hi
+          + echo bye
+        EOT
+    }`,
+		},
+		"no resources": {
+			output: "No changes. Infrastructure is up-to-date.\n\nThis means that Terraform did not detect any differences {\n  x = 1\n",
+			exp:    "No changes. Infrastructure is up-to-date.\n\nThis means that Terraform did not detect any differences {\n  x = 1\n",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			Equals(t, c.exp, events.CompactPlanOutput(c.output))
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package events
+
+import (
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/planstore"
+)
+
+// PlanStoreWorkingDir implements WorkingDir.
+// It deletes the plans in the plan store along with the working dirs they
+// were generated in so they can't be restored once they're discarded.
+type PlanStoreWorkingDir struct {
+	WorkingDir
+	PlanStore *planstore.Store
+}
+
+// Delete deletes the workspace and stored plans for this repo and pull.
+func (w *PlanStoreWorkingDir) Delete(r models.Repo, p models.PullRequest) error {
+	if err := w.PlanStore.DeleteForPull(r.FullName, p.Num); err != nil {
+		return err
+	}
+	return w.WorkingDir.Delete(r, p)
+}
+
+// DeleteForWorkspace deletes the working dir and stored plans for this
+// workspace.
+func (w *PlanStoreWorkingDir) DeleteForWorkspace(r models.Repo, p models.PullRequest, workspace string) error {
+	if err := w.PlanStore.DeleteForWorkspace(r.FullName, p.Num, workspace); err != nil {
+		return err
+	}
+	return w.WorkingDir.DeleteForWorkspace(r, p, workspace)
+}
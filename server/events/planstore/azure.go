@@ -0,0 +1,171 @@
+package planstore
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// AzureSASTokenEnvVar is the environment variable holding the shared
+	// access signature used to access the Azure Blob Storage container. It
+	// must allow reading, writing, listing and deleting blobs.
+	AzureSASTokenEnvVar = "AZURE_STORAGE_SAS_TOKEN" // nolint: gosec
+
+	azureAPIVersion = "2019-12-12"
+	azureTimeout    = time.Minute
+)
+
+// AzureBackend stores objects as block blobs in an Azure Blob Storage
+// container using its REST API.
+type AzureBackend struct {
+	endpoint   *url.URL
+	container  string
+	sasToken   url.Values
+	httpClient *http.Client
+}
+
+// NewAzureBackend returns a backend for the container of account. endpoint
+// overrides the account's endpoint, ex. for Azurite.
+func NewAzureBackend(account string, container string, sasToken string, endpoint string) (*AzureBackend, error) {
+	if sasToken == "" {
+		return nil, fmt.Errorf("%s must be set to use Azure Blob Storage", AzureSASTokenEnvVar)
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", account)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing Azure Blob Storage endpoint")
+	}
+	token, err := url.ParseQuery(strings.TrimPrefix(sasToken, "?"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", AzureSASTokenEnvVar)
+	}
+	return &AzureBackend{
+		endpoint:   u,
+		container:  container,
+		sasToken:   token,
+		httpClient: &http.Client{Timeout: azureTimeout},
+	}, nil
+}
+
+// Put uploads the object to key.
+func (a *AzureBackend) Put(key string, data []byte) error {
+	req, err := http.NewRequest("PUT", a.url(key, nil), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+	_, err = a.do(req, http.StatusCreated)
+	return err
+}
+
+// Get downloads the object at key.
+func (a *AzureBackend) Get(key string) ([]byte, error) {
+	req, err := http.NewRequest("GET", a.url(key, nil), nil)
+	if err != nil {
+		return nil, err
+	}
+	return a.do(req, http.StatusOK)
+}
+
+// azureBlobList is the response of the List Blobs operation.
+type azureBlobList struct {
+	Blobs []struct {
+		Name string `xml:"Name"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// List lists the container's objects starting with prefix.
+func (a *AzureBackend) List(prefix string) ([]string, error) {
+	var keys []string
+	marker := ""
+	for {
+		query := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {prefix}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		req, err := http.NewRequest("GET", a.url("", query), nil)
+		if err != nil {
+			return nil, err
+		}
+		body, err := a.do(req, http.StatusOK)
+		if err != nil {
+			return nil, err
+		}
+		var list azureBlobList
+		if err := xml.Unmarshal(body, &list); err != nil {
+			return nil, errors.Wrap(err, "parsing blob list")
+		}
+		for _, blob := range list.Blobs {
+			keys = append(keys, blob.Name)
+		}
+		if list.NextMarker == "" {
+			return keys, nil
+		}
+		marker = list.NextMarker
+	}
+}
+
+// Delete deletes the object at key.
+func (a *AzureBackend) Delete(key string) error {
+	req, err := http.NewRequest("DELETE", a.url(key, nil), nil)
+	if err != nil {
+		return err
+	}
+	_, err = a.do(req, http.StatusAccepted)
+	if err == ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// url returns the URL of the blob at key, or of the container if key is
+// empty, authenticated with the SAS token.
+func (a *AzureBackend) url(key string, query url.Values) string {
+	u := *a.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + a.container
+	if key != "" {
+		u.Path += "/" + key
+	}
+	q := url.Values{}
+	for k, v := range a.sasToken {
+		q[k] = v
+	}
+	for k, v := range query {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// do sends req and returns the response's body. It returns ErrNotFound on a
+// 404 and an error if the response's status isn't expStatus.
+func (a *AzureBackend) do(req *http.Request, expStatus int) ([]byte, error) {
+	req.Header.Set("x-ms-version", azureAPIVersion)
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != expStatus {
+		return nil, fmt.Errorf("%s %s returned %d: %s", req.Method, req.URL.Path, resp.StatusCode, resp.Header.Get("x-ms-error-code"))
+	}
+	return body, nil
+}
@@ -0,0 +1,99 @@
+package planstore_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/planstore"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// fakeAzure serves the subset of the Blob Storage REST API used by
+// AzureBackend for the container "plans".
+func fakeAzure(t *testing.T) *httptest.Server {
+	blobs := map[string][]byte{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sig") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, "/plans/")
+		switch {
+		case r.Method == "GET" && r.URL.Query().Get("comp") == "list":
+			var names []string
+			for n := range blobs {
+				if strings.HasPrefix(n, r.URL.Query().Get("prefix")) {
+					names = append(names, n)
+				}
+			}
+			sort.Strings(names)
+			fmt.Fprint(w, "<EnumerationResults><Blobs>")
+			for _, n := range names {
+				fmt.Fprintf(w, "<Blob><Name>%s</Name></Blob>", n)
+			}
+			fmt.Fprint(w, "</Blobs><NextMarker /></EnumerationResults>")
+		case r.Method == "PUT":
+			Equals(t, "BlockBlob", r.Header.Get("x-ms-blob-type"))
+			data, err := ioutil.ReadAll(r.Body)
+			Ok(t, err)
+			blobs[name] = data
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == "GET":
+			data, ok := blobs[name]
+			if !ok {
+				w.Header().Set("x-ms-error-code", "BlobNotFound")
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data) // nolint: errcheck
+		case r.Method == "DELETE":
+			if _, ok := blobs[name]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(blobs, name)
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+}
+
+func TestAzureBackend(t *testing.T) {
+	server := fakeAzure(t)
+	defer server.Close()
+	a, err := planstore.NewAzureBackend("account", "plans", "?sv=2019-12-12&sig=secret", server.URL)
+	Ok(t, err)
+
+	Ok(t, a.Put("owner/repo/1/default/%2E/default.tfplan", []byte("plan")))
+	Ok(t, a.Put("owner/repo/2/default/%2E/default.tfplan", []byte("other")))
+	data, err := a.Get("owner/repo/1/default/%2E/default.tfplan")
+	Ok(t, err)
+	Equals(t, "plan", string(data))
+	_, err = a.Get("missing")
+	Equals(t, planstore.ErrNotFound, err)
+
+	keys, err := a.List("owner/repo/1/")
+	Ok(t, err)
+	Equals(t, []string{"owner/repo/1/default/%2E/default.tfplan"}, keys)
+
+	Ok(t, a.Delete("owner/repo/1/default/%2E/default.tfplan"))
+	Ok(t, a.Delete("owner/repo/1/default/%2E/default.tfplan"))
+	keys, err = a.List("owner/repo/1/")
+	Ok(t, err)
+	Equals(t, 0, len(keys))
+}
+
+func TestAzureBackend_Forbidden(t *testing.T) {
+	server := fakeAzure(t)
+	defer server.Close()
+	a, err := planstore.NewAzureBackend("account", "plans", "sig=wrong", server.URL)
+	Ok(t, err)
+	ErrContains(t, "returned 403", a.Put("key", []byte("plan")))
+
+	_, err = planstore.NewAzureBackend("account", "plans", "", "")
+	ErrEquals(t, "AZURE_STORAGE_SAS_TOKEN must be set to use Azure Blob Storage", err)
+}
@@ -0,0 +1,73 @@
+package planstore
+
+import (
+	"context"
+	"io/ioutil"
+
+	"cloud.google.com/go/storage"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+)
+
+// GCSBackend stores objects in a Google Cloud Storage bucket.
+type GCSBackend struct {
+	bucket *storage.BucketHandle
+}
+
+// NewGCSBackend returns a backend for bucket. It authenticates with the
+// application default credentials.
+func NewGCSBackend(bucket string) (*GCSBackend, error) {
+	client, err := storage.NewClient(context.Background())
+	if err != nil {
+		return nil, errors.Wrap(err, "creating Google Cloud Storage client")
+	}
+	return &GCSBackend{bucket: client.Bucket(bucket)}, nil
+}
+
+// Put uploads the object to key.
+func (g *GCSBackend) Put(key string, data []byte) error {
+	w := g.bucket.Object(key).NewWriter(context.Background())
+	if _, err := w.Write(data); err != nil {
+		w.Close() // nolint: errcheck
+		return err
+	}
+	return w.Close()
+}
+
+// Get downloads the object at key.
+func (g *GCSBackend) Get(key string) ([]byte, error) {
+	r, err := g.bucket.Object(key).NewReader(context.Background())
+	if err == storage.ErrObjectNotExist {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close() // nolint: errcheck
+	return ioutil.ReadAll(r)
+}
+
+// List lists the bucket's objects starting with prefix.
+func (g *GCSBackend) List(prefix string) ([]string, error) {
+	var keys []string
+	it := g.bucket.Objects(context.Background(), &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return keys, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, attrs.Name)
+	}
+}
+
+// Delete deletes the object at key.
+func (g *GCSBackend) Delete(key string) error {
+	err := g.bucket.Object(key).Delete(context.Background())
+	if err == storage.ErrObjectNotExist {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,243 @@
+// Package planstore stores plan files in an object store so they survive
+// restarts and can be applied by a different Atlantis server than the one
+// that generated them.
+package planstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+const (
+	// metadataSuffix is appended to the key of a plan file to get the key of
+	// its metadata.
+	metadataSuffix = ".json"
+	// planFilePerms are the permissions of restored plan files.
+	planFilePerms = 0600
+)
+
+// ErrNotFound is returned by Backend.Get when there's no object at the key.
+var ErrNotFound = errors.New("object not found")
+
+// Backend stores objects by key. Keys are slash-separated paths.
+type Backend interface {
+	// Put creates or replaces the object at key.
+	Put(key string, data []byte) error
+	// Get returns the object at key or ErrNotFound.
+	Get(key string) ([]byte, error)
+	// List returns the keys of the objects whose keys start with prefix.
+	List(prefix string) ([]string, error)
+	// Delete deletes the object at key. It's a no-op if there's none.
+	Delete(key string) error
+}
+
+// Plan is the metadata stored alongside a plan file.
+type Plan struct {
+	Repo string `json:"repo"`
+	Pull int    `json:"pull"`
+	// HeadCommit is the commit the plan was generated for. Plans for any
+	// other commit are stale.
+	HeadCommit  string `json:"head_commit"`
+	RepoRelDir  string `json:"repo_rel_dir"`
+	Workspace   string `json:"workspace"`
+	ProjectName string `json:"project_name,omitempty"`
+	// PlanFile is the name of the plan file in the project's directory.
+	PlanFile  string    `json:"plan_file"`
+	User      string    `json:"user"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Store saves the plan files of pull requests in a Backend. Plans are keyed
+// by {prefix}{repo}/{pull}/{workspace}/{dir}/{plan file} so the plans of a
+// pull request, or of one of its workspaces, can be listed and deleted
+// together.
+type Store struct {
+	backend Backend
+	prefix  string
+	// Now returns the current time. It's only overridden in tests.
+	Now func() time.Time
+}
+
+// New returns a store that saves plans in backend under prefix.
+func New(backend Backend, prefix string) *Store {
+	return &Store{
+		backend: backend,
+		prefix:  prefix,
+		Now:     time.Now,
+	}
+}
+
+// NewFromURL returns a store for storeURL, one of:
+//
+//	s3://{bucket}/{prefix}?region={region}&endpoint={endpoint}
+//	gs://{bucket}/{prefix}
+//	azblob://{account}/{container}/{prefix}?endpoint={endpoint}
+//	file://{dir}
+//
+// keyPrefix is appended to the URL's prefix, ex. to namespace a tenant.
+func NewFromURL(storeURL string, keyPrefix string) (*Store, error) {
+	u, err := url.Parse(storeURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing plan store url")
+	}
+	prefix := strings.TrimPrefix(u.Path, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	var backend Backend
+	switch u.Scheme {
+	case "s3":
+		backend, err = NewS3Backend(u.Host, u.Query().Get("region"), u.Query().Get("endpoint"))
+	case "gs":
+		backend, err = NewGCSBackend(u.Host)
+	case "azblob":
+		// The container is the first path segment.
+		parts := strings.SplitN(prefix, "/", 2)
+		if parts[0] == "" {
+			return nil, fmt.Errorf("plan store url %q is missing the container", storeURL)
+		}
+		prefix = ""
+		if len(parts) == 2 {
+			prefix = parts[1]
+		}
+		backend, err = NewAzureBackend(u.Host, parts[0], os.Getenv(AzureSASTokenEnvVar), u.Query().Get("endpoint"))
+	case "file":
+		backend, prefix = &FileBackend{Dir: u.Path}, ""
+	default:
+		return nil, fmt.Errorf("unsupported plan store url scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return New(backend, prefix+keyPrefix), nil
+}
+
+// Save uploads the plan file at planPath that was generated for ctx.
+func (s *Store) Save(ctx models.ProjectCommandContext, planPath string) error {
+	data, err := ioutil.ReadFile(planPath) // nolint: gosec
+	if err != nil {
+		return errors.Wrap(err, "reading plan")
+	}
+	plan := Plan{
+		Repo:        ctx.Pull.BaseRepo.FullName,
+		Pull:        ctx.Pull.Num,
+		HeadCommit:  ctx.Pull.HeadCommit,
+		RepoRelDir:  ctx.RepoRelDir,
+		Workspace:   ctx.Workspace,
+		ProjectName: ctx.ProjectName,
+		PlanFile:    filepath.Base(planPath),
+		User:        ctx.User.Username,
+		CreatedAt:   s.Now().UTC(),
+	}
+	metadata, err := json.Marshal(plan)
+	if err != nil {
+		return err
+	}
+	key := s.planKey(plan)
+	if err := s.backend.Put(key, data); err != nil {
+		return errors.Wrapf(err, "uploading plan to %q", key)
+	}
+	// The metadata is written last since it's what List looks for.
+	return errors.Wrapf(s.backend.Put(key+metadataSuffix, metadata), "uploading plan metadata to %q", key+metadataSuffix)
+}
+
+// List returns the plans stored for the pull request.
+func (s *Store) List(repoFullName string, pullNum int) ([]Plan, error) {
+	keys, err := s.backend.List(s.pullPrefix(repoFullName, pullNum))
+	if err != nil {
+		return nil, errors.Wrap(err, "listing plans")
+	}
+	var plans []Plan
+	for _, key := range keys {
+		if !strings.HasSuffix(key, metadataSuffix) {
+			continue
+		}
+		data, err := s.backend.Get(key)
+		if err == ErrNotFound {
+			// It was deleted since it was listed.
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "downloading plan metadata %q", key)
+		}
+		var plan Plan
+		if err := json.Unmarshal(data, &plan); err != nil {
+			return nil, errors.Wrapf(err, "parsing plan metadata %q", key)
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+// Restore downloads plan into its project's directory in repoDir.
+func (s *Store) Restore(plan Plan, repoDir string) error {
+	data, err := s.backend.Get(s.planKey(plan))
+	if err != nil {
+		return errors.Wrapf(err, "downloading plan %q", s.planKey(plan))
+	}
+	planPath := filepath.Join(repoDir, plan.RepoRelDir, plan.PlanFile)
+	return errors.Wrap(ioutil.WriteFile(planPath, data, planFilePerms), "writing plan")
+}
+
+// Delete deletes the plan generated for ctx, ex. once it's applied.
+func (s *Store) Delete(ctx models.ProjectCommandContext, planFile string) error {
+	key := s.planKey(Plan{
+		Repo:       ctx.Pull.BaseRepo.FullName,
+		Pull:       ctx.Pull.Num,
+		Workspace:  ctx.Workspace,
+		RepoRelDir: ctx.RepoRelDir,
+		PlanFile:   planFile,
+	})
+	// The metadata is deleted first so the plan isn't listed anymore.
+	for _, k := range []string{key + metadataSuffix, key} {
+		if err := s.backend.Delete(k); err != nil {
+			return errors.Wrapf(err, "deleting %q", k)
+		}
+	}
+	return nil
+}
+
+// DeleteForWorkspace deletes the plans of the pull request in workspace.
+func (s *Store) DeleteForWorkspace(repoFullName string, pullNum int, workspace string) error {
+	return s.deletePrefix(s.pullPrefix(repoFullName, pullNum) + workspace + "/")
+}
+
+// DeleteForPull deletes the plans of the pull request.
+func (s *Store) DeleteForPull(repoFullName string, pullNum int) error {
+	return s.deletePrefix(s.pullPrefix(repoFullName, pullNum))
+}
+
+func (s *Store) deletePrefix(prefix string) error {
+	keys, err := s.backend.List(prefix)
+	if err != nil {
+		return errors.Wrap(err, "listing plans")
+	}
+	for _, key := range keys {
+		if err := s.backend.Delete(key); err != nil {
+			return errors.Wrapf(err, "deleting %q", key)
+		}
+	}
+	return nil
+}
+
+func (s *Store) pullPrefix(repoFullName string, pullNum int) string {
+	return s.prefix + repoFullName + "/" + strconv.Itoa(pullNum) + "/"
+}
+
+func (s *Store) planKey(plan Plan) string {
+	// The dir is escaped so that it's a single key segment, and so that "."
+	// isn't treated as a relative path by object stores.
+	dir := strings.ReplaceAll(url.PathEscape(path.Clean(filepath.ToSlash(plan.RepoRelDir))), ".", "%2E")
+	return s.pullPrefix(plan.Repo, plan.Pull) + plan.Workspace + "/" + dir + "/" + plan.PlanFile
+}
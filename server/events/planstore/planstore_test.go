@@ -0,0 +1,121 @@
+package planstore_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/planstore"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+var planStoreNow = time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func projectCtx(dir string, workspace string) models.ProjectCommandContext {
+	return models.ProjectCommandContext{
+		Pull: models.PullRequest{
+			Num:        1,
+			HeadCommit: "sha",
+			BaseRepo:   models.Repo{FullName: "owner/repo"},
+		},
+		User:       models.User{Username: "lkysow"},
+		RepoRelDir: dir,
+		Workspace:  workspace,
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, cleanup := TempDir(t)
+	t.Cleanup(cleanup)
+	return dir
+}
+
+func writePlan(t *testing.T, repoDir string, dir string, contents string) string {
+	Ok(t, os.MkdirAll(filepath.Join(repoDir, dir), 0700))
+	planPath := filepath.Join(repoDir, dir, "default.tfplan")
+	Ok(t, ioutil.WriteFile(planPath, []byte(contents), 0600))
+	return planPath
+}
+
+func TestStore_SaveRestore(t *testing.T) {
+	backendDir := tempDir(t)
+	s := planstore.New(&planstore.FileBackend{Dir: backendDir}, "atlantis/")
+	s.Now = func() time.Time { return planStoreNow }
+
+	repoDir := tempDir(t)
+	Ok(t, s.Save(projectCtx(".", "default"), writePlan(t, repoDir, ".", "root")))
+	Ok(t, s.Save(projectCtx("modules/vpc", "default"), writePlan(t, repoDir, "modules/vpc", "vpc")))
+	Ok(t, s.Save(projectCtx(".", "staging"), writePlan(t, repoDir, ".", "staging")))
+
+	// Each dir is a single key segment.
+	_, err := os.Stat(filepath.Join(backendDir, "atlantis/owner/repo/1/default/modules%2Fvpc/default.tfplan.json"))
+	Ok(t, err)
+
+	plans, err := s.List("owner/repo", 1)
+	Ok(t, err)
+	Equals(t, 3, len(plans))
+	Equals(t, planstore.Plan{
+		Repo:       "owner/repo",
+		Pull:       1,
+		HeadCommit: "sha",
+		RepoRelDir: ".",
+		Workspace:  "default",
+		PlanFile:   "default.tfplan",
+		User:       "lkysow",
+		CreatedAt:  planStoreNow,
+	}, plans[0])
+
+	// Restore into another clone.
+	otherRepoDir := tempDir(t)
+	Ok(t, os.MkdirAll(filepath.Join(otherRepoDir, "modules/vpc"), 0700))
+	for _, plan := range plans {
+		if plan.Workspace == "default" {
+			Ok(t, s.Restore(plan, otherRepoDir))
+		}
+	}
+	contents, err := ioutil.ReadFile(filepath.Join(otherRepoDir, "modules/vpc/default.tfplan"))
+	Ok(t, err)
+	Equals(t, "vpc", string(contents))
+	contents, err = ioutil.ReadFile(filepath.Join(otherRepoDir, "default.tfplan"))
+	Ok(t, err)
+	Equals(t, "root", string(contents))
+
+	// Other pull requests' plans aren't listed.
+	plans, err = s.List("owner/repo", 10)
+	Ok(t, err)
+	Equals(t, 0, len(plans))
+}
+
+func TestStore_Delete(t *testing.T) {
+	s := planstore.New(&planstore.FileBackend{Dir: tempDir(t)}, "")
+	repoDir := tempDir(t)
+	Ok(t, s.Save(projectCtx(".", "default"), writePlan(t, repoDir, ".", "root")))
+	Ok(t, s.Save(projectCtx("vpc", "default"), writePlan(t, repoDir, "vpc", "vpc")))
+	Ok(t, s.Save(projectCtx(".", "staging"), writePlan(t, repoDir, ".", "staging")))
+
+	Ok(t, s.Delete(projectCtx("vpc", "default"), "default.tfplan"))
+	plans, err := s.List("owner/repo", 1)
+	Ok(t, err)
+	Equals(t, 2, len(plans))
+
+	Ok(t, s.DeleteForWorkspace("owner/repo", 1, "default"))
+	plans, err = s.List("owner/repo", 1)
+	Ok(t, err)
+	Equals(t, 1, len(plans))
+	Equals(t, "staging", plans[0].Workspace)
+
+	Ok(t, s.DeleteForPull("owner/repo", 1))
+	plans, err = s.List("owner/repo", 1)
+	Ok(t, err)
+	Equals(t, 0, len(plans))
+}
+
+func TestNewFromURL_Invalid(t *testing.T) {
+	_, err := planstore.NewFromURL("ftp://bucket/plans", "")
+	ErrEquals(t, `unsupported plan store url scheme "ftp"`, err)
+	_, err = planstore.NewFromURL("azblob://account", "")
+	ErrEquals(t, `plan store url "azblob://account" is missing the container`, err)
+}
@@ -0,0 +1,97 @@
+package planstore
+
+import (
+	"bytes"
+	"io/ioutil"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/pkg/errors"
+)
+
+// S3Backend stores objects in an S3 bucket.
+type S3Backend struct {
+	client s3iface.S3API
+	bucket string
+}
+
+// NewS3Backend returns a backend for bucket. If region is empty, it's read
+// from the environment like the AWS CLI does. endpoint overrides the S3
+// endpoint, ex. for MinIO.
+func NewS3Backend(bucket string, region string, endpoint string) (*S3Backend, error) {
+	awsCfg := aws.Config{}
+	if region != "" {
+		awsCfg.Region = aws.String(region)
+	}
+	if endpoint != "" {
+		awsCfg.Endpoint = aws.String(endpoint)
+		// S3 compatible stores don't usually support virtual hosted buckets.
+		awsCfg.S3ForcePathStyle = aws.Bool(true)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            awsCfg,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating AWS session")
+	}
+	return NewS3BackendWithClient(s3.New(sess), bucket), nil
+}
+
+// NewS3BackendWithClient is used for testing.
+func NewS3BackendWithClient(client s3iface.S3API, bucket string) *S3Backend {
+	return &S3Backend{client: client, bucket: bucket}
+}
+
+// Put uploads the object to key.
+func (s *S3Backend) Put(key string, data []byte) error {
+	_, err := s.client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+// Get downloads the object at key.
+func (s *S3Backend) Get(key string) ([]byte, error) {
+	out, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close() // nolint: errcheck
+	return ioutil.ReadAll(out.Body)
+}
+
+// List lists the bucket's objects starting with prefix.
+func (s *S3Backend) List(prefix string) ([]string, error) {
+	var keys []string
+	err := s.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.StringValue(obj.Key))
+		}
+		return true
+	})
+	return keys, err
+}
+
+// Delete deletes the object at key. S3 doesn't fail if there's none.
+func (s *S3Backend) Delete(key string) error {
+	_, err := s.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
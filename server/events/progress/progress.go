@@ -0,0 +1,136 @@
+// Package progress shows the progress of long running commands in a comment
+// on the pull request that's edited while they run.
+package progress
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// DoneStage is the stage of projects whose steps have all run.
+const DoneStage = "done"
+
+// waitingStage is the stage of projects whose steps haven't started yet.
+const waitingStage = "waiting"
+
+// Comment is the progress comment of a command. It shows how long the
+// command has been running and the stage, ex. init, plan or apply, that each
+// of its projects is at. A nil *Comment shows nothing.
+type Comment struct {
+	command  string
+	projects []string
+	start    time.Time
+
+	mutex  sync.Mutex
+	stages map[string]string
+	stop   chan struct{}
+	done   chan struct{}
+	edit   func(body string) error
+	log    logging.SimpleLogging
+}
+
+// New returns the progress comment of command, which runs in projects. Each
+// project is described by a string, ex. its dir and workspace.
+func New(command string, projects []string) *Comment {
+	stages := make(map[string]string)
+	for _, p := range projects {
+		stages[p] = waitingStage
+	}
+	return &Comment{
+		command:  command,
+		projects: projects,
+		start:    time.Now(),
+		stages:   stages,
+	}
+}
+
+// Start edits the comment with edit every interval until Stop is called.
+// Errors editing it are logged to log.
+func (c *Comment) Start(interval time.Duration, edit func(body string) error, log logging.SimpleLogging) {
+	if c == nil {
+		return
+	}
+	c.edit = edit
+	c.log = log
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				c.update()
+			}
+		}
+	}()
+}
+
+// SetStage sets the stage of project, ex. to the name of the step it's
+// running.
+func (c *Comment) SetStage(project string, stage string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.stages[project]; ok {
+		c.stages[project] = stage
+	}
+}
+
+// Stop stops editing the comment and edits it one last time to show that
+// the command has completed.
+func (c *Comment) Stop() {
+	if c == nil || c.stop == nil {
+		return
+	}
+	close(c.stop)
+	<-c.done
+	c.update()
+}
+
+// Body returns the current body of the comment.
+func (c *Comment) Body() string {
+	if c == nil {
+		return ""
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	elapsed := time.Since(c.start).Round(time.Second)
+	var b strings.Builder
+	if c.stop != nil && isClosed(c.stop) {
+		fmt.Fprintf(&b, "Ran `%s` in %s.\n", c.command, elapsed)
+	} else {
+		fmt.Fprintf(&b, "Running `%s` for %s...\n", c.command, elapsed)
+	}
+	if len(c.projects) > 0 {
+		b.WriteString("\n")
+	}
+	for _, p := range c.projects {
+		fmt.Fprintf(&b, "* %s: `%s`\n", p, c.stages[p])
+	}
+	return b.String()
+}
+
+func (c *Comment) update() {
+	if err := c.edit(c.Body()); err != nil {
+		c.log.Warn("unable to update progress comment: %s", err)
+	}
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,47 @@
+package progress_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/progress"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestComment(t *testing.T) {
+	projects := []string{"dir: `a` workspace: `default`", "dir: `b` workspace: `default`"}
+	c := progress.New("apply", projects)
+	Equals(t, "Running `apply` for 0s...\n\n* dir: `a` workspace: `default`: `waiting`\n* dir: `b` workspace: `default`: `waiting`\n", c.Body())
+
+	var mutex sync.Mutex
+	var bodies []string
+	c.Start(10*time.Millisecond, func(body string) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		bodies = append(bodies, body)
+		return nil
+	}, logging.NewNoopLogger(t))
+	c.SetStage(projects[0], "init")
+	c.SetStage("dir: `other` workspace: `default`", "plan")
+	time.Sleep(50 * time.Millisecond)
+	c.SetStage(projects[0], progress.DoneStage)
+	c.Stop()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	Assert(t, len(bodies) > 1, "expected the comment to be edited while running, got %d edits", len(bodies))
+	Assert(t, strings.Contains(bodies[0], "* dir: `a` workspace: `default`: `init`\n"), "got %q", bodies[0])
+	Assert(t, !strings.Contains(bodies[0], "other"), "got %q", bodies[0])
+	Equals(t, "Ran `apply` in 0s.\n\n* dir: `a` workspace: `default`: `done`\n* dir: `b` workspace: `default`: `waiting`\n", bodies[len(bodies)-1])
+}
+
+func TestComment_Nil(t *testing.T) {
+	var c *progress.Comment
+	c.Start(time.Millisecond, nil, nil)
+	c.SetStage("dir: `a` workspace: `default`", "plan")
+	c.Stop()
+	Equals(t, "", c.Body())
+}
@@ -2,14 +2,21 @@ package events
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/runatlantis/atlantis/server/events/yaml/valid"
 
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
 	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/planstore"
+	"github.com/runatlantis/atlantis/server/events/runtime"
 	"github.com/runatlantis/atlantis/server/events/vcs"
 	"github.com/runatlantis/atlantis/server/events/yaml"
+	"github.com/runatlantis/atlantis/server/events/yaml/raw"
 )
 
 const (
@@ -36,7 +43,7 @@ func NewProjectCommandBuilder(
 	vcsClient vcs.Client,
 	workingDir WorkingDir,
 	workingDirLocker WorkingDirLocker,
-	globalCfg valid.GlobalCfg,
+	globalCfg *valid.GlobalCfgStore,
 	pendingPlanFinder *DefaultPendingPlanFinder,
 	commentBuilder CommentBuilder,
 	skipCloneNoChanges bool,
@@ -103,12 +110,25 @@ type DefaultProjectCommandBuilder struct {
 	VCSClient                    vcs.Client
 	WorkingDir                   WorkingDir
 	WorkingDirLocker             WorkingDirLocker
-	GlobalCfg                    valid.GlobalCfg
+	GlobalCfg                    *valid.GlobalCfgStore
 	PendingPlanFinder            *DefaultPendingPlanFinder
 	ProjectCommandContextBuilder ProjectCommandContextBuilder
 	SkipCloneNoChanges           bool
 	EnableRegExpCmd              bool
 	AutoplanFileList             string
+	// PlanStore stores the plans generated by every Atlantis server. If set,
+	// plans that aren't on disk are restored from it. If nil, only the plans
+	// on disk can be applied.
+	PlanStore *planstore.Store
+	// KeepUnchangedPlans is true if autoplan should keep the plans of the
+	// projects that the pull request's new commits didn't modify instead of
+	// planning them again. It requires the WorkingDir to keep the previous
+	// clones of pull requests.
+	KeepUnchangedPlans bool
+	// RepoAllowlistChecker drops the projects in dirs that their repo isn't
+	// allowlisted for when it's only allowlisted for some paths. If nil,
+	// projects aren't checked.
+	RepoAllowlistChecker *RepoAllowlistChecker
 }
 
 // See ProjectCommandBuilder.BuildAutoplanCommands.
@@ -117,6 +137,10 @@ func (p *DefaultProjectCommandBuilder) BuildAutoplanCommands(ctx *CommandContext
 	if err != nil {
 		return nil, err
 	}
+	projCtxs, err = p.allowlistedCommands(ctx, projCtxs, false)
+	if err != nil {
+		return nil, err
+	}
 	var autoplanEnabled []models.ProjectCommandContext
 	for _, projCtx := range projCtxs {
 		if !projCtx.AutoplanEnabled {
@@ -125,34 +149,82 @@ func (p *DefaultProjectCommandBuilder) BuildAutoplanCommands(ctx *CommandContext
 		}
 		autoplanEnabled = append(autoplanEnabled, projCtx)
 	}
+	if p.KeepUnchangedPlans {
+		if err := p.keepUnchangedPlans(ctx, autoplanEnabled); err != nil {
+			return nil, err
+		}
+	}
 	return autoplanEnabled, nil
 }
 
 // See ProjectCommandBuilder.BuildPlanCommands.
 func (p *DefaultProjectCommandBuilder) BuildPlanCommands(ctx *CommandContext, cmd *CommentCommand) ([]models.ProjectCommandContext, error) {
+	var pcc []models.ProjectCommandContext
+	var err error
 	if !cmd.IsForSpecificProject() {
-		return p.buildPlanAllCommands(ctx, cmd.Flags, cmd.Verbose)
+		pcc, err = p.buildPlanAllCommands(ctx, cmd.Flags, cmd.Verbose)
+	} else {
+		pcc, err = p.buildProjectPlanCommand(ctx, cmd)
+	}
+	if err != nil {
+		return pcc, err
 	}
-	pcc, err := p.buildProjectPlanCommand(ctx, cmd)
-	return pcc, err
+	return p.allowlistedCommands(ctx, pcc, cmd.IsForSpecificProject())
 }
 
 // See ProjectCommandBuilder.BuildApplyCommands.
 func (p *DefaultProjectCommandBuilder) BuildApplyCommands(ctx *CommandContext, cmd *CommentCommand) ([]models.ProjectCommandContext, error) {
+	var pac []models.ProjectCommandContext
+	var err error
 	if !cmd.IsForSpecificProject() {
-		return p.buildAllProjectCommands(ctx, cmd)
+		pac, err = p.buildAllProjectCommands(ctx, cmd)
+	} else {
+		pac, err = p.buildProjectApplyCommand(ctx, cmd)
 	}
-	pac, err := p.buildProjectApplyCommand(ctx, cmd)
-	return pac, err
+	if err != nil {
+		return pac, err
+	}
+	pac, err = p.allowlistedCommands(ctx, pac, cmd.IsForSpecificProject())
+	if err != nil {
+		return nil, err
+	}
+	return pac, p.findUnplannedProjects(ctx, pac)
 }
 
 func (p *DefaultProjectCommandBuilder) BuildApprovePoliciesCommands(ctx *CommandContext, cmd *CommentCommand) ([]models.ProjectCommandContext, error) {
-	return p.buildAllProjectCommands(ctx, cmd)
+	pac, err := p.buildAllProjectCommands(ctx, cmd)
+	if err != nil {
+		return pac, err
+	}
+	return p.allowlistedCommands(ctx, pac, false)
+}
+
+// allowlistedCommands returns the commands of projCtxs whose dirs the repo is
+// allowlisted for. If specific is true, projCtxs are for the project named in
+// a comment so an error is returned instead of ignoring it.
+func (p *DefaultProjectCommandBuilder) allowlistedCommands(ctx *CommandContext, projCtxs []models.ProjectCommandContext, specific bool) ([]models.ProjectCommandContext, error) {
+	if p.RepoAllowlistChecker == nil {
+		return projCtxs, nil
+	}
+	repo := ctx.Pull.BaseRepo
+	var allowlisted []models.ProjectCommandContext
+	for _, projCtx := range projCtxs {
+		if p.RepoAllowlistChecker.IsDirAllowlisted(repo.FullName, repo.VCSHost.Hostname, projCtx.RepoRelDir) {
+			allowlisted = append(allowlisted, projCtx)
+			continue
+		}
+		if specific {
+			return nil, fmt.Errorf("dir %q isn't allowlisted for this repo", projCtx.RepoRelDir)
+		}
+		ctx.Log.Info("ignoring project at dir %q, workspace: %q because the dir isn't allowlisted", projCtx.RepoRelDir, projCtx.Workspace)
+	}
+	return allowlisted, nil
 }
 
 // buildPlanAllCommands builds plan contexts for all projects we determine were
 // modified in this ctx.
 func (p *DefaultProjectCommandBuilder) buildPlanAllCommands(ctx *CommandContext, commentFlags []string, verbose bool) ([]models.ProjectCommandContext, error) {
+	globalCfg := p.GlobalCfg.Get()
 	// We'll need the list of modified files.
 	modifiedFiles, err := p.VCSClient.GetModifiedFiles(ctx.Pull.BaseRepo, ctx.Pull)
 	if err != nil {
@@ -167,7 +239,7 @@ func (p *DefaultProjectCommandBuilder) buildPlanAllCommands(ctx *CommandContext,
 		}
 
 		if hasRepoCfg {
-			repoCfg, err := p.ParserValidator.ParseRepoCfgData(repoCfgData, p.GlobalCfg, ctx.Pull.BaseRepo.ID())
+			repoCfg, err := p.ParserValidator.ParseRepoCfgData(repoCfgData, globalCfg, ctx.Pull.BaseRepo.ID())
 			if err != nil {
 				return nil, errors.Wrapf(err, "parsing %s", yaml.AtlantisYAMLFilename)
 			}
@@ -214,11 +286,24 @@ func (p *DefaultProjectCommandBuilder) buildPlanAllCommands(ctx *CommandContext,
 	if hasRepoCfg {
 		// If there's a repo cfg then we'll use it to figure out which projects
 		// should be planed.
-		repoCfg, err := p.ParserValidator.ParseRepoCfg(repoDir, p.GlobalCfg, ctx.Pull.BaseRepo.ID())
+		repoCfg, err := p.ParserValidator.ParseRepoCfg(repoDir, globalCfg, ctx.Pull.BaseRepo.ID())
 		if err != nil {
 			return nil, errors.Wrapf(err, "parsing %s", yaml.AtlantisYAMLFilename)
 		}
 		ctx.Log.Info("successfully parsed %s file", yaml.AtlantisYAMLFilename)
+		// The projects must be checked out before we can determine which of
+		// them exist.
+		modifiedProjects, err := p.ProjectFinder.DetermineProjectsViaConfig(ctx.Log, modifiedFiles, repoCfg, "")
+		if err != nil {
+			return nil, err
+		}
+		var dirs []string
+		for _, mp := range modifiedProjects {
+			dirs = append(dirs, projectCheckoutDirs(mp)...)
+		}
+		if err := p.WorkingDir.CheckoutDirs(ctx.Log, ctx.Pull, workspace, dirs); err != nil {
+			return nil, err
+		}
 		matchingProjects, err := p.ProjectFinder.DetermineProjectsViaConfig(ctx.Log, modifiedFiles, repoCfg, repoDir)
 		if err != nil {
 			return nil, err
@@ -227,7 +312,7 @@ func (p *DefaultProjectCommandBuilder) buildPlanAllCommands(ctx *CommandContext,
 
 		for _, mp := range matchingProjects {
 			ctx.Log.Debug("determining config for project at dir: %q workspace: %q", mp.Dir, mp.Workspace)
-			mergedCfg := p.GlobalCfg.MergeProjectCfg(ctx.Log, ctx.Pull.BaseRepo.ID(), mp, repoCfg)
+			mergedCfg := globalCfg.MergeProjectCfg(ctx.Log, ctx.Pull.BaseRepo.ID(), mp, repoCfg)
 
 			projCtxs = append(projCtxs,
 				p.ProjectCommandContextBuilder.BuildProjectContext(
@@ -247,14 +332,28 @@ func (p *DefaultProjectCommandBuilder) buildPlanAllCommands(ctx *CommandContext,
 		// If there is no config file, then we'll plan each project that
 		// our algorithm determines was modified.
 		ctx.Log.Info("found no %s file", yaml.AtlantisYAMLFilename)
+		var dirs []string
+		for _, f := range modifiedFiles {
+			dirs = append(dirs, filepath.Dir(f))
+		}
+		if err := p.WorkingDir.CheckoutDirs(ctx.Log, ctx.Pull, workspace, dirs); err != nil {
+			return nil, err
+		}
 		modifiedProjects := p.ProjectFinder.DetermineProjects(ctx.Log, modifiedFiles, ctx.Pull.BaseRepo.FullName, repoDir, p.AutoplanFileList)
 		if err != nil {
 			return nil, errors.Wrapf(err, "finding modified projects: %s", modifiedFiles)
 		}
+		dirs = nil
+		for _, mp := range modifiedProjects {
+			dirs = append(dirs, mp.Path)
+		}
+		if err := p.WorkingDir.CheckoutDirs(ctx.Log, ctx.Pull, workspace, dirs); err != nil {
+			return nil, err
+		}
 		ctx.Log.Info("automatically determined that there were %d projects modified in this pull request: %s", len(modifiedProjects), modifiedProjects)
 		for _, mp := range modifiedProjects {
 			ctx.Log.Debug("determining config for project at dir: %q", mp.Path)
-			pCfg := p.GlobalCfg.DefaultProjCfg(ctx.Log, ctx.Pull.BaseRepo.ID(), mp.Path, DefaultWorkspace)
+			pCfg := globalCfg.DefaultProjCfg(ctx.Log, ctx.Pull.BaseRepo.ID(), mp.Path, DefaultWorkspace)
 
 			projCtxs = append(projCtxs,
 				p.ProjectCommandContextBuilder.BuildProjectContext(
@@ -317,6 +416,7 @@ func (p *DefaultProjectCommandBuilder) buildProjectPlanCommand(ctx *CommandConte
 // getCfg returns the atlantis.yaml config (if it exists) for this project. If
 // there is no config, then projectCfg and repoCfg will be nil.
 func (p *DefaultProjectCommandBuilder) getCfg(ctx *CommandContext, projectName string, dir string, workspace string, repoDir string) (projectsCfg []valid.Project, repoCfg *valid.RepoCfg, err error) {
+	globalCfg := p.GlobalCfg.Get()
 	hasConfigFile, err := p.ParserValidator.HasRepoCfg(repoDir)
 	if err != nil {
 		err = errors.Wrapf(err, "looking for %s file in %q", yaml.AtlantisYAMLFilename, repoDir)
@@ -331,7 +431,7 @@ func (p *DefaultProjectCommandBuilder) getCfg(ctx *CommandContext, projectName s
 	}
 
 	var repoConfig valid.RepoCfg
-	repoConfig, err = p.ParserValidator.ParseRepoCfg(repoDir, p.GlobalCfg, ctx.Pull.BaseRepo.ID())
+	repoConfig, err = p.ParserValidator.ParseRepoCfg(repoDir, globalCfg, ctx.Pull.BaseRepo.ID())
 	if err != nil {
 		return
 	}
@@ -377,6 +477,10 @@ func (p *DefaultProjectCommandBuilder) buildAllProjectCommands(ctx *CommandConte
 	}
 	defer unlockFn()
 
+	if err := p.restorePlans(ctx, ""); err != nil {
+		return nil, err
+	}
+
 	pullDir, err := p.WorkingDir.GetPullDir(ctx.Pull.BaseRepo, ctx.Pull)
 	if err != nil {
 		return nil, err
@@ -413,6 +517,10 @@ func (p *DefaultProjectCommandBuilder) buildProjectApplyCommand(ctx *CommandCont
 	}
 	defer unlockFn()
 
+	if err := p.restorePlans(ctx, workspace); err != nil {
+		return projCtx, err
+	}
+
 	repoDir, err := p.WorkingDir.GetWorkingDir(ctx.Pull.BaseRepo, ctx.Pull, workspace)
 	if os.IsNotExist(errors.Cause(err)) {
 		return projCtx, errors.New("no working directory found–did you run plan?")
@@ -437,6 +545,299 @@ func (p *DefaultProjectCommandBuilder) buildProjectApplyCommand(ctx *CommandCont
 	)
 }
 
+// findUnplannedProjects sets the UnplannedProjects of the contexts in projCtxs
+// with the all_plans_succeeded apply requirement. The projects modified in
+// the pull request are found like for autoplan, which clones the repo if it
+// isn't already.
+func (p *DefaultProjectCommandBuilder) findUnplannedProjects(ctx *CommandContext, projCtxs []models.ProjectCommandContext) error {
+	var required []int
+	for i, projCtx := range projCtxs {
+		for _, req := range projCtx.ApplyRequirements {
+			if req == raw.AllPlansSucceededRequirement {
+				required = append(required, i)
+				break
+			}
+		}
+	}
+	if len(required) == 0 {
+		return nil
+	}
+
+	modified, err := p.buildPlanAllCommands(ctx, nil, false)
+	if err != nil {
+		return errors.Wrap(err, "finding modified projects")
+	}
+	var unplanned []string
+	for _, projCtx := range modified {
+		// Policy checks are built alongside the plans.
+		if projCtx.CommandName != models.PlanCommand {
+			continue
+		}
+		if !planSucceeded(ctx, projCtx) {
+			unplanned = append(unplanned, projectDescription(projCtx))
+		}
+	}
+	for _, i := range required {
+		projCtxs[i].UnplannedProjects = unplanned
+	}
+	return nil
+}
+
+// planSucceeded returns true if the last plan of the project of projCtx at the
+// pull request's head commit succeeded.
+func planSucceeded(ctx *CommandContext, projCtx models.ProjectCommandContext) bool {
+	if ctx.PullStatus == nil || ctx.PullStatus.Pull.HeadCommit != ctx.Pull.HeadCommit {
+		return false
+	}
+	status := projectStatus(ctx.PullStatus, projCtx)
+	if status == nil {
+		return false
+	}
+	switch status.Status {
+	case models.ErroredPlanStatus, models.DiscardedPlanStatus:
+		return false
+	}
+	return true
+}
+
+// restorePlans downloads the plans stored for the pull request's head commit
+// that aren't on disk, ex. because they were generated by another Atlantis
+// server or before a restart. Their workspaces are cloned first if needed.
+// If workspace is set, only the plans in that workspace are restored. The
+// caller must hold the working dir lock.
+func (p *DefaultProjectCommandBuilder) restorePlans(ctx *CommandContext, workspace string) error {
+	if p.PlanStore == nil {
+		return nil
+	}
+	plans, err := p.PlanStore.List(ctx.Pull.BaseRepo.FullName, ctx.Pull.Num)
+	if err != nil {
+		return errors.Wrap(err, "listing stored plans")
+	}
+	for _, plan := range plans {
+		if workspace != "" && plan.Workspace != workspace {
+			continue
+		}
+		// Plans for previous commits are stale.
+		if plan.HeadCommit != ctx.Pull.HeadCommit {
+			continue
+		}
+		// Clone is a no-op if the repo is already cloned at the head commit.
+		repoDir, _, err := p.WorkingDir.Clone(ctx.Log, ctx.HeadRepo, ctx.Pull, plan.Workspace)
+		if err != nil {
+			return err
+		}
+		if err := p.WorkingDir.CheckoutDirs(ctx.Log, ctx.Pull, plan.Workspace, []string{plan.RepoRelDir}); err != nil {
+			return err
+		}
+		if _, err := os.Stat(filepath.Join(repoDir, plan.RepoRelDir, plan.PlanFile)); err == nil {
+			continue
+		}
+		ctx.Log.Info("restoring plan for dir %q workspace %q generated by %s at %s", plan.RepoRelDir, plan.Workspace, plan.User, plan.CreatedAt)
+		if err := p.PlanStore.Restore(plan, repoDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keepUnchangedPlans keeps the plans of the projects in projCtxs that were
+// planned at the pull request's previous head commit and that none of the
+// files changed since modify. Their plans are copied from the clones of the
+// previous commit and KeptPlan is set so they aren't planned again.
+func (p *DefaultProjectCommandBuilder) keepUnchangedPlans(ctx *CommandContext, projCtxs []models.ProjectCommandContext) error {
+	// The status is written by the previous autoplan so it's for the
+	// previous commit until this one completes.
+	if ctx.PullStatus == nil || ctx.PullStatus.Pull.HeadCommit == ctx.Pull.HeadCommit {
+		return nil
+	}
+	workspaces := make(map[string]bool)
+	for _, projCtx := range projCtxs {
+		workspaces[projCtx.Workspace] = true
+	}
+	for workspace := range workspaces {
+		if err := p.keepUnchangedPlansInWorkspace(ctx, projCtxs, workspace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *DefaultProjectCommandBuilder) keepUnchangedPlansInWorkspace(ctx *CommandContext, projCtxs []models.ProjectCommandContext, workspace string) error {
+	unlockFn, err := p.WorkingDirLocker.TryLock(ctx.Pull.BaseRepo.FullName, ctx.Pull.Num, workspace)
+	if err != nil {
+		return err
+	}
+	defer unlockFn()
+
+	// Cloning the workspace at the new commit keeps its previous clone. It's
+	// a no-op if it's already cloned.
+	repoDir, _, err := p.WorkingDir.Clone(ctx.Log, ctx.HeadRepo, ctx.Pull, workspace)
+	if err != nil {
+		return err
+	}
+	prev, err := p.WorkingDir.GetPreviousClone(ctx.Log, ctx.Pull, workspace)
+	if err != nil {
+		return errors.Wrap(err, "comparing with previous commit")
+	}
+	if prev == nil {
+		return nil
+	}
+	defer func() {
+		if err := p.WorkingDir.DeletePreviousClone(ctx.Pull, workspace); err != nil {
+			ctx.Log.Warn("unable to delete clone of previous commit: %s", err)
+		}
+	}()
+	if !strings.HasPrefix(prev.HeadCommit, ctx.PullStatus.Pull.HeadCommit) {
+		ctx.Log.Debug("not keeping plans of commit %q since the last plans were for commit %q", prev.HeadCommit, ctx.PullStatus.Pull.HeadCommit)
+		return nil
+	}
+
+	modified, err := p.modifiedProjects(ctx, repoDir, prev.ChangedFiles)
+	if err != nil {
+		return err
+	}
+	// With policy checks, projects have a plan and a policy check command.
+	kept := make(map[string]*models.ProjectStatus)
+	for i := range projCtxs {
+		projCtx := &projCtxs[i]
+		key := projectKey(projCtx.RepoRelDir, projCtx.Workspace, projCtx.ProjectName)
+		if status, ok := kept[key]; ok {
+			projCtx.KeptPlan = status
+			continue
+		}
+		if projCtx.Workspace != workspace || modified(*projCtx) {
+			continue
+		}
+		status := projectStatus(ctx.PullStatus, *projCtx)
+		if status == nil || (status.Status != models.PlannedPlanStatus && status.Status != models.PassedPolicyCheckStatus) {
+			continue
+		}
+		planFile := filepath.Join(projCtx.RepoRelDir, runtime.GetPlanFilename(projCtx.Workspace, projCtx.ProjectName))
+		planPath := filepath.Join(repoDir, planFile)
+		if _, err := os.Stat(planPath); err == nil {
+			// It was planned at this commit already.
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(prev.Dir, planFile)) // nolint: gosec
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return errors.Wrap(err, "reading plan of previous commit")
+		}
+		if err := ioutil.WriteFile(planPath, data, 0600); err != nil {
+			return errors.Wrap(err, "copying plan of previous commit")
+		}
+		if p.PlanStore != nil {
+			if err := p.PlanStore.Save(*projCtx, planPath); err != nil {
+				return errors.Wrap(err, "saving plan to plan store")
+			}
+		}
+		ctx.Log.Info("keeping plan of commit %q for dir %q workspace %q since it wasn't modified", prev.HeadCommit, projCtx.RepoRelDir, projCtx.Workspace)
+		projCtx.KeptPlan = status
+		kept[key] = status
+	}
+	return nil
+}
+
+// modifiedProjects returns a function reporting whether changedFiles modify
+// a project of the repo in repoDir. Projects are modified by the files that
+// would trigger their autoplan and by the files in their dir and in the dirs
+// of the local modules they call. Every project is modified if the repo's
+// config changed.
+func (p *DefaultProjectCommandBuilder) modifiedProjects(ctx *CommandContext, repoDir string, changedFiles []string) (func(models.ProjectCommandContext) bool, error) {
+	all := func(models.ProjectCommandContext) bool { return true }
+	modifiedKeys := make(map[string]bool)
+	hasRepoCfg, err := p.ParserValidator.HasRepoCfg(repoDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "looking for %s file in %q", yaml.AtlantisYAMLFilename, repoDir)
+	}
+	if hasRepoCfg {
+		for _, f := range changedFiles {
+			if f == yaml.AtlantisYAMLFilename {
+				return all, nil
+			}
+		}
+		repoCfg, err := p.ParserValidator.ParseRepoCfg(repoDir, p.GlobalCfg.Get(), ctx.Pull.BaseRepo.ID())
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing %s", yaml.AtlantisYAMLFilename)
+		}
+		projects, err := p.ProjectFinder.DetermineProjectsViaConfig(ctx.Log, changedFiles, repoCfg, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, project := range projects {
+			modifiedKeys[projectKey(project.Dir, project.Workspace, project.GetName())] = true
+		}
+	} else {
+		for _, project := range p.ProjectFinder.DetermineProjects(ctx.Log, changedFiles, ctx.Pull.BaseRepo.FullName, repoDir, p.AutoplanFileList) {
+			modifiedKeys[projectKey(project.Path, DefaultWorkspace, "")] = true
+		}
+	}
+
+	return func(projCtx models.ProjectCommandContext) bool {
+		if modifiedKeys[projectKey(projCtx.RepoRelDir, projCtx.Workspace, projCtx.ProjectName)] {
+			return true
+		}
+		for _, dir := range append(localModuleDirs(repoDir, projCtx.RepoRelDir), projCtx.RepoRelDir) {
+			for _, f := range changedFiles {
+				// Projects at the root of the repo only contain its files
+				// since its dirs are other projects or modules.
+				if (dir == "." && !strings.Contains(f, "/")) || strings.HasPrefix(f, dir+"/") {
+					return true
+				}
+			}
+		}
+		return false
+	}, nil
+}
+
+// localModuleDirs returns the dirs, relative to the root of the repo in
+// repoDir, of the local modules called by the project in repoRelDir and by
+// the modules it calls. Modules outside of the repo are ignored.
+func localModuleDirs(repoDir string, repoRelDir string) []string {
+	var dirs []string
+	seen := map[string]bool{filepath.Clean(repoRelDir): true}
+	queue := []string{filepath.Clean(repoRelDir)}
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+		module, _ := tfconfig.LoadModule(filepath.Join(repoDir, dir))
+		if module == nil {
+			continue
+		}
+		for _, call := range module.ModuleCalls {
+			if !strings.HasPrefix(call.Source, "./") && !strings.HasPrefix(call.Source, "../") {
+				continue
+			}
+			modDir := filepath.Join(dir, call.Source)
+			if modDir == ".." || strings.HasPrefix(modDir, "../") || seen[modDir] {
+				continue
+			}
+			seen[modDir] = true
+			dirs = append(dirs, filepath.ToSlash(modDir))
+			queue = append(queue, modDir)
+		}
+	}
+	return dirs
+}
+
+// projectKey identifies a project of a repo.
+func projectKey(repoRelDir string, workspace string, projectName string) string {
+	return fmt.Sprintf("%s|%s|%s", filepath.Clean(repoRelDir), workspace, projectName)
+}
+
+// projectStatus returns the status of the project of projCtx in pullStatus
+// or nil if it has none.
+func projectStatus(pullStatus *models.PullStatus, projCtx models.ProjectCommandContext) *models.ProjectStatus {
+	for i, status := range pullStatus.Projects {
+		if status.RepoRelDir == projCtx.RepoRelDir && status.Workspace == projCtx.Workspace && status.ProjectName == projCtx.ProjectName {
+			return &pullStatus.Projects[i]
+		}
+	}
+	return nil
+}
+
 // buildProjectCommandCtx builds a context for a single or several projects identified
 // by the parameters.
 func (p *DefaultProjectCommandBuilder) buildProjectCommandCtx(ctx *CommandContext,
@@ -448,10 +849,21 @@ func (p *DefaultProjectCommandBuilder) buildProjectCommandCtx(ctx *CommandContex
 	workspace string,
 	verbose bool) ([]models.ProjectCommandContext, error) {
 
+	globalCfg := p.GlobalCfg.Get()
 	matchingProjects, repoCfgPtr, err := p.getCfg(ctx, projectName, repoRelDir, workspace, repoDir)
 	if err != nil {
 		return []models.ProjectCommandContext{}, err
 	}
+	var dirs []string
+	for _, mp := range matchingProjects {
+		dirs = append(dirs, projectCheckoutDirs(mp)...)
+	}
+	if len(matchingProjects) == 0 {
+		dirs = []string{repoRelDir}
+	}
+	if err := p.WorkingDir.CheckoutDirs(ctx.Log, ctx.Pull, workspace, dirs); err != nil {
+		return []models.ProjectCommandContext{}, err
+	}
 	var projCtxs []models.ProjectCommandContext
 	var projCfg valid.MergedProjectCfg
 	automerge := DefaultAutomergeEnabled
@@ -471,7 +883,7 @@ func (p *DefaultProjectCommandBuilder) buildProjectCommandCtx(ctx *CommandContex
 		workspace = projCfg.Workspace
 		for _, mp := range matchingProjects {
 			ctx.Log.Debug("Merging config for project at dir: %q workspace: %q", mp.Dir, mp.Workspace)
-			projCfg = p.GlobalCfg.MergeProjectCfg(ctx.Log, ctx.Pull.BaseRepo.ID(), mp, *repoCfgPtr)
+			projCfg = globalCfg.MergeProjectCfg(ctx.Log, ctx.Pull.BaseRepo.ID(), mp, *repoCfgPtr)
 
 			projCtxs = append(projCtxs,
 				p.ProjectCommandContextBuilder.BuildProjectContext(
@@ -488,7 +900,7 @@ func (p *DefaultProjectCommandBuilder) buildProjectCommandCtx(ctx *CommandContex
 				)...)
 		}
 	} else {
-		projCfg = p.GlobalCfg.DefaultProjCfg(ctx.Log, ctx.Pull.BaseRepo.ID(), repoRelDir, workspace)
+		projCfg = globalCfg.DefaultProjCfg(ctx.Log, ctx.Pull.BaseRepo.ID(), repoRelDir, workspace)
 		projCtxs = append(projCtxs,
 			p.ProjectCommandContextBuilder.BuildProjectContext(
 				ctx,
@@ -523,3 +935,29 @@ func (p *DefaultProjectCommandBuilder) validateWorkspaceAllowed(repoCfg *valid.R
 
 	return repoCfg.ValidateWorkspaceAllowed(repoRelDir, workspace)
 }
+
+// projectCheckoutDirs returns the dirs that must be checked out to run
+// commands for project when the repo is cloned with sparse checkout: its dir
+// and the dirs outside of it that its when_modified patterns refer to, ex.
+// ../modules for ../modules/**/*.tf.
+func projectCheckoutDirs(project valid.Project) []string {
+	dirs := []string{project.Dir}
+	for _, pattern := range project.Autoplan.WhenModified {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" || strings.HasPrefix(pattern, "!") {
+			continue
+		}
+		// Only the part of the pattern before its first wildcard is a dir.
+		// If it has none, it's a file.
+		path := filepath.ToSlash(filepath.Join(project.Dir, pattern))
+		dir := filepath.Dir(path)
+		if i := strings.IndexAny(path, "*?["); i >= 0 {
+			dir = filepath.Dir(path[:i] + "x")
+		}
+		if dir == ".." || strings.HasPrefix(dir, "../") {
+			continue
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
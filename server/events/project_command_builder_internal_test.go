@@ -593,7 +593,7 @@ projects:
 				vcsClient,
 				workingDir,
 				NewDefaultWorkingDirLocker(),
-				globalCfg,
+				valid.NewGlobalCfgStore(globalCfg),
 				&DefaultPendingPlanFinder{},
 				&CommentParser{},
 				false,
@@ -780,7 +780,7 @@ projects:
 				vcsClient,
 				workingDir,
 				NewDefaultWorkingDirLocker(),
-				globalCfg,
+				valid.NewGlobalCfgStore(globalCfg),
 				&DefaultPendingPlanFinder{},
 				&CommentParser{},
 				false,
@@ -993,7 +993,7 @@ workflows:
 				vcsClient,
 				workingDir,
 				NewDefaultWorkingDirLocker(),
-				globalCfg,
+				valid.NewGlobalCfgStore(globalCfg),
 				&DefaultPendingPlanFinder{},
 				&CommentParser{},
 				false,
@@ -12,6 +12,7 @@ import (
 	"github.com/runatlantis/atlantis/server/events/matchers"
 	"github.com/runatlantis/atlantis/server/events/mocks"
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/planstore"
 	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
 	"github.com/runatlantis/atlantis/server/events/yaml"
 	"github.com/runatlantis/atlantis/server/events/yaml/valid"
@@ -150,7 +151,7 @@ projects:
 				vcsClient,
 				workingDir,
 				events.NewDefaultWorkingDirLocker(),
-				valid.NewGlobalCfgFromArgs(globalCfgArgs),
+				valid.NewGlobalCfgStore(valid.NewGlobalCfgFromArgs(globalCfgArgs)),
 				&events.DefaultPendingPlanFinder{},
 				&events.CommentParser{},
 				false,
@@ -412,7 +413,7 @@ projects:
 					vcsClient,
 					workingDir,
 					events.NewDefaultWorkingDirLocker(),
-					valid.NewGlobalCfgFromArgs(globalCfgArgs),
+					valid.NewGlobalCfgStore(valid.NewGlobalCfgFromArgs(globalCfgArgs)),
 					&events.DefaultPendingPlanFinder{},
 					&events.CommentParser{},
 					false,
@@ -563,7 +564,7 @@ projects:
 				vcsClient,
 				workingDir,
 				events.NewDefaultWorkingDirLocker(),
-				valid.NewGlobalCfgFromArgs(globalCfgArgs),
+				valid.NewGlobalCfgStore(valid.NewGlobalCfgFromArgs(globalCfgArgs)),
 				&events.DefaultPendingPlanFinder{},
 				&events.CommentParser{},
 				false,
@@ -650,7 +651,7 @@ func TestDefaultProjectCommandBuilder_BuildMultiApply(t *testing.T) {
 		nil,
 		workingDir,
 		events.NewDefaultWorkingDirLocker(),
-		valid.NewGlobalCfgFromArgs(globalCfgArgs),
+		valid.NewGlobalCfgStore(valid.NewGlobalCfgFromArgs(globalCfgArgs)),
 		&events.DefaultPendingPlanFinder{},
 		&events.CommentParser{},
 		false,
@@ -682,6 +683,140 @@ func TestDefaultProjectCommandBuilder_BuildMultiApply(t *testing.T) {
 	Equals(t, "workspace2", ctxs[3].Workspace)
 }
 
+// Test that plans generated by another Atlantis server are restored from the
+// plan store before applying.
+func TestDefaultProjectCommandBuilder_BuildMultiApplyRestoresPlans(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmpDir, cleanup := DirStructure(t, map[string]interface{}{
+		"workspace1": map[string]interface{}{
+			"project1": map[string]interface{}{
+				"main.tf": nil,
+			},
+			"project2": map[string]interface{}{
+				"main.tf": nil,
+			},
+		},
+	})
+	defer cleanup()
+	runCmd(t, filepath.Join(tmpDir, "workspace1"), "git", "init")
+
+	storeDir, cleanupStore := TempDir(t)
+	defer cleanupStore()
+	planStore := planstore.New(&planstore.FileBackend{Dir: storeDir}, "")
+	pull := models.PullRequest{Num: 1, HeadCommit: "sha", BaseRepo: models.Repo{FullName: "owner/repo"}}
+	otherServerDir, cleanupOther := DirStructure(t, map[string]interface{}{
+		"project1": map[string]interface{}{"workspace1.tfplan": nil},
+		"project2": map[string]interface{}{"workspace1.tfplan": nil},
+	})
+	defer cleanupOther()
+	Ok(t, planStore.Save(models.ProjectCommandContext{Pull: pull, RepoRelDir: "project1", Workspace: "workspace1"},
+		filepath.Join(otherServerDir, "project1", "workspace1.tfplan")))
+	// Plans for previous commits aren't restored.
+	oldPull := pull
+	oldPull.HeadCommit = "old-sha"
+	Ok(t, planStore.Save(models.ProjectCommandContext{Pull: oldPull, RepoRelDir: "project2", Workspace: "workspace1"},
+		filepath.Join(otherServerDir, "project2", "workspace1.tfplan")))
+
+	workingDir := mocks.NewMockWorkingDir()
+	When(workingDir.Clone(matchers.AnyLoggingSimpleLogging(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), EqString("workspace1"))).
+		ThenReturn(filepath.Join(tmpDir, "workspace1"), false, nil)
+	When(workingDir.GetPullDir(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest())).
+		ThenReturn(tmpDir, nil)
+
+	builder := events.NewProjectCommandBuilder(
+		false,
+		&yaml.ParserValidator{},
+		&events.DefaultProjectFinder{},
+		nil,
+		workingDir,
+		events.NewDefaultWorkingDirLocker(),
+		valid.NewGlobalCfgStore(valid.NewGlobalCfgFromArgs(valid.GlobalCfgArgs{})),
+		&events.DefaultPendingPlanFinder{},
+		&events.CommentParser{},
+		false,
+		false,
+		"**/*.tf,**/*.tfvars,**/*.tfvars.json,**/terragrunt.hcl",
+	)
+	builder.PlanStore = planStore
+
+	ctxs, err := builder.BuildApplyCommands(
+		&events.CommandContext{
+			Log:  logging.NewNoopLogger(t),
+			Pull: pull,
+		},
+		&events.CommentCommand{Name: models.ApplyCommand})
+	Ok(t, err)
+	Equals(t, 1, len(ctxs))
+	Equals(t, "project1", ctxs[0].RepoRelDir)
+	Equals(t, "workspace1", ctxs[0].Workspace)
+}
+
+// Test that applies of projects with the all_plans_succeeded requirement are
+// told which modified projects don't have a successful plan.
+func TestDefaultProjectCommandBuilder_BuildApplyCommandsUnplannedProjects(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmpDir, cleanup := DirStructure(t, map[string]interface{}{
+		"default": map[string]interface{}{
+			"project1": map[string]interface{}{
+				"main.tf":        nil,
+				"default.tfplan": nil,
+			},
+			"project2": map[string]interface{}{
+				"main.tf": nil,
+			},
+			"project3": map[string]interface{}{
+				"main.tf": nil,
+			},
+		},
+	})
+	defer cleanup()
+	repoDir := filepath.Join(tmpDir, "default")
+	runCmd(t, repoDir, "git", "init")
+
+	pull := models.PullRequest{Num: 1, HeadCommit: "sha"}
+	workingDir := mocks.NewMockWorkingDir()
+	When(workingDir.Clone(matchers.AnyPtrToLoggingSimpleLogger(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString())).ThenReturn(repoDir, false, nil)
+	When(workingDir.GetWorkingDir(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString())).ThenReturn(repoDir, nil)
+	When(workingDir.GetPullDir(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest())).ThenReturn(tmpDir, nil)
+	vcsClient := vcsmocks.NewMockClient()
+	When(vcsClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest())).
+		ThenReturn([]string{"project1/main.tf", "project2/main.tf", "project3/main.tf"}, nil)
+
+	globalCfg := valid.NewGlobalCfgFromArgs(valid.GlobalCfgArgs{})
+	globalCfg.Repos[0].ApplyRequirements = []string{"all_plans_succeeded"}
+	builder := events.NewProjectCommandBuilder(
+		false,
+		&yaml.ParserValidator{},
+		&events.DefaultProjectFinder{},
+		vcsClient,
+		workingDir,
+		events.NewDefaultWorkingDirLocker(),
+		valid.NewGlobalCfgStore(globalCfg),
+		&events.DefaultPendingPlanFinder{},
+		&events.CommentParser{},
+		false,
+		false,
+		"**/*.tf,**/*.tfvars,**/*.tfvars.json,**/terragrunt.hcl",
+	)
+
+	ctxs, err := builder.BuildApplyCommands(
+		&events.CommandContext{
+			Log:  logging.NewNoopLogger(t),
+			Pull: pull,
+			PullStatus: &models.PullStatus{
+				Pull: pull,
+				Projects: []models.ProjectStatus{
+					{RepoRelDir: "project1", Workspace: "default", Status: models.PlannedPlanStatus},
+					{RepoRelDir: "project2", Workspace: "default", Status: models.ErroredPlanStatus},
+				},
+			},
+		},
+		&events.CommentCommand{Name: models.ApplyCommand, RepoRelDir: "project1"})
+	Ok(t, err)
+	Equals(t, 1, len(ctxs))
+	Equals(t, []string{"dir: `project2` workspace: `default`", "dir: `project3` workspace: `default`"}, ctxs[0].UnplannedProjects)
+}
+
 // Test that if a directory has a list of workspaces configured then we don't
 // allow plans for other workspace names.
 func TestDefaultProjectCommandBuilder_WrongWorkspaceName(t *testing.T) {
@@ -730,7 +865,7 @@ projects:
 		nil,
 		workingDir,
 		events.NewDefaultWorkingDirLocker(),
-		valid.NewGlobalCfgFromArgs(globalCfgArgs),
+		valid.NewGlobalCfgStore(valid.NewGlobalCfgFromArgs(globalCfgArgs)),
 		&events.DefaultPendingPlanFinder{},
 		&events.CommentParser{},
 		false,
@@ -805,7 +940,7 @@ func TestDefaultProjectCommandBuilder_EscapeArgs(t *testing.T) {
 				vcsClient,
 				workingDir,
 				events.NewDefaultWorkingDirLocker(),
-				valid.NewGlobalCfgFromArgs(globalCfgArgs),
+				valid.NewGlobalCfgStore(valid.NewGlobalCfgFromArgs(globalCfgArgs)),
 				&events.DefaultPendingPlanFinder{},
 				&events.CommentParser{},
 				false,
@@ -984,7 +1119,7 @@ projects:
 				vcsClient,
 				workingDir,
 				events.NewDefaultWorkingDirLocker(),
-				valid.NewGlobalCfgFromArgs(globalCfgArgs),
+				valid.NewGlobalCfgStore(valid.NewGlobalCfgFromArgs(globalCfgArgs)),
 				&events.DefaultPendingPlanFinder{},
 				&events.CommentParser{},
 				false,
@@ -1047,7 +1182,7 @@ projects:
 		vcsClient,
 		workingDir,
 		events.NewDefaultWorkingDirLocker(),
-		valid.NewGlobalCfgFromArgs(globalCfgArgs),
+		valid.NewGlobalCfgStore(valid.NewGlobalCfgFromArgs(globalCfgArgs)),
 		&events.DefaultPendingPlanFinder{},
 		&events.CommentParser{},
 		true,
@@ -1099,7 +1234,7 @@ func TestDefaultProjectCommandBuilder_WithPolicyCheckEnabled_BuildAutoplanComman
 		vcsClient,
 		workingDir,
 		events.NewDefaultWorkingDirLocker(),
-		globalCfg,
+		valid.NewGlobalCfgStore(globalCfg),
 		&events.DefaultPendingPlanFinder{},
 		&events.CommentParser{},
 		false,
@@ -1121,3 +1256,210 @@ func TestDefaultProjectCommandBuilder_WithPolicyCheckEnabled_BuildAutoplanComman
 	Equals(t, models.PolicyCheckCommand, policyCheckCtx.CommandName)
 	Equals(t, globalCfg.Workflows["default"].PolicyCheck.Steps, policyCheckCtx.Steps)
 }
+
+// Test that the dirs of the modified projects and the dirs outside of them
+// that their when_modified patterns refer to are checked out.
+func TestDefaultProjectCommandBuilder_BuildAutoplanCommandsChecksOutProjectDirs(t *testing.T) {
+	atlantisYAML := `
+version: 3
+projects:
+- dir: dir1
+  autoplan:
+    when_modified: ["*.tf", "../modules/**/*.tf", "!../modules/vpc/*.tf"]
+- dir: dir2
+`
+	RegisterMockTestingT(t)
+	tmpDir, cleanup := DirStructure(t, map[string]interface{}{
+		"dir1": map[string]interface{}{
+			"main.tf": nil,
+		},
+		"dir2": map[string]interface{}{
+			"main.tf": nil,
+		},
+	})
+	defer cleanup()
+	err := ioutil.WriteFile(filepath.Join(tmpDir, yaml.AtlantisYAMLFilename), []byte(atlantisYAML), 0600)
+	Ok(t, err)
+
+	logger := logging.NewNoopLogger(t)
+	workingDir := mocks.NewMockWorkingDir()
+	When(workingDir.Clone(matchers.AnyPtrToLoggingSimpleLogger(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString())).ThenReturn(tmpDir, false, nil)
+	vcsClient := vcsmocks.NewMockClient()
+	When(vcsClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest())).ThenReturn([]string{"modules/rds/main.tf"}, nil)
+
+	builder := events.NewProjectCommandBuilder(
+		false,
+		&yaml.ParserValidator{},
+		&events.DefaultProjectFinder{},
+		vcsClient,
+		workingDir,
+		events.NewDefaultWorkingDirLocker(),
+		valid.NewGlobalCfgStore(valid.NewGlobalCfgFromArgs(valid.GlobalCfgArgs{})),
+		&events.DefaultPendingPlanFinder{},
+		&events.CommentParser{},
+		false,
+		false,
+		"**/*.tf,**/*.tfvars,**/*.tfvars.json,**/terragrunt.hcl",
+	)
+
+	ctxs, err := builder.BuildAutoplanCommands(&events.CommandContext{
+		PullMergeable: true,
+		Log:           logger,
+	})
+	Ok(t, err)
+	Equals(t, 1, len(ctxs))
+	Equals(t, "dir1", ctxs[0].RepoRelDir)
+	workingDir.VerifyWasCalledOnce().CheckoutDirs(logger, models.PullRequest{}, "default", []string{"dir1", "dir1", "modules"})
+}
+
+// Test that autoplan keeps the plans of the projects that weren't modified
+// since the previous commit, including through the local modules they call.
+func TestDefaultProjectCommandBuilder_BuildAutoplanCommandsKeepsUnchangedPlans(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmpDir, cleanup := DirStructure(t, map[string]interface{}{
+		"dir1": map[string]interface{}{
+			"main.tf": nil,
+		},
+		"dir2": map[string]interface{}{
+			"main.tf": nil,
+		},
+		"dir3": map[string]interface{}{
+			"main.tf": nil,
+		},
+		"modules": map[string]interface{}{
+			"vpc": map[string]interface{}{
+				"main.tf": nil,
+			},
+		},
+	})
+	defer cleanup()
+	Ok(t, ioutil.WriteFile(filepath.Join(tmpDir, "dir1", "main.tf"), []byte(`module "vpc" { source = "../modules/vpc" }`), 0600))
+	prevDir, cleanup2 := DirStructure(t, map[string]interface{}{
+		"dir1": map[string]interface{}{
+			"default.tfplan": nil,
+		},
+		"dir2": map[string]interface{}{
+			"default.tfplan": nil,
+		},
+		"dir3": map[string]interface{}{
+			"default.tfplan": nil,
+		},
+	})
+	defer cleanup2()
+	Ok(t, ioutil.WriteFile(filepath.Join(prevDir, "dir2", "default.tfplan"), []byte("plan"), 0600))
+
+	logger := logging.NewNoopLogger(t)
+	pull := models.PullRequest{HeadCommit: "new"}
+	workingDir := mocks.NewMockWorkingDir()
+	When(workingDir.Clone(matchers.AnyPtrToLoggingSimpleLogger(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString())).ThenReturn(tmpDir, false, nil)
+	When(workingDir.GetPreviousClone(logger, pull, "default")).ThenReturn(&events.PreviousClone{
+		Dir:          prevDir,
+		HeadCommit:   "old",
+		ChangedFiles: []string{"dir3/main.tf", "modules/vpc/main.tf"},
+	}, nil)
+	vcsClient := vcsmocks.NewMockClient()
+	When(vcsClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest())).ThenReturn([]string{"dir1/main.tf", "dir2/main.tf", "dir3/main.tf"}, nil)
+
+	builder := events.NewProjectCommandBuilder(
+		false,
+		&yaml.ParserValidator{},
+		&events.DefaultProjectFinder{},
+		vcsClient,
+		workingDir,
+		events.NewDefaultWorkingDirLocker(),
+		valid.NewGlobalCfgStore(valid.NewGlobalCfgFromArgs(valid.GlobalCfgArgs{})),
+		&events.DefaultPendingPlanFinder{},
+		&events.CommentParser{},
+		false,
+		false,
+		"**/*.tf,**/*.tfvars,**/*.tfvars.json,**/terragrunt.hcl",
+	)
+	builder.KeepUnchangedPlans = true
+
+	pullStatus := &models.PullStatus{
+		Pull: models.PullRequest{HeadCommit: "old"},
+		Projects: []models.ProjectStatus{
+			{RepoRelDir: "dir1", Workspace: "default", Status: models.PlannedPlanStatus},
+			{RepoRelDir: "dir2", Workspace: "default", Status: models.PlannedPlanStatus, User: "user"},
+			{RepoRelDir: "dir3", Workspace: "default", Status: models.PlannedPlanStatus},
+		},
+	}
+	ctxs, err := builder.BuildAutoplanCommands(&events.CommandContext{
+		Pull:          pull,
+		PullMergeable: true,
+		PullStatus:    pullStatus,
+		Log:           logger,
+	})
+	Ok(t, err)
+	Equals(t, 3, len(ctxs))
+	kept := make(map[string]bool)
+	for _, ctx := range ctxs {
+		kept[ctx.RepoRelDir] = ctx.KeptPlan != nil
+	}
+	Equals(t, map[string]bool{"dir1": false, "dir2": true, "dir3": false}, kept)
+
+	// Only the kept plan is copied.
+	plan, err := ioutil.ReadFile(filepath.Join(tmpDir, "dir2", "default.tfplan"))
+	Ok(t, err)
+	Equals(t, "plan", string(plan))
+	_, err = ioutil.ReadFile(filepath.Join(tmpDir, "dir3", "default.tfplan"))
+	Assert(t, err != nil, "expected plan of modified project not to be copied")
+	workingDir.VerifyWasCalledOnce().DeletePreviousClone(pull, "default")
+}
+
+// Test that only the projects in the dirs that a repo is allowlisted for are
+// planned, whether they're autoplanned or named in comments.
+func TestDefaultProjectCommandBuilder_PathAllowlist(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmpDir, cleanup := DirStructure(t, map[string]interface{}{
+		"infra": map[string]interface{}{
+			"main.tf": nil,
+		},
+		"app": map[string]interface{}{
+			"main.tf": nil,
+		},
+	})
+	defer cleanup()
+
+	workingDir := mocks.NewMockWorkingDir()
+	When(workingDir.Clone(matchers.AnyPtrToLoggingSimpleLogger(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString())).ThenReturn(tmpDir, false, nil)
+	vcsClient := vcsmocks.NewMockClient()
+	When(vcsClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest())).ThenReturn([]string{"infra/main.tf", "app/main.tf"}, nil)
+
+	builder := events.NewProjectCommandBuilder(
+		false,
+		&yaml.ParserValidator{},
+		&events.DefaultProjectFinder{},
+		vcsClient,
+		workingDir,
+		events.NewDefaultWorkingDirLocker(),
+		valid.NewGlobalCfgStore(valid.NewGlobalCfgFromArgs(valid.GlobalCfgArgs{})),
+		&events.DefaultPendingPlanFinder{},
+		&events.CommentParser{},
+		false,
+		false,
+		"**/*.tf,**/*.tfvars,**/*.tfvars.json,**/terragrunt.hcl",
+	)
+	allowlist, err := events.NewRepoAllowlistChecker("github.com/owner/repo:infra/*")
+	Ok(t, err)
+	builder.RepoAllowlistChecker = allowlist
+
+	repo := models.Repo{FullName: "owner/repo", VCSHost: models.VCSHost{Hostname: "github.com", Type: models.Github}}
+	ctx := &events.CommandContext{
+		HeadRepo:      repo,
+		Pull:          models.PullRequest{BaseRepo: repo, Num: 1},
+		Log:           logging.NewNoopLogger(t),
+		PullMergeable: true,
+	}
+	ctxs, err := builder.BuildAutoplanCommands(ctx)
+	Ok(t, err)
+	Equals(t, 1, len(ctxs))
+	Equals(t, "infra", ctxs[0].RepoRelDir)
+
+	ctxs, err = builder.BuildPlanCommands(ctx, &events.CommentCommand{Name: models.PlanCommand, RepoRelDir: "infra"})
+	Ok(t, err)
+	Equals(t, 1, len(ctxs))
+
+	_, err = builder.BuildPlanCommands(ctx, &events.CommentCommand{Name: models.PlanCommand, RepoRelDir: "app"})
+	ErrEquals(t, `dir "app" isn't allowlisted for this repo`, err)
+}
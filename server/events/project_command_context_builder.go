@@ -179,21 +179,33 @@ func newProjectCommandContext(ctx *CommandContext,
 		DeleteSourceBranchOnMerge: deleteSourceBranchOnMerge,
 		ParallelApplyEnabled:      parallelApplyEnabled,
 		ParallelPlanEnabled:       parallelPlanEnabled,
-		AutoplanEnabled:           projCfg.AutoplanEnabled,
+		AutoplanEnabled:           projCfg.AutoplanEnabled && projCfg.AutoplanMatches(ctx.Pull.BaseBranch, ctx.Pull.HeadBranch),
 		Steps:                     steps,
+		ContainerImage:            projCfg.Workflow.ContainerImage,
 		HeadRepo:                  ctx.HeadRepo,
 		Log:                       ctx.Log,
 		PullMergeable:             ctx.PullMergeable,
+		AllowDestroy:              ctx.AllowDestroy,
+		UpgradeProviders:          ctx.UpgradeProviders,
 		ProjectPlanStatus:         projectPlanStatus,
 		Pull:                      ctx.Pull,
 		ProjectName:               projCfg.Name,
 		ApplyRequirements:         projCfg.ApplyRequirements,
+		Approvals:                 projCfg.Approvals,
+		Pipeline:                  projCfg.Pipeline,
+		Denylist:                  projCfg.Denylist,
+		VerifyLockfile:            projCfg.VerifyLockfile,
+		CloudCredentials:          projCfg.CloudCredentials,
+		DependsOn:                 projCfg.DependsOn,
+		Lint:                      projCfg.Lint,
 		RePlanCmd:                 planCmd,
 		RepoRelDir:                projCfg.RepoRelDir,
 		RepoConfigVersion:         projCfg.RepoCfgVersion,
 		TerraformVersion:          projCfg.TerraformVersion,
 		User:                      ctx.User,
 		Verbose:                   verbose,
+		Span:                      ctx.Span,
+		CorrelationID:             ctx.CorrelationID,
 		Workspace:                 projCfg.Workspace,
 		PolicySets:                policySets,
 	}
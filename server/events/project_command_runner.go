@@ -18,14 +18,22 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/codeowners"
+	"github.com/runatlantis/atlantis/server/events/credentials"
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/planstore"
+	"github.com/runatlantis/atlantis/server/events/progress"
 	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/vault"
 	"github.com/runatlantis/atlantis/server/events/webhooks"
 	"github.com/runatlantis/atlantis/server/events/yaml/raw"
 	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	"github.com/runatlantis/atlantis/server/jobs"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/tracing"
 )
 
 // DirNotExistErr is an error caused by the directory not existing.
@@ -46,6 +54,21 @@ type LockURLGenerator interface {
 	GenerateLockURL(lockID string) string
 }
 
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_output_url_generator.go OutputURLGenerator
+
+// OutputURLGenerator generates urls to watch the output of project commands.
+type OutputURLGenerator interface {
+	// GenerateOutputURL returns the full URL to the output with id.
+	GenerateOutputURL(id string) string
+}
+
+// PlanJSONURLGenerator generates urls to plans rendered as JSON.
+type PlanJSONURLGenerator interface {
+	// GeneratePlanJSONURL returns the full URL to the JSON plan of the
+	// project described by ctx.
+	GeneratePlanJSONURL(ctx models.ProjectCommandContext) string
+}
+
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_step_runner.go StepRunner
 
 // StepRunner runs steps. Steps are individual pieces of execution like
@@ -100,6 +123,14 @@ type ProjectApprovePoliciesCommandRunner interface {
 	ApprovePolicies(ctx models.ProjectCommandContext) models.ProjectResult
 }
 
+// ProjectApplyRequirementsChecker checks the apply requirements of projects,
+// ex. before commands that change their state other than apply.
+type ProjectApplyRequirementsChecker interface {
+	// ApplyRequirementsFailure returns why the apply requirements of the
+	// project described by ctx aren't met or "" if they are.
+	ApplyRequirementsFailure(ctx models.ProjectCommandContext) (string, error)
+}
+
 // ProjectCommandRunner runs project commands. A project command is a command
 // for a specific TF project.
 type ProjectCommandRunner interface {
@@ -121,15 +152,73 @@ type DefaultProjectCommandRunner struct {
 	RunStepRunner         CustomStepRunner
 	EnvStepRunner         EnvStepRunner
 	PullApprovedChecker   runtime.PullApprovedChecker
-	WorkingDir            WorkingDir
-	Webhooks              WebhooksSender
-	WorkingDirLocker      WorkingDirLocker
+	PullApprovalsGetter   runtime.PullApprovalsGetter
+	PullPipelineGetter    runtime.PullPipelineGetter
+	CodeOwnersChecker     CodeOwnersChecker
+	DenylistChecker       runtime.DenylistChecker
+	// RiskChecker finds the resources that plans destroy or replace so
+	// they're highlighted. If nil, they aren't.
+	RiskChecker runtime.RiskChecker
+	// Linter runs tflint on projects with a lint config before they're
+	// planned. If nil, projects aren't linted.
+	Linter runtime.Linter
+	// PlanEncryptor encrypts plan files at rest. If nil, plans aren't
+	// encrypted.
+	PlanEncryptor runtime.PlanEncryptor
+	// PlanCompressor compresses plan files and show step output at rest. If
+	// nil, they aren't compressed.
+	PlanCompressor *runtime.PlanCompressor
+	// PlanStore stores plan files so they can be applied by another Atlantis
+	// server or after a restart. If nil, plans are only kept on disk.
+	PlanStore *planstore.Store
+	// CredentialsProvider provides cloud credentials to workflows. If nil,
+	// no credentials are provided.
+	CredentialsProvider credentials.Provider
+	// DependencyOutputs provides the outputs of the projects a project
+	// depends on to its workflow. If nil, they aren't provided.
+	DependencyOutputs *DependencyOutputs
+	// VaultClient reads the Vault secrets referenced by env steps. If nil,
+	// env steps can't reference Vault secrets.
+	VaultClient      vault.Client
+	WorkingDir       WorkingDir
+	Webhooks         WebhooksSender
+	WorkingDirLocker WorkingDirLocker
+
+	// Outputs streams the output of commands so it can be watched while
+	// they run. If nil, output isn't streamed.
+	Outputs *jobs.OutputStore
+	// OutputURLGenerator generates the URLs to watch outputs. Must be set if
+	// Outputs is.
+	OutputURLGenerator OutputURLGenerator
+	// PlanJSONURLGenerator generates the URLs to plans rendered as JSON. If
+	// nil, plans are only rendered as JSON by show steps and comments don't
+	// link to them.
+	PlanJSONURLGenerator PlanJSONURLGenerator
+	// ConcurrencyLimiter limits how many plans, policy checks and applies
+	// run at the same time for each repo and project. If nil, they aren't
+	// limited.
+	ConcurrencyLimiter *ConcurrencyLimiter
+	// Deployments records applies as GitHub deployments. If nil, they
+	// aren't.
+	Deployments *GithubDeployments
+	// Insights publishes plans as Bitbucket Server Code Insights reports. If
+	// nil, they aren't.
+	Insights *BitbucketInsights
 }
 
 // Plan runs terraform plan for the project described by ctx.
 func (p *DefaultProjectCommandRunner) Plan(ctx models.ProjectCommandContext) models.ProjectResult {
-	planSuccess, failure, err := p.doPlan(ctx)
-	return models.ProjectResult{
+	release := p.ConcurrencyLimiter.Acquire(ctx.Log, ctx.Pull.BaseRepo, ctx.RepoRelDir)
+	defer release()
+	start := time.Now()
+	ctx, span := startProjectSpan(ctx, models.PlanCommand)
+	output := p.startOutput(ctx, models.PlanCommand)
+	p.Webhooks.Send(ctx.Log, webhooks.NewStartedResult(ctx, models.PlanCommand)) // nolint: errcheck
+	planSuccess, failure, err := p.doPlan(ctx, output)
+	p.finishOutput(output, failure)
+	p.Webhooks.Send(ctx.Log, webhooks.NewApplyResult(ctx, models.PlanCommand, failure, err)) // nolint: errcheck
+	endProjectSpan(span, failure, err)
+	result := models.ProjectResult{
 		Command:     models.PlanCommand,
 		PlanSuccess: planSuccess,
 		Error:       err,
@@ -137,12 +226,26 @@ func (p *DefaultProjectCommandRunner) Plan(ctx models.ProjectCommandContext) mod
 		RepoRelDir:  ctx.RepoRelDir,
 		Workspace:   ctx.Workspace,
 		ProjectName: ctx.ProjectName,
+		OutputURL:   p.outputURL(output),
+		User:        ctx.User.Username,
+		StartedAt:   start,
+		Duration:    time.Since(start),
 	}
+	p.Insights.PublishPlan(ctx, result)
+	return result
 }
 
 // PolicyCheck evaluates policies defined with Rego for the project described by ctx.
 func (p *DefaultProjectCommandRunner) PolicyCheck(ctx models.ProjectCommandContext) models.ProjectResult {
-	policySuccess, failure, err := p.doPolicyCheck(ctx)
+	release := p.ConcurrencyLimiter.Acquire(ctx.Log, ctx.Pull.BaseRepo, ctx.RepoRelDir)
+	defer release()
+	start := time.Now()
+	ctx, span := startProjectSpan(ctx, models.PolicyCheckCommand)
+	output := p.startOutput(ctx, models.PolicyCheckCommand)
+	policySuccess, failure, err := p.doPolicyCheck(ctx, output)
+	p.finishOutput(output, failure)
+	p.Webhooks.Send(ctx.Log, webhooks.NewApplyResult(ctx, models.PolicyCheckCommand, failure, err)) // nolint: errcheck
+	endProjectSpan(span, failure, err)
 	return models.ProjectResult{
 		Command:            models.PolicyCheckCommand,
 		PolicyCheckSuccess: policySuccess,
@@ -151,12 +254,25 @@ func (p *DefaultProjectCommandRunner) PolicyCheck(ctx models.ProjectCommandConte
 		RepoRelDir:         ctx.RepoRelDir,
 		Workspace:          ctx.Workspace,
 		ProjectName:        ctx.ProjectName,
+		OutputURL:          p.outputURL(output),
+		User:               ctx.User.Username,
+		StartedAt:          start,
+		Duration:           time.Since(start),
 	}
 }
 
 // Apply runs terraform apply for the project described by ctx.
 func (p *DefaultProjectCommandRunner) Apply(ctx models.ProjectCommandContext) models.ProjectResult {
-	applyOut, failure, err := p.doApply(ctx)
+	release := p.ConcurrencyLimiter.Acquire(ctx.Log, ctx.Pull.BaseRepo, ctx.RepoRelDir)
+	defer release()
+	start := time.Now()
+	ctx, span := startProjectSpan(ctx, models.ApplyCommand)
+	output := p.startOutput(ctx, models.ApplyCommand)
+	p.Webhooks.Send(ctx.Log, webhooks.NewStartedResult(ctx, models.ApplyCommand)) // nolint: errcheck
+	applyOut, failure, err := p.doApply(ctx, output)
+	p.finishOutput(output, failure)
+	p.Webhooks.Send(ctx.Log, webhooks.NewApplyResult(ctx, models.ApplyCommand, failure, err)) // nolint: errcheck
+	endProjectSpan(span, failure, err)
 	return models.ProjectResult{
 		Command:      models.ApplyCommand,
 		Failure:      failure,
@@ -165,6 +281,10 @@ func (p *DefaultProjectCommandRunner) Apply(ctx models.ProjectCommandContext) mo
 		RepoRelDir:   ctx.RepoRelDir,
 		Workspace:    ctx.Workspace,
 		ProjectName:  ctx.ProjectName,
+		OutputURL:    p.outputURL(output),
+		User:         ctx.User.Username,
+		StartedAt:    start,
+		Duration:     time.Since(start),
 	}
 }
 
@@ -191,7 +311,7 @@ func (p *DefaultProjectCommandRunner) doApprovePolicies(ctx models.ProjectComman
 	}, "", nil
 }
 
-func (p *DefaultProjectCommandRunner) doPolicyCheck(ctx models.ProjectCommandContext) (*models.PolicyCheckSuccess, string, error) {
+func (p *DefaultProjectCommandRunner) doPolicyCheck(ctx models.ProjectCommandContext, output *jobs.Output) (*models.PolicyCheckSuccess, string, error) {
 	// Acquire Atlantis lock for this repo/dir/workspace.
 	// This should already be acquired from the prior plan operation.
 	// if for some reason an unlock happens between the plan and policy check step
@@ -243,7 +363,13 @@ func (p *DefaultProjectCommandRunner) doPolicyCheck(ctx models.ProjectCommandCon
 		return nil, "", DirNotExistErr{RepoRelDir: ctx.RepoRelDir}
 	}
 
-	outputs, err := p.runSteps(ctx.Steps, ctx, absPath)
+	if err := p.unpackPlan(ctx, absPath); err != nil {
+		return nil, "", err
+	}
+	outputs, err := p.runSteps(ctx.Steps, ctx, absPath, output)
+	if packErr := p.packPlan(ctx, absPath); packErr != nil {
+		return nil, "", packErr
+	}
 	if err != nil {
 		// Note: we are explicitly not unlocking the pr here since a failing policy check will require
 		// approval
@@ -262,7 +388,7 @@ func (p *DefaultProjectCommandRunner) doPolicyCheck(ctx models.ProjectCommandCon
 	}, "", nil
 }
 
-func (p *DefaultProjectCommandRunner) doPlan(ctx models.ProjectCommandContext) (*models.PlanSuccess, string, error) {
+func (p *DefaultProjectCommandRunner) doPlan(ctx models.ProjectCommandContext, output *jobs.Output) (*models.PlanSuccess, string, error) {
 	// Acquire Atlantis lock for this repo/dir/workspace.
 	lockAttempt, err := p.Locker.TryLock(ctx.Log, ctx.Pull, ctx.User, ctx.Workspace, models.NewProject(ctx.Pull.BaseRepo.FullName, ctx.RepoRelDir))
 	if err != nil {
@@ -293,7 +419,19 @@ func (p *DefaultProjectCommandRunner) doPlan(ctx models.ProjectCommandContext) (
 		return nil, "", DirNotExistErr{RepoRelDir: ctx.RepoRelDir}
 	}
 
-	outputs, err := p.runSteps(ctx.Steps, ctx, projAbsPath)
+	lintFindings, lintFailure, err := p.lint(ctx, repoDir, projAbsPath)
+	if err != nil || lintFailure != "" {
+		if unlockErr := lockAttempt.UnlockFn(); unlockErr != nil {
+			ctx.Log.Err("error unlocking state after plan error: %v", unlockErr)
+		}
+		return nil, lintFailure, err
+	}
+
+	var providersBefore map[string]string
+	if ctx.UpgradeProviders {
+		providersBefore = p.providerVersions(ctx, projAbsPath)
+	}
+	outputs, err := p.runSteps(ctx.Steps, ctx, projAbsPath, output)
 	if err != nil {
 		if unlockErr := lockAttempt.UnlockFn(); unlockErr != nil {
 			ctx.Log.Err("error unlocking state after plan error: %v", unlockErr)
@@ -301,16 +439,160 @@ func (p *DefaultProjectCommandRunner) doPlan(ctx models.ProjectCommandContext) (
 		return nil, "", fmt.Errorf("%s\n%s", err, strings.Join(outputs, "\n"))
 	}
 
+	if p.DenylistChecker != nil {
+		denied, err := p.DenylistChecker.Check(ctx, projAbsPath)
+		if err == nil && len(denied) > 0 {
+			// Delete the plan so it can't be applied.
+			err = os.Remove(filepath.Join(projAbsPath, runtime.GetPlanFilename(ctx.Workspace, ctx.ProjectName)))
+		}
+		if err != nil || len(denied) > 0 {
+			if unlockErr := lockAttempt.UnlockFn(); unlockErr != nil {
+				ctx.Log.Err("error unlocking state after plan error: %v", unlockErr)
+			}
+		}
+		if err != nil {
+			return nil, "", errors.Wrap(err, "checking denylist")
+		}
+		if len(denied) > 0 {
+			return nil, fmt.Sprintf("Plan contains items that are denied by the server-side config:\n\n* %s", strings.Join(denied, "\n* ")), nil
+		}
+	}
+
+	riskyChanges := p.riskyChanges(ctx, projAbsPath)
+	planJSONURL := p.showPlan(ctx, projAbsPath)
+	var providerUpgrades []models.ProviderUpgrade
+	if ctx.UpgradeProviders {
+		providerUpgrades = runtime.ProviderUpgrades(providersBefore, p.providerVersions(ctx, projAbsPath))
+	}
+
+	if err := p.packPlan(ctx, projAbsPath); err != nil {
+		if unlockErr := lockAttempt.UnlockFn(); unlockErr != nil {
+			ctx.Log.Err("error unlocking state after plan error: %v", unlockErr)
+		}
+		return nil, "", err
+	}
+
+	if err := p.savePlan(ctx, projAbsPath); err != nil {
+		if unlockErr := lockAttempt.UnlockFn(); unlockErr != nil {
+			ctx.Log.Err("error unlocking state after plan error: %v", unlockErr)
+		}
+		return nil, "", err
+	}
+
 	return &models.PlanSuccess{
-		LockURL:         p.LockURLGenerator.GenerateLockURL(lockAttempt.LockKey),
-		TerraformOutput: strings.Join(outputs, "\n"),
-		RePlanCmd:       ctx.RePlanCmd,
-		ApplyCmd:        ctx.ApplyCmd,
-		HasDiverged:     hasDiverged,
+		LockURL:              p.LockURLGenerator.GenerateLockURL(lockAttempt.LockKey),
+		TerraformOutput:      strings.Join(outputs, "\n"),
+		RePlanCmd:            ctx.RePlanCmd,
+		ApplyCmd:             ctx.ApplyCmd,
+		HasDiverged:          hasDiverged,
+		PlanJSONURL:          planJSONURL,
+		RiskyChanges:         riskyChanges,
+		RequiresAllowDestroy: len(riskyChanges) > 0 && requiresAllowDestroy(ctx),
+		UpgradeProviders:     ctx.UpgradeProviders,
+		ProviderUpgrades:     providerUpgrades,
+		LintFindings:         lintFindings,
 	}, "", nil
 }
 
-func (p *DefaultProjectCommandRunner) doApply(ctx models.ProjectCommandContext) (applyOut string, failure string, err error) {
+// providerVersions returns the provider versions pinned in the project's
+// dependency lock file. It returns nil if they can't be read since that
+// shouldn't fail the plan.
+func (p *DefaultProjectCommandRunner) providerVersions(ctx models.ProjectCommandContext, absPath string) map[string]string {
+	versions, err := runtime.ProviderVersions(absPath)
+	if err != nil {
+		ctx.Log.Warn("unable to read provider versions: %s", err)
+	}
+	return versions
+}
+
+// lint runs tflint on the project if it has a lint config. If lint is
+// blocking and tflint found issues, it returns them as the failure to comment
+// instead of planning.
+func (p *DefaultProjectCommandRunner) lint(ctx models.ProjectCommandContext, repoDir string, absPath string) (models.LintFindings, string, error) {
+	if p.Linter == nil || ctx.Lint == nil {
+		return nil, "", nil
+	}
+	findings, err := p.Linter.Lint(ctx, repoDir, absPath)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "linting")
+	}
+	if ctx.Lint.Blocking && len(findings) > 0 {
+		return nil, fmt.Sprintf("tflint found issues so the plan wasn't run:\n\n%s", findings.Markdown()), nil
+	}
+	return findings, "", nil
+}
+
+// riskyChanges returns the resources that the project's plan replaces or
+// destroys. It returns nil if they can't be found since that shouldn't fail
+// the plan, ex. for remote operations.
+func (p *DefaultProjectCommandRunner) riskyChanges(ctx models.ProjectCommandContext, absPath string) []models.ResourceChanges {
+	if p.RiskChecker == nil {
+		return nil
+	}
+	changes, err := p.RiskChecker.Check(ctx, absPath)
+	if err != nil {
+		ctx.Log.Warn("unable to check plan for destroyed resources: %s", err)
+		return nil
+	}
+	return changes
+}
+
+// requiresAllowDestroy returns true if plans of the project that destroy or
+// replace resources must be applied with --allow-destroy.
+func requiresAllowDestroy(ctx models.ProjectCommandContext) bool {
+	for _, req := range ctx.ApplyRequirements {
+		if req == raw.DestroyConfirmedRequirement {
+			return true
+		}
+	}
+	return false
+}
+
+// destroyConfirmedFailure returns why the project's plan can't be applied if
+// it destroys or replaces resources and that wasn't confirmed with
+// --allow-destroy as the destroy_confirmed apply requirement requires.
+func (p *DefaultProjectCommandRunner) destroyConfirmedFailure(ctx models.ProjectCommandContext, absPath string) (string, error) {
+	if ctx.AllowDestroy || p.RiskChecker == nil || !requiresAllowDestroy(ctx) {
+		return "", nil
+	}
+	changes, err := p.RiskChecker.Check(ctx, absPath)
+	if err != nil {
+		return "", errors.Wrap(err, "checking plan for destroyed resources")
+	}
+	if len(changes) == 0 {
+		return "", nil
+	}
+	var addrs []string
+	for _, c := range changes {
+		addrs = append(addrs, c.Addresses...)
+	}
+	return fmt.Sprintf("Plan destroys or replaces resources so applying it must be confirmed by commenting `atlantis apply --allow-destroy` with the same flags. Destroyed or replaced: `%s`.", strings.Join(addrs, "`, `")), nil
+}
+
+// showPlan renders the project's plan as JSON next to it, unless a show step
+// already did, and returns the URL to view it. It returns "" if plans aren't
+// rendered as JSON or rendering failed since that shouldn't fail the plan.
+func (p *DefaultProjectCommandRunner) showPlan(ctx models.ProjectCommandContext, absPath string) string {
+	if p.PlanJSONURLGenerator == nil {
+		return ""
+	}
+	for _, step := range ctx.Steps {
+		if step.StepName == "show" {
+			return p.PlanJSONURLGenerator.GeneratePlanJSONURL(ctx)
+		}
+	}
+	if _, err := p.ShowStepRunner.Run(ctx, nil, absPath, map[string]string{}); err != nil {
+		ctx.Log.Warn("unable to render plan as JSON: %s", err)
+		return ""
+	}
+	// Remote plans and old Terraform versions aren't rendered.
+	if _, err := os.Stat(filepath.Join(absPath, ctx.GetShowResultFileName())); err != nil {
+		return ""
+	}
+	return p.PlanJSONURLGenerator.GeneratePlanJSONURL(ctx)
+}
+
+func (p *DefaultProjectCommandRunner) doApply(ctx models.ProjectCommandContext, output *jobs.Output) (applyOut string, failure string, err error) {
 	repoDir, err := p.WorkingDir.GetWorkingDir(ctx.Pull.BaseRepo, ctx.Pull, ctx.Workspace)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -323,57 +605,380 @@ func (p *DefaultProjectCommandRunner) doApply(ctx models.ProjectCommandContext)
 		return "", "", DirNotExistErr{RepoRelDir: ctx.RepoRelDir}
 	}
 
+	if failure, err := p.applyRequirementsFailure(ctx, repoDir, absPath); err != nil || failure != "" {
+		return "", failure, err
+	}
+	// Acquire internal lock for the directory we're going to operate in.
+	unlockFn, err := p.WorkingDirLocker.TryLock(ctx.Pull.BaseRepo.FullName, ctx.Pull.Num, ctx.Workspace)
+	if err != nil {
+		return "", "", err
+	}
+	defer unlockFn()
+
+	if err := p.unpackPlan(ctx, absPath); err != nil {
+		return "", "", err
+	}
+	if failure, err := p.destroyConfirmedFailure(ctx, absPath); err != nil || failure != "" {
+		if packErr := p.packPlan(ctx, absPath); packErr != nil {
+			ctx.Log.Err("%s", packErr)
+		}
+		return "", failure, err
+	}
+	deploymentID, err := p.Deployments.Start(ctx, p.outputURL(output))
+	if err != nil {
+		return "", "", errors.Wrap(err, "creating GitHub deployment")
+	}
+	outputs, err := p.runSteps(ctx.Steps, ctx, absPath, output)
+	p.Deployments.Finish(ctx, deploymentID, err, p.outputURL(output))
+	// A successful apply deletes the plan so this only applies to failures.
+	if packErr := p.packPlan(ctx, absPath); packErr != nil {
+		ctx.Log.Err("%s", packErr)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("%s\n%s", err, strings.Join(outputs, "\n"))
+	}
+	if p.PlanStore != nil {
+		if err := p.PlanStore.Delete(ctx, runtime.GetPlanFilename(ctx.Workspace, ctx.ProjectName)); err != nil {
+			ctx.Log.Err("deleting applied plan from plan store: %s", err)
+		}
+	}
+	return strings.Join(outputs, "\n"), "", nil
+}
+
+// ApplyRequirementsFailure implements ProjectApplyRequirementsChecker.
+func (p *DefaultProjectCommandRunner) ApplyRequirementsFailure(ctx models.ProjectCommandContext) (string, error) {
+	repoDir, err := p.WorkingDir.GetWorkingDir(ctx.Pull.BaseRepo, ctx.Pull, ctx.Workspace)
+	if err != nil {
+		return "", err
+	}
+	return p.applyRequirementsFailure(ctx, repoDir, filepath.Join(repoDir, ctx.RepoRelDir))
+}
+
+// applyRequirementsFailure returns why the apply requirements of the project
+// described by ctx, cloned in repoDir, aren't met or "" if they are.
+func (p *DefaultProjectCommandRunner) applyRequirementsFailure(ctx models.ProjectCommandContext, repoDir string, absPath string) (string, error) {
 	for _, req := range ctx.ApplyRequirements {
 		switch req {
 		case raw.ApprovedApplyRequirement:
-			approved, err := p.PullApprovedChecker.PullIsApproved(ctx.Pull.BaseRepo, ctx.Pull) // nolint: vetshadow
+			approved, err := p.PullApprovedChecker.PullIsApproved(ctx.Pull.BaseRepo, ctx.Pull)
 			if err != nil {
-				return "", "", errors.Wrap(err, "checking if pull request was approved")
+				return "", errors.Wrap(err, "checking if pull request was approved")
 			}
 			if !approved {
-				return "", "Pull request must be approved by at least one person other than the author before running apply.", nil
+				return "Pull request must be approved by at least one person other than the author before running apply.", nil
+			}
+		case raw.ApprovedCountRequirement:
+			count, err := p.countApprovals(ctx, absPath)
+			if err != nil {
+				return "", errors.Wrap(err, "counting pull request approvals")
+			}
+			required := ctx.Approvals.Count
+			if required < 1 {
+				required = 1
+			}
+			if count < required {
+				return fmt.Sprintf("Pull request must be approved by at least %d reviewer(s) before running apply, it has %d %s.", required, count, p.approvalsDescription(ctx)), nil
+			}
+		case raw.CodeOwnersApplyRequirement:
+			missing, err := p.CodeOwnersChecker.MissingApprovals(ctx, repoDir)
+			if err == codeowners.ErrNotFound {
+				return fmt.Sprintf("Pull request must be approved by code owners before running apply, but the %s branch has no CODEOWNERS file.", ctx.Pull.BaseBranch), nil
+			}
+			if err != nil {
+				return "", errors.Wrap(err, "checking code owner approvals")
+			}
+			if len(missing) > 0 {
+				var sets []string
+				for _, owners := range missing {
+					sets = append(sets, strings.Join(owners, " or "))
+				}
+				return fmt.Sprintf("Pull request must be approved by code owners before running apply. Missing approval from: %s.", strings.Join(sets, ", ")), nil
+			}
+		case raw.PipelineSucceededRequirement:
+			pipeline, err := p.PullPipelineGetter.GetPipeline(ctx.Pull.BaseRepo, ctx.Pull)
+			if err != nil {
+				return "", errors.Wrap(err, "getting pull request pipeline")
+			}
+			if failure := pipelineFailure(ctx.Pipeline, pipeline, ctx.Pull.HeadCommit); failure != "" {
+				return failure, nil
+			}
+		case raw.AllPlansSucceededRequirement:
+			if len(ctx.UnplannedProjects) > 0 {
+				return fmt.Sprintf("All projects modified in the pull request must be planned successfully before running apply. Missing a successful plan: %s.", strings.Join(ctx.UnplannedProjects, ", ")), nil
 			}
 		// this should come before mergeability check since mergeability is a superset of this check.
 		case valid.PoliciesPassedApplyReq:
 			if ctx.ProjectPlanStatus == models.ErroredPolicyCheckStatus {
-				return "", "All policies must pass for project before running apply", nil
+				return "All policies must pass for project before running apply", nil
 			}
 		case raw.MergeableApplyRequirement:
 			if !ctx.PullMergeable {
-				return "", "Pull request must be mergeable before running apply.", nil
+				return "Pull request must be mergeable before running apply.", nil
 			}
 		case raw.UnDivergedApplyRequirement:
 			if p.WorkingDir.HasDiverged(ctx.Log, repoDir) {
-				return "", "Default branch must be rebased onto pull request before running apply.", nil
+				return "Default branch must be rebased onto pull request before running apply.", nil
+			}
+		case raw.BaseUnchangedRequirement:
+			advanced, err := p.WorkingDir.BaseAdvanced(ctx.Log, ctx.HeadRepo, ctx.Pull, repoDir)
+			if err != nil {
+				return "", errors.Wrap(err, "checking if base branch advanced")
+			}
+			if advanced {
+				return fmt.Sprintf("Base branch %q has new commits since the plan was generated. Run `atlantis plan` again before running apply.", ctx.Pull.BaseBranch), nil
 			}
 		}
 	}
-	// Acquire internal lock for the directory we're going to operate in.
-	unlockFn, err := p.WorkingDirLocker.TryLock(ctx.Pull.BaseRepo.FullName, ctx.Pull.Num, ctx.Workspace)
+	return "", nil
+}
+
+// pipelineUnfinishedStatuses are the statuses of CI jobs that haven't
+// finished.
+var pipelineUnfinishedStatuses = []string{"created", "waiting_for_resource", "preparing", "pending", "running", "scheduled"}
+
+// pipelineFailure returns why pipeline, the latest pipeline of a pull request
+// at headCommit, doesn't meet the pipeline_succeeded apply requirement
+// configured by cfg, or "" if it does. The pipeline's own status isn't used
+// since it includes the commit statuses set by Atlantis, ex. atlantis/apply is
+// pending while applying, so its CI jobs are checked instead.
+func pipelineFailure(cfg valid.Pipeline, pipeline models.Pipeline, headCommit string) string {
+	if pipeline.ID == 0 || pipeline.SHA != headCommit {
+		return "Pull request must have a pipeline for its latest commit before running apply."
+	}
+	allowed := cfg.AllowedStatuses
+	if len(allowed) == 0 {
+		allowed = valid.DefaultPipelineAllowedStatuses
+	}
+	contains := func(strs []string, str string) bool {
+		for _, s := range strs {
+			if s == str {
+				return true
+			}
+		}
+		return false
+	}
+
+	var failed []string
+	found := make(map[string]bool)
+	for _, job := range pipeline.Jobs {
+		found[job.Name] = true
+		if contains(allowed, job.Status) {
+			continue
+		}
+		// Like in GitLab, jobs that are allowed to fail only have to finish,
+		// unless they're required.
+		if job.AllowFailure && !contains(pipelineUnfinishedStatuses, job.Status) && !contains(cfg.RequiredJobs, job.Name) {
+			continue
+		}
+		failed = append(failed, fmt.Sprintf("%s (%s)", job.Name, job.Status))
+	}
+	for _, name := range cfg.RequiredJobs {
+		if !found[name] {
+			failed = append(failed, fmt.Sprintf("%s (missing)", name))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Sprintf("Pull request's pipeline must succeed before running apply. Jobs that didn't: %s.", strings.Join(failed, ", "))
+	}
+	return ""
+}
+
+// countApprovals returns the number of distinct reviewers that approved the
+// pull request, ignoring approvals excluded by ctx.Approvals. absPath is the
+// project's directory, used to find when the latest plan was generated.
+func (p *DefaultProjectCommandRunner) countApprovals(ctx models.ProjectCommandContext, absPath string) (int, error) {
+	approvals, err := p.PullApprovalsGetter.GetApprovals(ctx.Pull.BaseRepo, ctx.Pull)
 	if err != nil {
-		return "", "", err
+		return 0, err
 	}
-	defer unlockFn()
 
-	outputs, err := p.runSteps(ctx.Steps, ctx, absPath)
-	p.Webhooks.Send(ctx.Log, webhooks.ApplyResult{ // nolint: errcheck
-		Workspace: ctx.Workspace,
-		User:      ctx.User,
-		Repo:      ctx.Pull.BaseRepo,
-		Pull:      ctx.Pull,
-		Success:   err == nil,
-		Directory: ctx.RepoRelDir,
-	})
+	var plannedAt time.Time
+	if ctx.Approvals.ExcludePrePlan {
+		// Without a plan every approval would count, so fail closed.
+		info, err := os.Stat(filepath.Join(absPath, runtime.GetPlanFilename(ctx.Workspace, ctx.ProjectName)))
+		if os.IsNotExist(err) {
+			return 0, errors.New("no plan found to compare approvals to–did you run plan?")
+		}
+		if err != nil {
+			return 0, errors.Wrap(err, "finding latest plan")
+		}
+		plannedAt = info.ModTime()
+	}
+
+	approvers := make(map[string]bool)
+	for _, a := range approvals {
+		if ctx.Approvals.ExcludeAuthor && strings.EqualFold(a.Username, ctx.Pull.Author) {
+			continue
+		}
+		if ctx.Approvals.ExcludePrePlan {
+			// Not every VCS host reports the approved commit or time.
+			if a.CommitSHA != "" && a.CommitSHA != ctx.Pull.HeadCommit {
+				continue
+			}
+			if !a.Time.IsZero() && a.Time.Before(plannedAt) {
+				continue
+			}
+		}
+		approvers[strings.ToLower(a.Username)] = true
+	}
+	return len(approvers), nil
+}
+
+// approvalsDescription describes which approvals are counted by countApprovals.
+func (p *DefaultProjectCommandRunner) approvalsDescription(ctx models.ProjectCommandContext) string {
+	var qualifiers []string
+	if ctx.Approvals.ExcludeAuthor {
+		qualifiers = append(qualifiers, "from someone other than the author")
+	}
+	if ctx.Approvals.ExcludePrePlan {
+		qualifiers = append(qualifiers, "since the latest plan")
+	}
+	if len(qualifiers) == 0 {
+		return "approval(s)"
+	}
+	return "approval(s) " + strings.Join(qualifiers, " ")
+}
+
+// unpackPlan decrypts and decompresses the project's plan file, and
+// decompresses the output of its show step, if they exist, so terraform and
+// policy checks can read them.
+func (p *DefaultProjectCommandRunner) unpackPlan(ctx models.ProjectCommandContext, absPath string) error {
+	planPath := filepath.Join(absPath, runtime.GetPlanFilename(ctx.Workspace, ctx.ProjectName))
+	if p.PlanEncryptor != nil {
+		if err := p.PlanEncryptor.Decrypt(planPath); err != nil {
+			return errors.Wrap(err, "decrypting plan")
+		}
+	}
+	// Files are decompressed even if compression is disabled in case they
+	// were compressed before it was.
+	if err := runtime.DecompressPlan(planPath); err != nil {
+		return err
+	}
+	return runtime.DecompressPlan(filepath.Join(absPath, ctx.GetShowResultFileName()))
+}
+
+// packPlan compresses and encrypts the project's plan file, and compresses
+// the output of its show step, if they exist. If the plan can't be
+// compressed or encrypted it's deleted rather than left in cleartext.
+func (p *DefaultProjectCommandRunner) packPlan(ctx models.ProjectCommandContext, absPath string) error {
+	planPath := filepath.Join(absPath, runtime.GetPlanFilename(ctx.Workspace, ctx.ProjectName))
+	err := p.PlanCompressor.Compress(planPath)
+	if err == nil && p.PlanEncryptor != nil {
+		err = errors.Wrap(p.PlanEncryptor.Encrypt(planPath), "encrypting plan")
+	}
 	if err != nil {
-		return "", "", fmt.Errorf("%s\n%s", err, strings.Join(outputs, "\n"))
+		if removeErr := os.Remove(planPath); removeErr != nil && !os.IsNotExist(removeErr) {
+			ctx.Log.Err("error deleting plan after compression or encryption error: %v", removeErr)
+		}
+		return err
 	}
-	return strings.Join(outputs, "\n"), "", nil
+	return p.PlanCompressor.Compress(filepath.Join(absPath, ctx.GetShowResultFileName()))
+}
+
+// savePlan uploads the project's plan file, if it exists, to the plan store.
+// If the upload fails the plan is deleted so it must be generated again
+// rather than only being applyable by this server.
+func (p *DefaultProjectCommandRunner) savePlan(ctx models.ProjectCommandContext, absPath string) error {
+	if p.PlanStore == nil {
+		return nil
+	}
+	planPath := filepath.Join(absPath, runtime.GetPlanFilename(ctx.Workspace, ctx.ProjectName))
+	if _, err := os.Stat(planPath); os.IsNotExist(err) {
+		return nil
+	}
+	if err := p.PlanStore.Save(ctx, planPath); err != nil {
+		if removeErr := os.Remove(planPath); removeErr != nil {
+			ctx.Log.Err("error deleting plan after plan store error: %v", removeErr)
+		}
+		return errors.Wrap(err, "saving plan to plan store")
+	}
+	return nil
+}
+
+// startOutput starts the output of running cmdName for ctx. It returns nil if
+// output isn't streamed.
+func (p *DefaultProjectCommandRunner) startOutput(ctx models.ProjectCommandContext, cmdName models.CommandName) *jobs.Output {
+	if p.Outputs == nil {
+		return nil
+	}
+	return p.Outputs.Start(jobs.OutputInfo{
+		Command:     cmdName.String(),
+		Repo:        ctx.Pull.BaseRepo.FullName,
+		Pull:        ctx.Pull.Num,
+		ProjectName: ctx.ProjectName,
+		RepoRelDir:  ctx.RepoRelDir,
+		Workspace:   ctx.Workspace,
+	})
+}
+
+// finishOutput marks output as finished. failure is added to the output since
+// otherwise it would only be in the pull request comment.
+func (p *DefaultProjectCommandRunner) finishOutput(output *jobs.Output, failure string) {
+	if output == nil {
+		return
+	}
+	if failure != "" {
+		output.Write([]byte(failure + "\n")) // nolint: errcheck
+	}
+	output.Close()
+}
+
+func (p *DefaultProjectCommandRunner) outputURL(output *jobs.Output) string {
+	if output == nil {
+		return ""
+	}
+	return p.OutputURLGenerator.GenerateOutputURL(output.Info().ID)
 }
 
-func (p *DefaultProjectCommandRunner) runSteps(steps []valid.Step, ctx models.ProjectCommandContext, absPath string) ([]string, error) {
+// runSteps runs steps in absPath. If output isn't nil, the steps' output is
+// streamed to it.
+func (p *DefaultProjectCommandRunner) runSteps(steps []valid.Step, ctx models.ProjectCommandContext, absPath string, output *jobs.Output) ([]string, error) {
 	var outputs []string
 	envs := make(map[string]string)
+	if p.CredentialsProvider != nil {
+		credEnvs, err := p.CredentialsProvider.Env(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range credEnvs {
+			envs[k] = v
+		}
+	}
+	// secrets are the values read from Vault so far and the sensitive outputs
+	// of dependencies. They're masked in all output since it's posted to the
+	// pull request.
+	var secrets []string
+	if p.DependencyOutputs != nil && len(ctx.DependsOn) > 0 {
+		depEnvs, sensitive, err := p.DependencyOutputs.Env(ctx, envs)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range depEnvs {
+			envs[k] = v
+		}
+		secrets = append(secrets, sensitive...)
+		if output != nil {
+			for _, s := range sensitive {
+				output.Mask(s)
+			}
+		}
+	}
+	project := projectDescription(ctx)
+	defer ctx.Progress.SetStage(project, progress.DoneStage)
 	for _, step := range steps {
+		ctx.Progress.SetStage(project, step.StepName)
+		// Terraform's output is streamed by the terraform client as it runs
+		// while the output of other steps is added once they finish.
+		streamed := output != nil && (step.StepName == "init" || step.StepName == "plan" || step.StepName == "apply")
+		if streamed {
+			p.Outputs.Attach(absPath, output)
+		}
+		stepSpan := ctx.Span.Start("step "+step.StepName, tracing.String("atlantis.step", step.StepName))
+		// Custom run steps and terraform can continue the trace, ex. terraform
+		// records its own spans if OTEL_TRACES_EXPORTER is set.
+		if tp := stepSpan.Traceparent(); tp != "" {
+			envs["TRACEPARENT"] = tp
+		}
 		var out string
 		var err error
 		switch step.StepName {
@@ -390,15 +995,35 @@ func (p *DefaultProjectCommandRunner) runSteps(steps []valid.Step, ctx models.Pr
 		case "run":
 			out, err = p.RunStepRunner.Run(ctx, step.RunCommand, absPath, envs)
 		case "env":
-			out, err = p.EnvStepRunner.Run(ctx, step.RunCommand, step.EnvVarValue, absPath, envs)
+			if step.VaultPath != "" {
+				out, err = p.readVaultSecret(step)
+				secrets = append(secrets, out)
+				if output != nil && out != "" {
+					output.Mask(out)
+				}
+			} else {
+				out, err = p.EnvStepRunner.Run(ctx, step.RunCommand, step.EnvVarValue, absPath, envs)
+			}
 			envs[step.EnvVarName] = out
 			// We reset out to the empty string because we don't want it to
 			// be printed to the PR, it's solely to set the environment variable.
 			out = ""
 		}
+		if err != nil && len(secrets) > 0 {
+			err = errors.New(vault.MaskSecrets(err.Error(), secrets))
+		}
+		stepSpan.RecordError(err)
+		stepSpan.End()
 
+		if streamed {
+			p.Outputs.Detach(absPath)
+		}
 		if out != "" {
+			out = vault.MaskSecrets(out, secrets)
 			outputs = append(outputs, out)
+			if output != nil && !streamed {
+				output.Write([]byte(out + "\n")) // nolint: errcheck
+			}
 		}
 		if err != nil {
 			return outputs, err
@@ -406,3 +1031,32 @@ func (p *DefaultProjectCommandRunner) runSteps(steps []valid.Step, ctx models.Pr
 	}
 	return outputs, nil
 }
+
+// startProjectSpan starts the span for running cmd for the project described
+// by ctx. It returns a copy of ctx with the span so steps are recorded under
+// it.
+func startProjectSpan(ctx models.ProjectCommandContext, cmd models.CommandName) (models.ProjectCommandContext, *tracing.Span) {
+	span := ctx.Span.Start("project "+cmd.String(),
+		tracing.String("atlantis.project", ctx.ProjectName),
+		tracing.String("atlantis.dir", ctx.RepoRelDir),
+		tracing.String("atlantis.workspace", ctx.Workspace))
+	ctx.Span = span
+	return ctx, span
+}
+
+// endProjectSpan ends span, marking it as failed if the command failed.
+func endProjectSpan(span *tracing.Span, failure string, err error) {
+	if err == nil && failure != "" {
+		err = errors.New(failure)
+	}
+	span.RecordError(err)
+	span.End()
+}
+
+func (p *DefaultProjectCommandRunner) readVaultSecret(step valid.Step) (string, error) {
+	if p.VaultClient == nil {
+		return "", fmt.Errorf("env step %q references a Vault secret but Vault isn't configured, see --vault-addr", step.EnvVarName)
+	}
+	value, err := p.VaultClient.Read(step.VaultPath, step.VaultKey)
+	return value, errors.Wrapf(err, "reading Vault secret for env step %q", step.EnvVarName)
+}
@@ -14,19 +14,29 @@
 package events_test
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/go-version"
 	. "github.com/petergtz/pegomock"
+	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/codeowners"
+	credmocks "github.com/runatlantis/atlantis/server/events/credentials/mocks"
 	"github.com/runatlantis/atlantis/server/events/mocks"
 	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/planstore"
 	"github.com/runatlantis/atlantis/server/events/runtime"
 	mocks2 "github.com/runatlantis/atlantis/server/events/runtime/mocks"
 	tmocks "github.com/runatlantis/atlantis/server/events/terraform/mocks"
+	vaultmocks "github.com/runatlantis/atlantis/server/events/vault/mocks"
+	"github.com/runatlantis/atlantis/server/events/webhooks"
 	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	"github.com/runatlantis/atlantis/server/jobs"
 	"github.com/runatlantis/atlantis/server/logging"
 	. "github.com/runatlantis/atlantis/testing"
 )
@@ -52,7 +62,7 @@ func TestDefaultProjectCommandRunner_Plan(t *testing.T) {
 		EnvStepRunner:       &realEnv,
 		PullApprovedChecker: nil,
 		WorkingDir:          mockWorkingDir,
-		Webhooks:            nil,
+		Webhooks:            mocks.NewMockWebhooksSender(),
 		WorkingDirLocker:    events.NewDefaultWorkingDirLocker(),
 	}
 
@@ -128,11 +138,540 @@ func TestDefaultProjectCommandRunner_Plan(t *testing.T) {
 	}
 }
 
+// Test that if the plan contains denied items the plan fails, the planfile is
+// deleted and the lock released.
+func TestDefaultProjectCommandRunner_PlanDenied(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockPlan := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+	mockDenylist := mocks2.NewMockDenylistChecker()
+
+	runner := events.DefaultProjectCommandRunner{
+		Webhooks:         mocks.NewMockWebhooksSender(),
+		Locker:           mockLocker,
+		LockURLGenerator: mockURLGenerator{},
+		PlanStepRunner:   mockPlan,
+		DenylistChecker:  mockDenylist,
+		WorkingDir:       mockWorkingDir,
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+	}
+
+	repoDir, cleanup := TempDir(t)
+	defer cleanup()
+	planFile := filepath.Join(repoDir, "default.tfplan")
+	Ok(t, ioutil.WriteFile(planFile, nil, 0600))
+	When(mockWorkingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(repoDir, false, nil)
+	unlocked := false
+	When(mockLocker.TryLock(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsPullRequest(),
+		matchers.AnyModelsUser(),
+		AnyString(),
+		matchers.AnyModelsProject(),
+	)).ThenReturn(&events.TryLockResponse{
+		LockAcquired: true,
+		LockKey:      "lock-key",
+		UnlockFn: func() error {
+			unlocked = true
+			return nil
+		},
+	}, nil)
+
+	ctx := models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(t),
+		Steps:      []valid.Step{{StepName: "plan"}},
+		Workspace:  "default",
+		RepoRelDir: ".",
+		Denylist:   valid.Denylist{Resources: []string{"aws_iam_*"}},
+	}
+	When(mockPlan.Run(ctx, nil, repoDir, make(map[string]string))).ThenReturn("plan", nil)
+	When(mockDenylist.Check(ctx, repoDir)).ThenReturn([]string{
+		"`aws_iam_user.a`: resource type `aws_iam_user` is denied by `aws_iam_*`",
+		"`aws_iam_user.b`: resource type `aws_iam_user` is denied by `aws_iam_*`",
+	}, nil)
+
+	res := runner.Plan(ctx)
+	Assert(t, res.PlanSuccess == nil, "exp plan to fail")
+	Ok(t, res.Error)
+	Equals(t, "Plan contains items that are denied by the server-side config:\n\n* `aws_iam_user.a`: resource type `aws_iam_user` is denied by `aws_iam_*`\n* `aws_iam_user.b`: resource type `aws_iam_user` is denied by `aws_iam_*`", res.Failure)
+	Assert(t, unlocked, "exp lock to be released")
+	_, err := os.Stat(planFile)
+	Assert(t, os.IsNotExist(err), "exp planfile to be deleted")
+}
+
+// Test that plans are encrypted after planning.
+func TestDefaultProjectCommandRunner_PlanEncrypted(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockPlan := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+	mockEncryptor := mocks2.NewMockPlanEncryptor()
+
+	runner := events.DefaultProjectCommandRunner{
+		Webhooks:         mocks.NewMockWebhooksSender(),
+		Locker:           mockLocker,
+		LockURLGenerator: mockURLGenerator{},
+		PlanStepRunner:   mockPlan,
+		PlanEncryptor:    mockEncryptor,
+		WorkingDir:       mockWorkingDir,
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+	}
+
+	repoDir, cleanup := TempDir(t)
+	defer cleanup()
+	When(mockWorkingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(repoDir, false, nil)
+	When(mockLocker.TryLock(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsPullRequest(),
+		matchers.AnyModelsUser(),
+		AnyString(),
+		matchers.AnyModelsProject(),
+	)).ThenReturn(&events.TryLockResponse{
+		LockAcquired: true,
+		LockKey:      "lock-key",
+	}, nil)
+
+	ctx := models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(t),
+		Steps:      []valid.Step{{StepName: "plan"}},
+		Workspace:  "default",
+		RepoRelDir: ".",
+	}
+	When(mockPlan.Run(ctx, nil, repoDir, make(map[string]string))).ThenReturn("plan", nil)
+
+	res := runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	mockEncryptor.VerifyWasCalledOnce().Encrypt(filepath.Join(repoDir, "default.tfplan"))
+}
+
+// Test that plans are rendered as JSON and linked to if
+// PlanJSONURLGenerator is set.
+func TestDefaultProjectCommandRunner_PlanJSON(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockPlan := mocks.NewMockStepRunner()
+	mockShow := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+
+	runner := events.DefaultProjectCommandRunner{
+		Webhooks:             mocks.NewMockWebhooksSender(),
+		Locker:               mockLocker,
+		LockURLGenerator:     mockURLGenerator{},
+		PlanJSONURLGenerator: mockURLGenerator{},
+		PlanStepRunner:       mockPlan,
+		ShowStepRunner:       mockShow,
+		WorkingDir:           mockWorkingDir,
+		WorkingDirLocker:     events.NewDefaultWorkingDirLocker(),
+	}
+
+	repoDir, cleanup := TempDir(t)
+	defer cleanup()
+	When(mockWorkingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(repoDir, false, nil)
+	When(mockLocker.TryLock(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsPullRequest(),
+		matchers.AnyModelsUser(),
+		AnyString(),
+		matchers.AnyModelsProject(),
+	)).ThenReturn(&events.TryLockResponse{
+		LockAcquired: true,
+		LockKey:      "lock-key",
+	}, nil)
+
+	ctx := models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(t),
+		Steps:      []valid.Step{{StepName: "plan"}},
+		Workspace:  "default",
+		RepoRelDir: ".",
+	}
+	When(mockPlan.Run(ctx, nil, repoDir, make(map[string]string))).ThenReturn("plan", nil)
+	When(mockShow.Run(ctx, nil, repoDir, make(map[string]string))).Then(func(_ []Param) ReturnValues {
+		Ok(t, ioutil.WriteFile(filepath.Join(repoDir, "default.json"), []byte("{}"), 0600))
+		return []ReturnValue{"{}", nil}
+	})
+
+	res := runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	Equals(t, "https://plans/default", res.PlanSuccess.PlanJSONURL)
+	mockShow.VerifyWasCalledOnce().Run(ctx, nil, repoDir, make(map[string]string))
+
+	// Plans aren't linked to if they couldn't be rendered.
+	Ok(t, os.Remove(filepath.Join(repoDir, "default.json")))
+	When(mockShow.Run(ctx, nil, repoDir, make(map[string]string))).ThenReturn("", errors.New("show failed"))
+	res = runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	Equals(t, "", res.PlanSuccess.PlanJSONURL)
+}
+
+// Test that plans are saved to the plan store after planning and deleted from
+// it once applied.
+func TestDefaultProjectCommandRunner_PlanStore(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockPlan := mocks.NewMockStepRunner()
+	mockApply := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+	storeDir, cleanupStore := TempDir(t)
+	defer cleanupStore()
+	planStore := planstore.New(&planstore.FileBackend{Dir: storeDir}, "")
+
+	runner := events.DefaultProjectCommandRunner{
+		Locker:           mockLocker,
+		LockURLGenerator: mockURLGenerator{},
+		PlanStepRunner:   mockPlan,
+		ApplyStepRunner:  mockApply,
+		PlanStore:        planStore,
+		WorkingDir:       mockWorkingDir,
+		Webhooks:         mocks.NewMockWebhooksSender(),
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+	}
+
+	repoDir, cleanup := TempDir(t)
+	defer cleanup()
+	When(mockWorkingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(repoDir, false, nil)
+	When(mockWorkingDir.GetWorkingDir(
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(repoDir, nil)
+	When(mockLocker.TryLock(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsPullRequest(),
+		matchers.AnyModelsUser(),
+		AnyString(),
+		matchers.AnyModelsProject(),
+	)).ThenReturn(&events.TryLockResponse{
+		LockAcquired: true,
+		LockKey:      "lock-key",
+	}, nil)
+
+	ctx := models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(t),
+		Pull:       models.PullRequest{Num: 1, HeadCommit: "sha", BaseRepo: models.Repo{FullName: "owner/repo"}},
+		Steps:      []valid.Step{{StepName: "plan"}},
+		Workspace:  "default",
+		RepoRelDir: ".",
+	}
+	When(mockPlan.Run(ctx, nil, repoDir, make(map[string]string))).Then(func(_ []Param) ReturnValues {
+		Ok(t, ioutil.WriteFile(filepath.Join(repoDir, "default.tfplan"), []byte("plan"), 0600))
+		return []ReturnValue{"plan", nil}
+	})
+
+	res := runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	plans, err := planStore.List("owner/repo", 1)
+	Ok(t, err)
+	Equals(t, 1, len(plans))
+	Equals(t, "default.tfplan", plans[0].PlanFile)
+
+	ctx.Steps = []valid.Step{{StepName: "apply"}}
+	When(mockApply.Run(ctx, nil, repoDir, make(map[string]string))).ThenReturn("applied", nil)
+	res = runner.Apply(ctx)
+	Equals(t, "applied", res.ApplySuccess)
+	plans, err = planStore.List("owner/repo", 1)
+	Ok(t, err)
+	Equals(t, 0, len(plans))
+}
+
+// Test that encrypted plans are decrypted for apply and encrypted again if
+// apply fails.
+func TestDefaultProjectCommandRunner_ApplyEncryptedPlan(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockApply := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	encryptor, err := runtime.NewAESGCMPlanEncryptor([]byte("0123456789abcdef"))
+	Ok(t, err)
+
+	runner := events.DefaultProjectCommandRunner{
+		ApplyStepRunner:  mockApply,
+		PlanEncryptor:    encryptor,
+		WorkingDir:       mockWorkingDir,
+		Webhooks:         mocks.NewMockWebhooksSender(),
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+	}
+
+	repoDir, cleanup := TempDir(t)
+	defer cleanup()
+	planPath := filepath.Join(repoDir, "default.tfplan")
+	Ok(t, ioutil.WriteFile(planPath, []byte("plan"), 0600))
+	Ok(t, encryptor.Encrypt(planPath))
+	When(mockWorkingDir.GetWorkingDir(
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(repoDir, nil)
+
+	ctx := models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(t),
+		Steps:      []valid.Step{{StepName: "apply"}},
+		Workspace:  "default",
+		RepoRelDir: ".",
+	}
+	var planDuringApply []byte
+	When(mockApply.Run(ctx, nil, repoDir, make(map[string]string))).Then(func(_ []Param) ReturnValues {
+		planDuringApply, _ = ioutil.ReadFile(planPath)
+		return []ReturnValue{"", errors.New("apply failed")}
+	})
+
+	res := runner.Apply(ctx)
+	Assert(t, res.Error != nil, "exp apply error")
+	Equals(t, "plan", string(planDuringApply))
+	contents, err := ioutil.ReadFile(planPath)
+	Ok(t, err)
+	Assert(t, string(contents) != "plan", "exp plan to be encrypted again")
+}
+
+// Test that cloud credentials are passed to steps as environment variables.
+func TestDefaultProjectCommandRunner_PlanCloudCredentials(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockPlan := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+	mockCredentials := credmocks.NewMockProvider()
+	mockSender := mocks.NewMockWebhooksSender()
+
+	runner := events.DefaultProjectCommandRunner{
+		Locker:              mockLocker,
+		LockURLGenerator:    mockURLGenerator{},
+		PlanStepRunner:      mockPlan,
+		CredentialsProvider: mockCredentials,
+		WorkingDir:          mockWorkingDir,
+		WorkingDirLocker:    events.NewDefaultWorkingDirLocker(),
+		Webhooks:            mockSender,
+	}
+
+	repoDir, cleanup := TempDir(t)
+	defer cleanup()
+	When(mockWorkingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(repoDir, false, nil)
+	When(mockLocker.TryLock(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsPullRequest(),
+		matchers.AnyModelsUser(),
+		AnyString(),
+		matchers.AnyModelsProject(),
+	)).ThenReturn(&events.TryLockResponse{
+		LockAcquired: true,
+		LockKey:      "lock-key",
+		UnlockFn:     func() error { return nil },
+	}, nil)
+
+	ctx := models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(t),
+		Steps:      []valid.Step{{StepName: "plan"}},
+		Workspace:  "default",
+		RepoRelDir: ".",
+	}
+	expEnvs := map[string]string{"AWS_ACCESS_KEY_ID": "key-id"}
+	When(mockCredentials.Env(ctx)).ThenReturn(expEnvs, nil)
+	When(mockPlan.Run(ctx, nil, repoDir, expEnvs)).ThenReturn("plan", nil)
+
+	res := runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	Equals(t, "plan", res.PlanSuccess.TerraformOutput)
+
+	// Errors getting credentials fail the plan.
+	When(mockCredentials.Env(ctx)).ThenReturn(nil, errors.New("sts unavailable"))
+	res = runner.Plan(ctx)
+	ErrContains(t, "sts unavailable", res.Error)
+	// Each plan sends a started and finished webhook.
+	_, results := mockSender.VerifyWasCalled(Times(4)).Send(matchers.AnyPtrToLoggingSimpleLogger(), matchers.AnyWebhooksApplyResult()).GetAllCapturedArguments()
+	Equals(t, webhooks.PlanStartedEvent, results[2].Event)
+	result := results[3]
+	Equals(t, webhooks.PlanFinishedEvent, result.Event)
+	Equals(t, models.PlanCommand, result.Command)
+	Equals(t, false, result.Success)
+	Assert(t, strings.Contains(result.Error, "sts unavailable"), "exp error in webhook, got %q", result.Error)
+}
+
+func TestDefaultProjectCommandRunner_PlanVaultSecret(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockPlan := mocks.NewMockStepRunner()
+	mockRun := mocks.NewMockCustomStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+	mockVault := vaultmocks.NewMockClient()
+
+	runner := events.DefaultProjectCommandRunner{
+		Webhooks:         mocks.NewMockWebhooksSender(),
+		Locker:           mockLocker,
+		LockURLGenerator: mockURLGenerator{},
+		PlanStepRunner:   mockPlan,
+		RunStepRunner:    mockRun,
+		VaultClient:      mockVault,
+		WorkingDir:       mockWorkingDir,
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+	}
+
+	repoDir, cleanup := TempDir(t)
+	defer cleanup()
+	When(mockWorkingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(repoDir, false, nil)
+	When(mockLocker.TryLock(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsPullRequest(),
+		matchers.AnyModelsUser(),
+		AnyString(),
+		matchers.AnyModelsProject(),
+	)).ThenReturn(&events.TryLockResponse{
+		LockAcquired: true,
+		LockKey:      "lock-key",
+		UnlockFn:     func() error { return nil },
+	}, nil)
+
+	ctx := models.ProjectCommandContext{
+		Log: logging.NewNoopLogger(t),
+		Steps: []valid.Step{
+			{StepName: "env", EnvVarName: "DB_PASSWORD", VaultPath: "secret/data/db", VaultKey: "password"},
+			{StepName: "run", RunCommand: "echo $DB_PASSWORD"},
+			{StepName: "plan"},
+		},
+		Workspace:  "default",
+		RepoRelDir: ".",
+	}
+	expEnvs := map[string]string{"DB_PASSWORD": "hunter2"}
+	When(mockVault.Read("secret/data/db", "password")).ThenReturn("hunter2", nil)
+	When(mockRun.Run(ctx, "echo $DB_PASSWORD", repoDir, expEnvs)).ThenReturn("hunter2", nil)
+	When(mockPlan.Run(ctx, nil, repoDir, expEnvs)).ThenReturn("password is hunter2", errors.New("error: hunter2"))
+
+	// The secret should be masked in both the output and the error.
+	res := runner.Plan(ctx)
+	ErrEquals(t, "error: ***\n***\npassword is ***", res.Error)
+
+	// Errors reading the secret fail the plan.
+	When(mockVault.Read("secret/data/db", "password")).ThenReturn("", errors.New("permission denied"))
+	res = runner.Plan(ctx)
+	ErrContains(t, `reading Vault secret for env step "DB_PASSWORD": permission denied`, res.Error)
+
+	// So does referencing a secret without Vault configured.
+	runner.VaultClient = nil
+	res = runner.Plan(ctx)
+	ErrContains(t, `env step "DB_PASSWORD" references a Vault secret but Vault isn't configured`, res.Error)
+}
+
+// Test that the output of the steps is streamed and linked to in the result.
+func TestDefaultProjectCommandRunner_PlanStreamsOutput(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockPlan := mocks.NewMockStepRunner()
+	mockRun := mocks.NewMockCustomStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+	mockVault := vaultmocks.NewMockClient()
+	mockOutputURLs := mocks.NewMockOutputURLGenerator()
+	outputs := jobs.NewOutputStore(jobs.DefaultMaxOutputs)
+
+	runner := events.DefaultProjectCommandRunner{
+		Webhooks:           mocks.NewMockWebhooksSender(),
+		Locker:             mockLocker,
+		LockURLGenerator:   mockURLGenerator{},
+		PlanStepRunner:     mockPlan,
+		RunStepRunner:      mockRun,
+		VaultClient:        mockVault,
+		WorkingDir:         mockWorkingDir,
+		WorkingDirLocker:   events.NewDefaultWorkingDirLocker(),
+		Outputs:            outputs,
+		OutputURLGenerator: mockOutputURLs,
+	}
+
+	repoDir, cleanup := TempDir(t)
+	defer cleanup()
+	When(mockWorkingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(repoDir, false, nil)
+	When(mockLocker.TryLock(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsPullRequest(),
+		matchers.AnyModelsUser(),
+		AnyString(),
+		matchers.AnyModelsProject(),
+	)).ThenReturn(&events.TryLockResponse{
+		LockAcquired: true,
+		LockKey:      "lock-key",
+		UnlockFn:     func() error { return nil },
+	}, nil)
+	When(mockOutputURLs.GenerateOutputURL(AnyString())).ThenReturn("https://atlantis/logs/id")
+
+	ctx := models.ProjectCommandContext{
+		Log: logging.NewNoopLogger(t),
+		Steps: []valid.Step{
+			{StepName: "env", EnvVarName: "DB_PASSWORD", VaultPath: "secret/data/db", VaultKey: "password"},
+			{StepName: "run", RunCommand: "echo $DB_PASSWORD"},
+			{StepName: "plan"},
+		},
+		Workspace:  "default",
+		RepoRelDir: ".",
+		Pull: models.PullRequest{
+			Num:      1,
+			BaseRepo: models.Repo{FullName: "owner/repo"},
+		},
+	}
+	expEnvs := map[string]string{"DB_PASSWORD": "hunter2"}
+	When(mockVault.Read("secret/data/db", "password")).ThenReturn("hunter2", nil)
+	When(mockRun.Run(ctx, "echo $DB_PASSWORD", repoDir, expEnvs)).ThenReturn("hunter2", nil)
+	// The terraform client writes to the output attached to the project's
+	// dir while the plan step runs.
+	When(mockPlan.Run(ctx, nil, repoDir, expEnvs)).Then(func(_ []Param) ReturnValues {
+		w := outputs.Writer(repoDir)
+		Assert(t, w != nil, "expected output to be attached during the plan step")
+		w.Write([]byte("plan with hunter2\n")) // nolint: errcheck
+		return ReturnValues{"plan with hunter2", nil}
+	})
+
+	res := runner.Plan(ctx)
+	Ok(t, res.Error)
+	Equals(t, "https://atlantis/logs/id", res.OutputURL)
+	Assert(t, outputs.Writer(repoDir) == nil, "expected output to be detached after the plan")
+
+	infos := outputs.List()
+	Equals(t, 1, len(infos))
+	Equals(t, "plan", infos[0].Command)
+	Equals(t, "owner/repo", infos[0].Repo)
+	Assert(t, infos[0].Finished, "expected output to be finished")
+	mockOutputURLs.VerifyWasCalledOnce().GenerateOutputURL(infos[0].ID)
+
+	output, _ := outputs.Get(infos[0].ID)
+	lines, _, _ := output.Subscribe()
+	Equals(t, []string{"***", "plan with ***"}, lines)
+}
+
 // Test what happens if there's no working dir. This signals that the project
 // was never planned.
 func TestDefaultProjectCommandRunner_ApplyNotCloned(t *testing.T) {
 	mockWorkingDir := mocks.NewMockWorkingDir()
 	runner := &events.DefaultProjectCommandRunner{
+		Webhooks:   mocks.NewMockWebhooksSender(),
 		WorkingDir: mockWorkingDir,
 	}
 	ctx := models.ProjectCommandContext{}
@@ -148,6 +687,7 @@ func TestDefaultProjectCommandRunner_ApplyNotApproved(t *testing.T) {
 	mockWorkingDir := mocks.NewMockWorkingDir()
 	mockApproved := mocks2.NewMockPullApprovedChecker()
 	runner := &events.DefaultProjectCommandRunner{
+		Webhooks:            mocks.NewMockWebhooksSender(),
 		WorkingDir:          mockWorkingDir,
 		PullApprovedChecker: mockApproved,
 		WorkingDirLocker:    events.NewDefaultWorkingDirLocker(),
@@ -164,11 +704,246 @@ func TestDefaultProjectCommandRunner_ApplyNotApproved(t *testing.T) {
 	Equals(t, "Pull request must be approved by at least one person other than the author before running apply.", res.Failure)
 }
 
+// Test that approved_count only counts the approvals that are configured to
+// count.
+func TestDefaultProjectCommandRunner_ApplyApprovedCount(t *testing.T) {
+	head := "sha2"
+	approvals := []models.Approval{
+		{Username: "author", CommitSHA: head},
+		{Username: "alice", CommitSHA: "sha1"},
+		{Username: "bob", CommitSHA: head},
+		{Username: "Bob", CommitSHA: head},
+	}
+	cases := []struct {
+		description string
+		approvals   valid.Approvals
+		noPlan      bool
+		expFailure  string
+		expErr      string
+	}{
+		{
+			description: "enough approvals",
+			approvals:   valid.Approvals{Count: 4},
+			expFailure:  "Pull request must be approved by at least 4 reviewer(s) before running apply, it has 3 approval(s).",
+		},
+		{
+			description: "all approvals count",
+			approvals:   valid.Approvals{Count: 3},
+		},
+		{
+			description: "exclude author",
+			approvals:   valid.Approvals{Count: 3, ExcludeAuthor: true},
+			expFailure:  "Pull request must be approved by at least 3 reviewer(s) before running apply, it has 2 approval(s) from someone other than the author.",
+		},
+		{
+			description: "exclude author and pre plan",
+			approvals:   valid.Approvals{Count: 2, ExcludeAuthor: true, ExcludePrePlan: true},
+			expFailure:  "Pull request must be approved by at least 2 reviewer(s) before running apply, it has 1 approval(s) from someone other than the author since the latest plan.",
+		},
+		{
+			description: "exclude pre plan without a plan",
+			approvals:   valid.Approvals{Count: 1, ExcludePrePlan: true},
+			noPlan:      true,
+			expErr:      "counting pull request approvals: no plan found to compare approvals to–did you run plan?",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			mockWorkingDir := mocks.NewMockWorkingDir()
+			mockApprovals := mocks2.NewMockPullApprovalsGetter()
+			mockApply := mocks.NewMockStepRunner()
+			runner := &events.DefaultProjectCommandRunner{
+				WorkingDir:          mockWorkingDir,
+				PullApprovalsGetter: mockApprovals,
+				ApplyStepRunner:     mockApply,
+				WorkingDirLocker:    events.NewDefaultWorkingDirLocker(),
+				Webhooks:            mocks.NewMockWebhooksSender(),
+			}
+			ctx := models.ProjectCommandContext{
+				Log:               logging.NewNoopLogger(t),
+				Pull:              models.PullRequest{Author: "author", HeadCommit: head},
+				ApplyRequirements: []string{"approved_count"},
+				Approvals:         c.approvals,
+				Steps:             []valid.Step{{StepName: "apply"}},
+			}
+			tmp, cleanup := TempDir(t)
+			defer cleanup()
+			When(mockWorkingDir.GetWorkingDir(ctx.BaseRepo, ctx.Pull, ctx.Workspace)).ThenReturn(tmp, nil)
+			When(mockApprovals.GetApprovals(ctx.BaseRepo, ctx.Pull)).ThenReturn(approvals, nil)
+			When(mockApply.Run(ctx, nil, tmp, make(map[string]string))).ThenReturn("applied", nil)
+			if !c.noPlan {
+				Ok(t, ioutil.WriteFile(filepath.Join(tmp, runtime.GetPlanFilename(ctx.Workspace, ctx.ProjectName)), nil, 0600))
+			}
+
+			res := runner.Apply(ctx)
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, res.Error)
+				return
+			}
+			Equals(t, c.expFailure, res.Failure)
+			if c.expFailure == "" {
+				Equals(t, "applied", res.ApplySuccess)
+			}
+		})
+	}
+}
+
+func TestDefaultProjectCommandRunner_ApplyPipelineSucceeded(t *testing.T) {
+	head := "sha2"
+	cases := []struct {
+		description string
+		cfg         valid.Pipeline
+		pipeline    models.Pipeline
+		expFailure  string
+	}{
+		{
+			description: "no pipeline",
+			expFailure:  "Pull request must have a pipeline for its latest commit before running apply.",
+		},
+		{
+			description: "pipeline for old commit",
+			pipeline:    models.Pipeline{ID: 1, SHA: "sha1"},
+			expFailure:  "Pull request must have a pipeline for its latest commit before running apply.",
+		},
+		{
+			description: "jobs succeeded",
+			pipeline: models.Pipeline{ID: 1, SHA: head, Jobs: []models.PipelineJob{
+				{Name: "test", Status: "success"},
+				{Name: "lint", Status: "skipped"},
+			}},
+		},
+		{
+			description: "job failed",
+			pipeline: models.Pipeline{ID: 1, SHA: head, Jobs: []models.PipelineJob{
+				{Name: "test", Status: "failed"},
+				{Name: "lint", Status: "running"},
+			}},
+			expFailure: "Pull request's pipeline must succeed before running apply. Jobs that didn't: test (failed), lint (running).",
+		},
+		{
+			description: "job allowed to fail",
+			pipeline: models.Pipeline{ID: 1, SHA: head, Jobs: []models.PipelineJob{
+				{Name: "test", Status: "success"},
+				{Name: "lint", Status: "failed", AllowFailure: true},
+			}},
+		},
+		{
+			description: "job allowed to fail is running",
+			pipeline: models.Pipeline{ID: 1, SHA: head, Jobs: []models.PipelineJob{
+				{Name: "lint", Status: "running", AllowFailure: true},
+			}},
+			expFailure: "Pull request's pipeline must succeed before running apply. Jobs that didn't: lint (running).",
+		},
+		{
+			description: "required jobs",
+			cfg:         valid.Pipeline{RequiredJobs: []string{"lint", "test"}},
+			pipeline: models.Pipeline{ID: 1, SHA: head, Jobs: []models.PipelineJob{
+				{Name: "lint", Status: "failed", AllowFailure: true},
+			}},
+			expFailure: "Pull request's pipeline must succeed before running apply. Jobs that didn't: lint (failed), test (missing).",
+		},
+		{
+			description: "allowed statuses",
+			cfg:         valid.Pipeline{AllowedStatuses: []string{"success", "manual"}},
+			pipeline: models.Pipeline{ID: 1, SHA: head, Jobs: []models.PipelineJob{
+				{Name: "test", Status: "success"},
+				{Name: "deploy", Status: "manual"},
+				{Name: "lint", Status: "skipped"},
+			}},
+			expFailure: "Pull request's pipeline must succeed before running apply. Jobs that didn't: lint (skipped).",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			mockWorkingDir := mocks.NewMockWorkingDir()
+			mockPipeline := mocks2.NewMockPullPipelineGetter()
+			mockApply := mocks.NewMockStepRunner()
+			runner := &events.DefaultProjectCommandRunner{
+				WorkingDir:         mockWorkingDir,
+				PullPipelineGetter: mockPipeline,
+				ApplyStepRunner:    mockApply,
+				WorkingDirLocker:   events.NewDefaultWorkingDirLocker(),
+				Webhooks:           mocks.NewMockWebhooksSender(),
+			}
+			ctx := models.ProjectCommandContext{
+				Log:               logging.NewNoopLogger(t),
+				Pull:              models.PullRequest{HeadCommit: head},
+				ApplyRequirements: []string{"pipeline_succeeded"},
+				Pipeline:          c.cfg,
+				Steps:             []valid.Step{{StepName: "apply"}},
+			}
+			tmp, cleanup := TempDir(t)
+			defer cleanup()
+			When(mockWorkingDir.GetWorkingDir(ctx.BaseRepo, ctx.Pull, ctx.Workspace)).ThenReturn(tmp, nil)
+			When(mockPipeline.GetPipeline(ctx.BaseRepo, ctx.Pull)).ThenReturn(c.pipeline, nil)
+			When(mockApply.Run(ctx, nil, tmp, make(map[string]string))).ThenReturn("applied", nil)
+
+			res := runner.Apply(ctx)
+			Equals(t, c.expFailure, res.Failure)
+			if c.expFailure == "" {
+				Equals(t, "applied", res.ApplySuccess)
+			}
+		})
+	}
+}
+
+// Test that if code owner approval is required and owners are missing we give
+// an error.
+func TestDefaultProjectCommandRunner_ApplyCodeOwnersNotApproved(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockChecker := mocks.NewMockCodeOwnersChecker()
+	runner := &events.DefaultProjectCommandRunner{
+		Webhooks:          mocks.NewMockWebhooksSender(),
+		WorkingDir:        mockWorkingDir,
+		CodeOwnersChecker: mockChecker,
+		WorkingDirLocker:  events.NewDefaultWorkingDirLocker(),
+	}
+	ctx := models.ProjectCommandContext{
+		ApplyRequirements: []string{"codeowners_approved"},
+	}
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	When(mockWorkingDir.GetWorkingDir(ctx.BaseRepo, ctx.Pull, ctx.Workspace)).ThenReturn(tmp, nil)
+	When(mockChecker.MissingApprovals(ctx, tmp)).ThenReturn([][]string{{"@org/infra", "@alice"}, {"@bob"}}, nil)
+
+	res := runner.Apply(ctx)
+	Equals(t, "Pull request must be approved by code owners before running apply. Missing approval from: @org/infra or @alice, @bob.", res.Failure)
+}
+
+// Test that if code owner approval is required and the base branch has no
+// CODEOWNERS file we give an error.
+func TestDefaultProjectCommandRunner_ApplyNoCodeOwnersFile(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockChecker := mocks.NewMockCodeOwnersChecker()
+	runner := &events.DefaultProjectCommandRunner{
+		Webhooks:          mocks.NewMockWebhooksSender(),
+		WorkingDir:        mockWorkingDir,
+		CodeOwnersChecker: mockChecker,
+		WorkingDirLocker:  events.NewDefaultWorkingDirLocker(),
+	}
+	ctx := models.ProjectCommandContext{
+		Pull:              models.PullRequest{BaseBranch: "main"},
+		ApplyRequirements: []string{"codeowners_approved"},
+	}
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	When(mockWorkingDir.GetWorkingDir(ctx.BaseRepo, ctx.Pull, ctx.Workspace)).ThenReturn(tmp, nil)
+	When(mockChecker.MissingApprovals(ctx, tmp)).ThenReturn(nil, codeowners.ErrNotFound)
+
+	res := runner.Apply(ctx)
+	Equals(t, "Pull request must be approved by code owners before running apply, but the main branch has no CODEOWNERS file.", res.Failure)
+}
+
 // Test that if mergeable is required and the PR isn't mergeable we give an error.
 func TestDefaultProjectCommandRunner_ApplyNotMergeable(t *testing.T) {
 	RegisterMockTestingT(t)
 	mockWorkingDir := mocks.NewMockWorkingDir()
 	runner := &events.DefaultProjectCommandRunner{
+		Webhooks:         mocks.NewMockWebhooksSender(),
 		WorkingDir:       mockWorkingDir,
 		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
 	}
@@ -189,6 +964,7 @@ func TestDefaultProjectCommandRunner_ApplyDiverged(t *testing.T) {
 	RegisterMockTestingT(t)
 	mockWorkingDir := mocks.NewMockWorkingDir()
 	runner := &events.DefaultProjectCommandRunner{
+		Webhooks:         mocks.NewMockWebhooksSender(),
 		WorkingDir:       mockWorkingDir,
 		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
 	}
@@ -203,6 +979,241 @@ func TestDefaultProjectCommandRunner_ApplyDiverged(t *testing.T) {
 	Equals(t, "Default branch must be rebased onto pull request before running apply.", res.Failure)
 }
 
+// Test that if all_plans_succeeded is required and other projects weren't
+// planned we give an error.
+func TestDefaultProjectCommandRunner_ApplyUnplannedProjects(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	runner := &events.DefaultProjectCommandRunner{
+		Webhooks:         mocks.NewMockWebhooksSender(),
+		WorkingDir:       mockWorkingDir,
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+	}
+	ctx := models.ProjectCommandContext{
+		ApplyRequirements: []string{"all_plans_succeeded"},
+		UnplannedProjects: []string{"dir: `staging` workspace: `default`", "project: `prod` dir: `prod` workspace: `default`"},
+	}
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	When(mockWorkingDir.GetWorkingDir(ctx.BaseRepo, ctx.Pull, ctx.Workspace)).ThenReturn(tmp, nil)
+
+	res := runner.Apply(ctx)
+	Equals(t, "All projects modified in the pull request must be planned successfully before running apply. "+
+		"Missing a successful plan: dir: `staging` workspace: `default`, project: `prod` dir: `prod` workspace: `default`.", res.Failure)
+}
+
+// Test that if base_unchanged is required and the base branch has new
+// commits since the plan we give an error.
+func TestDefaultProjectCommandRunner_ApplyBaseAdvanced(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	runner := &events.DefaultProjectCommandRunner{
+		Webhooks:         mocks.NewMockWebhooksSender(),
+		WorkingDir:       mockWorkingDir,
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+	}
+	ctx := models.ProjectCommandContext{
+		ApplyRequirements: []string{"base_unchanged"},
+		Pull:              models.PullRequest{BaseBranch: "main"},
+	}
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	When(mockWorkingDir.GetWorkingDir(ctx.BaseRepo, ctx.Pull, ctx.Workspace)).ThenReturn(tmp, nil)
+	When(mockWorkingDir.BaseAdvanced(ctx.Log, ctx.HeadRepo, ctx.Pull, tmp)).ThenReturn(true, nil)
+
+	res := runner.Apply(ctx)
+	Equals(t, "Base branch \"main\" has new commits since the plan was generated. Run `atlantis plan` again before running apply.", res.Failure)
+}
+
+// Test that if destroy_confirmed is required plans that destroy resources are
+// only applied with --allow-destroy.
+func TestDefaultProjectCommandRunner_ApplyDestroyConfirmed(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockApply := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockRisks := mocks2.NewMockRiskChecker()
+	runner := &events.DefaultProjectCommandRunner{
+		ApplyStepRunner:  mockApply,
+		RiskChecker:      mockRisks,
+		Webhooks:         mocks.NewMockWebhooksSender(),
+		WorkingDir:       mockWorkingDir,
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+	}
+	ctx := models.ProjectCommandContext{
+		Log:               logging.NewNoopLogger(t),
+		Steps:             []valid.Step{{StepName: "apply"}},
+		Workspace:         "default",
+		RepoRelDir:        ".",
+		ApplyRequirements: []string{"destroy_confirmed"},
+	}
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	When(mockWorkingDir.GetWorkingDir(ctx.BaseRepo, ctx.Pull, ctx.Workspace)).ThenReturn(tmp, nil)
+	When(mockRisks.Check(ctx, tmp)).ThenReturn([]models.ResourceChanges{
+		{Action: models.ReplaceResourceAction, Addresses: []string{"aws_db_instance.db"}},
+		{Action: models.DeleteResourceAction, Addresses: []string{"aws_s3_bucket.logs"}},
+	}, nil)
+
+	res := runner.Apply(ctx)
+	Equals(t, "Plan destroys or replaces resources so applying it must be confirmed by commenting `atlantis apply --allow-destroy` with the same flags. "+
+		"Destroyed or replaced: `aws_db_instance.db`, `aws_s3_bucket.logs`.", res.Failure)
+	mockApply.VerifyWasCalled(Never()).Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString(), matchers.AnyMapOfStringToString())
+
+	ctx.AllowDestroy = true
+	When(mockApply.Run(ctx, nil, tmp, make(map[string]string))).ThenReturn("applied", nil)
+	res = runner.Apply(ctx)
+	Equals(t, "", res.Failure)
+	Equals(t, "applied", res.ApplySuccess)
+	mockRisks.VerifyWasCalledOnce().Check(matchers.AnyModelsProjectCommandContext(), AnyString())
+}
+
+// Test that the resources a plan destroys or replaces are returned.
+func TestDefaultProjectCommandRunner_PlanRiskyChanges(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockPlan := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+	mockRisks := mocks2.NewMockRiskChecker()
+
+	runner := events.DefaultProjectCommandRunner{
+		Webhooks:         mocks.NewMockWebhooksSender(),
+		Locker:           mockLocker,
+		LockURLGenerator: mockURLGenerator{},
+		PlanStepRunner:   mockPlan,
+		RiskChecker:      mockRisks,
+		WorkingDir:       mockWorkingDir,
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+	}
+
+	repoDir, cleanup := TempDir(t)
+	defer cleanup()
+	When(mockWorkingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(repoDir, false, nil)
+	When(mockLocker.TryLock(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsPullRequest(),
+		matchers.AnyModelsUser(),
+		AnyString(),
+		matchers.AnyModelsProject(),
+	)).ThenReturn(&events.TryLockResponse{
+		LockAcquired: true,
+		LockKey:      "lock-key",
+	}, nil)
+
+	ctx := models.ProjectCommandContext{
+		Log:               logging.NewNoopLogger(t),
+		Steps:             []valid.Step{{StepName: "plan"}},
+		Workspace:         "default",
+		RepoRelDir:        ".",
+		ApplyRequirements: []string{"destroy_confirmed"},
+	}
+	changes := []models.ResourceChanges{
+		{Action: models.DeleteResourceAction, Addresses: []string{"aws_s3_bucket.logs"}},
+	}
+	When(mockPlan.Run(ctx, nil, repoDir, make(map[string]string))).ThenReturn("plan", nil)
+	When(mockRisks.Check(ctx, repoDir)).ThenReturn(changes, nil)
+
+	res := runner.Plan(ctx)
+	Ok(t, res.Error)
+	Equals(t, changes, res.PlanSuccess.RiskyChanges)
+	Equals(t, true, res.PlanSuccess.RequiresAllowDestroy)
+
+	// Errors checking the plan don't fail it.
+	When(mockRisks.Check(ctx, repoDir)).ThenReturn(nil, errors.New("show failed"))
+	res = runner.Plan(ctx)
+	Ok(t, res.Error)
+	Equals(t, 0, len(res.PlanSuccess.RiskyChanges))
+	Equals(t, false, res.PlanSuccess.RequiresAllowDestroy)
+}
+
+func TestDefaultProjectCommandRunner_PlanLint(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockPlan := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+	mockLinter := mocks2.NewMockLinter()
+
+	runner := events.DefaultProjectCommandRunner{
+		Webhooks:         mocks.NewMockWebhooksSender(),
+		Locker:           mockLocker,
+		LockURLGenerator: mockURLGenerator{},
+		PlanStepRunner:   mockPlan,
+		Linter:           mockLinter,
+		WorkingDir:       mockWorkingDir,
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+	}
+
+	repoDir, cleanup := TempDir(t)
+	defer cleanup()
+	When(mockWorkingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(repoDir, false, nil)
+	unlocked := false
+	When(mockLocker.TryLock(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsPullRequest(),
+		matchers.AnyModelsUser(),
+		AnyString(),
+		matchers.AnyModelsProject(),
+	)).ThenReturn(&events.TryLockResponse{
+		LockAcquired: true,
+		LockKey:      "lock-key",
+		UnlockFn: func() error {
+			unlocked = true
+			return nil
+		},
+	}, nil)
+
+	ctx := models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(t),
+		Steps:      []valid.Step{{StepName: "plan"}},
+		Workspace:  "default",
+		RepoRelDir: ".",
+		Lint:       &valid.Lint{},
+	}
+	findings := models.LintFindings{
+		{Rule: "terraform_unused_declarations", Severity: "warning", Message: "variable \"a\" is declared but not used", Filename: "variables.tf", Line: 3},
+	}
+	When(mockPlan.Run(ctx, nil, repoDir, make(map[string]string))).ThenReturn("plan", nil)
+	When(mockLinter.Lint(ctx, repoDir, repoDir)).ThenReturn(findings, nil)
+
+	// The findings are only warnings by default.
+	res := runner.Plan(ctx)
+	Ok(t, res.Error)
+	Equals(t, "", res.Failure)
+	Equals(t, findings, res.PlanSuccess.LintFindings)
+	Equals(t, false, unlocked)
+
+	// Blocking findings stop the plan.
+	ctx.Lint = &valid.Lint{Blocking: true}
+	When(mockLinter.Lint(ctx, repoDir, repoDir)).ThenReturn(findings, nil)
+	res = runner.Plan(ctx)
+	Ok(t, res.Error)
+	Assert(t, res.PlanSuccess == nil, "exp no plan")
+	Equals(t, "tflint found issues so the plan wasn't run:\n\n**Warning**\n\n* `variables.tf:3` variable \"a\" is declared but not used (`terraform_unused_declarations`)\n", res.Failure)
+	Equals(t, true, unlocked)
+	mockPlan.VerifyWasCalled(Never()).Run(ctx, nil, repoDir, make(map[string]string))
+
+	// Blocking lint without findings plans.
+	When(mockLinter.Lint(ctx, repoDir, repoDir)).ThenReturn(nil, nil)
+	When(mockPlan.Run(ctx, nil, repoDir, make(map[string]string))).ThenReturn("plan", nil)
+	res = runner.Plan(ctx)
+	Ok(t, res.Error)
+	Equals(t, "plan", res.PlanSuccess.TerraformOutput)
+
+	// Errors running tflint fail the plan.
+	When(mockLinter.Lint(ctx, repoDir, repoDir)).ThenReturn(nil, errors.New("tflint not found"))
+	res = runner.Plan(ctx)
+	ErrEquals(t, "linting: tflint not found", res.Error)
+}
+
 // Test that it runs the expected apply steps.
 func TestDefaultProjectCommandRunner_Apply(t *testing.T) {
 	cases := []struct {
@@ -382,7 +1393,7 @@ func TestDefaultProjectCommandRunner_RunEnvSteps(t *testing.T) {
 		EnvStepRunner:       &env,
 		PullApprovedChecker: nil,
 		WorkingDir:          mockWorkingDir,
-		Webhooks:            nil,
+		Webhooks:            mocks.NewMockWebhooksSender(),
 		WorkingDirLocker:    events.NewDefaultWorkingDirLocker(),
 	}
 
@@ -455,3 +1466,7 @@ type mockURLGenerator struct{}
 func (m mockURLGenerator) GenerateLockURL(lockID string) string {
 	return "https://" + lockID
 }
+
+func (m mockURLGenerator) GeneratePlanJSONURL(ctx models.ProjectCommandContext) string {
+	return "https://plans/" + ctx.Workspace
+}
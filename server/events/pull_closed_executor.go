@@ -39,6 +39,12 @@ type PullCleaner interface {
 	CleanUpPull(repo models.Repo, pull models.PullRequest) error
 }
 
+// PullWorkspaceDeleter deletes the Terraform workspaces created for pull
+// requests.
+type PullWorkspaceDeleter interface {
+	DeletePullWorkspaces(log logging.SimpleLogging, repo models.Repo, pull models.PullRequest)
+}
+
 // PullClosedExecutor executes the tasks required to clean up a closed pull
 // request.
 type PullClosedExecutor struct {
@@ -46,7 +52,10 @@ type PullClosedExecutor struct {
 	VCSClient  vcs.Client
 	WorkingDir WorkingDir
 	Logger     logging.SimpleLogging
-	DB         *db.BoltDB
+	DB         db.Database
+	// WorkspaceDeleter, if set, deletes the workspaces created for the pull
+	// request with atlantis workspace.
+	WorkspaceDeleter PullWorkspaceDeleter
 }
 
 type templatedProject struct {
@@ -61,6 +70,11 @@ var pullClosedTemplate = template.Must(template.New("").Parse(
 
 // CleanUpPull cleans up after a closed pull request.
 func (p *PullClosedExecutor) CleanUpPull(repo models.Repo, pull models.PullRequest) error {
+	// The workspaces are deleted first since it needs the clone.
+	if p.WorkspaceDeleter != nil {
+		p.WorkspaceDeleter.DeletePullWorkspaces(p.Logger, repo, pull)
+	}
+
 	if err := p.WorkingDir.Delete(repo, pull); err != nil {
 		return errors.Wrap(err, "cleaning workspace")
 	}
@@ -0,0 +1,164 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/db"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// DefaultPullLeaseTTL is how long a pull request stays leased to a server
+// that stopped renewing it, ex. because it died.
+const DefaultPullLeaseTTL = time.Minute
+
+// PullCoordinator ensures that only one of the Atlantis servers sharing a
+// database runs commands for a pull request at a time. The server running
+// commands for a pull request holds its lease and renews it until they
+// complete. If the server dies, the lease expires and the next command for
+// the pull request is run by whichever server receives it.
+type PullCoordinator struct {
+	Leases db.LeaseStore
+	// ServerID identifies this server in the leases it holds.
+	ServerID string
+	// TTL is how long a lease lasts unless it's renewed. Leases are renewed
+	// every third of TTL.
+	TTL    time.Duration
+	Logger logging.SimpleLogging
+	// Now returns the current time. It's only overridden in tests.
+	Now func() time.Time
+
+	// mutex guards held.
+	mutex sync.Mutex
+	// held are the leases this server holds by key. Commands run by this
+	// server for the same pull request share its lease.
+	held map[string]*heldLease
+}
+
+type heldLease struct {
+	commands int
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// PullBusyError is returned by Acquire when another server is running
+// commands for the pull request.
+type PullBusyError struct {
+	Lease db.Lease
+}
+
+func (e *PullBusyError) Error() string {
+	return fmt.Sprintf("pull request is leased to Atlantis server %q running %s until %s",
+		e.Lease.Holder, e.Lease.Command, e.Lease.ExpiresAt.Format(time.RFC3339))
+}
+
+// PullLease is the lease of a pull request acquired by this server.
+type PullLease struct {
+	// TakenOver is the expired lease of another server that was replaced,
+	// or nil. That server likely died while running TakenOver.Command.
+	TakenOver *db.Lease
+	release   func()
+	once      sync.Once
+}
+
+// Release releases the lease once the command is complete. Only the first
+// call has an effect.
+func (l *PullLease) Release() {
+	l.once.Do(l.release)
+}
+
+// NewPullCoordinator returns a PullCoordinator storing its leases in leases.
+func NewPullCoordinator(leases db.LeaseStore, serverID string, logger logging.SimpleLogging) *PullCoordinator {
+	return &PullCoordinator{
+		Leases:   leases,
+		ServerID: serverID,
+		TTL:      DefaultPullLeaseTTL,
+		Logger:   logger,
+		Now:      time.Now,
+	}
+}
+
+// Acquire leases the pull request pullNum of repo to this server while it
+// runs command. If another server holds its lease, it returns a
+// *PullBusyError.
+func (c *PullCoordinator) Acquire(repo models.Repo, pullNum int, command string) (*PullLease, error) {
+	key := fmt.Sprintf("%s/%s#%d", repo.VCSHost.Hostname, repo.FullName, pullNum)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.held == nil {
+		c.held = make(map[string]*heldLease)
+	}
+	if h, ok := c.held[key]; ok {
+		h.commands++
+		return &PullLease{release: func() { c.release(key) }}, nil
+	}
+
+	lease := db.Lease{
+		Key:       key,
+		Holder:    c.ServerID,
+		Command:   command,
+		ExpiresAt: c.Now().Add(c.TTL),
+	}
+	acquired, prev, err := c.Leases.TryAcquireLease(lease, c.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, &PullBusyError{Lease: *prev}
+	}
+	h := &heldLease{
+		commands: 1,
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	c.held[key] = h
+	go c.renew(key, h)
+
+	pullLease := &PullLease{release: func() { c.release(key) }}
+	// The server restarted if the lease was its own.
+	if prev != nil && prev.Holder != c.ServerID {
+		pullLease.TakenOver = prev
+	}
+	return pullLease, nil
+}
+
+// renew renews the lease of key every third of TTL until h is stopped.
+func (c *PullCoordinator) renew(key string, h *heldLease) {
+	defer close(h.stopped)
+	ticker := time.NewTicker(c.TTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+			renewed, err := c.Leases.RenewLease(key, c.ServerID, c.Now().Add(c.TTL))
+			if err != nil {
+				c.Logger.Warn("failed renewing lease of pull request %s: %s", key, err)
+				continue
+			}
+			if !renewed {
+				c.Logger.Err("lost lease of pull request %s to another Atlantis server while running commands for it", key)
+				return
+			}
+		}
+	}
+}
+
+func (c *PullCoordinator) release(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	h := c.held[key]
+	h.commands--
+	if h.commands > 0 {
+		return
+	}
+	delete(c.held, key)
+	close(h.stop)
+	<-h.stopped
+	if err := c.Leases.ReleaseLease(key, c.ServerID); err != nil {
+		c.Logger.Warn("failed releasing lease of pull request %s: %s", key, err)
+	}
+}
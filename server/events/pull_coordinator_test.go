@@ -0,0 +1,82 @@
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/db"
+	"github.com/runatlantis/atlantis/server/events/models/fixtures"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestPullCoordinator_Acquire(t *testing.T) {
+	leases := newTestLeaseStore(t)
+	now := time.Now()
+	server0 := newTestPullCoordinator(t, leases, "atlantis-0", &now)
+	server1 := newTestPullCoordinator(t, leases, "atlantis-1", &now)
+
+	lease, err := server0.Acquire(fixtures.GithubRepo, 1, "plan")
+	Ok(t, err)
+	Assert(t, lease.TakenOver == nil, "exp no lease to be taken over")
+	// Commands run by the same server share its lease.
+	other, err := server0.Acquire(fixtures.GithubRepo, 1, "apply")
+	Ok(t, err)
+	_, err = server1.Acquire(fixtures.GithubRepo, 1, "plan")
+	busyErr, ok := err.(*events.PullBusyError)
+	Assert(t, ok, "exp *PullBusyError, got %v", err)
+	Equals(t, "atlantis-0", busyErr.Lease.Holder)
+	Equals(t, "plan", busyErr.Lease.Command)
+	// Other pull requests aren't affected.
+	lease2, err := server1.Acquire(fixtures.GithubRepo, 2, "plan")
+	Ok(t, err)
+	lease2.Release()
+
+	lease.Release()
+	lease.Release()
+	_, err = server1.Acquire(fixtures.GithubRepo, 1, "plan")
+	Assert(t, err != nil, "exp lease to be held until all commands are complete")
+	other.Release()
+	lease, err = server1.Acquire(fixtures.GithubRepo, 1, "plan")
+	Ok(t, err)
+	Assert(t, lease.TakenOver == nil, "exp released lease not to be taken over")
+	lease.Release()
+}
+
+func TestPullCoordinator_AcquireTakesOverExpiredLease(t *testing.T) {
+	leases := newTestLeaseStore(t)
+	now := time.Now()
+	server0 := newTestPullCoordinator(t, leases, "atlantis-0", &now)
+	server1 := newTestPullCoordinator(t, leases, "atlantis-1", &now)
+
+	// atlantis-0 dies without releasing its lease.
+	_, err := server0.Acquire(fixtures.GithubRepo, 1, "apply")
+	Ok(t, err)
+	now = now.Add(2 * time.Hour)
+
+	lease, err := server1.Acquire(fixtures.GithubRepo, 1, "plan")
+	Ok(t, err)
+	defer lease.Release()
+	Assert(t, lease.TakenOver != nil, "exp expired lease to be taken over")
+	Equals(t, "atlantis-0", lease.TakenOver.Holder)
+	Equals(t, "apply", lease.TakenOver.Command)
+}
+
+func newTestLeaseStore(t *testing.T) db.LeaseStore {
+	s, err := miniredis.Run()
+	Ok(t, err)
+	t.Cleanup(s.Close)
+	return db.NewRedisWithClient(redis.NewClient(&redis.Options{Addr: s.Addr()}), db.DefaultKeyPrefix)
+}
+
+// newTestPullCoordinator returns a PullCoordinator whose clock reads now.
+// Its leases last an hour so they're never renewed during tests.
+func newTestPullCoordinator(t *testing.T, leases db.LeaseStore, serverID string, now *time.Time) *events.PullCoordinator {
+	c := events.NewPullCoordinator(leases, serverID, logging.NewNoopLogger(t))
+	c.TTL = time.Hour
+	c.Now = func() time.Time { return *now }
+	return c
+}
@@ -1,11 +1,32 @@
 package events
 
-import "github.com/runatlantis/atlantis/server/events/vcs"
+import (
+	"fmt"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/progress"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/jobs"
+)
 
 type PullUpdater struct {
 	HidePrevPlanComments bool
 	VCSClient            vcs.Client
 	MarkdownRenderer     *MarkdownRenderer
+	// ProgressInterval is how often the progress comments of plans and
+	// applies are edited. If 0, progress comments aren't created.
+	ProgressInterval time.Duration
+	// MaxCommentLengths are the maximum lengths of comments by VCS host.
+	// Hosts without one have no maximum.
+	MaxCommentLengths map[models.VCSHostType]int
+	// CommentOverflow is what to do with comments longer than the maximum.
+	// If empty, they're split by the VCS client.
+	CommentOverflow CommentOverflow
+	// Outputs and OutputURLGenerator are where comments are uploaded with
+	// UploadCommentOverflow.
+	Outputs            *jobs.OutputStore
+	OutputURLGenerator OutputURLGenerator
 }
 
 func (c *PullUpdater) updatePull(ctx *CommandContext, command PullCommand, res CommandResult) {
@@ -25,8 +46,48 @@ func (c *PullUpdater) updatePull(ctx *CommandContext, command PullCommand, res C
 		}
 	}
 
-	comment := c.MarkdownRenderer.Render(res, command.CommandName(), ctx.Log.GetHistory(), command.IsVerbose(), ctx.Pull.BaseRepo.VCSHost.Type)
+	comment := c.MarkdownRenderer.Render(res, command.CommandName(), ctx.Log.GetHistory(), command.IsVerbose(), ctx.Pull.BaseRepo)
+	comment = c.overflow(ctx, command.CommandName(), res, comment)
 	if err := c.VCSClient.CreateComment(ctx.Pull.BaseRepo, ctx.Pull.Num, comment, command.CommandName().String()); err != nil {
 		ctx.Log.Err("unable to comment: %s", err)
 	}
 }
+
+// startProgress creates the progress comment of command, which runs
+// projectCmds, and sets it as their Progress. The comment is edited every
+// ProgressInterval until it's stopped. It returns nil if progress comments
+// are disabled or the comment couldn't be created.
+func (c *PullUpdater) startProgress(ctx *CommandContext, command models.CommandName, projectCmds []models.ProjectCommandContext) *progress.Comment {
+	if c.ProgressInterval <= 0 || len(projectCmds) == 0 {
+		return nil
+	}
+	var projects []string
+	for _, cmd := range projectCmds {
+		projects = append(projects, projectDescription(cmd))
+	}
+	comment := progress.New(command.String(), projects)
+	repo := ctx.Pull.BaseRepo
+	pullNum := ctx.Pull.Num
+	id, err := c.VCSClient.CreateEditableComment(repo, pullNum, comment.Body())
+	if err != nil {
+		ctx.Log.Warn("unable to create progress comment: %s", err)
+		return nil
+	}
+	comment.Start(c.ProgressInterval, func(body string) error {
+		return c.VCSClient.EditComment(repo, pullNum, id, body)
+	}, ctx.Log)
+	for i := range projectCmds {
+		projectCmds[i].Progress = comment
+	}
+	return comment
+}
+
+// projectDescription describes the project of ctx like the comments of its
+// results do.
+func projectDescription(ctx models.ProjectCommandContext) string {
+	description := fmt.Sprintf("dir: `%s` workspace: `%s`", ctx.RepoRelDir, ctx.Workspace)
+	if ctx.ProjectName != "" {
+		description = fmt.Sprintf("project: `%s` %s", ctx.ProjectName, description)
+	}
+	return description
+}
@@ -0,0 +1,114 @@
+package events
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// pullWorkspaceExt is the extension of the files holding pull workspaces.
+const pullWorkspaceExt = ".json"
+
+// PullWorkspace is a Terraform workspace that atlantis workspace created for
+// a pull request.
+type PullWorkspace struct {
+	ID       string
+	Pull     models.PullRequest
+	HeadRepo models.Repo
+	// RepoRelDir and ProjectName are the project the workspace was created
+	// in.
+	RepoRelDir  string
+	ProjectName string
+	// ProjectWorkspace is the workspace of the project's clone, ex. default.
+	ProjectWorkspace string
+	// TerraformVersion is the version the workspace was created with. If
+	// empty, it was the default version.
+	TerraformVersion string
+	// Name is the name of the created workspace.
+	Name string
+}
+
+// PullWorkspaces records the workspaces created for pull requests as files in
+// Dir so they can be deleted once their pull requests are closed.
+type PullWorkspaces struct {
+	Dir string
+}
+
+// NewPullWorkspaces returns the pull workspaces stored in dir, creating it if
+// needed.
+func NewPullWorkspaces(dir string) (*PullWorkspaces, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "creating pull workspaces dir")
+	}
+	return &PullWorkspaces{Dir: dir}, nil
+}
+
+// Add records that workspace was created.
+func (p *PullWorkspaces) Add(workspace PullWorkspace) error {
+	workspace.ID = uuid.New().String()
+	data, err := json.Marshal(workspace)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so a crash never leaves a partial
+	// entry.
+	tmp := filepath.Join(p.Dir, "."+workspace.ID)
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return errors.Wrap(err, "writing pull workspace")
+	}
+	return errors.Wrap(os.Rename(tmp, p.path(workspace.ID)), "writing pull workspace")
+}
+
+// Remove forgets the workspaces named name that were created for pull in the
+// project in repoRelDir.
+func (p *PullWorkspaces) Remove(pull models.PullRequest, repoRelDir string, name string) error {
+	workspaces, err := p.ForPull(pull)
+	if err != nil {
+		return err
+	}
+	for _, w := range workspaces {
+		if w.RepoRelDir != repoRelDir || w.Name != name {
+			continue
+		}
+		if err := os.Remove(p.path(w.ID)); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "deleting pull workspace")
+		}
+	}
+	return nil
+}
+
+// ForPull returns the workspaces created for pull.
+func (p *PullWorkspaces) ForPull(pull models.PullRequest) ([]PullWorkspace, error) {
+	files, err := ioutil.ReadDir(p.Dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading pull workspaces dir")
+	}
+	var workspaces []PullWorkspace
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), pullWorkspaceExt) {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(p.Dir, f.Name()))
+		if err != nil {
+			return nil, errors.Wrap(err, "reading pull workspace")
+		}
+		var workspace PullWorkspace
+		if err := json.Unmarshal(data, &workspace); err != nil {
+			return nil, errors.Wrapf(err, "parsing pull workspace %s", f.Name())
+		}
+		if workspace.Pull.BaseRepo.FullName == pull.BaseRepo.FullName && workspace.Pull.Num == pull.Num {
+			workspaces = append(workspaces, workspace)
+		}
+	}
+	return workspaces, nil
+}
+
+func (p *PullWorkspaces) path(id string) string {
+	return filepath.Join(p.Dir, id+pullWorkspaceExt)
+}
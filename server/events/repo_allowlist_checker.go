@@ -15,6 +15,7 @@ package events
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 )
 
@@ -24,17 +25,36 @@ const Wildcard = "*"
 // RepoAllowlistChecker implements checking if repos are allowlisted to be used with
 // this Atlantis.
 type RepoAllowlistChecker struct {
-	rules []string
+	rules []allowlistRule
+}
+
+// allowlistRule is an entry of the allowlist. Entries can restrict a repo to
+// a path prefix, ex. github.com/owner/monorepo:infrastructure/*.
+type allowlistRule struct {
+	repo string
+	// path is the path prefix that pull requests must modify. If empty, the
+	// whole repo is allowlisted.
+	path string
 }
 
 // NewRepoAllowlistChecker constructs a new checker and validates that the
 // allowlist isn't malformed.
 func NewRepoAllowlistChecker(allowlist string) (*RepoAllowlistChecker, error) {
-	rules := strings.Split(allowlist, ",")
-	for _, rule := range rules {
+	var rules []allowlistRule
+	for _, rule := range strings.Split(allowlist, ",") {
 		if strings.Contains(rule, "://") {
 			return nil, fmt.Errorf("allowlist %q contained ://", rule)
 		}
+		// Hostnames don't include ports so the first colon separates the
+		// path.
+		repo, path := rule, ""
+		if idx := strings.Index(rule, ":"); idx != -1 {
+			repo, path = rule[:idx], strings.TrimPrefix(rule[idx+1:], "/")
+			if path == "" {
+				return nil, fmt.Errorf("allowlist %q contained an empty path", rule)
+			}
+		}
+		rules = append(rules, allowlistRule{repo: repo, path: path})
 	}
 	return &RepoAllowlistChecker{
 		rules: rules,
@@ -42,17 +62,72 @@ func NewRepoAllowlistChecker(allowlist string) (*RepoAllowlistChecker, error) {
 }
 
 // IsAllowlisted returns true if this repo is in our allowlist and false
-// otherwise.
+// otherwise. Repos allowlisted only for some paths are allowlisted, see
+// IsPathRestricted.
 func (r *RepoAllowlistChecker) IsAllowlisted(repoFullName string, vcsHostname string) bool {
 	candidate := fmt.Sprintf("%s/%s", vcsHostname, repoFullName)
 	for _, rule := range r.rules {
-		if r.matchesRule(rule, candidate) {
+		if r.matchesRule(rule.repo, candidate) {
 			return true
 		}
 	}
 	return false
 }
 
+// IsPathRestricted returns true if the repo is only allowlisted for some
+// paths, in which case pull requests that don't modify any of them should be
+// ignored.
+func (r *RepoAllowlistChecker) IsPathRestricted(repoFullName string, vcsHostname string) bool {
+	candidate := fmt.Sprintf("%s/%s", vcsHostname, repoFullName)
+	restricted := false
+	for _, rule := range r.rules {
+		if !r.matchesRule(rule.repo, candidate) {
+			continue
+		}
+		if rule.path == "" {
+			return false
+		}
+		restricted = true
+	}
+	return restricted
+}
+
+// IsPathAllowlisted returns true if the file at path, relative to the repo
+// root, is allowlisted for the repo.
+func (r *RepoAllowlistChecker) IsPathAllowlisted(repoFullName string, vcsHostname string, path string) bool {
+	candidate := fmt.Sprintf("%s/%s", vcsHostname, repoFullName)
+	for _, rule := range r.rules {
+		if r.matchesRule(rule.repo, candidate) && (rule.path == "" || matchesPath(rule.path, path)) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDirAllowlisted returns true if the projects in dir, relative to the repo
+// root, can be run for the repo. The dir is allowlisted if the files in it
+// are, ex. infrastructure/staging for the infrastructure/* path.
+func (r *RepoAllowlistChecker) IsDirAllowlisted(repoFullName string, vcsHostname string, dir string) bool {
+	dir = filepath.ToSlash(filepath.Clean(dir))
+	if dir == "." {
+		dir = ""
+	} else {
+		dir += "/"
+	}
+	return r.IsPathAllowlisted(repoFullName, vcsHostname, dir)
+}
+
+// matchesPath returns true if path is under the prefix rule. A trailing
+// wildcard matches any characters, ex. infra* matches infra-modules/main.tf,
+// otherwise rule must be path or one of its parent directories.
+func matchesPath(rule string, path string) bool {
+	if strings.HasSuffix(rule, Wildcard) {
+		return strings.HasPrefix(path, strings.TrimSuffix(rule, Wildcard))
+	}
+	rule = strings.TrimSuffix(rule, "/")
+	return path == rule || strings.HasPrefix(path, rule+"/")
+}
+
 func (r *RepoAllowlistChecker) matchesRule(rule string, candidate string) bool {
 	// Case insensitive compare.
 	rule = strings.ToLower(rule)
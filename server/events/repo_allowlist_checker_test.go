@@ -209,3 +209,64 @@ func TestRepoAllowlistChecker_ContainsSchema(t *testing.T) {
 		})
 	}
 }
+
+func TestRepoAllowlistChecker_EmptyPath(t *testing.T) {
+	_, err := events.NewRepoAllowlistChecker("github.com/owner/repo:")
+	ErrEquals(t, `allowlist "github.com/owner/repo:" contained an empty path`, err)
+}
+
+func TestRepoAllowlistChecker_Paths(t *testing.T) {
+	r, err := events.NewRepoAllowlistChecker("github.com/owner/monorepo:infrastructure/*,github.com/owner/monorepo:/modules,github.com/owner/other")
+	Ok(t, err)
+
+	// Repos allowlisted for some paths are allowlisted.
+	Equals(t, true, r.IsAllowlisted("owner/monorepo", "github.com"))
+	Equals(t, true, r.IsPathRestricted("owner/monorepo", "github.com"))
+	Equals(t, false, r.IsPathRestricted("owner/other", "github.com"))
+	Equals(t, false, r.IsPathRestricted("owner/unknown", "github.com"))
+
+	cases := []struct {
+		repo string
+		path string
+		exp  bool
+	}{
+		{"owner/monorepo", "infrastructure/main.tf", true},
+		{"owner/monorepo", "infrastructure/staging/main.tf", true},
+		{"owner/monorepo", "infrastructure.md", false},
+		{"owner/monorepo", "modules/vpc/main.tf", true},
+		{"owner/monorepo", "modules", true},
+		{"owner/monorepo", "modules-old/main.tf", false},
+		{"owner/monorepo", "app/main.go", false},
+		{"owner/other", "app/main.go", true},
+		{"owner/unknown", "infrastructure/main.tf", false},
+	}
+	for _, c := range cases {
+		t.Run(c.repo+"/"+c.path, func(t *testing.T) {
+			Equals(t, c.exp, r.IsPathAllowlisted(c.repo, "github.com", c.path))
+		})
+	}
+
+	dirCases := []struct {
+		dir string
+		exp bool
+	}{
+		{".", false},
+		{"infrastructure", true},
+		{"infrastructure/staging", true},
+		{"./modules/", true},
+		{"modules-old", false},
+		{"app", false},
+	}
+	for _, c := range dirCases {
+		t.Run("dir "+c.dir, func(t *testing.T) {
+			Equals(t, c.exp, r.IsDirAllowlisted("owner/monorepo", "github.com", c.dir))
+		})
+	}
+	Equals(t, true, r.IsDirAllowlisted("owner/other", "github.com", "."))
+
+	// A rule without a path allowlists the whole repo.
+	r, err = events.NewRepoAllowlistChecker("github.com/owner/monorepo:infrastructure/*,github.com/owner/*")
+	Ok(t, err)
+	Equals(t, false, r.IsPathRestricted("owner/monorepo", "github.com"))
+	Equals(t, true, r.IsPathAllowlisted("owner/monorepo", "github.com", "app/main.go"))
+}
@@ -0,0 +1,20 @@
+package events
+
+import (
+	"sync"
+)
+
+// RepoMutexes serializes commands that run outside pull requests, ex. API
+// jobs and drift checks, for the same repo. They all run as pull request 0 so
+// they share locks and working directories. The zero value is ready to use.
+type RepoMutexes struct {
+	mutexes sync.Map
+}
+
+// Lock locks the mutex of repoFullName and returns the function that unlocks
+// it.
+func (r *RepoMutexes) Lock(repoFullName string) func() {
+	mutex, _ := r.mutexes.LoadOrStore(repoFullName, &sync.Mutex{})
+	mutex.(*sync.Mutex).Lock()
+	return mutex.(*sync.Mutex).Unlock
+}
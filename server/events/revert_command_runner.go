@@ -0,0 +1,291 @@
+package events
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+)
+
+// revertBranchFmt is the name of the branch that the revert of a pull request
+// is pushed to when a pull request is opened with it.
+const revertBranchFmt = "atlantis/revert-%d"
+
+func NewRevertCommandRunner(
+	vcsClient vcs.Client,
+	workingDir WorkingDir,
+	workingDirLocker WorkingDirLocker,
+	preWorkflowHooksCommandRunner PreWorkflowHooksCommandRunner,
+	prjCmdBuilder ProjectPlanCommandBuilder,
+	prjCmdRunner ProjectPlanCommandRunner,
+	deleteLockCommand DeleteLockCommand,
+	templates *CommentTemplates,
+	SilenceNoProjects bool,
+) *RevertCommandRunner {
+	return &RevertCommandRunner{
+		vcsClient:                     vcsClient,
+		workingDir:                    workingDir,
+		workingDirLocker:              workingDirLocker,
+		preWorkflowHooksCommandRunner: preWorkflowHooksCommandRunner,
+		prjCmdBuilder:                 prjCmdBuilder,
+		prjCmdRunner:                  prjCmdRunner,
+		deleteLockCommand:             deleteLockCommand,
+		templates:                     templates,
+		SilenceNoProjects:             SilenceNoProjects,
+	}
+}
+
+// RevertCommandRunner plans the revert of the changes that a merged pull
+// request made to its projects, ex. to roll back a bad change quickly. The
+// project dirs are reverted to their state before the merge commit on top of
+// the base branch and planned there. The revert can be opened as a new pull
+// request, which is planned and applied like any other.
+type RevertCommandRunner struct {
+	vcsClient                     vcs.Client
+	workingDir                    WorkingDir
+	workingDirLocker              WorkingDirLocker
+	preWorkflowHooksCommandRunner PreWorkflowHooksCommandRunner
+	prjCmdBuilder                 ProjectPlanCommandBuilder
+	prjCmdRunner                  ProjectPlanCommandRunner
+	deleteLockCommand             DeleteLockCommand
+	templates                     *CommentTemplates
+	// SilenceNoProjects is whether Atlantis should respond to PRs if no projects
+	// are found
+	SilenceNoProjects bool
+}
+
+func (r *RevertCommandRunner) Run(
+	ctx *CommandContext,
+	cmd *CommentCommand,
+) {
+	pull := ctx.Pull
+	if pull.State == models.OpenPullState {
+		ctx.CommandFailure = "this pull request isn't merged yet"
+		r.commentErr(ctx, ctx.CommandFailure)
+		return
+	}
+	if pull.MergeCommit == "" {
+		ctx.CommandFailure = "this pull request wasn't merged or its merge commit is unknown, revert is only supported for GitHub and GitLab"
+		r.commentErr(ctx, ctx.CommandFailure)
+		return
+	}
+
+	// The revert is planned on the base branch, like drift checks, as if it
+	// was the head of a pull request with the same number.
+	revertPull := models.PullRequest{
+		Num:        pull.Num,
+		HeadCommit: pull.BaseBranch,
+		URL:        pull.URL,
+		HeadBranch: pull.BaseBranch,
+		BaseBranch: pull.BaseBranch,
+		Author:     ctx.User.Username,
+		Title:      pull.Title,
+		State:      models.OpenPullState,
+		BaseRepo:   pull.BaseRepo,
+	}
+	revertCtx := &CommandContext{
+		User:          ctx.User,
+		Log:           ctx.Log,
+		Pull:          revertPull,
+		HeadRepo:      pull.BaseRepo,
+		Trigger:       ctx.Trigger,
+		Span:          ctx.Span,
+		CorrelationID: ctx.CorrelationID,
+	}
+	// The plans can't be applied since the pull request is closed so they're
+	// deleted with their locks once they're commented.
+	defer r.cleanUp(revertCtx)
+
+	if err := r.preWorkflowHooksCommandRunner.RunPreHooks(revertCtx); err != nil {
+		ctx.Log.Err("Error running pre-workflow hooks %s. Proceeding with %s command.", err, models.RevertCommand)
+	}
+	projectCmds, err := r.prjCmdBuilder.BuildPlanCommands(revertCtx, cmd)
+	if err != nil {
+		ctx.Log.Err("failed to build revert commands: %s", err)
+		ctx.CommandError = err
+		r.commentErr(ctx, err.Error())
+		return
+	}
+	if len(projectCmds) == 0 {
+		if !r.SilenceNoProjects {
+			r.comment(ctx, "Ran revert for 0 projects.")
+		}
+		return
+	}
+
+	message := fmt.Sprintf("Revert \"%s\"\n\nThis reverts the changes that %s made to its projects in %s.", pull.Title, pull.URL, pull.MergeCommit)
+	commits, err := r.revert(revertCtx, projectCmds, pull.MergeCommit, message)
+	if err != nil {
+		ctx.Log.Err("failed to revert: %s", err)
+		ctx.CommandError = err
+		r.commentErr(ctx, err.Error())
+		return
+	}
+
+	data := revertPlanData{Pull: pull}
+	failed := false
+	for _, projectCmd := range projectCmds {
+		projectCmd.Pull.HeadCommit = commits[projectCmd.Workspace]
+		result := r.prjCmdRunner.Plan(projectCmd)
+		project := revertProjectData{
+			ProjectName: projectCmd.ProjectName,
+			RepoRelDir:  projectCmd.RepoRelDir,
+			Workspace:   projectCmd.Workspace,
+		}
+		switch {
+		case result.Error != nil:
+			project.Status = ":x: Errored"
+			project.Output = result.Error.Error()
+			failed = true
+		case result.Failure != "":
+			project.Status = ":warning: Failed"
+			project.Output = result.Failure
+			failed = true
+		case result.PlanSuccess != nil:
+			project.Status = ":white_check_mark: Planned"
+			project.Output = result.PlanSuccess.TerraformOutput
+		}
+		data.Projects = append(data.Projects, project)
+	}
+
+	if failed {
+		ctx.CommandFailure = "the revert plan failed"
+	}
+	tmpls := r.templates.For(pull.BaseRepo)
+	if cmd.OpenPR {
+		if failed {
+			data.OpenPRError = "the revert plan failed"
+		} else {
+			revertPR, err := r.openPR(revertCtx, tmpls, data, commits[projectCmds[0].Workspace], projectCmds[0].Workspace)
+			if err != nil {
+				ctx.Log.Err("failed to open revert pull request: %s", err)
+				ctx.CommandError = err
+				data.OpenPRError = err.Error()
+			} else {
+				ctx.Log.Info("opened revert pull request #%d", revertPR.Num)
+				data.RevertPullURL = revertPR.URL
+			}
+		}
+	}
+	r.comment(ctx, renderRevertPlan(tmpls, data))
+}
+
+// revert commits the revert of commit to the dirs of projectCmds in the clone
+// of each of their workspaces and returns the revert commits by workspace.
+// Every dir is reverted in each workspace so that any of the commits can be
+// pushed.
+func (r *RevertCommandRunner) revert(ctx *CommandContext, projectCmds []models.ProjectCommandContext, commit string, message string) (map[string]string, error) {
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, projectCmd := range projectCmds {
+		if !seen[projectCmd.RepoRelDir] {
+			seen[projectCmd.RepoRelDir] = true
+			dirs = append(dirs, projectCmd.RepoRelDir)
+		}
+	}
+	commits := make(map[string]string)
+	for _, projectCmd := range projectCmds {
+		if _, ok := commits[projectCmd.Workspace]; ok {
+			continue
+		}
+		revert, err := r.revertWorkspace(ctx, projectCmd.Workspace, commit, dirs, message)
+		if err != nil {
+			return nil, err
+		}
+		commits[projectCmd.Workspace] = revert
+	}
+	return commits, nil
+}
+
+func (r *RevertCommandRunner) revertWorkspace(ctx *CommandContext, workspace string, commit string, dirs []string, message string) (string, error) {
+	unlockFn, err := r.workingDirLocker.TryLock(ctx.Pull.BaseRepo.FullName, ctx.Pull.Num, workspace)
+	if err != nil {
+		return "", err
+	}
+	defer unlockFn()
+	if _, _, err := r.workingDir.Clone(ctx.Log, ctx.HeadRepo, ctx.Pull, workspace); err != nil {
+		return "", err
+	}
+	return r.workingDir.Revert(ctx.Log, ctx.Pull, workspace, commit, dirs, message)
+}
+
+// openPR pushes the revert commit of workspace to the revert branch and opens
+// a pull request from it with the revert plan as its description.
+func (r *RevertCommandRunner) openPR(ctx *CommandContext, tmpls *template.Template, data revertPlanData, commit string, workspace string) (models.PullRequest, error) {
+	branch := fmt.Sprintf(revertBranchFmt, data.Pull.Num)
+	if err := r.workingDir.PushBranch(ctx.Log, ctx.Pull, workspace, commit, branch); err != nil {
+		return models.PullRequest{}, err
+	}
+	data.InPull = true
+	title := fmt.Sprintf("Revert \"%s\"", data.Pull.Title)
+	return r.vcsClient.CreatePullRequest(data.Pull.BaseRepo, title, renderRevertPlan(tmpls, data), branch, data.Pull.BaseBranch)
+}
+
+func (r *RevertCommandRunner) cleanUp(ctx *CommandContext) {
+	if _, err := r.deleteLockCommand.DeleteLocksByPull(ctx.Pull.BaseRepo.FullName, ctx.Pull.Num); err != nil {
+		ctx.Log.Err("failed to delete locks: %s", err)
+	}
+	if err := r.workingDir.Delete(ctx.Pull.BaseRepo, ctx.Pull); err != nil {
+		ctx.Log.Err("failed to delete working dir: %s", err)
+	}
+}
+
+func (r *RevertCommandRunner) commentErr(ctx *CommandContext, err string) {
+	r.comment(ctx, fmt.Sprintf("**Revert Error**\n```\n%s\n```", err))
+}
+
+func (r *RevertCommandRunner) comment(ctx *CommandContext, comment string) {
+	if commentErr := r.vcsClient.CreateComment(ctx.Pull.BaseRepo, ctx.Pull.Num, comment, models.RevertCommand.String()); commentErr != nil {
+		ctx.Log.Err("unable to comment: %s", commentErr)
+	}
+}
+
+// revertPlanData is the data of the revertPlan template.
+type revertPlanData struct {
+	// Pull is the merged pull request that's reverted.
+	Pull     models.PullRequest
+	Projects []revertProjectData
+	// InPull is true if it's rendered as the description of the pull request
+	// with the revert rather than as a comment.
+	InPull bool
+	// RevertPullURL is the URL of the pull request opened with the revert, if
+	// any.
+	RevertPullURL string
+	// OpenPRError is why the pull request with the revert couldn't be opened.
+	OpenPRError string
+}
+
+type revertProjectData struct {
+	ProjectName string
+	RepoRelDir  string
+	Workspace   string
+	// Status is whether the project was planned, ex. ":x: Errored".
+	Status string
+	// Output is the output of the plan or why it failed.
+	Output string
+}
+
+// renderRevertPlan renders the revertPlan template of tmpls.
+func renderRevertPlan(tmpls *template.Template, data revertPlanData) string {
+	buf := &bytes.Buffer{}
+	if err := tmpls.ExecuteTemplate(buf, revertPlanTmpl, data); err != nil {
+		return fmt.Sprintf("Failed to render template, this is a bug: %v", err)
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+var revertPlanTmpl = commentTemplate("revertPlan",
+	"{{ if .InPull }}Reverts the changes that {{ .Pull.URL }} made to its projects.{{ else }}"+
+		"Ran Revert of `{{ .Pull.MergeCommit }}` for {{ len .Projects }} project{{ if ne (len .Projects) 1 }}s{{ end }}:{{ end }}\n\n"+
+		"{{ range $i, $p := .Projects }}"+
+		"### {{ add $i 1 }}. {{ if $p.ProjectName }}project: `{{ $p.ProjectName }}` {{ end }}dir: `{{ $p.RepoRelDir }}` workspace: `{{ $p.Workspace }}` {{ $p.Status }}\n"+
+		"```diff\n{{ $p.Output }}\n```\n\n"+
+		"{{ end }}"+
+		"{{ if not .InPull }}---\n"+
+		"{{ if .RevertPullURL }}* :rewind: Opened {{ .RevertPullURL }} with the revert. Plan and apply it there to roll back the changes."+
+		"{{ else if .OpenPRError }}* :x: Couldn't open a pull request with the revert: {{ .OpenPRError }}"+
+		"{{ else }}* :rewind: To open a pull request with the revert, comment `atlantis revert` again with the `--open-pr` flag.{{ end }}"+
+		"{{ end }}")
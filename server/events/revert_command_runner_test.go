@@ -0,0 +1,166 @@
+package events_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/models/fixtures"
+	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+type revertCommandRunnerMocks struct {
+	runner     *events.RevertCommandRunner
+	vcsClient  *vcsmocks.MockClient
+	workingDir *mocks.MockWorkingDir
+	builder    *mocks.MockProjectCommandBuilder
+	prjRunner  *mocks.MockProjectCommandRunner
+}
+
+func setupRevertCommandRunner(t *testing.T) revertCommandRunnerMocks {
+	RegisterMockTestingT(t)
+	m := revertCommandRunnerMocks{
+		vcsClient:  vcsmocks.NewMockClient(),
+		workingDir: mocks.NewMockWorkingDir(),
+		builder:    mocks.NewMockProjectCommandBuilder(),
+		prjRunner:  mocks.NewMockProjectCommandRunner(),
+	}
+	m.runner = events.NewRevertCommandRunner(
+		m.vcsClient,
+		m.workingDir,
+		events.NewDefaultWorkingDirLocker(),
+		mocks.NewMockPreWorkflowHooksCommandRunner(),
+		m.builder,
+		m.prjRunner,
+		mocks.NewMockDeleteLockCommand(),
+		nil,
+		false,
+	)
+	return m
+}
+
+// revertFixtures returns a merged pull request, the context of a comment on
+// it and the pull request that its revert is planned as.
+func revertFixtures(t *testing.T) (models.PullRequest, *events.CommandContext, models.PullRequest) {
+	pull := fixtures.Pull
+	pull.BaseRepo = fixtures.GithubRepo
+	pull.BaseBranch = "main"
+	pull.Title = "Add bucket"
+	pull.State = models.ClosedPullState
+	pull.MergeCommit = "abc123"
+	ctx := &events.CommandContext{Pull: pull, HeadRepo: fixtures.GithubRepo, User: fixtures.User, Log: logging.NewNoopLogger(t)}
+	revertPull := models.PullRequest{
+		Num:        pull.Num,
+		HeadCommit: "main",
+		URL:        pull.URL,
+		HeadBranch: "main",
+		BaseBranch: "main",
+		Author:     fixtures.User.Username,
+		Title:      pull.Title,
+		State:      models.OpenPullState,
+		BaseRepo:   fixtures.GithubRepo,
+	}
+	return pull, ctx, revertPull
+}
+
+func TestRevertCommandRunner_Run(t *testing.T) {
+	m := setupRevertCommandRunner(t)
+	_, ctx, revertPull := revertFixtures(t)
+	cmd := &events.CommentCommand{Name: models.RevertCommand, OpenPR: true}
+	When(m.builder.BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())).ThenReturn([]models.ProjectCommandContext{
+		{RepoRelDir: "dir1", Workspace: "default", Pull: revertPull},
+		{RepoRelDir: "dir2", Workspace: "staging", ProjectName: "p2", Pull: revertPull},
+	}, nil)
+	message := "Revert \"Add bucket\"\n\nThis reverts the changes that url made to its projects in abc123."
+	dirs := []string{"dir1", "dir2"}
+	When(m.workingDir.Revert(ctx.Log, revertPull, "default", "abc123", dirs, message)).ThenReturn("sha1", nil)
+	When(m.workingDir.Revert(ctx.Log, revertPull, "staging", "abc123", dirs, message)).ThenReturn("sha2", nil)
+	pullAt := func(sha string) models.PullRequest {
+		p := revertPull
+		p.HeadCommit = sha
+		return p
+	}
+	When(m.prjRunner.Plan(models.ProjectCommandContext{RepoRelDir: "dir1", Workspace: "default", Pull: pullAt("sha1")})).
+		ThenReturn(models.ProjectResult{PlanSuccess: &models.PlanSuccess{TerraformOutput: "- bucket"}})
+	When(m.prjRunner.Plan(models.ProjectCommandContext{RepoRelDir: "dir2", Workspace: "staging", ProjectName: "p2", Pull: pullAt("sha2")})).
+		ThenReturn(models.ProjectResult{PlanSuccess: &models.PlanSuccess{TerraformOutput: "No changes."}})
+	projects := "### 1. dir: `dir1` workspace: `default` :white_check_mark: Planned\n```diff\n- bucket\n```\n\n" +
+		"### 2. project: `p2` dir: `dir2` workspace: `staging` :white_check_mark: Planned\n```diff\nNo changes.\n```"
+	When(m.vcsClient.CreatePullRequest(fixtures.GithubRepo, "Revert \"Add bucket\"", "Reverts the changes that url made to its projects.\n\n"+projects, "atlantis/revert-1", "main")).
+		ThenReturn(models.PullRequest{Num: 2, URL: "url2"}, nil)
+
+	m.runner.Run(ctx, cmd)
+	m.workingDir.VerifyWasCalledOnce().PushBranch(ctx.Log, revertPull, "default", "sha1", "atlantis/revert-1")
+	m.vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, 1, "Ran Revert of `abc123` for 2 projects:\n\n"+projects+"\n\n---\n"+
+		"* :rewind: Opened url2 with the revert. Plan and apply it there to roll back the changes.", "revert")
+	m.workingDir.VerifyWasCalledOnce().Delete(fixtures.GithubRepo, revertPull)
+}
+
+// Test that a pull request isn't opened if the revert plan failed.
+func TestRevertCommandRunner_RunPlanFailed(t *testing.T) {
+	m := setupRevertCommandRunner(t)
+	_, ctx, revertPull := revertFixtures(t)
+	When(m.builder.BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())).ThenReturn([]models.ProjectCommandContext{
+		{RepoRelDir: "dir1", Workspace: "default", Pull: revertPull},
+	}, nil)
+	When(m.workingDir.Revert(matchers.AnyLoggingSimpleLogging(), matchers.AnyModelsPullRequest(), AnyString(), AnyString(), matchers.AnySliceOfString(), AnyString())).ThenReturn("sha1", nil)
+	When(m.prjRunner.Plan(matchers.AnyModelsProjectCommandContext())).ThenReturn(models.ProjectResult{Failure: "locked"})
+
+	m.runner.Run(ctx, &events.CommentCommand{Name: models.RevertCommand, OpenPR: true})
+	m.workingDir.VerifyWasCalled(Never()).PushBranch(matchers.AnyLoggingSimpleLogging(), matchers.AnyModelsPullRequest(), AnyString(), AnyString(), AnyString())
+	m.vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, 1, "Ran Revert of `abc123` for 1 project:\n\n"+
+		"### 1. dir: `dir1` workspace: `default` :warning: Failed\n```diff\nlocked\n```\n\n---\n"+
+		"* :x: Couldn't open a pull request with the revert: the revert plan failed", "revert")
+}
+
+func TestRevertCommandRunner_RunErrors(t *testing.T) {
+	cases := []struct {
+		description string
+		setup       func(m revertCommandRunnerMocks, pull *models.PullRequest)
+		expComment  string
+	}{
+		{
+			description: "open pull request",
+			setup: func(m revertCommandRunnerMocks, pull *models.PullRequest) {
+				pull.State = models.OpenPullState
+			},
+			expComment: "this pull request isn't merged yet",
+		},
+		{
+			description: "unknown merge commit",
+			setup: func(m revertCommandRunnerMocks, pull *models.PullRequest) {
+				pull.MergeCommit = ""
+			},
+			expComment: "this pull request wasn't merged or its merge commit is unknown, revert is only supported for GitHub and GitLab",
+		},
+		{
+			description: "revert error",
+			setup: func(m revertCommandRunnerMocks, pull *models.PullRequest) {
+				When(m.builder.BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())).ThenReturn([]models.ProjectCommandContext{
+					{RepoRelDir: "dir1", Workspace: "default"},
+				}, nil)
+				When(m.workingDir.Revert(matchers.AnyLoggingSimpleLogging(), matchers.AnyModelsPullRequest(), AnyString(), AnyString(), matchers.AnySliceOfString(), AnyString())).
+					ThenReturn("", errors.New("conflict"))
+			},
+			expComment: "conflict",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			m := setupRevertCommandRunner(t)
+			_, ctx, _ := revertFixtures(t)
+			c.setup(m, &ctx.Pull)
+
+			m.runner.Run(ctx, &events.CommentCommand{Name: models.RevertCommand})
+			_, _, comment, _ := m.vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString(), AnyString()).GetCapturedArguments()
+			Equals(t, "**Revert Error**\n```\n"+c.expComment+"\n```", comment)
+			m.prjRunner.VerifyWasCalled(Never()).Plan(matchers.AnyModelsProjectCommandContext())
+		})
+	}
+}
@@ -19,6 +19,9 @@ type ApplyStepRunner struct {
 	TerraformExecutor   TerraformExec
 	CommitStatusUpdater StatusUpdater
 	AsyncTFExec         AsyncTFExec
+	// TFCRuns, if set, is used to confirm the applies of projects using TFE
+	// remote ops through the API and to get the status of their runs.
+	TFCRuns TFCRunClient
 }
 
 func (a *ApplyStepRunner) Run(ctx models.ProjectCommandContext, extraArgs []string, path string, envs map[string]string) (string, error) {
@@ -41,10 +44,12 @@ func (a *ApplyStepRunner) Run(ctx models.ProjectCommandContext, extraArgs []stri
 	// TODO: Leverage PlanTypeStepRunnerDelegate here
 	if IsRemotePlan(contents) {
 		args := append(append([]string{"apply", "-input=false", "-no-color"}, extraArgs...), ctx.EscapedCommentArgs...)
-		out, err = a.runRemoteApply(ctx, args, path, planPath, ctx.TerraformVersion, envs)
+		var runHeader string
+		out, runHeader, err = a.runRemoteApply(ctx, args, path, planPath, ctx.TerraformVersion, envs)
 		if err == nil {
 			out = a.cleanRemoteApplyOutput(out)
 		}
+		out = runHeader + out
 	} else {
 		// NOTE: we need to quote the plan path because Bitbucket Server can
 		// have spaces in its repo owner names which is part of the path.
@@ -107,20 +112,24 @@ func (a *ApplyStepRunner) cleanRemoteApplyOutput(out string) string {
 // printed to the pull request.
 // We need to do this because remote plan doesn't support -out, so we do a
 // manual diff.
-// It also writes "yes" or "no" to the process to confirm the apply.
+// It confirms the apply through the Terraform Enterprise API if TFCRuns is
+// set, and otherwise writes "yes" to the process. It writes "no" to the
+// process to abort the apply.
+// Along with the output, it returns a header linking to the run for the
+// pull request comment.
 func (a *ApplyStepRunner) runRemoteApply(
 	ctx models.ProjectCommandContext,
 	applyArgs []string,
 	path string,
 	absPlanPath string,
 	tfVersion *version.Version,
-	envs map[string]string) (string, error) {
+	envs map[string]string) (string, string, error) {
 
 	// The planfile contents are needed to ensure that the plan didn't change
 	// between plan and apply phases.
 	planfileBytes, err := ioutil.ReadFile(absPlanPath)
 	if err != nil {
-		return "", errors.Wrap(err, "reading planfile")
+		return "", "", errors.Wrap(err, "reading planfile")
 	}
 
 	// updateStatusF will update the commit status and log any error.
@@ -173,6 +182,9 @@ func (a *ApplyStepRunner) runRemoteApply(
 			}
 
 			ctx.Log.Debug("plan generated during apply matches expected plan, continuing")
+			if a.confirmRemoteApply(ctx, runURL) {
+				continue
+			}
 			inCh <- "yes\n"
 		}
 	}
@@ -183,15 +195,42 @@ func (a *ApplyStepRunner) runRemoteApply(
 		updateStatusF(models.FailedCommitStatus, runURL)
 		// The output isn't important if the plans don't match so we just
 		// discard it.
-		return "", planChangedErr
+		return "", "", planChangedErr
 	}
 
+	runStatus := remoteRunStatus(ctx, a.TFCRuns, runURL)
+	status := models.SuccessCommitStatus
 	if err != nil {
-		updateStatusF(models.FailedCommitStatus, runURL)
-	} else {
-		updateStatusF(models.SuccessCommitStatus, runURL)
+		status = models.FailedCommitStatus
+	} else if runStatus != "" {
+		status = TFCRunCommitStatus(models.ApplyCommand, runStatus)
+		if status == models.FailedCommitStatus {
+			err = fmt.Errorf("remote run %s", runStatus)
+		}
+	}
+	updateStatusF(status, runURL)
+	return output, tfcRunHeader(runURL, runStatus), err
+}
+
+// confirmRemoteApply confirms the apply of the remote run at runURL through
+// the Terraform Enterprise API. Terraform notices the confirmation and
+// carries on with the apply. It returns false if the apply wasn't confirmed,
+// in which case it should be confirmed through the process.
+func (a *ApplyStepRunner) confirmRemoteApply(ctx models.ProjectCommandContext, runURL string) bool {
+	runID := TFCRunID(runURL)
+	if a.TFCRuns == nil || runID == "" {
+		return false
+	}
+	comment := fmt.Sprintf("Applied by Atlantis for %s#%d", ctx.BaseRepo.FullName, ctx.Pull.Num)
+	if ctx.User.Username != "" {
+		comment += fmt.Sprintf(" on behalf of %s", ctx.User.Username)
+	}
+	if err := a.TFCRuns.ApplyRun(runID, comment); err != nil {
+		ctx.Log.Warn("unable to confirm remote run %s through the API, confirming it through terraform: %s", runID, err)
+		return false
 	}
-	return output, err
+	ctx.Log.Debug("confirmed remote run %s through the API", runID)
+	return true
 }
 
 // remotePlanChanged checks if the plan generated during the plan phase matches
@@ -260,7 +260,9 @@ Plan: 0 to add, 0 to change, 1 to destroy.`
 
 	Ok(t, err)
 	Equals(t, "yes\n", tfExec.PassedInput)
-	Equals(t, `
+	Equals(t, `Terraform Cloud run: https://app.terraform.io/app/lkysow-enterprises/atlantis-tfe-test-dir2/runs/run-PiDsRYKGcerTttV2
+
+
 2019/02/27 21:47:36 [DEBUG] Using modified User-Agent: Terraform/0.11.11 TFE/d161c1b
 null_resource.dir2[1]: Destroying... (ID: 8554368366766418126)
 null_resource.dir2[1]: Destruction complete after 0s
@@ -351,6 +353,116 @@ To resolve, re-run plan.`, err)
 	Ok(t, err)
 }
 
+// Test that remote applies are confirmed through the API if we have a client
+// for it.
+func TestRun_RemoteApply_TFCConfirmation(t *testing.T) {
+	tmpDir, cleanup := TempDir(t)
+	defer cleanup()
+	planPath := filepath.Join(tmpDir, "workspace.tfplan")
+	planFileContents := `
+An execution plan has been generated and is shown below.
+Resource actions are indicated with the following symbols:
+  - destroy
+
+Terraform will perform the following actions:
+
+  - null_resource.hi[1]
+
+
+Plan: 0 to add, 0 to change, 1 to destroy.`
+	err := ioutil.WriteFile(planPath, []byte("Atlantis: this plan was created by remote ops\n"+planFileContents), 0600)
+	Ok(t, err)
+
+	RegisterMockTestingT(t)
+	tfOut := fmt.Sprintf(preConfirmOutFmt, planFileContents) + postConfirmOut
+	tfExec := &remoteApplyMock{LinesToSend: tfOut, NoInput: true, DoneCh: make(chan bool)}
+	tfcRuns := &tfcRunsMock{Status: "applied"}
+	updater := mocks2.NewMockCommitStatusUpdater()
+	o := runtime.ApplyStepRunner{
+		AsyncTFExec:         tfExec,
+		CommitStatusUpdater: updater,
+		TFCRuns:             tfcRuns,
+	}
+	tfVersion, _ := version.NewVersion("0.11.0")
+	ctx := models.ProjectCommandContext{
+		Log:                logging.NewNoopLogger(t),
+		Workspace:          "workspace",
+		RepoRelDir:         ".",
+		BaseRepo:           models.Repo{FullName: "owner/repo"},
+		Pull:               models.PullRequest{Num: 2},
+		User:               models.User{Username: "user"},
+		EscapedCommentArgs: []string{"comment", "args"},
+		TerraformVersion:   tfVersion,
+	}
+	output, err := o.Run(ctx, nil, tmpDir, map[string]string(nil))
+	<-tfExec.DoneCh
+
+	Ok(t, err)
+	Equals(t, "", tfExec.PassedInput)
+	Equals(t, []string{"run-PiDsRYKGcerTttV2"}, tfcRuns.Applied)
+	Equals(t, []string{"Applied by Atlantis for owner/repo#2 on behalf of user"}, tfcRuns.Comments)
+	runURL := "https://app.terraform.io/app/lkysow-enterprises/atlantis-tfe-test-dir2/runs/run-PiDsRYKGcerTttV2"
+	Assert(t, strings.HasPrefix(output, "Terraform Cloud run: "+runURL+" (applied)\n\n"), "output should link to the run, got %q", output)
+	updater.VerifyWasCalledOnce().UpdateProject(ctx, models.ApplyCommand, models.SuccessCommitStatus, runURL)
+}
+
+// Test that the apply fails if the remote run didn't apply.
+func TestRun_RemoteApply_TFCRunErrored(t *testing.T) {
+	tmpDir, cleanup := TempDir(t)
+	defer cleanup()
+	planPath := filepath.Join(tmpDir, "workspace.tfplan")
+	planFileContents := `
+Plan: 0 to add, 0 to change, 1 to destroy.`
+	err := ioutil.WriteFile(planPath, []byte("Atlantis: this plan was created by remote ops\n"+planFileContents), 0600)
+	Ok(t, err)
+
+	RegisterMockTestingT(t)
+	tfOut := fmt.Sprintf(preConfirmOutFmt, planFileContents) + postConfirmOut
+	tfExec := &remoteApplyMock{LinesToSend: tfOut, NoInput: true, DoneCh: make(chan bool)}
+	updater := mocks2.NewMockCommitStatusUpdater()
+	o := runtime.ApplyStepRunner{
+		AsyncTFExec:         tfExec,
+		CommitStatusUpdater: updater,
+		TFCRuns:             &tfcRunsMock{Status: "errored"},
+	}
+	tfVersion, _ := version.NewVersion("0.11.0")
+	ctx := models.ProjectCommandContext{
+		Log:              logging.NewNoopLogger(t),
+		Workspace:        "workspace",
+		RepoRelDir:       ".",
+		TerraformVersion: tfVersion,
+	}
+	_, err = o.Run(ctx, nil, tmpDir, map[string]string(nil))
+	<-tfExec.DoneCh
+
+	ErrEquals(t, "remote run errored", err)
+	runURL := "https://app.terraform.io/app/lkysow-enterprises/atlantis-tfe-test-dir2/runs/run-PiDsRYKGcerTttV2"
+	updater.VerifyWasCalledOnce().UpdateProject(ctx, models.ApplyCommand, models.FailedCommitStatus, runURL)
+
+	// Planfile should not be deleted.
+	_, err = os.Stat(planPath)
+	Ok(t, err)
+}
+
+type tfcRunsMock struct {
+	// Status is the status of every run.
+	Status string
+	// Applied are the IDs of the runs that were applied.
+	Applied []string
+	// Comments are the comments of the applies.
+	Comments []string
+}
+
+func (r *tfcRunsMock) RunStatus(runID string) (string, error) {
+	return r.Status, nil
+}
+
+func (r *tfcRunsMock) ApplyRun(runID string, comment string) error {
+	r.Applied = append(r.Applied, runID)
+	r.Comments = append(r.Comments, comment)
+	return nil
+}
+
 type remoteApplyMock struct {
 	// LinesToSend will be sent on the channel.
 	LinesToSend string
@@ -360,6 +472,8 @@ type remoteApplyMock struct {
 	CalledArgs []string
 	// PassedInput is set to the last string passed to our input channel.
 	PassedInput string
+	// NoInput is set if nothing will be passed to our input channel.
+	NoInput bool
 	// DoneCh callers should wait on the done channel to ensure we're done.
 	DoneCh chan bool
 }
@@ -383,8 +497,10 @@ func (r *remoteApplyMock) RunCommandAsync(log logging.SimpleLogging, path string
 
 	// Asynchronously process input.
 	go func() {
-		inLine := <-in
-		r.PassedInput = inLine
+		if !r.NoInput {
+			inLine := <-in
+			r.PassedInput = inLine
+		}
 		close(in)
 		wg.Done()
 	}()
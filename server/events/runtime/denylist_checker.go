@@ -0,0 +1,151 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_denylist_checker.go DenylistChecker
+
+// DenylistChecker checks plans against the project's denylist.
+type DenylistChecker interface {
+	// Check returns a description of each item in the plan in path that's
+	// denied by ctx.Denylist. It returns nil if nothing is denied.
+	Check(ctx models.ProjectCommandContext, path string) ([]string, error)
+}
+
+// DefaultDenylistChecker checks the JSON output of terraform show.
+type DefaultDenylistChecker struct {
+	TerraformExecutor TerraformExec
+	DefaultTFVersion  *version.Version
+}
+
+// planJSON is the subset of the terraform show -json output that we check.
+type planJSON struct {
+	ResourceChanges []struct {
+		Address      string `json:"address"`
+		Type         string `json:"type"`
+		ProviderName string `json:"provider_name"`
+		Change       struct {
+			Actions []string `json:"actions"`
+		} `json:"change"`
+	} `json:"resource_changes"`
+	Configuration struct {
+		RootModule configModule `json:"root_module"`
+	} `json:"configuration"`
+}
+
+type configModule struct {
+	Resources []struct {
+		Address      string `json:"address"`
+		Provisioners []struct {
+			Type string `json:"type"`
+		} `json:"provisioners"`
+	} `json:"resources"`
+	ModuleCalls map[string]struct {
+		Module configModule `json:"module"`
+	} `json:"module_calls"`
+}
+
+// Check runs terraform show on the plan file and checks the resources that
+// will be created or updated and the provisioners in the configuration.
+func (d *DefaultDenylistChecker) Check(ctx models.ProjectCommandContext, path string) ([]string, error) {
+	if ctx.Denylist.Empty() {
+		return nil, nil
+	}
+
+	tfVersion := d.DefaultTFVersion
+	if ctx.TerraformVersion != nil {
+		tfVersion = ctx.TerraformVersion
+	}
+	planFile := filepath.Join(path, GetPlanFilename(ctx.Workspace, ctx.ProjectName))
+	output, err := d.TerraformExecutor.RunCommandWithVersion(ctx.Log, path, []string{"show", "-no-color", "-json", filepath.Clean(planFile)}, map[string]string{}, tfVersion, ctx.Workspace)
+	if err != nil {
+		return nil, errors.Wrap(err, "running terraform show")
+	}
+	var plan planJSON
+	if err := json.Unmarshal([]byte(output), &plan); err != nil {
+		return nil, errors.Wrap(err, "parsing terraform show output")
+	}
+
+	var denied []string
+	for _, rc := range plan.ResourceChanges {
+		if !createsOrUpdates(rc.Change.Actions) {
+			continue
+		}
+		if pattern, ok := matchAny(ctx.Denylist.Resources, rc.Type); ok {
+			denied = append(denied, fmt.Sprintf("`%s`: resource type `%s` is denied by `%s`", rc.Address, rc.Type, pattern))
+		}
+		if pattern, ok := matchProvider(ctx.Denylist.Providers, rc.ProviderName); ok {
+			denied = append(denied, fmt.Sprintf("`%s`: provider `%s` is denied by `%s`", rc.Address, rc.ProviderName, pattern))
+		}
+	}
+	denied = append(denied, deniedProvisioners(ctx.Denylist.Provisioners, plan.Configuration.RootModule, "")...)
+	return denied, nil
+}
+
+// deniedProvisioners returns the resources in module, and its child modules,
+// with denied provisioners. prefix is the module's address.
+func deniedProvisioners(patterns []string, module configModule, prefix string) []string {
+	if len(patterns) == 0 {
+		return nil
+	}
+	var denied []string
+	for _, r := range module.Resources {
+		for _, p := range r.Provisioners {
+			if pattern, ok := matchAny(patterns, p.Type); ok {
+				denied = append(denied, fmt.Sprintf("`%s%s`: provisioner `%s` is denied by `%s`", prefix, r.Address, p.Type, pattern))
+			}
+		}
+	}
+	// Sort module names so the output is stable.
+	var names []string
+	for name := range module.ModuleCalls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		denied = append(denied, deniedProvisioners(patterns, module.ModuleCalls[name].Module, prefix+"module."+name+".")...)
+	}
+	return denied
+}
+
+func createsOrUpdates(actions []string) bool {
+	for _, a := range actions {
+		if a == "create" || a == "update" {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAny returns the first pattern that matches name.
+func matchAny(patterns []string, name string) (string, bool) {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// matchProvider returns the first pattern that matches the provider source
+// address name, ex. "registry.terraform.io/hashicorp/aws". Patterns can omit
+// the hostname, ex. "hashicorp/aws".
+func matchProvider(patterns []string, name string) (string, bool) {
+	if pattern, ok := matchAny(patterns, name); ok {
+		return pattern, true
+	}
+	if parts := strings.SplitN(name, "/", 2); len(parts) == 2 && strings.Contains(parts[0], ".") {
+		return matchAny(patterns, parts[1])
+	}
+	return "", false
+}
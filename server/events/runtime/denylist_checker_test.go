@@ -0,0 +1,123 @@
+package runtime_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-version"
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/terraform/mocks"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+const denylistShowOutput = `{
+  "resource_changes": [
+    {"address": "aws_iam_user.admin", "type": "aws_iam_user", "provider_name": "registry.terraform.io/hashicorp/aws", "change": {"actions": ["create"]}},
+    {"address": "aws_iam_role.old", "type": "aws_iam_role", "provider_name": "registry.terraform.io/hashicorp/aws", "change": {"actions": ["delete"]}},
+    {"address": "null_resource.script", "type": "null_resource", "provider_name": "registry.terraform.io/hashicorp/null", "change": {"actions": ["no-op"]}},
+    {"address": "module.app.random_id.id", "type": "random_id", "provider_name": "registry.terraform.io/hashicorp/random", "change": {"actions": ["delete", "create"]}}
+  ],
+  "configuration": {
+    "root_module": {
+      "resources": [
+        {"address": "null_resource.script", "provisioners": [{"type": "local-exec"}]}
+      ],
+      "module_calls": {
+        "app": {
+          "module": {
+            "resources": [
+              {"address": "random_id.id", "provisioners": [{"type": "remote-exec"}, {"type": "file"}]}
+            ]
+          }
+        }
+      }
+    }
+  }
+}`
+
+func TestDefaultDenylistChecker_Check(t *testing.T) {
+	cases := []struct {
+		description string
+		denylist    valid.Denylist
+		exp         []string
+	}{
+		{
+			description: "empty denylist",
+		},
+		{
+			description: "resources",
+			denylist:    valid.Denylist{Resources: []string{"aws_iam_*"}},
+			exp:         []string{"`aws_iam_user.admin`: resource type `aws_iam_user` is denied by `aws_iam_*`"},
+		},
+		{
+			description: "providers without hostname",
+			denylist:    valid.Denylist{Providers: []string{"hashicorp/random"}},
+			exp:         []string{"`module.app.random_id.id`: provider `registry.terraform.io/hashicorp/random` is denied by `hashicorp/random`"},
+		},
+		{
+			description: "providers with hostname",
+			denylist:    valid.Denylist{Providers: []string{"registry.terraform.io/hashicorp/aws"}},
+			exp:         []string{"`aws_iam_user.admin`: provider `registry.terraform.io/hashicorp/aws` is denied by `registry.terraform.io/hashicorp/aws`"},
+		},
+		{
+			description: "provisioners",
+			denylist:    valid.Denylist{Provisioners: []string{"*-exec"}},
+			exp: []string{
+				"`null_resource.script`: provisioner `local-exec` is denied by `*-exec`",
+				"`module.app.random_id.id`: provisioner `remote-exec` is denied by `*-exec`",
+			},
+		},
+		{
+			description: "nothing denied",
+			denylist:    valid.Denylist{Resources: []string{"google_*"}, Providers: []string{"hashicorp/null"}, Provisioners: []string{"chef"}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			logger := logging.NewNoopLogger(t)
+			tfVersion, _ := version.NewVersion("0.14.0")
+			executor := mocks.NewMockClient()
+			checker := &runtime.DefaultDenylistChecker{
+				TerraformExecutor: executor,
+				DefaultTFVersion:  tfVersion,
+			}
+			ctx := models.ProjectCommandContext{
+				Log:       logger,
+				Workspace: "default",
+				Denylist:  c.denylist,
+			}
+			When(executor.RunCommandWithVersion(logger, "/path", []string{"show", "-no-color", "-json", filepath.Join("/path", "default.tfplan")}, map[string]string{}, tfVersion, "default")).
+				ThenReturn(denylistShowOutput, nil)
+
+			denied, err := checker.Check(ctx, "/path")
+			Ok(t, err)
+			Equals(t, c.exp, denied)
+			if c.denylist.Empty() {
+				executor.VerifyWasCalled(Never()).RunCommandWithVersion(logger, "/path", []string{"show", "-no-color", "-json", filepath.Join("/path", "default.tfplan")}, map[string]string{}, tfVersion, "default")
+			}
+		})
+	}
+}
+
+func TestDefaultDenylistChecker_ShowError(t *testing.T) {
+	RegisterMockTestingT(t)
+	logger := logging.NewNoopLogger(t)
+	executor := mocks.NewMockClient()
+	checker := &runtime.DefaultDenylistChecker{TerraformExecutor: executor}
+	ctx := models.ProjectCommandContext{
+		Log:       logger,
+		Workspace: "default",
+		Denylist:  valid.Denylist{Resources: []string{"aws_iam_user"}},
+	}
+	When(executor.RunCommandWithVersion(logger, "/path", []string{"show", "-no-color", "-json", filepath.Join("/path", "default.tfplan")}, map[string]string{}, nil, "default")).
+		ThenReturn("", errors.New("err"))
+
+	_, err := checker.Check(ctx, "/path")
+	ErrEquals(t, "running terraform show: err", err)
+}
@@ -0,0 +1,178 @@
+package runtime
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	version "github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// initDirName is the dir terraform init installs modules and providers in
+// and stores the backend configuration in.
+const initDirName = ".terraform"
+
+// tmpPrefix is the prefix of the dirs .terraform dirs are copied to before
+// they're cached.
+const tmpPrefix = ".tmp"
+
+// InitCache keeps the .terraform dir of each repo, project dir and workspace
+// after terraform init so it can be restored for the next pull request,
+// which saves init from downloading modules and providers again. A cached
+// dir is only restored if the project's dependency lock file and Terraform
+// version haven't changed since it was cached. A nil *InitCache caches
+// nothing.
+type InitCache struct {
+	// Dir is the dir the .terraform dirs are cached in.
+	Dir string
+}
+
+// Restore copies the cached .terraform dir of the project described by ctx
+// into path if it matches the project's lock file and tfVersion. It returns
+// true if path has a .terraform dir that only needs to be updated by init,
+// either because it was restored or because it was already there.
+func (c *InitCache) Restore(ctx models.ProjectCommandContext, path string, tfVersion *version.Version) bool {
+	if c == nil {
+		return false
+	}
+	key, err := c.key(path, tfVersion)
+	if err != nil || key == "" {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(path, initDirName)); err == nil {
+		return true
+	}
+	cached := filepath.Join(c.projectDir(ctx), key)
+	if _, err := os.Stat(cached); err != nil {
+		return false
+	}
+	if err := copyDir(cached, filepath.Join(path, initDirName)); err != nil {
+		ctx.Log.Warn("unable to restore cached %s dir: %s", initDirName, err)
+		os.RemoveAll(filepath.Join(path, initDirName)) // nolint: errcheck
+		return false
+	}
+	ctx.Log.Info("restored cached %s dir", initDirName)
+	return true
+}
+
+// Save caches the .terraform dir in path for the project described by ctx,
+// replacing the one that was cached for it so modules that changed are
+// cached too. Projects without a lock file
+// aren't cached since the versions of their providers aren't pinned.
+func (c *InitCache) Save(ctx models.ProjectCommandContext, path string, tfVersion *version.Version) {
+	if c == nil {
+		return
+	}
+	key, err := c.key(path, tfVersion)
+	if err != nil || key == "" {
+		return
+	}
+	if err := c.save(ctx, path, key); err != nil {
+		ctx.Log.Warn("unable to cache %s dir: %s", initDirName, err)
+	}
+}
+
+func (c *InitCache) save(ctx models.ProjectCommandContext, path string, key string) error {
+	projectDir := c.projectDir(ctx)
+	if err := os.MkdirAll(projectDir, 0700); err != nil {
+		return err
+	}
+	// The dir is copied next to the others and renamed once complete so
+	// it's never restored half copied.
+	tmp, err := ioutil.TempDir(projectDir, tmpPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	if err := copyDir(filepath.Join(path, initDirName), filepath.Join(tmp, initDirName)); err != nil {
+		return err
+	}
+	entries, err := ioutil.ReadDir(projectDir)
+	if err != nil {
+		return err
+	}
+	// Other pull requests could be saving the dir at the same time.
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), tmpPrefix) {
+			os.RemoveAll(filepath.Join(projectDir, entry.Name())) // nolint: errcheck
+		}
+	}
+	if err := os.Rename(filepath.Join(tmp, initDirName), filepath.Join(projectDir, key)); err != nil && !os.IsExist(err) {
+		return err
+	}
+	ctx.Log.Debug("cached %s dir", initDirName)
+	return nil
+}
+
+// key returns the key of the .terraform dir of the project in path, which is
+// the hash of its lock file and tfVersion, or "" if it has no lock file.
+func (c *InitCache) key(path string, tfVersion *version.Version) (string, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(path, LockfileName)) // nolint: gosec
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write([]byte(tfVersion.String() + "\n")) // nolint: errcheck
+	h.Write(contents)                          // nolint: errcheck
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// projectDir returns the dir the .terraform dir of the project described by
+// ctx is cached in.
+func (c *InitCache) projectDir(ctx models.ProjectCommandContext) string {
+	return filepath.Join(c.Dir, ctx.BaseRepo.FullName, url.PathEscape(ctx.RepoRelDir), url.PathEscape(ctx.Workspace))
+}
+
+// copyDir copies the dir src to dst, keeping symlinks, ex. to providers in
+// the plugin cache, as they are.
+func copyDir(src string, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, relPath)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, 0700)
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			return copyFile(path, target, info.Mode())
+		}
+	})
+}
+
+func copyFile(src string, dst string, mode os.FileMode) error {
+	in, err := os.Open(src) // nolint: gosec
+	if err != nil {
+		return err
+	}
+	defer in.Close() // nolint: errcheck
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close() // nolint: errcheck
+		return errors.Wrapf(err, "copying %s", src)
+	}
+	return out.Close()
+}
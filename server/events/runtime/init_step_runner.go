@@ -1,7 +1,11 @@
 package runtime
 
 import (
+	"fmt"
+	"strings"
+
 	version "github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/events/models"
 )
 
@@ -9,6 +13,9 @@ import (
 type InitStepRunner struct {
 	TerraformExecutor TerraformExec
 	DefaultTFVersion  *version.Version
+	// Cache caches the .terraform dirs of projects. If nil, they aren't
+	// cached.
+	Cache *InitCache
 }
 
 func (i *InitStepRunner) Run(ctx models.ProjectCommandContext, extraArgs []string, path string, envs map[string]string) (string, error) {
@@ -16,12 +23,20 @@ func (i *InitStepRunner) Run(ctx models.ProjectCommandContext, extraArgs []strin
 	if ctx.TerraformVersion != nil {
 		tfVersion = ctx.TerraformVersion
 	}
+	// Upgrading providers changes the lock file so it isn't verified.
+	if ctx.VerifyLockfile && !ctx.UpgradeProviders {
+		return i.runVerifyingLockfile(ctx, extraArgs, path, envs, tfVersion)
+	}
 	terraformInitCmd := append([]string{"init", "-input=false", "-no-color", "-upgrade"}, extraArgs...)
 
 	// If we're running < 0.9 we have to use `terraform get` instead of `init`.
 	if MustConstraint("< 0.9.0").Check(tfVersion) {
 		ctx.Log.Info("running terraform version %s so will use `get` instead of `init`", tfVersion)
 		terraformInitCmd = append([]string{"get", "-no-color", "-upgrade"}, extraArgs...)
+	} else if !ctx.UpgradeProviders && i.Cache.Restore(ctx, path, tfVersion) {
+		// The providers installed match the lock file so only the modules
+		// and backend that changed need to be installed.
+		terraformInitCmd = append([]string{"init", "-input=false", "-no-color"}, extraArgs...)
 	}
 
 	out, err := i.TerraformExecutor.RunCommandWithVersion(ctx.Log, path, terraformInitCmd, envs, tfVersion, ctx.Workspace)
@@ -30,5 +45,42 @@ func (i *InitStepRunner) Run(ctx models.ProjectCommandContext, extraArgs []strin
 	if err != nil {
 		return out, err
 	}
+	i.Cache.Save(ctx, path, tfVersion)
+	return "", nil
+}
+
+// runVerifyingLockfile runs init and checks that the committed dependency lock
+// file pins every provider that init selected and that init didn't change it.
+// Init is run without -upgrade since upgrading changes the lock file.
+func (i *InitStepRunner) runVerifyingLockfile(ctx models.ProjectCommandContext, extraArgs []string, path string, envs map[string]string, tfVersion *version.Version) (string, error) {
+	if MustConstraint("< 0.14.0").Check(tfVersion) {
+		return "", fmt.Errorf("verifying %s requires Terraform 0.14 or later but this project uses %s", LockfileName, tfVersion)
+	}
+	before, err := readLockfile(path)
+	if err != nil {
+		return "", err
+	}
+	if before == nil {
+		return "", fmt.Errorf("%s not found: run terraform init locally and commit the lock file so the versions and hashes of providers are pinned", LockfileName)
+	}
+
+	i.Cache.Restore(ctx, path, tfVersion)
+	out, err := i.TerraformExecutor.RunCommandWithVersion(ctx.Log, path, append([]string{"init", "-input=false", "-no-color"}, extraArgs...), envs, tfVersion, ctx.Workspace)
+	if err != nil {
+		return out, err
+	}
+
+	after, err := readLockfile(path)
+	if err != nil {
+		return "", err
+	}
+	installed, err := installedProviders(path)
+	if err != nil {
+		return "", errors.Wrap(err, "finding installed providers")
+	}
+	if problems := verifyLockfile(before, after, installed); len(problems) > 0 {
+		return "", fmt.Errorf("%s verification failed:\n  - %s\n\nRun terraform init locally and commit the updated lock file. To add hashes for other platforms, run terraform providers lock -platform=linux_amd64", LockfileName, strings.Join(problems, "\n  - "))
+	}
+	i.Cache.Save(ctx, path, tfVersion)
 	return "", nil
 }
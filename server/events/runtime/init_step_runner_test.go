@@ -1,6 +1,9 @@
 package runtime_test
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	version "github.com/hashicorp/go-version"
@@ -95,3 +98,238 @@ func TestRun_ShowInitOutputOnError(t *testing.T) {
 	ErrEquals(t, "error", err)
 	Equals(t, "output", output)
 }
+
+const testLockfile = `# This file is maintained automatically by "terraform init".
+provider "registry.terraform.io/hashicorp/aws" {
+  version     = "3.37.0"
+  constraints = "~> 3.0"
+  hashes = [
+    "h1:abc=",
+    "zh:def",
+  ]
+}
+`
+
+func TestRun_VerifyLockfile(t *testing.T) {
+	cases := []struct {
+		description string
+		version     string
+		lockfile    string
+		// initLockfile is written by init if set.
+		initLockfile string
+		installed    []string
+		expErr       string
+	}{
+		{
+			description: "lock file matches",
+			version:     "0.14.0",
+			lockfile:    testLockfile,
+			installed:   []string{"registry.terraform.io/hashicorp/aws/3.37.0"},
+		},
+		{
+			description: "terraform too old",
+			version:     "0.13.5",
+			lockfile:    testLockfile,
+			expErr:      "verifying .terraform.lock.hcl requires Terraform 0.14 or later but this project uses 0.13.5",
+		},
+		{
+			description: "no lock file",
+			version:     "0.14.0",
+			expErr:      ".terraform.lock.hcl not found: run terraform init locally and commit the lock file so the versions and hashes of providers are pinned",
+		},
+		{
+			description: "init changed hashes",
+			version:     "0.15.0",
+			lockfile:    testLockfile,
+			initLockfile: `provider "registry.terraform.io/hashicorp/aws" {
+  version = "3.37.0"
+  hashes  = ["h1:abc=", "h1:new=", "zh:def"]
+}
+`,
+			installed: []string{"registry.terraform.io/hashicorp/aws/3.37.0"},
+			expErr:    "provider registry.terraform.io/hashicorp/aws hashes were changed by init",
+		},
+		{
+			description: "init added provider",
+			version:     "0.15.0",
+			lockfile:    testLockfile,
+			initLockfile: testLockfile + `
+provider "registry.terraform.io/hashicorp/null" {
+  version = "3.1.0"
+  hashes  = ["h1:xyz="]
+}
+`,
+			installed: []string{"registry.terraform.io/hashicorp/aws/3.37.0", "registry.terraform.io/hashicorp/null/3.1.0"},
+			expErr:    "provider registry.terraform.io/hashicorp/null was added by init but isn't in the committed lock file",
+		},
+		{
+			description: "installed provider isn't pinned",
+			version:     "0.14.0",
+			lockfile:    testLockfile,
+			installed:   []string{"registry.terraform.io/hashicorp/aws/3.37.0", "registry.terraform.io/hashicorp/random/3.1.0"},
+			expErr:      "provider registry.terraform.io/hashicorp/random was selected by init but isn't pinned in the lock file",
+		},
+		{
+			description: "installed version doesn't match",
+			version:     "0.14.0",
+			lockfile:    testLockfile,
+			installed:   []string{"registry.terraform.io/hashicorp/aws/3.38.0"},
+			expErr:      "provider registry.terraform.io/hashicorp/aws version 3.38.0 was selected by init but the lock file pins version 3.37.0",
+		},
+		{
+			description: "no hashes",
+			version:     "0.14.0",
+			lockfile: `provider "registry.terraform.io/hashicorp/aws" {
+  version = "3.37.0"
+}
+`,
+			installed: []string{"registry.terraform.io/hashicorp/aws/3.37.0"},
+			expErr:    "provider registry.terraform.io/hashicorp/aws has no hashes in the committed lock file",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			terraform := mocks.NewMockClient()
+			logger := logging.NewNoopLogger(t)
+			tfVersion, _ := version.NewVersion(c.version)
+			iso := runtime.InitStepRunner{
+				TerraformExecutor: terraform,
+				DefaultTFVersion:  tfVersion,
+			}
+
+			dir, cleanup := TempDir(t)
+			defer cleanup()
+			lockfilePath := filepath.Join(dir, ".terraform.lock.hcl")
+			if c.lockfile != "" {
+				Ok(t, ioutil.WriteFile(lockfilePath, []byte(c.lockfile), 0600))
+			}
+			expArgs := []string{"init", "-input=false", "-no-color", "extra"}
+			When(terraform.RunCommandWithVersion(logger, dir, expArgs, map[string]string(nil), tfVersion, "default")).
+				Then(func(_ []Param) ReturnValues {
+					if c.initLockfile != "" {
+						Ok(t, ioutil.WriteFile(lockfilePath, []byte(c.initLockfile), 0600))
+					}
+					for _, p := range c.installed {
+						Ok(t, os.MkdirAll(filepath.Join(dir, ".terraform", "providers", p), 0700))
+					}
+					return []ReturnValue{"output", nil}
+				})
+
+			output, err := iso.Run(models.ProjectCommandContext{
+				Workspace:      "default",
+				RepoRelDir:     ".",
+				Log:            logger,
+				VerifyLockfile: true,
+			}, []string{"extra"}, dir, map[string]string(nil))
+			Equals(t, "", output)
+			if c.expErr == "" {
+				Ok(t, err)
+				terraform.VerifyWasCalledOnce().RunCommandWithVersion(logger, dir, expArgs, map[string]string(nil), tfVersion, "default")
+				return
+			}
+			ErrContains(t, c.expErr, err)
+		})
+	}
+}
+
+// Test that upgrading providers runs init with -upgrade and doesn't verify
+// the lock file since upgrading changes it.
+func TestRun_UpgradeProviders(t *testing.T) {
+	RegisterMockTestingT(t)
+	terraform := mocks.NewMockClient()
+	logger := logging.NewNoopLogger(t)
+	tfVersion, _ := version.NewVersion("0.14.0")
+	iso := runtime.InitStepRunner{
+		TerraformExecutor: terraform,
+		DefaultTFVersion:  tfVersion,
+	}
+
+	dir, cleanup := TempDir(t)
+	defer cleanup()
+	lockfilePath := filepath.Join(dir, ".terraform.lock.hcl")
+	Ok(t, ioutil.WriteFile(lockfilePath, []byte(testLockfile), 0600))
+	expArgs := []string{"init", "-input=false", "-no-color", "-upgrade", "extra"}
+	When(terraform.RunCommandWithVersion(logger, dir, expArgs, map[string]string(nil), tfVersion, "default")).
+		Then(func(_ []Param) ReturnValues {
+			Ok(t, ioutil.WriteFile(lockfilePath, []byte(`provider "registry.terraform.io/hashicorp/aws" {
+  version = "3.38.0"
+  hashes  = ["h1:new="]
+}
+`), 0600))
+			return []ReturnValue{"output", nil}
+		})
+
+	output, err := iso.Run(models.ProjectCommandContext{
+		Workspace:        "default",
+		RepoRelDir:       ".",
+		Log:              logger,
+		VerifyLockfile:   true,
+		UpgradeProviders: true,
+	}, []string{"extra"}, dir, map[string]string(nil))
+	Ok(t, err)
+	Equals(t, "", output)
+	terraform.VerifyWasCalledOnce().RunCommandWithVersion(logger, dir, expArgs, map[string]string(nil), tfVersion, "default")
+}
+
+func TestRun_InitCache(t *testing.T) {
+	RegisterMockTestingT(t)
+	terraform := mocks.NewMockClient()
+	logger := logging.NewNoopLogger(t)
+	tfVersion, _ := version.NewVersion("0.14.0")
+	cacheDir, cleanup := TempDir(t)
+	defer cleanup()
+	iso := runtime.InitStepRunner{
+		TerraformExecutor: terraform,
+		DefaultTFVersion:  tfVersion,
+		Cache:             &runtime.InitCache{Dir: cacheDir},
+	}
+	ctx := models.ProjectCommandContext{
+		BaseRepo:   models.Repo{FullName: "owner/repo"},
+		Workspace:  "default",
+		RepoRelDir: "project",
+		Log:        logger,
+	}
+	newDir := func(lockfile string) string {
+		dir, cleanup := TempDir(t)
+		t.Cleanup(cleanup)
+		Ok(t, ioutil.WriteFile(filepath.Join(dir, ".terraform.lock.hcl"), []byte(lockfile), 0600))
+		return dir
+	}
+	upgradeArgs := []string{"init", "-input=false", "-no-color", "-upgrade"}
+	initArgs := []string{"init", "-input=false", "-no-color"}
+
+	// The first init installs everything and its .terraform dir is cached.
+	dir := newDir(testLockfile)
+	When(terraform.RunCommandWithVersion(logger, dir, upgradeArgs, map[string]string(nil), tfVersion, "default")).
+		Then(func(_ []Param) ReturnValues {
+			Ok(t, os.MkdirAll(filepath.Join(dir, ".terraform", "modules"), 0700))
+			Ok(t, ioutil.WriteFile(filepath.Join(dir, ".terraform", "modules", "modules.json"), []byte("{}"), 0600))
+			Ok(t, os.Symlink("/plugin-cache/aws", filepath.Join(dir, ".terraform", "aws")))
+			return []ReturnValue{"", nil}
+		})
+	_, err := iso.Run(ctx, nil, dir, map[string]string(nil))
+	Ok(t, err)
+	terraform.VerifyWasCalledOnce().RunCommandWithVersion(logger, dir, upgradeArgs, map[string]string(nil), tfVersion, "default")
+
+	// It's restored for the same lock file, without upgrading.
+	restoredDir := newDir(testLockfile)
+	_, err = iso.Run(ctx, nil, restoredDir, map[string]string(nil))
+	Ok(t, err)
+	terraform.VerifyWasCalledOnce().RunCommandWithVersion(logger, restoredDir, initArgs, map[string]string(nil), tfVersion, "default")
+	modules, err := ioutil.ReadFile(filepath.Join(restoredDir, ".terraform", "modules", "modules.json"))
+	Ok(t, err)
+	Equals(t, "{}", string(modules))
+	link, err := os.Readlink(filepath.Join(restoredDir, ".terraform", "aws"))
+	Ok(t, err)
+	Equals(t, "/plugin-cache/aws", link)
+
+	// It isn't restored once the lock file changes.
+	changedDir := newDir(testLockfile + "\n# changed\n")
+	_, err = iso.Run(ctx, nil, changedDir, map[string]string(nil))
+	Ok(t, err)
+	terraform.VerifyWasCalledOnce().RunCommandWithVersion(logger, changedDir, upgradeArgs, map[string]string(nil), tfVersion, "default")
+	_, err = os.Stat(filepath.Join(changedDir, ".terraform"))
+	Assert(t, os.IsNotExist(err), "expected .terraform not to be restored")
+}
@@ -0,0 +1,124 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	runtime_models "github.com/runatlantis/atlantis/server/events/runtime/models"
+)
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_linter.go Linter
+
+// Linter lints projects before they're planned.
+type Linter interface {
+	// Lint runs tflint on the project in path, configured by ctx.Lint, and
+	// returns the issues it found. repoDir is the root of the repo that
+	// relative config paths are relative to.
+	Lint(ctx models.ProjectCommandContext, repoDir string, path string) (models.LintFindings, error)
+}
+
+// DefaultLinter runs the tflint binary.
+type DefaultLinter struct {
+	Exec runtime_models.Exec
+}
+
+// lintSeverities are tflint's severities from most to least severe.
+var lintSeverities = []string{"error", "warning", "info"}
+
+// tflintJSON is the output of tflint --format=json.
+type tflintJSON struct {
+	Issues []struct {
+		Rule struct {
+			Name     string `json:"name"`
+			Severity string `json:"severity"`
+		} `json:"rule"`
+		Message string `json:"message"`
+		Range   struct {
+			Filename string `json:"filename"`
+			Start    struct {
+				Line int `json:"line"`
+			} `json:"start"`
+		} `json:"range"`
+	} `json:"issues"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// Lint runs tflint with the JSON format in path. If a config is set, the
+// plugins it declares are installed first with tflint --init.
+func (l *DefaultLinter) Lint(ctx models.ProjectCommandContext, repoDir string, path string) (models.LintFindings, error) {
+	var configArgs []string
+	if ctx.Lint != nil && ctx.Lint.Config != "" {
+		config := ctx.Lint.Config
+		if !filepath.IsAbs(config) {
+			config = filepath.Join(repoDir, config)
+		}
+		configArgs = []string{fmt.Sprintf("--config=%s", config)}
+		initArgs := append([]string{"tflint", "--init"}, configArgs...)
+		if out, err := l.Exec.CombinedOutput(initArgs, map[string]string{}, path); err != nil {
+			return nil, fmt.Errorf("installing tflint plugins: %s: %s", err, out)
+		}
+	}
+
+	args := append([]string{"tflint", "--format=json"}, configArgs...)
+	// tflint exits non-zero when it finds issues so the output is parsed
+	// before checking the error.
+	out, runErr := l.Exec.CombinedOutput(args, map[string]string{}, path)
+	ctx.Log.Debug("ran tflint: %s", out)
+	var result tflintJSON
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("running tflint: %s: %s", runErr, out)
+		}
+		return nil, errors.Wrap(err, "parsing tflint output")
+	}
+	if len(result.Errors) > 0 {
+		var msgs []string
+		for _, e := range result.Errors {
+			msgs = append(msgs, e.Message)
+		}
+		return nil, fmt.Errorf("running tflint: %s", strings.Join(msgs, "\n"))
+	}
+
+	var findings models.LintFindings
+	for _, issue := range result.Issues {
+		findings = append(findings, models.LintIssue{
+			Rule:     issue.Rule.Name,
+			Severity: strings.ToLower(issue.Rule.Severity),
+			Message:  issue.Message,
+			Filename: issue.Range.Filename,
+			Line:     issue.Range.Start.Line,
+		})
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Severity != findings[j].Severity {
+			ri, rj := severityRank(findings[i].Severity), severityRank(findings[j].Severity)
+			if ri != rj {
+				return ri < rj
+			}
+			return findings[i].Severity < findings[j].Severity
+		}
+		if findings[i].Filename != findings[j].Filename {
+			return findings[i].Filename < findings[j].Filename
+		}
+		return findings[i].Line < findings[j].Line
+	})
+	return findings, nil
+}
+
+// severityRank orders severities from most to least severe. Unknown
+// severities are last.
+func severityRank(severity string) int {
+	for i, s := range lintSeverities {
+		if s == severity {
+			return i
+		}
+	}
+	return len(lintSeverities)
+}
@@ -0,0 +1,83 @@
+package runtime_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	models_mocks "github.com/runatlantis/atlantis/server/events/runtime/models/mocks"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestDefaultLinter_Lint(t *testing.T) {
+	RegisterMockTestingT(t)
+	exec := models_mocks.NewMockExec()
+	linter := &runtime.DefaultLinter{Exec: exec}
+	ctx := models.ProjectCommandContext{
+		Log:  logging.NewNoopLogger(t),
+		Lint: &valid.Lint{},
+	}
+	When(exec.CombinedOutput([]string{"tflint", "--format=json"}, map[string]string{}, "/repo/project")).ThenReturn(`{
+  "issues": [
+    {"rule": {"name": "terraform_unused_declarations", "severity": "warning"}, "message": "variable \"a\" is declared but not used", "range": {"filename": "variables.tf", "start": {"line": 3}}},
+    {"rule": {"name": "terraform_deprecated_interpolation", "severity": "warning"}, "message": "Interpolation-only expressions are deprecated", "range": {"filename": "main.tf", "start": {"line": 7}}},
+    {"rule": {"name": "terraform_naming_convention", "severity": "info"}, "message": "resource name must match snake_case", "range": {"filename": "main.tf", "start": {"line": 1}}},
+    {"rule": {"name": "aws_instance_invalid_type", "severity": "error"}, "message": "\"t1.2xlarge\" is an invalid value as instance_type", "range": {"filename": "main.tf", "start": {"line": 12}}}
+  ],
+  "errors": []
+}`, errors.New("exit status 3"))
+
+	findings, err := linter.Lint(ctx, "/repo", "/repo/project")
+	Ok(t, err)
+	Equals(t, models.LintFindings{
+		{Rule: "aws_instance_invalid_type", Severity: "error", Message: "\"t1.2xlarge\" is an invalid value as instance_type", Filename: "main.tf", Line: 12},
+		{Rule: "terraform_deprecated_interpolation", Severity: "warning", Message: "Interpolation-only expressions are deprecated", Filename: "main.tf", Line: 7},
+		{Rule: "terraform_unused_declarations", Severity: "warning", Message: "variable \"a\" is declared but not used", Filename: "variables.tf", Line: 3},
+		{Rule: "terraform_naming_convention", Severity: "info", Message: "resource name must match snake_case", Filename: "main.tf", Line: 1},
+	}, findings)
+}
+
+func TestDefaultLinter_LintConfig(t *testing.T) {
+	RegisterMockTestingT(t)
+	exec := models_mocks.NewMockExec()
+	linter := &runtime.DefaultLinter{Exec: exec}
+	ctx := models.ProjectCommandContext{
+		Log:  logging.NewNoopLogger(t),
+		Lint: &valid.Lint{Config: "lint/.tflint.hcl"},
+	}
+	When(exec.CombinedOutput([]string{"tflint", "--init", "--config=/repo/lint/.tflint.hcl"}, map[string]string{}, "/repo/project")).ThenReturn("", nil)
+	When(exec.CombinedOutput([]string{"tflint", "--format=json", "--config=/repo/lint/.tflint.hcl"}, map[string]string{}, "/repo/project")).ThenReturn(`{"issues": [], "errors": []}`, nil)
+
+	findings, err := linter.Lint(ctx, "/repo", "/repo/project")
+	Ok(t, err)
+	Equals(t, 0, len(findings))
+
+	// Absolute paths are on the Atlantis server.
+	ctx.Lint.Config = "/etc/atlantis/.tflint.hcl"
+	When(exec.CombinedOutput([]string{"tflint", "--init", "--config=/etc/atlantis/.tflint.hcl"}, map[string]string{}, "/repo/project")).ThenReturn("Failed to install a plugin", errors.New("exit status 1"))
+	_, err = linter.Lint(ctx, "/repo", "/repo/project")
+	ErrEquals(t, "installing tflint plugins: exit status 1: Failed to install a plugin", err)
+}
+
+func TestDefaultLinter_LintErrors(t *testing.T) {
+	RegisterMockTestingT(t)
+	exec := models_mocks.NewMockExec()
+	linter := &runtime.DefaultLinter{Exec: exec}
+	ctx := models.ProjectCommandContext{
+		Log:  logging.NewNoopLogger(t),
+		Lint: &valid.Lint{},
+	}
+	When(exec.CombinedOutput([]string{"tflint", "--format=json"}, map[string]string{}, "/repo/project")).
+		ThenReturn(`{"issues": [], "errors": [{"message": "main.tf:1,1-2: Argument or block definition required", "severity": "error"}]}`, errors.New("exit status 2"))
+	_, err := linter.Lint(ctx, "/repo", "/repo/project")
+	ErrEquals(t, "running tflint: main.tf:1,1-2: Argument or block definition required", err)
+
+	When(exec.CombinedOutput([]string{"tflint", "--format=json"}, map[string]string{}, "/repo/project")).
+		ThenReturn("sh: tflint: not found", errors.New("exit status 127"))
+	_, err = linter.Lint(ctx, "/repo", "/repo/project")
+	ErrEquals(t, "running tflint: exit status 127: sh: tflint: not found", err)
+}
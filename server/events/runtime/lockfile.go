@@ -0,0 +1,201 @@
+package runtime
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// LockfileName is the name of Terraform's dependency lock file.
+const LockfileName = ".terraform.lock.hcl"
+
+// lockfile is the parsed dependency lock file. It maps provider source
+// addresses, ex. "registry.terraform.io/hashicorp/aws", to their locks.
+type lockfile map[string]providerLock
+
+type providerLock struct {
+	Version string
+	Hashes  []string
+}
+
+// lockfileSchema is the subset of the lock file that we check.
+type lockfileSchema struct {
+	Providers []struct {
+		Address string   `hcl:"address,label"`
+		Version string   `hcl:"version"`
+		Hashes  []string `hcl:"hashes,optional"`
+		Remain  hcl.Body `hcl:",remain"`
+	} `hcl:"provider,block"`
+	Remain hcl.Body `hcl:",remain"`
+}
+
+// readLockfile parses the lock file in dir. It returns nil if the file
+// doesn't exist.
+func readLockfile(dir string) (lockfile, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(dir, LockfileName)) // nolint: gosec
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", LockfileName)
+	}
+	return parseLockfile(contents)
+}
+
+func parseLockfile(contents []byte) (lockfile, error) {
+	f, diags := hclparse.NewParser().ParseHCL(contents, LockfileName)
+	if diags.HasErrors() {
+		return nil, errors.Wrapf(diags, "parsing %s", LockfileName)
+	}
+	var schema lockfileSchema
+	if diags := gohcl.DecodeBody(f.Body, nil, &schema); diags.HasErrors() {
+		return nil, errors.Wrapf(diags, "parsing %s", LockfileName)
+	}
+	locks := make(lockfile)
+	for _, p := range schema.Providers {
+		locks[p.Address] = providerLock{Version: p.Version, Hashes: p.Hashes}
+	}
+	return locks, nil
+}
+
+// ProviderVersions returns the versions of the providers pinned in the
+// dependency lock file in dir, keyed by source address. It returns nil if
+// there's no lock file, ex. before Terraform 0.14.
+func ProviderVersions(dir string) (map[string]string, error) {
+	locks, err := readLockfile(dir)
+	if err != nil || locks == nil {
+		return nil, err
+	}
+	versions := make(map[string]string)
+	for addr, lock := range locks {
+		versions[addr] = lock.Version
+	}
+	return versions, nil
+}
+
+// ProviderUpgrades returns the providers whose versions differ between the
+// versions before and after upgrading, sorted by source address.
+func ProviderUpgrades(before map[string]string, after map[string]string) []models.ProviderUpgrade {
+	var addrs []string
+	for addr := range before {
+		addrs = append(addrs, addr)
+	}
+	for addr := range after {
+		if _, ok := before[addr]; !ok {
+			addrs = append(addrs, addr)
+		}
+	}
+	sort.Strings(addrs)
+	var upgrades []models.ProviderUpgrade
+	for _, addr := range addrs {
+		if before[addr] != after[addr] {
+			upgrades = append(upgrades, models.ProviderUpgrade{Provider: addr, From: before[addr], To: after[addr]})
+		}
+	}
+	return upgrades
+}
+
+// installedProviders returns the versions of the providers that init
+// installed in dir, keyed by source address. Since Terraform 0.14 they're
+// installed in .terraform/providers/{hostname}/{namespace}/{type}/{version}.
+func installedProviders(dir string) (map[string]string, error) {
+	providersDir := filepath.Join(dir, ".terraform", "providers")
+	versionDirs, err := filepath.Glob(filepath.Join(providersDir, "*", "*", "*", "*"))
+	if err != nil {
+		return nil, err
+	}
+	installed := make(map[string]string)
+	for _, d := range versionDirs {
+		rel, err := filepath.Rel(providersDir, d)
+		if err != nil {
+			return nil, err
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		installed[strings.Join(parts[:3], "/")] = parts[3]
+	}
+	return installed, nil
+}
+
+// verifyLockfile compares the lock file before init, before, to the lock file
+// after init, after, and to the providers init installed. It returns a
+// description of each problem found.
+func verifyLockfile(before lockfile, after lockfile, installed map[string]string) []string {
+	var problems []string
+	for _, addr := range sortedKeys(before, after) {
+		b, inBefore := before[addr]
+		a, inAfter := after[addr]
+		switch {
+		case !inBefore:
+			problems = append(problems, fmt.Sprintf("provider %s was added by init but isn't in the committed lock file", addr))
+		case !inAfter:
+			problems = append(problems, fmt.Sprintf("provider %s was removed by init", addr))
+		case b.Version != a.Version:
+			problems = append(problems, fmt.Sprintf("provider %s was changed by init from version %s to %s", addr, b.Version, a.Version))
+		case !sameHashes(b.Hashes, a.Hashes):
+			problems = append(problems, fmt.Sprintf("provider %s hashes were changed by init", addr))
+		}
+		if inBefore && len(b.Hashes) == 0 {
+			problems = append(problems, fmt.Sprintf("provider %s has no hashes in the committed lock file", addr))
+		}
+	}
+
+	var addrs []string
+	for addr := range installed {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		lock, ok := before[addr]
+		if !ok {
+			// Already reported above if init added it to the lock file.
+			if _, inAfter := after[addr]; !inAfter {
+				problems = append(problems, fmt.Sprintf("provider %s was selected by init but isn't pinned in the lock file", addr))
+			}
+			continue
+		}
+		if lock.Version != installed[addr] {
+			problems = append(problems, fmt.Sprintf("provider %s version %s was selected by init but the lock file pins version %s", addr, installed[addr], lock.Version))
+		}
+	}
+	return problems
+}
+
+func sortedKeys(files ...lockfile) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, f := range files {
+		for k := range f {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sameHashes(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
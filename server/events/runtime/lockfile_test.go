@@ -0,0 +1,45 @@
+package runtime_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestProviderVersions(t *testing.T) {
+	dir, cleanup := TempDir(t)
+	defer cleanup()
+
+	versions, err := runtime.ProviderVersions(dir)
+	Ok(t, err)
+	Equals(t, map[string]string(nil), versions)
+
+	Ok(t, ioutil.WriteFile(filepath.Join(dir, ".terraform.lock.hcl"), []byte(testLockfile), 0600))
+	versions, err = runtime.ProviderVersions(dir)
+	Ok(t, err)
+	Equals(t, map[string]string{"registry.terraform.io/hashicorp/aws": "3.37.0"}, versions)
+}
+
+func TestProviderUpgrades(t *testing.T) {
+	before := map[string]string{
+		"registry.terraform.io/hashicorp/aws":    "3.37.0",
+		"registry.terraform.io/hashicorp/null":   "3.1.0",
+		"registry.terraform.io/hashicorp/random": "3.0.0",
+	}
+	after := map[string]string{
+		"registry.terraform.io/hashicorp/aws":    "3.38.0",
+		"registry.terraform.io/hashicorp/google": "4.0.0",
+		"registry.terraform.io/hashicorp/random": "3.0.0",
+	}
+	Equals(t, []models.ProviderUpgrade{
+		{Provider: "registry.terraform.io/hashicorp/aws", From: "3.37.0", To: "3.38.0"},
+		{Provider: "registry.terraform.io/hashicorp/google", To: "4.0.0"},
+		{Provider: "registry.terraform.io/hashicorp/null", From: "3.1.0"},
+	}, runtime.ProviderUpgrades(before, after))
+
+	Equals(t, 0, len(runtime.ProviderUpgrades(before, before)))
+}
@@ -0,0 +1,128 @@
+package runtime
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+const (
+	// GzipCompression compresses plans with gzip.
+	GzipCompression = "gzip"
+	// ZstdCompression compresses plans with zstd, which is faster and
+	// compresses better than gzip.
+	ZstdCompression = "zstd"
+)
+
+// compressedPlanHeader prefixes compressed files, followed by the
+// compression algorithm and a newline. Files without it are treated as
+// uncompressed so that enabling compression doesn't break existing plans.
+const compressedPlanHeader = "atlantis-compressed-plan-v1:"
+
+// PlanCompressor compresses plan files, and the JSON rendered from them by
+// show steps, at rest. They're decompressed before terraform runs and
+// compressed again afterwards. Compression happens before encryption since
+// encrypted files don't compress. A nil *PlanCompressor doesn't compress.
+type PlanCompressor struct {
+	algorithm string
+}
+
+// NewPlanCompressor returns a compressor using algorithm, either
+// GzipCompression or ZstdCompression.
+func NewPlanCompressor(algorithm string) (*PlanCompressor, error) {
+	if algorithm != GzipCompression && algorithm != ZstdCompression {
+		return nil, fmt.Errorf("unsupported compression algorithm %q", algorithm)
+	}
+	return &PlanCompressor{algorithm: algorithm}, nil
+}
+
+// Compress compresses the file at path in place. It does nothing if the file
+// doesn't exist or is already compressed or encrypted.
+func (c *PlanCompressor) Compress(path string) error {
+	if c == nil {
+		return nil
+	}
+	contents, info, err := readPlanFile(path)
+	if err != nil || contents == nil || bytes.HasPrefix(contents, []byte(compressedPlanHeader)) || bytes.HasPrefix(contents, []byte(encryptedPlanHeader)) {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(compressedPlanHeader + c.algorithm + "\n")
+	w, err := c.writer(&buf)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(contents); err != nil {
+		return errors.Wrapf(err, "compressing %s", path)
+	}
+	if err := w.Close(); err != nil {
+		return errors.Wrapf(err, "compressing %s", path)
+	}
+	return errors.Wrapf(writePlanFile(path, buf.Bytes(), info), "compressing %s", path)
+}
+
+// DecompressPlan decompresses the file at path in place. It does nothing if
+// the file doesn't exist or isn't compressed. Any algorithm is decompressed,
+// so plans stay readable when compression is changed or disabled.
+func DecompressPlan(path string) error {
+	contents, info, err := readPlanFile(path)
+	if err != nil || contents == nil || !bytes.HasPrefix(contents, []byte(compressedPlanHeader)) {
+		return err
+	}
+	decompressed, err := decompress(contents)
+	if err != nil {
+		return errors.Wrapf(err, "decompressing %s", path)
+	}
+	return errors.Wrapf(writePlanFile(path, decompressed, info), "decompressing %s", path)
+}
+
+// ReadDecompressed returns the contents of the file at path, decompressed if
+// needed, without changing the file.
+func ReadDecompressed(path string) ([]byte, error) {
+	contents, err := ioutil.ReadFile(path) // nolint: gosec
+	if err != nil || !bytes.HasPrefix(contents, []byte(compressedPlanHeader)) {
+		return contents, err
+	}
+	decompressed, err := decompress(contents)
+	return decompressed, errors.Wrapf(err, "decompressing %s", path)
+}
+
+func (c *PlanCompressor) writer(w io.Writer) (io.WriteCloser, error) {
+	if c.algorithm == ZstdCompression {
+		return zstd.NewWriter(w)
+	}
+	return gzip.NewWriter(w), nil
+}
+
+// decompress decompresses contents, which start with compressedPlanHeader.
+func decompress(contents []byte) ([]byte, error) {
+	header := contents[len(compressedPlanHeader):]
+	end := bytes.IndexByte(header, '\n')
+	if end < 0 {
+		return nil, errors.New("file is truncated")
+	}
+	algorithm, compressed := string(header[:end]), bytes.NewReader(header[end+1:])
+	switch algorithm {
+	case GzipCompression:
+		r, err := gzip.NewReader(compressed)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close() // nolint: errcheck
+		return ioutil.ReadAll(r)
+	case ZstdCompression:
+		r, err := zstd.NewReader(compressed)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	default:
+		return nil, fmt.Errorf("unsupported compression algorithm %q", algorithm)
+	}
+}
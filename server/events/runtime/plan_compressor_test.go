@@ -0,0 +1,109 @@
+package runtime_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestNewPlanCompressor_InvalidAlgorithm(t *testing.T) {
+	_, err := runtime.NewPlanCompressor("bzip2")
+	ErrEquals(t, `unsupported compression algorithm "bzip2"`, err)
+}
+
+func TestPlanCompressor_RoundTrip(t *testing.T) {
+	for _, algorithm := range []string{runtime.GzipCompression, runtime.ZstdCompression} {
+		t.Run(algorithm, func(t *testing.T) {
+			tmp, cleanup := TempDir(t)
+			defer cleanup()
+			planPath := filepath.Join(tmp, "default.tfplan")
+			plan := bytes.Repeat([]byte("resource changes\n"), 1000)
+			Ok(t, ioutil.WriteFile(planPath, plan, 0600))
+			plannedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+			Ok(t, os.Chtimes(planPath, plannedAt, plannedAt))
+
+			c, err := runtime.NewPlanCompressor(algorithm)
+			Ok(t, err)
+			Ok(t, c.Compress(planPath))
+			compressed, err := ioutil.ReadFile(planPath)
+			Ok(t, err)
+			Assert(t, len(compressed) < len(plan)/10, "exp plan to be compressed, got %d bytes", len(compressed))
+
+			// Compressing again does nothing.
+			Ok(t, c.Compress(planPath))
+			again, err := ioutil.ReadFile(planPath)
+			Ok(t, err)
+			Equals(t, compressed, again)
+
+			read, err := runtime.ReadDecompressed(planPath)
+			Ok(t, err)
+			Equals(t, plan, read)
+
+			Ok(t, runtime.DecompressPlan(planPath))
+			decompressed, err := ioutil.ReadFile(planPath)
+			Ok(t, err)
+			Equals(t, plan, decompressed)
+
+			// The modification time is kept since it's used as the time of
+			// the plan.
+			info, err := os.Stat(planPath)
+			Ok(t, err)
+			Assert(t, info.ModTime().Equal(plannedAt), "exp mod time %s, got %s", plannedAt, info.ModTime())
+
+			// Decompressing an uncompressed plan does nothing.
+			Ok(t, runtime.DecompressPlan(planPath))
+			decompressed, err = ioutil.ReadFile(planPath)
+			Ok(t, err)
+			Equals(t, plan, decompressed)
+		})
+	}
+}
+
+func TestPlanCompressor_Encrypted(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	planPath := filepath.Join(tmp, "default.tfplan")
+	Ok(t, ioutil.WriteFile(planPath, []byte("plan"), 0600))
+
+	c, err := runtime.NewPlanCompressor(runtime.GzipCompression)
+	Ok(t, err)
+	e, err := runtime.NewAESGCMPlanEncryptor(bytes.Repeat([]byte("k"), 16))
+	Ok(t, err)
+	Ok(t, c.Compress(planPath))
+	Ok(t, e.Encrypt(planPath))
+	encrypted, err := ioutil.ReadFile(planPath)
+	Ok(t, err)
+
+	// Encrypted plans aren't compressed since they wouldn't shrink.
+	Ok(t, c.Compress(planPath))
+	again, err := ioutil.ReadFile(planPath)
+	Ok(t, err)
+	Equals(t, encrypted, again)
+
+	Ok(t, e.Decrypt(planPath))
+	Ok(t, runtime.DecompressPlan(planPath))
+	decompressed, err := ioutil.ReadFile(planPath)
+	Ok(t, err)
+	Equals(t, []byte("plan"), decompressed)
+}
+
+func TestPlanCompressor_Nil(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	planPath := filepath.Join(tmp, "default.tfplan")
+	Ok(t, ioutil.WriteFile(planPath, []byte("plan"), 0600))
+
+	var c *runtime.PlanCompressor
+	Ok(t, c.Compress(planPath))
+	contents, err := ioutil.ReadFile(planPath)
+	Ok(t, err)
+	Equals(t, []byte("plan"), contents)
+	// Missing files are ignored.
+	Ok(t, runtime.DecompressPlan(filepath.Join(tmp, "missing.tfplan")))
+}
@@ -0,0 +1,126 @@
+package runtime
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/pkg/errors"
+)
+
+// encryptedPlanHeader prefixes encrypted plan files. Files without it are
+// treated as unencrypted so that enabling encryption doesn't break existing
+// plans.
+const encryptedPlanHeader = "atlantis-encrypted-plan-v1\n"
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_plan_encryptor.go PlanEncryptor
+
+// PlanEncryptor encrypts plan files at rest. Plans are decrypted before
+// terraform runs and encrypted again afterwards.
+type PlanEncryptor interface {
+	// Encrypt encrypts the plan file at path in place. It does nothing if the
+	// file doesn't exist or is already encrypted.
+	Encrypt(path string) error
+	// Decrypt decrypts the plan file at path in place. It does nothing if the
+	// file doesn't exist or isn't encrypted.
+	Decrypt(path string) error
+}
+
+// AESGCMPlanEncryptor encrypts plan files with AES-GCM.
+type AESGCMPlanEncryptor struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCMPlanEncryptor returns an encryptor using key, which must be 16, 24
+// or 32 bytes to select AES-128, AES-192 or AES-256.
+func NewAESGCMPlanEncryptor(key []byte) (*AESGCMPlanEncryptor, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AESGCMPlanEncryptor{aead: aead}, nil
+}
+
+// Encrypt encrypts the plan file at path in place.
+func (a *AESGCMPlanEncryptor) Encrypt(path string) error {
+	contents, info, err := readPlanFile(path)
+	if err != nil || contents == nil || bytes.HasPrefix(contents, []byte(encryptedPlanHeader)) {
+		return err
+	}
+	nonce := make([]byte, a.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return errors.Wrap(err, "generating nonce")
+	}
+	// The header is authenticated so it can't be swapped out.
+	encrypted := append([]byte(encryptedPlanHeader), nonce...)
+	encrypted = a.aead.Seal(encrypted, nonce, contents, []byte(encryptedPlanHeader))
+	return errors.Wrapf(writePlanFile(path, encrypted, info), "encrypting %s", path)
+}
+
+// Decrypt decrypts the plan file at path in place.
+func (a *AESGCMPlanEncryptor) Decrypt(path string) error {
+	contents, info, err := readPlanFile(path)
+	if err != nil || contents == nil || !bytes.HasPrefix(contents, []byte(encryptedPlanHeader)) {
+		return err
+	}
+	encrypted := contents[len(encryptedPlanHeader):]
+	if len(encrypted) < a.aead.NonceSize() {
+		return errors.Errorf("decrypting %s: file is truncated", path)
+	}
+	nonce, ciphertext := encrypted[:a.aead.NonceSize()], encrypted[a.aead.NonceSize():]
+	decrypted, err := a.aead.Open(nil, nonce, ciphertext, []byte(encryptedPlanHeader))
+	if err != nil {
+		return errors.Wrapf(err, "decrypting %s", path)
+	}
+	return errors.Wrapf(writePlanFile(path, decrypted, info), "decrypting %s", path)
+}
+
+// DecryptKMSDataKey decrypts a data key encrypted with AWS KMS, ex. the
+// CiphertextBlob from aws kms generate-data-key. The region and credentials
+// come from the environment.
+func DecryptKMSDataKey(ciphertext []byte) ([]byte, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating AWS session")
+	}
+	out, err := kms.New(sess).Decrypt(&kms.DecryptInput{CiphertextBlob: ciphertext})
+	if err != nil {
+		return nil, errors.Wrap(err, "decrypting data key with KMS")
+	}
+	return out.Plaintext, nil
+}
+
+// readPlanFile returns nil contents if path doesn't exist.
+func readPlanFile(path string) ([]byte, os.FileInfo, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	contents, err := ioutil.ReadFile(path) // nolint: gosec
+	if err != nil {
+		return nil, nil, err
+	}
+	return contents, info, nil
+}
+
+// writePlanFile overwrites the plan file at path, keeping its modification
+// time since it's used as the time of the plan.
+func writePlanFile(path string, contents []byte, info os.FileInfo) error {
+	if err := ioutil.WriteFile(path, contents, info.Mode()); err != nil {
+		return err
+	}
+	return os.Chtimes(path, info.ModTime(), info.ModTime())
+}
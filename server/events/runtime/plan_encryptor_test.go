@@ -0,0 +1,84 @@
+package runtime_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestNewAESGCMPlanEncryptor_InvalidKey(t *testing.T) {
+	_, err := runtime.NewAESGCMPlanEncryptor([]byte("short"))
+	ErrEquals(t, "crypto/aes: invalid key size 5", err)
+}
+
+func TestAESGCMPlanEncryptor_RoundTrip(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	planPath := filepath.Join(tmp, "default.tfplan")
+	plan := []byte("plan with a secret")
+	Ok(t, ioutil.WriteFile(planPath, plan, 0600))
+	plannedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	Ok(t, os.Chtimes(planPath, plannedAt, plannedAt))
+
+	e, err := runtime.NewAESGCMPlanEncryptor(bytes.Repeat([]byte("k"), 32))
+	Ok(t, err)
+	Ok(t, e.Encrypt(planPath))
+	encrypted, err := ioutil.ReadFile(planPath)
+	Ok(t, err)
+	Assert(t, !bytes.Contains(encrypted, plan), "exp plan to be encrypted")
+
+	// Encrypting again does nothing.
+	Ok(t, e.Encrypt(planPath))
+	again, err := ioutil.ReadFile(planPath)
+	Ok(t, err)
+	Equals(t, encrypted, again)
+
+	Ok(t, e.Decrypt(planPath))
+	decrypted, err := ioutil.ReadFile(planPath)
+	Ok(t, err)
+	Equals(t, plan, decrypted)
+
+	// The modification time is kept since it's used as the time of the plan.
+	info, err := os.Stat(planPath)
+	Ok(t, err)
+	Assert(t, info.ModTime().Equal(plannedAt), "exp mod time %s, got %s", plannedAt, info.ModTime())
+
+	// Decrypting an unencrypted plan does nothing.
+	Ok(t, e.Decrypt(planPath))
+	decrypted, err = ioutil.ReadFile(planPath)
+	Ok(t, err)
+	Equals(t, plan, decrypted)
+}
+
+func TestAESGCMPlanEncryptor_WrongKey(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	planPath := filepath.Join(tmp, "default.tfplan")
+	Ok(t, ioutil.WriteFile(planPath, []byte("plan"), 0600))
+
+	e, err := runtime.NewAESGCMPlanEncryptor(bytes.Repeat([]byte("k"), 16))
+	Ok(t, err)
+	Ok(t, e.Encrypt(planPath))
+	other, err := runtime.NewAESGCMPlanEncryptor(bytes.Repeat([]byte("o"), 16))
+	Ok(t, err)
+	ErrContains(t, "message authentication failed", other.Decrypt(planPath))
+}
+
+func TestAESGCMPlanEncryptor_MissingFile(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	planPath := filepath.Join(tmp, "default.tfplan")
+
+	e, err := runtime.NewAESGCMPlanEncryptor(bytes.Repeat([]byte("k"), 24))
+	Ok(t, err)
+	Ok(t, e.Encrypt(planPath))
+	Ok(t, e.Decrypt(planPath))
+	_, err = os.Stat(planPath)
+	Assert(t, os.IsNotExist(err), "exp plan not to be created")
+}
@@ -30,6 +30,9 @@ type PlanStepRunner struct {
 	DefaultTFVersion    *version.Version
 	CommitStatusUpdater StatusUpdater
 	AsyncTFExec         AsyncTFExec
+	// TFCRuns, if set, is used to get the status of the runs of projects
+	// using TFE remote ops.
+	TFCRuns TFCRunClient
 }
 
 func (p *PlanStepRunner) Run(ctx models.ProjectCommandContext, extraArgs []string, path string, envs map[string]string) (string, error) {
@@ -75,9 +78,9 @@ func (p *PlanStepRunner) remotePlan(ctx models.ProjectCommandContext, extraArgs
 		ctx.EscapedCommentArgs,
 	}
 	args := p.flatten(argList)
-	output, err := p.runRemotePlan(ctx, args, path, tfVersion, envs)
+	output, runHeader, err := p.runRemotePlan(ctx, args, path, tfVersion, envs)
 	if err != nil {
-		return output, err
+		return runHeader + output, err
 	}
 
 	// If using remote ops, we create our own "fake" planfile with the
@@ -97,7 +100,7 @@ func (p *PlanStepRunner) remotePlan(ctx models.ProjectCommandContext, extraArgs
 		return output, errors.Wrap(err, "unable to create planfile for remote ops")
 	}
 
-	return p.fmtPlanOutput(output, tfVersion), nil
+	return runHeader + p.fmtPlanOutput(output, tfVersion), nil
 }
 
 // switchWorkspace changes the terraform workspace if necessary and will create
@@ -235,6 +238,8 @@ func (p *PlanStepRunner) fmtPlanOutput(output string, tfVersion *version.Version
 // then updates the commit status with a link to the run url.
 // The run url is a link to the Terraform Enterprise UI where the output
 // from the in-progress command can be viewed.
+// Along with the output, it returns a header linking to the run for the
+// pull request comment.
 // cmdArgs is the args to terraform to execute.
 // path is the path to where we need to execute.
 func (p *PlanStepRunner) runRemotePlan(
@@ -242,7 +247,7 @@ func (p *PlanStepRunner) runRemotePlan(
 	cmdArgs []string,
 	path string,
 	tfVersion *version.Version,
-	envs map[string]string) (string, error) {
+	envs map[string]string) (string, string, error) {
 
 	// updateStatusF will update the commit status and log any error.
 	updateStatusF := func(status models.CommitStatus, url string) {
@@ -280,12 +285,16 @@ func (p *PlanStepRunner) runRemotePlan(
 
 	ctx.Log.Debug("async tf remote operation complete")
 	output := strings.Join(lines, "\n")
+	runStatus := remoteRunStatus(ctx, p.TFCRuns, runURL)
+	if err == nil && runStatus != "" && TFCRunCommitStatus(models.PlanCommand, runStatus) == models.FailedCommitStatus {
+		err = fmt.Errorf("remote run %s", runStatus)
+	}
 	if err != nil {
 		updateStatusF(models.FailedCommitStatus, runURL)
-	} else {
-		updateStatusF(models.SuccessCommitStatus, runURL)
+	} else if statusErr := p.CommitStatusUpdater.UpdatePlanProject(ctx, models.SuccessCommitStatus, runURL, models.ParsePlanCounts(output)); statusErr != nil {
+		ctx.Log.Err("unable to update status: %s", statusErr)
 	}
-	return output, err
+	return output, tfcRunHeader(runURL, runStatus), err
 }
 
 func StripRefreshingFromPlanOutput(output string, tfVersion *version.Version) string {
@@ -779,7 +779,9 @@ locally at this time.
 			}
 			output, err := s.Run(ctx, []string{"extra", "args"}, absProjectPath, map[string]string(nil))
 			Ok(t, err)
-			Equals(t, `
+			Equals(t, `Terraform Cloud run: https://app.terraform.io/app/lkysow-enterprises/atlantis-tfe-test/runs/run-is4oVvJfrkud1KvE
+
+
 An execution plan has been generated and is shown below.
 Resource actions are indicated with the following symbols:
 - destroy
@@ -813,7 +815,7 @@ Plan: 0 to add, 0 to change, 1 to destroy.`, string(bytes))
 			// Ensure that the status was updated with the runURL.
 			runURL := "https://app.terraform.io/app/lkysow-enterprises/atlantis-tfe-test/runs/run-is4oVvJfrkud1KvE"
 			updater.VerifyWasCalledOnce().UpdateProject(ctx, models.PlanCommand, models.PendingCommitStatus, runURL)
-			updater.VerifyWasCalledOnce().UpdateProject(ctx, models.PlanCommand, models.SuccessCommitStatus, runURL)
+			updater.VerifyWasCalledOnce().UpdatePlanProject(ctx, models.SuccessCommitStatus, runURL, &models.PlanCounts{Destroy: 1})
 		})
 	}
 }
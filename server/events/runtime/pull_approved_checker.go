@@ -9,3 +9,15 @@ import (
 type PullApprovedChecker interface {
 	PullIsApproved(baseRepo models.Repo, pull models.PullRequest) (bool, error)
 }
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_pull_approvals_getter.go PullApprovalsGetter
+
+type PullApprovalsGetter interface {
+	GetApprovals(repo models.Repo, pull models.PullRequest) ([]models.Approval, error)
+}
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_pull_pipeline_getter.go PullPipelineGetter
+
+type PullPipelineGetter interface {
+	GetPipeline(repo models.Repo, pull models.PullRequest) (models.Pipeline, error)
+}
@@ -0,0 +1,73 @@
+package runtime
+
+import (
+	"encoding/json"
+	"path/filepath"
+
+	"github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_risk_checker.go RiskChecker
+
+// RiskChecker finds the resources that plans destroy or replace.
+type RiskChecker interface {
+	// Check returns the resources that the plan in path replaces and
+	// destroys, grouped by action in that order. It returns nil if the plan
+	// neither replaces nor destroys anything.
+	Check(ctx models.ProjectCommandContext, path string) ([]models.ResourceChanges, error)
+}
+
+// DefaultRiskChecker checks the JSON output of terraform show.
+type DefaultRiskChecker struct {
+	TerraformExecutor TerraformExec
+	DefaultTFVersion  *version.Version
+}
+
+// Check runs terraform show on the plan file and returns the resources whose
+// actions include delete. Resources that are also created are replaced.
+func (r *DefaultRiskChecker) Check(ctx models.ProjectCommandContext, path string) ([]models.ResourceChanges, error) {
+	tfVersion := r.DefaultTFVersion
+	if ctx.TerraformVersion != nil {
+		tfVersion = ctx.TerraformVersion
+	}
+	planFile := filepath.Join(path, GetPlanFilename(ctx.Workspace, ctx.ProjectName))
+	output, err := r.TerraformExecutor.RunCommandWithVersion(ctx.Log, path, []string{"show", "-no-color", "-json", filepath.Clean(planFile)}, map[string]string{}, tfVersion, ctx.Workspace)
+	if err != nil {
+		return nil, errors.Wrap(err, "running terraform show")
+	}
+	var plan planJSON
+	if err := json.Unmarshal([]byte(output), &plan); err != nil {
+		return nil, errors.Wrap(err, "parsing terraform show output")
+	}
+
+	var replaced, destroyed []string
+	for _, rc := range plan.ResourceChanges {
+		if !deletes(rc.Change.Actions) {
+			continue
+		}
+		if createsOrUpdates(rc.Change.Actions) {
+			replaced = append(replaced, rc.Address)
+		} else {
+			destroyed = append(destroyed, rc.Address)
+		}
+	}
+	var changes []models.ResourceChanges
+	if len(replaced) > 0 {
+		changes = append(changes, models.ResourceChanges{Action: models.ReplaceResourceAction, Addresses: replaced})
+	}
+	if len(destroyed) > 0 {
+		changes = append(changes, models.ResourceChanges{Action: models.DeleteResourceAction, Addresses: destroyed})
+	}
+	return changes, nil
+}
+
+func deletes(actions []string) bool {
+	for _, a := range actions {
+		if a == "delete" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package runtime_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-version"
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/terraform/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestDefaultRiskChecker_Check(t *testing.T) {
+	cases := []struct {
+		description string
+		showOutput  string
+		exp         []models.ResourceChanges
+	}{
+		{
+			description: "replaces and destroys",
+			showOutput: `{
+  "resource_changes": [
+    {"address": "aws_instance.web", "change": {"actions": ["create"]}},
+    {"address": "aws_db_instance.db", "change": {"actions": ["delete", "create"]}},
+    {"address": "aws_s3_bucket.logs", "change": {"actions": ["delete"]}},
+    {"address": "module.app.random_id.id", "change": {"actions": ["create", "delete"]}},
+    {"address": "null_resource.script", "change": {"actions": ["no-op"]}}
+  ]
+}`,
+			exp: []models.ResourceChanges{
+				{Action: models.ReplaceResourceAction, Addresses: []string{"aws_db_instance.db", "module.app.random_id.id"}},
+				{Action: models.DeleteResourceAction, Addresses: []string{"aws_s3_bucket.logs"}},
+			},
+		},
+		{
+			description: "only destroys",
+			showOutput:  `{"resource_changes": [{"address": "aws_s3_bucket.logs", "change": {"actions": ["delete"]}}]}`,
+			exp: []models.ResourceChanges{
+				{Action: models.DeleteResourceAction, Addresses: []string{"aws_s3_bucket.logs"}},
+			},
+		},
+		{
+			description: "nothing risky",
+			showOutput:  `{"resource_changes": [{"address": "aws_instance.web", "change": {"actions": ["update"]}}]}`,
+		},
+		{
+			description: "no changes",
+			showOutput:  `{}`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			logger := logging.NewNoopLogger(t)
+			tfVersion, _ := version.NewVersion("0.14.0")
+			executor := mocks.NewMockClient()
+			checker := &runtime.DefaultRiskChecker{
+				TerraformExecutor: executor,
+				DefaultTFVersion:  tfVersion,
+			}
+			ctx := models.ProjectCommandContext{
+				Log:       logger,
+				Workspace: "default",
+			}
+			When(executor.RunCommandWithVersion(logger, "/path", []string{"show", "-no-color", "-json", filepath.Join("/path", "default.tfplan")}, map[string]string{}, tfVersion, "default")).
+				ThenReturn(c.showOutput, nil)
+
+			changes, err := checker.Check(ctx, "/path")
+			Ok(t, err)
+			Equals(t, c.exp, changes)
+		})
+	}
+}
+
+func TestDefaultRiskChecker_ShowError(t *testing.T) {
+	RegisterMockTestingT(t)
+	logger := logging.NewNoopLogger(t)
+	executor := mocks.NewMockClient()
+	checker := &runtime.DefaultRiskChecker{TerraformExecutor: executor}
+	ctx := models.ProjectCommandContext{
+		Log:       logger,
+		Workspace: "default",
+	}
+	When(executor.RunCommandWithVersion(logger, "/path", []string{"show", "-no-color", "-json", filepath.Join("/path", "default.tfplan")}, map[string]string{}, nil, "default")).
+		ThenReturn("", errors.New("err"))
+
+	_, err := checker.Check(ctx, "/path")
+	ErrEquals(t, "running terraform show: err", err)
+}
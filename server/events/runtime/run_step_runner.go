@@ -5,9 +5,13 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/google/uuid"
 	"github.com/hashicorp/go-version"
+	"github.com/runatlantis/atlantis/server/events/limits"
 	"github.com/runatlantis/atlantis/server/events/models"
 )
 
@@ -17,6 +21,13 @@ type RunStepRunner struct {
 	DefaultTFVersion  *version.Version
 	// TerraformBinDir is the directory where Atlantis downloads Terraform binaries.
 	TerraformBinDir string
+	// ContainerImage is the image of the containers commands are run in if
+	// their workflow doesn't set one. If both are empty, commands are run
+	// by Atlantis.
+	ContainerImage string
+	// Limits limits the time, output and resources of commands. If nil,
+	// they aren't limited.
+	Limits *limits.Config
 }
 
 func (r *RunStepRunner) Run(ctx models.ProjectCommandContext, command string, path string, envs map[string]string) (string, error) {
@@ -32,11 +43,8 @@ func (r *RunStepRunner) Run(ctx models.ProjectCommandContext, command string, pa
 		return "", err
 	}
 
-	cmd := exec.Command("sh", "-c", command) // #nosec
-	cmd.Dir = path
-
-	baseEnvVars := os.Environ()
 	customEnvVars := map[string]string{
+		"ATLANTIS_CORRELATION_ID":    ctx.CorrelationID,
 		"ATLANTIS_TERRAFORM_VERSION": tfVersion.String(),
 		"BASE_BRANCH_NAME":           ctx.Pull.BaseBranch,
 		"BASE_REPO_NAME":             ctx.BaseRepo.Name,
@@ -57,14 +65,22 @@ func (r *RunStepRunner) Run(ctx models.ProjectCommandContext, command string, pa
 		"WORKSPACE":                  ctx.Workspace,
 	}
 
-	finalEnvVars := baseEnvVars
-	for key, val := range customEnvVars {
-		finalEnvVars = append(finalEnvVars, fmt.Sprintf("%s=%s", key, val))
-	}
 	for key, val := range envs {
-		finalEnvVars = append(finalEnvVars, fmt.Sprintf("%s=%s", key, val))
+		customEnvVars[key] = val
+	}
+
+	var cmd *limits.Cmd
+	if image := r.containerImage(ctx); image != "" {
+		name := "atlantis-" + uuid.New().String()
+		cmd = r.Limits.WrapContainer(containerCmd(image, name, command, path, customEnvVars, r.Limits), name)
+	} else {
+		cmd, err = r.Limits.Command(command)
+		if err != nil {
+			return "", err
+		}
+		cmd.Dir = path
+		cmd.Env = append(os.Environ(), envList(customEnvVars)...)
 	}
-	cmd.Env = finalEnvVars
 	out, err := cmd.CombinedOutput()
 
 	if err != nil {
@@ -75,3 +91,64 @@ func (r *RunStepRunner) Run(ctx models.ProjectCommandContext, command string, pa
 	ctx.Log.Info("successfully ran %q in %q", command, path)
 	return string(out), nil
 }
+
+// containerImage returns the image of the container to run the commands of
+// ctx in or "" if they're run by Atlantis.
+func (r *RunStepRunner) containerImage(ctx models.ProjectCommandContext) string {
+	if ctx.ContainerImage != "" {
+		return ctx.ContainerImage
+	}
+	return r.ContainerImage
+}
+
+// containerCmd returns the command running command in a new container of
+// image named name. Only path is mounted in the container so the command
+// can't access the rest of the host, ex. the credentials and working dirs of
+// other projects. It runs as the Atlantis user so the files it writes in path
+// can still be read and deleted. The container's memory and CPUs are limited
+// by Docker. Its init process forwards the interrupts of timed out commands
+// to command, which would otherwise ignore them as the container's first
+// process.
+func containerCmd(image string, name string, command string, path string, envs map[string]string, l *limits.Config) *exec.Cmd {
+	args := []string{
+		"run", "--rm", "--init",
+		"--name", name,
+		"--security-opt", "no-new-privileges",
+		"--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()),
+		"--volume", fmt.Sprintf("%s:%s", path, path),
+		"--workdir", path,
+	}
+	if l != nil && l.MemoryBytes > 0 {
+		args = append(args, "--memory", strconv.FormatInt(l.MemoryBytes, 10))
+	}
+	if l != nil && l.CPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(l.CPUs, 'f', -1, 64))
+	}
+	// PATH is the container's own since the host's binaries aren't mounted.
+	delete(envs, "PATH")
+	// Only the names of the variables are passed to docker, which reads
+	// their values from its environment, so they're not in its arguments.
+	var keys []string
+	for key := range envs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, "--env", key)
+	}
+	args = append(args, image, "sh", "-c", command)
+
+	cmd := exec.Command("docker", args...) // #nosec
+	cmd.Dir = path
+	cmd.Env = append(os.Environ(), envList(envs)...)
+	return cmd
+}
+
+// envList returns envs in KEY=value form.
+func envList(envs map[string]string) []string {
+	var list []string
+	for key, val := range envs {
+		list = append(list, fmt.Sprintf("%s=%s", key, val))
+	}
+	return list
+}
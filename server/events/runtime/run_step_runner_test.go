@@ -2,7 +2,9 @@ package runtime_test
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -71,6 +73,10 @@ func TestRunStepRunner_Run(t *testing.T) {
 		{
 			Command: "echo user_name=$USER_NAME",
 			ExpOut:  "user_name=acme-user\n",
+		},
+		{
+			Command: "echo correlation_id=$ATLANTIS_CORRELATION_ID",
+			ExpOut:  "correlation_id=4bf92f3577b34da6a3ce929d0e0e4736\n",
 		}, {
 			Command: "echo $PATH",
 			ExpOut:  fmt.Sprintf("%s:%s\n", os.Getenv("PATH"), "/bin/dir"),
@@ -137,6 +143,7 @@ func TestRunStepRunner_Run(t *testing.T) {
 				TerraformVersion:   projVersion,
 				ProjectName:        c.ProjectName,
 				EscapedCommentArgs: []string{"-target=resource1", "-target=resource2"},
+				CorrelationID:      "4bf92f3577b34da6a3ce929d0e0e4736",
 			}
 			out, err := r.Run(ctx, c.Command, tmpDir, map[string]string{"test": "var"})
 			if c.ExpErr != "" {
@@ -156,3 +163,52 @@ func TestRunStepRunner_Run(t *testing.T) {
 		})
 	}
 }
+
+// Test that commands are run in a container of the workflow's image, or the
+// default image, with only the project dir mounted.
+func TestRunStepRunner_RunInContainer(t *testing.T) {
+	binDir, cleanup := TempDir(t)
+	defer cleanup()
+	// The fake docker prints its arguments and the value of the env var it's
+	// passed.
+	err := ioutil.WriteFile(filepath.Join(binDir, "docker"), []byte("#!/bin/sh\nfor arg in \"$@\"; do echo \"$arg\"; done\necho \"test=$test\"\n"), 0700) // #nosec G306
+	Ok(t, err)
+	origPath := os.Getenv("PATH")
+	Ok(t, os.Setenv("PATH", binDir+":"+origPath))
+	defer os.Setenv("PATH", origPath) // nolint: errcheck
+
+	RegisterMockTestingT(t)
+	terraform := mocks.NewMockClient()
+	defaultVersion, _ := version.NewVersion("0.8")
+	r := runtime.RunStepRunner{
+		TerraformExecutor: terraform,
+		DefaultTFVersion:  defaultVersion,
+		TerraformBinDir:   "/bin/dir",
+		ContainerImage:    "default-image",
+	}
+	tmpDir, cleanup := TempDir(t)
+	defer cleanup()
+
+	for _, image := range []string{"", "workflow-image"} {
+		ctx := models.ProjectCommandContext{
+			Log:            logging.NewNoopLogger(t),
+			Workspace:      "default",
+			ContainerImage: image,
+		}
+		out, err := r.Run(ctx, "echo hi", tmpDir, map[string]string{"test": "var"})
+		Ok(t, err)
+		args := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+
+		expImage := image
+		if expImage == "" {
+			expImage = "default-image"
+		}
+		Equals(t, []string{"run", "--rm", "--init", "--name"}, args[:4])
+		Assert(t, strings.HasPrefix(args[4], "atlantis-"), "expected container name, got %q", args[4])
+		Equals(t, []string{expImage, "sh", "-c", "echo hi", "test=var"}, args[len(args)-5:])
+		Assert(t, strings.Contains(out, fmt.Sprintf("--volume\n%s:%s\n--workdir\n%s\n", tmpDir, tmpDir, tmpDir)), "expected project dir mounted, got %q", out)
+		Assert(t, strings.Contains(out, "--env\ntest\n"), "expected test env var, got %q", out)
+		Assert(t, strings.Contains(out, "--env\nPLANFILE\n"), "expected PLANFILE env var, got %q", out)
+		Assert(t, !strings.Contains(out, "--env\nPATH\n"), "expected no PATH env var, got %q", out)
+	}
+}
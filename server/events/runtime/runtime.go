@@ -47,6 +47,7 @@ type AsyncTFExec interface {
 // without causing circular imports.
 type StatusUpdater interface {
 	UpdateProject(ctx models.ProjectCommandContext, cmdName models.CommandName, status models.CommitStatus, url string) error
+	UpdatePlanProject(ctx models.ProjectCommandContext, status models.CommitStatus, url string, counts *models.PlanCounts) error
 }
 
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_runner.go Runner
@@ -0,0 +1,152 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// TFCRunClient talks to the Terraform Cloud/Enterprise API about the runs
+// of remote operations.
+type TFCRunClient interface {
+	// RunStatus returns the status of the run with ID runID, ex. "planned" or
+	// "applied".
+	RunStatus(runID string) (string, error)
+	// ApplyRun confirms the apply of the run with ID runID, leaving comment
+	// on the run.
+	ApplyRun(runID string, comment string) error
+}
+
+// TFCClient is the TFCRunClient of the Terraform Cloud/Enterprise instance
+// at Hostname.
+type TFCClient struct {
+	Hostname   string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewTFCClient returns a client of the Terraform Cloud/Enterprise instance
+// at hostname that authenticates with token.
+func NewTFCClient(hostname string, token string) *TFCClient {
+	return &TFCClient{
+		Hostname:   hostname,
+		Token:      token,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// RunStatus returns the status of the run with ID runID.
+func (t *TFCClient) RunStatus(runID string) (string, error) {
+	var run struct {
+		Data struct {
+			Attributes struct {
+				Status string `json:"status"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	resp, err := t.do("GET", fmt.Sprintf("/api/v2/runs/%s", runID), nil)
+	if err != nil {
+		return "", err
+	}
+	if err := json.Unmarshal(resp, &run); err != nil {
+		return "", errors.Wrapf(err, "parsing run %s", runID)
+	}
+	return run.Data.Attributes.Status, nil
+}
+
+// ApplyRun confirms the apply of the run with ID runID.
+func (t *TFCClient) ApplyRun(runID string, comment string) error {
+	body, err := json.Marshal(map[string]string{"comment": comment})
+	if err != nil {
+		return err
+	}
+	_, err = t.do("POST", fmt.Sprintf("/api/v2/runs/%s/actions/apply", runID), body)
+	return err
+}
+
+func (t *TFCClient) do(method string, apiPath string, body []byte) ([]byte, error) {
+	url := fmt.Sprintf("https://%s%s", t.Hostname, apiPath)
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+t.Token)
+	req.Header.Set("Content-Type", "application/vnd.api+json")
+	resp, err := t.HTTPClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "making request %q %q", method, url)
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading response from request %q %q", method, url)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("making request %q %q unexpected status code: %d, body: %s", method, url, resp.StatusCode, string(respBody))
+	}
+	return respBody, nil
+}
+
+// TFCRunID returns the ID of the run that runURL links to, ex. "run-abc123"
+// for "https://app.terraform.io/app/org/workspaces/ws/runs/run-abc123", or
+// an empty string if it doesn't link to a run.
+func TFCRunID(runURL string) string {
+	id := path.Base(strings.TrimRight(runURL, "/"))
+	if !strings.HasPrefix(id, "run-") {
+		return ""
+	}
+	return id
+}
+
+// TFCRunCommitStatus translates the status of a Terraform Cloud/Enterprise
+// run of cmdName into a commit status. Runs that haven't finished are
+// pending, and a plan is only finished for an apply if nothing changes.
+func TFCRunCommitStatus(cmdName models.CommandName, runStatus string) models.CommitStatus {
+	switch runStatus {
+	case "planned_and_finished", "applied":
+		return models.SuccessCommitStatus
+	case "planned":
+		if cmdName == models.PlanCommand {
+			return models.SuccessCommitStatus
+		}
+		return models.PendingCommitStatus
+	case "errored", "canceled", "force_canceled", "discarded", "policy_soft_failed":
+		return models.FailedCommitStatus
+	default:
+		return models.PendingCommitStatus
+	}
+}
+
+// tfcRunHeader returns the line linking to the remote run at runURL that we
+// put at the top of the comment output, along with the run's status if known.
+func tfcRunHeader(runURL string, runStatus string) string {
+	if runURL == "" {
+		return ""
+	}
+	if runStatus == "" {
+		return fmt.Sprintf("Terraform Cloud run: %s\n\n", runURL)
+	}
+	return fmt.Sprintf("Terraform Cloud run: %s (%s)\n\n", runURL, runStatus)
+}
+
+// remoteRunStatus returns the status of the remote run at runURL, or an empty
+// string if tfcRuns is nil or the status can't be fetched.
+func remoteRunStatus(ctx models.ProjectCommandContext, tfcRuns TFCRunClient, runURL string) string {
+	runID := TFCRunID(runURL)
+	if tfcRuns == nil || runID == "" {
+		return ""
+	}
+	status, err := tfcRuns.RunStatus(runID)
+	if err != nil {
+		ctx.Log.Warn("unable to get status of remote run %s: %s", runID, err)
+		return ""
+	}
+	return status
+}
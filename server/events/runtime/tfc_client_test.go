@@ -0,0 +1,76 @@
+package runtime_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestTFCClient(t *testing.T) {
+	var appliedBody string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "Bearer token", r.Header.Get("Authorization"))
+		switch r.RequestURI {
+		case "/api/v2/runs/run-abc123":
+			w.Write([]byte(`{"data":{"id":"run-abc123","attributes":{"status":"planned"}}}`)) // nolint: errcheck
+		case "/api/v2/runs/run-abc123/actions/apply":
+			Equals(t, "POST", r.Method)
+			body, err := ioutil.ReadAll(r.Body)
+			Ok(t, err)
+			appliedBody = string(body)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := runtime.NewTFCClient(strings.TrimPrefix(server.URL, "https://"), "token")
+	client.HTTPClient = server.Client()
+
+	status, err := client.RunStatus("run-abc123")
+	Ok(t, err)
+	Equals(t, "planned", status)
+
+	Ok(t, client.ApplyRun("run-abc123", "Applied by Atlantis"))
+	Equals(t, `{"comment":"Applied by Atlantis"}`, appliedBody)
+
+	_, err = client.RunStatus("run-missing")
+	Assert(t, err != nil, "expected an error for a missing run")
+}
+
+func TestTFCRunID(t *testing.T) {
+	Equals(t, "run-abc123", runtime.TFCRunID("https://app.terraform.io/app/org/workspaces/ws/runs/run-abc123"))
+	Equals(t, "run-abc123", runtime.TFCRunID("https://app.terraform.io/app/org/workspaces/ws/runs/run-abc123/"))
+	Equals(t, "", runtime.TFCRunID("https://app.terraform.io/app/org/workspaces/ws"))
+	Equals(t, "", runtime.TFCRunID(""))
+}
+
+func TestTFCRunCommitStatus(t *testing.T) {
+	cases := []struct {
+		cmd    models.CommandName
+		status string
+		exp    models.CommitStatus
+	}{
+		{models.PlanCommand, "planned", models.SuccessCommitStatus},
+		{models.PlanCommand, "planned_and_finished", models.SuccessCommitStatus},
+		{models.PlanCommand, "policy_soft_failed", models.FailedCommitStatus},
+		{models.PlanCommand, "planning", models.PendingCommitStatus},
+		{models.ApplyCommand, "planned", models.PendingCommitStatus},
+		{models.ApplyCommand, "applying", models.PendingCommitStatus},
+		{models.ApplyCommand, "applied", models.SuccessCommitStatus},
+		{models.ApplyCommand, "errored", models.FailedCommitStatus},
+		{models.ApplyCommand, "discarded", models.FailedCommitStatus},
+	}
+	for _, c := range cases {
+		t.Run(c.cmd.String()+" "+c.status, func(t *testing.T) {
+			Equals(t, c.exp, runtime.TFCRunCommitStatus(c.cmd, c.status))
+		})
+	}
+}
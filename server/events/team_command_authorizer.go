@@ -0,0 +1,109 @@
+package events
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+)
+
+// DefaultTeamMembershipCacheTTL is how long team memberships are cached for
+// before being looked up again.
+const DefaultTeamMembershipCacheTTL = 5 * time.Minute
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_command_authorizer.go CommandAuthorizer
+
+// CommandAuthorizer decides whether users are allowed to run comment commands.
+type CommandAuthorizer interface {
+	// IsAuthorized returns true if user is allowed to run cmdName on repo.
+	IsAuthorized(repo models.Repo, user models.User, cmdName models.CommandName) (bool, error)
+}
+
+// TeamCommandAuthorizer authorizes commands using the team_permissions key
+// of the server-side repo config and the user's team memberships from the
+// VCS host. Memberships are cached for CacheTTL.
+type TeamCommandAuthorizer struct {
+	GlobalCfg *valid.GlobalCfgStore
+	VCSClient vcs.Client
+	CacheTTL  time.Duration
+
+	mutex sync.Mutex
+	cache map[string]cachedTeams
+}
+
+type cachedTeams struct {
+	teams   []string
+	expires time.Time
+}
+
+// NewTeamCommandAuthorizer returns a TeamCommandAuthorizer.
+func NewTeamCommandAuthorizer(globalCfg *valid.GlobalCfgStore, vcsClient vcs.Client, cacheTTL time.Duration) *TeamCommandAuthorizer {
+	return &TeamCommandAuthorizer{
+		GlobalCfg: globalCfg,
+		VCSClient: vcsClient,
+		CacheTTL:  cacheTTL,
+		cache:     make(map[string]cachedTeams),
+	}
+}
+
+// IsAuthorized returns true if the repo doesn't restrict commands to teams or
+// if user is in a team that is allowed to run cmdName. Workspace commands
+// are allowed to the teams that can apply since they change the backend and
+// fmt and validate commands to the teams that can plan.
+func (t *TeamCommandAuthorizer) IsAuthorized(repo models.Repo, user models.User, cmdName models.CommandName) (bool, error) {
+	permissions := t.GlobalCfg.Get().TeamPermissions(repo.ID())
+	if permissions == nil {
+		return true, nil
+	}
+	switch cmdName {
+	case models.WorkspaceCommand:
+		cmdName = models.ApplyCommand
+	case models.FmtCommand, models.ValidateCommand:
+		cmdName = models.PlanCommand
+	}
+
+	// Only the memberships of the teams with permissions are looked up.
+	var allowedTeams []string
+	for team := range permissions {
+		allowedTeams = append(allowedTeams, team)
+	}
+	sort.Strings(allowedTeams)
+	teams, err := t.teamsForUser(repo, user, allowedTeams)
+	if err != nil {
+		return false, err
+	}
+	for _, team := range teams {
+		for _, allowed := range permissions[team] {
+			if allowed == cmdName.String() {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (t *TeamCommandAuthorizer) teamsForUser(repo models.Repo, user models.User, allowedTeams []string) ([]string, error) {
+	key := repo.VCSHost.Hostname + "/" + repo.Owner + "/" + user.Username + ":" + strings.Join(allowedTeams, ",")
+
+	t.mutex.Lock()
+	cached, ok := t.cache[key]
+	t.mutex.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.teams, nil
+	}
+
+	teams, err := t.VCSClient.GetTeamNamesForUser(repo, user, allowedTeams)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting teams for %s", user.Username)
+	}
+
+	t.mutex.Lock()
+	t.cache[key] = cachedTeams{teams: teams, expires: time.Now().Add(t.CacheTTL)}
+	t.mutex.Unlock()
+	return teams, nil
+}
@@ -0,0 +1,111 @@
+package events_test
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/models/fixtures"
+	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	vcsmatchers "github.com/runatlantis/atlantis/server/events/vcs/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func teamPermissionsCfg() valid.GlobalCfg {
+	return valid.GlobalCfg{
+		Repos: []valid.Repo{
+			{IDRegex: regexp.MustCompile(".*")},
+			{
+				ID: fixtures.GithubRepo.ID(),
+				TeamPermissions: map[string][]string{
+					"infra-admins": {"plan", "apply", "unlock"},
+					"devs":         {"plan"},
+				},
+			},
+		},
+	}
+}
+
+// allowedTeams are the teams of teamPermissionsCfg whose memberships are
+// looked up.
+var allowedTeams = []string{"devs", "infra-admins"}
+
+func TestTeamCommandAuthorizer_NoTeamPermissions(t *testing.T) {
+	RegisterMockTestingT(t)
+	vcsClient := vcsmocks.NewMockClient()
+	authorizer := events.NewTeamCommandAuthorizer(valid.NewGlobalCfgStore(teamPermissionsCfg()), vcsClient, time.Minute)
+
+	repo := fixtures.GithubRepo
+	repo.FullName = "owner/unrestricted"
+	authorized, err := authorizer.IsAuthorized(repo, fixtures.User, models.ApplyCommand)
+	Ok(t, err)
+	Equals(t, true, authorized)
+	vcsClient.VerifyWasCalled(Never()).GetTeamNamesForUser(vcsmatchers.AnyModelsRepo(), vcsmatchers.AnyModelsUser(), vcsmatchers.AnySliceOfString())
+}
+
+func TestTeamCommandAuthorizer_IsAuthorized(t *testing.T) {
+	cases := []struct {
+		teams     []string
+		cmd       models.CommandName
+		expResult bool
+	}{
+		{[]string{"devs"}, models.PlanCommand, true},
+		{[]string{"devs"}, models.ApplyCommand, false},
+		{[]string{"other", "infra-admins"}, models.ApplyCommand, true},
+		{[]string{"infra-admins"}, models.ApprovePoliciesCommand, false},
+		{[]string{"devs"}, models.WorkspaceCommand, false},
+		{[]string{"infra-admins"}, models.WorkspaceCommand, true},
+		{[]string{"devs"}, models.FmtCommand, true},
+		{[]string{"devs"}, models.ValidateCommand, true},
+		{nil, models.PlanCommand, false},
+	}
+	for _, c := range cases {
+		t.Run(c.cmd.String(), func(t *testing.T) {
+			RegisterMockTestingT(t)
+			vcsClient := vcsmocks.NewMockClient()
+			When(vcsClient.GetTeamNamesForUser(fixtures.GithubRepo, fixtures.User, allowedTeams)).ThenReturn(c.teams, nil)
+			authorizer := events.NewTeamCommandAuthorizer(valid.NewGlobalCfgStore(teamPermissionsCfg()), vcsClient, time.Minute)
+
+			authorized, err := authorizer.IsAuthorized(fixtures.GithubRepo, fixtures.User, c.cmd)
+			Ok(t, err)
+			Equals(t, c.expResult, authorized)
+		})
+	}
+}
+
+func TestTeamCommandAuthorizer_CachesTeams(t *testing.T) {
+	RegisterMockTestingT(t)
+	vcsClient := vcsmocks.NewMockClient()
+	When(vcsClient.GetTeamNamesForUser(fixtures.GithubRepo, fixtures.User, allowedTeams)).ThenReturn([]string{"devs"}, nil)
+
+	authorizer := events.NewTeamCommandAuthorizer(valid.NewGlobalCfgStore(teamPermissionsCfg()), vcsClient, time.Minute)
+	for i := 0; i < 2; i++ {
+		_, err := authorizer.IsAuthorized(fixtures.GithubRepo, fixtures.User, models.PlanCommand)
+		Ok(t, err)
+	}
+	vcsClient.VerifyWasCalledOnce().GetTeamNamesForUser(fixtures.GithubRepo, fixtures.User, allowedTeams)
+
+	// With no TTL, teams are looked up every time.
+	authorizer = events.NewTeamCommandAuthorizer(valid.NewGlobalCfgStore(teamPermissionsCfg()), vcsClient, 0)
+	for i := 0; i < 2; i++ {
+		_, err := authorizer.IsAuthorized(fixtures.GithubRepo, fixtures.User, models.PlanCommand)
+		Ok(t, err)
+	}
+	vcsClient.VerifyWasCalled(Times(3)).GetTeamNamesForUser(fixtures.GithubRepo, fixtures.User, allowedTeams)
+}
+
+func TestTeamCommandAuthorizer_LookupErr(t *testing.T) {
+	RegisterMockTestingT(t)
+	vcsClient := vcsmocks.NewMockClient()
+	When(vcsClient.GetTeamNamesForUser(fixtures.GithubRepo, fixtures.User, allowedTeams)).ThenReturn(nil, errors.New("err"))
+	authorizer := events.NewTeamCommandAuthorizer(valid.NewGlobalCfgStore(teamPermissionsCfg()), vcsClient, time.Minute)
+
+	authorized, err := authorizer.IsAuthorized(fixtures.GithubRepo, fixtures.User, models.PlanCommand)
+	ErrEquals(t, "getting teams for "+fixtures.User.Username+": err", err)
+	Equals(t, false, authorized)
+}
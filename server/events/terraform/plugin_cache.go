@@ -0,0 +1,62 @@
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// pluginCacheLockFile is the name of the file inside the plugin cache dir
+// that's locked while providers are installed into it.
+const pluginCacheLockFile = ".atlantis.lock"
+
+// pluginCacheLock serializes the installation of providers into the plugin
+// cache by the commands of this server and of the other servers sharing the
+// cache dir. Terraform doesn't lock the cache itself so concurrent inits
+// installing the same provider corrupt it.
+type pluginCacheLock struct {
+	path string
+	// mutex serializes the commands of this server so only one of them at a
+	// time waits for the file lock.
+	mutex sync.Mutex
+}
+
+func newPluginCacheLock(cacheDir string) *pluginCacheLock {
+	return &pluginCacheLock{path: filepath.Join(cacheDir, pluginCacheLockFile)}
+}
+
+// lock blocks until the cache is locked and returns the function that
+// unlocks it.
+func (l *pluginCacheLock) lock(log logging.SimpleLogging) (func(), error) {
+	l.mutex.Lock()
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		l.mutex.Unlock()
+		return nil, errors.Wrap(err, "opening plugin cache lock file")
+	}
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		log.Info("waiting for another Atlantis server to finish installing providers into the plugin cache")
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+	}
+	if err != nil {
+		f.Close() // nolint: errcheck
+		l.mutex.Unlock()
+		return nil, errors.Wrap(err, "locking plugin cache")
+	}
+	return func() {
+		// Closing the file releases the lock.
+		f.Close() // nolint: errcheck
+		l.mutex.Unlock()
+	}, nil
+}
+
+// installsProviders returns true if running terraform with args may install
+// providers into the plugin cache.
+func installsProviders(args []string) bool {
+	return len(args) > 0 && args[0] == "init"
+}
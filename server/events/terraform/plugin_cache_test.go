@@ -0,0 +1,69 @@
+package terraform
+
+import (
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// Test that servers sharing a plugin cache dir install providers into it one
+// at a time.
+func TestPluginCacheLock_SharedDir(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	log := logging.NewNoopLogger(t)
+	// Each server has its own lock.
+	server1 := newPluginCacheLock(tmp)
+	server2 := newPluginCacheLock(tmp)
+
+	unlock1, err := server1.lock(log)
+	Ok(t, err)
+	locked2 := make(chan func())
+	go func() {
+		unlock2, err := server2.lock(log)
+		Ok(t, err)
+		locked2 <- unlock2
+	}()
+	select {
+	case <-locked2:
+		t.Fatal("cache was locked by both servers")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlock1()
+	select {
+	case unlock2 := <-locked2:
+		unlock2()
+	case <-time.After(5 * time.Second):
+		t.Fatal("cache wasn't locked after it was unlocked")
+	}
+}
+
+func TestDefaultClient_LockPluginCache(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	log := logging.NewNoopLogger(t)
+	client := &DefaultClient{
+		usePluginCache:  true,
+		pluginCacheLock: newPluginCacheLock(tmp),
+	}
+
+	unlock, err := client.lockPluginCache(log, []string{"init", "-input=false"})
+	Ok(t, err)
+	// Commands that don't install providers don't wait for the lock.
+	done := make(chan struct{})
+	go func() {
+		unlockPlan, err := client.lockPluginCache(log, []string{"plan", "-input=false"})
+		Ok(t, err)
+		unlockPlan()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("plan waited for the plugin cache lock")
+	}
+	unlock()
+}
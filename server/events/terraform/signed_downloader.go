@@ -0,0 +1,124 @@
+package terraform
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/go-getter"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/openpgp"
+)
+
+// SignedDownloader downloads terraform versions with Downloader after
+// checking that the SHA256SUMS file they're verified with was signed by a
+// trusted key. The detached signature of the file must be next to it, with
+// the .sig suffix, like on releases.hashicorp.com.
+type SignedDownloader struct {
+	Downloader Downloader
+	// Keyring has the keys that are trusted to sign the SHA256SUMS files.
+	Keyring openpgp.EntityList
+}
+
+// NewSignedDownloader returns a SignedDownloader trusting the ASCII armored
+// public keys in keyFile.
+func NewSignedDownloader(d Downloader, keyFile string) (*SignedDownloader, error) {
+	f, err := os.Open(keyFile) // nolint: gosec
+	if err != nil {
+		return nil, errors.Wrap(err, "opening GPG key file")
+	}
+	defer f.Close() // nolint: errcheck
+	keyring, err := openpgp.ReadArmoredKeyRing(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading GPG keys from %s", keyFile)
+	}
+	return &SignedDownloader{Downloader: d, Keyring: keyring}, nil
+}
+
+// GetFile downloads src to dst. src must have a checksum=file:<url> query
+// parameter, see go-getter.GetFile. The checksum file is downloaded and its
+// signature checked first, then src is verified with the checksum from it.
+func (d *SignedDownloader) GetFile(dst, src string, opts ...getter.ClientOption) error {
+	u, err := url.Parse(src)
+	if err != nil {
+		return err
+	}
+	query := u.Query()
+	checksum := query.Get("checksum")
+	if !strings.HasPrefix(checksum, "file:") {
+		return fmt.Errorf("%s has no checksum file to verify its signature", src)
+	}
+	sumsURL := strings.TrimPrefix(checksum, "file:")
+
+	tmp, err := ioutil.TempDir("", "atlantis-sums")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	sumsFile := filepath.Join(tmp, "SHA256SUMS")
+	sigFile := sumsFile + ".sig"
+	if err := d.Downloader.GetFile(sumsFile, sumsURL, opts...); err != nil {
+		return errors.Wrapf(err, "downloading %s", sumsURL)
+	}
+	if err := d.Downloader.GetFile(sigFile, sumsURL+".sig", opts...); err != nil {
+		return errors.Wrapf(err, "downloading signature of %s", sumsURL)
+	}
+	if err := d.checkSignature(sumsFile, sigFile); err != nil {
+		return errors.Wrapf(err, "checking signature of %s", sumsURL)
+	}
+	sum, err := findChecksum(sumsFile, path.Base(u.Path))
+	if err != nil {
+		return errors.Wrapf(err, "reading %s", sumsURL)
+	}
+
+	query.Set("checksum", "sha256:"+sum)
+	u.RawQuery = query.Encode()
+	return d.Downloader.GetFile(dst, u.String(), opts...)
+}
+
+// GetAny downloads src to dst without checking signatures, see
+// go-getter.GetAny.
+func (d *SignedDownloader) GetAny(dst, src string, opts ...getter.ClientOption) error {
+	return d.Downloader.GetAny(dst, src, opts...)
+}
+
+func (d *SignedDownloader) checkSignature(file string, sigFile string) error {
+	f, err := os.Open(file) // nolint: gosec
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint: errcheck
+	sig, err := os.Open(sigFile) // nolint: gosec
+	if err != nil {
+		return err
+	}
+	defer sig.Close() // nolint: errcheck
+	_, err = openpgp.CheckDetachedSignature(d.Keyring, f, sig)
+	return err
+}
+
+// findChecksum returns the checksum of filename in the SHA256SUMS file
+// sumsFile, whose lines are "<checksum>  <filename>".
+func findChecksum(sumsFile string, filename string) (string, error) {
+	f, err := os.Open(sumsFile) // nolint: gosec
+	if err != nil {
+		return "", err
+	}
+	defer f.Close() // nolint: errcheck
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == filename {
+			return fields[0], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no checksum for %s", filename)
+}
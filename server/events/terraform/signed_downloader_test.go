@@ -0,0 +1,111 @@
+package terraform_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-getter"
+	"github.com/runatlantis/atlantis/server/events/terraform"
+	. "github.com/runatlantis/atlantis/testing"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+func TestSignedDownloader_GetFile(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	signer, err := openpgp.NewEntity("releases", "", "releases@example.com", nil)
+	Ok(t, err)
+	other, err := openpgp.NewEntity("other", "", "other@example.com", nil)
+	Ok(t, err)
+	keyFile := filepath.Join(tmp, "key.asc")
+	Ok(t, ioutil.WriteFile(keyFile, armoredPublicKey(t, signer), 0600))
+
+	baseURL := "https://mirror.example.com/terraform/0.15.0"
+	sums := "abc123  terraform_0.15.0_linux_amd64.zip\ndef456  terraform_0.15.0_darwin_amd64.zip\n"
+	src := fmt.Sprintf("%s/terraform_0.15.0_linux_amd64.zip?checksum=file:%s/terraform_0.15.0_SHA256SUMS", baseURL, baseURL)
+
+	cases := []struct {
+		description string
+		src         string
+		signedBy    *openpgp.Entity
+		expSrc      string
+		expErr      string
+	}{
+		{
+			description: "signed by trusted key",
+			src:         src,
+			signedBy:    signer,
+			expSrc:      baseURL + "/terraform_0.15.0_linux_amd64.zip?checksum=sha256%3Aabc123",
+		},
+		{
+			description: "signed by other key",
+			src:         src,
+			signedBy:    other,
+			expErr:      "checking signature of " + baseURL + "/terraform_0.15.0_SHA256SUMS: openpgp: signature made by unknown entity",
+		},
+		{
+			description: "no checksum file",
+			src:         baseURL + "/terraform_0.15.0_linux_amd64.zip",
+			signedBy:    signer,
+			expErr:      baseURL + "/terraform_0.15.0_linux_amd64.zip has no checksum file to verify its signature",
+		},
+		{
+			description: "no checksum for file",
+			src:         strings.Replace(src, "linux_amd64", "linux_arm64", 1),
+			signedBy:    signer,
+			expErr:      "reading " + baseURL + "/terraform_0.15.0_SHA256SUMS: no checksum for terraform_0.15.0_linux_arm64.zip",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			var sig bytes.Buffer
+			Ok(t, openpgp.DetachSign(&sig, c.signedBy, strings.NewReader(sums), nil))
+			fake := &fakeDownloader{files: map[string]string{
+				baseURL + "/terraform_0.15.0_SHA256SUMS":     sums,
+				baseURL + "/terraform_0.15.0_SHA256SUMS.sig": sig.String(),
+			}}
+			d, err := terraform.NewSignedDownloader(fake, keyFile)
+			Ok(t, err)
+
+			err = d.GetFile(filepath.Join(tmp, "terraform"), c.src)
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+			Equals(t, c.expSrc, fake.srcs[len(fake.srcs)-1])
+		})
+	}
+}
+
+func armoredPublicKey(t *testing.T, e *openpgp.Entity) []byte {
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	Ok(t, err)
+	Ok(t, e.Serialize(w))
+	Ok(t, w.Close())
+	return buf.Bytes()
+}
+
+// fakeDownloader downloads files from memory.
+type fakeDownloader struct {
+	files map[string]string
+	srcs  []string
+}
+
+func (f *fakeDownloader) GetFile(dst, src string, opts ...getter.ClientOption) error {
+	f.srcs = append(f.srcs, src)
+	if contents, ok := f.files[src]; ok {
+		return ioutil.WriteFile(dst, []byte(contents), 0600)
+	}
+	return nil
+}
+
+func (f *fakeDownloader) GetAny(dst, src string, opts ...getter.ClientOption) error {
+	return nil
+}
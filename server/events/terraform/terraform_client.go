@@ -16,6 +16,7 @@ package terraform
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -31,6 +32,7 @@ import (
 	"github.com/hashicorp/go-version"
 	"github.com/mitchellh/go-homedir"
 	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/limits"
 	"github.com/runatlantis/atlantis/server/logging"
 )
 
@@ -70,6 +72,37 @@ type DefaultClient struct {
 
 	// usePluginCache determines whether or not to set the TF_PLUGIN_CACHE_DIR env var
 	usePluginCache bool
+	// pluginCacheLock is locked while terraform installs providers into the
+	// plugin cache. Can be nil.
+	pluginCacheLock *pluginCacheLock
+
+	// outputWriters streams the output of commands while they run. Can be nil.
+	outputWriters OutputWriters
+
+	// jobRunner runs plans and applies outside of Atlantis. If nil, they're
+	// run by Atlantis like the other commands.
+	jobRunner JobRunner
+
+	// commandLimits limits the time, output and resources of the commands
+	// run by Atlantis. If nil, they aren't limited.
+	commandLimits *limits.Config
+}
+
+// JobRunner runs terraform commands outside of the Atlantis process, ex. as
+// Kubernetes Jobs.
+type JobRunner interface {
+	// RunJob runs the shell command cmd in dir with the environment variables
+	// envs, in KEY=value form, writing its output to out. It returns an
+	// error if the command fails.
+	RunJob(log logging.SimpleLogging, dir string, cmd string, envs []string, out io.Writer) error
+}
+
+// OutputWriters returns where to stream the output of commands run in a
+// directory so it can be watched live.
+type OutputWriters interface {
+	// Writer returns the writer for commands run in dir or nil if their
+	// output shouldn't be streamed.
+	Writer(dir string) io.Writer
 }
 
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_downloader.go Downloader
@@ -101,6 +134,9 @@ func NewClientWithDefaultVersion(
 	tfDownloader Downloader,
 	usePluginCache bool,
 	fetchAsync bool,
+	outputWriters OutputWriters,
+	jobRunner JobRunner,
+	commandLimits *limits.Config,
 ) (*DefaultClient, error) {
 	var finalDefaultVersion *version.Version
 	var localVersion *version.Version
@@ -159,6 +195,11 @@ func NewClientWithDefaultVersion(
 		}
 	}
 
+	var cacheLock *pluginCacheLock
+	if usePluginCache {
+		cacheLock = newPluginCacheLock(cacheDir)
+	}
+
 	return &DefaultClient{
 		defaultVersion:          finalDefaultVersion,
 		terraformPluginCacheDir: cacheDir,
@@ -168,6 +209,10 @@ func NewClientWithDefaultVersion(
 		versionsLock:            &versionsLock,
 		versions:                versions,
 		usePluginCache:          usePluginCache,
+		pluginCacheLock:         cacheLock,
+		outputWriters:           outputWriters,
+		jobRunner:               jobRunner,
+		commandLimits:           commandLimits,
 	}, nil
 
 }
@@ -182,7 +227,10 @@ func NewTestClient(
 	defaultVersionFlagName string,
 	tfDownloadURL string,
 	tfDownloader Downloader,
-	usePluginCache bool) (*DefaultClient, error) {
+	usePluginCache bool,
+	outputWriters OutputWriters,
+	jobRunner JobRunner,
+	commandLimits *limits.Config) (*DefaultClient, error) {
 	return NewClientWithDefaultVersion(
 		log,
 		binDir,
@@ -195,6 +243,9 @@ func NewTestClient(
 		tfDownloader,
 		usePluginCache,
 		false,
+		outputWriters,
+		jobRunner,
+		commandLimits,
 	)
 }
 
@@ -205,6 +256,9 @@ func NewTestClient(
 // defaultVersionFlagName is the name of the flag that sets the default terraform
 // version.
 // tfDownloader is used to download terraform versions.
+// outputWriters is optional and streams the output of commands while they run.
+// jobRunner is optional and runs plans and applies instead of Atlantis.
+// commandLimits is optional and limits the commands run by Atlantis.
 // Will asynchronously download the required version if it doesn't exist already.
 func NewClient(
 	log logging.SimpleLogging,
@@ -216,7 +270,10 @@ func NewClient(
 	defaultVersionFlagName string,
 	tfDownloadURL string,
 	tfDownloader Downloader,
-	usePluginCache bool) (*DefaultClient, error) {
+	usePluginCache bool,
+	outputWriters OutputWriters,
+	jobRunner JobRunner,
+	commandLimits *limits.Config) (*DefaultClient, error) {
 	return NewClientWithDefaultVersion(
 		log,
 		binDir,
@@ -229,6 +286,9 @@ func NewClient(
 		tfDownloader,
 		usePluginCache,
 		true,
+		outputWriters,
+		jobRunner,
+		commandLimits,
 	)
 }
 
@@ -238,6 +298,24 @@ func (c *DefaultClient) DefaultVersion() *version.Version {
 	return c.defaultVersion
 }
 
+// CheckDefaultVersion returns an error if the default version of terraform
+// isn't on disk, ex. because it's still downloading or the download failed.
+func (c *DefaultClient) CheckDefaultVersion() error {
+	if c.defaultVersion == nil {
+		return errors.New("no default terraform version")
+	}
+	c.versionsLock.Lock()
+	path, ok := c.versions[c.defaultVersion.String()]
+	c.versionsLock.Unlock()
+	if !ok {
+		return fmt.Errorf("terraform %s hasn't been downloaded", c.defaultVersion)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return errors.Wrapf(err, "checking terraform %s", c.defaultVersion)
+	}
+	return nil
+}
+
 // TerraformBinDir returns the directory where we download Terraform binaries.
 func (c *DefaultClient) TerraformBinDir() string {
 	return c.binDir
@@ -260,6 +338,40 @@ func (c *DefaultClient) EnsureVersion(log logging.SimpleLogging, v *version.Vers
 	return nil
 }
 
+// PrewarmVersions downloads the versions that aren't on disk yet and checks
+// that each one runs, so the first commands using them don't have to wait
+// for them to download or fail if the download does. Versions in the bin dir
+// that don't run are deleted so they're downloaded again. Errors are logged
+// since commands download missing versions anyway.
+func (c *DefaultClient) PrewarmVersions(log logging.SimpleLogging, versions []*version.Version) {
+	for _, v := range versions {
+		c.versionsLock.Lock()
+		binPath, err := ensureVersion(log, c.downloader, c.versions, v, c.binDir, c.downloadBaseURL)
+		c.versionsLock.Unlock()
+		if err != nil {
+			log.Err("could not download terraform %s: %s", v, err)
+			continue
+		}
+		actual, err := getVersion(binPath)
+		if err == nil && !actual.Equal(v) {
+			err = fmt.Errorf("it's version %s", actual)
+		}
+		if err == nil {
+			log.Debug("terraform %s is ready at %s", v, binPath)
+			continue
+		}
+		log.Err("terraform %s at %s is broken: %s", v, binPath, err)
+		if filepath.Dir(binPath) == c.binDir {
+			c.versionsLock.Lock()
+			delete(c.versions, v.String())
+			c.versionsLock.Unlock()
+			if err := os.Remove(binPath); err != nil {
+				log.Err("could not delete %s: %s", binPath, err)
+			}
+		}
+	}
+}
+
 // See Client.RunCommandWithVersion.
 func (c *DefaultClient) RunCommandWithVersion(log logging.SimpleLogging, path string, args []string, customEnvVars map[string]string, v *version.Version, workspace string) (string, error) {
 	tfCmd, cmd, err := c.prepCmd(log, v, workspace, path, args)
@@ -271,20 +383,80 @@ func (c *DefaultClient) RunCommandWithVersion(log logging.SimpleLogging, path st
 		envVars = append(envVars, fmt.Sprintf("%s=%s", key, val))
 	}
 	cmd.Env = envVars
-	out, err := cmd.CombinedOutput()
+	var out bytes.Buffer
+	w := c.commandLimits.LimitOutput(&out)
+	if stream := c.outputWriter(path); stream != nil {
+		w = io.MultiWriter(w, stream)
+	}
+	cmd.Stdout = w
+	cmd.Stderr = w
+	unlock, err := c.lockPluginCache(log, args)
 	if err != nil {
+		return "", err
+	}
+	if c.runsInJob(args) {
+		err = c.jobRunner.RunJob(log, path, tfCmd, c.jobEnv(v, workspace, path, customEnvVars), w)
+	} else {
+		err = cmd.Run()
+	}
+	unlock()
+	if err != nil {
+		logHung(log, tfCmd, err)
 		err = errors.Wrapf(err, "running %q in %q", tfCmd, path)
 		log.Err(err.Error())
-		return string(out), err
+		return out.String(), err
 	}
 	log.Info("successfully ran %q in %q", tfCmd, path)
-	return string(out), nil
+	return out.String(), nil
+}
+
+// logHung logs the goroutines of Atlantis if err is from a command that was
+// killed for being hung, so that it can be diagnosed.
+func logHung(log logging.SimpleLogging, tfCmd string, err error) {
+	if hung, ok := err.(*limits.HungError); ok {
+		log.Err("%q was hung, the goroutines of Atlantis were:\n%s", tfCmd, hung.Goroutines)
+	}
+}
+
+// lockPluginCache locks the plugin cache if running terraform with args may
+// install providers into it. It returns the function that unlocks it.
+func (c *DefaultClient) lockPluginCache(log logging.SimpleLogging, args []string) (func(), error) {
+	if !c.usePluginCache || c.pluginCacheLock == nil || !installsProviders(args) {
+		return func() {}, nil
+	}
+	return c.pluginCacheLock.lock(log)
+}
+
+// runsInJob returns true if running terraform with args runs in a job. Only
+// plans and applies do since the other commands are quick.
+func (c *DefaultClient) runsInJob(args []string) bool {
+	return c.jobRunner != nil && len(args) > 0 && (args[0] == "plan" || args[0] == "apply")
+}
+
+// jobEnv returns the environment variables of commands run in jobs. Unlike
+// commands run by Atlantis, they don't get the environment of the Atlantis
+// process since they shouldn't need its credentials.
+func (c *DefaultClient) jobEnv(v *version.Version, workspace string, path string, customEnvVars map[string]string) []string {
+	envVars := c.commandEnv(v, workspace, path)
+	for key, val := range customEnvVars {
+		envVars = append(envVars, fmt.Sprintf("%s=%s", key, val))
+	}
+	return envVars
+}
+
+// outputWriter returns the writer to stream the output of commands run in
+// path to or nil.
+func (c *DefaultClient) outputWriter(path string) io.Writer {
+	if c.outputWriters == nil {
+		return nil
+	}
+	return c.outputWriters.Writer(path)
 }
 
 // prepCmd builds a ready to execute command based on the version of terraform
 // v, and args. It returns a printable representation of the command that will
 // be run and the actual command.
-func (c *DefaultClient) prepCmd(log logging.SimpleLogging, v *version.Version, workspace string, path string, args []string) (string, *exec.Cmd, error) {
+func (c *DefaultClient) prepCmd(log logging.SimpleLogging, v *version.Version, workspace string, path string, args []string) (string, *limits.Cmd, error) {
 	if v == nil {
 		v = c.defaultVersion
 	}
@@ -303,6 +475,25 @@ func (c *DefaultClient) prepCmd(log logging.SimpleLogging, v *version.Version, w
 		}
 	}
 
+	// Append current Atlantis process's environment variables, ex.
+	// AWS_ACCESS_KEY.
+	envVars := append(c.commandEnv(v, workspace, path), os.Environ()...)
+	tfCmd := fmt.Sprintf("%s %s", binPath, strings.Join(args, " "))
+	cmd, err := c.commandLimits.Command(tfCmd)
+	if err != nil {
+		return "", nil, err
+	}
+	cmd.Dir = path
+	cmd.Env = envVars
+	return tfCmd, cmd, nil
+}
+
+// commandEnv returns the environment variables Atlantis sets for running
+// terraform version v in path.
+func (c *DefaultClient) commandEnv(v *version.Version, workspace string, path string) []string {
+	if v == nil {
+		v = c.defaultVersion
+	}
 	// We add custom variables so that if `extra_args` is specified with env
 	// vars then they'll be substituted.
 	envVars := []string{
@@ -317,14 +508,7 @@ func (c *DefaultClient) prepCmd(log logging.SimpleLogging, v *version.Version, w
 	if c.usePluginCache {
 		envVars = append(envVars, fmt.Sprintf("TF_PLUGIN_CACHE_DIR=%s", c.terraformPluginCacheDir))
 	}
-	// Append current Atlantis process's environment variables, ex.
-	// AWS_ACCESS_KEY.
-	envVars = append(envVars, os.Environ()...)
-	tfCmd := fmt.Sprintf("%s %s", binPath, strings.Join(args, " "))
-	cmd := exec.Command("sh", "-c", tfCmd)
-	cmd.Dir = path
-	cmd.Env = envVars
-	return tfCmd, cmd, nil
+	return envVars
 }
 
 // Line represents a line that was output from a terraform command.
@@ -370,6 +554,19 @@ func (c *DefaultClient) RunCommandAsync(log logging.SimpleLogging, path string,
 		}
 		cmd.Env = envVars
 
+		unlock, err := c.lockPluginCache(log, args)
+		if err != nil {
+			log.Err(err.Error())
+			outCh <- Line{Err: err}
+			return
+		}
+		defer unlock()
+
+		if c.runsInJob(args) {
+			c.runJobAsync(log, path, tfCmd, c.jobEnv(v, workspace, path, customEnvVars), inCh, outCh)
+			return
+		}
+
 		log.Debug("starting %q in %q", tfCmd, path)
 		err = cmd.Start()
 		if err != nil {
@@ -391,6 +588,20 @@ func (c *DefaultClient) RunCommandAsync(log logging.SimpleLogging, path string,
 			}
 		}()
 
+		stream := c.outputWriter(path)
+		// streamMu guards stream and out since stdout and stderr are copied
+		// concurrently.
+		var streamMu sync.Mutex
+		out := c.commandLimits.LimitOutput(lineWriter(func(line string) { outCh <- Line{Line: line} }))
+		send := func(line string) {
+			streamMu.Lock()
+			defer streamMu.Unlock()
+			if stream != nil {
+				stream.Write([]byte(line + "\n")) // nolint: errcheck
+			}
+			out.Write([]byte(line + "\n")) // nolint: errcheck
+		}
+
 		// Use a waitgroup to block until our stdout/err copying is complete.
 		wg := new(sync.WaitGroup)
 		wg.Add(2)
@@ -399,14 +610,14 @@ func (c *DefaultClient) RunCommandAsync(log logging.SimpleLogging, path string,
 		go func() {
 			s := bufio.NewScanner(stdout)
 			for s.Scan() {
-				outCh <- Line{Line: s.Text()}
+				send(s.Text())
 			}
 			wg.Done()
 		}()
 		go func() {
 			s := bufio.NewScanner(stderr)
 			for s.Scan() {
-				outCh <- Line{Line: s.Text()}
+				send(s.Text())
 			}
 			wg.Done()
 		}()
@@ -420,6 +631,7 @@ func (c *DefaultClient) RunCommandAsync(log logging.SimpleLogging, path string,
 
 		// We're done now. Send an error if there was one.
 		if err != nil {
+			logHung(log, tfCmd, err)
 			err = errors.Wrapf(err, "running %q in %q", tfCmd, path)
 			log.Err(err.Error())
 			outCh <- Line{Err: err}
@@ -431,6 +643,57 @@ func (c *DefaultClient) RunCommandAsync(log logging.SimpleLogging, path string,
 	return inCh, outCh
 }
 
+// lineWriter calls itself with each line written to it, without the
+// newline. Lines must be written whole.
+type lineWriter func(line string)
+
+func (l lineWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.SplitAfter(string(p), "\n") {
+		if line != "" {
+			l(strings.TrimSuffix(line, "\n"))
+		}
+	}
+	return len(p), nil
+}
+
+// runJobAsync runs tfCmd in path with the job runner, sending its output on
+// outCh like RunCommandAsync.
+func (c *DefaultClient) runJobAsync(log logging.SimpleLogging, path string, tfCmd string, envs []string, inCh <-chan string, outCh chan<- Line) {
+	// Jobs don't have a stdin so input is discarded.
+	go func() {
+		for line := range inCh {
+			log.Debug("discarding %q since jobs have no stdin", line)
+		}
+	}()
+
+	r, w := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := c.jobRunner.RunJob(log, path, tfCmd, envs, w)
+		w.Close() // nolint: errcheck
+		done <- err
+	}()
+	stream := c.outputWriter(path)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		if stream != nil {
+			stream.Write([]byte(s.Text() + "\n")) // nolint: errcheck
+		}
+		outCh <- Line{Line: s.Text()}
+	}
+	// Discard what couldn't be scanned, ex. lines that are too long, so the
+	// job runner doesn't block.
+	io.Copy(ioutil.Discard, r) // nolint: errcheck
+
+	if err := <-done; err != nil {
+		err = errors.Wrapf(err, "running %q in %q", tfCmd, path)
+		log.Err(err.Error())
+		outCh <- Line{Err: err}
+		return
+	}
+	log.Info("successfully ran %q in %q", tfCmd, path)
+}
+
 // MustConstraint will parse one or more constraints from the given
 // constraint string. The string must be a comma-separated list of
 // constraints. It panics if there is an error.
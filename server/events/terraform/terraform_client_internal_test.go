@@ -1,14 +1,19 @@
 package terraform
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	version "github.com/hashicorp/go-version"
+	"github.com/runatlantis/atlantis/server/events/limits"
 	"github.com/runatlantis/atlantis/server/logging"
 	. "github.com/runatlantis/atlantis/testing"
 )
@@ -193,6 +198,34 @@ func TestDefaultClient_RunCommandAsync_BigOutput(t *testing.T) {
 	Equals(t, strings.TrimRight(exp, "\n"), out)
 }
 
+// Test that the output of commands is truncated and that they're killed
+// once they time out.
+func TestDefaultClient_CommandLimits(t *testing.T) {
+	v, err := version.NewVersion("0.11.11")
+	Ok(t, err)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	client := &DefaultClient{
+		defaultVersion:          v,
+		terraformPluginCacheDir: tmp,
+		overrideTF:              "echo",
+		commandLimits:           &limits.Config{MaxOutputBytes: 6, Timeout: time.Second},
+	}
+	log := logging.NewNoopLogger(t)
+
+	_, outCh := client.RunCommandAsync(log, tmp, []string{"line1", "&&", "echo", "line2"}, map[string]string{}, nil, "workspace")
+	out, err := waitCh(outCh)
+	Ok(t, err)
+	Equals(t, "line1\n\n[output truncated: it was longer than 6 bytes]", out)
+
+	out, err = client.RunCommandWithVersion(log, tmp, []string{"line1", "&&", "echo", "line2"}, map[string]string{}, nil, "workspace")
+	Ok(t, err)
+	Equals(t, "line1\n\n[output truncated: it was longer than 6 bytes]\n", out)
+
+	_, err = client.RunCommandWithVersion(log, tmp, []string{"&&", "sleep", "30"}, map[string]string{}, nil, "workspace")
+	ErrEquals(t, `running "echo && sleep 30" in "`+tmp+`": timed out after 1s and was killed`, err)
+}
+
 func TestDefaultClient_RunCommandAsync_StderrOutput(t *testing.T) {
 	v, err := version.NewVersion("0.11.11")
 	Ok(t, err)
@@ -249,6 +282,47 @@ func TestDefaultClient_RunCommandAsync_Input(t *testing.T) {
 	Equals(t, "echo me", out)
 }
 
+type fakeOutputWriters map[string]*bytes.Buffer
+
+func (f fakeOutputWriters) Writer(dir string) io.Writer {
+	if buf, ok := f[dir]; ok {
+		return buf
+	}
+	return nil
+}
+
+// Test that output is streamed for dirs that have a writer.
+func TestDefaultClient_StreamsOutput(t *testing.T) {
+	v, err := version.NewVersion("0.11.11")
+	Ok(t, err)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	streamed := &bytes.Buffer{}
+	client := &DefaultClient{
+		defaultVersion:          v,
+		terraformPluginCacheDir: tmp,
+		overrideTF:              "echo",
+		outputWriters:           fakeOutputWriters{tmp: streamed},
+	}
+	log := logging.NewNoopLogger(t)
+
+	out, err := client.RunCommandWithVersion(log, tmp, []string{"sync"}, map[string]string{}, nil, "workspace")
+	Ok(t, err)
+	Equals(t, "sync\n", out)
+	_, outCh := client.RunCommandAsync(log, tmp, []string{"async"}, map[string]string{}, nil, "workspace")
+	out, err = waitCh(outCh)
+	Ok(t, err)
+	Equals(t, "async", out)
+	Equals(t, "sync\nasync\n", streamed.String())
+
+	// Dirs without a writer aren't streamed.
+	other, cleanupOther := TempDir(t)
+	defer cleanupOther()
+	_, err = client.RunCommandWithVersion(log, other, []string{"other"}, map[string]string{}, nil, "workspace")
+	Ok(t, err)
+	Equals(t, "sync\nasync\n", streamed.String())
+}
+
 func waitCh(ch <-chan Line) (string, error) {
 	var ls []string
 	for line := range ch {
@@ -259,3 +333,84 @@ func waitCh(ch <-chan Line) (string, error) {
 	}
 	return strings.Join(ls, "\n"), nil
 }
+
+// Test that plans and applies are run by the job runner, without the
+// environment of the Atlantis process, while the other commands aren't.
+func TestDefaultClient_RunCommandWithVersion_Job(t *testing.T) {
+	v, err := version.NewVersion("0.11.11")
+	Ok(t, err)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	jobs := &fakeJobRunner{output: "Plan: 1 to add\n"}
+	client := &DefaultClient{
+		defaultVersion:          v,
+		terraformPluginCacheDir: tmp,
+		overrideTF:              "echo",
+		jobRunner:               jobs,
+	}
+	log := logging.NewNoopLogger(t)
+
+	out, err := client.RunCommandWithVersion(log, tmp, []string{"init"}, map[string]string{}, nil, "workspace")
+	Ok(t, err)
+	Equals(t, "init\n", out)
+	Equals(t, 0, len(jobs.cmds))
+
+	out, err = client.RunCommandWithVersion(log, tmp, []string{"plan", "-input=false"}, map[string]string{"CUSTOM": "value"}, nil, "workspace")
+	Ok(t, err)
+	Equals(t, "Plan: 1 to add\n", out)
+	Equals(t, []string{"echo plan -input=false"}, jobs.cmds)
+	Equals(t, tmp, jobs.dir)
+	Assert(t, containsEnv(jobs.envs, "TF_WORKSPACE=workspace"), "expected TF_WORKSPACE in %v", jobs.envs)
+	Assert(t, containsEnv(jobs.envs, "CUSTOM=value"), "expected CUSTOM in %v", jobs.envs)
+	Assert(t, !containsEnv(jobs.envs, "PATH="+os.Getenv("PATH")), "expected no PATH in %v", jobs.envs)
+
+	jobs.err = errors.New("exit code 1")
+	_, err = client.RunCommandWithVersion(log, tmp, []string{"apply"}, map[string]string{}, nil, "workspace")
+	ErrEquals(t, fmt.Sprintf(`running "echo apply" in %q: exit code 1`, tmp), err)
+}
+
+func TestDefaultClient_RunCommandAsync_Job(t *testing.T) {
+	v, err := version.NewVersion("0.11.11")
+	Ok(t, err)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	jobs := &fakeJobRunner{output: "line 1\nline 2\n"}
+	client := &DefaultClient{
+		defaultVersion:          v,
+		terraformPluginCacheDir: tmp,
+		overrideTF:              "echo",
+		jobRunner:               jobs,
+	}
+	log := logging.NewNoopLogger(t)
+
+	_, outCh := client.RunCommandAsync(log, tmp, []string{"apply"}, map[string]string{}, nil, "workspace")
+	out, err := waitCh(outCh)
+	Ok(t, err)
+	Equals(t, "line 1\nline 2", out)
+	Equals(t, []string{"echo apply"}, jobs.cmds)
+}
+
+type fakeJobRunner struct {
+	output string
+	err    error
+	cmds   []string
+	dir    string
+	envs   []string
+}
+
+func (f *fakeJobRunner) RunJob(log logging.SimpleLogging, dir string, cmd string, envs []string, out io.Writer) error {
+	f.cmds = append(f.cmds, cmd)
+	f.dir = dir
+	f.envs = envs
+	io.WriteString(out, f.output) // nolint: errcheck
+	return f.err
+}
+
+func containsEnv(envs []string, env string) bool {
+	for _, e := range envs {
+		if e == env {
+			return true
+		}
+	}
+	return false
+}
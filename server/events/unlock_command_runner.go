@@ -37,6 +37,7 @@ func (u *UnlockCommandRunner) Run(
 	if err != nil {
 		vcsMessage = "Failed to delete PR locks"
 		ctx.Log.Err("failed to delete locks by pull %s", err.Error())
+		ctx.CommandError = err
 	}
 
 	// if there are no locks to delete, no errors, and SilenceNoProjects is enabled, don't comment
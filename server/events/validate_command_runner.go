@@ -0,0 +1,134 @@
+package events
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+)
+
+func NewValidateCommandRunner(
+	prjCmdBuilder ProjectPlanCommandBuilder,
+	terraformExec runtime.TerraformExec,
+	workingDir WorkingDir,
+	workingDirLocker WorkingDirLocker,
+	vcsClient vcs.Client,
+	SilenceNoProjects bool,
+) *ValidateCommandRunner {
+	return &ValidateCommandRunner{
+		prjCmdBuilder:     prjCmdBuilder,
+		terraformExec:     terraformExec,
+		workingDir:        workingDir,
+		workingDirLocker:  workingDirLocker,
+		vcsClient:         vcsClient,
+		SilenceNoProjects: SilenceNoProjects,
+	}
+}
+
+// ValidateCommandRunner validates the configuration of projects with
+// terraform validate and comments the errors. It doesn't plan so it doesn't
+// lock the projects or create plan files.
+type ValidateCommandRunner struct {
+	prjCmdBuilder    ProjectPlanCommandBuilder
+	terraformExec    runtime.TerraformExec
+	workingDir       WorkingDir
+	workingDirLocker WorkingDirLocker
+	vcsClient        vcs.Client
+	// SilenceNoProjects is whether Atlantis should respond to PRs if no projects
+	// are found
+	SilenceNoProjects bool
+}
+
+func (v *ValidateCommandRunner) Run(
+	ctx *CommandContext,
+	cmd *CommentCommand,
+) {
+	// The projects are found like for plan so that the same flags select
+	// them, which also clones the pull request.
+	projectCmds, err := v.prjCmdBuilder.BuildPlanCommands(ctx, cmd)
+	if err != nil {
+		ctx.Log.Err("failed to build validate commands: %s", err)
+		ctx.CommandError = err
+		v.comment(ctx, fmt.Sprintf("**Validate Error**\n```\n%s\n```", err))
+		return
+	}
+	if len(projectCmds) == 0 {
+		if !v.SilenceNoProjects {
+			v.comment(ctx, "Ran validate for 0 projects.")
+		}
+		return
+	}
+	comment, valid := v.validate(ctx, projectCmds, cmd.Flags)
+	if !valid {
+		ctx.CommandFailure = "the configuration is invalid"
+	}
+	v.comment(ctx, comment)
+}
+
+// ValidateAutoplan validates the projects of projectCmds before they're
+// autoplanned. If any of them is invalid, it comments the errors and returns
+// false so they aren't planned.
+func (v *ValidateCommandRunner) ValidateAutoplan(ctx *CommandContext, projectCmds []models.ProjectCommandContext) bool {
+	comment, valid := v.validate(ctx, projectCmds, nil)
+	if valid {
+		return true
+	}
+	v.comment(ctx, comment+"\n\n---\n* :warning: Autoplan was skipped. Push a fix to plan again.")
+	return false
+}
+
+// validate runs terraform validate for each project of projectCmds. It
+// returns the comment with the results and whether all of them are valid.
+func (v *ValidateCommandRunner) validate(ctx *CommandContext, projectCmds []models.ProjectCommandContext, extraArgs []string) (string, bool) {
+	valid := true
+	var sections []string
+	for _, projectCmd := range projectCmds {
+		out, err := v.run(ctx, projectCmd, extraArgs)
+		if err != nil {
+			ctx.Log.Info("%s is invalid: %s", projectDescription(projectCmd), err)
+			valid = false
+			sections = append(sections, fmt.Sprintf("### %s\n:x: **Validate Error**\n```\n%s\n%s\n```", projectDescription(projectCmd), err, strings.TrimSpace(out)))
+			continue
+		}
+		sections = append(sections, fmt.Sprintf("### %s\n:white_check_mark: %s", projectDescription(projectCmd), strings.TrimSpace(out)))
+	}
+	projects := "projects"
+	if len(projectCmds) == 1 {
+		projects = "project"
+	}
+	return fmt.Sprintf("Ran validate for %d %s:\n\n%s", len(projectCmds), projects, strings.Join(sections, "\n\n")), valid
+}
+
+// run runs terraform validate for the project of projectCmd after
+// initializing it. The backend isn't initialized since validate doesn't read
+// the state so its credentials aren't needed.
+func (v *ValidateCommandRunner) run(ctx *CommandContext, projectCmd models.ProjectCommandContext, extraArgs []string) (string, error) {
+	unlockFn, err := v.workingDirLocker.TryLock(projectCmd.Pull.BaseRepo.FullName, projectCmd.Pull.Num, projectCmd.Workspace)
+	if err != nil {
+		return "", err
+	}
+	defer unlockFn()
+	repoDir, err := v.workingDir.GetWorkingDir(projectCmd.Pull.BaseRepo, projectCmd.Pull, projectCmd.Workspace)
+	if err != nil {
+		return "", err
+	}
+	absPath := filepath.Join(repoDir, projectCmd.RepoRelDir)
+	if _, err := os.Stat(absPath); os.IsNotExist(err) {
+		return "", DirNotExistErr{RepoRelDir: projectCmd.RepoRelDir}
+	}
+	if out, err := v.terraformExec.RunCommandWithVersion(ctx.Log, absPath, []string{"init", "-input=false", "-no-color", "-backend=false"}, nil, projectCmd.TerraformVersion, projectCmd.Workspace); err != nil {
+		return out, err
+	}
+	args := append([]string{"validate", "-no-color"}, extraArgs...)
+	return v.terraformExec.RunCommandWithVersion(ctx.Log, absPath, args, nil, projectCmd.TerraformVersion, projectCmd.Workspace)
+}
+
+func (v *ValidateCommandRunner) comment(ctx *CommandContext, comment string) {
+	if commentErr := v.vcsClient.CreateComment(ctx.Pull.BaseRepo, ctx.Pull.Num, comment, models.ValidateCommand.String()); commentErr != nil {
+		ctx.Log.Err("unable to comment: %s", commentErr)
+	}
+}
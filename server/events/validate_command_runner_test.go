@@ -0,0 +1,102 @@
+package events_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	version "github.com/hashicorp/go-version"
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/models/fixtures"
+	tfmocks "github.com/runatlantis/atlantis/server/events/terraform/mocks"
+	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+var validateInitArgs = []string{"init", "-input=false", "-no-color", "-backend=false"}
+
+func TestValidateCommandRunner_Run(t *testing.T) {
+	RegisterMockTestingT(t)
+	repoDir, cleanup := TempDir(t)
+	defer cleanup()
+	Ok(t, os.Mkdir(filepath.Join(repoDir, "app"), 0700))
+	Ok(t, os.Mkdir(filepath.Join(repoDir, "network"), 0700))
+
+	builder := mocks.NewMockProjectCommandBuilder()
+	tf := tfmocks.NewMockClient()
+	workingDir := mocks.NewMockWorkingDir()
+	vcsClient := vcsmocks.NewMockClient()
+	runner := events.NewValidateCommandRunner(builder, tf, workingDir, events.NewDefaultWorkingDirLocker(), vcsClient, false)
+
+	logger := logging.NewNoopLogger(t)
+	tfVersion := version.Must(version.NewVersion("0.14.0"))
+	pull := fixtures.Pull
+	pull.BaseRepo = fixtures.GithubRepo
+	ctx := &events.CommandContext{Pull: pull, HeadRepo: fixtures.GithubRepo, User: fixtures.User, Log: logger}
+	cmd := &events.CommentCommand{Name: models.ValidateCommand}
+	When(builder.BuildPlanCommands(ctx, cmd)).ThenReturn([]models.ProjectCommandContext{
+		{Log: logger, Pull: pull, RepoRelDir: "app", Workspace: "default", TerraformVersion: tfVersion},
+		{Log: logger, Pull: pull, RepoRelDir: "network", Workspace: "default"},
+	}, nil)
+	When(workingDir.GetWorkingDir(fixtures.GithubRepo, pull, "default")).ThenReturn(repoDir, nil)
+	When(tf.RunCommandWithVersion(logger, filepath.Join(repoDir, "app"), validateInitArgs, map[string]string(nil), tfVersion, "default")).
+		ThenReturn("", nil)
+	When(tf.RunCommandWithVersion(logger, filepath.Join(repoDir, "app"), []string{"validate", "-no-color"}, map[string]string(nil), tfVersion, "default")).
+		ThenReturn("Success! The configuration is valid.\n", nil)
+	When(tf.RunCommandWithVersion(logger, filepath.Join(repoDir, "network"), validateInitArgs, map[string]string(nil), nil, "default")).
+		ThenReturn("", nil)
+	When(tf.RunCommandWithVersion(logger, filepath.Join(repoDir, "network"), []string{"validate", "-no-color"}, map[string]string(nil), nil, "default")).
+		ThenReturn("\nError: Unsupported argument\n\n  on main.tf line 2, in resource \"aws_vpc\" \"main\":\n", errors.New("exit status 1"))
+
+	runner.Run(ctx, cmd)
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, pull.Num, "Ran validate for 2 projects:\n\n"+
+		"### dir: `app` workspace: `default`\n:white_check_mark: Success! The configuration is valid.\n\n"+
+		"### dir: `network` workspace: `default`\n:x: **Validate Error**\n```\nexit status 1\nError: Unsupported argument\n\n  on main.tf line 2, in resource \"aws_vpc\" \"main\":\n```",
+		"validate")
+}
+
+func TestValidateCommandRunner_ValidateAutoplan(t *testing.T) {
+	RegisterMockTestingT(t)
+	repoDir, cleanup := TempDir(t)
+	defer cleanup()
+	Ok(t, os.Mkdir(filepath.Join(repoDir, "app"), 0700))
+
+	tf := tfmocks.NewMockClient()
+	workingDir := mocks.NewMockWorkingDir()
+	vcsClient := vcsmocks.NewMockClient()
+	runner := events.NewValidateCommandRunner(mocks.NewMockProjectCommandBuilder(), tf, workingDir, events.NewDefaultWorkingDirLocker(), vcsClient, false)
+
+	logger := logging.NewNoopLogger(t)
+	pull := fixtures.Pull
+	pull.BaseRepo = fixtures.GithubRepo
+	ctx := &events.CommandContext{Pull: pull, HeadRepo: fixtures.GithubRepo, User: fixtures.User, Log: logger}
+	projectCmds := []models.ProjectCommandContext{
+		{Log: logger, Pull: pull, RepoRelDir: "app", Workspace: "default"},
+	}
+	absPath := filepath.Join(repoDir, "app")
+	When(workingDir.GetWorkingDir(fixtures.GithubRepo, pull, "default")).ThenReturn(repoDir, nil)
+	When(tf.RunCommandWithVersion(logger, absPath, validateInitArgs, map[string]string(nil), nil, "default")).
+		ThenReturn("", nil)
+	When(tf.RunCommandWithVersion(logger, absPath, []string{"validate", "-no-color"}, map[string]string(nil), nil, "default")).
+		ThenReturn("Success! The configuration is valid.\n", nil)
+
+	// Valid projects are planned without commenting.
+	Equals(t, true, runner.ValidateAutoplan(ctx, projectCmds))
+	vcsClient.VerifyWasCalled(Never()).CreateComment(fixtures.GithubRepo, pull.Num, "Ran validate for 1 project:\n\n"+
+		"### dir: `app` workspace: `default`\n:white_check_mark: Success! The configuration is valid.",
+		"validate")
+
+	// Errors initializing the project fail the validation too.
+	When(tf.RunCommandWithVersion(logger, absPath, validateInitArgs, map[string]string(nil), nil, "default")).
+		ThenReturn("Error: Module not found\n", errors.New("exit status 1"))
+	Equals(t, false, runner.ValidateAutoplan(ctx, projectCmds))
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, pull.Num, "Ran validate for 1 project:\n\n"+
+		"### dir: `app` workspace: `default`\n:x: **Validate Error**\n```\nexit status 1\nError: Module not found\n```\n\n"+
+		"---\n* :warning: Autoplan was skipped. Push a fix to plan again.",
+		"validate")
+}
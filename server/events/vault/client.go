@@ -0,0 +1,243 @@
+// Package vault reads secrets from HashiCorp Vault for project workflows.
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// TokenAuthMethod authenticates with a static token.
+	TokenAuthMethod = "token"
+	// KubernetesAuthMethod authenticates with the pod's service account token.
+	KubernetesAuthMethod = "kubernetes"
+	// AppRoleAuthMethod authenticates with a role ID and secret ID.
+	AppRoleAuthMethod = "approle"
+
+	// DefaultKubernetesTokenFile is where Kubernetes mounts the pod's service
+	// account token.
+	DefaultKubernetesTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token" // nolint: gosec
+
+	// renewBefore is how long before a token's lease expires that we log in
+	// again so tokens don't expire mid-request.
+	renewBefore = 30 * time.Second
+)
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_client.go Client
+
+// Client reads secrets from Vault.
+type Client interface {
+	// Read returns the value of key in the secret at path, ex.
+	// secret/data/db. Both KV version 1 and 2 secrets are supported.
+	Read(path string, key string) (string, error)
+}
+
+// Config configures how to connect and authenticate to Vault.
+type Config struct {
+	// Addr is Vault's address, ex. https://vault.example.com:8200.
+	Addr string
+	// Namespace is the Vault Enterprise namespace. Optional.
+	Namespace string
+	// AuthMethod is one of TokenAuthMethod, KubernetesAuthMethod or
+	// AppRoleAuthMethod.
+	AuthMethod string
+	// AuthMount is the path the auth method is mounted at. If empty, the
+	// auth method's name is used.
+	AuthMount string
+	// Token is used by the token auth method.
+	Token string
+	// Role is the role name for the kubernetes auth method and the role ID
+	// for the approle auth method.
+	Role string
+	// SecretID is used by the approle auth method.
+	SecretID string
+	// KubernetesTokenFile is read by the kubernetes auth method. If empty,
+	// DefaultKubernetesTokenFile is used.
+	KubernetesTokenFile string
+}
+
+// DefaultClient reads secrets using Vault's HTTP API. It logs in lazily and
+// logs in again when its token's lease is about to expire.
+type DefaultClient struct {
+	config     Config
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// NewDefaultClient returns a client for config.
+func NewDefaultClient(config Config) (*DefaultClient, error) {
+	switch config.AuthMethod {
+	case TokenAuthMethod:
+		if config.Token == "" {
+			return nil, errors.New("token auth method requires a token")
+		}
+	case KubernetesAuthMethod:
+		if config.Role == "" {
+			return nil, errors.New("kubernetes auth method requires a role")
+		}
+		if config.KubernetesTokenFile == "" {
+			config.KubernetesTokenFile = DefaultKubernetesTokenFile
+		}
+	case AppRoleAuthMethod:
+		if config.Role == "" || config.SecretID == "" {
+			return nil, errors.New("approle auth method requires a role ID and secret ID")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported auth method %q", config.AuthMethod)
+	}
+	if config.AuthMount == "" {
+		config.AuthMount = config.AuthMethod
+	}
+	config.Addr = strings.TrimSuffix(config.Addr, "/")
+	return &DefaultClient{
+		config:     config,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		now:        time.Now,
+	}, nil
+}
+
+type response struct {
+	Data json.RawMessage `json:"data"`
+	Auth *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+// Read returns the value of key in the secret at path.
+func (c *DefaultClient) Read(path string, key string) (string, error) {
+	token, err := c.login()
+	if err != nil {
+		return "", errors.Wrap(err, "logging in to Vault")
+	}
+	resp, err := c.do(http.MethodGet, "/v1/"+strings.TrimPrefix(path, "/"), token, nil)
+	if err != nil {
+		return "", errors.Wrapf(err, "reading %s", path)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		return "", errors.Wrapf(err, "parsing %s", path)
+	}
+	// KV version 2 nests the secret under data alongside its metadata.
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no key %q", path, key)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	encoded, err := json.Marshal(value)
+	return string(encoded), err
+}
+
+// login returns a valid token, logging in if necessary.
+func (c *DefaultClient) login() (string, error) {
+	if c.config.AuthMethod == TokenAuthMethod {
+		return c.config.Token, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && (c.tokenExpiry.IsZero() || c.now().Before(c.tokenExpiry.Add(-renewBefore))) {
+		return c.token, nil
+	}
+
+	body := map[string]string{}
+	switch c.config.AuthMethod {
+	case KubernetesAuthMethod:
+		jwt, err := ioutil.ReadFile(c.config.KubernetesTokenFile)
+		if err != nil {
+			return "", errors.Wrap(err, "reading service account token")
+		}
+		body["role"] = c.config.Role
+		body["jwt"] = strings.TrimSpace(string(jwt))
+	case AppRoleAuthMethod:
+		body["role_id"] = c.config.Role
+		body["secret_id"] = c.config.SecretID
+	}
+	resp, err := c.do(http.MethodPost, fmt.Sprintf("/v1/auth/%s/login", c.config.AuthMount), "", body)
+	if err != nil {
+		return "", err
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return "", errors.New("login response had no token")
+	}
+	c.token = resp.Auth.ClientToken
+	c.tokenExpiry = time.Time{}
+	if resp.Auth.LeaseDuration > 0 {
+		c.tokenExpiry = c.now().Add(time.Duration(resp.Auth.LeaseDuration) * time.Second)
+	}
+	return c.token, nil
+}
+
+func (c *DefaultClient) do(method string, path string, token string, body interface{}) (*response, error) {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(method, c.config.Addr+path, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if c.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.config.Namespace)
+	}
+	r, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close() // nolint: errcheck
+	respBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	var resp response
+	// Error responses are JSON too but we don't fail if they can't be parsed
+	// since we only want the status code then.
+	jsonErr := json.Unmarshal(respBody, &resp)
+	if r.StatusCode != http.StatusOK {
+		// Never include the response body since it could contain secrets.
+		if len(resp.Errors) > 0 {
+			return nil, fmt.Errorf("Vault returned %d: %s", r.StatusCode, strings.Join(resp.Errors, ", "))
+		}
+		return nil, fmt.Errorf("Vault returned %d", r.StatusCode)
+	}
+	if jsonErr != nil {
+		return nil, errors.Wrap(jsonErr, "parsing response")
+	}
+	return &resp, nil
+}
+
+// MaskSecrets replaces each of secrets in s with "***".
+func MaskSecrets(s string, secrets []string) string {
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		s = strings.Replace(s, secret, "***", -1)
+	}
+	return s
+}
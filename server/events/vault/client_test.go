@@ -0,0 +1,186 @@
+package vault_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/vault"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// fakeVault serves a KV v1 secret at secret/v1, a KV v2 secret at
+// secret/data/v2 and logs in with any auth method mounted at approle or
+// kubernetes.
+func fakeVault(t *testing.T, logins *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/approle/login", "/v1/auth/kubernetes/login":
+			var body map[string]string
+			Ok(t, json.NewDecoder(r.Body).Decode(&body))
+			if body["secret_id"] != "secret-id" && body["jwt"] != "jwt" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"errors":["invalid credentials"]}`) // nolint: errcheck
+				return
+			}
+			*logins++
+			fmt.Fprint(w, `{"auth":{"client_token":"login-token","lease_duration":3600}}`) // nolint: errcheck
+			return
+		}
+		token := r.Header.Get("X-Vault-Token")
+		if token != "token" && token != "login-token" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"errors":["permission denied"]}`) // nolint: errcheck
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/v1":
+			fmt.Fprint(w, `{"data":{"password":"v1-password","port":5432}}`) // nolint: errcheck
+		case "/v1/secret/data/v2":
+			Equals(t, "ns", r.Header.Get("X-Vault-Namespace"))
+			fmt.Fprint(w, `{"data":{"data":{"password":"v2-password"},"metadata":{"version":1}}}`) // nolint: errcheck
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errors":[]}`) // nolint: errcheck
+		}
+	}))
+}
+
+func TestDefaultClient_Read(t *testing.T) {
+	var logins int
+	server := fakeVault(t, &logins)
+	defer server.Close()
+
+	client, err := vault.NewDefaultClient(vault.Config{
+		Addr:       server.URL,
+		Namespace:  "ns",
+		AuthMethod: vault.TokenAuthMethod,
+		Token:      "token",
+	})
+	Ok(t, err)
+
+	value, err := client.Read("secret/v1", "password")
+	Ok(t, err)
+	Equals(t, "v1-password", value)
+
+	value, err = client.Read("secret/v1", "port")
+	Ok(t, err)
+	Equals(t, "5432", value)
+
+	value, err = client.Read("secret/data/v2", "password")
+	Ok(t, err)
+	Equals(t, "v2-password", value)
+
+	_, err = client.Read("secret/data/v2", "missing")
+	ErrEquals(t, `secret secret/data/v2 has no key "missing"`, err)
+
+	_, err = client.Read("secret/missing", "password")
+	ErrEquals(t, "reading secret/missing: Vault returned 404", err)
+}
+
+func TestDefaultClient_ReadPermissionDenied(t *testing.T) {
+	var logins int
+	server := fakeVault(t, &logins)
+	defer server.Close()
+
+	client, err := vault.NewDefaultClient(vault.Config{
+		Addr:       server.URL,
+		AuthMethod: vault.TokenAuthMethod,
+		Token:      "wrong",
+	})
+	Ok(t, err)
+	_, err = client.Read("secret/v1", "password")
+	ErrEquals(t, "reading secret/v1: Vault returned 403: permission denied", err)
+}
+
+func TestDefaultClient_AppRole(t *testing.T) {
+	var logins int
+	server := fakeVault(t, &logins)
+	defer server.Close()
+
+	client, err := vault.NewDefaultClient(vault.Config{
+		Addr:       server.URL,
+		AuthMethod: vault.AppRoleAuthMethod,
+		Role:       "role-id",
+		SecretID:   "secret-id",
+	})
+	Ok(t, err)
+	for i := 0; i < 2; i++ {
+		value, err := client.Read("secret/v1", "password")
+		Ok(t, err)
+		Equals(t, "v1-password", value)
+	}
+	// The token should be reused until its lease expires.
+	Equals(t, 1, logins)
+
+	client, err = vault.NewDefaultClient(vault.Config{
+		Addr:       server.URL,
+		AuthMethod: vault.AppRoleAuthMethod,
+		Role:       "role-id",
+		SecretID:   "wrong",
+	})
+	Ok(t, err)
+	_, err = client.Read("secret/v1", "password")
+	ErrEquals(t, "logging in to Vault: Vault returned 400: invalid credentials", err)
+}
+
+func TestDefaultClient_Kubernetes(t *testing.T) {
+	var logins int
+	server := fakeVault(t, &logins)
+	defer server.Close()
+
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	tokenFile := filepath.Join(tmp, "token")
+	Ok(t, ioutil.WriteFile(tokenFile, []byte("jwt\n"), 0600))
+
+	client, err := vault.NewDefaultClient(vault.Config{
+		Addr:                server.URL,
+		AuthMethod:          vault.KubernetesAuthMethod,
+		Role:                "atlantis",
+		KubernetesTokenFile: tokenFile,
+	})
+	Ok(t, err)
+	value, err := client.Read("secret/v1", "password")
+	Ok(t, err)
+	Equals(t, "v1-password", value)
+}
+
+func TestNewDefaultClient_InvalidConfig(t *testing.T) {
+	cases := map[string]struct {
+		config vault.Config
+		expErr string
+	}{
+		"token": {
+			vault.Config{AuthMethod: vault.TokenAuthMethod},
+			"token auth method requires a token",
+		},
+		"kubernetes": {
+			vault.Config{AuthMethod: vault.KubernetesAuthMethod},
+			"kubernetes auth method requires a role",
+		},
+		"approle": {
+			vault.Config{AuthMethod: vault.AppRoleAuthMethod, Role: "role-id"},
+			"approle auth method requires a role ID and secret ID",
+		},
+		"unsupported": {
+			vault.Config{AuthMethod: "ldap"},
+			`unsupported auth method "ldap"`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := vault.NewDefaultClient(c.config)
+			ErrEquals(t, c.expErr, err)
+		})
+	}
+}
+
+func TestMaskSecrets(t *testing.T) {
+	Equals(t, "password is *** and *** again, key is ***",
+		vault.MaskSecrets("password is hunter2 and hunter2 again, key is abc", []string{"hunter2", "", "abc"}))
+}
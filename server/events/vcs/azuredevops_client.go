@@ -17,9 +17,13 @@ import (
 
 // AzureDevopsClient represents an Azure DevOps VCS client
 type AzureDevopsClient struct {
-	Client   *azuredevops.Client
-	ctx      context.Context
-	UserName string
+	Client     *azuredevops.Client
+	ctx        context.Context
+	UserName   string
+	httpClient *http.Client
+	// MaxCommentLength is the maximum number of chars of a comment. Longer
+	// comments are split. If 0, it's 65536.
+	MaxCommentLength int
 }
 
 // NewAzureDevopsClient returns a valid Azure DevOps client.
@@ -45,14 +49,21 @@ func NewAzureDevopsClient(hostname string, userName string, token string) (*Azur
 	}
 
 	client := &AzureDevopsClient{
-		Client:   adClient,
-		UserName: userName,
-		ctx:      context.Background(),
+		Client:     adClient,
+		UserName:   userName,
+		ctx:        context.Background(),
+		httpClient: httpClient,
 	}
 
 	return client, nil
 }
 
+// TrackRateLimits records the rate limits of the responses to the client's
+// requests in limits.
+func (g *AzureDevopsClient) TrackRateLimits(limits *RateLimits) {
+	g.httpClient.Transport = limits.Transport(models.AzureDevops, g.httpClient.Transport)
+}
+
 // GetModifiedFiles returns the names of files that were modified in the merge request
 // relative to the repo root, e.g. parent/child/file.txt.
 func (g *AzureDevopsClient) GetModifiedFiles(repo models.Repo, pull models.PullRequest) ([]string, error) {
@@ -104,7 +115,10 @@ func (g *AzureDevopsClient) CreateComment(repo models.Repo, pullNum int, comment
 	// maxCommentLength is the maximum number of chars allowed in a single comment
 	// This length was copied from the Github client - haven't found documentation
 	// or tested limit in Azure DevOps.
-	const maxCommentLength = 65536
+	maxCommentLength := 65536
+	if g.MaxCommentLength > 0 {
+		maxCommentLength = g.MaxCommentLength
+	}
 
 	comments := common.SplitComment(comment, maxCommentLength, sepEnd, sepStart)
 	owner, project, repoName := SplitAzureDevopsRepoFullName(repo.FullName)
@@ -130,6 +144,16 @@ func (g *AzureDevopsClient) CreateComment(repo models.Repo, pullNum int, comment
 	return nil
 }
 
+// CreateEditableComment is not yet supported for Azure DevOps.
+func (g *AzureDevopsClient) CreateEditableComment(repo models.Repo, pullNum int, comment string) (int64, error) {
+	return 0, fmt.Errorf("editing comments is not supported for Azure DevOps")
+}
+
+// EditComment is not yet supported for Azure DevOps.
+func (g *AzureDevopsClient) EditComment(repo models.Repo, pullNum int, commentID int64, comment string) error {
+	return fmt.Errorf("editing comments is not supported for Azure DevOps")
+}
+
 func (g *AzureDevopsClient) HidePrevCommandComments(repo models.Repo, pullNum int, command string) error {
 	return nil
 }
@@ -164,6 +188,32 @@ func (g *AzureDevopsClient) PullIsApproved(repo models.Repo, pull models.PullReq
 	return false, nil
 }
 
+// GetApprovals returns the reviewers that voted to approve the pull request,
+// identified by their unique name. Azure DevOps doesn't report when votes
+// were cast.
+func (g *AzureDevopsClient) GetApprovals(repo models.Repo, pull models.PullRequest) ([]models.Approval, error) {
+	owner, project, repoName := SplitAzureDevopsRepoFullName(repo.FullName)
+
+	opts := azuredevops.PullRequestGetOptions{
+		IncludeWorkItemRefs: true,
+	}
+	adPull, _, err := g.Client.PullRequests.GetWithRepo(g.ctx, owner, project, repoName, pull.Num, &opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting pull request")
+	}
+
+	var approvals []models.Approval
+	for _, review := range adPull.Reviewers {
+		if review == nil {
+			continue
+		}
+		if review.GetVote() == azuredevops.VoteApproved || review.GetVote() == azuredevops.VoteApprovedWithSuggestions {
+			approvals = append(approvals, models.Approval{Username: review.IdentityRef.GetUniqueName()})
+		}
+	}
+	return approvals, nil
+}
+
 // PullIsMergeable returns true if the merge request can be merged.
 func (g *AzureDevopsClient) PullIsMergeable(repo models.Repo, pull models.PullRequest) (bool, error) {
 	owner, project, repoName := SplitAzureDevopsRepoFullName(repo.FullName)
@@ -215,6 +265,16 @@ func (g *AzureDevopsClient) PullIsMergeable(repo models.Repo, pull models.PullRe
 	return true, nil
 }
 
+// PullIsClosed returns true if the pull request was abandoned or completed.
+func (g *AzureDevopsClient) PullIsClosed(repo models.Repo, pull models.PullRequest) (bool, error) {
+	adPull, err := g.GetPullRequest(repo, pull.Num)
+	if err != nil {
+		return false, errors.Wrap(err, "getting pull request")
+	}
+	status := adPull.GetStatus()
+	return status == azuredevops.PullAbandoned.String() || status == azuredevops.PullCompleted.String(), nil
+}
+
 // GetPullRequest returns the pull request.
 func (g *AzureDevopsClient) GetPullRequest(repo models.Repo, num int) (*azuredevops.GitPullRequest, error) {
 	opts := azuredevops.PullRequestGetOptions{
@@ -288,6 +348,157 @@ func (g *AzureDevopsClient) UpdateStatus(repo models.Repo, pull models.PullReque
 	return err
 }
 
+// PropagateStatuses re-posts the latest Atlantis statuses of pull on its
+// latest iteration, if they were posted on previous ones. Azure DevOps
+// statuses belong to the iteration they're posted on so branch policies show
+// stale results after new commits are pushed until Atlantis posts statuses
+// again. It returns how many statuses were re-posted.
+func (g *AzureDevopsClient) PropagateStatuses(repo models.Repo, pull models.PullRequest) (int, error) {
+	owner, project, repoName := SplitAzureDevopsRepoFullName(repo.FullName)
+	source, _, err := g.Client.PullRequests.Get(g.ctx, owner, project, pull.Num, &azuredevops.PullRequestListOptions{})
+	if err != nil {
+		return 0, errors.Wrap(err, "getting pull request")
+	}
+	if !source.GetSupportsIterations() {
+		return 0, nil
+	}
+	iterations, _, err := g.Client.PullRequests.ListIterations(g.ctx, owner, project, repoName, pull.Num, &azuredevops.PullRequestIterationsListOptions{})
+	if err != nil {
+		return 0, errors.Wrap(err, "listing pull request iterations")
+	}
+	latestIteration := 0
+	for _, iteration := range iterations {
+		if iteration.GetID() > latestIteration {
+			latestIteration = iteration.GetID()
+		}
+	}
+
+	statuses, err := g.listStatuses(owner, project, repoName, pull.Num)
+	if err != nil {
+		return 0, err
+	}
+	// Statuses are never updated, a new one is posted with the same context
+	// instead, so the latest of each context has the highest ID.
+	latest := make(map[string]*azuredevops.GitPullRequestStatus)
+	var contexts []string
+	for _, status := range statuses {
+		statusContext := status.GetContext()
+		if statusContext == nil || !strings.HasPrefix(statusContext.GetGenre(), "Atlantis Bot") {
+			continue
+		}
+		key := statusContext.GetGenre() + "/" + statusContext.GetName()
+		if prev, ok := latest[key]; !ok {
+			contexts = append(contexts, key)
+		} else if prev.GetID() > status.GetID() {
+			continue
+		}
+		latest[key] = status
+	}
+
+	propagated := 0
+	for _, key := range contexts {
+		status := latest[key]
+		if status.IterationID == nil || *status.IterationID >= latestIteration {
+			continue
+		}
+		iterationID := latestIteration
+		newStatus := azuredevops.GitPullRequestStatus{IterationID: &iterationID}
+		newStatus.Context = status.Context
+		newStatus.Description = status.Description
+		newStatus.State = status.State
+		newStatus.TargetURL = status.TargetURL
+		if _, _, err := g.Client.PullRequests.CreateStatus(g.ctx, owner, project, repoName, pull.Num, &newStatus); err != nil {
+			return propagated, errors.Wrapf(err, "creating pull request status %s", key)
+		}
+		propagated++
+	}
+	return propagated, nil
+}
+
+// listStatuses returns the statuses of the pull request pullNum, which the
+// azuredevops package can't list.
+func (g *AzureDevopsClient) listStatuses(owner string, project string, repoName string, pullNum int) ([]*azuredevops.GitPullRequestStatus, error) {
+	URL := fmt.Sprintf("%s/%s/_apis/git/repositories/%s/pullrequests/%d/statuses?api-version=5.1-preview.1", owner, project, repoName, pullNum)
+	req, err := g.Client.NewRequest("GET", URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	var list struct {
+		Value []*azuredevops.GitPullRequestStatus `json:"value"`
+	}
+	if _, err := g.Client.Execute(g.ctx, req, &list); err != nil {
+		return nil, errors.Wrap(err, "listing pull request statuses")
+	}
+	return list.Value, nil
+}
+
+// azureDevopsThread is a pull request comment thread. The azuredevops
+// package can't list or update them.
+type azureDevopsThread struct {
+	ID       int    `json:"id"`
+	Status   string `json:"status"`
+	Comments []struct {
+		Content string `json:"content"`
+		Author  struct {
+			UniqueName string `json:"uniqueName"`
+		} `json:"author"`
+	} `json:"comments"`
+}
+
+// ClosePlanThreads sets the status of the active comment threads of the plans
+// of the pull request pullNum to fixed, so they don't count as open comments
+// once the plans are applied. Long plans are split over several threads,
+// which are all closed. It returns how many threads were closed.
+func (g *AzureDevopsClient) ClosePlanThreads(repo models.Repo, pullNum int) (int, error) {
+	owner, project, repoName := SplitAzureDevopsRepoFullName(repo.FullName)
+	threadsURL := fmt.Sprintf("%s/%s/_apis/git/repositories/%s/pullRequests/%d/threads", owner, project, repoName, pullNum)
+	req, err := g.Client.NewRequest("GET", threadsURL+"?api-version=5.1", nil)
+	if err != nil {
+		return 0, err
+	}
+	var list struct {
+		Value []azureDevopsThread `json:"value"`
+	}
+	if _, err := g.Client.Execute(g.ctx, req, &list); err != nil {
+		return 0, errors.Wrap(err, "listing pull request threads")
+	}
+
+	closed := 0
+	// inPlan is true while the threads continue the comment of a plan.
+	inPlan := false
+	for _, thread := range list.Value {
+		if len(thread.Comments) == 0 || !strings.EqualFold(thread.Comments[0].Author.UniqueName, g.UserName) {
+			inPlan = false
+			continue
+		}
+		firstLine := strings.ToLower(strings.Split(thread.Comments[0].Content, "\n")[0])
+		if strings.HasPrefix(firstLine, "continued from previous comment.") {
+			if !inPlan {
+				continue
+			}
+		} else {
+			// Like on GitHub, the comments of a command include its name in
+			// their first line.
+			inPlan = strings.Contains(firstLine, models.PlanCommand.String())
+			if !inPlan {
+				continue
+			}
+		}
+		if thread.Status != "active" {
+			continue
+		}
+		req, err := g.Client.NewRequest("PATCH", fmt.Sprintf("%s/%d?api-version=5.1", threadsURL, thread.ID), map[string]string{"status": "fixed"})
+		if err != nil {
+			return closed, err
+		}
+		if _, err := g.Client.Execute(g.ctx, req, nil); err != nil {
+			return closed, errors.Wrapf(err, "closing pull request thread %d", thread.ID)
+		}
+		closed++
+	}
+	return closed, nil
+}
+
 // MergePull merges the merge request using the default no fast-forward strategy
 // If the user has set a branch policy that disallows no fast-forward, the merge will fail
 // until we handle branch policies
@@ -318,7 +529,7 @@ func (g *AzureDevopsClient) MergePull(pull models.PullRequest, pullOptions model
 		BypassPolicy:            new(bool),
 		BypassReason:            azuredevops.String(""),
 		DeleteSourceBranch:      &pullOptions.DeleteSourceBranchOnMerge,
-		MergeCommitMessage:      azuredevops.String(common.AutomergeCommitMsg),
+		MergeCommitMessage:      azuredevops.String(common.AutomergeCommitMsgOrDefault(pullOptions.CommitMessage)),
 		MergeStrategy:           &mcm,
 		SquashMerge:             new(bool),
 		TransitionWorkItems:     twi,
@@ -377,6 +588,23 @@ func SplitAzureDevopsRepoFullName(repoFullName string) (owner string, project st
 	return repoFullName[:lastSlashIdx], "", repoFullName[lastSlashIdx+1:]
 }
 
+// CheckConnection returns an error if Azure DevOps can't be reached. The
+// token isn't checked since tokens are scoped to organizations, which aren't
+// known until a webhook is received.
+func (g *AzureDevopsClient) CheckConnection() error {
+	u := g.Client.BaseURL
+	u.Path = "/_apis/connectionData"
+	resp, err := g.httpClient.Get(u.String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("GET %s responded with status %d", u.String(), resp.StatusCode)
+	}
+	return nil
+}
+
 func (g *AzureDevopsClient) SupportsSingleFileDownload(repo models.Repo) bool {
 	return false
 }
@@ -385,6 +613,31 @@ func (g *AzureDevopsClient) DownloadRepoConfigFile(pull models.PullRequest) (boo
 	return false, []byte{}, fmt.Errorf("Not Implemented")
 }
 
+// GetTeamNamesForUser is not yet supported for Azure DevOps.
+func (g *AzureDevopsClient) GetTeamNamesForUser(repo models.Repo, user models.User, teams []string) ([]string, error) {
+	return nil, fmt.Errorf("team membership is not supported for Azure DevOps")
+}
+
+// FindOpenIssue is not yet supported for Azure DevOps.
+func (g *AzureDevopsClient) FindOpenIssue(repo models.Repo, title string) (*models.Issue, error) {
+	return nil, fmt.Errorf("issues are not supported for Azure DevOps")
+}
+
+// CreateIssue is not yet supported for Azure DevOps.
+func (g *AzureDevopsClient) CreateIssue(repo models.Repo, issue models.Issue) (models.Issue, error) {
+	return models.Issue{}, fmt.Errorf("issues are not supported for Azure DevOps")
+}
+
+// CreatePullRequest is not yet supported for Azure DevOps.
+func (g *AzureDevopsClient) CreatePullRequest(repo models.Repo, title string, body string, headBranch string, baseBranch string) (models.PullRequest, error) {
+	return models.PullRequest{}, fmt.Errorf("opening pull requests is not supported for Azure DevOps")
+}
+
+// GetPipeline is not yet supported for Azure DevOps.
+func (g *AzureDevopsClient) GetPipeline(repo models.Repo, pull models.PullRequest) (models.Pipeline, error) {
+	return models.Pipeline{}, fmt.Errorf("pipelines are not supported for Azure DevOps")
+}
+
 // GitStatusContextFromSrc parses an Atlantis formatted src string into a context suitable
 // for the status update API. In the AzureDevops branch policy UI there is a single string
 // field used to drive these contexts where all text preceding the final '/' character is
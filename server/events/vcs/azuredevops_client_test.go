@@ -233,6 +233,112 @@ func TestAzureDevopsClient_UpdateStatus(t *testing.T) {
 	}
 }
 
+// PropagateStatuses should re-post the latest Atlantis status of each context
+// that isn't on the latest iteration.
+func TestAzureDevopsClient_PropagateStatuses(t *testing.T) {
+	iterResponse := `{"count": 2, "value": [{"id": 1, "sourceRefCommit": { "commitId": "oldsha"}}, {"id": 2, "sourceRefCommit": { "commitId": "sha"}}]}`
+	statusesResponse := `{"count": 4, "value": [
+{"id": 1, "iterationId": 1, "context":{"genre":"Atlantis Bot","name":"plan"},"description":"planning","state":"pending"},
+{"id": 2, "iterationId": 1, "context":{"genre":"Atlantis Bot","name":"plan"},"description":"1/1 projects planned successfully.","state":"succeeded","targetUrl":"https://google.com"},
+{"id": 3, "iterationId": 2, "context":{"genre":"Atlantis Bot","name":"policy_check"},"description":"checked","state":"succeeded"},
+{"id": 4, "iterationId": 1, "context":{"genre":"ci","name":"build"},"description":"built","state":"succeeded"}
+]}`
+	var posted []string
+	testServer := httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.RequestURI {
+			case "/owner/project/_apis/git/repositories/repo/pullrequests/22/statuses?api-version=5.1-preview.1":
+				if r.Method == "GET" {
+					w.Write([]byte(statusesResponse)) // nolint: errcheck
+					return
+				}
+				defer r.Body.Close() // nolint: errcheck
+				body, err := ioutil.ReadAll(r.Body)
+				Ok(t, err)
+				posted = append(posted, string(body))
+				w.Write(body) // nolint: errcheck
+			case "/owner/project/_apis/git/repositories/repo/pullrequests/22/iterations?api-version=5.1":
+				w.Write([]byte(iterResponse)) // nolint: errcheck
+			case "/owner/project/_apis/git/pullrequests/22?api-version=5.1-preview.1":
+				w.Write([]byte(`{"supportsIterations": true}`)) // nolint: errcheck
+			default:
+				t.Errorf("got unexpected request at %q", r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		}))
+
+	testServerURL, err := url.Parse(testServer.URL)
+	Ok(t, err)
+	client, err := vcs.NewAzureDevopsClient(testServerURL.Host, "user", "token")
+	Ok(t, err)
+	defer disableSSLVerification()()
+
+	repo := models.Repo{
+		FullName: "owner/project/repo",
+		Owner:    "owner",
+		Name:     "repo",
+	}
+	propagated, err := client.PropagateStatuses(repo, models.PullRequest{
+		Num:        22,
+		BaseRepo:   repo,
+		HeadCommit: "sha",
+	})
+	Ok(t, err)
+	Equals(t, 1, propagated)
+	Equals(t, []string{
+		`{"context":{"genre":"Atlantis Bot","name":"plan"},"description":"1/1 projects planned successfully.","state":"succeeded","targetUrl":"https://google.com","iterationId":2}` + "\n",
+	}, posted)
+}
+
+// ClosePlanThreads should close the active threads of the Atlantis user's
+// plan comments and their continuations.
+func TestAzureDevopsClient_ClosePlanThreads(t *testing.T) {
+	threadsResponse := `{"count": 6, "value": [
+{"id": 1, "status": "active", "comments": [{"content": "Ran Plan for dir: ` + "`.`" + `", "author": {"uniqueName": "User"}}]},
+{"id": 2, "status": "active", "comments": [{"content": "Continued from previous comment.\n<details>", "author": {"uniqueName": "user"}}]},
+{"id": 3, "status": "active", "comments": [{"content": "Ran Apply for dir: ` + "`.`" + `", "author": {"uniqueName": "user"}}]},
+{"id": 4, "status": "active", "comments": [{"content": "Continued from previous comment.\n<details>", "author": {"uniqueName": "user"}}]},
+{"id": 5, "status": "fixed", "comments": [{"content": "Ran Plan for dir: ` + "`.`" + `", "author": {"uniqueName": "user"}}]},
+{"id": 6, "status": "active", "comments": [{"content": "Please plan again", "author": {"uniqueName": "reviewer"}}]}
+]}`
+	var patched []string
+	testServer := httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.RequestURI {
+			case "/owner/project/_apis/git/repositories/repo/pullRequests/22/threads?api-version=5.1":
+				w.Write([]byte(threadsResponse)) // nolint: errcheck
+			case "/owner/project/_apis/git/repositories/repo/pullRequests/22/threads/1?api-version=5.1",
+				"/owner/project/_apis/git/repositories/repo/pullRequests/22/threads/2?api-version=5.1":
+				Equals(t, "PATCH", r.Method)
+				defer r.Body.Close() // nolint: errcheck
+				body, err := ioutil.ReadAll(r.Body)
+				Ok(t, err)
+				Equals(t, `{"status":"fixed"}`+"\n", string(body))
+				patched = append(patched, r.RequestURI)
+				w.Write([]byte(`{}`)) // nolint: errcheck
+			default:
+				t.Errorf("got unexpected request at %q", r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		}))
+
+	testServerURL, err := url.Parse(testServer.URL)
+	Ok(t, err)
+	client, err := vcs.NewAzureDevopsClient(testServerURL.Host, "user", "token")
+	Ok(t, err)
+	defer disableSSLVerification()()
+
+	repo := models.Repo{
+		FullName: "owner/project/repo",
+		Owner:    "owner",
+		Name:     "repo",
+	}
+	closed, err := client.ClosePlanThreads(repo, 22)
+	Ok(t, err)
+	Equals(t, 2, closed)
+	Equals(t, 2, len(patched))
+}
+
 // GetModifiedFiles should make multiple requests if more than one page
 // and concat results.
 func TestAzureDevopsClient_GetModifiedFiles(t *testing.T) {
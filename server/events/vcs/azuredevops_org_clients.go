@@ -0,0 +1,243 @@
+package vcs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mcdafydd/go-azuredevops/azuredevops"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// AzureDevopsOrgClients routes calls to the client of the Azure DevOps
+// organization of the repo, ie. the first part of its full name, so one
+// server can serve several organizations with their own tokens.
+type AzureDevopsOrgClients struct {
+	// Orgs maps lowercase organization names to their clients.
+	Orgs map[string]*AzureDevopsClient
+	// Default is the client of the organizations that aren't in Orgs. If
+	// nil, they aren't supported.
+	Default *AzureDevopsClient
+}
+
+// ClientForOrg returns the client of the organization org.
+func (c *AzureDevopsOrgClients) ClientForOrg(org string) (*AzureDevopsClient, error) {
+	if client, ok := c.Orgs[strings.ToLower(org)]; ok {
+		return client, nil
+	}
+	if c.Default != nil {
+		return c.Default, nil
+	}
+	return nil, fmt.Errorf("Azure DevOps organization %q isn't configured", org)
+}
+
+// clientFor returns the client of the organization of repo.
+func (c *AzureDevopsOrgClients) clientFor(repo models.Repo) (*AzureDevopsClient, error) {
+	owner, _, _ := SplitAzureDevopsRepoFullName(repo.FullName)
+	return c.ClientForOrg(owner)
+}
+
+// TrackRateLimits records the rate limits of the responses to the requests
+// of every organization's client in limits.
+func (c *AzureDevopsOrgClients) TrackRateLimits(limits *RateLimits) {
+	for _, client := range c.clients() {
+		client.TrackRateLimits(limits)
+	}
+}
+
+// CheckConnection checks the connection of every organization's client.
+func (c *AzureDevopsOrgClients) CheckConnection() error {
+	for _, client := range c.clients() {
+		if err := client.CheckConnection(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clients returns the clients of every organization and the default one.
+func (c *AzureDevopsOrgClients) clients() []*AzureDevopsClient {
+	var clients []*AzureDevopsClient
+	if c.Default != nil {
+		clients = append(clients, c.Default)
+	}
+	for _, client := range c.Orgs {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+func (c *AzureDevopsOrgClients) GetModifiedFiles(repo models.Repo, pull models.PullRequest) ([]string, error) {
+	client, err := c.clientFor(repo)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetModifiedFiles(repo, pull)
+}
+
+func (c *AzureDevopsOrgClients) CreateComment(repo models.Repo, pullNum int, comment string, command string) error {
+	client, err := c.clientFor(repo)
+	if err != nil {
+		return err
+	}
+	return client.CreateComment(repo, pullNum, comment, command)
+}
+
+func (c *AzureDevopsOrgClients) CreateEditableComment(repo models.Repo, pullNum int, comment string) (int64, error) {
+	client, err := c.clientFor(repo)
+	if err != nil {
+		return 0, err
+	}
+	return client.CreateEditableComment(repo, pullNum, comment)
+}
+
+func (c *AzureDevopsOrgClients) EditComment(repo models.Repo, pullNum int, commentID int64, comment string) error {
+	client, err := c.clientFor(repo)
+	if err != nil {
+		return err
+	}
+	return client.EditComment(repo, pullNum, commentID, comment)
+}
+
+func (c *AzureDevopsOrgClients) HidePrevCommandComments(repo models.Repo, pullNum int, command string) error {
+	client, err := c.clientFor(repo)
+	if err != nil {
+		return err
+	}
+	return client.HidePrevCommandComments(repo, pullNum, command)
+}
+
+func (c *AzureDevopsOrgClients) PullIsApproved(repo models.Repo, pull models.PullRequest) (bool, error) {
+	client, err := c.clientFor(repo)
+	if err != nil {
+		return false, err
+	}
+	return client.PullIsApproved(repo, pull)
+}
+
+func (c *AzureDevopsOrgClients) GetApprovals(repo models.Repo, pull models.PullRequest) ([]models.Approval, error) {
+	client, err := c.clientFor(repo)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetApprovals(repo, pull)
+}
+
+func (c *AzureDevopsOrgClients) PullIsMergeable(repo models.Repo, pull models.PullRequest) (bool, error) {
+	client, err := c.clientFor(repo)
+	if err != nil {
+		return false, err
+	}
+	return client.PullIsMergeable(repo, pull)
+}
+
+func (c *AzureDevopsOrgClients) PullIsClosed(repo models.Repo, pull models.PullRequest) (bool, error) {
+	client, err := c.clientFor(repo)
+	if err != nil {
+		return false, err
+	}
+	return client.PullIsClosed(repo, pull)
+}
+
+func (c *AzureDevopsOrgClients) GetPullRequest(repo models.Repo, num int) (*azuredevops.GitPullRequest, error) {
+	client, err := c.clientFor(repo)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetPullRequest(repo, num)
+}
+
+func (c *AzureDevopsOrgClients) UpdateStatus(repo models.Repo, pull models.PullRequest, state models.CommitStatus, src string, description string, url string) error {
+	client, err := c.clientFor(repo)
+	if err != nil {
+		return err
+	}
+	return client.UpdateStatus(repo, pull, state, src, description, url)
+}
+
+func (c *AzureDevopsOrgClients) PropagateStatuses(repo models.Repo, pull models.PullRequest) (int, error) {
+	client, err := c.clientFor(repo)
+	if err != nil {
+		return 0, err
+	}
+	return client.PropagateStatuses(repo, pull)
+}
+
+func (c *AzureDevopsOrgClients) ClosePlanThreads(repo models.Repo, pullNum int) (int, error) {
+	client, err := c.clientFor(repo)
+	if err != nil {
+		return 0, err
+	}
+	return client.ClosePlanThreads(repo, pullNum)
+}
+
+func (c *AzureDevopsOrgClients) MergePull(pull models.PullRequest, pullOptions models.PullRequestOptions) error {
+	client, err := c.clientFor(pull.BaseRepo)
+	if err != nil {
+		return err
+	}
+	return client.MergePull(pull, pullOptions)
+}
+
+func (c *AzureDevopsOrgClients) MarkdownPullLink(pull models.PullRequest) (string, error) {
+	client, err := c.clientFor(pull.BaseRepo)
+	if err != nil {
+		return "", err
+	}
+	return client.MarkdownPullLink(pull)
+}
+
+func (c *AzureDevopsOrgClients) SupportsSingleFileDownload(repo models.Repo) bool {
+	client, err := c.clientFor(repo)
+	if err != nil {
+		return false
+	}
+	return client.SupportsSingleFileDownload(repo)
+}
+
+func (c *AzureDevopsOrgClients) DownloadRepoConfigFile(pull models.PullRequest) (bool, []byte, error) {
+	client, err := c.clientFor(pull.BaseRepo)
+	if err != nil {
+		return false, nil, err
+	}
+	return client.DownloadRepoConfigFile(pull)
+}
+
+func (c *AzureDevopsOrgClients) GetTeamNamesForUser(repo models.Repo, user models.User, teams []string) ([]string, error) {
+	client, err := c.clientFor(repo)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetTeamNamesForUser(repo, user, teams)
+}
+
+func (c *AzureDevopsOrgClients) FindOpenIssue(repo models.Repo, title string) (*models.Issue, error) {
+	client, err := c.clientFor(repo)
+	if err != nil {
+		return nil, err
+	}
+	return client.FindOpenIssue(repo, title)
+}
+
+func (c *AzureDevopsOrgClients) CreateIssue(repo models.Repo, issue models.Issue) (models.Issue, error) {
+	client, err := c.clientFor(repo)
+	if err != nil {
+		return models.Issue{}, err
+	}
+	return client.CreateIssue(repo, issue)
+}
+
+func (c *AzureDevopsOrgClients) CreatePullRequest(repo models.Repo, title string, body string, headBranch string, baseBranch string) (models.PullRequest, error) {
+	client, err := c.clientFor(repo)
+	if err != nil {
+		return models.PullRequest{}, err
+	}
+	return client.CreatePullRequest(repo, title, body, headBranch, baseBranch)
+}
+
+func (c *AzureDevopsOrgClients) GetPipeline(repo models.Repo, pull models.PullRequest) (models.Pipeline, error) {
+	client, err := c.clientFor(repo)
+	if err != nil {
+		return models.Pipeline{}, err
+	}
+	return client.GetPipeline(repo, pull)
+}
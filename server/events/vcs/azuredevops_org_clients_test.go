@@ -0,0 +1,42 @@
+package vcs_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestAzureDevopsOrgClients_ClientForOrg(t *testing.T) {
+	orgClient, err := vcs.NewAzureDevopsClient("dev.azure.com", "org-user", "org-token")
+	Ok(t, err)
+	defaultClient, err := vcs.NewAzureDevopsClient("dev.azure.com", "user", "token")
+	Ok(t, err)
+	clients := &vcs.AzureDevopsOrgClients{
+		Orgs:    map[string]*vcs.AzureDevopsClient{"org": orgClient},
+		Default: defaultClient,
+	}
+
+	client, err := clients.ClientForOrg("Org")
+	Ok(t, err)
+	Assert(t, client == orgClient, "expected the org's client")
+	client, err = clients.ClientForOrg("other")
+	Ok(t, err)
+	Assert(t, client == defaultClient, "expected the default client")
+
+	clients.Default = nil
+	_, err = clients.ClientForOrg("other")
+	ErrEquals(t, `Azure DevOps organization "other" isn't configured`, err)
+}
+
+func TestAzureDevopsOrgClients_NotConfigured(t *testing.T) {
+	clients := &vcs.AzureDevopsOrgClients{}
+	repo := models.Repo{FullName: "other/project/repo"}
+
+	err := clients.CreateComment(repo, 1, "comment", "")
+	ErrEquals(t, `Azure DevOps organization "other" isn't configured`, err)
+	_, err = clients.MarkdownPullLink(models.PullRequest{Num: 1, BaseRepo: repo})
+	ErrEquals(t, `Azure DevOps organization "other" isn't configured`, err)
+	Equals(t, false, clients.SupportsSingleFileDownload(repo))
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs/common"
 	validator "gopkg.in/go-playground/validator.v9"
 )
 
@@ -19,6 +20,12 @@ type Client struct {
 	Password    string
 	BaseURL     string
 	AtlantisURL string
+	// MaxCommentLength is the maximum number of chars of a comment. Longer
+	// comments are split. If 0, they aren't split.
+	MaxCommentLength int
+	// Credentials, if set, authenticate requests instead of Username and
+	// Password.
+	Credentials *Credentials
 }
 
 // NewClient builds a bitbucket cloud client. atlantisURL is the
@@ -87,8 +94,23 @@ func (b *Client) GetModifiedFiles(repo models.Repo, pull models.PullRequest) ([]
 // CreateComment creates a comment on the merge request.
 func (b *Client) CreateComment(repo models.Repo, pullNum int, comment string, command string) error {
 	// NOTE: I tried to find the maximum size of a comment for bitbucket.org but
-	// I got up to 200k chars without issue so for now I'm not going to bother
-	// to detect this.
+	// I got up to 200k chars without issue so comments are only split if
+	// MaxCommentLength is set.
+	comments := []string{comment}
+	if b.MaxCommentLength > 0 {
+		sepEnd := "\n```\n**Warning**: Output length greater than max comment size. Continued in next comment."
+		sepStart := "Continued from previous comment.\n```diff\n"
+		comments = common.SplitComment(comment, b.MaxCommentLength, sepEnd, sepStart)
+	}
+	for _, c := range comments {
+		if err := b.postComment(repo, pullNum, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Client) postComment(repo models.Repo, pullNum int, comment string) error {
 	bodyBytes, err := json.Marshal(map[string]map[string]string{"content": {
 		"raw": comment,
 	}})
@@ -100,6 +122,16 @@ func (b *Client) CreateComment(repo models.Repo, pullNum int, comment string, co
 	return err
 }
 
+// CreateEditableComment is not yet supported for Bitbucket Cloud.
+func (b *Client) CreateEditableComment(repo models.Repo, pullNum int, comment string) (int64, error) {
+	return 0, fmt.Errorf("editing comments is not supported for Bitbucket Cloud")
+}
+
+// EditComment is not yet supported for Bitbucket Cloud.
+func (b *Client) EditComment(repo models.Repo, pullNum int, commentID int64, comment string) error {
+	return fmt.Errorf("editing comments is not supported for Bitbucket Cloud")
+}
+
 func (b *Client) HidePrevCommandComments(repo models.Repo, pullNum int, command string) error {
 	return nil
 }
@@ -129,6 +161,11 @@ func (b *Client) PullIsApproved(repo models.Repo, pull models.PullRequest) (bool
 	return false, nil
 }
 
+// GetApprovals is not yet supported for Bitbucket Cloud.
+func (b *Client) GetApprovals(repo models.Repo, pull models.PullRequest) ([]models.Approval, error) {
+	return nil, fmt.Errorf("listing approvals is not supported for Bitbucket Cloud")
+}
+
 // PullIsMergeable returns true if the merge request has no conflicts and can be merged.
 func (b *Client) PullIsMergeable(repo models.Repo, pull models.PullRequest) (bool, error) {
 	nextPageURL := fmt.Sprintf("%s/2.0/repositories/%s/pullrequests/%d/diffstat", b.BaseURL, repo.FullName, pull.Num)
@@ -160,6 +197,26 @@ func (b *Client) PullIsMergeable(repo models.Repo, pull models.PullRequest) (boo
 	return true, nil
 }
 
+// PullIsClosed returns true if the pull request was declined, merged or
+// superseded.
+func (b *Client) PullIsClosed(repo models.Repo, pull models.PullRequest) (bool, error) {
+	path := fmt.Sprintf("%s/2.0/repositories/%s/pullrequests/%d", b.BaseURL, repo.FullName, pull.Num)
+	resp, err := b.makeRequest("GET", path, nil)
+	if err != nil {
+		return false, err
+	}
+	var pullResp struct {
+		State *string `json:"state,omitempty" validate:"required"`
+	}
+	if err := json.Unmarshal(resp, &pullResp); err != nil {
+		return false, errors.Wrapf(err, "Could not parse response %q", string(resp))
+	}
+	if err := validator.New().Struct(pullResp); err != nil {
+		return false, errors.Wrapf(err, "API response %q was missing fields", string(resp))
+	}
+	return *pullResp.State != "OPEN", nil
+}
+
 // UpdateStatus updates the status of a commit.
 func (b *Client) UpdateStatus(repo models.Repo, pull models.PullRequest, status models.CommitStatus, src string, description string, url string) error {
 	bbState := "FAILED"
@@ -196,7 +253,15 @@ func (b *Client) UpdateStatus(repo models.Repo, pull models.PullRequest, status
 // MergePull merges the pull request.
 func (b *Client) MergePull(pull models.PullRequest, pullOptions models.PullRequestOptions) error {
 	path := fmt.Sprintf("%s/2.0/repositories/%s/pullrequests/%d/merge", b.BaseURL, pull.BaseRepo.FullName, pull.Num)
-	_, err := b.makeRequest("POST", path, nil)
+	var body io.Reader
+	if pullOptions.CommitMessage != "" {
+		bodyBytes, err := json.Marshal(map[string]string{"message": pullOptions.CommitMessage})
+		if err != nil {
+			return errors.Wrap(err, "json encoding")
+		}
+		body = bytes.NewBuffer(bodyBytes)
+	}
+	_, err := b.makeRequest("POST", path, body)
 	return err
 }
 
@@ -211,7 +276,13 @@ func (b *Client) prepRequest(method string, path string, body io.Reader) (*http.
 	if err != nil {
 		return nil, err
 	}
-	req.SetBasicAuth(b.Username, b.Password)
+	if b.Credentials != nil {
+		if err := b.Credentials.Authorize(req); err != nil {
+			return nil, errors.Wrap(err, "authenticating")
+		}
+	} else {
+		req.SetBasicAuth(b.Username, b.Password)
+	}
 	if body != nil {
 		req.Header.Add("Content-Type", "application/json")
 	}
@@ -244,6 +315,19 @@ func (b *Client) makeRequest(method string, path string, reqBody io.Reader) ([]b
 	return respBody, nil
 }
 
+// CheckConnection returns an error if Bitbucket can't be reached or rejects
+// the credentials.
+func (b *Client) CheckConnection() error {
+	// Access tokens don't belong to a user so they can only be checked
+	// against an endpoint that doesn't need one.
+	if b.Credentials != nil && b.Credentials.Type == AccessTokenAuth {
+		_, err := b.makeRequest("GET", fmt.Sprintf("%s/2.0/repositories?pagelen=1", b.BaseURL), nil)
+		return err
+	}
+	_, err := b.makeRequest("GET", fmt.Sprintf("%s/2.0/user", b.BaseURL), nil)
+	return err
+}
+
 func (b *Client) SupportsSingleFileDownload(models.Repo) bool {
 	return false
 }
@@ -254,3 +338,28 @@ func (b *Client) SupportsSingleFileDownload(models.Repo) bool {
 func (b *Client) DownloadRepoConfigFile(pull models.PullRequest) (bool, []byte, error) {
 	return false, []byte{}, fmt.Errorf("Not Implemented")
 }
+
+// GetTeamNamesForUser is not yet supported for Bitbucket Cloud.
+func (b *Client) GetTeamNamesForUser(repo models.Repo, user models.User, teams []string) ([]string, error) {
+	return nil, fmt.Errorf("team membership is not supported for Bitbucket Cloud")
+}
+
+// FindOpenIssue is not yet supported for Bitbucket Cloud.
+func (b *Client) FindOpenIssue(repo models.Repo, title string) (*models.Issue, error) {
+	return nil, fmt.Errorf("issues are not supported for Bitbucket Cloud")
+}
+
+// CreateIssue is not yet supported for Bitbucket Cloud.
+func (b *Client) CreateIssue(repo models.Repo, issue models.Issue) (models.Issue, error) {
+	return models.Issue{}, fmt.Errorf("issues are not supported for Bitbucket Cloud")
+}
+
+// CreatePullRequest is not yet supported for Bitbucket Cloud.
+func (b *Client) CreatePullRequest(repo models.Repo, title string, body string, headBranch string, baseBranch string) (models.PullRequest, error) {
+	return models.PullRequest{}, fmt.Errorf("opening pull requests is not supported for Bitbucket Cloud")
+}
+
+// GetPipeline is not yet supported for Bitbucket Cloud.
+func (b *Client) GetPipeline(repo models.Repo, pull models.PullRequest) (models.Pipeline, error) {
+	return models.Pipeline{}, fmt.Errorf("pipelines are not supported for Bitbucket Cloud")
+}
@@ -352,3 +352,42 @@ func TestClient_MarkdownPullLink(t *testing.T) {
 	exp := "#1"
 	Equals(t, exp, s)
 }
+
+func TestClient_CheckConnection(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		if r.RequestURI != "/2.0/user" || user != "user" || pass != "pass" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"username": "user"}`)) // nolint: errcheck
+	}))
+	defer testServer.Close()
+
+	client := bitbucketcloud.NewClient(http.DefaultClient, "user", "pass", "runatlantis.io")
+	client.BaseURL = testServer.URL
+	Ok(t, client.CheckConnection())
+
+	client = bitbucketcloud.NewClient(http.DefaultClient, "user", "wrong", "runatlantis.io")
+	client.BaseURL = testServer.URL
+	ErrContains(t, "unexpected status code: 401", client.CheckConnection())
+}
+
+func TestClient_CheckConnectionAccessToken(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.RequestURI != "/2.0/repositories?pagelen=1" || r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"values": []}`)) // nolint: errcheck
+	}))
+	defer testServer.Close()
+
+	client := bitbucketcloud.NewClient(http.DefaultClient, "user", "token", "runatlantis.io")
+	client.BaseURL = testServer.URL
+	client.Credentials = &bitbucketcloud.Credentials{Type: bitbucketcloud.AccessTokenAuth, Password: "token"}
+	Ok(t, client.CheckConnection())
+
+	client.Credentials.Password = "wrong"
+	ErrContains(t, "unexpected status code: 401", client.CheckConnection())
+}
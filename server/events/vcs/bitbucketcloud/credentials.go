@@ -0,0 +1,169 @@
+package bitbucketcloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The ways Atlantis can authenticate to Bitbucket Cloud.
+const (
+	// AppPasswordAuth authenticates with a username and app password, or an
+	// Atlassian account's email and API token.
+	AppPasswordAuth = "app-password"
+	// AccessTokenAuth authenticates with a repository, project or workspace
+	// access token.
+	AccessTokenAuth = "access-token"
+	// OAuthAuth authenticates with access tokens of an OAuth consumer, which
+	// expire after two hours and are refreshed.
+	OAuthAuth = "oauth"
+)
+
+// AuthTypes are the supported ways to authenticate to Bitbucket Cloud.
+var AuthTypes = []string{AppPasswordAuth, AccessTokenAuth, OAuthAuth}
+
+// TokenURL is where OAuth consumers get access tokens.
+const TokenURL = "https://bitbucket.org/site/oauth2/access_token"
+
+// TokenGitUser is the git user of access tokens and OAuth access tokens.
+const TokenGitUser = "x-token-auth"
+
+// tokenRefreshMargin is how long before OAuth access tokens expire that
+// they're refreshed, so they don't expire during clones.
+const tokenRefreshMargin = 5 * time.Minute
+
+// Credentials authenticate requests to Bitbucket Cloud. Its methods are safe
+// for concurrent use.
+type Credentials struct {
+	// Type is how to authenticate, one of AuthTypes.
+	Type string
+	// Username and Password are the username and app password, or the
+	// account's email and API token, for AppPasswordAuth. Password is the
+	// access token for AccessTokenAuth.
+	Username string
+	Password string
+	// OAuthKey and OAuthSecret are the key and secret of the OAuth consumer
+	// for OAuthAuth.
+	OAuthKey    string
+	OAuthSecret string
+	// TokenURL is where OAuth access tokens are requested. It defaults to
+	// TokenURL.
+	TokenURL   string
+	HTTPClient *http.Client
+	// Now returns the current time. It's only overridden in tests.
+	Now func() time.Time
+
+	mutex        sync.Mutex
+	accessToken  string
+	refreshToken string
+	expiresAt    time.Time
+}
+
+// Authorize authenticates req.
+func (c *Credentials) Authorize(req *http.Request) error {
+	if c.Type == AppPasswordAuth || c.Type == "" {
+		req.SetBasicAuth(c.Username, c.Password)
+		return nil
+	}
+	token, err := c.Token()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// GitCredentials returns the user and password that git authenticates with
+// over HTTPS.
+func (c *Credentials) GitCredentials() (string, string, error) {
+	if c.Type == AppPasswordAuth || c.Type == "" {
+		return c.Username, c.Password, nil
+	}
+	token, err := c.Token()
+	return TokenGitUser, token, err
+}
+
+// Token returns the access token. OAuth access tokens are refreshed if they
+// expire soon.
+func (c *Credentials) Token() (string, error) {
+	if c.Type != OAuthAuth {
+		return c.Password, nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.accessToken != "" && c.now().Add(tokenRefreshMargin).Before(c.expiresAt) {
+		return c.accessToken, nil
+	}
+	var err error
+	if c.refreshToken != "" {
+		err = c.requestToken(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {c.refreshToken}})
+		if err == nil {
+			return c.accessToken, nil
+		}
+	}
+	// Refresh tokens of client credentials grants can't be revoked but the
+	// consumer can always get a new access token.
+	if err := c.requestToken(url.Values{"grant_type": {"client_credentials"}}); err != nil {
+		return "", err
+	}
+	return c.accessToken, nil
+}
+
+// requestToken requests an OAuth access token with the grant in form.
+func (c *Credentials) requestToken(form url.Values) error {
+	tokenURL := c.TokenURL
+	if tokenURL == "" {
+		tokenURL = TokenURL
+	}
+	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return errors.Wrap(err, "constructing request")
+	}
+	req.SetBasicAuth(c.OAuthKey, c.OAuthSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "requesting OAuth access token")
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "reading OAuth access token response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("requesting OAuth access token with %s grant unexpected status code: %d, body: %s", form.Get("grant_type"), resp.StatusCode, string(respBody))
+	}
+	var token struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(respBody, &token); err != nil {
+		return errors.Wrapf(err, "Could not parse response %q", string(respBody))
+	}
+	if token.AccessToken == "" {
+		return errors.New("OAuth access token response had no access token")
+	}
+	c.accessToken = token.AccessToken
+	c.refreshToken = token.RefreshToken
+	c.expiresAt = c.now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return nil
+}
+
+func (c *Credentials) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,108 @@
+package bitbucketcloud_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/vcs/bitbucketcloud"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestCredentials_AppPassword(t *testing.T) {
+	c := &bitbucketcloud.Credentials{Type: bitbucketcloud.AppPasswordAuth, Username: "user", Password: "pass"}
+	req, err := http.NewRequest("GET", "https://api.bitbucket.org/2.0/user", nil)
+	Ok(t, err)
+	Ok(t, c.Authorize(req))
+	user, pass, ok := req.BasicAuth()
+	Assert(t, ok, "exp basic auth")
+	Equals(t, "user", user)
+	Equals(t, "pass", pass)
+
+	user, pass, err = c.GitCredentials()
+	Ok(t, err)
+	Equals(t, "user", user)
+	Equals(t, "pass", pass)
+}
+
+func TestCredentials_AccessToken(t *testing.T) {
+	c := &bitbucketcloud.Credentials{Type: bitbucketcloud.AccessTokenAuth, Username: "user", Password: "token"}
+	req, err := http.NewRequest("GET", "https://api.bitbucket.org/2.0/user", nil)
+	Ok(t, err)
+	Ok(t, c.Authorize(req))
+	Equals(t, "Bearer token", req.Header.Get("Authorization"))
+
+	user, pass, err := c.GitCredentials()
+	Ok(t, err)
+	Equals(t, "x-token-auth", user)
+	Equals(t, "token", pass)
+}
+
+func TestCredentials_OAuth(t *testing.T) {
+	var grants []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, secret, _ := r.BasicAuth()
+		Equals(t, "key:secret", key+":"+secret)
+		Ok(t, r.ParseForm())
+		grant := r.PostForm.Get("grant_type")
+		grants = append(grants, grant)
+		switch grant {
+		case "client_credentials":
+			fmt.Fprintf(w, `{"access_token": "token%d", "refresh_token": "refresh", "expires_in": 7200}`, len(grants))
+		case "refresh_token":
+			Equals(t, "refresh", r.PostForm.Get("refresh_token"))
+			if len(grants) > 2 {
+				http.Error(w, `{"error": "invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintf(w, `{"access_token": "token%d", "refresh_token": "refresh", "expires_in": 7200}`, len(grants))
+		}
+	}))
+	defer testServer.Close()
+
+	now := time.Now()
+	c := &bitbucketcloud.Credentials{
+		Type:        bitbucketcloud.OAuthAuth,
+		OAuthKey:    "key",
+		OAuthSecret: "secret",
+		TokenURL:    testServer.URL,
+		Now:         func() time.Time { return now },
+	}
+	token, err := c.Token()
+	Ok(t, err)
+	Equals(t, "token1", token)
+
+	// The token is reused until it's about to expire.
+	now = now.Add(time.Hour)
+	user, token, err := c.GitCredentials()
+	Ok(t, err)
+	Equals(t, "x-token-auth", user)
+	Equals(t, "token1", token)
+
+	// Then it's refreshed.
+	now = now.Add(58 * time.Minute)
+	token, err = c.Token()
+	Ok(t, err)
+	Equals(t, "token2", token)
+
+	// If it can't be refreshed, a new one is requested.
+	now = now.Add(2 * time.Hour)
+	req, err := http.NewRequest("GET", "https://api.bitbucket.org/2.0/user", nil)
+	Ok(t, err)
+	Ok(t, c.Authorize(req))
+	Equals(t, "Bearer token4", req.Header.Get("Authorization"))
+	Equals(t, []string{"client_credentials", "refresh_token", "refresh_token", "client_credentials"}, grants)
+}
+
+func TestCredentials_OAuthErr(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "invalid_client"}`, http.StatusUnauthorized)
+	}))
+	defer testServer.Close()
+
+	c := &bitbucketcloud.Credentials{Type: bitbucketcloud.OAuthAuth, OAuthKey: "key", OAuthSecret: "wrong", TokenURL: testServer.URL}
+	_, err := c.Token()
+	ErrEquals(t, "requesting OAuth access token with client_credentials grant unexpected status code: 401, body: {\"error\": \"invalid_client\"}\n", err)
+}
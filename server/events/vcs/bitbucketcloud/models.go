@@ -53,7 +53,18 @@ type PullRequest struct {
 	Links        *Links        `json:"links,omitempty" validate:"required"`
 	State        *string       `json:"state,omitempty" validate:"required"`
 	Author       *Author       `jsonN:"author,omitempty" validate:"required"`
+	Title        *string       `json:"title,omitempty"`
 }
+
+// GetTitle returns the title of the pull request or an empty string if it's
+// not set.
+func (p PullRequest) GetTitle() string {
+	if p.Title == nil {
+		return ""
+	}
+	return *p.Title
+}
+
 type Links struct {
 	HTML *Link `json:"html,omitempty" validate:"required"`
 }
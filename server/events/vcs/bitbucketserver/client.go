@@ -28,6 +28,9 @@ type Client struct {
 	Password    string
 	BaseURL     string
 	AtlantisURL string
+	// MaxCommentLength is the maximum number of chars of a comment. Longer
+	// comments are split. If 0, maxCommentLength is used.
+	MaxCommentLength int
 }
 
 // NewClient builds a bitbucket cloud client. Returns an error if the baseURL is
@@ -132,7 +135,11 @@ func (b *Client) GetProjectKey(repoName string, cloneURL string) (string, error)
 func (b *Client) CreateComment(repo models.Repo, pullNum int, comment string, command string) error {
 	sepEnd := "\n```\n**Warning**: Output length greater than max comment size. Continued in next comment."
 	sepStart := "Continued from previous comment.\n```diff\n"
-	comments := common.SplitComment(comment, maxCommentLength, sepEnd, sepStart)
+	maxLength := maxCommentLength
+	if b.MaxCommentLength > 0 {
+		maxLength = b.MaxCommentLength
+	}
+	comments := common.SplitComment(comment, maxLength, sepEnd, sepStart)
 	for _, c := range comments {
 		if err := b.postComment(repo, pullNum, c); err != nil {
 			return err
@@ -141,6 +148,16 @@ func (b *Client) CreateComment(repo models.Repo, pullNum int, comment string, co
 	return nil
 }
 
+// CreateEditableComment is not yet supported for Bitbucket Server.
+func (b *Client) CreateEditableComment(repo models.Repo, pullNum int, comment string) (int64, error) {
+	return 0, fmt.Errorf("editing comments is not supported for Bitbucket Server")
+}
+
+// EditComment is not yet supported for Bitbucket Server.
+func (b *Client) EditComment(repo models.Repo, pullNum int, commentID int64, comment string) error {
+	return fmt.Errorf("editing comments is not supported for Bitbucket Server")
+}
+
 func (b *Client) HidePrevCommandComments(repo models.Repo, pullNum int, command string) error {
 	return nil
 }
@@ -186,6 +203,11 @@ func (b *Client) PullIsApproved(repo models.Repo, pull models.PullRequest) (bool
 	return false, nil
 }
 
+// GetApprovals is not yet supported for Bitbucket Server.
+func (b *Client) GetApprovals(repo models.Repo, pull models.PullRequest) ([]models.Approval, error) {
+	return nil, fmt.Errorf("listing approvals is not supported for Bitbucket Server")
+}
+
 // PullIsMergeable returns true if the merge request has no conflicts and can be merged.
 func (b *Client) PullIsMergeable(repo models.Repo, pull models.PullRequest) (bool, error) {
 	projectKey, err := b.GetProjectKey(repo.Name, repo.SanitizedCloneURL)
@@ -210,6 +232,29 @@ func (b *Client) PullIsMergeable(repo models.Repo, pull models.PullRequest) (boo
 	return false, nil
 }
 
+// PullIsClosed returns true if the pull request was declined or merged.
+func (b *Client) PullIsClosed(repo models.Repo, pull models.PullRequest) (bool, error) {
+	projectKey, err := b.GetProjectKey(repo.Name, repo.SanitizedCloneURL)
+	if err != nil {
+		return false, err
+	}
+	path := fmt.Sprintf("%s/rest/api/1.0/projects/%s/repos/%s/pull-requests/%d", b.BaseURL, projectKey, repo.Name, pull.Num)
+	resp, err := b.makeRequest("GET", path, nil)
+	if err != nil {
+		return false, err
+	}
+	var pullResp struct {
+		State *string `json:"state,omitempty" validate:"required"`
+	}
+	if err := json.Unmarshal(resp, &pullResp); err != nil {
+		return false, errors.Wrapf(err, "Could not parse response %q", string(resp))
+	}
+	if err := validator.New().Struct(pullResp); err != nil {
+		return false, errors.Wrapf(err, "API response %q was missing fields", string(resp))
+	}
+	return *pullResp.State != "OPEN", nil
+}
+
 // UpdateStatus updates the status of a commit.
 func (b *Client) UpdateStatus(repo models.Repo, pull models.PullRequest, status models.CommitStatus, src string, description string, url string) error {
 	bbState := "FAILED"
@@ -312,6 +357,13 @@ func (b *Client) makeRequest(method string, path string, reqBody io.Reader) ([]b
 	return respBody, nil
 }
 
+// CheckConnection returns an error if Bitbucket can't be reached or rejects
+// the credentials.
+func (b *Client) CheckConnection() error {
+	_, err := b.makeRequest("GET", fmt.Sprintf("%s/rest/api/1.0/users/%s", b.BaseURL, url.PathEscape(b.Username)), nil)
+	return err
+}
+
 func (b *Client) SupportsSingleFileDownload(repo models.Repo) bool {
 	return false
 }
@@ -322,3 +374,72 @@ func (b *Client) SupportsSingleFileDownload(repo models.Repo) bool {
 func (b *Client) DownloadRepoConfigFile(pull models.PullRequest) (bool, []byte, error) {
 	return false, []byte{}, fmt.Errorf("not implemented")
 }
+
+// GetTeamNamesForUser is not yet supported for Bitbucket Server.
+func (b *Client) GetTeamNamesForUser(repo models.Repo, user models.User, teams []string) ([]string, error) {
+	return nil, fmt.Errorf("team membership is not supported for Bitbucket Server")
+}
+
+// FindOpenIssue is not yet supported for Bitbucket Server.
+func (b *Client) FindOpenIssue(repo models.Repo, title string) (*models.Issue, error) {
+	return nil, fmt.Errorf("issues are not supported for Bitbucket Server")
+}
+
+// CreateIssue is not yet supported for Bitbucket Server.
+func (b *Client) CreateIssue(repo models.Repo, issue models.Issue) (models.Issue, error) {
+	return models.Issue{}, fmt.Errorf("issues are not supported for Bitbucket Server")
+}
+
+// CreatePullRequest is not yet supported for Bitbucket Server.
+func (b *Client) CreatePullRequest(repo models.Repo, title string, body string, headBranch string, baseBranch string) (models.PullRequest, error) {
+	return models.PullRequest{}, fmt.Errorf("opening pull requests is not supported for Bitbucket Server")
+}
+
+// CreateInsightReport creates, or replaces, the Code Insights report key on
+// commit. Code Insights requires Bitbucket Server 5.15.
+func (b *Client) CreateInsightReport(repo models.Repo, commit string, key string, report InsightReport) error {
+	path, err := b.insightReportPath(repo, commit, key)
+	if err != nil {
+		return err
+	}
+	bodyBytes, err := json.Marshal(report)
+	if err != nil {
+		return errors.Wrap(err, "json encoding")
+	}
+	_, err = b.makeRequest("PUT", path, bytes.NewBuffer(bodyBytes))
+	return err
+}
+
+// CreateInsightAnnotations replaces the annotations of the Code Insights
+// report key on commit, which must already exist, with annotations.
+func (b *Client) CreateInsightAnnotations(repo models.Repo, commit string, key string, annotations []InsightAnnotation) error {
+	path, err := b.insightReportPath(repo, commit, key)
+	if err != nil {
+		return err
+	}
+	if _, err := b.makeRequest("DELETE", path+"/annotations", nil); err != nil {
+		return err
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+	bodyBytes, err := json.Marshal(map[string]interface{}{"annotations": annotations})
+	if err != nil {
+		return errors.Wrap(err, "json encoding")
+	}
+	_, err = b.makeRequest("POST", path+"/annotations", bytes.NewBuffer(bodyBytes))
+	return err
+}
+
+func (b *Client) insightReportPath(repo models.Repo, commit string, key string) (string, error) {
+	projectKey, err := b.GetProjectKey(repo.Name, repo.SanitizedCloneURL)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/rest/insights/1.0/projects/%s/repos/%s/commits/%s/reports/%s", b.BaseURL, projectKey, repo.Name, commit, url.PathEscape(key)), nil
+}
+
+// GetPipeline is not yet supported for Bitbucket Server.
+func (b *Client) GetPipeline(repo models.Repo, pull models.PullRequest) (models.Pipeline, error) {
+	return models.Pipeline{}, fmt.Errorf("pipelines are not supported for Bitbucket Server")
+}
@@ -191,3 +191,38 @@ func TestClient_MarkdownPullLink(t *testing.T) {
 	exp := "#1"
 	Equals(t, exp, s)
 }
+
+func TestClient_CreateInsightReport(t *testing.T) {
+	var requests []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		Ok(t, err)
+		requests = append(requests, r.Method+" "+r.RequestURI+" "+string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer testServer.Close()
+
+	client, err := bitbucketserver.NewClient(http.DefaultClient, "user", "pass", testServer.URL, "runatlantis.io")
+	Ok(t, err)
+	repo := models.Repo{
+		Name:              "repo",
+		SanitizedCloneURL: fmt.Sprintf("%s/scm/ow/repo.git", testServer.URL),
+	}
+
+	err = client.CreateInsightReport(repo, "abc123", "key", bitbucketserver.InsightReport{Title: "title", Result: bitbucketserver.InsightPass})
+	Ok(t, err)
+	err = client.CreateInsightAnnotations(repo, "abc123", "key", []bitbucketserver.InsightAnnotation{
+		{Path: "main.tf", Line: 1, Message: "msg", Severity: "HIGH"},
+	})
+	Ok(t, err)
+	err = client.CreateInsightAnnotations(repo, "abc123", "key", nil)
+	Ok(t, err)
+
+	path := "/rest/insights/1.0/projects/ow/repos/repo/commits/abc123/reports/key"
+	Equals(t, []string{
+		"PUT " + path + ` {"title":"title","result":"PASS"}`,
+		"DELETE " + path + "/annotations ",
+		"POST " + path + `/annotations {"annotations":[{"path":"main.tf","line":1,"message":"msg","severity":"HIGH"}]}`,
+		"DELETE " + path + "/annotations ",
+	}, requests)
+}
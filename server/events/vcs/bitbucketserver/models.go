@@ -32,6 +32,16 @@ type PullRequest struct {
 	Reviewers []struct {
 		Approved *bool `json:"approved,omitempty" validate:"required"`
 	} `json:"reviewers,omitempty" validate:"required"`
+	Title *string `json:"title,omitempty"`
+}
+
+// GetTitle returns the title of the pull request or an empty string if it's
+// not set.
+func (p PullRequest) GetTitle() string {
+	if p.Title == nil {
+		return ""
+	}
+	return *p.Title
 }
 
 type Ref struct {
@@ -75,3 +85,38 @@ type MergeStatus struct {
 	CanMerge   *bool `json:"canMerge,omitempty" validate:"required"`
 	Conflicted *bool `json:"conflicted,omitempty" validate:"required"`
 }
+
+// InsightReport is a Code Insights report on a commit.
+// See https://docs.atlassian.com/bitbucket-server/rest/latest/bitbucket-code-insights-rest.html.
+type InsightReport struct {
+	Title    string `json:"title"`
+	Details  string `json:"details,omitempty"`
+	Result   string `json:"result,omitempty"`
+	Reporter string `json:"reporter,omitempty"`
+	Link     string `json:"link,omitempty"`
+	// Data are shown on the report's summary card.
+	Data []InsightData `json:"data,omitempty"`
+}
+
+// InsightData is a value shown on a Code Insights report.
+type InsightData struct {
+	Title string      `json:"title"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// InsightAnnotation annotates a line of a file with a problem found by a Code
+// Insights report. Line 0 annotates the whole file.
+type InsightAnnotation struct {
+	Path     string `json:"path"`
+	Line     int    `json:"line,omitempty"`
+	Message  string `json:"message"`
+	Severity string `json:"severity"`
+	Type     string `json:"type,omitempty"`
+}
+
+// The results of Code Insights reports.
+const (
+	InsightPass = "PASS"
+	InsightFail = "FAIL"
+)
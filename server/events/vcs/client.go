@@ -25,9 +25,20 @@ type Client interface {
 	// relative to the repo root, e.g. parent/child/file.txt.
 	GetModifiedFiles(repo models.Repo, pull models.PullRequest) ([]string, error)
 	CreateComment(repo models.Repo, pullNum int, comment string, command string) error
+	// CreateEditableComment creates comment and returns its ID so it can be
+	// edited with EditComment. Unlike CreateComment, comment isn't split so
+	// it must fit in one comment.
+	CreateEditableComment(repo models.Repo, pullNum int, comment string) (int64, error)
+	// EditComment replaces the body of the comment commentID with comment.
+	EditComment(repo models.Repo, pullNum int, commentID int64, comment string) error
 	HidePrevCommandComments(repo models.Repo, pullNum int, command string) error
 	PullIsApproved(repo models.Repo, pull models.PullRequest) (bool, error)
+	// GetApprovals returns the current approvals of pull, excluding approvals
+	// that have since been dismissed or revoked.
+	GetApprovals(repo models.Repo, pull models.PullRequest) ([]models.Approval, error)
 	PullIsMergeable(repo models.Repo, pull models.PullRequest) (bool, error)
+	// PullIsClosed returns true if pull was closed or merged.
+	PullIsClosed(repo models.Repo, pull models.PullRequest) (bool, error)
 	// UpdateStatus updates the commit status to state for pull. src is the
 	// source of this status. This should be relatively static across runs,
 	// ex. atlantis/plan or atlantis/apply.
@@ -44,4 +55,20 @@ type Client interface {
 	// if BaseRepo had one repo config file, its content will placed on the second return value
 	DownloadRepoConfigFile(pull models.PullRequest) (bool, []byte, error)
 	SupportsSingleFileDownload(repo models.Repo) bool
+	// GetTeamNamesForUser returns the names of the teams (or groups) of
+	// teams that user is a member of. Only teams are looked up since
+	// memberships take an API call per team.
+	GetTeamNamesForUser(repo models.Repo, user models.User, teams []string) ([]string, error)
+	// FindOpenIssue returns the open issue of repo titled title, or nil if
+	// there's none.
+	FindOpenIssue(repo models.Repo, title string) (*models.Issue, error)
+	// CreateIssue creates issue in repo and returns it with its number and
+	// URL set.
+	CreateIssue(repo models.Repo, issue models.Issue) (models.Issue, error)
+	// CreatePullRequest opens a pull request in repo that merges headBranch
+	// into baseBranch and returns it with its number and URL set.
+	CreatePullRequest(repo models.Repo, title string, body string, headBranch string, baseBranch string) (models.PullRequest, error)
+	// GetPipeline returns the latest CI pipeline of pull. Its ID is 0 if pull
+	// has no pipeline.
+	GetPipeline(repo models.Repo, pull models.PullRequest) (models.Pipeline, error)
 }
@@ -7,9 +7,18 @@ import (
 )
 
 // AutomergeCommitMsg is the commit message Atlantis will use when automatically
-// merging pull requests.
+// merging pull requests if no message is configured.
 const AutomergeCommitMsg = "[Atlantis] Automatically merging after successful apply"
 
+// AutomergeCommitMsgOrDefault returns msg, or AutomergeCommitMsg if it's
+// empty.
+func AutomergeCommitMsgOrDefault(msg string) string {
+	if msg == "" {
+		return AutomergeCommitMsg
+	}
+	return msg
+}
+
 // SplitComment splits comment into a slice of comments that are under maxSize.
 // It appends sepEnd to all comments that have a following comment.
 // It prepends sepStart to all comments that have a preceding comment.
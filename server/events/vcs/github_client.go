@@ -40,8 +40,13 @@ type GithubClient struct {
 	user           string
 	client         *github.Client
 	v4MutateClient *graphql.Client
+	v4QueryClient  *githubv4.Client
+	httpClient     *http.Client
 	ctx            context.Context
 	logger         logging.SimpleLogging
+	// MaxCommentLength is the maximum number of chars of a comment. Longer
+	// comments are split. If 0, maxCommentLength is used.
+	MaxCommentLength int
 }
 
 // GithubAppTemporarySecrets holds app credentials obtained from github after creation.
@@ -91,6 +96,7 @@ func NewGithubClient(hostname string, credentials GithubCredentials, logger logg
 		transport,
 		graphql.WithHeader("Accept", "application/vnd.github.queen-beryl-preview+json"),
 	)
+	v4QueryClient := githubv4.NewEnterpriseClient(graphqlURL, transport)
 
 	user, err := credentials.GetUser()
 	logger.Debug("GH User: %s", user)
@@ -102,14 +108,85 @@ func NewGithubClient(hostname string, credentials GithubCredentials, logger logg
 		user:           user,
 		client:         client,
 		v4MutateClient: v4MutateClient,
+		v4QueryClient:  v4QueryClient,
+		httpClient:     transport,
 		ctx:            context.Background(),
 		logger:         logger,
 	}, nil
 }
 
+// TrackRateLimits records the rate limits of the responses to the client's
+// requests in limits.
+func (g *GithubClient) TrackRateLimits(limits *RateLimits) {
+	g.httpClient.Transport = limits.Transport(models.Github, g.httpClient.Transport)
+}
+
 // GetModifiedFiles returns the names of files that were modified in the pull request
 // relative to the repo root, e.g. parent/child/file.txt.
+// The files are listed with the GraphQL API, which returns them in fewer
+// requests, or else with the REST API if that fails.
 func (g *GithubClient) GetModifiedFiles(repo models.Repo, pull models.PullRequest) ([]string, error) {
+	files, renamed, err := g.getModifiedFilesV4(repo, pull)
+	switch {
+	case err != nil:
+		g.logger.Warn("unable to get modified files with the GraphQL API, falling back to the REST API: %s", err)
+	case renamed:
+		// The GraphQL API doesn't return the previous names of renamed files,
+		// which we also need.
+	default:
+		return files, nil
+	}
+	return g.getModifiedFilesV3(repo, pull)
+}
+
+// getModifiedFilesV4 returns the names of files that were modified in the
+// pull request using the GraphQL API and whether any of them were renamed.
+func (g *GithubClient) getModifiedFilesV4(repo models.Repo, pull models.PullRequest) ([]string, bool, error) {
+	var q struct {
+		Repository struct {
+			PullRequest struct {
+				Files struct {
+					Nodes []struct {
+						Path       githubv4.String
+						ChangeType githubv4.String
+					}
+					PageInfo struct {
+						EndCursor   githubv4.String
+						HasNextPage githubv4.Boolean
+					}
+				} `graphql:"files(first: 100, after: $cursor)"`
+			} `graphql:"pullRequest(number: $number)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+	variables := map[string]interface{}{
+		"owner":  githubv4.String(repo.Owner),
+		"name":   githubv4.String(repo.Name),
+		"number": githubv4.Int(pull.Num),
+		"cursor": (*githubv4.String)(nil),
+	}
+	var files []string
+	for {
+		g.logger.Debug("POST /graphql pullRequest(%d).files", pull.Num)
+		if err := g.v4QueryClient.Query(g.ctx, &q, variables); err != nil {
+			return nil, false, err
+		}
+		for _, f := range q.Repository.PullRequest.Files.Nodes {
+			if f.ChangeType == "RENAMED" {
+				return nil, true, nil
+			}
+			files = append(files, string(f.Path))
+		}
+		if !q.Repository.PullRequest.Files.PageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = githubv4.NewString(q.Repository.PullRequest.Files.PageInfo.EndCursor)
+	}
+	return files, false, nil
+}
+
+// getModifiedFilesV3 returns the names of files that were modified in the
+// pull request using the REST API.
+func (g *GithubClient) getModifiedFilesV3(repo models.Repo, pull models.PullRequest) ([]string, error) {
 	var files []string
 	nextPage := 0
 	for {
@@ -158,7 +235,11 @@ func (g *GithubClient) CreateComment(repo models.Repo, pullNum int, comment stri
 			"```diff\n"
 	}
 
-	comments := common.SplitComment(comment, maxCommentLength, sepEnd, sepStart)
+	maxLength := maxCommentLength
+	if g.MaxCommentLength > 0 {
+		maxLength = g.MaxCommentLength
+	}
+	comments := common.SplitComment(comment, maxLength, sepEnd, sepStart)
 	for i := range comments {
 		g.logger.Debug("POST /repos/%v/%v/issues/%d/comments", repo.Owner, repo.Name, pullNum)
 		_, _, err := g.client.Issues.CreateComment(g.ctx, repo.Owner, repo.Name, pullNum, &github.IssueComment{Body: &comments[i]})
@@ -169,6 +250,24 @@ func (g *GithubClient) CreateComment(repo models.Repo, pullNum int, comment stri
 	return nil
 }
 
+// CreateEditableComment creates comment on the pull request and returns its
+// ID.
+func (g *GithubClient) CreateEditableComment(repo models.Repo, pullNum int, comment string) (int64, error) {
+	g.logger.Debug("POST /repos/%v/%v/issues/%d/comments", repo.Owner, repo.Name, pullNum)
+	created, _, err := g.client.Issues.CreateComment(g.ctx, repo.Owner, repo.Name, pullNum, &github.IssueComment{Body: &comment})
+	if err != nil {
+		return 0, err
+	}
+	return created.GetID(), nil
+}
+
+// EditComment replaces the body of the comment commentID.
+func (g *GithubClient) EditComment(repo models.Repo, pullNum int, commentID int64, comment string) error {
+	g.logger.Debug("PATCH /repos/%v/%v/issues/comments/%d", repo.Owner, repo.Name, commentID)
+	_, _, err := g.client.Issues.EditComment(g.ctx, repo.Owner, repo.Name, commentID, &github.IssueComment{Body: &comment})
+	return err
+}
+
 func (g *GithubClient) HidePrevCommandComments(repo models.Repo, pullNum int, command string) error {
 	var allComments []*github.IssueComment
 	nextPage := 0
@@ -232,7 +331,32 @@ func (g *GithubClient) HidePrevCommandComments(repo models.Repo, pullNum int, co
 }
 
 // PullIsApproved returns true if the pull request was approved.
+// Its approvals are counted with the GraphQL API in a single request, or else
+// listed with the REST API if that fails.
 func (g *GithubClient) PullIsApproved(repo models.Repo, pull models.PullRequest) (bool, error) {
+	var q struct {
+		Repository struct {
+			PullRequest struct {
+				Reviews struct {
+					TotalCount githubv4.Int
+				} `graphql:"reviews(states: [APPROVED])"`
+			} `graphql:"pullRequest(number: $number)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+	variables := map[string]interface{}{
+		"owner":  githubv4.String(repo.Owner),
+		"name":   githubv4.String(repo.Name),
+		"number": githubv4.Int(pull.Num),
+	}
+	g.logger.Debug("POST /graphql pullRequest(%d).reviews", pull.Num)
+	if err := g.v4QueryClient.Query(g.ctx, &q, variables); err != nil {
+		g.logger.Warn("unable to get reviews with the GraphQL API, falling back to the REST API: %s", err)
+		return g.pullIsApprovedV3(repo, pull)
+	}
+	return q.Repository.PullRequest.Reviews.TotalCount > 0, nil
+}
+
+func (g *GithubClient) pullIsApprovedV3(repo models.Repo, pull models.PullRequest) (bool, error) {
 	nextPage := 0
 	for {
 		opts := github.ListOptions{
@@ -259,6 +383,122 @@ func (g *GithubClient) PullIsApproved(repo models.Repo, pull models.PullRequest)
 	return false, nil
 }
 
+// GetApprovals returns the approvals of the pull request. Only each
+// reviewer's latest review counts, so approvals that were dismissed or
+// followed by a request for changes aren't returned.
+// The reviews are listed with the GraphQL API, which returns them in fewer
+// requests, or else with the REST API if that fails.
+func (g *GithubClient) GetApprovals(repo models.Repo, pull models.PullRequest) ([]models.Approval, error) {
+	var q struct {
+		Repository struct {
+			PullRequest struct {
+				Reviews struct {
+					Nodes []struct {
+						State  githubv4.PullRequestReviewState
+						Author struct {
+							Login githubv4.String
+						}
+						Commit struct {
+							Oid githubv4.GitObjectID
+						}
+						SubmittedAt githubv4.DateTime
+					}
+					PageInfo struct {
+						EndCursor   githubv4.String
+						HasNextPage githubv4.Boolean
+					}
+				} `graphql:"reviews(first: 100, after: $cursor, states: [APPROVED, CHANGES_REQUESTED, DISMISSED])"`
+			} `graphql:"pullRequest(number: $number)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+	variables := map[string]interface{}{
+		"owner":  githubv4.String(repo.Owner),
+		"name":   githubv4.String(repo.Name),
+		"number": githubv4.Int(pull.Num),
+		"cursor": (*githubv4.String)(nil),
+	}
+	latestReviews := make(map[string]models.Approval)
+	approved := make(map[string]bool)
+	var reviewers []string
+	for {
+		g.logger.Debug("POST /graphql pullRequest(%d).reviews", pull.Num)
+		if err := g.v4QueryClient.Query(g.ctx, &q, variables); err != nil {
+			g.logger.Warn("unable to get reviews with the GraphQL API, falling back to the REST API: %s", err)
+			return g.getApprovalsV3(repo, pull)
+		}
+		// Reviews are returned in chronological order.
+		for _, review := range q.Repository.PullRequest.Reviews.Nodes {
+			login := string(review.Author.Login)
+			if _, ok := latestReviews[login]; !ok {
+				reviewers = append(reviewers, login)
+			}
+			latestReviews[login] = models.Approval{
+				Username:  login,
+				CommitSHA: string(review.Commit.Oid),
+				Time:      review.SubmittedAt.Time,
+			}
+			approved[login] = review.State == githubv4.PullRequestReviewStateApproved
+		}
+		if !q.Repository.PullRequest.Reviews.PageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = githubv4.NewString(q.Repository.PullRequest.Reviews.PageInfo.EndCursor)
+	}
+
+	var approvals []models.Approval
+	for _, login := range reviewers {
+		if approved[login] {
+			approvals = append(approvals, latestReviews[login])
+		}
+	}
+	return approvals, nil
+}
+
+func (g *GithubClient) getApprovalsV3(repo models.Repo, pull models.PullRequest) ([]models.Approval, error) {
+	latestReviews := make(map[string]*github.PullRequestReview)
+	var reviewers []string
+	opts := github.ListOptions{
+		PerPage: 300,
+	}
+	for {
+		g.logger.Debug("GET /repos/%v/%v/pulls/%d/reviews", repo.Owner, repo.Name, pull.Num)
+		pageReviews, resp, err := g.client.PullRequests.ListReviews(g.ctx, repo.Owner, repo.Name, pull.Num, &opts)
+		if err != nil {
+			return nil, errors.Wrap(err, "getting reviews")
+		}
+		// Reviews are returned in chronological order.
+		for _, review := range pageReviews {
+			// Comments don't change whether a reviewer approved.
+			if review == nil || review.GetState() == "COMMENTED" || review.GetState() == "PENDING" {
+				continue
+			}
+			login := review.GetUser().GetLogin()
+			if _, ok := latestReviews[login]; !ok {
+				reviewers = append(reviewers, login)
+			}
+			latestReviews[login] = review
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	var approvals []models.Approval
+	for _, login := range reviewers {
+		review := latestReviews[login]
+		if review.GetState() != "APPROVED" {
+			continue
+		}
+		approvals = append(approvals, models.Approval{
+			Username:  login,
+			CommitSHA: review.GetCommitID(),
+			Time:      review.GetSubmittedAt(),
+		})
+	}
+	return approvals, nil
+}
+
 // PullIsMergeable returns true if the pull request is mergeable.
 func (g *GithubClient) PullIsMergeable(repo models.Repo, pull models.PullRequest) (bool, error) {
 	githubPR, err := g.GetPullRequest(repo, pull.Num)
@@ -281,6 +521,15 @@ func (g *GithubClient) PullIsMergeable(repo models.Repo, pull models.PullRequest
 	return true, nil
 }
 
+// PullIsClosed returns true if the pull request was closed or merged.
+func (g *GithubClient) PullIsClosed(repo models.Repo, pull models.PullRequest) (bool, error) {
+	githubPR, err := g.GetPullRequest(repo, pull.Num)
+	if err != nil {
+		return false, errors.Wrap(err, "getting pull request")
+	}
+	return githubPR.GetState() == "closed", nil
+}
+
 // GetPullRequest returns the pull request.
 func (g *GithubClient) GetPullRequest(repo models.Repo, num int) (*github.PullRequest, error) {
 	var err error
@@ -355,6 +604,16 @@ func (g *GithubClient) MergePull(pull models.PullRequest, pullOptions models.Pul
 	options := &github.PullRequestOptions{
 		MergeMethod: method,
 	}
+	// The first line of a configured message is the title of the commit and
+	// the rest its body.
+	var commitMsg string
+	if pullOptions.CommitMessage != "" {
+		lines := strings.SplitN(pullOptions.CommitMessage, "\n", 2)
+		options.CommitTitle = lines[0]
+		if len(lines) == 2 {
+			commitMsg = strings.TrimSpace(lines[1])
+		}
+	}
 	g.logger.Debug("PUT /repos/%v/%v/pulls/%d/merge", repo.Owner, repo.Name, pull.Num)
 	mergeResult, _, err := g.client.PullRequests.Merge(
 		g.ctx,
@@ -363,7 +622,7 @@ func (g *GithubClient) MergePull(pull models.PullRequest, pullOptions models.Pul
 		pull.Num,
 		// NOTE: Using the empty string here causes GitHub to autogenerate
 		// the commit message as it normally would.
-		"",
+		commitMsg,
 		options)
 	if err != nil {
 		return errors.Wrap(err, "merging pull request")
@@ -416,6 +675,153 @@ func (g *GithubClient) DownloadRepoConfigFile(pull models.PullRequest) (bool, []
 	return true, decodedData, nil
 }
 
+// CheckConnection returns an error if GitHub can't be reached or rejects the
+// credentials. It gets the rate limits since they can be read with any
+// credentials and don't count against them.
+func (g *GithubClient) CheckConnection() error {
+	_, _, err := g.client.RateLimits(g.ctx)
+	return err
+}
+
 func (g *GithubClient) SupportsSingleFileDownload(repo models.Repo) bool {
 	return true
 }
+
+// GetTeamNamesForUser returns the teams of teams that user is an active
+// member of. Teams are either slugs of teams in the repo's organization or
+// org/slug.
+func (g *GithubClient) GetTeamNamesForUser(repo models.Repo, user models.User, teams []string) ([]string, error) {
+	var teamNames []string
+	for _, team := range teams {
+		org, slug := repo.Owner, team
+		if i := strings.Index(team, "/"); i >= 0 {
+			org, slug = team[:i], team[i+1:]
+		}
+		membership, resp, err := g.client.Teams.GetTeamMembershipBySlug(g.ctx, org, slug, user.Username)
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting membership of %s in team %s", user.Username, team)
+		}
+		if membership.GetState() == "active" {
+			teamNames = append(teamNames, team)
+		}
+	}
+	return teamNames, nil
+}
+
+// FindOpenIssue returns the open issue of repo titled title, or nil if
+// there's none. Pull requests are ignored.
+func (g *GithubClient) FindOpenIssue(repo models.Repo, title string) (*models.Issue, error) {
+	opts := &github.IssueListByRepoOptions{
+		State:       "open",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		issues, resp, err := g.client.Issues.ListByRepo(g.ctx, repo.Owner, repo.Name, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "listing issues of %s", repo.FullName)
+		}
+		for _, i := range issues {
+			if i.IsPullRequest() || i.GetTitle() != title {
+				continue
+			}
+			return &models.Issue{
+				Number: i.GetNumber(),
+				URL:    i.GetHTMLURL(),
+				Title:  i.GetTitle(),
+				Body:   i.GetBody(),
+			}, nil
+		}
+		if resp.NextPage == 0 {
+			return nil, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// CreateIssue creates issue in repo.
+func (g *GithubClient) CreateIssue(repo models.Repo, issue models.Issue) (models.Issue, error) {
+	req := &github.IssueRequest{
+		Title: github.String(issue.Title),
+		Body:  github.String(issue.Body),
+	}
+	if len(issue.Labels) > 0 {
+		req.Labels = &issue.Labels
+	}
+	if len(issue.Assignees) > 0 {
+		req.Assignees = &issue.Assignees
+	}
+	created, _, err := g.client.Issues.Create(g.ctx, repo.Owner, repo.Name, req)
+	if err != nil {
+		return issue, errors.Wrapf(err, "creating issue in %s", repo.FullName)
+	}
+	issue.Number = created.GetNumber()
+	issue.URL = created.GetHTMLURL()
+	return issue, nil
+}
+
+// CreateDeployment creates a deployment of ref to environment in repo and
+// returns its ID. The commit statuses of ref aren't required to pass since
+// Atlantis sets its own while applying.
+func (g *GithubClient) CreateDeployment(repo models.Repo, ref string, environment string, description string, payload map[string]interface{}) (int64, error) {
+	deployment, _, err := g.client.Repositories.CreateDeployment(g.ctx, repo.Owner, repo.Name, &github.DeploymentRequest{
+		Ref:              github.String(ref),
+		AutoMerge:        github.Bool(false),
+		RequiredContexts: &[]string{},
+		Payload:          payload,
+		Environment:      github.String(environment),
+		Description:      github.String(description),
+	})
+	if err != nil {
+		return 0, errors.Wrapf(err, "creating deployment in %s", repo.FullName)
+	}
+	return deployment.GetID(), nil
+}
+
+// CreateDeploymentStatus sets the state of the deployment deploymentID in
+// repo, ex. "in_progress" or "success". logURL links to its output, if it's
+// not empty.
+func (g *GithubClient) CreateDeploymentStatus(repo models.Repo, deploymentID int64, state string, description string, logURL string) error {
+	status := &github.DeploymentStatusRequest{
+		State:       github.String(state),
+		Description: github.String(description),
+	}
+	if logURL != "" {
+		status.LogURL = github.String(logURL)
+	}
+	if _, _, err := g.client.Repositories.CreateDeploymentStatus(g.ctx, repo.Owner, repo.Name, deploymentID, status); err != nil {
+		return errors.Wrapf(err, "creating status of deployment %d in %s", deploymentID, repo.FullName)
+	}
+	return nil
+}
+
+// CreatePullRequest opens a pull request in repo from headBranch into
+// baseBranch.
+func (g *GithubClient) CreatePullRequest(repo models.Repo, title string, body string, headBranch string, baseBranch string) (models.PullRequest, error) {
+	created, _, err := g.client.PullRequests.Create(g.ctx, repo.Owner, repo.Name, &github.NewPullRequest{
+		Title: github.String(title),
+		Body:  github.String(body),
+		Head:  github.String(headBranch),
+		Base:  github.String(baseBranch),
+	})
+	if err != nil {
+		return models.PullRequest{}, errors.Wrapf(err, "creating pull request in %s", repo.FullName)
+	}
+	return models.PullRequest{
+		Num:        created.GetNumber(),
+		URL:        created.GetHTMLURL(),
+		Title:      title,
+		HeadBranch: headBranch,
+		BaseBranch: baseBranch,
+		State:      models.OpenPullState,
+		BaseRepo:   repo,
+	}, nil
+}
+
+// GetPipeline is not supported for GitHub. Use branch protection's required
+// status checks with the mergeable apply requirement instead.
+func (g *GithubClient) GetPipeline(repo models.Repo, pull models.PullRequest) (models.Pipeline, error) {
+	return models.Pipeline{}, fmt.Errorf("pipelines are not supported for GitHub")
+}
@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/vcs"
@@ -19,8 +20,8 @@ import (
 	"github.com/shurcooL/githubv4"
 )
 
-// GetModifiedFiles should make multiple requests if more than one page
-// and concat results.
+// GetModifiedFiles should fall back to the REST API if the GraphQL API
+// fails, and make multiple requests if more than one page and concat results.
 func TestGithubClient_GetModifiedFiles(t *testing.T) {
 	logger := logging.NewNoopLogger(t)
 	respTemplate := `[
@@ -42,6 +43,8 @@ func TestGithubClient_GetModifiedFiles(t *testing.T) {
 	testServer := httptest.NewTLSServer(
 		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			switch r.RequestURI {
+			case "/api/graphql":
+				w.Write([]byte(`{"errors": [{"message": "timeout"}]}`)) // nolint: errcheck
 			// The first request should hit this URL.
 			case "/api/v3/repos/owner/repo/pulls/1/files?per_page=300":
 				// We write a header that means there's an additional page.
@@ -61,7 +64,7 @@ func TestGithubClient_GetModifiedFiles(t *testing.T) {
 
 	testServerURL, err := url.Parse(testServer.URL)
 	Ok(t, err)
-	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{"user", "pass"}, logger)
+	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{User: "user", Token: "pass"}, logger)
 	Ok(t, err)
 	defer disableSSLVerification()()
 
@@ -103,6 +106,10 @@ func TestGithubClient_GetModifiedFilesMovedFile(t *testing.T) {
 	testServer := httptest.NewTLSServer(
 		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			switch r.RequestURI {
+			// The GraphQL API doesn't return the previous names of renamed
+			// files so the REST API is used.
+			case "/api/graphql":
+				w.Write([]byte(`{"data": {"repository": {"pullRequest": {"files": {"nodes": [{"path": "new/filename.txt", "changeType": "RENAMED"}]}}}}}`)) // nolint: errcheck
 			// The first request should hit this URL.
 			case "/api/v3/repos/owner/repo/pulls/1/files?per_page=300":
 				w.Write([]byte(resp)) // nolint: errcheck
@@ -116,7 +123,7 @@ func TestGithubClient_GetModifiedFilesMovedFile(t *testing.T) {
 
 	testServerURL, err := url.Parse(testServer.URL)
 	Ok(t, err)
-	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{"user", "pass"}, logging.NewNoopLogger(t))
+	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{User: "user", Token: "pass"}, logging.NewNoopLogger(t))
 	Ok(t, err)
 	defer disableSSLVerification()()
 
@@ -137,6 +144,39 @@ func TestGithubClient_GetModifiedFilesMovedFile(t *testing.T) {
 	Equals(t, []string{"new/filename.txt", "previous/filename.txt"}, files)
 }
 
+// GetModifiedFiles should use the GraphQL API and follow its cursors.
+func TestGithubClient_GetModifiedFilesGraphQL(t *testing.T) {
+	testServer := httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.RequestURI != "/api/graphql" {
+				t.Errorf("got unexpected request at %q", r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+			body, err := ioutil.ReadAll(r.Body)
+			Ok(t, err)
+			if strings.Contains(string(body), `"cursor":"c1"`) {
+				w.Write([]byte(`{"data": {"repository": {"pullRequest": {"files": {"nodes": [{"path": "file2.txt", "changeType": "MODIFIED"}], "pageInfo": {"endCursor": "c2", "hasNextPage": false}}}}}}`)) // nolint: errcheck
+				return
+			}
+			w.Write([]byte(`{"data": {"repository": {"pullRequest": {"files": {"nodes": [{"path": "file1.txt", "changeType": "ADDED"}], "pageInfo": {"endCursor": "c1", "hasNextPage": true}}}}}}`)) // nolint: errcheck
+		}))
+
+	testServerURL, err := url.Parse(testServer.URL)
+	Ok(t, err)
+	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{User: "user", Token: "pass"}, logging.NewNoopLogger(t))
+	Ok(t, err)
+	defer disableSSLVerification()()
+
+	files, err := client.GetModifiedFiles(models.Repo{
+		FullName: "owner/repo",
+		Owner:    "owner",
+		Name:     "repo",
+	}, models.PullRequest{Num: 1})
+	Ok(t, err)
+	Equals(t, []string{"file1.txt", "file2.txt"}, files)
+}
+
 func TestGithubClient_PaginatesComments(t *testing.T) {
 	calls := 0
 	issueResps := []string{
@@ -210,7 +250,7 @@ func TestGithubClient_PaginatesComments(t *testing.T) {
 	testServerURL, err := url.Parse(testServer.URL)
 	Ok(t, err)
 
-	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{"user", "pass"}, logging.NewNoopLogger(t))
+	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{User: "user", Token: "pass"}, logging.NewNoopLogger(t))
 	Ok(t, err)
 	defer disableSSLVerification()()
 
@@ -299,7 +339,7 @@ func TestGithubClient_HideOldComments(t *testing.T) {
 	testServerURL, err := url.Parse(testServer.URL)
 	Ok(t, err)
 
-	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{"user", "pass"}, logging.NewNoopLogger(t))
+	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{User: "user", Token: "pass"}, logging.NewNoopLogger(t))
 	Ok(t, err)
 	defer disableSSLVerification()()
 
@@ -365,7 +405,7 @@ func TestGithubClient_UpdateStatus(t *testing.T) {
 
 			testServerURL, err := url.Parse(testServer.URL)
 			Ok(t, err)
-			client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{"user", "pass"}, logging.NewNoopLogger(t))
+			client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{User: "user", Token: "pass"}, logging.NewNoopLogger(t))
 			Ok(t, err)
 			defer disableSSLVerification()()
 
@@ -432,6 +472,8 @@ func TestGithubClient_PullIsApproved(t *testing.T) {
 	testServer := httptest.NewTLSServer(
 		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			switch r.RequestURI {
+			case "/api/graphql":
+				http.Error(w, "bad gateway", http.StatusBadGateway)
 			// The first request should hit this URL.
 			case "/api/v3/repos/owner/repo/pulls/1/reviews?per_page=300":
 				// We write a header that means there's an additional page.
@@ -451,7 +493,7 @@ func TestGithubClient_PullIsApproved(t *testing.T) {
 
 	testServerURL, err := url.Parse(testServer.URL)
 	Ok(t, err)
-	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{"user", "pass"}, logging.NewNoopLogger(t))
+	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{User: "user", Token: "pass"}, logging.NewNoopLogger(t))
 	Ok(t, err)
 	defer disableSSLVerification()()
 
@@ -472,6 +514,36 @@ func TestGithubClient_PullIsApproved(t *testing.T) {
 	Equals(t, false, approved)
 }
 
+func TestGithubClient_PullIsApprovedGraphQL(t *testing.T) {
+	for _, count := range []int{0, 2} {
+		t.Run(fmt.Sprintf("%d approvals", count), func(t *testing.T) {
+			testServer := httptest.NewTLSServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.RequestURI != "/api/graphql" {
+						t.Errorf("got unexpected request at %q", r.RequestURI)
+						http.Error(w, "not found", http.StatusNotFound)
+						return
+					}
+					fmt.Fprintf(w, `{"data": {"repository": {"pullRequest": {"reviews": {"totalCount": %d}}}}}`, count)
+				}))
+
+			testServerURL, err := url.Parse(testServer.URL)
+			Ok(t, err)
+			client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{User: "user", Token: "pass"}, logging.NewNoopLogger(t))
+			Ok(t, err)
+			defer disableSSLVerification()()
+
+			approved, err := client.PullIsApproved(models.Repo{
+				FullName: "owner/repo",
+				Owner:    "owner",
+				Name:     "repo",
+			}, models.PullRequest{Num: 1})
+			Ok(t, err)
+			Equals(t, count > 0, approved)
+		})
+	}
+}
+
 func TestGithubClient_PullIsMergeable(t *testing.T) {
 	cases := []struct {
 		state        string
@@ -542,7 +614,7 @@ func TestGithubClient_PullIsMergeable(t *testing.T) {
 				}))
 			testServerURL, err := url.Parse(testServer.URL)
 			Ok(t, err)
-			client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{"user", "pass"}, logging.NewNoopLogger(t))
+			client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{User: "user", Token: "pass"}, logging.NewNoopLogger(t))
 			Ok(t, err)
 			defer disableSSLVerification()()
 
@@ -624,7 +696,7 @@ func TestGithubClient_MergePullHandlesError(t *testing.T) {
 
 			testServerURL, err := url.Parse(testServer.URL)
 			Ok(t, err)
-			client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{"user", "pass"}, logging.NewNoopLogger(t))
+			client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{User: "user", Token: "pass"}, logging.NewNoopLogger(t))
 			Ok(t, err)
 			defer disableSSLVerification()()
 
@@ -747,7 +819,7 @@ func TestGithubClient_MergePullCorrectMethod(t *testing.T) {
 
 			testServerURL, err := url.Parse(testServer.URL)
 			Ok(t, err)
-			client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{"user", "pass"}, logging.NewNoopLogger(t))
+			client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{User: "user", Token: "pass"}, logging.NewNoopLogger(t))
 			Ok(t, err)
 			defer disableSSLVerification()()
 
@@ -774,8 +846,57 @@ func TestGithubClient_MergePullCorrectMethod(t *testing.T) {
 	}
 }
 
+func TestGithubClient_MergePullCommitMessage(t *testing.T) {
+	jsBytes, err := ioutil.ReadFile("fixtures/github-repo.json")
+	Ok(t, err)
+	testServer := httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.RequestURI {
+			case "/api/v3/repos/runatlantis/atlantis":
+				w.Write(jsBytes) // nolint: errcheck
+				return
+			case "/api/v3/repos/runatlantis/atlantis/pulls/1/merge":
+				body, err := ioutil.ReadAll(r.Body)
+				Ok(t, err)
+				defer r.Body.Close() // nolint: errcheck
+				// The first line of the message is the title.
+				Equals(t, `{"commit_message":"Applied staging.","commit_title":"Add staging (#1)","merge_method":"merge"}`+"\n", string(body))
+
+				resp := `{"sha":"6dcb09b5b57875f334f61aebed695e2e4193db5e","merged":true,"message":"Pull Request successfully merged"}`
+				w.Write([]byte(resp)) // nolint: errcheck
+			default:
+				t.Errorf("got unexpected request at %q", r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+		}))
+
+	testServerURL, err := url.Parse(testServer.URL)
+	Ok(t, err)
+	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{User: "user", Token: "pass"}, logging.NewNoopLogger(t))
+	Ok(t, err)
+	defer disableSSLVerification()()
+
+	err = client.MergePull(
+		models.PullRequest{
+			BaseRepo: models.Repo{
+				FullName: "runatlantis/atlantis",
+				Owner:    "runatlantis",
+				Name:     "atlantis",
+				VCSHost: models.VCSHost{
+					Type:     models.Github,
+					Hostname: "github.com",
+				},
+			},
+			Num: 1,
+		}, models.PullRequestOptions{
+			CommitMessage: "Add staging (#1)\n\nApplied staging.",
+		})
+	Ok(t, err)
+}
+
 func TestGithubClient_MarkdownPullLink(t *testing.T) {
-	client, err := vcs.NewGithubClient("hostname", &vcs.GithubUserCredentials{"user", "pass"}, logging.NewNoopLogger(t))
+	client, err := vcs.NewGithubClient("hostname", &vcs.GithubUserCredentials{User: "user", Token: "pass"}, logging.NewNoopLogger(t))
 	Ok(t, err)
 	pull := models.PullRequest{Num: 1}
 	s, _ := client.MarkdownPullLink(pull)
@@ -830,7 +951,7 @@ func TestGithubClient_SplitComments(t *testing.T) {
 
 	testServerURL, err := url.Parse(testServer.URL)
 	Ok(t, err)
-	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{"user", "pass"}, logging.NewNoopLogger(t))
+	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{User: "user", Token: "pass"}, logging.NewNoopLogger(t))
 	Ok(t, err)
 	defer disableSSLVerification()()
 	pull := models.PullRequest{Num: 1}
@@ -860,6 +981,15 @@ func TestGithubClient_SplitComments(t *testing.T) {
 	Equals(t, 4, len(githubComments))
 	Assert(t, strings.Contains(firstSplit, models.PlanCommand.String()), fmt.Sprintf("comment should contain the command name but was %q", firstSplit))
 	Assert(t, strings.Contains(secondSplit, "continued from previous comment"), fmt.Sprintf("comment should contain no reference to the command name but was %q", secondSplit))
+
+	// Comments are split at MaxCommentLength if it's set.
+	githubComments = githubComments[:0]
+	client.MaxCommentLength = 1000
+	Ok(t, client.CreateComment(repo, pull.Num, strings.Repeat("a", 1500), ""))
+	Equals(t, 2, len(githubComments))
+	for _, c := range githubComments {
+		Assert(t, len(c.Body) <= 1000, "comment of length %d is longer than 1000", len(c.Body))
+	}
 }
 
 // Test that we retry the get pull request call if it 404s.
@@ -888,7 +1018,7 @@ func TestGithubClient_Retry404(t *testing.T) {
 
 	testServerURL, err := url.Parse(testServer.URL)
 	Ok(t, err)
-	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{"user", "pass"}, logging.NewNoopLogger(t))
+	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{User: "user", Token: "pass"}, logging.NewNoopLogger(t))
 	Ok(t, err)
 	defer disableSSLVerification()()
 	repo := models.Repo{
@@ -906,3 +1036,269 @@ func TestGithubClient_Retry404(t *testing.T) {
 	Ok(t, err)
 	Equals(t, 3, numCalls)
 }
+
+func TestGithubClient_GetTeamNamesForUser(t *testing.T) {
+	testServer := httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.RequestURI {
+			case "/api/v3/orgs/owner/teams/infra-admins/memberships/user":
+				w.Write([]byte(`{"state": "active", "role": "member"}`)) // nolint: errcheck
+			case "/api/v3/orgs/owner/teams/pending/memberships/user":
+				w.Write([]byte(`{"state": "pending", "role": "member"}`)) // nolint: errcheck
+			case "/api/v3/orgs/owner/teams/devs/memberships/user":
+				http.Error(w, "not found", http.StatusNotFound)
+			case "/api/v3/orgs/other/teams/ops/memberships/user":
+				w.Write([]byte(`{"state": "active", "role": "member"}`)) // nolint: errcheck
+			default:
+				t.Errorf("got unexpected request at %q", r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		}))
+
+	testServerURL, err := url.Parse(testServer.URL)
+	Ok(t, err)
+	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{User: "user", Token: "pass"}, logging.NewNoopLogger(t))
+	Ok(t, err)
+	defer disableSSLVerification()()
+
+	teams, err := client.GetTeamNamesForUser(models.Repo{
+		FullName: "owner/repo",
+		Owner:    "owner",
+		Name:     "repo",
+	}, models.User{Username: "user"}, []string{"infra-admins", "devs", "pending", "other/ops"})
+	Ok(t, err)
+	Equals(t, []string{"infra-admins", "other/ops"}, teams)
+}
+
+func TestGithubClient_GetApprovals(t *testing.T) {
+	testServer := httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.RequestURI {
+			case "/api/graphql":
+				w.Write([]byte(`{"errors": [{"message": "timeout"}]}`)) // nolint: errcheck
+			case "/api/v3/repos/owner/repo/pulls/1/reviews?per_page=300":
+				w.Write([]byte(`[
+					{"user": {"login": "alice"}, "state": "APPROVED", "commit_id": "sha1", "submitted_at": "2021-01-01T00:00:00Z"},
+					{"user": {"login": "bob"}, "state": "APPROVED", "commit_id": "sha1", "submitted_at": "2021-01-01T00:00:00Z"},
+					{"user": {"login": "bob"}, "state": "CHANGES_REQUESTED", "commit_id": "sha2", "submitted_at": "2021-01-02T00:00:00Z"},
+					{"user": {"login": "alice"}, "state": "COMMENTED", "commit_id": "sha2", "submitted_at": "2021-01-02T00:00:00Z"},
+					{"user": {"login": "carol"}, "state": "APPROVED", "commit_id": "sha2", "submitted_at": "2021-01-03T00:00:00Z"}
+				]`)) // nolint: errcheck
+			default:
+				t.Errorf("got unexpected request at %q", r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		}))
+
+	testServerURL, err := url.Parse(testServer.URL)
+	Ok(t, err)
+	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{User: "user", Token: "pass"}, logging.NewNoopLogger(t))
+	Ok(t, err)
+	defer disableSSLVerification()()
+
+	approvals, err := client.GetApprovals(models.Repo{
+		FullName: "owner/repo",
+		Owner:    "owner",
+		Name:     "repo",
+	}, models.PullRequest{Num: 1})
+	Ok(t, err)
+	Equals(t, []models.Approval{
+		{Username: "alice", CommitSHA: "sha1", Time: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Username: "carol", CommitSHA: "sha2", Time: time.Date(2021, 1, 3, 0, 0, 0, 0, time.UTC)},
+	}, approvals)
+}
+
+func TestGithubClient_GetApprovalsGraphQL(t *testing.T) {
+	testServer := httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.RequestURI != "/api/graphql" {
+				t.Errorf("got unexpected request at %q", r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{"data": {"repository": {"pullRequest": {"reviews": {"nodes": [
+				{"state": "APPROVED", "author": {"login": "alice"}, "commit": {"oid": "sha1"}, "submittedAt": "2021-01-01T00:00:00Z"},
+				{"state": "APPROVED", "author": {"login": "bob"}, "commit": {"oid": "sha1"}, "submittedAt": "2021-01-01T00:00:00Z"},
+				{"state": "CHANGES_REQUESTED", "author": {"login": "bob"}, "commit": {"oid": "sha2"}, "submittedAt": "2021-01-02T00:00:00Z"},
+				{"state": "APPROVED", "author": {"login": "carol"}, "commit": {"oid": "sha2"}, "submittedAt": "2021-01-03T00:00:00Z"},
+				{"state": "DISMISSED", "author": {"login": "carol"}, "commit": {"oid": "sha2"}, "submittedAt": "2021-01-03T00:00:00Z"}
+			], "pageInfo": {"hasNextPage": false}}}}}}`)) // nolint: errcheck
+		}))
+
+	testServerURL, err := url.Parse(testServer.URL)
+	Ok(t, err)
+	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{User: "user", Token: "pass"}, logging.NewNoopLogger(t))
+	Ok(t, err)
+	defer disableSSLVerification()()
+
+	approvals, err := client.GetApprovals(models.Repo{
+		FullName: "owner/repo",
+		Owner:    "owner",
+		Name:     "repo",
+	}, models.PullRequest{Num: 1})
+	Ok(t, err)
+	Equals(t, []models.Approval{
+		{Username: "alice", CommitSHA: "sha1", Time: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}, approvals)
+}
+
+func TestGithubClient_PullIsClosed(t *testing.T) {
+	testServer := httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.RequestURI {
+			case "/api/v3/repos/owner/repo/pulls/1":
+				w.Write([]byte(`{"number": 1, "state": "open"}`)) // nolint: errcheck
+			case "/api/v3/repos/owner/repo/pulls/2":
+				w.Write([]byte(`{"number": 2, "state": "closed", "merged": true}`)) // nolint: errcheck
+			default:
+				t.Errorf("got unexpected request at %q", r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		}))
+
+	testServerURL, err := url.Parse(testServer.URL)
+	Ok(t, err)
+	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{User: "user", Token: "pass"}, logging.NewNoopLogger(t))
+	Ok(t, err)
+	defer disableSSLVerification()()
+
+	repo := models.Repo{
+		FullName: "owner/repo",
+		Owner:    "owner",
+		Name:     "repo",
+	}
+	closed, err := client.PullIsClosed(repo, models.PullRequest{Num: 1})
+	Ok(t, err)
+	Equals(t, false, closed)
+	closed, err = client.PullIsClosed(repo, models.PullRequest{Num: 2})
+	Ok(t, err)
+	Equals(t, true, closed)
+}
+
+func TestGithubClient_EditComment(t *testing.T) {
+	var edited string
+	testServer := httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method + " " + r.RequestURI {
+			case "POST /api/v3/repos/owner/repo/issues/1/comments":
+				w.Write([]byte(`{"id": 42, "body": "Running"}`)) // nolint: errcheck
+			case "PATCH /api/v3/repos/owner/repo/issues/comments/42":
+				var comment struct {
+					Body string `json:"body"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
+					t.Errorf("parse body error: %v", err)
+				}
+				edited = comment.Body
+				w.Write([]byte(`{"id": 42}`)) // nolint: errcheck
+			default:
+				t.Errorf("got unexpected request %s %q", r.Method, r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		}))
+
+	testServerURL, err := url.Parse(testServer.URL)
+	Ok(t, err)
+	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{User: "user", Token: "pass"}, logging.NewNoopLogger(t))
+	Ok(t, err)
+	defer disableSSLVerification()()
+
+	repo := models.Repo{
+		FullName: "owner/repo",
+		Owner:    "owner",
+		Name:     "repo",
+	}
+	id, err := client.CreateEditableComment(repo, 1, "Running")
+	Ok(t, err)
+	Equals(t, int64(42), id)
+	Ok(t, client.EditComment(repo, 1, id, "Done"))
+	Equals(t, "Done", edited)
+}
+
+func TestGithubClient_Issues(t *testing.T) {
+	var created map[string]interface{}
+	testServer := httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method + " " + r.RequestURI {
+			case "GET /api/v3/repos/owner/repo/issues?per_page=100&state=open":
+				// Pull requests are listed as issues too.
+				w.Write([]byte(`[
+					{"number": 1, "title": "Drift", "pull_request": {"url": "https://api.github.com/repos/owner/repo/pulls/1"}},
+					{"number": 2, "title": "Other"},
+					{"number": 3, "title": "Drift", "html_url": "https://github.com/owner/repo/issues/3"}
+				]`)) // nolint: errcheck
+			case "POST /api/v3/repos/owner/repo/issues":
+				if err := json.NewDecoder(r.Body).Decode(&created); err != nil {
+					t.Errorf("parse body error: %v", err)
+				}
+				w.Write([]byte(`{"number": 4, "html_url": "https://github.com/owner/repo/issues/4"}`)) // nolint: errcheck
+			default:
+				t.Errorf("got unexpected request %s %q", r.Method, r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		}))
+
+	testServerURL, err := url.Parse(testServer.URL)
+	Ok(t, err)
+	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{User: "user", Token: "pass"}, logging.NewNoopLogger(t))
+	Ok(t, err)
+	defer disableSSLVerification()()
+
+	repo := models.Repo{
+		FullName: "owner/repo",
+		Owner:    "owner",
+		Name:     "repo",
+	}
+	issue, err := client.FindOpenIssue(repo, "Drift")
+	Ok(t, err)
+	Equals(t, &models.Issue{Number: 3, URL: "https://github.com/owner/repo/issues/3", Title: "Drift"}, issue)
+	issue, err = client.FindOpenIssue(repo, "Missing")
+	Ok(t, err)
+	Assert(t, issue == nil, "exp no issue, got %v", issue)
+
+	newIssue, err := client.CreateIssue(repo, models.Issue{Title: "New", Body: "body", Labels: []string{"drift"}, Assignees: []string{"alice"}})
+	Ok(t, err)
+	Equals(t, 4, newIssue.Number)
+	Equals(t, "https://github.com/owner/repo/issues/4", newIssue.URL)
+	Equals(t, map[string]interface{}{
+		"title":     "New",
+		"body":      "body",
+		"labels":    []interface{}{"drift"},
+		"assignees": []interface{}{"alice"},
+	}, created)
+}
+
+func TestGithubClient_CreateDeployment(t *testing.T) {
+	testServer := httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			Ok(t, err)
+			defer r.Body.Close() // nolint: errcheck
+			switch r.RequestURI {
+			case "/api/v3/repos/runatlantis/atlantis/deployments":
+				// Commit statuses aren't required to pass.
+				Equals(t, `{"ref":"branch","auto_merge":false,"required_contexts":[],"payload":{"dir":"."},"environment":"staging","description":"Atlantis apply"}`+"\n", string(body))
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"id":42}`)) // nolint: errcheck
+			case "/api/v3/repos/runatlantis/atlantis/deployments/42/statuses":
+				Equals(t, `{"state":"success","log_url":"https://atlantis/logs/1","description":"Apply succeeded."}`+"\n", string(body))
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"id":1}`)) // nolint: errcheck
+			default:
+				t.Errorf("got unexpected request at %q", r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		}))
+
+	testServerURL, err := url.Parse(testServer.URL)
+	Ok(t, err)
+	client, err := vcs.NewGithubClient(testServerURL.Host, &vcs.GithubUserCredentials{User: "user", Token: "pass"}, logging.NewNoopLogger(t))
+	Ok(t, err)
+	defer disableSSLVerification()()
+	repo := models.Repo{FullName: "runatlantis/atlantis", Owner: "runatlantis", Name: "atlantis"}
+
+	id, err := client.CreateDeployment(repo, "branch", "staging", "Atlantis apply", map[string]interface{}{"dir": "."})
+	Ok(t, err)
+	Equals(t, int64(42), id)
+	Ok(t, client.CreateDeploymentStatus(repo, id, "success", "Apply succeeded.", "https://atlantis/logs/1"))
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 
 	"github.com/bradleyfalzon/ghinstallation"
 	"github.com/google/go-github/v31/github"
@@ -41,18 +42,20 @@ func (c *GithubAnonymousCredentials) GetToken() (string, error) {
 }
 
 // GithubUserCredentials implements GithubCredentials for the personal auth token flow.
+// Its methods are safe for concurrent use.
 type GithubUserCredentials struct {
-	User  string
+	User string
+	// Token is the user token. Once the credentials are in use, it must only
+	// be replaced with SetToken.
 	Token string
+
+	mutex sync.RWMutex
 }
 
-// Client returns a client for basic auth user credentials.
+// Client returns a client for basic auth user credentials. Its requests are
+// authenticated with the current token.
 func (c *GithubUserCredentials) Client() (*http.Client, error) {
-	tr := &github.BasicAuthTransport{
-		Username: strings.TrimSpace(c.User),
-		Password: strings.TrimSpace(c.Token),
-	}
-	return tr.Client(), nil
+	return &http.Client{Transport: &githubUserTransport{creds: c}}, nil
 }
 
 // GetUser returns the username for these credentials.
@@ -62,9 +65,31 @@ func (c *GithubUserCredentials) GetUser() (string, error) {
 
 // GetToken returns the user token.
 func (c *GithubUserCredentials) GetToken() (string, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 	return c.Token, nil
 }
 
+// SetToken replaces the token, ex. when it's rotated in its secret store.
+func (c *GithubUserCredentials) SetToken(token string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.Token = token
+}
+
+type githubUserTransport struct {
+	creds *GithubUserCredentials
+}
+
+func (t *githubUserTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, _ := t.creds.GetToken()
+	tr := &github.BasicAuthTransport{
+		Username: strings.TrimSpace(t.creds.User),
+		Password: strings.TrimSpace(token),
+	}
+	return tr.RoundTrip(req)
+}
+
 // GithubAppCredentials implements GithubCredentials for github app installation token flow.
 type GithubAppCredentials struct {
 	AppID          int64
@@ -2,6 +2,8 @@ package vcs_test
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/runatlantis/atlantis/server/events/vcs"
@@ -80,3 +82,25 @@ func TestGithubClient_AppAuthentication(t *testing.T) {
 		t.Errorf("app token was not cached: %q != %q", token, newToken)
 	}
 }
+
+func TestGithubUserCredentials_SetToken(t *testing.T) {
+	var password string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, password, _ = r.BasicAuth()
+	}))
+	defer testServer.Close()
+
+	creds := &vcs.GithubUserCredentials{User: "user", Token: "token"}
+	client, err := creds.Client()
+	Ok(t, err)
+	creds.SetToken("rotated")
+	token, err := creds.GetToken()
+	Ok(t, err)
+	Equals(t, "rotated", token)
+
+	// Clients created before the rotation use the new token.
+	resp, err := client.Get(testServer.URL)
+	Ok(t, err)
+	resp.Body.Close() // nolint: errcheck
+	Equals(t, "rotated", password)
+}
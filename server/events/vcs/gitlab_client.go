@@ -36,6 +36,17 @@ type GitlabClient struct {
 	Client *gitlab.Client
 	// Version is set to the server version.
 	Version *version.Version
+	// MaxCommentLength is the maximum number of chars of a comment. Longer
+	// comments are split. If 0, they aren't split.
+	MaxCommentLength int
+	// PlanDiscussions is true if plan comments are posted as resolvable
+	// discussions so that HidePrevCommandComments can resolve them, since
+	// GitLab can't minimize comments.
+	PlanDiscussions bool
+	// User is the username of the Atlantis user. Only its discussions are
+	// resolved by HidePrevCommandComments.
+	User       string
+	httpClient *http.Client
 }
 
 // commonMarkSupported is a version constraint that is true when this version of
@@ -48,11 +59,13 @@ var gitlabClientUnderTest = false
 
 // NewGitlabClient returns a valid GitLab client.
 func NewGitlabClient(hostname string, token string, logger logging.SimpleLogging) (*GitlabClient, error) {
-	client := &GitlabClient{}
+	// The HTTP client is kept so its transport can be wrapped by
+	// TrackRateLimits.
+	client := &GitlabClient{httpClient: &http.Client{}}
 
 	// Create the client differently depending on the base URL.
 	if hostname == "gitlab.com" {
-		glClient, err := gitlab.NewClient(token)
+		glClient, err := gitlab.NewClient(token, gitlab.WithHTTPClient(client.httpClient))
 		if err != nil {
 			return nil, err
 		}
@@ -81,7 +94,7 @@ func NewGitlabClient(hostname string, token string, logger logging.SimpleLogging
 		// Now we're ready to construct the client.
 		absoluteURL = strings.TrimSuffix(absoluteURL, "/")
 		apiURL := fmt.Sprintf("%s/api/v4/", absoluteURL)
-		glClient, err := gitlab.NewClient(token, gitlab.WithBaseURL(apiURL))
+		glClient, err := gitlab.NewClient(token, gitlab.WithBaseURL(apiURL), gitlab.WithHTTPClient(client.httpClient))
 		if err != nil {
 			return nil, err
 		}
@@ -101,6 +114,12 @@ func NewGitlabClient(hostname string, token string, logger logging.SimpleLogging
 	return client, nil
 }
 
+// TrackRateLimits records the rate limits of the responses to the client's
+// requests in limits.
+func (g *GitlabClient) TrackRateLimits(limits *RateLimits) {
+	g.httpClient.Transport = limits.Transport(models.Gitlab, g.httpClient.Transport)
+}
+
 // GetModifiedFiles returns the names of files that were modified in the merge request
 // relative to the repo root, e.g. parent/child/file.txt.
 func (g *GitlabClient) GetModifiedFiles(repo models.Repo, pull models.PullRequest) ([]string, error) {
@@ -144,11 +163,95 @@ func (g *GitlabClient) GetModifiedFiles(repo models.Repo, pull models.PullReques
 
 // CreateComment creates a comment on the merge request.
 func (g *GitlabClient) CreateComment(repo models.Repo, pullNum int, comment string, command string) error {
-	_, _, err := g.Client.Notes.CreateMergeRequestNote(repo.FullName, pullNum, &gitlab.CreateMergeRequestNoteOptions{Body: gitlab.String(comment)})
+	comments := []string{comment}
+	if g.MaxCommentLength > 0 {
+		sepEnd := "\n```\n</details>" +
+			"\n<br>\n\n**Warning**: Output length greater than max comment size. Continued in next comment."
+		sepStart := "Continued from previous comment.\n<details><summary>Show Output</summary>\n\n" +
+			"```diff\n"
+		comments = common.SplitComment(comment, g.MaxCommentLength, sepEnd, sepStart)
+	}
+	if g.PlanDiscussions && command == models.PlanCommand.String() {
+		return g.createDiscussion(repo, pullNum, comments)
+	}
+	for _, c := range comments {
+		if _, _, err := g.Client.Notes.CreateMergeRequestNote(repo.FullName, pullNum, &gitlab.CreateMergeRequestNoteOptions{Body: gitlab.String(c)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createDiscussion creates a discussion on the merge request with the first
+// of comments and replies to it with the rest.
+func (g *GitlabClient) createDiscussion(repo models.Repo, pullNum int, comments []string) error {
+	discussion, _, err := g.Client.Discussions.CreateMergeRequestDiscussion(repo.FullName, pullNum, &gitlab.CreateMergeRequestDiscussionOptions{Body: gitlab.String(comments[0])})
+	if err != nil {
+		return err
+	}
+	for _, c := range comments[1:] {
+		if _, _, err := g.Client.Discussions.AddMergeRequestDiscussionNote(repo.FullName, pullNum, discussion.ID, &gitlab.AddMergeRequestDiscussionNoteOptions{Body: gitlab.String(c)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateEditableComment creates comment on the merge request and returns its
+// ID.
+func (g *GitlabClient) CreateEditableComment(repo models.Repo, pullNum int, comment string) (int64, error) {
+	note, _, err := g.Client.Notes.CreateMergeRequestNote(repo.FullName, pullNum, &gitlab.CreateMergeRequestNoteOptions{Body: gitlab.String(comment)})
+	if err != nil {
+		return 0, err
+	}
+	return int64(note.ID), nil
+}
+
+// EditComment replaces the body of the comment commentID.
+func (g *GitlabClient) EditComment(repo models.Repo, pullNum int, commentID int64, comment string) error {
+	_, _, err := g.Client.Notes.UpdateMergeRequestNote(repo.FullName, pullNum, int(commentID), &gitlab.UpdateMergeRequestNoteOptions{Body: gitlab.String(comment)})
 	return err
 }
 
+// HidePrevCommandComments resolves the unresolved discussions of the Atlantis
+// user for command. Only plan comments are posted as discussions, and only if
+// PlanDiscussions is set, so other comments aren't hidden.
 func (g *GitlabClient) HidePrevCommandComments(repo models.Repo, pullNum int, command string) error {
+	if !g.PlanDiscussions {
+		return nil
+	}
+	var discussions []*gitlab.Discussion
+	opts := &gitlab.ListMergeRequestDiscussionsOptions{PerPage: 100}
+	for {
+		page, resp, err := g.Client.Discussions.ListMergeRequestDiscussions(repo.FullName, pullNum, opts)
+		if err != nil {
+			return errors.Wrap(err, "listing discussions")
+		}
+		discussions = append(discussions, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	for _, discussion := range discussions {
+		if len(discussion.Notes) == 0 {
+			continue
+		}
+		note := discussion.Notes[0]
+		if !note.Resolvable || note.Resolved || !strings.EqualFold(note.Author.Username, g.User) {
+			continue
+		}
+		// Like on GitHub, the comments of a command include its name in
+		// their first line.
+		firstLine := strings.ToLower(strings.Split(note.Body, "\n")[0])
+		if !strings.Contains(firstLine, strings.ToLower(command)) {
+			continue
+		}
+		if _, _, err := g.Client.Discussions.ResolveMergeRequestDiscussion(repo.FullName, pullNum, discussion.ID, &gitlab.ResolveMergeRequestDiscussionOptions{Resolved: gitlab.Bool(true)}); err != nil {
+			return errors.Wrapf(err, "resolving discussion %s", discussion.ID)
+		}
+	}
 	return nil
 }
 
@@ -164,6 +267,24 @@ func (g *GitlabClient) PullIsApproved(repo models.Repo, pull models.PullRequest)
 	return true, nil
 }
 
+// GetApprovals returns the approvals of the merge request. GitLab doesn't
+// report when or on which commit an approval was given and removes approvals
+// on new commits if the project is configured to.
+func (g *GitlabClient) GetApprovals(repo models.Repo, pull models.PullRequest) ([]models.Approval, error) {
+	mrApprovals, _, err := g.Client.MergeRequests.GetMergeRequestApprovals(repo.FullName, pull.Num)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting merge request approvals")
+	}
+	var approvals []models.Approval
+	for _, approver := range mrApprovals.ApprovedBy {
+		if approver == nil || approver.User == nil {
+			continue
+		}
+		approvals = append(approvals, models.Approval{Username: approver.User.Username})
+	}
+	return approvals, nil
+}
+
 // PullIsMergeable returns true if the merge request can be merged.
 // In GitLab, there isn't a single field that tells us if the pull request is
 // mergeable so for now we check the merge_status and approvals_before_merge
@@ -188,6 +309,15 @@ func (g *GitlabClient) PullIsMergeable(repo models.Repo, pull models.PullRequest
 	return false, nil
 }
 
+// PullIsClosed returns true if the merge request was closed or merged.
+func (g *GitlabClient) PullIsClosed(repo models.Repo, pull models.PullRequest) (bool, error) {
+	mr, _, err := g.Client.MergeRequests.GetMergeRequest(repo.FullName, pull.Num, nil)
+	if err != nil {
+		return false, err
+	}
+	return mr.State == "closed" || mr.State == "merged", nil
+}
+
 // UpdateStatus updates the build status of a commit.
 func (g *GitlabClient) UpdateStatus(repo models.Repo, pull models.PullRequest, state models.CommitStatus, src string, description string, url string) error {
 	gitlabState := gitlab.Failed
@@ -215,7 +345,7 @@ func (g *GitlabClient) GetMergeRequest(repoFullName string, pullNum int) (*gitla
 
 // MergePull merges the merge request.
 func (g *GitlabClient) MergePull(pull models.PullRequest, pullOptions models.PullRequestOptions) error {
-	commitMsg := common.AutomergeCommitMsg
+	commitMsg := common.AutomergeCommitMsgOrDefault(pullOptions.CommitMessage)
 	_, _, err := g.Client.MergeRequests.AcceptMergeRequest(
 		pull.BaseRepo.FullName,
 		pull.Num,
@@ -292,6 +422,163 @@ func (g *GitlabClient) DownloadRepoConfigFile(pull models.PullRequest) (bool, []
 	return true, bytes, nil
 }
 
+// CheckConnection returns an error if GitLab can't be reached or rejects the
+// token.
+func (g *GitlabClient) CheckConnection() error {
+	_, _, err := g.Client.Users.CurrentUser()
+	return err
+}
+
 func (g *GitlabClient) SupportsSingleFileDownload(repo models.Repo) bool {
 	return true
 }
+
+// GetTeamNamesForUser returns the groups of teams that user is an active
+// member of with at least developer access.
+func (g *GitlabClient) GetTeamNamesForUser(repo models.Repo, user models.User, teams []string) ([]string, error) {
+	if len(teams) == 0 {
+		return nil, nil
+	}
+	users, _, err := g.Client.Users.ListUsers(&gitlab.ListUsersOptions{Username: gitlab.String(user.Username)})
+	if err != nil {
+		return nil, errors.Wrapf(err, "looking up user %s", user.Username)
+	}
+	if len(users) != 1 {
+		return nil, fmt.Errorf("expected 1 user with username %s, found %d", user.Username, len(users))
+	}
+
+	var groupNames []string
+	for _, group := range teams {
+		member, resp, err := g.Client.GroupMembers.GetGroupMember(group, users[0].ID)
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting membership of %s in group %s", user.Username, group)
+		}
+		if member.State == "active" && member.AccessLevel >= gitlab.DeveloperPermissions {
+			groupNames = append(groupNames, group)
+		}
+	}
+	return groupNames, nil
+}
+
+// FindOpenIssue returns the open issue of repo titled title, or nil if
+// there's none.
+func (g *GitlabClient) FindOpenIssue(repo models.Repo, title string) (*models.Issue, error) {
+	opts := &gitlab.ListProjectIssuesOptions{
+		State:       gitlab.String("opened"),
+		Search:      gitlab.String(title),
+		In:          gitlab.String("title"),
+		ListOptions: gitlab.ListOptions{PerPage: 100},
+	}
+	for {
+		issues, resp, err := g.Client.Issues.ListProjectIssues(repo.FullName, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "listing issues of %s", repo.FullName)
+		}
+		// The search matches titles containing title so it's checked again.
+		for _, i := range issues {
+			if i.Title != title {
+				continue
+			}
+			return &models.Issue{
+				Number: i.IID,
+				URL:    i.WebURL,
+				Title:  i.Title,
+				Body:   i.Description,
+			}, nil
+		}
+		if resp.NextPage == 0 {
+			return nil, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// CreateIssue creates issue in repo. Since GitLab assigns issues by user ID,
+// the assignees are looked up by username.
+func (g *GitlabClient) CreateIssue(repo models.Repo, issue models.Issue) (models.Issue, error) {
+	opts := &gitlab.CreateIssueOptions{
+		Title:       gitlab.String(issue.Title),
+		Description: gitlab.String(issue.Body),
+		Labels:      issue.Labels,
+	}
+	for _, username := range issue.Assignees {
+		users, _, err := g.Client.Users.ListUsers(&gitlab.ListUsersOptions{Username: gitlab.String(username)})
+		if err != nil {
+			return issue, errors.Wrapf(err, "looking up user %s", username)
+		}
+		if len(users) != 1 {
+			return issue, fmt.Errorf("expected 1 user with username %s, found %d", username, len(users))
+		}
+		opts.AssigneeIDs = append(opts.AssigneeIDs, users[0].ID)
+	}
+	created, _, err := g.Client.Issues.CreateIssue(repo.FullName, opts)
+	if err != nil {
+		return issue, errors.Wrapf(err, "creating issue in %s", repo.FullName)
+	}
+	issue.Number = created.IID
+	issue.URL = created.WebURL
+	return issue, nil
+}
+
+// CreatePullRequest opens a merge request in repo from headBranch into
+// baseBranch.
+func (g *GitlabClient) CreatePullRequest(repo models.Repo, title string, body string, headBranch string, baseBranch string) (models.PullRequest, error) {
+	created, _, err := g.Client.MergeRequests.CreateMergeRequest(repo.FullName, &gitlab.CreateMergeRequestOptions{
+		Title:        gitlab.String(title),
+		Description:  gitlab.String(body),
+		SourceBranch: gitlab.String(headBranch),
+		TargetBranch: gitlab.String(baseBranch),
+	})
+	if err != nil {
+		return models.PullRequest{}, errors.Wrapf(err, "creating merge request in %s", repo.FullName)
+	}
+	return models.PullRequest{
+		Num:        created.IID,
+		URL:        created.WebURL,
+		Title:      title,
+		HeadBranch: headBranch,
+		BaseBranch: baseBranch,
+		State:      models.OpenPullState,
+		BaseRepo:   repo,
+	}, nil
+}
+
+// GetPipeline returns the head pipeline of the merge request and its CI jobs.
+func (g *GitlabClient) GetPipeline(repo models.Repo, pull models.PullRequest) (models.Pipeline, error) {
+	mr, _, err := g.Client.MergeRequests.GetMergeRequest(repo.FullName, pull.Num, nil)
+	if err != nil {
+		return models.Pipeline{}, errors.Wrap(err, "getting merge request")
+	}
+	if mr.HeadPipeline == nil {
+		return models.Pipeline{}, nil
+	}
+	pipeline := models.Pipeline{
+		ID:     mr.HeadPipeline.ID,
+		SHA:    mr.HeadPipeline.SHA,
+		Status: mr.HeadPipeline.Status,
+	}
+	// The jobs API only returns CI jobs, not commit statuses like the ones
+	// Atlantis sets, and only the latest run of retried jobs.
+	opts := &gitlab.ListJobsOptions{ListOptions: gitlab.ListOptions{PerPage: 100}}
+	for {
+		jobs, resp, err := g.Client.Jobs.ListPipelineJobs(repo.FullName, pipeline.ID, opts)
+		if err != nil {
+			return models.Pipeline{}, errors.Wrapf(err, "listing jobs of pipeline %d", pipeline.ID)
+		}
+		for _, job := range jobs {
+			pipeline.Jobs = append(pipeline.Jobs, models.PipelineJob{
+				Name:         job.Name,
+				Status:       job.Status,
+				AllowFailure: job.AllowFailure,
+			})
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return pipeline, nil
+}
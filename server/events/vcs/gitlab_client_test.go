@@ -1,10 +1,12 @@
 package vcs
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	version "github.com/hashicorp/go-version"
@@ -242,6 +244,49 @@ func TestGitlabClient_UpdateStatus(t *testing.T) {
 	}
 }
 
+func TestGitlabClient_CreateComment_Split(t *testing.T) {
+	for _, maxLength := range []int{0, 1000} {
+		t.Run(fmt.Sprintf("%d", maxLength), func(t *testing.T) {
+			var notes []string
+			testServer := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					switch r.Method + " " + r.RequestURI {
+					case "POST /api/v4/projects/runatlantis%2Fatlantis/merge_requests/1/notes":
+						var body struct {
+							Body string `json:"body"`
+						}
+						Ok(t, json.NewDecoder(r.Body).Decode(&body))
+						notes = append(notes, body.Body)
+						w.Write([]byte(`{"id": 1}`)) // nolint: errcheck
+					case "GET /api/v4/":
+						// Rate limiter requests.
+						w.WriteHeader(http.StatusOK)
+					default:
+						t.Errorf("got unexpected request at %q", r.RequestURI)
+						http.Error(w, "not found", http.StatusNotFound)
+					}
+				}))
+			defer testServer.Close()
+
+			internalClient, err := gitlab.NewClient("token", gitlab.WithBaseURL(testServer.URL))
+			Ok(t, err)
+			client := &GitlabClient{Client: internalClient, MaxCommentLength: maxLength}
+			repo := models.Repo{FullName: "runatlantis/atlantis"}
+			Ok(t, client.CreateComment(repo, 1, strings.Repeat("a", 1500), "plan"))
+
+			if maxLength == 0 {
+				Equals(t, 1, len(notes))
+				return
+			}
+			Equals(t, 2, len(notes))
+			for _, note := range notes {
+				Assert(t, len(note) <= maxLength, "note of length %d is longer than %d", len(note), maxLength)
+			}
+			Assert(t, strings.HasPrefix(notes[1], "Continued from previous comment."), "exp second note to be continued but was %q", notes[1])
+		})
+	}
+}
+
 func TestGitlabClient_MarkdownPullLink(t *testing.T) {
 	gitlabClientUnderTest = true
 	defer func() { gitlabClientUnderTest = false }()
@@ -254,3 +299,176 @@ func TestGitlabClient_MarkdownPullLink(t *testing.T) {
 }
 
 var mergeSuccess = `{"id":22461274,"iid":13,"project_id":4580910,"title":"Update main.tf","description":"","state":"merged","created_at":"2019-01-15T18:27:29.375Z","updated_at":"2019-01-25T17:28:01.437Z","merged_by":{"id":1755902,"name":"Luke Kysow","username":"lkysow","state":"active","avatar_url":"https://secure.gravatar.com/avatar/25fd57e71590fe28736624ff24d41c5f?s=80\u0026d=identicon","web_url":"https://gitlab.com/lkysow"},"merged_at":"2019-01-25T17:28:01.459Z","closed_by":null,"closed_at":null,"target_branch":"patch-1","source_branch":"patch-1-merger","upvotes":0,"downvotes":0,"author":{"id":1755902,"name":"Luke Kysow","username":"lkysow","state":"active","avatar_url":"https://secure.gravatar.com/avatar/25fd57e71590fe28736624ff24d41c5f?s=80\u0026d=identicon","web_url":"https://gitlab.com/lkysow"},"assignee":null,"source_project_id":4580910,"target_project_id":4580910,"labels":[],"work_in_progress":false,"milestone":null,"merge_when_pipeline_succeeds":false,"merge_status":"can_be_merged","sha":"cb86d70f464632bdfbe1bb9bc0f2f9d847a774a0","merge_commit_sha":"c9b336f1c71d3e64810b8cfa2abcfab232d6bff6","user_notes_count":0,"discussion_locked":null,"should_remove_source_branch":null,"force_remove_source_branch":false,"web_url":"https://gitlab.com/lkysow/atlantis-example/merge_requests/13","time_stats":{"time_estimate":0,"total_time_spent":0,"human_time_estimate":null,"human_total_time_spent":null},"squash":false,"subscribed":true,"changes_count":"1","latest_build_started_at":null,"latest_build_finished_at":null,"first_deployed_to_production_at":null,"pipeline":null,"diff_refs":{"base_sha":"67cb91d3f6198189f433c045154a885784ba6977","head_sha":"cb86d70f464632bdfbe1bb9bc0f2f9d847a774a0","start_sha":"67cb91d3f6198189f433c045154a885784ba6977"},"merge_error":null,"approvals_before_merge":null}`
+
+func TestGitlabClient_Issues(t *testing.T) {
+	var created map[string]interface{}
+	testServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method + " " + r.RequestURI {
+			case "GET /api/v4/projects/owner%2Frepo/issues?in=title&per_page=100&search=Drift&state=opened":
+				// The search matches titles that contain it.
+				w.Write([]byte(`[
+					{"id": 101, "iid": 1, "title": "Drift in staging"},
+					{"id": 102, "iid": 2, "title": "Drift", "web_url": "https://gitlab.com/owner/repo/-/issues/2"}
+				]`)) // nolint: errcheck
+			case "GET /api/v4/users?username=alice":
+				w.Write([]byte(`[{"id": 42, "username": "alice"}]`)) // nolint: errcheck
+			case "POST /api/v4/projects/owner%2Frepo/issues":
+				if err := json.NewDecoder(r.Body).Decode(&created); err != nil {
+					t.Errorf("parse body error: %v", err)
+				}
+				w.Write([]byte(`{"id": 103, "iid": 3, "web_url": "https://gitlab.com/owner/repo/-/issues/3"}`)) // nolint: errcheck
+			case "GET /api/v4/":
+				// Rate limiter requests.
+				w.WriteHeader(http.StatusOK)
+			default:
+				t.Errorf("got unexpected request %s %q", r.Method, r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		}))
+
+	internalClient, err := gitlab.NewClient("token", gitlab.WithBaseURL(testServer.URL))
+	Ok(t, err)
+	client := &GitlabClient{Client: internalClient}
+	repo := models.Repo{
+		FullName: "owner/repo",
+		Owner:    "owner",
+		Name:     "repo",
+	}
+
+	issue, err := client.FindOpenIssue(repo, "Drift")
+	Ok(t, err)
+	Equals(t, &models.Issue{Number: 2, URL: "https://gitlab.com/owner/repo/-/issues/2", Title: "Drift"}, issue)
+
+	newIssue, err := client.CreateIssue(repo, models.Issue{Title: "New", Body: "body", Labels: []string{"drift"}, Assignees: []string{"alice"}})
+	Ok(t, err)
+	Equals(t, 3, newIssue.Number)
+	Equals(t, "https://gitlab.com/owner/repo/-/issues/3", newIssue.URL)
+	Equals(t, map[string]interface{}{
+		"title":        "New",
+		"description":  "body",
+		"labels":       "drift",
+		"assignee_ids": []interface{}{float64(42)},
+	}, created)
+}
+
+func TestGitlabClient_GetPipeline(t *testing.T) {
+	headPipeline := `{"id": 7, "sha": "abc123", "status": "running"}`
+	testServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method + " " + r.RequestURI {
+			case "GET /api/v4/projects/owner%2Frepo/merge_requests/1":
+				w.Write([]byte(fmt.Sprintf(`{"iid": 1, "head_pipeline": %s}`, headPipeline))) // nolint: errcheck
+			case "GET /api/v4/projects/owner%2Frepo/pipelines/7/jobs?per_page=100":
+				w.Header().Set("X-Next-Page", "2")
+				w.Write([]byte(`[{"name": "test", "status": "success"}]`)) // nolint: errcheck
+			case "GET /api/v4/projects/owner%2Frepo/pipelines/7/jobs?page=2&per_page=100":
+				w.Write([]byte(`[{"name": "lint", "status": "failed", "allow_failure": true}]`)) // nolint: errcheck
+			case "GET /api/v4/":
+				// Rate limiter requests.
+				w.WriteHeader(http.StatusOK)
+			default:
+				t.Errorf("got unexpected request %s %q", r.Method, r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		}))
+
+	internalClient, err := gitlab.NewClient("token", gitlab.WithBaseURL(testServer.URL))
+	Ok(t, err)
+	client := &GitlabClient{Client: internalClient}
+	repo := models.Repo{FullName: "owner/repo"}
+	pull := models.PullRequest{Num: 1}
+
+	pipeline, err := client.GetPipeline(repo, pull)
+	Ok(t, err)
+	Equals(t, models.Pipeline{
+		ID:     7,
+		SHA:    "abc123",
+		Status: "running",
+		Jobs: []models.PipelineJob{
+			{Name: "test", Status: "success"},
+			{Name: "lint", Status: "failed", AllowFailure: true},
+		},
+	}, pipeline)
+
+	// Merge requests without a pipeline have no head pipeline.
+	headPipeline = "null"
+	pipeline, err = client.GetPipeline(repo, pull)
+	Ok(t, err)
+	Equals(t, models.Pipeline{}, pipeline)
+}
+
+func TestGitlabClient_PlanDiscussions(t *testing.T) {
+	var created, replied []string
+	var resolved []string
+	testServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			switch r.Method + " " + r.RequestURI {
+			case "POST /api/v4/projects/owner%2Frepo/merge_requests/1/discussions":
+				created = append(created, string(body))
+				w.Write([]byte(`{"id": "new"}`)) // nolint: errcheck
+			case "POST /api/v4/projects/owner%2Frepo/merge_requests/1/discussions/new/notes":
+				replied = append(replied, string(body))
+				w.Write([]byte(`{"id": 2}`)) // nolint: errcheck
+			case "GET /api/v4/projects/owner%2Frepo/merge_requests/1/discussions?per_page=100":
+				w.Write([]byte(`[
+					{"id": "plan", "notes": [{"body": "Ran Plan for dir: ` + "`.`" + `\nmore", "author": {"username": "Atlantis"}, "resolvable": true}]},
+					{"id": "resolved", "notes": [{"body": "Ran Plan", "author": {"username": "atlantis"}, "resolvable": true, "resolved": true}]},
+					{"id": "apply", "notes": [{"body": "Ran Apply", "author": {"username": "atlantis"}, "resolvable": true}]},
+					{"id": "user", "notes": [{"body": "atlantis plan", "author": {"username": "alice"}, "resolvable": true}]},
+					{"id": "note", "notes": [{"body": "Ran Plan", "author": {"username": "atlantis"}}]}
+				]`)) // nolint: errcheck
+			case "PUT /api/v4/projects/owner%2Frepo/merge_requests/1/discussions/plan":
+				resolved = append(resolved, string(body))
+				w.Write([]byte(`{"id": "plan"}`)) // nolint: errcheck
+			case "GET /api/v4/":
+				// Rate limiter requests.
+				w.WriteHeader(http.StatusOK)
+			default:
+				t.Errorf("got unexpected request %s %q", r.Method, r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		}))
+
+	internalClient, err := gitlab.NewClient("token", gitlab.WithBaseURL(testServer.URL))
+	Ok(t, err)
+	client := &GitlabClient{Client: internalClient, MaxCommentLength: 500, PlanDiscussions: true, User: "atlantis"}
+	repo := models.Repo{FullName: "owner/repo"}
+
+	Ok(t, client.HidePrevCommandComments(repo, 1, "Plan"))
+	Equals(t, []string{`{"resolved":true}`}, resolved)
+
+	Ok(t, client.CreateComment(repo, 1, strings.Repeat("a", 600), "plan"))
+	Equals(t, 1, len(created))
+	Equals(t, 1, len(replied))
+}
+
+// Test that plan comments are notes and aren't resolved if plan discussions
+// are disabled.
+func TestGitlabClient_PlanDiscussionsDisabled(t *testing.T) {
+	var notes int
+	testServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method + " " + r.RequestURI {
+			case "POST /api/v4/projects/owner%2Frepo/merge_requests/1/notes":
+				notes++
+				w.Write([]byte(`{"id": 1}`)) // nolint: errcheck
+			case "GET /api/v4/":
+				// Rate limiter requests.
+				w.WriteHeader(http.StatusOK)
+			default:
+				t.Errorf("got unexpected request %s %q", r.Method, r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		}))
+
+	internalClient, err := gitlab.NewClient("token", gitlab.WithBaseURL(testServer.URL))
+	Ok(t, err)
+	client := &GitlabClient{Client: internalClient, User: "atlantis"}
+	repo := models.Repo{FullName: "owner/repo"}
+
+	Ok(t, client.HidePrevCommandComments(repo, 1, "Plan"))
+	Ok(t, client.CreateComment(repo, 1, "Ran Plan", "plan"))
+	Equals(t, 1, notes)
+}
@@ -0,0 +1,151 @@
+package vcs
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// The types of GitLab access tokens. Group and project access tokens belong
+// to a bot user that's created with the token.
+const (
+	GitlabPersonalToken = "personal"
+	GitlabGroupToken    = "group"
+	GitlabProjectToken  = "project"
+)
+
+// GitlabTokenTypes are the supported types of GitLab access tokens.
+var GitlabTokenTypes = []string{GitlabPersonalToken, GitlabGroupToken, GitlabProjectToken}
+
+// GitlabCredentials is the access token that Atlantis authenticates to GitLab
+// with. If it's read from a file, the file can be reloaded to rotate the
+// token without restarting Atlantis. Its methods are safe for concurrent use.
+type GitlabCredentials struct {
+	// Type is the type of the token, one of GitlabTokenTypes.
+	Type string
+	// File is the file that the token is read from. If empty, the token
+	// can't be reloaded.
+	File string
+
+	mutex sync.RWMutex
+	token string
+}
+
+// NewGitlabCredentials returns the credentials of a token of tokenType. If
+// file is set, the token is read from it instead.
+func NewGitlabCredentials(tokenType string, token string, file string) (*GitlabCredentials, error) {
+	c := &GitlabCredentials{Type: tokenType, File: file, token: token}
+	if file != "" {
+		if _, err := c.Reload(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Token returns the current token.
+func (c *GitlabCredentials) Token() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.token
+}
+
+// Reload reads the token from File again and returns true if it changed.
+func (c *GitlabCredentials) Reload() (bool, error) {
+	if c.File == "" {
+		return false, nil
+	}
+	contents, err := ioutil.ReadFile(c.File)
+	if err != nil {
+		return false, errors.Wrapf(err, "reading GitLab token file %s", c.File)
+	}
+	token := strings.TrimSpace(string(contents))
+	if token == "" {
+		return false, errors.Errorf("GitLab token file %s is empty", c.File)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	changed := token != c.token
+	c.token = token
+	return changed, nil
+}
+
+// SetToken replaces the token, ex. when it's rotated in its secret store.
+func (c *GitlabCredentials) SetToken(token string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.token = token
+}
+
+// Transport returns a transport that sends requests with base, or
+// http.DefaultTransport if base is nil, authenticated with the current token.
+func (c *GitlabCredentials) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &gitlabTokenTransport{creds: c, base: base}
+}
+
+type gitlabTokenTransport struct {
+	creds *GitlabCredentials
+	base  http.RoundTripper
+}
+
+func (t *gitlabTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Requests must not be modified so the header is set on a copy.
+	req = req.Clone(req.Context())
+	req.Header.Set("PRIVATE-TOKEN", t.creds.Token())
+	return t.base.RoundTrip(req)
+}
+
+// GitlabTokenInfo describes a GitLab access token.
+type GitlabTokenInfo struct {
+	Name   string
+	Scopes []string
+	// ExpiresAt is the day the token expires at midnight UTC. If nil, it
+	// never expires.
+	ExpiresAt *time.Time
+	Active    bool
+	Revoked   bool
+}
+
+// UseCredentials authenticates the client's requests with creds so that the
+// token can be rotated.
+func (g *GitlabClient) UseCredentials(creds *GitlabCredentials) {
+	g.httpClient.Transport = creds.Transport(g.httpClient.Transport)
+}
+
+// GetTokenInfo returns the client's access token. Personal, group and project
+// access tokens can all describe themselves, which requires GitLab 15.5.
+func (g *GitlabClient) GetTokenInfo() (GitlabTokenInfo, error) {
+	req, err := g.Client.NewRequest(http.MethodGet, "personal_access_tokens/self", nil, nil)
+	if err != nil {
+		return GitlabTokenInfo{}, err
+	}
+	var token struct {
+		Name      string          `json:"name"`
+		Scopes    []string        `json:"scopes"`
+		ExpiresAt *gitlab.ISOTime `json:"expires_at"`
+		Active    bool            `json:"active"`
+		Revoked   bool            `json:"revoked"`
+	}
+	if _, err := g.Client.Do(req, &token); err != nil {
+		return GitlabTokenInfo{}, errors.Wrap(err, "getting access token")
+	}
+	info := GitlabTokenInfo{
+		Name:    token.Name,
+		Scopes:  token.Scopes,
+		Active:  token.Active,
+		Revoked: token.Revoked,
+	}
+	if token.ExpiresAt != nil {
+		expiresAt := time.Time(*token.ExpiresAt).UTC()
+		info.ExpiresAt = &expiresAt
+	}
+	return info, nil
+}
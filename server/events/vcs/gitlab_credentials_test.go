@@ -0,0 +1,103 @@
+package vcs
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gitlab "github.com/xanzy/go-gitlab"
+
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestGitlabCredentials_Reload(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	file := filepath.Join(tmp, "token")
+	Ok(t, ioutil.WriteFile(file, []byte("token1\n"), 0600))
+
+	creds, err := NewGitlabCredentials(GitlabGroupToken, "", file)
+	Ok(t, err)
+	Equals(t, "token1", creds.Token())
+
+	changed, err := creds.Reload()
+	Ok(t, err)
+	Equals(t, false, changed)
+
+	Ok(t, ioutil.WriteFile(file, []byte("token2"), 0600))
+	changed, err = creds.Reload()
+	Ok(t, err)
+	Equals(t, true, changed)
+	Equals(t, "token2", creds.Token())
+
+	// The previous token is kept if the file can't be read.
+	Ok(t, ioutil.WriteFile(file, []byte(" \n"), 0600))
+	_, err = creds.Reload()
+	ErrEquals(t, "GitLab token file "+file+" is empty", err)
+	Equals(t, "token2", creds.Token())
+}
+
+// Test that credentials without a file keep their token.
+func TestGitlabCredentials_NoFile(t *testing.T) {
+	creds, err := NewGitlabCredentials(GitlabPersonalToken, "token", "")
+	Ok(t, err)
+	changed, err := creds.Reload()
+	Ok(t, err)
+	Equals(t, false, changed)
+	Equals(t, "token", creds.Token())
+
+	creds.SetToken("rotated")
+	Equals(t, "rotated", creds.Token())
+}
+
+func TestGitlabClient_GetTokenInfo(t *testing.T) {
+	var tokens []string
+	testServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method + " " + r.RequestURI {
+			case "GET /api/v4/personal_access_tokens/self":
+				tokens = append(tokens, r.Header.Get("PRIVATE-TOKEN"))
+				w.Write([]byte(`{"name": "atlantis", "scopes": ["api"], "expires_at": "2021-06-01", "active": true, "revoked": false}`)) // nolint: errcheck
+			case "GET /api/v4/":
+				// Rate limiter requests.
+				w.WriteHeader(http.StatusOK)
+			default:
+				t.Errorf("got unexpected request %s %q", r.Method, r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		}))
+
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	file := filepath.Join(tmp, "token")
+	Ok(t, ioutil.WriteFile(file, []byte("token1"), 0600))
+	creds, err := NewGitlabCredentials(GitlabProjectToken, "", file)
+	Ok(t, err)
+
+	httpClient := &http.Client{}
+	internalClient, err := gitlab.NewClient(creds.Token(), gitlab.WithBaseURL(testServer.URL), gitlab.WithHTTPClient(httpClient))
+	Ok(t, err)
+	client := &GitlabClient{Client: internalClient, httpClient: httpClient}
+	client.UseCredentials(creds)
+
+	info, err := client.GetTokenInfo()
+	Ok(t, err)
+	expiresAt := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	Equals(t, GitlabTokenInfo{
+		Name:      "atlantis",
+		Scopes:    []string{"api"},
+		ExpiresAt: &expiresAt,
+		Active:    true,
+	}, info)
+
+	// Requests use the rotated token.
+	Ok(t, ioutil.WriteFile(file, []byte("token2"), 0600))
+	_, err = creds.Reload()
+	Ok(t, err)
+	_, err = client.GetTokenInfo()
+	Ok(t, err)
+	Equals(t, []string{"token1", "token2"}, tokens)
+}
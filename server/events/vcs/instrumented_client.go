@@ -0,0 +1,137 @@
+package vcs
+
+import (
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/tracing"
+)
+
+// InstrumentedClient records a tracing span for each VCS API call and logs it
+// with the correlation ID of the command that made it. Since the Client
+// methods don't take a context, calls are attributed to the command running
+// for the same pull request. Calls made while no command is running for the
+// pull request aren't recorded.
+type InstrumentedClient struct {
+	Client
+	Tracer *tracing.Tracer
+	Logger logging.SimpleLogging
+}
+
+// NewInstrumentedClient returns client instrumented with tracer and logger.
+func NewInstrumentedClient(client Client, tracer *tracing.Tracer, logger logging.SimpleLogging) *InstrumentedClient {
+	return &InstrumentedClient{Client: client, Tracer: tracer, Logger: logger}
+}
+
+func (c *InstrumentedClient) GetModifiedFiles(repo models.Repo, pull models.PullRequest) ([]string, error) {
+	done := c.start("GetModifiedFiles", repo, pull.Num)
+	files, err := c.Client.GetModifiedFiles(repo, pull)
+	done(err)
+	return files, err
+}
+
+func (c *InstrumentedClient) CreateComment(repo models.Repo, pullNum int, comment string, command string) error {
+	done := c.start("CreateComment", repo, pullNum)
+	err := c.Client.CreateComment(repo, pullNum, comment, command)
+	done(err)
+	return err
+}
+
+func (c *InstrumentedClient) CreateEditableComment(repo models.Repo, pullNum int, comment string) (int64, error) {
+	done := c.start("CreateEditableComment", repo, pullNum)
+	id, err := c.Client.CreateEditableComment(repo, pullNum, comment)
+	done(err)
+	return id, err
+}
+
+func (c *InstrumentedClient) EditComment(repo models.Repo, pullNum int, commentID int64, comment string) error {
+	done := c.start("EditComment", repo, pullNum)
+	err := c.Client.EditComment(repo, pullNum, commentID, comment)
+	done(err)
+	return err
+}
+
+func (c *InstrumentedClient) HidePrevCommandComments(repo models.Repo, pullNum int, command string) error {
+	done := c.start("HidePrevCommandComments", repo, pullNum)
+	err := c.Client.HidePrevCommandComments(repo, pullNum, command)
+	done(err)
+	return err
+}
+
+func (c *InstrumentedClient) PullIsApproved(repo models.Repo, pull models.PullRequest) (bool, error) {
+	done := c.start("PullIsApproved", repo, pull.Num)
+	approved, err := c.Client.PullIsApproved(repo, pull)
+	done(err)
+	return approved, err
+}
+
+func (c *InstrumentedClient) GetApprovals(repo models.Repo, pull models.PullRequest) ([]models.Approval, error) {
+	done := c.start("GetApprovals", repo, pull.Num)
+	approvals, err := c.Client.GetApprovals(repo, pull)
+	done(err)
+	return approvals, err
+}
+
+func (c *InstrumentedClient) PullIsMergeable(repo models.Repo, pull models.PullRequest) (bool, error) {
+	done := c.start("PullIsMergeable", repo, pull.Num)
+	mergeable, err := c.Client.PullIsMergeable(repo, pull)
+	done(err)
+	return mergeable, err
+}
+
+func (c *InstrumentedClient) PullIsClosed(repo models.Repo, pull models.PullRequest) (bool, error) {
+	done := c.start("PullIsClosed", repo, pull.Num)
+	closed, err := c.Client.PullIsClosed(repo, pull)
+	done(err)
+	return closed, err
+}
+
+func (c *InstrumentedClient) UpdateStatus(repo models.Repo, pull models.PullRequest, state models.CommitStatus, src string, description string, url string) error {
+	done := c.start("UpdateStatus", repo, pull.Num)
+	err := c.Client.UpdateStatus(repo, pull, state, src, description, url)
+	done(err)
+	return err
+}
+
+func (c *InstrumentedClient) MergePull(pull models.PullRequest, pullOptions models.PullRequestOptions) error {
+	done := c.start("MergePull", pull.BaseRepo, pull.Num)
+	err := c.Client.MergePull(pull, pullOptions)
+	done(err)
+	return err
+}
+
+func (c *InstrumentedClient) DownloadRepoConfigFile(pull models.PullRequest) (bool, []byte, error) {
+	done := c.start("DownloadRepoConfigFile", pull.BaseRepo, pull.Num)
+	hasFile, content, err := c.Client.DownloadRepoConfigFile(pull)
+	done(err)
+	return hasFile, content, err
+}
+
+// start starts recording a call to method for repo's pull request pullNum.
+// The returned func must be called with the call's error once it returns.
+func (c *InstrumentedClient) start(method string, repo models.Repo, pullNum int) func(error) {
+	parent := c.Tracer.Active(repo.FullName, pullNum)
+	if parent == nil {
+		return func(error) {}
+	}
+	span := parent.Start("vcs "+method,
+		tracing.String("vcs.host", repo.VCSHost.Type.String()),
+		tracing.String("vcs.method", method))
+	span.SetKind(tracing.ClientKind)
+	start := time.Now()
+	return func(err error) {
+		span.RecordError(err)
+		span.End()
+		if c.Logger == nil {
+			return
+		}
+		// The command's correlation ID is the ID of its trace.
+		log := c.Logger.With(logging.CorrelationIDKey, parent.TraceID())
+		if err != nil {
+			log.Debug("%s for %s#%d failed after %s: %s", method, repo.FullName, pullNum, time.Since(start), err)
+			return
+		}
+		log.Debug("%s for %s#%d took %s", method, repo.FullName, pullNum, time.Since(start))
+	}
+}
@@ -32,15 +32,27 @@ func (a *NotConfiguredVCSClient) GetModifiedFiles(repo models.Repo, pull models.
 func (a *NotConfiguredVCSClient) CreateComment(repo models.Repo, pullNum int, comment string, command string) error {
 	return a.err()
 }
+func (a *NotConfiguredVCSClient) CreateEditableComment(repo models.Repo, pullNum int, comment string) (int64, error) {
+	return 0, a.err()
+}
+func (a *NotConfiguredVCSClient) EditComment(repo models.Repo, pullNum int, commentID int64, comment string) error {
+	return a.err()
+}
 func (a *NotConfiguredVCSClient) HidePrevCommandComments(repo models.Repo, pullNum int, command string) error {
 	return nil
 }
 func (a *NotConfiguredVCSClient) PullIsApproved(repo models.Repo, pull models.PullRequest) (bool, error) {
 	return false, a.err()
 }
+func (a *NotConfiguredVCSClient) GetApprovals(repo models.Repo, pull models.PullRequest) ([]models.Approval, error) {
+	return nil, a.err()
+}
 func (a *NotConfiguredVCSClient) PullIsMergeable(repo models.Repo, pull models.PullRequest) (bool, error) {
 	return false, a.err()
 }
+func (a *NotConfiguredVCSClient) PullIsClosed(repo models.Repo, pull models.PullRequest) (bool, error) {
+	return false, a.err()
+}
 func (a *NotConfiguredVCSClient) UpdateStatus(repo models.Repo, pull models.PullRequest, state models.CommitStatus, src string, description string, url string) error {
 	return a.err()
 }
@@ -61,3 +73,23 @@ func (a *NotConfiguredVCSClient) SupportsSingleFileDownload(repo models.Repo) bo
 func (a *NotConfiguredVCSClient) DownloadRepoConfigFile(pull models.PullRequest) (bool, []byte, error) {
 	return true, []byte{}, a.err()
 }
+
+func (a *NotConfiguredVCSClient) GetTeamNamesForUser(repo models.Repo, user models.User, teams []string) ([]string, error) {
+	return nil, a.err()
+}
+
+func (a *NotConfiguredVCSClient) FindOpenIssue(repo models.Repo, title string) (*models.Issue, error) {
+	return nil, a.err()
+}
+
+func (a *NotConfiguredVCSClient) CreateIssue(repo models.Repo, issue models.Issue) (models.Issue, error) {
+	return models.Issue{}, a.err()
+}
+
+func (a *NotConfiguredVCSClient) CreatePullRequest(repo models.Repo, title string, body string, headBranch string, baseBranch string) (models.PullRequest, error) {
+	return models.PullRequest{}, a.err()
+}
+
+func (a *NotConfiguredVCSClient) GetPipeline(repo models.Repo, pull models.PullRequest) (models.Pipeline, error) {
+	return models.Pipeline{}, a.err()
+}
@@ -60,6 +60,14 @@ func (d *ClientProxy) CreateComment(repo models.Repo, pullNum int, comment strin
 	return d.clients[repo.VCSHost.Type].CreateComment(repo, pullNum, comment, command)
 }
 
+func (d *ClientProxy) CreateEditableComment(repo models.Repo, pullNum int, comment string) (int64, error) {
+	return d.clients[repo.VCSHost.Type].CreateEditableComment(repo, pullNum, comment)
+}
+
+func (d *ClientProxy) EditComment(repo models.Repo, pullNum int, commentID int64, comment string) error {
+	return d.clients[repo.VCSHost.Type].EditComment(repo, pullNum, commentID, comment)
+}
+
 func (d *ClientProxy) HidePrevCommandComments(repo models.Repo, pullNum int, command string) error {
 	return d.clients[repo.VCSHost.Type].HidePrevCommandComments(repo, pullNum, command)
 }
@@ -68,10 +76,18 @@ func (d *ClientProxy) PullIsApproved(repo models.Repo, pull models.PullRequest)
 	return d.clients[repo.VCSHost.Type].PullIsApproved(repo, pull)
 }
 
+func (d *ClientProxy) GetApprovals(repo models.Repo, pull models.PullRequest) ([]models.Approval, error) {
+	return d.clients[repo.VCSHost.Type].GetApprovals(repo, pull)
+}
+
 func (d *ClientProxy) PullIsMergeable(repo models.Repo, pull models.PullRequest) (bool, error) {
 	return d.clients[repo.VCSHost.Type].PullIsMergeable(repo, pull)
 }
 
+func (d *ClientProxy) PullIsClosed(repo models.Repo, pull models.PullRequest) (bool, error) {
+	return d.clients[repo.VCSHost.Type].PullIsClosed(repo, pull)
+}
+
 func (d *ClientProxy) UpdateStatus(repo models.Repo, pull models.PullRequest, state models.CommitStatus, src string, description string, url string) error {
 	return d.clients[repo.VCSHost.Type].UpdateStatus(repo, pull, state, src, description, url)
 }
@@ -91,3 +107,23 @@ func (d *ClientProxy) DownloadRepoConfigFile(pull models.PullRequest) (bool, []b
 func (d *ClientProxy) SupportsSingleFileDownload(repo models.Repo) bool {
 	return d.clients[repo.VCSHost.Type].SupportsSingleFileDownload(repo)
 }
+
+func (d *ClientProxy) GetTeamNamesForUser(repo models.Repo, user models.User, teams []string) ([]string, error) {
+	return d.clients[repo.VCSHost.Type].GetTeamNamesForUser(repo, user, teams)
+}
+
+func (d *ClientProxy) FindOpenIssue(repo models.Repo, title string) (*models.Issue, error) {
+	return d.clients[repo.VCSHost.Type].FindOpenIssue(repo, title)
+}
+
+func (d *ClientProxy) CreateIssue(repo models.Repo, issue models.Issue) (models.Issue, error) {
+	return d.clients[repo.VCSHost.Type].CreateIssue(repo, issue)
+}
+
+func (d *ClientProxy) CreatePullRequest(repo models.Repo, title string, body string, headBranch string, baseBranch string) (models.PullRequest, error) {
+	return d.clients[repo.VCSHost.Type].CreatePullRequest(repo, title, body, headBranch, baseBranch)
+}
+
+func (d *ClientProxy) GetPipeline(repo models.Repo, pull models.PullRequest) (models.Pipeline, error) {
+	return d.clients[repo.VCSHost.Type].GetPipeline(repo, pull)
+}
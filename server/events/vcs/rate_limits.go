@@ -0,0 +1,174 @@
+package vcs
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+const (
+	// DefaultRateLimitLowFraction is the default fraction of a rate limit
+	// below which non-critical calls are throttled.
+	DefaultRateLimitLowFraction = 0.1
+	// DefaultRateLimitMaxDelay is the default longest that a non-critical
+	// call is delayed.
+	DefaultRateLimitMaxDelay = time.Minute
+)
+
+// RateLimit is the rate limit of a VCS host's API as of its latest response.
+type RateLimit struct {
+	// Limit is the number of requests allowed per window.
+	Limit int64 `json:"limit"`
+	// Remaining is the number of requests left in the current window.
+	Remaining int64 `json:"remaining"`
+	// Reset is when the current window ends.
+	Reset time.Time `json:"reset"`
+}
+
+// RateLimits tracks the rate limits of the VCS hosts' APIs from the headers
+// of their responses and throttles non-critical calls, like hiding comments,
+// when a limit runs low so that critical calls, like updating statuses and
+// merging, don't hit it. Its methods are safe for concurrent use and do
+// nothing if it's nil.
+type RateLimits struct {
+	// LowFraction is the fraction of a rate limit below which non-critical
+	// calls are throttled.
+	LowFraction float64
+	// MaxDelay is the longest that a non-critical call is delayed.
+	MaxDelay time.Duration
+
+	mutex sync.Mutex
+	// limits maps from the VCS host type, and the resource for hosts with
+	// a limit per resource, ex. Github/graphql, to its rate limit.
+	limits map[string]RateLimit
+}
+
+// NewRateLimits returns RateLimits with the default throttling.
+func NewRateLimits() *RateLimits {
+	return &RateLimits{
+		LowFraction: DefaultRateLimitLowFraction,
+		MaxDelay:    DefaultRateLimitMaxDelay,
+		limits:      make(map[string]RateLimit),
+	}
+}
+
+// Transport returns a transport that sends requests to the API of hostType
+// with base, or http.DefaultTransport if base is nil, and records the rate
+// limits of the responses.
+func (r *RateLimits) Transport(hostType models.VCSHostType, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if r == nil {
+		return base
+	}
+	return &rateLimitTransport{limits: r, hostType: hostType, base: base}
+}
+
+// Snapshot returns a copy of the rate limits by VCS host type, and resource
+// for hosts with a limit per resource.
+func (r *RateLimits) Snapshot() map[string]RateLimit {
+	if r == nil {
+		return nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	snapshot := make(map[string]RateLimit, len(r.limits))
+	for name, limit := range r.limits {
+		snapshot[name] = limit
+	}
+	return snapshot
+}
+
+// Delay returns how long a non-critical call to the API of hostType should be
+// delayed. Once a limit is below LowFraction, its remaining requests are
+// spread over the rest of its window, so the delay grows as it runs out.
+func (r *RateLimits) Delay(hostType models.VCSHostType) time.Duration {
+	if r == nil {
+		return 0
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	now := time.Now()
+	var delay time.Duration
+	for name, limit := range r.limits {
+		if name != hostType.String() && !strings.HasPrefix(name, hostType.String()+"/") {
+			continue
+		}
+		if limit.Limit <= 0 || float64(limit.Remaining) >= r.LowFraction*float64(limit.Limit) || !limit.Reset.After(now) {
+			continue
+		}
+		if d := limit.Reset.Sub(now) / time.Duration(limit.Remaining+1); d > delay {
+			delay = d
+		}
+	}
+	if delay > r.MaxDelay {
+		delay = r.MaxDelay
+	}
+	return delay
+}
+
+// Throttle sleeps for the Delay of a non-critical call to the API of
+// hostType.
+func (r *RateLimits) Throttle(hostType models.VCSHostType) {
+	if delay := r.Delay(hostType); delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// record records the rate limit in header, if any, of a response from the API
+// of hostType. GitHub and Azure DevOps send X-RateLimit-* headers and GitLab
+// sends RateLimit-* headers.
+func (r *RateLimits) record(hostType models.VCSHostType, header http.Header) {
+	prefix := "X-RateLimit-"
+	if header.Get(prefix+"Remaining") == "" {
+		prefix = "RateLimit-"
+	}
+	remaining, err := strconv.ParseInt(header.Get(prefix+"Remaining"), 10, 64)
+	if err != nil {
+		return
+	}
+	limit, _ := strconv.ParseInt(header.Get(prefix+"Limit"), 10, 64)
+	reset, _ := strconv.ParseInt(header.Get(prefix+"Reset"), 10, 64)
+	name := hostType.String()
+	if resource := header.Get(prefix + "Resource"); resource != "" {
+		name += "/" + resource
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.limits[name] = RateLimit{
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     time.Unix(reset, 0).UTC(),
+	}
+}
+
+type rateLimitTransport struct {
+	limits   *RateLimits
+	hostType models.VCSHostType
+	base     http.RoundTripper
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		t.limits.record(t.hostType, resp.Header)
+	}
+	return resp, err
+}
+
+// ThrottledClient throttles the non-critical calls of Client when the rate
+// limit of the VCS host's API runs low. Other calls aren't throttled.
+type ThrottledClient struct {
+	Client
+	RateLimits *RateLimits
+}
+
+func (c *ThrottledClient) HidePrevCommandComments(repo models.Repo, pullNum int, command string) error {
+	c.RateLimits.Throttle(repo.VCSHost.Type)
+	return c.Client.HidePrevCommandComments(repo, pullNum, command)
+}
@@ -0,0 +1,95 @@
+package vcs_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestRateLimits_Transport(t *testing.T) {
+	reset := time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)
+	testServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/github":
+				w.Header().Set("X-RateLimit-Limit", "5000")
+				w.Header().Set("X-RateLimit-Remaining", "4999")
+				w.Header().Set("X-RateLimit-Reset", fmt.Sprint(reset.Unix()))
+				w.Header().Set("X-RateLimit-Resource", "core")
+			case "/gitlab":
+				w.Header().Set("RateLimit-Limit", "600")
+				w.Header().Set("RateLimit-Remaining", "10")
+				w.Header().Set("RateLimit-Reset", fmt.Sprint(reset.Unix()))
+			}
+		}))
+	defer testServer.Close()
+
+	limits := vcs.NewRateLimits()
+	for _, c := range []struct {
+		hostType models.VCSHostType
+		path     string
+	}{
+		{models.Github, "/github"},
+		{models.Gitlab, "/gitlab"},
+		{models.AzureDevops, "/none"},
+	} {
+		client := &http.Client{Transport: limits.Transport(c.hostType, nil)}
+		resp, err := client.Get(testServer.URL + c.path)
+		Ok(t, err)
+		resp.Body.Close() // nolint: errcheck
+	}
+	Equals(t, map[string]vcs.RateLimit{
+		"Github/core": {Limit: 5000, Remaining: 4999, Reset: reset},
+		"Gitlab":      {Limit: 600, Remaining: 10, Reset: reset},
+	}, limits.Snapshot())
+}
+
+func TestRateLimits_Delay(t *testing.T) {
+	cases := []struct {
+		description string
+		remaining   int
+		reset       time.Duration
+		minDelay    time.Duration
+		maxDelay    time.Duration
+	}{
+		{"plenty remaining", 500, 50 * time.Second, 0, 0},
+		{"low", 9, 50 * time.Second, 4 * time.Second, 5 * time.Second},
+		{"exhausted", 0, 50 * time.Second, 49 * time.Second, 50 * time.Second},
+		{"capped", 0, time.Hour, time.Minute, time.Minute},
+		{"reset", 0, -time.Second, 0, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			testServer := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("X-RateLimit-Limit", "1000")
+					w.Header().Set("X-RateLimit-Remaining", fmt.Sprint(c.remaining))
+					w.Header().Set("X-RateLimit-Reset", fmt.Sprint(time.Now().Add(c.reset).Unix()))
+				}))
+			defer testServer.Close()
+			limits := vcs.NewRateLimits()
+			client := &http.Client{Transport: limits.Transport(models.Github, nil)}
+			resp, err := client.Get(testServer.URL)
+			Ok(t, err)
+			resp.Body.Close() // nolint: errcheck
+
+			delay := limits.Delay(models.Github)
+			Assert(t, delay >= c.minDelay && delay <= c.maxDelay, "exp delay between %s and %s, got %s", c.minDelay, c.maxDelay, delay)
+			Equals(t, time.Duration(0), limits.Delay(models.Gitlab))
+		})
+	}
+}
+
+// Test that RateLimits does nothing if it's nil.
+func TestRateLimits_Nil(t *testing.T) {
+	var limits *vcs.RateLimits
+	Equals(t, http.DefaultTransport, limits.Transport(models.Github, nil))
+	Equals(t, time.Duration(0), limits.Delay(models.Github))
+	Equals(t, 0, len(limits.Snapshot()))
+}
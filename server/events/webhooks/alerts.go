@@ -0,0 +1,238 @@
+package webhooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+const (
+	// PagerDutyURL is the URL of the PagerDuty Events API v2.
+	PagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+	// OpsgenieURL is the URL of the Opsgenie Alert API. Accounts in the EU
+	// use https://api.eu.opsgenie.com/v2/alerts.
+	OpsgenieURL = "https://api.opsgenie.com/v2/alerts"
+	// opsgenieMaxMessageLen is the maximum length of Opsgenie alert
+	// messages.
+	opsgenieMaxMessageLen = 130
+)
+
+// PagerDutyWebhook triggers a PagerDuty incident when an apply fails and
+// resolves it when an apply of the same project succeeds.
+type PagerDutyWebhook struct {
+	URL            string
+	RoutingKey     string
+	Client         *http.Client
+	WorkspaceRegex *regexp.Regexp
+
+	queue *queue
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+	Links       []pagerDutyLink   `json:"links,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string `json:"summary"`
+	Source        string `json:"source"`
+	Severity      string `json:"severity"`
+	Component     string `json:"component,omitempty"`
+	Group         string `json:"group,omitempty"`
+	CustomDetails Event  `json:"custom_details"`
+}
+
+type pagerDutyLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+// NewPagerDuty returns a PagerDutyWebhook that sends events of workspaces
+// matching r with routingKey, the integration key of a PagerDuty service,
+// to apiURL, or to PagerDutyURL if it's empty.
+func NewPagerDuty(r *regexp.Regexp, apiURL string, routingKey string) *PagerDutyWebhook {
+	if apiURL == "" {
+		apiURL = PagerDutyURL
+	}
+	return &PagerDutyWebhook{
+		URL:            apiURL,
+		RoutingKey:     routingKey,
+		Client:         &http.Client{Timeout: 10 * time.Second},
+		WorkspaceRegex: r,
+		queue:          newQueue(),
+	}
+}
+
+// Send queues triggering an incident if the result is of a failed apply, or
+// resolving it if it's of a successful one, and the workspace matches the
+// regex.
+func (p *PagerDutyWebhook) Send(log logging.SimpleLogging, applyResult ApplyResult) error {
+	if !applyResult.MatchesEvent(ApplyEvent) || !p.WorkspaceRegex.MatchString(applyResult.Workspace) {
+		return nil
+	}
+	event := pagerDutyEvent{
+		RoutingKey:  p.RoutingKey,
+		EventAction: "resolve",
+		DedupKey:    alertKey(applyResult),
+	}
+	if !applyResult.Success {
+		event.EventAction = "trigger"
+		event.Payload = &pagerDutyPayload{
+			Summary:       alertSummary(applyResult),
+			Source:        "atlantis",
+			Severity:      "error",
+			Component:     applyResult.Directory,
+			Group:         applyResult.Repo.FullName,
+			CustomDetails: NewEvent(ApplyFinishedEvent, applyResult),
+		}
+		if applyResult.Pull.URL != "" {
+			event.Links = []pagerDutyLink{{Href: applyResult.Pull.URL, Text: "Pull Request"}}
+		}
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "serializing pagerduty event")
+	}
+	return p.queue.push(log, fmt.Sprintf("pagerduty %s", event.EventAction), func() error {
+		return postAlert(p.Client, p.URL, "", body)
+	})
+}
+
+// OpsgenieWebhook creates an Opsgenie alert when an apply fails and closes
+// it when an apply of the same project succeeds.
+type OpsgenieWebhook struct {
+	URL            string
+	APIKey         string
+	Client         *http.Client
+	WorkspaceRegex *regexp.Regexp
+
+	queue *queue
+}
+
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Source      string            `json:"source"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+}
+
+type opsgenieClose struct {
+	Source string `json:"source"`
+	Note   string `json:"note,omitempty"`
+}
+
+// NewOpsgenie returns an OpsgenieWebhook that sends alerts of workspaces
+// matching r with apiKey, the key of an Opsgenie API integration, to apiURL,
+// or to OpsgenieURL if it's empty.
+func NewOpsgenie(r *regexp.Regexp, apiURL string, apiKey string) *OpsgenieWebhook {
+	if apiURL == "" {
+		apiURL = OpsgenieURL
+	}
+	return &OpsgenieWebhook{
+		URL:            apiURL,
+		APIKey:         apiKey,
+		Client:         &http.Client{Timeout: 10 * time.Second},
+		WorkspaceRegex: r,
+		queue:          newQueue(),
+	}
+}
+
+// Send queues creating an alert if the result is of a failed apply, or
+// closing it if it's of a successful one, and the workspace matches the
+// regex.
+func (o *OpsgenieWebhook) Send(log logging.SimpleLogging, applyResult ApplyResult) error {
+	if !applyResult.MatchesEvent(ApplyEvent) || !o.WorkspaceRegex.MatchString(applyResult.Workspace) {
+		return nil
+	}
+	alias := alertKey(applyResult)
+	var body interface{}
+	alertURL := o.URL
+	action := "close"
+	if applyResult.Success {
+		alertURL = fmt.Sprintf("%s/%s/close?identifierType=alias", o.URL, url.PathEscape(alias))
+		body = opsgenieClose{Source: "atlantis", Note: fmt.Sprintf("Applied by %s", applyResult.User.Username)}
+	} else {
+		action = "create"
+		message := alertSummary(applyResult)
+		if len(message) > opsgenieMaxMessageLen {
+			message = message[:opsgenieMaxMessageLen-3] + "..."
+		}
+		body = opsgenieAlert{
+			Message:     message,
+			Alias:       alias,
+			Description: applyResult.Error,
+			Source:      "atlantis",
+			Tags:        []string{"atlantis", applyResult.Workspace},
+			Details: map[string]string{
+				"repo":      applyResult.Repo.FullName,
+				"pull_url":  applyResult.Pull.URL,
+				"user":      applyResult.User.Username,
+				"dir":       applyResult.Directory,
+				"workspace": applyResult.Workspace,
+				"project":   applyResult.ProjectName,
+			},
+		}
+	}
+	serialized, err := json.Marshal(body)
+	if err != nil {
+		return errors.Wrap(err, "serializing opsgenie alert")
+	}
+	return o.queue.push(log, fmt.Sprintf("opsgenie %s", action), func() error {
+		return postAlert(o.Client, alertURL, "GenieKey "+o.APIKey, serialized)
+	})
+}
+
+// alertKey identifies the alerts of a project so later applies resolve the
+// alerts of earlier ones.
+func alertKey(applyResult ApplyResult) string {
+	key := fmt.Sprintf("atlantis/%s/%s/%s", applyResult.Repo.FullName, applyResult.Directory, applyResult.Workspace)
+	if applyResult.ProjectName != "" {
+		key += "/" + applyResult.ProjectName
+	}
+	return key
+}
+
+func alertSummary(applyResult ApplyResult) string {
+	project := applyResult.ProjectName
+	if project == "" {
+		project = applyResult.Directory
+	}
+	return fmt.Sprintf("Apply failed for %s (%s) in %s#%d", project, applyResult.Workspace, applyResult.Repo.FullName, applyResult.Pull.Num)
+}
+
+// postAlert POSTs body to alertURL with the authorization header, if set.
+func postAlert(client *http.Client, alertURL string, authorization string, body []byte) error {
+	req, err := http.NewRequest("POST", alertURL, bytes.NewReader(body))
+	if err != nil {
+		return errors.New("creating request: invalid url")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// The URL may contain credentials so it's left out of the error.
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("responded with %d", resp.StatusCode)
+	}
+	return nil
+}
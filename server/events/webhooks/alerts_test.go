@@ -0,0 +1,86 @@
+package webhooks_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/webhooks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+var failedApply = webhooks.ApplyResult{
+	Workspace:   "production",
+	Repo:        models.Repo{FullName: "owner/repo"},
+	Pull:        models.PullRequest{Num: 1, URL: "https://github.com/owner/repo/pull/1"},
+	User:        models.User{Username: "user"},
+	Directory:   "dir",
+	Command:     models.ApplyCommand,
+	ProjectName: "myproject",
+	Error:       "exit status 1",
+}
+
+func TestPagerDutyWebhook_Send(t *testing.T) {
+	ts, requests := httpReceiver(t, http.StatusAccepted, http.StatusAccepted)
+	defer ts.Close()
+
+	hook := webhooks.NewPagerDuty(regexp.MustCompile(".*"), ts.URL, "key")
+	Ok(t, hook.Send(logging.NewNoopLogger(t), failedApply))
+	succeeded := failedApply
+	succeeded.Success = true
+	succeeded.Error = ""
+	Ok(t, hook.Send(logging.NewNoopLogger(t), succeeded))
+
+	var trigger map[string]interface{}
+	Ok(t, json.Unmarshal(receive(t, requests).body, &trigger))
+	Equals(t, "key", trigger["routing_key"])
+	Equals(t, "trigger", trigger["event_action"])
+	Equals(t, "atlantis/owner/repo/dir/production/myproject", trigger["dedup_key"])
+	payload := trigger["payload"].(map[string]interface{})
+	Equals(t, "Apply failed for myproject (production) in owner/repo#1", payload["summary"])
+	Equals(t, "error", payload["severity"])
+	Equals(t, "exit status 1", payload["custom_details"].(map[string]interface{})["error"])
+
+	// The next successful apply resolves the incident.
+	var resolve map[string]interface{}
+	Ok(t, json.Unmarshal(receive(t, requests).body, &resolve))
+	Equals(t, map[string]interface{}{
+		"routing_key":  "key",
+		"event_action": "resolve",
+		"dedup_key":    "atlantis/owner/repo/dir/production/myproject",
+	}, resolve)
+}
+
+func TestOpsgenieWebhook_Send(t *testing.T) {
+	ts, requests := httpReceiver(t, http.StatusAccepted, http.StatusAccepted)
+	defer ts.Close()
+
+	hook := webhooks.NewOpsgenie(regexp.MustCompile(".*"), ts.URL+"/v2/alerts", "key")
+	Ok(t, hook.Send(logging.NewNoopLogger(t), failedApply))
+	// Applies that didn't run aren't alerted.
+	notRun := failedApply
+	notRun.Failure = "not approved"
+	Ok(t, hook.Send(logging.NewNoopLogger(t), notRun))
+	succeeded := failedApply
+	succeeded.Success = true
+	Ok(t, hook.Send(logging.NewNoopLogger(t), succeeded))
+
+	r := receive(t, requests)
+	Equals(t, "/v2/alerts", r.url)
+	Equals(t, "GenieKey key", r.header.Get("Authorization"))
+	var alert map[string]interface{}
+	Ok(t, json.Unmarshal(r.body, &alert))
+	Equals(t, "Apply failed for myproject (production) in owner/repo#1", alert["message"])
+	Equals(t, "atlantis/owner/repo/dir/production/myproject", alert["alias"])
+	Equals(t, "exit status 1", alert["description"])
+
+	r = receive(t, requests)
+	Equals(t, "/v2/alerts/atlantis%2Fowner%2Frepo%2Fdir%2Fproduction%2Fmyproject/close?identifierType=alias", r.url)
+	Equals(t, "GenieKey key", r.header.Get("Authorization"))
+	var closed map[string]interface{}
+	Ok(t, json.Unmarshal(r.body, &closed))
+	Equals(t, "Applied by user", closed["note"])
+}
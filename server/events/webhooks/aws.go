@@ -0,0 +1,159 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// EventBridgeSource is the source of the events published to EventBridge.
+const EventBridgeSource = "atlantis"
+
+// SNSWebhook publishes events as JSON to an SNS topic.
+type SNSWebhook struct {
+	TopicARN       string
+	SNS            snsiface.SNSAPI
+	WorkspaceRegex *regexp.Regexp
+	Event          string
+
+	queue *queue
+}
+
+// NewSNS returns an SNSWebhook that publishes event for workspaces matching r
+// to the topic topicARN. Credentials are read from the environment like the
+// AWS CLI does.
+func NewSNS(r *regexp.Regexp, event string, topicARN string) (*SNSWebhook, error) {
+	parsed, err := arn.Parse(topicARN)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing topic-arn %q", topicARN)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            aws.Config{Region: aws.String(parsed.Region)},
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating AWS session")
+	}
+	return NewSNSWithClient(r, event, topicARN, sns.New(sess)), nil
+}
+
+// NewSNSWithClient is used for testing.
+func NewSNSWithClient(r *regexp.Regexp, event string, topicARN string, client snsiface.SNSAPI) *SNSWebhook {
+	return &SNSWebhook{
+		TopicARN:       topicARN,
+		SNS:            client,
+		WorkspaceRegex: r,
+		Event:          event,
+		queue:          newQueue(),
+	}
+}
+
+// Send queues publishing the event if the result is of its event and the
+// workspace matches the regex.
+func (s *SNSWebhook) Send(log logging.SimpleLogging, applyResult ApplyResult) error {
+	if !applyResult.MatchesEvent(s.Event) || !s.WorkspaceRegex.MatchString(applyResult.Workspace) {
+		return nil
+	}
+	body, err := json.Marshal(NewEvent(s.Event, applyResult))
+	if err != nil {
+		return errors.Wrap(err, "serializing event")
+	}
+	input := &sns.PublishInput{
+		TopicArn: aws.String(s.TopicARN),
+		Message:  aws.String(string(body)),
+		// The event is an attribute so subscriptions can filter by it.
+		MessageAttributes: map[string]*sns.MessageAttributeValue{
+			"event": {DataType: aws.String("String"), StringValue: aws.String(s.Event)},
+		},
+	}
+	return s.queue.push(log, fmt.Sprintf("%s sns event", s.Event), func() error {
+		_, err := s.SNS.Publish(input)
+		return err
+	})
+}
+
+// EventBridgeWebhook puts events on an EventBridge event bus with
+// EventBridgeSource as their source, the event as their detail type and the
+// JSON of the event as their detail.
+type EventBridgeWebhook struct {
+	EventBus       string
+	EventBridge    eventbridgeiface.EventBridgeAPI
+	WorkspaceRegex *regexp.Regexp
+	Event          string
+
+	queue *queue
+}
+
+// NewEventBridge returns an EventBridgeWebhook that puts event for workspaces
+// matching r on eventBus, or on the default event bus if it's empty. The
+// region and credentials are read from the environment like the AWS CLI does.
+// eventBus can also be the ARN of a bus, in which case its region is used.
+func NewEventBridge(r *regexp.Regexp, event string, eventBus string) (*EventBridgeWebhook, error) {
+	awsCfg := aws.Config{}
+	if parsed, err := arn.Parse(eventBus); err == nil {
+		awsCfg.Region = aws.String(parsed.Region)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            awsCfg,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating AWS session")
+	}
+	return NewEventBridgeWithClient(r, event, eventBus, eventbridge.New(sess)), nil
+}
+
+// NewEventBridgeWithClient is used for testing.
+func NewEventBridgeWithClient(r *regexp.Regexp, event string, eventBus string, client eventbridgeiface.EventBridgeAPI) *EventBridgeWebhook {
+	if eventBus == "" {
+		eventBus = "default"
+	}
+	return &EventBridgeWebhook{
+		EventBus:       eventBus,
+		EventBridge:    client,
+		WorkspaceRegex: r,
+		Event:          event,
+		queue:          newQueue(),
+	}
+}
+
+// Send queues putting the event if the result is of its event and the
+// workspace matches the regex.
+func (e *EventBridgeWebhook) Send(log logging.SimpleLogging, applyResult ApplyResult) error {
+	if !applyResult.MatchesEvent(e.Event) || !e.WorkspaceRegex.MatchString(applyResult.Workspace) {
+		return nil
+	}
+	body, err := json.Marshal(NewEvent(e.Event, applyResult))
+	if err != nil {
+		return errors.Wrap(err, "serializing event")
+	}
+	input := &eventbridge.PutEventsInput{
+		Entries: []*eventbridge.PutEventsRequestEntry{{
+			EventBusName: aws.String(e.EventBus),
+			Source:       aws.String(EventBridgeSource),
+			DetailType:   aws.String(e.Event),
+			Detail:       aws.String(string(body)),
+		}},
+	}
+	return e.queue.push(log, fmt.Sprintf("%s eventbridge event", e.Event), func() error {
+		out, err := e.EventBridge.PutEvents(input)
+		if err != nil {
+			return err
+		}
+		// PutEvents doesn't error if entries fail so they're checked too.
+		if aws.Int64Value(out.FailedEntryCount) > 0 && len(out.Entries) > 0 {
+			return fmt.Errorf("%s: %s", aws.StringValue(out.Entries[0].ErrorCode), aws.StringValue(out.Entries[0].ErrorMessage))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,102 @@
+package webhooks_test
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/webhooks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+type fakeSNS struct {
+	snsiface.SNSAPI
+	published chan *sns.PublishInput
+}
+
+func (f *fakeSNS) Publish(input *sns.PublishInput) (*sns.PublishOutput, error) {
+	f.published <- input
+	return &sns.PublishOutput{}, nil
+}
+
+type fakeEventBridge struct {
+	eventbridgeiface.EventBridgeAPI
+	put chan *eventbridge.PutEventsInput
+	out *eventbridge.PutEventsOutput
+}
+
+func (f *fakeEventBridge) PutEvents(input *eventbridge.PutEventsInput) (*eventbridge.PutEventsOutput, error) {
+	f.put <- input
+	return f.out, nil
+}
+
+var lockResult = webhooks.ApplyResult{
+	Workspace: "default",
+	Repo:      models.Repo{FullName: "owner/repo"},
+	Pull:      models.PullRequest{Num: 1, URL: "https://github.com/owner/repo/pull/1"},
+	User:      models.User{Username: "user"},
+	Directory: "dir",
+	Event:     webhooks.LockAcquiredEvent,
+}
+
+func TestSNSWebhook_Send(t *testing.T) {
+	client := &fakeSNS{published: make(chan *sns.PublishInput, 10)}
+	topic := "arn:aws:sns:us-east-1:123456789012:atlantis"
+	hook := webhooks.NewSNSWithClient(regexp.MustCompile(".*"), webhooks.LockAcquiredEvent, topic, client)
+	Ok(t, hook.Send(logging.NewNoopLogger(t), lockResult))
+
+	var input *sns.PublishInput
+	select {
+	case input = <-client.published:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	Equals(t, topic, aws.StringValue(input.TopicArn))
+	Equals(t, webhooks.LockAcquiredEvent, aws.StringValue(input.MessageAttributes["event"].StringValue))
+	var event webhooks.Event
+	Ok(t, json.Unmarshal([]byte(aws.StringValue(input.Message)), &event))
+	Equals(t, webhooks.LockAcquiredEvent, event.Event)
+	Equals(t, "owner/repo", event.Repo)
+	Equals(t, "", event.Command)
+}
+
+func TestEventBridgeWebhook_Send(t *testing.T) {
+	client := &fakeEventBridge{
+		put: make(chan *eventbridge.PutEventsInput, 10),
+		out: &eventbridge.PutEventsOutput{FailedEntryCount: aws.Int64(0)},
+	}
+	hook := webhooks.NewEventBridgeWithClient(regexp.MustCompile(".*"), webhooks.LockAcquiredEvent, "", client)
+	Ok(t, hook.Send(logging.NewNoopLogger(t), lockResult))
+	// Other events aren't put.
+	released := lockResult
+	released.Event = webhooks.LockReleasedEvent
+	Ok(t, hook.Send(logging.NewNoopLogger(t), released))
+
+	var input *eventbridge.PutEventsInput
+	select {
+	case input = <-client.put:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	Equals(t, 1, len(input.Entries))
+	entry := input.Entries[0]
+	Equals(t, "default", aws.StringValue(entry.EventBusName))
+	Equals(t, webhooks.EventBridgeSource, aws.StringValue(entry.Source))
+	Equals(t, webhooks.LockAcquiredEvent, aws.StringValue(entry.DetailType))
+	var event webhooks.Event
+	Ok(t, json.Unmarshal([]byte(aws.StringValue(entry.Detail)), &event))
+	Equals(t, "dir", event.Directory)
+	select {
+	case <-client.put:
+		t.Fatal("exp only one event")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,88 @@
+package webhooks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// queueSize is how many events can wait to be sent by each webhook before
+// new ones are dropped.
+const queueSize = 1000
+
+// Event is the JSON body of HTTP webhooks and the message of AWS events.
+type Event struct {
+	Event       string    `json:"event"`
+	Time        time.Time `json:"time"`
+	Repo        string    `json:"repo"`
+	PullNum     int       `json:"pull_num"`
+	PullURL     string    `json:"pull_url"`
+	User        string    `json:"user"`
+	Command     string    `json:"command,omitempty"`
+	ProjectName string    `json:"project_name,omitempty"`
+	Directory   string    `json:"dir"`
+	Workspace   string    `json:"workspace"`
+	// Success, Failure and Error are only set by events of commands
+	// finishing.
+	Success bool   `json:"success"`
+	Failure string `json:"failure,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// NewEvent returns event for applyResult.
+func NewEvent(event string, applyResult ApplyResult) Event {
+	e := Event{
+		Event:       event,
+		Time:        time.Now().UTC(),
+		Repo:        applyResult.Repo.FullName,
+		PullNum:     applyResult.Pull.Num,
+		PullURL:     applyResult.Pull.URL,
+		User:        applyResult.User.Username,
+		ProjectName: applyResult.ProjectName,
+		Directory:   applyResult.Directory,
+		Workspace:   applyResult.Workspace,
+		Success:     applyResult.Success,
+		Failure:     applyResult.Failure,
+		Error:       applyResult.Error,
+	}
+	if applyResult.Event != LockAcquiredEvent && applyResult.Event != LockReleasedEvent {
+		e.Command = applyResult.Command.String()
+	}
+	return e
+}
+
+// queue sends events in the background, one at a time in the order they
+// happened, so slow receivers don't slow down commands.
+type queue struct {
+	sends chan queuedSend
+}
+
+type queuedSend struct {
+	log  logging.SimpleLogging
+	desc string
+	send func() error
+}
+
+func newQueue() *queue {
+	q := &queue{sends: make(chan queuedSend, queueSize)}
+	go func() {
+		for s := range q.sends {
+			if err := s.send(); err != nil {
+				s.log.Warn("error sending %s: %s", s.desc, err)
+			}
+		}
+	}()
+	return q
+}
+
+// push queues send, described by desc, ex. "plan-started webhook". It errors
+// if the queue is full.
+func (q *queue) push(log logging.SimpleLogging, desc string, send func() error) error {
+	select {
+	case q.sends <- queuedSend{log: log, desc: desc, send: send}:
+		return nil
+	default:
+		return fmt.Errorf("dropping %s since %d events are waiting to be sent", desc, queueSize)
+	}
+}
@@ -0,0 +1,135 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+const (
+	// HTTPSignatureHeader is the header of the signature of HTTP webhooks:
+	// "sha256=" followed by the hex encoded HMAC-SHA256 of the body, keyed
+	// with the secret.
+	HTTPSignatureHeader = "X-Atlantis-Signature"
+	// HTTPEventHeader is the header of the event of HTTP webhooks.
+	HTTPEventHeader = "X-Atlantis-Event"
+	// HTTPDeliveryHeader is the header of the ID of HTTP webhooks. Retries
+	// have the same ID, so receivers can ignore duplicates.
+	HTTPDeliveryHeader = "X-Atlantis-Delivery"
+)
+
+// HTTPWebhook POSTs events as JSON to a URL. Webhooks are sent in the
+// background and failed requests are retried.
+type HTTPWebhook struct {
+	URL            string
+	Client         *http.Client
+	WorkspaceRegex *regexp.Regexp
+	Event          string
+	// Secret is the key of the signature in HTTPSignatureHeader. If empty,
+	// webhooks aren't signed.
+	Secret string
+	// Retries is how many times requests that fail with a network error or a
+	// 5xx or 429 response are retried.
+	Retries int
+	// Backoff is how long to wait before the first retry. It doubles for
+	// each retry after that.
+	Backoff time.Duration
+
+	queue *queue
+}
+
+type httpDelivery struct {
+	log  logging.SimpleLogging
+	id   string
+	body []byte
+}
+
+// NewHTTP returns an HTTPWebhook that POSTs event for workspaces matching r
+// to webhookURL, signed with secret.
+func NewHTTP(r *regexp.Regexp, event string, webhookURL string, secret string) *HTTPWebhook {
+	return &HTTPWebhook{
+		URL:            webhookURL,
+		Client:         &http.Client{Timeout: 10 * time.Second},
+		WorkspaceRegex: r,
+		Event:          event,
+		Secret:         secret,
+		Retries:        5,
+		Backoff:        time.Second,
+		queue:          newQueue(),
+	}
+}
+
+// Send queues the webhook if the result is of its event and the workspace
+// matches the regex.
+func (h *HTTPWebhook) Send(log logging.SimpleLogging, applyResult ApplyResult) error {
+	if !applyResult.MatchesEvent(h.Event) || !h.WorkspaceRegex.MatchString(applyResult.Workspace) {
+		return nil
+	}
+	body, err := json.Marshal(NewEvent(h.Event, applyResult))
+	if err != nil {
+		return errors.Wrap(err, "serializing webhook")
+	}
+	d := httpDelivery{log: log, id: uuid.New().String(), body: body}
+	return h.queue.push(log, fmt.Sprintf("%s webhook %s", h.Event, d.id), func() error { return h.deliver(d) })
+}
+
+// deliver POSTs d, retrying if it fails.
+func (h *HTTPWebhook) deliver(d httpDelivery) error {
+	backoff := h.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := h.post(d)
+		if err == nil || !retry || attempt >= h.Retries {
+			return err
+		}
+		d.log.Debug("retrying %s webhook %s in %s: %s", h.Event, d.id, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post POSTs d once. It returns whether a failed request should be retried.
+func (h *HTTPWebhook) post(d httpDelivery) (bool, error) {
+	req, err := http.NewRequest("POST", h.URL, bytes.NewReader(d.body))
+	if err != nil {
+		return false, errors.New("creating request: invalid url")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HTTPEventHeader, h.Event)
+	req.Header.Set(HTTPDeliveryHeader, d.id)
+	if h.Secret != "" {
+		req.Header.Set(HTTPSignatureHeader, HTTPSignature(h.Secret, d.body))
+	}
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		// The URL may contain credentials so it's left out of the error.
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return true, err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("responded with %d", resp.StatusCode)
+	}
+	return false, nil
+}
+
+// HTTPSignature returns the value of HTTPSignatureHeader for body signed
+// with secret.
+func HTTPSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body) // nolint: errcheck
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,139 @@
+package webhooks_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/webhooks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+type httpRequest struct {
+	url    string
+	header http.Header
+	body   []byte
+}
+
+// httpReceiver returns a server that responds to requests with the statuses
+// in order, and 200 once they run out, and sends them to the returned
+// channel.
+func httpReceiver(t *testing.T, statuses ...int) (*httptest.Server, chan httpRequest) {
+	requests := make(chan httpRequest, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		Ok(t, err)
+		requests <- httpRequest{url: r.URL.String(), header: r.Header, body: body}
+		if len(statuses) > 0 {
+			w.WriteHeader(statuses[0])
+			statuses = statuses[1:]
+		}
+	}))
+	return ts, requests
+}
+
+func receive(t *testing.T, requests chan httpRequest) httpRequest {
+	select {
+	case r := <-requests:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for webhook")
+		return httpRequest{}
+	}
+}
+
+func TestHTTPWebhook_Send(t *testing.T) {
+	ts, requests := httpReceiver(t)
+	defer ts.Close()
+
+	hook := webhooks.NewHTTP(regexp.MustCompile(".*"), webhooks.PlanFinishedEvent, ts.URL, "secret")
+	result := webhooks.ApplyResult{
+		Workspace:   "default",
+		Repo:        models.Repo{FullName: "owner/repo"},
+		Pull:        models.PullRequest{Num: 1, URL: "https://github.com/owner/repo/pull/1"},
+		User:        models.User{Username: "user"},
+		Directory:   "dir",
+		Command:     models.PlanCommand,
+		ProjectName: "myproject",
+		Failure:     "locked",
+		Event:       webhooks.PlanFinishedEvent,
+	}
+	Ok(t, hook.Send(logging.NewNoopLogger(t), result))
+	// Other events aren't sent.
+	result.Event = webhooks.PlanStartedEvent
+	Ok(t, hook.Send(logging.NewNoopLogger(t), result))
+
+	r := receive(t, requests)
+	Equals(t, "application/json", r.header.Get("Content-Type"))
+	Equals(t, webhooks.PlanFinishedEvent, r.header.Get(webhooks.HTTPEventHeader))
+	Assert(t, r.header.Get(webhooks.HTTPDeliveryHeader) != "", "exp delivery id")
+	Equals(t, webhooks.HTTPSignature("secret", r.body), r.header.Get(webhooks.HTTPSignatureHeader))
+	var payload webhooks.Event
+	Ok(t, json.Unmarshal(r.body, &payload))
+	Assert(t, time.Since(payload.Time) < time.Minute, "exp time to be now, got %s", payload.Time)
+	payload.Time = time.Time{}
+	Equals(t, webhooks.Event{
+		Event:       webhooks.PlanFinishedEvent,
+		Repo:        "owner/repo",
+		PullNum:     1,
+		PullURL:     "https://github.com/owner/repo/pull/1",
+		User:        "user",
+		Command:     "plan",
+		ProjectName: "myproject",
+		Directory:   "dir",
+		Workspace:   "default",
+		Failure:     "locked",
+	}, payload)
+	select {
+	case r := <-requests:
+		t.Fatalf("exp one webhook, got another: %s", r.body)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestHTTPWebhook_Signature(t *testing.T) {
+	// Computed with: printf '{}' | openssl dgst -sha256 -hmac secret
+	Equals(t, "sha256=77325902caca812dc259733aacd046b73817372c777b8d95b402647474516e13", webhooks.HTTPSignature("secret", []byte("{}")))
+}
+
+func TestHTTPWebhook_Retries(t *testing.T) {
+	ts, requests := httpReceiver(t, http.StatusBadGateway, http.StatusTooManyRequests)
+	defer ts.Close()
+
+	hook := webhooks.NewHTTP(regexp.MustCompile(".*"), webhooks.LockAcquiredEvent, ts.URL, "")
+	hook.Backoff = time.Millisecond
+	Ok(t, hook.Send(logging.NewNoopLogger(t), webhooks.ApplyResult{Event: webhooks.LockAcquiredEvent}))
+	first := receive(t, requests)
+	Equals(t, "", first.header.Get(webhooks.HTTPSignatureHeader))
+	for i := 0; i < 2; i++ {
+		retry := receive(t, requests)
+		Equals(t, first.header.Get(webhooks.HTTPDeliveryHeader), retry.header.Get(webhooks.HTTPDeliveryHeader))
+		Equals(t, first.body, retry.body)
+	}
+}
+
+func TestHTTPWebhook_NoRetryOn4xx(t *testing.T) {
+	ts, requests := httpReceiver(t, http.StatusBadRequest)
+	defer ts.Close()
+
+	hook := webhooks.NewHTTP(regexp.MustCompile(".*"), webhooks.LockReleasedEvent, ts.URL, "")
+	hook.Backoff = time.Millisecond
+	result := webhooks.ApplyResult{Event: webhooks.LockReleasedEvent, Workspace: "first"}
+	Ok(t, hook.Send(logging.NewNoopLogger(t), result))
+	result.Workspace = "second"
+	Ok(t, hook.Send(logging.NewNoopLogger(t), result))
+
+	// The first webhook isn't retried so the next request is the second.
+	for _, exp := range []string{"first", "second"} {
+		var payload webhooks.Event
+		Ok(t, json.Unmarshal(receive(t, requests).body, &payload))
+		Equals(t, exp, payload.Workspace)
+		Equals(t, "", payload.Command)
+	}
+}
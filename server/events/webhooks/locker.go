@@ -0,0 +1,55 @@
+package webhooks
+
+import (
+	"github.com/runatlantis/atlantis/server/events/locking"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// Locker wraps a locking.Locker to send LockAcquiredEvent and
+// LockReleasedEvent webhooks when locks are created and deleted.
+type Locker struct {
+	locking.Locker
+	Sender Sender
+	Logger logging.SimpleLogging
+}
+
+// TryLock attempts to acquire a lock to a project and workspace and sends a
+// webhook if it's acquired.
+func (l *Locker) TryLock(p models.Project, workspace string, pull models.PullRequest, user models.User) (locking.TryLockResponse, error) {
+	resp, err := l.Locker.TryLock(p, workspace, pull, user)
+	if err == nil && resp.LockAcquired {
+		l.send(LockAcquiredEvent, resp.CurrLock)
+	}
+	return resp, err
+}
+
+// Unlock deletes the lock at key and sends a webhook if there was one.
+func (l *Locker) Unlock(key string) (*models.ProjectLock, error) {
+	lock, err := l.Locker.Unlock(key)
+	if err == nil && lock != nil {
+		l.send(LockReleasedEvent, *lock)
+	}
+	return lock, err
+}
+
+// UnlockByPull deletes the locks of a pull request and sends a webhook for
+// each.
+func (l *Locker) UnlockByPull(repoFullName string, pullNum int) ([]models.ProjectLock, error) {
+	locks, err := l.Locker.UnlockByPull(repoFullName, pullNum)
+	for _, lock := range locks {
+		l.send(LockReleasedEvent, lock)
+	}
+	return locks, err
+}
+
+func (l *Locker) send(event string, lock models.ProjectLock) {
+	l.Sender.Send(l.Logger, ApplyResult{ // nolint: errcheck
+		Workspace: lock.Workspace,
+		Repo:      lock.Pull.BaseRepo,
+		Pull:      lock.Pull,
+		User:      lock.User,
+		Directory: lock.Project.Path,
+		Event:     event,
+	})
+}
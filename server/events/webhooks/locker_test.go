@@ -0,0 +1,60 @@
+package webhooks_test
+
+import (
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events/locking"
+	lockmocks "github.com/runatlantis/atlantis/server/events/locking/mocks"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/webhooks"
+	"github.com/runatlantis/atlantis/server/events/webhooks/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestLocker(t *testing.T) {
+	RegisterMockTestingT(t)
+	underlying := lockmocks.NewMockLocker()
+	sender := mocks.NewMockSender()
+	logger := logging.NewNoopLogger(t)
+	locker := webhooks.Locker{Locker: underlying, Sender: sender, Logger: logger}
+
+	repo := models.Repo{FullName: "owner/repo"}
+	pull := models.PullRequest{Num: 1, BaseRepo: repo}
+	user := models.User{Username: "user"}
+	project := models.NewProject(repo.FullName, "dir")
+	lock := models.ProjectLock{Project: project, Pull: pull, User: user, Workspace: "default"}
+	exp := func(event string) webhooks.ApplyResult {
+		return webhooks.ApplyResult{Workspace: "default", Repo: repo, Pull: pull, User: user, Directory: "dir", Event: event}
+	}
+
+	t.Log("Locks held by another pull request don't send a webhook")
+	When(underlying.TryLock(project, "default", pull, user)).ThenReturn(locking.TryLockResponse{LockAcquired: false, CurrLock: lock}, nil)
+	_, err := locker.TryLock(project, "default", pull, user)
+	Ok(t, err)
+	sender.VerifyWasCalled(Never()).Send(logger, exp(webhooks.LockAcquiredEvent))
+
+	When(underlying.TryLock(project, "default", pull, user)).ThenReturn(locking.TryLockResponse{LockAcquired: true, CurrLock: lock}, nil)
+	resp, err := locker.TryLock(project, "default", pull, user)
+	Ok(t, err)
+	Equals(t, true, resp.LockAcquired)
+	sender.VerifyWasCalledOnce().Send(logger, exp(webhooks.LockAcquiredEvent))
+
+	t.Log("Unlocking a missing lock doesn't send a webhook")
+	When(underlying.Unlock("key")).ThenReturn(nil, nil)
+	_, err = locker.Unlock("key")
+	Ok(t, err)
+	sender.VerifyWasCalled(Never()).Send(logger, exp(webhooks.LockReleasedEvent))
+
+	When(underlying.Unlock("key")).ThenReturn(&lock, nil)
+	_, err = locker.Unlock("key")
+	Ok(t, err)
+	sender.VerifyWasCalledOnce().Send(logger, exp(webhooks.LockReleasedEvent))
+
+	When(underlying.UnlockByPull(repo.FullName, 1)).ThenReturn([]models.ProjectLock{lock, lock}, nil)
+	locks, err := locker.UnlockByPull(repo.FullName, 1)
+	Ok(t, err)
+	Equals(t, 2, len(locks))
+	sender.VerifyWasCalled(Times(3)).Send(logger, exp(webhooks.LockReleasedEvent))
+}
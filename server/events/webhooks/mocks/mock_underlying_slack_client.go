@@ -90,6 +90,33 @@ func (mock *MockUnderlyingSlackClient) PostMessage(channel string, text string,
 	return ret0, ret1, ret2
 }
 
+func (mock *MockUnderlyingSlackClient) UpdateMessage(channelID string, timestamp string, text string) (string, string, string, error) {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockUnderlyingSlackClient().")
+	}
+	params := []pegomock.Param{channelID, timestamp, text}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("UpdateMessage", params, []reflect.Type{reflect.TypeOf((*string)(nil)).Elem(), reflect.TypeOf((*string)(nil)).Elem(), reflect.TypeOf((*string)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 string
+	var ret1 string
+	var ret2 string
+	var ret3 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(string)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(string)
+		}
+		if result[2] != nil {
+			ret2 = result[2].(string)
+		}
+		if result[3] != nil {
+			ret3 = result[3].(error)
+		}
+	}
+	return ret0, ret1, ret2, ret3
+}
+
 func (mock *MockUnderlyingSlackClient) VerifyWasCalledOnce() *VerifierMockUnderlyingSlackClient {
 	return &VerifierMockUnderlyingSlackClient{
 		mock:                   mock,
@@ -205,3 +232,38 @@ func (c *MockUnderlyingSlackClient_PostMessage_OngoingVerification) GetAllCaptur
 	}
 	return
 }
+
+func (verifier *VerifierMockUnderlyingSlackClient) UpdateMessage(channelID string, timestamp string, text string) *MockUnderlyingSlackClient_UpdateMessage_OngoingVerification {
+	params := []pegomock.Param{channelID, timestamp, text}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "UpdateMessage", params, verifier.timeout)
+	return &MockUnderlyingSlackClient_UpdateMessage_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type MockUnderlyingSlackClient_UpdateMessage_OngoingVerification struct {
+	mock              *MockUnderlyingSlackClient
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *MockUnderlyingSlackClient_UpdateMessage_OngoingVerification) GetCapturedArguments() (string, string, string) {
+	channelID, timestamp, text := c.GetAllCapturedArguments()
+	return channelID[len(channelID)-1], timestamp[len(timestamp)-1], text[len(text)-1]
+}
+
+func (c *MockUnderlyingSlackClient_UpdateMessage_OngoingVerification) GetAllCapturedArguments() (_param0 []string, _param1 []string, _param2 []string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]string, len(c.methodInvocations))
+		for u, param := range params[0] {
+			_param0[u] = param.(string)
+		}
+		_param1 = make([]string, len(c.methodInvocations))
+		for u, param := range params[1] {
+			_param1[u] = param.(string)
+		}
+		_param2 = make([]string, len(c.methodInvocations))
+		for u, param := range params[2] {
+			_param2[u] = param.(string)
+		}
+	}
+	return
+}
@@ -15,6 +15,9 @@ package webhooks
 
 import (
 	"regexp"
+	"strings"
+	"sync"
+	"text/template"
 
 	"fmt"
 
@@ -22,11 +25,56 @@ import (
 	"github.com/runatlantis/atlantis/server/logging"
 )
 
+// maxSlackThreads is how many pull requests' threads, and digests, are
+// remembered. The oldest are forgotten first, so the next event of their pull
+// request starts a new thread or digest.
+const maxSlackThreads = 1000
+
 // SlackWebhook sends webhooks to Slack.
 type SlackWebhook struct {
 	Client         SlackClient
 	WorkspaceRegex *regexp.Regexp
-	Channel        string
+	// Channel is the channel to post to. If it contains "{{", it's a
+	// template rendered with the result. Results it renders empty for
+	// aren't posted.
+	Channel string
+	// Event is the event to post. If empty, it's ApplyEvent.
+	Event string
+	// Template renders the text of messages. If nil, a default text is
+	// used.
+	Template *template.Template
+	// Thread posts the events of a pull request after its first one as
+	// replies in the thread of the first.
+	Thread bool
+	// Digest posts one message per pull request, listing the latest result
+	// of each of its projects, and updates it in place as results come in
+	// instead of posting a message per result.
+	Digest bool
+
+	// threadsMutex guards threads and threadKeys.
+	threadsMutex sync.Mutex
+	// threads maps channel/owner/repo#num to the timestamp of the first
+	// message posted for the pull request, which identifies its thread.
+	// threadKeys are its keys, least recently added first.
+	threads    map[string]string
+	threadKeys []string
+
+	// digestsMutex guards digests and digestKeys. It's held while digests
+	// are posted so concurrent results don't post two messages.
+	digestsMutex sync.Mutex
+	// digests maps channel/owner/repo#num to the digest of the pull
+	// request. digestKeys are its keys, least recently added first.
+	digests    map[string]*slackDigest
+	digestKeys []string
+}
+
+// slackDigest is a posted digest message.
+type slackDigest struct {
+	timestamp string
+	// lines are the lines of each project, in the order their first result
+	// came in, and projects the index of each project's line.
+	lines    []SlackDigestLine
+	projects map[string]int
 }
 
 func NewSlack(r *regexp.Regexp, channel string, client SlackClient) (*SlackWebhook, error) {
@@ -34,12 +82,19 @@ func NewSlack(r *regexp.Regexp, channel string, client SlackClient) (*SlackWebho
 		return nil, fmt.Errorf("testing slack authentication: %s. Verify your slack-token is valid", err)
 	}
 
-	channelExists, err := client.ChannelExists(channel)
-	if err != nil {
-		return nil, err
-	}
-	if !channelExists {
-		return nil, errors.Errorf("slack channel %q doesn't exist", channel)
+	// Templated channels are only known once there's a result.
+	if isTemplate(channel) {
+		if _, err := template.New("channel").Parse(channel); err != nil {
+			return nil, errors.Wrap(err, "parsing channel template")
+		}
+	} else {
+		channelExists, err := client.ChannelExists(channel)
+		if err != nil {
+			return nil, err
+		}
+		if !channelExists {
+			return nil, errors.Errorf("slack channel %q doesn't exist", channel)
+		}
 	}
 
 	return &SlackWebhook{
@@ -49,10 +104,110 @@ func NewSlack(r *regexp.Regexp, channel string, client SlackClient) (*SlackWebho
 	}, nil
 }
 
-// Send sends the webhook to Slack if the workspace matches the regex.
+// Send sends the webhook to Slack if the result is of its event and the
+// workspace matches the regex.
 func (s *SlackWebhook) Send(log logging.SimpleLogging, applyResult ApplyResult) error {
-	if !s.WorkspaceRegex.MatchString(applyResult.Workspace) {
+	if !applyResult.MatchesEvent(s.Event) || !s.WorkspaceRegex.MatchString(applyResult.Workspace) {
+		return nil
+	}
+	channel, err := render("channel", s.Channel, applyResult)
+	if err != nil {
+		return err
+	}
+	channel = strings.TrimPrefix(strings.TrimSpace(channel), "#")
+	if channel == "" {
+		log.Debug("not posting to slack since the channel template rendered empty")
 		return nil
 	}
-	return s.Client.PostMessage(s.Channel, applyResult)
+	msg := SlackMessage{Result: applyResult}
+	if s.Template != nil {
+		if msg.Text, err = execute(s.Template, applyResult); err != nil {
+			return err
+		}
+	}
+	threadKey := fmt.Sprintf("%s/%s#%d", channel, applyResult.Repo.FullName, applyResult.Pull.Num)
+	if s.Digest {
+		return s.postDigest(channel, threadKey, SlackDigestLine{Result: applyResult, Text: msg.Text})
+	}
+	if s.Thread {
+		msg.ThreadTimestamp = s.thread(threadKey)
+	}
+	timestamp, err := s.Client.PostMessage(channel, msg)
+	if err != nil {
+		return err
+	}
+	if s.Thread && msg.ThreadTimestamp == "" {
+		s.addThread(threadKey, timestamp)
+	}
+	return nil
+}
+
+// thread returns the timestamp of the thread of key, or an empty string if
+// there's none.
+func (s *SlackWebhook) thread(key string) string {
+	s.threadsMutex.Lock()
+	defer s.threadsMutex.Unlock()
+	return s.threads[key]
+}
+
+func (s *SlackWebhook) addThread(key string, timestamp string) {
+	s.threadsMutex.Lock()
+	defer s.threadsMutex.Unlock()
+	if s.threads == nil {
+		s.threads = make(map[string]string)
+	}
+	if _, ok := s.threads[key]; ok {
+		return
+	}
+	s.threads[key] = timestamp
+	s.threadKeys = append(s.threadKeys, key)
+	if len(s.threadKeys) > maxSlackThreads {
+		delete(s.threads, s.threadKeys[0])
+		s.threadKeys = s.threadKeys[1:]
+	}
+}
+
+// postDigest adds line to the digest of key, replacing the line of the same
+// project, and posts or updates it.
+func (s *SlackWebhook) postDigest(channel string, key string, line SlackDigestLine) error {
+	s.digestsMutex.Lock()
+	defer s.digestsMutex.Unlock()
+	digest, ok := s.digests[key]
+	if !ok {
+		digest = &slackDigest{projects: make(map[string]int)}
+	}
+	// The digest is only changed once it's been posted so failed posts are
+	// retried with the next result.
+	lines := append([]SlackDigestLine{}, digest.lines...)
+	project := fmt.Sprintf("%s/%s/%s", line.Result.Directory, line.Result.Workspace, line.Result.ProjectName)
+	i, exists := digest.projects[project]
+	if exists {
+		lines[i] = line
+	} else {
+		i = len(lines)
+		lines = append(lines, line)
+	}
+	timestamp, err := s.Client.PostMessage(channel, SlackMessage{
+		Result:    line.Result,
+		Digest:    lines,
+		Timestamp: digest.timestamp,
+	})
+	if err != nil {
+		return err
+	}
+	digest.timestamp = timestamp
+	digest.lines = lines
+	digest.projects[project] = i
+	if !ok {
+		if s.digests == nil {
+			s.digests = make(map[string]*slackDigest)
+		}
+		s.digests[key] = digest
+		s.digestKeys = append(s.digestKeys, key)
+		if len(s.digestKeys) > maxSlackThreads {
+			delete(s.digests, s.digestKeys[0])
+			s.digestKeys = s.digestKeys[1:]
+		}
+	}
+	return nil
 }
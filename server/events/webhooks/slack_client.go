@@ -31,7 +31,33 @@ type SlackClient interface {
 	AuthTest() error
 	TokenIsSet() bool
 	ChannelExists(channelName string) (bool, error)
-	PostMessage(channel string, applyResult ApplyResult) error
+	// PostMessage posts msg to channel, or updates the message at
+	// msg.Timestamp if set, and returns its timestamp.
+	PostMessage(channel string, msg SlackMessage) (string, error)
+}
+
+// SlackMessage is a message about a result.
+type SlackMessage struct {
+	Result ApplyResult
+	// Text replaces the default text of the message if set.
+	Text string
+	// ThreadTimestamp is the timestamp of the message to reply to in its
+	// thread. If empty, the message isn't a reply.
+	ThreadTimestamp string
+	// Digest are the latest results of each project of a pull request. If
+	// set, the message is a summary of them, one line each, instead of being
+	// about Result.
+	Digest []SlackDigestLine
+	// Timestamp is the timestamp of the message to update. If empty, a new
+	// message is posted.
+	Timestamp string
+}
+
+// SlackDigestLine is a line of a digest message.
+type SlackDigestLine struct {
+	Result ApplyResult
+	// Text replaces the default text of the line if set.
+	Text string
 }
 
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_underlying_slack_client.go UnderlyingSlackClient
@@ -42,6 +68,7 @@ type UnderlyingSlackClient interface {
 	AuthTest() (response *slack.AuthTestResponse, error error)
 	GetConversations(conversationParams *slack.GetConversationsParameters) (channels []slack.Channel, nextCursor string, err error)
 	PostMessage(channel, text string, parameters slack.PostMessageParameters) (string, string, error)
+	UpdateMessage(channelID, timestamp, text string) (string, string, string, error)
 }
 
 type DefaultSlackClient struct {
@@ -90,16 +117,64 @@ func (d *DefaultSlackClient) ChannelExists(channelName string) (bool, error) {
 	return false, nil
 }
 
-func (d *DefaultSlackClient) PostMessage(channel string, applyResult ApplyResult) error {
+func (d *DefaultSlackClient) PostMessage(channel string, msg SlackMessage) (string, error) {
+	if msg.Digest != nil {
+		return d.postDigest(channel, msg)
+	}
 	params := slack.NewPostMessageParameters()
-	params.Attachments = d.createAttachments(applyResult)
+	params.Attachments = d.createAttachments(msg.Result, msg.Text)
 	params.AsUser = true
 	params.EscapeText = false
-	_, _, err := d.Slack.PostMessage(channel, "", params)
-	return err
+	if msg.ThreadTimestamp != "" {
+		params.ThreadTimestamp = msg.ThreadTimestamp
+	}
+	_, timestamp, err := d.Slack.PostMessage(channel, "", params)
+	return timestamp, err
+}
+
+// postDigest posts or updates a digest. Digests are plain text since
+// messages can only be updated with text.
+func (d *DefaultSlackClient) postDigest(channel string, msg SlackMessage) (string, error) {
+	text := d.createDigest(msg.Digest)
+	if msg.Timestamp != "" {
+		_, _, _, err := d.Slack.UpdateMessage(channel, msg.Timestamp, text)
+		return msg.Timestamp, err
+	}
+	params := slack.NewPostMessageParameters()
+	params.AsUser = true
+	params.EscapeText = false
+	_, timestamp, err := d.Slack.PostMessage(channel, text, params)
+	return timestamp, err
 }
 
-func (d *DefaultSlackClient) createAttachments(applyResult ApplyResult) []slack.Attachment {
+func (d *DefaultSlackClient) createDigest(lines []SlackDigestLine) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	first := lines[0].Result
+	text := fmt.Sprintf("Atlantis results for <%s|%s#%d>", first.Pull.URL, first.Repo.FullName, first.Pull.Num)
+	for _, line := range lines {
+		r := line.Result
+		emoji := ":white_check_mark:"
+		successWord := "succeeded"
+		if !r.Success {
+			emoji = ":x:"
+			successWord = "failed"
+		}
+		lineText := line.Text
+		if lineText == "" {
+			project := r.ProjectName
+			if project == "" {
+				project = fmt.Sprintf("`%s`", r.Directory)
+			}
+			lineText = fmt.Sprintf("%s %s for %s in `%s`", r.Command.TitleString(), successWord, project, r.Workspace)
+		}
+		text += fmt.Sprintf("\n%s %s", emoji, lineText)
+	}
+	return text
+}
+
+func (d *DefaultSlackClient) createAttachments(applyResult ApplyResult, text string) []slack.Attachment {
 	var colour string
 	var successWord string
 	if applyResult.Success {
@@ -110,7 +185,9 @@ func (d *DefaultSlackClient) createAttachments(applyResult ApplyResult) []slack.
 		successWord = "failed"
 	}
 
-	text := fmt.Sprintf("Apply %s for <%s|%s>", successWord, applyResult.Pull.URL, applyResult.Repo.FullName)
+	if text == "" {
+		text = fmt.Sprintf("%s %s for <%s|%s>", applyResult.Command.TitleString(), successWord, applyResult.Pull.URL, applyResult.Repo.FullName)
+	}
 	directory := applyResult.Directory
 	// Since "." looks weird, replace it with "/" to make it clear this is the root.
 	if directory == "." {
@@ -138,5 +215,12 @@ func (d *DefaultSlackClient) createAttachments(applyResult ApplyResult) []slack.
 			},
 		},
 	}
+	if applyResult.ProjectName != "" {
+		attachment.Fields = append(attachment.Fields, slack.AttachmentField{
+			Title: "Project",
+			Value: applyResult.ProjectName,
+			Short: true,
+		})
+	}
 	return []slack.Attachment{attachment}
 }
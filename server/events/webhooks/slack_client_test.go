@@ -22,6 +22,7 @@ import (
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/webhooks"
 	"github.com/runatlantis/atlantis/server/events/webhooks/mocks"
+	"github.com/runatlantis/atlantis/server/events/webhooks/mocks/matchers"
 
 	. "github.com/petergtz/pegomock"
 	. "github.com/runatlantis/atlantis/testing"
@@ -123,8 +124,10 @@ func TestPostMessage_Success(t *testing.T) {
 	expParams.EscapeText = false
 
 	channel := "somechannel"
-	err := client.PostMessage(channel, result)
+	When(underlying.PostMessage(channel, "", expParams)).ThenReturn(channel, "1234.5678", nil)
+	timestamp, err := client.PostMessage(channel, webhooks.SlackMessage{Result: result})
 	Ok(t, err)
+	Equals(t, "1234.5678", timestamp)
 	underlying.VerifyWasCalledOnce().PostMessage(channel, "", expParams)
 
 	t.Log("When apply fails, function should succeed and indicate failure")
@@ -132,11 +135,41 @@ func TestPostMessage_Success(t *testing.T) {
 	expParams.Attachments[0].Color = "danger"
 	expParams.Attachments[0].Text = "Apply failed for <url|runatlantis/atlantis>"
 
-	err = client.PostMessage(channel, result)
+	_, err = client.PostMessage(channel, webhooks.SlackMessage{Result: result})
 	Ok(t, err)
 	underlying.VerifyWasCalledOnce().PostMessage(channel, "", expParams)
 }
 
+func TestPostMessage_PlanErrored(t *testing.T) {
+	t.Log("When a plan of a project errors, the message should say so and include the project")
+	setup(t)
+	result.Command = models.PlanCommand
+	result.Success = false
+	result.ProjectName = "myproject"
+
+	_, err := client.PostMessage("somechannel", webhooks.SlackMessage{Result: result})
+	Ok(t, err)
+	_, _, params := underlying.VerifyWasCalledOnce().PostMessage(AnyString(), AnyString(), matchers.AnySlackPostMessageParameters()).GetCapturedArguments()
+	Equals(t, "danger", params.Attachments[0].Color)
+	Equals(t, "Plan failed for <url|runatlantis/atlantis>", params.Attachments[0].Text)
+	Equals(t, slack.AttachmentField{Title: "Project", Value: "myproject", Short: true}, params.Attachments[0].Fields[3])
+}
+
+func TestPostMessage_TextAndThread(t *testing.T) {
+	t.Log("When the message has a text and thread, they should replace the default text and be replied to")
+	setup(t)
+
+	_, err := client.PostMessage("somechannel", webhooks.SlackMessage{
+		Result:          result,
+		Text:            "custom text",
+		ThreadTimestamp: "1234.5678",
+	})
+	Ok(t, err)
+	_, _, params := underlying.VerifyWasCalledOnce().PostMessage(AnyString(), AnyString(), matchers.AnySlackPostMessageParameters()).GetCapturedArguments()
+	Equals(t, "custom text", params.Attachments[0].Text)
+	Equals(t, "1234.5678", params.ThreadTimestamp)
+}
+
 func TestPostMessage_Error(t *testing.T) {
 	t.Log("When the underlying slack client errors, an error should be returned")
 	setup(t)
@@ -169,7 +202,7 @@ func TestPostMessage_Error(t *testing.T) {
 	channel := "somechannel"
 	When(underlying.PostMessage(channel, "", expParams)).ThenReturn("", "", errors.New(""))
 
-	err := client.PostMessage(channel, result)
+	_, err := client.PostMessage(channel, webhooks.SlackMessage{Result: result})
 	Assert(t, err != nil, "expected error")
 }
 
@@ -195,3 +228,28 @@ func setup(t *testing.T) {
 		Success: true,
 	}
 }
+
+func TestPostMessage_Digest(t *testing.T) {
+	t.Log("When the message is a digest, it should be posted as text and updated once it has a timestamp")
+	setup(t)
+	result.Directory = "."
+	failed := result
+	failed.Success = false
+	When(underlying.PostMessage(AnyString(), AnyString(), matchers.AnySlackPostMessageParameters())).ThenReturn("", "1.1", nil)
+	digest := []webhooks.SlackDigestLine{{Result: result}, {Result: failed, Text: "custom text"}}
+
+	timestamp, err := client.PostMessage("somechannel", webhooks.SlackMessage{Result: result, Digest: digest})
+	Ok(t, err)
+	Equals(t, "1.1", timestamp)
+	exp := "Atlantis results for <url|runatlantis/atlantis#1>\n" +
+		":white_check_mark: Apply succeeded for `.` in `production`\n" +
+		":x: custom text"
+	_, text, params := underlying.VerifyWasCalledOnce().PostMessage(AnyString(), AnyString(), matchers.AnySlackPostMessageParameters()).GetCapturedArguments()
+	Equals(t, exp, text)
+	Equals(t, 0, len(params.Attachments))
+
+	timestamp, err = client.PostMessage("somechannel", webhooks.SlackMessage{Result: result, Digest: digest, Timestamp: "1.1"})
+	Ok(t, err)
+	Equals(t, "1.1", timestamp)
+	underlying.VerifyWasCalledOnce().UpdateMessage("somechannel", "1.1", exp)
+}
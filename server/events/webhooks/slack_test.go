@@ -14,12 +14,16 @@
 package webhooks_test
 
 import (
+	"errors"
 	"regexp"
 	"testing"
+	"text/template"
 
 	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/webhooks"
 	"github.com/runatlantis/atlantis/server/events/webhooks/mocks"
+	"github.com/runatlantis/atlantis/server/events/webhooks/mocks/matchers"
 	"github.com/runatlantis/atlantis/server/logging"
 	. "github.com/runatlantis/atlantis/testing"
 )
@@ -43,7 +47,7 @@ func TestSend_PostMessage(t *testing.T) {
 
 	t.Log("PostMessage should be called, doesn't matter if it errors or not")
 	_ = hook.Send(logging.NewNoopLogger(t), result)
-	client.VerifyWasCalledOnce().PostMessage(channel, result)
+	client.VerifyWasCalledOnce().PostMessage(channel, webhooks.SlackMessage{Result: result})
 }
 
 func TestSend_NoopSuccess(t *testing.T) {
@@ -64,5 +68,140 @@ func TestSend_NoopSuccess(t *testing.T) {
 	}
 	err = hook.Send(logging.NewNoopLogger(t), result)
 	Ok(t, err)
-	client.VerifyWasCalled(Never()).PostMessage(channel, result)
+	client.VerifyWasCalled(Never()).PostMessage(channel, webhooks.SlackMessage{Result: result})
+}
+
+func TestSend_Event(t *testing.T) {
+	t.Log("Sending a hook should only call PostMessage for results of its event")
+	applySucceeded := webhooks.ApplyResult{Command: models.ApplyCommand, Event: webhooks.ApplyFinishedEvent, Success: true}
+	applyFailed := webhooks.ApplyResult{Command: models.ApplyCommand, Event: webhooks.ApplyFinishedEvent, Error: "error"}
+	applyNotRun := webhooks.ApplyResult{Command: models.ApplyCommand, Event: webhooks.ApplyFinishedEvent, Failure: "not approved"}
+	planErrored := webhooks.ApplyResult{Command: models.PlanCommand, Event: webhooks.PlanFinishedEvent, Error: "error"}
+	planNotRun := webhooks.ApplyResult{Command: models.PlanCommand, Event: webhooks.PlanFinishedEvent, Failure: "locked"}
+	policyFailed := webhooks.ApplyResult{Command: models.PolicyCheckCommand, Event: webhooks.PolicyCheckFinishedEvent, Error: "error"}
+	cases := []struct {
+		event  string
+		result webhooks.ApplyResult
+		exp    bool
+	}{
+		{webhooks.ApplyEvent, applySucceeded, true},
+		{webhooks.ApplyEvent, applyFailed, true},
+		{webhooks.ApplyEvent, applyNotRun, false},
+		{webhooks.ApplyEvent, planErrored, false},
+		{webhooks.ApplySucceededEvent, applySucceeded, true},
+		{webhooks.ApplySucceededEvent, applyFailed, false},
+		{webhooks.ApplyFailedEvent, applyFailed, true},
+		{webhooks.ApplyFailedEvent, applySucceeded, false},
+		{webhooks.ApplyFailedEvent, applyNotRun, false},
+		{webhooks.PlanErroredEvent, planErrored, true},
+		{webhooks.PlanErroredEvent, planNotRun, false},
+		{webhooks.PlanErroredEvent, applyFailed, false},
+		{webhooks.PolicyCheckFailedEvent, policyFailed, true},
+		{webhooks.PolicyCheckFailedEvent, planErrored, false},
+	}
+	for _, c := range cases {
+		t.Run(c.event, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			client := mocks.NewMockSlackClient()
+			hook := webhooks.SlackWebhook{
+				Client:         client,
+				WorkspaceRegex: regexp.MustCompile(".*"),
+				Channel:        "somechannel",
+				Event:          c.event,
+			}
+			Ok(t, hook.Send(logging.NewNoopLogger(t), c.result))
+			times := Never()
+			if c.exp {
+				times = Once()
+			}
+			client.VerifyWasCalled(times).PostMessage("somechannel", webhooks.SlackMessage{Result: c.result})
+		})
+	}
+}
+
+func TestSend_Templates(t *testing.T) {
+	t.Log("Sending a hook should post to the channel and with the text its templates render")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	hook := webhooks.SlackWebhook{
+		Client:         client,
+		WorkspaceRegex: regexp.MustCompile(".*"),
+		Channel:        "{{ if eq .Workspace \"production\" }}#deploys-{{ .Workspace }}{{ end }}",
+		Template:       template.Must(template.New("template").Parse("{{ .Command }} of {{ .ProjectName }} in {{ .Repo.FullName }}#{{ .Pull.Num }} by {{ .User.Username }}")),
+	}
+	result := webhooks.ApplyResult{
+		Workspace:   "production",
+		Repo:        models.Repo{FullName: "owner/repo"},
+		Pull:        models.PullRequest{Num: 1},
+		User:        models.User{Username: "user"},
+		ProjectName: "myproject",
+	}
+	Ok(t, hook.Send(logging.NewNoopLogger(t), result))
+	client.VerifyWasCalledOnce().PostMessage("deploys-production", webhooks.SlackMessage{
+		Result: result,
+		Text:   "apply of myproject in owner/repo#1 by user",
+	})
+
+	t.Log("Results the channel renders empty for shouldn't be posted")
+	result.Workspace = "staging"
+	Ok(t, hook.Send(logging.NewNoopLogger(t), result))
+	client.VerifyWasCalled(Once()).PostMessage(AnyString(), matchers.AnyWebhooksSlackMessage())
+}
+
+func TestSend_Thread(t *testing.T) {
+	t.Log("Sending hooks for a pull request should reply in the thread of its first message")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	hook := webhooks.SlackWebhook{
+		Client:         client,
+		WorkspaceRegex: regexp.MustCompile(".*"),
+		Channel:        "somechannel",
+		Thread:         true,
+	}
+	pull1 := webhooks.ApplyResult{Repo: models.Repo{FullName: "owner/repo"}, Pull: models.PullRequest{Num: 1}}
+	pull2 := webhooks.ApplyResult{Repo: models.Repo{FullName: "owner/repo"}, Pull: models.PullRequest{Num: 2}}
+	When(client.PostMessage("somechannel", webhooks.SlackMessage{Result: pull1})).ThenReturn("", errors.New("error"))
+	Assert(t, hook.Send(logging.NewNoopLogger(t), pull1) != nil, "expected error")
+
+	// The first message that's posted starts the thread.
+	When(client.PostMessage("somechannel", webhooks.SlackMessage{Result: pull1})).ThenReturn("1.1", nil)
+	When(client.PostMessage("somechannel", webhooks.SlackMessage{Result: pull2})).ThenReturn("2.1", nil)
+	Ok(t, hook.Send(logging.NewNoopLogger(t), pull1))
+	Ok(t, hook.Send(logging.NewNoopLogger(t), pull2))
+	Ok(t, hook.Send(logging.NewNoopLogger(t), pull1))
+	Ok(t, hook.Send(logging.NewNoopLogger(t), pull1))
+	client.VerifyWasCalled(Times(2)).PostMessage("somechannel", webhooks.SlackMessage{Result: pull1, ThreadTimestamp: "1.1"})
+	client.VerifyWasCalledOnce().PostMessage("somechannel", webhooks.SlackMessage{Result: pull2})
+}
+
+func TestSend_Digest(t *testing.T) {
+	t.Log("Sending hooks for a pull request in digest mode should update one message per pull request")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	hook := webhooks.SlackWebhook{
+		Client:         client,
+		WorkspaceRegex: regexp.MustCompile(".*"),
+		Channel:        "somechannel",
+		Digest:         true,
+	}
+	pull := models.PullRequest{Num: 1}
+	repo := models.Repo{FullName: "owner/repo"}
+	project1 := webhooks.ApplyResult{Repo: repo, Pull: pull, Directory: "project1", Workspace: "default"}
+	project2 := webhooks.ApplyResult{Repo: repo, Pull: pull, Directory: "project2", Workspace: "default"}
+	When(client.PostMessage(AnyString(), matchers.AnyWebhooksSlackMessage())).ThenReturn("1.1", nil)
+
+	Ok(t, hook.Send(logging.NewNoopLogger(t), project1))
+	Ok(t, hook.Send(logging.NewNoopLogger(t), project2))
+	// The latest result of a project replaces its line.
+	applied1 := project1
+	applied1.Success = true
+	Ok(t, hook.Send(logging.NewNoopLogger(t), applied1))
+
+	_, msgs := client.VerifyWasCalled(Times(3)).PostMessage(AnyString(), matchers.AnyWebhooksSlackMessage()).GetAllCapturedArguments()
+	Equals(t, "", msgs[0].Timestamp)
+	Equals(t, []webhooks.SlackDigestLine{{Result: project1}}, msgs[0].Digest)
+	Equals(t, "1.1", msgs[1].Timestamp)
+	Equals(t, []webhooks.SlackDigestLine{{Result: project1}, {Result: project2}}, msgs[1].Digest)
+	Equals(t, "1.1", msgs[2].Timestamp)
+	Equals(t, []webhooks.SlackDigestLine{{Result: applied1}, {Result: project2}}, msgs[2].Digest)
 }
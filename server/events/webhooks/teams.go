@@ -0,0 +1,179 @@
+package webhooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+const (
+	teamsSuccessColor = "Good"
+	teamsFailureColor = "Attention"
+)
+
+// TeamsWebhook sends webhooks to a Microsoft Teams channel as Adaptive Cards
+// through the channel's incoming webhook.
+type TeamsWebhook struct {
+	// URL is the incoming webhook URL. If it contains "{{", it's a
+	// template rendered with the result. Results it renders empty for
+	// aren't posted.
+	URL            string
+	Client         *http.Client
+	WorkspaceRegex *regexp.Regexp
+	// Event is the event to post. If empty, it's ApplyEvent.
+	Event string
+	// Template renders the text of cards. If nil, a default text is used.
+	Template *template.Template
+}
+
+// teamsMessage is the body of a message to an incoming webhook.
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string    `json:"contentType"`
+	Content     teamsCard `json:"content"`
+}
+
+// teamsCard is an Adaptive Card, see https://adaptivecards.io.
+type teamsCard struct {
+	Schema  string        `json:"$schema"`
+	Type    string        `json:"type"`
+	Version string        `json:"version"`
+	Body    []interface{} `json:"body"`
+	Actions []teamsAction `json:"actions,omitempty"`
+}
+
+type teamsTextBlock struct {
+	Type   string `json:"type"`
+	Text   string `json:"text"`
+	Wrap   bool   `json:"wrap"`
+	Weight string `json:"weight,omitempty"`
+	Color  string `json:"color,omitempty"`
+}
+
+type teamsFactSet struct {
+	Type  string      `json:"type"`
+	Facts []teamsFact `json:"facts"`
+}
+
+type teamsFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type teamsAction struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// NewTeams returns a TeamsWebhook that posts results of workspaces matching
+// r to webhookURL.
+func NewTeams(r *regexp.Regexp, webhookURL string) (*TeamsWebhook, error) {
+	if isTemplate(webhookURL) {
+		if _, err := template.New("url").Parse(webhookURL); err != nil {
+			return nil, errors.Wrap(err, "parsing url template")
+		}
+	}
+	return &TeamsWebhook{
+		URL:            webhookURL,
+		Client:         &http.Client{Timeout: 10 * time.Second},
+		WorkspaceRegex: r,
+	}, nil
+}
+
+// Send posts the webhook to Teams if the result is of its event and the
+// workspace matches the regex.
+func (t *TeamsWebhook) Send(log logging.SimpleLogging, applyResult ApplyResult) error {
+	if !applyResult.MatchesEvent(t.Event) || !t.WorkspaceRegex.MatchString(applyResult.Workspace) {
+		return nil
+	}
+	webhookURL, err := render("url", t.URL, applyResult)
+	if err != nil {
+		return err
+	}
+	webhookURL = strings.TrimSpace(webhookURL)
+	if webhookURL == "" {
+		log.Debug("not posting to teams since the url template rendered empty")
+		return nil
+	}
+	var text string
+	if t.Template != nil {
+		if text, err = execute(t.Template, applyResult); err != nil {
+			return err
+		}
+	}
+	body, err := json.Marshal(t.createMessage(applyResult, text))
+	if err != nil {
+		return errors.Wrap(err, "serializing teams message")
+	}
+	resp, err := t.Client.Post(webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// The URL is a secret so it's left out of the error.
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return errors.Wrap(err, "posting teams message")
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("posting teams message: responded with %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (t *TeamsWebhook) createMessage(applyResult ApplyResult, text string) teamsMessage {
+	color := teamsSuccessColor
+	successWord := "succeeded"
+	if !applyResult.Success {
+		color = teamsFailureColor
+		successWord = "failed"
+	}
+	if text == "" {
+		text = fmt.Sprintf("%s %s for %s", applyResult.Command.TitleString(), successWord, applyResult.Repo.FullName)
+	}
+	directory := applyResult.Directory
+	// Since "." looks weird, replace it with "/" to make it clear this is the root.
+	if directory == "." {
+		directory = "/"
+	}
+	facts := []teamsFact{
+		{Title: "Workspace", Value: applyResult.Workspace},
+		{Title: "User", Value: applyResult.User.Username},
+		{Title: "Directory", Value: directory},
+	}
+	if applyResult.ProjectName != "" {
+		facts = append(facts, teamsFact{Title: "Project", Value: applyResult.ProjectName})
+	}
+	card := teamsCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.2",
+		Body: []interface{}{
+			teamsTextBlock{Type: "TextBlock", Text: text, Wrap: true, Weight: "Bolder", Color: color},
+			teamsFactSet{Type: "FactSet", Facts: facts},
+		},
+	}
+	if applyResult.Pull.URL != "" {
+		card.Actions = []teamsAction{{Type: "Action.OpenUrl", Title: "View Pull Request", URL: applyResult.Pull.URL}}
+	}
+	return teamsMessage{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content:     card,
+		}},
+	}
+}
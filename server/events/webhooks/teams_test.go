@@ -0,0 +1,109 @@
+package webhooks_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"text/template"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/webhooks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestTeamsWebhook_Send(t *testing.T) {
+	var received []map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "application/json", r.Header.Get("Content-Type"))
+		var msg map[string]interface{}
+		Ok(t, json.NewDecoder(r.Body).Decode(&msg))
+		received = append(received, msg)
+	}))
+	defer ts.Close()
+
+	hook, err := webhooks.NewTeams(regexp.MustCompile("prod.*"), ts.URL)
+	Ok(t, err)
+	hook.Event = webhooks.PlanErroredEvent
+	result := webhooks.ApplyResult{
+		Workspace:   "production",
+		Repo:        models.Repo{FullName: "owner/repo"},
+		Pull:        models.PullRequest{Num: 1, URL: "https://github.com/owner/repo/pull/1"},
+		User:        models.User{Username: "user"},
+		Directory:   ".",
+		Command:     models.PlanCommand,
+		ProjectName: "myproject",
+		Error:       "exit status 1",
+		Event:       webhooks.PlanFinishedEvent,
+	}
+	Ok(t, hook.Send(logging.NewNoopLogger(t), result))
+
+	t.Log("Results of other events or workspaces shouldn't be posted")
+	staging := result
+	staging.Workspace = "staging"
+	Ok(t, hook.Send(logging.NewNoopLogger(t), staging))
+	apply := result
+	apply.Command = models.ApplyCommand
+	apply.Event = webhooks.ApplyFinishedEvent
+	Ok(t, hook.Send(logging.NewNoopLogger(t), apply))
+
+	Equals(t, 1, len(received))
+	var exp map[string]interface{}
+	Ok(t, json.Unmarshal([]byte(`{
+  "type": "message",
+  "attachments": [{
+    "contentType": "application/vnd.microsoft.card.adaptive",
+    "content": {
+      "$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+      "type": "AdaptiveCard",
+      "version": "1.2",
+      "body": [
+        {"type": "TextBlock", "text": "Plan failed for owner/repo", "wrap": true, "weight": "Bolder", "color": "Attention"},
+        {"type": "FactSet", "facts": [
+          {"title": "Workspace", "value": "production"},
+          {"title": "User", "value": "user"},
+          {"title": "Directory", "value": "/"},
+          {"title": "Project", "value": "myproject"}
+        ]}
+      ],
+      "actions": [{"type": "Action.OpenUrl", "title": "View Pull Request", "url": "https://github.com/owner/repo/pull/1"}]
+    }
+  }]
+}`), &exp))
+	Equals(t, exp, received[0])
+
+	t.Log("The template should replace the default text")
+	hook.Template = template.Must(template.New("template").Parse("{{ .User.Username }}'s plan of {{ .ProjectName }} errored"))
+	Ok(t, hook.Send(logging.NewNoopLogger(t), result))
+	body := received[1]["attachments"].([]interface{})[0].(map[string]interface{})["content"].(map[string]interface{})["body"].([]interface{})
+	Equals(t, "user's plan of myproject errored", body[0].(map[string]interface{})["text"])
+}
+
+func TestTeamsWebhook_SendURLTemplate(t *testing.T) {
+	posted := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "/production", r.URL.Path)
+		posted++
+	}))
+	defer ts.Close()
+
+	hook, err := webhooks.NewTeams(regexp.MustCompile(".*"), `{{ if eq .Workspace "production" }}`+ts.URL+`/production{{ end }}`)
+	Ok(t, err)
+	Ok(t, hook.Send(logging.NewNoopLogger(t), webhooks.ApplyResult{Workspace: "production"}))
+	Ok(t, hook.Send(logging.NewNoopLogger(t), webhooks.ApplyResult{Workspace: "staging"}))
+	Equals(t, 1, posted)
+}
+
+func TestTeamsWebhook_SendErrOnNon2xx(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	hook, err := webhooks.NewTeams(regexp.MustCompile(".*"), ts.URL)
+	Ok(t, err)
+	err = hook.Send(logging.NewNoopLogger(t), webhooks.ApplyResult{})
+	ErrEquals(t, "posting teams message: responded with 400", err)
+}
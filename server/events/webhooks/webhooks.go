@@ -16,15 +16,70 @@ package webhooks
 import (
 	"fmt"
 	"regexp"
+	"strings"
+	"text/template"
 
-	"errors"
-
+	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/logging"
 )
 
-const SlackKind = "slack"
-const ApplyEvent = "apply"
+const (
+	SlackKind = "slack"
+	// TeamsKind posts to a Microsoft Teams channel.
+	TeamsKind = "msteams"
+	// HTTPKind POSTs signed JSON to a URL.
+	HTTPKind = "http"
+	// SNSKind publishes JSON to an Amazon SNS topic.
+	SNSKind = "sns"
+	// EventBridgeKind puts JSON on an Amazon EventBridge event bus.
+	EventBridgeKind = "eventbridge"
+	// PagerDutyKind triggers PagerDuty incidents when applies fail.
+	PagerDutyKind = "pagerduty"
+	// OpsgenieKind creates Opsgenie alerts when applies fail.
+	OpsgenieKind = "opsgenie"
+)
+
+// kinds are the kinds of webhooks and eventKinds the ones that send events as
+// JSON, which can be sent for lifecycle events.
+var kinds = []string{SlackKind, TeamsKind, HTTPKind, SNSKind, EventBridgeKind, PagerDutyKind, OpsgenieKind}
+var eventKinds = []string{HTTPKind, SNSKind, EventBridgeKind}
+
+const (
+	// ApplyEvent is sent when an apply succeeds or fails.
+	ApplyEvent = "apply"
+	// ApplySucceededEvent is sent when an apply succeeds.
+	ApplySucceededEvent = "apply-succeeded"
+	// ApplyFailedEvent is sent when an apply fails.
+	ApplyFailedEvent = "apply-failed"
+	// PlanErroredEvent is sent when a plan errors.
+	PlanErroredEvent = "plan-errored"
+	// PolicyCheckFailedEvent is sent when a policy check fails.
+	PolicyCheckFailedEvent = "policy-check-failed"
+)
+
+// Lifecycle events are sent as commands run and locks change, whether or
+// not they succeed. They're only sent by webhooks of eventKinds.
+const (
+	PlanStartedEvent  = "plan-started"
+	PlanFinishedEvent = "plan-finished"
+	// ApplyStartedEvent and ApplyFinishedEvent are also sent for applies
+	// that don't run, ex. because they aren't approved, unlike ApplyEvent.
+	ApplyStartedEvent        = "apply-started"
+	ApplyFinishedEvent       = "apply-finished"
+	PolicyCheckFinishedEvent = "policy-check-finished"
+	LockAcquiredEvent        = "lock-acquired"
+	LockReleasedEvent        = "lock-released"
+)
+
+// events are the events webhooks of any kind can be sent for and
+// lifecycleEvents the ones only webhooks of eventKinds can.
+var events = []string{ApplyEvent, ApplySucceededEvent, ApplyFailedEvent, PlanErroredEvent, PolicyCheckFailedEvent}
+var lifecycleEvents = []string{PlanStartedEvent, PlanFinishedEvent, ApplyStartedEvent, ApplyFinishedEvent, PolicyCheckFinishedEvent, LockAcquiredEvent, LockReleasedEvent}
+
+// maxErrorLen is the maximum length of ApplyResult.Error. Longer errors are
+// truncated to their end since that's where terraform prints them.
+const maxErrorLen = 2000
 
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_sender.go Sender
 
@@ -34,7 +89,9 @@ type Sender interface {
 	Send(log logging.SimpleLogging, applyResult ApplyResult) error
 }
 
-// ApplyResult is the result of a terraform apply.
+// ApplyResult is the result of a terraform apply, or of the plan or policy
+// check in Command, or a lifecycle event. It's also the data of message
+// templates.
 type ApplyResult struct {
 	Workspace string
 	Repo      models.Repo
@@ -42,6 +99,96 @@ type ApplyResult struct {
 	User      models.User
 	Success   bool
 	Directory string
+	// Command is the command that ran. It's models.ApplyCommand unless
+	// set.
+	Command     models.CommandName
+	ProjectName string
+	// Failure is why the command didn't run, if it didn't, ex. because the
+	// project is locked by another pull request.
+	Failure string
+	// Error is the end of the error the command failed with, if it did.
+	Error string
+	// Event is the lifecycle event of the result. It's ApplyFinishedEvent
+	// unless set.
+	Event string
+}
+
+// finishedEvents are the lifecycle events sent when each command finishes.
+var finishedEvents = map[models.CommandName]string{
+	models.PlanCommand:        PlanFinishedEvent,
+	models.ApplyCommand:       ApplyFinishedEvent,
+	models.PolicyCheckCommand: PolicyCheckFinishedEvent,
+}
+
+// NewApplyResult returns the result of command for the project described by
+// ctx. failure is why it didn't run and err the error it failed with, if
+// any.
+func NewApplyResult(ctx models.ProjectCommandContext, command models.CommandName, failure string, err error) ApplyResult {
+	result := ApplyResult{
+		Workspace:   ctx.Workspace,
+		Repo:        ctx.Pull.BaseRepo,
+		Pull:        ctx.Pull,
+		User:        ctx.User,
+		Success:     failure == "" && err == nil,
+		Directory:   ctx.RepoRelDir,
+		Command:     command,
+		ProjectName: ctx.ProjectName,
+		Failure:     failure,
+		Event:       finishedEvents[command],
+	}
+	if err != nil {
+		result.Error = strings.TrimSpace(err.Error())
+		if len(result.Error) > maxErrorLen {
+			result.Error = "..." + result.Error[len(result.Error)-maxErrorLen:]
+		}
+	}
+	return result
+}
+
+// NewStartedResult returns the result sent when command, either a plan or
+// apply, starts for the project described by ctx.
+func NewStartedResult(ctx models.ProjectCommandContext, command models.CommandName) ApplyResult {
+	result := NewApplyResult(ctx, command, "", nil)
+	result.Success = false
+	result.Event = PlanStartedEvent
+	if command == models.ApplyCommand {
+		result.Event = ApplyStartedEvent
+	}
+	return result
+}
+
+// MatchesEvent returns whether the result is of event. An empty event is
+// ApplyEvent.
+func (r ApplyResult) MatchesEvent(event string) bool {
+	resultEvent := r.Event
+	if resultEvent == "" {
+		resultEvent = ApplyFinishedEvent
+	}
+	// Applies that didn't run aren't ApplyEvents.
+	applied := resultEvent == ApplyFinishedEvent && r.Failure == ""
+	switch event {
+	case ApplyEvent, "":
+		return applied
+	case ApplySucceededEvent:
+		return applied && r.Success
+	case ApplyFailedEvent:
+		return applied && !r.Success
+	case PlanErroredEvent:
+		return resultEvent == PlanFinishedEvent && r.Error != ""
+	case PolicyCheckFailedEvent:
+		return resultEvent == PolicyCheckFinishedEvent && r.Error != ""
+	}
+	return resultEvent == event
+}
+
+// Failed returns whether the result is of a command that failed or didn't
+// run. Results of commands starting and of locks never fail.
+func (r ApplyResult) Failed() bool {
+	switch r.Event {
+	case PlanStartedEvent, ApplyStartedEvent, LockAcquiredEvent, LockReleasedEvent:
+		return false
+	}
+	return !r.Success
 }
 
 // MultiWebhookSender sends multiple webhooks for each one it's configured for.
@@ -52,8 +199,43 @@ type MultiWebhookSender struct {
 type Config struct {
 	Event          string
 	WorkspaceRegex string
-	Kind           string
-	Channel        string
+	// RepoRegex only sends the webhook for repos whose full name matches it,
+	// ex. to route the alerts of each repo to its team. If empty, it's sent
+	// for every repo.
+	RepoRegex string
+	Kind      string
+	// Channel is the Slack channel to post to. It's a template, rendered
+	// with the ApplyResult, so results can be routed by workspace.
+	Channel string
+	// URL is the incoming webhook URL of the Teams channel to post to. Like
+	// Channel, it's a template. For HTTP webhooks, it's the URL to POST to
+	// and for PagerDuty and Opsgenie webhooks it overrides the URL of their
+	// API.
+	URL string
+	// Secret is the key of the signatures of HTTP webhooks.
+	Secret string
+	// TopicARN is the ARN of the topic SNS webhooks publish to.
+	TopicARN string
+	// EventBus is the name or ARN of the event bus EventBridge webhooks put
+	// events on. If empty, it's the default event bus.
+	EventBus string
+	// RoutingKey is the integration key of the PagerDuty service to trigger
+	// incidents of.
+	RoutingKey string
+	// APIKey is the key of the Opsgenie API integration to create alerts
+	// with.
+	APIKey string
+	// Template is the text of messages, rendered with the ApplyResult. If
+	// empty, a default text is used.
+	Template string
+	// Thread posts the events of a pull request after its first one as
+	// replies in the thread of the first. It only applies to Slack.
+	Thread bool
+	// Digest posts one message per pull request summarizing the latest
+	// result of each project, updated in place. It only applies to Slack.
+	Digest bool
+	// FailuresOnly only sends the webhook for results that failed.
+	FailuresOnly bool
 }
 
 func NewMultiWebhookSender(configs []Config, client SlackClient) (*MultiWebhookSender, error) {
@@ -66,9 +248,34 @@ func NewMultiWebhookSender(configs []Config, client SlackClient) (*MultiWebhookS
 		if c.Kind == "" || c.Event == "" {
 			return nil, errors.New("must specify \"kind\" and \"event\" keys for webhooks")
 		}
-		if c.Event != ApplyEvent {
-			return nil, fmt.Errorf("\"event: %s\" not supported. Only \"event: %s\" is supported right now", c.Event, ApplyEvent)
+		if !contains(events, c.Event) && !contains(lifecycleEvents, c.Event) {
+			return nil, fmt.Errorf("\"event: %s\" not supported. Must be one of %q", c.Event, append(events, lifecycleEvents...))
+		}
+		if (c.Kind == PagerDutyKind || c.Kind == OpsgenieKind) && c.Event != ApplyEvent {
+			return nil, fmt.Errorf("\"kind: %s\" only supports \"event: %s\"", c.Kind, ApplyEvent)
+		}
+		if !contains(eventKinds, c.Kind) && contains(lifecycleEvents, c.Event) {
+			return nil, fmt.Errorf("\"event: %s\" is only supported by webhooks of kind %q", c.Event, eventKinds)
+		}
+		var tmpl *template.Template
+		if c.Template != "" {
+			if tmpl, err = template.New("template").Parse(c.Template); err != nil {
+				return nil, fmt.Errorf("parsing %s message template: %s", c.Kind, err)
+			}
+		}
+		if c.Digest && c.Kind != SlackKind {
+			return nil, errors.New("\"digest\" is only supported by webhooks of \"kind: slack\"")
+		}
+		if c.Digest && c.Thread {
+			return nil, errors.New("\"digest\" and \"thread\" can't both be set")
+		}
+		var repoRegex *regexp.Regexp
+		if c.RepoRegex != "" {
+			if repoRegex, err = regexp.Compile(c.RepoRegex); err != nil {
+				return nil, err
+			}
 		}
+		var sender Sender
 		switch c.Kind {
 		case SlackKind:
 			if !client.TokenIsSet() {
@@ -81,10 +288,59 @@ func NewMultiWebhookSender(configs []Config, client SlackClient) (*MultiWebhookS
 			if err != nil {
 				return nil, err
 			}
-			webhooks = append(webhooks, slack)
+			slack.Event = c.Event
+			slack.Template = tmpl
+			slack.Thread = c.Thread
+			slack.Digest = c.Digest
+			sender = slack
+		case TeamsKind:
+			if c.URL == "" {
+				return nil, errors.New("must specify \"url\" if using a webhook of \"kind: msteams\"")
+			}
+			teams, err := NewTeams(r, c.URL)
+			if err != nil {
+				return nil, err
+			}
+			teams.Event = c.Event
+			teams.Template = tmpl
+			sender = teams
+		case HTTPKind:
+			if c.URL == "" {
+				return nil, errors.New("must specify \"url\" if using a webhook of \"kind: http\"")
+			}
+			sender = NewHTTP(r, c.Event, c.URL, c.Secret)
+		case SNSKind:
+			if c.TopicARN == "" {
+				return nil, errors.New("must specify \"topic-arn\" if using a webhook of \"kind: sns\"")
+			}
+			sns, err := NewSNS(r, c.Event, c.TopicARN)
+			if err != nil {
+				return nil, err
+			}
+			sender = sns
+		case EventBridgeKind:
+			eventBridge, err := NewEventBridge(r, c.Event, c.EventBus)
+			if err != nil {
+				return nil, err
+			}
+			sender = eventBridge
+		case PagerDutyKind:
+			if c.RoutingKey == "" {
+				return nil, errors.New("must specify \"routing-key\" if using a webhook of \"kind: pagerduty\"")
+			}
+			sender = NewPagerDuty(r, c.URL, c.RoutingKey)
+		case OpsgenieKind:
+			if c.APIKey == "" {
+				return nil, errors.New("must specify \"api-key\" if using a webhook of \"kind: opsgenie\"")
+			}
+			sender = NewOpsgenie(r, c.URL, c.APIKey)
 		default:
-			return nil, fmt.Errorf("\"kind: %s\" not supported. Only \"kind: %s\" is supported right now", c.Kind, SlackKind)
+			return nil, fmt.Errorf("\"kind: %s\" not supported. Must be one of %q", c.Kind, kinds)
+		}
+		if repoRegex != nil || c.FailuresOnly {
+			sender = &filter{Sender: sender, RepoRegex: repoRegex, FailuresOnly: c.FailuresOnly}
 		}
+		webhooks = append(webhooks, sender)
 	}
 
 	return &MultiWebhookSender{
@@ -96,8 +352,61 @@ func NewMultiWebhookSender(configs []Config, client SlackClient) (*MultiWebhookS
 func (w *MultiWebhookSender) Send(log logging.SimpleLogging, result ApplyResult) error {
 	for _, w := range w.Webhooks {
 		if err := w.Send(log, result); err != nil {
-			log.Warn("error sending slack webhook: %s", err)
+			log.Warn("error sending webhook: %s", err)
 		}
 	}
 	return nil
 }
+
+// filter only sends webhooks of repos matching RepoRegex, if set, and of
+// failed results if FailuresOnly.
+type filter struct {
+	Sender
+	RepoRegex    *regexp.Regexp
+	FailuresOnly bool
+}
+
+func (f *filter) Send(log logging.SimpleLogging, applyResult ApplyResult) error {
+	if f.RepoRegex != nil && !f.RepoRegex.MatchString(applyResult.Repo.FullName) {
+		return nil
+	}
+	if f.FailuresOnly && !applyResult.Failed() {
+		return nil
+	}
+	return f.Sender.Send(log, applyResult)
+}
+
+func contains(events []string, event string) bool {
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// render renders the template text with applyResult. If text isn't a
+// template, it's returned as is.
+func render(name string, text string, applyResult ApplyResult) (string, error) {
+	if !isTemplate(text) {
+		return text, nil
+	}
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing %s template", name)
+	}
+	return execute(tmpl, applyResult)
+}
+
+// execute renders tmpl with applyResult.
+func execute(tmpl *template.Template, applyResult ApplyResult) (string, error) {
+	var out strings.Builder
+	if err := tmpl.Execute(&out, applyResult); err != nil {
+		return "", errors.Wrapf(err, "rendering %s template", tmpl.Name())
+	}
+	return out.String(), nil
+}
+
+func isTemplate(s string) bool {
+	return strings.Contains(s, "{{")
+}
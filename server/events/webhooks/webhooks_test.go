@@ -18,8 +18,10 @@ import (
 	"testing"
 
 	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/webhooks"
 	"github.com/runatlantis/atlantis/server/events/webhooks/mocks"
+	"github.com/runatlantis/atlantis/server/events/webhooks/mocks/matchers"
 	"github.com/runatlantis/atlantis/server/logging"
 	. "github.com/runatlantis/atlantis/testing"
 )
@@ -78,7 +80,38 @@ func TestNewWebhooksManager_UnsupportedEvent(t *testing.T) {
 	configs[0].Event = unsupportedEvent
 	_, err := webhooks.NewMultiWebhookSender(configs, client)
 	Assert(t, err != nil, "expected error")
-	Equals(t, "\"event: badevent\" not supported. Only \"event: apply\" is supported right now", err.Error())
+	Equals(t, "\"event: badevent\" not supported. Must be one of [\"apply\" \"apply-succeeded\" \"apply-failed\" \"plan-errored\" \"policy-check-failed\" \"plan-started\" \"plan-finished\" \"apply-started\" \"apply-finished\" \"policy-check-finished\" \"lock-acquired\" \"lock-released\"]", err.Error())
+}
+
+func TestNewWebhooksManager_InvalidTemplate(t *testing.T) {
+	t.Log("When given a template that doesn't parse in a config, an error is returned")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	When(client.TokenIsSet()).ThenReturn(true)
+	When(client.ChannelExists(validChannel)).ThenReturn(true, nil)
+
+	configs := validConfigs()
+	configs[0].Template = "{{ .Workspace"
+	_, err := webhooks.NewMultiWebhookSender(configs, client)
+	ErrContains(t, "parsing slack message template", err)
+}
+
+func TestNewWebhooksManager_TemplatedChannel(t *testing.T) {
+	t.Log("When the channel is a template, it isn't checked to exist")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	When(client.TokenIsSet()).ThenReturn(true)
+
+	configs := validConfigs()
+	configs[0].Event = webhooks.PlanErroredEvent
+	configs[0].Channel = "deploys-{{ .Workspace }}"
+	configs[0].Thread = true
+	m, err := webhooks.NewMultiWebhookSender(configs, client)
+	Ok(t, err)
+	client.VerifyWasCalled(Never()).ChannelExists(AnyString())
+	hook := m.Webhooks[0].(*webhooks.SlackWebhook)
+	Equals(t, webhooks.PlanErroredEvent, hook.Event)
+	Equals(t, true, hook.Thread)
 }
 
 func TestNewWebhooksManager_NoKind(t *testing.T) {
@@ -103,7 +136,28 @@ func TestNewWebhooksManager_UnsupportedKind(t *testing.T) {
 	configs[0].Kind = unsupportedKind
 	_, err := webhooks.NewMultiWebhookSender(configs, client)
 	Assert(t, err != nil, "expected error")
-	Equals(t, "\"kind: badkind\" not supported. Only \"kind: slack\" is supported right now", err.Error())
+	Equals(t, "\"kind: badkind\" not supported. Must be one of [\"slack\" \"msteams\" \"http\" \"sns\" \"eventbridge\" \"pagerduty\" \"opsgenie\"]", err.Error())
+}
+
+func TestNewWebhooksManager_Teams(t *testing.T) {
+	t.Log("When given a msteams config, a url is required and no slack token is needed")
+	RegisterMockTestingT(t)
+	configs := []webhooks.Config{{
+		Event:          webhooks.ApplyFailedEvent,
+		WorkspaceRegex: validRegex,
+		Kind:           webhooks.TeamsKind,
+	}}
+	_, err := webhooks.NewMultiWebhookSender(configs, nil)
+	ErrEquals(t, "must specify \"url\" if using a webhook of \"kind: msteams\"", err)
+
+	configs[0].URL = "https://example.webhook.office.com/webhookb2/abc"
+	configs[0].Template = "{{ .Repo.FullName }} failed"
+	m, err := webhooks.NewMultiWebhookSender(configs, nil)
+	Ok(t, err)
+	hook := m.Webhooks[0].(*webhooks.TeamsWebhook)
+	Equals(t, configs[0].URL, hook.URL)
+	Equals(t, webhooks.ApplyFailedEvent, hook.Event)
+	Assert(t, hook.Template != nil, "exp template to be parsed")
 }
 
 func TestNewWebhooksManager_NoConfigSuccess(t *testing.T) {
@@ -182,3 +236,181 @@ func TestSend_MultipleSuccess(t *testing.T) {
 		s.VerifyWasCalledOnce().Send(logger, result)
 	}
 }
+
+func TestNewWebhooksManager_HTTP(t *testing.T) {
+	t.Log("When given an http config, a url is required and lifecycle events are supported")
+	configs := []webhooks.Config{{
+		Event:          webhooks.PlanStartedEvent,
+		WorkspaceRegex: validRegex,
+		Kind:           webhooks.HTTPKind,
+	}}
+	_, err := webhooks.NewMultiWebhookSender(configs, nil)
+	ErrEquals(t, "must specify \"url\" if using a webhook of \"kind: http\"", err)
+
+	configs[0].URL = "https://example.com/atlantis"
+	configs[0].Secret = "secret"
+	m, err := webhooks.NewMultiWebhookSender(configs, nil)
+	Ok(t, err)
+	hook := m.Webhooks[0].(*webhooks.HTTPWebhook)
+	Equals(t, configs[0].URL, hook.URL)
+	Equals(t, "secret", hook.Secret)
+	Equals(t, webhooks.PlanStartedEvent, hook.Event)
+}
+
+func TestNewWebhooksManager_SNS(t *testing.T) {
+	t.Log("When given an sns config, a valid topic-arn is required")
+	configs := []webhooks.Config{{
+		Event:          webhooks.LockAcquiredEvent,
+		WorkspaceRegex: validRegex,
+		Kind:           webhooks.SNSKind,
+	}}
+	_, err := webhooks.NewMultiWebhookSender(configs, nil)
+	ErrEquals(t, "must specify \"topic-arn\" if using a webhook of \"kind: sns\"", err)
+
+	configs[0].TopicARN = "atlantis"
+	_, err = webhooks.NewMultiWebhookSender(configs, nil)
+	ErrContains(t, "parsing topic-arn \"atlantis\"", err)
+
+	configs[0].TopicARN = "arn:aws:sns:us-east-1:123456789012:atlantis"
+	m, err := webhooks.NewMultiWebhookSender(configs, nil)
+	Ok(t, err)
+	hook := m.Webhooks[0].(*webhooks.SNSWebhook)
+	Equals(t, configs[0].TopicARN, hook.TopicARN)
+	Equals(t, webhooks.LockAcquiredEvent, hook.Event)
+}
+
+func TestNewWebhooksManager_EventBridge(t *testing.T) {
+	t.Log("When given an eventbridge config, the event bus defaults to the default bus")
+	configs := []webhooks.Config{{
+		Event:          webhooks.ApplyFinishedEvent,
+		WorkspaceRegex: validRegex,
+		Kind:           webhooks.EventBridgeKind,
+	}}
+	m, err := webhooks.NewMultiWebhookSender(configs, nil)
+	Ok(t, err)
+	hook := m.Webhooks[0].(*webhooks.EventBridgeWebhook)
+	Equals(t, "default", hook.EventBus)
+	Equals(t, webhooks.ApplyFinishedEvent, hook.Event)
+
+	configs[0].EventBus = "deploys"
+	m, err = webhooks.NewMultiWebhookSender(configs, nil)
+	Ok(t, err)
+	Equals(t, "deploys", m.Webhooks[0].(*webhooks.EventBridgeWebhook).EventBus)
+}
+
+func TestNewWebhooksManager_Alerts(t *testing.T) {
+	t.Log("When given a pagerduty or opsgenie config, a key and the apply event are required")
+	configs := []webhooks.Config{{
+		Event:          webhooks.ApplyFailedEvent,
+		WorkspaceRegex: validRegex,
+		Kind:           webhooks.PagerDutyKind,
+	}}
+	_, err := webhooks.NewMultiWebhookSender(configs, nil)
+	ErrEquals(t, "\"kind: pagerduty\" only supports \"event: apply\"", err)
+
+	configs[0].Event = webhooks.ApplyEvent
+	_, err = webhooks.NewMultiWebhookSender(configs, nil)
+	ErrEquals(t, "must specify \"routing-key\" if using a webhook of \"kind: pagerduty\"", err)
+
+	configs[0].RoutingKey = "key"
+	m, err := webhooks.NewMultiWebhookSender(configs, nil)
+	Ok(t, err)
+	pagerDuty := m.Webhooks[0].(*webhooks.PagerDutyWebhook)
+	Equals(t, webhooks.PagerDutyURL, pagerDuty.URL)
+	Equals(t, "key", pagerDuty.RoutingKey)
+
+	configs[0].Kind = webhooks.OpsgenieKind
+	_, err = webhooks.NewMultiWebhookSender(configs, nil)
+	ErrEquals(t, "must specify \"api-key\" if using a webhook of \"kind: opsgenie\"", err)
+
+	configs[0].APIKey = "key"
+	configs[0].URL = "https://api.eu.opsgenie.com/v2/alerts"
+	m, err = webhooks.NewMultiWebhookSender(configs, nil)
+	Ok(t, err)
+	opsgenie := m.Webhooks[0].(*webhooks.OpsgenieWebhook)
+	Equals(t, configs[0].URL, opsgenie.URL)
+	Equals(t, "key", opsgenie.APIKey)
+}
+
+func TestNewWebhooksManager_RepoRegex(t *testing.T) {
+	t.Log("When given a repo regex, only webhooks of matching repos are sent")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	When(client.TokenIsSet()).ThenReturn(true)
+	When(client.ChannelExists(validChannel)).ThenReturn(true, nil)
+	configs := validConfigs()
+	configs[0].RepoRegex = "("
+	_, err := webhooks.NewMultiWebhookSender(configs, client)
+	ErrContains(t, "error parsing regexp", err)
+
+	configs[0].RepoRegex = "^owner/"
+	m, err := webhooks.NewMultiWebhookSender(configs, client)
+	Ok(t, err)
+	result := webhooks.ApplyResult{Workspace: "production", Repo: models.Repo{FullName: "other/repo"}, Success: true}
+	Ok(t, m.Send(logging.NewNoopLogger(t), result))
+	client.VerifyWasCalled(Never()).PostMessage(AnyString(), matchers.AnyWebhooksSlackMessage())
+
+	result.Repo.FullName = "owner/repo"
+	Ok(t, m.Send(logging.NewNoopLogger(t), result))
+	client.VerifyWasCalledOnce().PostMessage(AnyString(), matchers.AnyWebhooksSlackMessage())
+}
+
+func TestNewWebhooksManager_Digest(t *testing.T) {
+	t.Log("When given a digest, the webhook must be a slack webhook without threads")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	When(client.TokenIsSet()).ThenReturn(true)
+	When(client.ChannelExists(validChannel)).ThenReturn(true, nil)
+	configs := validConfigs()
+	configs[0].Digest = true
+	configs[0].Thread = true
+	_, err := webhooks.NewMultiWebhookSender(configs, client)
+	ErrEquals(t, "\"digest\" and \"thread\" can't both be set", err)
+
+	configs[0].Thread = false
+	m, err := webhooks.NewMultiWebhookSender(configs, client)
+	Ok(t, err)
+	Assert(t, m.Webhooks[0].(*webhooks.SlackWebhook).Digest, "exp digest")
+
+	configs[0].Kind = webhooks.TeamsKind
+	configs[0].URL = "https://example.com"
+	_, err = webhooks.NewMultiWebhookSender(configs, client)
+	ErrEquals(t, "\"digest\" is only supported by webhooks of \"kind: slack\"", err)
+}
+
+func TestNewWebhooksManager_FailuresOnly(t *testing.T) {
+	t.Log("When given failures-only, only webhooks of failed results are sent")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	When(client.TokenIsSet()).ThenReturn(true)
+	When(client.ChannelExists(validChannel)).ThenReturn(true, nil)
+	configs := validConfigs()
+	configs[0].FailuresOnly = true
+	m, err := webhooks.NewMultiWebhookSender(configs, client)
+	Ok(t, err)
+	result := webhooks.ApplyResult{Workspace: "production", Success: true}
+	Ok(t, m.Send(logging.NewNoopLogger(t), result))
+	client.VerifyWasCalled(Never()).PostMessage(AnyString(), matchers.AnyWebhooksSlackMessage())
+
+	result.Success = false
+	Ok(t, m.Send(logging.NewNoopLogger(t), result))
+	client.VerifyWasCalledOnce().PostMessage(AnyString(), matchers.AnyWebhooksSlackMessage())
+}
+
+func TestApplyResult_Failed(t *testing.T) {
+	Equals(t, true, webhooks.ApplyResult{}.Failed())
+	Equals(t, false, webhooks.ApplyResult{Success: true}.Failed())
+	Equals(t, true, webhooks.ApplyResult{Event: webhooks.PlanFinishedEvent, Error: "error"}.Failed())
+	Equals(t, false, webhooks.ApplyResult{Event: webhooks.PlanStartedEvent}.Failed())
+	Equals(t, false, webhooks.ApplyResult{Event: webhooks.LockReleasedEvent}.Failed())
+}
+
+func TestNewWebhooksManager_LifecycleEventNotHTTP(t *testing.T) {
+	t.Log("When given a lifecycle event in a slack config, an error is returned")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	configs := validConfigs()
+	configs[0].Event = webhooks.LockAcquiredEvent
+	_, err := webhooks.NewMultiWebhookSender(configs, client)
+	ErrEquals(t, "\"event: lock-acquired\" is only supported by webhooks of kind [\"http\" \"sns\" \"eventbridge\"]", err)
+}
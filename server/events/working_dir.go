@@ -15,9 +15,11 @@ package events
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -28,6 +30,17 @@ import (
 
 const workingDirPrefix = "repos"
 
+// previousClonesPrefix is the dir, relative to the data dir, where the clones
+// of the previous head commits of pull requests are kept.
+const previousClonesPrefix = "previous-repos"
+
+// The files and dirs of a previous clone.
+const (
+	previousHeadFile = "HEAD"
+	previousTreeFile = "tree"
+	previousPlansDir = "plans"
+)
+
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_working_dir.go WorkingDir
 //go:generate pegomock generate -m --use-experimental-model-gen --package events WorkingDir
 
@@ -42,10 +55,50 @@ type WorkingDir interface {
 	// If workspace does not exist on disk, error will be of type os.IsNotExist.
 	GetWorkingDir(r models.Repo, p models.PullRequest, workspace string) (string, error)
 	HasDiverged(log logging.SimpleLogging, cloneDir string) bool
+	// BaseAdvanced returns true if the base branch of p has new commits since
+	// it was merged into the clone in cloneDir, or with the branch checkout
+	// strategy, since the clone was last updated.
+	BaseAdvanced(log logging.SimpleLogging, headRepo models.Repo, p models.PullRequest, cloneDir string) (bool, error)
 	GetPullDir(r models.Repo, p models.PullRequest) (string, error)
 	// Delete deletes the workspace for this repo and pull.
 	Delete(r models.Repo, p models.PullRequest) error
 	DeleteForWorkspace(r models.Repo, p models.PullRequest, workspace string) error
+	// CheckoutDirs checks out dirs, relative to the root of the repo, in the
+	// workspace for this pull if it was cloned with sparse checkout.
+	CheckoutDirs(log logging.SimpleLogging, p models.PullRequest, workspace string, dirs []string) error
+	// GetPreviousClone returns the clone of the pull's previous head commit
+	// that Clone kept when it updated workspace to the current one. It
+	// returns nil if there's none.
+	GetPreviousClone(log logging.SimpleLogging, p models.PullRequest, workspace string) (*PreviousClone, error)
+	// DeletePreviousClone deletes the previous clone of workspace, if any.
+	DeletePreviousClone(p models.PullRequest, workspace string) error
+	// Revert commits the revert of the changes that commit made to dirs,
+	// relative to the root of the repo, in the workspace for this pull and
+	// returns the sha of the revert commit.
+	Revert(log logging.SimpleLogging, p models.PullRequest, workspace string, commit string, dirs []string, message string) (string, error)
+	// PushBranch pushes commit from the workspace for this pull to branch of
+	// the base repo, replacing the branch if it exists.
+	PushBranch(log logging.SimpleLogging, p models.PullRequest, workspace string, commit string, branch string) error
+	// PushCommit commits the uncommitted changes to dirs, relative to the
+	// root of the repo, in the workspace for this pull on top of the pull's
+	// head commit and pushes it to the pull's head branch. It returns the
+	// sha of the commit.
+	PushCommit(log logging.SimpleLogging, headRepo models.Repo, p models.PullRequest, workspace string, dirs []string, message string) (string, error)
+	// RemoteBranchSHA returns the sha of the commit that branch of repo
+	// points to.
+	RemoteBranchSHA(log logging.SimpleLogging, repo models.Repo, branch string) (string, error)
+}
+
+// PreviousClone is the clone of a pull request at a previous head commit.
+type PreviousClone struct {
+	// Dir is the absolute path to the dir with the plans of the clone, at
+	// their paths relative to the root of the repo.
+	Dir string
+	// HeadCommit is the head commit of the pull request it was cloned at.
+	HeadCommit string
+	// ChangedFiles are the files, relative to the root of the repo, that
+	// differ between it and the current clone.
+	ChangedFiles []string
 }
 
 // FileWorkspace implements WorkingDir with the file system.
@@ -56,6 +109,21 @@ type FileWorkspace struct {
 	// If this is false, then we will check out the head branch from the pull
 	// request.
 	CheckoutMerge bool
+	// CheckoutDepth is how many commits of history are fetched. If it's 0,
+	// the whole history is fetched when merging and only the head commit
+	// otherwise. If the branches being merged have no common ancestor within
+	// CheckoutDepth commits, the whole history is fetched.
+	CheckoutDepth int
+	// SparseCheckout is true if we should only check out the files at the
+	// root of the repo when cloning it and check out the dirs of the projects
+	// when running commands for them with CheckoutDirs. File contents are
+	// fetched when they're checked out.
+	SparseCheckout bool
+	// KeepPreviousClone is true if Clone should keep the clone of the
+	// previous head commit of a pull request when it updates it to a new
+	// one, so the plans of the projects that didn't change can be kept. See
+	// GetPreviousClone.
+	KeepPreviousClone bool
 	// TestingOverrideHeadCloneURL can be used during testing to override the
 	// URL of the head repo to be cloned. If it's empty then we clone normally.
 	TestingOverrideHeadCloneURL string
@@ -105,11 +173,23 @@ func (w *FileWorkspace) Clone(
 		// commit, only a 12 character prefix.
 		if strings.HasPrefix(currCommit, p.HeadCommit) {
 			log.Debug("repo is at correct commit %q so will not re-clone", p.HeadCommit)
+			w.fetchBase(log, cloneDir, p, headRepo)
 			return cloneDir, w.warnDiverged(log, p, headRepo, cloneDir), nil
 		}
 
 		log.Debug("repo was already cloned but is not at correct commit, wanted %q got %q", p.HeadCommit, currCommit)
-		// We'll fall through to re-clone.
+		if w.KeepPreviousClone {
+			if err := w.keepPreviousClone(cloneDir, p, workspace, currCommit); err != nil {
+				log.Warn("unable to keep clone of previous commit %q: %s", currCommit, err)
+			}
+		}
+		// Fetching only the new commits is much faster than cloning large
+		// repos again.
+		err = w.updateClone(log, cloneDir, headRepo, p)
+		if err == nil {
+			return cloneDir, false, nil
+		}
+		log.Warn("will re-clone repo, could not update it to commit %q: %s", p.HeadCommit, err)
 	}
 
 	// Otherwise we clone the repo.
@@ -188,6 +268,54 @@ func (w *FileWorkspace) HasDiverged(log logging.SimpleLogging, cloneDir string)
 	return hasDiverged
 }
 
+// BaseAdvanced returns true if the base branch of p has new commits since it
+// was merged into the clone in cloneDir, ex. when its projects were planned.
+// With the branch checkout strategy, the base branch isn't merged so it's
+// compared to the base branch fetched when the pull request was last cloned.
+// Unlike HasDiverged, the base branch's head is fetched from the base repo
+// rather than from the remote refs of the clone, which may be outdated.
+func (w *FileWorkspace) BaseAdvanced(log logging.SimpleLogging, headRepo models.Repo, p models.PullRequest, cloneDir string) (bool, error) {
+	var merged string
+	var err error
+	if w.CheckoutMerge {
+		// The first parent of the merge commit is the base branch's head
+		// that was merged, see mergeHead.
+		if merged, err = w.runGitCmd(log, cloneDir, p, headRepo, "git", "rev-parse", "HEAD^1"); err != nil {
+			return false, err
+		}
+	} else {
+		// See fetchBase.
+		if merged, err = w.runGitCmd(log, cloneDir, p, headRepo, "git", "rev-parse", "--verify", "-q", "refs/remotes/origin/"+p.BaseBranch); err != nil {
+			return false, fmt.Errorf("base branch %q wasn't fetched when the pull request was planned", p.BaseBranch)
+		}
+	}
+	_, baseCloneURL := w.cloneURLs(headRepo, p)
+	remote, err := w.runGitCmd(log, cloneDir, p, headRepo, "git", "ls-remote", baseCloneURL, "refs/heads/"+p.BaseBranch)
+	if err != nil {
+		return false, err
+	}
+	fields := strings.Fields(remote)
+	if len(fields) == 0 {
+		return false, fmt.Errorf("base branch %q not found", p.BaseBranch)
+	}
+	return fields[0] != strings.TrimSpace(merged), nil
+}
+
+// RemoteBranchSHA returns the sha of the commit that branch of repo points to.
+func (w *FileWorkspace) RemoteBranchSHA(log logging.SimpleLogging, repo models.Repo, branch string) (string, error) {
+	p := models.PullRequest{BaseRepo: repo}
+	_, cloneURL := w.cloneURLs(repo, p)
+	remote, err := w.runGitCmd(log, "", p, repo, "git", "ls-remote", cloneURL, "refs/heads/"+branch)
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(remote)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("branch %q not found", branch)
+	}
+	return fields[0], nil
+}
+
 func (w *FileWorkspace) forceClone(log logging.SimpleLogging,
 	cloneDir string,
 	headRepo models.Repo,
@@ -204,7 +332,253 @@ func (w *FileWorkspace) forceClone(log logging.SimpleLogging,
 		return errors.Wrap(err, "creating new workspace")
 	}
 
-	// During testing, we mock some of this out.
+	headCloneURL, baseCloneURL := w.cloneURLs(headRepo, p)
+	if !w.CheckoutMerge {
+		// Only the pull request's commits are fetched rather than cloning
+		// its branch. They're fetched from the base repo if it has a ref for
+		// the pull request, which also works for pull requests from forks.
+		originURL := headCloneURL
+		if pullRef(p) != "" {
+			originURL = baseCloneURL
+		}
+		if err := w.initRepo(log, cloneDir, p, headRepo, originURL, headCloneURL); err != nil {
+			return err
+		}
+		if err := w.checkoutHead(log, cloneDir, p, headRepo); err != nil {
+			return err
+		}
+		w.fetchBase(log, cloneDir, p, headRepo)
+		return nil
+	}
+
+	cloneArgs := []string{"git", "clone", "--single-branch"}
+	if w.SparseCheckout {
+		// Blobs outside of the sparse checkout are only fetched if they're
+		// needed, ex. to merge changes to them.
+		cloneArgs = append(cloneArgs, "--filter=blob:none", "--sparse")
+	}
+	// NOTE: If we do a shallow clone when we're merging we'll get merge
+	// conflicts if our clone doesn't have the commits that the branch we're
+	// merging branched off at so we fetch the whole history if it doesn't.
+	// See https://groups.google.com/forum/#!topic/git-users/v3MkuuiDJ98.
+	cloneArgs = append(cloneArgs, "--branch", p.BaseBranch)
+	cloneArgs = append(cloneArgs, w.depthArgs()...)
+	cmds := [][]string{
+		append(cloneArgs, baseCloneURL, cloneDir),
+		{
+			"git", "remote", "add", "head", headCloneURL,
+		},
+	}
+	for _, args := range cmds {
+		if _, err := w.runGitCmd(log, cloneDir, p, headRepo, args...); err != nil {
+			return err
+		}
+	}
+	return w.mergeHead(log, cloneDir, p, headRepo)
+}
+
+// initRepo creates a repo in cloneDir without any commits, whose origin is
+// originURL and whose head remote is headCloneURL. With sparse checkout,
+// it's set up like git clone --sparse --filter=blob:none would.
+func (w *FileWorkspace) initRepo(log logging.SimpleLogging, cloneDir string, p models.PullRequest, headRepo models.Repo, originURL string, headCloneURL string) error {
+	cmds := [][]string{
+		{"git", "init", "-q"},
+		{"git", "remote", "add", "origin", originURL},
+		{"git", "remote", "add", "head", headCloneURL},
+	}
+	if w.SparseCheckout {
+		cmds = append(cmds,
+			[]string{"git", "config", "remote.origin.promisor", "true"},
+			[]string{"git", "config", "remote.origin.partialclonefilter", "blob:none"},
+			[]string{"git", "sparse-checkout", "init", "--cone"},
+		)
+	}
+	for _, args := range cmds {
+		if _, err := w.runGitCmd(log, cloneDir, p, headRepo, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateClone updates the existing clone in cloneDir to the pull request's
+// head commit by only fetching the new commits. Files that aren't committed
+// are deleted, except for the .terraform dirs so providers and modules
+// don't need to be downloaded again.
+func (w *FileWorkspace) updateClone(log logging.SimpleLogging, cloneDir string, headRepo models.Repo, p models.PullRequest) error {
+	headCloneURL, baseCloneURL := w.cloneURLs(headRepo, p)
+	// Clones made before pull requests were fetched by ref don't have a head
+	// remote.
+	if _, err := w.runGitCmd(log, cloneDir, p, headRepo, "git", "remote", "set-url", "head", headCloneURL); err != nil {
+		return err
+	}
+	originURL := headCloneURL
+	if w.CheckoutMerge || pullRef(p) != "" {
+		originURL = baseCloneURL
+	}
+	if _, err := w.runGitCmd(log, cloneDir, p, headRepo, "git", "remote", "set-url", "origin", originURL); err != nil {
+		return err
+	}
+
+	if !w.CheckoutMerge {
+		if err := w.checkoutHead(log, cloneDir, p, headRepo); err != nil {
+			return err
+		}
+		w.fetchBase(log, cloneDir, p, headRepo)
+		return w.clean(log, cloneDir, p, headRepo)
+	}
+
+	baseRef := fmt.Sprintf("refs/remotes/origin/%s", p.BaseBranch)
+	fetchArgs := append([]string{"git", "fetch", "-q"}, w.depthArgs()...)
+	cmds := [][]string{
+		append(fetchArgs, "origin", fmt.Sprintf("+refs/heads/%s:%s", p.BaseBranch, baseRef)),
+		{"git", "checkout", "-q", "-f", "-B", p.BaseBranch, baseRef},
+	}
+	for _, args := range cmds {
+		if _, err := w.runGitCmd(log, cloneDir, p, headRepo, args...); err != nil {
+			return err
+		}
+	}
+	if err := w.clean(log, cloneDir, p, headRepo); err != nil {
+		return err
+	}
+	return w.mergeHead(log, cloneDir, p, headRepo)
+}
+
+// clean deletes the files that aren't committed in cloneDir, ex. plans,
+// except for the .terraform dirs.
+func (w *FileWorkspace) clean(log logging.SimpleLogging, cloneDir string, p models.PullRequest, headRepo models.Repo) error {
+	_, err := w.runGitCmd(log, cloneDir, p, headRepo, "git", "clean", "-q", "-ffdx", "-e", ".terraform/")
+	return err
+}
+
+// checkoutHead fetches the pull request's head commit and checks it out on
+// its branch.
+func (w *FileWorkspace) checkoutHead(log logging.SimpleLogging, cloneDir string, p models.PullRequest, headRepo models.Repo) error {
+	depthArgs := w.depthArgs()
+	if len(depthArgs) == 0 {
+		depthArgs = []string{"--depth=1"}
+	}
+	headRemote, err := w.fetchHead(log, cloneDir, p, headRepo, depthArgs)
+	if err != nil {
+		return err
+	}
+	log.Debug("fetched head commit from %s", headRemote)
+	_, err = w.runGitCmd(log, cloneDir, p, headRepo, "git", "checkout", "-q", "-f", "-B", p.HeadBranch, "FETCH_HEAD")
+	return err
+}
+
+// fetchBase fetches the head commit of the pull request's base branch to
+// refs/remotes/origin/<base branch> with the branch checkout strategy, which
+// otherwise only fetches the pull request's commits, so the base branch is
+// known as it was when the pull request was planned, ex. to read its
+// CODEOWNERS file. With the merge checkout strategy it's already fetched to
+// be merged. Failures are only logged since plans don't need it.
+func (w *FileWorkspace) fetchBase(log logging.SimpleLogging, cloneDir string, p models.PullRequest, headRepo models.Repo) {
+	if w.CheckoutMerge || p.BaseBranch == "" {
+		return
+	}
+	_, baseCloneURL := w.cloneURLs(headRepo, p)
+	args := []string{"git", "fetch", "-q", "--depth=1"}
+	if w.SparseCheckout {
+		// Blobs are fetched from origin when they're read.
+		args = append(args, "--filter=blob:none")
+	}
+	args = append(args, baseCloneURL, fmt.Sprintf("+refs/heads/%s:refs/remotes/origin/%s", p.BaseBranch, p.BaseBranch))
+	if _, err := w.runGitCmd(log, cloneDir, p, headRepo, args...); err != nil {
+		log.Warn("unable to fetch base branch %q: %s", p.BaseBranch, err)
+	}
+}
+
+// mergeHead fetches the pull request's head commit and merges it into the
+// base branch checked out in cloneDir.
+func (w *FileWorkspace) mergeHead(log logging.SimpleLogging, cloneDir string, p models.PullRequest, headRepo models.Repo) error {
+	headRemote, err := w.fetchHead(log, cloneDir, p, headRepo, w.depthArgs())
+	if err != nil {
+		return err
+	}
+	if w.CheckoutDepth > 0 {
+		if _, err := w.runGitCmd(log, cloneDir, p, headRepo, "git", "merge-base", "HEAD", "FETCH_HEAD"); err != nil {
+			log.Info("branches %q and %q have no common ancestor within the last %d commits, fetching their whole history", p.BaseBranch, p.HeadBranch, w.CheckoutDepth)
+			if err := w.unshallow(log, cloneDir, p, headRepo, headRemote); err != nil {
+				return err
+			}
+		}
+	}
+	// We use --no-ff because we always want there to be a merge commit.
+	// This way, our branch will look the same regardless if the merge
+	// could be fast forwarded. This is useful later when we run
+	// git rev-parse HEAD^2 to get the head commit because it will
+	// always succeed whereas without --no-ff, if the merge was fast
+	// forwarded then git rev-parse HEAD^2 would fail.
+	_, err = w.runGitCmd(log, cloneDir, p, headRepo, "git", "merge", "-q", "--no-ff", "-m", "atlantis-merge", "FETCH_HEAD")
+	return err
+}
+
+// fetchHead fetches the pull request's head commit into FETCH_HEAD and
+// returns the remote it was fetched from. If the VCS host has a ref for the
+// pull request, it's fetched from the base repo since it only has the pull
+// request's commits. Otherwise, or if the ref isn't at the head commit yet,
+// the head branch is fetched.
+func (w *FileWorkspace) fetchHead(log logging.SimpleLogging, cloneDir string, p models.PullRequest, headRepo models.Repo, depthArgs []string) (string, error) {
+	fetchArgs := append([]string{"git", "fetch", "-q"}, depthArgs...)
+	if ref := pullRef(p); ref != "" {
+		_, err := w.runGitCmd(log, cloneDir, p, headRepo, append(fetchArgs, "origin", ref)...)
+		if err == nil {
+			fetched, err := w.runGitCmd(log, cloneDir, p, headRepo, "git", "rev-parse", "FETCH_HEAD")
+			if err == nil && strings.HasPrefix(strings.TrimSpace(fetched), p.HeadCommit) {
+				return "origin", nil
+			}
+		}
+		log.Debug("could not fetch commit %q from %q, fetching branch %q instead", p.HeadCommit, ref, p.HeadBranch)
+	}
+	_, err := w.runGitCmd(log, cloneDir, p, headRepo, append(fetchArgs, "head", headBranchRef(p))...)
+	return "head", err
+}
+
+// unshallow fetches the whole history of the base and head branches cloned
+// in cloneDir. The head branch is fetched last, from headRemote, so
+// FETCH_HEAD still points to it.
+func (w *FileWorkspace) unshallow(log logging.SimpleLogging, cloneDir string, p models.PullRequest, headRepo models.Repo, headRemote string) error {
+	for _, remote := range []string{"origin", headRemote} {
+		// Fetching the history of one branch can make the repo complete, in
+		// which case git refuses to unshallow it.
+		out, err := w.runGitCmd(log, cloneDir, p, headRepo, "git", "rev-parse", "--is-shallow-repository")
+		if err != nil {
+			return err
+		}
+		args := []string{"git", "fetch", remote}
+		if strings.TrimSpace(out) == "true" {
+			args = []string{"git", "fetch", "--unshallow", remote}
+		}
+		if remote == headRemote {
+			ref := headBranchRef(p)
+			if remote == "origin" {
+				ref = pullRef(p)
+			}
+			args = append(args, ref)
+		} else {
+			args = append(args, fmt.Sprintf("+refs/heads/%s:refs/remotes/origin/%s", p.BaseBranch, p.BaseBranch))
+		}
+		if _, err := w.runGitCmd(log, cloneDir, p, headRepo, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// depthArgs returns the git fetch args that limit the history fetched to
+// CheckoutDepth commits.
+func (w *FileWorkspace) depthArgs() []string {
+	if w.CheckoutDepth > 0 {
+		return []string{fmt.Sprintf("--depth=%d", w.CheckoutDepth)}
+	}
+	return nil
+}
+
+// cloneURLs returns the URLs of the head and base repos. During testing, we
+// mock some of this out.
+func (w *FileWorkspace) cloneURLs(headRepo models.Repo, p models.PullRequest) (string, string) {
 	headCloneURL := headRepo.CloneURL
 	if w.TestingOverrideHeadCloneURL != "" {
 		headCloneURL = w.TestingOverrideHeadCloneURL
@@ -213,61 +587,341 @@ func (w *FileWorkspace) forceClone(log logging.SimpleLogging,
 	if w.TestingOverrideBaseCloneURL != "" {
 		baseCloneURL = w.TestingOverrideBaseCloneURL
 	}
+	return headCloneURL, baseCloneURL
+}
+
+// pullRef returns the ref of the base repo that points to the head commit of
+// the pull request or "" if the VCS host doesn't have one.
+func pullRef(p models.PullRequest) string {
+	switch p.BaseRepo.VCSHost.Type {
+	case models.Github:
+		return fmt.Sprintf("refs/pull/%d/head", p.Num)
+	case models.Gitlab:
+		return fmt.Sprintf("refs/merge-requests/%d/head", p.Num)
+	case models.BitbucketServer:
+		return fmt.Sprintf("refs/pull-requests/%d/from", p.Num)
+	}
+	return ""
+}
+
+// headBranchRef returns the refspec that fetches the pull request's branch.
+func headBranchRef(p models.PullRequest) string {
+	return fmt.Sprintf("+refs/heads/%s:", p.HeadBranch)
+}
+
+// runGitCmd runs args in cloneDir and returns their output. Credentials are
+// removed from the output and errors.
+func (w *FileWorkspace) runGitCmd(log logging.SimpleLogging, cloneDir string, p models.PullRequest, headRepo models.Repo, args ...string) (string, error) {
+	cmd := exec.Command(args[0], args[1:]...) // nolint: gosec
+	cmd.Dir = cloneDir
+	// The git merge command requires these env vars are set.
+	cmd.Env = append(os.Environ(), []string{
+		"EMAIL=atlantis@runatlantis.io",
+		"GIT_AUTHOR_NAME=atlantis",
+		"GIT_COMMITTER_NAME=atlantis",
+	}...)
+
+	cmdStr := w.sanitizeGitCredentials(strings.Join(cmd.Args, " "), p.BaseRepo, headRepo)
+	output, err := cmd.CombinedOutput()
+	sanitizedOutput := w.sanitizeGitCredentials(string(output), p.BaseRepo, headRepo)
+	if err != nil {
+		sanitizedErrMsg := w.sanitizeGitCredentials(err.Error(), p.BaseRepo, headRepo)
+		return "", fmt.Errorf("running %s: %s: %s", cmdStr, sanitizedOutput, sanitizedErrMsg)
+	}
+	log.Debug("ran: %s. Output: %s", cmdStr, strings.TrimSuffix(sanitizedOutput, "\n"))
+	return sanitizedOutput, nil
+}
+
+// CheckoutDirs checks out dirs, relative to the root of the repo, in the
+// workspace for this pull if it was cloned with sparse checkout. Dirs that
+// are already checked out are skipped.
+func (w *FileWorkspace) CheckoutDirs(log logging.SimpleLogging, p models.PullRequest, workspace string, dirs []string) error {
+	if !w.SparseCheckout {
+		return nil
+	}
+	cloneDir := w.cloneDir(p.BaseRepo, p, workspace)
+	// The repo may have been cloned before sparse checkout was enabled.
+	out, err := w.runGitCmd(log, cloneDir, p, p.BaseRepo, "git", "config", "--bool", "core.sparseCheckout")
+	if err != nil || strings.TrimSpace(out) != "true" {
+		return nil
+	}
+
+	args := []string{"git", "sparse-checkout", "add", "--"}
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		dir = filepath.ToSlash(filepath.Clean(dir))
+		if dir == "." {
+			// The whole repo is needed.
+			_, err := w.runGitCmd(log, cloneDir, p, p.BaseRepo, "git", "sparse-checkout", "disable")
+			return err
+		}
+		if seen[dir] || strings.HasPrefix(dir, "../") {
+			continue
+		}
+		seen[dir] = true
+		args = append(args, dir)
+	}
+	if len(seen) == 0 {
+		return nil
+	}
+	log.Debug("checking out dirs %v in %q", args[4:], cloneDir)
+	_, err = w.runGitCmd(log, cloneDir, p, p.BaseRepo, args...)
+	return err
+}
+
+// Revert commits the revert of the changes that commit made to dirs in the
+// workspace for this pull, which must be cloned, and returns the sha of the
+// revert commit. commit and its first parent are fetched if the clone
+// doesn't have them, ex. because it's shallow. If the changes can't be
+// reverted cleanly, ex. because dirs were changed since, the clone is left
+// as it was.
+// If CheckoutMerge is set, the revert commit is merged into the branch
+// checked out like Clone merges head commits, so Clone reuses the clone for
+// the revert commit.
+func (w *FileWorkspace) Revert(log logging.SimpleLogging, p models.PullRequest, workspace string, commit string, dirs []string, message string) (string, error) {
+	cloneDir := w.cloneDir(p.BaseRepo, p, workspace)
+	if err := w.CheckoutDirs(log, p, workspace, dirs); err != nil {
+		return "", err
+	}
+	parent := commit + "^1"
+	if _, err := w.runGitCmd(log, cloneDir, p, p.BaseRepo, "git", "cat-file", "-e", parent+"^{commit}"); err != nil {
+		log.Debug("fetching commit %q to revert it", commit)
+		if _, err := w.runGitCmd(log, cloneDir, p, p.BaseRepo, "git", "fetch", "-q", "--depth=2", "origin", commit); err != nil {
+			return "", err
+		}
+	}
+	base, err := w.runGitCmd(log, cloneDir, p, p.BaseRepo, "git", "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	base = strings.TrimSpace(base)
+
+	patch := filepath.Join(cloneDir, ".git", "atlantis-revert.patch")
+	defer os.Remove(patch) // nolint: errcheck
+	diffArgs := append([]string{"git", "diff", "--binary", "--output=" + patch, commit, parent, "--"}, dirs...)
+	if _, err := w.runGitCmd(log, cloneDir, p, p.BaseRepo, diffArgs...); err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(patch); err != nil || info.Size() == 0 {
+		return "", fmt.Errorf("commit %s didn't change %s", commit, strings.Join(dirs, ", "))
+	}
+	if _, err := w.runGitCmd(log, cloneDir, p, p.BaseRepo, "git", "apply", "--3way", "--index", patch); err != nil {
+		if _, resetErr := w.runGitCmd(log, cloneDir, p, p.BaseRepo, "git", "reset", "-q", "--hard", base); resetErr != nil {
+			log.Warn("unable to reset clone after failed revert: %s", resetErr)
+		}
+		return "", errors.Wrapf(err, "reverting commit %s", commit)
+	}
+	if _, err := w.runGitCmd(log, cloneDir, p, p.BaseRepo, "git", "commit", "-q", "-m", message); err != nil {
+		return "", err
+	}
+	revert, err := w.runGitCmd(log, cloneDir, p, p.BaseRepo, "git", "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	revert = strings.TrimSpace(revert)
 
-	var cmds [][]string
 	if w.CheckoutMerge {
-		// NOTE: We can't do a shallow clone when we're merging because we'll
-		// get merge conflicts if our clone doesn't have the commits that the
-		// branch we're merging branched off at.
-		// See https://groups.google.com/forum/#!topic/git-users/v3MkuuiDJ98.
-		cmds = [][]string{
-			{
-				"git", "clone", "--branch", p.BaseBranch, "--single-branch", baseCloneURL, cloneDir,
-			},
-			{
-				"git", "remote", "add", "head", headCloneURL,
-			},
-			{
-				"git", "fetch", "head", fmt.Sprintf("+refs/heads/%s:", p.HeadBranch),
-			},
-			// We use --no-ff because we always want there to be a merge commit.
-			// This way, our branch will look the same regardless if the merge
-			// could be fast forwarded. This is useful later when we run
-			// git rev-parse HEAD^2 to get the head commit because it will
-			// always succeed whereas without --no-ff, if the merge was fast
-			// forwarded then git rev-parse HEAD^2 would fail.
-			{
-				"git", "merge", "-q", "--no-ff", "-m", "atlantis-merge", "FETCH_HEAD",
-			},
+		cmds := [][]string{
+			{"git", "reset", "-q", "--hard", base},
+			{"git", "merge", "-q", "--no-ff", "-m", "atlantis-merge", revert},
 		}
-	} else {
-		cmds = [][]string{
-			{
-				"git", "clone", "--branch", p.HeadBranch, "--depth=1", "--single-branch", headCloneURL, cloneDir,
-			},
+		for _, args := range cmds {
+			if _, err := w.runGitCmd(log, cloneDir, p, p.BaseRepo, args...); err != nil {
+				return "", err
+			}
 		}
 	}
+	return revert, nil
+}
+
+// PushBranch pushes commit from the workspace for this pull to branch of the
+// base repo, replacing the branch if it exists.
+func (w *FileWorkspace) PushBranch(log logging.SimpleLogging, p models.PullRequest, workspace string, commit string, branch string) error {
+	cloneDir := w.cloneDir(p.BaseRepo, p, workspace)
+	_, err := w.runGitCmd(log, cloneDir, p, p.BaseRepo, "git", "push", "-q", "-f", "origin", fmt.Sprintf("%s:refs/heads/%s", commit, branch))
+	return err
+}
+
+// PushCommit commits the uncommitted changes to dirs in the workspace for
+// this pull on top of the pull's head commit and pushes it to the pull's head
+// branch, which fails if the branch has new commits. The changes are
+// committed in a separate worktree since the clone may have the head commit
+// merged into the base branch. Either way, the changes are discarded from
+// the clone so it's updated to the pushed commit like any other.
+func (w *FileWorkspace) PushCommit(log logging.SimpleLogging, headRepo models.Repo, p models.PullRequest, workspace string, dirs []string, message string) (string, error) {
+	cloneDir := w.cloneDir(p.BaseRepo, p, workspace)
+	patch := filepath.Join(cloneDir, ".git", "atlantis-push.patch")
+	defer os.Remove(patch) // nolint: errcheck
+	diffArgs := append([]string{"git", "diff", "--binary", "--output=" + patch, "--"}, dirs...)
+	_, err := w.runGitCmd(log, cloneDir, p, headRepo, diffArgs...)
+	checkoutArgs := append([]string{"git", "checkout", "-q", "--"}, dirs...)
+	if _, checkoutErr := w.runGitCmd(log, cloneDir, p, headRepo, checkoutArgs...); checkoutErr != nil {
+		log.Warn("unable to discard changes from clone: %s", checkoutErr)
+	}
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(patch); err != nil || info.Size() == 0 {
+		return "", fmt.Errorf("nothing changed in %s", strings.Join(dirs, ", "))
+	}
 
+	worktree := filepath.Join(cloneDir, ".git", "atlantis-push")
+	if err := os.RemoveAll(worktree); err != nil {
+		return "", err
+	}
+	if _, err := w.runGitCmd(log, cloneDir, p, headRepo, "git", "worktree", "add", "-q", "--detach", worktree, p.HeadCommit); err != nil {
+		return "", err
+	}
+	defer func() {
+		if _, err := w.runGitCmd(log, cloneDir, p, headRepo, "git", "worktree", "remove", "--force", worktree); err != nil {
+			log.Warn("unable to remove worktree: %s", err)
+		}
+	}()
+	cmds := [][]string{
+		{"git", "apply", "--index", patch},
+		{"git", "commit", "-q", "-m", message},
+	}
 	for _, args := range cmds {
-		cmd := exec.Command(args[0], args[1:]...) // nolint: gosec
-		cmd.Dir = cloneDir
-		// The git merge command requires these env vars are set.
-		cmd.Env = append(os.Environ(), []string{
-			"EMAIL=atlantis@runatlantis.io",
-			"GIT_AUTHOR_NAME=atlantis",
-			"GIT_COMMITTER_NAME=atlantis",
-		}...)
-
-		cmdStr := w.sanitizeGitCredentials(strings.Join(cmd.Args, " "), p.BaseRepo, headRepo)
-		output, err := cmd.CombinedOutput()
-		sanitizedOutput := w.sanitizeGitCredentials(string(output), p.BaseRepo, headRepo)
+		if _, err := w.runGitCmd(log, worktree, p, headRepo, args...); err != nil {
+			return "", err
+		}
+	}
+	commit, err := w.runGitCmd(log, worktree, p, headRepo, "git", "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	commit = strings.TrimSpace(commit)
+	if _, err := w.runGitCmd(log, worktree, p, headRepo, "git", "push", "-q", "head", fmt.Sprintf("%s:refs/heads/%s", commit, p.HeadBranch)); err != nil {
+		return "", err
+	}
+	return commit, nil
+}
+
+// keepPreviousClone keeps what's needed of the clone in cloneDir at
+// headCommit before it's updated to a new commit, replacing the previous
+// clone of workspace. Only the head commit, the files committed and the
+// plans are kept since the clone itself is updated in place.
+func (w *FileWorkspace) keepPreviousClone(cloneDir string, p models.PullRequest, workspace string, headCommit string) error {
+	prevDir := w.previousCloneDir(p.BaseRepo, p, workspace)
+	if err := os.RemoveAll(prevDir); err != nil {
+		return err
+	}
+	tree, err := w.lsTree(cloneDir)
+	if err != nil {
+		return err
+	}
+	plansDir := filepath.Join(prevDir, previousPlansDir)
+	if err := os.MkdirAll(plansDir, 0700); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(prevDir, previousHeadFile), []byte(headCommit), 0600); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(prevDir, previousTreeFile), tree, 0600); err != nil {
+		return err
+	}
+	return filepath.Walk(cloneDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			sanitizedErrMsg := w.sanitizeGitCredentials(err.Error(), p.BaseRepo, headRepo)
-			return fmt.Errorf("running %s: %s: %s", cmdStr, sanitizedOutput, sanitizedErrMsg)
+			return err
+		}
+		if info.IsDir() {
+			switch info.Name() {
+			case ".git", ".terraform", ".terragrunt-cache":
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(info.Name(), ".tfplan") {
+			return nil
+		}
+		relPath, err := filepath.Rel(cloneDir, path)
+		if err != nil {
+			return err
+		}
+		plan, err := ioutil.ReadFile(path) // nolint: gosec
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(plansDir, relPath)
+		if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(dst, plan, 0600)
+	})
+}
+
+// GetPreviousClone returns the clone of the pull's previous head commit that
+// Clone kept when it updated workspace to the current one, or nil if
+// there's none. The changed files are found by comparing the files
+// committed in both clones so neither needs the other's history.
+func (w *FileWorkspace) GetPreviousClone(log logging.SimpleLogging, p models.PullRequest, workspace string) (*PreviousClone, error) {
+	prevDir := w.previousCloneDir(p.BaseRepo, p, workspace)
+	headCommit, err := ioutil.ReadFile(filepath.Join(prevDir, previousHeadFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	prevTree, err := ioutil.ReadFile(filepath.Join(prevDir, previousTreeFile))
+	if err != nil {
+		return nil, err
+	}
+	currTree, err := w.lsTree(w.cloneDir(p.BaseRepo, p, workspace))
+	if err != nil {
+		return nil, err
+	}
+	prevFiles := parseTree(prevTree)
+	currFiles := parseTree(currTree)
+	var changed []string
+	for file, obj := range currFiles {
+		if prevFiles[file] != obj {
+			changed = append(changed, file)
 		}
-		log.Debug("ran: %s. Output: %s", cmdStr, strings.TrimSuffix(sanitizedOutput, "\n"))
 	}
-	return nil
+	for file := range prevFiles {
+		if _, ok := currFiles[file]; !ok {
+			changed = append(changed, file)
+		}
+	}
+	sort.Strings(changed)
+	return &PreviousClone{
+		Dir:          filepath.Join(prevDir, previousPlansDir),
+		HeadCommit:   strings.TrimSpace(string(headCommit)),
+		ChangedFiles: changed,
+	}, nil
+}
+
+// lsTree returns the output of git ls-tree for the files committed at HEAD
+// in cloneDir.
+func (w *FileWorkspace) lsTree(cloneDir string) ([]byte, error) {
+	cmd := exec.Command("git", "ls-tree", "-r", "-z", "--full-tree", "HEAD") // #nosec
+	cmd.Dir = cloneDir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "listing files in %q", cloneDir)
+	}
+	return out, nil
+}
+
+// parseTree returns the mode and object of each file in the output of
+// lsTree by path.
+func parseTree(tree []byte) map[string]string {
+	files := make(map[string]string)
+	for _, entry := range strings.Split(string(tree), "\x00") {
+		// Entries are "<mode> <type> <object>\t<path>".
+		tab := strings.Index(entry, "\t")
+		if tab == -1 {
+			continue
+		}
+		files[entry[tab+1:]] = entry[:tab]
+	}
+	return files
+}
+
+// DeletePreviousClone deletes the previous clone of workspace, if any.
+func (w *FileWorkspace) DeletePreviousClone(p models.PullRequest, workspace string) error {
+	return os.RemoveAll(w.previousCloneDir(p.BaseRepo, p, workspace))
 }
 
 // GetWorkingDir returns the path to the workspace for this repo and pull.
@@ -291,11 +945,17 @@ func (w *FileWorkspace) GetPullDir(r models.Repo, p models.PullRequest) (string,
 
 // Delete deletes the workspace for this repo and pull.
 func (w *FileWorkspace) Delete(r models.Repo, p models.PullRequest) error {
+	if err := os.RemoveAll(filepath.Join(w.DataDir, previousClonesPrefix, r.FullName, strconv.Itoa(p.Num))); err != nil {
+		return err
+	}
 	return os.RemoveAll(w.repoPullDir(r, p))
 }
 
 // DeleteForWorkspace deletes the working dir for this workspace.
 func (w *FileWorkspace) DeleteForWorkspace(r models.Repo, p models.PullRequest, workspace string) error {
+	if err := os.RemoveAll(w.previousCloneDir(r, p, workspace)); err != nil {
+		return err
+	}
 	return os.RemoveAll(w.cloneDir(r, p, workspace))
 }
 
@@ -307,6 +967,10 @@ func (w *FileWorkspace) cloneDir(r models.Repo, p models.PullRequest, workspace
 	return filepath.Join(w.repoPullDir(r, p), workspace)
 }
 
+func (w *FileWorkspace) previousCloneDir(r models.Repo, p models.PullRequest, workspace string) string {
+	return filepath.Join(w.DataDir, previousClonesPrefix, r.FullName, strconv.Itoa(p.Num), workspace)
+}
+
 // sanitizeGitCredentials replaces any git clone urls that contain credentials
 // in s with the sanitized versions.
 func (w *FileWorkspace) sanitizeGitCredentials(s string, base models.Repo, head models.Repo) string {
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/runatlantis/atlantis/server/events"
@@ -325,6 +326,217 @@ func TestClone_RecloneWrongCommit(t *testing.T) {
 	Equals(t, expCommit, actCommit)
 }
 
+// Test that if the repo is already cloned at the wrong commit, it's updated
+// in place and only the files that aren't committed outside of .terraform
+// dirs are deleted.
+func TestClone_UpdateWrongCommit(t *testing.T) {
+	repoDir, cleanup := initRepo(t)
+	defer cleanup()
+	dataDir, cleanup2 := TempDir(t)
+	defer cleanup2()
+
+	wd := &events.FileWorkspace{
+		DataDir:                     dataDir,
+		TestingOverrideHeadCloneURL: fmt.Sprintf("file://%s", repoDir),
+	}
+	pull := models.PullRequest{
+		HeadBranch: "branch",
+		HeadCommit: strings.TrimSpace(runCmd(t, repoDir, "git", "rev-parse", "branch")),
+	}
+	cloneDir, _, err := wd.Clone(logging.NewNoopLogger(t), models.Repo{}, pull, "default")
+	Ok(t, err)
+	runCmd(t, cloneDir, "mkdir", ".terraform")
+	runCmd(t, cloneDir, "touch", ".terraform/provider", "default.tfplan")
+
+	runCmd(t, repoDir, "git", "checkout", "branch")
+	runCmd(t, repoDir, "touch", "newfile")
+	runCmd(t, repoDir, "git", "add", "newfile")
+	runCmd(t, repoDir, "git", "commit", "-m", "newfile")
+	pull.HeadCommit = strings.TrimSpace(runCmd(t, repoDir, "git", "rev-parse", "HEAD"))
+	updatedDir, hasDiverged, err := wd.Clone(logging.NewNoopLogger(t), models.Repo{}, pull, "default")
+	Ok(t, err)
+	Equals(t, false, hasDiverged)
+	Equals(t, cloneDir, updatedDir)
+
+	Equals(t, pull.HeadCommit, strings.TrimSpace(runCmd(t, cloneDir, "git", "rev-parse", "HEAD")))
+	Equals(t, "branch", strings.TrimSpace(runCmd(t, cloneDir, "git", "rev-parse", "--abbrev-ref", "HEAD")))
+	_, err = os.Stat(filepath.Join(cloneDir, ".terraform", "provider"))
+	Ok(t, err)
+	_, err = os.Stat(filepath.Join(cloneDir, "default.tfplan"))
+	Assert(t, os.IsNotExist(err), "expected plan to be deleted")
+}
+
+// Test that if we're using the merge method and the repo is already cloned at
+// the wrong commit, it's updated to the new commits of both branches.
+func TestClone_CheckoutMergeUpdateWrongCommit(t *testing.T) {
+	repoDir, cleanup := initRepo(t)
+	defer cleanup()
+	dataDir, cleanup2 := TempDir(t)
+	defer cleanup2()
+
+	overrideURL := fmt.Sprintf("file://%s", repoDir)
+	wd := &events.FileWorkspace{
+		DataDir:                     dataDir,
+		CheckoutMerge:               true,
+		CheckoutDepth:               1,
+		TestingOverrideHeadCloneURL: overrideURL,
+		TestingOverrideBaseCloneURL: overrideURL,
+	}
+	pull := models.PullRequest{
+		HeadBranch: "branch",
+		BaseBranch: "master",
+		HeadCommit: strings.TrimSpace(runCmd(t, repoDir, "git", "rev-parse", "branch")),
+	}
+	cloneDir, _, err := wd.Clone(logging.NewNoopLogger(t), models.Repo{}, pull, "default")
+	Ok(t, err)
+
+	// Advance both branches.
+	runCmd(t, repoDir, "git", "checkout", "branch")
+	runCmd(t, repoDir, "touch", "branch-file")
+	runCmd(t, repoDir, "git", "add", "branch-file")
+	runCmd(t, repoDir, "git", "commit", "-m", "branch-commit")
+	pull.HeadCommit = strings.TrimSpace(runCmd(t, repoDir, "git", "rev-parse", "HEAD"))
+	runCmd(t, repoDir, "git", "checkout", "master")
+	runCmd(t, repoDir, "touch", "master-file")
+	runCmd(t, repoDir, "git", "add", "master-file")
+	runCmd(t, repoDir, "git", "commit", "-m", "master-commit")
+	masterCommit := strings.TrimSpace(runCmd(t, repoDir, "git", "rev-parse", "HEAD"))
+
+	_, _, err = wd.Clone(logging.NewNoopLogger(t), models.Repo{}, pull, "default")
+	Ok(t, err)
+	Equals(t, masterCommit, strings.TrimSpace(runCmd(t, cloneDir, "git", "rev-parse", "HEAD~1")))
+	Equals(t, pull.HeadCommit, strings.TrimSpace(runCmd(t, cloneDir, "git", "rev-parse", "HEAD^2")))
+	_, err = os.Stat(filepath.Join(cloneDir, "master-file"))
+	Ok(t, err)
+	_, err = os.Stat(filepath.Join(cloneDir, "branch-file"))
+	Ok(t, err)
+}
+
+// Test that the head commit is fetched from the pull request's ref of the
+// base repo, so the head repo isn't needed.
+func TestClone_PullRef(t *testing.T) {
+	repoDir, cleanup := initRepo(t)
+	defer cleanup()
+	runCmd(t, repoDir, "git", "checkout", "branch")
+	runCmd(t, repoDir, "touch", "pull-file")
+	runCmd(t, repoDir, "git", "add", "pull-file")
+	runCmd(t, repoDir, "git", "commit", "-m", "pull-commit")
+	headCommit := strings.TrimSpace(runCmd(t, repoDir, "git", "rev-parse", "HEAD"))
+	runCmd(t, repoDir, "git", "update-ref", "refs/pull/1/head", headCommit)
+	runCmd(t, repoDir, "git", "reset", "-q", "--hard", "HEAD~1")
+	dataDir, cleanup2 := TempDir(t)
+	defer cleanup2()
+
+	for _, merge := range []bool{false, true} {
+		t.Run(fmt.Sprintf("merge %t", merge), func(t *testing.T) {
+			wd := &events.FileWorkspace{
+				DataDir:                     dataDir,
+				CheckoutMerge:               merge,
+				TestingOverrideHeadCloneURL: fmt.Sprintf("file://%s/missing", dataDir),
+				TestingOverrideBaseCloneURL: fmt.Sprintf("file://%s", repoDir),
+			}
+			pull := models.PullRequest{
+				Num:        1,
+				BaseRepo:   models.Repo{VCSHost: models.VCSHost{Type: models.Github}},
+				HeadBranch: "branch",
+				BaseBranch: "master",
+				HeadCommit: headCommit,
+			}
+			cloneDir, _, err := wd.Clone(logging.NewNoopLogger(t), models.Repo{}, pull, fmt.Sprintf("merge-%t", merge))
+			Ok(t, err)
+			_, err = os.Stat(filepath.Join(cloneDir, "pull-file"))
+			Ok(t, err)
+		})
+	}
+}
+
+// Test that the base branch is fetched with the branch checkout strategy and
+// updated each time the pull request is cloned.
+func TestClone_FetchesBase(t *testing.T) {
+	repoDir, cleanup := initRepo(t)
+	defer cleanup()
+	dataDir, cleanup2 := TempDir(t)
+	defer cleanup2()
+	overrideURL := fmt.Sprintf("file://%s", repoDir)
+	wd := &events.FileWorkspace{
+		DataDir:                     dataDir,
+		CheckoutMerge:               false,
+		TestingOverrideHeadCloneURL: overrideURL,
+		TestingOverrideBaseCloneURL: overrideURL,
+	}
+	pull := models.PullRequest{
+		HeadBranch: "branch",
+		BaseBranch: "master",
+		HeadCommit: strings.TrimSpace(runCmd(t, repoDir, "git", "rev-parse", "branch")),
+	}
+	cloneDir, _, err := wd.Clone(logging.NewNoopLogger(t), models.Repo{}, pull, "default")
+	Ok(t, err)
+	Equals(t, runCmd(t, repoDir, "git", "rev-parse", "master"), runCmd(t, cloneDir, "git", "rev-parse", "refs/remotes/origin/master"))
+
+	runCmd(t, repoDir, "git", "checkout", "master")
+	runCmd(t, repoDir, "touch", "master-file")
+	runCmd(t, repoDir, "git", "add", "master-file")
+	runCmd(t, repoDir, "git", "commit", "-m", "master-commit")
+	_, _, err = wd.Clone(logging.NewNoopLogger(t), models.Repo{}, pull, "default")
+	Ok(t, err)
+	Equals(t, runCmd(t, repoDir, "git", "rev-parse", "master"), runCmd(t, cloneDir, "git", "rev-parse", "refs/remotes/origin/master"))
+}
+
+// Test that the clone of the previous commit is kept when re-cloning and that
+// the files changed since are found.
+func TestClone_KeepPreviousClone(t *testing.T) {
+	repoDir, cleanup := initRepo(t)
+	defer cleanup()
+	runCmd(t, repoDir, "git", "checkout", "branch")
+	runCmd(t, repoDir, "mkdir", "a", "b")
+	runCmd(t, repoDir, "touch", "a/main.tf", "b/main.tf")
+	runCmd(t, repoDir, "git", "add", "a", "b")
+	runCmd(t, repoDir, "git", "commit", "-m", "projects")
+	prevCommit := strings.TrimSpace(runCmd(t, repoDir, "git", "rev-parse", "HEAD"))
+
+	dataDir, cleanup2 := TempDir(t)
+	defer cleanup2()
+	wd := &events.FileWorkspace{
+		DataDir:                     dataDir,
+		KeepPreviousClone:           true,
+		TestingOverrideHeadCloneURL: fmt.Sprintf("file://%s", repoDir),
+	}
+	pull := models.PullRequest{
+		HeadBranch: "branch",
+		HeadCommit: prevCommit,
+	}
+	cloneDir, _, err := wd.Clone(logging.NewNoopLogger(t), models.Repo{}, pull, "default")
+	Ok(t, err)
+	runCmd(t, cloneDir, "touch", "a/default.tfplan")
+	prev, err := wd.GetPreviousClone(logging.NewNoopLogger(t), pull, "default")
+	Ok(t, err)
+	Assert(t, prev == nil, "expected no previous clone")
+
+	// Modify a project, delete a file and add one.
+	runCmd(t, repoDir, "sh", "-c", "echo 'variable \"x\" {}' > b/main.tf")
+	runCmd(t, repoDir, "git", "rm", "-q", ".gitkeep")
+	runCmd(t, repoDir, "touch", "new-file")
+	runCmd(t, repoDir, "git", "add", "b", "new-file")
+	runCmd(t, repoDir, "git", "commit", "-m", "change")
+	pull.HeadCommit = strings.TrimSpace(runCmd(t, repoDir, "git", "rev-parse", "HEAD"))
+	cloneDir, _, err = wd.Clone(logging.NewNoopLogger(t), models.Repo{}, pull, "default")
+	Ok(t, err)
+	_, err = os.Stat(filepath.Join(cloneDir, "a", "default.tfplan"))
+	Assert(t, os.IsNotExist(err), "expected plan to be deleted from clone")
+
+	prev, err = wd.GetPreviousClone(logging.NewNoopLogger(t), pull, "default")
+	Ok(t, err)
+	Equals(t, prevCommit, prev.HeadCommit)
+	Equals(t, []string{".gitkeep", "b/main.tf", "new-file"}, prev.ChangedFiles)
+	_, err = os.Stat(filepath.Join(prev.Dir, "a", "default.tfplan"))
+	Ok(t, err)
+
+	Ok(t, wd.DeletePreviousClone(pull, "default"))
+	prev, err = wd.GetPreviousClone(logging.NewNoopLogger(t), pull, "default")
+	Ok(t, err)
+	Assert(t, prev == nil, "expected previous clone to be deleted")
+}
+
 // Test that if the branch we're merging into has diverged and we're using
 // checkout-strategy=merge, we warn the user (see #804).
 func TestClone_MasterHasDiverged(t *testing.T) {
@@ -462,6 +674,104 @@ func TestHasDiverged_MasterHasDiverged(t *testing.T) {
 	Equals(t, hasDiverged, false)
 }
 
+func TestBaseAdvanced(t *testing.T) {
+	repoDir, cleanup := initRepo(t)
+	defer cleanup()
+	runCmd(t, repoDir, "git", "checkout", "branch")
+	runCmd(t, repoDir, "touch", "branch-file")
+	runCmd(t, repoDir, "git", "add", "branch-file")
+	runCmd(t, repoDir, "git", "commit", "-m", "branch-commit")
+
+	dataDir, cleanup2 := TempDir(t)
+	defer cleanup2()
+	overrideURL := fmt.Sprintf("file://%s", repoDir)
+	wd := &events.FileWorkspace{
+		DataDir:                     dataDir,
+		CheckoutMerge:               true,
+		TestingOverrideHeadCloneURL: overrideURL,
+		TestingOverrideBaseCloneURL: overrideURL,
+	}
+	pull := models.PullRequest{
+		HeadBranch: "branch",
+		BaseBranch: "master",
+	}
+	cloneDir, _, err := wd.Clone(logging.NewNoopLogger(t), models.Repo{}, pull, "default")
+	Ok(t, err)
+	advanced, err := wd.BaseAdvanced(logging.NewNoopLogger(t), models.Repo{}, pull, cloneDir)
+	Ok(t, err)
+	Equals(t, false, advanced)
+
+	// Advance master after the clone.
+	runCmd(t, repoDir, "git", "checkout", "master")
+	runCmd(t, repoDir, "touch", "master-file")
+	runCmd(t, repoDir, "git", "add", "master-file")
+	runCmd(t, repoDir, "git", "commit", "-m", "master-commit")
+	advanced, err = wd.BaseAdvanced(logging.NewNoopLogger(t), models.Repo{}, pull, cloneDir)
+	Ok(t, err)
+	Equals(t, true, advanced)
+
+}
+
+func TestBaseAdvanced_Branch(t *testing.T) {
+	repoDir, cleanup := initRepo(t)
+	defer cleanup()
+	dataDir, cleanup2 := TempDir(t)
+	defer cleanup2()
+	overrideURL := fmt.Sprintf("file://%s", repoDir)
+	wd := &events.FileWorkspace{
+		DataDir:                     dataDir,
+		CheckoutMerge:               false,
+		TestingOverrideHeadCloneURL: overrideURL,
+		TestingOverrideBaseCloneURL: overrideURL,
+	}
+	pull := models.PullRequest{
+		HeadBranch: "branch",
+		BaseBranch: "master",
+	}
+	cloneDir, _, err := wd.Clone(logging.NewNoopLogger(t), models.Repo{}, pull, "default")
+	Ok(t, err)
+	advanced, err := wd.BaseAdvanced(logging.NewNoopLogger(t), models.Repo{}, pull, cloneDir)
+	Ok(t, err)
+	Equals(t, false, advanced)
+
+	// Advance master after the clone.
+	runCmd(t, repoDir, "git", "checkout", "master")
+	runCmd(t, repoDir, "touch", "master-file")
+	runCmd(t, repoDir, "git", "add", "master-file")
+	runCmd(t, repoDir, "git", "commit", "-m", "master-commit")
+	advanced, err = wd.BaseAdvanced(logging.NewNoopLogger(t), models.Repo{}, pull, cloneDir)
+	Ok(t, err)
+	Equals(t, true, advanced)
+
+	// Planning again fetches the new base.
+	_, _, err = wd.Clone(logging.NewNoopLogger(t), models.Repo{}, pull, "default")
+	Ok(t, err)
+	advanced, err = wd.BaseAdvanced(logging.NewNoopLogger(t), models.Repo{}, pull, cloneDir)
+	Ok(t, err)
+	Equals(t, false, advanced)
+
+	// It can't be checked if the base wasn't fetched.
+	runCmd(t, cloneDir, "git", "update-ref", "-d", "refs/remotes/origin/master")
+	_, err = wd.BaseAdvanced(logging.NewNoopLogger(t), models.Repo{}, pull, cloneDir)
+	ErrEquals(t, `base branch "master" wasn't fetched when the pull request was planned`, err)
+}
+
+func TestRemoteBranchSHA(t *testing.T) {
+	repoDir, cleanup := initRepo(t)
+	defer cleanup()
+	expSHA := strings.TrimSpace(runCmd(t, repoDir, "git", "rev-parse", "branch"))
+
+	wd := &events.FileWorkspace{
+		TestingOverrideBaseCloneURL: fmt.Sprintf("file://%s", repoDir),
+	}
+	sha, err := wd.RemoteBranchSHA(logging.NewNoopLogger(t), models.Repo{}, "branch")
+	Ok(t, err)
+	Equals(t, expSHA, sha)
+
+	_, err = wd.RemoteBranchSHA(logging.NewNoopLogger(t), models.Repo{}, "missing")
+	ErrEquals(t, `branch "missing" not found`, err)
+}
+
 func initRepo(t *testing.T) (string, func()) {
 	repoDir, cleanup := TempDir(t)
 	runCmd(t, repoDir, "git", "init")
@@ -473,3 +783,291 @@ func initRepo(t *testing.T) (string, func()) {
 	runCmd(t, repoDir, "git", "branch", "branch")
 	return repoDir, cleanup
 }
+
+// Test that shallow clones fetch the whole history when the branches being
+// merged have no common ancestor within the checkout depth.
+func TestClone_CheckoutMergeShallow(t *testing.T) {
+	repoDir, cleanup := initRepo(t)
+	defer cleanup()
+
+	// Branch off of a commit after the initial commit so that it's the
+	// oldest commit needed to merge.
+	runCmd(t, repoDir, "touch", "base-file")
+	runCmd(t, repoDir, "git", "add", "base-file")
+	runCmd(t, repoDir, "git", "commit", "-m", "base-commit")
+	runCmd(t, repoDir, "git", "checkout", "-B", "branch")
+	runCmd(t, repoDir, "touch", "branch-file")
+	runCmd(t, repoDir, "git", "add", "branch-file")
+	runCmd(t, repoDir, "git", "commit", "-m", "branch-commit")
+	branchCommit := runCmd(t, repoDir, "git", "rev-parse", "HEAD")
+
+	runCmd(t, repoDir, "git", "checkout", "master")
+	runCmd(t, repoDir, "touch", "master-file")
+	runCmd(t, repoDir, "git", "add", "master-file")
+	runCmd(t, repoDir, "git", "commit", "-m", "master-commit")
+	masterCommit := runCmd(t, repoDir, "git", "rev-parse", "HEAD")
+
+	overrideURL := fmt.Sprintf("file://%s", repoDir)
+	for _, c := range []struct {
+		depth      int
+		expShallow string
+	}{
+		// The base commit is within 2 commits of master and branch so the
+		// initial commit isn't fetched.
+		{2, "true\n"},
+		// It isn't within 1 commit of them.
+		{1, "false\n"},
+	} {
+		t.Run(fmt.Sprintf("depth %d", c.depth), func(t *testing.T) {
+			dataDir, cleanup2 := TempDir(t)
+			defer cleanup2()
+			wd := &events.FileWorkspace{
+				DataDir:                     dataDir,
+				CheckoutMerge:               true,
+				CheckoutDepth:               c.depth,
+				TestingOverrideHeadCloneURL: overrideURL,
+				TestingOverrideBaseCloneURL: overrideURL,
+			}
+
+			cloneDir, _, err := wd.Clone(logging.NewNoopLogger(t), models.Repo{}, models.PullRequest{
+				BaseRepo:   models.Repo{},
+				HeadBranch: "branch",
+				BaseBranch: "master",
+			}, "default")
+			Ok(t, err)
+			Equals(t, masterCommit, runCmd(t, cloneDir, "git", "rev-parse", "HEAD~1"))
+			Equals(t, branchCommit, runCmd(t, cloneDir, "git", "rev-parse", "HEAD^2"))
+			Equals(t, c.expShallow, runCmd(t, cloneDir, "git", "rev-parse", "--is-shallow-repository"))
+		})
+	}
+}
+
+// Test that sparse clones only check out the files at the root of the repo
+// and the dirs passed to CheckoutDirs.
+func TestCheckoutDirs_SparseCheckout(t *testing.T) {
+	repoDir, cleanup := initRepo(t)
+	defer cleanup()
+	runCmd(t, repoDir, "git", "checkout", "branch")
+	for _, dir := range []string{"dir1/sub", "dir2"} {
+		runCmd(t, repoDir, "mkdir", "-p", dir)
+		runCmd(t, repoDir, "touch", filepath.Join(dir, "main.tf"))
+	}
+	runCmd(t, repoDir, "touch", "atlantis.yaml")
+	runCmd(t, repoDir, "git", "add", ".")
+	runCmd(t, repoDir, "git", "commit", "-m", "projects")
+
+	dataDir, cleanup2 := TempDir(t)
+	defer cleanup2()
+	wd := &events.FileWorkspace{
+		DataDir:                     dataDir,
+		SparseCheckout:              true,
+		TestingOverrideHeadCloneURL: fmt.Sprintf("file://%s", repoDir),
+	}
+	pull := models.PullRequest{
+		BaseRepo:   models.Repo{},
+		HeadBranch: "branch",
+	}
+	logger := logging.NewNoopLogger(t)
+	cloneDir, _, err := wd.Clone(logger, models.Repo{}, pull, "default")
+	Ok(t, err)
+	Equals(t, ".gitkeep\natlantis.yaml\n", runCmd(t, cloneDir, "ls", "-A", "-I", ".git"))
+
+	Ok(t, wd.CheckoutDirs(logger, pull, "default", []string{"dir1/sub", "dir1/sub/", "../outside"}))
+	Equals(t, ".gitkeep\natlantis.yaml\ndir1\n", runCmd(t, cloneDir, "ls", "-A", "-I", ".git"))
+	Equals(t, "main.tf\n", runCmd(t, cloneDir, "ls", "dir1/sub"))
+
+	// The whole repo is checked out if its root dir is needed.
+	Ok(t, wd.CheckoutDirs(logger, pull, "default", []string{"."}))
+	Equals(t, ".gitkeep\natlantis.yaml\ndir1\ndir2\n", runCmd(t, cloneDir, "ls", "-A", "-I", ".git"))
+}
+
+// Test that CheckoutDirs does nothing if sparse checkout isn't enabled.
+func TestCheckoutDirs_NotSparse(t *testing.T) {
+	wd := &events.FileWorkspace{
+		DataDir: "/does-not-exist",
+	}
+	Ok(t, wd.CheckoutDirs(logging.NewNoopLogger(t), models.PullRequest{}, "default", []string{"dir1"}))
+}
+
+// Test that Revert only reverts the changes of the commit in the dirs and
+// that the revert commit can be pushed.
+func TestRevert(t *testing.T) {
+	repoDir, cleanup := initRepo(t)
+	defer cleanup()
+	for _, dir := range []string{"dir1", "dir2"} {
+		runCmd(t, repoDir, "mkdir", dir)
+		runCmd(t, repoDir, "sh", "-c", "echo before > "+filepath.Join(dir, "main.tf"))
+	}
+	runCmd(t, repoDir, "git", "add", ".")
+	runCmd(t, repoDir, "git", "commit", "-m", "projects")
+	runCmd(t, repoDir, "sh", "-c", "echo after > dir1/main.tf && echo after > dir2/main.tf")
+	runCmd(t, repoDir, "git", "commit", "-am", "change")
+	commit := strings.TrimSpace(runCmd(t, repoDir, "git", "rev-parse", "HEAD"))
+	runCmd(t, repoDir, "touch", "later-file")
+	runCmd(t, repoDir, "git", "add", "later-file")
+	runCmd(t, repoDir, "git", "commit", "-m", "later")
+
+	dataDir, cleanup2 := TempDir(t)
+	defer cleanup2()
+	wd := &events.FileWorkspace{
+		DataDir:                     dataDir,
+		TestingOverrideHeadCloneURL: fmt.Sprintf("file://%s", repoDir),
+		TestingOverrideBaseCloneURL: fmt.Sprintf("file://%s", repoDir),
+	}
+	pull := models.PullRequest{
+		BaseRepo:   models.Repo{},
+		HeadBranch: "master",
+		HeadCommit: "master",
+		BaseBranch: "master",
+	}
+	logger := logging.NewNoopLogger(t)
+	cloneDir, _, err := wd.Clone(logger, models.Repo{}, pull, "default")
+	Ok(t, err)
+
+	revert, err := wd.Revert(logger, pull, "default", commit, []string{"dir1"}, "Revert change")
+	Ok(t, err)
+	Equals(t, revert+"\n", runCmd(t, cloneDir, "git", "rev-parse", "HEAD"))
+	Equals(t, "Revert change\n", runCmd(t, cloneDir, "git", "log", "-1", "--format=%s"))
+	Equals(t, "before\n", runCmd(t, cloneDir, "cat", "dir1/main.tf"))
+	Equals(t, "after\n", runCmd(t, cloneDir, "cat", "dir2/main.tf"))
+	Equals(t, "", runCmd(t, cloneDir, "git", "status", "--porcelain"))
+
+	Ok(t, wd.PushBranch(logger, pull, "default", revert, "atlantis/revert"))
+	Equals(t, revert+"\n", runCmd(t, repoDir, "git", "rev-parse", "atlantis/revert"))
+}
+
+// Test that Revert errors and leaves the clone as it was if the commit
+// didn't change the dirs or its changes can't be reverted cleanly.
+func TestRevert_Errors(t *testing.T) {
+	repoDir, cleanup := initRepo(t)
+	defer cleanup()
+	runCmd(t, repoDir, "mkdir", "dir1", "dir2")
+	runCmd(t, repoDir, "sh", "-c", "echo before > dir1/main.tf && echo before > dir2/main.tf")
+	runCmd(t, repoDir, "git", "add", ".")
+	runCmd(t, repoDir, "git", "commit", "-m", "projects")
+	runCmd(t, repoDir, "sh", "-c", "echo after > dir1/main.tf")
+	runCmd(t, repoDir, "git", "commit", "-am", "change")
+	commit := strings.TrimSpace(runCmd(t, repoDir, "git", "rev-parse", "HEAD"))
+	runCmd(t, repoDir, "sh", "-c", "echo conflict > dir1/main.tf")
+	runCmd(t, repoDir, "git", "commit", "-am", "conflict")
+	head := runCmd(t, repoDir, "git", "rev-parse", "HEAD")
+
+	dataDir, cleanup2 := TempDir(t)
+	defer cleanup2()
+	wd := &events.FileWorkspace{
+		DataDir:                     dataDir,
+		TestingOverrideHeadCloneURL: fmt.Sprintf("file://%s", repoDir),
+		TestingOverrideBaseCloneURL: fmt.Sprintf("file://%s", repoDir),
+	}
+	pull := models.PullRequest{
+		BaseRepo:   models.Repo{},
+		HeadBranch: "master",
+		HeadCommit: "master",
+		BaseBranch: "master",
+	}
+	logger := logging.NewNoopLogger(t)
+	cloneDir, _, err := wd.Clone(logger, models.Repo{}, pull, "default")
+	Ok(t, err)
+
+	_, err = wd.Revert(logger, pull, "default", commit, []string{"dir2"}, "Revert change")
+	ErrEquals(t, fmt.Sprintf("commit %s didn't change dir2", commit), err)
+
+	_, err = wd.Revert(logger, pull, "default", commit, []string{"dir1"}, "Revert change")
+	Assert(t, err != nil, "exp error reverting conflicting change")
+	Equals(t, head, runCmd(t, cloneDir, "git", "rev-parse", "HEAD"))
+	Equals(t, "conflict\n", runCmd(t, cloneDir, "cat", "dir1/main.tf"))
+	Equals(t, "", runCmd(t, cloneDir, "git", "status", "--porcelain"))
+}
+
+// Test that with the merge checkout strategy, Clone reuses the clone for the
+// revert commit.
+func TestRevert_CheckoutMerge(t *testing.T) {
+	repoDir, cleanup := initRepo(t)
+	defer cleanup()
+	runCmd(t, repoDir, "sh", "-c", "echo after > file")
+	runCmd(t, repoDir, "git", "add", "file")
+	runCmd(t, repoDir, "git", "commit", "-m", "change")
+	commit := strings.TrimSpace(runCmd(t, repoDir, "git", "rev-parse", "HEAD"))
+
+	dataDir, cleanup2 := TempDir(t)
+	defer cleanup2()
+	wd := &events.FileWorkspace{
+		DataDir:                     dataDir,
+		CheckoutMerge:               true,
+		TestingOverrideHeadCloneURL: fmt.Sprintf("file://%s", repoDir),
+		TestingOverrideBaseCloneURL: fmt.Sprintf("file://%s", repoDir),
+	}
+	pull := models.PullRequest{
+		BaseRepo:   models.Repo{},
+		HeadBranch: "master",
+		HeadCommit: "master",
+		BaseBranch: "master",
+	}
+	logger := logging.NewNoopLogger(t)
+	cloneDir, _, err := wd.Clone(logger, models.Repo{}, pull, "default")
+	Ok(t, err)
+
+	revert, err := wd.Revert(logger, pull, "default", commit, []string{"."}, "Revert change")
+	Ok(t, err)
+	Equals(t, revert+"\n", runCmd(t, cloneDir, "git", "rev-parse", "HEAD^2"))
+	runCmd(t, cloneDir, "touch", "proof")
+
+	pull.HeadCommit = revert
+	_, _, err = wd.Clone(logger, models.Repo{}, pull, "default")
+	Ok(t, err)
+	_, err = os.Stat(filepath.Join(cloneDir, "proof"))
+	Ok(t, err)
+	_, err = os.Stat(filepath.Join(cloneDir, "file"))
+	Assert(t, os.IsNotExist(err), "exp file to be reverted")
+}
+
+func TestPushCommit(t *testing.T) {
+	for _, checkoutMerge := range []bool{false, true} {
+		t.Run(fmt.Sprintf("checkout merge %t", checkoutMerge), func(t *testing.T) {
+			repoDir, cleanup := initRepo(t)
+			defer cleanup()
+			runCmd(t, repoDir, "git", "checkout", "branch")
+			runCmd(t, repoDir, "mkdir", "dir")
+			runCmd(t, repoDir, "sh", "-c", "echo 'a=1' > dir/main.tf")
+			runCmd(t, repoDir, "git", "add", "dir")
+			runCmd(t, repoDir, "git", "commit", "-m", "branch-commit")
+			branchCommit := strings.TrimSpace(runCmd(t, repoDir, "git", "rev-parse", "HEAD"))
+			// The branch can't be pushed to while it's checked out.
+			runCmd(t, repoDir, "git", "checkout", "master")
+			runCmd(t, repoDir, "touch", "master-file")
+			runCmd(t, repoDir, "git", "add", "master-file")
+			runCmd(t, repoDir, "git", "commit", "-m", "master-commit")
+
+			dataDir, cleanup2 := TempDir(t)
+			defer cleanup2()
+			wd := &events.FileWorkspace{
+				DataDir:                     dataDir,
+				CheckoutMerge:               checkoutMerge,
+				TestingOverrideHeadCloneURL: fmt.Sprintf("file://%s", repoDir),
+				TestingOverrideBaseCloneURL: fmt.Sprintf("file://%s", repoDir),
+			}
+			pull := models.PullRequest{
+				BaseRepo:   models.Repo{},
+				HeadBranch: "branch",
+				HeadCommit: branchCommit,
+				BaseBranch: "master",
+			}
+			logger := logging.NewNoopLogger(t)
+			cloneDir, _, err := wd.Clone(logger, models.Repo{}, pull, "default")
+			Ok(t, err)
+			runCmd(t, cloneDir, "sh", "-c", "echo 'a = 1' > dir/main.tf")
+
+			commit, err := wd.PushCommit(logger, models.Repo{}, pull, "default", []string{"dir"}, "Format")
+			Ok(t, err)
+			Equals(t, commit+"\n", runCmd(t, repoDir, "git", "rev-parse", "branch"))
+			Equals(t, branchCommit+"\n", runCmd(t, repoDir, "git", "rev-parse", "branch~1"))
+			Equals(t, "a = 1\n", runCmd(t, repoDir, "git", "show", "branch:dir/main.tf"))
+			Equals(t, "Format\n", runCmd(t, repoDir, "git", "log", "-1", "--format=%s", "branch"))
+
+			// The changes are discarded from the clone.
+			Equals(t, "a=1\n", runCmd(t, cloneDir, "cat", "dir/main.tf"))
+			_, err = wd.PushCommit(logger, models.Repo{}, pull, "default", []string{"dir"}, "Format")
+			ErrEquals(t, "nothing changed in dir", err)
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package events
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	version "github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+func NewWorkspaceCommandRunner(
+	prjCmdBuilder ProjectPlanCommandBuilder,
+	terraformExec runtime.TerraformExec,
+	workingDir WorkingDir,
+	workingDirLocker WorkingDirLocker,
+	projectLocker ProjectLocker,
+	applyReqsChecker ProjectApplyRequirementsChecker,
+	vcsClient vcs.Client,
+	pullWorkspaces *PullWorkspaces,
+	SilenceNoProjects bool,
+) *WorkspaceCommandRunner {
+	return &WorkspaceCommandRunner{
+		prjCmdBuilder:     prjCmdBuilder,
+		terraformExec:     terraformExec,
+		workingDir:        workingDir,
+		workingDirLocker:  workingDirLocker,
+		projectLocker:     projectLocker,
+		applyReqsChecker:  applyReqsChecker,
+		vcsClient:         vcsClient,
+		pullWorkspaces:    pullWorkspaces,
+		SilenceNoProjects: SilenceNoProjects,
+	}
+}
+
+// WorkspaceCommandRunner creates, deletes and lists the Terraform workspaces
+// of projects with atlantis workspace, ex. for ephemeral per pull request
+// environments, so they don't need shell access to Atlantis.
+type WorkspaceCommandRunner struct {
+	prjCmdBuilder    ProjectPlanCommandBuilder
+	terraformExec    runtime.TerraformExec
+	workingDir       WorkingDir
+	workingDirLocker WorkingDirLocker
+	// projectLocker and applyReqsChecker guard deleting workspaces like
+	// apply, see deleteFailure.
+	projectLocker    ProjectLocker
+	applyReqsChecker ProjectApplyRequirementsChecker
+	vcsClient        vcs.Client
+	// pullWorkspaces records the created workspaces so they're deleted when
+	// their pull request is closed. If nil, they're kept.
+	pullWorkspaces *PullWorkspaces
+	// SilenceNoProjects is whether Atlantis should respond to PRs if no projects
+	// are found
+	SilenceNoProjects bool
+}
+
+func (w *WorkspaceCommandRunner) Run(
+	ctx *CommandContext,
+	cmd *CommentCommand,
+) {
+	if cmd.WorkspaceAction == workspaceDeleteAction {
+		// The mergeable apply requirement is checked before deleting.
+		var err error
+		if ctx.PullMergeable, err = w.vcsClient.PullIsMergeable(ctx.Pull.BaseRepo, ctx.Pull); err != nil {
+			ctx.PullMergeable = false
+			ctx.Log.Warn("unable to get mergeable status: %s. Continuing with mergeable assumed false", err)
+		}
+	}
+
+	// The projects are found like for plan so that the same flags select
+	// them, which also clones the pull request.
+	projectCmds, err := w.prjCmdBuilder.BuildPlanCommands(ctx, cmd)
+	if err != nil {
+		ctx.Log.Err("failed to build workspace commands: %s", err)
+		ctx.CommandError = err
+		w.comment(ctx, fmt.Sprintf("**Workspace Error**\n```\n%s\n```", err))
+		return
+	}
+	if len(projectCmds) == 0 {
+		if !w.SilenceNoProjects {
+			w.comment(ctx, fmt.Sprintf("Ran workspace %s for 0 projects.", cmd.WorkspaceAction))
+		}
+		return
+	}
+
+	var sections []string
+	for _, projectCmd := range projectCmds {
+		if cmd.WorkspaceAction == workspaceDeleteAction {
+			failure, err := w.deleteFailure(projectCmd)
+			if err != nil {
+				ctx.Log.Err("failed to run workspace %s for %s: %s", cmd.WorkspaceAction, projectDescription(projectCmd), err)
+				ctx.CommandError = err
+				sections = append(sections, fmt.Sprintf("### %s\n**Workspace Error**\n```\n%s\n```", projectDescription(projectCmd), err))
+				continue
+			}
+			if failure != "" {
+				ctx.CommandFailure = failure
+				sections = append(sections, fmt.Sprintf("### %s\n**Workspace Failed**: %s", projectDescription(projectCmd), failure))
+				continue
+			}
+		}
+		out, err := w.run(ctx.Log, projectCmd, cmd.WorkspaceAction, cmd.WorkspaceName)
+		if err != nil {
+			ctx.Log.Err("failed to run workspace %s for %s: %s", cmd.WorkspaceAction, projectDescription(projectCmd), err)
+			ctx.CommandError = err
+			sections = append(sections, fmt.Sprintf("### %s\n**Workspace Error**\n```\n%s\n%s\n```", projectDescription(projectCmd), err, out))
+			continue
+		}
+		sections = append(sections, fmt.Sprintf("### %s\n```\n%s\n```", projectDescription(projectCmd), strings.TrimSpace(out)))
+		w.record(ctx.Log, projectCmd, cmd.WorkspaceAction, cmd.WorkspaceName)
+	}
+	projects := "projects"
+	if len(projectCmds) == 1 {
+		projects = "project"
+	}
+	w.comment(ctx, fmt.Sprintf("Ran workspace %s for %d %s:\n\n%s", cmd.WorkspaceAction, len(projectCmds), projects, strings.Join(sections, "\n\n")))
+}
+
+// DeletePullWorkspaces deletes the workspaces that were created for pull and
+// comments which were deleted. It does nothing if the created workspaces
+// aren't recorded. Unlike atlantis workspace delete, the project locks and
+// apply requirements aren't checked since pull is closed.
+func (w *WorkspaceCommandRunner) DeletePullWorkspaces(log logging.SimpleLogging, repo models.Repo, pull models.PullRequest) {
+	if w.pullWorkspaces == nil {
+		return
+	}
+	workspaces, err := w.pullWorkspaces.ForPull(pull)
+	if err != nil {
+		log.Err("unable to find workspaces of pull request: %s", err)
+		return
+	}
+	var lines []string
+	for _, workspace := range workspaces {
+		projectCmd := models.ProjectCommandContext{
+			Log:         log,
+			Pull:        workspace.Pull,
+			HeadRepo:    workspace.HeadRepo,
+			RepoRelDir:  workspace.RepoRelDir,
+			ProjectName: workspace.ProjectName,
+			Workspace:   workspace.ProjectWorkspace,
+		}
+		if workspace.TerraformVersion != "" {
+			projectCmd.TerraformVersion, _ = version.NewVersion(workspace.TerraformVersion)
+		}
+		// The clone is usually still there since it's deleted after this
+		// but it may have been cleaned up already.
+		if err := w.ensureCloned(log, workspace); err != nil {
+			log.Err("unable to clone pull request to delete workspace %q of %s: %s", workspace.Name, projectDescription(projectCmd), err)
+			lines = append(lines, fmt.Sprintf("* :x: `%s` in %s: %s", workspace.Name, projectDescription(projectCmd), err))
+			continue
+		}
+		if out, err := w.run(log, projectCmd, workspaceDeleteAction, workspace.Name); err != nil {
+			log.Err("unable to delete workspace %q of %s: %s: %s", workspace.Name, projectDescription(projectCmd), err, out)
+			lines = append(lines, fmt.Sprintf("* :x: `%s` in %s: %s", workspace.Name, projectDescription(projectCmd), err))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("* `%s` in %s", workspace.Name, projectDescription(projectCmd)))
+		w.record(log, projectCmd, workspaceDeleteAction, workspace.Name)
+	}
+	if len(lines) == 0 {
+		return
+	}
+	comment := fmt.Sprintf("Deleted the workspaces created for this pull request:\n\n%s", strings.Join(lines, "\n"))
+	if err := w.vcsClient.CreateComment(repo, pull.Num, comment, models.WorkspaceCommand.String()); err != nil {
+		log.Err("unable to comment: %s", err)
+	}
+}
+
+// ensureCloned clones the pull request of workspace unless it's still cloned.
+func (w *WorkspaceCommandRunner) ensureCloned(log logging.SimpleLogging, workspace PullWorkspace) error {
+	unlockFn, err := w.workingDirLocker.TryLock(workspace.Pull.BaseRepo.FullName, workspace.Pull.Num, workspace.ProjectWorkspace)
+	if err != nil {
+		return err
+	}
+	defer unlockFn()
+	if _, err := w.workingDir.GetWorkingDir(workspace.Pull.BaseRepo, workspace.Pull, workspace.ProjectWorkspace); !os.IsNotExist(err) {
+		return nil
+	}
+	_, _, err = w.workingDir.Clone(log, workspace.HeadRepo, workspace.Pull, workspace.ProjectWorkspace)
+	return err
+}
+
+// deleteFailure returns why the workspace can't be deleted from the project
+// of projectCmd or "" if it can. Deleting workspaces changes the project's
+// backend so, like for apply, the project must be locked by the pull request
+// and its apply requirements must be met. The project stays locked, like
+// after plan.
+func (w *WorkspaceCommandRunner) deleteFailure(projectCmd models.ProjectCommandContext) (string, error) {
+	lockAttempt, err := w.projectLocker.TryLock(projectCmd.Log, projectCmd.Pull, projectCmd.User, projectCmd.Workspace, models.NewProject(projectCmd.Pull.BaseRepo.FullName, projectCmd.RepoRelDir))
+	if err != nil {
+		return "", errors.Wrap(err, "acquiring lock")
+	}
+	if !lockAttempt.LockAcquired {
+		return lockAttempt.LockFailureReason, nil
+	}
+	return w.applyReqsChecker.ApplyRequirementsFailure(projectCmd)
+}
+
+// run runs terraform workspace action for the project of projectCmd after
+// initializing it, since the workspaces are stored in its backend.
+func (w *WorkspaceCommandRunner) run(log logging.SimpleLogging, projectCmd models.ProjectCommandContext, action string, name string) (string, error) {
+	unlockFn, err := w.workingDirLocker.TryLock(projectCmd.Pull.BaseRepo.FullName, projectCmd.Pull.Num, projectCmd.Workspace)
+	if err != nil {
+		return "", err
+	}
+	defer unlockFn()
+	repoDir, err := w.workingDir.GetWorkingDir(projectCmd.Pull.BaseRepo, projectCmd.Pull, projectCmd.Workspace)
+	if err != nil {
+		return "", err
+	}
+	absPath := filepath.Join(repoDir, projectCmd.RepoRelDir)
+	if _, err := os.Stat(absPath); os.IsNotExist(err) {
+		return "", DirNotExistErr{RepoRelDir: projectCmd.RepoRelDir}
+	}
+	if out, err := w.terraformExec.RunCommandWithVersion(log, absPath, []string{"init", "-input=false", "-no-color"}, nil, projectCmd.TerraformVersion, projectCmd.Workspace); err != nil {
+		return out, err
+	}
+	args := []string{"workspace", action, "-no-color"}
+	if name != "" {
+		args = append(args, name)
+	}
+	return w.terraformExec.RunCommandWithVersion(log, absPath, args, nil, projectCmd.TerraformVersion, projectCmd.Workspace)
+}
+
+// record records that the workspace name was created or deleted in the
+// project of projectCmd.
+func (w *WorkspaceCommandRunner) record(log logging.SimpleLogging, projectCmd models.ProjectCommandContext, action string, name string) {
+	if w.pullWorkspaces == nil {
+		return
+	}
+	var err error
+	switch action {
+	case workspaceNewAction:
+		var tfVersion string
+		if projectCmd.TerraformVersion != nil {
+			tfVersion = projectCmd.TerraformVersion.String()
+		}
+		err = w.pullWorkspaces.Add(PullWorkspace{
+			Pull:             projectCmd.Pull,
+			HeadRepo:         projectCmd.HeadRepo,
+			RepoRelDir:       projectCmd.RepoRelDir,
+			ProjectName:      projectCmd.ProjectName,
+			ProjectWorkspace: projectCmd.Workspace,
+			TerraformVersion: tfVersion,
+			Name:             name,
+		})
+	case workspaceDeleteAction:
+		err = w.pullWorkspaces.Remove(projectCmd.Pull, projectCmd.RepoRelDir, name)
+	}
+	if err != nil {
+		log.Err("unable to record workspace %s %q: %s", action, name, err)
+	}
+}
+
+func (w *WorkspaceCommandRunner) comment(ctx *CommandContext, comment string) {
+	if commentErr := w.vcsClient.CreateComment(ctx.Pull.BaseRepo, ctx.Pull.Num, comment, models.WorkspaceCommand.String()); commentErr != nil {
+		ctx.Log.Err("unable to comment: %s", commentErr)
+	}
+}
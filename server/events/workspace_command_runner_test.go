@@ -0,0 +1,210 @@
+package events_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	version "github.com/hashicorp/go-version"
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/models/fixtures"
+	tfmocks "github.com/runatlantis/atlantis/server/events/terraform/mocks"
+	tmatchers "github.com/runatlantis/atlantis/server/events/terraform/mocks/matchers"
+	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestWorkspaceCommandRunner_NewAndDeleteOnClose(t *testing.T) {
+	RegisterMockTestingT(t)
+	repoDir, cleanup := TempDir(t)
+	defer cleanup()
+	Ok(t, os.Mkdir(filepath.Join(repoDir, "dir"), 0700))
+	absPath := filepath.Join(repoDir, "dir")
+	registryDir, cleanupRegistry := TempDir(t)
+	defer cleanupRegistry()
+	pullWorkspaces, err := events.NewPullWorkspaces(registryDir)
+	Ok(t, err)
+
+	builder := mocks.NewMockProjectCommandBuilder()
+	tf := tfmocks.NewMockClient()
+	workingDir := mocks.NewMockWorkingDir()
+	vcsClient := vcsmocks.NewMockClient()
+	runner := events.NewWorkspaceCommandRunner(builder, tf, workingDir, events.NewDefaultWorkingDirLocker(), mocks.NewMockProjectLocker(), applyReqsChecker{}, vcsClient, pullWorkspaces, false)
+
+	logger := logging.NewNoopLogger(t)
+	tfVersion := version.Must(version.NewVersion("0.14.0"))
+	pull := fixtures.Pull
+	pull.BaseRepo = fixtures.GithubRepo
+	ctx := &events.CommandContext{Pull: pull, HeadRepo: fixtures.GithubRepo, User: fixtures.User, Log: logger}
+	cmd := &events.CommentCommand{Name: models.WorkspaceCommand, RepoRelDir: "dir", WorkspaceAction: "new", WorkspaceName: "pr-1"}
+	When(builder.BuildPlanCommands(ctx, cmd)).ThenReturn([]models.ProjectCommandContext{
+		{Log: logger, Pull: pull, HeadRepo: fixtures.GithubRepo, RepoRelDir: "dir", Workspace: "default", TerraformVersion: tfVersion},
+	}, nil)
+	When(workingDir.GetWorkingDir(fixtures.GithubRepo, pull, "default")).ThenReturn(repoDir, nil)
+	When(tf.RunCommandWithVersion(logger, absPath, []string{"init", "-input=false", "-no-color"}, map[string]string(nil), tfVersion, "default")).
+		ThenReturn("", nil)
+	When(tf.RunCommandWithVersion(logger, absPath, []string{"workspace", "new", "-no-color", "pr-1"}, map[string]string(nil), tfVersion, "default")).
+		ThenReturn("Created and switched to workspace \"pr-1\"!\n", nil)
+
+	runner.Run(ctx, cmd)
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, pull.Num, "Ran workspace new for 1 project:\n\n"+
+		"### dir: `dir` workspace: `default`\n```\nCreated and switched to workspace \"pr-1\"!\n```",
+		"workspace")
+	workspaces, err := pullWorkspaces.ForPull(pull)
+	Ok(t, err)
+	Equals(t, 1, len(workspaces))
+	Equals(t, "pr-1", workspaces[0].Name)
+	Equals(t, "0.14.0", workspaces[0].TerraformVersion)
+
+	// Closing the pull request deletes the workspace.
+	When(tf.RunCommandWithVersion(logger, absPath, []string{"workspace", "delete", "-no-color", "pr-1"}, map[string]string(nil), tfVersion, "default")).
+		ThenReturn("Deleted workspace \"pr-1\"!\n", nil)
+	runner.DeletePullWorkspaces(logger, fixtures.GithubRepo, pull)
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, pull.Num, "Deleted the workspaces created for this pull request:\n\n"+
+		"* `pr-1` in dir: `dir` workspace: `default`",
+		"workspace")
+	workspaces, err = pullWorkspaces.ForPull(pull)
+	Ok(t, err)
+	Equals(t, 0, len(workspaces))
+}
+
+func TestWorkspaceCommandRunner_RunError(t *testing.T) {
+	RegisterMockTestingT(t)
+	repoDir, cleanup := TempDir(t)
+	defer cleanup()
+	absPath := filepath.Join(repoDir, ".")
+
+	builder := mocks.NewMockProjectCommandBuilder()
+	tf := tfmocks.NewMockClient()
+	workingDir := mocks.NewMockWorkingDir()
+	vcsClient := vcsmocks.NewMockClient()
+	locker := mocks.NewMockProjectLocker()
+	When(locker.TryLock(matchers.AnyLoggingSimpleLogging(), matchers.AnyModelsPullRequest(), matchers.AnyModelsUser(), AnyString(), matchers.AnyModelsProject())).
+		ThenReturn(&events.TryLockResponse{LockAcquired: true}, nil)
+	// Without a registry, nothing is recorded.
+	runner := events.NewWorkspaceCommandRunner(builder, tf, workingDir, events.NewDefaultWorkingDirLocker(), locker, applyReqsChecker{}, vcsClient, nil, false)
+
+	logger := logging.NewNoopLogger(t)
+	tfVersion := version.Must(version.NewVersion("0.14.0"))
+	pull := fixtures.Pull
+	pull.BaseRepo = fixtures.GithubRepo
+	ctx := &events.CommandContext{Pull: pull, Log: logger}
+	cmd := &events.CommentCommand{Name: models.WorkspaceCommand, WorkspaceAction: "delete", WorkspaceName: "pr-1"}
+	When(builder.BuildPlanCommands(ctx, cmd)).ThenReturn([]models.ProjectCommandContext{
+		{Log: logger, Pull: pull, RepoRelDir: ".", Workspace: "default", TerraformVersion: tfVersion},
+	}, nil)
+	When(workingDir.GetWorkingDir(fixtures.GithubRepo, pull, "default")).ThenReturn(repoDir, nil)
+	When(tf.RunCommandWithVersion(logger, absPath, []string{"init", "-input=false", "-no-color"}, map[string]string(nil), tfVersion, "default")).
+		ThenReturn("", nil)
+	When(tf.RunCommandWithVersion(logger, absPath, []string{"workspace", "delete", "-no-color", "pr-1"}, map[string]string(nil), tfVersion, "default")).
+		ThenReturn("Workspace \"pr-1\" is not empty.", errors.New("exit status 1"))
+
+	runner.Run(ctx, cmd)
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, pull.Num, "Ran workspace delete for 1 project:\n\n"+
+		"### dir: `.` workspace: `default`\n**Workspace Error**\n```\nexit status 1\nWorkspace \"pr-1\" is not empty.\n```",
+		"workspace")
+}
+
+func TestWorkspaceCommandRunner_RunNoProjects(t *testing.T) {
+	RegisterMockTestingT(t)
+	builder := mocks.NewMockProjectCommandBuilder()
+	vcsClient := vcsmocks.NewMockClient()
+	runner := events.NewWorkspaceCommandRunner(builder, tfmocks.NewMockClient(), mocks.NewMockWorkingDir(), events.NewDefaultWorkingDirLocker(), mocks.NewMockProjectLocker(), applyReqsChecker{}, vcsClient, nil, true)
+	ctx := &events.CommandContext{Pull: fixtures.Pull, Log: logging.NewNoopLogger(t)}
+	When(builder.BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())).ThenReturn(nil, nil)
+
+	runner.Run(ctx, &events.CommentCommand{Name: models.WorkspaceCommand, WorkspaceAction: "list"})
+	vcsClient.VerifyWasCalled(Never()).CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString(), AnyString())
+}
+
+func TestWorkspaceCommandRunner_DeleteFailures(t *testing.T) {
+	cases := []struct {
+		description string
+		lock        events.TryLockResponse
+		reqFailure  string
+		expComment  string
+	}{
+		{
+			description: "locked by another pull request",
+			lock:        events.TryLockResponse{LockFailureReason: "This project is currently locked by an unapplied plan from pull #2."},
+			expComment:  "**Workspace Failed**: This project is currently locked by an unapplied plan from pull #2.",
+		},
+		{
+			description: "apply requirements not met",
+			lock:        events.TryLockResponse{LockAcquired: true},
+			reqFailure:  "Pull request must be approved by at least one person other than the author before running apply.",
+			expComment:  "**Workspace Failed**: Pull request must be approved by at least one person other than the author before running apply.",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			builder := mocks.NewMockProjectCommandBuilder()
+			tf := tfmocks.NewMockClient()
+			vcsClient := vcsmocks.NewMockClient()
+			locker := mocks.NewMockProjectLocker()
+			lock := c.lock
+			When(locker.TryLock(matchers.AnyLoggingSimpleLogging(), matchers.AnyModelsPullRequest(), matchers.AnyModelsUser(), AnyString(), matchers.AnyModelsProject())).
+				ThenReturn(&lock, nil)
+			runner := events.NewWorkspaceCommandRunner(builder, tf, mocks.NewMockWorkingDir(), events.NewDefaultWorkingDirLocker(), locker, applyReqsChecker{c.reqFailure}, vcsClient, nil, false)
+
+			logger := logging.NewNoopLogger(t)
+			pull := fixtures.Pull
+			pull.BaseRepo = fixtures.GithubRepo
+			ctx := &events.CommandContext{Pull: pull, Log: logger}
+			cmd := &events.CommentCommand{Name: models.WorkspaceCommand, WorkspaceAction: "delete", WorkspaceName: "pr-1"}
+			When(builder.BuildPlanCommands(ctx, cmd)).ThenReturn([]models.ProjectCommandContext{
+				{Log: logger, Pull: pull, RepoRelDir: ".", Workspace: "default"},
+			}, nil)
+
+			runner.Run(ctx, cmd)
+			Equals(t, c.expComment[len("**Workspace Failed**: "):], ctx.CommandFailure)
+			vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, pull.Num, "Ran workspace delete for 1 project:\n\n"+
+				"### dir: `.` workspace: `default`\n"+c.expComment,
+				"workspace")
+			tf.VerifyWasCalled(Never()).RunCommandWithVersion(tmatchers.AnyLoggingSimpleLogging(), AnyString(), tmatchers.AnySliceOfString(), tmatchers.AnyMapOfStringToString(), tmatchers.AnyPtrToGoVersionVersion(), AnyString())
+		})
+	}
+}
+
+func TestWorkspaceCommandRunner_WorkingDirLocked(t *testing.T) {
+	RegisterMockTestingT(t)
+	builder := mocks.NewMockProjectCommandBuilder()
+	tf := tfmocks.NewMockClient()
+	vcsClient := vcsmocks.NewMockClient()
+	workingDirLocker := events.NewDefaultWorkingDirLocker()
+	runner := events.NewWorkspaceCommandRunner(builder, tf, mocks.NewMockWorkingDir(), workingDirLocker, mocks.NewMockProjectLocker(), applyReqsChecker{}, vcsClient, nil, false)
+
+	logger := logging.NewNoopLogger(t)
+	pull := fixtures.Pull
+	pull.BaseRepo = fixtures.GithubRepo
+	ctx := &events.CommandContext{Pull: pull, Log: logger}
+	cmd := &events.CommentCommand{Name: models.WorkspaceCommand, WorkspaceAction: "new", WorkspaceName: "pr-1"}
+	When(builder.BuildPlanCommands(ctx, cmd)).ThenReturn([]models.ProjectCommandContext{
+		{Log: logger, Pull: pull, RepoRelDir: ".", Workspace: "default"},
+	}, nil)
+	unlock, err := workingDirLocker.TryLock(pull.BaseRepo.FullName, pull.Num, "default")
+	Ok(t, err)
+	defer unlock()
+
+	runner.Run(ctx, cmd)
+	Assert(t, ctx.CommandError != nil, "expected an error")
+	ErrContains(t, "workspace is currently locked", ctx.CommandError)
+	tf.VerifyWasCalled(Never()).RunCommandWithVersion(tmatchers.AnyLoggingSimpleLogging(), AnyString(), tmatchers.AnySliceOfString(), tmatchers.AnyMapOfStringToString(), tmatchers.AnyPtrToGoVersionVersion(), AnyString())
+}
+
+// applyReqsChecker fails the apply requirements of every project with its
+// failure.
+type applyReqsChecker struct {
+	failure string
+}
+
+func (a applyReqsChecker) ApplyRequirementsFailure(_ models.ProjectCommandContext) (string, error) {
+	return a.failure, nil
+}
@@ -78,6 +78,9 @@ func (p *ParserValidator) ParseRepoCfgData(repoCfgData []byte, globalCfg valid.G
 	if err := p.validateProjectNames(validConfig); err != nil {
 		return valid.RepoCfg{}, err
 	}
+	if err := p.validateProjectDependencies(validConfig); err != nil {
+		return valid.RepoCfg{}, err
+	}
 	if validConfig.Version == 2 {
 		// The only difference between v2 and v3 is how we parse custom run
 		// commands.
@@ -173,6 +176,59 @@ func (p *ParserValidator) validateProjectNames(config valid.RepoCfg) error {
 	return nil
 }
 
+// validateProjectDependencies validates that depends_on only references
+// other named projects and that no project depends on itself, even through
+// other projects.
+func (p *ParserValidator) validateProjectDependencies(config valid.RepoCfg) error {
+	dependsOn := make(map[string][]string)
+	for _, project := range config.Projects {
+		if len(project.DependsOn) == 0 {
+			continue
+		}
+		if project.Name == nil {
+			return fmt.Errorf("project with dir: %q workspace: %q has depends_on but no name; projects with dependencies must have a 'name' key", project.Dir, project.Workspace)
+		}
+		for _, dep := range project.DependsOn {
+			if config.FindProjectByName(dep) == nil {
+				return fmt.Errorf("project %q depends on %q but there is no project with that name", *project.Name, dep)
+			}
+		}
+		dependsOn[*project.Name] = project.DependsOn
+	}
+
+	// visiting holds the projects on the current path so a project seen
+	// twice is part of a cycle.
+	visiting := make(map[string]bool)
+	visited := make(map[string]bool)
+	var visit func(name string) error
+	visit = func(name string) error {
+		if visiting[name] {
+			return fmt.Errorf("project %q depends on itself through depends_on", name)
+		}
+		if visited[name] {
+			return nil
+		}
+		visiting[name] = true
+		for _, dep := range dependsOn[name] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		visiting[name] = false
+		visited[name] = true
+		return nil
+	}
+	for _, project := range config.Projects {
+		if project.Name == nil {
+			continue
+		}
+		if err := visit(*project.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // applyLegacyShellParsing changes any custom run commands in cfg to use the old
 // parsing method with shlex.Split().
 func (p *ParserValidator) applyLegacyShellParsing(cfg *valid.RepoCfg) error {
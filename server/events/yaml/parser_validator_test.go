@@ -781,6 +781,76 @@ projects:
 				Workflows: map[string]valid.Workflow{},
 			},
 		},
+		{
+			description: "project depends on another project",
+			input: `
+version: 3
+projects:
+- name: network
+  dir: network
+- name: app
+  dir: app
+  depends_on: [network]`,
+			exp: valid.RepoCfg{
+				Version: 3,
+				Projects: []valid.Project{
+					{
+						Name:      String("network"),
+						Dir:       "network",
+						Workspace: "default",
+						Autoplan: valid.Autoplan{
+							WhenModified: []string{"**/*.tf*", "**/terragrunt.hcl"},
+							Enabled:      true,
+						},
+					},
+					{
+						Name:      String("app"),
+						Dir:       "app",
+						Workspace: "default",
+						Autoplan: valid.Autoplan{
+							WhenModified: []string{"**/*.tf*", "**/terragrunt.hcl"},
+							Enabled:      true,
+						},
+						DependsOn: []string{"network"},
+					},
+				},
+				Workflows: map[string]valid.Workflow{},
+			},
+		},
+		{
+			description: "project depends on a project that doesn't exist",
+			input: `
+version: 3
+projects:
+- name: app
+  dir: app
+  depends_on: [network]`,
+			expErr: "project \"app\" depends on \"network\" but there is no project with that name",
+		},
+		{
+			description: "unnamed project with depends_on",
+			input: `
+version: 3
+projects:
+- name: network
+  dir: network
+- dir: app
+  depends_on: [network]`,
+			expErr: "project with dir: \"app\" workspace: \"default\" has depends_on but no name; projects with dependencies must have a 'name' key",
+		},
+		{
+			description: "projects that depend on each other",
+			input: `
+version: 3
+projects:
+- name: network
+  dir: network
+  depends_on: [app]
+- name: app
+  dir: app
+  depends_on: [network]`,
+			expErr: "project \"network\" depends on itself through depends_on",
+		},
 		{
 			description: "if steps are set then we parse them properly",
 			input: `
@@ -1230,7 +1300,46 @@ func TestParseGlobalCfg(t *testing.T) {
 			input: `repos:
 - id: /.*/
   apply_requirements: [invalid]`,
-			expErr: "repos: (0: (apply_requirements: \"invalid\" is not a valid apply_requirement, only \"approved\", \"mergeable\" and \"undiverged\" are supported.).).",
+			expErr: "repos: (0: (apply_requirements: \"invalid\" is not a valid apply_requirement, only \"approved\", \"mergeable\", \"undiverged\", \"codeowners_approved\", \"approved_count\", \"all_plans_succeeded\", \"base_unchanged\", \"pipeline_succeeded\" and \"destroy_confirmed\" are supported.).).",
+		},
+		"invalid team_permissions command": {
+			input: `repos:
+- id: /.*/
+  team_permissions:
+    devs: [plan, destroy]`,
+			expErr: "repos: (0: (team_permissions: team \"devs\": \"destroy\" is not a valid command, only \"plan\", \"apply\", \"unlock\", \"approve_policies\", \"lock\", \"revert\" are supported.).).",
+		},
+		"team_permissions": {
+			input: `repos:
+- id: /.*/
+  team_permissions:
+    infra-admins: [plan, apply, unlock]
+    devs: [plan]`,
+			exp: valid.GlobalCfg{
+				Repos: []valid.Repo{
+					defaultCfg.Repos[0],
+					{
+						IDRegex: regexp.MustCompile(".*"),
+						TeamPermissions: map[string][]string{
+							"infra-admins": {"plan", "apply", "unlock"},
+							"devs":         {"plan"},
+						},
+					},
+				},
+				Workflows: defaultCfg.Workflows,
+			},
+		},
+		"terraform_versions": {
+			input: `terraform_versions: [0.14.11, v1.0.0]`,
+			exp: valid.GlobalCfg{
+				Repos:             defaultCfg.Repos,
+				Workflows:         defaultCfg.Workflows,
+				TerraformVersions: []*version.Version{version.Must(version.NewVersion("0.14.11")), version.Must(version.NewVersion("v1.0.0"))},
+			},
+		},
+		"invalid terraform_versions": {
+			input:  `terraform_versions: [latest]`,
+			expErr: "terraform_versions: version \"latest\" could not be parsed: Malformed version: latest",
 		},
 		"no workflows key": {
 			input: `repos: []`,
@@ -0,0 +1,48 @@
+package raw
+
+import (
+	"errors"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+)
+
+// DefaultApprovedCount is the number of approvals required by the
+// approved_count apply requirement if count isn't set.
+const DefaultApprovedCount = 1
+
+// Approvals configures the approved_count apply requirement.
+type Approvals struct {
+	Count          *int  `yaml:"count,omitempty" json:"count,omitempty"`
+	ExcludeAuthor  *bool `yaml:"exclude_author,omitempty" json:"exclude_author,omitempty"`
+	ExcludePrePlan *bool `yaml:"exclude_pre_plan,omitempty" json:"exclude_pre_plan,omitempty"`
+}
+
+func (a Approvals) Validate() error {
+	countValid := func(value interface{}) error {
+		count := value.(*int)
+		if count != nil && *count < 1 {
+			return errors.New("must be at least 1")
+		}
+		return nil
+	}
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.Count, validation.By(countValid)),
+	)
+}
+
+func (a Approvals) ToValid() valid.Approvals {
+	v := valid.Approvals{
+		Count: DefaultApprovedCount,
+	}
+	if a.Count != nil {
+		v.Count = *a.Count
+	}
+	if a.ExcludeAuthor != nil {
+		v.ExcludeAuthor = *a.ExcludeAuthor
+	}
+	if a.ExcludePrePlan != nil {
+		v.ExcludePrePlan = *a.ExcludePrePlan
+	}
+	return v
+}
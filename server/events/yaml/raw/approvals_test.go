@@ -0,0 +1,44 @@
+package raw_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/yaml/raw"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	. "github.com/runatlantis/atlantis/testing"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestApprovals_UnmarshalYAML(t *testing.T) {
+	var a raw.Approvals
+	err := yaml.UnmarshalStrict([]byte(`
+count: 2
+exclude_author: true
+exclude_pre_plan: false
+`), &a)
+	Ok(t, err)
+	Equals(t, raw.Approvals{
+		Count:          Int(2),
+		ExcludeAuthor:  Bool(true),
+		ExcludePrePlan: Bool(false),
+	}, a)
+}
+
+func TestApprovals_Validate(t *testing.T) {
+	Ok(t, raw.Approvals{}.Validate())
+	Ok(t, raw.Approvals{Count: Int(1)}.Validate())
+	ErrEquals(t, "count: must be at least 1.", raw.Approvals{Count: Int(0)}.Validate())
+}
+
+func TestApprovals_ToValid(t *testing.T) {
+	Equals(t, valid.Approvals{Count: 1}, raw.Approvals{}.ToValid())
+	Equals(t, valid.Approvals{
+		Count:          3,
+		ExcludeAuthor:  true,
+		ExcludePrePlan: true,
+	}, raw.Approvals{
+		Count:          Int(3),
+		ExcludeAuthor:  Bool(true),
+		ExcludePrePlan: Bool(true),
+	}.ToValid())
+}
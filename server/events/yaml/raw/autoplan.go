@@ -1,6 +1,11 @@
 package raw
 
 import (
+	"fmt"
+	"path"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/runatlantis/atlantis/server/events/yaml/valid"
 )
 
@@ -9,8 +14,9 @@ import (
 var DefaultAutoPlanWhenModified = []string{"**/*.tf*", "**/terragrunt.hcl"}
 
 type Autoplan struct {
-	WhenModified []string `yaml:"when_modified,omitempty"`
-	Enabled      *bool    `yaml:"enabled,omitempty"`
+	WhenModified []string          `yaml:"when_modified,omitempty"`
+	Enabled      *bool             `yaml:"enabled,omitempty"`
+	Branches     *AutoplanBranches `yaml:"branches,omitempty"`
 }
 
 func (a Autoplan) ToValid() valid.Autoplan {
@@ -27,11 +33,47 @@ func (a Autoplan) ToValid() valid.Autoplan {
 		v.Enabled = *a.Enabled
 	}
 
+	if a.Branches != nil {
+		v.Branches = a.Branches.ToValid()
+	}
+
 	return v
 }
 
 func (a Autoplan) Validate() error {
-	return nil
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.Branches),
+	)
+}
+
+// AutoplanBranches is the raw schema for the branches that autoplan is
+// restricted to, in the autoplan key of repo config and the autoplan_branches
+// key of server-side repo config.
+type AutoplanBranches struct {
+	Base []string `yaml:"base,omitempty" json:"base,omitempty"`
+	Head []string `yaml:"head,omitempty" json:"head,omitempty"`
+}
+
+func (a AutoplanBranches) Validate() error {
+	patternsValid := func(value interface{}) error {
+		for _, pattern := range value.([]string) {
+			if _, err := path.Match(strings.TrimPrefix(pattern, "!"), ""); err != nil {
+				return fmt.Errorf("%q is not a valid pattern: %s", pattern, err)
+			}
+		}
+		return nil
+	}
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.Base, validation.By(patternsValid)),
+		validation.Field(&a.Head, validation.By(patternsValid)),
+	)
+}
+
+func (a AutoplanBranches) ToValid() valid.AutoplanBranches {
+	return valid.AutoplanBranches{
+		Base: a.Base,
+		Head: a.Head,
+	}
 }
 
 // DefaultAutoPlan returns the default autoplan config.
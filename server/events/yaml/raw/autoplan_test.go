@@ -57,6 +57,20 @@ when_modified:
 				WhenModified: []string{""},
 			},
 		},
+		{
+			description: "branches set",
+			input: `
+branches:
+  base: ["main"]
+  head: ["!release/*"]
+`,
+			exp: raw.Autoplan{
+				Branches: &raw.AutoplanBranches{
+					Base: []string{"main"},
+					Head: []string{"!release/*"},
+				},
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -90,6 +104,15 @@ func TestAutoplan_Validate(t *testing.T) {
 				Enabled: Bool(false),
 			},
 		},
+		{
+			description: "branches set",
+			input: raw.Autoplan{
+				Branches: &raw.AutoplanBranches{
+					Base: []string{"main", "release/*"},
+					Head: []string{"!hotfix/*"},
+				},
+			},
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.description, func(t *testing.T) {
@@ -98,6 +121,15 @@ func TestAutoplan_Validate(t *testing.T) {
 	}
 }
 
+func TestAutoplan_ValidateInvalidBranches(t *testing.T) {
+	err := raw.Autoplan{
+		Branches: &raw.AutoplanBranches{
+			Head: []string{"!["},
+		},
+	}.Validate()
+	ErrEquals(t, "Branches: (head: \"![\" is not a valid pattern: syntax error in pattern.).", err)
+}
+
 func TestAutoplan_ToValid(t *testing.T) {
 	cases := []struct {
 		description string
@@ -142,6 +174,21 @@ func TestAutoplan_ToValid(t *testing.T) {
 				WhenModified: []string{"**/*.tf*", "**/terragrunt.hcl"},
 			},
 		},
+		{
+			description: "branches set",
+			input: raw.Autoplan{
+				Branches: &raw.AutoplanBranches{
+					Base: []string{"main"},
+				},
+			},
+			exp: valid.Autoplan{
+				Enabled:      true,
+				WhenModified: []string{"**/*.tf*", "**/terragrunt.hcl"},
+				Branches: valid.AutoplanBranches{
+					Base: []string{"main"},
+				},
+			},
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.description, func(t *testing.T) {
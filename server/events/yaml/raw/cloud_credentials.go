@@ -0,0 +1,125 @@
+package raw
+
+import (
+	"errors"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+)
+
+// AWS limits the duration of sessions from AssumeRoleWithWebIdentity.
+const (
+	minAWSSessionDuration = 15 * time.Minute
+	maxAWSSessionDuration = 12 * time.Hour
+)
+
+// CloudCredentials configures the short-lived cloud credentials that are
+// exchanged for an OIDC token before running each project's workflow.
+type CloudCredentials struct {
+	TokenFile string                 `yaml:"token_file,omitempty" json:"token_file,omitempty"`
+	AWS       *AWSCloudCredentials   `yaml:"aws,omitempty" json:"aws,omitempty"`
+	GCP       *GCPCloudCredentials   `yaml:"gcp,omitempty" json:"gcp,omitempty"`
+	Azure     *AzureCloudCredentials `yaml:"azure,omitempty" json:"azure,omitempty"`
+}
+
+type AWSCloudCredentials struct {
+	RoleARN         string `yaml:"role_arn" json:"role_arn"`
+	Region          string `yaml:"region,omitempty" json:"region,omitempty"`
+	SessionDuration string `yaml:"session_duration,omitempty" json:"session_duration,omitempty"`
+	Audience        string `yaml:"audience,omitempty" json:"audience,omitempty"`
+}
+
+type GCPCloudCredentials struct {
+	WorkloadIdentityProvider string `yaml:"workload_identity_provider" json:"workload_identity_provider"`
+	ServiceAccount           string `yaml:"service_account,omitempty" json:"service_account,omitempty"`
+	Audience                 string `yaml:"audience,omitempty" json:"audience,omitempty"`
+}
+
+type AzureCloudCredentials struct {
+	ClientID       string `yaml:"client_id" json:"client_id"`
+	TenantID       string `yaml:"tenant_id" json:"tenant_id"`
+	SubscriptionID string `yaml:"subscription_id,omitempty" json:"subscription_id,omitempty"`
+	Audience       string `yaml:"audience,omitempty" json:"audience,omitempty"`
+}
+
+func (c CloudCredentials) Validate() error {
+	oneCloud := func(value interface{}) error {
+		if c.AWS == nil && c.GCP == nil && c.Azure == nil {
+			return errors.New("at least one of aws, gcp or azure must be set")
+		}
+		return nil
+	}
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.TokenFile, validation.By(oneCloud)),
+		validation.Field(&c.AWS),
+		validation.Field(&c.GCP),
+		validation.Field(&c.Azure),
+	)
+}
+
+func (a AWSCloudCredentials) Validate() error {
+	durationValid := func(value interface{}) error {
+		s := value.(string)
+		if s == "" {
+			return nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		if d < minAWSSessionDuration || d > maxAWSSessionDuration {
+			return errors.New("must be between 15m and 12h")
+		}
+		return nil
+	}
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.RoleARN, validation.Required),
+		validation.Field(&a.SessionDuration, validation.By(durationValid)),
+	)
+}
+
+func (g GCPCloudCredentials) Validate() error {
+	return validation.ValidateStruct(&g,
+		validation.Field(&g.WorkloadIdentityProvider, validation.Required),
+	)
+}
+
+func (a AzureCloudCredentials) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.ClientID, validation.Required),
+		validation.Field(&a.TenantID, validation.Required),
+	)
+}
+
+func (c CloudCredentials) ToValid() valid.CloudCredentials {
+	v := valid.CloudCredentials{
+		TokenFile: c.TokenFile,
+	}
+	if c.AWS != nil {
+		// Validated already so the error can be ignored.
+		duration, _ := time.ParseDuration(c.AWS.SessionDuration)
+		v.AWS = &valid.AWSCloudCredentials{
+			RoleARN:         c.AWS.RoleARN,
+			Region:          c.AWS.Region,
+			SessionDuration: duration,
+			Audience:        c.AWS.Audience,
+		}
+	}
+	if c.GCP != nil {
+		v.GCP = &valid.GCPCloudCredentials{
+			WorkloadIdentityProvider: c.GCP.WorkloadIdentityProvider,
+			ServiceAccount:           c.GCP.ServiceAccount,
+			Audience:                 c.GCP.Audience,
+		}
+	}
+	if c.Azure != nil {
+		v.Azure = &valid.AzureCloudCredentials{
+			ClientID:       c.Azure.ClientID,
+			TenantID:       c.Azure.TenantID,
+			SubscriptionID: c.Azure.SubscriptionID,
+			Audience:       c.Azure.Audience,
+		}
+	}
+	return v
+}
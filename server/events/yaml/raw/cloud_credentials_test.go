@@ -0,0 +1,89 @@
+package raw_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/yaml/raw"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	. "github.com/runatlantis/atlantis/testing"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestCloudCredentials_UnmarshalYAML(t *testing.T) {
+	var c raw.CloudCredentials
+	err := yaml.UnmarshalStrict([]byte(`
+token_file: /var/run/secrets/token
+aws:
+  role_arn: arn:aws:iam::123456789012:role/atlantis
+  region: us-east-1
+  session_duration: 30m
+gcp:
+  workload_identity_provider: projects/123/locations/global/workloadIdentityPools/pool/providers/atlantis
+  service_account: tf@proj.iam.gserviceaccount.com
+azure:
+  client_id: client
+  tenant_id: tenant
+  subscription_id: sub
+  audience: api://custom
+`), &c)
+	Ok(t, err)
+	Ok(t, c.Validate())
+	Equals(t, valid.CloudCredentials{
+		TokenFile: "/var/run/secrets/token",
+		AWS: &valid.AWSCloudCredentials{
+			RoleARN:         "arn:aws:iam::123456789012:role/atlantis",
+			Region:          "us-east-1",
+			SessionDuration: 30 * time.Minute,
+		},
+		GCP: &valid.GCPCloudCredentials{
+			WorkloadIdentityProvider: "projects/123/locations/global/workloadIdentityPools/pool/providers/atlantis",
+			ServiceAccount:           "tf@proj.iam.gserviceaccount.com",
+		},
+		Azure: &valid.AzureCloudCredentials{
+			ClientID:       "client",
+			TenantID:       "tenant",
+			SubscriptionID: "sub",
+			Audience:       "api://custom",
+		},
+	}, c.ToValid())
+}
+
+func TestCloudCredentials_Validate(t *testing.T) {
+	cases := []struct {
+		description string
+		input       raw.CloudCredentials
+		expErr      string
+	}{
+		{
+			description: "no clouds",
+			input:       raw.CloudCredentials{TokenFile: "/token"},
+			expErr:      "token_file: at least one of aws, gcp or azure must be set.",
+		},
+		{
+			description: "aws without role",
+			input:       raw.CloudCredentials{AWS: &raw.AWSCloudCredentials{}},
+			expErr:      "aws: (role_arn: cannot be blank.).",
+		},
+		{
+			description: "aws invalid duration",
+			input:       raw.CloudCredentials{AWS: &raw.AWSCloudCredentials{RoleARN: "arn", SessionDuration: "1m"}},
+			expErr:      "aws: (session_duration: must be between 15m and 12h.).",
+		},
+		{
+			description: "gcp without provider",
+			input:       raw.CloudCredentials{GCP: &raw.GCPCloudCredentials{}},
+			expErr:      "gcp: (workload_identity_provider: cannot be blank.).",
+		},
+		{
+			description: "azure without tenant",
+			input:       raw.CloudCredentials{Azure: &raw.AzureCloudCredentials{ClientID: "client"}},
+			expErr:      "azure: (tenant_id: cannot be blank.).",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			ErrEquals(t, c.expErr, c.input.Validate())
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package raw
+
+import (
+	"fmt"
+	"path"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+)
+
+// Denylist is the raw schema for the denylist key in the server-side repo
+// config.
+type Denylist struct {
+	Providers    []string `yaml:"providers,omitempty" json:"providers,omitempty"`
+	Resources    []string `yaml:"resources,omitempty" json:"resources,omitempty"`
+	Provisioners []string `yaml:"provisioners,omitempty" json:"provisioners,omitempty"`
+}
+
+func (d Denylist) Validate() error {
+	patternsValid := func(value interface{}) error {
+		for _, pattern := range value.([]string) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("%q is not a valid pattern: %s", pattern, err)
+			}
+		}
+		return nil
+	}
+	return validation.ValidateStruct(&d,
+		validation.Field(&d.Providers, validation.By(patternsValid)),
+		validation.Field(&d.Resources, validation.By(patternsValid)),
+		validation.Field(&d.Provisioners, validation.By(patternsValid)),
+	)
+}
+
+func (d Denylist) ToValid() valid.Denylist {
+	return valid.Denylist{
+		Providers:    d.Providers,
+		Resources:    d.Resources,
+		Provisioners: d.Provisioners,
+	}
+}
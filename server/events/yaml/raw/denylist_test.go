@@ -0,0 +1,44 @@
+package raw_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/yaml/raw"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	. "github.com/runatlantis/atlantis/testing"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestDenylist_UnmarshalYAML(t *testing.T) {
+	var d raw.Denylist
+	err := yaml.UnmarshalStrict([]byte(`
+providers: [hashicorp/external]
+resources: ["aws_iam_*"]
+provisioners: [local-exec, remote-exec]
+`), &d)
+	Ok(t, err)
+	Equals(t, raw.Denylist{
+		Providers:    []string{"hashicorp/external"},
+		Resources:    []string{"aws_iam_*"},
+		Provisioners: []string{"local-exec", "remote-exec"},
+	}, d)
+}
+
+func TestDenylist_Validate(t *testing.T) {
+	Ok(t, raw.Denylist{}.Validate())
+	Ok(t, raw.Denylist{Resources: []string{"aws_*", "google_?_instance"}}.Validate())
+	ErrEquals(t, "resources: \"aws_[\" is not a valid pattern: syntax error in pattern.", raw.Denylist{Resources: []string{"aws_["}}.Validate())
+}
+
+func TestDenylist_ToValid(t *testing.T) {
+	Equals(t, valid.Denylist{}, raw.Denylist{}.ToValid())
+	Equals(t, valid.Denylist{
+		Providers:    []string{"hashicorp/external"},
+		Resources:    []string{"aws_iam_*"},
+		Provisioners: []string{"local-exec"},
+	}, raw.Denylist{
+		Providers:    []string{"hashicorp/external"},
+		Resources:    []string{"aws_iam_*"},
+		Provisioners: []string{"local-exec"},
+	}.ToValid())
+}
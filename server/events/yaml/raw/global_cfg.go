@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	validation "github.com/go-ozzo/ozzo-validation"
+	version "github.com/hashicorp/go-version"
 	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/events/yaml/valid"
 )
@@ -15,19 +16,30 @@ type GlobalCfg struct {
 	Repos      []Repo              `yaml:"repos" json:"repos"`
 	Workflows  map[string]Workflow `yaml:"workflows" json:"workflows"`
 	PolicySets PolicySets          `yaml:"policies" json:"policies"`
+	// TerraformVersions are downloaded on startup and whenever the config is
+	// reloaded.
+	TerraformVersions []string `yaml:"terraform_versions,omitempty" json:"terraform_versions,omitempty"`
 }
 
 // Repo is the raw schema for repos in the server-side repo config.
 type Repo struct {
-	ID                        string            `yaml:"id" json:"id"`
-	Branch                    string            `yaml:"branch" json:"branch"`
-	ApplyRequirements         []string          `yaml:"apply_requirements" json:"apply_requirements"`
-	PreWorkflowHooks          []PreWorkflowHook `yaml:"pre_workflow_hooks" json:"pre_workflow_hooks"`
-	Workflow                  *string           `yaml:"workflow,omitempty" json:"workflow,omitempty"`
-	AllowedWorkflows          []string          `yaml:"allowed_workflows,omitempty" json:"allowed_workflows,omitempty"`
-	AllowedOverrides          []string          `yaml:"allowed_overrides" json:"allowed_overrides"`
-	AllowCustomWorkflows      *bool             `yaml:"allow_custom_workflows,omitempty" json:"allow_custom_workflows,omitempty"`
-	DeleteSourceBranchOnMerge *bool             `yaml:"delete_source_branch_on_merge,omitempty" json:"delete_source_branch_on_merge,omitempty"`
+	ID                        string              `yaml:"id" json:"id"`
+	Branch                    string              `yaml:"branch" json:"branch"`
+	ApplyRequirements         []string            `yaml:"apply_requirements" json:"apply_requirements"`
+	PreWorkflowHooks          []PreWorkflowHook   `yaml:"pre_workflow_hooks" json:"pre_workflow_hooks"`
+	Workflow                  *string             `yaml:"workflow,omitempty" json:"workflow,omitempty"`
+	AllowedWorkflows          []string            `yaml:"allowed_workflows,omitempty" json:"allowed_workflows,omitempty"`
+	AllowedOverrides          []string            `yaml:"allowed_overrides" json:"allowed_overrides"`
+	AllowCustomWorkflows      *bool               `yaml:"allow_custom_workflows,omitempty" json:"allow_custom_workflows,omitempty"`
+	DeleteSourceBranchOnMerge *bool               `yaml:"delete_source_branch_on_merge,omitempty" json:"delete_source_branch_on_merge,omitempty"`
+	TeamPermissions           map[string][]string `yaml:"team_permissions,omitempty" json:"team_permissions,omitempty"`
+	Approvals                 *Approvals          `yaml:"approvals,omitempty" json:"approvals,omitempty"`
+	Pipeline                  *Pipeline           `yaml:"pipeline,omitempty" json:"pipeline,omitempty"`
+	Denylist                  *Denylist           `yaml:"denylist,omitempty" json:"denylist,omitempty"`
+	Lint                      *Lint               `yaml:"lint,omitempty" json:"lint,omitempty"`
+	VerifyLockfile            *bool               `yaml:"verify_lockfile,omitempty" json:"verify_lockfile,omitempty"`
+	CloudCredentials          *CloudCredentials   `yaml:"cloud_credentials,omitempty" json:"cloud_credentials,omitempty"`
+	AutoplanBranches          *AutoplanBranches   `yaml:"autoplan_branches,omitempty" json:"autoplan_branches,omitempty"`
 }
 
 func (g GlobalCfg) Validate() error {
@@ -37,6 +49,11 @@ func (g GlobalCfg) Validate() error {
 	if err != nil {
 		return err
 	}
+	for _, v := range g.TerraformVersions {
+		if _, err := version.NewVersion(v); err != nil {
+			return errors.Wrapf(err, "terraform_versions: version %q could not be parsed", v)
+		}
+	}
 
 	// Check that all workflows referenced by repos are actually defined.
 	for _, repo := range g.Repos {
@@ -124,10 +141,17 @@ func (g GlobalCfg) ToValid(defaultCfg valid.GlobalCfg) valid.GlobalCfg {
 	}
 	repos = append(defaultCfg.Repos, repos...)
 
+	var terraformVersions []*version.Version
+	for _, v := range g.TerraformVersions {
+		parsed, _ := version.NewVersion(v)
+		terraformVersions = append(terraformVersions, parsed)
+	}
+
 	return valid.GlobalCfg{
-		Repos:      repos,
-		Workflows:  workflows,
-		PolicySets: g.PolicySets.ToValid(),
+		Repos:             repos,
+		Workflows:         workflows,
+		PolicySets:        g.PolicySets.ToValid(),
+		TerraformVersions: terraformVersions,
 	}
 }
 
@@ -182,6 +206,18 @@ func (r Repo) Validate() error {
 		return nil
 	}
 
+	teamPermissionsValid := func(value interface{}) error {
+		permissions := value.(map[string][]string)
+		for team, cmds := range permissions {
+			for _, cmd := range cmds {
+				if !validTeamCommand(cmd) {
+					return fmt.Errorf("team %q: %q is not a valid command, only %s are supported", team, cmd, strings.Join(quoteAll(valid.TeamPermissionCommands), ", "))
+				}
+			}
+		}
+		return nil
+	}
+
 	return validation.ValidateStruct(&r,
 		validation.Field(&r.ID, validation.Required, validation.By(idValid)),
 		validation.Field(&r.Branch, validation.By(branchValid)),
@@ -189,9 +225,33 @@ func (r Repo) Validate() error {
 		validation.Field(&r.ApplyRequirements, validation.By(validApplyReq)),
 		validation.Field(&r.Workflow, validation.By(workflowExists)),
 		validation.Field(&r.DeleteSourceBranchOnMerge, validation.By(deleteSourceBranchOnMergeValid)),
+		validation.Field(&r.TeamPermissions, validation.By(teamPermissionsValid)),
+		validation.Field(&r.Approvals),
+		validation.Field(&r.Pipeline),
+		validation.Field(&r.Denylist),
+		validation.Field(&r.Lint),
+		validation.Field(&r.CloudCredentials),
+		validation.Field(&r.AutoplanBranches),
 	)
 }
 
+func validTeamCommand(cmd string) bool {
+	for _, c := range valid.TeamPermissionCommands {
+		if c == cmd {
+			return true
+		}
+	}
+	return false
+}
+
+func quoteAll(strs []string) []string {
+	var quoted []string
+	for _, s := range strs {
+		quoted = append(quoted, fmt.Sprintf("%q", s))
+	}
+	return quoted
+}
+
 func (r Repo) ToValid(workflows map[string]valid.Workflow, globalApplyReqs []string) valid.Repo {
 	var id string
 	var idRegex *regexp.Regexp
@@ -240,6 +300,42 @@ OUTER:
 		mergedApplyReqs = append(mergedApplyReqs, globalReq)
 	}
 
+	var approvals *valid.Approvals
+	if r.Approvals != nil {
+		v := r.Approvals.ToValid()
+		approvals = &v
+	}
+
+	var pipeline *valid.Pipeline
+	if r.Pipeline != nil {
+		v := r.Pipeline.ToValid()
+		pipeline = &v
+	}
+
+	var denylist *valid.Denylist
+	if r.Denylist != nil {
+		v := r.Denylist.ToValid()
+		denylist = &v
+	}
+
+	var lint *valid.Lint
+	if r.Lint != nil {
+		v := r.Lint.ToValid()
+		lint = &v
+	}
+
+	var cloudCredentials *valid.CloudCredentials
+	if r.CloudCredentials != nil {
+		v := r.CloudCredentials.ToValid()
+		cloudCredentials = &v
+	}
+
+	var autoplanBranches *valid.AutoplanBranches
+	if r.AutoplanBranches != nil {
+		v := r.AutoplanBranches.ToValid()
+		autoplanBranches = &v
+	}
+
 	return valid.Repo{
 		ID:                        id,
 		IDRegex:                   idRegex,
@@ -251,5 +347,13 @@ OUTER:
 		AllowedOverrides:          r.AllowedOverrides,
 		AllowCustomWorkflows:      r.AllowCustomWorkflows,
 		DeleteSourceBranchOnMerge: r.DeleteSourceBranchOnMerge,
+		TeamPermissions:           r.TeamPermissions,
+		Approvals:                 approvals,
+		Pipeline:                  pipeline,
+		Denylist:                  denylist,
+		Lint:                      lint,
+		VerifyLockfile:            r.VerifyLockfile,
+		CloudCredentials:          cloudCredentials,
+		AutoplanBranches:          autoplanBranches,
 	}
 }
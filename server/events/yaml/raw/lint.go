@@ -0,0 +1,39 @@
+package raw
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+)
+
+// Lint is the raw schema for the lint key in the server-side repo config.
+type Lint struct {
+	Config   string `yaml:"config,omitempty" json:"config,omitempty"`
+	Blocking bool   `yaml:"blocking,omitempty" json:"blocking,omitempty"`
+}
+
+func (l Lint) Validate() error {
+	configValid := func(value interface{}) error {
+		config := value.(string)
+		if config == "" || filepath.IsAbs(config) {
+			return nil
+		}
+		if clean := filepath.Clean(config); clean == ".." || strings.HasPrefix(clean, "../") {
+			return errors.New("relative paths can't be outside of the repo")
+		}
+		return nil
+	}
+	return validation.ValidateStruct(&l,
+		validation.Field(&l.Config, validation.By(configValid)),
+	)
+}
+
+func (l Lint) ToValid() valid.Lint {
+	return valid.Lint{
+		Config:   l.Config,
+		Blocking: l.Blocking,
+	}
+}
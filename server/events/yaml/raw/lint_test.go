@@ -0,0 +1,41 @@
+package raw_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/yaml/raw"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	. "github.com/runatlantis/atlantis/testing"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestLint_UnmarshalYAML(t *testing.T) {
+	var l raw.Lint
+	err := yaml.UnmarshalStrict([]byte(`
+config: lint/.tflint.hcl
+blocking: true
+`), &l)
+	Ok(t, err)
+	Equals(t, raw.Lint{
+		Config:   "lint/.tflint.hcl",
+		Blocking: true,
+	}, l)
+}
+
+func TestLint_Validate(t *testing.T) {
+	Ok(t, raw.Lint{}.Validate())
+	Ok(t, raw.Lint{Config: ".tflint.hcl"}.Validate())
+	Ok(t, raw.Lint{Config: "/etc/atlantis/.tflint.hcl"}.Validate())
+	ErrEquals(t, "config: relative paths can't be outside of the repo.", raw.Lint{Config: "../.tflint.hcl"}.Validate())
+}
+
+func TestLint_ToValid(t *testing.T) {
+	Equals(t, valid.Lint{}, raw.Lint{}.ToValid())
+	Equals(t, valid.Lint{
+		Config:   ".tflint.hcl",
+		Blocking: true,
+	}, raw.Lint{
+		Config:   ".tflint.hcl",
+		Blocking: true,
+	}.ToValid())
+}
@@ -0,0 +1,43 @@
+package raw
+
+import (
+	"fmt"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+)
+
+// PipelineJobStatuses are the statuses of GitLab CI jobs.
+var PipelineJobStatuses = []string{"created", "waiting_for_resource", "preparing", "pending", "running", "success", "failed", "canceled", "skipped", "manual", "scheduled"}
+
+// Pipeline configures the pipeline_succeeded apply requirement.
+type Pipeline struct {
+	AllowedStatuses []string `yaml:"allowed_statuses,omitempty" json:"allowed_statuses,omitempty"`
+	RequiredJobs    []string `yaml:"required_jobs,omitempty" json:"required_jobs,omitempty"`
+}
+
+func (p Pipeline) Validate() error {
+	statusesValid := func(value interface{}) error {
+	OUTER:
+		for _, status := range value.([]string) {
+			for _, s := range PipelineJobStatuses {
+				if s == status {
+					continue OUTER
+				}
+			}
+			return fmt.Errorf("%q is not a valid status, only %s are supported", status, strings.Join(quoteAll(PipelineJobStatuses), ", "))
+		}
+		return nil
+	}
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.AllowedStatuses, validation.By(statusesValid)),
+	)
+}
+
+func (p Pipeline) ToValid() valid.Pipeline {
+	return valid.Pipeline{
+		AllowedStatuses: p.AllowedStatuses,
+		RequiredJobs:    p.RequiredJobs,
+	}
+}
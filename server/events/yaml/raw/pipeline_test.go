@@ -0,0 +1,41 @@
+package raw_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/yaml/raw"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	. "github.com/runatlantis/atlantis/testing"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestPipeline_UnmarshalYAML(t *testing.T) {
+	var p raw.Pipeline
+	err := yaml.UnmarshalStrict([]byte(`
+allowed_statuses: [success, manual]
+required_jobs: [test]
+`), &p)
+	Ok(t, err)
+	Equals(t, raw.Pipeline{
+		AllowedStatuses: []string{"success", "manual"},
+		RequiredJobs:    []string{"test"},
+	}, p)
+}
+
+func TestPipeline_Validate(t *testing.T) {
+	Ok(t, raw.Pipeline{}.Validate())
+	Ok(t, raw.Pipeline{AllowedStatuses: []string{"success", "skipped"}}.Validate())
+	ErrEquals(t, `allowed_statuses: "passed" is not a valid status, only "created", "waiting_for_resource", "preparing", "pending", "running", "success", "failed", "canceled", "skipped", "manual", "scheduled" are supported.`,
+		raw.Pipeline{AllowedStatuses: []string{"success", "passed"}}.Validate())
+}
+
+func TestPipeline_ToValid(t *testing.T) {
+	Equals(t, valid.Pipeline{}, raw.Pipeline{}.ToValid())
+	Equals(t, valid.Pipeline{
+		AllowedStatuses: []string{"success"},
+		RequiredJobs:    []string{"test"},
+	}, raw.Pipeline{
+		AllowedStatuses: []string{"success"},
+		RequiredJobs:    []string{"test"},
+	}.ToValid())
+}
@@ -13,21 +13,30 @@ import (
 )
 
 const (
-	DefaultWorkspace           = "default"
-	ApprovedApplyRequirement   = "approved"
-	MergeableApplyRequirement  = "mergeable"
-	UnDivergedApplyRequirement = "undiverged"
+	DefaultWorkspace             = "default"
+	ApprovedApplyRequirement     = "approved"
+	MergeableApplyRequirement    = "mergeable"
+	UnDivergedApplyRequirement   = "undiverged"
+	CodeOwnersApplyRequirement   = "codeowners_approved"
+	ApprovedCountRequirement     = "approved_count"
+	AllPlansSucceededRequirement = "all_plans_succeeded"
+	BaseUnchangedRequirement     = "base_unchanged"
+	PipelineSucceededRequirement = "pipeline_succeeded"
+	DestroyConfirmedRequirement  = "destroy_confirmed"
 )
 
 type Project struct {
-	Name                      *string   `yaml:"name,omitempty"`
-	Dir                       *string   `yaml:"dir,omitempty"`
-	Workspace                 *string   `yaml:"workspace,omitempty"`
-	Workflow                  *string   `yaml:"workflow,omitempty"`
-	TerraformVersion          *string   `yaml:"terraform_version,omitempty"`
-	Autoplan                  *Autoplan `yaml:"autoplan,omitempty"`
-	ApplyRequirements         []string  `yaml:"apply_requirements,omitempty"`
-	DeleteSourceBranchOnMerge *bool     `yaml:"delete_source_branch_on_merge,omitempty"`
+	Name                      *string    `yaml:"name,omitempty"`
+	Dir                       *string    `yaml:"dir,omitempty"`
+	Workspace                 *string    `yaml:"workspace,omitempty"`
+	Workflow                  *string    `yaml:"workflow,omitempty"`
+	TerraformVersion          *string    `yaml:"terraform_version,omitempty"`
+	Autoplan                  *Autoplan  `yaml:"autoplan,omitempty"`
+	ApplyRequirements         []string   `yaml:"apply_requirements,omitempty"`
+	DeleteSourceBranchOnMerge *bool      `yaml:"delete_source_branch_on_merge,omitempty"`
+	Approvals                 *Approvals `yaml:"approvals,omitempty"`
+	Pipeline                  *Pipeline  `yaml:"pipeline,omitempty"`
+	DependsOn                 []string   `yaml:"depends_on,omitempty"`
 }
 
 func (p Project) Validate() error {
@@ -56,6 +65,9 @@ func (p Project) Validate() error {
 		validation.Field(&p.ApplyRequirements, validation.By(validApplyReq)),
 		validation.Field(&p.TerraformVersion, validation.By(VersionValidator)),
 		validation.Field(&p.Name, validation.By(validName)),
+		validation.Field(&p.Approvals),
+		validation.Field(&p.Pipeline),
+		validation.Field(&p.Autoplan),
 	)
 }
 
@@ -85,8 +97,17 @@ func (p Project) ToValid() valid.Project {
 
 	// There are no default apply requirements.
 	v.ApplyRequirements = p.ApplyRequirements
+	if p.Approvals != nil {
+		approvals := p.Approvals.ToValid()
+		v.Approvals = &approvals
+	}
+	if p.Pipeline != nil {
+		pipeline := p.Pipeline.ToValid()
+		v.Pipeline = &pipeline
+	}
 
 	v.Name = p.Name
+	v.DependsOn = p.DependsOn
 
 	if p.DeleteSourceBranchOnMerge != nil {
 		v.DeleteSourceBranchOnMerge = p.DeleteSourceBranchOnMerge
@@ -107,8 +128,8 @@ func validProjectName(name string) bool {
 func validApplyReq(value interface{}) error {
 	reqs := value.([]string)
 	for _, r := range reqs {
-		if r != ApprovedApplyRequirement && r != MergeableApplyRequirement && r != UnDivergedApplyRequirement {
-			return fmt.Errorf("%q is not a valid apply_requirement, only %q, %q and %q are supported", r, ApprovedApplyRequirement, MergeableApplyRequirement, UnDivergedApplyRequirement)
+		if r != ApprovedApplyRequirement && r != MergeableApplyRequirement && r != UnDivergedApplyRequirement && r != CodeOwnersApplyRequirement && r != ApprovedCountRequirement && r != AllPlansSucceededRequirement && r != BaseUnchangedRequirement && r != PipelineSucceededRequirement && r != DestroyConfirmedRequirement {
+			return fmt.Errorf("%q is not a valid apply_requirement, only %q, %q, %q, %q, %q, %q, %q, %q and %q are supported", r, ApprovedApplyRequirement, MergeableApplyRequirement, UnDivergedApplyRequirement, CodeOwnersApplyRequirement, ApprovedCountRequirement, AllPlansSucceededRequirement, BaseUnchangedRequirement, PipelineSucceededRequirement, DestroyConfirmedRequirement)
 		}
 	}
 	return nil
@@ -101,7 +101,7 @@ func TestProject_Validate(t *testing.T) {
 				Dir:               String("."),
 				ApplyRequirements: []string{"unsupported"},
 			},
-			expErr: "apply_requirements: \"unsupported\" is not a valid apply_requirement, only \"approved\", \"mergeable\" and \"undiverged\" are supported.",
+			expErr: "apply_requirements: \"unsupported\" is not a valid apply_requirement, only \"approved\", \"mergeable\", \"undiverged\", \"codeowners_approved\", \"approved_count\", \"all_plans_succeeded\", \"base_unchanged\", \"pipeline_succeeded\" and \"destroy_confirmed\" are supported.",
 		},
 		{
 			description: "apply reqs with approved requirement",
@@ -16,6 +16,7 @@ const (
 	NameArgKey          = "name"
 	CommandArgKey       = "command"
 	ValueArgKey         = "value"
+	VaultArgKey         = "vault"
 	RunStepName         = "run"
 	PlanStepName        = "plan"
 	ShowStepName        = "show"
@@ -30,11 +31,12 @@ const (
 //    - init
 //    - plan
 //    - policy_check
-// 2. A map for an env step with name and command or value
+// 2. A map for an env step with name and command, value or vault
 //    - env:
 //        name: test
 //        command: echo 312
 //        value: value
+//        vault: secret/data/test#key
 // 3. A map for a built-in command and extra_args:
 //    - plan:
 //        extra_args: [-var-file=staging.tfvars]
@@ -158,8 +160,8 @@ func (s Step) Validate() error {
 
 			foundNameKey := false
 			for _, k := range argKeys {
-				if k != NameArgKey && k != CommandArgKey && k != ValueArgKey {
-					return fmt.Errorf("env steps only support keys %q, %q and %q, found key %q", NameArgKey, ValueArgKey, CommandArgKey, k)
+				if k != NameArgKey && k != CommandArgKey && k != ValueArgKey && k != VaultArgKey {
+					return fmt.Errorf("env steps only support keys %q, %q, %q and %q, found key %q", NameArgKey, ValueArgKey, CommandArgKey, VaultArgKey, k)
 				}
 				if k == NameArgKey {
 					foundNameKey = true
@@ -168,10 +170,16 @@ func (s Step) Validate() error {
 			if !foundNameKey {
 				return fmt.Errorf("env steps must have a %q key set", NameArgKey)
 			}
-			// If we have 3 keys at this point then they've set both command and value.
+			// If we have more than 2 keys at this point then they've set more
+			// than one of command, value and vault.
 			if len(argKeys) != 2 {
-				return fmt.Errorf("env steps only support one of the %q or %q keys, found both",
-					ValueArgKey, CommandArgKey)
+				return fmt.Errorf("env steps only support one of the %q, %q or %q keys, found %d",
+					ValueArgKey, CommandArgKey, VaultArgKey, len(argKeys)-1)
+			}
+			if ref, ok := args[VaultArgKey]; ok {
+				if _, _, err := parseVaultRef(ref); err != nil {
+					return err
+				}
 			}
 		}
 		return nil
@@ -226,12 +234,16 @@ func (s Step) ToValid() valid.Step {
 		// After validation we assume there's only one key and it's a valid
 		// step name so we just use the first one.
 		for stepName, stepArgs := range s.Env {
-			return valid.Step{
+			step := valid.Step{
 				StepName:    stepName,
 				EnvVarName:  stepArgs[NameArgKey],
 				RunCommand:  stepArgs[CommandArgKey],
 				EnvVarValue: stepArgs[ValueArgKey],
 			}
+			if ref, ok := stepArgs[VaultArgKey]; ok {
+				step.VaultPath, step.VaultKey, _ = parseVaultRef(ref)
+			}
+			return step
 		}
 	}
 
@@ -301,6 +313,7 @@ func (s *Step) unmarshalGeneric(unmarshal func(interface{}) error) error {
 	//     name: k
 	//     value: hi //optional
 	//     command: exec
+	//     vault: secret/data/k#key //optional
 	var envStep map[string]map[string]string
 	err = unmarshal(&envStep)
 	if err == nil {
@@ -337,3 +350,12 @@ func (s Step) marshalGeneric() (interface{}, error) {
 	// unexpected behavior.
 	return nil, nil
 }
+
+// parseVaultRef splits a Vault secret reference of the form "path#key".
+func parseVaultRef(ref string) (string, string, error) {
+	i := strings.LastIndex(ref, "#")
+	if i <= 0 || i == len(ref)-1 {
+		return "", "", fmt.Errorf("%q key must be of the form path#key, found %q", VaultArgKey, ref)
+	}
+	return ref[:i], ref[i+1:], nil
+}
@@ -371,7 +371,7 @@ func TestStep_Validate(t *testing.T) {
 					},
 				},
 			},
-			expErr: "env steps only support keys \"name\", \"value\" and \"command\", found key \"abc\"",
+			expErr: "env steps only support keys \"name\", \"value\", \"command\" and \"vault\", found key \"abc\"",
 		},
 		{
 			description: "env step with both command and value set",
@@ -384,7 +384,43 @@ func TestStep_Validate(t *testing.T) {
 					},
 				},
 			},
-			expErr: "env steps only support one of the \"value\" or \"command\" keys, found both",
+			expErr: "env steps only support one of the \"value\", \"command\" or \"vault\" keys, found 2",
+		},
+		{
+			description: "env step with both vault and value set",
+			input: raw.Step{
+				Env: EnvType{
+					"env": {
+						"name":  "name",
+						"vault": "secret/data/db#password",
+						"value": "value",
+					},
+				},
+			},
+			expErr: "env steps only support one of the \"value\", \"command\" or \"vault\" keys, found 2",
+		},
+		{
+			description: "env step with vault ref missing key",
+			input: raw.Step{
+				Env: EnvType{
+					"env": {
+						"name":  "name",
+						"vault": "secret/data/db",
+					},
+				},
+			},
+			expErr: "\"vault\" key must be of the form path#key, found \"secret/data/db\"",
+		},
+		{
+			description: "env step with vault ref",
+			input: raw.Step{
+				Env: EnvType{
+					"env": {
+						"name":  "name",
+						"vault": "secret/data/db#password",
+					},
+				},
+			},
 		},
 		{
 			// For atlantis.yaml v2, this wouldn't parse, but now there should
@@ -467,6 +503,23 @@ func TestStep_ToValid(t *testing.T) {
 				EnvVarName: "test",
 			},
 		},
+		{
+			description: "env step with vault",
+			input: raw.Step{
+				Env: EnvType{
+					"env": {
+						"name":  "test",
+						"vault": "secret/data/db#password",
+					},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "env",
+				EnvVarName: "test",
+				VaultPath:  "secret/data/db",
+				VaultKey:   "password",
+			},
+		},
 		{
 			description: "init extra_args",
 			input: raw.Step{
@@ -6,9 +6,10 @@ import (
 )
 
 type Workflow struct {
-	Apply       *Stage `yaml:"apply,omitempty" json:"apply,omitempty"`
-	Plan        *Stage `yaml:"plan,omitempty" json:"plan,omitempty"`
-	PolicyCheck *Stage `yaml:"policy_check,omitempty" json:"policy_check,omitempty"`
+	Apply          *Stage `yaml:"apply,omitempty" json:"apply,omitempty"`
+	Plan           *Stage `yaml:"plan,omitempty" json:"plan,omitempty"`
+	PolicyCheck    *Stage `yaml:"policy_check,omitempty" json:"policy_check,omitempty"`
+	ContainerImage string `yaml:"container_image,omitempty" json:"container_image,omitempty"`
 }
 
 func (w Workflow) Validate() error {
@@ -29,7 +30,8 @@ func (w Workflow) toValidStage(stage *Stage, defaultStage valid.Stage) valid.Sta
 
 func (w Workflow) ToValid(name string) valid.Workflow {
 	v := valid.Workflow{
-		Name: name,
+		Name:           name,
+		ContainerImage: w.ContainerImage,
 	}
 
 	v.Apply = w.toValidStage(w.Apply, valid.DefaultApplyStage)
@@ -35,6 +35,13 @@ func TestWorkflow_UnmarshalYAML(t *testing.T) {
 				Plan:        nil,
 			},
 		},
+		{
+			description: "container image set",
+			input:       `container_image: hashicorp/terraform:light`,
+			exp: raw.Workflow{
+				ContainerImage: "hashicorp/terraform:light",
+			},
+		},
 		{
 			description: "only plan/apply set",
 			input: `
@@ -199,6 +206,18 @@ func TestWorkflow_ToValid(t *testing.T) {
 				},
 			},
 		},
+		{
+			description: "container image set",
+			input: raw.Workflow{
+				ContainerImage: "hashicorp/terraform:light",
+			},
+			exp: valid.Workflow{
+				Apply:          valid.DefaultApplyStage,
+				Plan:           valid.DefaultPlanStage,
+				PolicyCheck:    valid.DefaultPolicyCheckStage,
+				ContainerImage: "hashicorp/terraform:light",
+			},
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.description, func(t *testing.T) {
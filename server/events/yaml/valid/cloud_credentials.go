@@ -0,0 +1,55 @@
+package valid
+
+import "time"
+
+// CloudCredentials configures the short-lived cloud credentials that are
+// exchanged for an OIDC token before running each project's workflow.
+type CloudCredentials struct {
+	// TokenFile is the path to an ambient workload identity token, ex. a
+	// Kubernetes service account token. If empty, Atlantis issues the token.
+	TokenFile string
+	AWS       *AWSCloudCredentials
+	GCP       *GCPCloudCredentials
+	Azure     *AzureCloudCredentials
+}
+
+// Empty returns true if no credentials are configured.
+func (c CloudCredentials) Empty() bool {
+	return c.AWS == nil && c.GCP == nil && c.Azure == nil
+}
+
+// AWSCloudCredentials configures assuming an IAM role with
+// AssumeRoleWithWebIdentity.
+type AWSCloudCredentials struct {
+	RoleARN string
+	// Region is set as AWS_REGION if not empty.
+	Region string
+	// SessionDuration is how long the credentials are valid for. If zero,
+	// AWS's default of one hour is used.
+	SessionDuration time.Duration
+	// Audience is the token's audience. If empty, sts.amazonaws.com is used.
+	Audience string
+}
+
+// GCPCloudCredentials configures workload identity federation.
+type GCPCloudCredentials struct {
+	// WorkloadIdentityProvider is the full name of the provider, ex.
+	// projects/123/locations/global/workloadIdentityPools/pool/providers/atlantis.
+	WorkloadIdentityProvider string
+	// ServiceAccount is impersonated if not empty.
+	ServiceAccount string
+	// Audience is the token's audience. If empty, the provider's full
+	// resource name is used.
+	Audience string
+}
+
+// AzureCloudCredentials configures a federated identity credential for an
+// app registration.
+type AzureCloudCredentials struct {
+	ClientID       string
+	TenantID       string
+	SubscriptionID string
+	// Audience is the token's audience. If empty,
+	// api://AzureADTokenExchange is used.
+	Audience string
+}
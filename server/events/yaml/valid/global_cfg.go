@@ -3,6 +3,7 @@ package valid
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 
 	version "github.com/hashicorp/go-version"
@@ -12,7 +13,13 @@ import (
 const MergeableApplyReq = "mergeable"
 const ApprovedApplyReq = "approved"
 const UnDivergedApplyReq = "undiverged"
+const CodeOwnersApprovedApplyReq = "codeowners_approved"
+const ApprovedCountApplyReq = "approved_count"
 const PoliciesPassedApplyReq = "policies_passed"
+const AllPlansSucceededApplyReq = "all_plans_succeeded"
+const BaseUnchangedApplyReq = "base_unchanged"
+const PipelineSucceededApplyReq = "pipeline_succeeded"
+const DestroyConfirmedApplyReq = "destroy_confirmed"
 const ApplyRequirementsKey = "apply_requirements"
 const PreWorkflowHooksKey = "pre_workflow_hooks"
 const WorkflowKey = "workflow"
@@ -21,6 +28,11 @@ const AllowedOverridesKey = "allowed_overrides"
 const AllowCustomWorkflowsKey = "allow_custom_workflows"
 const DefaultWorkflowName = "default"
 const DeleteSourceBranchOnMergeKey = "delete_source_branch_on_merge"
+const TeamPermissionsKey = "team_permissions"
+
+// TeamPermissionCommands are the comment commands that can be granted to
+// teams in team_permissions.
+var TeamPermissionCommands = []string{"plan", "apply", "unlock", "approve_policies", "lock", "revert"}
 
 // NonOverrideableApplyReqs will get applied across all "repos" in the server side config.
 // If repo config is allowed overrides, they can override this.
@@ -34,6 +46,9 @@ type GlobalCfg struct {
 	Repos      []Repo
 	Workflows  map[string]Workflow
 	PolicySets PolicySets
+	// TerraformVersions are downloaded ahead of time so commands using them
+	// don't have to wait for them to download.
+	TerraformVersions []*version.Version
 }
 
 // Repo is the final parsed version of server-side repo config.
@@ -52,10 +67,96 @@ type Repo struct {
 	AllowedOverrides          []string
 	AllowCustomWorkflows      *bool
 	DeleteSourceBranchOnMerge *bool
+	// TeamPermissions maps VCS team names to the commands their members are
+	// allowed to run. If nil, any user can run any command.
+	TeamPermissions map[string][]string
+	// Approvals configures the approved_count apply requirement. If nil, the
+	// defaults are used.
+	Approvals *Approvals
+	// Pipeline configures the pipeline_succeeded apply requirement. If nil,
+	// the defaults are used.
+	Pipeline *Pipeline
+	// Denylist is the providers, resource types and provisioners that plans
+	// can't contain. If nil, nothing is denied.
+	Denylist *Denylist
+	// Lint configures running tflint before plans. If nil, projects aren't
+	// linted.
+	Lint *Lint
+	// VerifyLockfile is true if init must not change the committed
+	// dependency lock file. If nil, it's false.
+	VerifyLockfile *bool
+	// CloudCredentials configures the cloud credentials that are exchanged
+	// for an OIDC token before running a project's workflow. If nil, no
+	// credentials are provided.
+	CloudCredentials *CloudCredentials
+	// AutoplanBranches restricts autoplan to pull requests between matching
+	// branches. If nil, every pull request is autoplanned.
+	AutoplanBranches *AutoplanBranches
+}
+
+// Denylist is the providers, resource types and provisioners that plans can't
+// contain. Each entry is a glob pattern, ex. "aws_iam_*".
+type Denylist struct {
+	// Providers match provider source addresses, ex. "hashicorp/aws", with or
+	// without the registry hostname.
+	Providers []string
+	// Resources match resource types, ex. "aws_iam_user".
+	Resources []string
+	// Provisioners match provisioner types, ex. "local-exec".
+	Provisioners []string
+}
+
+// Empty returns true if nothing is denied.
+func (d Denylist) Empty() bool {
+	return len(d.Providers) == 0 && len(d.Resources) == 0 && len(d.Provisioners) == 0
+}
+
+// Lint configures running tflint on projects before they're planned.
+type Lint struct {
+	// Config is the path of the tflint config file. Relative paths are
+	// relative to the root of the repo. If empty, tflint looks for
+	// .tflint.hcl in the project's directory.
+	Config string
+	// Blocking is true if plans aren't run when tflint finds issues. If
+	// false, the issues are only warnings.
+	Blocking bool
+}
+
+// Approvals configures the approved_count apply requirement.
+type Approvals struct {
+	// Count is the number of distinct reviewers that must approve. If less
+	// than 1, one approval is required.
+	Count int
+	// ExcludeAuthor is true if the pull request author's approval doesn't
+	// count.
+	ExcludeAuthor bool
+	// ExcludePrePlan is true if approvals of earlier commits, or from before
+	// the latest plan, don't count.
+	ExcludePrePlan bool
+}
+
+// DefaultPipelineAllowedStatuses are the statuses of the CI jobs of a
+// pipeline that succeeded if allowed_statuses isn't set.
+var DefaultPipelineAllowedStatuses = []string{"success", "skipped"}
+
+// Pipeline configures the pipeline_succeeded apply requirement.
+type Pipeline struct {
+	// AllowedStatuses are the statuses that the CI jobs of the pipeline must
+	// have. Jobs that are allowed to fail only have to be finished. If empty,
+	// DefaultPipelineAllowedStatuses are used.
+	AllowedStatuses []string
+	// RequiredJobs are the names of jobs that the pipeline must have, even if
+	// they're allowed to fail.
+	RequiredJobs []string
 }
 
 type MergedProjectCfg struct {
 	ApplyRequirements         []string
+	Approvals                 Approvals
+	Pipeline                  Pipeline
+	Denylist                  Denylist
+	VerifyLockfile            bool
+	CloudCredentials          CloudCredentials
 	Workflow                  Workflow
 	AllowedWorkflows          []string
 	RepoRelDir                string
@@ -66,6 +167,24 @@ type MergedProjectCfg struct {
 	RepoCfgVersion            int
 	PolicySets                PolicySets
 	DeleteSourceBranchOnMerge bool
+	// AutoplanBranches are the branch restrictions of the server-side repo
+	// config and of the project. Autoplan runs only for pull requests that
+	// match all of them.
+	AutoplanBranches []AutoplanBranches
+	// DependsOn are the projects whose outputs are provided to this project.
+	DependsOn []ProjectDependency
+	// Lint configures running tflint before the project is planned. If nil,
+	// it isn't linted.
+	Lint *Lint
+}
+
+// ProjectDependency is a project that another project depends on through
+// depends_on.
+type ProjectDependency struct {
+	Name             string
+	RepoRelDir       string
+	Workspace        string
+	TerraformVersion *version.Version
 }
 
 // PreWorkflowHook is a map of custom run commands to run before workflows.
@@ -224,6 +343,16 @@ func (r Repo) IDString() string {
 func (g GlobalCfg) MergeProjectCfg(log logging.SimpleLogging, repoID string, proj Project, rCfg RepoCfg) MergedProjectCfg {
 	log.Debug("MergeProjectCfg started")
 	applyReqs, workflow, allowedOverrides, allowCustomWorkflows, deleteSourceBranchOnMerge := g.getMatchingCfg(log, repoID)
+	approvals := g.approvals(repoID)
+	pipeline := g.pipeline(repoID)
+	denylist := g.denylist(repoID)
+	lint := g.lint(repoID)
+	verifyLockfile := g.verifyLockfile(repoID)
+	cloudCredentials := g.cloudCredentials(repoID)
+	autoplanBranches := g.autoplanBranches(repoID)
+	if !proj.Autoplan.Branches.Empty() {
+		autoplanBranches = append(autoplanBranches, proj.Autoplan.Branches)
+	}
 
 	// If repos are allowed to override certain keys then override them.
 	for _, key := range allowedOverrides {
@@ -233,6 +362,12 @@ func (g GlobalCfg) MergeProjectCfg(log logging.SimpleLogging, repoID string, pro
 				log.Debug("overriding server-defined %s with repo settings: [%s]", ApplyRequirementsKey, strings.Join(proj.ApplyRequirements, ","))
 				applyReqs = proj.ApplyRequirements
 			}
+			if proj.Approvals != nil {
+				approvals = *proj.Approvals
+			}
+			if proj.Pipeline != nil {
+				pipeline = *proj.Pipeline
+			}
 		case WorkflowKey:
 			if proj.WorkflowName != nil {
 				// We iterate over the global workflows first and the repo
@@ -276,17 +411,38 @@ func (g GlobalCfg) MergeProjectCfg(log logging.SimpleLogging, repoID string, pro
 	log.Debug("final settings: %s: [%s], %s: %s",
 		ApplyRequirementsKey, strings.Join(applyReqs, ","), WorkflowKey, workflow.Name)
 
+	// The dependencies were validated when parsing so they always exist.
+	var dependsOn []ProjectDependency
+	for _, name := range proj.DependsOn {
+		if dep := rCfg.FindProjectByName(name); dep != nil {
+			dependsOn = append(dependsOn, ProjectDependency{
+				Name:             name,
+				RepoRelDir:       dep.Dir,
+				Workspace:        dep.Workspace,
+				TerraformVersion: dep.TerraformVersion,
+			})
+		}
+	}
+
 	return MergedProjectCfg{
 		ApplyRequirements:         applyReqs,
+		Approvals:                 approvals,
+		Pipeline:                  pipeline,
+		Denylist:                  denylist,
+		VerifyLockfile:            verifyLockfile,
+		CloudCredentials:          cloudCredentials,
 		Workflow:                  workflow,
 		RepoRelDir:                proj.Dir,
 		Workspace:                 proj.Workspace,
 		Name:                      proj.GetName(),
 		AutoplanEnabled:           proj.Autoplan.Enabled,
+		AutoplanBranches:          autoplanBranches,
 		TerraformVersion:          proj.TerraformVersion,
 		RepoCfgVersion:            rCfg.Version,
 		PolicySets:                g.PolicySets,
 		DeleteSourceBranchOnMerge: deleteSourceBranchOnMerge,
+		DependsOn:                 dependsOn,
+		Lint:                      lint,
 	}
 }
 
@@ -295,17 +451,155 @@ func (g GlobalCfg) MergeProjectCfg(log logging.SimpleLogging, repoID string, pro
 func (g GlobalCfg) DefaultProjCfg(log logging.SimpleLogging, repoID string, repoRelDir string, workspace string) MergedProjectCfg {
 	log.Debug("building config based on server-side config")
 	applyReqs, workflow, _, _, deleteSourceBranchOnMerge := g.getMatchingCfg(log, repoID)
+	approvals := g.approvals(repoID)
+	denylist := g.denylist(repoID)
+	lint := g.lint(repoID)
+	verifyLockfile := g.verifyLockfile(repoID)
+	cloudCredentials := g.cloudCredentials(repoID)
 	return MergedProjectCfg{
 		ApplyRequirements:         applyReqs,
+		Approvals:                 approvals,
+		Pipeline:                  g.pipeline(repoID),
+		Denylist:                  denylist,
+		VerifyLockfile:            verifyLockfile,
+		CloudCredentials:          cloudCredentials,
 		Workflow:                  workflow,
 		RepoRelDir:                repoRelDir,
 		Workspace:                 workspace,
 		Name:                      "",
 		AutoplanEnabled:           DefaultAutoPlanEnabled,
+		AutoplanBranches:          g.autoplanBranches(repoID),
 		TerraformVersion:          nil,
 		PolicySets:                g.PolicySets,
 		DeleteSourceBranchOnMerge: deleteSourceBranchOnMerge,
+		Lint:                      lint,
+	}
+}
+
+// AutoplanMatches returns true if pull requests from headBranch into
+// baseBranch match the autoplan branch restrictions.
+func (m MergedProjectCfg) AutoplanMatches(baseBranch string, headBranch string) bool {
+	for _, branches := range m.AutoplanBranches {
+		if !branches.Matches(baseBranch, headBranch) {
+			return false
+		}
+	}
+	return true
+}
+
+// autoplanBranches returns the autoplan branch restrictions for the repo
+// with id repoID, or nil if it has none. Later matching repos override
+// earlier ones.
+func (g GlobalCfg) autoplanBranches(repoID string) []AutoplanBranches {
+	var branches []AutoplanBranches
+	for _, repo := range g.Repos {
+		if repo.IDMatches(repoID) && repo.AutoplanBranches != nil {
+			branches = []AutoplanBranches{*repo.AutoplanBranches}
+		}
+	}
+	return branches
+}
+
+// approvals returns the approvals config for the repo with id repoID. Later
+// matching repos override earlier ones.
+func (g GlobalCfg) approvals(repoID string) Approvals {
+	var approvals Approvals
+	for _, repo := range g.Repos {
+		if repo.IDMatches(repoID) && repo.Approvals != nil {
+			approvals = *repo.Approvals
+		}
+	}
+	return approvals
+}
+
+// pipeline returns the pipeline config for the repo with id repoID. Later
+// matching repos override earlier ones.
+func (g GlobalCfg) pipeline(repoID string) Pipeline {
+	var pipeline Pipeline
+	for _, repo := range g.Repos {
+		if repo.IDMatches(repoID) && repo.Pipeline != nil {
+			pipeline = *repo.Pipeline
+		}
+	}
+	return pipeline
+}
+
+// denylist returns the denylist for the repo with id repoID. Later matching
+// repos override earlier ones.
+func (g GlobalCfg) denylist(repoID string) Denylist {
+	var denylist Denylist
+	for _, repo := range g.Repos {
+		if repo.IDMatches(repoID) && repo.Denylist != nil {
+			denylist = *repo.Denylist
+		}
+	}
+	return denylist
+}
+
+// lint returns the lint config for the repo with id repoID, or nil if it
+// isn't linted. Later matching repos override earlier ones.
+func (g GlobalCfg) lint(repoID string) *Lint {
+	var lint *Lint
+	for _, repo := range g.Repos {
+		if repo.IDMatches(repoID) && repo.Lint != nil {
+			lint = repo.Lint
+		}
+	}
+	return lint
+}
+
+// verifyLockfile returns whether the dependency lock file is verified for the
+// repo with id repoID. Later matching repos override earlier ones.
+func (g GlobalCfg) verifyLockfile(repoID string) bool {
+	verify := false
+	for _, repo := range g.Repos {
+		if repo.IDMatches(repoID) && repo.VerifyLockfile != nil {
+			verify = *repo.VerifyLockfile
+		}
+	}
+	return verify
+}
+
+// cloudCredentials returns the cloud credentials config for the repo with id
+// repoID. Later matching repos override earlier ones.
+func (g GlobalCfg) cloudCredentials(repoID string) CloudCredentials {
+	var creds CloudCredentials
+	for _, repo := range g.Repos {
+		if repo.IDMatches(repoID) && repo.CloudCredentials != nil {
+			creds = *repo.CloudCredentials
+		}
+	}
+	return creds
+}
+
+// TeamPermissions returns the team permissions for the repo with id repoID.
+// Like other keys, later matching repos override earlier ones. It returns nil
+// if no matching repo restricts commands to teams.
+func (g GlobalCfg) TeamPermissions(repoID string) map[string][]string {
+	var permissions map[string][]string
+	for _, repo := range g.Repos {
+		if repo.IDMatches(repoID) && repo.TeamPermissions != nil {
+			permissions = repo.TeamPermissions
+		}
+	}
+	return permissions
+}
+
+// TeamNames returns the sorted names of all teams referenced in the
+// team permissions of any repo.
+func (g GlobalCfg) TeamNames() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, repo := range g.Repos {
+		for team := range repo.TeamPermissions {
+			if !seen[team] {
+				seen[team] = true
+				names = append(names, team)
+			}
+		}
 	}
+	sort.Strings(names)
+	return names
 }
 
 // ValidateRepoCfg validates that rCfg for repo with id repoID is valid based
@@ -342,7 +636,7 @@ func (g GlobalCfg) ValidateRepoCfg(rCfg RepoCfg, repoID string) error {
 		if p.WorkflowName != nil && !sliceContainsF(allowedOverrides, WorkflowKey) {
 			return fmt.Errorf("repo config not allowed to set '%s' key: server-side config needs '%s: [%s]'", WorkflowKey, AllowedOverridesKey, WorkflowKey)
 		}
-		if p.ApplyRequirements != nil && !sliceContainsF(allowedOverrides, ApplyRequirementsKey) {
+		if (p.ApplyRequirements != nil || p.Approvals != nil || p.Pipeline != nil) && !sliceContainsF(allowedOverrides, ApplyRequirementsKey) {
 			return fmt.Errorf("repo config not allowed to set '%s' key: server-side config needs '%s: [%s]'", ApplyRequirementsKey, AllowedOverridesKey, ApplyRequirementsKey)
 		}
 		if p.DeleteSourceBranchOnMerge != nil && !sliceContainsF(allowedOverrides, DeleteSourceBranchOnMergeKey) {
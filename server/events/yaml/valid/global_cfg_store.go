@@ -0,0 +1,34 @@
+package valid
+
+import "sync"
+
+// GlobalCfgStore holds the server-side repo config so that it can be replaced
+// while Atlantis is running. It's safe for concurrent use.
+type GlobalCfgStore struct {
+	mutex sync.RWMutex
+	cfg   GlobalCfg
+}
+
+// NewGlobalCfgStore returns a store holding cfg.
+func NewGlobalCfgStore(cfg GlobalCfg) *GlobalCfgStore {
+	return &GlobalCfgStore{cfg: cfg}
+}
+
+// Get returns the current config. Callers should call Get once per command so
+// a reload doesn't change the config halfway through. A nil store holds the
+// zero config.
+func (s *GlobalCfgStore) Get() GlobalCfg {
+	if s == nil {
+		return GlobalCfg{}
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.cfg
+}
+
+// Set replaces the config.
+func (s *GlobalCfgStore) Set(cfg GlobalCfg) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.cfg = cfg
+}
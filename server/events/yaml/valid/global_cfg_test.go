@@ -892,6 +892,208 @@ func TestRepo_BranchMatches(t *testing.T) {
 	Equals(t, false, (valid.Repo{BranchRegex: regexp.MustCompile("release")}).BranchMatches("main"))
 }
 
+func TestGlobalCfg_TeamPermissions(t *testing.T) {
+	cfg := valid.GlobalCfg{
+		Repos: []valid.Repo{
+			{IDRegex: regexp.MustCompile(".*")},
+			{
+				IDRegex: regexp.MustCompile("github.com/owner/.*"),
+				TeamPermissions: map[string][]string{
+					"devs":   {"plan"},
+					"admins": {"plan", "apply"},
+				},
+			},
+			{
+				ID: "github.com/owner/special",
+				TeamPermissions: map[string][]string{
+					"special": {"plan", "apply"},
+				},
+			},
+		},
+	}
+
+	Equals(t, map[string][]string(nil), cfg.TeamPermissions("github.com/other/repo"))
+	Equals(t, map[string][]string{"devs": {"plan"}, "admins": {"plan", "apply"}}, cfg.TeamPermissions("github.com/owner/repo"))
+	// Later repos override earlier ones.
+	Equals(t, map[string][]string{"special": {"plan", "apply"}}, cfg.TeamPermissions("github.com/owner/special"))
+	Equals(t, []string{"admins", "devs", "special"}, cfg.TeamNames())
+}
+
+func TestGlobalCfg_MergeProjectCfg_Approvals(t *testing.T) {
+	serverApprovals := &valid.Approvals{Count: 2, ExcludeAuthor: true}
+	repoApprovals := &valid.Approvals{Count: 3}
+	cases := []struct {
+		description      string
+		allowedOverrides []string
+		projApprovals    *valid.Approvals
+		exp              valid.Approvals
+	}{
+		{
+			description: "server-side approvals",
+			exp:         *serverApprovals,
+		},
+		{
+			description:      "repo-side approvals win out if apply_requirements is allowed",
+			allowedOverrides: []string{"apply_requirements"},
+			projApprovals:    repoApprovals,
+			exp:              *repoApprovals,
+		},
+		{
+			description:      "repo-side approvals are ignored if not set",
+			allowedOverrides: []string{"apply_requirements"},
+			exp:              *serverApprovals,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			global := valid.NewGlobalCfg(false, false, false)
+			global.Repos[0].AllowedOverrides = c.allowedOverrides
+			global.Repos = append(global.Repos, valid.Repo{
+				ID:        "github.com/owner/repo",
+				Approvals: serverApprovals,
+			})
+			proj := valid.Project{Dir: ".", Workspace: "default", Approvals: c.projApprovals}
+			merged := global.MergeProjectCfg(logging.NewNoopLogger(t), "github.com/owner/repo", proj, valid.RepoCfg{})
+			Equals(t, c.exp, merged.Approvals)
+		})
+	}
+	// Other repos don't get the approvals config.
+	global := valid.NewGlobalCfg(false, false, false)
+	Equals(t, valid.Approvals{}, global.DefaultProjCfg(logging.NewNoopLogger(t), "github.com/owner/repo", ".", "default").Approvals)
+}
+
+func TestGlobalCfg_MergeProjectCfg_Denylist(t *testing.T) {
+	global := valid.NewGlobalCfg(false, false, false)
+	global.Repos = append(global.Repos,
+		valid.Repo{
+			IDRegex:  regexp.MustCompile(".*"),
+			Denylist: &valid.Denylist{Providers: []string{"hashicorp/external"}},
+		},
+		valid.Repo{
+			ID:       "github.com/owner/repo",
+			Denylist: &valid.Denylist{Resources: []string{"aws_iam_*"}},
+		},
+	)
+	proj := valid.Project{Dir: ".", Workspace: "default"}
+
+	// The last matching repo wins.
+	merged := global.MergeProjectCfg(logging.NewNoopLogger(t), "github.com/owner/repo", proj, valid.RepoCfg{})
+	Equals(t, valid.Denylist{Resources: []string{"aws_iam_*"}}, merged.Denylist)
+	merged = global.MergeProjectCfg(logging.NewNoopLogger(t), "github.com/owner/other", proj, valid.RepoCfg{})
+	Equals(t, valid.Denylist{Providers: []string{"hashicorp/external"}}, merged.Denylist)
+	Equals(t, valid.Denylist{Resources: []string{"aws_iam_*"}}, global.DefaultProjCfg(logging.NewNoopLogger(t), "github.com/owner/repo", ".", "default").Denylist)
+}
+
+func TestGlobalCfg_MergeProjectCfg_Lint(t *testing.T) {
+	global := valid.NewGlobalCfg(false, false, false)
+	global.Repos = append(global.Repos,
+		valid.Repo{
+			IDRegex: regexp.MustCompile("github.com/owner/.*"),
+			Lint:    &valid.Lint{},
+		},
+		valid.Repo{
+			ID:   "github.com/owner/repo",
+			Lint: &valid.Lint{Config: ".tflint.hcl", Blocking: true},
+		},
+	)
+	proj := valid.Project{Dir: ".", Workspace: "default"}
+
+	// The last matching repo wins.
+	merged := global.MergeProjectCfg(logging.NewNoopLogger(t), "github.com/owner/repo", proj, valid.RepoCfg{})
+	Equals(t, &valid.Lint{Config: ".tflint.hcl", Blocking: true}, merged.Lint)
+	merged = global.MergeProjectCfg(logging.NewNoopLogger(t), "github.com/owner/other", proj, valid.RepoCfg{})
+	Equals(t, &valid.Lint{}, merged.Lint)
+	Equals(t, &valid.Lint{Config: ".tflint.hcl", Blocking: true}, global.DefaultProjCfg(logging.NewNoopLogger(t), "github.com/owner/repo", ".", "default").Lint)
+
+	// Repos without a lint config aren't linted.
+	Assert(t, global.DefaultProjCfg(logging.NewNoopLogger(t), "github.com/other/repo", ".", "default").Lint == nil, "exp no lint config")
+}
+
+func TestGlobalCfg_MergeProjectCfg_VerifyLockfile(t *testing.T) {
+	global := valid.NewGlobalCfg(false, false, false)
+	global.Repos = append(global.Repos,
+		valid.Repo{
+			IDRegex:        regexp.MustCompile(".*"),
+			VerifyLockfile: Bool(true),
+		},
+		valid.Repo{
+			ID:             "github.com/owner/repo",
+			VerifyLockfile: Bool(false),
+		},
+	)
+	proj := valid.Project{Dir: ".", Workspace: "default"}
+	Equals(t, false, global.MergeProjectCfg(logging.NewNoopLogger(t), "github.com/owner/repo", proj, valid.RepoCfg{}).VerifyLockfile)
+	Equals(t, true, global.MergeProjectCfg(logging.NewNoopLogger(t), "github.com/owner/other", proj, valid.RepoCfg{}).VerifyLockfile)
+	Equals(t, true, global.DefaultProjCfg(logging.NewNoopLogger(t), "github.com/owner/other", ".", "default").VerifyLockfile)
+}
+
+func TestGlobalCfg_MergeProjectCfg_AutoplanBranches(t *testing.T) {
+	global := valid.NewGlobalCfg(false, false, false)
+	global.Repos = append(global.Repos,
+		valid.Repo{
+			IDRegex:          regexp.MustCompile(".*"),
+			AutoplanBranches: &valid.AutoplanBranches{Head: []string{"!release/*"}},
+		},
+	)
+	proj := valid.Project{
+		Dir:       ".",
+		Workspace: "default",
+		Autoplan: valid.Autoplan{
+			Enabled:  true,
+			Branches: valid.AutoplanBranches{Base: []string{"main", "dev*", "!devops"}},
+		},
+	}
+
+	// Both the server-side and the project restrictions must match.
+	merged := global.MergeProjectCfg(logging.NewNoopLogger(t), "github.com/owner/repo", proj, valid.RepoCfg{})
+	Equals(t, true, merged.AutoplanMatches("main", "feature"))
+	Equals(t, true, merged.AutoplanMatches("develop", "feature"))
+	Equals(t, false, merged.AutoplanMatches("devops", "feature"))
+	Equals(t, false, merged.AutoplanMatches("staging", "feature"))
+	Equals(t, false, merged.AutoplanMatches("main", "release/v1"))
+
+	defaultCfg := global.DefaultProjCfg(logging.NewNoopLogger(t), "github.com/owner/repo", ".", "default")
+	Equals(t, true, defaultCfg.AutoplanMatches("staging", "feature"))
+	Equals(t, false, defaultCfg.AutoplanMatches("main", "release/v1"))
+
+	// Without restrictions every pull request matches.
+	Equals(t, true, valid.NewGlobalCfg(false, false, false).DefaultProjCfg(logging.NewNoopLogger(t), "github.com/owner/repo", ".", "default").AutoplanMatches("main", "release/v1"))
+}
+
+func TestGlobalCfg_MergeProjectCfg_DependsOn(t *testing.T) {
+	tfVersion, _ := version.NewVersion("1.0.0")
+	network := valid.Project{
+		Name:             String("network"),
+		Dir:              "network",
+		Workspace:        "staging",
+		TerraformVersion: tfVersion,
+	}
+	app := valid.Project{
+		Name:      String("app"),
+		Dir:       "app",
+		Workspace: "default",
+		DependsOn: []string{"network"},
+	}
+	rCfg := valid.RepoCfg{Projects: []valid.Project{network, app}}
+
+	global := valid.NewGlobalCfg(false, false, false)
+	merged := global.MergeProjectCfg(logging.NewNoopLogger(t), "github.com/owner/repo", app, rCfg)
+	Equals(t, []valid.ProjectDependency{
+		{Name: "network", RepoRelDir: "network", Workspace: "staging", TerraformVersion: tfVersion},
+	}, merged.DependsOn)
+
+	merged = global.MergeProjectCfg(logging.NewNoopLogger(t), "github.com/owner/repo", network, rCfg)
+	Equals(t, 0, len(merged.DependsOn))
+}
+
+func TestGlobalCfg_ValidateRepoCfg_Approvals(t *testing.T) {
+	global := valid.NewGlobalCfg(false, false, false)
+	err := global.ValidateRepoCfg(valid.RepoCfg{
+		Projects: []valid.Project{{Dir: ".", Workspace: "default", Approvals: &valid.Approvals{Count: 2}}},
+	}, "github.com/owner/repo")
+	ErrEquals(t, "repo config not allowed to set 'apply_requirements' key: server-side config needs 'allowed_overrides: [apply_requirements]'", err)
+}
+
 // String is a helper routine that allocates a new string value
 // to store v and returns a pointer to it.
 func String(v string) *string { return &v }
@@ -4,6 +4,7 @@ package valid
 
 import (
 	"fmt"
+	"path"
 	"regexp"
 	"strings"
 
@@ -106,7 +107,12 @@ type Project struct {
 	TerraformVersion          *version.Version
 	Autoplan                  Autoplan
 	ApplyRequirements         []string
+	Approvals                 *Approvals
+	Pipeline                  *Pipeline
 	DeleteSourceBranchOnMerge *bool
+	// DependsOn are the names of the projects whose outputs this project
+	// uses.
+	DependsOn []string
 }
 
 // GetName returns the name of the project or an empty string if there is no
@@ -121,6 +127,44 @@ func (p Project) GetName() string {
 type Autoplan struct {
 	WhenModified []string
 	Enabled      bool
+	// Branches restricts autoplan to pull requests between matching branches.
+	Branches AutoplanBranches
+}
+
+// AutoplanBranches restricts autoplan to pull requests whose base and head
+// branches match glob patterns, ex. "release/*". A pattern prefixed with "!"
+// excludes the branches it matches. If a list is empty, every branch matches.
+type AutoplanBranches struct {
+	Base []string
+	Head []string
+}
+
+// Empty returns true if every pull request matches.
+func (a AutoplanBranches) Empty() bool {
+	return len(a.Base) == 0 && len(a.Head) == 0
+}
+
+// Matches returns true if pull requests from headBranch into baseBranch are
+// autoplanned.
+func (a AutoplanBranches) Matches(baseBranch string, headBranch string) bool {
+	return branchMatches(a.Base, baseBranch) && branchMatches(a.Head, headBranch)
+}
+
+// branchMatches returns true if branch matches patterns. Like when_modified,
+// later patterns override earlier ones. If the first pattern is an exclusion,
+// the branches that aren't excluded match.
+func branchMatches(patterns []string, branch string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	matches := strings.HasPrefix(patterns[0], "!")
+	for _, pattern := range patterns {
+		exclude := strings.HasPrefix(pattern, "!")
+		if ok, _ := path.Match(strings.TrimPrefix(pattern, "!"), branch); ok {
+			matches = !exclude
+		}
+	}
+	return matches
 }
 
 type Stage struct {
@@ -138,6 +182,10 @@ type Step struct {
 	EnvVarName string
 	// EnvVarValue is the value to set EnvVarName to.
 	EnvVarValue string
+	// VaultPath is the path of the Vault secret to set EnvVarName to.
+	VaultPath string
+	// VaultKey is the key within the secret at VaultPath.
+	VaultKey string
 }
 
 type Workflow struct {
@@ -145,4 +193,7 @@ type Workflow struct {
 	Apply       Stage
 	Plan        Stage
 	PolicyCheck Stage
+	// ContainerImage is the image of the containers the workflow's custom
+	// run steps are run in. If empty, the server's default is used.
+	ContainerImage string
 }
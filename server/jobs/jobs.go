@@ -0,0 +1,221 @@
+// Package jobs tracks commands that run asynchronously, ex. plans triggered
+// through the API, so their results can be polled.
+package jobs
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// DefaultMaxJobs is how many jobs are kept by default. Once reached, the
+// oldest finished jobs are forgotten.
+const DefaultMaxJobs = 1000
+
+// Status is the status of a job.
+type Status string
+
+const (
+	PendingStatus   Status = "pending"
+	RunningStatus   Status = "running"
+	SucceededStatus Status = "succeeded"
+	FailedStatus    Status = "failed"
+)
+
+// ProjectResult is the result of running a job's command for one project.
+type ProjectResult struct {
+	Project   string `json:"project,omitempty"`
+	Dir       string `json:"dir"`
+	Workspace string `json:"workspace"`
+	Success   bool   `json:"success"`
+	// Output is terraform's output if the command succeeded.
+	Output string `json:"output,omitempty"`
+	// Summary is a one line summary of the plan's changes.
+	Summary string `json:"summary,omitempty"`
+	// Failure is set if the command couldn't run, ex. because the project is
+	// locked by another pull request.
+	Failure string `json:"failure,omitempty"`
+	// Error is set if the command failed.
+	Error string `json:"error,omitempty"`
+	// OutputURL is where the command's full log can be viewed.
+	OutputURL string `json:"output_url,omitempty"`
+}
+
+// Job is a command that runs in the background.
+type Job struct {
+	ID      string `json:"id"`
+	Command string `json:"command"`
+	Repo    string `json:"repo"`
+	Ref     string `json:"ref"`
+	// Commit is the sha that Ref pointed to when the job was created.
+	Commit string `json:"commit,omitempty"`
+	Pull   int    `json:"pull,omitempty"`
+	// CreatedBy is the name of the API token that created the job.
+	CreatedBy  string          `json:"created_by"`
+	Status     Status          `json:"status"`
+	Error      string          `json:"error,omitempty"`
+	Results    []ProjectResult `json:"results"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+// Finished returns true if the job has succeeded or failed.
+func (j Job) Finished() bool {
+	return j.Status == SucceededStatus || j.Status == FailedStatus
+}
+
+// Persister stores jobs durably, ex. in PostgreSQL, so their history is kept
+// after they're evicted from memory or the server restarts.
+type Persister interface {
+	SaveJob(job Job) error
+	// GetJob returns the job with id or nil if there's none.
+	GetJob(id string) (*Job, error)
+}
+
+// Store keeps jobs in memory. It's safe for concurrent use.
+type Store struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+	// order is the IDs of jobs in the order they were created.
+	order []string
+	max   int
+	now   func() time.Time
+	// persister is nil if jobs are only kept in memory.
+	persister Persister
+	logger    logging.SimpleLogging
+}
+
+// NewStore returns a store that keeps at most max jobs.
+func NewStore(max int) *Store {
+	return &Store{
+		jobs: make(map[string]*Job),
+		max:  max,
+		now:  time.Now,
+	}
+}
+
+// NewPersistentStore returns a store that keeps at most max jobs in memory
+// and saves every job with persister. Jobs that aren't in memory anymore are
+// retrieved from persister. Failures to save jobs are logged with logger.
+func NewPersistentStore(max int, persister Persister, logger logging.SimpleLogging) *Store {
+	s := NewStore(max)
+	s.persister = persister
+	s.logger = logger
+	return s
+}
+
+// Create stores job as pending and returns it with its ID set.
+func (s *Store) Create(job Job) Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job.ID = uuid.New().String()
+	job.Status = PendingStatus
+	job.CreatedAt = s.now()
+	job.Results = []ProjectResult{}
+	s.jobs[job.ID] = &job
+	s.order = append(s.order, job.ID)
+	s.persist(job)
+	s.evict()
+	return job
+}
+
+// Get returns the job with id.
+func (s *Store) Get(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return s.getPersisted(id)
+	}
+	j := *job
+	j.Results = append([]ProjectResult{}, job.Results...)
+	return j, true
+}
+
+// Start marks the job with id as running.
+func (s *Store) Start(id string) {
+	s.update(id, func(job *Job) {
+		now := s.now()
+		job.Status = RunningStatus
+		job.StartedAt = &now
+	})
+}
+
+// AddResult records the result for one of the job's projects.
+func (s *Store) AddResult(id string, result ProjectResult) {
+	s.update(id, func(job *Job) {
+		job.Results = append(job.Results, result)
+	})
+}
+
+// Finish marks the job with id as finished. It has failed if err is not nil
+// or any of its projects failed.
+func (s *Store) Finish(id string, err error) {
+	s.update(id, func(job *Job) {
+		now := s.now()
+		job.FinishedAt = &now
+		job.Status = SucceededStatus
+		if err != nil {
+			job.Status = FailedStatus
+			job.Error = err.Error()
+		}
+		for _, r := range job.Results {
+			if !r.Success {
+				job.Status = FailedStatus
+			}
+		}
+	})
+}
+
+func (s *Store) update(id string, f func(job *Job)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job, ok := s.jobs[id]; ok {
+		f(job)
+		s.persist(*job)
+	}
+}
+
+// persist saves job if the store has a persister. Must be called with mu
+// held so updates of the same job are saved in order.
+func (s *Store) persist(job Job) {
+	if s.persister == nil {
+		return
+	}
+	if err := s.persister.SaveJob(job); err != nil {
+		s.logger.Warn("failed to save job %s: %s", job.ID, err)
+	}
+}
+
+// getPersisted returns the job with id from the persister, if any.
+func (s *Store) getPersisted(id string) (Job, bool) {
+	if s.persister == nil {
+		return Job{}, false
+	}
+	job, err := s.persister.GetJob(id)
+	if err != nil {
+		s.logger.Warn("failed to get job %s: %s", id, err)
+		return Job{}, false
+	}
+	if job == nil {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// evict forgets the oldest finished jobs until there are at most max jobs.
+// Unfinished jobs are never forgotten. Must be called with mu held.
+func (s *Store) evict() {
+	for i := 0; len(s.jobs) > s.max && i < len(s.order); {
+		id := s.order[i]
+		if !s.jobs[id].Finished() {
+			i++
+			continue
+		}
+		delete(s.jobs, id)
+		s.order = append(s.order[:i], s.order[i+1:]...)
+	}
+}
@@ -0,0 +1,115 @@
+package jobs_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/jobs"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestStore_Lifecycle(t *testing.T) {
+	s := jobs.NewStore(10)
+	job := s.Create(jobs.Job{Command: "plan", Repo: "owner/repo", Ref: "main", CreatedBy: "ci"})
+	Assert(t, job.ID != "", "expected ID to be set")
+	Equals(t, jobs.PendingStatus, job.Status)
+
+	s.Start(job.ID)
+	got, ok := s.Get(job.ID)
+	Assert(t, ok, "expected job to exist")
+	Equals(t, jobs.RunningStatus, got.Status)
+	Assert(t, got.StartedAt != nil, "expected StartedAt to be set")
+
+	s.AddResult(job.ID, jobs.ProjectResult{Dir: ".", Workspace: "default", Success: true})
+	s.Finish(job.ID, nil)
+	got, _ = s.Get(job.ID)
+	Equals(t, jobs.SucceededStatus, got.Status)
+	Equals(t, 1, len(got.Results))
+	Assert(t, got.Finished(), "expected job to be finished")
+
+	_, ok = s.Get("missing")
+	Assert(t, !ok, "expected missing job to not exist")
+}
+
+func TestStore_FinishFailed(t *testing.T) {
+	s := jobs.NewStore(10)
+
+	job := s.Create(jobs.Job{})
+	s.Finish(job.ID, errors.New("err"))
+	got, _ := s.Get(job.ID)
+	Equals(t, jobs.FailedStatus, got.Status)
+	Equals(t, "err", got.Error)
+
+	job = s.Create(jobs.Job{})
+	s.AddResult(job.ID, jobs.ProjectResult{Dir: ".", Workspace: "default", Error: "plan failed"})
+	s.Finish(job.ID, nil)
+	got, _ = s.Get(job.ID)
+	Equals(t, jobs.FailedStatus, got.Status)
+}
+
+func TestStore_EvictsOldestFinished(t *testing.T) {
+	s := jobs.NewStore(2)
+	running := s.Create(jobs.Job{})
+	s.Start(running.ID)
+	finished := s.Create(jobs.Job{})
+	s.Finish(finished.ID, nil)
+	latest := s.Create(jobs.Job{})
+
+	_, ok := s.Get(running.ID)
+	Assert(t, ok, "expected running job to be kept")
+	_, ok = s.Get(finished.ID)
+	Assert(t, !ok, "expected finished job to be evicted")
+	_, ok = s.Get(latest.ID)
+	Assert(t, ok, "expected latest job to be kept")
+}
+
+func TestPersistentStore(t *testing.T) {
+	persister := &fakePersister{jobs: make(map[string]jobs.Job)}
+	s := jobs.NewPersistentStore(1, persister, logging.NewNoopLogger(t))
+	job := s.Create(jobs.Job{Command: "plan"})
+	Equals(t, jobs.PendingStatus, persister.jobs[job.ID].Status)
+
+	// Every update is saved.
+	s.AddResult(job.ID, jobs.ProjectResult{Dir: ".", Workspace: "default", Success: true})
+	s.Finish(job.ID, nil)
+	Equals(t, jobs.SucceededStatus, persister.jobs[job.ID].Status)
+	Equals(t, 1, len(persister.jobs[job.ID].Results))
+
+	// Evicted jobs are retrieved from the persister.
+	s.Create(jobs.Job{})
+	got, ok := s.Get(job.ID)
+	Assert(t, ok, "expected evicted job to be retrieved")
+	Equals(t, jobs.SucceededStatus, got.Status)
+
+	persister.err = errors.New("err")
+	s.Create(jobs.Job{})
+	_, ok = s.Get(job.ID)
+	Assert(t, !ok, "expected job to not be retrieved on error")
+	_, ok = s.Get("missing")
+	Assert(t, !ok, "expected missing job to not exist")
+}
+
+type fakePersister struct {
+	jobs map[string]jobs.Job
+	err  error
+}
+
+func (f *fakePersister) SaveJob(job jobs.Job) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.jobs[job.ID] = job
+	return nil
+}
+
+func (f *fakePersister) GetJob(id string) (*jobs.Job, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	job, ok := f.jobs[id]
+	if !ok {
+		return nil, nil
+	}
+	return &job, nil
+}
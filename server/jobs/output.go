@@ -0,0 +1,229 @@
+package jobs
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/runatlantis/atlantis/server/events/vault"
+)
+
+// DefaultMaxOutputs is how many project command outputs are kept by default.
+// Once reached, the oldest finished outputs are forgotten.
+const DefaultMaxOutputs = 500
+
+// OutputInfo describes the project command that an Output is for.
+type OutputInfo struct {
+	ID          string
+	Command     string
+	Repo        string
+	Pull        int
+	ProjectName string
+	RepoRelDir  string
+	Workspace   string
+	StartedAt   time.Time
+	// Finished is true once the command has finished running.
+	Finished bool
+}
+
+// Output is the output of a project command, ex. a plan, that can be watched
+// while the command is running.
+type Output struct {
+	mu          sync.Mutex
+	info        OutputInfo
+	lines       []string
+	partial     string
+	secrets     []string
+	subscribers map[chan string]struct{}
+}
+
+// Info returns what the output is for.
+func (o *Output) Info() OutputInfo {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.info
+}
+
+// Write adds p to the output. Output is split into lines and each line is
+// only made available once it's complete so that secrets are never sent
+// partially masked.
+func (o *Output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.info.Finished {
+		return len(p), nil
+	}
+	lines := strings.Split(o.partial+string(p), "\n")
+	o.partial = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		o.addLine(line)
+	}
+	return len(p), nil
+}
+
+// Mask masks secret in all output written after this call.
+func (o *Output) Mask(secret string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.secrets = append(o.secrets, secret)
+}
+
+// Close marks the command as finished. Subscribers' channels are closed.
+func (o *Output) Close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.info.Finished {
+		return
+	}
+	if o.partial != "" {
+		o.addLine(o.partial)
+		o.partial = ""
+	}
+	o.info.Finished = true
+	for ch := range o.subscribers {
+		close(ch)
+	}
+	o.subscribers = nil
+}
+
+// Subscribe returns the lines output so far and a channel that receives each
+// new line. The channel is closed once the command finishes. If the command
+// has already finished, the channel is nil. unsubscribe must be called once
+// the caller is done with the channel.
+func (o *Output) Subscribe() (lines []string, ch <-chan string, unsubscribe func()) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	lines = append([]string{}, o.lines...)
+	if o.info.Finished {
+		return lines, nil, func() {}
+	}
+	// The channel is buffered so slow subscribers don't block the command.
+	// If the buffer fills up, lines are dropped for that subscriber.
+	c := make(chan string, 1000)
+	o.subscribers[c] = struct{}{}
+	return lines, c, func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if _, ok := o.subscribers[c]; ok {
+			delete(o.subscribers, c)
+			close(c)
+		}
+	}
+}
+
+// addLine must be called with mu held.
+func (o *Output) addLine(line string) {
+	line = vault.MaskSecrets(strings.TrimSuffix(line, "\r"), o.secrets)
+	o.lines = append(o.lines, line)
+	for ch := range o.subscribers {
+		select {
+		case ch <- line:
+		default:
+		}
+	}
+}
+
+// OutputStore keeps the output of project commands in memory. It's safe for
+// concurrent use.
+type OutputStore struct {
+	mu      sync.Mutex
+	outputs map[string]*Output
+	// dirs maps the absolute path of the project each running command
+	// runs in to its output.
+	dirs  map[string]*Output
+	order []string
+	max   int
+	now   func() time.Time
+}
+
+// NewOutputStore returns a store that keeps at most max outputs.
+func NewOutputStore(max int) *OutputStore {
+	return &OutputStore{
+		outputs: make(map[string]*Output),
+		dirs:    make(map[string]*Output),
+		max:     max,
+		now:     time.Now,
+	}
+}
+
+// Start returns a new output for the command described by info.
+func (s *OutputStore) Start(info OutputInfo) *Output {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info.ID = uuid.New().String()
+	info.StartedAt = s.now()
+	info.Finished = false
+	o := &Output{
+		info:        info,
+		subscribers: make(map[chan string]struct{}),
+	}
+	s.outputs[info.ID] = o
+	s.order = append(s.order, info.ID)
+	s.evict()
+	return o
+}
+
+// Get returns the output with id.
+func (s *OutputStore) Get(id string) (*Output, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.outputs[id]
+	return o, ok
+}
+
+// List returns all the outputs, most recently started first.
+func (s *OutputStore) List() []OutputInfo {
+	s.mu.Lock()
+	outputs := make([]*Output, 0, len(s.order))
+	for i := len(s.order) - 1; i >= 0; i-- {
+		outputs = append(outputs, s.outputs[s.order[i]])
+	}
+	s.mu.Unlock()
+
+	var infos []OutputInfo
+	for _, o := range outputs {
+		infos = append(infos, o.Info())
+	}
+	return infos
+}
+
+// Attach sends the output of commands run in dir to o until Detach is called.
+func (s *OutputStore) Attach(dir string, o *Output) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirs[dir] = o
+}
+
+// Detach stops sending the output of commands run in dir.
+func (s *OutputStore) Detach(dir string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.dirs, dir)
+}
+
+// Writer returns the writer for commands run in dir or nil if no output is
+// attached to dir.
+func (s *OutputStore) Writer(dir string) io.Writer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if o, ok := s.dirs[dir]; ok {
+		return o
+	}
+	return nil
+}
+
+// evict forgets the oldest finished outputs until there are at most max.
+// Must be called with mu held.
+func (s *OutputStore) evict() {
+	for i := 0; len(s.outputs) > s.max && i < len(s.order); {
+		id := s.order[i]
+		if !s.outputs[id].Info().Finished {
+			i++
+			continue
+		}
+		delete(s.outputs, id)
+		s.order = append(s.order[:i], s.order[i+1:]...)
+	}
+}
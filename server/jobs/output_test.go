@@ -0,0 +1,82 @@
+package jobs_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/jobs"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestOutput_WriteAndSubscribe(t *testing.T) {
+	s := jobs.NewOutputStore(10)
+	o := s.Start(jobs.OutputInfo{Command: "plan", Repo: "owner/repo", Pull: 1, RepoRelDir: ".", Workspace: "default"})
+	Assert(t, o.Info().ID != "", "expected ID to be set")
+
+	o.Write([]byte("line 1\nline")) // nolint: errcheck
+	lines, ch, unsubscribe := o.Subscribe()
+	defer unsubscribe()
+	// The partial line isn't available until it's complete.
+	Equals(t, []string{"line 1"}, lines)
+
+	o.Write([]byte(" 2\r\n")) // nolint: errcheck
+	Equals(t, "line 2", <-ch)
+
+	o.Write([]byte("last")) // nolint: errcheck
+	o.Close()
+	Equals(t, "last", <-ch)
+	_, ok := <-ch
+	Assert(t, !ok, "expected channel to be closed")
+	Assert(t, o.Info().Finished, "expected output to be finished")
+
+	// Writes after closing are dropped.
+	o.Write([]byte("ignored\n")) // nolint: errcheck
+	lines, ch, _ = o.Subscribe()
+	Equals(t, []string{"line 1", "line 2", "last"}, lines)
+	Assert(t, ch == nil, "expected nil channel for finished output")
+}
+
+func TestOutput_Mask(t *testing.T) {
+	o := jobs.NewOutputStore(10).Start(jobs.OutputInfo{})
+	o.Write([]byte("before hunter")) // nolint: errcheck
+	o.Mask("hunter2")
+	o.Write([]byte("2\nafter hunter2\n")) // nolint: errcheck
+	lines, _, unsubscribe := o.Subscribe()
+	defer unsubscribe()
+	Equals(t, []string{"before ***", "after ***"}, lines)
+}
+
+func TestOutputStore_Writer(t *testing.T) {
+	s := jobs.NewOutputStore(10)
+	o := s.Start(jobs.OutputInfo{})
+	Assert(t, s.Writer("/dir") == nil, "expected no writer before attaching")
+
+	s.Attach("/dir", o)
+	w := s.Writer("/dir")
+	Assert(t, w != nil, "expected writer after attaching")
+	w.Write([]byte("streamed\n")) // nolint: errcheck
+	s.Detach("/dir")
+	Assert(t, s.Writer("/dir") == nil, "expected no writer after detaching")
+
+	lines, _, unsubscribe := o.Subscribe()
+	defer unsubscribe()
+	Equals(t, []string{"streamed"}, lines)
+
+	got, ok := s.Get(o.Info().ID)
+	Assert(t, ok, "expected output to exist")
+	Equals(t, o, got)
+}
+
+func TestOutputStore_ListAndEvict(t *testing.T) {
+	s := jobs.NewOutputStore(2)
+	running := s.Start(jobs.OutputInfo{Command: "plan"})
+	finished := s.Start(jobs.OutputInfo{Command: "apply"})
+	finished.Close()
+	latest := s.Start(jobs.OutputInfo{Command: "policy_check"})
+
+	_, ok := s.Get(finished.Info().ID)
+	Assert(t, !ok, "expected finished output to be evicted")
+	infos := s.List()
+	Equals(t, 2, len(infos))
+	Equals(t, latest.Info().ID, infos[0].ID)
+	Equals(t, running.Info().ID, infos[1].ID)
+}
@@ -0,0 +1,507 @@
+// Package kubernetes runs commands as Kubernetes Jobs so each one gets a
+// fresh, isolated and resource-limited pod.
+package kubernetes
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/logging"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// ServiceAccountDir is where Kubernetes mounts the pod's service account
+	// token, CA certificate and namespace.
+	ServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	// ManagedByLabel is set on the Jobs created by Atlantis.
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+
+	// envFilePrefix is the prefix of the files the command's environment
+	// variables are written to. They're written to a file rather than the
+	// Job so the Job doesn't contain secrets.
+	envFilePrefix = ".atlantis-job-env-"
+	// defaultPollInterval is how often Jobs are checked by default.
+	defaultPollInterval = 2 * time.Second
+)
+
+// envNameRegex matches the names of the environment variables sh can set.
+var envNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// JobRunner runs commands as Kubernetes Jobs created from a template. The
+// template's pod must mount the Atlantis data dir at the same path as the
+// Atlantis server, ex. from a ReadWriteMany volume, since commands are run
+// in the server's working dirs.
+type JobRunner struct {
+	// APIURL is the URL of the Kubernetes API server.
+	APIURL string
+	// HTTPClient calls the API server.
+	HTTPClient *http.Client
+	// TokenFile contains the token the API server is called with. It's read
+	// for each call since service account tokens are rotated.
+	TokenFile string
+	// Namespace is where Jobs are created.
+	Namespace string
+	// Template is the Job each command is run as. The command is run in its
+	// first container.
+	Template map[string]interface{}
+	// PollInterval is how often Jobs are checked while they run. If 0,
+	// they're checked every 2s.
+	PollInterval time.Duration
+}
+
+// NewInClusterJobRunner returns a runner creating Jobs from the Job manifest
+// in templateFile, using the service account of the pod Atlantis runs in.
+// Jobs are created in the template's namespace or, if it doesn't have one,
+// in the pod's namespace.
+func NewInClusterJobRunner(templateFile string) (*JobRunner, error) {
+	template, err := readTemplate(templateFile)
+	if err != nil {
+		return nil, err
+	}
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be set, is Atlantis running in Kubernetes?")
+	}
+	caCert, err := ioutil.ReadFile(filepath.Join(ServiceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading service account CA certificate")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, errors.New("service account CA certificate isn't valid PEM")
+	}
+	namespace, _ := template["metadata"].(map[string]interface{})["namespace"].(string)
+	if namespace == "" {
+		ns, err := ioutil.ReadFile(filepath.Join(ServiceAccountDir, "namespace"))
+		if err != nil {
+			return nil, errors.Wrap(err, "reading service account namespace")
+		}
+		namespace = strings.TrimSpace(string(ns))
+	}
+	return &JobRunner{
+		APIURL: "https://" + net.JoinHostPort(host, port),
+		HTTPClient: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+			},
+		},
+		TokenFile: filepath.Join(ServiceAccountDir, "token"),
+		Namespace: namespace,
+		Template:  template,
+	}, nil
+}
+
+// readTemplate reads the Job manifest in path.
+func readTemplate(path string) (map[string]interface{}, error) {
+	raw, err := ioutil.ReadFile(path) // nolint: gosec
+	if err != nil {
+		return nil, errors.Wrap(err, "reading Job template")
+	}
+	var manifest interface{}
+	if err := yaml.Unmarshal(raw, &manifest); err != nil {
+		return nil, errors.Wrapf(err, "parsing Job template %s", path)
+	}
+	template, ok := stringKeys(manifest).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Job template %s isn't a Job manifest", path)
+	}
+	if kind, _ := template["kind"].(string); kind != "Job" {
+		return nil, fmt.Errorf("Job template %s must be of kind Job, got %q", path, kind)
+	}
+	if _, err := containers(template); err != nil {
+		return nil, errors.Wrapf(err, "Job template %s", path)
+	}
+	if _, ok := template["metadata"].(map[string]interface{}); !ok {
+		template["metadata"] = map[string]interface{}{}
+	}
+	return template, nil
+}
+
+// stringKeys converts the maps decoded by yaml, which can have keys of any
+// type, to maps with string keys so they can be encoded as JSON.
+func stringKeys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = stringKeys(value)
+		}
+		return m
+	case []interface{}:
+		for i, value := range v {
+			v[i] = stringKeys(value)
+		}
+	}
+	return v
+}
+
+// containers returns the containers of job's pod.
+func containers(job map[string]interface{}) ([]interface{}, error) {
+	spec, _ := job["spec"].(map[string]interface{})
+	template, _ := spec["template"].(map[string]interface{})
+	podSpec, _ := template["spec"].(map[string]interface{})
+	containers, _ := podSpec["containers"].([]interface{})
+	if len(containers) == 0 {
+		return nil, errors.New("spec.template.spec.containers must have a container")
+	}
+	if _, ok := containers[0].(map[string]interface{}); !ok {
+		return nil, errors.New("spec.template.spec.containers[0] must be an object")
+	}
+	return containers, nil
+}
+
+// RunJob runs the shell command cmd in dir with the environment variables
+// envs, in KEY=value form, as a Job. The output of the Job's pod is written
+// to out while it runs. It returns an error if the command fails.
+func (r *JobRunner) RunJob(log logging.SimpleLogging, dir string, cmd string, envs []string, out io.Writer) error {
+	envFile, err := writeEnvFile(dir, envs)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(envFile) // nolint: errcheck
+
+	job, container, err := r.newJob(dir, fmt.Sprintf(". %s && rm -f %s && %s", shellQuote(envFile), shellQuote(envFile), cmd))
+	if err != nil {
+		return err
+	}
+	var created struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+	}
+	if err := r.call("POST", r.jobsPath(""), job, &created); err != nil {
+		return errors.Wrap(err, "creating Job")
+	}
+	name := created.Metadata.Name
+	log.Info("running %q in Job %s/%s", cmd, r.Namespace, name)
+	defer func() {
+		// Background propagation deletes the Job's pod too.
+		if err := r.call("DELETE", r.jobsPath(name)+"?propagationPolicy=Background", nil, nil); err != nil {
+			log.Warn("failed deleting Job %s/%s: %s", r.Namespace, name, err)
+		}
+	}()
+
+	pod, err := r.waitForPod(name)
+	if err != nil {
+		return err
+	}
+	if err := r.streamLogs(pod, container, out); err != nil {
+		log.Warn("failed streaming logs of pod %s/%s: %s", r.Namespace, pod, err)
+	}
+	return r.waitForCompletion(name, pod)
+}
+
+// newJob returns the Job running script in dir and the name of the
+// container running it.
+func (r *JobRunner) newJob(dir string, script string) (map[string]interface{}, string, error) {
+	// Copy the template so it isn't changed.
+	raw, err := json.Marshal(r.Template)
+	if err != nil {
+		return nil, "", err
+	}
+	var job map[string]interface{}
+	if err := json.Unmarshal(raw, &job); err != nil {
+		return nil, "", err
+	}
+
+	metadata := job["metadata"].(map[string]interface{})
+	prefix, _ := metadata["generateName"].(string)
+	if name, _ := metadata["name"].(string); prefix == "" && name != "" {
+		prefix = name + "-"
+	}
+	if prefix == "" {
+		prefix = "atlantis-"
+	}
+	delete(metadata, "name")
+	metadata["generateName"] = prefix
+	metadata["namespace"] = r.Namespace
+	labels, _ := metadata["labels"].(map[string]interface{})
+	if labels == nil {
+		labels = map[string]interface{}{}
+		metadata["labels"] = labels
+	}
+	labels[ManagedByLabel] = "atlantis"
+
+	spec := job["spec"].(map[string]interface{})
+	// Commands aren't retried since they can change infrastructure.
+	spec["backoffLimit"] = 0
+	podSpec := spec["template"].(map[string]interface{})["spec"].(map[string]interface{})
+	podSpec["restartPolicy"] = "Never"
+	conts, err := containers(job)
+	if err != nil {
+		return nil, "", err
+	}
+	container := conts[0].(map[string]interface{})
+	container["command"] = []string{"sh", "-c", script}
+	delete(container, "args")
+	container["workingDir"] = dir
+	name, _ := container["name"].(string)
+	return job, name, nil
+}
+
+// waitForPod returns the name of the pod of the Job once it's started.
+func (r *JobRunner) waitForPod(job string) (string, error) {
+	for {
+		var pods struct {
+			Items []podStatus `json:"items"`
+		}
+		if err := r.call("GET", r.podsPath("")+"?labelSelector="+url.QueryEscape("job-name="+job), nil, &pods); err != nil {
+			return "", errors.Wrap(err, "listing pods of Job")
+		}
+		for _, pod := range pods.Items {
+			if pod.Status.Phase != "Pending" {
+				return pod.Metadata.Name, nil
+			}
+			if reason := pod.waitingError(); reason != "" {
+				return "", fmt.Errorf("pod %s/%s of Job can't start: %s", r.Namespace, pod.Metadata.Name, reason)
+			}
+		}
+		if len(pods.Items) == 0 {
+			if failure, err := r.jobFailure(job); err != nil || failure != "" {
+				if err == nil {
+					err = fmt.Errorf("Job %s/%s failed: %s", r.Namespace, job, failure)
+				}
+				return "", err
+			}
+		}
+		time.Sleep(r.pollInterval())
+	}
+}
+
+// streamLogs copies the logs of container in pod to out until it exits.
+func (r *JobRunner) streamLogs(pod string, container string, out io.Writer) error {
+	query := url.Values{"follow": {"true"}}
+	if container != "" {
+		query.Set("container", container)
+	}
+	req, err := r.newRequest("GET", r.podsPath(pod)+"/log?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	// The logs are followed for as long as the command runs so the client's
+	// timeout can't be used.
+	client := *r.HTTPClient
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
+
+// waitForCompletion waits for pod of job to exit. It returns an error if the
+// command failed.
+func (r *JobRunner) waitForCompletion(job string, pod string) error {
+	for {
+		var status podStatus
+		if err := r.call("GET", r.podsPath(pod), nil, &status); err != nil {
+			return errors.Wrapf(err, "getting pod of Job %s/%s", r.Namespace, job)
+		}
+		switch status.Status.Phase {
+		case "Succeeded":
+			return nil
+		case "Failed":
+			for _, c := range status.Status.ContainerStatuses {
+				if t := c.State.Terminated; t != nil && t.ExitCode != 0 {
+					return fmt.Errorf("Job %s/%s failed with exit code %d", r.Namespace, job, t.ExitCode)
+				}
+			}
+			return fmt.Errorf("Job %s/%s failed: %s", r.Namespace, job, status.Status.Reason)
+		}
+		time.Sleep(r.pollInterval())
+	}
+}
+
+// jobFailure returns why job failed or "" if it hasn't.
+func (r *JobRunner) jobFailure(job string) (string, error) {
+	var status struct {
+		Status struct {
+			Conditions []struct {
+				Type    string `json:"type"`
+				Status  string `json:"status"`
+				Message string `json:"message"`
+			} `json:"conditions"`
+		} `json:"status"`
+	}
+	if err := r.call("GET", r.jobsPath(job), nil, &status); err != nil {
+		return "", errors.Wrap(err, "getting Job")
+	}
+	for _, c := range status.Status.Conditions {
+		if c.Type == "Failed" && c.Status == "True" {
+			return c.Message, nil
+		}
+	}
+	return "", nil
+}
+
+// podStatus is the part of a pod's status used to follow its Job.
+type podStatus struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Status struct {
+		Phase             string `json:"phase"`
+		Reason            string `json:"reason"`
+		ContainerStatuses []struct {
+			State struct {
+				Waiting *struct {
+					Reason  string `json:"reason"`
+					Message string `json:"message"`
+				} `json:"waiting"`
+				Terminated *struct {
+					ExitCode int `json:"exitCode"`
+				} `json:"terminated"`
+			} `json:"state"`
+		} `json:"containerStatuses"`
+	} `json:"status"`
+}
+
+// waitingError returns why the pod's containers can't start or "" if they
+// can.
+func (p podStatus) waitingError() string {
+	for _, c := range p.Status.ContainerStatuses {
+		if w := c.State.Waiting; w != nil {
+			switch w.Reason {
+			case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "CreateContainerConfigError", "CreateContainerError":
+				return fmt.Sprintf("%s: %s", w.Reason, w.Message)
+			}
+		}
+	}
+	return ""
+}
+
+func (r *JobRunner) jobsPath(name string) string {
+	path := fmt.Sprintf("/apis/batch/v1/namespaces/%s/jobs", url.PathEscape(r.Namespace))
+	if name != "" {
+		path += "/" + url.PathEscape(name)
+	}
+	return path
+}
+
+func (r *JobRunner) podsPath(name string) string {
+	path := fmt.Sprintf("/api/v1/namespaces/%s/pods", url.PathEscape(r.Namespace))
+	if name != "" {
+		path += "/" + url.PathEscape(name)
+	}
+	return path
+}
+
+func (r *JobRunner) pollInterval() time.Duration {
+	if r.PollInterval == 0 {
+		return defaultPollInterval
+	}
+	return r.PollInterval
+}
+
+// call calls the API server and decodes its JSON response into out, if it's
+// not nil.
+func (r *JobRunner) call(method string, path string, body interface{}, out interface{}) error {
+	req, err := r.newRequest(method, path, body)
+	if err != nil {
+		return err
+	}
+	resp, err := r.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return responseError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	return errors.Wrap(json.NewDecoder(resp.Body).Decode(out), "decoding response")
+}
+
+func (r *JobRunner) newRequest(method string, path string, body interface{}) (*http.Request, error) {
+	var reqBody io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(r.APIURL, "/")+path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.TokenFile != "" {
+		token, err := ioutil.ReadFile(r.TokenFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading service account token")
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	return req, nil
+}
+
+// responseError returns the error for the API server's unsuccessful resp.
+func responseError(resp *http.Response) error {
+	var status struct {
+		Message string `json:"message"`
+	}
+	raw, _ := ioutil.ReadAll(resp.Body)
+	if json.Unmarshal(raw, &status) == nil && status.Message != "" {
+		return fmt.Errorf("%s: %s", resp.Status, status.Message)
+	}
+	return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(raw)))
+}
+
+// writeEnvFile writes envs to a file in dir that can be sourced by sh. Only
+// the current user can read it.
+func writeEnvFile(dir string, envs []string) (string, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	sorted := append([]string(nil), envs...)
+	sort.Strings(sorted)
+	var buf bytes.Buffer
+	for _, env := range sorted {
+		kv := strings.SplitN(env, "=", 2)
+		// Variables sh can't set, ex. with dots in their names, are skipped.
+		if len(kv) != 2 || !envNameRegex.MatchString(kv[0]) {
+			continue
+		}
+		fmt.Fprintf(&buf, "export %s=%s\n", kv[0], shellQuote(kv[1]))
+	}
+	path := filepath.Join(dir, envFilePrefix+hex.EncodeToString(suffix))
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		return "", errors.Wrap(err, "writing Job environment file")
+	}
+	return path, nil
+}
+
+// shellQuote quotes s so sh reads it as a single word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,189 @@
+package kubernetes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+const jobTemplate = `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: terraform
+  labels:
+    team: infra
+spec:
+  template:
+    spec:
+      serviceAccountName: terraform
+      containers:
+      - name: terraform
+        image: hashicorp/terraform:light
+        args: ["version"]
+        resources:
+          limits:
+            memory: 1Gi
+`
+
+func TestReadTemplate(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	path := filepath.Join(tmp, "job.yaml")
+	Ok(t, ioutil.WriteFile(path, []byte(jobTemplate), 0600))
+
+	template, err := readTemplate(path)
+	Ok(t, err)
+	// The template can be encoded as JSON.
+	_, err = json.Marshal(template)
+	Ok(t, err)
+
+	Ok(t, ioutil.WriteFile(path, []byte("kind: Pod"), 0600))
+	_, err = readTemplate(path)
+	ErrEquals(t, fmt.Sprintf(`Job template %s must be of kind Job, got "Pod"`, path), err)
+
+	Ok(t, ioutil.WriteFile(path, []byte("kind: Job\nspec: {}"), 0600))
+	_, err = readTemplate(path)
+	ErrEquals(t, fmt.Sprintf("Job template %s: spec.template.spec.containers must have a container", path), err)
+}
+
+func TestJobRunner_RunJob(t *testing.T) {
+	dir, cleanup := TempDir(t)
+	defer cleanup()
+	api := &fakeAPI{t: t, phase: "Succeeded", logs: "Plan: 1 to add\n"}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	r := newTestJobRunner(t, server.URL)
+
+	var out bytes.Buffer
+	err := r.RunJob(logging.NewNoopLogger(t), dir, "terraform plan", []string{"TF_WORKSPACE=default", "SECRET=it's", "not.valid=x"}, &out)
+	Ok(t, err)
+	Equals(t, "Plan: 1 to add\n", out.String())
+	Equals(t, true, api.deleted)
+
+	// The Job is created from the template.
+	metadata := api.job["metadata"].(map[string]interface{})
+	Equals(t, "terraform-", metadata["generateName"])
+	Equals(t, nil, metadata["name"])
+	Equals(t, "atlantis", metadata["namespace"])
+	Equals(t, map[string]interface{}{"team": "infra", ManagedByLabel: "atlantis"}, metadata["labels"])
+	spec := api.job["spec"].(map[string]interface{})
+	Equals(t, float64(0), spec["backoffLimit"])
+	podSpec := spec["template"].(map[string]interface{})["spec"].(map[string]interface{})
+	Equals(t, "Never", podSpec["restartPolicy"])
+	Equals(t, "terraform", podSpec["serviceAccountName"])
+	container := podSpec["containers"].([]interface{})[0].(map[string]interface{})
+	Equals(t, dir, container["workingDir"])
+	Equals(t, nil, container["args"])
+	Equals(t, map[string]interface{}{"limits": map[string]interface{}{"memory": "1Gi"}}, container["resources"])
+
+	// The environment is sourced from a file that's deleted once the Job
+	// completes.
+	command := container["command"].([]interface{})
+	Equals(t, 3, len(command))
+	Assert(t, strings.HasSuffix(command[2].(string), " && terraform plan"), "unexpected command %q", command[2])
+	Equals(t, "export SECRET='it'\\''s'\nexport TF_WORKSPACE='default'\n", api.envFile)
+	files, err := ioutil.ReadDir(dir)
+	Ok(t, err)
+	Equals(t, 0, len(files))
+}
+
+func TestJobRunner_RunJobFailed(t *testing.T) {
+	dir, cleanup := TempDir(t)
+	defer cleanup()
+	api := &fakeAPI{t: t, phase: "Failed", exitCode: 2, logs: "Error: invalid config\n"}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	r := newTestJobRunner(t, server.URL)
+
+	var out bytes.Buffer
+	err := r.RunJob(logging.NewNoopLogger(t), dir, "terraform apply", nil, &out)
+	ErrEquals(t, "Job atlantis/terraform-abcde failed with exit code 2", err)
+	Equals(t, "Error: invalid config\n", out.String())
+	Equals(t, true, api.deleted)
+}
+
+func newTestJobRunner(t *testing.T, url string) *JobRunner {
+	tmp, cleanup := TempDir(t)
+	t.Cleanup(cleanup)
+	path := filepath.Join(tmp, "job.yaml")
+	Ok(t, ioutil.WriteFile(path, []byte(jobTemplate), 0600))
+	template, err := readTemplate(path)
+	Ok(t, err)
+	tokenFile := filepath.Join(tmp, "token")
+	Ok(t, ioutil.WriteFile(tokenFile, []byte("token\n"), 0600))
+	return &JobRunner{
+		APIURL:       url,
+		HTTPClient:   http.DefaultClient,
+		TokenFile:    tokenFile,
+		Namespace:    "atlantis",
+		Template:     template,
+		PollInterval: 1,
+	}
+}
+
+// fakeAPI is a Kubernetes API server running a single Job.
+type fakeAPI struct {
+	t        *testing.T
+	phase    string
+	exitCode int
+	logs     string
+
+	mutex   sync.Mutex
+	job     map[string]interface{}
+	envFile string
+	deleted bool
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	pod := map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "terraform-abcde-pod"},
+		"status": map[string]interface{}{
+			"phase": f.phase,
+			"containerStatuses": []interface{}{
+				map[string]interface{}{"state": map[string]interface{}{"terminated": map[string]interface{}{"exitCode": f.exitCode}}},
+			},
+		},
+	}
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/apis/batch/v1/namespaces/atlantis/jobs":
+		Ok(f.t, json.NewDecoder(r.Body).Decode(&f.job))
+		// Read the environment file while the Job runs.
+		script := f.job["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]interface{})[0].(map[string]interface{})["command"].([]interface{})[2].(string)
+		envFile := strings.Trim(strings.Fields(script)[1], "'")
+		raw, err := ioutil.ReadFile(envFile)
+		Ok(f.t, err)
+		f.envFile = string(raw)
+		json.NewEncoder(w).Encode(map[string]interface{}{"metadata": map[string]interface{}{"name": "terraform-abcde"}}) // nolint: errcheck
+	case r.Method == "GET" && r.URL.Path == "/api/v1/namespaces/atlantis/pods":
+		Equals(f.t, "job-name=terraform-abcde", r.URL.Query().Get("labelSelector"))
+		json.NewEncoder(w).Encode(map[string]interface{}{"items": []interface{}{pod}}) // nolint: errcheck
+	case r.Method == "GET" && r.URL.Path == "/api/v1/namespaces/atlantis/pods/terraform-abcde-pod/log":
+		Equals(f.t, "terraform", r.URL.Query().Get("container"))
+		w.Write([]byte(f.logs)) // nolint: errcheck
+	case r.Method == "GET" && r.URL.Path == "/api/v1/namespaces/atlantis/pods/terraform-abcde-pod":
+		json.NewEncoder(w).Encode(pod) // nolint: errcheck
+	case r.Method == "DELETE" && r.URL.Path == "/apis/batch/v1/namespaces/atlantis/jobs/terraform-abcde":
+		f.deleted = true
+		w.Write([]byte("{}")) // nolint: errcheck
+	default:
+		f.t.Errorf("unexpected call %s %s", r.Method, r.URL)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/controllers/templates"
+)
+
+// LockIndexPageSize is the number of locks shown per page of the index view.
+const LockIndexPageSize = 50
+
+const (
+	// lockSortNewest sorts the locks in the index view from newest to oldest.
+	// It's the default.
+	lockSortNewest = "newest"
+	// lockSortOldest sorts the locks from oldest to newest.
+	lockSortOldest = "oldest"
+)
+
+// lockPage filters locks by the q query parameter, sorts them by the sort
+// query parameter and returns the locks on the page query parameter.
+func lockPage(locks []templates.LockIndexData, query url.Values) ([]templates.LockIndexData, templates.LockPageData) {
+	q := strings.TrimSpace(query.Get("q"))
+	sortBy := query.Get("sort")
+	if sortBy != lockSortOldest {
+		sortBy = lockSortNewest
+	}
+
+	var matched []templates.LockIndexData
+	for _, l := range locks {
+		if lockMatches(l, q) {
+			matched = append(matched, l)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if sortBy == lockSortOldest {
+			return matched[i].Time.Before(matched[j].Time)
+		}
+		return matched[i].Time.After(matched[j].Time)
+	})
+
+	pages := (len(matched) + LockIndexPageSize - 1) / LockIndexPageSize
+	if pages == 0 {
+		pages = 1
+	}
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	if page > pages {
+		page = pages
+	}
+	start := (page - 1) * LockIndexPageSize
+	end := start + LockIndexPageSize
+	if end > len(matched) {
+		end = len(matched)
+	}
+
+	data := templates.LockPageData{
+		Query: q,
+		Sort:  sortBy,
+		Page:  page,
+		Pages: pages,
+		Total: len(matched),
+	}
+	pageQuery := func(p int) string {
+		v := url.Values{"page": {strconv.Itoa(p)}}
+		if q != "" {
+			v.Set("q", q)
+		}
+		if sortBy != lockSortNewest {
+			v.Set("sort", sortBy)
+		}
+		return "?" + v.Encode()
+	}
+	if page > 1 {
+		data.PrevQuery = pageQuery(page - 1)
+	}
+	if page < pages {
+		data.NextQuery = pageQuery(page + 1)
+	}
+	return matched[start:end], data
+}
+
+// lockMatches returns true if every word in q is found in the lock's repo,
+// path, workspace or pull request author, or is its pull request number, ex.
+// "#12". It's case-insensitive.
+func lockMatches(lock templates.LockIndexData, q string) bool {
+	fields := strings.ToLower(strings.Join([]string{lock.RepoFullName, lock.Path, lock.Workspace, lock.Author}, " "))
+	for _, word := range strings.Fields(strings.ToLower(q)) {
+		if strings.TrimPrefix(word, "#") == strconv.Itoa(lock.PullNum) {
+			continue
+		}
+		if !strings.Contains(fields, word) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,35 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// CorrelationIDKey is the key of the log field holding the ID shared by all
+// logs of a webhook and the commands it triggers.
+const CorrelationIDKey = "correlation_id"
+
+// NewCorrelationID returns a random correlation ID. It's the same length as a
+// trace ID so the two can be used interchangeably.
+func NewCorrelationID() string {
+	var id [16]byte
+	rand.Read(id[:]) // nolint: errcheck
+	return hex.EncodeToString(id[:])
+}
+
+type correlationIDContextKey struct{}
+
+// WithCorrelationID returns a copy of ctx containing the correlation ID id.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDContextKey{}, id)
+}
+
+// CorrelationIDFromContext returns the correlation ID in ctx or "".
+func CorrelationIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(correlationIDContextKey{}).(string)
+	return id
+}
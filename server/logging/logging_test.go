@@ -14,6 +14,7 @@
 package logging_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/runatlantis/atlantis/server/logging"
@@ -32,3 +33,22 @@ func TestStructuredLoggerSavesHistory(t *testing.T) {
 
 	assert.Equal(t, expectedStr, historyLogger.GetHistory())
 }
+
+func TestNewStructuredLoggerWithFormat(t *testing.T) {
+	for _, format := range []string{logging.JSONFormat, logging.ConsoleFormat} {
+		_, err := logging.NewStructuredLoggerWithFormat(logging.Info, format)
+		assert.NoError(t, err)
+	}
+	_, err := logging.NewStructuredLoggerWithFormat(logging.Info, "text")
+	assert.EqualError(t, err, `invalid log format "text": not one of json or console`)
+}
+
+func TestCorrelationID(t *testing.T) {
+	assert.Equal(t, "", logging.CorrelationIDFromContext(context.Background()))
+
+	id := logging.NewCorrelationID()
+	assert.Len(t, id, 32)
+	assert.NotEqual(t, id, logging.NewCorrelationID())
+	ctx := logging.WithCorrelationID(context.Background(), id)
+	assert.Equal(t, id, logging.CorrelationIDFromContext(ctx))
+}
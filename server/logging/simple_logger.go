@@ -71,11 +71,34 @@ type StructuredLogger struct {
 	history bytes.Buffer
 }
 
+// These are the formats logs can be written in.
+const (
+	// JSONFormat writes each log as a JSON object for log aggregators.
+	JSONFormat = "json"
+	// ConsoleFormat writes each log as a line of tab separated text.
+	ConsoleFormat = "console"
+)
+
 func NewStructuredLoggerFromLevel(lvl LogLevel) (SimpleLogging, error) {
+	return NewStructuredLoggerWithFormat(lvl, JSONFormat)
+}
+
+// NewStructuredLoggerWithFormat returns a logger that writes logs of lvl and
+// above in format, either JSONFormat or ConsoleFormat. It defaults to
+// JSONFormat if format is empty.
+func NewStructuredLoggerWithFormat(lvl LogLevel, format string) (SimpleLogging, error) {
 	cfg := zap.NewProductionConfig()
 
 	cfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 	cfg.Level = zap.NewAtomicLevelAt(lvl.zLevel)
+	switch format {
+	case JSONFormat, "":
+	case ConsoleFormat:
+		cfg.Encoding = ConsoleFormat
+		cfg.EncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+	default:
+		return nil, errors.Errorf("invalid log format %q: not one of %s or %s", format, JSONFormat, ConsoleFormat)
+	}
 	return newStructuredLogger(cfg)
 }
 
@@ -0,0 +1,48 @@
+// Package metrics holds simple in-memory metrics that Atlantis exposes on its
+// status endpoint.
+package metrics
+
+import "sync"
+
+// Counters is a set of named counters. It's safe for concurrent use.
+type Counters struct {
+	mutex  sync.Mutex
+	counts map[string]int64
+}
+
+// NewCounters returns an empty set of counters.
+func NewCounters() *Counters {
+	return &Counters{counts: make(map[string]int64)}
+}
+
+// Inc increments the counter called name.
+func (c *Counters) Inc(name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts[name]++
+}
+
+// Add adds delta to the counter called name.
+func (c *Counters) Add(name string, delta int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts[name] += delta
+}
+
+// Get returns the value of the counter called name.
+func (c *Counters) Get(name string) int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.counts[name]
+}
+
+// Snapshot returns a copy of all the counters.
+func (c *Counters) Snapshot() map[string]int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	snapshot := make(map[string]int64, len(c.counts))
+	for name, count := range c.counts {
+		snapshot[name] = count
+	}
+	return snapshot
+}
@@ -0,0 +1,36 @@
+package metrics_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/metrics"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestCounters(t *testing.T) {
+	c := metrics.NewCounters()
+	Equals(t, int64(0), c.Get("a"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Inc("a")
+		}()
+	}
+	wg.Wait()
+	c.Inc("b")
+
+	Equals(t, int64(10), c.Get("a"))
+	snapshot := c.Snapshot()
+	Equals(t, map[string]int64{"a": 10, "b": 1}, snapshot)
+
+	// Snapshots are copies.
+	c.Inc("b")
+	Equals(t, int64(1), snapshot["b"])
+
+	c.Add("b", 3)
+	Equals(t, int64(5), c.Get("b"))
+}
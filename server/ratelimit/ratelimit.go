@@ -0,0 +1,85 @@
+// Package ratelimit limits how often something can happen, ex. how many
+// webhooks each repo can send.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// maxBuckets is how many keys are tracked before the full buckets are
+// forgotten. Forgetting a full bucket doesn't change the limits.
+const maxBuckets = 10000
+
+// Limiter is a token bucket rate limiter with a bucket per key. Each bucket
+// holds up to burst tokens and is refilled with perMinute tokens a minute.
+// A nil Limiter allows everything. It's safe for concurrent use.
+type Limiter struct {
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+
+	perSecond float64
+	burst     float64
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a limiter allowing perMinute events a minute per key with
+// bursts of up to burst events. If perMinute is 0, it returns nil so nothing
+// is limited.
+func NewLimiter(perMinute int, burst int) *Limiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	return &Limiter{
+		Now:       time.Now,
+		perSecond: float64(perMinute) / 60,
+		burst:     float64(burst),
+		buckets:   make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token from key's bucket. If the bucket is empty, it returns
+// false and how long until a token is available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.Now()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxBuckets {
+			l.forgetFull(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.perSecond)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.perSecond * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// forgetFull deletes the buckets that would be full by now.
+func (l *Limiter) forgetFull(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.perSecond >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/ratelimit"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestNewLimiter_Disabled(t *testing.T) {
+	l := ratelimit.NewLimiter(0, 10)
+	Assert(t, l == nil, "expected nil limiter")
+	for i := 0; i < 100; i++ {
+		allowed, _ := l.Allow("key")
+		Equals(t, true, allowed)
+	}
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := ratelimit.NewLimiter(60, 2)
+	l.Now = func() time.Time { return now }
+
+	// The burst is allowed straight away.
+	for i := 0; i < 2; i++ {
+		allowed, _ := l.Allow("a")
+		Equals(t, true, allowed)
+	}
+	allowed, wait := l.Allow("a")
+	Equals(t, false, allowed)
+	Equals(t, time.Second, wait)
+
+	// Other keys have their own bucket.
+	allowed, _ = l.Allow("b")
+	Equals(t, true, allowed)
+
+	// Tokens are refilled at the rate.
+	now = now.Add(500 * time.Millisecond)
+	allowed, wait = l.Allow("a")
+	Equals(t, false, allowed)
+	Equals(t, 500*time.Millisecond, wait)
+	now = now.Add(500 * time.Millisecond)
+	allowed, _ = l.Allow("a")
+	Equals(t, true, allowed)
+
+	// Buckets don't fill past the burst.
+	now = now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		allowed, _ := l.Allow("a")
+		Equals(t, true, allowed)
+	}
+	allowed, _ = l.Allow("a")
+	Equals(t, false, allowed)
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/terraform"
+	"github.com/runatlantis/atlantis/server/events/yaml"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// RepoConfigReloader reloads the server-side repo config file while Atlantis
+// is running, ex. on SIGHUP.
+type RepoConfigReloader struct {
+	// Path is the --repo-config file.
+	Path string
+	// DefaultCfg is the config built from the flags that the file is merged
+	// into.
+	DefaultCfg valid.GlobalCfg
+	Validator  *yaml.ParserValidator
+	Store      *valid.GlobalCfgStore
+	Logger     logging.SimpleLogging
+	// GitlabEnabled is true if GitLab is configured. Its groups are only
+	// read from team_permissions on startup.
+	GitlabEnabled bool
+	// TerraformClient downloads the terraform_versions of the reloaded config.
+	// If nil, they aren't downloaded ahead of time.
+	TerraformClient *terraform.DefaultClient
+}
+
+// Reload parses and validates the file and replaces the current config with
+// it. If the file is invalid, the current config is kept and an error is
+// returned. Commands that are already running keep using the config they
+// started with.
+func (r *RepoConfigReloader) Reload() error {
+	globalCfg, err := r.Validator.ParseGlobalCfg(r.Path, r.DefaultCfg)
+	if err != nil {
+		r.Logger.Err("not reloading %s since it's invalid: %s", r.Path, err)
+		return errors.Wrapf(err, "parsing %s file", r.Path)
+	}
+	if r.GitlabEnabled && !reflect.DeepEqual(globalCfg.TeamNames(), r.Store.Get().TeamNames()) {
+		r.Logger.Warn("the teams in team_permissions changed: restart Atlantis for GitLab group memberships to be looked up for the new teams")
+	}
+	r.Store.Set(globalCfg)
+	r.Logger.Info("reloaded %s", r.Path)
+	if r.TerraformClient != nil {
+		go r.TerraformClient.PrewarmVersions(r.Logger, globalCfg.TerraformVersions)
+	}
+	return nil
+}
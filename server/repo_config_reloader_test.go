@@ -0,0 +1,38 @@
+package server_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server"
+	"github.com/runatlantis/atlantis/server/events/yaml"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestRepoConfigReloader_Reload(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	path := filepath.Join(tmp, "repos.yaml")
+	Ok(t, ioutil.WriteFile(path, []byte("repos:\n- id: /.*/\n  apply_requirements: [approved]\n"), 0600))
+
+	defaultCfg := valid.NewGlobalCfgFromArgs(valid.GlobalCfgArgs{})
+	store := valid.NewGlobalCfgStore(defaultCfg)
+	reloader := &server.RepoConfigReloader{
+		Path:       path,
+		DefaultCfg: defaultCfg,
+		Validator:  &yaml.ParserValidator{},
+		Store:      store,
+		Logger:     logging.NewNoopLogger(t),
+	}
+	Ok(t, reloader.Reload())
+	Equals(t, []string{"approved"}, store.Get().Repos[1].ApplyRequirements)
+
+	// An invalid file is reported and the current config kept.
+	Ok(t, ioutil.WriteFile(path, []byte("repos:\n- id: /.*/\n  apply_requirements: [unknown]\n"), 0600))
+	err := reloader.Reload()
+	ErrContains(t, "apply_requirements", err)
+	Equals(t, []string{"approved"}, store.Get().Repos[1].ApplyRequirements)
+}
@@ -2,8 +2,10 @@ package server
 
 import (
 	"net/url"
+	"strconv"
 
 	"github.com/gorilla/mux"
+	"github.com/runatlantis/atlantis/server/events/models"
 )
 
 // Router can be used to retrieve Atlantis URLs. It acts as an intermediary
@@ -18,6 +20,12 @@ type Router struct {
 	// LockViewRouteIDQueryParam is the query parameter needed to construct the
 	// lock view: underlying.Get(LockViewRouteName).URL(LockViewRouteIDQueryParam, "my id").
 	LockViewRouteIDQueryParam string
+	// LogViewRouteName is the named route for the log view that can be Get'd
+	// from the Underlying router. Its path has an {id} variable.
+	LogViewRouteName string
+	// PlanJSONRouteName is the named route for the plans rendered as JSON
+	// that can be Get'd from the Underlying router.
+	PlanJSONRouteName string
 	// AtlantisURL is the fully qualified URL that Atlantis is
 	// accessible from externally.
 	AtlantisURL *url.URL
@@ -33,3 +41,24 @@ func (r *Router) GenerateLockURL(lockID string) string {
 	// golang likes to double escape the lockURL path when using url.Parse().
 	return r.AtlantisURL.String() + lockURL.String()
 }
+
+// GenerateOutputURL returns a fully qualified URL to watch the output with id.
+func (r *Router) GenerateOutputURL(id string) string {
+	logURL, _ := r.Underlying.Get(r.LogViewRouteName).URL("id", id)
+	return r.AtlantisURL.String() + logURL.String()
+}
+
+// GeneratePlanJSONURL returns a fully qualified URL to download the plan of
+// the project described by ctx rendered as JSON.
+func (r *Router) GeneratePlanJSONURL(ctx models.ProjectCommandContext) string {
+	planURL, _ := r.Underlying.Get(r.PlanJSONRouteName).URL()
+	query := url.Values{}
+	query.Set("repo", ctx.Pull.BaseRepo.FullName)
+	query.Set("pull", strconv.Itoa(ctx.Pull.Num))
+	query.Set("dir", ctx.RepoRelDir)
+	query.Set("workspace", ctx.Workspace)
+	if ctx.ProjectName != "" {
+		query.Set("project", ctx.ProjectName)
+	}
+	return r.AtlantisURL.String() + planURL.String() + "?" + query.Encode()
+}
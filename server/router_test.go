@@ -6,6 +6,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/runatlantis/atlantis/server"
+	"github.com/runatlantis/atlantis/server/events/models"
 	. "github.com/runatlantis/atlantis/testing"
 )
 
@@ -60,3 +61,44 @@ func TestRouter_GenerateLockURL(t *testing.T) {
 		})
 	}
 }
+
+func TestRouter_GenerateOutputURL(t *testing.T) {
+	routeName := "routename"
+	underlyingRouter := mux.NewRouter()
+	underlyingRouter.HandleFunc("/logs/{id}", func(_ http.ResponseWriter, _ *http.Request) {}).Methods("GET").Name(routeName)
+
+	for _, u := range []string{"https://example.com/basepath", "https://example.com/basepath/"} {
+		t.Run(u, func(t *testing.T) {
+			atlantisURL, err := server.ParseAtlantisURL(u)
+			Ok(t, err)
+			router := &server.Router{
+				AtlantisURL:      atlantisURL,
+				LogViewRouteName: routeName,
+				Underlying:       underlyingRouter,
+			}
+			Equals(t, "https://example.com/basepath/logs/abc-123", router.GenerateOutputURL("abc-123"))
+		})
+	}
+}
+
+func TestRouter_GeneratePlanJSONURL(t *testing.T) {
+	routeName := "routename"
+	underlyingRouter := mux.NewRouter()
+	underlyingRouter.HandleFunc("/plans/json", func(_ http.ResponseWriter, _ *http.Request) {}).Methods("GET").Name(routeName)
+	atlantisURL, err := server.ParseAtlantisURL("https://example.com/basepath")
+	Ok(t, err)
+	router := &server.Router{
+		AtlantisURL:       atlantisURL,
+		PlanJSONRouteName: routeName,
+		Underlying:        underlyingRouter,
+	}
+
+	ctx := models.ProjectCommandContext{
+		Pull:       models.PullRequest{Num: 1, BaseRepo: models.Repo{FullName: "owner/repo"}},
+		RepoRelDir: "staging",
+		Workspace:  "default",
+	}
+	Equals(t, "https://example.com/basepath/plans/json?dir=staging&pull=1&repo=owner%2Frepo&workspace=default", router.GeneratePlanJSONURL(ctx))
+	ctx.ProjectName = "staging"
+	Equals(t, "https://example.com/basepath/plans/json?dir=staging&project=staging&pull=1&repo=owner%2Frepo&workspace=default", router.GeneratePlanJSONURL(ctx))
+}
@@ -0,0 +1,149 @@
+// Package secrets resolves references to secrets in the server config so
+// credentials don't need to appear literally in flags, environment variables
+// or config files.
+package secrets
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/vault"
+)
+
+// The schemes of references to secrets.
+const (
+	// FileScheme refers to the contents of a file, ex. file:///etc/atlantis/gh-token.
+	FileScheme = "file://"
+	// EnvScheme refers to an environment variable, ex. env://GITHUB_TOKEN.
+	EnvScheme = "env://"
+	// VaultScheme refers to a key of a Vault secret, ex.
+	// vault://secret/data/atlantis#gh-token.
+	VaultScheme = "vault://"
+)
+
+// secretTag is the struct tag of the config fields that can refer to
+// secrets, ex. `secret:"true"`.
+const secretTag = "secret"
+
+// IsRef returns true if value is a reference to a secret.
+func IsRef(value string) bool {
+	return strings.HasPrefix(value, FileScheme) || strings.HasPrefix(value, EnvScheme) || strings.HasPrefix(value, VaultScheme)
+}
+
+// Resolver resolves references to secrets.
+type Resolver struct {
+	// Vault reads vault:// references. If nil, they can't be resolved.
+	Vault vault.Client
+}
+
+// Resolve returns the secret that ref refers to. Values that aren't
+// references are returned as is. Files are trimmed of surrounding whitespace
+// since they usually end with a newline.
+func (r *Resolver) Resolve(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, FileScheme):
+		path := strings.TrimPrefix(ref, FileScheme)
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", errors.Wrapf(err, "reading %s", path)
+		}
+		value := strings.TrimSpace(string(contents))
+		if value == "" {
+			return "", fmt.Errorf("%s is empty", path)
+		}
+		return value, nil
+	case strings.HasPrefix(ref, EnvScheme):
+		name := strings.TrimPrefix(ref, EnvScheme)
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			return "", fmt.Errorf("environment variable %s isn't set", name)
+		}
+		return value, nil
+	case strings.HasPrefix(ref, VaultScheme):
+		if r.Vault == nil {
+			return "", errors.New("Vault isn't configured")
+		}
+		// Paths are split on the last # like the vault key of env steps.
+		pathAndKey := strings.TrimPrefix(ref, VaultScheme)
+		i := strings.LastIndex(pathAndKey, "#")
+		if i <= 0 || i == len(pathAndKey)-1 {
+			return "", fmt.Errorf("%q must be of the form %spath#key", ref, VaultScheme)
+		}
+		return r.Vault.Read(pathAndKey[:i], pathAndKey[i+1:])
+	}
+	return ref, nil
+}
+
+// Ref is a config field that refers to a secret.
+type Ref struct {
+	// Field is the name of the field, ex. gh-token or tenants[0].gh-token.
+	Field string
+	// Ref is the reference, ex. env://GITHUB_TOKEN.
+	Ref string
+	// value is the secret that Ref was last resolved to.
+	value string
+}
+
+// ResolveFields replaces the references in the string fields of cfg, a
+// pointer to a struct, that are tagged `secret:"true"` with their secrets.
+// Nested structs and slices of structs are resolved too. Fields are named by
+// their mapstructure tags. It returns the fields that were references.
+func ResolveFields(cfg interface{}, r *Resolver) ([]Ref, error) {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected a pointer to a struct, got %T", cfg)
+	}
+	var refs []Ref
+	err := resolveStruct(v.Elem(), "", r, &refs)
+	return refs, err
+}
+
+func resolveStruct(v reflect.Value, prefix string, r *Resolver, refs *[]Ref) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+		if name == "" || name == "-" || field.PkgPath != "" {
+			continue
+		}
+		name = prefix + name
+		fv := v.Field(i)
+		switch fv.Kind() {
+		case reflect.String:
+			if field.Tag.Get(secretTag) != "true" || !IsRef(fv.String()) {
+				continue
+			}
+			ref := fv.String()
+			value, err := r.Resolve(ref)
+			if err != nil {
+				return errors.Wrapf(err, "resolving %s", name)
+			}
+			fv.SetString(value)
+			*refs = append(*refs, Ref{Field: name, Ref: ref, value: value})
+		case reflect.Struct:
+			if err := resolveStruct(fv, name+".", r, refs); err != nil {
+				return err
+			}
+		case reflect.Ptr:
+			if !fv.IsNil() && fv.Elem().Kind() == reflect.Struct {
+				if err := resolveStruct(fv.Elem(), name+".", r, refs); err != nil {
+					return err
+				}
+			}
+		case reflect.Slice:
+			if fv.Type().Elem().Kind() != reflect.Struct {
+				continue
+			}
+			for j := 0; j < fv.Len(); j++ {
+				if err := resolveStruct(fv.Index(j), fmt.Sprintf("%s[%d].", name, j), r, refs); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package secrets_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events/vault/mocks"
+	"github.com/runatlantis/atlantis/server/secrets"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	Ok(t, ioutil.WriteFile(filepath.Join(tmp, "token"), []byte("file-token\n"), 0600))
+	Ok(t, ioutil.WriteFile(filepath.Join(tmp, "empty"), []byte("\n"), 0600))
+	Ok(t, os.Setenv("ATLANTIS_TEST_SECRET", "env-token"))
+	defer os.Unsetenv("ATLANTIS_TEST_SECRET") // nolint: errcheck
+
+	vaultClient := mocks.NewMockClient()
+	When(vaultClient.Read("secret/data/atlantis", "gh-token")).ThenReturn("vault-token", nil)
+	r := &secrets.Resolver{Vault: vaultClient}
+
+	cases := []struct {
+		ref    string
+		exp    string
+		expErr string
+	}{
+		{"token", "token", ""},
+		{"", "", ""},
+		{"file://" + filepath.Join(tmp, "token"), "file-token", ""},
+		{"file://" + filepath.Join(tmp, "empty"), "", filepath.Join(tmp, "empty") + " is empty"},
+		{"file://" + filepath.Join(tmp, "missing"), "", "reading " + filepath.Join(tmp, "missing") + ": open " + filepath.Join(tmp, "missing") + ": no such file or directory"},
+		{"env://ATLANTIS_TEST_SECRET", "env-token", ""},
+		{"env://ATLANTIS_TEST_UNSET", "", "environment variable ATLANTIS_TEST_UNSET isn't set"},
+		{"vault://secret/data/atlantis#gh-token", "vault-token", ""},
+		{"vault://secret/data/atlantis", "", "\"vault://secret/data/atlantis\" must be of the form vault://path#key"},
+		{"vault://secret/data/atlantis#", "", "\"vault://secret/data/atlantis#\" must be of the form vault://path#key"},
+	}
+	for _, c := range cases {
+		t.Run(c.ref, func(t *testing.T) {
+			value, err := r.Resolve(c.ref)
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+			Equals(t, c.exp, value)
+		})
+	}
+
+	_, err := (&secrets.Resolver{}).Resolve("vault://secret/data/atlantis#gh-token")
+	ErrEquals(t, "Vault isn't configured", err)
+}
+
+type tenant struct {
+	Name  string `mapstructure:"name"`
+	Token string `mapstructure:"gh-token" secret:"true"`
+}
+
+type config struct {
+	User    string             `mapstructure:"gh-user"`
+	Token   string             `mapstructure:"gh-token" secret:"true"`
+	Secret  string             `mapstructure:"gh-webhook-secret" secret:"true"`
+	NotRef  string             `mapstructure:"slack-token" secret:"true"`
+	Tenants []tenant           `mapstructure:"tenants"`
+	Default *tenant            `mapstructure:"default"`
+	Refs    []tenant           `mapstructure:"-"`
+	Hosts   []string           `mapstructure:"hosts"`
+	Nested  struct{ A string } `mapstructure:"nested"`
+}
+
+func TestResolveFields(t *testing.T) {
+	Ok(t, os.Setenv("ATLANTIS_TEST_SECRET", "env-token"))
+	defer os.Unsetenv("ATLANTIS_TEST_SECRET") // nolint: errcheck
+
+	cfg := config{
+		// Only tagged fields are resolved.
+		User:   "env://ATLANTIS_TEST_SECRET",
+		Token:  "env://ATLANTIS_TEST_SECRET",
+		NotRef: "literal",
+		Tenants: []tenant{
+			{Name: "a", Token: "literal"},
+			{Name: "b", Token: "env://ATLANTIS_TEST_SECRET"},
+		},
+		Default: &tenant{Token: "env://ATLANTIS_TEST_SECRET"},
+		Refs:    []tenant{{Token: "env://ATLANTIS_TEST_SECRET"}},
+		Hosts:   []string{"env://ATLANTIS_TEST_SECRET"},
+	}
+	refs, err := secrets.ResolveFields(&cfg, &secrets.Resolver{})
+	Ok(t, err)
+	Equals(t, "env://ATLANTIS_TEST_SECRET", cfg.User)
+	Equals(t, "env-token", cfg.Token)
+	Equals(t, "", cfg.Secret)
+	Equals(t, "literal", cfg.NotRef)
+	Equals(t, "literal", cfg.Tenants[0].Token)
+	Equals(t, "env-token", cfg.Tenants[1].Token)
+	Equals(t, "env-token", cfg.Default.Token)
+	Equals(t, "env://ATLANTIS_TEST_SECRET", cfg.Refs[0].Token)
+	Equals(t, "env://ATLANTIS_TEST_SECRET", cfg.Hosts[0])
+
+	var fields []string
+	for _, ref := range refs {
+		Equals(t, "env://ATLANTIS_TEST_SECRET", ref.Ref)
+		fields = append(fields, ref.Field)
+	}
+	Equals(t, []string{"gh-token", "tenants[1].gh-token", "default.gh-token"}, fields)
+
+	cfg = config{Secret: "env://ATLANTIS_TEST_UNSET"}
+	_, err = secrets.ResolveFields(&cfg, &secrets.Resolver{})
+	ErrEquals(t, "resolving gh-webhook-secret: environment variable ATLANTIS_TEST_UNSET isn't set", err)
+
+	_, err = secrets.ResolveFields(cfg, &secrets.Resolver{})
+	ErrEquals(t, "expected a pointer to a struct, got secrets_test.config", err)
+}
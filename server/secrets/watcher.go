@@ -0,0 +1,80 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// Watcher resolves the references to secrets again so that rotated secrets
+// are picked up without restarting Atlantis.
+type Watcher struct {
+	Resolver *Resolver
+	// Refs are the references resolved on startup.
+	Refs   []Ref
+	Logger logging.SimpleLogging
+	// Interval is how often the references are resolved by Run.
+	Interval time.Duration
+	// OnRotate are called with the new secret of their field, by field name,
+	// when it's rotated. If a rotated field has none, Atlantis has to be
+	// restarted to use the new secret and a warning is logged.
+	OnRotate map[string]func(value string) error
+
+	mutex sync.Mutex
+}
+
+// Run resolves the references every Interval until ctx is done.
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.Check(); err != nil {
+				w.Logger.Err("failed refreshing secrets: %s", err)
+			}
+		}
+	}
+}
+
+// Check resolves the references again and handles the rotated secrets. It's
+// also run on SIGHUP so rotated secrets can be picked up immediately. If a
+// reference can't be resolved, its previous secret is kept.
+func (w *Watcher) Check() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	var errs []string
+	for i := range w.Refs {
+		ref := &w.Refs[i]
+		value, err := w.Resolver.Resolve(ref.Ref)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("resolving %s: %s", ref.Field, err))
+			continue
+		}
+		if value == ref.value {
+			continue
+		}
+		onRotate, ok := w.OnRotate[ref.Field]
+		if !ok {
+			w.Logger.Warn("%s was rotated: restart Atlantis to use the new secret", ref.Field)
+			ref.value = value
+			continue
+		}
+		if err := onRotate(value); err != nil {
+			errs = append(errs, fmt.Sprintf("rotating %s: %s", ref.Field, err))
+			continue
+		}
+		w.Logger.Info("rotated %s", ref.Field)
+		ref.value = value
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package secrets_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/secrets"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestWatcher_Check(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	tokenFile := filepath.Join(tmp, "token")
+	secretFile := filepath.Join(tmp, "secret")
+	Ok(t, ioutil.WriteFile(tokenFile, []byte("token"), 0600))
+	Ok(t, ioutil.WriteFile(secretFile, []byte("secret"), 0600))
+
+	cfg := config{Token: "file://" + tokenFile, Secret: "file://" + secretFile}
+	resolver := &secrets.Resolver{}
+	refs, err := secrets.ResolveFields(&cfg, resolver)
+	Ok(t, err)
+	var rotated []string
+	w := &secrets.Watcher{
+		Resolver: resolver,
+		Refs:     refs,
+		Logger:   logging.NewNoopLogger(t),
+		OnRotate: map[string]func(string) error{
+			"gh-token": func(token string) error {
+				rotated = append(rotated, token)
+				return nil
+			},
+		},
+	}
+
+	// Unchanged secrets aren't rotated.
+	Ok(t, w.Check())
+	Equals(t, 0, len(rotated))
+
+	// Rotated secrets with a handler are passed to it, the others are only
+	// logged.
+	Ok(t, ioutil.WriteFile(tokenFile, []byte("rotated"), 0600))
+	Ok(t, ioutil.WriteFile(secretFile, []byte("rotated"), 0600))
+	Ok(t, w.Check())
+	Equals(t, []string{"rotated"}, rotated)
+	Ok(t, w.Check())
+	Equals(t, []string{"rotated"}, rotated)
+
+	// If a secret can't be resolved, the previous one is kept.
+	Ok(t, os.Remove(tokenFile))
+	ErrEquals(t, "resolving gh-token: reading "+tokenFile+": open "+tokenFile+": no such file or directory", w.Check())
+	Ok(t, ioutil.WriteFile(tokenFile, []byte("rotated"), 0600))
+	Ok(t, w.Check())
+	Equals(t, []string{"rotated"}, rotated)
+}
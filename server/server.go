@@ -17,17 +17,20 @@ package server
 
 import (
 	"context"
-	"encoding/json"
+	"encoding/base64"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
-	"sort"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -38,22 +41,39 @@ import (
 	assetfs "github.com/elazarl/go-bindata-assetfs"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/agent"
+	"github.com/runatlantis/atlantis/server/auth"
 	"github.com/runatlantis/atlantis/server/controllers"
 	events_controllers "github.com/runatlantis/atlantis/server/controllers/events"
 	"github.com/runatlantis/atlantis/server/controllers/templates"
+	"github.com/runatlantis/atlantis/server/deliveries"
 	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/audit"
+	"github.com/runatlantis/atlantis/server/events/authz"
+	"github.com/runatlantis/atlantis/server/events/credentials"
+	"github.com/runatlantis/atlantis/server/events/limits"
 	"github.com/runatlantis/atlantis/server/events/locking"
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/planstore"
 	"github.com/runatlantis/atlantis/server/events/runtime"
+	runtime_models "github.com/runatlantis/atlantis/server/events/runtime/models"
 	"github.com/runatlantis/atlantis/server/events/runtime/policy"
 	"github.com/runatlantis/atlantis/server/events/terraform"
+	"github.com/runatlantis/atlantis/server/events/vault"
 	"github.com/runatlantis/atlantis/server/events/vcs"
 	"github.com/runatlantis/atlantis/server/events/vcs/bitbucketcloud"
 	"github.com/runatlantis/atlantis/server/events/vcs/bitbucketserver"
 	"github.com/runatlantis/atlantis/server/events/webhooks"
 	"github.com/runatlantis/atlantis/server/events/yaml"
+	"github.com/runatlantis/atlantis/server/jobs"
+	"github.com/runatlantis/atlantis/server/kubernetes"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/metrics"
+	"github.com/runatlantis/atlantis/server/ratelimit"
+	"github.com/runatlantis/atlantis/server/secrets"
 	"github.com/runatlantis/atlantis/server/static"
+	"github.com/runatlantis/atlantis/server/tracing"
+	"github.com/runatlantis/atlantis/server/workerpool"
 	"github.com/urfave/cli"
 	"github.com/urfave/negroni"
 )
@@ -67,6 +87,12 @@ const (
 	// route. ex:
 	//   mux.Router.Get(LockViewRouteName).URL(LockViewRouteIDQueryParam, "my id")
 	LockViewRouteIDQueryParam = "id"
+	// LogViewRouteName is the named route in mux.Router for the log view.
+	// Its path has an {id} variable.
+	LogViewRouteName = "log-detail"
+	// PlanJSONRouteName is the named route in mux.Router for plans rendered
+	// as JSON.
+	PlanJSONRouteName = "plan-json"
 
 	// binDirName is the name of the directory inside our data dir where
 	// we download binaries.
@@ -75,6 +101,26 @@ const (
 	// terraformPluginCacheDir is the name of the dir inside our data dir
 	// where we tell terraform to cache plugins and modules.
 	TerraformPluginCacheDirName = "plugin-cache"
+
+	// InitCacheDirName is the name of the dir inside our data dir where the
+	// .terraform dirs of projects are cached.
+	InitCacheDirName = "init-cache"
+
+	// TenantsDirName is the name of the dir inside our data dir where each
+	// tenant's data dir is created.
+	TenantsDirName = "tenants"
+
+	// CommandJournalDirName is the name of the dir inside our data dir where
+	// the commands in progress are recorded.
+	CommandJournalDirName = "journal"
+
+	// PullWorkspacesDirName is the name of the dir inside our data dir where
+	// the workspaces created for pull requests are recorded.
+	PullWorkspacesDirName = "pr-workspaces"
+
+	// TenantPathPrefix is the path under which each tenant's routes are
+	// served, ex. /tenants/{name}/events.
+	TenantPathPrefix = "/tenants/"
 )
 
 // Server runs the Atlantis web server.
@@ -92,11 +138,90 @@ type Server struct {
 	GithubAppController           *controllers.GithubAppController
 	LocksController               *controllers.LocksController
 	StatusController              *controllers.StatusController
-	IndexTemplate                 templates.TemplateWriter
-	LockDetailTemplate            templates.TemplateWriter
-	SSLCertFile                   string
-	SSLKeyFile                    string
-	Drainer                       *events.Drainer
+	// HealthController serves /healthz and /readyz. If nil, /healthz always
+	// returns ok.
+	HealthController   *controllers.HealthController
+	IndexTemplate      templates.TemplateWriter
+	LockDetailTemplate templates.TemplateWriter
+	SSLCertFile        string
+	SSLKeyFile         string
+	Drainer            *events.Drainer
+	// OIDCIssuer issues tokens for cloud credentials. If nil, Atlantis
+	// doesn't act as an OIDC issuer.
+	OIDCIssuer *credentials.Issuer
+	// APIController handles the versioned API. If nil, no API tokens are
+	// configured and the API is disabled.
+	APIController *controllers.APIController
+	// LogsController serves the output of project commands.
+	LogsController *controllers.LogsController
+	// Outputs is the output of recent project commands.
+	Outputs *jobs.OutputStore
+	// PullsController serves the dashboard of open pull requests.
+	PullsController *controllers.PullsController
+	// PlansController serves the plans of pull requests rendered as JSON.
+	PlansController *controllers.PlansController
+	// WebAuth requires users to log in to the web UI. If nil, the web UI
+	// isn't authenticated.
+	WebAuth *auth.OIDC
+	// Tracer records traces of commands. Its remaining spans are exported on
+	// shutdown.
+	Tracer *tracing.Tracer
+	// RepoConfigReloader reloads the server-side repo config on SIGHUP. If
+	// nil, --repo-config isn't set and SIGHUPs are ignored.
+	RepoConfigReloader *RepoConfigReloader
+	// Maintenance rejects applies during the configured maintenance windows.
+	// If nil, there are none.
+	Maintenance *locking.MaintenanceSchedule
+	// LockExpirer reminds or releases locks older than --lock-ttl. If nil,
+	// locks never expire.
+	LockExpirer *events.LockExpirer
+	// OrphanCollector cleans up after pull requests closed without Atlantis
+	// receiving their webhook. If nil, --orphan-cleanup-interval isn't set.
+	OrphanCollector *events.OrphanCollector
+	// BoltDBMaintainer checks and compacts the BoltDB file. If nil, BoltDB
+	// isn't used.
+	BoltDBMaintainer *events.BoltDBMaintainer
+	// GitlabTokenWatcher reloads the GitLab token from --gitlab-token-file
+	// and warns before it expires. If nil, GitLab isn't configured or
+	// --gitlab-token-check-interval is 0.
+	GitlabTokenWatcher *events.GitlabTokenWatcher
+	// SecretsWatcher resolves the references to secrets in the config again
+	// to pick up rotated secrets. If nil, there are none.
+	SecretsWatcher *secrets.Watcher
+	// secretHooks are the OnRotate hooks of SecretsWatcher. Tenant servers
+	// have them even though they have no watcher so that the top-level one
+	// can rotate their secrets.
+	secretHooks map[string]func(string) error
+	// DiskQuota keeps the data dir under --data-dir-quota-mb. If nil, it's
+	// not set.
+	DiskQuota *events.DiskQuota
+	// DriftDetector runs the configured drift checks. If nil, there are
+	// none.
+	DriftDetector *events.DriftDetector
+	// DriftController serves the drift dashboard.
+	DriftController *controllers.DriftController
+	// CrashRecovery handles the commands interrupted by the last shutdown. If
+	// nil, --disable-crash-recovery is set.
+	CrashRecovery *events.CrashRecovery
+	// AgentServer runs the commands sent by the server with --agent-addrs
+	// pointing to this one. If nil, --agent-port isn't set.
+	AgentServer *agent.Server
+	// AgentPort is the port AgentServer is served on.
+	AgentPort int
+	// ShutdownTimeout is how long in-progress operations have to complete on
+	// shutdown before their commands are aborted. If 0, Atlantis waits for
+	// them forever.
+	ShutdownTimeout time.Duration
+	// Tenants are the organizations hosted by this server in addition to the
+	// top-level one. Each has its own server, so nothing is shared between
+	// them.
+	Tenants []Tenant
+}
+
+// Tenant is an organization hosted by the server.
+type Tenant struct {
+	Name   string
+	Server *Server
 }
 
 // Config holds config for server that isn't passed in by the user.
@@ -109,39 +234,253 @@ type Config struct {
 	SilenceForkPRErrorsFlag string
 }
 
+// APITokenConfig is nested within UserConfig. It's used to configure the
+// tokens that can access the API.
+type APITokenConfig struct {
+	// Name identifies the token in logs and jobs.
+	Name string `mapstructure:"name"`
+	// Token is the secret sent as a bearer token.
+	Token string `mapstructure:"token" secret:"true"`
+	// Scopes are what the token is allowed to do, ex. plan.
+	Scopes []string `mapstructure:"scopes"`
+}
+
+// AzureDevopsOrgConfig is nested within UserConfig. It's used to configure
+// the credentials of an Azure DevOps organization that differ from the
+// top-level ones, so one server can serve several organizations.
+type AzureDevopsOrgConfig struct {
+	// Name is the organization's name, ie. the first part of the full names
+	// of its repos. It's case-insensitive.
+	Name            string `mapstructure:"name"`
+	Token           string `mapstructure:"token" secret:"true"`
+	User            string `mapstructure:"user"`
+	WebhookPassword string `mapstructure:"webhook-password" secret:"true"`
+	WebhookSecret   string `mapstructure:"webhook-secret" secret:"true"`
+	WebhookUser     string `mapstructure:"webhook-user"`
+}
+
+// TenantConfig is nested within UserConfig. It's used to configure a tenant:
+// an organization that's hosted on the same server but isolated from the
+// others. The mapstructure tags correspond to the flags that each tenant sets
+// for itself.
+type TenantConfig struct {
+	// Name identifies the tenant. Its routes are served under
+	// /tenants/{name}, ex. its webhooks are sent to /tenants/{name}/events,
+	// and its data is stored in {data-dir}/tenants/{name}.
+	Name                       string                 `mapstructure:"name"`
+	APITokens                  []APITokenConfig       `mapstructure:"api-tokens"`
+	AuditLogFile               string                 `mapstructure:"audit-log-file"`
+	AuditLogSQLURL             string                 `mapstructure:"audit-log-sql-url" secret:"true"`
+	AuditLogWebhookURL         string                 `mapstructure:"audit-log-webhook-url" secret:"true"`
+	AzureDevopsOrgs            []AzureDevopsOrgConfig `mapstructure:"azuredevops-orgs"`
+	AzureDevopsToken           string                 `mapstructure:"azuredevops-token" secret:"true"`
+	AzureDevopsUser            string                 `mapstructure:"azuredevops-user"`
+	AzureDevopsWebhookPassword string                 `mapstructure:"azuredevops-webhook-password" secret:"true"`
+	AzureDevopsWebhookSecret   string                 `mapstructure:"azuredevops-webhook-secret" secret:"true"`
+	AzureDevopsWebhookUser     string                 `mapstructure:"azuredevops-webhook-user"`
+	BitbucketAuthType          string                 `mapstructure:"bitbucket-auth-type"`
+	BitbucketBaseURL           string                 `mapstructure:"bitbucket-base-url"`
+	BitbucketOAuthKey          string                 `mapstructure:"bitbucket-oauth-key"`
+	BitbucketOAuthSecret       string                 `mapstructure:"bitbucket-oauth-secret" secret:"true"`
+	BitbucketToken             string                 `mapstructure:"bitbucket-token" secret:"true"`
+	BitbucketUser              string                 `mapstructure:"bitbucket-user"`
+	BitbucketWebhookSecret     string                 `mapstructure:"bitbucket-webhook-secret" secret:"true"`
+	GithubHostname             string                 `mapstructure:"gh-hostname"`
+	GithubToken                string                 `mapstructure:"gh-token" secret:"true"`
+	GithubUser                 string                 `mapstructure:"gh-user"`
+	GithubWebhookSecret        string                 `mapstructure:"gh-webhook-secret" secret:"true"`
+	GithubOrg                  string                 `mapstructure:"gh-org"`
+	GithubAppID                int64                  `mapstructure:"gh-app-id"`
+	GithubAppKey               string                 `mapstructure:"gh-app-key-file"`
+	GithubAppSlug              string                 `mapstructure:"gh-app-slug"`
+	GitlabHostname             string                 `mapstructure:"gitlab-hostname"`
+	GitlabToken                string                 `mapstructure:"gitlab-token" secret:"true"`
+	GitlabTokenFile            string                 `mapstructure:"gitlab-token-file"`
+	GitlabTokenType            string                 `mapstructure:"gitlab-token-type"`
+	GitlabUser                 string                 `mapstructure:"gitlab-user"`
+	GitlabWebhookSecret        string                 `mapstructure:"gitlab-webhook-secret" secret:"true"`
+	RepoAllowlist              string                 `mapstructure:"repo-allowlist"`
+	RepoConfig                 string                 `mapstructure:"repo-config"`
+	RepoConfigJSON             string                 `mapstructure:"repo-config-json"`
+	SlackToken                 string                 `mapstructure:"slack-token" secret:"true"`
+	TFEHostname                string                 `mapstructure:"tfe-hostname"`
+	TFEToken                   string                 `mapstructure:"tfe-token" secret:"true"`
+	Webhooks                   []WebhookConfig        `mapstructure:"webhooks"`
+}
+
+// MaintenanceWindowConfig is nested within UserConfig. It's used to configure
+// a recurring period during which applies are rejected.
+type MaintenanceWindowConfig struct {
+	// Name identifies the window, ex. when overriding it.
+	Name string `mapstructure:"name"`
+	// Schedule is a cron expression of when the window starts, ex.
+	// "0 22 * * 5" for Fridays at 22:00.
+	Schedule string `mapstructure:"schedule"`
+	// Duration is how long the window lasts, ex. 56h.
+	Duration string `mapstructure:"duration"`
+	// Repos is a comma-separated list of the repos the window applies to in
+	// the format of --repo-allowlist. If empty, it applies to every repo.
+	Repos string `mapstructure:"repos"`
+	// Message is added to the comment rejecting applies, ex. to explain why.
+	Message string `mapstructure:"message"`
+}
+
+// ErrorHintConfig is nested within UserConfig. It's used to configure a
+// remediation hint added to the comments of errors matching a regex.
+type ErrorHintConfig struct {
+	// Name identifies the hint. If it's the name of a default hint, ex.
+	// state-lock, it replaces it.
+	Name string `mapstructure:"name"`
+	// Pattern is a regex matched against the error's output.
+	Pattern string `mapstructure:"pattern"`
+	// Hint is added to the comment. If empty, the default hint with the same
+	// name is disabled.
+	Hint string `mapstructure:"hint"`
+}
+
+// DriftCheckConfig is nested within UserConfig. It's used to configure
+// periodic plans of a branch that detect drift.
+type DriftCheckConfig struct {
+	// Name identifies the check, ex. when running it through the API.
+	Name string `mapstructure:"name"`
+	// Schedule is a cron expression of when the check runs, ex. "0 6 * * *"
+	// for every day at 06:00.
+	Schedule string `mapstructure:"schedule"`
+	// VCS is the VCS host of the repo, ex. github. Can be omitted if only
+	// one VCS host is configured.
+	VCS string `mapstructure:"vcs"`
+	// Repository is the repo's full name, ex. owner/repo.
+	Repository string `mapstructure:"repository"`
+	// Branch is the branch to plan, usually the default branch.
+	Branch   string                    `mapstructure:"branch"`
+	Projects []DriftCheckProjectConfig `mapstructure:"projects"`
+	// Issues, if set, opens an issue in the repo for each project that
+	// drifted.
+	Issues *DriftIssuesConfig `mapstructure:"issues"`
+}
+
+// DriftCheckProjectConfig identifies a project of a drift check either by name
+// or by dir and workspace.
+type DriftCheckProjectConfig struct {
+	Name      string `mapstructure:"name"`
+	Dir       string `mapstructure:"dir"`
+	Workspace string `mapstructure:"workspace"`
+	// Assignees are the users that own the project. If set, they replace the
+	// assignees of the check's issues.
+	Assignees []string `mapstructure:"assignees"`
+}
+
+// DriftIssuesConfig is nested within DriftCheckConfig. It's used to configure
+// the issues opened for projects that drifted.
+type DriftIssuesConfig struct {
+	Labels    []string `mapstructure:"labels"`
+	Assignees []string `mapstructure:"assignees"`
+}
+
 // WebhookConfig is nested within UserConfig. It's used to configure webhooks.
 type WebhookConfig struct {
-	// Event is the type of event we should send this webhook for, ex. apply.
+	// Event is the type of event we should send this webhook for, ex. apply
+	// or plan-errored.
 	Event string `mapstructure:"event"`
 	// WorkspaceRegex is a regex that is used to match against the workspace
 	// that is being modified for this event. If the regex matches, we'll
 	// send the webhook, ex. "production.*".
 	WorkspaceRegex string `mapstructure:"workspace-regex"`
-	// Kind is the type of webhook we should send, ex. slack.
+	// RepoRegex is a regex that is matched against the full name of the
+	// repo, ex. to route each repo's alerts to its team. If empty, we send
+	// the webhook for every repo.
+	RepoRegex string `mapstructure:"repo-regex"`
+	// Kind is the type of webhook we should send, ex. slack, msteams, http,
+	// sns, eventbridge, pagerduty or opsgenie.
 	Kind string `mapstructure:"kind"`
 	// Channel is the channel to send this webhook to. It only applies to
-	// slack webhooks. Should be without '#'.
+	// slack webhooks. Should be without '#'. It can be a template, ex.
+	// "deploys-{{ .Workspace }}", to route events by workspace.
 	Channel string `mapstructure:"channel"`
+	// URL is the URL to send this webhook to. It only applies to msteams
+	// webhooks, for which it's the incoming webhook URL and, like Channel,
+	// can be a template, and http webhooks. For pagerduty and opsgenie
+	// webhooks it overrides the URL of their API.
+	URL string `mapstructure:"url" secret:"true"`
+	// Secret signs http webhooks so receivers can verify they were sent by
+	// Atlantis.
+	Secret string `mapstructure:"secret" secret:"true"`
+	// TopicARN is the ARN of the topic sns webhooks publish to.
+	TopicARN string `mapstructure:"topic-arn"`
+	// EventBus is the name or ARN of the event bus eventbridge webhooks put
+	// events on. Defaults to the default event bus.
+	EventBus string `mapstructure:"event-bus"`
+	// RoutingKey is the integration key of the PagerDuty service pagerduty
+	// webhooks trigger incidents of.
+	RoutingKey string `mapstructure:"routing-key" secret:"true"`
+	// APIKey is the key of the Opsgenie API integration opsgenie webhooks
+	// create alerts with.
+	APIKey string `mapstructure:"api-key" secret:"true"`
+	// Template is a Go template for the text of messages, ex.
+	// "{{ .Repo.FullName }}#{{ .Pull.Num }} failed". If empty, a default text
+	// is used.
+	Template string `mapstructure:"template"`
+	// Thread posts the events of a pull request after the first one as
+	// replies in its thread. It only applies to slack webhooks.
+	Thread bool `mapstructure:"thread"`
+	// Digest posts one message per pull request summarizing the latest
+	// result of each project and updates it in place. It only applies to
+	// slack webhooks.
+	Digest bool `mapstructure:"digest"`
+	// FailuresOnly only sends the webhook for commands that failed.
+	FailuresOnly bool `mapstructure:"failures-only"`
 }
 
 // NewServer returns a new server. If there are issues starting the server or
 // its dependencies an error will be returned. This is like the main() function
 // for the server CLI command because it injects all the dependencies.
 func NewServer(userConfig UserConfig, config Config) (*Server, error) {
-	logger, err := logging.NewStructuredLoggerFromLevel(userConfig.ToLogLevel())
+	s, err := newServer(userConfig, config, "")
+	if err != nil {
+		return nil, err
+	}
+	for i, t := range userConfig.Tenants {
+		tenantServer, err := newServer(userConfig.ForTenant(t), config, t.Name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "initializing tenant %q", t.Name)
+		}
+		// The tenants' secrets are resolved with the top-level config so
+		// their fields are prefixed with the tenant's.
+		for field, hook := range tenantServer.secretHooks {
+			s.secretHooks[fmt.Sprintf("tenants[%d].%s", i, field)] = hook
+		}
+		s.Tenants = append(s.Tenants, Tenant{Name: t.Name, Server: tenantServer})
+	}
+	return s, nil
+}
+
+// newServer returns a server for userConfig. If tenant is set, the server is
+// for that tenant and its logs are tagged with it.
+func newServer(userConfig UserConfig, config Config, tenant string) (*Server, error) {
+	structuredLogger, err := logging.NewStructuredLoggerWithFormat(userConfig.ToLogLevel(), userConfig.LogFormat)
 
 	if err != nil {
 		return nil, err
 	}
+	var logger logging.SimpleLogging = structuredLogger
+	if tenant != "" {
+		logger = structuredLogger.With("tenant", tenant)
+	}
 
 	var supportedVCSHosts []models.VCSHostType
 	var githubClient *vcs.GithubClient
 	var githubAppEnabled bool
 	var githubCredentials vcs.GithubCredentials
+	var githubUserCredentials *vcs.GithubUserCredentials
 	var gitlabClient *vcs.GitlabClient
 	var bitbucketCloudClient *bitbucketcloud.Client
+	var bitbucketCloudCredentials *bitbucketcloud.Credentials
 	var bitbucketServerClient *bitbucketserver.Client
-	var azuredevopsClient *vcs.AzureDevopsClient
+	var azuredevopsClient *vcs.AzureDevopsOrgClients
+	vcsRateLimits := vcs.NewRateLimits()
+	// secretHooks swap the secrets that can be rotated without restarting,
+	// by the name of their field.
+	secretHooks := map[string]func(string) error{}
 
 	policyChecksEnabled := false
 	if userConfig.EnablePolicyChecksFlag {
@@ -152,10 +491,22 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 	if userConfig.GithubUser != "" || userConfig.GithubAppID != 0 {
 		supportedVCSHosts = append(supportedVCSHosts, models.Github)
 		if userConfig.GithubUser != "" {
-			githubCredentials = &vcs.GithubUserCredentials{
+			githubUserCredentials = &vcs.GithubUserCredentials{
 				User:  userConfig.GithubUser,
 				Token: userConfig.GithubToken,
 			}
+			githubCredentials = githubUserCredentials
+			secretHooks["gh-token"] = func(token string) error {
+				githubUserCredentials.SetToken(token)
+				if !userConfig.WriteGitCreds {
+					return nil
+				}
+				home, err := homedir.Dir()
+				if err != nil {
+					return errors.Wrap(err, "getting home dir to write ~/.git-credentials file")
+				}
+				return events.WriteGitCreds(userConfig.GithubUser, token, userConfig.GithubHostname, home, logger, true)
+			}
 		} else if userConfig.GithubAppID != 0 {
 			githubCredentials = &vcs.GithubAppCredentials{
 				AppID:    userConfig.GithubAppID,
@@ -171,14 +522,27 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		if err != nil {
 			return nil, err
 		}
+		githubClient.MaxCommentLength = userConfig.GithubMaxCommentLen
+		githubClient.TrackRateLimits(vcsRateLimits)
 	}
+	var gitlabCredentials *vcs.GitlabCredentials
 	if userConfig.GitlabUser != "" {
 		supportedVCSHosts = append(supportedVCSHosts, models.Gitlab)
 		var err error
+		gitlabCredentials, err = vcs.NewGitlabCredentials(userConfig.GitlabTokenType, userConfig.GitlabToken, userConfig.GitlabTokenFile)
+		if err != nil {
+			return nil, err
+		}
+		userConfig.GitlabToken = gitlabCredentials.Token()
 		gitlabClient, err = vcs.NewGitlabClient(userConfig.GitlabHostname, userConfig.GitlabToken, logger)
 		if err != nil {
 			return nil, err
 		}
+		gitlabClient.UseCredentials(gitlabCredentials)
+		gitlabClient.MaxCommentLength = userConfig.GitlabMaxCommentLen
+		gitlabClient.PlanDiscussions = userConfig.HidePrevPlanComments
+		gitlabClient.User = userConfig.GitlabUser
+		gitlabClient.TrackRateLimits(vcsRateLimits)
 	}
 	if userConfig.BitbucketUser != "" {
 		if userConfig.BitbucketBaseURL == bitbucketcloud.BaseURL {
@@ -188,6 +552,18 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 				userConfig.BitbucketUser,
 				userConfig.BitbucketToken,
 				userConfig.AtlantisURL)
+			bitbucketCloudClient.MaxCommentLength = userConfig.BitbucketMaxCommentLen
+			if userConfig.BitbucketAuthType != bitbucketcloud.AppPasswordAuth {
+				bitbucketCloudCredentials = &bitbucketcloud.Credentials{
+					Type:        userConfig.BitbucketAuthType,
+					Username:    userConfig.BitbucketUser,
+					Password:    userConfig.BitbucketToken,
+					OAuthKey:    userConfig.BitbucketOAuthKey,
+					OAuthSecret: userConfig.BitbucketOAuthSecret,
+					HTTPClient:  http.DefaultClient,
+				}
+				bitbucketCloudClient.Credentials = bitbucketCloudCredentials
+			}
 		} else {
 			supportedVCSHosts = append(supportedVCSHosts, models.BitbucketServer)
 			var err error
@@ -200,15 +576,24 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 			if err != nil {
 				return nil, errors.Wrapf(err, "setting up Bitbucket Server client")
 			}
+			bitbucketServerClient.MaxCommentLength = userConfig.BitbucketMaxCommentLen
 		}
 	}
-	if userConfig.AzureDevopsUser != "" {
+	if userConfig.AzureDevopsUser != "" || len(userConfig.AzureDevopsOrgs) > 0 {
 		supportedVCSHosts = append(supportedVCSHosts, models.AzureDevops)
-		var err error
-		azuredevopsClient, err = vcs.NewAzureDevopsClient("dev.azure.com", userConfig.AzureDevopsUser, userConfig.AzureDevopsToken)
+		azuredevopsClient = &vcs.AzureDevopsOrgClients{}
+		if userConfig.AzureDevopsUser != "" {
+			azuredevopsClient.Default, err = vcs.NewAzureDevopsClient("dev.azure.com", userConfig.AzureDevopsUser, userConfig.AzureDevopsToken)
+			if err != nil {
+				return nil, err
+			}
+			azuredevopsClient.Default.MaxCommentLength = userConfig.AzureDevopsMaxCommentLen
+		}
+		azuredevopsClient.Orgs, err = newAzureDevopsOrgClients(userConfig.AzureDevopsOrgs, userConfig.AzureDevopsMaxCommentLen)
 		if err != nil {
 			return nil, err
 		}
+		azuredevopsClient.TrackRateLimits(vcsRateLimits)
 	}
 
 	if userConfig.WriteGitCreds {
@@ -233,7 +618,14 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 			if bitbucketBaseURL == "https://api.bitbucket.org" {
 				bitbucketBaseURL = "bitbucket.org"
 			}
-			if err := events.WriteGitCreds(userConfig.BitbucketUser, userConfig.BitbucketToken, bitbucketBaseURL, home, logger, false); err != nil {
+			gitUser, gitToken := userConfig.BitbucketUser, userConfig.BitbucketToken
+			if bitbucketCloudCredentials != nil {
+				gitUser, gitToken, err = bitbucketCloudCredentials.GitCredentials()
+				if err != nil {
+					return nil, errors.Wrap(err, "getting Bitbucket Cloud credentials")
+				}
+			}
+			if err := events.WriteGitCreds(gitUser, gitToken, bitbucketBaseURL, home, logger, false); err != nil {
 				return nil, err
 			}
 		}
@@ -251,6 +643,17 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 			Event:          c.Event,
 			Kind:           c.Kind,
 			WorkspaceRegex: c.WorkspaceRegex,
+			RepoRegex:      c.RepoRegex,
+			URL:            c.URL,
+			Secret:         c.Secret,
+			TopicARN:       c.TopicARN,
+			EventBus:       c.EventBus,
+			RoutingKey:     c.RoutingKey,
+			APIKey:         c.APIKey,
+			Template:       c.Template,
+			Thread:         c.Thread,
+			Digest:         c.Digest,
+			FailuresOnly:   c.FailuresOnly,
 		}
 		webhooksConfig = append(webhooksConfig, config)
 	}
@@ -258,7 +661,20 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "initializing webhooks")
 	}
-	vcsClient := vcs.NewClientProxy(githubClient, gitlabClient, bitbucketCloudClient, bitbucketServerClient, azuredevopsClient)
+	headers, err := ParseHeaders(userConfig.TracingOTLPHeaders)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing tracing headers")
+	}
+	tracer := tracing.NewTracer(tracing.Config{
+		Endpoint:    userConfig.TracingOTLPEndpoint,
+		Headers:     headers,
+		ServiceName: userConfig.TracingServiceName,
+		Version:     config.AtlantisVersion,
+	}, logger)
+	vcsClient := vcs.NewInstrumentedClient(&vcs.ThrottledClient{
+		Client:     vcs.NewClientProxy(githubClient, gitlabClient, bitbucketCloudClient, bitbucketServerClient, azuredevopsClient),
+		RateLimits: vcsRateLimits,
+	}, tracer, logger)
 	commitStatusUpdater := &events.DefaultCommitStatusUpdater{Client: vcsClient, StatusName: userConfig.VCSStatusName}
 
 	binDir, err := mkSubDir(userConfig.DataDir, BinDirName)
@@ -268,11 +684,35 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 	}
 
 	cacheDir, err := mkSubDir(userConfig.DataDir, TerraformPluginCacheDirName)
+	if userConfig.TFPluginCacheDir != "" {
+		cacheDir, err = mkSubDir(userConfig.TFPluginCacheDir, "")
+	}
 
 	if err != nil {
 		return nil, err
 	}
 
+	// outputs keeps the output of project commands so they can be watched
+	// live from the UI.
+	outputs := jobs.NewOutputStore(jobs.DefaultMaxOutputs)
+	var jobRunner terraform.JobRunner
+	if userConfig.KubernetesJobTemplate != "" {
+		jobRunner, err = kubernetes.NewInClusterJobRunner(userConfig.KubernetesJobTemplate)
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing Kubernetes Jobs")
+		}
+	}
+	commandLimits, err := newCommandLimits(userConfig)
+	if err != nil {
+		return nil, err
+	}
+	var tfDownloader terraform.Downloader = &terraform.DefaultDownloader{}
+	if userConfig.TFDownloadGPGKeyFile != "" {
+		tfDownloader, err = terraform.NewSignedDownloader(tfDownloader, userConfig.TFDownloadGPGKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing Terraform downloads")
+		}
+	}
 	terraformClient, err := terraform.NewClient(
 		logger,
 		binDir,
@@ -282,39 +722,80 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		userConfig.DefaultTFVersion,
 		config.DefaultTFVersionFlag,
 		userConfig.TFDownloadURL,
-		&terraform.DefaultDownloader{},
-		true)
+		tfDownloader,
+		true,
+		outputs,
+		jobRunner,
+		commandLimits)
 	// The flag.Lookup call is to detect if we're running in a unit test. If we
 	// are, then we don't error out because we don't have/want terraform
 	// installed on our CI system where the unit tests run.
 	if err != nil && flag.Lookup("test.v") == nil {
 		return nil, errors.Wrap(err, "initializing terraform")
 	}
+	// Projects using TFE remote ops only have their runs' statuses checked and
+	// their applies confirmed through the API if we have a token for it.
+	var tfcRuns runtime.TFCRunClient
+	if userConfig.TFEToken != "" {
+		tfcRuns = runtime.NewTFCClient(userConfig.TFEHostname, userConfig.TFEToken)
+	}
 	markdownRenderer := &events.MarkdownRenderer{
 		GitlabSupportsCommonMark: gitlabClient.SupportsCommonMark(),
 		DisableApplyAll:          userConfig.DisableApplyAll,
 		DisableMarkdownFolding:   userConfig.DisableMarkdownFolding,
+		CompactPlanOutput:        userConfig.CompactPlanOutput,
+		AggregatedComments:       userConfig.AggregatedComments,
 		DisableApply:             userConfig.DisableApply,
 		DisableRepoLocking:       userConfig.DisableRepoLocking,
 	}
+	if userConfig.MarkdownTemplatesDir != "" {
+		markdownRenderer.Templates, err = events.LoadCommentTemplates(userConfig.MarkdownTemplatesDir)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading markdown templates")
+		}
+	}
+	markdownRenderer.ErrorHints, err = newErrorHints(userConfig.ErrorHints)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing error hints")
+	}
 
-	boltdb, err := db.New(userConfig.DataDir)
+	database, err := NewDatabase(userConfig, tenant)
 	if err != nil {
 		return nil, err
 	}
+	var boltDBMaintainer *events.BoltDBMaintainer
+	if boltDB, ok := database.(*db.BoltDB); ok {
+		boltDBMaintainer = &events.BoltDBMaintainer{
+			DB:     boltDB,
+			Logger: logger,
+			Now:    time.Now,
+		}
+		if userConfig.BoltDBMaintenanceInterval != "" {
+			if boltDBMaintainer.Interval, err = time.ParseDuration(userConfig.BoltDBMaintenanceInterval); err != nil {
+				return nil, errors.Wrap(err, "parsing BoltDB maintenance interval")
+			}
+		}
+	}
 	var lockingClient locking.Locker
 	var applyLockingClient locking.ApplyLocker
 	if userConfig.DisableRepoLocking {
 		lockingClient = locking.NewNoOpLocker()
 	} else {
-		lockingClient = locking.NewClient(boltdb)
+		lockingClient = &webhooks.Locker{
+			Locker: locking.NewClient(database),
+			Sender: webhooksManager,
+			Logger: logger,
+		}
 	}
-	applyLockingClient = locking.NewApplyClient(boltdb, userConfig.DisableApply)
+	applyLockingClient = locking.NewApplyClient(database, userConfig.DisableApply)
 	workingDirLocker := events.NewDefaultWorkingDirLocker()
 
 	var workingDir events.WorkingDir = &events.FileWorkspace{
-		DataDir:       userConfig.DataDir,
-		CheckoutMerge: userConfig.CheckoutStrategy == "merge",
+		DataDir:           userConfig.DataDir,
+		CheckoutMerge:     userConfig.CheckoutStrategy == "merge",
+		CheckoutDepth:     userConfig.CheckoutDepth,
+		SparseCheckout:    userConfig.SparseCheckout,
+		KeepPreviousClone: userConfig.KeepUnchangedPlans,
 	}
 	// provide fresh tokens before clone from the GitHub Apps integration, proxy workingDir
 	if githubAppEnabled {
@@ -327,6 +808,32 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 			GithubHostname: userConfig.GithubHostname,
 		}
 	}
+	// OAuth access tokens expire so they're written before each clone too.
+	if bitbucketCloudCredentials != nil && bitbucketCloudCredentials.Type == bitbucketcloud.OAuthAuth && userConfig.WriteGitCreds {
+		workingDir = &events.BitbucketOAuthWorkingDir{
+			WorkingDir:  workingDir,
+			Credentials: bitbucketCloudCredentials,
+		}
+	}
+	workingDir = &events.InstrumentedWorkingDir{
+		WorkingDir: workingDir,
+		Tracer:     tracer,
+	}
+	var planStore *planstore.Store
+	if userConfig.PlanStoreURL != "" {
+		var keyPrefix string
+		if tenant != "" {
+			keyPrefix = "tenants/" + tenant + "/"
+		}
+		planStore, err = planstore.NewFromURL(userConfig.PlanStoreURL, keyPrefix)
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing plan store")
+		}
+		workingDir = &events.PlanStoreWorkingDir{
+			WorkingDir: workingDir,
+			PlanStore:  planStore,
+		}
+	}
 
 	projectLocker := &events.DefaultProjectLocker{
 		Locker:    lockingClient,
@@ -337,7 +844,7 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		Logger:           logger,
 		WorkingDir:       workingDir,
 		WorkingDirLocker: workingDirLocker,
-		DB:               boltdb,
+		DB:               database,
 	}
 
 	parsedURL, err := ParseAtlantisURL(userConfig.AtlantisURL)
@@ -347,7 +854,7 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 	}
 	validator := &yaml.ParserValidator{}
 
-	globalCfg := valid.NewGlobalCfgFromArgs(
+	defaultGlobalCfg := valid.NewGlobalCfgFromArgs(
 		valid.GlobalCfgArgs{
 			AllowRepoCfg:       userConfig.AllowRepoConfig,
 			MergeableReq:       userConfig.RequireMergeable,
@@ -355,23 +862,54 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 			UnDivergedReq:      userConfig.RequireUnDiverged,
 			PolicyCheckEnabled: userConfig.EnablePolicyChecksFlag,
 		})
+	globalCfg := defaultGlobalCfg
 	if userConfig.RepoConfig != "" {
-		globalCfg, err = validator.ParseGlobalCfg(userConfig.RepoConfig, globalCfg)
+		globalCfg, err = validator.ParseGlobalCfg(userConfig.RepoConfig, defaultGlobalCfg)
 		if err != nil {
 			return nil, errors.Wrapf(err, "parsing %s file", userConfig.RepoConfig)
 		}
 	} else if userConfig.RepoConfigJSON != "" {
-		globalCfg, err = validator.ParseGlobalCfgJSON(userConfig.RepoConfigJSON, globalCfg)
+		globalCfg, err = validator.ParseGlobalCfgJSON(userConfig.RepoConfigJSON, defaultGlobalCfg)
 		if err != nil {
 			return nil, errors.Wrapf(err, "parsing --%s", config.RepoConfigJSONFlag)
 		}
 	}
+	globalCfgStore := valid.NewGlobalCfgStore(globalCfg)
+	// Only the --repo-config file can be reloaded since flags can't change
+	// while Atlantis is running.
+	var repoConfigReloader *RepoConfigReloader
+	if userConfig.RepoConfig != "" {
+		repoConfigReloader = &RepoConfigReloader{
+			Path:            userConfig.RepoConfig,
+			DefaultCfg:      defaultGlobalCfg,
+			Validator:       validator,
+			Store:           globalCfgStore,
+			Logger:          logger,
+			GitlabEnabled:   gitlabClient != nil,
+			TerraformClient: terraformClient,
+		}
+	}
+	// The versions are downloaded in the background so they don't delay
+	// startup.
+	if terraformClient != nil {
+		go terraformClient.PrewarmVersions(logger, globalCfg.TerraformVersions)
+	}
+
+	// Commands are only restricted to teams if the server-side repo config
+	// sets team_permissions. Since the file can be reloaded with
+	// team_permissions, the authorizer is always used if there's a file.
+	var commandAuthorizer events.CommandAuthorizer
+	if len(globalCfg.TeamNames()) > 0 || repoConfigReloader != nil {
+		commandAuthorizer = events.NewTeamCommandAuthorizer(globalCfgStore, vcsClient, events.DefaultTeamMembershipCacheTTL)
+	}
 
 	underlyingRouter := mux.NewRouter()
 	router := &Router{
 		AtlantisURL:               parsedURL,
 		LockViewRouteIDQueryParam: LockViewRouteIDQueryParam,
 		LockViewRouteName:         LockViewRouteName,
+		LogViewRouteName:          LogViewRouteName,
+		PlanJSONRouteName:         PlanJSONRouteName,
 		Underlying:                underlyingRouter,
 	}
 	pullClosedExecutor := &events.PullClosedExecutor{
@@ -379,20 +917,29 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		Locker:     lockingClient,
 		WorkingDir: workingDir,
 		Logger:     logger,
-		DB:         boltdb,
+		DB:         database,
 	}
 	eventParser := &events.EventParser{
 		GithubUser:         userConfig.GithubUser,
 		GithubToken:        userConfig.GithubToken,
+		GithubCredentials:  githubUserCredentials,
+		GithubHostname:     userConfig.GithubHostname,
 		GitlabUser:         userConfig.GitlabUser,
 		GitlabToken:        userConfig.GitlabToken,
+		GitlabCredentials:  gitlabCredentials,
+		GitlabHostname:     userConfig.GitlabHostname,
 		AllowDraftPRs:      userConfig.PlanDrafts,
 		BitbucketUser:      userConfig.BitbucketUser,
 		BitbucketToken:     userConfig.BitbucketToken,
 		BitbucketServerURL: userConfig.BitbucketBaseURL,
 		AzureDevopsUser:    userConfig.AzureDevopsUser,
 		AzureDevopsToken:   userConfig.AzureDevopsToken,
+		AzureDevopsOrgs:    make(map[string]events.AzureDevopsCredentials),
+	}
+	for _, org := range userConfig.AzureDevopsOrgs {
+		eventParser.AzureDevopsOrgs[strings.ToLower(org.Name)] = events.AzureDevopsCredentials{User: org.User, Token: org.Token}
 	}
+	eventParser.BitbucketCloudCredentials = bitbucketCloudCredentials
 	commentParser := &events.CommentParser{
 		GithubUser:      userConfig.GithubUser,
 		GitlabUser:      userConfig.GitlabUser,
@@ -406,15 +953,88 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		TerraformExecutor: terraformClient,
 		DefaultTFVersion:  defaultTfVersion,
 		TerraformBinDir:   terraformClient.TerraformBinDir(),
+		ContainerImage:    userConfig.RunStepContainerImage,
+		Limits:            commandLimits,
 	}
 	drainer := &events.Drainer{}
+	rejectedWebhooks := metrics.NewCounters()
+	rateLimitedWebhooks := metrics.NewCounters()
+	webhookWorkers := workerpool.New(userConfig.WebhookWorkers, userConfig.WebhookQueueSize)
+	var cleanedOrphans *metrics.Counters
+	if userConfig.OrphanCleanupInterval != "" {
+		cleanedOrphans = metrics.NewCounters()
+	}
+	var diskQuota *events.DiskQuota
+	if userConfig.DataDirQuotaMB > 0 {
+		diskQuota = &events.DiskQuota{
+			DataDir:          userConfig.DataDir,
+			QuotaBytes:       int64(userConfig.DataDirQuotaMB) * 1024 * 1024,
+			DB:               database,
+			WorkingDir:       workingDir,
+			WorkingDirLocker: workingDirLocker,
+			VCSClient:        vcsClient,
+			Logger:           logger,
+			Interval:         diskQuotaInterval,
+			Now:              time.Now,
+		}
+	}
+	var gitlabTokenWatcher *events.GitlabTokenWatcher
+	if gitlabClient != nil {
+		// Both durations were validated by the server command.
+		interval, _ := time.ParseDuration(userConfig.GitlabTokenCheckInterval)
+		warnBefore, _ := time.ParseDuration(userConfig.GitlabTokenExpiryWarning)
+		if interval > 0 {
+			gitlabTokenWatcher = &events.GitlabTokenWatcher{
+				Credentials: gitlabCredentials,
+				Client:      gitlabClient,
+				Logger:      logger,
+				Interval:    interval,
+				WarnBefore:  warnBefore,
+				GitHostname: userConfig.GitlabHostname,
+			}
+			if userConfig.WriteGitCreds {
+				gitlabTokenWatcher.GitUser = userConfig.GitlabUser
+			}
+		}
+	}
 	statusController := &controllers.StatusController{
+		Logger:              logger,
+		Drainer:             drainer,
+		RejectedWebhooks:    rejectedWebhooks,
+		RateLimitedWebhooks: rateLimitedWebhooks,
+		WebhookWorkers:      webhookWorkers,
+		CleanedOrphans:      cleanedOrphans,
+		BoltDB:              boltDBMaintainer,
+		DiskQuota:           diskQuota,
+		VCSRateLimits:       vcsRateLimits,
+		GitlabToken:         gitlabTokenWatcher,
+	}
+	healthController := &controllers.HealthController{
 		Logger:  logger,
 		Drainer: drainer,
+		LivenessChecks: []controllers.HealthCheck{
+			{Name: "data-dir", Check: func() error { return checkDirWritable(userConfig.DataDir) }},
+			{Name: "terraform", Check: terraformClient.CheckDefaultVersion},
+		},
+	}
+	if githubClient != nil {
+		healthController.ReadinessChecks = append(healthController.ReadinessChecks, controllers.HealthCheck{Name: "vcs/" + userConfig.GithubHostname, Check: githubClient.CheckConnection})
+	}
+	if gitlabClient != nil {
+		healthController.ReadinessChecks = append(healthController.ReadinessChecks, controllers.HealthCheck{Name: "vcs/" + userConfig.GitlabHostname, Check: gitlabClient.CheckConnection})
+	}
+	if bitbucketCloudClient != nil {
+		healthController.ReadinessChecks = append(healthController.ReadinessChecks, controllers.HealthCheck{Name: "vcs/bitbucket.org", Check: bitbucketCloudClient.CheckConnection})
+	}
+	if bitbucketServerClient != nil {
+		healthController.ReadinessChecks = append(healthController.ReadinessChecks, controllers.HealthCheck{Name: "vcs/" + userConfig.BitbucketBaseURL, Check: bitbucketServerClient.CheckConnection})
+	}
+	if azuredevopsClient != nil {
+		healthController.ReadinessChecks = append(healthController.ReadinessChecks, controllers.HealthCheck{Name: "vcs/dev.azure.com", Check: azuredevopsClient.CheckConnection})
 	}
 	preWorkflowHooksCommandRunner := &events.DefaultPreWorkflowHooksCommandRunner{
 		VCSClient:             vcsClient,
-		GlobalCfg:             globalCfg,
+		GlobalCfg:             globalCfgStore,
 		WorkingDirLocker:      workingDirLocker,
 		WorkingDir:            workingDir,
 		PreWorkflowHookRunner: runtime.DefaultPreWorkflowHookRunner{},
@@ -426,13 +1046,15 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		vcsClient,
 		workingDir,
 		workingDirLocker,
-		globalCfg,
+		globalCfgStore,
 		pendingPlanFinder,
 		commentParser,
 		userConfig.SkipCloneNoChanges,
 		userConfig.EnableRegExpCmd,
 		userConfig.AutoplanFileList,
 	)
+	projectCommandBuilder.PlanStore = planStore
+	projectCommandBuilder.KeepUnchangedPlans = userConfig.KeepUnchangedPlans
 
 	showStepRunner, err := runtime.NewShowStepRunner(terraformClient, defaultTfVersion)
 
@@ -449,18 +1071,106 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		return nil, errors.Wrap(err, "initializing policy check runner")
 	}
 
+	var oidcIssuer *credentials.Issuer
+	if userConfig.OIDCSigningKeyFile != "" {
+		oidcIssuer, err = credentials.NewIssuer(parsedURL.String(), userConfig.OIDCSigningKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing OIDC issuer")
+		}
+	}
+	credentialsProvider, err := credentials.NewDefaultProvider(oidcIssuer)
+	if err != nil {
+		return nil, errors.Wrap(err, "initializing cloud credentials provider")
+	}
+
+	var vaultClient vault.Client
+	if userConfig.VaultAddr != "" {
+		vaultClient, err = vault.NewDefaultClient(vault.Config{
+			Addr:       userConfig.VaultAddr,
+			Namespace:  userConfig.VaultNamespace,
+			AuthMethod: userConfig.VaultAuthMethod,
+			AuthMount:  userConfig.VaultAuthMount,
+			Token:      userConfig.VaultToken,
+			Role:       userConfig.VaultRole,
+			SecretID:   userConfig.VaultSecretID,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing Vault client")
+		}
+	}
+	var secretsWatcher *secrets.Watcher
+	if len(userConfig.SecretRefs) > 0 {
+		// The duration was validated by the server command.
+		interval, _ := time.ParseDuration(userConfig.SecretsRefreshInterval)
+		secretsWatcher = &secrets.Watcher{
+			Resolver: &secrets.Resolver{Vault: vaultClient},
+			Refs:     append([]secrets.Ref(nil), userConfig.SecretRefs...),
+			Logger:   logger,
+			Interval: interval,
+			// The hooks of the controllers are added once they're
+			// constructed and those of the tenants by NewServer.
+			OnRotate: secretHooks,
+		}
+	}
+	if gitlabCredentials != nil {
+		secretHooks["gitlab-token"] = func(token string) error {
+			gitlabCredentials.SetToken(token)
+			if !userConfig.WriteGitCreds {
+				return nil
+			}
+			home, err := homedir.Dir()
+			if err != nil {
+				return errors.Wrap(err, "getting home dir to write ~/.git-credentials file")
+			}
+			return events.WriteGitCreds(userConfig.GitlabUser, token, userConfig.GitlabHostname, home, logger, true)
+		}
+	}
+
+	var planEncryptor runtime.PlanEncryptor
+	if userConfig.PlanEncryptionKey != "" || userConfig.PlanEncryptionKMSKey != "" {
+		planEncryptor, err = newPlanEncryptor(userConfig)
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing plan encryption")
+		}
+	}
+	var planCompressor *runtime.PlanCompressor
+	if userConfig.PlanCompression != "" {
+		planCompressor, err = runtime.NewPlanCompressor(userConfig.PlanCompression)
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing plan compression")
+		}
+	}
+
+	var initCache *runtime.InitCache
+	if userConfig.EnableInitCache {
+		initCacheDir, err := mkSubDir(userConfig.DataDir, InitCacheDirName)
+		if err != nil {
+			return nil, err
+		}
+		initCache = &runtime.InitCache{Dir: initCacheDir}
+	}
+
+	// Projects can use the outputs of the projects they depend on.
+	dependencyOutputs := &events.DependencyOutputs{
+		TerraformExecutor: terraformClient,
+		DefaultTFVersion:  defaultTfVersion,
+		WorkingDir:        workingDir,
+	}
+
 	projectCommandRunner := &events.DefaultProjectCommandRunner{
 		Locker:           projectLocker,
 		LockURLGenerator: router,
 		InitStepRunner: &runtime.InitStepRunner{
 			TerraformExecutor: terraformClient,
 			DefaultTFVersion:  defaultTfVersion,
+			Cache:             initCache,
 		},
 		PlanStepRunner: &runtime.PlanStepRunner{
 			TerraformExecutor:   terraformClient,
 			DefaultTFVersion:    defaultTfVersion,
 			CommitStatusUpdater: commitStatusUpdater,
 			AsyncTFExec:         terraformClient,
+			TFCRuns:             tfcRuns,
 		},
 		ShowStepRunner:        showStepRunner,
 		PolicyCheckStepRunner: policyCheckRunner,
@@ -468,37 +1178,154 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 			TerraformExecutor:   terraformClient,
 			CommitStatusUpdater: commitStatusUpdater,
 			AsyncTFExec:         terraformClient,
+			TFCRuns:             tfcRuns,
 		},
 		RunStepRunner: runStepRunner,
 		EnvStepRunner: &runtime.EnvStepRunner{
 			RunStepRunner: runStepRunner,
 		},
+		Outputs:             outputs,
+		OutputURLGenerator:  router,
+		ConcurrencyLimiter:  events.NewConcurrencyLimiter(userConfig.RepoConcurrencyLimit, userConfig.ProjectConcurrencyLimit),
 		PullApprovedChecker: vcsClient,
+		PullApprovalsGetter: vcsClient,
+		PullPipelineGetter:  vcsClient,
+		CodeOwnersChecker:   &events.DefaultCodeOwnersChecker{VCSClient: vcsClient},
+		DenylistChecker: &runtime.DefaultDenylistChecker{
+			TerraformExecutor: terraformClient,
+			DefaultTFVersion:  defaultTfVersion,
+		},
+		RiskChecker: &runtime.DefaultRiskChecker{
+			TerraformExecutor: terraformClient,
+			DefaultTFVersion:  defaultTfVersion,
+		},
+		Linter: &runtime.DefaultLinter{
+			Exec: runtime_models.LocalExec{},
+		},
+		PlanEncryptor:       planEncryptor,
+		PlanCompressor:      planCompressor,
+		PlanStore:           planStore,
+		CredentialsProvider: credentialsProvider,
+		DependencyOutputs:   dependencyOutputs,
+		VaultClient:         vaultClient,
 		WorkingDir:          workingDir,
 		Webhooks:            webhooksManager,
 		WorkingDirLocker:    workingDirLocker,
 	}
+	// Plans rendered as JSON are only served with web auth, see Handler.
+	if userConfig.PlanJSONArtifacts && userConfig.WebOIDCIssuerURL != "" {
+		projectCommandRunner.PlanJSONURLGenerator = router
+	}
+	if userConfig.GithubDeployments && githubClient != nil {
+		projectCommandRunner.Deployments = &events.GithubDeployments{Client: githubClient}
+	}
+	if userConfig.BitbucketCodeInsights && bitbucketServerClient != nil {
+		projectCommandRunner.Insights = &events.BitbucketInsights{Client: bitbucketServerClient}
+	}
+
+	auditStore, err := newAuditStore(userConfig, database, logger)
+	if err != nil {
+		return nil, errors.Wrap(err, "initializing audit log")
+	}
+	var prjCmdRunner events.ProjectCommandRunner = projectCommandRunner
+	var agentServer *agent.Server
+	if userConfig.AgentPort != 0 {
+		// Projects are locked and webhooks sent by the server sending the
+		// commands.
+		agentRunner := *projectCommandRunner
+		agentRunner.Locker = agent.UnlockedProjectLocker{}
+		agentRunner.Webhooks = &webhooks.MultiWebhookSender{}
+		agentServer, err = agent.NewServer(&agentRunner, logger, userConfig.AgentToken, userConfig.SSLCertFile, userConfig.SSLKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing agent server")
+		}
+	}
+	if userConfig.AgentAddrs != "" {
+		var addrs []string
+		for _, addr := range strings.Split(userConfig.AgentAddrs, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				addrs = append(addrs, addr)
+			}
+		}
+		agentClient, err := agent.Dial(addrs, userConfig.AgentToken, userConfig.AgentInsecure)
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing agent client")
+		}
+		prjCmdRunner = &agent.ProjectCommandRunner{
+			Client:             agentClient,
+			Locker:             projectLocker,
+			LockURLGenerator:   router,
+			Webhooks:           webhooksManager,
+			ConcurrencyLimiter: projectCommandRunner.ConcurrencyLimiter,
+		}
+	}
+	var journal *events.CommandJournal
+	if !userConfig.DisableCrashRecovery {
+		journal, err = events.NewCommandJournal(filepath.Join(userConfig.DataDir, CommandJournalDirName))
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing command journal")
+		}
+		prjCmdRunner = &events.JournalProjectCommandRunner{
+			ProjectCommandRunner: prjCmdRunner,
+			Journal:              journal,
+		}
+	}
+	if userConfig.AuthzURL != "" {
+		prjCmdRunner = &events.AuthzProjectCommandRunner{
+			ProjectCommandRunner: prjCmdRunner,
+			Authorizer:           authz.NewOPAAuthorizer(userConfig.AuthzURL, userConfig.AuthzToken),
+		}
+	}
+	// Audit last so that authorization denials are recorded too.
+	if auditStore != nil {
+		prjCmdRunner = &events.AuditProjectCommandRunner{
+			ProjectCommandRunner: prjCmdRunner,
+			Store:                auditStore,
+		}
+	}
 
 	dbUpdater := &events.DBUpdater{
-		DB: boltdb,
+		DB: database,
 	}
 
 	pullUpdater := &events.PullUpdater{
 		HidePrevPlanComments: userConfig.HidePrevPlanComments,
 		VCSClient:            vcsClient,
 		MarkdownRenderer:     markdownRenderer,
+		MaxCommentLengths: map[models.VCSHostType]int{
+			models.Github:          userConfig.GithubMaxCommentLen,
+			models.Gitlab:          userConfig.GitlabMaxCommentLen,
+			models.BitbucketCloud:  userConfig.BitbucketMaxCommentLen,
+			models.BitbucketServer: userConfig.BitbucketMaxCommentLen,
+			models.AzureDevops:     userConfig.AzureDevopsMaxCommentLen,
+		},
+		CommentOverflow:    events.CommentOverflow(userConfig.CommentOverflow),
+		Outputs:            outputs,
+		OutputURLGenerator: router,
+	}
+	var shutdownTimeout time.Duration
+	if userConfig.ShutdownTimeout != "" {
+		if shutdownTimeout, err = time.ParseDuration(userConfig.ShutdownTimeout); err != nil {
+			return nil, errors.Wrap(err, "parsing shutdown timeout")
+		}
+	}
+	if userConfig.ProgressCommentInterval != "" {
+		if pullUpdater.ProgressInterval, err = time.ParseDuration(userConfig.ProgressCommentInterval); err != nil {
+			return nil, errors.Wrap(err, "parsing progress comment interval")
+		}
 	}
 
 	autoMerger := &events.AutoMerger{
 		VCSClient:       vcsClient,
 		GlobalAutomerge: userConfig.Automerge,
+		Templates:       markdownRenderer.Templates,
 	}
 
 	policyCheckCommandRunner := events.NewPolicyCheckCommandRunner(
 		dbUpdater,
 		pullUpdater,
 		commitStatusUpdater,
-		projectCommandRunner,
+		prjCmdRunner,
 		userConfig.ParallelPoolSize,
 		userConfig.SilenceVCSStatusNoProjects,
 	)
@@ -511,15 +1338,24 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		workingDir,
 		commitStatusUpdater,
 		projectCommandBuilder,
-		projectCommandRunner,
+		prjCmdRunner,
 		dbUpdater,
 		pullUpdater,
 		policyCheckCommandRunner,
 		autoMerger,
 		userConfig.ParallelPoolSize,
 		userConfig.SilenceNoProjects,
-		boltdb,
+		database,
 	)
+	planCommandRunner.PlanStore = planStore
+
+	var maintenance *locking.MaintenanceSchedule
+	if len(userConfig.MaintenanceWindows) > 0 {
+		maintenance, err = newMaintenanceSchedule(userConfig.MaintenanceWindows)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing maintenance windows")
+		}
+	}
 
 	applyCommandRunner := events.NewApplyCommandRunner(
 		vcsClient,
@@ -527,37 +1363,144 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		applyLockingClient,
 		commitStatusUpdater,
 		projectCommandBuilder,
-		projectCommandRunner,
+		prjCmdRunner,
 		autoMerger,
 		pullUpdater,
 		dbUpdater,
-		boltdb,
+		database,
 		userConfig.ParallelPoolSize,
 		userConfig.SilenceNoProjects,
 		userConfig.SilenceVCSStatusNoProjects,
 	)
+	applyCommandRunner.Maintenance = maintenance
+	if userConfig.AzureDevopsClosePlanThreads && azuredevopsClient != nil {
+		applyCommandRunner.PlanThreads = &events.AzureDevopsPlanThreads{Client: azuredevopsClient}
+	}
 
 	approvePoliciesCommandRunner := events.NewApprovePoliciesCommandRunner(
 		commitStatusUpdater,
 		projectCommandBuilder,
-		projectCommandRunner,
+		prjCmdRunner,
 		pullUpdater,
 		dbUpdater,
 		userConfig.SilenceNoProjects,
 		userConfig.SilenceVCSStatusNoPlans,
 	)
 
-	unlockCommandRunner := events.NewUnlockCommandRunner(
+	var unlockCommandRunner events.CommentCommandRunner = events.NewUnlockCommandRunner(
 		deleteLockCommand,
 		vcsClient,
 		userConfig.SilenceNoProjects,
 	)
+	if auditStore != nil {
+		unlockCommandRunner = &events.AuditCommentCommandRunner{
+			CommentCommandRunner: unlockCommandRunner,
+			Store:                auditStore,
+		}
+	}
+
+	var lockCommandRunner events.CommentCommandRunner = events.NewLockCommandRunner(
+		projectCommandBuilder,
+		lockingClient,
+		vcsClient,
+		userConfig.SilenceNoProjects,
+	)
+	if auditStore != nil {
+		lockCommandRunner = &events.AuditCommentCommandRunner{
+			CommentCommandRunner: lockCommandRunner,
+			Store:                auditStore,
+		}
+	}
+
+	var revertCommandRunner events.CommentCommandRunner = events.NewRevertCommandRunner(
+		vcsClient,
+		workingDir,
+		workingDirLocker,
+		preWorkflowHooksCommandRunner,
+		projectCommandBuilder,
+		prjCmdRunner,
+		deleteLockCommand,
+		markdownRenderer.Templates,
+		userConfig.SilenceNoProjects,
+	)
+	if auditStore != nil {
+		revertCommandRunner = &events.AuditCommentCommandRunner{
+			CommentCommandRunner: revertCommandRunner,
+			Store:                auditStore,
+		}
+	}
+
+	var pullWorkspaces *events.PullWorkspaces
+	if userConfig.DeletePRWorkspaces {
+		pullWorkspaces, err = events.NewPullWorkspaces(filepath.Join(userConfig.DataDir, PullWorkspacesDirName))
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing pull workspaces")
+		}
+	}
+	workspaceCommandRunner := events.NewWorkspaceCommandRunner(
+		projectCommandBuilder,
+		terraformClient,
+		workingDir,
+		workingDirLocker,
+		projectLocker,
+		projectCommandRunner,
+		vcsClient,
+		pullWorkspaces,
+		userConfig.SilenceNoProjects,
+	)
+	if pullWorkspaces != nil {
+		pullClosedExecutor.WorkspaceDeleter = workspaceCommandRunner
+	}
+	var workspaceCommentCommandRunner events.CommentCommandRunner = workspaceCommandRunner
+	if auditStore != nil {
+		workspaceCommentCommandRunner = &events.AuditCommentCommandRunner{
+			CommentCommandRunner: workspaceCommentCommandRunner,
+			Store:                auditStore,
+		}
+	}
+	validateCommandRunner := events.NewValidateCommandRunner(
+		projectCommandBuilder,
+		terraformClient,
+		workingDir,
+		workingDirLocker,
+		vcsClient,
+		userConfig.SilenceNoProjects,
+	)
+	if userConfig.ValidateBeforeAutoplan {
+		planCommandRunner.Validator = validateCommandRunner
+	}
+	var validateCommentCommandRunner events.CommentCommandRunner = validateCommandRunner
+	if auditStore != nil {
+		validateCommentCommandRunner = &events.AuditCommentCommandRunner{
+			CommentCommandRunner: validateCommentCommandRunner,
+			Store:                auditStore,
+		}
+	}
+	var fmtCommandRunner events.CommentCommandRunner = events.NewFmtCommandRunner(
+		projectCommandBuilder,
+		terraformClient,
+		workingDir,
+		workingDirLocker,
+		vcsClient,
+		userConfig.SilenceNoProjects,
+	)
+	if auditStore != nil {
+		fmtCommandRunner = &events.AuditCommentCommandRunner{
+			CommentCommandRunner: fmtCommandRunner,
+			Store:                auditStore,
+		}
+	}
 
 	commentCommandRunnerByCmd := map[models.CommandName]events.CommentCommandRunner{
 		models.PlanCommand:            planCommandRunner,
 		models.ApplyCommand:           applyCommandRunner,
 		models.ApprovePoliciesCommand: approvePoliciesCommandRunner,
 		models.UnlockCommand:          unlockCommandRunner,
+		models.LockCommand:            lockCommandRunner,
+		models.RevertCommand:          revertCommandRunner,
+		models.WorkspaceCommand:       workspaceCommentCommandRunner,
+		models.FmtCommand:             fmtCommandRunner,
+		models.ValidateCommand:        validateCommentCommandRunner,
 	}
 
 	commandRunner := &events.DefaultCommandRunner{
@@ -575,12 +1518,35 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		DisableAutoplan:               userConfig.DisableAutoplan,
 		Drainer:                       drainer,
 		PreWorkflowHooksCommandRunner: preWorkflowHooksCommandRunner,
-		PullStatusFetcher:             boltdb,
+		PullStatusFetcher:             database,
+		CommandAuthorizer:             commandAuthorizer,
+		Tracer:                        tracer,
+		DiskQuota:                     diskQuota,
+		InvalidatedPlansCommenter: &events.InvalidatedPlansCommenter{
+			VCSClient:         vcsClient,
+			PullStatusFetcher: database,
+			Templates:         markdownRenderer.Templates,
+		},
+	}
+	if userConfig.EnableReplicaCoordination {
+		leases, ok := database.(db.LeaseStore)
+		if !ok {
+			return nil, fmt.Errorf("replica coordination isn't supported by %s", userConfig.LockingDBType)
+		}
+		// Replicas usually have unique host names, ex. the names of their
+		// pods. They're stable across restarts, in which case crash recovery
+		// handles the interrupted commands.
+		serverID, err := os.Hostname()
+		if err != nil {
+			return nil, errors.Wrap(err, "getting host name to identify this server")
+		}
+		commandRunner.PullCoordinator = events.NewPullCoordinator(leases, serverID, logger)
 	}
 	repoAllowlist, err := events.NewRepoAllowlistChecker(userConfig.RepoAllowlist)
 	if err != nil {
 		return nil, err
 	}
+	projectCommandBuilder.RepoAllowlistChecker = repoAllowlist
 	locksController := &controllers.LocksController{
 		AtlantisVersion:    config.AtlantisVersion,
 		AtlantisURL:        parsedURL,
@@ -591,14 +1557,94 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		LockDetailTemplate: templates.LockTemplate,
 		WorkingDir:         workingDir,
 		WorkingDirLocker:   workingDirLocker,
-		DB:                 boltdb,
+		DB:                 database,
 		DeleteLockCommand:  deleteLockCommand,
+		Maintenance:        maintenance,
+	}
+	var lockExpirer *events.LockExpirer
+	if userConfig.LockTTL != "" {
+		lockTTL, err := time.ParseDuration(userConfig.LockTTL)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing lock TTL")
+		}
+		lockExpirer = &events.LockExpirer{
+			Locker:            lockingClient,
+			DeleteLockCommand: deleteLockCommand,
+			VCSClient:         vcsClient,
+			Logger:            logger,
+			TTL:               lockTTL,
+			AutoRelease:       userConfig.LockTTLAutoRelease,
+			Now:               time.Now,
+		}
+	}
+	var orphanCollector *events.OrphanCollector
+	if userConfig.OrphanCleanupInterval != "" {
+		interval, err := time.ParseDuration(userConfig.OrphanCleanupInterval)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing orphan cleanup interval")
+		}
+		orphanCollector = &events.OrphanCollector{
+			Locker:      lockingClient,
+			DB:          database,
+			VCSClient:   vcsClient,
+			WorkingDir:  workingDir,
+			PullCleaner: pullClosedExecutor,
+			Logger:      logger,
+			Interval:    interval,
+			Cleaned:     cleanedOrphans,
+		}
+	}
+	// Drift checks and API jobs both run as pull request 0 so they're
+	// serialized per repo.
+	repoMutexes := &events.RepoMutexes{}
+	var driftDetector *events.DriftDetector
+	if len(userConfig.DriftDetection) > 0 {
+		checks, err := newDriftChecks(userConfig.DriftDetection, supportedVCSHosts, eventParser, repoAllowlist)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing drift detection")
+		}
+		driftDetector = &events.DriftDetector{
+			Checks:                        checks,
+			PreWorkflowHooksCommandRunner: preWorkflowHooksCommandRunner,
+			ProjectCommandBuilder:         projectCommandBuilder,
+			ProjectCommandRunner:          prjCmdRunner,
+			DeleteLockCommand:             deleteLockCommand,
+			VCSClient:                     vcsClient,
+			Drainer:                       drainer,
+			RepoMutexes:                   repoMutexes,
+			Logger:                        logger,
+			Now:                           time.Now,
+		}
+	}
+	var crashRecovery *events.CrashRecovery
+	if journal != nil {
+		crashRecovery = &events.CrashRecovery{
+			Journal:       journal,
+			VCSClient:     vcsClient,
+			CommandRunner: commandRunner,
+			DB:            database,
+			Logger:        logger,
+		}
+	}
+	var webhookDeliveries *deliveries.Store
+	if userConfig.WebhookReplayRetention != "" {
+		retention, err := time.ParseDuration(userConfig.WebhookReplayRetention)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing webhook replay retention")
+		}
+		webhookDeliveries = deliveries.NewStore(retention, deliveries.DefaultMaxDeliveries)
+	}
+	helpCommentRenderer := &events.HelpCommentRenderer{
+		Templates:         markdownRenderer.Templates,
+		CommandAuthorizer: commandAuthorizer,
+		ApplyDisabled:     userConfig.DisableApply,
 	}
 	eventsController := &events_controllers.VCSEventsController{
 		CommandRunner:                   commandRunner,
 		PullCleaner:                     pullClosedExecutor,
 		Parser:                          eventParser,
 		CommentParser:                   commentParser,
+		HelpCommentRenderer:             helpCommentRenderer,
 		Logger:                          logger,
 		ApplyDisabled:                   userConfig.DisableApply,
 		GithubWebhookSecret:             []byte(userConfig.GithubWebhookSecret),
@@ -612,7 +1658,121 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		BitbucketWebhookSecret:          []byte(userConfig.BitbucketWebhookSecret),
 		AzureDevopsWebhookBasicUser:     []byte(userConfig.AzureDevopsWebhookUser),
 		AzureDevopsWebhookBasicPassword: []byte(userConfig.AzureDevopsWebhookPassword),
+		AzureDevopsWebhookSecret:        []byte(userConfig.AzureDevopsWebhookSecret),
+		RejectedWebhooks:                rejectedWebhooks,
+		GlobalRateLimiter:               ratelimit.NewLimiter(userConfig.WebhookRateLimit, userConfig.WebhookRateBurst),
+		RepoRateLimiter:                 ratelimit.NewLimiter(userConfig.WebhookRepoRateLimit, userConfig.WebhookRepoRateBurst),
+		RateLimitedWebhooks:             rateLimitedWebhooks,
 		AzureDevopsRequestValidator:     &events_controllers.DefaultAzureDevopsRequestValidator{},
+		Tracer:                          tracer,
+		Deliveries:                      webhookDeliveries,
+		WorkerPool:                      webhookWorkers,
+	}
+	if len(userConfig.AzureDevopsOrgs) > 0 {
+		eventsController.AzureDevopsOrgWebhooks = make(map[string]events_controllers.AzureDevopsWebhookAuth)
+		for _, org := range userConfig.AzureDevopsOrgs {
+			eventsController.AzureDevopsOrgWebhooks[strings.ToLower(org.Name)] = events_controllers.AzureDevopsWebhookAuth{
+				BasicUser:     []byte(org.WebhookUser),
+				BasicPassword: []byte(org.WebhookPassword),
+				Secret:        []byte(org.WebhookSecret),
+			}
+		}
+	}
+	if azuredevopsClient != nil {
+		eventsController.AzureDevopsStatuses = &events.AzureDevopsStatuses{Client: azuredevopsClient}
+	}
+	addWebhookSecretHooks(secretHooks, eventsController, userConfig.AzureDevopsOrgs)
+	logsController := &controllers.LogsController{
+		AtlantisVersion: config.AtlantisVersion,
+		AtlantisURL:     parsedURL,
+		Logger:          logger,
+		Outputs:         outputs,
+		LogTemplate:     templates.LogTemplate,
+	}
+	pullsController := &controllers.PullsController{
+		AtlantisVersion: config.AtlantisVersion,
+		AtlantisURL:     parsedURL,
+		Logger:          logger,
+		DB:              database,
+		Outputs:         outputs,
+		PullsTemplate:   templates.PullsTemplate,
+	}
+	planArtifacts := &events.PlanArtifactReader{
+		WorkingDir:        workingDir,
+		WorkingDirLocker:  workingDirLocker,
+		PendingPlanFinder: pendingPlanFinder,
+		PlanEncryptor:     planEncryptor,
+		TerraformExecutor: terraformClient,
+		DefaultTFVersion:  defaultTfVersion,
+	}
+	plansController := &controllers.PlansController{
+		Logger:        logger,
+		PlanArtifacts: planArtifacts,
+	}
+	driftController := &controllers.DriftController{
+		AtlantisVersion: config.AtlantisVersion,
+		AtlantisURL:     parsedURL,
+		Logger:          logger,
+		Drift:           driftDetector,
+		DriftTemplate:   templates.DriftTemplate,
+	}
+	auditController := &controllers.AuditController{
+		Logger: logger,
+	}
+	if auditStore != nil {
+		auditController.Querier = auditStore.Querier()
+	}
+	var apiController *controllers.APIController
+	if len(userConfig.APITokens) > 0 {
+		apiTokens, err := newAPITokens(userConfig.APITokens)
+		if err != nil {
+			return nil, errors.Wrap(err, "configuring API tokens")
+		}
+		apiController = &controllers.APIController{
+			Logger:                        logger,
+			Tokens:                        apiTokens,
+			Parser:                        eventParser,
+			SupportedVCSHosts:             supportedVCSHosts,
+			RepoAllowlistChecker:          repoAllowlist,
+			PreWorkflowHooksCommandRunner: preWorkflowHooksCommandRunner,
+			ProjectCommandBuilder:         projectCommandBuilder,
+			ProjectCommandRunner:          prjCmdRunner,
+			DeleteLockCommand:             deleteLockCommand,
+			Drainer:                       drainer,
+			Jobs:                          newJobStore(database, logger),
+			Maintenance:                   maintenance,
+			BoltDB:                        boltDBMaintainer,
+			RepoMutexes:                   repoMutexes,
+			Drift:                         driftDetector,
+			PlanArtifacts:                 planArtifacts,
+			Audit:                         auditController,
+			WorkingDir:                    workingDir,
+		}
+		if repoConfigReloader != nil {
+			apiController.ReloadRepoConfig = repoConfigReloader.Reload
+		}
+		if webhookDeliveries != nil {
+			apiController.Deliveries = webhookDeliveries
+			apiController.ReplayWebhook = eventsController.Replay
+		}
+		addAPITokenHooks(secretHooks, apiController, userConfig.APITokens)
+	}
+	var webAuth *auth.OIDC
+	if userConfig.WebOIDCIssuerURL != "" {
+		webAuth, err = auth.NewOIDC(auth.Config{
+			IssuerURL:     userConfig.WebOIDCIssuerURL,
+			ClientID:      userConfig.WebOIDCClientID,
+			ClientSecret:  userConfig.WebOIDCClientSecret,
+			AtlantisURL:   parsedURL,
+			GroupsClaim:   userConfig.WebOIDCGroupsClaim,
+			AllowedGroups: splitList(userConfig.WebOIDCAllowedGroups),
+			AdminGroups:   splitList(userConfig.WebOIDCAdminGroups),
+			Public:        isPublicRequest,
+			Admin:         isAdminRequest,
+		}, logger, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "configuring web UI login")
+		}
 	}
 	githubAppController := &controllers.GithubAppController{
 		AtlantisURL:         parsedURL,
@@ -636,46 +1796,257 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		GithubAppController:           githubAppController,
 		LocksController:               locksController,
 		StatusController:              statusController,
+		HealthController:              healthController,
+		APIController:                 apiController,
 		IndexTemplate:                 templates.IndexTemplate,
 		LockDetailTemplate:            templates.LockTemplate,
 		SSLKeyFile:                    userConfig.SSLKeyFile,
 		SSLCertFile:                   userConfig.SSLCertFile,
 		Drainer:                       drainer,
+		OIDCIssuer:                    oidcIssuer,
+		LogsController:                logsController,
+		Outputs:                       outputs,
+		PullsController:               pullsController,
+		PlansController:               plansController,
+		WebAuth:                       webAuth,
+		Tracer:                        tracer,
+		RepoConfigReloader:            repoConfigReloader,
+		LockExpirer:                   lockExpirer,
+		CrashRecovery:                 crashRecovery,
+		OrphanCollector:               orphanCollector,
+		Maintenance:                   maintenance,
+		BoltDBMaintainer:              boltDBMaintainer,
+		GitlabTokenWatcher:            gitlabTokenWatcher,
+		SecretsWatcher:                secretsWatcher,
+		secretHooks:                   secretHooks,
+		DiskQuota:                     diskQuota,
+		DriftDetector:                 driftDetector,
+		DriftController:               driftController,
+		AgentServer:                   agentServer,
+		AgentPort:                     userConfig.AgentPort,
+		ShutdownTimeout:               shutdownTimeout,
 	}, nil
 }
 
-// Start creates the routes and starts serving traffic.
-func (s *Server) Start() error {
+// Handler creates the routes and returns the handler serving them, including
+// the routes of each tenant under TenantPathPrefix.
+func (s *Server) Handler() http.Handler {
 	s.Router.HandleFunc("/", s.Index).Methods("GET").MatcherFunc(func(r *http.Request, rm *mux.RouteMatch) bool {
 		return r.URL.Path == "/" || r.URL.Path == "/index.html"
 	})
 	s.Router.HandleFunc("/healthz", s.Healthz).Methods("GET")
+	if s.HealthController != nil {
+		s.Router.HandleFunc("/readyz", s.HealthController.Readyz).Methods("GET")
+	}
 	s.Router.HandleFunc("/status", s.StatusController.Get).Methods("GET")
 	s.Router.PathPrefix("/static/").Handler(http.FileServer(&assetfs.AssetFS{Asset: static.Asset, AssetDir: static.AssetDir, AssetInfo: static.AssetInfo}))
 	s.Router.HandleFunc("/events", s.VCSEventsController.Post).Methods("POST")
+	if s.APIController != nil {
+		s.Router.HandleFunc(controllers.APIPrefix+"/plan", s.APIController.Plan).Methods("POST")
+		s.Router.HandleFunc(controllers.APIPrefix+"/apply", s.APIController.Apply).Methods("POST")
+		s.Router.HandleFunc(controllers.APIPrefix+"/jobs/{id}", s.APIController.GetJob).Methods("GET")
+		s.Router.HandleFunc(controllers.APIPrefix+"/plans", s.APIController.ListPlans).Methods("GET")
+		s.Router.HandleFunc(controllers.APIPrefix+"/plans/download", s.APIController.DownloadPlan).Methods("GET")
+		s.Router.HandleFunc(controllers.APIPrefix+"/drain", s.APIController.GetDrain).Methods("GET")
+		s.Router.HandleFunc(controllers.APIPrefix+"/drain", s.APIController.StartDrain).Methods("POST")
+		s.Router.HandleFunc(controllers.APIPrefix+"/drain", s.APIController.StopDrain).Methods("DELETE")
+		s.Router.HandleFunc(controllers.APIPrefix+"/repo-config/reload", s.APIController.ReloadRepoConfigHandler).Methods("POST")
+		s.Router.HandleFunc(controllers.APIPrefix+"/boltdb/maintenance", s.APIController.MaintainBoltDB).Methods("POST")
+		s.Router.HandleFunc(controllers.APIPrefix+"/webhooks", s.APIController.ListWebhooks).Methods("GET")
+		s.Router.HandleFunc(controllers.APIPrefix+"/webhooks/{id}/replay", s.APIController.ReplayWebhookHandler).Methods("POST")
+		s.Router.HandleFunc(controllers.APIPrefix+"/maintenance-windows", s.APIController.ListMaintenanceWindows).Methods("GET")
+		s.Router.HandleFunc(controllers.APIPrefix+"/audit", s.APIController.GetAudit).Methods("GET")
+		s.Router.HandleFunc(controllers.APIPrefix+"/drift", s.APIController.ListDrift).Methods("GET")
+		s.Router.HandleFunc(controllers.APIPrefix+"/drift/summary", s.APIController.DriftSummary).Methods("GET")
+		s.Router.HandleFunc(controllers.APIPrefix+"/drift/{name}/run", s.APIController.RunDriftCheck).Methods("POST")
+		s.Router.HandleFunc(controllers.APIPrefix+"/maintenance-windows/{name}/override", s.APIController.OverrideMaintenanceWindow).Methods("POST")
+		s.Router.HandleFunc(controllers.APIPrefix+"/maintenance-windows/{name}/override", s.APIController.CancelMaintenanceWindowOverride).Methods("DELETE")
+	}
+	if s.OIDCIssuer != nil {
+		s.Router.HandleFunc(credentials.DiscoveryPath, s.OIDCIssuer.ServeDiscovery).Methods("GET")
+		s.Router.HandleFunc(credentials.JWKSPath, s.OIDCIssuer.ServeJWKS).Methods("GET")
+	}
 	s.Router.HandleFunc("/github-app/exchange-code", s.GithubAppController.ExchangeCode).Methods("GET")
 	s.Router.HandleFunc("/github-app/setup", s.GithubAppController.New).Methods("GET")
 	s.Router.HandleFunc("/apply/lock", s.LocksController.LockApply).Methods("POST").Queries()
 	s.Router.HandleFunc("/apply/unlock", s.LocksController.UnlockApply).Methods("DELETE").Queries()
 	s.Router.HandleFunc("/locks", s.LocksController.DeleteLock).Methods("DELETE").Queries("id", "{id:.*}")
+	s.Router.HandleFunc("/locks/discard", s.LocksController.DeleteLocks).Methods("POST")
 	s.Router.HandleFunc("/lock", s.LocksController.GetLock).Methods("GET").
 		Queries(LockViewRouteIDQueryParam, fmt.Sprintf("{%s}", LockViewRouteIDQueryParam)).Name(LockViewRouteName)
+	s.Router.HandleFunc("/logs/{id}", s.LogsController.GetLog).Methods("GET").Name(LogViewRouteName)
+	s.Router.HandleFunc("/logs/{id}/stream", s.LogsController.GetLogStream).Methods("GET")
+	s.Router.HandleFunc("/pulls", s.PullsController.Index).Methods("GET")
+	s.Router.HandleFunc("/drift", s.DriftController.Index).Methods("GET")
 	n := negroni.New(&negroni.Recovery{
 		Logger:     log.New(os.Stdout, "", log.LstdFlags),
 		PrintStack: false,
 		StackAll:   false,
 		StackSize:  1024 * 8,
 	}, NewRequestLogger(s.Logger))
+	if s.WebAuth != nil {
+		// Overriding maintenance windows from the UI requires an admin so
+		// without web auth they can only be overridden through the API.
+		s.Router.HandleFunc("/maintenance-windows/{name}/override", s.LocksController.OverrideMaintenanceWindow).Methods("POST")
+		s.Router.HandleFunc("/maintenance-windows/{name}/override", s.LocksController.CancelMaintenanceWindowOverride).Methods("DELETE")
+		// Plans are only served to logged in users. Without web auth they
+		// can be downloaded through the API.
+		s.Router.HandleFunc("/plans/json", s.PlansController.GetPlanJSON).Methods("GET").Name(PlanJSONRouteName)
+		s.Router.HandleFunc(auth.LoginPath, s.WebAuth.Login).Methods("GET")
+		s.Router.HandleFunc(auth.CallbackPath, s.WebAuth.Callback).Methods("GET")
+		s.Router.HandleFunc(auth.LogoutPath, s.WebAuth.Logout).Methods("GET")
+		n.Use(s.WebAuth)
+	}
 	n.UseHandler(s.Router)
+	if len(s.Tenants) == 0 {
+		return n
+	}
+
+	// Each tenant's routes are served by its own handler so that its
+	// requests never reach the top-level server, ex. its web auth.
+	mux := http.NewServeMux()
+	for _, t := range s.Tenants {
+		prefix := TenantPathPrefix + t.Name
+		mux.Handle(prefix+"/", http.StripPrefix(prefix, t.Server.Handler()))
+	}
+	mux.Handle("/", n)
+	return mux
+}
+
+// NewDatabase returns the database configured by --locking-db-type. If
+// tenant is set, the database is for that tenant.
+func NewDatabase(userConfig UserConfig, tenant string) (db.Database, error) {
+	prefix := db.DefaultKeyPrefix
+	if tenant != "" {
+		// Tenants can share a Redis database or DynamoDB table so their keys
+		// are namespaced.
+		prefix += "tenants:" + tenant + ":"
+	}
+	switch userConfig.LockingDBType {
+	case db.RedisType:
+		redisDB, err := db.NewRedis(db.RedisConfig{
+			Addrs:                 strings.Split(userConfig.RedisAddrs, ","),
+			Password:              userConfig.RedisPassword,
+			DB:                    userConfig.RedisDB,
+			SentinelMaster:        userConfig.RedisSentinelMaster,
+			Cluster:               userConfig.RedisCluster,
+			PoolSize:              userConfig.RedisPoolSize,
+			TLSEnabled:            userConfig.RedisTLSEnabled,
+			TLSInsecureSkipVerify: userConfig.RedisInsecureSkipVerify,
+			KeyPrefix:             prefix,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing Redis")
+		}
+		return redisDB, nil
+	case db.DynamoDBType:
+		var pullTTL time.Duration
+		if userConfig.DynamoDBPullTTL != "" {
+			var err error
+			if pullTTL, err = time.ParseDuration(userConfig.DynamoDBPullTTL); err != nil {
+				return nil, errors.Wrap(err, "parsing DynamoDB pull TTL")
+			}
+		}
+		dynamoDB, err := db.NewDynamoDB(db.DynamoDBConfig{
+			Table:     userConfig.DynamoDBTable,
+			Region:    userConfig.DynamoDBRegion,
+			Endpoint:  userConfig.DynamoDBEndpoint,
+			PullTTL:   pullTTL,
+			KeyPrefix: prefix,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing DynamoDB")
+		}
+		return dynamoDB, nil
+	case db.PostgresType:
+		postgres, err := db.NewPostgres(db.PostgresConfig{
+			URL:    userConfig.PostgresURL,
+			Tenant: tenant,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing Postgres")
+		}
+		return postgres, nil
+	default:
+		return db.New(userConfig.DataDir)
+	}
+}
 
-	defer s.Logger.Flush()
+// Start creates the routes and starts serving traffic.
+func (s *Server) Start() error {
+	handler := s.Handler()
+	servers := s.servers()
+	for _, srv := range servers {
+		defer srv.Logger.Flush()
+	}
 
 	// Ensure server gracefully drains connections when stopped.
 	stop := make(chan os.Signal, 1)
 	// Stop on SIGINTs and SIGTERMs.
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	// Reload the server-side repo config, the GitLab token and the secrets on
+	// SIGHUPs.
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			for _, srv := range servers {
+				if srv.GitlabTokenWatcher != nil {
+					if err := srv.GitlabTokenWatcher.Check(); err != nil {
+						srv.Logger.Err("failed checking GitLab access token: %s", err)
+					}
+				}
+				if srv.SecretsWatcher != nil {
+					if err := srv.SecretsWatcher.Check(); err != nil {
+						srv.Logger.Err("failed refreshing secrets: %s", err)
+					}
+				}
+				if srv.RepoConfigReloader == nil {
+					srv.Logger.Warn("ignoring SIGHUP since there's no server-side repo config file to reload")
+					continue
+				}
+				// Errors are logged by Reload.
+				srv.RepoConfigReloader.Reload() // nolint: errcheck
+			}
+		}
+	}()
+
+	for _, srv := range servers {
+		if srv.CrashRecovery == nil {
+			continue
+		}
+		if err := srv.CrashRecovery.Recover(); err != nil {
+			srv.Logger.Err("failed recovering interrupted commands: %s", err)
+		}
+	}
+
+	expiryCtx, stopExpiry := context.WithCancel(context.Background())
+	defer stopExpiry()
+	for _, srv := range servers {
+		if srv.LockExpirer != nil {
+			go srv.LockExpirer.Run(expiryCtx)
+		}
+		if srv.OrphanCollector != nil {
+			go srv.OrphanCollector.Run(expiryCtx)
+		}
+		if srv.BoltDBMaintainer != nil && srv.BoltDBMaintainer.Interval > 0 {
+			go srv.BoltDBMaintainer.Run(expiryCtx)
+		}
+		if srv.GitlabTokenWatcher != nil {
+			go srv.GitlabTokenWatcher.Run(expiryCtx)
+		}
+		if srv.SecretsWatcher != nil && srv.SecretsWatcher.Interval > 0 {
+			go srv.SecretsWatcher.Run(expiryCtx)
+		}
+		if srv.DiskQuota != nil {
+			go srv.DiskQuota.Run(expiryCtx)
+		}
+		if srv.DriftDetector != nil {
+			go srv.DriftDetector.Run(expiryCtx)
+		}
+	}
 
-	server := &http.Server{Addr: fmt.Sprintf(":%d", s.Port), Handler: n}
+	server := &http.Server{Addr: fmt.Sprintf(":%d", s.Port), Handler: handler}
 	go func() {
 		s.Logger.Info("Atlantis started - listening on port %v", s.Port)
 
@@ -690,17 +2061,84 @@ func (s *Server) Start() error {
 			s.Logger.Err(err.Error())
 		}
 	}()
+	if s.AgentServer != nil {
+		go func() {
+			s.Logger.Info("Atlantis agent listening on port %v", s.AgentPort)
+			if err := s.AgentServer.ListenAndServe(s.AgentPort); err != nil {
+				s.Logger.Err("agent server: %s", err)
+			}
+		}()
+	}
 	<-stop
 
 	s.Logger.Warn("Received interrupt. Waiting for in-progress operations to complete")
-	s.waitForDrain()
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func(srv *Server) {
+			defer wg.Done()
+			srv.waitForDrain()
+		}(srv)
+	}
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+	s.waitForDrainOrAbort(drained)
+	if s.AgentServer != nil {
+		s.AgentServer.Stop()
+	}
 	ctx, _ := context.WithTimeout(context.Background(), 5*time.Second) // nolint: vet
 	if err := server.Shutdown(ctx); err != nil {
 		return cli.NewExitError(fmt.Sprintf("while shutting down: %s", err), 1)
 	}
+	for _, srv := range servers {
+		if err := srv.Tracer.Shutdown(ctx); err != nil {
+			srv.Logger.Warn("failed exporting traces on shutdown: %s", err)
+		}
+	}
 	return nil
 }
 
+// servers returns s and the servers of its tenants.
+func (s *Server) servers() []*Server {
+	servers := []*Server{s}
+	for _, t := range s.Tenants {
+		servers = append(servers, t.Server)
+	}
+	return servers
+}
+
+// diskQuotaInterval is how often --data-dir-quota-mb is enforced.
+const diskQuotaInterval = 5 * time.Minute
+
+// abortReportTimeout is how long operations have to report that they were
+// aborted once their commands are killed.
+const abortReportTimeout = 30 * time.Second
+
+// waitForDrainOrAbort blocks until drained is closed. If ShutdownTimeout
+// passes first, the commands of the in-progress operations are aborted, which
+// fails them, and it waits for the operations to report their failure until
+// the commands are killed and abortReportTimeout has passed.
+func (s *Server) waitForDrainOrAbort(drained <-chan struct{}) {
+	if s.ShutdownTimeout <= 0 {
+		<-drained
+		return
+	}
+	select {
+	case <-drained:
+		return
+	case <-time.After(s.ShutdownTimeout):
+	}
+	s.Logger.Warn("in-progress operations didn't complete within %s, aborting their %d running commands", s.ShutdownTimeout, limits.AbortAll())
+	select {
+	case <-drained:
+	case <-time.After(limits.KillGracePeriod + abortReportTimeout):
+		s.Logger.Err("in-progress operations didn't complete after being aborted, shutting down anyway")
+	}
+}
+
 // waitForDrain blocks until draining is complete.
 func (s *Server) waitForDrain() {
 	drainComplete := make(chan bool, 1)
@@ -721,7 +2159,7 @@ func (s *Server) waitForDrain() {
 }
 
 // Index is the / route.
-func (s *Server) Index(w http.ResponseWriter, _ *http.Request) {
+func (s *Server) Index(w http.ResponseWriter, r *http.Request) {
 	locks, err := s.Locker.List()
 	if err != nil {
 		w.WriteHeader(http.StatusServiceUnavailable)
@@ -736,8 +2174,10 @@ func (s *Server) Index(w http.ResponseWriter, _ *http.Request) {
 			// NOTE: must use .String() instead of .Path because we need the
 			// query params as part of the lock URL.
 			LockPath:      lockURL.String(),
+			LockID:        id,
 			RepoFullName:  v.Project.RepoFullName,
 			PullNum:       v.Pull.Num,
+			Author:        v.Pull.Author,
 			Path:          v.Project.Path,
 			Workspace:     v.Workspace,
 			Time:          v.Time,
@@ -758,20 +2198,348 @@ func (s *Server) Index(w http.ResponseWriter, _ *http.Request) {
 		Locked:        applyCmdLock.Locked,
 		TimeFormatted: applyCmdLock.Time.Format("02-01-2006 15:04:05"),
 	}
-	//Sort by date - newest to oldest.
-	sort.SliceStable(lockResults, func(i, j int) bool { return lockResults[i].Time.After(lockResults[j].Time) })
+	lockResults, lockPageData := lockPage(lockResults, r.URL.Query())
 
 	err = s.IndexTemplate.Execute(w, templates.IndexData{
-		Locks:           lockResults,
-		ApplyLock:       applyLockData,
-		AtlantisVersion: s.AtlantisVersion,
-		CleanedBasePath: s.AtlantisURL.Path,
+		Locks:              lockResults,
+		LockPage:           lockPageData,
+		ApplyLock:          applyLockData,
+		MaintenanceWindows: s.maintenanceWindowData(),
+		CanOverride:        s.WebAuth != nil,
+		AtlantisVersion:    s.AtlantisVersion,
+		CleanedBasePath:    s.AtlantisURL.Path,
+		Logs:               s.logIndexData(),
 	})
 	if err != nil {
 		s.Logger.Err(err.Error())
 	}
 }
 
+// maxIndexLogs is how many of the most recent logs are shown on the index page.
+const maxIndexLogs = 20
+
+func (s *Server) logIndexData() []templates.LogIndexData {
+	if s.Outputs == nil {
+		return nil
+	}
+	var logs []templates.LogIndexData
+	for _, o := range s.Outputs.List() {
+		if len(logs) == maxIndexLogs {
+			break
+		}
+		logURL, _ := s.Router.Get(LogViewRouteName).URL("id", o.ID)
+		logs = append(logs, templates.LogIndexData{
+			LogPath:       logURL.String(),
+			Command:       o.Command,
+			RepoFullName:  o.Repo,
+			PullNum:       o.Pull,
+			Path:          o.RepoRelDir,
+			Workspace:     o.Workspace,
+			Running:       !o.Finished,
+			TimeFormatted: o.StartedAt.Format("02-01-2006 15:04:05"),
+		})
+	}
+	return logs
+}
+
+func (s *Server) maintenanceWindowData() []templates.MaintenanceWindowData {
+	if s.Maintenance == nil {
+		return nil
+	}
+	var windows []templates.MaintenanceWindowData
+	for _, w := range s.Maintenance.Status() {
+		windows = append(windows, templates.MaintenanceWindowData{
+			Name:           w.Name,
+			Message:        w.Message,
+			Global:         w.Global,
+			Active:         w.Active,
+			Overridden:     w.Overridden,
+			StartFormatted: w.Start.Format("02-01-2006 15:04:05"),
+			EndFormatted:   w.End.Format("02-01-2006 15:04:05"),
+		})
+	}
+	return windows
+}
+
+// newMaintenanceSchedule validates the configured maintenance windows.
+func newMaintenanceSchedule(configs []MaintenanceWindowConfig) (*locking.MaintenanceSchedule, error) {
+	var windows []locking.MaintenanceWindow
+	names := make(map[string]bool)
+	for _, c := range configs {
+		if c.Name == "" {
+			return nil, errors.New("all maintenance windows must have a name")
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("maintenance window name %q is used more than once", c.Name)
+		}
+		names[c.Name] = true
+		schedule, err := locking.ParseMaintenanceSpec(c.Schedule)
+		if err != nil {
+			return nil, errors.Wrapf(err, "maintenance window %q has an invalid schedule", c.Name)
+		}
+		duration, err := time.ParseDuration(c.Duration)
+		if err != nil {
+			return nil, errors.Wrapf(err, "maintenance window %q has an invalid duration", c.Name)
+		}
+		if duration <= 0 {
+			return nil, fmt.Errorf("maintenance window %q must have a positive duration", c.Name)
+		}
+		window := locking.MaintenanceWindow{
+			Name:     c.Name,
+			Spec:     c.Schedule,
+			Schedule: schedule,
+			Duration: duration,
+			Message:  c.Message,
+		}
+		if c.Repos != "" {
+			repos, err := events.NewRepoAllowlistChecker(c.Repos)
+			if err != nil {
+				return nil, errors.Wrapf(err, "maintenance window %q has invalid repos", c.Name)
+			}
+			window.Repos = repos
+		}
+		windows = append(windows, window)
+	}
+	return locking.NewMaintenanceSchedule(windows), nil
+}
+
+// newErrorHints validates the configured error hints and adds them to the
+// default ones.
+func newErrorHints(configs []ErrorHintConfig) (events.ErrorHints, error) {
+	var hints []events.ErrorHint
+	names := make(map[string]bool)
+	for _, c := range configs {
+		if c.Name == "" {
+			return nil, errors.New("all error hints must have a name")
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("error hint name %q is used more than once", c.Name)
+		}
+		names[c.Name] = true
+		hint := events.ErrorHint{Name: c.Name, Hint: c.Hint}
+		if c.Hint != "" {
+			if c.Pattern == "" {
+				return nil, fmt.Errorf("error hint %q must have a pattern", c.Name)
+			}
+			pattern, err := regexp.Compile(c.Pattern)
+			if err != nil {
+				return nil, errors.Wrapf(err, "error hint %q has an invalid pattern", c.Name)
+			}
+			hint.Pattern = pattern
+		}
+		hints = append(hints, hint)
+	}
+	return events.NewErrorHints(hints), nil
+}
+
+// newDriftChecks validates the configured drift checks.
+func newDriftChecks(configs []DriftCheckConfig, supportedVCSHosts []models.VCSHostType, parser events.EventParsing, allowlist *events.RepoAllowlistChecker) ([]events.DriftCheck, error) {
+	var checks []events.DriftCheck
+	names := make(map[string]bool)
+	for _, c := range configs {
+		if c.Name == "" {
+			return nil, errors.New("all drift checks must have a name")
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("drift check name %q is used more than once", c.Name)
+		}
+		names[c.Name] = true
+		schedule, err := locking.ParseMaintenanceSpec(c.Schedule)
+		if err != nil {
+			return nil, errors.Wrapf(err, "drift check %q has an invalid schedule", c.Name)
+		}
+		if c.Repository == "" || c.Branch == "" {
+			return nil, fmt.Errorf("drift check %q must have a repository and branch", c.Name)
+		}
+		hostType, err := controllers.VCSHostType(c.VCS, supportedVCSHosts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "drift check %q", c.Name)
+		}
+		repo, err := parser.ParseAPIRepo(hostType, c.Repository)
+		if err != nil {
+			return nil, errors.Wrapf(err, "drift check %q", c.Name)
+		}
+		if !allowlist.IsAllowlisted(repo.FullName, repo.VCSHost.Hostname) {
+			return nil, fmt.Errorf("drift check %q: repo %s is not in the allowlist", c.Name, repo.FullName)
+		}
+		if len(c.Projects) == 0 {
+			return nil, fmt.Errorf("drift check %q must have at least one project", c.Name)
+		}
+		check := events.DriftCheck{
+			Name:     c.Name,
+			Spec:     c.Schedule,
+			Schedule: schedule,
+			Repo:     repo,
+			Branch:   c.Branch,
+		}
+		if c.Issues != nil {
+			if hostType != models.Github && hostType != models.Gitlab {
+				return nil, fmt.Errorf("drift check %q: issues are only supported for GitHub and GitLab repos", c.Name)
+			}
+			check.Issues = &events.DriftIssues{Labels: c.Issues.Labels, Assignees: c.Issues.Assignees}
+		}
+		for _, p := range c.Projects {
+			if p.Name == "" && p.Dir == "" {
+				return nil, fmt.Errorf("drift check %q: projects must have a name or dir", c.Name)
+			}
+			check.Projects = append(check.Projects, events.DriftProject{Name: p.Name, Dir: p.Dir, Workspace: p.Workspace, Assignees: p.Assignees})
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// newAzureDevopsOrgClients validates the configured Azure DevOps
+// organizations and returns their clients by lowercase name.
+func newAzureDevopsOrgClients(configs []AzureDevopsOrgConfig, maxCommentLength int) (map[string]*vcs.AzureDevopsClient, error) {
+	clients := make(map[string]*vcs.AzureDevopsClient)
+	for _, c := range configs {
+		if c.Name == "" {
+			return nil, errors.New("all Azure DevOps organizations must have a name")
+		}
+		name := strings.ToLower(c.Name)
+		if _, ok := clients[name]; ok {
+			return nil, fmt.Errorf("Azure DevOps organization %q is configured more than once", c.Name)
+		}
+		if c.User == "" || c.Token == "" {
+			return nil, fmt.Errorf("Azure DevOps organization %q must have a user and token", c.Name)
+		}
+		client, err := vcs.NewAzureDevopsClient("dev.azure.com", c.User, c.Token)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Azure DevOps organization %q", c.Name)
+		}
+		client.MaxCommentLength = maxCommentLength
+		clients[name] = client
+	}
+	return clients, nil
+}
+
+// addWebhookSecretHooks adds the hooks that swap the webhook secrets of
+// eventsController, including those of orgs, to hooks.
+func addWebhookSecretHooks(hooks map[string]func(string) error, eventsController *events_controllers.VCSEventsController, orgs []AzureDevopsOrgConfig) {
+	set := func(secret *[]byte) func(string) error {
+		return func(value string) error {
+			eventsController.UpdateWebhookSecrets(func() { *secret = []byte(value) })
+			return nil
+		}
+	}
+	hooks["gh-webhook-secret"] = set(&eventsController.GithubWebhookSecret)
+	hooks["gitlab-webhook-secret"] = set(&eventsController.GitlabWebhookSecret)
+	hooks["bitbucket-webhook-secret"] = set(&eventsController.BitbucketWebhookSecret)
+	hooks["azuredevops-webhook-password"] = set(&eventsController.AzureDevopsWebhookBasicPassword)
+	hooks["azuredevops-webhook-secret"] = set(&eventsController.AzureDevopsWebhookSecret)
+
+	for i, org := range orgs {
+		name := strings.ToLower(org.Name)
+		setOrg := func(update func(auth *events_controllers.AzureDevopsWebhookAuth, value []byte)) func(string) error {
+			return func(value string) error {
+				eventsController.UpdateWebhookSecrets(func() {
+					auth := eventsController.AzureDevopsOrgWebhooks[name]
+					update(&auth, []byte(value))
+					eventsController.AzureDevopsOrgWebhooks[name] = auth
+				})
+				return nil
+			}
+		}
+		hooks[fmt.Sprintf("azuredevops-orgs[%d].webhook-password", i)] = setOrg(func(auth *events_controllers.AzureDevopsWebhookAuth, value []byte) {
+			auth.BasicPassword = value
+		})
+		hooks[fmt.Sprintf("azuredevops-orgs[%d].webhook-secret", i)] = setOrg(func(auth *events_controllers.AzureDevopsWebhookAuth, value []byte) {
+			auth.Secret = value
+		})
+	}
+}
+
+// addAPITokenHooks adds the hooks that swap the secrets of the API tokens of
+// apiController, configured by configs, to hooks.
+func addAPITokenHooks(hooks map[string]func(string) error, apiController *controllers.APIController, configs []APITokenConfig) {
+	for i, c := range configs {
+		name := c.Name
+		hooks[fmt.Sprintf("api-tokens[%d].token", i)] = func(token string) error {
+			if len(token) < 16 {
+				return fmt.Errorf("token %q must be at least 16 characters", name)
+			}
+			return apiController.SetToken(name, token)
+		}
+	}
+}
+
+// newAPITokens validates the configured API tokens.
+func newAPITokens(configs []APITokenConfig) ([]controllers.APIToken, error) {
+	var tokens []controllers.APIToken
+	names := make(map[string]bool)
+	for _, c := range configs {
+		if c.Name == "" {
+			return nil, errors.New("all tokens must have a name")
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("token name %q is used more than once", c.Name)
+		}
+		names[c.Name] = true
+		if len(c.Token) < 16 {
+			return nil, fmt.Errorf("token %q must be at least 16 characters", c.Name)
+		}
+		for _, scope := range c.Scopes {
+			valid := false
+			for _, v := range controllers.ValidScopes {
+				valid = valid || scope == v
+			}
+			if !valid {
+				return nil, fmt.Errorf("token %q has invalid scope %q, must be one of %s", c.Name, scope, strings.Join(controllers.ValidScopes, ", "))
+			}
+		}
+		tokens = append(tokens, controllers.APIToken{Name: c.Name, Token: c.Token, Scopes: c.Scopes})
+	}
+	return tokens, nil
+}
+
+// newJobStore returns the store of the jobs run through the API. Their
+// history is kept in database if it's Postgres.
+func newJobStore(database db.Database, logger logging.SimpleLogging) *jobs.Store {
+	if postgres, ok := database.(*db.Postgres); ok {
+		return jobs.NewPersistentStore(jobs.DefaultMaxJobs, postgres, logger)
+	}
+	return jobs.NewStore(jobs.DefaultMaxJobs)
+}
+
+// newAuditStore returns a store that records to every audit log target
+// configured by the user or nil if the audit log is disabled. If database is
+// Postgres, the audit log is stored in it unless another SQL database is
+// configured.
+func newAuditStore(userConfig UserConfig, database db.Database, logger logging.SimpleLogging) (*audit.MultiStore, error) {
+	var stores []audit.Store
+	if userConfig.AuditLogFile != "" {
+		fileStore, err := audit.NewFileStore(userConfig.AuditLogFile)
+		if err != nil {
+			return nil, err
+		}
+		stores = append(stores, fileStore)
+	}
+	if userConfig.AuditLogSQLURL != "" {
+		sqlStore, err := audit.NewSQLStore(userConfig.AuditLogSQLURL)
+		if err != nil {
+			return nil, err
+		}
+		stores = append(stores, sqlStore)
+	} else if postgres, ok := database.(*db.Postgres); ok {
+		sqlStore, err := audit.NewSQLStoreWithDB(postgres.DB())
+		if err != nil {
+			return nil, err
+		}
+		stores = append(stores, sqlStore)
+	}
+	if userConfig.AuditLogWebhookURL != "" {
+		stores = append(stores, audit.NewWebhookStore(userConfig.AuditLogWebhookURL))
+	}
+	if len(stores) == 0 {
+		return nil, nil
+	}
+	return &audit.MultiStore{
+		Stores: stores,
+		Logger: logger,
+	}, nil
+}
+
 func mkSubDir(parentDir string, subDir string) (string, error) {
 	fullDir := filepath.Join(parentDir, subDir)
 	if err := os.MkdirAll(fullDir, 0700); err != nil {
@@ -781,20 +2549,76 @@ func mkSubDir(parentDir string, subDir string) (string, error) {
 	return fullDir, nil
 }
 
-// Healthz returns the health check response. It always returns a 200 currently.
-func (s *Server) Healthz(w http.ResponseWriter, _ *http.Request) {
-	data, err := json.MarshalIndent(&struct {
-		Status string `json:"status"`
-	}{
-		Status: "ok",
-	}, "", "  ")
+// Healthz returns the health check response. It returns a 503 if any of the
+// HealthController's liveness checks fail.
+func (s *Server) Healthz(w http.ResponseWriter, r *http.Request) {
+	h := s.HealthController
+	if h == nil {
+		h = &controllers.HealthController{}
+	}
+	h.Healthz(w, r)
+}
+
+// checkDirWritable returns an error if files can't be created in dir.
+func checkDirWritable(dir string) error {
+	f, err := ioutil.TempFile(dir, ".healthz-")
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "Error creating status json response: %s", err)
-		return
+		return err
+	}
+	f.Close() // nolint: errcheck
+	return os.Remove(f.Name())
+}
+
+// isPublicRequest returns true if r doesn't require logging in to the web
+// UI. These requests are either authenticated separately, ex. webhooks and
+// the API, or are needed by monitoring.
+func isPublicRequest(r *http.Request) bool {
+	switch r.URL.Path {
+	case "/events", "/healthz", "/readyz", "/status", credentials.DiscoveryPath, credentials.JWKSPath:
+		return true
+	}
+	return strings.HasPrefix(r.URL.Path, "/static/") || strings.HasPrefix(r.URL.Path, controllers.APIPrefix+"/")
+}
+
+// isAdminRequest returns true if r is a destructive action that requires
+// being in one of the web UI's admin groups.
+func isAdminRequest(r *http.Request) bool {
+	switch {
+	case r.URL.Path == "/locks" && r.Method == http.MethodDelete:
+		return true
+	case r.URL.Path == "/locks/discard":
+		return true
+	case r.URL.Path == "/apply/lock" || r.URL.Path == "/apply/unlock":
+		return true
+	case strings.HasPrefix(r.URL.Path, "/maintenance-windows/"):
+		return true
+	}
+	return strings.HasPrefix(r.URL.Path, "/github-app/")
+}
+
+// splitList splits a comma-separated list, ignoring empty elements.
+func splitList(list string) []string {
+	var split []string
+	for _, s := range strings.Split(list, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			split = append(split, s)
+		}
+	}
+	return split
+}
+
+// ParseHeaders parses a comma separated list of key=value headers, ex.
+// x-api-key=key,x-dataset=atlantis.
+func ParseHeaders(list string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, h := range splitList(list) {
+		split := strings.SplitN(h, "=", 2)
+		if len(split) != 2 || strings.TrimSpace(split[0]) == "" {
+			return nil, fmt.Errorf("%q is not of the form key=value", h)
+		}
+		headers[strings.TrimSpace(split[0])] = strings.TrimSpace(split[1])
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(data) // nolint: errcheck
+	return headers, nil
 }
 
 // ParseAtlantisURL parses the user-passed atlantis URL to ensure it is valid
@@ -814,3 +2638,60 @@ func ParseAtlantisURL(u string) (*url.URL, error) {
 	parsed.Path = strings.TrimSuffix(parsed.Path, "/")
 	return parsed, nil
 }
+
+// newCommandLimits returns the limits of the commands run for workflow steps
+// or nil if they aren't limited.
+func newCommandLimits(userConfig UserConfig) (*limits.Config, error) {
+	if userConfig.StepTimeout == "" && userConfig.StepIdleTimeout == "" && userConfig.StepMaxOutputBytes == 0 && userConfig.StepMemoryLimitMB == 0 && userConfig.StepCPULimit == "" {
+		return nil, nil
+	}
+	l := &limits.Config{
+		MaxOutputBytes: userConfig.StepMaxOutputBytes,
+		MemoryBytes:    int64(userConfig.StepMemoryLimitMB) * 1024 * 1024,
+		CgroupDir:      userConfig.StepCgroupDir,
+	}
+	if userConfig.StepTimeout != "" {
+		timeout, err := time.ParseDuration(userConfig.StepTimeout)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing step timeout")
+		}
+		l.Timeout = timeout
+	}
+	if userConfig.StepIdleTimeout != "" {
+		timeout, err := time.ParseDuration(userConfig.StepIdleTimeout)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing step idle timeout")
+		}
+		l.IdleTimeout = timeout
+	}
+	if userConfig.StepCPULimit != "" {
+		cpus, err := strconv.ParseFloat(userConfig.StepCPULimit, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing step CPU limit")
+		}
+		l.CPUs = cpus
+	}
+	return l, nil
+}
+
+// newPlanEncryptor returns an encryptor using the key from
+// --plan-encryption-key, or the key decrypted with KMS from
+// --plan-encryption-kms-data-key.
+func newPlanEncryptor(userConfig UserConfig) (runtime.PlanEncryptor, error) {
+	var key []byte
+	var err error
+	if userConfig.PlanEncryptionKMSKey != "" {
+		var ciphertext []byte
+		ciphertext, err = base64.StdEncoding.DecodeString(userConfig.PlanEncryptionKMSKey)
+		if err != nil {
+			return nil, err
+		}
+		key, err = runtime.DecryptKMSDataKey(ciphertext)
+	} else {
+		key, err = base64.StdEncoding.DecodeString(userConfig.PlanEncryptionKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return runtime.NewAESGCMPlanEncryptor(key)
+}
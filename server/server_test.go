@@ -16,6 +16,8 @@ package server_test
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -26,6 +28,7 @@ import (
 	"github.com/gorilla/mux"
 	. "github.com/petergtz/pegomock"
 	"github.com/runatlantis/atlantis/server"
+	"github.com/runatlantis/atlantis/server/controllers"
 	"github.com/runatlantis/atlantis/server/controllers/templates"
 	tMocks "github.com/runatlantis/atlantis/server/controllers/templates/mocks"
 	"github.com/runatlantis/atlantis/server/events/locking/mocks"
@@ -121,17 +124,127 @@ func TestIndex_Success(t *testing.T) {
 		Locks: []templates.LockIndexData{
 			{
 				LockPath:      "/lock?id=lkysow%252Fatlantis-example%252F.%252Fdefault",
+				LockID:        "lkysow/atlantis-example/./default",
 				RepoFullName:  "lkysow/atlantis-example",
 				PullNum:       9,
 				Time:          now,
 				TimeFormatted: now.Format("02-01-2006 15:04:05"),
 			},
 		},
+		LockPage: templates.LockPageData{
+			Sort:  "newest",
+			Page:  1,
+			Pages: 1,
+			Total: 1,
+		},
 		AtlantisVersion: atlantisVersion,
 	})
 	ResponseContains(t, w, http.StatusOK, "")
 }
 
+// indexTemplateRecorder records the data the index template is executed with.
+type indexTemplateRecorder struct {
+	data templates.IndexData
+}
+
+func (i *indexTemplateRecorder) Execute(_ io.Writer, data interface{}) error {
+	i.data = data.(templates.IndexData)
+	return nil
+}
+
+func TestIndex_LockSearchAndPagination(t *testing.T) {
+	RegisterMockTestingT(t)
+	l := mocks.NewMockLocker()
+	al := mocks.NewMockApplyLocker()
+	start := time.Now()
+	locks := make(map[string]models.ProjectLock)
+	// Locks 0 to 119 are in lkysow/atlantis-example and lock 120 is in
+	// runatlantis/atlantis. Higher numbers are newer.
+	for i := 0; i <= server.LockIndexPageSize*2+20; i++ {
+		repo := "lkysow/atlantis-example"
+		if i == server.LockIndexPageSize*2+20 {
+			repo = "runatlantis/atlantis"
+		}
+		locks[fmt.Sprintf("%s/%d/default", repo, i)] = models.ProjectLock{
+			Pull:      models.PullRequest{Num: i, Author: "author"},
+			Project:   models.Project{RepoFullName: repo, Path: fmt.Sprintf("%d", i)},
+			Workspace: "default",
+			Time:      start.Add(time.Duration(i) * time.Minute),
+		}
+	}
+	When(l.List()).ThenReturn(locks, nil)
+	r := mux.NewRouter()
+	r.NewRoute().Path("/lock").
+		Queries("id", "{id}").Name(server.LockViewRouteName)
+	u, err := url.Parse("https://example.com")
+	Ok(t, err)
+
+	cases := []struct {
+		query    string
+		expPaths []string
+		expPage  templates.LockPageData
+	}{
+		{
+			query:    "",
+			expPaths: []string{"120", "71"},
+			expPage:  templates.LockPageData{Sort: "newest", Page: 1, Pages: 3, Total: 121, NextQuery: "?page=2"},
+		},
+		{
+			query:    "?page=3",
+			expPaths: []string{"20", "0"},
+			expPage:  templates.LockPageData{Sort: "newest", Page: 3, Pages: 3, Total: 121, PrevQuery: "?page=2"},
+		},
+		{
+			query:    "?sort=oldest&page=2",
+			expPaths: []string{"50", "99"},
+			expPage:  templates.LockPageData{Sort: "oldest", Page: 2, Pages: 3, Total: 121, PrevQuery: "?page=1&sort=oldest", NextQuery: "?page=3&sort=oldest"},
+		},
+		{
+			query:    "?page=100",
+			expPaths: []string{"20", "0"},
+			expPage:  templates.LockPageData{Sort: "newest", Page: 3, Pages: 3, Total: 121, PrevQuery: "?page=2"},
+		},
+		{
+			query:    "?q=RunAtlantis",
+			expPaths: []string{"120", "120"},
+			expPage:  templates.LockPageData{Query: "RunAtlantis", Sort: "newest", Page: 1, Pages: 1, Total: 1},
+		},
+		{
+			query:    "?q=example+%2312",
+			expPaths: []string{"12", "12"},
+			expPage:  templates.LockPageData{Query: "example #12", Sort: "newest", Page: 1, Pages: 1, Total: 1},
+		},
+		{
+			query:    "?q=nomatch",
+			expPaths: nil,
+			expPage:  templates.LockPageData{Query: "nomatch", Sort: "newest", Page: 1, Pages: 1, Total: 0},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			it := &indexTemplateRecorder{}
+			s := server.Server{
+				Locker:        l,
+				ApplyLocker:   al,
+				IndexTemplate: it,
+				Router:        r,
+				AtlantisURL:   u,
+				Logger:        logging.NewNoopLogger(t),
+			}
+			req, _ := http.NewRequest("GET", "/"+c.query, bytes.NewBuffer(nil))
+			w := httptest.NewRecorder()
+			s.Index(w, req)
+			Equals(t, c.expPage, it.data.LockPage)
+			if c.expPaths == nil {
+				Equals(t, 0, len(it.data.Locks))
+				return
+			}
+			Equals(t, c.expPaths[0], it.data.Locks[0].Path)
+			Equals(t, c.expPaths[1], it.data.Locks[len(it.data.Locks)-1].Path)
+		})
+	}
+}
+
 func TestHealthz(t *testing.T) {
 	s := server.Server{}
 	req, _ := http.NewRequest("GET", "/healthz", bytes.NewBuffer(nil))
@@ -146,6 +259,67 @@ func TestHealthz(t *testing.T) {
 }`, string(body))
 }
 
+func TestHandler_Tenants(t *testing.T) {
+	failed := func() error { return errors.New("data dir isn't writable") }
+	tenant := &server.Server{
+		Router: mux.NewRouter(),
+		Logger: logging.NewNoopLogger(t),
+		HealthController: &controllers.HealthController{
+			LivenessChecks: []controllers.HealthCheck{{Name: "data-dir", Check: failed}},
+		},
+	}
+	s := &server.Server{
+		Router:  mux.NewRouter(),
+		Logger:  logging.NewNoopLogger(t),
+		Tenants: []server.Tenant{{Name: "acme", Server: tenant}},
+	}
+	handler := s.Handler()
+
+	cases := []struct {
+		path    string
+		expCode int
+	}{
+		{"/healthz", http.StatusOK},
+		{"/tenants/acme/healthz", http.StatusServiceUnavailable},
+		{"/tenants/other/healthz", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", c.path, bytes.NewBuffer(nil))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			Equals(t, c.expCode, w.Result().StatusCode)
+		})
+	}
+}
+
+// Test that maintenance windows can't be overridden from the UI without web
+// auth since anyone could override them.
+func TestHandler_MaintenanceOverrideRequiresWebAuth(t *testing.T) {
+	s := &server.Server{
+		Router: mux.NewRouter(),
+		Logger: logging.NewNoopLogger(t),
+	}
+	handler := s.Handler()
+	for _, method := range []string{"POST", "DELETE"} {
+		req, _ := http.NewRequest(method, "/maintenance-windows/nightly/override", bytes.NewBuffer(nil))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		Equals(t, http.StatusNotFound, w.Result().StatusCode)
+	}
+}
+
+func TestHandler_PlanJSONRequiresWebAuth(t *testing.T) {
+	s := &server.Server{
+		Router: mux.NewRouter(),
+		Logger: logging.NewNoopLogger(t),
+	}
+	req, _ := http.NewRequest("GET", "/plans/json?repo=owner/repo&pull=1", bytes.NewBuffer(nil))
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	Equals(t, http.StatusNotFound, w.Result().StatusCode)
+}
+
 func TestParseAtlantisURL(t *testing.T) {
 	cases := []struct {
 		In     string
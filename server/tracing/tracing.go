@@ -0,0 +1,339 @@
+// Package tracing records OpenTelemetry spans for the lifecycle of commands,
+// from the webhook through VCS API calls, cloning and terraform, and exports
+// them to an OTLP collector.
+//
+// Spans are exported with OTLP/HTTP's JSON encoding rather than through the
+// OpenTelemetry SDK because the SDK's exporters require a newer gRPC than the
+// one Atlantis is pinned to.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// DefaultServiceName is the service.name resource attribute by default.
+const DefaultServiceName = "atlantis"
+
+// SpanKind describes the relationship of a span to its callers, ex. a client
+// span is an outgoing call.
+type SpanKind int
+
+// These values match OTLP's span kinds.
+const (
+	InternalKind SpanKind = 1
+	ServerKind   SpanKind = 2
+	ClientKind   SpanKind = 3
+)
+
+// Attribute is a key/value pair describing a span. Values are strings, ints
+// or bools.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a string attribute.
+func String(key string, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int returns an int attribute.
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Bool returns a bool attribute.
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Config configures a Tracer.
+type Config struct {
+	// Endpoint is the OTLP/HTTP endpoint, ex. http://localhost:4318. Spans
+	// are sent to Endpoint + /v1/traces. If empty, spans aren't exported.
+	Endpoint string
+	// Headers are added to export requests, ex. for authentication.
+	Headers map[string]string
+	// ServiceName is the service.name resource attribute.
+	ServiceName string
+	// Version is the Atlantis version.
+	Version string
+}
+
+// Tracer starts spans. A nil Tracer is valid and starts nil spans, which
+// record nothing.
+type Tracer struct {
+	exporter *exporter
+	now      func() time.Time
+
+	mu sync.Mutex
+	// active maps pull requests to the span of the command running for them.
+	active map[string]*Span
+}
+
+// NewTracer returns a tracer that exports spans as configured by cfg.
+func NewTracer(cfg Config, logger logging.SimpleLogging) *Tracer {
+	t := &Tracer{
+		now:    time.Now,
+		active: make(map[string]*Span),
+	}
+	if cfg.Endpoint != "" {
+		if cfg.ServiceName == "" {
+			cfg.ServiceName = DefaultServiceName
+		}
+		t.exporter = newExporter(cfg, logger)
+	}
+	return t
+}
+
+// Shutdown exports any spans that haven't been exported yet.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil || t.exporter == nil {
+		return nil
+	}
+	return t.exporter.shutdown(ctx)
+}
+
+// Start starts a span that's a child of the span in ctx, if any, and returns
+// a copy of ctx containing the new span. The span must be ended with End.
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	var s *Span
+	if parent := SpanFromContext(ctx); parent != nil {
+		s = parent.Start(name, attrs...)
+	} else {
+		s = t.newSpan(name, newTraceID(), [8]byte{}, attrs)
+	}
+	return ContextWithSpan(ctx, s), s
+}
+
+// StartRemote starts a span that continues the trace described by
+// traceparent, a W3C trace context header. If traceparent isn't valid, a
+// new trace is started.
+func (t *Tracer) StartRemote(ctx context.Context, traceparent string, name string, attrs ...Attribute) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	traceID, parentID, ok := parseTraceparent(traceparent)
+	if !ok {
+		return t.Start(ctx, name, attrs...)
+	}
+	s := t.newSpan(name, traceID, parentID, attrs)
+	return ContextWithSpan(ctx, s), s
+}
+
+// Activate records calls made for repo's pull request pullNum, ex. VCS API
+// calls, under s until the returned func is called. It's needed for calls
+// whose signatures can't carry a span.
+func (t *Tracer) Activate(s *Span, repo string, pullNum int) func() {
+	if t == nil || s == nil {
+		return func() {}
+	}
+	key := pullKey(repo, pullNum)
+	t.mu.Lock()
+	t.active[key] = s
+	t.mu.Unlock()
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.active[key] == s {
+			delete(t.active, key)
+		}
+	}
+}
+
+// Active returns the span activated for repo's pull request pullNum or nil.
+func (t *Tracer) Active(repo string, pullNum int) *Span {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.active[pullKey(repo, pullNum)]
+}
+
+func (t *Tracer) newSpan(name string, traceID [16]byte, parentID [8]byte, attrs []Attribute) *Span {
+	return &Span{
+		tracer:   t,
+		traceID:  traceID,
+		spanID:   newSpanID(),
+		parentID: parentID,
+		name:     name,
+		kind:     InternalKind,
+		start:    t.now(),
+		attrs:    append([]Attribute{}, attrs...),
+	}
+}
+
+// Span is a timed operation. A nil Span is valid and records nothing so
+// callers don't need to check whether tracing is enabled.
+type Span struct {
+	tracer   *Tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+
+	mu    sync.Mutex
+	kind  SpanKind
+	start time.Time
+	end   time.Time
+	attrs []Attribute
+	err   error
+	ended bool
+}
+
+// Start starts a child span of s.
+func (s *Span) Start(name string, attrs ...Attribute) *Span {
+	if s == nil {
+		return nil
+	}
+	return s.tracer.newSpan(name, s.traceID, s.spanID, attrs)
+}
+
+// SetKind sets the kind of span. Spans are internal by default.
+func (s *Span) SetKind(kind SpanKind) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.kind = kind
+}
+
+// SetAttributes adds attrs to the span.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// RecordError marks the span as failed with err. It does nothing if err is
+// nil.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// End ends the span and queues it for export. Calls after the first are
+// ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = s.tracer.now()
+	s.mu.Unlock()
+	if s.tracer.exporter != nil {
+		s.tracer.exporter.add(s.data())
+	}
+}
+
+// TraceID returns the hex-encoded ID of the span's trace or "" for a nil span.
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// Traceparent returns the W3C trace context header for s so that other
+// processes can continue the trace. It's "" for a nil span.
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(s.traceID[:]), hex.EncodeToString(s.spanID[:]))
+}
+
+func (s *Span) data() spanData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := spanData{
+		TraceID: hex.EncodeToString(s.traceID[:]),
+		SpanID:  hex.EncodeToString(s.spanID[:]),
+		Name:    s.name,
+		Kind:    s.kind,
+		Start:   s.start,
+		End:     s.end,
+		Attrs:   append([]Attribute{}, s.attrs...),
+		Err:     s.err,
+	}
+	if s.parentID != ([8]byte{}) {
+		d.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	return d
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpan returns a copy of ctx containing s.
+func ContextWithSpan(ctx context.Context, s *Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, s)
+}
+
+// SpanFromContext returns the span in ctx or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(spanContextKey{}).(*Span)
+	return s
+}
+
+// parseTraceparent parses a W3C traceparent header, ex.
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func parseTraceparent(h string) (traceID [16]byte, spanID [8]byte, ok bool) {
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return traceID, spanID, false
+	}
+	if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil {
+		return traceID, spanID, false
+	}
+	if _, err := hex.Decode(spanID[:], []byte(parts[2])); err != nil {
+		return traceID, spanID, false
+	}
+	if traceID == ([16]byte{}) || spanID == ([8]byte{}) {
+		return traceID, spanID, false
+	}
+	return traceID, spanID, true
+}
+
+func pullKey(repo string, pullNum int) string {
+	return fmt.Sprintf("%s#%d", repo, pullNum)
+}
+
+func newTraceID() [16]byte {
+	var id [16]byte
+	rand.Read(id[:]) // nolint: errcheck
+	return id
+}
+
+func newSpanID() [8]byte {
+	var id [8]byte
+	rand.Read(id[:]) // nolint: errcheck
+	return id
+}
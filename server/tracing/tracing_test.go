@@ -0,0 +1,139 @@
+package tracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/tracing"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// collector is a fake OTLP/HTTP collector.
+type collector struct {
+	mu      sync.Mutex
+	headers http.Header
+	spans   []map[string]interface{}
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	var req struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []map[string]interface{} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if r.URL.Path != "/v1/traces" || json.Unmarshal(body, &req) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.headers = r.Header
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+}
+
+func TestNilTracer(t *testing.T) {
+	var tracer *tracing.Tracer
+	ctx, span := tracer.Start(context.Background(), "name")
+	Assert(t, span == nil, "expected nil span")
+	Assert(t, tracing.SpanFromContext(ctx) == nil, "expected no span in context")
+	// None of these should panic.
+	child := span.Start("child")
+	child.SetAttributes(tracing.String("key", "value"))
+	child.RecordError(errors.New("err"))
+	child.End()
+	Equals(t, "", span.Traceparent())
+	tracer.Activate(span, "owner/repo", 1)()
+	Ok(t, tracer.Shutdown(context.Background()))
+}
+
+func TestTracer_Start(t *testing.T) {
+	tracer := tracing.NewTracer(tracing.Config{}, logging.NewNoopLogger(t))
+	ctx, root := tracer.Start(context.Background(), "root")
+	Equals(t, root, tracing.SpanFromContext(ctx))
+	_, child := tracer.Start(ctx, "child")
+	Equals(t, root.TraceID(), child.TraceID())
+	Assert(t, strings.HasPrefix(child.Traceparent(), "00-"+root.TraceID()+"-"), "got %s", child.Traceparent())
+
+	_, other := tracer.Start(context.Background(), "other")
+	Assert(t, other.TraceID() != root.TraceID(), "expected a new trace")
+}
+
+func TestTracer_StartRemote(t *testing.T) {
+	tracer := tracing.NewTracer(tracing.Config{}, logging.NewNoopLogger(t))
+	_, s := tracer.StartRemote(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "name")
+	Equals(t, "4bf92f3577b34da6a3ce929d0e0e4736", s.TraceID())
+
+	for _, invalid := range []string{"", "garbage", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"} {
+		_, s := tracer.StartRemote(context.Background(), invalid, "name")
+		Assert(t, s.TraceID() != "4bf92f3577b34da6a3ce929d0e0e4736" && s.TraceID() != "00000000000000000000000000000000", "expected new trace for %q", invalid)
+	}
+}
+
+func TestTracer_Activate(t *testing.T) {
+	tracer := tracing.NewTracer(tracing.Config{}, logging.NewNoopLogger(t))
+	_, s := tracer.Start(context.Background(), "command")
+	deactivate := tracer.Activate(s, "owner/repo", 1)
+	Equals(t, s, tracer.Active("owner/repo", 1))
+	Assert(t, tracer.Active("owner/repo", 2) == nil, "expected no span for other pull")
+
+	// A later command replaces the span and the earlier one deactivating
+	// doesn't remove it.
+	_, s2 := tracer.Start(context.Background(), "command2")
+	deactivate2 := tracer.Activate(s2, "owner/repo", 1)
+	deactivate()
+	Equals(t, s2, tracer.Active("owner/repo", 1))
+	deactivate2()
+	Assert(t, tracer.Active("owner/repo", 1) == nil, "expected no span")
+}
+
+func TestTracer_Exports(t *testing.T) {
+	c := &collector{}
+	server := httptest.NewServer(c)
+	defer server.Close()
+	tracer := tracing.NewTracer(tracing.Config{
+		Endpoint: server.URL,
+		Headers:  map[string]string{"x-api-key": "key"},
+	}, logging.NewNoopLogger(t))
+
+	ctx, root := tracer.Start(context.Background(), "root", tracing.String("repo", "owner/repo"), tracing.Int("pull", 1))
+	root.SetKind(tracing.ServerKind)
+	_, child := tracer.Start(ctx, "child")
+	child.RecordError(errors.New("failed"))
+	child.End()
+	root.End()
+	root.End()
+	Ok(t, tracer.Shutdown(context.Background()))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	Equals(t, "key", c.headers.Get("x-api-key"))
+	Equals(t, 2, len(c.spans))
+	childSpan, rootSpan := c.spans[0], c.spans[1]
+	Equals(t, "child", childSpan["name"])
+	Equals(t, rootSpan["spanId"], childSpan["parentSpanId"])
+	Equals(t, map[string]interface{}{"code": float64(2), "message": "failed"}, childSpan["status"])
+	Equals(t, "root", rootSpan["name"])
+	Equals(t, root.TraceID(), rootSpan["traceId"])
+	Equals(t, float64(tracing.ServerKind), rootSpan["kind"])
+	Equals(t, []interface{}{
+		map[string]interface{}{"key": "repo", "value": map[string]interface{}{"stringValue": "owner/repo"}},
+		map[string]interface{}{"key": "pull", "value": map[string]interface{}{"intValue": "1"}},
+	}, rootSpan["attributes"])
+	_, hasParent := rootSpan["parentSpanId"]
+	Assert(t, !hasParent, "expected root span to have no parent")
+}
@@ -1,66 +1,150 @@
 package server
 
 import (
+	"path/filepath"
+	"strings"
+
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/secrets"
 )
 
 // UserConfig holds config values passed in by the user.
 // The mapstructure tags correspond to flags in cmd/server.go and are used when
 // the config is parsed from a YAML file.
 type UserConfig struct {
+	AgentAddrs                 string `mapstructure:"agent-addrs"`
+	AgentInsecure              bool   `mapstructure:"agent-insecure"`
+	AgentPort                  int    `mapstructure:"agent-port"`
+	AgentToken                 string `mapstructure:"agent-token" secret:"true"`
+	AggregatedComments         bool   `mapstructure:"aggregated-comments"`
 	AllowForkPRs               bool   `mapstructure:"allow-fork-prs"`
 	AllowRepoConfig            bool   `mapstructure:"allow-repo-config"`
 	AtlantisURL                string `mapstructure:"atlantis-url"`
+	AuditLogFile               string `mapstructure:"audit-log-file"`
+	AuditLogSQLURL             string `mapstructure:"audit-log-sql-url" secret:"true"`
+	AuditLogWebhookURL         string `mapstructure:"audit-log-webhook-url" secret:"true"`
+	AuthzToken                 string `mapstructure:"authz-token" secret:"true"`
+	AuthzURL                   string `mapstructure:"authz-url"`
 	Automerge                  bool   `mapstructure:"automerge"`
 	AutoplanFileList           string `mapstructure:"autoplan-file-list"`
-	AzureDevopsToken           string `mapstructure:"azuredevops-token"`
+	AzureDevopsToken           string `mapstructure:"azuredevops-token" secret:"true"`
+	AzureDevopsMaxCommentLen   int    `mapstructure:"azuredevops-max-comment-length"`
 	AzureDevopsUser            string `mapstructure:"azuredevops-user"`
-	AzureDevopsWebhookPassword string `mapstructure:"azuredevops-webhook-password"`
+	AzureDevopsWebhookPassword string `mapstructure:"azuredevops-webhook-password" secret:"true"`
+	AzureDevopsWebhookSecret   string `mapstructure:"azuredevops-webhook-secret" secret:"true"`
 	AzureDevopsWebhookUser     string `mapstructure:"azuredevops-webhook-user"`
+	BitbucketAuthType          string `mapstructure:"bitbucket-auth-type"`
 	BitbucketBaseURL           string `mapstructure:"bitbucket-base-url"`
-	BitbucketToken             string `mapstructure:"bitbucket-token"`
+	BitbucketCodeInsights      bool   `mapstructure:"bitbucket-code-insights"`
+	BitbucketToken             string `mapstructure:"bitbucket-token" secret:"true"`
+	BitbucketMaxCommentLen     int    `mapstructure:"bitbucket-max-comment-length"`
+	BitbucketOAuthKey          string `mapstructure:"bitbucket-oauth-key"`
+	BitbucketOAuthSecret       string `mapstructure:"bitbucket-oauth-secret" secret:"true"`
 	BitbucketUser              string `mapstructure:"bitbucket-user"`
-	BitbucketWebhookSecret     string `mapstructure:"bitbucket-webhook-secret"`
+	BitbucketWebhookSecret     string `mapstructure:"bitbucket-webhook-secret" secret:"true"`
+	BoltDBMaintenanceInterval  string `mapstructure:"boltdb-maintenance-interval"`
+	CheckoutDepth              int    `mapstructure:"checkout-depth"`
 	CheckoutStrategy           string `mapstructure:"checkout-strategy"`
+	CommentOverflow            string `mapstructure:"comment-overflow"`
+	CompactPlanOutput          bool   `mapstructure:"compact-plan-output"`
 	DataDir                    string `mapstructure:"data-dir"`
+	DataDirQuotaMB             int    `mapstructure:"data-dir-quota-mb"`
 	DisableApplyAll            bool   `mapstructure:"disable-apply-all"`
 	DisableApply               bool   `mapstructure:"disable-apply"`
 	DisableAutoplan            bool   `mapstructure:"disable-autoplan"`
+	DisableCrashRecovery       bool   `mapstructure:"disable-crash-recovery"`
 	DisableMarkdownFolding     bool   `mapstructure:"disable-markdown-folding"`
 	DisableRepoLocking         bool   `mapstructure:"disable-repo-locking"`
+	DynamoDBEndpoint           string `mapstructure:"dynamodb-endpoint"`
+	DynamoDBPullTTL            string `mapstructure:"dynamodb-pull-ttl"`
+	DynamoDBRegion             string `mapstructure:"dynamodb-region"`
+	DynamoDBTable              string `mapstructure:"dynamodb-table"`
+	EnableInitCache            bool   `mapstructure:"enable-init-cache"`
 	EnablePolicyChecksFlag     bool   `mapstructure:"enable-policy-checks"`
 	EnableRegExpCmd            bool   `mapstructure:"enable-regexp-cmd"`
+	EnableReplicaCoordination  bool   `mapstructure:"enable-replica-coordination"`
+	GithubDeployments          bool   `mapstructure:"gh-deployments"`
 	GithubHostname             string `mapstructure:"gh-hostname"`
-	GithubToken                string `mapstructure:"gh-token"`
+	GithubToken                string `mapstructure:"gh-token" secret:"true"`
+	GithubMaxCommentLen        int    `mapstructure:"gh-max-comment-length"`
 	GithubUser                 string `mapstructure:"gh-user"`
-	GithubWebhookSecret        string `mapstructure:"gh-webhook-secret"`
+	GithubWebhookSecret        string `mapstructure:"gh-webhook-secret" secret:"true"`
 	GithubOrg                  string `mapstructure:"gh-org"`
 	GithubAppID                int64  `mapstructure:"gh-app-id"`
 	GithubAppKey               string `mapstructure:"gh-app-key-file"`
 	GithubAppSlug              string `mapstructure:"gh-app-slug"`
 	GitlabHostname             string `mapstructure:"gitlab-hostname"`
-	GitlabToken                string `mapstructure:"gitlab-token"`
+	GitlabToken                string `mapstructure:"gitlab-token" secret:"true"`
+	GitlabTokenCheckInterval   string `mapstructure:"gitlab-token-check-interval"`
+	GitlabTokenExpiryWarning   string `mapstructure:"gitlab-token-expiry-warning"`
+	GitlabTokenFile            string `mapstructure:"gitlab-token-file"`
+	GitlabTokenType            string `mapstructure:"gitlab-token-type"`
+	GitlabMaxCommentLen        int    `mapstructure:"gitlab-max-comment-length"`
 	GitlabUser                 string `mapstructure:"gitlab-user"`
-	GitlabWebhookSecret        string `mapstructure:"gitlab-webhook-secret"`
+	GitlabWebhookSecret        string `mapstructure:"gitlab-webhook-secret" secret:"true"`
 	HidePrevPlanComments       bool   `mapstructure:"hide-prev-plan-comments"`
+	KeepUnchangedPlans         bool   `mapstructure:"keep-unchanged-plans"`
+	KubernetesJobTemplate      string `mapstructure:"kubernetes-job-template"`
+	LockingDBType              string `mapstructure:"locking-db-type"`
+	LockTTL                    string `mapstructure:"lock-ttl"`
+	LockTTLAutoRelease         bool   `mapstructure:"lock-ttl-auto-release"`
+	LogFormat                  string `mapstructure:"log-format"`
 	LogLevel                   string `mapstructure:"log-level"`
+	MarkdownTemplatesDir       string `mapstructure:"markdown-templates-dir"`
+	OIDCSigningKeyFile         string `mapstructure:"oidc-signing-key-file"`
+	OrphanCleanupInterval      string `mapstructure:"orphan-cleanup-interval"`
 	ParallelPoolSize           int    `mapstructure:"parallel-pool-size"`
 	PlanDrafts                 bool   `mapstructure:"allow-draft-prs"`
+	PlanCompression            string `mapstructure:"plan-compression"`
+	PlanEncryptionKey          string `mapstructure:"plan-encryption-key" secret:"true"`
+	PlanEncryptionKMSKey       string `mapstructure:"plan-encryption-kms-data-key"`
+	PlanJSONArtifacts          bool   `mapstructure:"plan-json-artifacts"`
+	PlanStoreURL               string `mapstructure:"plan-store-url"`
 	Port                       int    `mapstructure:"port"`
+	PostgresURL                string `mapstructure:"postgres-url" secret:"true"`
+	ProgressCommentInterval    string `mapstructure:"progress-comment-interval"`
+	ProjectConcurrencyLimit    int    `mapstructure:"project-concurrency-limit"`
+	RedisAddrs                 string `mapstructure:"redis-addrs"`
+	RedisCluster               bool   `mapstructure:"redis-cluster"`
+	RedisDB                    int    `mapstructure:"redis-db"`
+	RedisInsecureSkipVerify    bool   `mapstructure:"redis-insecure-skip-verify"`
+	RedisPassword              string `mapstructure:"redis-password" secret:"true"`
+	RedisPoolSize              int    `mapstructure:"redis-pool-size"`
+	RedisSentinelMaster        string `mapstructure:"redis-sentinel-master"`
+	RedisTLSEnabled            bool   `mapstructure:"redis-tls-enabled"`
+	RepoConcurrencyLimit       int    `mapstructure:"repo-concurrency-limit"`
 	RepoConfig                 string `mapstructure:"repo-config"`
 	RepoConfigJSON             string `mapstructure:"repo-config-json"`
 	RepoAllowlist              string `mapstructure:"repo-allowlist"`
 	// RepoWhitelist is deprecated in favour of RepoAllowlist.
 	RepoWhitelist string `mapstructure:"repo-whitelist"`
 
+	// AzureDevopsClosePlanThreads is whether to close the comment threads of
+	// plans once they're all applied.
+	AzureDevopsClosePlanThreads bool `mapstructure:"azuredevops-close-plan-threads"`
+	// DeletePRWorkspaces is whether to delete the workspaces created with
+	// atlantis workspace new when their pull request is closed.
+	DeletePRWorkspaces bool `mapstructure:"delete-pr-workspaces"`
 	// RequireApproval is whether to require pull request approval before
 	// allowing terraform apply's to be run.
 	RequireApproval bool `mapstructure:"require-approval"`
 	// RequireMergeable is whether to require pull requests to be mergeable before
 	// allowing terraform apply's to run.
 	RequireMergeable bool `mapstructure:"require-mergeable"`
+	// RunStepContainerImage is the Docker image custom run steps are run in
+	// when their workflow doesn't set one. If empty, they run on the host.
+	RunStepContainerImage string `mapstructure:"run-step-container-image"`
+	// ShutdownTimeout is how long in-progress operations have to complete on
+	// shutdown before they're aborted. If empty, they aren't aborted.
+	ShutdownTimeout string `mapstructure:"shutdown-timeout"`
+	// SecretsRefreshInterval is how often the references to secrets are
+	// resolved again to pick up rotated secrets.
+	SecretsRefreshInterval string `mapstructure:"secrets-refresh-interval"`
 	// SilenceNoProjects is whether Atlantis should respond to a PR if no projects are found.
 	SilenceNoProjects bool `mapstructure:"silence-no-projects"`
+	// ValidateBeforeAutoplan is whether to run terraform validate before
+	// autoplanning and skip autoplan if any project is invalid.
+	ValidateBeforeAutoplan bool `mapstructure:"validate-before-autoplan"`
 	// RequireUnDiverged is whether to require pull requests to rebase default branch before
 	// allowing terraform apply's to run.
 	RequireUnDiverged   bool `mapstructure:"require-undiverged"`
@@ -75,16 +159,138 @@ type UserConfig struct {
 	// SilenceWhitelistErrors is deprecated in favour of SilenceAllowlistErrors
 	SilenceWhitelistErrors bool            `mapstructure:"silence-whitelist-errors"`
 	SkipCloneNoChanges     bool            `mapstructure:"skip-clone-no-changes"`
-	SlackToken             string          `mapstructure:"slack-token"`
+	SlackToken             string          `mapstructure:"slack-token" secret:"true"`
+	SparseCheckout         bool            `mapstructure:"sparse-checkout"`
 	SSLCertFile            string          `mapstructure:"ssl-cert-file"`
 	SSLKeyFile             string          `mapstructure:"ssl-key-file"`
+	StepCgroupDir          string          `mapstructure:"step-cgroup-dir"`
+	StepCPULimit           string          `mapstructure:"step-cpu-limit"`
+	StepIdleTimeout        string          `mapstructure:"step-idle-timeout"`
+	StepMaxOutputBytes     int             `mapstructure:"step-max-output-bytes"`
+	StepMemoryLimitMB      int             `mapstructure:"step-memory-limit-mb"`
+	StepTimeout            string          `mapstructure:"step-timeout"`
+	TFDownloadGPGKeyFile   string          `mapstructure:"tf-download-gpg-key-file"`
 	TFDownloadURL          string          `mapstructure:"tf-download-url"`
+	TFPluginCacheDir       string          `mapstructure:"tf-plugin-cache-dir"`
 	TFEHostname            string          `mapstructure:"tfe-hostname"`
-	TFEToken               string          `mapstructure:"tfe-token"`
+	TFEToken               string          `mapstructure:"tfe-token" secret:"true"`
+	TracingOTLPEndpoint    string          `mapstructure:"tracing-otlp-endpoint"`
+	TracingOTLPHeaders     string          `mapstructure:"tracing-otlp-headers" secret:"true"`
+	TracingServiceName     string          `mapstructure:"tracing-service-name"`
+	VaultAddr              string          `mapstructure:"vault-addr"`
+	VaultAuthMethod        string          `mapstructure:"vault-auth-method"`
+	VaultAuthMount         string          `mapstructure:"vault-auth-mount"`
+	VaultNamespace         string          `mapstructure:"vault-namespace"`
+	VaultRole              string          `mapstructure:"vault-role"`
+	VaultSecretID          string          `mapstructure:"vault-secret-id" secret:"true"`
+	VaultToken             string          `mapstructure:"vault-token" secret:"true"`
 	VCSStatusName          string          `mapstructure:"vcs-status-name"`
 	DefaultTFVersion       string          `mapstructure:"default-tf-version"`
+	WebOIDCAdminGroups     string          `mapstructure:"web-oidc-admin-groups"`
+	WebOIDCAllowedGroups   string          `mapstructure:"web-oidc-allowed-groups"`
+	WebOIDCClientID        string          `mapstructure:"web-oidc-client-id"`
+	WebOIDCClientSecret    string          `mapstructure:"web-oidc-client-secret" secret:"true"`
+	WebOIDCGroupsClaim     string          `mapstructure:"web-oidc-groups-claim"`
+	WebOIDCIssuerURL       string          `mapstructure:"web-oidc-issuer-url"`
 	Webhooks               []WebhookConfig `mapstructure:"webhooks"`
+	WebhookQueueSize       int             `mapstructure:"webhook-queue-size"`
+	WebhookRateBurst       int             `mapstructure:"webhook-rate-burst"`
+	WebhookRateLimit       int             `mapstructure:"webhook-rate-limit"`
+	WebhookRepoRateBurst   int             `mapstructure:"webhook-repo-rate-burst"`
+	WebhookRepoRateLimit   int             `mapstructure:"webhook-repo-rate-limit"`
+	WebhookReplayRetention string          `mapstructure:"webhook-replay-retention"`
+	WebhookWorkers         int             `mapstructure:"webhook-workers"`
 	WriteGitCreds          bool            `mapstructure:"write-git-creds"`
+	// APITokens can only be set in the config file.
+	APITokens []APITokenConfig `mapstructure:"api-tokens"`
+	// MaintenanceWindows can only be set in the config file.
+	MaintenanceWindows []MaintenanceWindowConfig `mapstructure:"maintenance-windows"`
+	// ErrorHints can only be set in the config file.
+	ErrorHints []ErrorHintConfig `mapstructure:"error-hints"`
+	// DriftDetection can only be set in the config file.
+	DriftDetection []DriftCheckConfig `mapstructure:"drift-detection"`
+	// AzureDevopsOrgs can only be set in the config file.
+	AzureDevopsOrgs []AzureDevopsOrgConfig `mapstructure:"azuredevops-orgs"`
+	// Tenants can only be set in the config file.
+	Tenants []TenantConfig `mapstructure:"tenants"`
+	// SecretRefs are the fields that referred to secrets and were resolved
+	// when the config was loaded. They're set by the server command.
+	SecretRefs []secrets.Ref `mapstructure:"-"`
+}
+
+// ForTenant returns the config of tenant t. The settings that t can set
+// replace the top-level ones, even if t leaves them unset, so that no
+// credentials, allowlists or notifications are shared between tenants. The
+// other settings are inherited. The VCS hostnames, the Bitbucket auth type and
+// the GitLab token type default to the top-level ones.
+func (u UserConfig) ForTenant(t TenantConfig) UserConfig {
+	c := u
+	c.Tenants = nil
+	c.AtlantisURL = strings.TrimSuffix(u.AtlantisURL, "/") + TenantPathPrefix + t.Name
+	c.DataDir = filepath.Join(u.DataDir, TenantsDirName, t.Name)
+
+	c.APITokens = t.APITokens
+	c.AuditLogFile = t.AuditLogFile
+	c.AuditLogSQLURL = t.AuditLogSQLURL
+	c.AuditLogWebhookURL = t.AuditLogWebhookURL
+	c.AzureDevopsOrgs = t.AzureDevopsOrgs
+	c.AzureDevopsToken = t.AzureDevopsToken
+	c.AzureDevopsUser = t.AzureDevopsUser
+	c.AzureDevopsWebhookPassword = t.AzureDevopsWebhookPassword
+	c.AzureDevopsWebhookSecret = t.AzureDevopsWebhookSecret
+	c.AzureDevopsWebhookUser = t.AzureDevopsWebhookUser
+	c.BitbucketOAuthKey = t.BitbucketOAuthKey
+	c.BitbucketOAuthSecret = t.BitbucketOAuthSecret
+	c.BitbucketToken = t.BitbucketToken
+	c.BitbucketUser = t.BitbucketUser
+	c.BitbucketWebhookSecret = t.BitbucketWebhookSecret
+	c.GithubAppID = t.GithubAppID
+	c.GithubAppKey = t.GithubAppKey
+	c.GithubAppSlug = t.GithubAppSlug
+	c.GithubOrg = t.GithubOrg
+	c.GithubToken = t.GithubToken
+	c.GithubUser = t.GithubUser
+	c.GithubWebhookSecret = t.GithubWebhookSecret
+	c.GitlabToken = t.GitlabToken
+	c.GitlabTokenFile = t.GitlabTokenFile
+	c.GitlabUser = t.GitlabUser
+	c.GitlabWebhookSecret = t.GitlabWebhookSecret
+	c.RepoAllowlist = t.RepoAllowlist
+	c.RepoWhitelist = ""
+	c.RepoConfig = t.RepoConfig
+	c.RepoConfigJSON = t.RepoConfigJSON
+	c.SlackToken = t.SlackToken
+	c.TFEToken = t.TFEToken
+	c.Webhooks = t.Webhooks
+	// Agents run commands with the top-level config so tenants run their
+	// commands themselves.
+	c.AgentAddrs = ""
+	c.AgentPort = 0
+	// Drift checks are of the top-level repos.
+	c.DriftDetection = nil
+	// Secrets are refreshed by the top-level server, which rotates the
+	// tenant's with its hooks.
+	c.SecretRefs = nil
+
+	if t.BitbucketAuthType != "" {
+		c.BitbucketAuthType = t.BitbucketAuthType
+	}
+	if t.BitbucketBaseURL != "" {
+		c.BitbucketBaseURL = t.BitbucketBaseURL
+	}
+	if t.GithubHostname != "" {
+		c.GithubHostname = t.GithubHostname
+	}
+	if t.GitlabHostname != "" {
+		c.GitlabHostname = t.GitlabHostname
+	}
+	if t.GitlabTokenType != "" {
+		c.GitlabTokenType = t.GitlabTokenType
+	}
+	if t.TFEHostname != "" {
+		c.TFEHostname = t.TFEHostname
+	}
+	return c
 }
 
 // ToLogLevel returns the LogLevel object corresponding to the user-passed
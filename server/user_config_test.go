@@ -44,3 +44,58 @@ func TestUserConfig_ToLogLevel(t *testing.T) {
 		})
 	}
 }
+
+func TestUserConfig_ForTenant(t *testing.T) {
+	u := server.UserConfig{
+		AtlantisURL:         "https://atlantis.example.com/",
+		BitbucketAuthType:   "oauth",
+		BitbucketOAuthKey:   "top-level-key",
+		DataDir:             "/data",
+		DefaultTFVersion:    "0.14.0",
+		GithubHostname:      "github.com",
+		GithubToken:         "top-level-token",
+		GithubUser:          "top-level-user",
+		GithubWebhookSecret: "top-level-secret",
+		GitlabTokenFile:     "/etc/atlantis/gitlab-token",
+		GitlabTokenType:     "personal",
+		RepoAllowlist:       "github.com/*",
+		RepoConfig:          "/etc/atlantis/repos.yaml",
+		SlackToken:          "top-level-slack",
+		APITokens:           []server.APITokenConfig{{Name: "ci", Token: "top-level-api-token"}},
+		AzureDevopsOrgs:     []server.AzureDevopsOrgConfig{{Name: "org", User: "top-level-user", Token: "top-level-token"}},
+		DriftDetection:      []server.DriftCheckConfig{{Name: "prod"}},
+		Tenants:             []server.TenantConfig{{Name: "acme"}},
+	}
+	tenant := server.TenantConfig{
+		Name:            "acme",
+		GitlabHostname:  "gitlab.acme.com",
+		GitlabToken:     "acme-token",
+		GitlabTokenType: "group",
+		GitlabUser:      "acme-user",
+		RepoAllowlist:   "gitlab.acme.com/*",
+	}
+
+	c := u.ForTenant(tenant)
+	Equals(t, "https://atlantis.example.com/tenants/acme", c.AtlantisURL)
+	Equals(t, "/data/tenants/acme", c.DataDir)
+	Equals(t, "gitlab.acme.com/*", c.RepoAllowlist)
+	Equals(t, "acme-user", c.GitlabUser)
+	Equals(t, "gitlab.acme.com", c.GitlabHostname)
+	Equals(t, "group", c.GitlabTokenType)
+	// Settings that aren't set by tenants are inherited.
+	Equals(t, "0.14.0", c.DefaultTFVersion)
+	Equals(t, "github.com", c.GithubHostname)
+	Equals(t, "oauth", c.BitbucketAuthType)
+	// Credentials, repo configs and notifications aren't.
+	Equals(t, "", c.GithubUser)
+	Equals(t, "", c.GithubToken)
+	Equals(t, "", c.GithubWebhookSecret)
+	Equals(t, "", c.GitlabTokenFile)
+	Equals(t, "", c.BitbucketOAuthKey)
+	Equals(t, "", c.RepoConfig)
+	Equals(t, "", c.SlackToken)
+	Equals(t, 0, len(c.APITokens))
+	Equals(t, 0, len(c.AzureDevopsOrgs))
+	Equals(t, 0, len(c.DriftDetection))
+	Equals(t, 0, len(c.Tenants))
+}
@@ -0,0 +1,103 @@
+// Package workerpool runs jobs on a bounded number of goroutines, ex. the
+// commands triggered by webhooks.
+package workerpool
+
+import (
+	"sync"
+)
+
+// Pool runs jobs on a fixed number of workers. Jobs wait in a bounded queue
+// until a worker is free. Once the queue is full, new jobs are rejected so
+// callers can push back, ex. by asking for the webhook to be retried later.
+// A nil Pool runs each job in its own goroutine. It's safe for concurrent use.
+type Pool struct {
+	workers int
+	jobs    chan func()
+
+	// mutex guards the counts.
+	mutex     sync.Mutex
+	running   int
+	completed int64
+	rejected  int64
+}
+
+// Stats are the current state of a Pool.
+type Stats struct {
+	Workers   int `json:"workers"`
+	QueueSize int `json:"queue_size"`
+	// Running is the number of jobs being run and Queued the number waiting
+	// for a free worker.
+	Running int `json:"running"`
+	Queued  int `json:"queued"`
+	// Completed and Rejected are the number of jobs run and rejected because
+	// the queue was full since the pool was created.
+	Completed int64 `json:"completed"`
+	Rejected  int64 `json:"rejected"`
+}
+
+// New returns a pool running jobs on workers goroutines, with up to
+// queueSize jobs waiting for them. If workers is 0, it returns nil so jobs
+// aren't limited.
+func New(workers int, queueSize int) *Pool {
+	if workers <= 0 {
+		return nil
+	}
+	p := &Pool{
+		workers: workers,
+		jobs:    make(chan func(), queueSize),
+	}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// Submit queues job to be run by the next free worker. It returns false
+// without running job if the queue is full.
+func (p *Pool) Submit(job func()) bool {
+	if p == nil {
+		go job()
+		return true
+	}
+	select {
+	case p.jobs <- job:
+		return true
+	default:
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+		p.rejected++
+		return false
+	}
+}
+
+// Stats returns the current state of the pool. A nil Pool has none.
+func (p *Pool) Stats() *Stats {
+	if p == nil {
+		return nil
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return &Stats{
+		Workers:   p.workers,
+		QueueSize: cap(p.jobs),
+		Running:   p.running,
+		Queued:    len(p.jobs),
+		Completed: p.completed,
+		Rejected:  p.rejected,
+	}
+}
+
+func (p *Pool) work() {
+	for job := range p.jobs {
+		p.mutex.Lock()
+		p.running++
+		p.mutex.Unlock()
+
+		job()
+
+		p.mutex.Lock()
+		p.running--
+		p.completed++
+		p.mutex.Unlock()
+	}
+}
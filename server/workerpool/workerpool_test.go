@@ -0,0 +1,70 @@
+package workerpool_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/workerpool"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestNew_Unbounded(t *testing.T) {
+	p := workerpool.New(0, 10)
+	Assert(t, p == nil, "expected nil pool")
+	Assert(t, p.Stats() == nil, "expected no stats")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		Equals(t, true, p.Submit(wg.Done))
+	}
+	wg.Wait()
+}
+
+func TestPool_Submit(t *testing.T) {
+	p := workerpool.New(1, 1)
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	job := func() {
+		<-release
+		wg.Done()
+	}
+
+	wg.Add(1)
+	Equals(t, true, p.Submit(job))
+	waitFor(t, func() bool { return p.Stats().Running == 1 })
+	// The second job waits for the worker and the third doesn't fit in the
+	// queue.
+	wg.Add(1)
+	Equals(t, true, p.Submit(job))
+	Equals(t, false, p.Submit(job))
+	Equals(t, &workerpool.Stats{
+		Workers:   1,
+		QueueSize: 1,
+		Running:   1,
+		Queued:    1,
+		Rejected:  1,
+	}, p.Stats())
+
+	close(release)
+	wg.Wait()
+	waitFor(t, func() bool { return p.Stats().Completed == 2 })
+	Equals(t, &workerpool.Stats{
+		Workers:   1,
+		QueueSize: 1,
+		Completed: 2,
+		Rejected:  1,
+	}, p.Stats())
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}